@@ -0,0 +1,333 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: agent/v1/agent.proto
+
+package agentv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AgentService_Register_FullMethodName       = "/agent.v1.AgentService/Register"
+	AgentService_Heartbeat_FullMethodName      = "/agent.v1.AgentService/Heartbeat"
+	AgentService_Unregister_FullMethodName     = "/agent.v1.AgentService/Unregister"
+	AgentService_ReceiveTasks_FullMethodName   = "/agent.v1.AgentService/ReceiveTasks"
+	AgentService_ReportProgress_FullMethodName = "/agent.v1.AgentService/ReportProgress"
+)
+
+// AgentServiceClient is the client API for AgentService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentServiceClient interface {
+	// Register 注册Agent，同名Agent已注册时更新其能力、端点和元数据（Agent重启后重新注册）
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Heartbeat 心跳，超过心跳间隔未上报的Agent视为不健康
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	// Unregister 注销Agent
+	Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*UnregisterResponse, error)
+	// ReceiveTasks 接收分配给该Agent的任务，连接期间Agent可被调度，连接断开后Agent变为inactive
+	// 同一Agent只保留最新的连接；执行中的任务被取消时发送cancel事件
+	ReceiveTasks(ctx context.Context, in *ReceiveTasksRequest, opts ...grpc.CallOption) (AgentService_ReceiveTasksClient, error)
+	// ReportProgress 上报任务进度，最后一条消息携带结果或错误；每个任务使用一个流
+	// 任务已不在该Agent上执行（被取消、超时或已上报结果）时以NOT_FOUND结束
+	ReportProgress(ctx context.Context, opts ...grpc.CallOption) (AgentService_ReportProgressClient, error)
+}
+
+type agentServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentServiceClient(cc grpc.ClientConnInterface) AgentServiceClient {
+	return &agentServiceClient{cc}
+}
+
+func (c *agentServiceClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, AgentService_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, AgentService_Heartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*UnregisterResponse, error) {
+	out := new(UnregisterResponse)
+	err := c.cc.Invoke(ctx, AgentService_Unregister_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) ReceiveTasks(ctx context.Context, in *ReceiveTasksRequest, opts ...grpc.CallOption) (AgentService_ReceiveTasksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentService_ServiceDesc.Streams[0], AgentService_ReceiveTasks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentServiceReceiveTasksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AgentService_ReceiveTasksClient interface {
+	Recv() (*TaskEvent, error)
+	grpc.ClientStream
+}
+
+type agentServiceReceiveTasksClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentServiceReceiveTasksClient) Recv() (*TaskEvent, error) {
+	m := new(TaskEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentServiceClient) ReportProgress(ctx context.Context, opts ...grpc.CallOption) (AgentService_ReportProgressClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentService_ServiceDesc.Streams[1], AgentService_ReportProgress_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentServiceReportProgressClient{stream}
+	return x, nil
+}
+
+type AgentService_ReportProgressClient interface {
+	Send(*TaskProgress) error
+	CloseAndRecv() (*ReportProgressResponse, error)
+	grpc.ClientStream
+}
+
+type agentServiceReportProgressClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentServiceReportProgressClient) Send(m *TaskProgress) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *agentServiceReportProgressClient) CloseAndRecv() (*ReportProgressResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ReportProgressResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentServiceServer is the server API for AgentService service.
+// All implementations must embed UnimplementedAgentServiceServer
+// for forward compatibility
+type AgentServiceServer interface {
+	// Register 注册Agent，同名Agent已注册时更新其能力、端点和元数据（Agent重启后重新注册）
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Heartbeat 心跳，超过心跳间隔未上报的Agent视为不健康
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// Unregister 注销Agent
+	Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error)
+	// ReceiveTasks 接收分配给该Agent的任务，连接期间Agent可被调度，连接断开后Agent变为inactive
+	// 同一Agent只保留最新的连接；执行中的任务被取消时发送cancel事件
+	ReceiveTasks(*ReceiveTasksRequest, AgentService_ReceiveTasksServer) error
+	// ReportProgress 上报任务进度，最后一条消息携带结果或错误；每个任务使用一个流
+	// 任务已不在该Agent上执行（被取消、超时或已上报结果）时以NOT_FOUND结束
+	ReportProgress(AgentService_ReportProgressServer) error
+	mustEmbedUnimplementedAgentServiceServer()
+}
+
+// UnimplementedAgentServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAgentServiceServer struct {
+}
+
+func (UnimplementedAgentServiceServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedAgentServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedAgentServiceServer) Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unregister not implemented")
+}
+func (UnimplementedAgentServiceServer) ReceiveTasks(*ReceiveTasksRequest, AgentService_ReceiveTasksServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReceiveTasks not implemented")
+}
+func (UnimplementedAgentServiceServer) ReportProgress(AgentService_ReportProgressServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReportProgress not implemented")
+}
+func (UnimplementedAgentServiceServer) mustEmbedUnimplementedAgentServiceServer() {}
+
+// UnsafeAgentServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentServiceServer will
+// result in compilation errors.
+type UnsafeAgentServiceServer interface {
+	mustEmbedUnimplementedAgentServiceServer()
+}
+
+func RegisterAgentServiceServer(s grpc.ServiceRegistrar, srv AgentServiceServer) {
+	s.RegisterService(&AgentService_ServiceDesc, srv)
+}
+
+func _AgentService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_Unregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).Unregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentService_Unregister_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).Unregister(ctx, req.(*UnregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_ReceiveTasks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReceiveTasksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServiceServer).ReceiveTasks(m, &agentServiceReceiveTasksServer{stream})
+}
+
+type AgentService_ReceiveTasksServer interface {
+	Send(*TaskEvent) error
+	grpc.ServerStream
+}
+
+type agentServiceReceiveTasksServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentServiceReceiveTasksServer) Send(m *TaskEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AgentService_ReportProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentServiceServer).ReportProgress(&agentServiceReportProgressServer{stream})
+}
+
+type AgentService_ReportProgressServer interface {
+	SendAndClose(*ReportProgressResponse) error
+	Recv() (*TaskProgress, error)
+	grpc.ServerStream
+}
+
+type agentServiceReportProgressServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentServiceReportProgressServer) SendAndClose(m *ReportProgressResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *agentServiceReportProgressServer) Recv() (*TaskProgress, error) {
+	m := new(TaskProgress)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentService_ServiceDesc is the grpc.ServiceDesc for AgentService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agent.v1.AgentService",
+	HandlerType: (*AgentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _AgentService_Register_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _AgentService_Heartbeat_Handler,
+		},
+		{
+			MethodName: "Unregister",
+			Handler:    _AgentService_Unregister_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReceiveTasks",
+			Handler:       _AgentService_ReceiveTasks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReportProgress",
+			Handler:       _AgentService_ReportProgress_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "agent/v1/agent.proto",
+}
@@ -0,0 +1,336 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: assistant/v1/assistant.proto
+
+package assistantv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AssistantService_Chat_FullMethodName            = "/assistant.v1.AssistantService/Chat"
+	AssistantService_StreamChat_FullMethodName      = "/assistant.v1.AssistantService/StreamChat"
+	AssistantService_SubmitTask_FullMethodName      = "/assistant.v1.AssistantService/SubmitTask"
+	AssistantService_GetTask_FullMethodName         = "/assistant.v1.AssistantService/GetTask"
+	AssistantService_ExecuteWorkflow_FullMethodName = "/assistant.v1.AssistantService/ExecuteWorkflow"
+	AssistantService_SearchKnowledge_FullMethodName = "/assistant.v1.AssistantService/SearchKnowledge"
+)
+
+// AssistantServiceClient is the client API for AssistantService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AssistantServiceClient interface {
+	// Chat 单轮对话，对应 POST /api/v1/chat
+	Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	// StreamChat 流式对话，对应 POST /api/v1/chat/stream
+	// 依次返回start、token、usage、done事件，出错时以gRPC状态结束
+	StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (AssistantService_StreamChatClient, error)
+	// SubmitTask 提交任务并在后台执行，对应 POST /api/v1/tasks
+	SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// GetTask 查询任务状态和结果，对应 GET /api/v1/tasks/:id
+	GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// ExecuteWorkflow 执行工作流，对应 POST /api/v1/workflows/:id/execute
+	ExecuteWorkflow(ctx context.Context, in *ExecuteWorkflowRequest, opts ...grpc.CallOption) (*WorkflowExecution, error)
+	// SearchKnowledge 知识库检索，对应 POST /api/v1/knowledge/search
+	SearchKnowledge(ctx context.Context, in *SearchKnowledgeRequest, opts ...grpc.CallOption) (*SearchKnowledgeResponse, error)
+}
+
+type assistantServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAssistantServiceClient(cc grpc.ClientConnInterface) AssistantServiceClient {
+	return &assistantServiceClient{cc}
+}
+
+func (c *assistantServiceClient) Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, AssistantService_Chat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assistantServiceClient) StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (AssistantService_StreamChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AssistantService_ServiceDesc.Streams[0], AssistantService_StreamChat_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &assistantServiceStreamChatClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AssistantService_StreamChatClient interface {
+	Recv() (*ChatEvent, error)
+	grpc.ClientStream
+}
+
+type assistantServiceStreamChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *assistantServiceStreamChatClient) Recv() (*ChatEvent, error) {
+	m := new(ChatEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *assistantServiceClient) SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, AssistantService_SubmitTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assistantServiceClient) GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, AssistantService_GetTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assistantServiceClient) ExecuteWorkflow(ctx context.Context, in *ExecuteWorkflowRequest, opts ...grpc.CallOption) (*WorkflowExecution, error) {
+	out := new(WorkflowExecution)
+	err := c.cc.Invoke(ctx, AssistantService_ExecuteWorkflow_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assistantServiceClient) SearchKnowledge(ctx context.Context, in *SearchKnowledgeRequest, opts ...grpc.CallOption) (*SearchKnowledgeResponse, error) {
+	out := new(SearchKnowledgeResponse)
+	err := c.cc.Invoke(ctx, AssistantService_SearchKnowledge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AssistantServiceServer is the server API for AssistantService service.
+// All implementations must embed UnimplementedAssistantServiceServer
+// for forward compatibility
+type AssistantServiceServer interface {
+	// Chat 单轮对话，对应 POST /api/v1/chat
+	Chat(context.Context, *ChatRequest) (*ChatResponse, error)
+	// StreamChat 流式对话，对应 POST /api/v1/chat/stream
+	// 依次返回start、token、usage、done事件，出错时以gRPC状态结束
+	StreamChat(*ChatRequest, AssistantService_StreamChatServer) error
+	// SubmitTask 提交任务并在后台执行，对应 POST /api/v1/tasks
+	SubmitTask(context.Context, *SubmitTaskRequest) (*Task, error)
+	// GetTask 查询任务状态和结果，对应 GET /api/v1/tasks/:id
+	GetTask(context.Context, *GetTaskRequest) (*Task, error)
+	// ExecuteWorkflow 执行工作流，对应 POST /api/v1/workflows/:id/execute
+	ExecuteWorkflow(context.Context, *ExecuteWorkflowRequest) (*WorkflowExecution, error)
+	// SearchKnowledge 知识库检索，对应 POST /api/v1/knowledge/search
+	SearchKnowledge(context.Context, *SearchKnowledgeRequest) (*SearchKnowledgeResponse, error)
+	mustEmbedUnimplementedAssistantServiceServer()
+}
+
+// UnimplementedAssistantServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAssistantServiceServer struct {
+}
+
+func (UnimplementedAssistantServiceServer) Chat(context.Context, *ChatRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedAssistantServiceServer) StreamChat(*ChatRequest, AssistantService_StreamChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamChat not implemented")
+}
+func (UnimplementedAssistantServiceServer) SubmitTask(context.Context, *SubmitTaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTask not implemented")
+}
+func (UnimplementedAssistantServiceServer) GetTask(context.Context, *GetTaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedAssistantServiceServer) ExecuteWorkflow(context.Context, *ExecuteWorkflowRequest) (*WorkflowExecution, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecuteWorkflow not implemented")
+}
+func (UnimplementedAssistantServiceServer) SearchKnowledge(context.Context, *SearchKnowledgeRequest) (*SearchKnowledgeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchKnowledge not implemented")
+}
+func (UnimplementedAssistantServiceServer) mustEmbedUnimplementedAssistantServiceServer() {}
+
+// UnsafeAssistantServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AssistantServiceServer will
+// result in compilation errors.
+type UnsafeAssistantServiceServer interface {
+	mustEmbedUnimplementedAssistantServiceServer()
+}
+
+func RegisterAssistantServiceServer(s grpc.ServiceRegistrar, srv AssistantServiceServer) {
+	s.RegisterService(&AssistantService_ServiceDesc, srv)
+}
+
+func _AssistantService_Chat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssistantServiceServer).Chat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssistantService_Chat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssistantServiceServer).Chat(ctx, req.(*ChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssistantService_StreamChat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AssistantServiceServer).StreamChat(m, &assistantServiceStreamChatServer{stream})
+}
+
+type AssistantService_StreamChatServer interface {
+	Send(*ChatEvent) error
+	grpc.ServerStream
+}
+
+type assistantServiceStreamChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *assistantServiceStreamChatServer) Send(m *ChatEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AssistantService_SubmitTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssistantServiceServer).SubmitTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssistantService_SubmitTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssistantServiceServer).SubmitTask(ctx, req.(*SubmitTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssistantService_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssistantServiceServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssistantService_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssistantServiceServer).GetTask(ctx, req.(*GetTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssistantService_ExecuteWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssistantServiceServer).ExecuteWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssistantService_ExecuteWorkflow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssistantServiceServer).ExecuteWorkflow(ctx, req.(*ExecuteWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssistantService_SearchKnowledge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchKnowledgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssistantServiceServer).SearchKnowledge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssistantService_SearchKnowledge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssistantServiceServer).SearchKnowledge(ctx, req.(*SearchKnowledgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AssistantService_ServiceDesc is the grpc.ServiceDesc for AssistantService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AssistantService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "assistant.v1.AssistantService",
+	HandlerType: (*AssistantServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Chat",
+			Handler:    _AssistantService_Chat_Handler,
+		},
+		{
+			MethodName: "SubmitTask",
+			Handler:    _AssistantService_SubmitTask_Handler,
+		},
+		{
+			MethodName: "GetTask",
+			Handler:    _AssistantService_GetTask_Handler,
+		},
+		{
+			MethodName: "ExecuteWorkflow",
+			Handler:    _AssistantService_ExecuteWorkflow_Handler,
+		},
+		{
+			MethodName: "SearchKnowledge",
+			Handler:    _AssistantService_SearchKnowledge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamChat",
+			Handler:       _AssistantService_StreamChat_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "assistant/v1/assistant.proto",
+}
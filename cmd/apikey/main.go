@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/auth"
+)
+
+// apikey 生成API Key
+// 指定-store时写入file存储，否则只打印哈希，供填入配置文件的auth.api_keys.keys
+func main() {
+	name := flag.String("name", "", "Key名称（必填）")
+	scopes := flag.String("scopes", auth.ScopeChat, "权限范围，逗号分隔：chat, knowledge:write, workflows:admin, tools:execute, *")
+	ttl := flag.Duration("ttl", 0, "有效期，如720h，0表示永久有效")
+	store := flag.String("store", "", "file存储路径，如./data/api_keys.json")
+	tenant := flag.String("tenant", "", "绑定的租户，启用多租户时该Key只能访问此租户的数据")
+	flag.Parse()
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	var scopeList []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopeList = append(scopeList, scope)
+		}
+	}
+
+	plaintext, key, err := auth.GenerateAPIKey(*name, scopeList, *ttl)
+	if err != nil {
+		log.Fatalf("Failed to generate api key: %v", err)
+	}
+	key.Tenant = *tenant
+
+	if *store != "" {
+		keyStore, err := auth.NewFileKeyStore(*store)
+		if err != nil {
+			log.Fatalf("Failed to open key store: %v", err)
+		}
+		if err := keyStore.Save(context.Background(), key); err != nil {
+			log.Fatalf("Failed to save api key: %v", err)
+		}
+		fmt.Printf("Saved %s to %s\n", key.ID, *store)
+	}
+
+	fmt.Printf("API Key: %s\n", plaintext)
+	fmt.Printf("Hash:    %s\n", key.Hash)
+	fmt.Printf("Scopes:  %s\n", strings.Join(key.Scopes, ", "))
+	if key.Tenant != "" {
+		fmt.Printf("Tenant:  %s\n", key.Tenant)
+	}
+	if key.ExpiresAt != nil {
+		fmt.Printf("Expires: %s\n", key.ExpiresAt.Format(time.RFC3339))
+	}
+	fmt.Println("请妥善保存API Key，明文不会再次显示")
+}
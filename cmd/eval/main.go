@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	aiagentexpert "ai-agent-assistant/internal/agent/expert"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/eval"
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/rag"
+	"ai-agent-assistant/internal/rag/adaptive"
+	rageval "ai-agent-assistant/internal/rag/eval"
+	"ai-agent-assistant/internal/secrets"
+	aiagenttask "ai-agent-assistant/internal/task"
+	aitools "ai-agent-assistant/internal/tools"
+	"ai-agent-assistant/internal/workflow"
+)
+
+// eval 离线评估：加载数据集，执行对话、RAG或工作流流水线，输出JSON/HTML报告
+// 指定 -trajectories 时不执行流水线，只评估已持久化的代理式RAG轨迹；
+// 指定 -bench-retrieval 时对向量存储后端执行检索基准测试
+// 设置 -min-accuracy 后准确率低于该值时以非零状态退出，便于夜间回归任务判断结果
+func main() {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	loadOpts := config.RegisterFlags(fs)
+	dataset := fs.String("dataset", "", "数据集文件或BEIR目录（必填）")
+	format := fs.String("format", "auto", "数据集格式：auto, json, squad, beir, csv；auto按扩展名和内容判断")
+	split := fs.String("split", "test", "beir格式使用的qrels划分")
+	limit := fs.Int("limit", 0, "最多使用的用例数，0表示不限制")
+	importOut := fs.String("import", "", "只把数据集转换为JSONL写入该路径，不执行评估")
+	pipeline := fs.String("pipeline", "chat", "被评估的流水线：chat, rag, workflow")
+	modelName := fs.String("model", "", "生成回答使用的模型，默认agent.default_model")
+	judgeName := fs.String("judge", "", "计算RAGAS指标使用的模型，为空表示不计算")
+	workflowPath := fs.String("workflow", "", "workflow流水线执行的工作流定义（YAML/JSON），默认内置报告工作流")
+	topK := fs.Int("top-k", 0, "rag流水线检索条数，默认rag.top_k；检索基准测试每次查询的返回条数，默认5")
+	scoring := fs.String("scoring", "similarity", "答案评分方式：exact_match, similarity")
+	threshold := fs.Float64("threshold", 0.8, "similarity评分的通过阈值")
+	timeout := fs.Duration("timeout", 30*time.Minute, "整个评估的最长时间")
+	out := fs.String("out", "", "报告输出路径，.html输出HTML，否则输出JSON；为空时输出JSON到标准输出")
+	minAccuracy := fs.Float64("min-accuracy", 0, "准确率低于该值时以状态码2退出，0表示不检查")
+	trajectoryDir := fs.String("trajectories", "", "评估该目录下持久化的代理式RAG轨迹而不执行流水线，输出轨迹评估JSON报告")
+	benchRetrieval := fs.Bool("bench-retrieval", false, "检索基准测试：向各向量存储后端写入合成语料，测量写入速率、QPS和延迟，不需要-dataset")
+	backends := fs.String("backends", "", "检索基准测试的后端：memory, milvus，逗号分隔；默认memory，配置了Milvus地址时加上milvus")
+	sizes := fs.String("sizes", "1000,10000", "检索基准测试的语料规模，逗号分隔")
+	queries := fs.Int("queries", 0, "检索基准测试每个规模的查询次数，默认200")
+	concurrency := fs.Int("concurrency", 0, "检索基准测试的并发查询数，默认4")
+	dimension := fs.Int("dimension", 0, "检索基准测试的向量维度，默认128")
+	fs.Parse(os.Args[1:])
+
+	if *benchRetrieval {
+		os.Exit(benchmarkRetrieval(*loadOpts, *backends, *sizes, eval.RetrievalBenchOptions{
+			Dimension:   *dimension,
+			Queries:     *queries,
+			Concurrency: *concurrency,
+			TopK:        *topK,
+		}, *out, *timeout))
+	}
+
+	if *dataset == "" {
+		log.Fatal("-dataset is required")
+	}
+	cases, err := eval.ImportDataset(*dataset, eval.ImportOptions{Format: *format, Split: *split, Limit: *limit})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *importOut != "" {
+		if err := writeOutput(*importOut, func(w io.Writer) error { return eval.WriteDataset(w, cases) }, nil); err != nil {
+			log.Fatalf("Failed to write dataset: %v", err)
+		}
+		log.Printf("Imported %d test cases from %s into %s", len(cases), *dataset, *importOut)
+		return
+	}
+	if *trajectoryDir != "" {
+		os.Exit(evaluateTrajectories(*trajectoryDir, cases, filepath.Base(*dataset), *scoring, *threshold, *out, *minAccuracy))
+	}
+
+	cfg, err := config.LoadWithOptions(*loadOpts)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	secretResolver, err := secrets.NewResolverFromConfig(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("Failed to create secret resolver: %v", err)
+	}
+	if err := secretResolver.ResolveConfig(context.Background(), cfg); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
+
+	modelManager, err := llm.NewModelManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create model manager: %v", err)
+	}
+	if *modelName == "" {
+		*modelName = cfg.Agent.DefaultModel
+	}
+	model, err := modelManager.GetModel(*modelName)
+	if err != nil {
+		log.Fatalf("Model %s unavailable: %v", *modelName, err)
+	}
+
+	p, err := newPipeline(*pipeline, cfg, model, modelManager, *workflowPath, *topK)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := eval.HarnessOptions{
+		Dataset:   filepath.Base(*dataset),
+		Scoring:   *scoring,
+		Threshold: *threshold,
+	}
+	if *judgeName != "" {
+		judge, err := modelManager.GetModel(*judgeName)
+		if err != nil {
+			log.Fatalf("Judge model %s unavailable: %v", *judgeName, err)
+		}
+		if opts.RAGAS, err = rageval.NewRAGASEvaluator(eval.NewModelJudge(judge)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	log.Printf("Evaluating %s pipeline on %d cases from %s", p.Name(), len(cases), *dataset)
+	report, err := eval.NewHarness(p, opts).Run(ctx, cases)
+	if err != nil {
+		log.Printf("Evaluation stopped early after %d cases: %v", len(report.Cases), err)
+	}
+
+	if err := writeOutput(*out, report.WriteJSON, report.WriteHTML); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	s := report.Summary
+	log.Printf("Accuracy %.2f%% (%d/%d), avg score %.3f, p95 latency %dms", s.Accuracy*100, s.Passed, s.Total, s.AvgScore, s.P95LatencyMs)
+
+	if err != nil {
+		os.Exit(1)
+	}
+	if *minAccuracy > 0 && s.Accuracy < *minAccuracy {
+		log.Printf("Accuracy %.2f%% is below -min-accuracy %.2f%%", s.Accuracy*100, *minAccuracy*100)
+		os.Exit(2)
+	}
+}
+
+// newPipeline 按名称创建被评估的流水线
+func newPipeline(name string, cfg *config.Config, model llm.Model, modelManager *llm.ModelManager, workflowPath string, topK int) (eval.Pipeline, error) {
+	switch name {
+	case "chat":
+		return eval.NewChatPipeline(model), nil
+	case "rag":
+		ragSystem, err := rag.NewRAG(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create RAG: %w", err)
+		}
+		if topK <= 0 {
+			topK = cfg.RAG.TopK
+		}
+		return eval.NewRAGPipeline(ragSystem, model, topK), nil
+	case "workflow":
+		return newWorkflowPipeline(cfg, model, modelManager, workflowPath)
+	default:
+		return nil, fmt.Errorf("unknown pipeline %q, expected chat, rag or workflow", name)
+	}
+}
+
+// newWorkflowPipeline 用专家Agent执行工作流，用例的input作为工作流输入input和topic，
+// metadata中的字段作为其他输入；最后一个步骤的输出作为回答
+func newWorkflowPipeline(cfg *config.Config, model llm.Model, modelManager *llm.ModelManager, path string) (eval.Pipeline, error) {
+	def := workflow.ReportWorkflow()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workflow: %w", err)
+		}
+		format := "yaml"
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = "json"
+		}
+		if def, err = workflow.NewParser("").ParseFromString(string(data), format); err != nil {
+			return nil, err
+		}
+	}
+	if len(def.Steps) == 0 {
+		return nil, fmt.Errorf("workflow %s has no steps", def.Name)
+	}
+
+	factory := aiagentexpert.NewFactory()
+	factory.SetToolManager(aitools.NewToolManagerFromConfig(cfg.Tools.Manager))
+	if cfg.Agent.PersonasDir != "" {
+		if err := factory.LoadPersonas(cfg.Agent.PersonasDir); err != nil {
+			return nil, fmt.Errorf("failed to load personas: %w", err)
+		}
+	}
+	if err := factory.SetResourceLimits(cfg.Agent.Limits); err != nil {
+		return nil, err
+	}
+	factory.SetModel(model)
+	if err := factory.SetPersonaModels(modelManager.GetModel); err != nil {
+		log.Printf("Warning: Persona models unavailable, using default model: %v", err)
+	}
+
+	executor := workflow.NewExecutorFromConfig(nil, nil, cfg.Workflows.Executor)
+	executor.SetModelManager(modelManager)
+	executor.SetStepRunner(func(ctx context.Context, step *workflow.Step, inputs map[string]interface{}) (interface{}, error) {
+		agent, err := factory.CreateAgent(step.Agent)
+		if err != nil {
+			return nil, err
+		}
+		goal := step.Name
+		if g, ok := step.Config["goal"].(string); ok && g != "" {
+			goal = g
+		}
+		for name, value := range inputs {
+			switch value.(type) {
+			case string, int, int64, float64, bool:
+				goal = strings.ReplaceAll(goal, "{{"+name+"}}", fmt.Sprint(value))
+			}
+		}
+		result, err := aiagentexpert.ExecuteWithUsage(ctx, agent, &aiagenttask.Task{
+			ID:           step.ID,
+			Type:         step.Agent,
+			Goal:         goal,
+			Requirements: inputs,
+			Priority:     aiagenttask.PriorityNormal,
+			Status:       aiagenttask.TaskStatusPending,
+			CreatedAt:    time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.Status == aiagenttask.TaskStatusFailed {
+			return nil, errors.New(result.Error)
+		}
+		return result.Output, nil
+	})
+
+	last := def.Steps[len(def.Steps)-1].ID
+	return eval.NewPipelineFunc("workflow:"+def.Name, func(ctx context.Context, tc eval.TestCase) (*eval.PipelineOutput, error) {
+		inputs := make(map[string]interface{}, len(tc.Metadata)+2)
+		for key, value := range tc.Metadata {
+			inputs[key] = value
+		}
+		inputs["input"] = tc.Input
+		if _, ok := inputs["topic"]; !ok {
+			inputs["topic"] = tc.Input
+		}
+		execution, err := executor.Execute(ctx, def, inputs)
+		if err != nil {
+			return nil, err
+		}
+		state := execution.GetStepState(last)
+		if state == nil {
+			return nil, fmt.Errorf("step %s did not run", last)
+		}
+		return &eval.PipelineOutput{Answer: outputText(state.Output), Run: eval.RunFromExecution(execution)}, nil
+	}), nil
+}
+
+// evaluateTrajectories 用数据集评估已持久化的轨迹，返回进程退出码；-min-accuracy 作用于轨迹通过率
+func evaluateTrajectories(dir string, cases []eval.TestCase, dataset, scoring string, threshold float64, out string, minPassRate float64) int {
+	store, err := adaptive.NewFileTrajectoryStore(dir)
+	if err != nil {
+		log.Printf("Failed to open trajectories: %v", err)
+		return 1
+	}
+	trajectories, err := store.List(context.Background(), adaptive.TrajectoryFilter{})
+	if err != nil {
+		log.Printf("Failed to list trajectories: %v", err)
+		return 1
+	}
+	report, err := eval.NewTrajectoryEvaluator(scoring, threshold).EvaluateTrajectories(trajectories, cases)
+	if err != nil {
+		log.Printf("Trajectory evaluation failed: %v", err)
+		return 1
+	}
+	report.Dataset = dataset
+
+	if err := writeOutput(out, report.WriteJSON, report.WriteJSON); err != nil {
+		log.Printf("Failed to write report: %v", err)
+		return 1
+	}
+
+	s := report.Summary
+	log.Printf("Trajectory pass rate %.2f%% (%d/%d, %d missing), tool choice %.3f, redundancy %.3f, budget %.3f",
+		s.PassRate*100, s.Passed, s.Total, s.Missing, s.AvgToolChoiceScore, s.AvgRedundancyScore, s.AvgBudgetScore)
+	if minPassRate > 0 && s.PassRate < minPassRate {
+		log.Printf("Pass rate %.2f%% is below -min-accuracy %.2f%%", s.PassRate*100, minPassRate*100)
+		return 2
+	}
+	return 0
+}
+
+// outputText 步骤输出的文本：优先取content字段，否则整体序列化
+func outputText(output interface{}) string {
+	switch v := output.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if content, ok := v["content"].(string); ok {
+			return content
+		}
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Sprint(output)
+	}
+	return string(data)
+}
+
+// writeOutput 按输出路径的扩展名写出报告：.html 用writeHTML，否则用writeJSON；路径为空时输出JSON到标准输出
+func writeOutput(path string, writeJSON, writeHTML func(io.Writer) error) error {
+	if path == "" {
+		return writeJSON(os.Stdout)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), ".html") {
+		return writeHTML(file)
+	}
+	return writeJSON(file)
+}
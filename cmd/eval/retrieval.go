@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/eval"
+	"ai-agent-assistant/internal/rag/store"
+	"ai-agent-assistant/internal/vectordb"
+)
+
+// benchmarkRetrieval 对配置的向量存储后端执行检索基准测试，返回进程退出码
+// backends 为空时测试内存后端，配置了 vectordb.milvus.address 时同时测试Milvus
+func benchmarkRetrieval(loadOpts config.LoadOptions, backends, sizes string, opts eval.RetrievalBenchOptions, out string, timeout time.Duration) int {
+	cfg, err := config.LoadWithOptions(loadOpts)
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return 1
+	}
+	if opts.Sizes, err = parseSizes(sizes); err != nil {
+		log.Print(err)
+		return 1
+	}
+	selected, closeBackends, err := retrievalBackends(cfg, backends)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	defer closeBackends()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	names := make([]string, len(selected))
+	for i, b := range selected {
+		names[i] = b.Name
+	}
+	log.Printf("Benchmarking %s at corpus sizes %s", strings.Join(names, ", "), sizes)
+	report, err := eval.RunRetrievalBenchmark(ctx, selected, opts)
+	if err != nil {
+		log.Printf("Benchmark stopped early after %d runs: %v", len(report.Results), err)
+	}
+
+	if werr := writeOutput(out, report.WriteJSON, report.WriteHTML); werr != nil {
+		log.Printf("Failed to write report: %v", werr)
+		return 1
+	}
+	failed := false
+	for _, r := range report.Results {
+		if r.Error != "" {
+			log.Printf("%s @ %d: %s", r.Backend, r.CorpusSize, r.Error)
+			failed = true
+			continue
+		}
+		log.Printf("%s @ %d: ingest %.0f docs/s, %.1f QPS, p95 %.3fms", r.Backend, r.CorpusSize, r.IngestRate, r.QPS, r.P95LatencyMs)
+	}
+	if err != nil || failed {
+		return 1
+	}
+	return 0
+}
+
+// parseSizes 解析逗号分隔的语料规模
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		size, err := strconv.Atoi(part)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid corpus size %q in -sizes", part)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// retrievalBackends 按名称创建被测后端：memory、milvus；返回关闭外部连接的函数
+func retrievalBackends(cfg *config.Config, names string) ([]eval.RetrievalBackend, func(), error) {
+	if names == "" {
+		names = "memory"
+		if cfg.VectorDB.Milvus.Address != "" {
+			names += ",milvus"
+		}
+	}
+
+	var backends []eval.RetrievalBackend
+	var closers []func()
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "memory":
+			backends = append(backends, eval.RetrievalBackend{
+				Name: "memory",
+				New: func(ctx context.Context, size, dimension int) (store.VectorStore, func(), error) {
+					return store.NewInMemoryVectorStore(nil), nil, nil
+				},
+			})
+		case "milvus":
+			if cfg.VectorDB.Milvus.Address == "" {
+				closeAll()
+				return nil, nil, fmt.Errorf("milvus backend requires vectordb.milvus.address")
+			}
+			client, err := vectordb.NewMilvusClient(vectordb.MilvusConfigFromConfig(cfg.VectorDB.Milvus))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("failed to create milvus client: %w", err)
+			}
+			closers = append(closers, func() { client.Close() })
+			collection := cfg.VectorDB.Milvus.CollectionName
+			if collection == "" {
+				collection = "documents"
+			}
+			backends = append(backends, eval.RetrievalBackend{
+				Name: "milvus",
+				// 每个规模使用独立的临时集合，测完删除，不影响线上集合
+				New: func(ctx context.Context, size, dimension int) (store.VectorStore, func(), error) {
+					name := fmt.Sprintf("%s_bench_%d", collection, size)
+					if err := client.DropCollection(ctx, name); err != nil {
+						if exists, _ := client.HasCollection(ctx, name); exists {
+							return nil, nil, err
+						}
+					}
+					cleanup := func() {
+						if err := client.DropCollection(context.Background(), name); err != nil {
+							log.Printf("Warning: failed to drop benchmark collection %s: %v", name, err)
+						}
+					}
+					vs := store.NewMilvusVectorStore(client, name, dimension)
+					vs.SetWriteOptions(store.MilvusWriteOptionsFromConfig(cfg.VectorDB.Milvus))
+					return vs, cleanup, nil
+				},
+			})
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown retrieval backend %q, expected memory or milvus", name)
+		}
+	}
+	return backends, closeAll, nil
+}
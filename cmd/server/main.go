@@ -1,22 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 
 	"ai-agent-assistant/internal/agent"
 	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/handler"
+	"ai-agent-assistant/internal/secrets"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// 加载配置
-	cfg, err := config.Load("config.yaml")
+	// 加载配置（默认值 < config.yaml < config.<profile>.yaml < AIAGENT_*环境变量 < -set命令行参数）
+	loadOpts, err := config.ParseFlags(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	cfg, err := config.LoadWithOptions(loadOpts)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	secretResolver, err := secrets.NewResolverFromConfig(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("Failed to create secret resolver: %v", err)
+	}
+	if err := secretResolver.ResolveConfig(context.Background(), cfg); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
 
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
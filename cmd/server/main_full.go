@@ -1,30 +1,79 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	assistantv1 "ai-agent-assistant/api/proto/assistant/v1"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
 	aiagentconfig "ai-agent-assistant/internal/config"
 	aiagenteval "ai-agent-assistant/internal/eval"
 	llm "ai-agent-assistant/internal/llm"
 	memory "ai-agent-assistant/internal/memory"
+	"ai-agent-assistant/internal/grpcapi"
+	"ai-agent-assistant/internal/handler"
+	"ai-agent-assistant/internal/health"
+	"ai-agent-assistant/internal/idempotency"
+	"ai-agent-assistant/internal/jobs"
+	"ai-agent-assistant/internal/middleware"
+	"ai-agent-assistant/internal/moderation"
+	"ai-agent-assistant/internal/monitoring"
+	"ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/pagination"
 	aiagentrag "ai-agent-assistant/internal/rag"
+	"ai-agent-assistant/internal/rag/embedding"
+	"ai-agent-assistant/internal/ratelimit"
 	aigentreasoning "ai-agent-assistant/internal/reasoning"
+	"ai-agent-assistant/internal/secrets"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/internal/validation"
 	pkgmodels "ai-agent-assistant/pkg/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	// memory.store_type 为postgres时使用的database/sql驱动
+	_ "github.com/lib/pq"
 )
 
 func main() {
-	// 1. 加载配置
-	cfg, err := aiagentconfig.Load("config.yaml")
+	// 1. 加载配置（默认值 < config.yaml < config.<profile>.yaml < AIAGENT_*环境变量 < -set命令行参数）
+	loadOpts, err := aiagentconfig.ParseFlags(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	cfg, err := aiagentconfig.LoadWithOptions(loadOpts)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if cfg.Profile != "" {
+		log.Printf("Config profile: %s", cfg.Profile)
+	}
+	// 解析配置中的密钥引用（vault://、awssm://），密钥不写入配置文件（未启用时为nil）
+	secretResolver, err := secrets.NewResolverFromConfig(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("Failed to create secret resolver: %v", err)
+	}
+	if err := secretResolver.ResolveConfig(context.Background(), cfg); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
+	validateOpts := aiagentconfig.ValidateOptions{
+		KnownModel:            llm.NewModelFactory().SupportsModel,
+		ResolveEmbeddingModel: embedding.ResolveModelName,
+		EmbeddingDimension:    embedding.LookupDimension,
+	}
+	if err := cfg.Validate(validateOpts); err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Println("\n🚀 AI Agent Assistant v0.4 - 完整版服务器")
 	fmt.Println("========================================\n")
@@ -36,6 +85,47 @@ func main() {
 	} else {
 		fmt.Printf("✅ Model Manager created\n")
 		fmt.Printf("   Loaded models: %v\n", modelManager.ListModels())
+
+		// 启用token用量与费用统计
+		if cfg.Usage.Enabled {
+			modelManager.EnableUsageTracking(llm.NewUsageTrackerFromConfig(cfg.Usage))
+			fmt.Printf("✅ Usage Tracking enabled (priced models: %d)\n", len(cfg.Usage.Pricing))
+		}
+
+		// 启用LLM请求/响应日志（持久化前脱敏）
+		if cfg.LLMLogging.Enabled {
+			callLogger, err := llm.NewCallLoggerFromConfig(cfg.LLMLogging)
+			if err != nil {
+				log.Printf("Warning: Failed to create LLM call logger: %v", err)
+			} else {
+				modelManager.EnableCallLogging(callLogger)
+				fmt.Printf("✅ LLM Call Logging enabled (store: %s)\n", cfg.LLMLogging.Store)
+			}
+		}
+
+		// 服务商限流时按优先级和租户排队，而不是直接失败
+		if queue := llm.NewRequestQueueFromConfig(cfg.LLMQueue); queue != nil {
+			modelManager.EnableRequestQueue(queue)
+			fmt.Printf("✅ LLM Request Queue enabled (max concurrent per provider: %d)\n", cfg.LLMQueue.MaxConcurrent)
+		}
+
+		// 启用便宜/昂贵模型路由
+		if cfg.ModelRouting.Enabled {
+			modelManager.SetModelRouter(llm.NewModelRouterFromConfig(cfg.ModelRouting))
+			fmt.Printf("✅ Model Routing enabled (pipelines: %d)\n", len(cfg.ModelRouting.Pipelines))
+		}
+
+		// 启用LLM响应缓存（按路由开启）
+		if cfg.Cache.LLM.Enabled {
+			responseCache := llm.NewResponseCacheFromConfig(cfg.Cache.LLM)
+			if cfg.Cache.LLM.EmbeddingModel != "" {
+				if embedder, err := modelManager.GetModel(cfg.Cache.LLM.EmbeddingModel); err == nil && embedder.SupportsEmbedding() {
+					responseCache.SetEmbedder(embedder)
+				}
+			}
+			modelManager.EnableResponseCache(responseCache)
+			fmt.Printf("✅ LLM Response Cache enabled (routes: %d)\n", len(cfg.Cache.LLM.Routes))
+		}
 	}
 
 	// 3. 创建RAG系统
@@ -48,17 +138,39 @@ func main() {
 
 	// 4. 创建会话管理器
 	embeddingModel, _ := modelManager.GetModel(cfg.Agent.EmbeddingModel)
-	sessionManager := memory.NewEnhancedSessionManager(
-		cfg.Memory.MaxHistory,
-		"memory", // 使用内存存储以避免数据库依赖
-		embeddingModel,
-	)
+	summaryModel := embeddingModel
+	if cfg.Memory.Summary.Model != "" {
+		if model, err := modelManager.GetModel(cfg.Memory.Summary.Model); err == nil {
+			summaryModel = model
+		} else {
+			fmt.Printf("⚠️  Summary model %q unavailable, using %s: %v\n", cfg.Memory.Summary.Model, cfg.Agent.EmbeddingModel, err)
+		}
+	}
+	sessionManager, err := memory.NewEnhancedSessionManagerFromConfig(cfg.Memory, summaryModel)
+	if err != nil {
+		fmt.Printf("⚠️  Session store %q unavailable, falling back to memory: %v\n", cfg.Memory.StoreType, err)
+		sessionManager = memory.NewEnhancedSessionManager(cfg.Memory.MaxHistory, "memory", summaryModel)
+	}
+	defer sessionManager.Close()
+	if cfg.Memory.SessionTTL != "" {
+		interval, _ := time.ParseDuration(cfg.Memory.EvictionInterval)
+		sessionManager.StartIdleEviction(context.Background(), interval)
+	}
 	sessionManager.EnableAutoSummary(true)
-	sessionManager.SetSummaryThreshold(cfg.Memory.MaxHistory)
+	if cfg.Memory.Summary.Threshold <= 0 {
+		sessionManager.SetSummaryThreshold(cfg.Memory.MaxHistory)
+	}
 	fmt.Printf("✅ Session Manager created\n")
 
 	// 5. 创建记忆管理器
-	memoryManager := memory.NewEnhancedMemoryManager(embeddingModel)
+	memoryManager, err := memory.NewEnhancedMemoryManagerFromConfig(cfg.Memory, embeddingModel)
+	if err != nil {
+		fmt.Printf("⚠️  User memory store unavailable, falling back to memory: %v\n", err)
+		memoryManager = memory.NewEnhancedMemoryManager(embeddingModel)
+	}
+	if interval, err := time.ParseDuration(cfg.Memory.UserMemory.ConsolidateInterval); err == nil {
+		memoryManager.StartMaintenance(context.Background(), interval)
+	}
 	memoryManager.EnableAutoExtract(true)
 	memoryManager.EnableSemanticSearch(true)
 	memoryManager.SetOptimizationStrategy("importance")
@@ -74,13 +186,183 @@ func main() {
 		}
 	}
 
-	// 7. 设置Gin模式
+	// 7. 创建认证器
+	authenticator, err := auth.NewAuthenticatorFromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Failed to create authenticator: %v", err)
+	}
+	if authenticator.Enabled() {
+		fmt.Printf("✅ API Key Authentication enabled (store: %s)\n", cfg.Auth.APIKeys.Store)
+		if cfg.Auth.JWT.Enabled {
+			fmt.Printf("✅ JWT/OIDC Authentication enabled (issuer: %s)\n", cfg.Auth.JWT.Issuer)
+		}
+	}
+
+	// 8. 创建异步作业管理器（知识导入等长耗时操作，GET /jobs/:id 查询）
+	jobManager := jobs.NewManagerFromConfig(cfg.Jobs)
+	jobManager.StartCleanup(context.Background(), time.Hour)
+
+	// 定时评估：按cron运行黄金数据集，指标比基线下降超过阈值时报警
+	var evalScheduler *aiagenteval.Scheduler
+	if len(cfg.Eval.Suites) > 0 {
+		evalScheduler, err = aiagenteval.NewSchedulerFromConfig(cfg.Eval, cfg.Jobs.Webhook, func(sc aiagentconfig.EvalSuiteConfig) (aiagenteval.Pipeline, error) {
+			modelName := sc.Model
+			if modelName == "" {
+				modelName = cfg.Agent.DefaultModel
+			}
+			model, err := modelManager.GetModel(modelName)
+			if err != nil {
+				return nil, err
+			}
+			if sc.Pipeline != "rag" {
+				return aiagenteval.NewChatPipeline(model), nil
+			}
+			if ragSystem == nil {
+				return nil, fmt.Errorf("rag pipeline requires the RAG system")
+			}
+			topK := sc.TopK
+			if topK <= 0 {
+				topK = cfg.RAG.TopK
+			}
+			return aiagenteval.NewRAGPipeline(ragSystem, model, topK), nil
+		})
+		if err != nil {
+			log.Fatalf("Failed to create eval scheduler: %v", err)
+		}
+		evalScheduler.Start(context.Background())
+		fmt.Printf("✅ Scheduled Evaluation enabled (suites: %d)\n", evalScheduler.Len())
+	}
+
+	// 9. 创建限流器
+	limiter := ratelimit.NewLimiterFromConfig(cfg.RateLimit)
+	if limiter != nil {
+		fmt.Printf("✅ Rate Limiting enabled (%.0f req/min, budgets: %d)\n", cfg.RateLimit.RequestsPerMinute, len(cfg.RateLimit.Budgets))
+	}
+
+	// 内容审核：对话的用户输入和模型输出按规则和分类模型审核（未启用时为nil）
+	var classifier moderation.Classifier
+	if cfg.Moderation.Classifier.Enabled {
+		classifierModel, err := modelManager.GetModel(cfg.Moderation.Classifier.Model)
+		if err != nil {
+			log.Fatalf("Moderation classifier model unavailable: %v", err)
+		}
+		classifier = moderation.NewLLMClassifier(classifierModel, cfg.Moderation.Classifier.Categories)
+	}
+	moderator, err := moderation.NewModeratorFromConfig(cfg.Moderation, classifier)
+	if err != nil {
+		log.Fatalf("Failed to create moderator: %v", err)
+	}
+	if moderator != nil {
+		fmt.Printf("✅ Content Moderation enabled (rules: %d, classifier: %v)\n", len(cfg.Moderation.Rules), classifier != nil)
+	}
+
+	// 多租户：按凭证或X-Tenant-ID隔离会话、记忆、知识库和用量（未启用时为nil）
+	tenants := tenant.NewResolverFromConfig(cfg.Tenancy)
+	if tenants != nil {
+		fmt.Printf("✅ Multi-tenancy enabled (allow header: %v)\n", cfg.Tenancy.AllowHeader)
+	}
+
+	// 配置热加载：功能开关、限流、模型路由和提示词模板修改后无需重启（未启用时为nil）
+	configWatcher, err := aiagentconfig.NewWatcherFromConfig(loadOpts, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create config watcher: %v", err)
+	}
+	eventBus := orchestrator.NewEventBus()
+	if configWatcher != nil {
+		configWatcher.SetValidateOptions(validateOpts)
+		configWatcher.SetResolver(func(next *aiagentconfig.Config) error {
+			return secretResolver.ResolveConfig(context.Background(), next)
+		})
+		configWatcher.OnChange(func(event aiagentconfig.ChangeEvent) {
+			if event.Changed(aiagentconfig.SectionRateLimit) {
+				limiter.Reconfigure(event.New.RateLimit)
+			}
+			if event.Changed(aiagentconfig.SectionModelRouting) && modelManager != nil {
+				if event.New.ModelRouting.Enabled {
+					modelManager.SetModelRouter(llm.NewModelRouterFromConfig(event.New.ModelRouting))
+				} else {
+					modelManager.SetModelRouter(nil)
+				}
+			}
+			if event.Changed(aiagentconfig.SectionPromptTemplates) {
+				// 模板已通过校验
+				_ = sessionManager.SetSummaryPrompt(event.New.Memory.Summary.PromptTemplate)
+			}
+			_ = eventBus.Publish(&orchestrator.Event{
+				Name:   aiagentconfig.EventConfigChanged,
+				Source: "config",
+				Data: map[string]interface{}{
+					"sections":         event.Sections,
+					"restart_required": event.RestartRequired,
+					"config":           event.New,
+				},
+			})
+		})
+		if err := configWatcher.Start(context.Background()); err != nil {
+			log.Printf("Warning: Failed to start config hot reload: %v", err)
+		} else {
+			defer configWatcher.Close()
+			fmt.Printf("✅ Config Hot Reload enabled\n")
+		}
+	}
+
+	// 密钥轮换：定期重新读取引用的密钥，模型API Key即时生效，其他密钥（如数据库密码）需要重启
+	if secretResolver != nil {
+		secretResolver.OnRotate(func(rotation secrets.Rotation) {
+			restart := make([]string, 0)
+			modelsRotated := false
+			for _, key := range rotation.Keys {
+				if strings.HasPrefix(key, "models.") {
+					modelsRotated = true
+				} else {
+					restart = append(restart, key)
+				}
+			}
+			if modelsRotated && modelManager != nil {
+				models := cfg.Models
+				secretResolver.Apply(&models, "models")
+				if err := modelManager.UpdateModelsConfig(models); err != nil {
+					log.Printf("Warning: Failed to apply rotated model keys: %v", err)
+				}
+			}
+			if len(restart) > 0 {
+				log.Printf("Secrets rotated, restart required for: %v", restart)
+			}
+			_ = eventBus.Publish(&orchestrator.Event{
+				Name:   secrets.EventSecretsRotated,
+				Source: "secrets",
+				Data: map[string]interface{}{
+					"keys":             rotation.Keys,
+					"restart_required": restart,
+				},
+			})
+		})
+		secretResolver.Start(context.Background())
+		fmt.Printf("✅ Secret Management enabled (resolved: %d)\n", len(secretResolver.Keys()))
+	}
+
+	// 10. 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
-	// 8. 创建路由
-	router := setupRouter(cfg, modelManager, ragSystem, sessionManager, memoryManager, reasoningManager)
+	// 11. 创建路由
+	router := setupRouter(cfg, authenticator, limiter, tenants, jobManager, evalScheduler, modelManager, ragSystem, sessionManager, memoryManager, reasoningManager, moderator)
+
+	// 12. 启动gRPC服务（与REST共用认证和限流，未启用时为nil）
+	if grpcServer := grpcapi.NewServerFromConfig(cfg.GRPC, authenticator, limiter); grpcServer != nil {
+		grpcServer.SetTenancy(tenants)
+		grpcServer.SetChat(&grpcChatBackend{service: newChatService(cfg, modelManager, sessionManager, memoryManager, moderator)})
+		if ragSystem != nil {
+			grpcServer.SetKnowledge(ragSystem)
+		}
+		go func() {
+			if err := grpcServer.ListenAndServe(fmt.Sprintf(":%d", cfg.GRPC.Port)); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+		fmt.Printf("✅ gRPC server enabled (port: %d)\n", cfg.GRPC.Port)
+	}
 
-	// 9. 启动服务器
+	// 13. 启动服务器
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
 
 	// 打印启动信息
@@ -98,51 +380,115 @@ func main() {
 // setupRouter 设置路由
 func setupRouter(
 	cfg *aiagentconfig.Config,
+	authenticator *auth.Authenticator,
+	limiter *ratelimit.Limiter,
+	tenants *tenant.Resolver,
+	jobManager *jobs.Manager,
+	evalScheduler *aiagenteval.Scheduler,
 	modelManager *llm.ModelManager,
 	ragSystem *aiagentrag.RAG,
 	sessionManager *memory.EnhancedSessionManager,
 	memoryManager *memory.EnhancedMemoryManager,
 	reasoningManager *aigentreasoning.ReasoningManager,
+	moderator *moderation.Moderator,
 ) *gin.Engine {
 	router := gin.Default()
+	// 按路由统计请求数、状态码和耗时，抓取接口为 monitoring.prometheus.path（未启用监控时为nil）
+	metrics := monitoring.NewHTTPMetricsFromConfig(cfg.Monitoring)
+	router.Use(metrics.Middleware())
+	if modelManager != nil && modelManager.GetRequestQueue() != nil {
+		metrics.MustRegister(monitoring.NewLLMQueueCollector(modelManager.GetRequestQueue()))
+	}
+	if ragSystem != nil && ragSystem.Shadow() != nil {
+		metrics.MustRegister(monitoring.NewRAGShadowCollector(ragSystem.Shadow()))
+	}
+	// 每个请求分配请求ID，错误响应中的request_id与响应头X-Request-ID一致
+	router.Use(apierror.RequestID())
+	// 跨域、安全响应头、响应压缩和请求体大小限制（未启用的项直接放行）
+	router.Use(
+		middleware.NewCORSFromConfig(cfg.Server.CORS).Middleware(),
+		middleware.NewSecurityHeadersFromConfig(cfg.Server.SecurityHeaders).Middleware(),
+		middleware.NewGzipFromConfig(cfg.Server.Gzip).Middleware(),
+		middleware.NewBodyLimitFromConfig(cfg.Server.BodyLimit).Middleware(),
+	)
 
 	// API v1 路由
-	api := router.Group("/api/v1")
+	// 启用认证后，各路由组按API Key的权限范围校验；限流按认证后的调用方区分客户端
+	// 启用多租户时在认证之后解析租户
+	api := router.Group("/api/v1", authenticator.Middleware(), tenants.Middleware(), limiter.Middleware())
+	chat := api.Group("", authenticator.RequireScope(auth.ScopeChat))
+	knowledgeWrite := api.Group("", authenticator.RequireScope(auth.ScopeKnowledgeWrite))
+	// 知识导入支持Idempotency-Key，客户端重试不会重复导入
+	idempotent := idempotency.NewCacheFromConfig(cfg.Idempotency).Middleware()
 	{
 		// === 对话接口 ===
-		api.POST("/chat", handleChat(cfg, modelManager, sessionManager))
-		api.POST("/chat/rag", handleChatWithRAG(cfg, modelManager, ragSystem, sessionManager))
+		chat.POST("/chat", handleChat(cfg, modelManager, sessionManager, memoryManager, moderator))
+		chat.POST("/chat/stream", handleChatStream(cfg, modelManager, sessionManager, memoryManager, moderator))
+		chat.GET("/chat/ws", handleChatWebSocket(cfg, modelManager, sessionManager, memoryManager, moderator))
+		chat.POST("/chat/rag", handleChatWithRAG(cfg, modelManager, ragSystem, sessionManager, moderator))
 
 		// === 推理接口 ===
 		if reasoningManager != nil {
-			api.POST("/reasoning/cot", handleChainOfThought(reasoningManager))
-			api.POST("/reasoning/reflect", handleReflection(reasoningManager))
+			chat.POST("/reasoning/cot", handleChainOfThought(reasoningManager))
+			chat.POST("/reasoning/reflect", handleReflection(reasoningManager))
+			chat.POST("/reasoning/tot", handleTreeOfThoughts(reasoningManager))
 		}
 
 		// === 会话管理 ===
-		api.GET("/session", handleGetSession(sessionManager))
-		api.DELETE("/session", handleClearSession(sessionManager))
-		api.POST("/session/state", handleUpdateState(sessionManager))
+		chat.GET("/session", handleGetSession(sessionManager))
+		chat.GET("/session/summaries", handleGetSummaryVersions(sessionManager))
+		chat.GET("/session/export", handleExportSession(sessionManager))
+		chat.DELETE("/session", handleClearSession(sessionManager))
+		chat.POST("/session/state", handleUpdateState(sessionManager))
+		chat.GET("/sessions", handleListSessions(sessionManager))
+		chat.PUT("/sessions/:id/ttl", handleSetSessionTTL(sessionManager))
+		chat.POST("/sessions/:id/fork", handleForkSession(sessionManager))
+		chat.GET("/sessions/:id/forks", handleListForks(sessionManager))
 
 		// === 记忆管理 ===
-		api.POST("/memory/extract", handleExtractMemory(memoryManager))
-		api.GET("/memory/search", handleSearchMemory(memoryManager))
+		chat.POST("/memory/extract", handleExtractMemory(memoryManager))
+		chat.GET("/memory/search", handleSearchMemory(memoryManager))
+
+		// === 用户数据管理 ===
+		chat.GET("/memories", handleListScopedMemories(memoryManager))
+		chat.POST("/memories", handleAddScopedMemory(memoryManager))
+		chat.DELETE("/memories/:memory_id", handleDeleteScopedMemory(memoryManager))
+		chat.GET("/users/:id/memories", handleListUserMemories(memoryManager))
+		chat.PUT("/users/:id/memories/:memory_id", handleUpdateUserMemory(memoryManager))
+		chat.DELETE("/users/:id/memories/:memory_id", handleDeleteUserMemory(memoryManager))
+		chat.GET("/users/:id/export", handleExportUserData(memoryManager, sessionManager))
+		chat.DELETE("/users/:id/data", handleDeleteUserData(memoryManager, sessionManager))
 
 		// === 知识库管理 ===
-		api.POST("/knowledge/add", handleAddKnowledge(ragSystem))
+		knowledgeWrite.POST("/knowledge/add", idempotent, handleAddKnowledge(ragSystem, jobManager))
+		knowledgeWrite.POST("/knowledge/upload", idempotent, handleUploadKnowledge(ragSystem, jobManager, aiagentrag.NewUploader(cfg.RAG.Upload)))
 		api.GET("/knowledge/stats", handleGetKnowledgeStats(ragSystem))
-		api.POST("/knowledge/search", handleSearchKnowledge(ragSystem))
+		api.GET("/knowledge/sources", handleListKnowledgeSources(ragSystem))
+		api.POST("/knowledge/search", handleSearchKnowledge(ragSystem, aiagentrag.NewSearchPager(cfg.RAG.Search)))
+
+		// === 异步作业 ===
+		handler.NewJobHandler(jobManager).RegisterRoutes(chat)
 
 		// === 评估接口 ===
-		api.POST("/eval/accuracy", handleEvaluation(modelManager))
+		chat.POST("/eval/accuracy", handleEvaluation(modelManager))
+		if evalScheduler != nil {
+			handler.NewEvalScheduleHandler(evalScheduler, jobManager).RegisterRoutes(chat)
+		}
 
 		// === 模型管理接口 ===
 		api.GET("/models", handleListModels(modelManager))
 		api.GET("/models/:name", handleGetModelInfo(modelManager))
+		api.GET("/llm/cache/stats", handleGetLLMCacheStats(modelManager))
+		api.GET("/llm/queue/stats", handleGetLLMQueueStats(modelManager))
+
+		// === 用量统计接口 ===
+		api.GET("/usage", handleGetUsage(modelManager))
+		api.GET("/llm/logs", handleGetLLMLogs(modelManager))
+		api.GET("/moderation/audit", handleGetModerationAudit(moderator))
 	}
 
-	// 健康检查
-	router.GET("/health", func(c *gin.Context) {
+	// 存活检查：进程可响应即返回200，/health保留兼容
+	liveness := func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status":  "healthy",
 			"version": "v0.4",
@@ -155,271 +501,1218 @@ func setupRouter(
 				"Evaluation & Monitoring",
 			},
 		})
+	}
+	router.GET("/health", liveness)
+	router.GET("/health/live", liveness)
+	metrics.Register(router)
+
+	// 运行时诊断：/debug/pprof、协程转储和运行时统计，需要debug权限；未启用认证时只允许本机访问
+	diagnostics := monitoring.NewDiagnosticsFromConfig(cfg.Monitoring.Debug)
+	diagnostics.SetLocalOnly(!authenticator.Enabled())
+	diagnostics.Register(router, authenticator.Middleware(), authenticator.RequireScope(auth.ScopeDebug))
+
+	// 就绪检查：探测向量库、会话存储（关键依赖）和模型服务商，报告各依赖的状态和延迟
+	checker := health.NewCheckerFromConfig(cfg.Health)
+	checker.Register("vectordb", true, func(ctx context.Context) (interface{}, error) {
+		if ragSystem == nil {
+			return nil, errors.New("RAG system not initialized")
+		}
+		return gin.H{"provider": cfg.VectorDB.Provider}, ragSystem.Ping(ctx)
+	})
+	checker.Register("session_store", true, func(ctx context.Context) (interface{}, error) {
+		return gin.H{"type": sessionManager.StoreType()}, sessionManager.Ping(ctx)
 	})
+	health.RegisterModelProviders(checker, cfg.Models)
+	router.GET("/health/ready", checker.ReadyHandler())
 
 	return router
 }
 
-// Handler函数
-
-func handleChat(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+// Handler函数
+
+// chatRequest /chat请求，普通与流式对话共用
+type chatRequest struct {
+	SessionID   string   `json:"session_id" binding:"required"`
+	UserID      string   `json:"user_id,omitempty"`
+	Message     string   `json:"message" binding:"required"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty" binding:"gte=0"`
+}
+
+// chatTurn 一轮对话的准备结果
+type chatTurn struct {
+	req        chatRequest
+	sessionKey string // 会话在存储中的标识，多租户时限定在租户内
+	model      llm.Model
+	modelName  string
+	routing    *llm.RouteDecision
+	generation llm.GenerationOptions
+	history    []pkgmodels.Message
+	usage      *llm.UsageCollector
+	moderation []*moderation.Result // 被标记、替换的审核结果
+	ctx        context.Context
+}
+
+// chatService 普通与流式对话共用的会话处理，保证两种方式写入会话的内容一致
+type chatService struct {
+	cfg            *aiagentconfig.Config
+	limits         *llm.GenerationLimits
+	modelManager   *llm.ModelManager
+	sessionManager *memory.EnhancedSessionManager
+	memoryManager  *memory.EnhancedMemoryManager
+	moderator      *moderation.Moderator // 未启用内容审核时为nil
+}
+
+func newChatService(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, sessionManager *memory.EnhancedSessionManager, memoryManager *memory.EnhancedMemoryManager, moderator *moderation.Moderator) *chatService {
+	return &chatService{
+		cfg:            cfg,
+		limits:         llm.NewGenerationLimitsFromConfig(cfg.Generation),
+		modelManager:   modelManager,
+		sessionManager: sessionManager,
+		memoryManager:  memoryManager,
+		moderator:      moderator,
+	}
+}
+
+// moderationError 内容被审核拦截时返回带错误码的错误，否则返回nil
+func moderationError(result *moderation.Result) error {
+	if !result.Blocked() {
+		return nil
+	}
+	return apierror.New(apierror.CodeUnprocessable, "Content blocked by moderation policy").WithDetails(gin.H{
+		"stage":      result.Stage,
+		"categories": result.Categories(),
+	})
+}
+
+// moderateOutput 审核模型回复，返回处理后的回复；拦截时不写入会话
+func (s *chatService) moderateOutput(turn *chatTurn, response string) (string, error) {
+	result := s.moderator.CheckOutput(turn.ctx, turn.req.SessionID, response)
+	if err := moderationError(result); err != nil {
+		return "", err
+	}
+	if result.Action != moderation.ActionAllow {
+		turn.moderation = append(turn.moderation, result)
+	}
+	return result.Text, nil
+}
+
+// prepare 校验请求、选择模型、记录用户消息并组装历史，失败时返回带错误码的错误
+func (s *chatService) prepare(ctx context.Context, req chatRequest) (*chatTurn, error) {
+	// 校验并限制客户端传入的模型和生成参数
+	if err := s.limits.CheckModel(req.Model); err != nil {
+		return nil, apierror.Validation(err)
+	}
+	generation, err := s.limits.Clamp(llm.GenerationOptions{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+	})
+	if err != nil {
+		return nil, apierror.Validation(err)
+	}
+
+	// 审核用户输入，拦截的消息不写入会话，替换后的内容用于后续处理
+	inputModeration := s.moderator.CheckInput(ctx, req.SessionID, req.Message)
+	if err := moderationError(inputModeration); err != nil {
+		return nil, err
+	}
+	req.Message = inputModeration.Text
+
+	modelName := req.Model
+	if modelName == "" {
+		modelName = s.cfg.Agent.DefaultModel
+	}
+
+	var model llm.Model
+	var routing *llm.RouteDecision
+	if req.Model == "" && s.modelManager.GetModelRouter() != nil {
+		// 未指定模型时按路由策略选择便宜/昂贵模型
+		var decision llm.RouteDecision
+		model, decision, err = s.modelManager.RouteModel(ctx, llm.RouteRequest{
+			Pipeline: "chat",
+			TaskType: "chat",
+			Prompt:   req.Message,
+		})
+		if err == nil {
+			modelName = decision.Model
+			routing = &decision
+		}
+	} else {
+		model, err = s.modelManager.GetModel(modelName)
+	}
+	if err != nil {
+		return nil, apierror.New(apierror.CodeUnavailable, "Model not available")
+	}
+
+	// 获取或创建会话，会话和用户记忆按租户隔离
+	sessionKey := tenant.Scope(ctx, req.SessionID)
+	_, _ = s.sessionManager.GetOrCreateSession(sessionKey, modelName)
+	if req.UserID != "" {
+		_ = s.sessionManager.SetMetadata(sessionKey, map[string]interface{}{
+			memory.SessionUserIDKey: tenant.Scope(ctx, req.UserID),
+		})
+	}
+
+	// 添加用户消息
+	s.sessionManager.AddMessage(sessionKey, pkgmodels.Message{
+		Role:    "user",
+		Content: req.Message,
+	})
+
+	// 获取历史
+	history, _ := s.sessionManager.GetHistory(sessionKey)
+
+	// 注入与当前问题相关的用户长期记忆
+	if req.UserID != "" && s.cfg.Memory.UserMemory.ContextLimit > 0 {
+		recalled, _ := s.memoryManager.RecallForContext(ctx, tenant.Scope(ctx, req.UserID), req.Message, s.cfg.Memory.UserMemory.ContextLimit)
+		if memoryContext := memory.FormatMemoryContext(recalled); memoryContext != "" {
+			history = append([]pkgmodels.Message{{Role: "system", Content: memoryContext}}, history...)
+		}
+	}
+
+	usage := llm.NewUsageCollector(nil, "chat")
+	ctx = llm.WithUsageCollector(llm.WithCacheRoute(ctx, "chat"), usage)
+	ctx = llm.WithGenerationOptions(ctx, generation)
+
+	turn := &chatTurn{
+		req:        req,
+		sessionKey: sessionKey,
+		model:      model,
+		modelName:  modelName,
+		routing:    routing,
+		generation: generation,
+		history:    history,
+		usage:      usage,
+		ctx:        ctx,
+	}
+	if inputModeration.Action != moderation.ActionAllow {
+		turn.moderation = append(turn.moderation, inputModeration)
+	}
+	return turn, nil
+}
+
+// complete 记录助手回复
+func (s *chatService) complete(turn *chatTurn, response string) {
+	s.sessionManager.AddMessage(turn.sessionKey, pkgmodels.Message{
+		Role:    "assistant",
+		Content: response,
+	})
+}
+
+// chat 执行一轮非流式对话并记录助手回复
+func (s *chatService) chat(ctx context.Context, req chatRequest) (*chatTurn, string, error) {
+	turn, err := s.prepare(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 调用模型
+	response, err := turn.model.Chat(turn.ctx, turn.history)
+	if err != nil {
+		return nil, "", err
+	}
+	if response, err = s.moderateOutput(turn, response); err != nil {
+		return nil, "", err
+	}
+
+	// 添加助手消息
+	s.complete(turn, response)
+	return turn, response, nil
+}
+
+func handleChat(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, sessionManager *memory.EnhancedSessionManager, memoryManager *memory.EnhancedMemoryManager, moderator *moderation.Moderator) gin.HandlerFunc {
+	service := newChatService(cfg, modelManager, sessionManager, memoryManager, moderator)
+
+	return func(c *gin.Context) {
+		var req chatRequest
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		turn, response, err := service.chat(c.Request.Context(), req)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"response":   response,
+			"model":      turn.modelName,
+			"session_id": req.SessionID,
+			"usage":      turn.usage.Metadata(),
+			"routing":    turn.routing,
+			"generation": turn.generation,
+			"moderation": turn.moderation,
+		})
+	}
+}
+
+// chatEventSink 流式对话事件的输出端（SSE、WebSocket或gRPC）
+type chatEventSink func(event string, data interface{}) error
+
+// streamChat 流式执行一轮对话，依次发送start、token、usage、done事件
+// 准备或调用模型失败时返回错误，由调用方按各自协议输出；客户端断开时返回nil
+// 只有完整生成的回复才写入会话，客户端中途断开时不记录助手消息
+func (s *chatService) streamChat(ctx context.Context, req chatRequest, send chatEventSink) error {
+	turn, err := s.prepare(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := send("start", gin.H{
+		"session_id": req.SessionID,
+		"model":      turn.modelName,
+		"routing":    turn.routing,
+	}); err != nil {
+		return nil
+	}
+
+	stream, err := turn.model.ChatStream(turn.ctx, turn.history)
+	if err != nil {
+		return err
+	}
+
+	var response strings.Builder
+	for chunk := range stream {
+		response.WriteString(chunk)
+		if err := send("token", gin.H{"content": chunk}); err != nil {
+			return nil // 客户端断开，上下文取消后模型流会结束
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// 输出在生成完成后整体审核：拦截时不写入会话并返回错误，替换后的回复随done事件返回
+	final, err := s.moderateOutput(turn, response.String())
+	if err != nil {
+		return err
+	}
+	s.complete(turn, final)
+
+	if len(turn.moderation) > 0 {
+		_ = send("moderation", gin.H{"results": turn.moderation})
+	}
+	_ = send("usage", gin.H{
+		"usage":      turn.usage.Totals(),
+		"generation": turn.generation,
+	})
+	_ = send("done", gin.H{
+		"session_id": req.SessionID,
+		"model":      turn.modelName,
+		"response":   final,
+	})
+	return nil
+}
+
+func handleChatStream(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, sessionManager *memory.EnhancedSessionManager, memoryManager *memory.EnhancedMemoryManager, moderator *moderation.Moderator) gin.HandlerFunc {
+	service := newChatService(cfg, modelManager, sessionManager, memoryManager, moderator)
+
+	return func(c *gin.Context) {
+		var req chatRequest
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		send := func(event string, data interface{}) error {
+			c.SSEvent(event, data)
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		}
+		if err := service.streamChat(c.Request.Context(), req, send); err != nil {
+			_ = send("error", apierror.Body(c.Request.Context(), err))
+		}
+	}
+}
+
+// chatWebSocketUpgrader WebSocket升级器，默认只接受同源请求
+var chatWebSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// handleChatWebSocket WebSocket流式对话
+// 客户端每发送一条chatRequest JSON，服务端以 {"event": ..., "data": ...} 帧返回该轮的事件，连接可复用多轮
+func handleChatWebSocket(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, sessionManager *memory.EnhancedSessionManager, memoryManager *memory.EnhancedMemoryManager, moderator *moderation.Moderator) gin.HandlerFunc {
+	service := newChatService(cfg, modelManager, sessionManager, memoryManager, moderator)
+
+	return func(c *gin.Context) {
+		conn, err := chatWebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return // Upgrade已写回错误响应
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		send := func(event string, data interface{}) error {
+			if err := conn.WriteJSON(gin.H{"event": event, "data": data}); err != nil {
+				cancel()
+				return err
+			}
+			return nil
+		}
+
+		for {
+			var req chatRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					_ = send("error", apierror.Body(ctx, apierror.Validation(err)))
+				}
+				return
+			}
+			if err := service.streamChat(ctx, req, send); err != nil {
+				_ = send("error", apierror.Body(ctx, err))
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// grpcChatBackend gRPC对话接口，复用chatService，写入会话的内容与REST一致
+type grpcChatBackend struct {
+	service *chatService
+}
+
+// chatRequestFromProto gRPC对话请求转换为chatRequest
+func chatRequestFromProto(req *assistantv1.ChatRequest) chatRequest {
+	return chatRequest{
+		SessionID:   req.GetSessionId(),
+		UserID:      req.GetUserId(),
+		Message:     req.GetMessage(),
+		Model:       req.GetModel(),
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   int(req.GetMaxTokens()),
+	}
+}
+
+func (b *grpcChatBackend) Chat(ctx context.Context, req *assistantv1.ChatRequest) (*assistantv1.ChatResponse, error) {
+	turn, response, err := b.service.chat(ctx, chatRequestFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+	return &assistantv1.ChatResponse{
+		Response:  response,
+		Model:     turn.modelName,
+		SessionId: req.GetSessionId(),
+		Usage:     grpcapi.UsageToProto(turn.usage.Totals()),
+		Routing:   grpcapi.RouteDecisionToProto(turn.routing),
+	}, nil
+}
+
+func (b *grpcChatBackend) StreamChat(ctx context.Context, req *assistantv1.ChatRequest, send func(*assistantv1.ChatEvent) error) error {
+	return b.service.streamChat(ctx, chatRequestFromProto(req), func(event string, data interface{}) error {
+		payload, _ := data.(gin.H)
+		switch event {
+		case "start":
+			routing, _ := payload["routing"].(*llm.RouteDecision)
+			return send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Start{Start: &assistantv1.ChatStarted{
+				SessionId: req.GetSessionId(),
+				Model:     fmt.Sprint(payload["model"]),
+				Routing:   grpcapi.RouteDecisionToProto(routing),
+			}}})
+		case "token":
+			content, _ := payload["content"].(string)
+			return send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Token{Token: content}})
+		case "usage":
+			usage, _ := payload["usage"].(llm.UsageSummary)
+			return send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Usage{Usage: grpcapi.UsageToProto(usage)}})
+		case "done":
+			response, _ := payload["response"].(string)
+			return send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Done{Done: &assistantv1.ChatResponse{
+				Response:  response,
+				Model:     fmt.Sprint(payload["model"]),
+				SessionId: req.GetSessionId(),
+			}}})
+		}
+		return nil
+	})
+}
+
+func handleChatWithRAG(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, ragSystem *aiagentrag.RAG, sessionManager *memory.EnhancedSessionManager, moderator *moderation.Moderator) gin.HandlerFunc {
+	limits := llm.NewGenerationLimitsFromConfig(cfg.Generation)
+
+	return func(c *gin.Context) {
+		var req struct {
+			SessionID   string   `json:"session_id" binding:"required"`
+			Message     string   `json:"message" binding:"required"`
+			TopK        int      `json:"top_k,omitempty" binding:"gte=0,lte=20"`
+			Model       string   `json:"model,omitempty"`
+			Temperature *float64 `json:"temperature,omitempty"`
+			TopP        *float64 `json:"top_p,omitempty"`
+			MaxTokens   int      `json:"max_tokens,omitempty" binding:"gte=0"`
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		// 校验并限制客户端传入的模型和生成参数
+		if err := limits.CheckModel(req.Model); err != nil {
+			apierror.Respond(c, apierror.Validation(err))
+			return
+		}
+		generation, err := limits.Clamp(llm.GenerationOptions{
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+			MaxTokens:   req.MaxTokens,
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.Validation(err))
+			return
+		}
+
+		topK := req.TopK
+		if topK <= 0 {
+			topK = 3
+		}
+
+		// 审核用户输入，替换后的内容用于检索和生成
+		var moderations []*moderation.Result
+		inputModeration := moderator.CheckInput(c.Request.Context(), req.SessionID, req.Message)
+		if err := moderationError(inputModeration); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		if inputModeration.Action != moderation.ActionAllow {
+			moderations = append(moderations, inputModeration)
+		}
+		req.Message = inputModeration.Text
+
+		// RAG检索
+		usage := llm.NewUsageCollector(nil, "chat_rag")
+		ctx := llm.WithUsageCollector(llm.WithCacheRoute(c.Request.Context(), "chat_rag"), usage)
+		context, results, err := ragSystem.BuildContextWithResults(ctx, req.Message, topK)
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeInternal, "RAG retrieval failed"))
+			return
+		}
+
+		// 构建增强消息
+		messages := []pkgmodels.Message{
+			{Role: "system", Content: context},
+			{Role: "user", Content: req.Message},
+		}
+
+		// 调用模型（指定模型优先；启用路由时最终回答按问题复杂度选择模型）
+		var routing *llm.RouteDecision
+		modelName := req.Model
+		if modelName == "" {
+			modelName = cfg.Agent.DefaultModel
+		}
+		model, err := modelManager.GetModel(modelName)
+		if req.Model == "" && modelManager.GetModelRouter() != nil {
+			routed, decision, routeErr := modelManager.RouteModel(ctx, llm.RouteRequest{
+				Pipeline: "chat_rag",
+				TaskType: "final_answer",
+				Prompt:   req.Message,
+			})
+			if routeErr == nil {
+				model, err = routed, nil
+				routing = &decision
+			}
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "Model not available"))
+			return
+		}
+
+		response, err := model.Chat(llm.WithGenerationOptions(ctx, generation), messages)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		outputModeration := moderator.CheckOutput(ctx, req.SessionID, response)
+		if err := moderationError(outputModeration); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		if outputModeration.Action != moderation.ActionAllow {
+			moderations = append(moderations, outputModeration)
+		}
+		response = outputModeration.Text
+
+		// 记录到会话，回答附带引用的检索结果
+		citations := make([]pkgmodels.Citation, len(results))
+		for i, result := range results {
+			citations[i] = pkgmodels.Citation{Index: i + 1, Content: result}
+		}
+		if req.SessionID != "" {
+			sessionKey := tenant.Scope(ctx, req.SessionID)
+			_, _ = sessionManager.GetOrCreateSession(sessionKey, model.GetModelName())
+			sessionManager.AddMessage(sessionKey, pkgmodels.Message{Role: "user", Content: req.Message})
+			sessionManager.AddMessage(sessionKey, pkgmodels.Message{
+				Role:      "assistant",
+				Content:   response,
+				Citations: citations,
+			})
+		}
+
+		c.JSON(200, gin.H{
+			"response":   response,
+			"rag_used":   true,
+			"citations":  citations,
+			"session_id": req.SessionID,
+			"usage":      usage.Metadata(),
+			"routing":    routing,
+			"generation": generation,
+			"moderation": moderations,
+		})
+	}
+}
+
+// handleChainOfThought 思维链推理
+// stream为true时通过SSE依次发送step（每个中间推理步骤）和done事件；redact为推理过程的脱敏规则（如 email、phone、id_card）
+func handleChainOfThought(reasoningManager *aigentreasoning.ReasoningManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Task   string   `json:"task" binding:"required"`
+			Stream bool     `json:"stream"`
+			Redact []string `json:"redact"`
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		redactor, err := llm.NewRedactor(req.Redact, nil)
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, err.Error()))
+			return
+		}
+
+		// 执行思维链推理
+		ctx := c.Request.Context()
+		if !req.Stream {
+			reasoning, answer, err := reasoningManager.ReasonWithCoTAndReflection(ctx, req.Task)
+			if err != nil {
+				apierror.Respond(c, err)
+				return
+			}
+
+			c.JSON(200, gin.H{
+				"reasoning": redactor.Redact(reasoning),
+				"answer":    answer,
+			})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		send := func(event string, data interface{}) error {
+			c.SSEvent(event, data)
+			c.Writer.Flush()
+			return ctx.Err()
+		}
+		reasoning, answer, err := reasoningManager.ReasonWithCoTAndReflectionStream(ctx, req.Task, func(step aigentreasoning.ReasoningStep) error {
+			step.Content = redactor.Redact(step.Content)
+			return send("step", step)
+		})
+		if ctx.Err() != nil {
+			return // 客户端已断开
+		}
+		if err != nil {
+			_ = send("error", apierror.Body(ctx, err))
+			return
+		}
+		_ = send("done", gin.H{
+			"reasoning": redactor.Redact(reasoning),
+			"answer":    answer,
+		})
+	}
+}
+
+func handleReflection(reasoningManager *aigentreasoning.ReasoningManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Task              string   `json:"task" binding:"required"`
+			PreviousAttempts []string `json:"previous_attempts"`
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		// 执行反思（使用CoT + Reflection）
+		ctx := c.Request.Context()
+		reasoning, answer, err := reasoningManager.ReasonWithCoTAndReflection(ctx, req.Task)
+
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"reflection":      reasoning,
+			"improved_answer": answer,
+		})
+	}
+}
+
+// handleTreeOfThoughts 思维树推理
+// breadth、depth、beam_width 可按请求调整搜索规模，未设置时使用默认配置
+func handleTreeOfThoughts(reasoningManager *aigentreasoning.ReasoningManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Task           string  `json:"task" binding:"required"`
+			Breadth        int     `json:"breadth" binding:"gte=0,lte=5"`
+			Depth          int     `json:"depth" binding:"gte=0,lte=5"`
+			BeamWidth      int     `json:"beam_width" binding:"gte=0,lte=5"`
+			PruneThreshold float64 `json:"prune_threshold" binding:"gte=0,lte=1"`
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		result, err := reasoningManager.ReasonWithToT(c.Request.Context(), req.Task, aigentreasoning.ToTConfig{
+			Breadth:        req.Breadth,
+			Depth:          req.Depth,
+			BeamWidth:      req.BeamWidth,
+			PruneThreshold: req.PruneThreshold,
+		})
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, result)
+	}
+}
+
+func handleGetSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Query("session_id")
+		if sessionID == "" {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
+			return
+		}
+
+		session, err := sessionManager.GetSession(tenant.Scope(c.Request.Context(), sessionID))
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"session_id": sessionID,
+			"model":      session.Model,
+			"summary":    session.Summary,
+			"state":      session.State,
+			"created_at": session.CreatedAt,
+			"updated_at": session.UpdatedAt,
+		})
+	}
+}
+
+func handleGetSummaryVersions(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Query("session_id")
+		if sessionID == "" {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
+			return
+		}
+
+		versions, err := sessionManager.GetSummaryVersions(tenant.Scope(c.Request.Context(), sessionID))
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"session_id": sessionID,
+			"versions":   versions,
+			"count":      len(versions),
+		})
+	}
+}
+
+func handleExportSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Query("session_id")
+		if sessionID == "" {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
+			return
+		}
+
+		format := c.DefaultQuery("format", "markdown")
+		if format != "markdown" && format != "md" && format != "json" {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "format must be markdown or json"))
+			return
+		}
+
+		transcript, err := sessionManager.ExportTranscript(tenant.Scope(c.Request.Context(), sessionID))
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
+			return
+		}
+
+		if c.Query("download") == "true" {
+			ext := "md"
+			if format == "json" {
+				ext = "json"
+			}
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sessionID+"."+ext))
+		}
+
+		if format == "json" {
+			c.JSON(200, transcript)
+			return
+		}
+		c.Data(200, "text/markdown; charset=utf-8", []byte(transcript.Markdown()))
+	}
+}
+
+func handleClearSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Query("session_id")
+		if sessionID == "" {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
+			return
+		}
+
+		if err := sessionManager.Clear(tenant.Scope(c.Request.Context(), sessionID)); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Session cleared"})
+	}
+}
+
+func handleListSessions(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			SessionID string `json:"session_id"`
-			Message   string `json:"message"`
-			Model     string `json:"model,omitempty"`
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
 		}
-
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
-			return
+		if offset < 0 {
+			offset = 0
 		}
 
-		modelName := req.Model
-		if modelName == "" {
-			modelName = cfg.Agent.DefaultModel
+		ctx := c.Request.Context()
+		query := memory.SessionQuery{
+			IDPrefix: tenant.Prefix(ctx),
+			UserID:   scopeOptional(ctx, c.Query("user_id")),
+			Model:    c.Query("model"),
+			Offset:   offset,
+			Limit:    limit,
 		}
 
-		model, err := modelManager.GetModel(modelName)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Model not available"})
+		// 最后活跃时间支持RFC3339时间或相对时长（如 "1h" 表示一小时内）
+		var err error
+		if query.ActiveSince, err = parseActivityTime(c.Query("active_since")); err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "invalid active_since: "+err.Error()))
+			return
+		}
+		if query.ActiveBefore, err = parseActivityTime(c.Query("active_before")); err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "invalid active_before: "+err.Error()))
 			return
 		}
 
-		// 获取或创建会话
-		_, _ = sessionManager.GetOrCreateSession(req.SessionID, modelName)
+		sessions, total := sessionManager.QuerySessions(query)
 
-		// 添加用户消息
-		sessionManager.AddMessage(req.SessionID, pkgmodels.Message{
-			Role:    "user",
-			Content: req.Message,
+		c.JSON(200, gin.H{
+			"sessions": unscopeSessions(ctx, sessions),
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+			"has_more": offset+len(sessions) < total,
+			"eviction": sessionManager.GetEvictionStats(),
 		})
+	}
+}
 
-		// 获取历史
-		history, _ := sessionManager.GetHistory(req.SessionID)
+// scopeOptional 把可选的过滤参数限定在请求的租户内，参数为空时不过滤
+func scopeOptional(ctx context.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+	return tenant.Scope(ctx, id)
+}
 
-		// 调用模型
-		ctx := c.Request.Context()
-		response, err := model.Chat(ctx, history)
+// unscopeSessions 去掉会话概要中的租户前缀，返回客户端可见的会话ID和用户ID
+func unscopeSessions(ctx context.Context, sessions []memory.SessionInfo) []memory.SessionInfo {
+	for i := range sessions {
+		sessions[i].ID, _ = tenant.Unscope(ctx, sessions[i].ID)
+		sessions[i].UserID, _ = tenant.Unscope(ctx, sessions[i].UserID)
+		sessions[i].ForkedFrom, _ = tenant.Unscope(ctx, sessions[i].ForkedFrom)
+	}
+	return sessions
+}
+
+// parseActivityTime 解析活跃时间过滤参数
+func parseActivityTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func handleSetSessionTTL(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			TTL string `json:"ttl"` // 如 "30m"，"0" 表示恢复默认值
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
 
+		ttl, err := time.ParseDuration(req.TTL)
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "invalid ttl: "+err.Error()))
 			return
 		}
 
-		// 添加助手消息
-		sessionManager.AddMessage(req.SessionID, pkgmodels.Message{
-			Role:    "assistant",
-			Content: response,
-		})
+		if err := sessionManager.SetSessionTTL(tenant.Scope(c.Request.Context(), c.Param("id")), ttl); err != nil {
+			if errors.Is(err, memory.ErrSessionNotFound) {
+				apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Validation(err))
+			return
+		}
 
 		c.JSON(200, gin.H{
-			"response":  response,
-			"model":     modelName,
-			"session_id": req.SessionID,
+			"message":    "Session TTL updated",
+			"session_id": c.Param("id"),
+			"ttl":        ttl.String(),
 		})
 	}
 }
 
-func handleChatWithRAG(cfg *aiagentconfig.Config, modelManager *llm.ModelManager, ragSystem *aiagentrag.RAG, sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+func handleForkSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			SessionID string `json:"session_id"`
-			Message   string `json:"message"`
-			TopK      int    `json:"top_k,omitempty"`
+			NewSessionID string `json:"new_session_id"`                         // 为空时自动生成
+			MessageIndex *int   `json:"message_index" binding:"omitempty,gte=0"` // 新会话保留该位置之前的消息，为空时复制全部
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
-		topK := req.TopK
-		if topK <= 0 {
-			topK = 3
+		index := -1
+		if req.MessageIndex != nil {
+			index = *req.MessageIndex
 		}
 
-		// RAG检索
 		ctx := c.Request.Context()
-		context, err := ragSystem.BuildContext(ctx, req.Message, topK)
+		fork, err := sessionManager.ForkSession(tenant.Scope(ctx, c.Param("id")), scopeOptional(ctx, req.NewSessionID), index)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "RAG retrieval failed"})
+			switch {
+			case errors.Is(err, memory.ErrSessionNotFound):
+				apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
+			case errors.Is(err, memory.ErrSessionExists):
+				apierror.Respond(c, apierror.Wrap(apierror.CodeConflict, err))
+			default:
+				apierror.Respond(c, apierror.Validation(err))
+			}
 			return
 		}
 
-		// 构建增强消息
-		messages := []pkgmodels.Message{
-			{Role: "system", Content: context},
-			{Role: "user", Content: req.Message},
-		}
+		history, _ := sessionManager.GetHistory(fork.ID)
+		forkID, _ := tenant.Unscope(ctx, fork.ID)
+		c.JSON(200, gin.H{
+			"message":     "Session forked",
+			"session_id":  forkID,
+			"forked_from": c.Param("id"),
+			"history":     history,
+		})
+	}
+}
+
+// forkListSpec 分支会话列表支持的排序和过滤字段，查询参数约定见pagination.Spec
+var forkListSpec = pagination.Spec[memory.SessionInfo]{
+	Fields: map[string]pagination.Field[memory.SessionInfo]{
+		"model":         func(s memory.SessionInfo) interface{} { return s.Model },
+		"user_id":       func(s memory.SessionInfo) interface{} { return s.UserID },
+		"message_count": func(s memory.SessionInfo) interface{} { return s.MessageCount },
+		"created_at":    func(s memory.SessionInfo) interface{} { return s.CreatedAt },
+		"updated_at":    func(s memory.SessionInfo) interface{} { return s.UpdatedAt },
+	},
+	DefaultSort: "-created_at",
+}
 
-		// 调用模型
-		model, _ := modelManager.GetModel(cfg.Agent.DefaultModel)
-		response, err := model.Chat(ctx, messages)
+func handleListForks(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		page, err := forkListSpec.List(c, unscopeSessions(ctx, sessionManager.ListForks(tenant.Scope(ctx, c.Param("id")))))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"response":   response,
-			"rag_used":   true,
-			"session_id": req.SessionID,
-		})
+		response := page.Response("forks")
+		response["session_id"] = c.Param("id")
+		response["count"] = len(page.Items)
+		c.JSON(200, response)
 	}
 }
 
-func handleChainOfThought(reasoningManager *aigentreasoning.ReasoningManager) gin.HandlerFunc {
+func handleUpdateState(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			Task string `json:"task"`
+			SessionID string                 `json:"session_id" binding:"required"`
+			Updates   map[string]interface{} `json:"updates" binding:"required"`
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
-		// 执行思维链推理
-		ctx := c.Request.Context()
-		reasoning, answer, err := reasoningManager.ReasonWithCoTAndReflection(ctx, req.Task)
-
+		version, err := sessionManager.UpdateState(tenant.Scope(c.Request.Context(), req.SessionID), req.Updates)
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
 		c.JSON(200, gin.H{
-			"reasoning": reasoning,
-			"answer":    answer,
+			"message": "State updated",
+			"version": version,
 		})
 	}
 }
 
-func handleReflection(reasoningManager *aigentreasoning.ReasoningManager) gin.HandlerFunc {
+func handleExtractMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			Task              string   `json:"task"`
-			PreviousAttempts []string `json:"previous_attempts"`
+			UserID      string `json:"user_id" binding:"required"`
+			Conversation string `json:"conversation" binding:"required"`
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
-		// 执行反思（使用CoT + Reflection）
 		ctx := c.Request.Context()
-		reasoning, answer, err := reasoningManager.ReasonWithCoTAndReflection(ctx, req.Task)
+		memories, err := memoryManager.ExtractMemories(ctx, tenant.Scope(ctx, req.UserID), req.Conversation)
 
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
+		// 添加到记忆存储
+		for _, memory := range memories {
+			memoryManager.AddMemory(ctx, memory)
+		}
+
 		c.JSON(200, gin.H{
-			"reflection":      reasoning,
-			"improved_answer": answer,
+			"message":  "Memories extracted",
+			"count":     len(memories),
+			"memories": memories,
 		})
 	}
 }
 
-func handleGetSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+// memoryListSpec 记忆列表支持的排序和过滤字段，查询参数约定见pagination.Spec
+var memoryListSpec = pagination.Spec[*memory.UserMemory]{
+	Fields: map[string]pagination.Field[*memory.UserMemory]{
+		"topics":       func(m *memory.UserMemory) interface{} { return m.Topics },
+		"importance":   func(m *memory.UserMemory) interface{} { return m.Importance },
+		"access_count": func(m *memory.UserMemory) interface{} { return m.AccessCount },
+		"created_at":   func(m *memory.UserMemory) interface{} { return m.CreatedAt },
+		"updated_at":   func(m *memory.UserMemory) interface{} { return m.UpdatedAt },
+	},
+	DefaultSort: "-created_at",
+}
+
+func handleListScopedMemories(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sessionID := c.Query("session_id")
-		if sessionID == "" {
-			c.JSON(400, gin.H{"error": "session_id is required"})
+		scope, err := memory.ParseMemoryScope(c.Query("scope"))
+		if err != nil {
+			apierror.Respond(c, apierror.Validation(err))
 			return
 		}
 
-		session, err := sessionManager.GetSession(sessionID)
+		ctx := c.Request.Context()
+		memories, err := memoryManager.ListScopedMemories(ctx, scopeOptional(ctx, c.Query("user_id")), scope, scopeOptional(ctx, c.Query("team_id")))
+		if err != nil {
+			apierror.Respond(c, memoryError(err))
+			return
+		}
+		page, err := memoryListSpec.List(c, memories)
 		if err != nil {
-			c.JSON(404, gin.H{"error": "Session not found"})
+			apierror.Respond(c, err)
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"session_id": session.ID,
-			"model":      session.Model,
-			"summary":    session.Summary,
-			"state":      session.State,
-			"created_at": session.CreatedAt,
-			"updated_at": session.UpdatedAt,
-		})
+		response := page.Response("memories")
+		response["scope"] = scope
+		response["team_id"] = c.Query("team_id")
+		response["count"] = len(page.Items)
+		c.JSON(200, response)
 	}
 }
 
-func handleClearSession(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+func handleAddScopedMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sessionID := c.Query("session_id")
-		if sessionID == "" {
-			c.JSON(400, gin.H{"error": "session_id is required"})
+		var req struct {
+			UserID     string   `json:"user_id" binding:"required"`
+			Scope      string   `json:"scope" binding:"omitempty,oneof=user team global"`
+			TeamID     string   `json:"team_id" binding:"required_if=Scope team"`
+			Content    string   `json:"content" binding:"required"`
+			Topics     []string `json:"topics"`
+			Importance float64  `json:"importance" binding:"gte=0,lte=1"`
+		}
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
-		if err := sessionManager.Clear(sessionID); err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+		ctx := c.Request.Context()
+		entry := &memory.UserMemory{
+			Scope:      memory.MemoryScope(req.Scope),
+			TeamID:     scopeOptional(ctx, req.TeamID),
+			Content:    req.Content,
+			Topics:     req.Topics,
+			Importance: req.Importance,
+		}
+		if err := memoryManager.AddScopedMemory(ctx, tenant.Scope(ctx, req.UserID), entry); err != nil {
+			apierror.Respond(c, memoryError(err))
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Session cleared"})
+		c.JSON(200, gin.H{
+			"message": "Memory added",
+			"memory":  entry,
+		})
 	}
 }
 
-func handleUpdateState(sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+func handleDeleteScopedMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			SessionID string                 `json:"session_id"`
-			Updates   map[string]interface{} `json:"updates"`
+		scope, err := memory.ParseMemoryScope(c.Query("scope"))
+		if err != nil {
+			apierror.Respond(c, apierror.Validation(err))
+			return
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		ctx := c.Request.Context()
+		err = memoryManager.DeleteScopedMemory(ctx, scopeOptional(ctx, c.Query("user_id")), scope, scopeOptional(ctx, c.Query("team_id")), c.Param("memory_id"))
+		if err != nil {
+			apierror.Respond(c, memoryError(err))
 			return
 		}
 
-		version, err := sessionManager.UpdateState(req.SessionID, req.Updates)
+		c.JSON(200, gin.H{"message": "Memory deleted"})
+	}
+}
+
+// memoryError 记忆操作错误：越权和不存在按注册的错误码返回，其余视为参数错误
+func memoryError(err error) error {
+	if errors.Is(err, memory.ErrMemoryAccessDenied) || errors.Is(err, memory.ErrMemoryNotFound) {
+		return err
+	}
+	return apierror.Validation(err)
+}
+
+func handleListUserMemories(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := memoryListSpec.List(c, memoryManager.ListMemories(tenant.Scope(c.Request.Context(), c.Param("id"))))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"message": "State updated",
-			"version": version,
-		})
+		response := page.Response("memories")
+		response["user_id"] = c.Param("id")
+		response["count"] = len(page.Items)
+		c.JSON(200, response)
 	}
 }
 
-func handleExtractMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
+func handleUpdateUserMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			UserID      string `json:"user_id"`
-			Conversation string `json:"conversation"`
+		var req memory.MemoryUpdate
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		ctx := c.Request.Context()
+		updated, err := memoryManager.UpdateMemory(ctx, tenant.Scope(ctx, c.Param("id")), c.Param("memory_id"), req)
+		if err != nil {
+			if errors.Is(err, memory.ErrMemoryNotFound) {
+				apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Memory not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Validation(err))
 			return
 		}
 
-		ctx := c.Request.Context()
-		memories, err := memoryManager.ExtractMemories(ctx, req.UserID, req.Conversation)
+		c.JSON(200, gin.H{
+			"message": "Memory updated",
+			"memory":  updated,
+		})
+	}
+}
 
+func handleDeleteUserMemory(memoryManager *memory.EnhancedMemoryManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		err := memoryManager.DeleteMemory(ctx, tenant.Scope(ctx, c.Param("id")), c.Param("memory_id"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			if errors.Is(err, memory.ErrMemoryNotFound) {
+				apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Memory not found"))
+				return
+			}
+			apierror.Respond(c, err)
 			return
 		}
 
-		// 添加到记忆存储
-		for _, memory := range memories {
-			memoryManager.AddMemory(ctx, memory)
+		c.JSON(200, gin.H{"message": "Memory deleted"})
+	}
+}
+
+func handleExportUserData(memoryManager *memory.EnhancedMemoryManager, sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, memory.ExportUserData(tenant.Scope(c.Request.Context(), c.Param("id")), memoryManager, sessionManager))
+	}
+}
+
+func handleDeleteUserData(memoryManager *memory.EnhancedMemoryManager, sessionManager *memory.EnhancedSessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		result, err := memory.DeleteUserData(ctx, tenant.Scope(ctx, c.Param("id")), memoryManager, sessionManager)
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeInternal, err.Error()).WithDetails(gin.H{"result": result}))
+			return
 		}
 
 		c.JSON(200, gin.H{
-			"message":  "Memories extracted",
-			"count":     len(memories),
-			"memories": memories,
+			"message": "User data deleted",
+			"result":  result,
 		})
 	}
 }
@@ -433,15 +1726,15 @@ func handleSearchMemory(memoryManager *memory.EnhancedMemoryManager) gin.Handler
 		limitInt, _ := strconv.Atoi(limit)
 
 		if userID == "" || query == "" {
-			c.JSON(400, gin.H{"error": "user_id and query are required"})
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "user_id and query are required"))
 			return
 		}
 
 		ctx := c.Request.Context()
-		memories, err := memoryManager.SemanticSearch(ctx, userID, query, limitInt)
+		memories, err := memoryManager.SemanticSearch(ctx, tenant.Scope(ctx, userID), query, limitInt)
 
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
@@ -453,21 +1746,43 @@ func handleSearchMemory(memoryManager *memory.EnhancedMemoryManager) gin.Handler
 	}
 }
 
-func handleAddKnowledge(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
+func handleAddKnowledge(ragSystem *aiagentrag.RAG, jobManager *jobs.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			Text   string `json:"text"`
-			Source string `json:"source"`
+			Text        string `json:"text" binding:"required"`
+			Source      string `json:"source"`
+			Async       bool   `json:"async"`        // 作为异步作业导入，返回job_id
+			CallbackURL string `json:"callback_url"` // 导入结束后接收webhook（隐含async）
+		}
+
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
+			return
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if req.Async || req.CallbackURL != "" {
+			job, err := jobManager.SubmitContext(c.Request.Context(), jobs.Request{
+				Kind:        "knowledge_ingest",
+				CallbackURL: req.CallbackURL,
+				Owner:       handler.JobOwner(c),
+				Metadata:    map[string]interface{}{"source": req.Source, "length": len(req.Text)},
+			}, func(ctx context.Context) (interface{}, error) {
+				if err := ragSystem.AddText(ctx, req.Text, req.Source); err != nil {
+					return nil, err
+				}
+				return gin.H{"source": req.Source, "stats": ragSystem.StatsFor(ctx)}, nil
+			})
+			if err != nil {
+				apierror.Respond(c, apierror.Validation(err))
+				return
+			}
+			handler.JobAccepted(c, job, nil)
 			return
 		}
 
 		ctx := c.Request.Context()
 		if err := ragSystem.AddText(ctx, req.Text, req.Source); err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
@@ -475,25 +1790,136 @@ func handleAddKnowledge(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
 	}
 }
 
+// handleUploadKnowledge 上传文件（PDF/DOCX/TXT/MD）到知识库
+// multipart表单：file（可重复）、callback_url（可选）
+// 每个文件保存后提交一个knowledge_ingest作业，返回202和各文件的job_id
+func handleUploadKnowledge(ragSystem *aiagentrag.RAG, jobManager *jobs.Manager, uploader *aiagentrag.Uploader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploader.MaxRequestSize())
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				apierror.Respond(c, apierror.New(apierror.CodePayloadTooLarge, "request body too large"))
+				return
+			}
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "invalid multipart form: "+err.Error()))
+			return
+		}
+		defer form.RemoveAll()
+
+		files := form.File["file"]
+		if len(files) == 0 {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "file is required"))
+			return
+		}
+		if len(files) > uploader.MaxFiles() {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, fmt.Sprintf("too many files: at most %d per request", uploader.MaxFiles())))
+			return
+		}
+
+		callbackURL := c.PostForm("callback_url")
+		if err := jobManager.ValidateCallbackURL(callbackURL); err != nil {
+			apierror.Respond(c, apierror.Validation(err))
+			return
+		}
+
+		// 先校验并保存全部文件，任一文件不合法则整个请求失败
+		saved := make([]*aiagentrag.UploadedFile, 0, len(files))
+		for _, header := range files {
+			file, err := uploader.Save(header)
+			if err != nil {
+				for _, f := range saved {
+					os.Remove(f.Path)
+				}
+				apierror.Respond(c, err)
+				return
+			}
+			saved = append(saved, file)
+		}
+
+		results := make([]gin.H, 0, len(saved))
+		for _, file := range saved {
+			file := file
+			job, err := jobManager.SubmitContext(c.Request.Context(), jobs.Request{
+				Kind:        "knowledge_ingest",
+				CallbackURL: callbackURL,
+				Owner:       handler.JobOwner(c),
+				Metadata:    map[string]interface{}{"source": file.Filename, "size": file.Size, "type": file.Type},
+			}, func(ctx context.Context) (interface{}, error) {
+				if err := ragSystem.AddDocumentAs(ctx, file.Path, file.Filename); err != nil {
+					return nil, err
+				}
+				return gin.H{"source": file.Filename, "stats": ragSystem.StatsFor(ctx)}, nil
+			})
+			if err != nil {
+				apierror.Respond(c, err)
+				return
+			}
+			results = append(results, gin.H{
+				"filename":   file.Filename,
+				"size":       file.Size,
+				"job_id":     job.ID,
+				"status":     job.Status,
+				"status_url": "/api/v1/jobs/" + job.ID,
+			})
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"files": results,
+			"total": len(results),
+		})
+	}
+}
+
 func handleGetKnowledgeStats(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		stats := ragSystem.GetStats()
+		stats := ragSystem.StatsFor(c.Request.Context())
+		index, err := ragSystem.IndexStatsFor(c.Request.Context())
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
 
 		c.JSON(200, gin.H{
 			"stats": stats,
+			"index": index,
 		})
 	}
 }
 
-func handleSearchKnowledge(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
+// handleListKnowledgeSources 按来源列出请求租户知识库中的分块数、字符数、分块类型和最后更新时间
+func handleListKnowledgeSources(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		index, err := ragSystem.IndexStatsFor(c.Request.Context())
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		page, err := handler.KnowledgeSourceListSpec.List(c, index.Sources)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		resp := page.Response("sources")
+		resp["tracked_since"] = index.TrackedSince
+		c.JSON(200, resp)
+	}
+}
+
+func handleSearchKnowledge(ragSystem *aiagentrag.RAG, pager *aiagentrag.SearchPager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			Query string `json:"query"`
-			TopK  int    `json:"top_k,omitempty"`
+			Query            string `json:"query" binding:"required"`
+			TopK             int    `json:"top_k,omitempty" binding:"gte=0,lte=20"`       // 每页结果数
+			PageToken        string `json:"page_token,omitempty"`                         // 上一页响应中的next_page_token
+			MaxContentLength int    `json:"max_content_length,omitempty" binding:"gte=0"` // 单条结果的最大字符数，不超过服务端上限
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
@@ -501,19 +1927,29 @@ func handleSearchKnowledge(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
 		if topK <= 0 {
 			topK = 3
 		}
+		offset, err := pager.Offset(req.Query, req.PageToken)
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, err.Error()).WithDetails(gin.H{"page_token": req.PageToken}))
+			return
+		}
 
 		ctx := c.Request.Context()
-		results, err := ragSystem.Retrieve(ctx, req.Query, topK)
+		results, err := ragSystem.Retrieve(ctx, req.Query, pager.FetchSize(offset, topK))
 
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
+		page := pager.Page(req.Query, results, offset, topK, req.MaxContentLength)
 		c.JSON(200, gin.H{
-			"query":   req.Query,
-			"count":   len(results),
-			"results": results,
+			"query":           req.Query,
+			"count":           len(page.Results),
+			"results":         page.Results,
+			"offset":          page.Offset,
+			"truncated":       page.Truncated,
+			"has_more":        page.NextPageToken != "",
+			"next_page_token": page.NextPageToken,
 		})
 	}
 }
@@ -521,19 +1957,19 @@ func handleSearchKnowledge(ragSystem *aiagentrag.RAG) gin.HandlerFunc {
 func handleEvaluation(modelManager *llm.ModelManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			TestCases  []aiagenteval.TestCase `json:"test_cases"`
+			TestCases  []aiagenteval.TestCase `json:"test_cases" binding:"required,min=1"`
 			Accuracy   bool             `json:"accuracy,omitempty"`
 			Performance bool             `json:"performance,omitempty"`
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+		if err := validation.Bind(c, &req); err != nil {
+			apierror.Respond(c, err)
 			return
 		}
 
 		model, _ := modelManager.GetModel("qwen")
 		if model == nil {
-			c.JSON(500, gin.H{"error": "No model available"})
+			apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "No model available"))
 			return
 		}
 
@@ -549,11 +1985,11 @@ func handleEvaluation(modelManager *llm.ModelManager) gin.HandlerFunc {
 
 		manager := builder.Build()
 
-		ctx := c.Request.Context()
+		ctx := llm.WithCacheRoute(c.Request.Context(), "eval")
 		results, err := manager.RunEvaluations(ctx, model, req.TestCases)
 
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			apierror.Respond(c, err)
 			return
 		}
 
@@ -588,7 +2024,7 @@ func handleGetModelInfo(modelManager *llm.ModelManager) gin.HandlerFunc {
 		info := modelManager.GetModelInfo(modelName)
 
 		if info == nil {
-			c.JSON(404, gin.H{"error": "Model not found"})
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Model not found"))
 			return
 		}
 
@@ -599,18 +2035,133 @@ func handleGetModelInfo(modelManager *llm.ModelManager) gin.HandlerFunc {
 	}
 }
 
+func handleGetLLMQueueStats(modelManager *llm.ModelManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queue := modelManager.GetRequestQueue()
+		if queue == nil {
+			c.JSON(200, gin.H{"enabled": false})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"enabled":   true,
+			"providers": queue.Stats(),
+		})
+	}
+}
+
+func handleGetLLMCacheStats(modelManager *llm.ModelManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cache := modelManager.GetResponseCache()
+		if cache == nil {
+			c.JSON(200, gin.H{"enabled": false})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"enabled": true,
+			"stats":   cache.Stats(),
+		})
+	}
+}
+
+func handleGetUsage(modelManager *llm.ModelManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracker := modelManager.GetUsageTracker()
+		if tracker == nil {
+			c.JSON(200, gin.H{"enabled": false})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("recent", "0"))
+
+		// 多租户时只返回请求租户的用量
+		if t := tenant.FromContext(c.Request.Context()); t != "" {
+			response := gin.H{
+				"enabled": true,
+				"summary": tracker.TenantSummary(t),
+			}
+			if limit > 0 {
+				response["recent"] = tracker.RecentFor(t, limit)
+			}
+			c.JSON(200, response)
+			return
+		}
+
+		response := gin.H{
+			"enabled": true,
+			"summary": tracker.Summary(),
+		}
+		if limit > 0 {
+			response["recent"] = tracker.Recent(limit)
+		}
+
+		c.JSON(200, response)
+	}
+}
+
+func handleGetLLMLogs(modelManager *llm.ModelManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := modelManager.GetCallLogger()
+		if logger == nil {
+			c.JSON(200, gin.H{"enabled": false})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		logs, err := logger.Recent(c.Request.Context(), limit)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"enabled": true,
+			"logs":    logs,
+			"count":   len(logs),
+		})
+	}
+}
+
+// handleGetModerationAudit 最近的内容审核记录，多租户时只返回请求租户的记录
+func handleGetModerationAudit(moderator *moderation.Moderator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if moderator == nil {
+			c.JSON(200, gin.H{"enabled": false})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		records, err := moderator.Recent(c.Request.Context(), tenant.FromContext(c.Request.Context()), limit)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"enabled": true,
+			"records": records,
+			"count":   len(records),
+		})
+	}
+}
+
 // 打印启动信息
 func printStartupInfo(cfg *aiagentconfig.Config) {
 	fmt.Printf("\n✅ 服务器就绪！\n")
 	fmt.Printf("📍 地址: http://0.0.0.0:%d\n", cfg.Server.Port)
-	fmt.Printf("🏥 健康检查: http://0.0.0.0:%d/health\n", cfg.Server.Port)
+	fmt.Printf("🏥 健康检查: http://0.0.0.0:%d/health (就绪: /health/ready)\n", cfg.Server.Port)
 	fmt.Printf("🤖 模型API: http://0.0.0.0:%d/api/v1/models\n", cfg.Server.Port)
 	fmt.Printf("💬 对话API: http://0.0.0.0:%d/api/v1/chat\n", cfg.Server.Port)
 	fmt.Printf("🧠 RAG对话: http://0.0.0.0:%d/api/v1/chat/rag\n", cfg.Server.Port)
 	fmt.Printf("🤔 推理API: http://0.0.0.0:%d/api/v1/reasoning/cot\n", cfg.Server.Port)
 	fmt.Printf("💾 记忆API: http://0.0.0.0:%d/api/v1/memory/*\n", cfg.Server.Port)
 	fmt.Printf("📚 知识库: http://0.0.0.0:%d/api/v1/knowledge/*\n", cfg.Server.Port)
-	fmt.Printf("📊 评估系统: http://0.0.0.0:%d/api/v1/eval/*\n\n", cfg.Server.Port)
+	fmt.Printf("📊 评估系统: http://0.0.0.0:%d/api/v1/eval/*\n", cfg.Server.Port)
+	if cfg.GRPC.Enabled {
+		fmt.Printf("🔌 gRPC: 0.0.0.0:%d (assistant.v1.AssistantService)\n", cfg.GRPC.Port)
+	}
+	fmt.Println()
 	fmt.Println("========================================")
 	fmt.Println("🎯 v0.4 完整功能已启用！")
 	fmt.Println("========================================\n")
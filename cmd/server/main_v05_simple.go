@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/auth"
 	aiagentconfig "ai-agent-assistant/internal/config"
 	aiagentexpert "ai-agent-assistant/internal/agent/expert"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/grpcapi"
 	"ai-agent-assistant/internal/handler"
+	"ai-agent-assistant/internal/health"
+	"ai-agent-assistant/internal/idempotency"
+	"ai-agent-assistant/internal/jobs"
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/memory"
+	"ai-agent-assistant/internal/middleware"
+	"ai-agent-assistant/internal/monitoring"
+	"ai-agent-assistant/internal/ratelimit"
+	"ai-agent-assistant/internal/report"
+	aiagenttask "ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
 	aitools "ai-agent-assistant/internal/tools"
+	"ai-agent-assistant/internal/workflow"
 
 	"github.com/gin-gonic/gin"
+	// scheduler.registry.store 为postgres时使用的database/sql驱动
+	_ "github.com/lib/pq"
 )
 
 func main() {
@@ -23,16 +43,49 @@ func main() {
 	fmt.Println("🚀 AI Agent Assistant v0.5")
 	fmt.Println("========================================")
 
-	// 初始化Agent系统
-	agentRegistry := aiagentorchestrator.NewAgentRegistry()
+	// 初始化Agent系统（注册表按 scheduler.registry 使用内存、Redis或Postgres存储）
+	agentRegistry, err := aiagentorchestrator.NewAgentRegistryFromConfig(cfg.Scheduler.Registry)
+	if err != nil {
+		log.Fatalf("Agent注册表创建失败: %v", err)
+	}
+	defer agentRegistry.Close()
+
+	// Agent注册、注销和状态变化（含租约失效）以注册表事件广播到通信总线
+	agentBus, err := aiagentorchestrator.NewCommunicationBusFromConfig(cfg.Scheduler.Bus)
+	if err != nil {
+		log.Fatalf("Agent通信总线创建失败: %v", err)
+	}
+	defer agentBus.Stop()
+	agentRegistry.SetEventBus(agentBus)
+
+	// 启用多租户时按 tenancy.quotas 限制每个租户注册的Agent数和调度器中的任务数
+	tenantQuotas := tenant.NewQuotasFromConfig(cfg.Tenancy)
+	agentRegistry.SetQuotas(tenantQuotas)
+
+	// 多实例部署时启用 scheduler.election：只有主实例调度任务和启动工作流执行，其他实例热备
+	leaderElector, err := aiagentorchestrator.NewLeaderElectorFromConfig(cfg.Scheduler.Election)
+	if err != nil {
+		log.Fatalf("编排器选主初始化失败: %v", err)
+	}
+
 	expertFactory := aiagentexpert.NewFactory()
 
 	// 创建工具管理器并设置到工厂
-	toolManager := aitools.NewToolManager(&aitools.ToolManagerConfig{
-		AutoRegister: true,
-	})
+	toolManager := aitools.NewToolManagerFromConfig(cfg.Tools.Manager)
 	expertFactory.SetToolManager(toolManager)
 
+	// 加载Agent人设（名称、系统提示词、能力、默认模型、工具白名单）
+	if cfg.Agent.PersonasDir != "" {
+		if err := expertFactory.LoadPersonas(cfg.Agent.PersonasDir); err != nil {
+			log.Fatalf("Agent人设加载失败: %v", err)
+		}
+	}
+
+	// 按Agent类型设置单个任务的资源限制（token、工具调用次数、执行时长）
+	if err := expertFactory.SetResourceLimits(cfg.Agent.Limits); err != nil {
+		log.Fatalf("Agent资源限制配置无效: %v", err)
+	}
+
 	expertFactory.RegisterAllAgents(agentRegistry)
 
 	// 列出Agent
@@ -42,34 +95,205 @@ func main() {
 		fmt.Printf("   - %s (%s): %d项能力\n", agent.Name, agent.Type, len(agent.Capabilities))
 	}
 
+	// 启用远程Agent时创建任务调度器：已连接的远程Agent通过gRPC流执行任务，其他Agent在进程内执行
+	var taskScheduler *aiagentorchestrator.TaskScheduler
+	var remoteAgents *aiagentorchestrator.RemoteAgents
+	if cfg.Scheduler.RemoteAgents.Enabled {
+		remoteAgents = aiagentorchestrator.NewRemoteAgents(agentRegistry)
+		taskScheduler = aiagentorchestrator.NewTaskSchedulerFromConfig(agentRegistry, cfg.Scheduler)
+		taskScheduler.SetExecutor(remoteAgents.Executor(expertFactory.TaskExecutor()))
+		taskScheduler.SetQuotas(tenantQuotas)
+		taskScheduler.SetLeaderElector(leaderElector)
+		taskScheduler.Start()
+		defer taskScheduler.Stop()
+	}
+
 	// 创建Agent Handler
 	agentHandler := handler.NewAgentHandler(
 		cfg,
 		expertFactory,
 		agentRegistry,
-		nil, // scheduler
+		taskScheduler, // 未启用远程Agent时为nil
 	)
+	agentHandler.SetLeaderElector(leaderElector)
+	if leaderElector != nil {
+		leaderElector.Start()
+		defer leaderElector.Close()
+		fmt.Printf("✅ 编排器选主已启用 (实例: %s，主实例: %v)\n", leaderElector.ID(), leaderElector.IsLeader())
+	}
+
+	// 创建任务执行记录存储（GET /tasks/:id 查询）
+	if taskStore, err := aiagenttask.NewTaskStoreFromConfig(cfg.Tasks); err != nil {
+		log.Printf("Warning: Failed to create task store, using memory: %v", err)
+	} else {
+		agentHandler.SetTaskStore(taskStore)
+	}
+
+	// 创建报告存储（GET /analysis/report/:id 查询）
+	if reportStore, err := report.NewStoreFromConfig(cfg.Reports); err != nil {
+		log.Printf("Warning: Failed to create report store, using memory: %v", err)
+	} else {
+		agentHandler.SetReportStore(reportStore)
+	}
+
+	// 创建产物存储（分析Agent渲染的图表，GET /artifacts/:id 获取）
+	if artifactStore, err := artifact.NewStoreFromConfig(cfg.Artifacts); err != nil {
+		log.Printf("Warning: Failed to create artifact store, using memory: %v", err)
+	} else {
+		agentHandler.SetArtifactStore(artifactStore)
+	}
+
+	// 创建仓库工具（代码Agent读取仓库、在沙箱中运行测试，需配置tools.repo.roots）
+	if gitTool, sandboxTool, err := aitools.NewRepoToolsFromConfig(cfg.Tools.Repo); err != nil {
+		log.Printf("Warning: Failed to create repo tools: %v", err)
+	} else if gitTool != nil {
+		if err := agentHandler.RegisterTools(gitTool, sandboxTool); err != nil {
+			log.Printf("Warning: Failed to register repo tools: %v", err)
+		}
+	}
+
+	// 创建工作流定义存储（/workflows 接口）
+	if workflowRepo, err := workflow.NewRepositoryFromConfig(cfg.Workflows); err != nil {
+		log.Printf("Warning: Failed to create workflow store, using memory: %v", err)
+	} else {
+		agentHandler.SetWorkflowRepository(workflowRepo)
+	}
+
+	// 创建异步作业管理器（报告生成、批量任务，GET /jobs/:id 查询）
+	jobManager := jobs.NewManagerFromConfig(cfg.Jobs)
+	jobManager.StartCleanup(context.Background(), time.Hour)
+	agentHandler.SetJobManager(jobManager)
+
+	// 创建模型管理器（工作流consensus步骤和Agent内容生成使用）
+	var embeddingModel llm.Model
+	if modelManager, err := llm.NewModelManager(cfg); err != nil {
+		log.Printf("Warning: Failed to create model manager: %v", err)
+	} else {
+		agentHandler.SetModelManager(modelManager)
+		// 写作Agent使用默认模型生成文章、报告和摘要，模型不可用时使用模板生成
+		if model, err := modelManager.GetModel(cfg.Agent.DefaultModel); err != nil {
+			log.Printf("Warning: Default model unavailable, agents use offline templates: %v", err)
+		} else {
+			expertFactory.SetModel(model)
+		}
+		// 人设声明了默认模型的Agent改用该模型
+		if err := expertFactory.SetPersonaModels(modelManager.GetModel); err != nil {
+			log.Printf("Warning: Persona models unavailable, using default model: %v", err)
+		}
+		embeddingModel, _ = modelManager.GetModel(cfg.Agent.EmbeddingModel)
+	}
+
+	// 创建Agent记忆（专家Agent检索同一用户或项目的历史任务结果）
+	if cfg.Agent.Memory.Enabled {
+		memoryManager, err := memory.NewEnhancedMemoryManagerFromConfig(cfg.Memory, embeddingModel)
+		if err != nil {
+			log.Printf("Warning: Failed to create memory store, using memory: %v", err)
+			memoryManager = memory.NewEnhancedMemoryManager(embeddingModel)
+		}
+		if err := expertFactory.SetMemoryManager(memoryManager, cfg.Agent.Memory); err != nil {
+			log.Fatalf("Agent记忆配置无效: %v", err)
+		}
+	}
+
+	// 创建认证器（未启用时所有接口直接放行）
+	authenticator, err := auth.NewAuthenticatorFromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatalf("认证初始化失败: %v", err)
+	}
+	if authenticator.Enabled() {
+		agentHandler.SetAuthenticator(authenticator)
+		fmt.Printf("✅ API Key认证已启用 (store: %s)\n", cfg.Auth.APIKeys.Store)
+		if cfg.Auth.JWT.Enabled {
+			fmt.Printf("✅ JWT/OIDC认证已启用 (issuer: %s)\n", cfg.Auth.JWT.Issuer)
+		}
+	}
+
+	// 创建限流器（未启用时为nil，直接放行）
+	limiter := ratelimit.NewLimiterFromConfig(cfg.RateLimit)
+
+	// 多租户：任务和作业按租户隔离（未启用时为nil，直接放行）
+	tenants := tenant.NewResolverFromConfig(cfg.Tenancy)
+
+	// 任务提交和工作流执行支持Idempotency-Key（未启用时为nil，忽略该请求头）
+	agentHandler.SetIdempotency(idempotency.NewCacheFromConfig(cfg.Idempotency))
 
 	// 创建路由
 	router := gin.Default()
 	gin.SetMode(cfg.Server.Mode)
+	// 按路由统计请求数、状态码和耗时（未启用监控时为nil）
+	metrics := monitoring.NewHTTPMetricsFromConfig(cfg.Monitoring)
+	router.Use(metrics.Middleware())
+	router.Use(apierror.RequestID())
+	// 跨域、安全响应头、响应压缩和请求体大小限制（未启用的项直接放行）
+	router.Use(
+		middleware.NewCORSFromConfig(cfg.Server.CORS).Middleware(),
+		middleware.NewSecurityHeadersFromConfig(cfg.Server.SecurityHeaders).Middleware(),
+		middleware.NewGzipFromConfig(cfg.Server.Gzip).Middleware(),
+		middleware.NewBodyLimitFromConfig(cfg.Server.BodyLimit).Middleware(),
+	)
 
 	// 注册路由
-	api := router.Group("/api/v1")
+	api := router.Group("/api/v1", authenticator.Middleware(), tenants.Middleware(), limiter.Middleware())
 	{
 		// v0.5 新增API
 		agentHandler.RegisterRoutes(api)
+		handler.NewJobHandler(jobManager).RegisterRoutes(api)
 	}
 
-	// 健康检查
-	router.GET("/health", func(c *gin.Context) {
+	// 交互式Agent会话（/ws/agent），与REST共用认证、多租户和限流
+	agentHandler.RegisterWebSocketRoutes(router.Group("/ws", authenticator.Middleware(), tenants.Middleware(), limiter.Middleware()))
+
+	// 存活检查，/health保留兼容
+	liveness := func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status":  "ok",
 			"version": "v0.5",
 			"agents":   len(agents),
 			"message": "AI Agent Assistant v0.5 - Agent编排和工作流系统",
 		})
+	}
+	router.GET("/health", liveness)
+	router.GET("/health/live", liveness)
+	metrics.Register(router)
+
+	// 就绪检查：探测模型服务商和工具可用性，报告各依赖的状态和延迟
+	checker := health.NewCheckerFromConfig(cfg.Health)
+	checker.Register("tools", false, func(ctx context.Context) (interface{}, error) {
+		failures := toolManager.CheckHealth(ctx)
+		details := gin.H{"enabled": len(toolManager.GetAvailableTools())}
+		if len(failures) == 0 {
+			return details, nil
+		}
+		unavailable := make(map[string]string, len(failures))
+		for name, err := range failures {
+			unavailable[name] = err.Error()
+		}
+		details["unavailable"] = unavailable
+		return details, fmt.Errorf("%d tool(s) unavailable", len(failures))
 	})
+	health.RegisterModelProviders(checker, cfg.Models)
+	router.GET("/health/ready", checker.ReadyHandler())
+
+	// 启动gRPC服务：任务提交和工作流执行，与REST共用认证和限流
+	if grpcServer := grpcapi.NewServerFromConfig(cfg.GRPC, authenticator, limiter); grpcServer != nil {
+		grpcServer.SetTenancy(tenants)
+		grpcServer.SetTasks(agentHandler)
+		grpcServer.SetWorkflows(agentHandler)
+		// 远程Agent通过同一端口上的agent.v1.AgentService注册、接收任务和上报进度
+		if remoteAgents != nil {
+			agentService := grpcapi.NewAgentService(agentRegistry, taskScheduler, remoteAgents)
+			if interval, err := time.ParseDuration(cfg.Scheduler.RemoteAgents.HeartbeatInterval); err == nil {
+				agentService.SetHeartbeatInterval(interval)
+			}
+			grpcServer.SetAgents(agentService)
+		}
+		go func() {
+			if err := grpcServer.ListenAndServe(fmt.Sprintf(":%d", cfg.GRPC.Port)); err != nil {
+				log.Fatalf("gRPC服务启动失败: %v", err)
+			}
+		}()
+		fmt.Printf("✅ gRPC服务已启用 (端口: %d)\n", cfg.GRPC.Port)
+	}
 
 	// 启动服务器
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
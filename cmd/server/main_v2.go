@@ -176,6 +176,9 @@ func main() {
 			knowledge.GET("/stats", func(c *gin.Context) {
 				handler.HandleGetKnowledgeStats(c, ragSystem)
 			})
+			knowledge.GET("/sources", func(c *gin.Context) {
+				handler.HandleListKnowledgeSources(c, ragSystem)
+			})
 			knowledge.POST("/search", func(c *gin.Context) {
 				handler.HandleSearchKnowledge(c, ragSystem)
 			})
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	aiagentconfig "ai-agent-assistant/internal/config"
 	aiagentexpert "ai-agent-assistant/internal/agent/expert"
@@ -10,7 +11,10 @@ import (
 	aiagentmemory "ai-agent-assistant/internal/memory"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	aiagentrag "ai-agent-assistant/internal/rag"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/grpcapi"
 	"ai-agent-assistant/internal/handler"
+	"ai-agent-assistant/internal/tenant"
 
 	"github.com/gin-gonic/gin"
 )
@@ -81,9 +85,31 @@ func main() {
 	log.Println("🎭 初始化Agent编排器...")
 
 	// 创建Agent注册表
-	agentRegistry := aiagentorchestrator.NewAgentRegistry()
+	agentRegistry, err := aiagentorchestrator.NewAgentRegistryFromConfig(cfg.Scheduler.Registry)
+	if err != nil {
+		log.Fatalf("❌ Agent注册表创建失败: %v", err)
+	}
+	defer agentRegistry.Close()
 	log.Println("✅ Agent注册表创建成功")
 
+	// Agent注册、注销和状态变化（含租约失效）以注册表事件广播到通信总线
+	agentBus, err := aiagentorchestrator.NewCommunicationBusFromConfig(cfg.Scheduler.Bus)
+	if err != nil {
+		log.Fatalf("❌ Agent通信总线创建失败: %v", err)
+	}
+	defer agentBus.Stop()
+	agentRegistry.SetEventBus(agentBus)
+
+	// 启用多租户时按 tenancy.quotas 限制每个租户注册的Agent数和调度器中的任务数
+	tenantQuotas := tenant.NewQuotasFromConfig(cfg.Tenancy)
+	agentRegistry.SetQuotas(tenantQuotas)
+
+	// 多实例部署时启用 scheduler.election：只有主实例调度任务和启动工作流执行，其他实例热备
+	leaderElector, err := aiagentorchestrator.NewLeaderElectorFromConfig(cfg.Scheduler.Election)
+	if err != nil {
+		log.Fatalf("❌ 编排器选主初始化失败: %v", err)
+	}
+
 	// 创建专家Agent工厂
 	expertFactory := aiagentexpert.NewFactory()
 	log.Println("✅ 专家Agent工厂创建成功")
@@ -102,6 +128,37 @@ func main() {
 		}
 	}
 
+	// 启用远程Agent时创建任务调度器：已连接的远程Agent通过gRPC流执行任务，其他Agent在进程内执行
+	var taskScheduler *aiagentorchestrator.TaskScheduler
+	if cfg.Scheduler.RemoteAgents.Enabled {
+		authenticator, err := auth.NewAuthenticatorFromConfig(cfg.Auth)
+		if err != nil {
+			log.Fatalf("❌ 认证器创建失败: %v", err)
+		}
+
+		remoteAgents := aiagentorchestrator.NewRemoteAgents(agentRegistry)
+		taskScheduler = aiagentorchestrator.NewTaskSchedulerFromConfig(agentRegistry, cfg.Scheduler)
+		taskScheduler.SetExecutor(remoteAgents.Executor(expertFactory.TaskExecutor()))
+		taskScheduler.SetQuotas(tenantQuotas)
+		taskScheduler.SetLeaderElector(leaderElector)
+		taskScheduler.Start()
+		defer taskScheduler.Stop()
+
+		agentService := grpcapi.NewAgentService(agentRegistry, taskScheduler, remoteAgents)
+		if interval, err := time.ParseDuration(cfg.Scheduler.RemoteAgents.HeartbeatInterval); err == nil {
+			agentService.SetHeartbeatInterval(interval)
+		}
+		grpcServer := grpcapi.NewServer(authenticator, nil, cfg.GRPC.Reflection)
+		grpcServer.SetTenancy(tenant.NewResolverFromConfig(cfg.Tenancy))
+		grpcServer.SetAgents(agentService)
+		go func() {
+			if err := grpcServer.ListenAndServe(fmt.Sprintf(":%d", cfg.GRPC.Port)); err != nil {
+				log.Fatalf("❌ gRPC服务启动失败: %v", err)
+			}
+		}()
+		log.Printf("✅ 远程Agent协议已启用 - gRPC端口: %d", cfg.GRPC.Port)
+	}
+
 	// ============================================================
 	// 第七步：创建HTTP处理器
 	// ============================================================
@@ -121,8 +178,14 @@ func main() {
 		cfg,
 		expertFactory,
 		agentRegistry,
-		nil, // taskScheduler设为nil，简化启动
+		taskScheduler, // 未启用远程Agent时为nil，简化启动
 	)
+	agentHandler.SetLeaderElector(leaderElector)
+	if leaderElector != nil {
+		leaderElector.Start()
+		defer leaderElector.Close()
+		log.Printf("✅ 编排器选主已启用 - 实例: %s，主实例: %v", leaderElector.ID(), leaderElector.IsLeader())
+	}
 	log.Println("✅ HTTP处理器创建成功")
 
 	// ============================================================
@@ -178,6 +241,9 @@ func main() {
 				knowledge.GET("/stats", func(c *gin.Context) {
 					handler.HandleGetKnowledgeStats(c, ragSystem)
 				})
+				knowledge.GET("/sources", func(c *gin.Context) {
+					handler.HandleListKnowledgeSources(c, ragSystem)
+				})
 				knowledge.POST("/search", func(c *gin.Context) {
 					handler.HandleSearchKnowledge(c, ragSystem)
 				})
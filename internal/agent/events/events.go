@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// 事件类型
+const (
+	TypeThought       = "thought"        // Agent的推理过程：选择的方法、当前在做什么
+	TypeToolCall      = "tool_call"      // 调用工具（含搜索）之前
+	TypeToolResult    = "tool_result"    // 工具调用结束
+	TypeStepStarted   = "step_started"   // 工作流步骤开始
+	TypeStepCompleted = "step_completed" // 工作流步骤完成
+	TypeStepFailed    = "step_failed"    // 工作流步骤失败
+)
+
+// Event Agent执行过程中的事件
+type Event struct {
+	Type    string      `json:"type"`
+	Agent   string      `json:"agent,omitempty"`   // 产生事件的Agent
+	Step    string      `json:"step,omitempty"`    // 工作流步骤ID
+	Tool    string      `json:"tool,omitempty"`    // 工具名称
+	Content string      `json:"content,omitempty"` // 可读的描述
+	Data    interface{} `json:"data,omitempty"`    // 参数、结果摘要等
+	Error   string      `json:"error,omitempty"`
+	Time    time.Time   `json:"time"`
+}
+
+// Sink 事件接收函数，可能被多个goroutine并发调用
+type Sink func(Event)
+
+// sinkKey context中事件接收函数的键
+type sinkKey struct{}
+
+// WithSink 把事件接收函数放入context，执行期间的事件都交给sink
+func WithSink(ctx context.Context, sink Sink) context.Context {
+	return context.WithValue(ctx, sinkKey{}, sink)
+}
+
+// Emit 发送事件，context中没有接收函数时忽略
+func Emit(ctx context.Context, event Event) {
+	sink, ok := ctx.Value(sinkKey{}).(Sink)
+	if !ok || sink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	sink(event)
+}
+
+// Thought 发送推理过程事件
+func Thought(ctx context.Context, agent, content string) {
+	Emit(ctx, Event{Type: TypeThought, Agent: agent, Content: content})
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+// TestEmit 测试事件发送到context中的接收函数，没有接收函数时忽略
+func TestEmit(t *testing.T) {
+	Emit(context.Background(), Event{Type: TypeThought}) // 不应panic
+
+	var got []Event
+	ctx := WithSink(context.Background(), func(e Event) { got = append(got, e) })
+	Thought(ctx, "researcher", "开始搜索")
+	Emit(ctx, Event{Type: TypeToolCall, Tool: "search"})
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(got))
+	}
+	if got[0].Type != TypeThought || got[0].Agent != "researcher" || got[0].Content != "开始搜索" || got[0].Time.IsZero() {
+		t.Errorf("unexpected thought event: %+v", got[0])
+	}
+	if got[1].Type != TypeToolCall || got[1].Tool != "search" {
+		t.Errorf("unexpected tool event: %+v", got[1])
+	}
+}
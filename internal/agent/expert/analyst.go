@@ -2,7 +2,6 @@ package expert
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
 	"math"
 	"os"
@@ -10,6 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/csvparse"
 	"ai-agent-assistant/internal/task"
 )
 
@@ -18,6 +21,9 @@ type AnalystAgent struct {
 	*BaseAgent
 	analysisMethods []string
 	charts          bool
+	artifacts       artifact.Store // 渲染的图表保存到产物存储，未设置时只输出图表数据
+	clock           clock.Clock    // 任务计时和模拟时间序列的日期
+	rand            *clock.Rand    // 没有输入数据时生成模拟数据
 }
 
 // NewAnalystAgent 创建分析Agent
@@ -40,14 +46,16 @@ func NewAnalystAgent() *AnalystAgent {
 
 	return &AnalystAgent{
 		BaseAgent:       base,
-		analysisMethods: []string{"mean", "median", "mode", "std_dev", "correlation"},
+		analysisMethods: []string{"mean", "median", "mode", "std_dev", "correlation", "spearman", "regression", "t_test", "chi_square"},
 		charts:          true,
+		clock:           clock.System,
+		rand:            clock.NewRand(0),
 	}
 }
 
 // Execute 执行分析任务
 func (a *AnalystAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.TaskResult, error) {
-	startTime := time.Now()
+	startTime := a.clock.Now()
 	a.UpdateStatus("running")
 
 	// 验证任务
@@ -58,20 +66,37 @@ func (a *AnalystAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.T
 	// 解析任务目标
 	analysisGoal := taskObj.Goal
 
+	// 检索之前为同一用户或项目得出的分析结论
+	ctx, recalled := a.RecallMemories(ctx, taskObj)
+
 	// 根据任务类型选择分析方法
 	var output interface{}
 	var err error
 
-	if strings.Contains(analysisGoal, "统计") || strings.Contains(analysisGoal, "分析数据") {
+	if strings.Contains(analysisGoal, "相关") {
+		events.Thought(ctx, a.Type, "相关性分析："+analysisGoal)
+		output, err = a.performCorrelationAnalysis(ctx, taskObj.Requirements)
+	} else if strings.Contains(analysisGoal, "回归") {
+		events.Thought(ctx, a.Type, "回归分析："+analysisGoal)
+		output, err = a.performRegressionAnalysis(ctx, taskObj.Requirements)
+	} else if strings.Contains(analysisGoal, "检验") || strings.Contains(analysisGoal, "显著") {
+		events.Thought(ctx, a.Type, "假设检验："+analysisGoal)
+		output, err = a.performHypothesisTest(ctx, taskObj.Requirements)
+	} else if strings.Contains(analysisGoal, "统计") || strings.Contains(analysisGoal, "分析数据") {
+		events.Thought(ctx, a.Type, "统计分析："+analysisGoal)
 		output, err = a.performStatisticalAnalysis(ctx, taskObj.Requirements)
 	} else if strings.Contains(analysisGoal, "趋势") || strings.Contains(analysisGoal, "预测") {
+		events.Thought(ctx, a.Type, "趋势分析："+analysisGoal)
 		output, err = a.performTrendAnalysis(ctx, taskObj.Requirements)
 	} else if strings.Contains(analysisGoal, "对比") || strings.Contains(analysisGoal, "比较") {
+		events.Thought(ctx, a.Type, "对比分析："+analysisGoal)
 		output, err = a.performComparativeAnalysis(ctx, taskObj.Requirements)
 	} else if strings.Contains(analysisGoal, "报告") || strings.Contains(analysisGoal, "总结") {
+		events.Thought(ctx, a.Type, "生成分析报告："+analysisGoal)
 		output, err = a.generateReport(ctx, taskObj.Requirements)
 	} else {
 		// 默认执行统计分析
+		events.Thought(ctx, a.Type, "统计分析："+analysisGoal)
 		output, err = a.performStatisticalAnalysis(ctx, taskObj.Requirements)
 	}
 
@@ -80,6 +105,18 @@ func (a *AnalystAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.T
 		return a.createErrorResult(taskObj, err, startTime), err
 	}
 
+	// 渲染的图表作为任务产物引用
+	var figures []task.ArtifactRef
+	if outMap, ok := output.(map[string]interface{}); ok {
+		figures, _ = outMap["figures"].([]task.ArtifactRef)
+		if len(recalled) > 0 {
+			outMap["prior_findings"] = recalled
+		}
+		if finding := a.memorySummary(outMap); finding != "" {
+			a.RememberOutcome(ctx, taskObj, fmt.Sprintf("分析「%s」：%s", goalSummary(analysisGoal), finding))
+		}
+	}
+
 	a.UpdateStatus("idle")
 	return &task.TaskResult{
 		TaskID:    taskObj.ID,
@@ -88,17 +125,37 @@ func (a *AnalystAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.T
 		Status:    task.TaskStatusCompleted,
 		Output:    output,
 		Error:     "",
-		Duration:  time.Since(startTime),
+		Duration:  a.clock.Now().Sub(startTime),
 		Metadata: map[string]interface{}{
 			"agent_type":        "analyst",
 			"analysis_methods":  a.analysisMethods,
 			"charts_generated":  a.charts,
+			"memories_recalled": len(recalled),
 		},
-		Timestamp: time.Now(),
+		Timestamp: a.clock.Now(),
 		AgentUsed: a.Name,
+		Artifacts: figures,
 	}, nil
 }
 
+// memorySummary 分析结论摘要，记入Agent记忆；没有可记录的结论时返回空字符串
+func (a *AnalystAgent) memorySummary(output map[string]interface{}) string {
+	if interpretation, ok := output["interpretation"].(string); ok {
+		return flattenText(interpretation, 200)
+	}
+	if trend, ok := output["trend"].(map[string]interface{}); ok {
+		if direction, ok := trend["direction"].(string); ok {
+			return fmt.Sprintf("趋势%s，斜率%.4g", direction, trend["slope"])
+		}
+	}
+	if stats, ok := output["statistics"].(map[string]interface{}); ok {
+		if count, ok := stats["count"].(int); ok {
+			return fmt.Sprintf("%d个数据点，均值%.4g，中位数%.4g，标准差%.4g", count, stats["mean"], stats["median"], stats["std_dev"])
+		}
+	}
+	return ""
+}
+
 // performStatisticalAnalysis 执行统计分析
 func (a *AnalystAgent) performStatisticalAnalysis(ctx context.Context, requirements interface{}) (interface{}, error) {
 	// 获取数据
@@ -113,12 +170,16 @@ func (a *AnalystAgent) performStatisticalAnalysis(ctx context.Context, requireme
 	// 生成可视化数据
 	chartData := a.generateChartData(data)
 
-	return map[string]interface{}{
+	output := map[string]interface{}{
 		"analysis_type": "statistical",
 		"statistics":    analysis,
 		"charts":        chartData,
 		"data_points":   len(data),
-	}, nil
+	}
+	if len(data) > 0 {
+		a.attachFigures(ctx, requirements, output, histogramChart(data, 10))
+	}
+	return output, nil
 }
 
 // performTrendAnalysis 执行趋势分析
@@ -135,13 +196,21 @@ func (a *AnalystAgent) performTrendAnalysis(ctx context.Context, requirements in
 	// 预测
 	prediction := a.predictNext(data, 3)
 
-	return map[string]interface{}{
+	output := map[string]interface{}{
 		"analysis_type": "trend",
 		"trend":         trend,
 		"prediction":    prediction,
 		"data_points":   len(data),
 		"chart_data":    data,
-	}, nil
+	}
+	values := make([]float64, 0, len(data))
+	for _, d := range data {
+		if v, ok := d["value"].(float64); ok {
+			values = append(values, v)
+		}
+	}
+	a.attachFigures(ctx, requirements, output, trendChart(values, prediction))
+	return output, nil
 }
 
 // performComparativeAnalysis 执行对比分析
@@ -158,12 +227,16 @@ func (a *AnalystAgent) performComparativeAnalysis(ctx context.Context, requireme
 	// 找出差异
 	differences := a.findDifferences(datasets)
 
-	return map[string]interface{}{
+	output := map[string]interface{}{
 		"analysis_type": "comparative",
 		"comparison":    comparison,
 		"differences":   differences,
 		"datasets":      len(datasets),
-	}, nil
+	}
+	if len(datasets) > 0 {
+		a.attachFigures(ctx, requirements, output, comparisonChart(datasets, a.mean))
+	}
+	return output, nil
 }
 
 // generateReport 生成报告
@@ -208,7 +281,7 @@ func (a *AnalystAgent) extractData(requirements interface{}) ([]float64, error)
 
 		// 从文件路径提取
 		if filePath, ok := reqMap["file_path"].(string); ok {
-			fileData, err := a.readDataFromFile(filePath)
+			fileData, err := a.readDataFromFile(filePath, csvparse.OptionsFromParams(reqMap))
 			if err == nil {
 				data = append(data, fileData...)
 			}
@@ -286,23 +359,25 @@ func (a *AnalystAgent) calculateStatistics(data []float64) map[string]interface{
 
 	stats := make(map[string]interface{})
 
-	// 基本统计
-	stats["count"] = len(data)
-	stats["mean"] = a.mean(data)
-	stats["median"] = a.median(data)
-	stats["mode"] = a.mode(data)
-	stats["min"] = a.min(data)
-	stats["max"] = a.max(data)
+	// 基本统计和离散度一次遍历得到，分位数共用一份排好序的副本
+	desc := describe(data)
+	sorted := sortedCopy(data)
+	stats["count"] = desc.count
+	stats["mean"] = desc.mean
+	stats["median"] = percentileSorted(sorted, 50)
+	stats["mode"] = modeSorted(sorted)
+	stats["min"] = desc.min
+	stats["max"] = desc.max
 
 	// 离散度
-	stats["variance"] = a.variance(data)
-	stats["std_dev"] = a.stdDev(data)
-	stats["range"] = stats["max"].(float64) - stats["min"].(float64)
+	stats["variance"] = desc.variance()
+	stats["std_dev"] = math.Sqrt(desc.variance())
+	stats["range"] = desc.max - desc.min
 
 	// 分位数
-	stats["q1"] = a.percentile(data, 25)
-	stats["q2"] = a.percentile(data, 50)
-	stats["q3"] = a.percentile(data, 75)
+	stats["q1"] = percentileSorted(sorted, 25)
+	stats["q2"] = stats["median"]
+	stats["q3"] = percentileSorted(sorted, 75)
 	stats["iqr"] = stats["q3"].(float64) - stats["q1"].(float64)
 
 	return stats
@@ -488,9 +563,10 @@ func (a *AnalystAgent) createHistogram(data []float64, bins int) map[string]inte
 
 // createBoxPlot 创建箱线图数据
 func (a *AnalystAgent) createBoxPlot(data []float64) map[string]interface{} {
-	q1 := a.percentile(data, 25)
-	q2 := a.percentile(data, 50)
-	q3 := a.percentile(data, 75)
+	sorted := sortedCopy(data)
+	q1 := percentileSorted(sorted, 25)
+	q2 := percentileSorted(sorted, 50)
+	q3 := percentileSorted(sorted, 75)
 	iqr := q3 - q1
 	min := q1 - 1.5*iqr
 	max := q3 + 1.5*iqr
@@ -538,16 +614,13 @@ func (a *AnalystAgent) buildReport(data []float64) string {
 	return report
 }
 
-// readDataFromFile 从文件读取数据
-func (a *AnalystAgent) readDataFromFile(filePath string) ([]float64, error) {
-	file, err := os.Open(filePath)
+// readDataFromFile 从CSV文件读取数值，opts为要求中的delimiter、quote、escape、encoding（默认自动识别编码）
+func (a *AnalystAgent) readDataFromFile(filePath string, opts csvparse.Options) ([]float64, error) {
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	records, err := csvparse.Parse(content, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -555,7 +628,7 @@ func (a *AnalystAgent) readDataFromFile(filePath string) ([]float64, error) {
 	data := make([]float64, 0)
 	for _, record := range records {
 		for _, field := range record {
-			if val, err := strconv.ParseFloat(field, 64); err == nil {
+			if val, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
 				data = append(data, val)
 			}
 		}
@@ -564,32 +637,30 @@ func (a *AnalystAgent) readDataFromFile(filePath string) ([]float64, error) {
 	return data, nil
 }
 
-// generateMockData 生成模拟数据
+// generateMockData 生成模拟数据：均值50、标准差10的正态分布
 func (a *AnalystAgent) generateMockData(count int) []float64 {
 	data := make([]float64, count)
 	for i := 0; i < count; i++ {
-		// 生成正态分布随机数
-		u1 := 0.0 + float64(i)/float64(count)
-		u2 := 0.0 + float64(i)/float64(count)
-		data[i] = math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2) * 10 + 50
+		data[i] = a.rand.NormFloat64()*10 + 50
 	}
 	return data
 }
 
-// generateMockTimeSeries 生成模拟时间序列
+// generateMockTimeSeries 生成模拟时间序列：截至今天的days天随机游走
 func (a *AnalystAgent) generateMockTimeSeries(days int) []map[string]interface{} {
 	data := make([]map[string]interface{}, days)
 	baseValue := 100.0
+	start := a.clock.Now().AddDate(0, 0, 1-days)
 
 	for i := 0; i < days; i++ {
-		change := (randFloat() - 0.5) * 10
+		change := (a.rand.Float64() - 0.5) * 10
 		baseValue += change
 		if baseValue < 0 {
 			baseValue = 0
 		}
 
 		data[i] = map[string]interface{}{
-			"date":  fmt.Sprintf("2024-01-%02d", i+1),
+			"date":  start.AddDate(0, 0, i).Format("2006-01-02"),
 			"value": baseValue,
 		}
 	}
@@ -623,40 +694,14 @@ func (a *AnalystAgent) median(data []float64) float64 {
 	if len(data) == 0 {
 		return 0
 	}
-	sorted := make([]float64, len(data))
-	copy(sorted, data)
-	// 简单排序
-	for i := 0; i < len(sorted); i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i] > sorted[j] {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
-	}
-	mid := len(sorted) / 2
-	if len(sorted)%2 == 0 {
-		return (sorted[mid-1] + sorted[mid]) / 2
-	}
-	return sorted[mid]
+	return a.percentile(data, 50)
 }
 
 func (a *AnalystAgent) mode(data []float64) float64 {
 	if len(data) == 0 {
 		return 0
 	}
-	freq := make(map[float64]int)
-	for _, v := range data {
-		freq[v]++
-	}
-	maxFreq := 0
-	mode := data[0]
-	for v, f := range freq {
-		if f > maxFreq {
-			maxFreq = f
-			mode = v
-		}
-	}
-	return mode
+	return modeSorted(sortedCopy(data))
 }
 
 func (a *AnalystAgent) min(data []float64) float64 {
@@ -689,13 +734,7 @@ func (a *AnalystAgent) variance(data []float64) float64 {
 	if len(data) == 0 {
 		return 0
 	}
-	m := a.mean(data)
-	sum := 0.0
-	for _, v := range data {
-		diff := v - m
-		sum += diff * diff
-	}
-	return sum / float64(len(data))
+	return describe(data).variance()
 }
 
 func (a *AnalystAgent) stdDev(data []float64) float64 {
@@ -706,23 +745,23 @@ func (a *AnalystAgent) percentile(data []float64, p float64) float64 {
 	if len(data) == 0 {
 		return 0
 	}
-	sorted := make([]float64, len(data))
-	copy(sorted, data)
-	// 简单排序
-	for i := 0; i < len(sorted); i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i] > sorted[j] {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
-	}
-	k := (p / 100) * float64(len(sorted)-1)
+	// 只需要单个分位数时用快速选择，平均O(n)，不必完整排序
+	values := make([]float64, len(data))
+	copy(values, data)
+	k := (p / 100) * float64(len(values)-1)
 	low := int(k)
-	high := low + 1
-	if high >= len(sorted) {
-		return sorted[low]
+	lowValue := selectKth(values, low)
+	if low+1 >= len(values) || k == float64(low) {
+		return lowValue
+	}
+	// 选择后low之后的元素都不小于lowValue，其中的最小值就是第low+1小的元素
+	highValue := values[low+1]
+	for _, v := range values[low+2:] {
+		if v < highValue {
+			highValue = v
+		}
 	}
-	return sorted[low] + (k-float64(low))*(sorted[high]-sorted[low])
+	return lowValue + (k-float64(low))*(highValue-lowValue)
 }
 
 func (a *AnalystAgent) argmax(arr []float64) int {
@@ -749,10 +788,6 @@ func (a *AnalystAgent) argmin(arr []float64) int {
 	return minIdx
 }
 
-func randFloat() float64 {
-	return float64(time.Now().UnixNano()%10000) / 10000.0
-}
-
 // createErrorResult 创建错误结果
 func (a *AnalystAgent) createErrorResult(taskObj *task.Task, err error, startTime time.Time) *task.TaskResult {
 	return &task.TaskResult{
@@ -762,15 +797,25 @@ func (a *AnalystAgent) createErrorResult(taskObj *task.Task, err error, startTim
 		Status:    task.TaskStatusFailed,
 		Output:    nil,
 		Error:     err.Error(),
-		Duration:  time.Since(startTime),
+		Duration:  a.clock.Now().Sub(startTime),
 		Metadata: map[string]interface{}{
 			"agent_type": "analyst",
 		},
-		Timestamp: time.Now(),
+		Timestamp: a.clock.Now(),
 		AgentUsed: a.Name,
 	}
 }
 
+// SetClock 设置时钟，测试和模拟中使用clock.Fake使计时和模拟数据的日期可复现
+func (a *AnalystAgent) SetClock(c clock.Clock) {
+	a.clock = clock.OrSystem(c)
+}
+
+// SetSeed 设置模拟数据的随机种子，种子相同时生成的模拟数据相同；0表示使用当前时间
+func (a *AnalystAgent) SetSeed(seed int64) {
+	a.rand = clock.NewRand(seed)
+}
+
 // SetCharts 设置是否生成图表
 func (a *AnalystAgent) SetCharts(enable bool) {
 	a.charts = enable
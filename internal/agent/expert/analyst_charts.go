@@ -0,0 +1,266 @@
+package expert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/task"
+)
+
+// defaultChartFormat 未指定chart_format时渲染的图表格式
+const defaultChartFormat = "svg"
+
+// chartRenderers 支持的图表格式
+var chartRenderers = map[string]struct {
+	provider    chart.RendererProvider
+	contentType string
+}{
+	"svg": {chart.SVG, "image/svg+xml"},
+	"png": {chart.PNG, "image/png"},
+}
+
+// chartSpec 一张待渲染的图表
+// 内置字体不含中文字形，图表中的标题和坐标轴使用英文
+type chartSpec struct {
+	name   string // 产物文件名（不含扩展名）
+	title  string
+	render func(rp chart.RendererProvider, w io.Writer) error
+}
+
+// SetArtifactStore 设置产物存储，设置后开启图表时把图表渲染为图片保存，并在结果中引用
+func (a *AnalystAgent) SetArtifactStore(store artifact.Store) {
+	a.artifacts = store
+}
+
+// chartsEnabled 是否渲染图表：需开启图表、设置了产物存储，且任务未通过charts/generate_charts关闭
+func (a *AnalystAgent) chartsEnabled(requirements interface{}) bool {
+	if !a.charts || a.artifacts == nil {
+		return false
+	}
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		for _, key := range []string{"charts", "generate_charts"} {
+			if enabled, ok := reqMap[key].(bool); ok && !enabled {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// chartFormats 读取任务要求的图表格式（chart_format，如 "png" 或 ["svg", "png"]），默认SVG
+func chartFormats(requirements interface{}) []string {
+	var requested []string
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		switch v := reqMap["chart_format"].(type) {
+		case string:
+			requested = strings.Split(v, ",")
+		case []interface{}:
+			for _, f := range v {
+				if s, ok := f.(string); ok {
+					requested = append(requested, s)
+				}
+			}
+		case []string:
+			requested = v
+		}
+	}
+
+	formats := make([]string, 0, len(requested))
+	seen := make(map[string]bool)
+	for _, f := range requested {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if _, ok := chartRenderers[f]; ok && !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	if len(formats) == 0 {
+		formats = []string{defaultChartFormat}
+	}
+	return formats
+}
+
+// attachFigures 渲染图表并保存为产物，在output中记录引用（figures）和渲染失败的原因（chart_errors）
+// 渲染失败不影响分析结果
+func (a *AnalystAgent) attachFigures(ctx context.Context, requirements interface{}, output map[string]interface{}, specs ...chartSpec) {
+	if !a.chartsEnabled(requirements) || len(specs) == 0 {
+		return
+	}
+
+	figures := make([]task.ArtifactRef, 0, len(specs))
+	var chartErrors []string
+	for _, spec := range specs {
+		for _, format := range chartFormats(requirements) {
+			ref, err := a.saveChart(ctx, spec, format)
+			if err != nil {
+				chartErrors = append(chartErrors, fmt.Sprintf("%s.%s: %v", spec.name, format, err))
+				continue
+			}
+			figures = append(figures, ref)
+		}
+	}
+
+	if len(figures) > 0 {
+		events.Thought(ctx, a.Type, fmt.Sprintf("已渲染%d张图表", len(figures)))
+	}
+	output["figures"] = figures
+	if len(chartErrors) > 0 {
+		output["chart_errors"] = chartErrors
+	}
+}
+
+// saveChart 按格式渲染一张图表并保存到产物存储
+func (a *AnalystAgent) saveChart(ctx context.Context, spec chartSpec, format string) (task.ArtifactRef, error) {
+	renderer := chartRenderers[format]
+	var buf bytes.Buffer
+	if err := spec.render(renderer.provider, &buf); err != nil {
+		return task.ArtifactRef{}, fmt.Errorf("render failed: %w", err)
+	}
+
+	item := &artifact.Artifact{
+		Name:        spec.name + "." + format,
+		ContentType: renderer.contentType,
+		Source:      a.Type,
+	}
+	if err := a.artifacts.Save(ctx, item, buf.Bytes()); err != nil {
+		return task.ArtifactRef{}, fmt.Errorf("save failed: %w", err)
+	}
+	return task.ArtifactRef{
+		ID:          item.ID,
+		Name:        item.Name,
+		Title:       spec.title,
+		ContentType: item.ContentType,
+		URL:         item.URL(),
+	}, nil
+}
+
+// valueRange 包含0的纵轴范围，数据全部相同时放宽上界，避免渲染时范围为0
+func valueRange(values []float64) *chart.ContinuousRange {
+	min, max := 0.0, 0.0
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if max == min {
+		max = min + 1
+	}
+	return &chart.ContinuousRange{Min: min, Max: max}
+}
+
+// barChart 柱状图
+func barChart(title string, labels []string, values []float64) func(chart.RendererProvider, io.Writer) error {
+	bars := make([]chart.Value, len(values))
+	for i, v := range values {
+		bars[i] = chart.Value{Label: labels[i], Value: v}
+	}
+	width := 120 + 96*len(bars)
+	if width < 480 {
+		width = 480
+	}
+	return chart.BarChart{
+		Title:      title,
+		Width:      width,
+		Height:     400,
+		BarWidth:   64,
+		BarSpacing: 32,
+		Background: chart.Style{Padding: chart.Box{Top: 40, Left: 20, Right: 20, Bottom: 20}},
+		YAxis:      chart.YAxis{Range: valueRange(values)},
+		Bars:       bars,
+	}.Render
+}
+
+// histogramChart 数据分布直方图，最后一个区间包含最大值
+func histogramChart(data []float64, bins int) chartSpec {
+	min, max := data[0], data[0]
+	for _, v := range data {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if max == min {
+		bins = 1
+	}
+	width := (max - min) / float64(bins)
+
+	counts := make([]float64, bins)
+	for _, v := range data {
+		i := bins - 1
+		if width > 0 {
+			i = int((v - min) / width)
+		}
+		if i >= bins {
+			i = bins - 1
+		}
+		counts[i]++
+	}
+
+	labels := make([]string, bins)
+	for i := range labels {
+		labels[i] = chart.FloatValueFormatter(min + float64(i)*width)
+	}
+	return chartSpec{
+		name:   "histogram",
+		title:  "数据分布直方图",
+		render: barChart("Distribution", labels, counts),
+	}
+}
+
+// trendChart 时间序列折线图，预测值以虚线接在观测值之后
+func trendChart(values, prediction []float64) chartSpec {
+	xs := make([]float64, len(values))
+	for i := range xs {
+		xs[i] = float64(i + 1)
+	}
+	series := []chart.Series{
+		chart.ContinuousSeries{Name: "observed", XValues: xs, YValues: values},
+	}
+	if len(prediction) > 0 && len(values) > 0 {
+		px := []float64{xs[len(xs)-1]}
+		py := []float64{values[len(values)-1]}
+		for i, p := range prediction {
+			px = append(px, float64(len(values)+i+1))
+			py = append(py, p)
+		}
+		series = append(series, chart.ContinuousSeries{
+			Name:    "prediction",
+			Style:   chart.Style{StrokeColor: drawing.ColorRed, StrokeDashArray: []float64{5, 5}, StrokeWidth: 2},
+			XValues: px,
+			YValues: py,
+		})
+	}
+
+	c := chart.Chart{
+		Title:      "Trend",
+		Width:      800,
+		Height:     400,
+		Background: chart.Style{Padding: chart.Box{Top: 40, Left: 20, Right: 20, Bottom: 20}},
+		XAxis:      chart.XAxis{Name: "t"},
+		YAxis:      chart.YAxis{Name: "value"},
+		Series:     series,
+	}
+	c.Elements = []chart.Renderable{chart.Legend(&c)}
+	return chartSpec{name: "trend", title: "趋势与预测", render: c.Render}
+}
+
+// comparisonChart 各数据集均值柱状图
+func comparisonChart(datasets [][]float64, mean func([]float64) float64) chartSpec {
+	labels := make([]string, len(datasets))
+	means := make([]float64, len(datasets))
+	for i, data := range datasets {
+		labels[i] = fmt.Sprintf("dataset_%d", i+1)
+		means[i] = mean(data)
+	}
+	return chartSpec{
+		name:   "comparison",
+		title:  "各数据集均值对比",
+		render: barChart("Mean by dataset", labels, means),
+	}
+}
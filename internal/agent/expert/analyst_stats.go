@@ -0,0 +1,903 @@
+package expert
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// defaultSignificance 未指定显著性水平时使用的alpha
+const defaultSignificance = 0.05
+
+// performCorrelationAnalysis 计算各变量间的Pearson和Spearman相关系数矩阵
+// requirements.variables 为 变量名 -> 数值数组，各变量长度需一致
+func (a *AnalystAgent) performCorrelationAnalysis(ctx context.Context, requirements interface{}) (interface{}, error) {
+	names, variables, err := a.extractVariables(requirements)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) < 2 {
+		return nil, fmt.Errorf("correlation analysis requires at least 2 variables")
+	}
+
+	n := len(variables[names[0]])
+	pearson := make([][]float64, len(names))
+	spearman := make([][]float64, len(names))
+	pairs := make([]map[string]interface{}, 0)
+	for i, x := range names {
+		pearson[i] = make([]float64, len(names))
+		spearman[i] = make([]float64, len(names))
+		for j, y := range names {
+			pearson[i][j] = pearsonCorrelation(variables[x], variables[y])
+			spearman[i][j] = spearmanCorrelation(variables[x], variables[y])
+			if j > i {
+				r := pearson[i][j]
+				pairs = append(pairs, map[string]interface{}{
+					"x":        x,
+					"y":        y,
+					"pearson":  r,
+					"spearman": spearman[i][j],
+					"p_value":  correlationPValue(r, n),
+				})
+			}
+		}
+	}
+
+	// 按相关强度排序，便于解读最强的关系
+	sort.Slice(pairs, func(i, j int) bool {
+		return math.Abs(pairs[i]["pearson"].(float64)) > math.Abs(pairs[j]["pearson"].(float64))
+	})
+
+	alpha := a.significanceLevel(requirements)
+	lines := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		r := pair["pearson"].(float64)
+		significance := "不显著"
+		if pair["p_value"].(float64) < alpha {
+			significance = "显著"
+		}
+		lines = append(lines, fmt.Sprintf("%s与%s呈%s（r=%.3f，Spearman ρ=%.3f，p=%.4f，%s）",
+			pair["x"], pair["y"], describeCorrelation(r), r, pair["spearman"], pair["p_value"], significance))
+	}
+
+	return map[string]interface{}{
+		"analysis_type":  "correlation",
+		"variables":      names,
+		"pearson":        pearson,
+		"spearman":       spearman,
+		"pairs":          pairs,
+		"data_points":    n,
+		"alpha":          alpha,
+		"interpretation": strings.Join(lines, "；"),
+	}, nil
+}
+
+// performRegressionAnalysis 多元线性回归（最小二乘）
+// requirements.variables 提供数据，requirements.target 为因变量，requirements.features 为自变量（缺省为其余全部变量）
+func (a *AnalystAgent) performRegressionAnalysis(ctx context.Context, requirements interface{}) (interface{}, error) {
+	names, variables, err := a.extractVariables(requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	reqMap, _ := requirements.(map[string]interface{})
+	target, _ := reqMap["target"].(string)
+	if target == "" {
+		return nil, fmt.Errorf("regression requires a target variable")
+	}
+	y, ok := variables[target]
+	if !ok {
+		return nil, fmt.Errorf("target variable %q not found", target)
+	}
+
+	features := toStrings(reqMap["features"])
+	if len(features) == 0 {
+		for _, name := range names {
+			if name != target {
+				features = append(features, name)
+			}
+		}
+	}
+	if len(features) == 0 {
+		return nil, fmt.Errorf("regression requires at least 1 feature")
+	}
+
+	n, k := len(y), len(features)+1
+	if n <= k {
+		return nil, fmt.Errorf("regression requires more observations (%d) than coefficients (%d)", n, k)
+	}
+
+	// 设计矩阵，第一列为截距
+	X := make([][]float64, n)
+	for i := range X {
+		X[i] = make([]float64, k)
+		X[i][0] = 1
+		for j, feature := range features {
+			column, ok := variables[feature]
+			if !ok {
+				return nil, fmt.Errorf("feature variable %q not found", feature)
+			}
+			X[i][j+1] = column[i]
+		}
+	}
+
+	// 正规方程 (XᵀX)β = Xᵀy
+	xtx := make([][]float64, k)
+	xty := make([]float64, k)
+	for p := 0; p < k; p++ {
+		xtx[p] = make([]float64, k)
+		for q := 0; q < k; q++ {
+			for i := 0; i < n; i++ {
+				xtx[p][q] += X[i][p] * X[i][q]
+			}
+		}
+		for i := 0; i < n; i++ {
+			xty[p] += X[i][p] * y[i]
+		}
+	}
+	inverse, err := invertMatrix(xtx)
+	if err != nil {
+		return nil, fmt.Errorf("features are collinear: %w", err)
+	}
+	beta := make([]float64, k)
+	for p := 0; p < k; p++ {
+		for q := 0; q < k; q++ {
+			beta[p] += inverse[p][q] * xty[q]
+		}
+	}
+
+	// 拟合优度
+	meanY := a.mean(y)
+	var ssRes, ssTot float64
+	for i := 0; i < n; i++ {
+		predicted := 0.0
+		for p := 0; p < k; p++ {
+			predicted += X[i][p] * beta[p]
+		}
+		ssRes += (y[i] - predicted) * (y[i] - predicted)
+		ssTot += (y[i] - meanY) * (y[i] - meanY)
+	}
+	df := float64(n - k)
+	rSquared := 1.0
+	if ssTot > 0 {
+		rSquared = 1 - ssRes/ssTot
+	}
+	adjusted := 1 - (1-rSquared)*float64(n-1)/df
+	sigma2 := ssRes / df
+
+	alpha := a.significanceLevel(requirements)
+	coefficients := make([]map[string]interface{}, k)
+	significant := make([]string, 0)
+	for p := 0; p < k; p++ {
+		name := "intercept"
+		if p > 0 {
+			name = features[p-1]
+		}
+		stdErr := math.Sqrt(sigma2 * inverse[p][p])
+		tStat, pValue := 0.0, 1.0
+		if stdErr > 0 {
+			tStat = beta[p] / stdErr
+			pValue = studentTPValue(tStat, df)
+		}
+		coefficients[p] = map[string]interface{}{
+			"name":      name,
+			"estimate":  beta[p],
+			"std_error": stdErr,
+			"t_stat":    tStat,
+			"p_value":   pValue,
+		}
+		if p > 0 && pValue < alpha {
+			significant = append(significant, fmt.Sprintf("%s（系数%.4f，p=%.4f）", name, beta[p], pValue))
+		}
+	}
+
+	interpretation := fmt.Sprintf("以%s为因变量的回归模型解释了%.1f%%的方差（调整R²=%.3f）", target, rSquared*100, adjusted)
+	if len(significant) > 0 {
+		interpretation += fmt.Sprintf("；在α=%.2f下显著的自变量：%s", alpha, strings.Join(significant, "、"))
+	} else {
+		interpretation += fmt.Sprintf("；在α=%.2f下没有显著的自变量", alpha)
+	}
+
+	return map[string]interface{}{
+		"analysis_type":      "regression",
+		"target":             target,
+		"features":           features,
+		"coefficients":       coefficients,
+		"r_squared":          rSquared,
+		"adjusted_r_squared": adjusted,
+		"residual_std":       math.Sqrt(sigma2),
+		"data_points":        n,
+		"alpha":              alpha,
+		"interpretation":     interpretation,
+	}, nil
+}
+
+// performHypothesisTest 假设检验
+// requirements.contingency_table 为列联表时做卡方独立性检验；
+// 否则对 requirements.samples（或datasets）的两组样本做t检验（paired为true时配对，否则Welch），
+// 只有一组样本时与 requirements.mu 做单样本t检验
+func (a *AnalystAgent) performHypothesisTest(ctx context.Context, requirements interface{}) (interface{}, error) {
+	reqMap, _ := requirements.(map[string]interface{})
+	alpha := a.significanceLevel(requirements)
+
+	if table, ok := reqMap["contingency_table"].([]interface{}); ok {
+		observed := make([][]float64, 0, len(table))
+		for _, row := range table {
+			observed = append(observed, toFloats(row))
+		}
+		result, err := chiSquareTest(observed)
+		if err != nil {
+			return nil, err
+		}
+		result["analysis_type"] = "hypothesis_test"
+		result["alpha"] = alpha
+		result["interpretation"] = interpretTest("行变量与列变量相互独立", result["p_value"].(float64), alpha)
+		return result, nil
+	}
+
+	samples := make([][]float64, 0, 2)
+	if sampleMap, ok := reqMap["samples"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(sampleMap))
+		for key := range sampleMap {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			samples = append(samples, toFloats(sampleMap[key]))
+		}
+	} else if sampleList, ok := reqMap["samples"].([]interface{}); ok {
+		for _, sample := range sampleList {
+			samples = append(samples, toFloats(sample))
+		}
+	} else if datasets, ok := reqMap["datasets"].([]interface{}); ok {
+		for _, dataset := range datasets {
+			samples = append(samples, toFloats(dataset))
+		}
+	}
+
+	var result map[string]interface{}
+	var err error
+	var hypothesis string
+	switch {
+	case len(samples) >= 2:
+		paired, _ := reqMap["paired"].(bool)
+		if paired {
+			result, err = pairedTTest(samples[0], samples[1])
+			hypothesis = "两组配对样本的均值差为0"
+		} else {
+			result, err = welchTTest(samples[0], samples[1])
+			hypothesis = "两组样本的均值相等"
+		}
+	case len(samples) == 1:
+		mu, _ := reqMap["mu"].(float64)
+		result, err = oneSampleTTest(samples[0], mu)
+		hypothesis = fmt.Sprintf("样本均值等于%.4g", mu)
+	default:
+		return nil, fmt.Errorf("hypothesis test requires samples or a contingency_table")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result["analysis_type"] = "hypothesis_test"
+	result["alpha"] = alpha
+	result["interpretation"] = interpretTest(hypothesis, result["p_value"].(float64), alpha)
+	return result, nil
+}
+
+// interpretTest 生成检验结论
+func interpretTest(hypothesis string, pValue, alpha float64) string {
+	if pValue < alpha {
+		return fmt.Sprintf("p=%.4f < α=%.2f，拒绝原假设（%s），差异具有统计显著性", pValue, alpha, hypothesis)
+	}
+	return fmt.Sprintf("p=%.4f ≥ α=%.2f，不能拒绝原假设（%s）", pValue, alpha, hypothesis)
+}
+
+// extractVariables 提取命名变量，按变量名排序返回
+func (a *AnalystAgent) extractVariables(requirements interface{}) ([]string, map[string][]float64, error) {
+	reqMap, _ := requirements.(map[string]interface{})
+	raw, ok := reqMap["variables"].(map[string]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, nil, fmt.Errorf("variables are required (name -> numeric array)")
+	}
+
+	names := make([]string, 0, len(raw))
+	variables := make(map[string][]float64, len(raw))
+	n := -1
+	for name, values := range raw {
+		data := toFloats(values)
+		if n >= 0 && len(data) != n {
+			return nil, nil, fmt.Errorf("variable %q has %d values, expected %d", name, len(data), n)
+		}
+		n = len(data)
+		names = append(names, name)
+		variables[name] = data
+	}
+	if n < 3 {
+		return nil, nil, fmt.Errorf("at least 3 observations are required")
+	}
+	sort.Strings(names)
+	return names, variables, nil
+}
+
+// significanceLevel 显著性水平，requirements.alpha 未指定或不合法时为0.05
+func (a *AnalystAgent) significanceLevel(requirements interface{}) float64 {
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		if alpha, ok := reqMap["alpha"].(float64); ok && alpha > 0 && alpha < 1 {
+			return alpha
+		}
+	}
+	return defaultSignificance
+}
+
+// toFloats 把JSON数组转换为数值数组，忽略非数值元素
+func toFloats(v interface{}) []float64 {
+	switch values := v.(type) {
+	case []float64:
+		return values
+	case []int:
+		data := make([]float64, len(values))
+		for i, value := range values {
+			data[i] = float64(value)
+		}
+		return data
+	case []interface{}:
+		data := make([]float64, 0, len(values))
+		for _, value := range values {
+			switch num := value.(type) {
+			case float64:
+				data = append(data, num)
+			case int:
+				data = append(data, float64(num))
+			}
+		}
+		return data
+	}
+	return nil
+}
+
+// toStrings 把JSON数组转换为字符串数组
+func toStrings(v interface{}) []string {
+	switch values := v.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		result := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// describeCorrelation 相关系数的文字描述
+func describeCorrelation(r float64) string {
+	direction := "正"
+	if r < 0 {
+		direction = "负"
+	}
+	switch abs := math.Abs(r); {
+	case abs >= 0.8:
+		return "强" + direction + "相关"
+	case abs >= 0.5:
+		return "中等" + direction + "相关"
+	case abs >= 0.3:
+		return "弱" + direction + "相关"
+	default:
+		return "几乎不相关"
+	}
+}
+
+// pearsonCorrelation Pearson相关系数，任一变量方差为0时返回0
+func pearsonCorrelation(x, y []float64) float64 {
+	n := len(x)
+	if n == 0 || n != len(y) {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := 0; i < n; i++ {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX, varY float64
+	for i := 0; i < n; i++ {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// spearmanCorrelation Spearman秩相关系数（并列值取平均秩）
+func spearmanCorrelation(x, y []float64) float64 {
+	return pearsonCorrelation(ranks(x), ranks(y))
+}
+
+// ranks 计算秩，并列值取平均秩
+func ranks(data []float64) []float64 {
+	idx := make([]int, len(data))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return data[idx[i]] < data[idx[j]] })
+
+	result := make([]float64, len(data))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && data[idx[j+1]] == data[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			result[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return result
+}
+
+// correlationPValue 相关系数的双侧p值（t = r·√((n-2)/(1-r²))）
+func correlationPValue(r float64, n int) float64 {
+	if n < 3 {
+		return 1
+	}
+	if math.Abs(r) >= 1 {
+		return 0
+	}
+	df := float64(n - 2)
+	t := r * math.Sqrt(df/(1-r*r))
+	return studentTPValue(t, df)
+}
+
+// sampleVariance 样本方差（n-1）
+func sampleVariance(data []float64, mean float64) float64 {
+	sum := 0.0
+	for _, v := range data {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(data)-1)
+}
+
+// sampleMean 样本均值
+func sampleMean(data []float64) float64 {
+	sum := 0.0
+	for _, v := range data {
+		sum += v
+	}
+	return sum / float64(len(data))
+}
+
+// descriptiveStats 一次遍历得到的描述统计量
+type descriptiveStats struct {
+	count    int
+	mean     float64
+	m2       float64 // 与均值之差的平方和
+	min, max float64
+}
+
+// describe 用Welford算法一次遍历计算数量、均值、方差和极值，数值稳定且不需要额外内存
+func describe(data []float64) descriptiveStats {
+	var s descriptiveStats
+	for i, v := range data {
+		if i == 0 {
+			s.min, s.max = v, v
+		} else if v < s.min {
+			s.min = v
+		} else if v > s.max {
+			s.max = v
+		}
+		s.count++
+		delta := v - s.mean
+		s.mean += delta / float64(s.count)
+		s.m2 += delta * (v - s.mean)
+	}
+	return s
+}
+
+// variance 总体方差（n）
+func (s descriptiveStats) variance() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.count)
+}
+
+// sortedCopy 返回升序排列的副本
+func sortedCopy(data []float64) []float64 {
+	sorted := make([]float64, len(data))
+	copy(sorted, data)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// percentileSorted 在已排序数据上按线性插值计算p分位数（p取0~100）
+func percentileSorted(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	k := (p / 100) * float64(len(sorted)-1)
+	low := int(k)
+	if low+1 >= len(sorted) {
+		return sorted[low]
+	}
+	return sorted[low] + (k-float64(low))*(sorted[low+1]-sorted[low])
+}
+
+// modeSorted 在已排序数据上找出现次数最多的值（最长的连续段），并列时取较小值
+func modeSorted(sorted []float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	mode, best := sorted[0], 0
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		if j-i > best {
+			mode, best = sorted[i], j-i
+		}
+		if j == i {
+			j++ // NaN与自身不相等
+		}
+		i = j
+	}
+	return mode
+}
+
+// selectKth 快速选择第k小（从0开始）的元素，会重排values：
+// 返回后values[k]为该元素，其前面的元素都不大于它，后面的都不小于它。
+// 三路划分使大量重复值时仍保持平均O(n)
+func selectKth(values []float64, k int) float64 {
+	lo, hi := 0, len(values)-1
+	for lo < hi {
+		// 三数取中作为主元，避免有序输入退化为O(n²)
+		mid := lo + (hi-lo)/2
+		if values[mid] < values[lo] {
+			values[mid], values[lo] = values[lo], values[mid]
+		}
+		if values[hi] < values[lo] {
+			values[hi], values[lo] = values[lo], values[hi]
+		}
+		if values[hi] < values[mid] {
+			values[hi], values[mid] = values[mid], values[hi]
+		}
+		pivot := values[mid]
+
+		// 划分为 < pivot | == pivot | > pivot
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			switch {
+			case values[i] < pivot:
+				values[lt], values[i] = values[i], values[lt]
+				lt++
+				i++
+			case values[i] > pivot:
+				values[i], values[gt] = values[gt], values[i]
+				gt--
+			default:
+				i++
+			}
+		}
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return values[k]
+		}
+	}
+	return values[k]
+}
+
+// welchTTest Welch双样本t检验（不假设方差相等）
+func welchTTest(a, b []float64) (map[string]interface{}, error) {
+	if len(a) < 2 || len(b) < 2 {
+		return nil, fmt.Errorf("t-test requires at least 2 observations per sample")
+	}
+	meanA, meanB := sampleMean(a), sampleMean(b)
+	varA, varB := sampleVariance(a, meanA)/float64(len(a)), sampleVariance(b, meanB)/float64(len(b))
+	if varA+varB == 0 {
+		return nil, fmt.Errorf("t-test requires samples with non-zero variance")
+	}
+	t := (meanA - meanB) / math.Sqrt(varA+varB)
+	df := (varA + varB) * (varA + varB) /
+		(varA*varA/float64(len(a)-1) + varB*varB/float64(len(b)-1))
+
+	return map[string]interface{}{
+		"test":        "welch_t_test",
+		"statistic":   t,
+		"df":          df,
+		"p_value":     studentTPValue(t, df),
+		"mean_a":      meanA,
+		"mean_b":      meanB,
+		"mean_diff":   meanA - meanB,
+		"sample_size": []int{len(a), len(b)},
+	}, nil
+}
+
+// pairedTTest 配对样本t检验
+func pairedTTest(a, b []float64) (map[string]interface{}, error) {
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("paired t-test requires samples of equal length")
+	}
+	diff := make([]float64, len(a))
+	for i := range a {
+		diff[i] = a[i] - b[i]
+	}
+	result, err := oneSampleTTest(diff, 0)
+	if err != nil {
+		return nil, err
+	}
+	result["test"] = "paired_t_test"
+	return result, nil
+}
+
+// oneSampleTTest 单样本t检验
+func oneSampleTTest(data []float64, mu float64) (map[string]interface{}, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("t-test requires at least 2 observations")
+	}
+	mean := sampleMean(data)
+	se := math.Sqrt(sampleVariance(data, mean) / float64(len(data)))
+	if se == 0 {
+		return nil, fmt.Errorf("t-test requires a sample with non-zero variance")
+	}
+	t := (mean - mu) / se
+	df := float64(len(data) - 1)
+
+	return map[string]interface{}{
+		"test":        "one_sample_t_test",
+		"statistic":   t,
+		"df":          df,
+		"p_value":     studentTPValue(t, df),
+		"mean":        mean,
+		"mu":          mu,
+		"sample_size": len(data),
+	}, nil
+}
+
+// chiSquareTest 列联表卡方独立性检验，附带Cramér's V效应量
+func chiSquareTest(observed [][]float64) (map[string]interface{}, error) {
+	rows := len(observed)
+	if rows < 2 {
+		return nil, fmt.Errorf("chi-square test requires at least a 2x2 table")
+	}
+	cols := len(observed[0])
+	if cols < 2 {
+		return nil, fmt.Errorf("chi-square test requires at least a 2x2 table")
+	}
+
+	rowTotals := make([]float64, rows)
+	colTotals := make([]float64, cols)
+	total := 0.0
+	for i, row := range observed {
+		if len(row) != cols {
+			return nil, fmt.Errorf("contingency table rows must have equal length")
+		}
+		for j, v := range row {
+			if v < 0 {
+				return nil, fmt.Errorf("contingency table counts must be non-negative")
+			}
+			rowTotals[i] += v
+			colTotals[j] += v
+			total += v
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("contingency table is empty")
+	}
+
+	chi2 := 0.0
+	expected := make([][]float64, rows)
+	for i := range observed {
+		expected[i] = make([]float64, cols)
+		for j := range observed[i] {
+			e := rowTotals[i] * colTotals[j] / total
+			expected[i][j] = e
+			if e > 0 {
+				chi2 += (observed[i][j] - e) * (observed[i][j] - e) / e
+			}
+		}
+	}
+	df := float64((rows - 1) * (cols - 1))
+	minDim := math.Min(float64(rows), float64(cols)) - 1
+
+	return map[string]interface{}{
+		"test":       "chi_square_independence",
+		"statistic":  chi2,
+		"df":         df,
+		"p_value":    chiSquarePValue(chi2, df),
+		"expected":   expected,
+		"cramers_v":  math.Sqrt(chi2 / (total * minDim)),
+		"total":      total,
+		"table_size": []int{rows, cols},
+	}, nil
+}
+
+// invertMatrix Gauss-Jordan消元求逆矩阵（部分主元）
+func invertMatrix(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	aug := make([][]float64, n)
+	for i := range m {
+		aug[i] = make([]float64, 2*n)
+		copy(aug[i], m[i])
+		aug[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(aug[row][col]) > math.Abs(aug[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(aug[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("matrix is singular")
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		scale := aug[col][col]
+		for j := range aug[col] {
+			aug[col][j] /= scale
+		}
+		for row := 0; row < n; row++ {
+			if row == col || aug[row][col] == 0 {
+				continue
+			}
+			factor := aug[row][col]
+			for j := range aug[row] {
+				aug[row][j] -= factor * aug[col][j]
+			}
+		}
+	}
+
+	inverse := make([][]float64, n)
+	for i := range aug {
+		inverse[i] = aug[i][n:]
+	}
+	return inverse, nil
+}
+
+// studentTPValue t分布的双侧p值：I_{df/(df+t²)}(df/2, 1/2)
+func studentTPValue(t, df float64) float64 {
+	if df <= 0 || math.IsNaN(t) {
+		return 1
+	}
+	return regularizedIncompleteBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// chiSquarePValue 卡方分布的上侧p值：Q(df/2, x/2)
+func chiSquarePValue(x, df float64) float64 {
+	if x <= 0 || df <= 0 || math.IsNaN(x) {
+		return 1
+	}
+	return 1 - regularizedLowerGamma(df/2, x/2)
+}
+
+// regularizedIncompleteBeta 正则化不完全贝塔函数 I_x(a, b)，使用连分式展开
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lbeta, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lbeta - la - lb + a*math.Log(x) + b*math.Log(1-x))
+
+	// 连分式在 x < (a+1)/(a+b+2) 时收敛较快，否则利用对称性 I_x(a,b) = 1 - I_{1-x}(b,a)
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction 不完全贝塔函数的连分式（Lentz算法）
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1.0, 1-qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+// regularizedLowerGamma 正则化下不完全伽马函数 P(a, x)
+// x < a+1 时用级数展开，否则用连分式计算 Q(a, x) 后取补
+func regularizedLowerGamma(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lga, _ := math.Lgamma(a)
+
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < 500; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lga)
+	}
+
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 500; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lga)*h
+}
@@ -3,11 +3,16 @@ package expert
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/agent/persona"
+	"ai-agent-assistant/internal/llm"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	"ai-agent-assistant/internal/task"
 	aitools "ai-agent-assistant/internal/tools"
+	"ai-agent-assistant/pkg/models"
 )
 
 // BaseAgent 专家Agent基类
@@ -21,6 +26,10 @@ type BaseAgent struct {
 	Status       string
 	StartTime    time.Time
 	ToolIntegration *aitools.AgentToolIntegration // 工具集成
+	Model           llm.Model                     // 生成内容使用的模型，为nil时使用离线实现
+	Persona         *persona.Persona              // 人设，声明系统提示词、默认模型和允许的工具
+	memory          *agentMemory                  // 历史任务记忆，为nil时不使用记忆
+	limits          ResourceLimits                // 单个任务的资源限制
 }
 
 // NewBaseAgent 创建基础Agent
@@ -155,6 +164,28 @@ func (a *BaseAgent) GetDescription() string {
 	return a.Description
 }
 
+// ApplyPersona 应用人设：覆盖名称、描述和能力，记录系统提示词、默认模型和工具白名单
+func (a *BaseAgent) ApplyPersona(p *persona.Persona) {
+	if p.Name != "" {
+		a.Name = p.Name
+	}
+	if p.Description != "" {
+		a.Description = p.Description
+	}
+	if len(p.Capabilities) > 0 {
+		a.Capabilities = append([]string(nil), p.Capabilities...)
+	}
+	a.Persona = p
+}
+
+// PreferredModel 人设声明的默认模型，未声明时返回空字符串
+func (a *BaseAgent) PreferredModel() string {
+	if a.Persona == nil {
+		return ""
+	}
+	return a.Persona.Model
+}
+
 // SetToolIntegration 设置工具集成
 func (a *BaseAgent) SetToolIntegration(toolIntegration *aitools.AgentToolIntegration) {
 	a.ToolIntegration = toolIntegration
@@ -167,7 +198,7 @@ func (a *BaseAgent) GetToolIntegration() *aitools.AgentToolIntegration {
 
 // HasTool 检查是否有指定工具
 func (a *BaseAgent) HasTool(toolName string) bool {
-	if a.ToolIntegration == nil {
+	if a.ToolIntegration == nil || !a.Persona.AllowsTool(toolName) {
 		return false
 	}
 	return a.ToolIntegration.HasTool(toolName)
@@ -186,8 +217,68 @@ func (a *BaseAgent) CallTool(ctx context.Context, toolName, operation string, pa
 	if a.ToolIntegration == nil {
 		return nil, fmt.Errorf("工具集成未初始化")
 	}
+	if !a.Persona.AllowsTool(toolName) {
+		return nil, fmt.Errorf("agent %s is not allowed to use tool %s", a.Type, toolName)
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
+	}
+	if err := budgetFromContext(ctx).allowToolCalls(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	events.Emit(ctx, events.Event{Type: events.TypeToolCall, Agent: a.Type, Tool: toolName, Content: operation, Data: params})
+	start := time.Now()
+	result, err := a.ToolIntegration.CallTool(ctx, toolName, operation, params)
+
+	done := events.Event{Type: events.TypeToolResult, Agent: a.Type, Tool: toolName, Content: operation,
+		Data: map[string]interface{}{"duration_ms": time.Since(start).Milliseconds()}}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	events.Emit(ctx, done)
+
+	return result, err
+}
+
+// SetModel 设置生成内容使用的模型
+func (a *BaseAgent) SetModel(model llm.Model) {
+	a.Model = model
+}
+
+// GetModel 获取生成内容使用的模型
+func (a *BaseAgent) GetModel() llm.Model {
+	return a.Model
+}
+
+// Generate 使用模型生成内容，未设置模型时返回错误
+func (a *BaseAgent) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	if a.Model == nil {
+		return "", fmt.Errorf("agent %s has no model", a.Type)
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return "", err
+	}
+	if err := budgetFromContext(ctx).allowGenerate(ctx); err != nil {
+		return "", err
+	}
 
-	return a.ToolIntegration.CallTool(ctx, toolName, operation, params)
+	// 人设的系统提示词置于任务提示词之前
+	if a.Persona != nil && a.Persona.SystemPrompt != "" {
+		systemPrompt = strings.TrimSpace(strings.TrimSpace(a.Persona.SystemPrompt) + "\n\n" + systemPrompt)
+	}
+	// 本次任务检索到的历史任务记录置于最后
+	if recalled := recalledMemoryPrompt(ctx); recalled != "" {
+		systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + recalled)
+	}
+
+	messages := make([]models.Message, 0, 2)
+	if systemPrompt != "" {
+		messages = append(messages, models.Message{Role: "system", Content: systemPrompt})
+	}
+	messages = append(messages, models.Message{Role: "user", Content: prompt})
+
+	return a.Model.Chat(llm.WithCacheRoute(ctx, "agent:"+a.Type), messages)
 }
 
 // GetAvailableTools 获取可用工具列表
@@ -196,7 +287,17 @@ func (a *BaseAgent) GetAvailableTools() []map[string]interface{} {
 		return []map[string]interface{}{}
 	}
 
-	return a.ToolIntegration.GetAvailableTools()
+	tools := a.ToolIntegration.GetAvailableTools()
+	if a.Persona == nil || len(a.Persona.Tools) == 0 {
+		return tools
+	}
+	allowed := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		if name, _ := tool["name"].(string); a.Persona.AllowsTool(name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
 }
 
 // GetToolCapabilities 获取工具能力
@@ -214,5 +315,17 @@ func (a *BaseAgent) BatchCallTools(ctx context.Context, calls []aitools.ToolCall
 		return nil, fmt.Errorf("工具集成未初始化")
 	}
 
+	for _, call := range calls {
+		if !a.Persona.AllowsTool(call.ToolName) {
+			return nil, fmt.Errorf("agent %s is not allowed to use tool %s", a.Type, call.ToolName)
+		}
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
+	}
+	if err := budgetFromContext(ctx).allowToolCalls(ctx, len(calls)); err != nil {
+		return nil, err
+	}
+
 	return a.ToolIntegration.BatchCallTools(ctx, calls)
 }
@@ -0,0 +1,360 @@
+package expert
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/task"
+	aitools "ai-agent-assistant/internal/tools"
+)
+
+// 代码Agent读取仓库上下文的限制
+const (
+	defaultCoderMaxFiles  = 8
+	maxCoderFileBytes     = 12000 // 每个文件放入提示词的最大长度
+	maxCoderSearchSymbols = 3     // 按目标中的标识符搜索文件时最多搜索的标识符数
+)
+
+// identifierPattern 目标中的代码标识符（函数名、类型名、文件名等）
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_./-]{2,}`)
+
+// CoderAgent 代码专家Agent
+// 通过git工具读取仓库上下文，由模型生成unified diff补丁，校验补丁后在沙箱中运行测试；
+// 不直接修改仓库，补丁由调用方审阅后应用
+type CoderAgent struct {
+	*BaseAgent
+}
+
+// NewCoderAgent 创建代码Agent
+func NewCoderAgent() *CoderAgent {
+	base := NewBaseAgent(
+		"coder-001",
+		"Coder",
+		"coder",
+		"代码专家，读取仓库代码、提出修改补丁并在沙箱中运行测试",
+		[]string{
+			"code_reading",
+			"code_generation",
+			"diff_proposal",
+			"test_execution",
+			"code_review",
+		},
+	)
+
+	return &CoderAgent{BaseAgent: base}
+}
+
+// Execute 执行代码任务
+// 任务要求：
+//   - repo: 仓库路径（必填，需在tools.repo.roots配置的目录内）
+//   - files: 需要阅读的文件（可选，默认按目标中的标识符查找相关文件）
+//   - test_command: 验证补丁的测试命令，如 "go test ./..."（可选）
+//   - max_files: 最多阅读的文件数（可选，默认8）
+func (c *CoderAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.TaskResult, error) {
+	startTime := time.Now()
+	c.UpdateStatus("running")
+
+	if err := c.ValidateTask(taskObj); err != nil {
+		return c.createErrorResult(taskObj, err, startTime), err
+	}
+
+	// 之前在同一仓库完成的修改作为生成补丁的参考
+	ctx, recalled := c.RecallMemories(ctx, taskObj)
+
+	output, err := c.solve(ctx, taskObj.Goal, taskObj.Requirements)
+	if err != nil {
+		c.UpdateStatus("failed")
+		return c.createErrorResult(taskObj, err, startTime), err
+	}
+	c.RememberOutcome(ctx, taskObj, c.memorySummary(taskObj.Goal, output))
+
+	c.UpdateStatus("idle")
+	return &task.TaskResult{
+		TaskID:   taskObj.ID,
+		TaskGoal: taskObj.Goal,
+		Type:     taskObj.Type,
+		Status:   task.TaskStatusCompleted,
+		Output:   output,
+		Duration: time.Since(startTime),
+		Metadata: map[string]interface{}{
+			"agent_type":        "coder",
+			"generated_by":      output["generated_by"],
+			"files_read":        len(output["files"].([]string)),
+			"memories_recalled": len(recalled),
+		},
+		Timestamp: time.Now(),
+		AgentUsed: c.Name,
+	}, nil
+}
+
+// solve 读取仓库上下文、生成补丁并运行测试
+func (c *CoderAgent) solve(ctx context.Context, goal string, requirements interface{}) (map[string]interface{}, error) {
+	reqMap, _ := requirements.(map[string]interface{})
+	repo, _ := reqMap["repo"].(string)
+	if repo == "" {
+		return nil, fmt.Errorf("repo is required for coding tasks")
+	}
+	if !c.HasTool("git") {
+		return nil, fmt.Errorf("git tool is not available, configure tools.repo.roots")
+	}
+
+	// 读取仓库上下文
+	files, err := c.selectFiles(ctx, repo, goal, reqMap)
+	if err != nil {
+		return nil, err
+	}
+	events.Thought(ctx, c.Type, fmt.Sprintf("阅读%d个文件：%s", len(files), strings.Join(files, ", ")))
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		result, err := c.callRepoTool(ctx, "git", "show", map[string]interface{}{"repo": repo, "path": file})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		content, _ := result.Data.(string)
+		if len(content) > maxCoderFileBytes {
+			content = content[:maxCoderFileBytes] + "\n...（文件过长，已截断）"
+		}
+		contents[file] = content
+	}
+	commits := ""
+	if result, err := c.callRepoTool(ctx, "git", "log", map[string]interface{}{"repo": repo, "limit": 5}); err == nil {
+		commits, _ = result.Data.(string)
+	}
+
+	output := map[string]interface{}{
+		"repo":           repo,
+		"files":          files,
+		"recent_commits": commits,
+		"generated_by":   "none",
+	}
+
+	// 生成并校验补丁
+	var patch string
+	if c.Model == nil {
+		output["note"] = "no model configured, returning repository context only"
+	} else {
+		explanation, diff, err := c.proposeDiff(ctx, goal, files, contents, commits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to propose diff: %w", err)
+		}
+		output["generated_by"] = "llm"
+		output["explanation"] = explanation
+		output["diff"] = diff
+		if diff != "" {
+			check, err := c.callRepoTool(ctx, "git", "apply_check", map[string]interface{}{"repo": repo, "patch": diff})
+			output["diff_valid"] = err == nil
+			if err != nil {
+				events.Thought(ctx, c.Type, "补丁无法应用："+err.Error())
+				output["diff_error"] = err.Error()
+			} else {
+				output["diff_stat"] = check.Data
+				patch = diff
+			}
+		}
+	}
+
+	// 在沙箱中运行测试，补丁无法应用时不运行
+	if command := reqMap["test_command"]; command != nil && output["diff_error"] == nil {
+		if !c.HasTool("sandbox_exec") {
+			output["test_result"] = map[string]interface{}{"error": "sandbox_exec tool is not available"}
+		} else {
+			events.Thought(ctx, c.Type, fmt.Sprintf("在沙箱中运行测试：%v", command))
+			result, err := c.CallTool(ctx, "sandbox_exec", "run", map[string]interface{}{"repo": repo, "command": command, "patch": patch})
+			output["test_result"] = sandboxSummary(result, err)
+		}
+	}
+	return output, nil
+}
+
+// memorySummary 本次修改涉及的文件、补丁和测试结果，记入Agent记忆
+func (c *CoderAgent) memorySummary(goal string, output map[string]interface{}) string {
+	summary := fmt.Sprintf("代码任务「%s」：阅读了%s", goalSummary(goal), strings.Join(output["files"].([]string), "、"))
+	switch valid, ok := output["diff_valid"].(bool); {
+	case ok && valid:
+		summary += "，补丁可以应用"
+	case ok:
+		summary += "，补丁无法应用"
+	}
+	if result, ok := output["test_result"].(map[string]interface{}); ok {
+		if passed, _ := result["passed"].(bool); passed {
+			summary += "，测试通过"
+		} else {
+			summary += "，测试未通过"
+		}
+	}
+	return summary
+}
+
+// selectFiles 选择要阅读的文件：优先使用任务指定的文件，否则按目标中的标识符匹配路径和文件内容
+func (c *CoderAgent) selectFiles(ctx context.Context, repo, goal string, reqMap map[string]interface{}) ([]string, error) {
+	maxFiles := defaultCoderMaxFiles
+	if n, ok := reqMap["max_files"].(float64); ok && n > 0 {
+		maxFiles = int(n)
+	}
+
+	var requested []string
+	switch files := reqMap["files"].(type) {
+	case []string:
+		requested = files
+	case []interface{}:
+		for _, f := range files {
+			if s, ok := f.(string); ok {
+				requested = append(requested, s)
+			}
+		}
+	}
+	if len(requested) > 0 {
+		if len(requested) > maxFiles {
+			requested = requested[:maxFiles]
+		}
+		return requested, nil
+	}
+
+	result, err := c.callRepoTool(ctx, "git", "ls_files", map[string]interface{}{"repo": repo})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository files: %w", err)
+	}
+	tracked, _ := result.Data.([]string)
+
+	// 路径命中标识符记2分，内容命中记1分
+	symbols := identifierPattern.FindAllString(goal, -1)
+	scores := make(map[string]int)
+	for _, file := range tracked {
+		lower := strings.ToLower(file)
+		for _, symbol := range symbols {
+			if strings.Contains(lower, strings.ToLower(symbol)) {
+				scores[file] += 2
+			}
+		}
+	}
+	for i, symbol := range symbols {
+		if i >= maxCoderSearchSymbols {
+			break
+		}
+		result, err := c.callRepoTool(ctx, "git", "grep", map[string]interface{}{"repo": repo, "pattern": regexp.QuoteMeta(symbol)})
+		if err != nil {
+			continue
+		}
+		text, _ := result.Data.(string)
+		matched := make(map[string]bool)
+		for _, line := range strings.Split(text, "\n") {
+			if idx := strings.Index(line, ":"); idx > 0 && !matched[line[:idx]] {
+				matched[line[:idx]] = true
+				scores[line[:idx]]++
+			}
+		}
+	}
+
+	files := make([]string, 0, len(scores))
+	for file := range scores {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if scores[files[i]] != scores[files[j]] {
+			return scores[files[i]] > scores[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	if len(files) > maxFiles {
+		files = files[:maxFiles]
+	}
+	return files, nil
+}
+
+// proposeDiff 由模型根据仓库上下文生成补丁，返回说明和unified diff
+func (c *CoderAgent) proposeDiff(ctx context.Context, goal string, files []string, contents map[string]string, commits string) (string, string, error) {
+	var sb strings.Builder
+	sb.WriteString("任务：" + goal + "\n\n")
+	if commits != "" {
+		sb.WriteString("最近的提交：\n" + commits + "\n\n")
+	}
+	for _, file := range files {
+		sb.WriteString(fmt.Sprintf("文件 %s：\n```\n%s\n```\n\n", file, contents[file]))
+	}
+	sb.WriteString("先用一两句话说明修改思路，再在```diff代码块中给出可以用 git apply 应用的unified diff（路径带a/和b/前缀）。不需要修改代码时不输出diff。")
+
+	raw, err := c.Generate(ctx, "你是资深软件工程师，只做完成任务所需的最小修改，遵循仓库已有的代码风格。", sb.String())
+	if err != nil {
+		return "", "", err
+	}
+	explanation, diff := splitDiff(raw)
+	return explanation, diff, nil
+}
+
+// splitDiff 从模型输出中分离说明文字和diff
+func splitDiff(raw string) (string, string) {
+	if start := strings.Index(raw, "```diff"); start >= 0 {
+		body := raw[start+len("```diff"):]
+		end := strings.Index(body, "```")
+		if end < 0 {
+			end = len(body)
+		}
+		diff := strings.Trim(body[:end], "\n") + "\n"
+		return strings.TrimSpace(raw[:start]), diff
+	}
+	for _, marker := range []string{"diff --git ", "--- a/"} {
+		if start := strings.Index(raw, marker); start >= 0 {
+			return strings.TrimSpace(raw[:start]), strings.TrimRight(raw[start:], "\n") + "\n"
+		}
+	}
+	return strings.TrimSpace(raw), ""
+}
+
+// callRepoTool 调用仓库工具，工具返回失败结果时转为错误
+func (c *CoderAgent) callRepoTool(ctx context.Context, tool, operation string, params map[string]interface{}) (*aitools.ToolResult, error) {
+	raw, err := c.CallTool(ctx, tool, operation, params)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := raw.(*aitools.ToolResult)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result from %s tool", tool)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+	return result, nil
+}
+
+// sandboxSummary 沙箱执行结果摘要
+func sandboxSummary(raw interface{}, err error) map[string]interface{} {
+	if err != nil {
+		return map[string]interface{}{"passed": false, "error": err.Error()}
+	}
+	result, ok := raw.(*aitools.ToolResult)
+	if !ok {
+		return map[string]interface{}{"passed": false, "error": "unexpected sandbox result"}
+	}
+	summary := map[string]interface{}{"passed": result.Success}
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		for k, v := range data {
+			summary[k] = v
+		}
+	}
+	if !result.Success {
+		summary["error"] = result.Error
+	}
+	return summary
+}
+
+// createErrorResult 创建错误结果
+func (c *CoderAgent) createErrorResult(taskObj *task.Task, err error, startTime time.Time) *task.TaskResult {
+	return &task.TaskResult{
+		TaskID:   taskObj.ID,
+		TaskGoal: taskObj.Goal,
+		Type:     taskObj.Type,
+		Status:   task.TaskStatusFailed,
+		Error:    err.Error(),
+		Duration: time.Since(startTime),
+		Metadata: map[string]interface{}{
+			"agent_type": "coder",
+		},
+		Timestamp: time.Now(),
+		AgentUsed: c.Name,
+	}
+}
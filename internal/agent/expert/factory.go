@@ -2,8 +2,12 @@ package expert
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"ai-agent-assistant/internal/agent/persona"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/llm"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	"ai-agent-assistant/internal/task"
 	aitools "ai-agent-assistant/internal/tools"
@@ -14,6 +18,8 @@ type Factory struct {
 	researcher *ResearcherAgent
 	analyst    *AnalystAgent
 	writer     *WriterAgent
+	planner    *PlannerAgent
+	coder      *CoderAgent
 	toolManager *aitools.ToolManager // 工具管理器
 }
 
@@ -23,6 +29,8 @@ func NewFactory() *Factory {
 		researcher: NewResearcherAgent(),
 		analyst:    NewAnalystAgent(),
 		writer:     NewWriterAgent(),
+		planner:    NewPlannerAgent(),
+		coder:      NewCoderAgent(),
 		toolManager: nil, // 延迟初始化
 	}
 }
@@ -32,13 +40,86 @@ func (f *Factory) SetToolManager(toolManager *aitools.ToolManager) {
 	f.toolManager = toolManager
 
 	// 为所有 Agent 设置工具集成
-	agents := []ExpertAgent{f.researcher, f.analyst, f.writer}
-	for _, agent := range agents {
-		if baseAgent, ok := agent.(*BaseAgent); ok {
-			toolIntegration := aitools.NewAgentToolIntegration(baseAgent.ID, toolManager)
-			baseAgent.SetToolIntegration(toolIntegration)
+	for _, baseAgent := range f.baseAgents() {
+		toolIntegration := aitools.NewAgentToolIntegration(baseAgent.ID, toolManager)
+		baseAgent.SetToolIntegration(toolIntegration)
+	}
+}
+
+// SetModel 设置各Agent生成内容使用的模型，未设置时Agent使用离线的模板实现
+func (f *Factory) SetModel(model llm.Model) {
+	for _, agent := range f.baseAgents() {
+		agent.SetModel(model)
+	}
+}
+
+// baseAgents 所有Agent的基础Agent
+func (f *Factory) baseAgents() []*BaseAgent {
+	return []*BaseAgent{f.researcher.BaseAgent, f.analyst.BaseAgent, f.writer.BaseAgent, f.planner.BaseAgent, f.coder.BaseAgent}
+}
+
+// LoadPersonas 从目录加载Agent人设并应用到对应的Agent，需在RegisterAllAgents之前调用
+func (f *Factory) LoadPersonas(dir string) error {
+	personas, err := persona.Load(dir)
+	if err != nil {
+		return err
+	}
+	agents := make(map[string]*BaseAgent)
+	for _, agent := range f.baseAgents() {
+		agents[agent.Type] = agent
+	}
+	for agentType := range personas {
+		if agents[agentType] == nil {
+			return fmt.Errorf("persona for unknown agent type: %s", agentType)
+		}
+	}
+	for agentType, p := range personas {
+		agents[agentType].ApplyPersona(p)
+	}
+	return nil
+}
+
+// Persona 指定类型Agent的人设，未加载人设文件时返回由内置名称、描述和能力组成的人设；Agent类型不存在时返回false
+func (f *Factory) Persona(agentType string) (*persona.Persona, bool) {
+	for _, agent := range f.baseAgents() {
+		if agent.Type != agentType {
+			continue
+		}
+		if agent.Persona != nil {
+			return agent.Persona, true
+		}
+		return &persona.Persona{
+			Agent:        agent.Type,
+			Name:         agent.Name,
+			Description:  agent.Description,
+			Capabilities: append([]string(nil), agent.Capabilities...),
+		}, true
+	}
+	return nil, false
+}
+
+// SetPersonaModels 为人设声明了默认模型的Agent设置模型，需在SetModel之后调用
+// 无法获取的模型合并为一个错误返回，对应的Agent继续使用默认模型
+func (f *Factory) SetPersonaModels(lookup func(name string) (llm.Model, error)) error {
+	var errs []error
+	for _, agent := range f.baseAgents() {
+		name := agent.PreferredModel()
+		if name == "" {
+			continue
 		}
+		model, err := lookup(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %s model %s: %w", agent.Type, name, err))
+			continue
+		}
+		agent.SetModel(model)
 	}
+	return errors.Join(errs...)
+}
+
+// SetArtifactStore 设置产物存储，分析Agent渲染的图表保存到其中
+func (f *Factory) SetArtifactStore(store artifact.Store) {
+	f.analyst.SetArtifactStore(store)
 }
 
 // GetToolManager 获取工具管理器
@@ -55,6 +136,10 @@ func (f *Factory) CreateAgent(agentType string) (ExpertAgent, error) {
 		return f.analyst, nil
 	case "writer":
 		return f.writer, nil
+	case "planner":
+		return f.planner, nil
+	case "coder":
+		return f.coder, nil
 	default:
 		return nil, fmt.Errorf("unknown agent type: %s", agentType)
 	}
@@ -66,16 +151,24 @@ func (f *Factory) GetAllAgents() map[string]ExpertAgent {
 		"researcher": f.researcher,
 		"analyst":    f.analyst,
 		"writer":     f.writer,
+		"planner":    f.planner,
+		"coder":      f.coder,
 	}
 }
 
-// RegisterAllAgents 注册所有Agent到注册表
+// RegisterAllAgents 注册所有Agent到注册表，规划Agent按该注册表中的Agent规划工作流
 func (f *Factory) RegisterAllAgents(registry *aiagentorchestrator.AgentRegistry) error {
+	f.planner.SetRegistry(registry)
 	agents := f.GetAllAgents()
 
 	for _, agent := range agents {
 		info := agent.GetInfo()
-		if err := registry.Register(info); err != nil {
+		err := registry.Register(info)
+		if errors.Is(err, aiagentorchestrator.ErrAgentExists) {
+			// 使用持久化注册表时，重启前或其他编排器实例已注册过同名Agent，只刷新心跳
+			err = registry.UpdateHeartbeat(info.Name)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to register agent: %w", err)
 		}
 	}
@@ -83,6 +176,33 @@ func (f *Factory) RegisterAllAgents(registry *aiagentorchestrator.AgentRegistry)
 	return nil
 }
 
+// TaskExecutor 在进程内执行调度器分配给专家Agent的任务，按注册名称查找Agent
+// 与远程Agent一起使用时作为 RemoteAgents.Executor 的local执行器
+func (f *Factory) TaskExecutor() aiagentorchestrator.TaskExecutor {
+	return func(ctx context.Context, scheduled *aiagentorchestrator.Task, info *aiagentorchestrator.AgentInfo) (interface{}, error) {
+		for _, agent := range f.GetAllAgents() {
+			if agent.GetInfo().Name != info.Name {
+				continue
+			}
+			result, err := agent.Execute(ctx, &task.Task{
+				ID:           scheduled.ID,
+				Type:         scheduled.Type,
+				Goal:         scheduled.Goal,
+				Requirements: scheduled.Requirements,
+				Priority:     task.TaskPriority(scheduled.Priority),
+				Status:       task.TaskStatusRunning,
+				AssignedTo:   info.Name,
+				CreatedAt:    scheduled.CreatedAt,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("no local agent named %s", info.Name)
+	}
+}
+
 // ExpertAgent 专家Agent接口
 type ExpertAgent interface {
 	Execute(ctx context.Context, taskObj *task.Task) (*task.TaskResult, error)
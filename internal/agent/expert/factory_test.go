@@ -2,11 +2,21 @@ package expert
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/pkg/models"
 )
 
 func TestFactory(t *testing.T) {
@@ -72,7 +82,7 @@ func TestRegisterAllAgents(t *testing.T) {
 	}
 
 	// 验证所有Agent都已注册
-	agents := []string{"researcher", "analyst", "writer"}
+	agents := []string{"Researcher", "Analyst", "Writer"}
 	for _, name := range agents {
 		_, err := registry.Get(name)
 		if err != nil {
@@ -107,7 +117,7 @@ func TestResearcherAgent(t *testing.T) {
 	})
 
 	t.Run("Execute Search Task", func(t *testing.T) {
-		task := &task.Task{
+		taskObj := &task.Task{
 			ID:       "task-1",
 			Type:     "researcher",
 			Goal:     "搜索关于AI的最新信息",
@@ -118,15 +128,15 @@ func TestResearcherAgent(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		result, err := researcher.Execute(ctx, task)
+		result, err := researcher.Execute(ctx, taskObj)
 		if err != nil {
 			t.Logf("Search task failed (expected with mock data): %v", err)
 		}
 		if result == nil {
 			t.Fatal("Result is nil")
 		}
-		if result.TaskID != task.ID {
-			t.Errorf("Expected task ID '%s', got '%s'", task.ID, result.TaskID)
+		if result.TaskID != taskObj.ID {
+			t.Errorf("Expected task ID '%s', got '%s'", taskObj.ID, result.TaskID)
 		}
 	})
 }
@@ -158,7 +168,7 @@ func TestAnalystAgent(t *testing.T) {
 			"data": []interface{}{10.0, 20.0, 30.0, 40.0, 50.0},
 		}
 
-		task := &task.Task{
+		taskObj := &task.Task{
 			ID:           "task-2",
 			Type:         "analyst",
 			Goal:         "分析数据的统计特征",
@@ -168,7 +178,7 @@ func TestAnalystAgent(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		result, err := analyst.Execute(ctx, task)
+		result, err := analyst.Execute(ctx, taskObj)
 		if err != nil {
 			t.Fatalf("Analysis task failed: %v", err)
 		}
@@ -217,7 +227,7 @@ func TestWriterAgent(t *testing.T) {
 			"keywords": []string{"AI", "技术"},
 		}
 
-		task := &task.Task{
+		taskObj := &task.Task{
 			ID:           "task-3",
 			Type:         "writer",
 			Goal:         "撰写一篇关于AI技术的文章",
@@ -227,7 +237,7 @@ func TestWriterAgent(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		result, err := writer.Execute(ctx, task)
+		result, err := writer.Execute(ctx, taskObj)
 		if err != nil {
 			t.Fatalf("Writing task failed: %v", err)
 		}
@@ -252,7 +262,7 @@ func TestWriterAgent(t *testing.T) {
 			"title":   "人工智能发展史",
 		}
 
-		task := &task.Task{
+		taskObj := &task.Task{
 			ID:           "task-4",
 			Type:         "writer",
 			Goal:         "为这段内容生成摘要",
@@ -262,7 +272,7 @@ func TestWriterAgent(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		result, err := writer.Execute(ctx, task)
+		result, err := writer.Execute(ctx, taskObj)
 		if err != nil {
 			t.Fatalf("Summary task failed: %v", err)
 		}
@@ -294,9 +304,719 @@ func TestAgentCollaboration(t *testing.T) {
 	})
 
 	t.Run("Get All Agents", func(t *testing.T) {
-		agents := registry.GetAll()
-		if len(agents) != 3 {
-			t.Errorf("Expected 3 agents, got %d", len(agents))
+		agents := registry.List()
+		if len(agents) != 5 {
+			t.Errorf("Expected 5 agents, got %d", len(agents))
 		}
 	})
 }
+
+// TestAnalystStatistics 快速选择和单次遍历统计与排序后的参考结果一致
+func TestAnalystStatistics(t *testing.T) {
+	analyst := NewAnalystAgent()
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 10, 101, 1000} {
+		data := make([]float64, n)
+		for i := range data {
+			data[i] = float64(rng.Intn(20)) // 含大量重复值
+		}
+		sorted := sortedCopy(data)
+		for _, p := range []float64{0, 10, 25, 50, 75, 90, 100} {
+			if got, want := analyst.percentile(data, p), percentileSorted(sorted, p); math.Abs(got-want) > 1e-9 {
+				t.Errorf("n=%d p=%v: percentile = %v, want %v", n, p, got, want)
+			}
+		}
+
+		var sum float64
+		for _, v := range data {
+			sum += v
+		}
+		mean := sum / float64(n)
+		var ss float64
+		for _, v := range data {
+			ss += (v - mean) * (v - mean)
+		}
+		desc := describe(data)
+		if math.Abs(desc.mean-mean) > 1e-9 || math.Abs(desc.variance()-ss/float64(n)) > 1e-9 {
+			t.Errorf("n=%d: describe = %+v, want mean %v variance %v", n, desc, mean, ss/float64(n))
+		}
+		if desc.min != sorted[0] || desc.max != sorted[n-1] {
+			t.Errorf("n=%d: min/max = %v/%v, want %v/%v", n, desc.min, desc.max, sorted[0], sorted[n-1])
+		}
+	}
+
+	stats := analyst.calculateStatistics([]float64{4, 1, 3, 2, 3})
+	if stats["median"] != 3.0 || stats["mode"] != 3.0 || stats["q1"] != 2.0 || stats["q3"] != 3.0 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+	stats = analyst.calculateStatistics([]float64{4, 1, 3, 2})
+	if stats["median"] != 2.5 || stats["q1"] != 1.75 || stats["q3"] != 3.25 || stats["variance"] != 1.25 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+}
+
+// R datasets::sleep 中两组学生的睡眠增加时长
+var (
+	sleepGroup1 = []float64{0.7, -1.6, -0.2, -1.2, -0.1, 3.4, 3.7, 0.8, 0.0, 2.0}
+	sleepGroup2 = []float64{1.9, 0.8, 1.1, 0.1, -0.1, 4.4, 5.5, 1.6, 4.6, 3.4}
+)
+
+// closeTo 按相对误差比较，参考值取自R输出的有效数字
+func closeTo(got, want, rel float64) bool {
+	return math.Abs(got-want) <= rel*math.Abs(want)
+}
+
+// TestAnalystHypothesisTests 检验统计量、自由度和p值与R的参考结果一致
+func TestAnalystHypothesisTests(t *testing.T) {
+	analyst := NewAnalystAgent()
+	floats := func(data []float64) []interface{} {
+		values := make([]interface{}, len(data))
+		for i, v := range data {
+			values[i] = v
+		}
+		return values
+	}
+
+	tests := []struct {
+		name         string
+		requirements map[string]interface{}
+		test         string
+		statistic    float64
+		df           float64
+		pValue       float64
+	}{
+		{
+			// t.test(extra ~ group, data = sleep)
+			name:         "welch",
+			requirements: map[string]interface{}{"samples": map[string]interface{}{"a": floats(sleepGroup1), "b": floats(sleepGroup2)}},
+			test:         "welch_t_test", statistic: -1.8608, df: 17.776, pValue: 0.07939,
+		},
+		{
+			// t.test(sleep$extra[1:10], sleep$extra[11:20], paired = TRUE)
+			name:         "paired",
+			requirements: map[string]interface{}{"samples": []interface{}{floats(sleepGroup1), floats(sleepGroup2)}, "paired": true},
+			test:         "paired_t_test", statistic: -4.0621, df: 9, pValue: 0.002833,
+		},
+		{
+			// t.test(sleep$extra[1:10], mu = 0)
+			name:         "one sample",
+			requirements: map[string]interface{}{"datasets": []interface{}{floats(sleepGroup1)}},
+			test:         "one_sample_t_test", statistic: 1.3257, df: 9, pValue: 0.2176,
+		},
+		{
+			// chisq.test(rbind(c(762, 327, 468), c(484, 239, 477)))
+			name: "chi-square",
+			requirements: map[string]interface{}{"contingency_table": []interface{}{
+				[]interface{}{762.0, 327.0, 468.0},
+				[]interface{}{484.0, 239.0, 477.0},
+			}},
+			test: "chi_square_independence", statistic: 30.07, df: 2, pValue: 2.954e-07,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := analyst.performHypothesisTest(context.Background(), tt.requirements)
+			if err != nil {
+				t.Fatalf("performHypothesisTest failed: %v", err)
+			}
+			result := output.(map[string]interface{})
+			if result["test"] != tt.test {
+				t.Errorf("test = %v, want %s", result["test"], tt.test)
+			}
+			if got := result["statistic"].(float64); !closeTo(got, tt.statistic, 1e-3) {
+				t.Errorf("statistic = %v, want %v", got, tt.statistic)
+			}
+			if got := result["df"].(float64); !closeTo(got, tt.df, 1e-3) {
+				t.Errorf("df = %v, want %v", got, tt.df)
+			}
+			if got := result["p_value"].(float64); !closeTo(got, tt.pValue, 1e-3) {
+				t.Errorf("p_value = %v, want %v", got, tt.pValue)
+			}
+		})
+	}
+}
+
+// TestAnalystDistributions 分布函数在已知分位点上的p值（R: qt(0.975, df)、qchisq(0.95, df)）
+func TestAnalystDistributions(t *testing.T) {
+	tests := []struct {
+		name   string
+		pValue float64
+		want   float64
+	}{
+		{"t df=10 at qt(0.975)", studentTPValue(2.228139, 10), 0.05},
+		{"t df=1 at qt(0.975)", studentTPValue(12.7062, 1), 0.05},
+		{"t df=30 at qt(0.995)", studentTPValue(-2.749996, 30), 0.01},
+		{"t at 0", studentTPValue(0, 5), 1},
+		{"chi-square df=1 at qchisq(0.95)", chiSquarePValue(3.841459, 1), 0.05},
+		{"chi-square df=4 at qchisq(0.99)", chiSquarePValue(13.2767, 4), 0.01},
+		{"chi-square df=10 at qchisq(0.5)", chiSquarePValue(9.341818, 10), 0.5},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.pValue, tt.want, 1e-4) {
+			t.Errorf("%s: p = %v, want %v", tt.name, tt.pValue, tt.want)
+		}
+	}
+}
+
+// TestAnalystCorrelation 相关系数和p值与R的参考结果一致
+func TestAnalystCorrelation(t *testing.T) {
+	// ?cor.test 中的示例数据
+	x := []interface{}{44.4, 45.9, 41.9, 53.3, 44.7, 44.1, 50.7, 45.2, 60.1}
+	y := []interface{}{2.6, 3.1, 2.5, 5.0, 3.6, 4.0, 5.2, 2.8, 3.8}
+	output, err := NewAnalystAgent().performCorrelationAnalysis(context.Background(), map[string]interface{}{
+		"variables": map[string]interface{}{"x": x, "y": y},
+	})
+	if err != nil {
+		t.Fatalf("performCorrelationAnalysis failed: %v", err)
+	}
+	pair := output.(map[string]interface{})["pairs"].([]map[string]interface{})[0]
+
+	// cor.test(x, y): t = 1.8411, df = 7, p-value = 0.1082, cor = 0.5711816
+	if r := pair["pearson"].(float64); !closeTo(r, 0.5711816, 1e-6) {
+		t.Errorf("pearson = %v, want 0.5711816", r)
+	}
+	if p := pair["p_value"].(float64); !closeTo(p, 0.1082, 1e-3) {
+		t.Errorf("p_value = %v, want 0.1082", p)
+	}
+	// cor(x, y, method = "spearman") = 0.6
+	if rho := pair["spearman"].(float64); !closeTo(rho, 0.6, 1e-9) {
+		t.Errorf("spearman = %v, want 0.6", rho)
+	}
+
+	// rank(c(10, 20, 10, 30, 20, 10)) 并列值取平均秩
+	if got, want := ranks([]float64{10, 20, 10, 30, 20, 10}), []float64{2, 4.5, 2, 6, 4.5, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ranks = %v, want %v", got, want)
+	}
+}
+
+// TestAnalystRegression 回归系数、标准误和p值与R的参考结果一致
+func TestAnalystRegression(t *testing.T) {
+	// R datasets::cars，lm(dist ~ speed, data = cars)
+	speed := []interface{}{4, 4, 7, 7, 8, 9, 10, 10, 10, 11, 11, 12, 12, 12, 12, 13, 13, 13, 13, 14, 14, 14, 14, 15, 15,
+		15, 16, 16, 17, 17, 17, 18, 18, 18, 18, 19, 19, 19, 20, 20, 20, 20, 20, 22, 23, 24, 24, 24, 24, 25}
+	dist := []interface{}{2, 10, 4, 22, 16, 10, 18, 26, 34, 17, 28, 14, 20, 24, 28, 26, 34, 34, 46, 26, 36, 60, 80, 20, 26,
+		54, 32, 40, 32, 40, 50, 42, 56, 76, 84, 36, 46, 68, 32, 48, 52, 56, 64, 66, 54, 70, 92, 93, 120, 85}
+	output, err := NewAnalystAgent().performRegressionAnalysis(context.Background(), map[string]interface{}{
+		"variables": map[string]interface{}{"speed": speed, "dist": dist},
+		"target":    "dist",
+	})
+	if err != nil {
+		t.Fatalf("performRegressionAnalysis failed: %v", err)
+	}
+	result := output.(map[string]interface{})
+
+	want := []struct {
+		name                            string
+		estimate, stdErr, tStat, pValue float64
+	}{
+		{"intercept", -17.5791, 6.7584, -2.601, 0.0123},
+		{"speed", 3.9324, 0.4155, 9.464, 1.49e-12},
+	}
+	coefficients := result["coefficients"].([]map[string]interface{})
+	for i, w := range want {
+		c := coefficients[i]
+		if c["name"] != w.name {
+			t.Errorf("coefficient %d = %v, want %s", i, c["name"], w.name)
+		}
+		for field, expected := range map[string]float64{"estimate": w.estimate, "std_error": w.stdErr, "t_stat": w.tStat, "p_value": w.pValue} {
+			if got := c[field].(float64); !closeTo(got, expected, 5e-3) {
+				t.Errorf("%s %s = %v, want %v", w.name, field, got, expected)
+			}
+		}
+	}
+	// Residual standard error: 15.38, Multiple R-squared: 0.6511, Adjusted R-squared: 0.6438
+	for field, expected := range map[string]float64{"residual_std": 15.38, "r_squared": 0.6511, "adjusted_r_squared": 0.6438} {
+		if got := result[field].(float64); !closeTo(got, expected, 1e-3) {
+			t.Errorf("%s = %v, want %v", field, got, expected)
+		}
+	}
+}
+
+// TestAnalystStatsEdgeCases 样本不足、零方差和NaN输入
+func TestAnalystStatsEdgeCases(t *testing.T) {
+	analyst := NewAnalystAgent()
+	ctx := context.Background()
+
+	errorCases := []struct {
+		name string
+		run  func() (interface{}, error)
+	}{
+		{"one sample n<2", func() (interface{}, error) { return oneSampleTTest([]float64{1}, 0) }},
+		{"welch n<2", func() (interface{}, error) { return welchTTest([]float64{1}, []float64{1, 2}) }},
+		{"paired unequal length", func() (interface{}, error) { return pairedTTest([]float64{1, 2}, []float64{1, 2, 3}) }},
+		{"one sample zero variance", func() (interface{}, error) { return oneSampleTTest([]float64{3, 3, 3}, 0) }},
+		{"welch zero variance", func() (interface{}, error) { return welchTTest([]float64{1, 1}, []float64{2, 2}) }},
+		{"paired identical", func() (interface{}, error) { return pairedTTest([]float64{1, 2, 3}, []float64{1, 2, 3}) }},
+		{"chi-square 1 row", func() (interface{}, error) { return chiSquareTest([][]float64{{1, 2}}) }},
+		{"chi-square empty", func() (interface{}, error) { return chiSquareTest([][]float64{{0, 0}, {0, 0}}) }},
+		{"chi-square negative", func() (interface{}, error) { return chiSquareTest([][]float64{{1, -1}, {2, 3}}) }},
+		{"chi-square ragged", func() (interface{}, error) { return chiSquareTest([][]float64{{1, 2}, {3}}) }},
+		{"no samples", func() (interface{}, error) { return analyst.performHypothesisTest(ctx, map[string]interface{}{}) }},
+		{"correlation n<3", func() (interface{}, error) {
+			return analyst.performCorrelationAnalysis(ctx, map[string]interface{}{
+				"variables": map[string]interface{}{"x": []interface{}{1.0, 2.0}, "y": []interface{}{2.0, 1.0}},
+			})
+		}},
+		{"correlation 1 variable", func() (interface{}, error) {
+			return analyst.performCorrelationAnalysis(ctx, map[string]interface{}{
+				"variables": map[string]interface{}{"x": []interface{}{1.0, 2.0, 3.0}},
+			})
+		}},
+		{"regression collinear", func() (interface{}, error) {
+			return analyst.performRegressionAnalysis(ctx, map[string]interface{}{
+				"variables": map[string]interface{}{
+					"x": []interface{}{1.0, 2.0, 3.0, 4.0},
+					"z": []interface{}{2.0, 4.0, 6.0, 8.0},
+					"y": []interface{}{1.0, 3.0, 2.0, 5.0},
+				},
+				"target": "y",
+			})
+		}},
+		{"regression n<=k", func() (interface{}, error) {
+			return analyst.performRegressionAnalysis(ctx, map[string]interface{}{
+				"variables": map[string]interface{}{
+					"x": []interface{}{1.0, 2.0, 3.0},
+					"z": []interface{}{3.0, 1.0, 2.0},
+					"y": []interface{}{1.0, 3.0, 2.0},
+				},
+				"target": "y",
+			})
+		}},
+	}
+	for _, tt := range errorCases {
+		if _, err := tt.run(); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	// 零方差变量的相关系数记为0，|r|=1时p值为0，样本不足时p值为1
+	if r := pearsonCorrelation([]float64{1, 1, 1}, []float64{1, 2, 3}); r != 0 {
+		t.Errorf("pearson with zero variance = %v, want 0", r)
+	}
+	if p := correlationPValue(1, 10); p != 0 {
+		t.Errorf("p-value for r=1 = %v, want 0", p)
+	}
+	if p := correlationPValue(0.5, 2); p != 1 {
+		t.Errorf("p-value for n=2 = %v, want 1", p)
+	}
+
+	// NaN不产生NaN的p值
+	if p := studentTPValue(math.NaN(), 5); p != 1 {
+		t.Errorf("p-value for NaN statistic = %v, want 1", p)
+	}
+	if p := studentTPValue(2, 0); p != 1 {
+		t.Errorf("p-value for df=0 = %v, want 1", p)
+	}
+	if p := chiSquarePValue(math.NaN(), 2); math.IsNaN(p) {
+		t.Error("p-value for NaN chi-square statistic should not be NaN")
+	}
+	result, err := welchTTest([]float64{1, 2, math.NaN()}, []float64{4, 5, 6})
+	if err != nil {
+		t.Fatalf("welchTTest failed: %v", err)
+	}
+	if p := result["p_value"].(float64); math.IsNaN(p) || p < 0 || p > 1 {
+		t.Errorf("p-value with NaN input = %v, want within [0, 1]", p)
+	}
+	if mode := modeSorted([]float64{math.NaN(), math.NaN(), 1}); mode != 1 {
+		t.Errorf("mode skipping NaN = %v, want 1", mode)
+	}
+}
+
+// TestAnalystMockDataSeed 测试设置种子和时钟后模拟数据可复现
+func TestAnalystMockDataSeed(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	newAnalyst := func(seed int64) *AnalystAgent {
+		a := NewAnalystAgent()
+		a.SetSeed(seed)
+		a.SetClock(clock.NewFake(now))
+		return a
+	}
+
+	first, second := newAnalyst(7), newAnalyst(7)
+	a, b := first.generateMockData(50), second.generateMockData(50)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed produced %v and %v at %d", a[i], b[i], i)
+		}
+		if math.IsNaN(a[i]) || math.IsInf(a[i], 0) {
+			t.Fatalf("mock data contains %v", a[i])
+		}
+	}
+	if c := newAnalyst(8).generateMockData(50); c[0] == a[0] && c[1] == a[1] {
+		t.Error("different seeds should produce different data")
+	}
+
+	series, again := first.generateMockTimeSeries(30), second.generateMockTimeSeries(30)
+	if series[0]["date"] != "2024-02-10" || series[29]["date"] != "2024-03-10" {
+		t.Errorf("unexpected dates %v .. %v", series[0]["date"], series[29]["date"])
+	}
+	for i := range series {
+		if series[i]["value"] != again[i]["value"] {
+			t.Fatalf("same seed produced different series at %d", i)
+		}
+	}
+}
+
+// 1M数据点上的分位数和描述统计：go test -bench Analyst -run ^$ ./internal/agent/expert/
+// 原先的冒泡排序在此规模上需要约10^12次比较，无法在合理时间内完成
+
+func benchmarkData(n int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]float64, n)
+	for i := range data {
+		data[i] = rng.NormFloat64()*10 + 100
+	}
+	return data
+}
+
+func BenchmarkAnalystMedian1M(b *testing.B) {
+	analyst := NewAnalystAgent()
+	data := benchmarkData(1_000_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyst.median(data)
+	}
+}
+
+// BenchmarkAnalystMedianFullSort1M 对照：每次完整排序后取中位数
+func BenchmarkAnalystMedianFullSort1M(b *testing.B) {
+	data := benchmarkData(1_000_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		percentileSorted(sortedCopy(data), 50)
+	}
+}
+
+func BenchmarkAnalystStatistics1M(b *testing.B) {
+	analyst := NewAnalystAgent()
+	data := benchmarkData(1_000_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyst.calculateStatistics(data)
+	}
+}
+
+// cancellingModel 第一次调用时取消任务的模型，记录调用次数
+type cancellingModel struct {
+	cancel context.CancelFunc
+	calls  int
+}
+
+func (m *cancellingModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	m.calls++
+	m.cancel()
+	return "", ctx.Err()
+}
+
+func (m *cancellingModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+func (m *cancellingModel) SupportsToolCalling() bool { return false }
+func (m *cancellingModel) SupportsEmbedding() bool   { return false }
+func (m *cancellingModel) Embed(ctx context.Context, text string) ([]float64, error) {
+	return nil, errors.New("not supported")
+}
+func (m *cancellingModel) GetModelName() string    { return "cancelling" }
+func (m *cancellingModel) GetProviderName() string { return "test" }
+
+// TestExecuteWithUsageCancelled 测试任务取消传播到Agent：不再调用模型，降级结果也按取消返回
+func TestExecuteWithUsageCancelled(t *testing.T) {
+	taskObj := &task.Task{ID: "task-cancel", Type: "writer", Goal: "撰写一篇关于AI技术的文章"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result, err := ExecuteWithUsage(ctx, NewWriterAgent(), taskObj); !errors.Is(err, context.Canceled) || result != nil {
+		t.Fatalf("cancelled task should not execute, got %v, %v", result, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	model := &cancellingModel{cancel: cancel}
+	writer := NewWriterAgent()
+	writer.SetModel(model)
+	if _, err := ExecuteWithUsage(ctx, writer, taskObj); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled after cancelling a running task, got %v", err)
+	}
+	if model.calls != 1 {
+		t.Errorf("model should not be called after cancellation, got %d calls", model.calls)
+	}
+	if _, err := writer.Generate(ctx, "", "prompt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate should refuse cancelled tasks, got %v", err)
+	}
+}
+
+// scriptedModel 按顺序返回预设回复的模型，记录收到的消息
+type scriptedModel struct {
+	replies  []string
+	err      error
+	messages [][]models.Message
+}
+
+func (m *scriptedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	m.messages = append(m.messages, messages)
+	if m.err != nil {
+		return "", m.err
+	}
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return reply, nil
+}
+
+func (m *scriptedModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+func (m *scriptedModel) SupportsToolCalling() bool { return false }
+func (m *scriptedModel) SupportsEmbedding() bool   { return false }
+func (m *scriptedModel) Embed(ctx context.Context, text string) ([]float64, error) {
+	return nil, errors.New("not supported")
+}
+func (m *scriptedModel) GetModelName() string    { return "scripted" }
+func (m *scriptedModel) GetProviderName() string { return "test" }
+
+// TestWriterAgentModel 测试设置模型后按约束由模型生成内容，模型失败时回退到模板
+func TestWriterAgentModel(t *testing.T) {
+	ctx := context.Background()
+	model := &scriptedModel{replies: []string{
+		"```markdown\n# AI技术\n\nAI正在改变软件开发。\n```",
+		"# 季度 报告\n\n## 执行摘要\n收入增长。\n\n## 分析\n新用户贡献最多。\n\n## 建议\n继续投入。",
+		"人工智能从专家系统发展到深度学习。",
+	}}
+	writer := NewWriterAgent()
+	writer.SetModel(model)
+
+	article, err := writer.writeArticle(ctx, "AI技术", map[string]interface{}{
+		"style":    "academic",
+		"length":   300,
+		"keywords": []interface{}{"AI", "开源"},
+	})
+	if err != nil {
+		t.Fatalf("writeArticle failed: %v", err)
+	}
+	out := article.(map[string]interface{})
+	if out["generated_by"] != "llm" || out["content"] != "# AI技术\n\nAI正在改变软件开发。" {
+		t.Errorf("expected unwrapped model output, got %v", out)
+	}
+	if missing, _ := out["missing_keywords"].([]string); !reflect.DeepEqual(missing, []string{"开源"}) {
+		t.Errorf("expected missing keyword 开源, got %v", out["missing_keywords"])
+	}
+	messages := model.messages[0]
+	if len(messages) != 2 || messages[0].Role != "system" || messages[1].Role != "user" {
+		t.Fatalf("expected system and user messages, got %+v", messages)
+	}
+	for _, want := range []string{"「AI技术」", "学术化", "约300字", "AI、开源"} {
+		if !strings.Contains(messages[1].Content, want) {
+			t.Errorf("prompt should contain %q:\n%s", want, messages[1].Content)
+		}
+	}
+
+	report, err := writer.writeReport(ctx, "季度", map[string]interface{}{"data": map[string]interface{}{"revenue": 120}})
+	if err != nil {
+		t.Fatalf("writeReport failed: %v", err)
+	}
+	out = report.(map[string]interface{})
+	if out["executive_summary"] != "收入增长。" || out["analysis"] != "新用户贡献最多。" || out["recommendations"] != "继续投入。" {
+		t.Errorf("report sections should come from the model output, got %v", out)
+	}
+	if !strings.Contains(model.messages[1][1].Content, `"revenue": 120`) {
+		t.Errorf("report prompt should contain the data:\n%s", model.messages[1][1].Content)
+	}
+
+	summary, err := writer.writeSummary(ctx, map[string]interface{}{"content": "很长的原文", "title": "AI"})
+	if err != nil {
+		t.Fatalf("writeSummary failed: %v", err)
+	}
+	if out = summary.(map[string]interface{}); out["summary"] != "人工智能从专家系统发展到深度学习。" || out["generated_by"] != "llm" {
+		t.Errorf("unexpected summary output: %v", out)
+	}
+
+	// 模型调用失败时使用模板，并记录原因
+	writer.SetModel(&scriptedModel{err: errors.New("rate limited")})
+	article, err = writer.writeArticle(ctx, "AI技术", nil)
+	if err != nil {
+		t.Fatalf("writeArticle failed: %v", err)
+	}
+	out = article.(map[string]interface{})
+	if out["generated_by"] != "template" || out["fallback_reason"] != "rate limited" || out["content"] == "" {
+		t.Errorf("expected template fallback, got %v", out)
+	}
+}
+
+// recordingTranslator 记录调用的翻译服务，第failAt次调用返回错误
+type recordingTranslator struct {
+	chunks   []string
+	glossary map[string]string
+	failAt   int
+}
+
+func (tr *recordingTranslator) Translate(ctx context.Context, text, source, target string, glossary map[string]string) (string, error) {
+	tr.chunks = append(tr.chunks, text)
+	tr.glossary = glossary
+	if len(tr.chunks) == tr.failAt {
+		return "", errors.New("quota exceeded")
+	}
+	return fmt.Sprintf("[%s->%s %d]", source, target, len([]rune(text))), nil
+}
+
+// TestWriterAgentTranslation 测试语言识别、术语表和长文档分块翻译
+func TestWriterAgentTranslation(t *testing.T) {
+	for text, want := range map[string]string{
+		"今天天气很好，适合使用 Kubernetes 部署。": "Chinese",
+		"The quick brown fox.": "English",
+		"こんにちは世界":              "Japanese",
+		"안녕하세요":                "Korean",
+		"Привет, мир":          "Russian",
+		"12345":                "unknown",
+	} {
+		if got := detectLanguage(text); got != want {
+			t.Errorf("detectLanguage(%q) = %s, want %s", text, got, want)
+		}
+	}
+	if got := languageFromGoal("把这段话翻译成西班牙语"); got != "Spanish" {
+		t.Errorf("expected Spanish, got %s", got)
+	}
+
+	ctx := context.Background()
+	paragraph := strings.Repeat("模型推理。", 200) // 1000字
+	content := strings.Join([]string{paragraph, paragraph, paragraph}, "\n\n")
+	glossary := map[string]interface{}{"模型": "model", "": "ignored", "推理": 1}
+
+	tr := &recordingTranslator{}
+	writer := NewWriterAgent()
+	writer.SetTranslator(tr)
+	result, err := writer.translateContent(ctx, "翻译成英文", map[string]interface{}{"content": content, "glossary": glossary})
+	if err != nil {
+		t.Fatalf("translateContent failed: %v", err)
+	}
+	out := result.(map[string]interface{})
+	if out["source_lang"] != "Chinese" || out["target_lang"] != "English" || out["generated_by"] != "translator" || out["chunks"] != 3 {
+		t.Errorf("unexpected translation output: %v", out)
+	}
+	if len(tr.chunks) != 3 || out["translation"] != strings.Repeat("[Chinese->English 1000]\n\n", 2)+"[Chinese->English 1000]" {
+		t.Errorf("each paragraph should be translated separately, got %d chunks: %v", len(tr.chunks), out["translation"])
+	}
+	if !reflect.DeepEqual(tr.glossary, map[string]string{"模型": "model"}) || !reflect.DeepEqual(out["glossary_applied"], []string{"模型"}) {
+		t.Errorf("unexpected glossary: %v, applied %v", tr.glossary, out["glossary_applied"])
+	}
+
+	// 原文已是目标语言时不翻译
+	result, _ = writer.translateContent(ctx, "翻译", map[string]interface{}{"content": "Hello", "target_lang": "en"})
+	if out = result.(map[string]interface{}); out["generated_by"] != "none" || out["translation"] != "Hello" {
+		t.Errorf("same language should not be translated, got %v", out)
+	}
+
+	// 某一块翻译失败时整体回退到术语替换
+	writer.SetTranslator(&recordingTranslator{failAt: 2})
+	result, _ = writer.translateContent(ctx, "翻译成英文", map[string]interface{}{"content": content, "glossary": glossary})
+	out = result.(map[string]interface{})
+	if out["generated_by"] != "template" || out["fallback_reason"] != "chunk 2: quota exceeded" || !strings.Contains(out["translation"].(string), "model推理") {
+		t.Errorf("expected glossary fallback, got %v", out)
+	}
+
+	// 没有翻译服务时使用模型，提示词包含术语表
+	model := &scriptedModel{replies: []string{"Model inference."}}
+	writer = NewWriterAgent()
+	writer.SetModel(model)
+	result, _ = writer.translateContent(ctx, "翻译成英文", map[string]interface{}{"content": "模型推理。", "glossary": glossary})
+	if out = result.(map[string]interface{}); out["generated_by"] != "llm" || out["translation"] != "Model inference." {
+		t.Errorf("expected model translation, got %v", out)
+	}
+	if !strings.Contains(model.messages[0][1].Content, "模型 => model") {
+		t.Errorf("prompt should contain the glossary:\n%s", model.messages[0][1].Content)
+	}
+
+	if _, err := writer.translateContent(ctx, "翻译", map[string]interface{}{"content": "  "}); err == nil {
+		t.Error("expected error for empty content")
+	}
+}
+
+// tokenModel 每次调用记录固定token用量的模型
+type tokenModel struct {
+	scriptedModel
+	tokens int
+}
+
+func (m *tokenModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	llm.UsageCollectorFromContext(ctx).Add(llm.UsageRecord{Model: "token", TotalTokens: m.tokens})
+	m.messages = append(m.messages, messages)
+	return "translated", nil
+}
+
+// TestResourceLimits 测试按Agent类型合并资源限制，超出限制时降级并记录在元数据中
+func TestResourceLimits(t *testing.T) {
+	factory := NewFactory()
+	err := factory.SetResourceLimits(map[string]config.AgentLimitConfig{
+		"default": {MaxTokens: 5000, MaxDuration: "1m"},
+		"writer":  {MaxTokens: 1000, MaxToolCalls: 2},
+	})
+	if err != nil {
+		t.Fatalf("SetResourceLimits failed: %v", err)
+	}
+	want := ResourceLimits{MaxTokens: 1000, MaxToolCalls: 2, MaxDuration: time.Minute}
+	if got := factory.writer.ResourceLimits(); got != want {
+		t.Errorf("writer limits = %+v, want %+v", got, want)
+	}
+	if got := factory.coder.ResourceLimits(); got != (ResourceLimits{MaxTokens: 5000, MaxDuration: time.Minute}) {
+		t.Errorf("coder should use the defaults, got %+v", got)
+	}
+	if err := factory.SetResourceLimits(map[string]config.AgentLimitConfig{"poet": {MaxTokens: 1}}); err == nil {
+		t.Error("expected error for unknown agent type")
+	}
+	if err := factory.SetResourceLimits(map[string]config.AgentLimitConfig{"writer": {MaxDuration: "soon"}}); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+
+	// 每块翻译用600个token，第三块时预算已用完，整体降级为术语替换
+	model := &tokenModel{tokens: 600}
+	factory.SetModel(model)
+	paragraph := strings.Repeat("模型推理。", 200)
+	taskObj := &task.Task{
+		ID:           "task-limits",
+		Type:         "writer",
+		Goal:         "翻译成英文",
+		Requirements: map[string]interface{}{"content": strings.Join([]string{paragraph, paragraph, paragraph}, "\n\n")},
+	}
+	result, err := ExecuteWithUsage(context.Background(), factory.writer, taskObj)
+	if err != nil {
+		t.Fatalf("ExecuteWithUsage failed: %v", err)
+	}
+	if len(model.messages) != 2 {
+		t.Errorf("model should not be called after the token budget is used up, got %d calls", len(model.messages))
+	}
+	if out := result.Output.(map[string]interface{}); out["generated_by"] != "template" {
+		t.Errorf("expected degraded output, got %v", out)
+	}
+	limits, _ := result.Metadata["resource_limits"].(map[string]interface{})
+	if !reflect.DeepEqual(limits["triggered"], []string{"max_tokens"}) || limits["degraded"] != true || limits["max_tokens"] != 1000 {
+		t.Errorf("unexpected resource_limits metadata: %v", result.Metadata["resource_limits"])
+	}
+
+	// 未触发限制时不附加resource_limits
+	result, _ = ExecuteWithUsage(context.Background(), factory.writer, &task.Task{ID: "task-ok", Type: "writer", Goal: "撰写一篇文章"})
+	if _, ok := result.Metadata["resource_limits"]; ok {
+		t.Errorf("resource_limits should only be reported when a limit triggers, got %v", result.Metadata)
+	}
+
+	// 工具调用次数和执行时长
+	ctx := context.Background()
+	budget := &taskBudget{limits: ResourceLimits{MaxToolCalls: 2, MaxDuration: time.Millisecond}}
+	if err := budget.allowToolCalls(ctx, 1); err != nil {
+		t.Fatalf("first tool call should be allowed: %v", err)
+	}
+	if err := budget.allowToolCalls(ctx, 2); !errors.Is(err, ErrResourceLimitExceeded) {
+		t.Errorf("expected tool call limit, got %v", err)
+	}
+	expired, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+	if err := budget.allowGenerate(expired); !errors.Is(err, ErrResourceLimitExceeded) {
+		t.Errorf("expected duration limit, got %v", err)
+	}
+	meta := budget.metadata()
+	if !reflect.DeepEqual(meta["triggered"], []string{"max_tool_calls", "max_duration"}) || meta["tool_calls"] != 1 || meta["max_duration_ms"] != int64(1) {
+		t.Errorf("unexpected metadata: %v", meta)
+	}
+	if (*taskBudget)(nil).allowToolCalls(ctx, 100) != nil {
+		t.Error("tasks without limits should not be restricted")
+	}
+}
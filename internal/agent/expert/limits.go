@@ -0,0 +1,197 @@
+package expert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
+)
+
+// ErrResourceLimitExceeded 任务超出Agent的资源限制，Agent应改用不依赖模型或工具的降级实现
+var ErrResourceLimitExceeded = errors.New("agent resource limit exceeded")
+
+// 资源限制名称，记录在TaskResult元数据的resource_limits.triggered中
+const (
+	limitMaxTokens    = "max_tokens"
+	limitMaxToolCalls = "max_tool_calls"
+	limitMaxDuration  = "max_duration"
+)
+
+// defaultLimitsKey 配置中适用于所有Agent类型的限制
+const defaultLimitsKey = "default"
+
+// ResourceLimits 单个任务的资源限制，0表示不限制
+type ResourceLimits struct {
+	MaxTokens    int           // 模型token总量
+	MaxToolCalls int           // 工具调用次数
+	MaxDuration  time.Duration // 执行时长
+}
+
+// IsZero 是否没有任何限制
+func (l ResourceLimits) IsZero() bool {
+	return l.MaxTokens <= 0 && l.MaxToolCalls <= 0 && l.MaxDuration <= 0
+}
+
+// SetResourceLimits 按Agent类型设置资源限制，default为所有类型的默认值，类型配置中非0的字段覆盖默认值
+func (f *Factory) SetResourceLimits(cfg map[string]config.AgentLimitConfig) error {
+	defaults, err := parseResourceLimits(cfg[defaultLimitsKey])
+	if err != nil {
+		return fmt.Errorf("invalid default agent limits: %w", err)
+	}
+	agents := make(map[string]*BaseAgent)
+	for _, agent := range f.baseAgents() {
+		agents[agent.Type] = agent
+	}
+	for agentType := range cfg {
+		if agentType != defaultLimitsKey && agents[agentType] == nil {
+			return fmt.Errorf("limits for unknown agent type: %s", agentType)
+		}
+	}
+
+	for agentType, agent := range agents {
+		limits := defaults
+		if override, ok := cfg[agentType]; ok {
+			parsed, err := parseResourceLimits(override)
+			if err != nil {
+				return fmt.Errorf("invalid limits for %s: %w", agentType, err)
+			}
+			if parsed.MaxTokens > 0 {
+				limits.MaxTokens = parsed.MaxTokens
+			}
+			if parsed.MaxToolCalls > 0 {
+				limits.MaxToolCalls = parsed.MaxToolCalls
+			}
+			if parsed.MaxDuration > 0 {
+				limits.MaxDuration = parsed.MaxDuration
+			}
+		}
+		agent.limits = limits
+	}
+	return nil
+}
+
+// parseResourceLimits 解析资源限制配置
+func parseResourceLimits(cfg config.AgentLimitConfig) (ResourceLimits, error) {
+	limits := ResourceLimits{MaxTokens: cfg.MaxTokens, MaxToolCalls: cfg.MaxToolCalls}
+	if cfg.MaxDuration != "" {
+		d, err := time.ParseDuration(cfg.MaxDuration)
+		if err != nil || d <= 0 {
+			return limits, fmt.Errorf("invalid max_duration %q", cfg.MaxDuration)
+		}
+		limits.MaxDuration = d
+	}
+	return limits, nil
+}
+
+// ResourceLimits 返回Agent的资源限制
+func (a *BaseAgent) ResourceLimits() ResourceLimits {
+	return a.limits
+}
+
+// taskBudget 单个任务的资源预算
+// 由ExecuteWithUsage放入上下文，Generate和CallTool调用前检查，超出时返回ErrResourceLimitExceeded
+type taskBudget struct {
+	limits    ResourceLimits
+	usage     *llm.UsageCollector
+	mu        sync.Mutex
+	toolCalls int
+	triggered []string
+}
+
+// budgetKey 上下文中任务预算的键
+type budgetKey struct{}
+
+// budgetFromContext 从上下文获取任务预算，未设置限制时返回nil
+func budgetFromContext(ctx context.Context) *taskBudget {
+	budget, _ := ctx.Value(budgetKey{}).(*taskBudget)
+	return budget
+}
+
+// allowGenerate 检查是否还能调用模型
+func (b *taskBudget) allowGenerate(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.checkDeadline(ctx); err != nil {
+		return err
+	}
+	if b.limits.MaxTokens > 0 && b.usage != nil {
+		if used := b.usage.Totals().TotalTokens; used >= int64(b.limits.MaxTokens) {
+			b.trigger(limitMaxTokens)
+			return fmt.Errorf("%w: used %d of %d tokens", ErrResourceLimitExceeded, used, b.limits.MaxTokens)
+		}
+	}
+	return nil
+}
+
+// allowToolCalls 检查是否还能调用n次工具，允许时计入调用次数
+func (b *taskBudget) allowToolCalls(ctx context.Context, n int) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.checkDeadline(ctx); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits.MaxToolCalls > 0 && b.toolCalls+n > b.limits.MaxToolCalls {
+		b.addTriggerLocked(limitMaxToolCalls)
+		return fmt.Errorf("%w: tool call budget of %d used up", ErrResourceLimitExceeded, b.limits.MaxToolCalls)
+	}
+	b.toolCalls += n
+	return nil
+}
+
+// checkDeadline 执行时长用尽后不再发起新的模型或工具调用
+func (b *taskBudget) checkDeadline(ctx context.Context) error {
+	if b.limits.MaxDuration > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		b.trigger(limitMaxDuration)
+		return fmt.Errorf("%w: exceeded %s", ErrResourceLimitExceeded, b.limits.MaxDuration)
+	}
+	return nil
+}
+
+// trigger 记录触发的限制
+func (b *taskBudget) trigger(limit string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.addTriggerLocked(limit)
+}
+
+func (b *taskBudget) addTriggerLocked(limit string) {
+	for _, t := range b.triggered {
+		if t == limit {
+			return
+		}
+	}
+	b.triggered = append(b.triggered, limit)
+}
+
+// metadata 触发限制时附加到TaskResult元数据的内容，未触发时返回nil
+func (b *taskBudget) metadata() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.triggered) == 0 {
+		return nil
+	}
+
+	meta := map[string]interface{}{
+		"triggered":  append([]string(nil), b.triggered...),
+		"degraded":   true,
+		"tool_calls": b.toolCalls,
+	}
+	if b.limits.MaxTokens > 0 {
+		meta[limitMaxTokens] = b.limits.MaxTokens
+	}
+	if b.limits.MaxToolCalls > 0 {
+		meta[limitMaxToolCalls] = b.limits.MaxToolCalls
+	}
+	if b.limits.MaxDuration > 0 {
+		meta["max_duration_ms"] = b.limits.MaxDuration.Milliseconds()
+	}
+	return meta
+}
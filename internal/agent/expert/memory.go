@@ -0,0 +1,145 @@
+package expert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/memory"
+	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// 专家Agent记忆的作用域
+const (
+	agentMemoryScopeUser    = "user"
+	agentMemoryScopeProject = "project"
+)
+
+// defaultAgentRecallLimit 每次检索的默认记忆条数
+const defaultAgentRecallLimit = 5
+
+// agentMemory Agent记忆
+// 在记忆管理器中为每个Agent类型和用户（或项目）单独存放任务结果，不同Agent、用户和租户之间互不可见
+type agentMemory struct {
+	manager *memory.EnhancedMemoryManager
+	scope   string
+	limit   int
+}
+
+// recalledKey 上下文中本次任务检索到的记忆
+type recalledKey struct{}
+
+// SetMemoryManager 设置记忆管理器，按配置为未停用记忆的Agent开启记忆
+func (f *Factory) SetMemoryManager(manager *memory.EnhancedMemoryManager, cfg config.AgentMemoryConfig) error {
+	disabled := make(map[string]bool, len(cfg.DisabledAgents))
+	for _, agentType := range cfg.DisabledAgents {
+		disabled[agentType] = true
+	}
+	limit := cfg.RecallLimit
+	if limit <= 0 {
+		limit = defaultAgentRecallLimit
+	}
+
+	for _, agent := range f.baseAgents() {
+		if manager == nil || !cfg.Enabled || disabled[agent.Type] {
+			agent.memory = nil
+			continue
+		}
+		scope := cfg.Scope
+		if s, ok := cfg.Scopes[agent.Type]; ok {
+			scope = s
+		}
+		switch scope {
+		case "":
+			scope = agentMemoryScopeUser
+		case agentMemoryScopeUser, agentMemoryScopeProject:
+		default:
+			return fmt.Errorf("unsupported agent memory scope for %s: %s", agent.Type, scope)
+		}
+		agent.memory = &agentMemory{manager: manager, scope: scope, limit: limit}
+	}
+	return nil
+}
+
+// memoryOwner 任务的记忆归属，按作用域取requirements中的project或user_id
+// project作用域缺少project时退回user；无法确定归属或任务关闭了记忆（memory: false）时返回false
+func (a *BaseAgent) memoryOwner(ctx context.Context, taskObj *task.Task) (string, bool) {
+	if a.memory == nil {
+		return "", false
+	}
+	reqMap := taskObj.Requirements
+	if enabled, ok := reqMap["memory"].(bool); ok && !enabled {
+		return "", false
+	}
+
+	if a.memory.scope == agentMemoryScopeProject {
+		if project, _ := reqMap["project"].(string); project != "" {
+			return tenant.Scope(ctx, "agent/"+a.Type+"/project/"+project), true
+		}
+	}
+	if userID, _ := reqMap["user_id"].(string); userID != "" {
+		return tenant.Scope(ctx, "agent/"+a.Type+"/user/"+userID), true
+	}
+	return "", false
+}
+
+// RecallMemories 检索与任务目标相关的历史任务结果
+// 返回的上下文携带检索到的记忆，Generate会把它们放入系统提示词
+func (a *BaseAgent) RecallMemories(ctx context.Context, taskObj *task.Task) (context.Context, []string) {
+	owner, ok := a.memoryOwner(ctx, taskObj)
+	if !ok {
+		return ctx, nil
+	}
+	memories, err := a.memory.manager.SemanticSearch(ctx, owner, taskObj.Goal, a.memory.limit)
+	if err != nil || len(memories) == 0 {
+		return ctx, nil
+	}
+
+	recalled := make([]string, 0, len(memories))
+	for _, m := range memories {
+		recalled = append(recalled, m.Content)
+	}
+	events.Thought(ctx, a.Type, fmt.Sprintf("回忆起%d条相关的历史任务记录", len(recalled)))
+	return context.WithValue(ctx, recalledKey{}, recalled), recalled
+}
+
+// RememberOutcome 记录任务结果，供之后同一用户或项目的任务检索
+func (a *BaseAgent) RememberOutcome(ctx context.Context, taskObj *task.Task, content string, topics ...string) {
+	owner, ok := a.memoryOwner(ctx, taskObj)
+	if !ok || strings.TrimSpace(content) == "" {
+		return
+	}
+	// 记录失败不影响任务结果
+	_ = a.memory.manager.AddScopedMemory(ctx, owner, &memory.UserMemory{
+		Content:    content,
+		Topics:     append([]string{"agent:" + a.Type}, topics...),
+		Importance: 0.6,
+	})
+}
+
+// recalledMemoryPrompt 上下文中检索到的记忆，格式化为系统提示词
+func recalledMemoryPrompt(ctx context.Context) string {
+	recalled, _ := ctx.Value(recalledKey{}).([]string)
+	if len(recalled) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("[历史任务记录]\n以下是之前为同一用户或项目完成的相关任务，可参考但需以本次任务为准：\n")
+	for _, content := range recalled {
+		sb.WriteString("- " + content + "\n")
+	}
+	return sb.String()
+}
+
+// goalSummary 截断任务目标用于记忆内容
+func goalSummary(goal string) string {
+	return truncateRunes(strings.TrimSpace(goal), 80)
+}
+
+// flattenText 合并空白并截断，用于记忆内容
+func flattenText(s string, n int) string {
+	return truncateRunes(strings.Join(strings.Fields(s), " "), n)
+}
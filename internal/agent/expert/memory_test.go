@@ -0,0 +1,170 @@
+package expert
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/memory"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// TestSetMemoryManager 测试按配置为Agent开启记忆，停用的Agent不使用记忆，不支持的作用域返回错误
+func TestSetMemoryManager(t *testing.T) {
+	manager := memory.NewEnhancedMemoryManager(nil)
+
+	factory := NewFactory()
+	err := factory.SetMemoryManager(manager, config.AgentMemoryConfig{
+		Enabled:        true,
+		Scopes:         map[string]string{"researcher": "project"},
+		DisabledAgents: []string{"coder"},
+	})
+	if err != nil {
+		t.Fatalf("SetMemoryManager() error = %v", err)
+	}
+	if factory.coder.memory != nil {
+		t.Error("disabled agent coder should not have memory")
+	}
+	if m := factory.writer.memory; m == nil || m.scope != agentMemoryScopeUser || m.limit != defaultAgentRecallLimit {
+		t.Errorf("writer memory = %+v, want user scope with default limit", m)
+	}
+	if m := factory.researcher.memory; m == nil || m.scope != agentMemoryScopeProject {
+		t.Errorf("researcher memory = %+v, want project scope", m)
+	}
+
+	err = factory.SetMemoryManager(manager, config.AgentMemoryConfig{Enabled: true, Scopes: map[string]string{"analyst": "team"}})
+	if err == nil || !strings.Contains(err.Error(), "analyst") {
+		t.Errorf("SetMemoryManager() with invalid scope error = %v, want unsupported scope for analyst", err)
+	}
+
+	// 关闭记忆后所有Agent都不使用记忆
+	if err := factory.SetMemoryManager(manager, config.AgentMemoryConfig{Enabled: false}); err != nil {
+		t.Fatalf("SetMemoryManager() error = %v", err)
+	}
+	for _, agent := range factory.baseAgents() {
+		if agent.memory != nil {
+			t.Errorf("agent %s should not have memory when disabled", agent.Type)
+		}
+	}
+}
+
+// TestMemoryOwner 测试按作用域、用户、项目和租户确定记忆归属，以及任务关闭记忆
+func TestMemoryOwner(t *testing.T) {
+	manager := memory.NewEnhancedMemoryManager(nil)
+	userAgent := NewBaseAgent("writer-test", "Writer", "writer", "", nil)
+	userAgent.memory = &agentMemory{manager: manager, scope: agentMemoryScopeUser, limit: 5}
+	projectAgent := NewBaseAgent("researcher-test", "Researcher", "researcher", "", nil)
+	projectAgent.memory = &agentMemory{manager: manager, scope: agentMemoryScopeProject, limit: 5}
+
+	ctx := context.Background()
+	tenantCtx := tenant.WithTenant(ctx, "acme")
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		agent  *BaseAgent
+		req    map[string]interface{}
+		want   string
+		wantOK bool
+	}{
+		{"user", ctx, userAgent, map[string]interface{}{"user_id": "u1", "project": "p1"}, "agent/writer/user/u1", true},
+		{"project", ctx, projectAgent, map[string]interface{}{"user_id": "u1", "project": "p1"}, "agent/researcher/project/p1", true},
+		{"project falls back to user", ctx, projectAgent, map[string]interface{}{"user_id": "u1"}, "agent/researcher/user/u1", true},
+		{"tenant", tenantCtx, userAgent, map[string]interface{}{"user_id": "u1"}, tenant.ScopeTo("acme", "agent/writer/user/u1"), true},
+		{"no owner", ctx, userAgent, map[string]interface{}{"project": "p1"}, "", false},
+		{"opt out", ctx, userAgent, map[string]interface{}{"user_id": "u1", "memory": false}, "", false},
+		{"no memory", ctx, NewBaseAgent("coder-test", "Coder", "coder", "", nil), map[string]interface{}{"user_id": "u1"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.agent.memoryOwner(tt.ctx, &task.Task{Goal: "goal", Requirements: tt.req})
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("memoryOwner() = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestRecallMemoriesInPrompt 测试记录的任务结果在之后的任务中被检索并放入系统提示词，关闭记忆的任务不检索也不记录
+func TestRecallMemoriesInPrompt(t *testing.T) {
+	manager := memory.NewEnhancedMemoryManager(nil)
+	model := &scriptedModel{replies: []string{"ok", "ok"}}
+	agent := NewBaseAgent("writer-test", "Writer", "writer", "", nil)
+	agent.SetModel(model)
+	agent.memory = &agentMemory{manager: manager, scope: agentMemoryScopeUser, limit: 5}
+	ctx := context.Background()
+
+	first := &task.Task{Goal: "撰写周报", Requirements: map[string]interface{}{"user_id": "u1"}}
+	agent.RememberOutcome(ctx, first, "周报使用表格列出本周进展")
+
+	optOut := &task.Task{Goal: "撰写月报", Requirements: map[string]interface{}{"user_id": "u1", "memory": false}}
+	agent.RememberOutcome(ctx, optOut, "不应被记录")
+	if _, recalled := agent.RecallMemories(ctx, optOut); recalled != nil {
+		t.Errorf("RecallMemories() with memory=false = %v, want nil", recalled)
+	}
+	if got := manager.ListMemories("agent/writer/user/u1"); len(got) != 1 {
+		t.Fatalf("stored memories = %d, want 1", len(got))
+	}
+
+	second := &task.Task{Goal: "撰写下周周报", Requirements: map[string]interface{}{"user_id": "u1"}}
+	recallCtx, recalled := agent.RecallMemories(ctx, second)
+	if len(recalled) != 1 || recalled[0] != "周报使用表格列出本周进展" {
+		t.Fatalf("RecallMemories() = %v, want the stored outcome", recalled)
+	}
+	if _, err := agent.Generate(recallCtx, "你是写作助手", "撰写下周周报"); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	system := model.messages[0][0]
+	if system.Role != "system" || !strings.Contains(system.Content, "[历史任务记录]") || !strings.Contains(system.Content, "- 周报使用表格列出本周进展") {
+		t.Errorf("system prompt = %q, want recalled memories", system.Content)
+	}
+
+	// 其他用户检索不到
+	other := &task.Task{Goal: "撰写周报", Requirements: map[string]interface{}{"user_id": "u2"}}
+	otherCtx, recalled := agent.RecallMemories(ctx, other)
+	if recalled != nil {
+		t.Errorf("RecallMemories() for another user = %v, want nil", recalled)
+	}
+	if _, err := agent.Generate(otherCtx, "你是写作助手", "撰写周报"); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if content := model.messages[1][0].Content; strings.Contains(content, "[历史任务记录]") {
+		t.Errorf("system prompt for another user = %q, want no recalled memories", content)
+	}
+}
+
+// TestPlannerRemembersOutcome 测试规划Agent执行后记录规划结果
+func TestPlannerRemembersOutcome(t *testing.T) {
+	manager := memory.NewEnhancedMemoryManager(nil)
+	factory := NewFactory()
+	if err := factory.SetMemoryManager(manager, config.AgentMemoryConfig{Enabled: true, Scope: "project"}); err != nil {
+		t.Fatalf("SetMemoryManager() error = %v", err)
+	}
+	if err := factory.RegisterAllAgents(aiagentorchestrator.NewAgentRegistry()); err != nil {
+		t.Fatalf("RegisterAllAgents() error = %v", err)
+	}
+
+	taskObj := &task.Task{
+		ID:           "task-plan",
+		Type:         "planner",
+		Goal:         "调研并分析AI市场趋势，撰写报告",
+		Requirements: map[string]interface{}{"user_id": "u1", "project": "market"},
+	}
+	if _, err := factory.planner.Execute(context.Background(), taskObj); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	memories := manager.ListMemories("agent/planner/project/market")
+	if len(memories) != 1 {
+		t.Fatalf("planner memories = %d, want 1", len(memories))
+	}
+	if !strings.Contains(memories[0].Content, "规划「调研并分析AI市场趋势，撰写报告」") {
+		t.Errorf("memory content = %q, want planned goal", memories[0].Content)
+	}
+	if len(memories[0].Topics) == 0 || memories[0].Topics[0] != "agent:planner" {
+		t.Errorf("memory topics = %v, want agent:planner first", memories[0].Topics)
+	}
+}
@@ -0,0 +1,398 @@
+package expert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/agent/events"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/workflow"
+)
+
+// maxPlanSteps 规划出的工作流最多包含的步骤数
+const maxPlanSteps = 10
+
+// planStage 启发式规划的一个阶段：目标中出现关键词时，由具备该能力的Agent执行
+type planStage struct {
+	id         string
+	name       string
+	capability string
+	keywords   []string
+	goal       string            // 步骤目标，{{goal}} 由工作流输入替换
+	direct     []string          // 目标包含这些关键词时直接以目标作为步骤目标，由Agent按目标选择处理方式
+	inputs     map[string]string // 输入名 -> 前序阶段ID，前序阶段不存在时忽略
+}
+
+// planStages 启发式规划的阶段，按执行顺序排列，与内置报告工作流的数据流一致
+var planStages = []planStage{
+	{
+		id: "research", name: "收集资料", capability: "information_collection",
+		keywords: []string{"搜索", "查找", "调研", "资料", "收集", "研究"},
+		goal:     "收集资料：{{goal}}",
+	},
+	{
+		id: "analyze", name: "分析数据", capability: "data_analysis",
+		keywords: []string{"分析", "统计", "趋势", "对比", "比较", "预测", "数据"},
+		goal:     "分析资料：{{goal}}",
+		inputs:   map[string]string{"input": "research"},
+	},
+	{
+		id: "write", name: "撰写内容", capability: "content_generation",
+		keywords: []string{"报告", "文章", "撰写", "总结", "摘要", "翻译", "写"},
+		goal:     "生成报告：{{goal}}",
+		direct:   []string{"翻译", "文章", "摘要"},
+		inputs:   map[string]string{"data": "analyze", "research": "research"},
+	},
+}
+
+// PlannerAgent 规划Agent：把高层目标拆解为由其他专家Agent执行的工作流定义
+// 从Agent注册表获取可用Agent及其能力；设置了模型时由模型规划，否则按关键词启发式规划
+type PlannerAgent struct {
+	*BaseAgent
+	registry *aiagentorchestrator.AgentRegistry
+}
+
+// NewPlannerAgent 创建规划Agent
+func NewPlannerAgent() *PlannerAgent {
+	base := NewBaseAgent(
+		"planner-001",
+		"Planner",
+		"planner",
+		"规划协调专家，把复杂目标拆解为多Agent协作的工作流",
+		[]string{
+			"task_planning",
+			"task_decomposition",
+			"workflow_generation",
+			"agent_coordination",
+		},
+	)
+
+	return &PlannerAgent{BaseAgent: base}
+}
+
+// SetRegistry 设置Agent注册表，规划时只使用其中已注册的Agent
+func (p *PlannerAgent) SetRegistry(registry *aiagentorchestrator.AgentRegistry) {
+	p.registry = registry
+}
+
+// Execute 为任务目标生成工作流定义
+// 输出中的workflow可直接保存并执行，执行输入 goal 默认为任务目标
+func (p *PlannerAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.TaskResult, error) {
+	startTime := time.Now()
+	p.UpdateStatus("running")
+
+	if err := p.ValidateTask(taskObj); err != nil {
+		return p.createErrorResult(taskObj, err, startTime), err
+	}
+
+	// 之前为同一用户或项目规划的工作流作为模型规划的参考
+	ctx, recalled := p.RecallMemories(ctx, taskObj)
+
+	output, err := p.plan(ctx, taskObj.Goal)
+	if err != nil {
+		p.UpdateStatus("failed")
+		return p.createErrorResult(taskObj, err, startTime), err
+	}
+	p.RememberOutcome(ctx, taskObj, fmt.Sprintf("规划「%s」：%d个步骤，使用%s",
+		goalSummary(taskObj.Goal), output["steps"], strings.Join(output["agents"].([]string), "、")))
+
+	p.UpdateStatus("idle")
+	return &task.TaskResult{
+		TaskID:   taskObj.ID,
+		TaskGoal: taskObj.Goal,
+		Type:     taskObj.Type,
+		Status:   task.TaskStatusCompleted,
+		Output:   output,
+		Duration: time.Since(startTime),
+		Metadata: map[string]interface{}{
+			"agent_type":        "planner",
+			"planned_by":        output["planned_by"],
+			"memories_recalled": len(recalled),
+		},
+		Timestamp: time.Now(),
+		AgentUsed: p.Name,
+	}, nil
+}
+
+// plan 先由模型规划，模型不可用或规划不合法时使用启发式规划
+func (p *PlannerAgent) plan(ctx context.Context, goal string) (map[string]interface{}, error) {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no agents available for planning")
+	}
+
+	output := map[string]interface{}{"goal": goal}
+	var wf *workflow.Workflow
+	if p.Model != nil {
+		events.Thought(ctx, p.Type, fmt.Sprintf("根据%d个可用Agent规划：%s", len(candidates), goal))
+		planned, err := p.planWithModel(ctx, goal, candidates)
+		if err != nil {
+			events.Thought(ctx, p.Type, "模型规划失败，改用启发式规划："+err.Error())
+			output["fallback_reason"] = err.Error()
+		} else {
+			wf = planned
+			output["planned_by"] = "llm"
+		}
+	}
+	if wf == nil {
+		planned, err := p.planHeuristic(goal, candidates)
+		if err != nil {
+			return nil, err
+		}
+		wf = planned
+		output["planned_by"] = "heuristic"
+	}
+
+	agents := make([]string, 0, len(wf.Agents))
+	for _, ref := range wf.Agents {
+		agents = append(agents, ref.Type)
+	}
+	output["workflow"] = wf
+	output["agents"] = agents
+	output["steps"] = len(wf.Steps)
+	return output, nil
+}
+
+// candidates 注册表中可参与规划的Agent（按类型去重，排除规划Agent自身），按类型排序
+func (p *PlannerAgent) candidates() []*aiagentorchestrator.AgentInfo {
+	if p.registry == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	agents := make([]*aiagentorchestrator.AgentInfo, 0)
+	for _, info := range p.registry.List() {
+		if info.Type == p.Type || info.Status != "active" || seen[info.Type] {
+			continue
+		}
+		seen[info.Type] = true
+		agents = append(agents, info)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Type < agents[j].Type })
+	return agents
+}
+
+// plannedStep 模型输出的步骤
+type plannedStep struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Agent     string            `json:"agent"`
+	Goal      string            `json:"goal"`
+	DependsOn []string          `json:"depends_on"`
+	Inputs    map[string]string `json:"inputs"`
+}
+
+// planWithModel 由模型按可用Agent的能力规划步骤
+func (p *PlannerAgent) planWithModel(ctx context.Context, goal string, candidates []*aiagentorchestrator.AgentInfo) (*workflow.Workflow, error) {
+	var sb strings.Builder
+	sb.WriteString("可用的Agent（agent字段只能使用这些类型）：\n")
+	for _, info := range candidates {
+		sb.WriteString(fmt.Sprintf("- %s：%s\n", info.Type, strings.Join(info.Capabilities, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf(`
+把下面的目标拆解为不超过%d个步骤，只输出JSON：
+{"name": "工作流名称", "steps": [{"id": "步骤ID", "name": "步骤名称", "agent": "Agent类型", "goal": "该步骤的任务目标", "depends_on": ["前置步骤ID"], "inputs": {"输入名": "前置步骤ID"}}]}
+inputs把前置步骤的输出传给该步骤，省略时按depends_on以步骤ID为输入名。
+
+目标：%s`, maxPlanSteps, goal))
+
+	raw, err := p.Generate(ctx, "你是多Agent协作的任务规划专家，善于把复杂目标拆解为分工明确、依赖清晰的步骤。", sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model returned no plan")
+	}
+	var plan struct {
+		Name  string        `json:"name"`
+		Steps []plannedStep `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("plan has no steps")
+	}
+	if len(plan.Steps) > maxPlanSteps {
+		return nil, fmt.Errorf("plan has %d steps, at most %d allowed", len(plan.Steps), maxPlanSteps)
+	}
+
+	available := make(map[string]*aiagentorchestrator.AgentInfo, len(candidates))
+	for _, info := range candidates {
+		available[info.Type] = info
+	}
+
+	wf := newPlanWorkflow(plan.Name, goal)
+	for _, ps := range plan.Steps {
+		if available[ps.Agent] == nil {
+			return nil, fmt.Errorf("step %s: unknown agent %q", ps.ID, ps.Agent)
+		}
+		inputs := ps.Inputs
+		if len(inputs) == 0 && len(ps.DependsOn) > 0 {
+			inputs = make(map[string]string, len(ps.DependsOn))
+			for _, dep := range ps.DependsOn {
+				inputs[dep] = dep
+			}
+		}
+		stepGoal := ps.Goal
+		if stepGoal == "" {
+			stepGoal = "{{goal}}"
+		}
+		wf.AddStep(&workflow.Step{
+			ID:        ps.ID,
+			Name:      ps.Name,
+			Type:      "task",
+			Agent:     ps.Agent,
+			DependsOn: ps.DependsOn,
+			Config:    map[string]interface{}{"goal": stepGoal},
+			Inputs:    inputs,
+		})
+		addAgentRef(wf, available[ps.Agent])
+	}
+
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// planHeuristic 按目标中的关键词选择阶段，每个阶段由注册表中能力最匹配的Agent执行
+// 没有命中任何阶段时使用完整的调研、分析、撰写流程
+func (p *PlannerAgent) planHeuristic(goal string, candidates []*aiagentorchestrator.AgentInfo) (*workflow.Workflow, error) {
+	selected := make([]planStage, 0, len(planStages))
+	for _, stage := range planStages {
+		for _, keyword := range stage.keywords {
+			if strings.Contains(goal, keyword) {
+				selected = append(selected, stage)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		selected = planStages
+	}
+
+	wf := newPlanWorkflow("", goal)
+	planned := make(map[string]bool)
+	previous := ""
+	for _, stage := range selected {
+		info := bestCandidate(candidates, stage.capability)
+		if info == nil {
+			continue
+		}
+
+		stepGoal := stage.goal
+		for _, keyword := range stage.direct {
+			if strings.Contains(goal, keyword) {
+				stepGoal = "{{goal}}"
+				break
+			}
+		}
+		step := &workflow.Step{
+			ID:     stage.id,
+			Name:   stage.name,
+			Type:   "task",
+			Agent:  info.Type,
+			Config: map[string]interface{}{"goal": stepGoal},
+		}
+		if previous != "" {
+			step.DependsOn = []string{previous}
+		}
+		for name, from := range stage.inputs {
+			if planned[from] {
+				if step.Inputs == nil {
+					step.Inputs = make(map[string]string)
+				}
+				step.Inputs[name] = from
+			}
+		}
+		wf.AddStep(step)
+		addAgentRef(wf, info)
+		planned[stage.id] = true
+		previous = stage.id
+	}
+
+	if len(wf.Steps) == 0 {
+		return nil, fmt.Errorf("no registered agent can handle goal: %s", goal)
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// bestCandidate 具备指定能力的Agent，没有时返回nil
+func bestCandidate(candidates []*aiagentorchestrator.AgentInfo, capability string) *aiagentorchestrator.AgentInfo {
+	for _, info := range candidates {
+		for _, c := range info.Capabilities {
+			if c == capability {
+				return info
+			}
+		}
+	}
+	return nil
+}
+
+// newPlanWorkflow 创建规划出的工作流，目标作为 goal 变量的默认值
+func newPlanWorkflow(name, goal string) *workflow.Workflow {
+	if name == "" {
+		name = "plan: " + truncateRunes(goal, 40)
+	}
+	wf := workflow.NewWorkflow(name, "由规划Agent生成："+goal)
+	wf.Variables = append(wf.Variables, &workflow.Variable{
+		Name:         "goal",
+		Type:         "string",
+		DefaultValue: goal,
+		Description:  "任务目标",
+	})
+	wf.Metadata["planned_by"] = "planner"
+	return wf
+}
+
+// addAgentRef 记录工作流使用的Agent（按类型去重）
+func addAgentRef(wf *workflow.Workflow, info *aiagentorchestrator.AgentInfo) {
+	for _, ref := range wf.Agents {
+		if ref.Type == info.Type {
+			return
+		}
+	}
+	wf.Agents = append(wf.Agents, &workflow.AgentRef{
+		Name:         info.Name,
+		Type:         info.Type,
+		Role:         info.Type,
+		Capabilities: info.Capabilities,
+	})
+}
+
+// truncateRunes 按字符截断
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// createErrorResult 创建错误结果
+func (p *PlannerAgent) createErrorResult(taskObj *task.Task, err error, startTime time.Time) *task.TaskResult {
+	return &task.TaskResult{
+		TaskID:   taskObj.ID,
+		TaskGoal: taskObj.Goal,
+		Type:     taskObj.Type,
+		Status:   task.TaskStatusFailed,
+		Error:    err.Error(),
+		Duration: time.Since(startTime),
+		Metadata: map[string]interface{}{
+			"agent_type": "planner",
+		},
+		Timestamp: time.Now(),
+		AgentUsed: p.Name,
+	}
+}
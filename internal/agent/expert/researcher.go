@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"ai-agent-assistant/internal/agent/events"
 	"ai-agent-assistant/internal/task"
 )
 
@@ -60,18 +61,25 @@ func (r *ResearcherAgent) Execute(ctx context.Context, taskObj *task.Task) (*tas
 	// 解析任务目标
 	researchGoal := taskObj.Goal
 
+	// 检索之前为同一用户或项目核实过的信息源
+	ctx, recalled := r.RecallMemories(ctx, taskObj)
+
 	// 根据任务类型选择研究方法
 	var output interface{}
 	var err error
 
 	if strings.Contains(researchGoal, "搜索") || strings.Contains(researchGoal, "查找") {
+		events.Thought(ctx, r.Type, "执行网络搜索："+researchGoal)
 		output, err = r.performWebSearch(ctx, researchGoal, taskObj.Requirements)
 	} else if strings.Contains(researchGoal, "分析") || strings.Contains(researchGoal, "研究") {
+		events.Thought(ctx, r.Type, "多角度深度研究："+researchGoal)
 		output, err = r.performResearch(ctx, researchGoal, taskObj.Requirements)
 	} else if strings.Contains(researchGoal, "验证") || strings.Contains(researchGoal, "核查") {
+		events.Thought(ctx, r.Type, "搜索证据进行事实核查："+researchGoal)
 		output, err = r.performFactCheck(ctx, researchGoal, taskObj.Requirements)
 	} else {
 		// 默认执行搜索
+		events.Thought(ctx, r.Type, "执行网络搜索："+researchGoal)
 		output, err = r.performWebSearch(ctx, researchGoal, taskObj.Requirements)
 	}
 
@@ -80,6 +88,13 @@ func (r *ResearcherAgent) Execute(ctx context.Context, taskObj *task.Task) (*tas
 		return r.createErrorResult(taskObj, err, startTime), err
 	}
 
+	if outMap, ok := output.(map[string]interface{}); ok {
+		if len(recalled) > 0 {
+			outMap["prior_findings"] = recalled
+		}
+		r.RememberOutcome(ctx, taskObj, r.memorySummary(researchGoal, outMap), "sources")
+	}
+
 	r.UpdateStatus("idle")
 	return &task.TaskResult{
 		TaskID:    taskObj.ID,
@@ -90,15 +105,49 @@ func (r *ResearcherAgent) Execute(ctx context.Context, taskObj *task.Task) (*tas
 		Error:     "",
 		Duration:  time.Since(startTime),
 		Metadata: map[string]interface{}{
-			"agent_type":    "researcher",
-			"search_engine": r.searchEngine,
-			"result_count":  r.getResultCount(output),
+			"agent_type":        "researcher",
+			"search_engine":     r.searchEngine,
+			"result_count":      r.getResultCount(output),
+			"memories_recalled": len(recalled),
 		},
 		Timestamp: time.Now(),
 		AgentUsed: r.Name,
 	}, nil
 }
 
+// memorySummary 本次调研核实的信息源和核查结论，记入Agent记忆
+func (r *ResearcherAgent) memorySummary(goal string, output map[string]interface{}) string {
+	urls := make([]string, 0)
+	seen := make(map[string]bool)
+	addURL := func(url string) {
+		if url != "" && !seen[url] && len(urls) < 8 {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	if sources, ok := output["sources"].([]string); ok {
+		for _, url := range sources {
+			addURL(url)
+		}
+	}
+	for _, key := range []string{"results", "evidence"} {
+		items, _ := output[key].([]map[string]interface{})
+		for _, item := range items {
+			url, _ := item["url"].(string)
+			addURL(url)
+		}
+	}
+
+	summary := fmt.Sprintf("调研「%s」", goalSummary(goal))
+	if verdict, ok := output["verdict"].(string); ok {
+		summary += "，核查结论：" + verdict
+	}
+	if len(urls) == 0 {
+		return summary + "，未找到可用的信息源"
+	}
+	return summary + "，核实的信息源：" + strings.Join(urls, "、")
+}
+
 // performWebSearch 执行网络搜索
 func (r *ResearcherAgent) performWebSearch(ctx context.Context, query string, requirements interface{}) (interface{}, error) {
 	// 构建搜索查询
@@ -192,15 +241,27 @@ func (r *ResearcherAgent) performFactCheck(ctx context.Context, claim string, re
 
 // search 执行搜索（简化实现）
 func (r *ResearcherAgent) search(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	tool := "search:" + r.searchEngine
+	events.Emit(ctx, events.Event{Type: events.TypeToolCall, Agent: r.Type, Tool: tool, Data: map[string]interface{}{"query": query}})
+
 	// 根据搜索引擎类型选择实现
+	var results []map[string]interface{}
+	var err error
 	switch r.searchEngine {
 	case "duckduckgo":
-		return r.searchDuckDuckGo(ctx, query)
+		results, err = r.searchDuckDuckGo(ctx, query)
 	case "google":
-		return r.searchGoogle(ctx, query)
+		results, err = r.searchGoogle(ctx, query)
 	default:
-		return r.searchDuckDuckGo(ctx, query)
+		results, err = r.searchDuckDuckGo(ctx, query)
+	}
+
+	result := events.Event{Type: events.TypeToolResult, Agent: r.Type, Tool: tool, Data: map[string]interface{}{"query": query, "count": len(results)}}
+	if err != nil {
+		result.Error = err.Error()
 	}
+	events.Emit(ctx, result)
+	return results, err
 }
 
 // searchDuckDuckGo 使用DuckDuckGo搜索（无需API key）
@@ -386,7 +447,7 @@ func (r *ResearcherAgent) analyzeEvidence(claim string, evidence []map[string]in
 		}
 	}
 
-	return fmt.Sprintf("找到%d条证据，其中%d条支持，%条反驳", len(evidence), supporting, refuting)
+	return fmt.Sprintf("找到%d条证据，其中%d条支持，%d条反驳", len(evidence), supporting, refuting)
 }
 
 // getVerdict 获取判定结果
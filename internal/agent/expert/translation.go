@@ -0,0 +1,357 @@
+package expert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"ai-agent-assistant/internal/agent/events"
+)
+
+// Translator 翻译服务，可接入外部翻译API；未设置时写作Agent使用模型翻译
+type Translator interface {
+	// Translate 把text从source翻译为target，glossary为必须遵守的术语译法
+	Translate(ctx context.Context, text, source, target string, glossary map[string]string) (string, error)
+}
+
+// maxTranslationChunkRunes 单次翻译的最大字符数，长文档按段落切分后逐块翻译
+const maxTranslationChunkRunes = 1500
+
+// languageAliases 语言代码和中文名称到标准语言名的映射
+var languageAliases = map[string]string{
+	"en": "English", "english": "English", "英文": "English", "英语": "English",
+	"zh": "Chinese", "zh-cn": "Chinese", "chinese": "Chinese", "中文": "Chinese", "汉语": "Chinese",
+	"ja": "Japanese", "japanese": "Japanese", "日文": "Japanese", "日语": "Japanese",
+	"ko": "Korean", "korean": "Korean", "韩文": "Korean", "韩语": "Korean",
+	"fr": "French", "french": "French", "法文": "French", "法语": "French",
+	"de": "German", "german": "German", "德文": "German", "德语": "German",
+	"es": "Spanish", "spanish": "Spanish", "西班牙文": "Spanish", "西班牙语": "Spanish",
+	"ru": "Russian", "russian": "Russian", "俄文": "Russian", "俄语": "Russian",
+}
+
+// normalizeLanguage 把语言代码或名称统一为标准语言名，无法识别时原样返回
+func normalizeLanguage(lang string) string {
+	if name, ok := languageAliases[strings.ToLower(strings.TrimSpace(lang))]; ok {
+		return name
+	}
+	return strings.TrimSpace(lang)
+}
+
+// languageFromGoal 从任务目标中识别目标语言，如“翻译成英文”
+func languageFromGoal(goal string) string {
+	aliases := make([]string, 0, len(languageAliases))
+	for alias := range languageAliases {
+		if !isASCII(alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	// 优先匹配较长的名称，避免“西班牙语”被其他名称截断
+	sort.Slice(aliases, func(i, j int) bool { return len(aliases[i]) > len(aliases[j]) })
+
+	for _, marker := range []string{"翻译成", "翻译为", "译成", "译为"} {
+		idx := strings.Index(goal, marker)
+		if idx < 0 {
+			continue
+		}
+		rest := goal[idx+len(marker):]
+		for _, alias := range aliases {
+			if strings.HasPrefix(rest, alias) {
+				return languageAliases[alias]
+			}
+		}
+	}
+	return ""
+}
+
+// isASCII 字符串是否只包含ASCII字符
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// detectLanguage 按文字系统粗略识别文本的语言
+// 含假名时为日文，含谚文时为韩文，其余按汉字、西里尔字母和拉丁字母的占比判断
+func detectLanguage(text string) string {
+	var han, kana, hangul, cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case kana > 0:
+		return "Japanese"
+	case hangul > 0:
+		return "Korean"
+	case han == 0 && cyrillic == 0 && latin == 0:
+		return "unknown"
+	case han >= cyrillic && han*3 >= latin:
+		// 中文里夹杂英文术语很常见，一个汉字按三个字母计
+		return "Chinese"
+	case cyrillic > latin:
+		return "Russian"
+	default:
+		return "English"
+	}
+}
+
+// splitTranslationChunks 按段落把长文本切分为不超过maxRunes的块，超长段落再按句子切分
+func splitTranslationChunks(text string, maxRunes int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentRunes := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentRunes = 0
+		}
+	}
+	add := func(piece, sep string) {
+		n := len([]rune(piece))
+		if currentRunes > 0 && currentRunes+n > maxRunes {
+			flush()
+		}
+		if currentRunes > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+		currentRunes += n
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if len([]rune(paragraph)) <= maxRunes {
+			add(paragraph, "\n\n")
+			continue
+		}
+		// 超长段落按句子切分，句子本身超长时按字符数截断
+		flush()
+		for _, sentence := range splitSentences(paragraph) {
+			runes := []rune(sentence)
+			for len(runes) > maxRunes {
+				add(string(runes[:maxRunes]), "")
+				runes = runes[maxRunes:]
+			}
+			add(string(runes), "")
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// splitSentences 按中英文句末标点切分，保留标点
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		if strings.ContainsRune("。！？!?.；;\n", r) {
+			sentences = append(sentences, string(runes[start:i+1]))
+			start = i + 1
+		}
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// applyGlossary 按术语表替换原文中的术语，返回替换后的文本和命中的术语
+func applyGlossary(text string, glossary map[string]string) (string, []string) {
+	terms := glossaryTerms(glossary)
+	applied := make([]string, 0)
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			text = strings.ReplaceAll(text, term, glossary[term])
+			applied = append(applied, term)
+		}
+	}
+	return text, applied
+}
+
+// glossaryTerms 术语按长度倒序排列，较长的术语优先
+func glossaryTerms(glossary map[string]string) []string {
+	terms := make([]string, 0, len(glossary))
+	for term := range glossary {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+	return terms
+}
+
+// translateWithModel 使用写作Agent的模型翻译
+func (w *WriterAgent) translateWithModel(ctx context.Context, text, source, target string, glossary map[string]string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("请把下面的%s文本翻译为%s。保持原文的段落结构和Markdown格式，只输出译文。\n", source, target))
+	if len(glossary) > 0 {
+		sb.WriteString("必须使用以下术语译法：\n")
+		for _, term := range glossaryTerms(glossary) {
+			sb.WriteString(fmt.Sprintf("- %s => %s\n", term, glossary[term]))
+		}
+	}
+	sb.WriteString("\n原文：\n")
+	sb.WriteString(text)
+
+	output, err := w.Generate(ctx, "你是专业翻译，译文准确、通顺，符合目标语言的表达习惯。", sb.String())
+	if err != nil {
+		return "", err
+	}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return "", fmt.Errorf("model returned empty translation")
+	}
+	return output, nil
+}
+
+// translate 翻译一段文本，优先使用设置的翻译服务，其次使用模型
+func (w *WriterAgent) translate(ctx context.Context, text, source, target string, glossary map[string]string) (string, error) {
+	if w.translator != nil {
+		return w.translator.Translate(ctx, text, source, target, glossary)
+	}
+	return w.translateWithModel(ctx, text, source, target, glossary)
+}
+
+// translateContent 翻译内容
+// 自动识别原文语言，长文档按段落分块逐块翻译，术语表中的术语按指定译法翻译；
+// 没有翻译服务和模型或调用失败时，只按术语表替换并标记译文
+func (w *WriterAgent) translateContent(ctx context.Context, goal string, requirements interface{}) (interface{}, error) {
+	// 提取原文和目标语言
+	content := w.getContentFromRequirements(requirements)
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("content is required for translation")
+	}
+	targetLang := w.getTargetLanguage(goal, requirements)
+	sourceLang := w.getSourceLanguage(requirements)
+	if sourceLang == "" {
+		sourceLang = detectLanguage(content)
+	}
+	glossary := w.getGlossaryFromRequirements(requirements)
+
+	output := map[string]interface{}{
+		"content_type": "translation",
+		"original":     content,
+		"source_lang":  sourceLang,
+		"target_lang":  targetLang,
+	}
+
+	// 原文已是目标语言时不翻译
+	if strings.EqualFold(sourceLang, targetLang) {
+		output["translation"] = content
+		output["chunks"] = 0
+		output["generated_by"] = "none"
+		output["word_count"] = w.countWords(map[string]interface{}{"content": content})
+		return output, nil
+	}
+
+	chunks := splitTranslationChunks(content, maxTranslationChunkRunes)
+	var translation string
+	var fallbackReason string
+	if w.translator != nil || w.Model != nil {
+		translated := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			if len(chunks) > 1 {
+				events.Thought(ctx, w.Type, fmt.Sprintf("翻译第%d/%d块", i+1, len(chunks)))
+			}
+			part, err := w.translate(ctx, chunk, sourceLang, targetLang, glossary)
+			if err != nil {
+				fallbackReason = fmt.Sprintf("chunk %d: %v", i+1, err)
+				events.Thought(ctx, w.Type, "翻译失败，改用术语替换："+err.Error())
+				break
+			}
+			translated = append(translated, part)
+		}
+		if fallbackReason == "" {
+			translation = strings.Join(translated, "\n\n")
+		}
+	}
+
+	if translation == "" {
+		replaced, _ := applyGlossary(content, glossary)
+		translation = w.mockTranslate(replaced, targetLang)
+		output["generated_by"] = "template"
+		if fallbackReason != "" {
+			output["fallback_reason"] = fallbackReason
+		}
+	} else if w.translator != nil {
+		output["generated_by"] = "translator"
+	} else {
+		output["generated_by"] = "llm"
+	}
+
+	// 记录原文中出现的术语
+	_, glossaryApplied := applyGlossary(content, glossary)
+	output["translation"] = translation
+	output["chunks"] = len(chunks)
+	output["glossary_applied"] = glossaryApplied
+	output["word_count"] = w.countWords(map[string]interface{}{"content": translation})
+	return output, nil
+}
+
+// SetTranslator 设置翻译服务（如外部翻译API），未设置时使用模型翻译
+func (w *WriterAgent) SetTranslator(translator Translator) {
+	w.translator = translator
+}
+
+func (w *WriterAgent) getTargetLanguage(goal string, requirements interface{}) string {
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		if lang, ok := reqMap["target_lang"].(string); ok && lang != "" {
+			return normalizeLanguage(lang)
+		}
+	}
+	if lang := languageFromGoal(goal); lang != "" {
+		return lang
+	}
+	return "English"
+}
+
+func (w *WriterAgent) getSourceLanguage(requirements interface{}) string {
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		if lang, ok := reqMap["source_lang"].(string); ok && lang != "" && lang != "auto" {
+			return normalizeLanguage(lang)
+		}
+	}
+	return ""
+}
+
+// getGlossaryFromRequirements 读取术语表（原文术语 -> 译法）
+func (w *WriterAgent) getGlossaryFromRequirements(requirements interface{}) map[string]string {
+	glossary := make(map[string]string)
+	if reqMap, ok := requirements.(map[string]interface{}); ok {
+		switch terms := reqMap["glossary"].(type) {
+		case map[string]string:
+			for term, translation := range terms {
+				glossary[term] = translation
+			}
+		case map[string]interface{}:
+			for term, translation := range terms {
+				if s, ok := translation.(string); ok && term != "" {
+					glossary[term] = s
+				}
+			}
+		}
+	}
+	return glossary
+}
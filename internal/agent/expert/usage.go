@@ -0,0 +1,62 @@
+package expert
+
+import (
+	"context"
+	"errors"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/task"
+)
+
+// ExecuteWithUsage 执行任务并把期间的模型token用量附加到TaskResult元数据
+// Agent设置了资源限制时，在执行时长、模型token和工具调用次数上按限制约束本次任务，
+// 触发限制导致降级时把触发的限制记录在元数据resource_limits中
+// ctx被取消时不再发起新的模型和工具调用，执行中被取消的任务即使Agent返回了降级结果也返回ctx的错误
+func ExecuteWithUsage(ctx context.Context, agent ExpertAgent, taskObj *task.Task) (*task.TaskResult, error) {
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
+	}
+	collector := llm.NewUsageCollector(nil, "task:"+taskObj.Type)
+	ctx = llm.WithUsageCollector(ctx, collector)
+
+	var budget *taskBudget
+	if limited, ok := agent.(interface{ ResourceLimits() ResourceLimits }); ok && !limited.ResourceLimits().IsZero() {
+		budget = &taskBudget{limits: limited.ResourceLimits(), usage: collector}
+		ctx = context.WithValue(ctx, budgetKey{}, budget)
+		if budget.limits.MaxDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, budget.limits.MaxDuration)
+			defer cancel()
+		}
+	}
+
+	result, err := agent.Execute(ctx, taskObj)
+	if err == nil {
+		err = checkCancelled(ctx)
+	}
+	if budget != nil && budget.limits.MaxDuration > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		budget.trigger(limitMaxDuration)
+	}
+	if result != nil {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["usage"] = collector.Metadata()
+		if budget != nil {
+			if limits := budget.metadata(); limits != nil {
+				result.Metadata["resource_limits"] = limits
+			}
+		}
+	}
+
+	return result, err
+}
+
+// checkCancelled 任务被取消（而非超时）时返回ctx的错误
+// 超时由资源限制按降级处理（见checkDeadline），取消则应尽快停止执行
+func checkCancelled(ctx context.Context) error {
+	if err := ctx.Err(); errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
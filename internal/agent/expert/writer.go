@@ -2,11 +2,13 @@ package expert
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
+	"ai-agent-assistant/internal/agent/events"
 	"ai-agent-assistant/internal/task"
 )
 
@@ -16,6 +18,7 @@ type WriterAgent struct {
 	writingStyles []string
 	maxLength     int
 	templates     map[string]string
+	translator    Translator // 翻译服务，为nil时使用模型翻译
 }
 
 // NewWriterAgent 创建写作Agent
@@ -87,22 +90,32 @@ func (w *WriterAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.Ta
 	// 解析任务目标
 	writingGoal := taskObj.Goal
 
+	// 检索之前为同一用户或项目撰写的相关内容，生成时作为参考
+	ctx, recalled := w.RecallMemories(ctx, taskObj)
+
 	// 根据任务类型选择写作方法
 	var output interface{}
 	var err error
 
-	if strings.Contains(writingGoal, "文章") || strings.Contains(writingGoal, "撰写") {
+	// 翻译优先判断，避免“翻译这篇文章”被当作写文章
+	if strings.Contains(writingGoal, "翻译") {
+		events.Thought(ctx, w.Type, "翻译内容："+writingGoal)
+		output, err = w.translateContent(ctx, writingGoal, taskObj.Requirements)
+	} else if strings.Contains(writingGoal, "文章") || strings.Contains(writingGoal, "撰写") {
+		events.Thought(ctx, w.Type, "撰写文章："+writingGoal)
 		output, err = w.writeArticle(ctx, writingGoal, taskObj.Requirements)
 	} else if strings.Contains(writingGoal, "报告") || strings.Contains(writingGoal, "总结") {
+		events.Thought(ctx, w.Type, "撰写报告："+writingGoal)
 		output, err = w.writeReport(ctx, writingGoal, taskObj.Requirements)
 	} else if strings.Contains(writingGoal, "摘要") || strings.Contains(writingGoal, "总结") {
+		events.Thought(ctx, w.Type, "生成摘要："+writingGoal)
 		output, err = w.writeSummary(ctx, taskObj.Requirements)
 	} else if strings.Contains(writingGoal, "润色") || strings.Contains(writingGoal, "修改") {
+		events.Thought(ctx, w.Type, "润色内容："+writingGoal)
 		output, err = w.editContent(ctx, taskObj.Requirements)
-	} else if strings.Contains(writingGoal, "翻译") {
-		output, err = w.translateContent(ctx, taskObj.Requirements)
 	} else {
 		// 默认执行文章写作
+		events.Thought(ctx, w.Type, "撰写文章："+writingGoal)
 		output, err = w.writeArticle(ctx, writingGoal, taskObj.Requirements)
 	}
 
@@ -111,6 +124,12 @@ func (w *WriterAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.Ta
 		return w.createErrorResult(taskObj, err, startTime), err
 	}
 
+	if outMap, ok := output.(map[string]interface{}); ok {
+		if content, _ := outMap["content"].(string); content != "" {
+			w.RememberOutcome(ctx, taskObj, fmt.Sprintf("撰写「%s」：%s", goalSummary(writingGoal), flattenText(content, 120)))
+		}
+	}
+
 	w.UpdateStatus("idle")
 	return &task.TaskResult{
 		TaskID:    taskObj.ID,
@@ -121,10 +140,11 @@ func (w *WriterAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.Ta
 		Error:     "",
 		Duration:  time.Since(startTime),
 		Metadata: map[string]interface{}{
-			"agent_type":     "writer",
-			"writing_style":  w.getStyleFromRequirements(taskObj.Requirements),
-			"word_count":     w.countWords(output),
-			"char_count":     w.countChars(output),
+			"agent_type":        "writer",
+			"writing_style":     w.getStyleFromRequirements(taskObj.Requirements),
+			"word_count":        w.countWords(output),
+			"char_count":        w.countChars(output),
+			"memories_recalled": len(recalled),
 		},
 		Timestamp: time.Now(),
 		AgentUsed: w.Name,
@@ -132,6 +152,7 @@ func (w *WriterAgent) Execute(ctx context.Context, taskObj *task.Task) (*task.Ta
 }
 
 // writeArticle 撰写文章
+// 设置了模型时由模型按风格、篇幅和关键词生成，模型不可用或调用失败时使用模板生成
 func (w *WriterAgent) writeArticle(ctx context.Context, topic string, requirements interface{}) (interface{}, error) {
 	// 提取要求
 	style := w.getStyleFromRequirements(requirements)
@@ -141,59 +162,117 @@ func (w *WriterAgent) writeArticle(ctx context.Context, topic string, requiremen
 	// 生成大纲
 	outline := w.generateOutline(topic, style)
 
-	// 生成内容
-	content := w.generateContent(topic, outline, style, length, keywords)
+	var article, fallbackReason string
+	if w.Model != nil {
+		prompt := fmt.Sprintf("请以「%s」为题撰写一篇Markdown格式的文章，以一级标题开头。\n%s\n参考大纲：\n- %s",
+			topic, w.constraintText(style, length, keywords), strings.Join(outline, "\n- "))
+		generated, err := w.compose(ctx, prompt)
+		if err == nil {
+			article = generated
+		} else {
+			fallbackReason = err.Error()
+		}
+	}
+
+	if article == "" {
+		// 生成内容
+		content := w.generateContent(topic, outline, style, length, keywords)
 
-	// 格式化文章
-	article := w.formatArticle(topic, outline, content, style)
+		// 格式化文章
+		article = w.formatArticle(topic, outline, content, style)
+	}
 
-	return map[string]interface{}{
+	return w.withGeneration(map[string]interface{}{
 		"content_type": "article",
 		"title":        topic,
 		"content":      article,
 		"outline":      outline,
 		"style":        style,
-		"word_count":   w.countWords(article),
-	}, nil
+		"word_count":   w.countWords(map[string]interface{}{"content": article}),
+	}, article, keywords, fallbackReason), nil
 }
 
 // writeReport 撰写报告
+// 设置了模型时由模型根据数据撰写执行摘要、分析和建议，否则使用模板生成
 func (w *WriterAgent) writeReport(ctx context.Context, title string, requirements interface{}) (interface{}, error) {
 	// 提取数据
 	data := w.getDataFromRequirements(requirements)
+	style := w.getStyleFromRequirements(requirements)
+	length := w.getLengthFromRequirements(requirements)
+	keywords := w.getKeywordsFromRequirements(requirements)
 
-	// 生成报告各部分
-	executiveSummary := w.generateExecutiveSummary(data)
-	analysis := w.generateAnalysis(data)
-	recommendations := w.generateRecommendations(data)
+	var report, executiveSummary, analysis, recommendations, fallbackReason string
+	if w.Model != nil {
+		dataJSON, _ := json.MarshalIndent(data, "", "  ")
+		prompt := fmt.Sprintf("请根据以下数据撰写「%s」报告，使用Markdown格式，以「# %s 报告」开头，"+
+			"并依次包含「## 执行摘要」「## 分析」「## 建议」三个二级标题。\n%s\n数据：\n%s",
+			title, title, w.constraintText(style, length, keywords), dataJSON)
+		generated, err := w.compose(ctx, prompt)
+		if err == nil {
+			report = generated
+			executiveSummary = markdownSection(report, "执行摘要")
+			analysis = markdownSection(report, "分析")
+			recommendations = markdownSection(report, "建议")
+		} else {
+			fallbackReason = err.Error()
+		}
+	}
 
-	// 格式化报告
-	report := fmt.Sprintf(w.templates["report"],
-		title,
-		executiveSummary,
-		analysis,
-		recommendations,
-	)
+	if report == "" {
+		// 生成报告各部分
+		executiveSummary = w.generateExecutiveSummary(data)
+		analysis = w.generateAnalysis(data)
+		recommendations = w.generateRecommendations(data)
+
+		// 格式化报告
+		report = fmt.Sprintf(w.templates["report"],
+			title,
+			executiveSummary,
+			analysis,
+			recommendations,
+		)
+	}
 
-	return map[string]interface{}{
+	return w.withGeneration(map[string]interface{}{
 		"content_type":      "report",
 		"title":             title,
 		"content":           report,
 		"executive_summary": executiveSummary,
 		"analysis":          analysis,
 		"recommendations":   recommendations,
-		"word_count":        w.countWords(report),
-	}, nil
+		"word_count":        w.countWords(map[string]interface{}{"content": report}),
+	}, report, keywords, fallbackReason), nil
 }
 
 // writeSummary 撰写摘要
+// 设置了模型时由模型概括原文，否则截取原文开头
 func (w *WriterAgent) writeSummary(ctx context.Context, requirements interface{}) (interface{}, error) {
 	// 提取原文
 	content := w.getContentFromRequirements(requirements)
 	title := w.getTitleFromRequirements(requirements)
+	style := w.getStyleFromRequirements(requirements)
+	keywords := w.getKeywordsFromRequirements(requirements)
+	length := defaultSummaryLength
+	if requested, ok := w.requestedLength(requirements); ok {
+		length = requested
+	}
+
+	var summary, fallbackReason string
+	if w.Model != nil && strings.TrimSpace(content) != "" {
+		prompt := fmt.Sprintf("请概括以下内容，只输出摘要正文，不要标题。\n%s\n原文：\n%s",
+			w.constraintText(style, length, keywords), content)
+		generated, err := w.compose(ctx, prompt)
+		if err == nil {
+			summary = generated
+		} else {
+			fallbackReason = err.Error()
+		}
+	}
 
-	// 生成摘要
-	summary := w.generateSummary(content)
+	if summary == "" {
+		// 生成摘要
+		summary = w.generateSummary(content)
+	}
 
 	// 格式化摘要
 	formattedSummary := fmt.Sprintf(w.templates["summary"],
@@ -201,13 +280,111 @@ func (w *WriterAgent) writeSummary(ctx context.Context, requirements interface{}
 		summary,
 	)
 
-	return map[string]interface{}{
+	return w.withGeneration(map[string]interface{}{
 		"content_type": "summary",
 		"title":        title,
 		"summary":      summary,
 		"content":      formattedSummary,
-		"word_count":   w.countWords(formattedSummary),
-	}, nil
+		"word_count":   w.countWords(map[string]interface{}{"content": formattedSummary}),
+	}, summary, keywords, fallbackReason), nil
+}
+
+// defaultSummaryLength 未指定篇幅时摘要的目标字数
+const defaultSummaryLength = 200
+
+// writerSystemPrompt 写作Agent的系统提示词
+const writerSystemPrompt = "你是专业的中文内容创作专家，擅长撰写结构清晰、论据充分的文章和报告。" +
+	"严格遵守用户给出的风格、篇幅和关键词要求，直接输出正文，不要输出额外的说明。"
+
+// styleDescriptions 写作风格对应的提示词描述
+var styleDescriptions = map[string]string{
+	"formal":       "正式、严谨",
+	"casual":       "轻松、口语化",
+	"professional": "专业、客观，使用行业术语",
+	"creative":     "富有创意、生动形象",
+	"academic":     "学术化，论证严密并注明依据",
+}
+
+// constraintText 把风格、篇幅和关键词要求组织为提示词
+func (w *WriterAgent) constraintText(style string, length int, keywords []string) string {
+	description, ok := styleDescriptions[style]
+	if !ok {
+		description = style
+	}
+	if w.maxLength > 0 && length > w.maxLength {
+		length = w.maxLength
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("要求：\n- 风格：%s\n- 篇幅：约%d字\n", description, length))
+	if len(keywords) > 0 {
+		sb.WriteString(fmt.Sprintf("- 必须包含以下关键词：%s\n", strings.Join(keywords, "、")))
+	}
+	return sb.String()
+}
+
+// compose 调用模型生成内容，去掉模型可能包裹的代码块
+func (w *WriterAgent) compose(ctx context.Context, prompt string) (string, error) {
+	output, err := w.Generate(ctx, writerSystemPrompt, prompt)
+	if err != nil {
+		events.Thought(ctx, w.Type, "模型生成失败，改用模板生成："+err.Error())
+		return "", err
+	}
+
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "```") {
+		output = strings.TrimPrefix(output, "```markdown")
+		output = strings.TrimPrefix(output, "```")
+		output = strings.TrimSuffix(strings.TrimSpace(output), "```")
+		output = strings.TrimSpace(output)
+	}
+	if output == "" {
+		events.Thought(ctx, w.Type, "模型返回空内容，改用模板生成")
+		return "", fmt.Errorf("model returned empty content")
+	}
+	return output, nil
+}
+
+// withGeneration 在输出中记录生成方式和缺失的关键词
+// generated_by为llm或template，模型调用失败时fallback_reason为失败原因
+func (w *WriterAgent) withGeneration(output map[string]interface{}, text string, keywords []string, fallbackReason string) map[string]interface{} {
+	output["generated_by"] = "template"
+	if w.Model != nil && fallbackReason == "" && text != "" {
+		output["generated_by"] = "llm"
+	}
+	if fallbackReason != "" {
+		output["fallback_reason"] = fallbackReason
+	}
+
+	missing := make([]string, 0)
+	for _, keyword := range keywords {
+		if !strings.Contains(strings.ToLower(text), strings.ToLower(keyword)) {
+			missing = append(missing, keyword)
+		}
+	}
+	if len(missing) > 0 {
+		output["missing_keywords"] = missing
+	}
+	return output
+}
+
+// markdownSection 提取Markdown中指定二级标题下的内容
+func markdownSection(doc, heading string) string {
+	var section []string
+	inSection := false
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if inSection {
+				break
+			}
+			inSection = strings.TrimSpace(strings.TrimPrefix(line, "## ")) == heading
+			continue
+		}
+		if inSection {
+			section = append(section, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(section, "\n"))
 }
 
 // editContent 润色内容
@@ -240,24 +417,6 @@ func (w *WriterAgent) editContent(ctx context.Context, requirements interface{})
 	}, nil
 }
 
-// translateContent 翻译内容
-func (w *WriterAgent) translateContent(ctx context.Context, requirements interface{}) (interface{}, error) {
-	// 提取原文和目标语言
-	content := w.getContentFromRequirements(requirements)
-	targetLang := w.getTargetLanguage(requirements)
-
-	// 简化实现：这里只是标记翻译，实际应该调用翻译API
-	translation := w.mockTranslate(content, targetLang)
-
-	return map[string]interface{}{
-		"content_type":  "translation",
-		"original":      content,
-		"translation":   translation,
-		"target_lang":   targetLang,
-		"word_count":    w.countWords(translation),
-	}, nil
-}
-
 // generateOutline 生成大纲
 func (w *WriterAgent) generateOutline(topic string, style string) []string {
 	outline := []string{
@@ -452,7 +611,7 @@ func (w *WriterAgent) generalEdit(content string) (string, []string) {
 	return edited, changes
 }
 
-// mockTranslate 模拟翻译
+// mockTranslate 离线翻译占位：标记目标语言后返回原文
 func (w *WriterAgent) mockTranslate(content string, targetLang string) string {
 	// 简化实现：添加翻译标记
 	return fmt.Sprintf("[%s翻译] %s", targetLang, content)
@@ -470,18 +629,47 @@ func (w *WriterAgent) getStyleFromRequirements(requirements interface{}) string
 }
 
 func (w *WriterAgent) getLengthFromRequirements(requirements interface{}) int {
+	if length, ok := w.requestedLength(requirements); ok {
+		return length
+	}
+	return 1000 // 默认长度
+}
+
+// requestedLength 读取要求中的篇幅，兼容JSON解码得到的float64
+func (w *WriterAgent) requestedLength(requirements interface{}) (int, bool) {
 	if reqMap, ok := requirements.(map[string]interface{}); ok {
-		if length, ok := reqMap["length"].(int); ok {
-			return length
+		switch length := reqMap["length"].(type) {
+		case int:
+			return length, length > 0
+		case float64:
+			return int(length), length > 0
 		}
 	}
-	return 1000 // 默认长度
+	return 0, false
 }
 
+// getKeywordsFromRequirements 读取关键词，支持字符串数组、JSON数组和逗号分隔的字符串
 func (w *WriterAgent) getKeywordsFromRequirements(requirements interface{}) []string {
 	if reqMap, ok := requirements.(map[string]interface{}); ok {
-		if keywords, ok := reqMap["keywords"].([]string); ok {
+		switch keywords := reqMap["keywords"].(type) {
+		case []string:
 			return keywords
+		case []interface{}:
+			result := make([]string, 0, len(keywords))
+			for _, keyword := range keywords {
+				if s, ok := keyword.(string); ok && s != "" {
+					result = append(result, s)
+				}
+			}
+			return result
+		case string:
+			result := make([]string, 0)
+			for _, keyword := range strings.FieldsFunc(keywords, func(r rune) bool { return r == ',' || r == '，' || r == '、' }) {
+				if keyword = strings.TrimSpace(keyword); keyword != "" {
+					result = append(result, keyword)
+				}
+			}
+			return result
 		}
 	}
 	return []string{}
@@ -523,15 +711,6 @@ func (w *WriterAgent) getEditTypeFromRequirements(requirements interface{}) stri
 	return "general"
 }
 
-func (w *WriterAgent) getTargetLanguage(requirements interface{}) string {
-	if reqMap, ok := requirements.(map[string]interface{}); ok {
-		if lang, ok := reqMap["target_lang"].(string); ok {
-			return lang
-		}
-	}
-	return "English"
-}
-
 // countWords 统计字数
 func (w *WriterAgent) countWords(content interface{}) int {
 	if contentMap, ok := content.(map[string]interface{}); ok {
@@ -0,0 +1,112 @@
+package persona
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Persona Agent人设
+// 声明专家Agent的名称、描述、系统提示词、能力、默认模型和允许使用的工具，未设置的字段保留Agent的内置值
+type Persona struct {
+	Agent        string   `yaml:"agent"`         // 应用到的Agent类型，如 researcher、writer（必填）
+	Name         string   `yaml:"name"`          // 显示名称
+	Description  string   `yaml:"description"`   // 描述
+	SystemPrompt string   `yaml:"system_prompt"` // 系统提示词，生成内容时置于各任务提示词之前
+	Capabilities []string `yaml:"capabilities"`  // 能力列表，替换内置能力
+	Model        string   `yaml:"model"`         // 默认模型，为空时使用 agent.default_model
+	Tools        []string `yaml:"tools"`         // 允许调用的工具，为空表示不限制
+}
+
+// Load 加载目录中的人设文件（*.yaml、*.yml），每个文件声明一个Agent的人设
+// 返回按Agent类型索引的人设；文件包含未知字段、缺少agent或同一Agent重复声明时返回错误
+func Load(dir string) (map[string]*Persona, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("personas dir unavailable: %w", err)
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid personas dir %q: %w", dir, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	personas := make(map[string]*Persona, len(files))
+	sources := make(map[string]string, len(files))
+	for _, file := range files {
+		p, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := sources[p.Agent]; ok {
+			return nil, fmt.Errorf("persona for agent %q declared in both %s and %s", p.Agent, prev, file)
+		}
+		personas[p.Agent] = p
+		sources[p.Agent] = file
+	}
+	return personas, nil
+}
+
+// LoadFile 加载单个人设文件
+func LoadFile(path string) (*Persona, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persona: %w", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse 解析并校验人设
+func Parse(data []byte) (*Persona, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var p Persona
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid persona: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate 校验人设
+func (p *Persona) Validate() error {
+	p.Agent = strings.TrimSpace(p.Agent)
+	if p.Agent == "" {
+		return fmt.Errorf("invalid persona: agent is required")
+	}
+	for _, list := range [][]string{p.Capabilities, p.Tools} {
+		for _, item := range list {
+			if strings.TrimSpace(item) == "" {
+				return fmt.Errorf("invalid persona for %s: empty capability or tool name", p.Agent)
+			}
+		}
+	}
+	return nil
+}
+
+// AllowsTool 人设是否允许调用指定工具，未声明工具列表时不限制
+func (p *Persona) AllowsTool(name string) bool {
+	if p == nil || len(p.Tools) == 0 {
+		return true
+	}
+	for _, tool := range p.Tools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package persona
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("bundled personas", func(t *testing.T) {
+		personas, err := Load("../../../personas")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		for _, agent := range []string{"researcher", "analyst", "writer", "planner", "coder"} {
+			if personas[agent] == nil {
+				t.Errorf("missing persona for %s", agent)
+			}
+		}
+		coder := personas["coder"]
+		if !coder.AllowsTool("git") || coder.AllowsTool("file_ops") {
+			t.Errorf("coder tools = %v, want only repo tools", coder.Tools)
+		}
+	})
+
+	t.Run("parses all fields", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "writer.yml", `
+agent: writer
+name: 撰稿人
+system_prompt: 简洁
+capabilities: [article_writing]
+model: qwen
+tools: [file_ops]
+`)
+		personas, err := Load(dir)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		p := personas["writer"]
+		if p == nil || p.Name != "撰稿人" || p.SystemPrompt != "简洁" || p.Model != "qwen" || len(p.Capabilities) != 1 {
+			t.Fatalf("unexpected persona: %+v", p)
+		}
+	})
+
+	t.Run("rejects invalid personas", func(t *testing.T) {
+		cases := map[string][]string{
+			"missing agent":  {"name: x\n"},
+			"unknown field":  {"agent: writer\nprompt: x\n"},
+			"empty tool":     {"agent: writer\ntools: ['']\n"},
+			"duplicate type": {"agent: writer\n", "agent: writer\n"},
+		}
+		for name, files := range cases {
+			dir := t.TempDir()
+			for i, content := range files {
+				writeFile(t, dir, string(rune('a'+i))+".yaml", content)
+			}
+			if _, err := Load(dir); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+		if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.Contains(err.Error(), "unavailable") {
+			t.Errorf("missing dir: got %v", err)
+		}
+	})
+}
+
+func TestAllowsTool(t *testing.T) {
+	var unset *Persona
+	if !unset.AllowsTool("git") || !(&Persona{}).AllowsTool("git") {
+		t.Error("persona without tools should allow all tools")
+	}
+	p := &Persona{Tools: []string{"git"}}
+	if !p.AllowsTool("git") || p.AllowsTool("sandbox_exec") {
+		t.Error("allowlist not enforced")
+	}
+}
@@ -0,0 +1,193 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code 错误码，客户端按错误码而不是错误信息判断错误类型
+type Code string
+
+const (
+	CodeValidation           Code = "validation_error"       // 请求参数不合法
+	CodeUnauthenticated      Code = "unauthenticated"        // 未认证或凭证无效
+	CodeForbidden            Code = "forbidden"              // 无权限
+	CodeNotFound             Code = "not_found"              // 资源不存在
+	CodeConflict             Code = "conflict"               // 资源状态冲突
+	CodePayloadTooLarge      Code = "payload_too_large"      // 请求体或文件过大
+	CodeUnsupportedMediaType Code = "unsupported_media_type" // 文件类型不支持
+	CodeUnprocessable        Code = "unprocessable_entity"   // 请求格式正确但无法处理
+	CodeRateLimited          Code = "rate_limited"           // 超出限流
+	CodeQuotaExceeded        Code = "quota_exceeded"         // 模型服务商或租户配额用尽
+	CodeProviderError        Code = "provider_error"         // 模型服务商调用失败
+	CodeUnavailable          Code = "service_unavailable"    // 功能未启用或依赖不可用
+	CodeTimeout              Code = "timeout"                // 处理超时
+	CodeInternal             Code = "internal_error"         // 服务内部错误
+)
+
+// statusByCode 错误码对应的HTTP状态码
+var statusByCode = map[Code]int{
+	CodeValidation:           http.StatusBadRequest,
+	CodeUnauthenticated:      http.StatusUnauthorized,
+	CodeForbidden:            http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeConflict:             http.StatusConflict,
+	CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodeUnprocessable:        http.StatusUnprocessableEntity,
+	CodeRateLimited:          http.StatusTooManyRequests,
+	CodeQuotaExceeded:        http.StatusTooManyRequests,
+	CodeProviderError:        http.StatusBadGateway,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+	CodeTimeout:              http.StatusGatewayTimeout,
+	CodeInternal:             http.StatusInternalServerError,
+}
+
+// Status 错误码对应的HTTP状态码
+func (c Code) Status() int {
+	if status, ok := statusByCode[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error 带错误码的错误
+type Error struct {
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error // 原始错误，用于errors.Is/As
+}
+
+// New 创建错误
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap 包装已有错误，错误信息沿用原始错误
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// Validation 参数校验错误（请求体绑定失败、参数缺失或取值不合法）
+func Validation(err error) *Error {
+	return Wrap(CodeValidation, err)
+}
+
+// InvalidBody 请求体解析失败
+func InvalidBody(err error) *Error {
+	return New(CodeValidation, "Invalid request body").WithDetails(err.Error())
+}
+
+// Annotate 为内部错误指定对外的错误信息，错误码按原始错误映射，原始错误信息放入details
+func Annotate(err error, message string) *Error {
+	return &Error{Code: From(err).Code, Message: message, Details: err.Error(), Err: err}
+}
+
+// WithDetails 附加错误详情
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classifier 将内部错误映射为错误码，无法识别时返回false
+type Classifier func(err error) (Code, bool)
+
+var (
+	registryMu  sync.RWMutex
+	sentinels   []sentinel
+	classifiers []Classifier
+)
+
+// sentinel 哨兵错误与错误码的映射
+type sentinel struct {
+	target error
+	code   Code
+}
+
+// Register 注册哨兵错误的错误码，如 task.ErrTaskNotFound -> not_found
+func Register(target error, code Code) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	sentinels = append(sentinels, sentinel{target: target, code: code})
+}
+
+// RegisterClassifier 注册错误分类函数，用于按错误类型映射（如模型服务商错误）
+func RegisterClassifier(classifier Classifier) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	classifiers = append(classifiers, classifier)
+}
+
+// From 将任意错误转换为带错误码的错误
+// 依次匹配：*Error、注册的哨兵错误、注册的分类函数、超时和请求体过大，其余为internal_error
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, s := range sentinels {
+		if errors.Is(err, s.target) {
+			return Wrap(s.code, err)
+		}
+	}
+	for _, classify := range classifiers {
+		if code, ok := classify(err); ok {
+			return Wrap(code, err)
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(CodeTimeout, err)
+	case errors.As(err, &maxBytesErr):
+		return Wrap(CodePayloadTooLarge, err)
+	}
+	return Wrap(CodeInternal, err)
+}
+
+// Body 统一的错误响应体：
+//
+//	{"error": {"code": "not_found", "message": "...", "details": {...}, "request_id": "..."}}
+//
+// ctx可以是gin.Context，也可以是请求的context（流式接口的error事件）
+func Body(ctx context.Context, err error) gin.H {
+	apiErr := From(err)
+	body := gin.H{
+		"code":    apiErr.Code,
+		"message": apiErr.Message,
+	}
+	if apiErr.Details != nil {
+		body["details"] = apiErr.Details
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		body["request_id"] = requestID
+	}
+	return gin.H{"error": body}
+}
+
+// Respond 按错误码返回对应状态码和统一错误响应体
+func Respond(c *gin.Context, err error) {
+	c.JSON(From(err).Code.Status(), Body(c, err))
+}
+
+// Abort 同Respond，并中止后续处理，供中间件使用
+func Abort(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(From(err).Code.Status(), Body(c, err))
+}
@@ -0,0 +1,88 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFrom 测试内部错误到错误码的映射
+func TestFrom(t *testing.T) {
+	errWidgetNotFound := errors.New("widget not found")
+	Register(errWidgetNotFound, CodeNotFound)
+
+	type quotaError struct{ error }
+	RegisterClassifier(func(err error) (Code, bool) {
+		var q quotaError
+		if errors.As(err, &q) {
+			return CodeQuotaExceeded, true
+		}
+		return "", false
+	})
+
+	cases := []struct {
+		err    error
+		code   Code
+		status int
+	}{
+		{fmt.Errorf("%w: w-1", errWidgetNotFound), CodeNotFound, http.StatusNotFound},
+		{fmt.Errorf("chat failed: %w", quotaError{errors.New("429")}), CodeQuotaExceeded, http.StatusTooManyRequests},
+		{fmt.Errorf("search: %w", context.DeadlineExceeded), CodeTimeout, http.StatusGatewayTimeout},
+		{Validation(errors.New("message is required")), CodeValidation, http.StatusBadRequest},
+		{Annotate(errWidgetNotFound, "Failed to load widget"), CodeNotFound, http.StatusNotFound},
+		{errors.New("boom"), CodeInternal, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		got := From(tc.err)
+		if got.Code != tc.code || got.Code.Status() != tc.status {
+			t.Errorf("From(%v) = %s/%d, want %s/%d", tc.err, got.Code, got.Code.Status(), tc.code, tc.status)
+		}
+	}
+	if got := From(Annotate(errWidgetNotFound, "Failed to load widget")); got.Message != "Failed to load widget" || got.Details != "widget not found" {
+		t.Errorf("Annotate should keep original error in details: %+v", got)
+	}
+}
+
+// TestRespond 测试统一错误响应体和请求ID
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		Respond(c, New(CodeNotFound, "Task not found").WithDetails(gin.H{"task_id": c.Param("id")}))
+	})
+
+	for _, incoming := range []string{"req-123", "bad id\n"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/t-1", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body struct {
+			Error struct {
+				Code      Code              `json:"code"`
+				Message   string            `json:"message"`
+				Details   map[string]string `json:"details"`
+				RequestID string            `json:"request_id"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body %s: %v", w.Body, err)
+		}
+		if w.Code != http.StatusNotFound || body.Error.Code != CodeNotFound || body.Error.Details["task_id"] != "t-1" {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+		}
+		if body.Error.RequestID == "" || body.Error.RequestID != w.Header().Get(RequestIDHeader) {
+			t.Errorf("request_id %q should match header %q", body.Error.RequestID, w.Header().Get(RequestIDHeader))
+		}
+		if incoming == "req-123" && body.Error.RequestID != incoming {
+			t.Errorf("valid client request id should be kept, got %q", body.Error.RequestID)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package apierror
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求ID的请求头/响应头
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey 请求ID在gin.Context和请求context中的键
+type requestIDKey struct{}
+
+// ginRequestIDKey gin.Context中请求ID的键
+const ginRequestIDKey = "request_id"
+
+// validRequestID 客户端传入的请求ID只接受安全字符，避免注入日志
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID 请求ID中间件：沿用客户端传入的X-Request-ID，否则生成一个，并写入响应头
+// 错误响应中的request_id与该值一致，便于按请求排查日志
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, requestID := ContextWithRequestID(c.Request.Context(), c.GetHeader(RequestIDHeader))
+		c.Set(ginRequestIDKey, requestID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// ContextWithRequestID 沿用客户端传入的合法请求ID，否则生成一个，放入请求context
+// 供gin以外的入口（如gRPC）使用，返回新的context和请求ID
+func ContextWithRequestID(ctx context.Context, requestID string) (context.Context, string) {
+	if !validRequestID.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID), requestID
+}
+
+// RequestIDFromContext 获取当前请求的请求ID，可传入gin.Context或请求的context，未挂载中间件时为空
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		return c.GetString(ginRequestIDKey)
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID 生成请求ID
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,185 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/idgen"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// ErrArtifactNotFound 产物不存在
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact 产物元数据，如分析Agent渲染的图表
+type Artifact struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Tenant      string    `json:"tenant,omitempty"` // 生成时请求的租户，只对同一租户可见
+	Source      string    `json:"source,omitempty"` // 生成产物的Agent类型
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// URL 产物的下载地址
+func (a *Artifact) URL() string {
+	return "/api/v1/artifacts/" + a.ID
+}
+
+// Store 产物存储
+type Store interface {
+	// Save 保存产物，未指定ID和租户时自动生成ID并取请求的租户
+	Save(ctx context.Context, artifact *Artifact, data []byte) error
+
+	// Get 获取产物元数据和内容
+	Get(ctx context.Context, id string) (*Artifact, []byte, error)
+}
+
+// NewStoreFromConfig 根据配置创建产物存储
+func NewStoreFromConfig(cfg config.ArtifactStoreConfig) (Store, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported artifact store: %s", cfg.Store)
+	}
+}
+
+// prepare 补全产物的ID、租户、大小和创建时间
+func prepare(ctx context.Context, artifact *Artifact, data []byte) {
+	if artifact.ID == "" {
+		artifact.ID = idgen.New(idgen.PrefixArtifact)
+	}
+	if artifact.Tenant == "" {
+		artifact.Tenant = tenant.FromContext(ctx)
+	}
+	if artifact.ContentType == "" {
+		artifact.ContentType = "application/octet-stream"
+	}
+	if artifact.CreatedAt.IsZero() {
+		artifact.CreatedAt = time.Now()
+	}
+	artifact.Size = len(data)
+}
+
+// entry 内存中的一个产物
+type entry struct {
+	artifact Artifact
+	data     []byte
+}
+
+// MemoryStore 内存产物存储
+type MemoryStore struct {
+	mu        sync.RWMutex
+	artifacts map[string]*entry
+}
+
+// NewMemoryStore 创建内存产物存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		artifacts: make(map[string]*entry),
+	}
+}
+
+// Save 保存产物
+func (s *MemoryStore) Save(ctx context.Context, artifact *Artifact, data []byte) error {
+	prepare(ctx, artifact, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artifacts[artifact.ID] = &entry{artifact: *artifact, data: append([]byte(nil), data...)}
+	return nil
+}
+
+// Get 获取产物
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Artifact, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.artifacts[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, id)
+	}
+	artifact := e.artifact
+	return &artifact, e.data, nil
+}
+
+// FileStore 文件产物存储
+// 每个产物一个数据文件（<id>.data）和一个元数据文件（<id>.json），读取时按需加载
+type FileStore struct {
+	dir string
+	mu  sync.Mutex // 串行化文件写入
+}
+
+// NewFileStore 创建文件产物存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		dir = "./data/artifacts"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save 保存产物，先写数据再写元数据，元数据存在即表示产物完整
+func (s *FileStore) Save(ctx context.Context, artifact *Artifact, data []byte) error {
+	prepare(ctx, artifact, data)
+	meta, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode artifact: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base := filepath.Join(s.dir, filepath.Base(artifact.ID))
+	if err := writeFile(base+".data", data); err != nil {
+		return err
+	}
+	return writeFile(base+".json", meta)
+}
+
+// Get 读取产物
+func (s *FileStore) Get(ctx context.Context, id string) (*Artifact, []byte, error) {
+	base := filepath.Join(s.dir, filepath.Base(id))
+	meta, err := os.ReadFile(base + ".json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, id)
+		}
+		return nil, nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	var artifact Artifact
+	if err := json.Unmarshal(meta, &artifact); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode artifact %s: %w", id, err)
+	}
+	data, err := os.ReadFile(base + ".data")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	return &artifact, data, nil
+}
+
+// writeFile 先写临时文件再重命名，避免读到写了一半的文件
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	return nil
+}
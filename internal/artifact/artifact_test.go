@@ -0,0 +1,53 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// TestStores 测试内存和文件存储的保存、读取与元数据补全
+func TestStores(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			ctx := tenant.WithTenant(context.Background(), "acme")
+			data := []byte("<svg></svg>")
+
+			a := &Artifact{Name: "histogram.svg", ContentType: "image/svg+xml", Source: "analyst"}
+			if err := store.Save(ctx, a, data); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if !strings.HasPrefix(a.ID, "artifact-") || a.Tenant != "acme" || a.Size != len(data) || a.CreatedAt.IsZero() {
+				t.Errorf("artifact metadata not filled: %+v", a)
+			}
+			if a.URL() != "/api/v1/artifacts/"+a.ID {
+				t.Errorf("unexpected url: %s", a.URL())
+			}
+
+			got, content, err := store.Get(ctx, a.ID)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if string(content) != string(data) || got.Name != a.Name || got.ContentType != a.ContentType || got.Tenant != "acme" {
+				t.Errorf("unexpected artifact: %+v, %q", got, content)
+			}
+
+			if _, _, err := store.Get(ctx, "artifact-missing"); !errors.Is(err, ErrArtifactNotFound) {
+				t.Errorf("expected ErrArtifactNotFound, got %v", err)
+			}
+		})
+	}
+
+	if _, err := NewStoreFromConfig(config.ArtifactStoreConfig{Store: "s3"}); err == nil {
+		t.Error("expected error for unsupported store")
+	}
+}
@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// apiKeyPrefix 生成的API Key前缀，便于识别和密钥扫描
+const apiKeyPrefix = "aak_"
+
+// ErrKeyNotFound API Key不存在
+var ErrKeyNotFound = errors.New("api key not found")
+
+// APIKey API Key记录，只保存哈希，不保存明文
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"` // 明文的SHA-256（十六进制）
+	Scopes    []string   `json:"scopes"`
+	Tenant    string     `json:"tenant,omitempty"` // 绑定的租户，启用多租户时请求只能访问该租户的数据
+	Disabled  bool       `json:"disabled,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Active 是否可用（未停用且未过期）
+func (k *APIKey) Active() bool {
+	if k.Disabled {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
+
+// HashAPIKey 计算API Key的哈希
+// API Key为高熵随机串，使用SHA-256即可，无需慢哈希
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey 生成新的API Key，返回明文（仅此一次可见）和待保存的记录
+func GenerateAPIKey(name string, scopes []string, ttl time.Duration) (string, *APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key id: %w", err)
+	}
+
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+	key := &APIKey{
+		ID:        "key_" + hex.EncodeToString(id),
+		Name:      name,
+		Hash:      HashAPIKey(plaintext),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+	return plaintext, key, nil
+}
+
+// KeyStore API Key存储
+type KeyStore interface {
+	// Lookup 按哈希查找API Key
+	Lookup(ctx context.Context, hash string) (*APIKey, error)
+
+	// Save 保存或更新API Key
+	Save(ctx context.Context, key *APIKey) error
+
+	// Delete 删除API Key
+	Delete(ctx context.Context, id string) error
+
+	// List 列出全部API Key（按创建时间排序）
+	List(ctx context.Context) ([]*APIKey, error)
+}
+
+// NewKeyStoreFromConfig 根据配置创建API Key存储，配置中的keys会预先载入
+func NewKeyStoreFromConfig(cfg config.APIKeyStoreConfig) (KeyStore, error) {
+	var store KeyStore
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		store = NewMemoryKeyStore()
+	case "file":
+		fileStore, err := NewFileKeyStore(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	default:
+		return nil, fmt.Errorf("unsupported api key store: %s", cfg.Store)
+	}
+
+	for i, keyCfg := range cfg.Keys {
+		if len(keyCfg.Hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("api key %q: hash must be a hex encoded sha256", keyCfg.Name)
+		}
+		key := &APIKey{
+			ID:        fmt.Sprintf("config_%d", i),
+			Name:      keyCfg.Name,
+			Hash:      strings.ToLower(keyCfg.Hash),
+			Scopes:    keyCfg.Scopes,
+			Tenant:    keyCfg.Tenant,
+			CreatedAt: time.Now(),
+		}
+		if memory, ok := store.(*MemoryKeyStore); ok {
+			memory.add(key)
+			continue
+		}
+		if fileStore, ok := store.(*FileKeyStore); ok {
+			fileStore.static.add(key) // 配置中的Key不写入文件
+		}
+	}
+
+	return store, nil
+}
+
+// MemoryKeyStore 内存API Key存储
+type MemoryKeyStore struct {
+	mu     sync.RWMutex
+	byHash map[string]*APIKey
+}
+
+// NewMemoryKeyStore 创建内存API Key存储
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		byHash: make(map[string]*APIKey),
+	}
+}
+
+// add 添加API Key
+func (s *MemoryKeyStore) add(key *APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[key.Hash] = key
+}
+
+// Lookup 按哈希查找API Key
+func (s *MemoryKeyStore) Lookup(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// Save 保存API Key
+func (s *MemoryKeyStore) Save(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, existing := range s.byHash {
+		if existing.ID == key.ID {
+			delete(s.byHash, hash)
+		}
+	}
+	s.byHash[key.Hash] = key
+	return nil
+}
+
+// Delete 删除API Key
+func (s *MemoryKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, key := range s.byHash {
+		if key.ID == id {
+			delete(s.byHash, hash)
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// List 列出全部API Key
+func (s *MemoryKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(s.byHash))
+	for _, key := range s.byHash {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// FileKeyStore 文件API Key存储，所有Key保存在一个JSON文件中
+// 文件被外部修改（如命令行工具新增Key）后，下次查找时自动重新加载
+type FileKeyStore struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	keys    *MemoryKeyStore // 文件中的Key
+	static  *MemoryKeyStore // 配置中的Key
+}
+
+// NewFileKeyStore 创建文件API Key存储
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	if path == "" {
+		path = "./data/api_keys.json"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create api key directory: %w", err)
+	}
+
+	s := &FileKeyStore{
+		path:   path,
+		keys:   NewMemoryKeyStore(),
+		static: NewMemoryKeyStore(),
+	}
+	if err := s.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reloadLocked 文件有变化时重新加载，调用方需持有锁
+func (s *FileKeyStore) reloadLocked() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat api key file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read api key file: %w", err)
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to decode api key file: %w", err)
+	}
+
+	loaded := NewMemoryKeyStore()
+	for _, key := range keys {
+		loaded.add(key)
+	}
+	s.keys = loaded
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// writeLocked 写回文件（先写临时文件再重命名），调用方需持有锁
+func (s *FileKeyStore) writeLocked(ctx context.Context) error {
+	keys, _ := s.keys.List(ctx)
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode api keys: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write api keys: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write api keys: %w", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Lookup 按哈希查找API Key
+func (s *FileKeyStore) Lookup(ctx context.Context, hash string) (*APIKey, error) {
+	if key, err := s.static.Lookup(ctx, hash); err == nil {
+		return key, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return s.keys.Lookup(ctx, hash)
+}
+
+// Save 保存API Key并写回文件
+func (s *FileKeyStore) Save(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return err
+	}
+	_ = s.keys.Save(ctx, key)
+	return s.writeLocked(ctx)
+}
+
+// Delete 删除API Key并写回文件
+func (s *FileKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return err
+	}
+	if err := s.keys.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.writeLocked(ctx)
+}
+
+// List 列出全部API Key（含配置中的Key）
+func (s *FileKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return nil, err
+	}
+	static, _ := s.static.List(ctx)
+	keys, _ := s.keys.List(ctx)
+	return append(static, keys...), nil
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 权限范围
+const (
+	ScopeChat              = "chat"               // 对话、会话与记忆
+	ScopeKnowledgeWrite    = "knowledge:write"    // 知识库写入
+	ScopeWorkflowsAdmin    = "workflows:admin"    // 工作流创建、执行、删除与导入
+	ScopeToolsExecute      = "tools:execute"      // 工具与工具链执行
+	ScopeDebug             = "debug"              // 运行时诊断与性能分析
+	ScopeOrchestratorAdmin = "orchestrator:admin" // 编排器运行时快照与恢复、死信队列、选主状态、容量模拟
+	ScopeAgentsConnect     = "agents:connect"     // 远程Agent注册、接收任务与上报进度（gRPC）
+	ScopeAll               = "*"                  // 全部权限
+)
+
+// principalContextKey gin上下文中调用方的键
+const principalContextKey = "auth.principal"
+
+var (
+	// ErrUnauthenticated 未提供或无效的凭证
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrInvalidCredential 凭证无效、已过期或已停用
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// Principal 已认证的调用方
+type Principal struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Method string   `json:"method"`           // api_key, jwt
+	Role   Role     `json:"role,omitempty"`   // JWT角色
+	Tenant string   `json:"tenant,omitempty"` // 凭证绑定的租户，为空表示未绑定
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope 是否拥有权限范围，支持 "*" 与 "workflows:*" 形式的通配
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == ScopeAll || granted == scope {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(granted, ":*"); ok && strings.HasPrefix(scope, prefix+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey 请求context中调用方的键
+type principalKey struct{}
+
+// PrincipalFromContext 获取请求的调用方，未启用认证时返回nil
+func PrincipalFromContext(c *gin.Context) *Principal {
+	if value, ok := c.Get(principalContextKey); ok {
+		if principal, ok := value.(*Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
+
+// ContextWithPrincipal 把调用方放入请求context，供gin以外的入口（如gRPC）传递
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromRequestContext 从请求context获取调用方，未启用认证时返回nil
+func PrincipalFromRequestContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Authenticator 请求认证与权限校验
+// 未启用时所有中间件直接放行，nil值同样视为未启用
+type Authenticator struct {
+	enabled bool
+	keys    KeyStore
+	jwt     *JWTVerifier
+}
+
+// NewAuthenticator 创建认证器
+func NewAuthenticator(keys KeyStore) *Authenticator {
+	return &Authenticator{
+		enabled: true,
+		keys:    keys,
+	}
+}
+
+// NewAuthenticatorFromConfig 根据配置创建认证器
+func NewAuthenticatorFromConfig(cfg config.AuthConfig) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return &Authenticator{}, nil
+	}
+
+	keys, err := NewKeyStoreFromConfig(cfg.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key store: %w", err)
+	}
+	a := NewAuthenticator(keys)
+
+	if cfg.JWT.Enabled {
+		verifier, err := NewJWTVerifierFromConfig(cfg.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jwt verifier: %w", err)
+		}
+		a.SetJWTVerifier(verifier)
+	}
+	return a, nil
+}
+
+// SetJWTVerifier 设置JWT校验器，设置后Bearer中的JWT按OIDC令牌校验
+func (a *Authenticator) SetJWTVerifier(verifier *JWTVerifier) {
+	a.jwt = verifier
+}
+
+// Enabled 是否启用认证
+func (a *Authenticator) Enabled() bool {
+	return a != nil && a.enabled
+}
+
+// KeyStore 获取API Key存储
+func (a *Authenticator) KeyStore() KeyStore {
+	if a == nil {
+		return nil
+	}
+	return a.keys
+}
+
+// Authenticate 校验凭证，apiKey为X-API-Key的值，authorization为Authorization的值
+// 支持 "Authorization: Bearer <key|jwt>" 和 "X-API-Key: <key>"
+func (a *Authenticator) Authenticate(ctx context.Context, apiKey, authorization string) (*Principal, error) {
+	key := apiKey
+	if key == "" {
+		if strings.HasPrefix(authorization, "Bearer ") {
+			key = strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+			if a.jwt != nil && looksLikeJWT(key) {
+				return a.jwt.Verify(ctx, key)
+			}
+		}
+	}
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+	if a.keys == nil {
+		return nil, ErrInvalidCredential
+	}
+
+	stored, err := a.keys.Lookup(ctx, HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, ErrInvalidCredential
+		}
+		return nil, err
+	}
+	if !stored.Active() {
+		return nil, ErrInvalidCredential
+	}
+
+	return &Principal{
+		ID:     stored.ID,
+		Name:   stored.Name,
+		Method: "api_key",
+		Tenant: stored.Tenant,
+		Scopes: stored.Scopes,
+	}, nil
+}
+
+// AuthError 把认证错误转换为带错误码的错误：无可用角色为403，凭证缺失或无效为401
+func AuthError(err error) error {
+	if errors.Is(err, ErrNoRole) {
+		return apierror.Wrap(apierror.CodeForbidden, err)
+	}
+	if errors.Is(err, ErrUnauthenticated) || errors.Is(err, ErrInvalidCredential) {
+		return apierror.Wrap(apierror.CodeUnauthenticated, err)
+	}
+	return err
+}
+
+// CheckScopes 校验调用方拥有全部指定权限范围，未启用认证时直接通过
+func (a *Authenticator) CheckScopes(principal *Principal, scopes ...string) error {
+	if !a.Enabled() {
+		return nil
+	}
+	if principal == nil {
+		return apierror.New(apierror.CodeUnauthenticated, "authentication required")
+	}
+	for _, scope := range scopes {
+		if !principal.HasScope(scope) {
+			return apierror.New(apierror.CodeForbidden, "insufficient scope").
+				WithDetails(gin.H{"required_scope": scope})
+		}
+	}
+	return nil
+}
+
+// Middleware 认证中间件，认证失败返回401，JWT中没有可用角色返回403
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+
+		principal, err := a.Authenticate(c.Request.Context(), c.GetHeader("X-API-Key"), c.GetHeader("Authorization"))
+		if err != nil {
+			err = AuthError(err)
+			if apierror.From(err).Code == apierror.CodeUnauthenticated {
+				c.Header("WWW-Authenticate", `Bearer realm="ai-agent-assistant"`)
+			}
+			apierror.Abort(c, err)
+			return
+		}
+
+		c.Set(principalContextKey, principal)
+		c.Request = c.Request.WithContext(ContextWithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// RequireScope 要求调用方拥有全部指定权限范围，缺少时返回403
+// 需挂在Middleware之后
+func (a *Authenticator) RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+
+		if err := a.CheckScopes(PrincipalFromContext(c), scopes...); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
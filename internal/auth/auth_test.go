@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(a *Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api", a.Middleware())
+	api.POST("/chat", a.RequireScope(ScopeChat), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"principal": PrincipalFromContext(c).ID})
+	})
+	api.POST("/workflows", a.RequireScope(ScopeWorkflowsAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func doRequest(router *gin.Engine, path string, header map[string]string) int {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// TestAPIKeyAuth 测试API Key认证与权限范围
+func TestAPIKeyAuth(t *testing.T) {
+	store := NewMemoryKeyStore()
+	chatKey, chatRecord, err := GenerateAPIKey("chat-only", []string{ScopeChat}, 0)
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	adminKey, adminRecord, _ := GenerateAPIKey("admin", []string{"workflows:*", ScopeChat}, 0)
+	expiredKey, expiredRecord, _ := GenerateAPIKey("expired", []string{ScopeAll}, time.Nanosecond)
+	for _, key := range []*APIKey{chatRecord, adminRecord, expiredRecord} {
+		store.Save(context.Background(), key)
+	}
+	if chatRecord.Hash == chatKey || chatRecord.Hash != HashAPIKey(chatKey) {
+		t.Fatalf("key store must hold the hash, not the plaintext")
+	}
+
+	router := newTestRouter(NewAuthenticator(store))
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"missing key", "/api/chat", nil, http.StatusUnauthorized},
+		{"unknown key", "/api/chat", map[string]string{"X-API-Key": "aak_unknown"}, http.StatusUnauthorized},
+		{"expired key", "/api/chat", map[string]string{"X-API-Key": expiredKey}, http.StatusUnauthorized},
+		{"bearer key", "/api/chat", map[string]string{"Authorization": "Bearer " + chatKey}, http.StatusOK},
+		{"missing scope", "/api/workflows", map[string]string{"X-API-Key": chatKey}, http.StatusForbidden},
+		{"prefix wildcard", "/api/workflows", map[string]string{"X-API-Key": adminKey}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := doRequest(router, tt.path, tt.header); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// 未启用认证时直接放行
+	if got := doRequest(newTestRouter(nil), "/api/workflows", nil); got != http.StatusOK {
+		t.Errorf("disabled auth: status = %d, want 200", got)
+	}
+}
+
+// TestFileKeyStore 测试文件存储的持久化与重新加载
+func TestFileKeyStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	writer, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore failed: %v", err)
+	}
+	reader, _ := NewFileKeyStore(path)
+
+	plaintext, key, _ := GenerateAPIKey("ci", []string{ScopeChat}, 0)
+	if err := writer.Save(ctx, key); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	found, err := reader.Lookup(ctx, HashAPIKey(plaintext))
+	if err != nil {
+		t.Fatalf("reader should reload the file after it changes: %v", err)
+	}
+	if found.Name != "ci" {
+		t.Errorf("Name = %s, want ci", found.Name)
+	}
+
+	if err := writer.Delete(ctx, key.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	reopened, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if _, err := reopened.Lookup(ctx, HashAPIKey(plaintext)); err != ErrKeyNotFound {
+		t.Errorf("deleted key should not be found, got %v", err)
+	}
+}
+
+// signRS256 签发RS256测试令牌
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// TestJWTRoles 测试OIDC令牌校验与角色权限
+func TestJWTRoles(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer idp.Close()
+	issuer = idp.URL
+
+	// newJWTRouter 使用指定角色映射的JWT校验的路由
+	newJWTRouter := func(mapping map[string]string, defaultRole string) *gin.Engine {
+		verifier, err := NewJWTVerifierFromConfig(config.JWTConfig{
+			Enabled:     true,
+			Issuer:      issuer,
+			Audience:    "assistant",
+			RoleClaim:   "realm_access.roles",
+			RoleMapping: mapping,
+			DefaultRole: defaultRole,
+		})
+		if err != nil {
+			t.Fatalf("NewJWTVerifierFromConfig failed: %v", err)
+		}
+		a := NewAuthenticator(NewMemoryKeyStore())
+		a.SetJWTVerifier(verifier)
+		router := newTestRouter(a)
+		router.Group("/api", a.Middleware()).POST("/knowledge/add", a.RequireScope(ScopeKnowledgeWrite), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"role": PrincipalFromContext(c).Role})
+		})
+		return router
+	}
+	router := newJWTRouter(nil, "")
+	mapped := newJWTRouter(map[string]string{"kb-writers": "editor"}, "viewer")
+
+	token := func(roles []string, mutate func(map[string]interface{})) map[string]string {
+		claims := map[string]interface{}{
+			"iss":          issuer,
+			"aud":          []string{"assistant"},
+			"sub":          "user-1",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]interface{}{"roles": roles},
+		}
+		if mutate != nil {
+			mutate(claims)
+		}
+		return map[string]string{"Authorization": "Bearer " + signRS256(t, key, "k1", claims)}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"viewer chat", "/api/chat", token([]string{"viewer"}, nil), http.StatusOK},
+		{"viewer knowledge write", "/api/knowledge/add", token([]string{"viewer"}, nil), http.StatusForbidden},
+		{"editor workflow admin", "/api/workflows", token([]string{"editor"}, nil), http.StatusForbidden},
+		{"highest role wins", "/api/workflows", token([]string{"viewer", "admin"}, nil), http.StatusOK},
+		{"no role", "/api/chat", token([]string{"unknown"}, nil), http.StatusForbidden},
+		{"expired", "/api/chat", token([]string{"admin"}, func(c map[string]interface{}) {
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		}), http.StatusUnauthorized},
+		{"wrong audience", "/api/chat", token([]string{"admin"}, func(c map[string]interface{}) {
+			c["aud"] = "other"
+		}), http.StatusUnauthorized},
+		{"wrong issuer", "/api/chat", token([]string{"admin"}, func(c map[string]interface{}) {
+			c["iss"] = "https://evil.example.com"
+		}), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := doRequest(router, tt.path, tt.header); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// 配置了角色映射时只按映射解析，未映射的值（即使与角色同名）使用默认角色
+	mappedTests := []struct {
+		name  string
+		path  string
+		roles []string
+		want  int
+	}{
+		{"mapped editor knowledge write", "/api/knowledge/add", []string{"kb-writers"}, http.StatusOK},
+		{"unmapped admin group", "/api/workflows", []string{"admin"}, http.StatusForbidden},
+		{"unmapped value gets default role", "/api/chat", []string{"admin"}, http.StatusOK},
+		{"default role knowledge write", "/api/knowledge/add", []string{"editor"}, http.StatusForbidden},
+	}
+	for _, tt := range mappedTests {
+		if got := doRequest(mapped, tt.path, token(tt.roles, nil)); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// alg=none的令牌必须被拒绝
+	parts := strings.Split(strings.TrimPrefix(token([]string{"admin"}, nil)["Authorization"], "Bearer "), ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	if got := doRequest(router, "/api/chat", map[string]string{"Authorization": "Bearer " + none}); got != http.StatusUnauthorized {
+		t.Errorf("alg none: status = %d, want 401", got)
+	}
+}
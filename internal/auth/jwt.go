@@ -0,0 +1,484 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// ErrNoRole JWT中没有可映射的角色
+var ErrNoRole = errors.New("no role granted")
+
+// jwtHeader JWT头部
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jsonWebKey JWKS中的公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWTVerifier 校验OIDC签发的JWT并将角色声明映射为角色
+// 支持RS256、ES256（公钥来自JWKS）和HS256（共享密钥）
+type JWTVerifier struct {
+	issuer      string
+	audience    string
+	jwksURL     string
+	hmacSecret  []byte
+	roleClaim   string
+	tenantClaim string
+	roleMapping map[string]Role
+	defaultRole Role
+	policy      *RolePolicy
+	clockSkew   time.Duration
+	cacheTTL    time.Duration
+	minRefresh  time.Duration // 遇到未知kid时两次拉取JWKS的最小间隔
+	httpClient  *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey // kid -> 公钥
+	fetchedAt time.Time
+}
+
+// NewJWTVerifierFromConfig 根据配置创建JWT校验器
+func NewJWTVerifierFromConfig(cfg config.JWTConfig) (*JWTVerifier, error) {
+	if cfg.Issuer == "" && cfg.JWKSURL == "" && cfg.HMACSecret == "" {
+		return nil, fmt.Errorf("jwt requires issuer, jwks_url or hmac_secret")
+	}
+
+	policy, err := NewRolePolicy(cfg.RoleScopes)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &JWTVerifier{
+		issuer:      strings.TrimSuffix(cfg.Issuer, "/"),
+		audience:    cfg.Audience,
+		jwksURL:     cfg.JWKSURL,
+		roleClaim:   cfg.RoleClaim,
+		tenantClaim: cfg.TenantClaim,
+		roleMapping: make(map[string]Role),
+		policy:      policy,
+		clockSkew:   time.Minute,
+		cacheTTL:    time.Hour,
+		minRefresh:  30 * time.Second,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		keys:        make(map[string]crypto.PublicKey),
+	}
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+	}
+	if v.roleClaim == "" {
+		v.roleClaim = "roles"
+	}
+	if cfg.DefaultRole != "" {
+		if v.defaultRole, err = ParseRole(cfg.DefaultRole); err != nil {
+			return nil, err
+		}
+	}
+	for claim, name := range cfg.RoleMapping {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("role_mapping %q: %w", claim, err)
+		}
+		v.roleMapping[claim] = role
+	}
+	if d, err := time.ParseDuration(cfg.ClockSkew); err == nil && d >= 0 {
+		v.clockSkew = d
+	}
+	if d, err := time.ParseDuration(cfg.JWKSCacheTTL); err == nil && d > 0 {
+		v.cacheTTL = d
+	}
+	if d, err := time.ParseDuration(cfg.JWKSMinRefresh); err == nil && d >= 0 {
+		v.minRefresh = d
+	}
+	if d, err := time.ParseDuration(cfg.HTTPTimeout); err == nil && d > 0 {
+		v.httpClient = &http.Client{Timeout: d}
+	}
+
+	return v, nil
+}
+
+// looksLikeJWT 是否为JWT格式（三段式），用于区分Bearer中的JWT与API Key
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify 校验JWT并返回调用方
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed jwt", ErrInvalidCredential)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidCredential)
+	}
+	if err := v.verifySignature(ctx, header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	role := v.resolveRole(claims)
+	if role == "" {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredential, ErrNoRole)
+	}
+
+	principal := &Principal{
+		ID:     stringClaim(claims, "sub"),
+		Method: "jwt",
+		Role:   role,
+		Scopes: v.policy.ScopesFor(role),
+	}
+	if v.tenantClaim != "" {
+		principal.Tenant, _ = lookupClaim(claims, v.tenantClaim).(string)
+	}
+	for _, key := range []string{"name", "preferred_username", "email"} {
+		if name := stringClaim(claims, key); name != "" {
+			principal.Name = name
+			break
+		}
+	}
+	return principal, nil
+}
+
+// verifySignature 校验签名
+func (v *JWTVerifier) verifySignature(ctx context.Context, header jwtHeader, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch header.Alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return fmt.Errorf("%w: HS256 is not configured", ErrInvalidCredential)
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredential)
+		}
+		return nil
+
+	case "RS256":
+		key, err := v.publicKey(ctx, header.Kid)
+		if err != nil {
+			return err
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key %s is not an RSA key", ErrInvalidCredential, header.Kid)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredential)
+		}
+		return nil
+
+	case "ES256":
+		key, err := v.publicKey(ctx, header.Kid)
+		if err != nil {
+			return err
+		}
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredential)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredential)
+		}
+		return nil
+
+	default:
+		// 拒绝alg=none及未支持的算法
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidCredential, header.Alg)
+	}
+}
+
+// validateClaims 校验iss、aud、exp、nbf
+func (v *JWTVerifier) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrInvalidCredential)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.clockSkew)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidCredential)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidCredential)
+	}
+
+	if v.issuer != "" && strings.TrimSuffix(stringClaim(claims, "iss"), "/") != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidCredential)
+	}
+
+	if v.audience != "" {
+		matched := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			matched = aud == v.audience
+		case []interface{}:
+			for _, item := range aud {
+				if item == v.audience {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: unexpected audience", ErrInvalidCredential)
+		}
+	}
+
+	return nil
+}
+
+// resolveRole 从角色声明中取最高的角色
+// 配置了role_mapping时只按映射解析，未映射的值（包括与角色同名的值）都不授予角色，
+// 避免身份提供方中恰好名为admin的组获得管理员权限；未配置映射时按角色名直接匹配
+func (v *JWTVerifier) resolveRole(claims map[string]interface{}) Role {
+	var values []string
+	switch raw := lookupClaim(claims, v.roleClaim).(type) {
+	case string:
+		values = strings.Fields(raw)
+	case []interface{}:
+		for _, item := range raw {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var role Role
+	for _, value := range values {
+		mapped, ok := v.roleMapping[value]
+		if !ok && len(v.roleMapping) == 0 {
+			if parsed, err := ParseRole(value); err == nil {
+				mapped, ok = parsed, true
+			}
+		}
+		if ok {
+			role = higherRole(role, mapped)
+		}
+	}
+	if role == "" {
+		role = v.defaultRole
+	}
+	return role
+}
+
+// lookupClaim 按点分路径读取声明，如 realm_access.roles
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var current interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = obj[key]
+	}
+	return current
+}
+
+// stringClaim 读取字符串声明
+func stringClaim(claims map[string]interface{}, key string) string {
+	s, _ := claims[key].(string)
+	return s
+}
+
+// decodeSegment 解码JWT的base64url段
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("malformed jwt segment: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("malformed jwt segment: %w", err)
+	}
+	return nil
+}
+
+// publicKey 按kid获取公钥，缓存过期或遇到未知kid（密钥轮换）时重新拉取JWKS
+func (v *JWTVerifier) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	fresh := time.Since(v.fetchedAt) < v.cacheTTL
+	recentlyFetched := time.Since(v.fetchedAt) < v.minRefresh
+	v.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+	// 避免伪造的kid导致频繁拉取
+	if !ok && recentlyFetched {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidCredential, kid)
+	}
+
+	if err := v.refreshKeys(ctx); err != nil {
+		if ok {
+			return key, nil // 拉取失败时沿用旧公钥
+		}
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidCredential, kid)
+}
+
+// refreshKeys 拉取JWKS
+func (v *JWTVerifier) refreshKeys(ctx context.Context) error {
+	jwksURL, err := v.resolveJWKSURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
+
+// resolveJWKSURL 获取JWKS地址，未配置时通过OIDC发现
+func (v *JWTVerifier) resolveJWKSURL(ctx context.Context) (string, error) {
+	v.mu.RLock()
+	jwksURL := v.jwksURL
+	v.mu.RUnlock()
+	if jwksURL != "" {
+		return jwksURL, nil
+	}
+	if v.issuer == "" {
+		return "", fmt.Errorf("jwks_url or issuer is required for asymmetric tokens")
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("oidc discovery returned no jwks_uri")
+	}
+
+	v.mu.Lock()
+	v.jwksURL = discovery.JWKSURI
+	v.mu.Unlock()
+	return discovery.JWKSURI, nil
+}
+
+// getJSON 发起GET请求并解码JSON
+func (v *JWTVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// publicKey 将JWK转换为公钥
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role 角色，权限由低到高：viewer < editor < admin
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // 只读：对话、检索和查看
+	RoleEditor Role = "editor" // 可写知识库、执行工具
+	RoleAdmin  Role = "admin"  // 全部权限，包括工作流的创建、执行和删除
+)
+
+// roleRank 角色等级
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// defaultRoleScopes 角色默认拥有的权限范围
+// 各接口仍按权限范围校验，角色只决定授予哪些权限范围，API Key与JWT共用同一套校验
+var defaultRoleScopes = map[Role][]string{
+	RoleViewer: {ScopeChat},
+	RoleEditor: {ScopeChat, ScopeKnowledgeWrite, ScopeToolsExecute},
+	RoleAdmin:  {ScopeAll},
+}
+
+// ParseRole 解析角色
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleRank[role]; !ok {
+		return "", fmt.Errorf("unsupported role: %s", s)
+	}
+	return role, nil
+}
+
+// higherRole 返回较高的角色
+func higherRole(a, b Role) Role {
+	if roleRank[b] > roleRank[a] {
+		return b
+	}
+	return a
+}
+
+// RolePolicy 角色到权限范围的映射
+type RolePolicy struct {
+	scopes map[Role][]string
+}
+
+// NewRolePolicy 创建角色策略，overrides覆盖对应角色的默认权限范围
+func NewRolePolicy(overrides map[string][]string) (*RolePolicy, error) {
+	policy := &RolePolicy{scopes: make(map[Role][]string, len(defaultRoleScopes))}
+	for role, scopes := range defaultRoleScopes {
+		policy.scopes[role] = scopes
+	}
+	for name, scopes := range overrides {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		policy.scopes[role] = scopes
+	}
+	return policy, nil
+}
+
+// ScopesFor 角色拥有的权限范围
+func (p *RolePolicy) ScopesFor(role Role) []string {
+	if p == nil {
+		return defaultRoleScopes[role]
+	}
+	return p.scopes[role]
+}
@@ -0,0 +1,59 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源
+// 生产环境使用System，测试和模拟中使用Fake手动推进时间，使依赖时间的逻辑可以复现
+type Clock interface {
+	Now() time.Time
+}
+
+// System 系统时钟
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// OrSystem 返回c，c为nil时返回系统时钟
+func OrSystem(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+	return c
+}
+
+// Fake 手动推进的时钟，并发安全
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake 创建停在start时刻的时钟
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now 当前时刻，只有调用Advance或Set后才会变化
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance 时钟前进d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set 把时钟设置为t
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
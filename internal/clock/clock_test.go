@@ -0,0 +1,45 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFake 测试手动推进的时钟
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Now() = %v, want %v", c.Now(), start)
+	}
+	c.Advance(90 * time.Second)
+	if got := c.Now().Sub(start); got != 90*time.Second {
+		t.Errorf("after Advance elapsed = %v, want 90s", got)
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("after Set Now() = %v, want %v", c.Now(), start)
+	}
+	if OrSystem(nil) != System || OrSystem(c) != c {
+		t.Error("OrSystem should return the given clock or System for nil")
+	}
+}
+
+// TestRandSeed 测试种子相同时随机序列相同
+func TestRandSeed(t *testing.T) {
+	a, b, other := NewRand(42), NewRand(42), NewRand(43)
+	same := true
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Float64(), b.Float64(), other.Float64()
+		if x != y {
+			t.Fatalf("same seed produced %v and %v at %d", x, y, i)
+		}
+		same = same && x == z
+	}
+	if same {
+		t.Error("different seeds produced the same sequence")
+	}
+	if a.Intn(0) != 0 {
+		t.Error("Intn(0) should return 0")
+	}
+}
@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Rand 可指定种子的随机数生成器，并发安全
+// 种子相同时生成的序列相同，用于可复现的模拟数据、测试和仿真
+type Rand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewRand 创建随机数生成器，seed为0时使用当前时间作为种子（不可复现）
+func NewRand(seed int64) *Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Rand{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Float64 [0, 1)内的均匀分布随机数
+func (r *Rand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+// NormFloat64 标准正态分布随机数
+func (r *Rand) NormFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.NormFloat64()
+}
+
+// Intn [0, n)内的随机整数，n<=0时返回0
+func (r *Rand) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
+}
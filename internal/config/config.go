@@ -2,11 +2,10 @@ package config
 
 import (
 	"fmt"
-
-	"github.com/spf13/viper"
 )
 
 type Config struct {
+	Profile   string          `mapstructure:"profile"` // 生效的配置环境（dev/staging/prod），对应 config.<profile>.yaml 覆盖文件
 	Server    ServerConfig    `mapstructure:"server"`
 	Proxy     ProxyConfig     `mapstructure:"proxy"`
 	Agent     AgentConfig     `mapstructure:"agent"`
@@ -18,11 +17,84 @@ type Config struct {
 	Cache     CacheConfig     `mapstructure:"cache"`
 	RAG       RAGConfig       `mapstructure:"rag"`
 	Monitoring MonitoringConfig `mapstructure:"monitoring"`
+	Usage     UsageConfig     `mapstructure:"usage"`
+	ModelRouting ModelRoutingConfig `mapstructure:"model_routing"`
+	Generation GenerationConfig   `mapstructure:"generation"`
+	LLMLogging LLMLoggingConfig   `mapstructure:"llm_logging"`
+	LLMQueue   LLMQueueConfig     `mapstructure:"llm_queue"`
+	Auth       AuthConfig         `mapstructure:"auth"`
+	RateLimit  RateLimitConfig    `mapstructure:"rate_limit"`
+	Tasks      TaskStoreConfig    `mapstructure:"tasks"`
+	Scheduler  SchedulerConfig    `mapstructure:"scheduler"`
+	Reports    ReportStoreConfig  `mapstructure:"reports"`
+	Artifacts  ArtifactStoreConfig `mapstructure:"artifacts"`
+	Workflows  WorkflowStoreConfig `mapstructure:"workflows"`
+	Jobs       JobsConfig         `mapstructure:"jobs"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Health      HealthConfig      `mapstructure:"health"`
+	GRPC        GRPCConfig        `mapstructure:"grpc"`
+	Tenancy     TenancyConfig     `mapstructure:"tenancy"`
+	Moderation  ModerationConfig  `mapstructure:"moderation"`
+	Features    map[string]bool   `mapstructure:"features"` // 功能开关，支持热加载
+	Reload      ReloadConfig      `mapstructure:"reload"`
+	Secrets     SecretsConfig     `mapstructure:"secrets"`
+	Eval        EvalConfig        `mapstructure:"eval"`
+}
+
+// FeatureEnabled 功能开关是否开启，未配置的开关视为关闭
+func (c *Config) FeatureEnabled(name string) bool {
+	if c == nil {
+		return false
+	}
+	return c.Features[name]
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port            int                   `mapstructure:"port"`
+	Mode            string                `mapstructure:"mode"`
+	CORS            CORSConfig            `mapstructure:"cors"`
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"`
+	Gzip            GzipConfig            `mapstructure:"gzip"`
+	BodyLimit       BodyLimitConfig       `mapstructure:"body_limit"`
+}
+
+// CORSConfig 跨域资源共享配置
+type CORSConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	AllowedOrigins   []string `mapstructure:"allowed_origins"` // "*" 表示任意来源，支持 https://*.example.com
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"` // 为空时允许预检请求中声明的请求头
+	ExposedHeaders   []string `mapstructure:"exposed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	MaxAge           string   `mapstructure:"max_age"` // 预检结果缓存时间
+}
+
+// SecurityHeadersConfig 安全响应头配置
+type SecurityHeadersConfig struct {
+	Enabled               bool   `mapstructure:"enabled"`
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+	FrameOptions          string `mapstructure:"frame_options"`
+	ReferrerPolicy        string `mapstructure:"referrer_policy"`
+	HSTSMaxAge            string `mapstructure:"hsts_max_age"` // 为空表示不发送Strict-Transport-Security
+}
+
+// GzipConfig 响应压缩配置
+type GzipConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Level        int      `mapstructure:"level"`         // 1-9，0使用默认级别
+	ContentTypes []string `mapstructure:"content_types"` // 压缩的响应类型前缀，流式响应不压缩
+}
+
+// BodyLimitConfig 请求体大小限制
+type BodyLimitConfig struct {
+	DefaultKB int                    `mapstructure:"default_kb"` // 0表示不限制
+	Routes    []BodyLimitRouteConfig `mapstructure:"routes"`     // 按顺序匹配，先匹配的生效
+}
+
+// BodyLimitRouteConfig 一组接口的请求体大小上限
+type BodyLimitRouteConfig struct {
+	Paths []string `mapstructure:"paths"`  // 路由模式，以*结尾表示前缀匹配
+	MaxKB int      `mapstructure:"max_kb"` // 0表示不限制（如文件上传由接口自身限制）
 }
 
 type ProxyConfig struct {
@@ -38,6 +110,451 @@ type AgentConfig struct {
 	MaxTokens      int    `mapstructure:"max_tokens"`
 	Temperature    float64 `mapstructure:"temperature"`
 	EnableStream   bool   `mapstructure:"enable_stream"`
+	PersonasDir    string `mapstructure:"personas_dir"` // Agent人设目录，为空时使用内置人设
+	Memory         AgentMemoryConfig `mapstructure:"memory"`
+	Limits         map[string]AgentLimitConfig `mapstructure:"limits"` // 按Agent类型的资源限制，default适用于所有类型
+}
+
+// AgentLimitConfig 专家Agent单个任务的资源限制，0或空表示不限制
+// 超出限制后Agent不再调用模型或工具，改用降级实现完成任务
+type AgentLimitConfig struct {
+	MaxTokens    int    `mapstructure:"max_tokens"`     // 模型token总量
+	MaxToolCalls int    `mapstructure:"max_tool_calls"` // 工具调用次数
+	MaxDuration  string `mapstructure:"max_duration"`   // 执行时长，如 "2m"
+}
+
+// AgentMemoryConfig 专家Agent记忆配置
+// Agent执行任务前检索同一用户或项目的历史任务结果，完成后记录本次结果
+type AgentMemoryConfig struct {
+	Enabled        bool              `mapstructure:"enabled"`
+	Scope          string            `mapstructure:"scope"`           // 默认作用域：user（按requirements.user_id）或 project（按requirements.project，缺省时退回user）
+	Scopes         map[string]string `mapstructure:"scopes"`          // 按Agent类型覆盖作用域，如 researcher: project
+	RecallLimit    int               `mapstructure:"recall_limit"`    // 每次检索的记忆条数，默认5
+	DisabledAgents []string          `mapstructure:"disabled_agents"` // 不使用记忆的Agent类型
+}
+
+// GenerationConfig 客户端生成参数的服务端上限
+type GenerationConfig struct {
+	MaxTemperature float64  `mapstructure:"max_temperature"`
+	MaxTopP        float64  `mapstructure:"max_top_p"`
+	MaxTokens      int      `mapstructure:"max_tokens"`
+	AllowedModels  []string `mapstructure:"allowed_models"` // 为空表示不限制
+}
+
+// LLMLoggingConfig LLM请求/响应日志配置
+type LLMLoggingConfig struct {
+	Enabled    bool            `mapstructure:"enabled"`
+	Store      string          `mapstructure:"store"` // memory, file
+	Path       string          `mapstructure:"path"`  // file存储的JSONL路径
+	MaxEntries int             `mapstructure:"max_entries"`
+	Routes     []string        `mapstructure:"routes"` // 为空表示记录所有路由
+	Redaction  RedactionConfig `mapstructure:"redaction"`
+}
+
+// LLMQueueConfig LLM请求队列配置
+// 服务商限流（429）时请求按优先级（交互式对话 > 工作流步骤 > 批量评测）和租户公平排队，而不是直接失败
+type LLMQueueConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	MaxConcurrent int    `mapstructure:"max_concurrent"` // 每个服务商同时进行的请求数，0表示不限制（只在被限流后排队）
+	MaxQueueSize  int    `mapstructure:"max_queue_size"` // 每个服务商的等待请求数上限，默认1000
+	MaxWait       string `mapstructure:"max_wait"`       // 排队最长等待时间，默认 "60s"
+	MaxRetries    int    `mapstructure:"max_retries"`    // 被限流后重新排队的次数，默认3
+	Backoff       string `mapstructure:"backoff"`        // 被限流后暂停该服务商的初始时长，连续限流时翻倍，默认 "1s"
+	MaxBackoff    string `mapstructure:"max_backoff"`    // 暂停时长上限，默认 "30s"
+}
+
+// AuthConfig API认证配置
+type AuthConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	APIKeys APIKeyStoreConfig `mapstructure:"api_keys"`
+	JWT     JWTConfig         `mapstructure:"jwt"`
+}
+
+// APIKeyStoreConfig API Key存储配置
+type APIKeyStoreConfig struct {
+	Store string         `mapstructure:"store"` // memory, file
+	Path  string         `mapstructure:"path"`  // file存储的JSON路径
+	Keys  []APIKeyConfig `mapstructure:"keys"`  // 静态配置的Key，只填写哈希
+}
+
+// APIKeyConfig 静态配置的API Key
+type APIKeyConfig struct {
+	Name   string   `mapstructure:"name"`
+	Hash   string   `mapstructure:"hash"`   // 明文的SHA-256（十六进制）
+	Scopes []string `mapstructure:"scopes"` // chat, knowledge:write, workflows:admin, tools:execute, *
+	Tenant string   `mapstructure:"tenant"` // 绑定的租户，为空表示不绑定
+}
+
+// JWTConfig OIDC签发的JWT认证配置
+type JWTConfig struct {
+	Enabled      bool                `mapstructure:"enabled"`
+	Issuer       string              `mapstructure:"issuer"`         // 校验iss，同时用于OIDC发现
+	Audience     string              `mapstructure:"audience"`       // 校验aud，为空表示不校验
+	JWKSURL      string              `mapstructure:"jwks_url"`       // 为空时通过 {issuer}/.well-known/openid-configuration 发现
+	HMACSecret   string              `mapstructure:"hmac_secret"`    // HS256密钥，仅用于无OIDC的自签发场景
+	RoleClaim    string              `mapstructure:"role_claim"`     // 角色声明，支持嵌套路径如 realm_access.roles
+	TenantClaim  string              `mapstructure:"tenant_claim"`   // 租户声明，支持嵌套路径，为空表示令牌不绑定租户
+	RoleMapping  map[string]string   `mapstructure:"role_mapping"`   // 声明值 -> viewer/editor/admin，配置后未映射的值不授予角色
+	DefaultRole  string              `mapstructure:"default_role"`   // 无匹配角色时使用，为空则拒绝
+	RoleScopes   map[string][]string `mapstructure:"role_scopes"`    // 覆盖角色的默认权限范围
+	ClockSkew    string              `mapstructure:"clock_skew"`     // exp/nbf允许的时钟偏差
+	JWKSCacheTTL string              `mapstructure:"jwks_cache_ttl"` // JWKS缓存时间，默认1h
+	JWKSMinRefresh string            `mapstructure:"jwks_min_refresh"` // 遇到未知kid时两次重新拉取JWKS的最小间隔，默认30s
+	HTTPTimeout  string              `mapstructure:"http_timeout"`   // OIDC发现和JWKS请求的超时时间，默认10s
+}
+
+// RateLimitConfig 按客户端的限流与并发配置
+type RateLimitConfig struct {
+	Enabled           bool                    `mapstructure:"enabled"`
+	RequestsPerMinute float64                 `mapstructure:"requests_per_minute"` // 默认预算
+	Burst             int                     `mapstructure:"burst"`
+	MaxInFlight       int                     `mapstructure:"max_in_flight"`        // 全局最大并发，0表示不限制
+	ClientMaxInFlight int                     `mapstructure:"client_max_in_flight"` // 每个客户端最大并发
+	Budgets           []RateLimitBudgetConfig `mapstructure:"budgets"`              // 昂贵接口的独立预算
+}
+
+// RateLimitBudgetConfig 一组接口的独立限流预算
+type RateLimitBudgetConfig struct {
+	Name              string   `mapstructure:"name"`
+	Paths             []string `mapstructure:"paths"` // 路由模式，以*结尾表示前缀匹配
+	RequestsPerMinute float64  `mapstructure:"requests_per_minute"`
+	Burst             int      `mapstructure:"burst"`
+	MaxInFlight       int      `mapstructure:"max_in_flight"`
+}
+
+// TaskStoreConfig 任务执行记录存储配置
+type TaskStoreConfig struct {
+	Store string `mapstructure:"store"` // memory, file
+	Path    string `mapstructure:"path"`    // file存储的目录，每个任务一个JSON文件
+	Timeout string `mapstructure:"timeout"` // 后台任务的最长执行时间，为空表示不限制
+}
+
+// SchedulerConfig 任务调度器配置
+type SchedulerConfig struct {
+	PollInterval   string               `mapstructure:"poll_interval"` // 从队列取出任务分配给Agent的间隔，默认1s
+	MaxRetries     int                  `mapstructure:"max_retries"`   // 任务未设置max_retries时分配失败的重试次数，默认3
+	Workers        int                  `mapstructure:"workers"`       // 执行任务的工作协程数，默认4
+	QueueSize      int                  `mapstructure:"queue_size"`    // 等待调度的任务数上限，超出时提交失败，默认1000
+	TaskTimeout    string               `mapstructure:"task_timeout"`  // 单个任务的最长执行时间，为空表示不限制
+	Registry       AgentRegistryConfig  `mapstructure:"registry"`
+	SnapshotDir    string               `mapstructure:"snapshot_dir"` // 运行时快照（/admin/snapshots）的保存目录，默认./data/snapshots
+	RemoteAgents   RemoteAgentsConfig   `mapstructure:"remote_agents"`
+	Retry          TaskRetryConfig      `mapstructure:"retry"`
+	DeadLetterSize int                  `mapstructure:"dead_letter_size"` // 死信队列保留的任务数上限，超出时丢弃最早的，默认1000
+	TraceSize      int                  `mapstructure:"trace_size"`       // 容量模拟（/admin/scheduler/simulate）回放的任务到达轨迹条数，默认1000
+	Preemption     PreemptionConfig     `mapstructure:"preemption"`
+	Fairness       FairnessConfig       `mapstructure:"fairness"`
+	Bus            BusConfig            `mapstructure:"bus"`
+	Election       LeaderElectionConfig `mapstructure:"election"`
+}
+
+// LeaderElectionConfig 编排器选主配置
+// 多个实例共享同一组Agent时启用：只有主实例的任务调度器分配任务、工作流执行器启动执行，
+// 其他实例作为热备，主实例的租约过期后由其中一个接替
+type LeaderElectionConfig struct {
+	Enabled       bool        `mapstructure:"enabled"`
+	Backend       string      `mapstructure:"backend"`        // redis（默认）, memory（仅限进程内，用于单实例和测试）
+	Key           string      `mapstructure:"key"`            // 锁的键名，同一组实例使用相同的键，默认ai-agent:leader
+	ID            string      `mapstructure:"id"`             // 本实例的标识，默认 主机名-进程号
+	TTL           string      `mapstructure:"ttl"`            // 租约时长，主实例超过ttl没有续约时其他实例可以接替，默认15s
+	RenewInterval string      `mapstructure:"renew_interval"` // 续约和竞选的间隔，默认ttl的三分之一
+	Redis         RedisConfig `mapstructure:"redis"`
+}
+
+// BusConfig Agent通信总线配置
+// 使用nats或kafka时Send/Broadcast/Subscribe跨多个编排器和Agent进程工作，消息至少投递一次
+type BusConfig struct {
+	Broker     string         `mapstructure:"broker"`      // memory（默认，仅限进程内）, nats, kafka
+	Prefix     string         `mapstructure:"prefix"`      // 主题前缀，默认agents
+	MaxDeliver int            `mapstructure:"max_deliver"` // 处理失败时的最多投递次数，之后丢弃该消息，默认5
+	RetryDelay string         `mapstructure:"retry_delay"` // 处理失败后重新投递前的等待时间，默认1s
+	AckWait    string         `mapstructure:"ack_wait"`    // nats等待处理确认的时间，超时未确认时重新投递，默认30s
+	NATS       NATSBusConfig  `mapstructure:"nats"`
+	Kafka      KafkaBusConfig `mapstructure:"kafka"`
+	Log        BusLogConfig   `mapstructure:"log"`
+}
+
+// BusLogConfig 通信总线的消息日志配置
+// 启用后本进程发送的消息按顺序追加到JSONL文件，离线的消费者可以从偏移量重放错过的消息
+type BusLogConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // 默认./data/bus-messages.jsonl
+}
+
+// NATSBusConfig NATS JetStream连接配置
+type NATSBusConfig struct {
+	URL    string `mapstructure:"url"`    // 默认nats://127.0.0.1:4222
+	Stream string `mapstructure:"stream"` // 保存总线消息的流，不存在时自动创建，默认AGENT_BUS
+}
+
+// KafkaBusConfig Kafka连接配置
+type KafkaBusConfig struct {
+	Brokers  []string `mapstructure:"brokers"`   // 默认127.0.0.1:9092
+	ClientID string   `mapstructure:"client_id"` // 默认当前主机名
+}
+
+// PreemptionConfig 任务抢占配置
+// 启用后高优先级任务因没有空闲Agent或工作协程而等待时，暂停一个优先级更低的执行中任务并放回队列
+type PreemptionConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	MinPriority string `mapstructure:"min_priority"` // 可以抢占其他任务的最低优先级：low, normal, high, urgent，默认high
+}
+
+// FairnessConfig 任务出队顺序配置
+type FairnessConfig struct {
+	Mode    string         `mapstructure:"mode"`    // strict（默认，严格按优先级）, weighted（按优先级加权公平）
+	Weights map[string]int `mapstructure:"weights"` // weighted模式下各优先级的权重，默认low 1、normal 2、high 4、urgent 8
+}
+
+// TaskRetryConfig 任务执行失败后的默认重试策略，任务自身设置retry_policy时以任务为准
+// 用完重试次数仍失败的任务进入死信队列（/admin/dead-letters）
+type TaskRetryConfig struct {
+	MaxAttempts int     `mapstructure:"max_attempts"` // 最多执行次数（含第一次），默认1即不重试
+	Backoff     string  `mapstructure:"backoff"`      // 第一次重试前的等待时间，默认 "1s"
+	MaxBackoff  string  `mapstructure:"max_backoff"`  // 等待时间上限，默认 "1m"
+	Multiplier  float64 `mapstructure:"multiplier"`   // 每次重试等待时间的倍数，默认2
+	Jitter      float64 `mapstructure:"jitter"`       // 0-1，等待时间在±jitter比例内随机，默认0.2
+}
+
+// RemoteAgentsConfig 远程Agent协议配置
+// 启用后远程Agent通过gRPC端口（grpc.port）上的agent.v1.AgentService注册、接收调度器分配的任务、上报进度和心跳
+type RemoteAgentsConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	HeartbeatInterval string `mapstructure:"heartbeat_interval"` // 注册时告知Agent的心跳间隔，默认 "10s"
+}
+
+// AgentRegistryConfig Agent注册表存储配置
+// 使用redis或postgres时编排器重启后Agent注册信息不丢失，多个编排器实例可共享同一份注册表
+type AgentRegistryConfig struct {
+	Store    string                `mapstructure:"store"` // memory（默认）、redis、postgres
+	Redis    RedisConfig           `mapstructure:"redis"`
+	Postgres PostgresSessionConfig `mapstructure:"postgres"`
+	Scoring  AgentScoringConfig    `mapstructure:"scoring"`
+	Lease    AgentLeaseConfig      `mapstructure:"lease"`
+}
+
+// AgentLeaseConfig 基于心跳的Agent租约：超过ttl没有心跳的Agent变为unhealthy，不再被调度，
+// 超过expire_after变为expired，超过evict_after从注册表注销；恢复心跳后回到原来的状态
+// 只作用于有endpoint的Agent（远程Agent），进程内的专家Agent不发送心跳，不受租约限制
+type AgentLeaseConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	TTL           string `mapstructure:"ttl"`            // 默认 "30s"，同时是健康检查（/agents/:id/status）的判定时间
+	ExpireAfter   string `mapstructure:"expire_after"`   // 默认3倍ttl
+	EvictAfter    string `mapstructure:"evict_after"`    // 默认10倍ttl
+	CheckInterval string `mapstructure:"check_interval"` // 检查租约的间隔，默认ttl的一半
+}
+
+// AgentScoringConfig FindBestAgent在能力匹配数相同的Agent之间的选择策略
+type AgentScoringConfig struct {
+	Strategy      string  `mapstructure:"strategy"`       // capability（默认，只看能力匹配）, least_loaded, best_performing, weighted
+	SuccessWeight float64 `mapstructure:"success_weight"` // weighted：历史成功率的权重，默认1
+	LoadWeight    float64 `mapstructure:"load_weight"`    // weighted：每个执行中任务的扣分，默认0.2
+	LatencyWeight float64 `mapstructure:"latency_weight"` // weighted：平均执行时长每秒的扣分，默认0.01
+}
+
+// EvalConfig 定时评估配置
+type EvalConfig struct {
+	ResultsDir string            `mapstructure:"results_dir"` // 每次运行的报告和基线的保存目录，默认./data/eval
+	Suites     []EvalSuiteConfig `mapstructure:"suites"`
+	Alert      EvalAlertConfig   `mapstructure:"alert"`
+}
+
+// EvalSuiteConfig 按cron定时运行的评估套件
+// 第一次运行的结果作为基线，之后每次运行与基线比较，指标下降超过阈值时报警
+type EvalSuiteConfig struct {
+	Name               string  `mapstructure:"name"`
+	Schedule           string  `mapstructure:"schedule"`             // cron表达式（分 时 日 月 周），或 @hourly、@daily、@weekly
+	Dataset            string  `mapstructure:"dataset"`              // 黄金数据集（.json、.jsonl、SQuAD JSON、.csv 或 BEIR目录），每次运行时重新读取
+	Pipeline           string  `mapstructure:"pipeline"`             // chat 或 rag，默认chat
+	Model              string  `mapstructure:"model"`                // 生成回答的模型，默认agent.default_model
+	TopK               int     `mapstructure:"top_k"`                // rag流水线检索条数，默认rag.top_k
+	Scoring            string  `mapstructure:"scoring"`              // exact_match 或 similarity，默认similarity
+	Threshold          float64 `mapstructure:"threshold"`            // similarity评分的通过阈值，默认0.8
+	Timeout            string  `mapstructure:"timeout"`              // 单次运行的最长时间，默认30m
+	MaxAccuracyDrop    float64 `mapstructure:"max_accuracy_drop"`    // 准确率比基线下降超过该值时报警，默认0.05
+	MaxScoreDrop       float64 `mapstructure:"max_score_drop"`       // 平均得分比基线下降超过该值时报警，默认0.05
+	MaxLatencyIncrease float64 `mapstructure:"max_latency_increase"` // P95延迟比基线增长超过该比例时报警（0.5表示50%），0表示不检查
+}
+
+// EvalAlertConfig 评估回归报警配置
+type EvalAlertConfig struct {
+	WebhookURL  string `mapstructure:"webhook_url"`  // 接收 eval.regression 事件的地址，为空时只记录日志
+	Secret      string `mapstructure:"secret"`       // webhook签名密钥，默认使用jobs.webhook.secret
+	MaxAttempts int    `mapstructure:"max_attempts"` // 投递失败时的最多尝试次数，默认3
+}
+
+// ReportStoreConfig 报告存储配置
+type ReportStoreConfig struct {
+	Store string `mapstructure:"store"` // memory, file
+	Path  string `mapstructure:"path"`  // file存储的目录，每份报告一个JSON文件和一个Markdown文件
+}
+
+// ArtifactStoreConfig 产物存储配置（Agent生成的图表等文件）
+type ArtifactStoreConfig struct {
+	Store string `mapstructure:"store"` // memory, file
+	Path  string `mapstructure:"path"`  // file存储的目录，每个产物一个数据文件和一个JSON元数据文件
+}
+
+// WorkflowStoreConfig 工作流配置：定义存储、执行器和执行监控
+type WorkflowStoreConfig struct {
+	Store    string                 `mapstructure:"store"` // memory, file
+	Path     string                 `mapstructure:"path"`  // file存储的目录，每个工作流一个JSON文件
+	Executor WorkflowExecutorConfig `mapstructure:"executor"`
+	Monitor  WorkflowMonitorConfig  `mapstructure:"monitor"`
+}
+
+// WorkflowExecutorConfig 工作流执行器配置
+type WorkflowExecutorConfig struct {
+	DefaultTimeout     string         `mapstructure:"default_timeout"`      // 工作流未设置timeout时的执行时长上限，为空表示不限制
+	MaxParallelSteps   int            `mapstructure:"max_parallel_steps"`   // 并行执行时同一层同时运行的步骤数，0表示不限制
+	MaxConcurrentSteps int            `mapstructure:"max_concurrent_steps"` // 所有执行合计同时运行的步骤数，0表示不限制
+	AgentLimits        map[string]int `mapstructure:"agent_limits"`         // 每种Agent同时运行的步骤数，键为步骤的agent，所有执行共享
+	DefaultAgentLimit  int            `mapstructure:"default_agent_limit"`  // 未在agent_limits中列出的Agent的上限，0表示不限制
+}
+
+// WorkflowMonitorConfig 工作流执行监控配置
+type WorkflowMonitorConfig struct {
+	Retention       string  `mapstructure:"retention"`         // 已结束执行的指标保留时间，默认24h
+	EventBufferSize int     `mapstructure:"event_buffer_size"` // 待处理监控事件的缓冲区大小，默认1000
+	CollectInterval string  `mapstructure:"collect_interval"`  // 汇总Agent指标的间隔，默认1m
+	CleanupInterval string  `mapstructure:"cleanup_interval"`  // 清理过期指标的间隔，默认1h
+	Overflow        string  `mapstructure:"overflow"`          // 缓冲区满时的处理方式：drop（默认）、block、spill、sample
+	BlockTimeout    string  `mapstructure:"block_timeout"`     // overflow为block时等待缓冲区空位的最长时间，默认100ms
+	SpillPath       string  `mapstructure:"spill_path"`        // overflow为spill时溢出事件写入的JSONL文件，默认./data/monitor-spill.jsonl
+	SampleRate      float64 `mapstructure:"sample_rate"`       // overflow为sample时缓冲区过半后保留事件的比例，默认0.1
+}
+
+// JobsConfig 异步作业配置
+type JobsConfig struct {
+	Timeout   string        `mapstructure:"timeout"`   // 单个作业最长执行时间
+	Retention string        `mapstructure:"retention"` // 已结束作业的保留时间
+	Webhook   WebhookConfig `mapstructure:"webhook"`
+}
+
+// WebhookConfig 作业完成回调配置
+type WebhookConfig struct {
+	Secret       string   `mapstructure:"secret"` // HMAC-SHA256签名密钥，为空表示不支持callback_url
+	Timeout      string   `mapstructure:"timeout"`
+	MaxAttempts  int      `mapstructure:"max_attempts"`
+	AllowedHosts []string `mapstructure:"allowed_hosts"` // 允许的回调域名，为空表示不限制
+}
+
+// IdempotencyConfig 幂等键配置
+type IdempotencyConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	TTL     string `mapstructure:"ttl"` // 幂等键对应响应的保留时间
+}
+
+// HealthConfig 就绪检查配置
+type HealthConfig struct {
+	Timeout string `mapstructure:"timeout"` // 单个依赖的探测超时，默认3s
+}
+
+// ReloadConfig 配置热加载
+// 只有功能开关、限流、模型路由和提示词模板在运行时生效，其他配置段的变更需要重启
+type ReloadConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Debounce string `mapstructure:"debounce"` // 文件变更后等待的时间，合并编辑器的多次写入，默认200ms
+}
+
+// SecretsConfig 密钥管理配置
+// 配置项的值可以写成密钥引用，启动时从 Vault 或 AWS Secrets Manager 解析：
+// vault://secret/data/ai-agent#glm_api_key、awssm://prod/ai-agent#db_password
+type SecretsConfig struct {
+	Enabled          bool               `mapstructure:"enabled"`
+	Timeout          string             `mapstructure:"timeout"`           // 单次读取密钥的超时时间，默认5s
+	RotationInterval string             `mapstructure:"rotation_interval"` // 重新读取密钥检查轮换的间隔，为空表示不检查
+	Vault            VaultSecretsConfig `mapstructure:"vault"`
+	AWS              AWSSecretsConfig   `mapstructure:"aws"`
+}
+
+// VaultSecretsConfig Vault配置，未设置时读取 VAULT_ADDR、VAULT_TOKEN、VAULT_NAMESPACE 环境变量
+type VaultSecretsConfig struct {
+	Address   string `mapstructure:"address"`
+	Token     string `mapstructure:"token"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+// AWSSecretsConfig AWS Secrets Manager配置，未设置时读取 AWS_REGION、AWS_ACCESS_KEY_ID 等标准环境变量
+type AWSSecretsConfig struct {
+	Region          string `mapstructure:"region"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+	Endpoint        string `mapstructure:"endpoint"` // 为空时使用 https://secretsmanager.<region>.amazonaws.com
+}
+
+// GRPCConfig gRPC服务配置，与HTTP服务共用认证和限流
+type GRPCConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	Port       int  `mapstructure:"port"`
+	Reflection bool `mapstructure:"reflection"` // 注册反射服务，便于grpcurl调试
+}
+
+// TenancyConfig 多租户配置
+// 租户优先取自凭证（API Key的tenant或JWT的tenant_claim），凭证未绑定租户时按allow_header决定是否接受X-Tenant-ID
+type TenancyConfig struct {
+	Enabled       bool               `mapstructure:"enabled"`
+	AllowHeader   bool               `mapstructure:"allow_header"`   // 凭证未绑定租户时是否接受X-Tenant-ID
+	DefaultTenant string             `mapstructure:"default_tenant"` // 无法确定租户时使用，为空则拒绝请求
+	Quotas        TenantQuotasConfig `mapstructure:"quotas"`
+}
+
+// TenantQuotasConfig 租户配额，default对所有租户生效，tenants按租户覆盖
+type TenantQuotasConfig struct {
+	Default TenantQuotaConfig            `mapstructure:"default"`
+	Tenants map[string]TenantQuotaConfig `mapstructure:"tenants"`
+}
+
+// TenantQuotaConfig 单个租户的配额，0表示沿用默认配额（default中为不限制），负数表示不限制
+type TenantQuotaConfig struct {
+	MaxAgents     int `mapstructure:"max_agents"`     // 注册的Agent数
+	MaxTasks      int `mapstructure:"max_tasks"`      // 调度器中等待和执行中的任务数
+	MaxExecutions int `mapstructure:"max_executions"` // 同时运行的工作流执行数
+	MaxDocuments  int `mapstructure:"max_documents"`  // 知识库中的文档（来源）数
+}
+
+// ModerationConfig 内容审核配置，对对话的用户输入和模型输出生效
+// 先按关键词/正则规则匹配，未被拦截时再交给可选的分类模型判断
+type ModerationConfig struct {
+	Enabled    bool                       `mapstructure:"enabled"`
+	Rules      []ModerationRuleConfig     `mapstructure:"rules"`
+	Classifier ModerationClassifierConfig `mapstructure:"classifier"`
+	Audit      ModerationAuditConfig      `mapstructure:"audit"`
+}
+
+// ModerationRuleConfig 关键词/正则审核规则
+type ModerationRuleConfig struct {
+	Name     string   `mapstructure:"name"`
+	Category string   `mapstructure:"category"` // 为空时使用name
+	Keywords []string `mapstructure:"keywords"` // 不区分大小写的子串匹配
+	Patterns []string `mapstructure:"patterns"` // 正则表达式
+	Action   string   `mapstructure:"action"`   // block, redact, flag
+	Stages   []string `mapstructure:"stages"`   // input, output，为空表示两者
+}
+
+// ModerationClassifierConfig 分类模型审核配置
+type ModerationClassifierConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	Model      string   `mapstructure:"model"`       // 用于审核的模型
+	Categories []string `mapstructure:"categories"`  // 需要识别的违规类别
+	Action     string   `mapstructure:"action"`      // 判定违规时的处理：block, flag
+	Stages     []string `mapstructure:"stages"`      // input, output，为空表示两者
+	FailClosed bool     `mapstructure:"fail_closed"` // 分类模型调用失败时拦截，默认放行
+}
+
+// ModerationAuditConfig 审核记录存储配置
+type ModerationAuditConfig struct {
+	Store      string `mapstructure:"store"` // memory, file
+	Path       string `mapstructure:"path"`  // file存储的JSONL路径
+	MaxEntries int    `mapstructure:"max_entries"`
+}
+
+// RedactionConfig 敏感信息脱敏配置
+type RedactionConfig struct {
+	Enabled        bool              `mapstructure:"enabled"`
+	Rules          []string          `mapstructure:"rules"`           // 内置规则: email, phone, id_card
+	CustomPatterns map[string]string `mapstructure:"custom_patterns"` // 自定义规则: 名称 -> 正则
 }
 
 type ModelsConfig struct {
@@ -52,12 +569,75 @@ type ModelConfig struct {
 }
 
 type MemoryConfig struct {
-	MaxHistory int    `mapstructure:"max_history"`
-	StoreType  string `mapstructure:"store_type"`
+	MaxHistory          int                   `mapstructure:"max_history"`
+	StoreType           string                `mapstructure:"store_type"`            // memory, redis, postgres
+	SessionTTL          string                `mapstructure:"session_ttl"`           // 会话空闲过期时间，如 "24h"，为空表示不过期
+	EvictionInterval    string                `mapstructure:"eviction_interval"`     // 空闲会话淘汰检查间隔，默认 "1m"
+	HistoryTokenBudget  int                   `mapstructure:"history_token_budget"`  // 历史窗口token预算，替代max_history按条数截断
+	HistoryContextRatio float64               `mapstructure:"history_context_ratio"` // 历史窗口占模型上下文窗口的比例，与预算同时设置时取较小值
+	Redis               RedisConfig           `mapstructure:"redis"`
+	Postgres            PostgresSessionConfig `mapstructure:"postgres"`
+	UserMemory          UserMemoryConfig      `mapstructure:"user_memory"`
+	Summary             SummaryConfig         `mapstructure:"summary"`
+}
+
+
+// SummaryConfig 会话自动摘要配置
+type SummaryConfig struct {
+	Model          string `mapstructure:"model"`           // 摘要使用的模型，为空时沿用agent.embedding_model
+	PromptTemplate string `mapstructure:"prompt_template"` // 提示词模板，需包含{{conversation}}，可选{{previous_summary}}
+	Threshold      int    `mapstructure:"threshold"`       // 消息数超过此值时自动摘要
+	TokenThreshold int    `mapstructure:"token_threshold"` // 历史token数超过此值时自动摘要，0表示不按token触发
+	MaxVersions    int    `mapstructure:"max_versions"`    // 每个会话保留的摘要版本数，默认10
+}
+
+
+// UserMemoryConfig 用户长期记忆配置
+type UserMemoryConfig struct {
+	Store                string            `mapstructure:"store"`                 // memory, file, redis
+	Path                 string            `mapstructure:"path"`                  // file存储目录
+	DecayHalfLife        string            `mapstructure:"decay_half_life"`       // 重要性衰减半衰期，默认 "720h"
+	MinImportance        float64           `mapstructure:"min_importance"`        // 衰减后低于此值的记忆被清理
+	ConsolidateInterval  string            `mapstructure:"consolidate_interval"`  // 定期合并/清理间隔，为空表示不自动执行
+	ConsolidateThreshold float64           `mapstructure:"consolidate_threshold"` // 相似度不低于此值的记忆合并，默认 0.85
+	ContextLimit         int               `mapstructure:"context_limit"`         // 注入对话上下文的记忆条数，0表示不注入
+	Scopes               MemoryScopeConfig `mapstructure:"scopes"`
+}
+
+
+// MemoryScopeConfig 共享记忆作用域配置
+type MemoryScopeConfig struct {
+	Teams   map[string][]string `mapstructure:"teams"`   // 团队ID -> 成员用户ID（团队ID会被转为小写）
+	Admins  []string            `mapstructure:"admins"`  // 可写全局记忆的管理员
+	Weights map[string]float64  `mapstructure:"weights"` // 检索权重（user/team/global），默认 1.0/0.9/0.8
+}
+
+
+// PostgresSessionConfig Postgres会话存储配置
+type PostgresSessionConfig struct {
+	DSN          string `mapstructure:"dsn"`
+	Driver       string `mapstructure:"driver"` // database/sql驱动名称，默认 "postgres"
+	MaxOpenConns int    `mapstructure:"max_open_conns"`
 }
 
 type ToolsConfig struct {
-	Enabled []string `mapstructure:"enabled"`
+	Enabled []string          `mapstructure:"enabled"`
+	Repo    RepoToolsConfig   `mapstructure:"repo"`
+	Manager ToolManagerConfig `mapstructure:"manager"`
+}
+
+// ToolManagerConfig 专家Agent和 /tools 接口使用的工具管理器配置
+type ToolManagerConfig struct {
+	DisableBuiltin bool     `mapstructure:"disable_builtin"` // 不注册内置的文件操作、数据处理和批量操作工具
+	Enabled        []string `mapstructure:"enabled"`         // 允许执行的工具，为空表示全部
+}
+
+// RepoToolsConfig 代码仓库工具配置：git只读工具和沙箱执行工具，供代码Agent读取仓库和运行测试
+type RepoToolsConfig struct {
+	Roots           []string `mapstructure:"roots"`            // 允许访问的仓库所在目录，为空时不注册这两个工具
+	AllowedCommands []string `mapstructure:"allowed_commands"` // 沙箱中允许执行的命令，默认 go、make、npm、pytest、cargo
+	Timeout         string   `mapstructure:"timeout"`          // 沙箱中单条命令的最长执行时间，默认2m
+	MaxOutputBytes  int      `mapstructure:"max_output_bytes"` // 保留的命令输出长度，默认64KB
 }
 
 type DatabaseConfig struct {
@@ -91,12 +671,29 @@ type MilvusConfig struct {
 	IndexType      string `mapstructure:"index_type"`
 	MetricType     string `mapstructure:"metric_type"`
 	EmbeddingModel string `mapstructure:"embedding_model"`
+
+	PoolSize            int    `mapstructure:"pool_size"`             // 连接数，并发写入时分摊到不同连接，默认1
+	HealthCheckInterval string `mapstructure:"health_check_interval"` // 连接健康检查间隔，失败的连接自动重建，默认30s
+	InsertBatchSize     int    `mapstructure:"insert_batch_size"`     // 写入缓冲凑满多少条后插入一批，默认256
+	FlushInterval       string `mapstructure:"flush_interval"`        // 缓冲中的向量最长等待时间，默认1s
+	InsertRetries       int    `mapstructure:"insert_retries"`        // 连接不可用、限流等瞬时错误的重试次数，默认3
 }
 
 type CacheConfig struct {
 	Enabled bool        `mapstructure:"enabled"`
 	Provider string     `mapstructure:"provider"`
 	Redis   RedisConfig `mapstructure:"redis"`
+	LLM     LLMCacheConfig `mapstructure:"llm"`
+}
+
+// LLMCacheConfig LLM响应缓存配置（进程内，按路由开启）
+type LLMCacheConfig struct {
+	Enabled           bool              `mapstructure:"enabled"`
+	TTL               string            `mapstructure:"ttl"`                // 缓存有效期，如 "10m"
+	MaxEntries        int               `mapstructure:"max_entries"`        // 最大缓存条目数
+	SemanticThreshold float64           `mapstructure:"semantic_threshold"` // 语义匹配相似度阈值
+	EmbeddingModel    string            `mapstructure:"embedding_model"`    // 语义匹配使用的向量化模型
+	Routes            map[string]string `mapstructure:"routes"`             // 路由 -> 缓存模式（exact, semantic）
 }
 
 type RedisConfig struct {
@@ -111,18 +708,83 @@ type RedisConfig struct {
 }
 
 type RAGConfig struct {
+	Enabled            bool                  `mapstructure:"enabled"`
+	TopK               int                   `mapstructure:"top_k"`
+	Threshold          float64               `mapstructure:"threshold"`
+	ChunkSize          int                   `mapstructure:"chunk_size"`
+	ChunkOverlap       int                   `mapstructure:"chunk_overlap"`
+	EnableHybridSearch bool                  `mapstructure:"enable_hybrid_search"`
+	VisionModel        string                `mapstructure:"vision_model"`
+	Upload             KnowledgeUploadConfig `mapstructure:"upload"`
+	Search             KnowledgeSearchConfig `mapstructure:"search"`
+	Reflection         RAGReflectionConfig   `mapstructure:"reflection"`
+	Shadow             RAGShadowConfig       `mapstructure:"shadow"`
+
+	EmbeddingRateLimit EmbeddingRateLimitConfig `mapstructure:"embedding_rate_limit"`
+}
+
+// RAGShadowConfig 检索影子测试：按比例抽样线上查询，在后台用另一套分块、检索方式和重排序再检索一次，
+// 两边的结果和对比指标写入JSONL日志和Prometheus指标，用于离线对比，不影响响应
+type RAGShadowConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	Name           string  `mapstructure:"name"`             // 影子管道名称，写入日志和指标标签，默认 "shadow"
+	SampleRate     float64 `mapstructure:"sample_rate"`      // 0-1，抽样比例，默认0.1
+	ChunkSize      int     `mapstructure:"chunk_size"`       // 影子索引的分块大小，0表示与主管道相同
+	ChunkOverlap   int     `mapstructure:"chunk_overlap"`    // 影子索引的分块重叠
+	Retriever      string  `mapstructure:"retriever"`        // vector（默认）, bm25, hybrid
+	Reranker       string  `mapstructure:"reranker"`         // none（默认）, simple
+	Timeout        string  `mapstructure:"timeout"`          // 单次影子检索的超时，默认 "10s"
+	MaxConcurrent  int     `mapstructure:"max_concurrent"`   // 同时进行的影子检索数，超出时跳过该次抽样，默认4
+	LogPath        string  `mapstructure:"log_path"`         // 对比结果的JSONL文件，默认 ./data/rag_shadow.jsonl
+	MaxResultChars int     `mapstructure:"max_result_chars"` // 日志中每条结果保留的字符数，默认200
+}
+
+// EmbeddingRateLimitConfig 向量化调用的共享令牌桶，对话检索优先于文档导入
+type EmbeddingRateLimitConfig struct {
 	Enabled            bool    `mapstructure:"enabled"`
-	TopK               int     `mapstructure:"top_k"`
-	Threshold          float64 `mapstructure:"threshold"`
-	ChunkSize          int     `mapstructure:"chunk_size"`
-	ChunkOverlap       int     `mapstructure:"chunk_overlap"`
-	EnableHybridSearch bool    `mapstructure:"enable_hybrid_search"`
+	RequestsPerSecond  float64 `mapstructure:"requests_per_second"` // 每秒补充的令牌数，默认10
+	Burst              int     `mapstructure:"burst"`               // 令牌桶容量，默认等于requests_per_second
+	InteractiveReserve int     `mapstructure:"interactive_reserve"` // 文档导入不能使用的令牌数，留给对话检索，默认burst的1/5
+}
+
+// RAGReflectionConfig Self-RAG 与 Agentic RAG 反思循环的预算，0或空表示使用内置默认值
+type RAGReflectionConfig struct {
+	MaxIterations  int     `mapstructure:"max_iterations"`  // Agentic RAG 最大迭代次数
+	MaxRetries     int     `mapstructure:"max_retries"`     // Self-RAG 最多重新检索次数
+	MinScore       float64 `mapstructure:"min_score"`       // 检索质量达到该分数后不再重新检索
+	MinImprovement float64 `mapstructure:"min_improvement"` // 一轮重新检索的得分提升低于该值时提前结束
+	MaxDuration    string  `mapstructure:"max_duration"`    // 反思循环总耗时上限，如 "10s"
+	StopOnRepeat   bool    `mapstructure:"stop_on_repeat"`  // Agentic RAG 重复相同行动时提前结束
+}
+
+// KnowledgeSearchConfig POST /knowledge/search 响应的分页与大小限制，0表示使用默认值
+type KnowledgeSearchConfig struct {
+	MaxResults       int `mapstructure:"max_results"`        // 分页最多能取到的结果数，默认100
+	MaxContentLength int `mapstructure:"max_content_length"` // 单条结果的最大字符数，超出截断，默认2000
+	MaxResponseBytes int `mapstructure:"max_response_bytes"` // 一页结果内容的总字节数上限，默认256KB
+}
+
+// KnowledgeUploadConfig 知识库文件上传配置
+type KnowledgeUploadConfig struct {
+	Dir           string   `mapstructure:"dir"`              // 上传文件保存目录
+	MaxFileSizeMB int      `mapstructure:"max_file_size_mb"` // 单个文件大小上限
+	MaxFiles      int      `mapstructure:"max_files"`        // 单次请求最多文件数
+	AllowedTypes  []string `mapstructure:"allowed_types"`    // pdf, docx, txt, md
 }
 
 type MonitoringConfig struct {
 	Enabled    bool             `mapstructure:"enabled"`
 	Prometheus PrometheusConfig `mapstructure:"prometheus"`
 	Tracing    TracingConfig    `mapstructure:"tracing"`
+	Debug      DebugConfig      `mapstructure:"debug"`
+}
+
+// DebugConfig 运行时诊断接口配置（/debug/pprof、协程转储、运行时统计），需要debug权限
+type DebugConfig struct {
+	Enabled              bool `mapstructure:"enabled"`                // 默认关闭
+	MaxProfileSeconds    int  `mapstructure:"max_profile_seconds"`    // CPU profile和trace的最长采样秒数，默认30
+	BlockProfileRate     int  `mapstructure:"block_profile_rate"`     // 阻塞采样率（纳秒），0表示不采样
+	MutexProfileFraction int  `mapstructure:"mutex_profile_fraction"` // 锁竞争采样比例（1/n），0表示不采样
 }
 
 type PrometheusConfig struct {
@@ -135,23 +797,55 @@ type TracingConfig struct {
 	JaegerEndpoint string  `mapstructure:"jaeger_endpoint"`
 }
 
-var GlobalConfig *Config
+// UsageConfig token用量与费用统计配置
+type UsageConfig struct {
+	Enabled  bool                         `mapstructure:"enabled"`
+	Currency string                       `mapstructure:"currency"`
+	Pricing  map[string]ModelPriceConfig `mapstructure:"pricing"` // 模型名 -> 价格
+}
 
-func Load(configPath string) (*Config, error) {
-	viper.SetConfigFile(configPath)
-	viper.SetConfigType("yaml")
+// ModelPriceConfig 模型价格（每1000个token）
+type ModelPriceConfig struct {
+	PromptPer1K     float64 `mapstructure:"prompt_per_1k"`
+	CompletionPer1K float64 `mapstructure:"completion_per_1k"`
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
-	}
+// ModelRoutingConfig 模型路由策略配置（按请求在便宜/昂贵模型之间选择）
+type ModelRoutingConfig struct {
+	Enabled      bool                            `mapstructure:"enabled"`
+	DefaultModel string                          `mapstructure:"default_model"`
+	Models       map[string]ModelProfileConfig   `mapstructure:"models"`    // 候选模型档案
+	Pipelines    map[string]PipelineRoutingConfig `mapstructure:"pipelines"` // 按流水线配置的策略
+}
 
-	config := &Config{}
-	if err := viper.Unmarshal(config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
-	}
+// ModelProfileConfig 候选模型档案
+type ModelProfileConfig struct {
+	Tier         string  `mapstructure:"tier"`           // cheap, standard, strong
+	AvgLatencyMs int     `mapstructure:"avg_latency_ms"` // 平均延迟
+	CostPer1K    float64 `mapstructure:"cost_per_1k"`    // 每1000个token的平均价格
+}
+
+// PipelineRoutingConfig 单条流水线的路由策略
+type PipelineRoutingConfig struct {
+	Rules               []RoutingRuleConfig `mapstructure:"rules"`
+	ComplexityThreshold float64             `mapstructure:"complexity_threshold"` // 超过该复杂度使用强模型
+	LatencyBudgetMs     int                 `mapstructure:"latency_budget_ms"`
+	CostCeiling         float64             `mapstructure:"cost_ceiling"` // 单次调用费用上限
+}
 
-	GlobalConfig = config
-	return config, nil
+// RoutingRuleConfig 路由规则
+type RoutingRuleConfig struct {
+	TaskType      string  `mapstructure:"task_type"`
+	MinComplexity float64 `mapstructure:"min_complexity"`
+	MaxComplexity float64 `mapstructure:"max_complexity"`
+	Model         string  `mapstructure:"model"`
+}
+
+var GlobalConfig *Config
+
+// Load 加载配置文件，环境变量（AIAGENT_前缀）覆盖文件中的配置项
+func Load(configPath string) (*Config, error) {
+	return LoadWithOptions(LoadOptions{Path: configPath})
 }
 
 func GetModelConfig(modelName string) (ModelConfig, error) {
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 在临时目录写入配置文件，返回文件路径
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// TestLoadDefaults 测试配置文件未设置时使用默认值
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv(ProfileEnv, "")
+	path := writeConfig(t, t.TempDir(), "config.yaml", "agent:\n  default_model: glm-4-flash\n")
+
+	cfg, err := loadConfig(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Port != 8080 || cfg.Server.Mode != "debug" {
+		t.Errorf("expected defaults 8080/debug, got %d/%s", cfg.Server.Port, cfg.Server.Mode)
+	}
+	if cfg.Agent.DefaultModel != "glm-4-flash" {
+		t.Errorf("expected file value, got %q", cfg.Agent.DefaultModel)
+	}
+
+	if _, err := loadConfig(LoadOptions{Path: filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+// TestLoadOverrides 测试优先级：配置文件 < 环境变量 < 命令行
+func TestLoadOverrides(t *testing.T) {
+	t.Setenv(ProfileEnv, "")
+	path := writeConfig(t, t.TempDir(), "config.yaml", `server:
+  port: 9000
+  mode: release
+features:
+  beta_search: false
+`)
+
+	t.Setenv("AIAGENT_SERVER_PORT", "9100")
+	t.Setenv("AIAGENT_AGENT_TEMPERATURE", "0.3")
+	t.Setenv("AIAGENT_FEATURES_BETA_SEARCH", "true")
+	t.Setenv("AIAGENT_FEATURES_NEW_UI", "true")
+
+	cfg, err := loadConfig(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Port != 9100 {
+		t.Errorf("env should override file: got port %d", cfg.Server.Port)
+	}
+	if cfg.Server.Mode != "release" {
+		t.Errorf("file value should be kept: got mode %q", cfg.Server.Mode)
+	}
+	if cfg.Agent.Temperature != 0.3 {
+		t.Errorf("env should set keys missing from file: got temperature %v", cfg.Agent.Temperature)
+	}
+	if !cfg.FeatureEnabled("beta_search") || !cfg.FeatureEnabled("new_ui") {
+		t.Errorf("env should set feature flags: got %v", cfg.Features)
+	}
+
+	opts, err := ParseFlags("server", []string{"-config", path, "-set", "server.port=9200", "-set", "server.mode=test"})
+	if err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	cfg, err = loadConfig(opts)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Port != 9200 || cfg.Server.Mode != "test" {
+		t.Errorf("flags should override env: got %d/%s", cfg.Server.Port, cfg.Server.Mode)
+	}
+}
+
+// TestParseFlags 测试 -set 参数的校验
+func TestParseFlags(t *testing.T) {
+	opts, err := ParseFlags("server", nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.Path != DefaultConfigPath || opts.Profile != "" || len(opts.Overrides) != 0 {
+		t.Errorf("unexpected defaults: %+v", opts)
+	}
+
+	opts, err = ParseFlags("server", []string{"-set", " Server.Port =9000", "-set", "features.beta=true", "-set", "rag.enabled=true"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if opts.Overrides["server.port"] != "9000" || opts.Overrides["features.beta"] != "true" || opts.Overrides["rag.enabled"] != "true" {
+		t.Errorf("unexpected overrides: %v", opts.Overrides)
+	}
+
+	invalid := map[string]string{
+		"missing value": "server.port",
+		"empty key":     "=9000",
+		"unknown key":   "server.nope=1",
+		"section key":   "server=1",
+		"profile key":   "profile=prod",
+	}
+	for name, arg := range invalid {
+		if _, err := ParseFlags("server", []string{"-set", arg}); err == nil {
+			t.Errorf("%s: expected error for -set %s", name, arg)
+		}
+	}
+}
+
+// TestEnvKey 测试配置项对应的环境变量名
+func TestEnvKey(t *testing.T) {
+	if got := EnvKey("server.cors.max_age"); got != "AIAGENT_SERVER_CORS_MAX_AGE" {
+		t.Errorf("got %s", got)
+	}
+	if !knownKey("server.port") || !knownKey("features.anything") || knownKey("features") || knownKey("server") {
+		t.Error("unexpected knownKey results")
+	}
+	for _, key := range configKeys() {
+		if strings.ContainsAny(key.name, " -") {
+			t.Errorf("config key %q cannot be set from env", key.name)
+		}
+	}
+}
+
+// validConfig 能通过校验的最小配置
+func validConfig() *Config {
+	cfg := &Config{}
+	cfg.Server.Port = 8080
+	cfg.Server.Mode = "debug"
+	cfg.Agent.DefaultModel = "glm-4-flash"
+	cfg.Models.GLM.APIKey = "test-key"
+	return cfg
+}
+
+// TestValidate 测试各类无效配置都出现在汇总的错误中
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(ValidateOptions{}); err != nil {
+		t.Fatalf("minimal config should be valid: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(cfg *Config)
+		key    string
+	}{
+		{"port out of range", func(c *Config) { c.Server.Port = 70000 }, "server.port"},
+		{"unsupported mode", func(c *Config) { c.Server.Mode = "prod" }, "server.mode"},
+		{"missing default model", func(c *Config) { c.Agent.DefaultModel = "" }, "agent.default_model"},
+		{"missing api key", func(c *Config) { c.Models.GLM.APIKey = "" }, "agent.default_model"},
+		{"temperature out of range", func(c *Config) { c.Agent.Temperature = 2.5 }, "agent.temperature"},
+		{"invalid duration", func(c *Config) { c.Memory.SessionTTL = "1 day" }, "memory.session_ttl"},
+		{"negative duration", func(c *Config) { c.Tasks.Timeout = "-5s" }, "tasks.timeout"},
+		{"redis store without addr", func(c *Config) { c.Memory.StoreType = "redis" }, "memory.redis.addr"},
+		{"chunk overlap", func(c *Config) { c.RAG.ChunkSize = 100; c.RAG.ChunkOverlap = 100 }, "rag.chunk_overlap"},
+		{"milvus without dimension", func(c *Config) {
+			c.VectorDB.Provider = "milvus"
+			c.VectorDB.Milvus.Address = "localhost:19530"
+			c.VectorDB.Milvus.CollectionName = "docs"
+		}, "vectordb.milvus.dimension"},
+		{"grpc port conflict", func(c *Config) { c.GRPC.Enabled = true; c.GRPC.Port = 8080 }, "grpc.port"},
+		{"invalid retry multiplier", func(c *Config) { c.Scheduler.Retry.Multiplier = 0.5 }, "scheduler.retry.multiplier"},
+		{"invalid bus prefix", func(c *Config) { c.Scheduler.Bus.Prefix = "a.b" }, "scheduler.bus.prefix"},
+	}
+	for _, tc := range cases {
+		cfg := validConfig()
+		tc.modify(cfg)
+		err := cfg.Validate(ValidateOptions{})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: expected ValidationErrors, got %v", tc.name, err)
+			continue
+		}
+		found := false
+		for _, fe := range errs {
+			found = found || fe.Key == tc.key
+		}
+		if !found {
+			t.Errorf("%s: expected problem for %s, got %v", tc.name, tc.key, err)
+		}
+	}
+}
+
+// TestValidateReport 测试一次报告所有问题，并附上可覆盖配置项的环境变量
+func TestValidateReport(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = 0
+	cfg.Server.Mode = "prod"
+	cfg.Agent.MaxTokens = -1
+
+	err := cfg.Validate(ValidateOptions{})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"invalid configuration (3 problems):",
+		"server.port: must be between 1 and 65535, got 0 (env AIAGENT_SERVER_PORT)",
+		`server.mode: unsupported value "prod", expected one of: debug, release, test (env AIAGENT_SERVER_MODE)`,
+		"agent.max_tokens: must not be negative, got -1 (env AIAGENT_AGENT_MAX_TOKENS)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("report missing %q:\n%s", want, msg)
+		}
+	}
+
+	// 列表中的配置项不能用环境变量覆盖，不附环境变量
+	cfg = validConfig()
+	cfg.Server.BodyLimit.Routes = []BodyLimitRouteConfig{{MaxKB: 10}}
+	msg = cfg.Validate(ValidateOptions{}).Error()
+	if !strings.HasSuffix(msg, "server.body_limit.routes[0].paths: is required") {
+		t.Errorf("unexpected report:\n%s", msg)
+	}
+}
+
+// TestValidateOptions 测试依赖其他包信息的检查
+func TestValidateOptions(t *testing.T) {
+	opts := ValidateOptions{
+		KnownModel: func(name string) bool { return strings.HasPrefix(name, "glm") },
+		ResolveEmbeddingModel: func(name string) string {
+			if name == "qwen" {
+				return "text-embedding-v2"
+			}
+			return "embedding-2"
+		},
+		EmbeddingDimension: func(model string) (int, bool) {
+			return map[string]int{"embedding-2": 1024, "text-embedding-v2": 1536}[model], true
+		},
+	}
+
+	cfg := validConfig()
+	cfg.Agent.DefaultModel = "gpt-4"
+	cfg.VectorDB.Provider = "milvus"
+	cfg.VectorDB.Milvus.Address = "localhost:19530"
+	cfg.VectorDB.Milvus.CollectionName = "docs"
+	cfg.VectorDB.Milvus.Dimension = 1536
+	cfg.VectorDB.Milvus.EmbeddingModel = "qwen"
+
+	var errs ValidationErrors
+	if !errors.As(cfg.Validate(opts), &errs) {
+		t.Fatal("expected validation errors")
+	}
+	keys := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		keys = append(keys, fe.Key)
+	}
+	want := []string{"agent.default_model", "vectordb.milvus.embedding_model", "vectordb.milvus.dimension"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("got problems for %v, want %v", keys, want)
+	}
+
+	cfg.Agent.DefaultModel = "glm-4-flash"
+	cfg.VectorDB.Milvus.Dimension = 1024
+	cfg.VectorDB.Milvus.EmbeddingModel = "glm"
+	if err := cfg.Validate(opts); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+// TestValidateExample 测试示例配置能通过校验
+func TestValidateExample(t *testing.T) {
+	t.Setenv(ProfileEnv, "")
+	cfg, err := loadConfig(LoadOptions{Path: "../../config.yaml.example"})
+	if err != nil {
+		t.Fatalf("load example: %v", err)
+	}
+	if err := cfg.Validate(ValidateOptions{}); err != nil {
+		t.Errorf("config.yaml.example should be valid: %v", err)
+	}
+}
+
+// TestLoadProfile 测试环境覆盖文件的合并和环境的选择
+func TestLoadProfile(t *testing.T) {
+	t.Setenv(ProfileEnv, "")
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config.yaml", `profile: dev
+server:
+  port: 9000
+  mode: debug
+rag:
+  upload:
+    allowed_types: [pdf, docx, txt]
+features:
+  beta_search: true
+`)
+	writeConfig(t, dir, "config.dev.yaml", "server:\n  mode: test\n")
+	writeConfig(t, dir, "config.prod.yaml", `server:
+  mode: release
+rag:
+  upload:
+    allowed_types: [pdf]
+features:
+  new_ui: true
+`)
+
+	// 基础配置文件中的 profile 作为默认环境
+	cfg, err := loadConfig(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Profile != "dev" || cfg.Server.Mode != "test" || cfg.Server.Port != 9000 {
+		t.Errorf("dev profile: got %s %s/%d", cfg.Profile, cfg.Server.Mode, cfg.Server.Port)
+	}
+
+	// AIAGENT_PROFILE 覆盖配置文件，映射按键合并，列表整体替换
+	t.Setenv(ProfileEnv, "prod")
+	cfg, err = loadConfig(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Profile != "prod" || cfg.Server.Mode != "release" || cfg.Server.Port != 9000 {
+		t.Errorf("prod profile: got %s %s/%d", cfg.Profile, cfg.Server.Mode, cfg.Server.Port)
+	}
+	if got := cfg.RAG.Upload.AllowedTypes; len(got) != 1 || got[0] != "pdf" {
+		t.Errorf("lists should be replaced, got %v", got)
+	}
+	if !cfg.FeatureEnabled("beta_search") || !cfg.FeatureEnabled("new_ui") {
+		t.Errorf("maps should be merged, got %v", cfg.Features)
+	}
+
+	// -profile 参数优先于环境变量，环境变量仍覆盖环境文件
+	t.Setenv("AIAGENT_SERVER_MODE", "debug")
+	cfg, err = loadConfig(LoadOptions{Path: path, Profile: "dev"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Profile != "dev" || cfg.Server.Mode != "debug" {
+		t.Errorf("flag profile: got %s %s", cfg.Profile, cfg.Server.Mode)
+	}
+	if files := configFiles(LoadOptions{Path: path}, cfg.Profile); len(files) != 2 || files[1] != filepath.Join(dir, "config.dev.yaml") {
+		t.Errorf("unexpected watched files: %v", files)
+	}
+
+	if _, err := loadConfig(LoadOptions{Path: path, Profile: "staging"}); err == nil {
+		t.Error("expected error for missing profile file")
+	}
+	if _, err := loadConfig(LoadOptions{Path: path, Profile: "../prod"}); err == nil || !strings.Contains(err.Error(), "invalid config profile") {
+		t.Errorf("expected invalid profile error, got %v", err)
+	}
+}
+
+// TestProfilePath 测试环境覆盖文件的路径
+func TestProfilePath(t *testing.T) {
+	cases := map[string]string{
+		"config.yaml":          "config.prod.yaml",
+		"/etc/aiagent/app.yml": "/etc/aiagent/app.prod.yml",
+		"deploy/config":        "deploy/config.prod",
+	}
+	for path, want := range cases {
+		if got := ProfilePath(path, "prod"); got != want {
+			t.Errorf("ProfilePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 环境变量前缀，配置项 server.port 对应环境变量 AIAGENT_SERVER_PORT
+const EnvPrefix = "AIAGENT"
+
+// DefaultConfigPath 默认的配置文件路径
+const DefaultConfigPath = "config.yaml"
+
+// LoadOptions 配置加载选项
+type LoadOptions struct {
+	Path      string            // 配置文件路径，为空时使用 config.yaml
+	Profile   string            // 配置环境，合并同目录下的 config.<profile>.yaml，为空时取 AIAGENT_PROFILE 或配置文件中的 profile
+	Overrides map[string]string // 命令行覆盖的配置项，键为 server.port 形式
+}
+
+// EnvKey 配置项对应的环境变量名
+func EnvKey(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// setFlag 可重复的 -set key=value 参数
+type setFlag map[string]string
+
+func (f setFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f setFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	if key == profileKey {
+		return fmt.Errorf("use -profile to select the config profile")
+	}
+	if !knownKey(key) {
+		return fmt.Errorf("unknown config key: %s", key)
+	}
+	f[key] = value
+	return nil
+}
+
+// ParseFlags 解析命令行参数
+// -config 指定配置文件，-profile 指定配置环境，-set key=value 覆盖任意配置项（可重复，列表用逗号分隔）
+func ParseFlags(name string, args []string) (LoadOptions, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return *opts, err
+	}
+	return *opts, nil
+}
+
+// RegisterFlags 在已有的参数集上注册 -config、-profile、-set，供需要额外参数的命令行工具使用
+// 返回的加载选项在参数解析后生效
+func RegisterFlags(fs *flag.FlagSet) *LoadOptions {
+	opts := &LoadOptions{Overrides: make(map[string]string)}
+	fs.StringVar(&opts.Path, "config", DefaultConfigPath, "配置文件路径")
+	fs.StringVar(&opts.Profile, "profile", "", "配置环境，如 prod 会合并 config.prod.yaml")
+	fs.Var(setFlag(opts.Overrides), "set", "覆盖配置项，如 -set server.port=9000，可重复")
+	return opts
+}
+
+// LoadWithOptions 加载配置，优先级为 默认值 < 配置文件 < 环境覆盖文件 < 环境变量 < 命令行
+func LoadWithOptions(opts LoadOptions) (*Config, error) {
+	config, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	GlobalConfig = config
+	return config, nil
+}
+
+// loadConfig 按加载选项读取配置，不修改GlobalConfig
+func loadConfig(opts LoadOptions) (*Config, error) {
+	if opts.Path == "" {
+		opts.Path = DefaultConfigPath
+	}
+
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigFile(opts.Path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	profile, err := resolveProfile(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		if err := mergeProfile(v, opts.Path, profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := bindEnv(v); err != nil {
+		return nil, err
+	}
+	for key, value := range opts.Overrides {
+		v.Set(key, value)
+	}
+	v.Set(profileKey, profile)
+
+	config := &Config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return config, nil
+}
+
+// setDefaults 配置文件未设置时使用的默认值
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.mode", "debug")
+}
+
+// bindEnv 为所有配置项绑定环境变量
+// 键值对类型的配置（如 features）按已设置的环境变量绑定子项，如 AIAGENT_FEATURES_BETA_SEARCH
+func bindEnv(v *viper.Viper) error {
+	for _, key := range configKeys() {
+		if !key.isMap {
+			if err := v.BindEnv(key.name, EnvKey(key.name)); err != nil {
+				return fmt.Errorf("failed to bind env for %s: %w", key.name, err)
+			}
+			continue
+		}
+		prefix := EnvKey(key.name) + "_"
+		for _, env := range os.Environ() {
+			name, _, _ := strings.Cut(env, "=")
+			if sub, ok := strings.CutPrefix(name, prefix); ok && sub != "" {
+				if err := v.BindEnv(key.name+"."+strings.ToLower(sub), name); err != nil {
+					return fmt.Errorf("failed to bind env for %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// configKey 配置项
+type configKey struct {
+	name  string
+	isMap bool // 值为标量的键值对配置，子项名称由用户定义
+}
+
+// configKeys 从Config结构体的mapstructure标签展开所有配置项
+// 结构体列表和值为结构体的键值对无法用单个环境变量表示，只能在配置文件中设置
+func configKeys() []configKey {
+	keys := make([]configKey, 0)
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("mapstructure")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			name := prefix + tag
+			switch field.Type.Kind() {
+			case reflect.Struct:
+				walk(field.Type, name+".")
+			case reflect.Map:
+				if isScalar(field.Type.Elem()) {
+					keys = append(keys, configKey{name: name, isMap: true})
+				}
+			case reflect.Slice:
+				if isScalar(field.Type.Elem()) {
+					keys = append(keys, configKey{name: name})
+				}
+			default:
+				keys = append(keys, configKey{name: name})
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// isScalar 是否为可以用字符串表示的类型
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Interface, reflect.Pointer:
+		return false
+	}
+	return true
+}
+
+// knownKey 是否为可覆盖的配置项
+func knownKey(name string) bool {
+	for _, key := range configKeys() {
+		if key.name == name {
+			return !key.isMap
+		}
+		if key.isMap && strings.HasPrefix(name, key.name+".") {
+			return true
+		}
+	}
+	return false
+}
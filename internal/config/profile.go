@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileEnv 选择配置环境的环境变量
+const ProfileEnv = EnvPrefix + "_PROFILE"
+
+// profileKey 基础配置文件中声明默认环境的配置项
+const profileKey = "profile"
+
+// profileName 环境名称只允许字母、数字、- 和 _，避免拼出其他目录下的文件
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ProfilePath 环境覆盖文件的路径：config.yaml 的 prod 环境对应同目录下的 config.prod.yaml
+func ProfilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// resolveProfile 确定生效的环境，优先级为 基础配置文件的 profile < AIAGENT_PROFILE < -profile 参数
+func resolveProfile(v *viper.Viper, opts LoadOptions) (string, error) {
+	profile := opts.Profile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile == "" {
+		profile = v.GetString(profileKey)
+	}
+	profile = strings.TrimSpace(profile)
+	if profile != "" && !profileName.MatchString(profile) {
+		return "", fmt.Errorf("invalid config profile %q: only letters, digits, '-' and '_' are allowed", profile)
+	}
+	return profile, nil
+}
+
+// mergeProfile 把环境覆盖文件合并到基础配置上
+// 映射按键递归合并，标量和列表整体替换；指定了环境但覆盖文件不存在时报错
+func mergeProfile(v *viper.Viper, path, profile string) error {
+	overlay := ProfilePath(path, profile)
+	file, err := os.Open(overlay)
+	if err != nil {
+		return fmt.Errorf("failed to read config profile %q: %w", profile, err)
+	}
+	defer file.Close()
+	if err := v.MergeConfig(file); err != nil {
+		return fmt.Errorf("failed to merge config profile %q (%s): %w", profile, overlay, err)
+	}
+	return nil
+}
+
+// configFiles 加载选项实际读取的配置文件，热加载时监听这些文件
+func configFiles(opts LoadOptions, profile string) []string {
+	files := []string{filepath.Clean(opts.Path)}
+	if profile != "" {
+		files = append(files, filepath.Clean(ProfilePath(opts.Path, profile)))
+	}
+	return files
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventConfigChanged 配置变更事件名
+const EventConfigChanged = "config.changed"
+
+// 可热加载的配置段
+const (
+	SectionFeatures        = "features"
+	SectionRateLimit       = "rate_limit"
+	SectionModelRouting    = "model_routing"
+	SectionPromptTemplates = "prompt_templates" // memory.summary.prompt_template
+)
+
+// defaultReloadDebounce 默认的文件变更合并等待时间
+const defaultReloadDebounce = 200 * time.Millisecond
+
+// ChangeEvent 配置变更事件
+type ChangeEvent struct {
+	Sections        []string  // 已生效的配置段
+	RestartRequired []string  // 发生了变更但需要重启才能生效的配置段，这些变更被忽略
+	Old             *Config   // 变更前生效的配置
+	New             *Config   // 变更后生效的配置
+	At              time.Time // 生效时间
+}
+
+// Changed 配置段是否已在本次变更中生效
+func (e ChangeEvent) Changed(section string) bool {
+	for _, s := range e.Sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator 配置校验函数，返回错误时本次变更不生效
+type Validator func(cfg *Config) error
+
+// Watcher 配置热加载
+// 监听配置文件变更，重新读取并校验后只替换可热加载的配置段，再把变更事件交给订阅者；
+// 读取或校验失败时保留当前配置
+type Watcher struct {
+	opts     LoadOptions
+	debounce time.Duration
+
+	mu         sync.RWMutex
+	current    *Config
+	validators []Validator
+	handlers   []func(ChangeEvent)
+
+	validateOpts ValidateOptions
+	resolve      func(cfg *Config) error
+	reloadMu     sync.Mutex // 串行化重新加载
+	fsw          *fsnotify.Watcher
+}
+
+// NewWatcher 创建配置热加载器，opts 与启动时加载配置的选项一致，current 为启动时加载的配置
+// 重新加载时同样应用环境变量和命令行覆盖
+func NewWatcher(opts LoadOptions, current *Config) *Watcher {
+	if opts.Path == "" {
+		opts.Path = DefaultConfigPath
+	}
+	return &Watcher{
+		opts:     opts,
+		debounce: defaultReloadDebounce,
+		current:  current,
+	}
+}
+
+// NewWatcherFromConfig 根据配置创建热加载器，未启用时返回nil
+func NewWatcherFromConfig(opts LoadOptions, current *Config) (*Watcher, error) {
+	if !current.Reload.Enabled {
+		return nil, nil
+	}
+	w := NewWatcher(opts, current)
+	if current.Reload.Debounce != "" {
+		d, err := time.ParseDuration(current.Reload.Debounce)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid reload debounce %q", current.Reload.Debounce)
+		}
+		w.debounce = d
+	}
+	return w, nil
+}
+
+// SetValidateOptions 设置重新加载时完整校验配置使用的选项（如可用的模型名称）
+func (w *Watcher) SetValidateOptions(opts ValidateOptions) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.validateOpts = opts
+}
+
+// SetResolver 设置重新读取配置后、比较和校验之前执行的处理，用于解析密钥引用
+func (w *Watcher) SetResolver(resolve func(cfg *Config) error) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resolve = resolve
+}
+
+// AddValidator 添加配置校验，在内置校验之后执行
+func (w *Watcher) AddValidator(validator Validator) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.validators = append(w.validators, validator)
+}
+
+// OnChange 订阅配置变更，处理函数按订阅顺序同步调用
+func (w *Watcher) OnChange(handler func(ChangeEvent)) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+// Current 当前生效的配置
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Start 开始监听配置文件，ctx结束或调用Close后停止
+// 监听配置文件所在目录，以便处理编辑器先写临时文件再重命名的保存方式；
+// 指定了配置环境时，环境覆盖文件的修改同样触发重新加载
+func (w *Watcher) Start(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	if err := fsw.Add(filepath.Dir(w.opts.Path)); err != nil {
+		fsw.Close()
+		return fmt.Errorf("failed to watch config: %w", err)
+	}
+	w.fsw = fsw
+
+	go w.watch(ctx, fsw)
+	return nil
+}
+
+// Close 停止监听
+func (w *Watcher) Close() error {
+	if w == nil || w.fsw == nil {
+		return nil
+	}
+	return w.fsw.Close()
+}
+
+// watch 合并短时间内的多次文件事件后重新加载
+func (w *Watcher) watch(ctx context.Context, fsw *fsnotify.Watcher) {
+	targets := make(map[string]bool)
+	for _, file := range configFiles(w.opts, w.Current().Profile) {
+		targets[file] = true
+	}
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fsw.Close()
+			return
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			if !targets[filepath.Clean(event.Name)] || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(w.debounce, func() {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			})
+		case <-reload:
+			event, err := w.Reload()
+			switch {
+			case err != nil:
+				log.Printf("Config reload rejected, keeping current config: %v", err)
+			case event != nil && len(event.RestartRequired) > 0:
+				log.Printf("Config reloaded (applied: %v), restart required for: %v", event.Sections, event.RestartRequired)
+			case event != nil:
+				log.Printf("Config reloaded (applied: %v)", event.Sections)
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Config watcher error: %v", err)
+		}
+	}
+}
+
+// Reload 重新读取配置文件，校验通过后替换可热加载的配置段并通知订阅者
+// 配置没有变化时返回nil事件
+func (w *Watcher) Reload() (*ChangeEvent, error) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	loaded, err := loadConfig(w.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.RLock()
+	old := w.current
+	validators := append([]Validator(nil), w.validators...)
+	validateOpts := w.validateOpts
+	resolve := w.resolve
+	w.mu.RUnlock()
+
+	if resolve != nil {
+		if err := resolve(loaded); err != nil {
+			return nil, err
+		}
+	}
+
+	next, sections, restart := mergeReloadable(old, loaded)
+	if len(sections) == 0 && len(restart) == 0 {
+		return nil, nil
+	}
+	if len(sections) > 0 {
+		if err := next.Validate(validateOpts); err != nil {
+			return nil, err
+		}
+		for _, validate := range validators {
+			if err := validate(next); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	event := ChangeEvent{Sections: sections, RestartRequired: restart, Old: old, New: next, At: time.Now()}
+	w.mu.Lock()
+	w.current = next
+	handlers := make([]func(ChangeEvent), len(w.handlers))
+	copy(handlers, w.handlers)
+	w.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return &event, nil
+}
+
+// mergeReloadable 以当前配置为基础替换可热加载的配置段
+// 返回合并后的配置、生效的配置段和需要重启的配置段
+func mergeReloadable(old, loaded *Config) (*Config, []string, []string) {
+	next := *old
+	sections := make([]string, 0)
+
+	if !reflect.DeepEqual(old.Features, loaded.Features) {
+		next.Features = loaded.Features
+		sections = append(sections, SectionFeatures)
+	}
+	// 限流的启停决定了是否挂载限流中间件，需要重启
+	rateLimit := loaded.RateLimit
+	rateLimit.Enabled = old.RateLimit.Enabled
+	if !reflect.DeepEqual(old.RateLimit, rateLimit) {
+		next.RateLimit = rateLimit
+		sections = append(sections, SectionRateLimit)
+	}
+	if !reflect.DeepEqual(old.ModelRouting, loaded.ModelRouting) {
+		next.ModelRouting = loaded.ModelRouting
+		sections = append(sections, SectionModelRouting)
+	}
+	if old.Memory.Summary.PromptTemplate != loaded.Memory.Summary.PromptTemplate {
+		next.Memory.Summary.PromptTemplate = loaded.Memory.Summary.PromptTemplate
+		sections = append(sections, SectionPromptTemplates)
+	}
+
+	// 其余配置段逐个比较，可热加载的部分已合并，比较结果只剩需要重启的变更
+	restart := make([]string, 0)
+	nextValue := reflect.ValueOf(next)
+	loadedValue := reflect.ValueOf(*loaded)
+	if loaded.RateLimit.Enabled != old.RateLimit.Enabled {
+		restart = append(restart, SectionRateLimit+".enabled")
+	}
+	configType := nextValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		name := configType.Field(i).Tag.Get("mapstructure")
+		switch name {
+		case SectionFeatures, SectionRateLimit, SectionModelRouting:
+			continue
+		}
+		if !reflect.DeepEqual(nextValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			restart = append(restart, name)
+		}
+	}
+	return &next, sections, restart
+}
@@ -0,0 +1,701 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/cron"
+)
+
+// busTopicPattern 通信总线主题前缀允许的字符，NATS主题和Kafka主题名都能使用
+var busTopicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FieldError 一个配置项的问题
+type FieldError struct {
+	Key     string // 配置项，如 server.port
+	Message string
+}
+
+// ValidationErrors 配置校验发现的全部问题
+type ValidationErrors []FieldError
+
+// Error 汇总所有问题，每行一个；可用环境变量覆盖的配置项附上对应的环境变量，便于容器部署时修正
+func (e ValidationErrors) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("invalid configuration (%d problems):", len(e)))
+	for _, fe := range e {
+		sb.WriteString(fmt.Sprintf("\n  - %s: %s", fe.Key, fe.Message))
+		if knownKey(fe.Key) {
+			sb.WriteString(" (env " + EnvKey(fe.Key) + ")")
+		}
+	}
+	return sb.String()
+}
+
+// ValidateOptions 校验选项，需要其他包提供的信息，为nil的函数对应的检查会跳过
+type ValidateOptions struct {
+	KnownModel            func(name string) bool         // 模型名称是否受支持
+	ResolveEmbeddingModel func(name string) string       // 将 glm/qwen 解析为实际的向量化模型名称
+	EmbeddingDimension    func(model string) (int, bool) // 向量化模型的维度
+}
+
+// Validate 校验配置，一次返回所有问题而不是在构造各组件时逐个失败
+// 检查必填项、取值范围、时长格式、互相依赖的配置和引用的模型名称
+func (c *Config) Validate(opts ValidateOptions) error {
+	v := &validator{cfg: c, opts: opts}
+	v.server()
+	v.agent()
+	v.memory()
+	v.vectorDB()
+	v.cache()
+	v.rag()
+	v.usage()
+	v.modelRouting()
+	v.generation()
+	v.llmLogging()
+	v.llmQueue()
+	v.auth()
+	v.rateLimit()
+	v.stores()
+	v.jobs()
+	v.grpc()
+	v.moderation()
+	v.workflows()
+	v.eval()
+	v.debug()
+	v.duration("scheduler.poll_interval", c.Scheduler.PollInterval)
+	v.nonNegative("scheduler.max_retries", float64(c.Scheduler.MaxRetries))
+	v.duration("tasks.timeout", c.Tasks.Timeout)
+	v.nonNegative("scheduler.workers", float64(c.Scheduler.Workers))
+	v.nonNegative("scheduler.queue_size", float64(c.Scheduler.QueueSize))
+	v.duration("scheduler.task_timeout", c.Scheduler.TaskTimeout)
+	v.nonNegative("scheduler.retry.max_attempts", float64(c.Scheduler.Retry.MaxAttempts))
+	v.duration("scheduler.retry.backoff", c.Scheduler.Retry.Backoff)
+	v.duration("scheduler.retry.max_backoff", c.Scheduler.Retry.MaxBackoff)
+	if m := c.Scheduler.Retry.Multiplier; m != 0 && m < 1 {
+		v.add("scheduler.retry.multiplier", "must be at least 1, got %v", m)
+	}
+	v.between("scheduler.retry.jitter", c.Scheduler.Retry.Jitter, 0, 1)
+	v.nonNegative("scheduler.dead_letter_size", float64(c.Scheduler.DeadLetterSize))
+	v.nonNegative("scheduler.trace_size", float64(c.Scheduler.TraceSize))
+	v.scheduling()
+	v.duration("tools.repo.timeout", c.Tools.Repo.Timeout)
+	v.nonNegative("tools.repo.max_output_bytes", float64(c.Tools.Repo.MaxOutputBytes))
+	v.duration("idempotency.ttl", c.Idempotency.TTL)
+	v.duration("health.timeout", c.Health.Timeout)
+	v.duration("reload.debounce", c.Reload.Debounce)
+	v.duration("secrets.timeout", c.Secrets.Timeout)
+	v.duration("secrets.rotation_interval", c.Secrets.RotationInterval)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// validator 收集校验问题
+type validator struct {
+	cfg  *Config
+	opts ValidateOptions
+	errs ValidationErrors
+}
+
+func (v *validator) add(key, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// required 必填项
+func (v *validator) required(key, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(key, "is required")
+		return false
+	}
+	return true
+}
+
+// duration 时长格式，空值表示使用默认值
+func (v *validator) duration(key, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d < 0 {
+		v.add(key, "invalid duration %q, expected a value like \"30s\" or \"5m\"", value)
+	}
+}
+
+// oneOf 枚举值，不区分大小写；空值是否允许由allowed中是否包含""决定
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	options := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if a != "" {
+			options = append(options, a)
+		}
+	}
+	v.add(key, "unsupported value %q, expected one of: %s", value, strings.Join(options, ", "))
+}
+
+// between 取值范围
+func (v *validator) between(key string, value, min, max float64) {
+	if value < min || value > max {
+		v.add(key, "must be between %v and %v, got %v", min, max, value)
+	}
+}
+
+// nonNegative 不能为负数
+func (v *validator) nonNegative(key string, value float64) {
+	if value < 0 {
+		v.add(key, "must not be negative, got %v", value)
+	}
+}
+
+// pattern 正则表达式
+func (v *validator) pattern(key, value string) {
+	if _, err := regexp.Compile(value); err != nil {
+		v.add(key, "invalid regular expression: %v", err)
+	}
+}
+
+// model 引用的模型名称，空值跳过
+// glm、qwen 系列模型使用 models 中的API Key，未配置时调用必然失败
+func (v *validator) model(key, name string) {
+	if name == "" {
+		return
+	}
+	if v.opts.KnownModel != nil && !v.opts.KnownModel(name) {
+		v.add(key, "unknown model %q", name)
+		return
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(lower, "glm") && v.cfg.Models.GLM.APIKey == "":
+		v.add(key, "model %q requires models.glm.api_key", name)
+	case strings.HasPrefix(lower, "qwen") && v.cfg.Models.Qwen.APIKey == "":
+		v.add(key, "model %q requires models.qwen.api_key", name)
+	}
+}
+
+func (v *validator) server() {
+	s := v.cfg.Server
+	v.between("server.port", float64(s.Port), 1, 65535)
+	v.oneOf("server.mode", s.Mode, "debug", "release", "test")
+	v.duration("server.cors.max_age", s.CORS.MaxAge)
+	v.duration("server.security_headers.hsts_max_age", s.SecurityHeaders.HSTSMaxAge)
+	v.between("server.gzip.level", float64(s.Gzip.Level), 0, 9)
+	v.nonNegative("server.body_limit.default_kb", float64(s.BodyLimit.DefaultKB))
+	for i, route := range s.BodyLimit.Routes {
+		key := fmt.Sprintf("server.body_limit.routes[%d]", i)
+		if len(route.Paths) == 0 {
+			v.add(key+".paths", "is required")
+		}
+		v.nonNegative(key+".max_kb", float64(route.MaxKB))
+	}
+}
+
+func (v *validator) agent() {
+	a := v.cfg.Agent
+	if v.required("agent.default_model", a.DefaultModel) {
+		v.model("agent.default_model", a.DefaultModel)
+	}
+	v.model("agent.embedding_model", a.EmbeddingModel)
+	if v.cfg.RAG.Enabled {
+		// RAG的向量化只支持 glm 和 qwen
+		v.oneOf("agent.embedding_model", a.EmbeddingModel, "", "glm", "qwen")
+	}
+	v.nonNegative("agent.max_tokens", float64(a.MaxTokens))
+	v.between("agent.temperature", a.Temperature, 0, 2)
+
+	v.oneOf("agent.memory.scope", a.Memory.Scope, "", "user", "project")
+	for _, agentType := range sortedKeys(a.Memory.Scopes) {
+		v.oneOf("agent.memory.scopes."+agentType, a.Memory.Scopes[agentType], "user", "project")
+	}
+	v.nonNegative("agent.memory.recall_limit", float64(a.Memory.RecallLimit))
+	for _, agentType := range sortedKeys(a.Limits) {
+		limits := a.Limits[agentType]
+		key := "agent.limits." + agentType
+		v.nonNegative(key+".max_tokens", float64(limits.MaxTokens))
+		v.nonNegative(key+".max_tool_calls", float64(limits.MaxToolCalls))
+		v.duration(key+".max_duration", limits.MaxDuration)
+	}
+}
+
+func (v *validator) memory() {
+	m := v.cfg.Memory
+	v.nonNegative("memory.max_history", float64(m.MaxHistory))
+	v.oneOf("memory.store_type", m.StoreType, "", "memory", "redis", "postgres", "postgresql")
+	switch strings.ToLower(m.StoreType) {
+	case "redis":
+		v.required("memory.redis.addr", m.Redis.Addr)
+	case "postgres", "postgresql":
+		v.required("memory.postgres.dsn", m.Postgres.DSN)
+	}
+	v.duration("memory.session_ttl", m.SessionTTL)
+	v.duration("memory.eviction_interval", m.EvictionInterval)
+	v.nonNegative("memory.history_token_budget", float64(m.HistoryTokenBudget))
+	v.between("memory.history_context_ratio", m.HistoryContextRatio, 0, 1)
+
+	um := m.UserMemory
+	v.oneOf("memory.user_memory.store", um.Store, "", "memory", "file", "redis")
+	if strings.EqualFold(um.Store, "redis") {
+		v.required("memory.redis.addr", m.Redis.Addr)
+	}
+	v.duration("memory.user_memory.decay_half_life", um.DecayHalfLife)
+	v.duration("memory.user_memory.consolidate_interval", um.ConsolidateInterval)
+	v.between("memory.user_memory.min_importance", um.MinImportance, 0, 1)
+	v.between("memory.user_memory.consolidate_threshold", um.ConsolidateThreshold, 0, 1)
+	v.nonNegative("memory.user_memory.context_limit", float64(um.ContextLimit))
+
+	v.model("memory.summary.model", m.Summary.Model)
+	if tpl := m.Summary.PromptTemplate; tpl != "" && !strings.Contains(tpl, "{{conversation}}") {
+		v.add("memory.summary.prompt_template", "must contain {{conversation}}")
+	}
+	v.nonNegative("memory.summary.threshold", float64(m.Summary.Threshold))
+	v.nonNegative("memory.summary.token_threshold", float64(m.Summary.TokenThreshold))
+	v.nonNegative("memory.summary.max_versions", float64(m.Summary.MaxVersions))
+}
+
+// vectorDB Milvus需要地址、集合和与向量化模型一致的维度；内存存储按模型自动确定维度，milvus配置不生效
+func (v *validator) vectorDB() {
+	vdb := v.cfg.VectorDB
+	v.oneOf("vectordb.provider", vdb.Provider, "", "memory", "milvus")
+	if !strings.EqualFold(vdb.Provider, "milvus") {
+		return
+	}
+
+	m := vdb.Milvus
+	v.required("vectordb.milvus.address", m.Address)
+	v.required("vectordb.milvus.collection_name", m.CollectionName)
+	if m.Dimension <= 0 {
+		v.add("vectordb.milvus.dimension", "must be positive when vectordb.provider is milvus, got %d", m.Dimension)
+	}
+	if m.IndexType != "" {
+		v.oneOf("vectordb.milvus.index_type", m.IndexType, "HNSW", "IVF_FLAT", "IVF_SQ8")
+	}
+	if m.MetricType != "" {
+		v.oneOf("vectordb.milvus.metric_type", m.MetricType, "COSINE", "L2", "IP")
+	}
+	v.nonNegative("vectordb.milvus.pool_size", float64(m.PoolSize))
+	v.duration("vectordb.milvus.health_check_interval", m.HealthCheckInterval)
+	v.nonNegative("vectordb.milvus.insert_batch_size", float64(m.InsertBatchSize))
+	v.duration("vectordb.milvus.flush_interval", m.FlushInterval)
+	v.nonNegative("vectordb.milvus.insert_retries", float64(m.InsertRetries))
+
+	if v.opts.ResolveEmbeddingModel == nil {
+		return
+	}
+	name := v.cfg.Agent.EmbeddingModel
+	if name == "" {
+		name = "glm"
+	}
+	model := v.opts.ResolveEmbeddingModel(name)
+	if m.EmbeddingModel != "" && v.opts.ResolveEmbeddingModel(m.EmbeddingModel) != model {
+		v.add("vectordb.milvus.embedding_model", "is %s but agent.embedding_model uses %s; they must match", m.EmbeddingModel, model)
+	}
+	if v.opts.EmbeddingDimension != nil && m.Dimension > 0 {
+		if dim, ok := v.opts.EmbeddingDimension(model); ok && dim != m.Dimension {
+			v.add("vectordb.milvus.dimension", "is %d but embedding model %s produces %d-dim vectors", m.Dimension, model, dim)
+		}
+	}
+}
+
+func (v *validator) cache() {
+	l := v.cfg.Cache.LLM
+	if !l.Enabled {
+		return
+	}
+	v.duration("cache.llm.ttl", l.TTL)
+	v.nonNegative("cache.llm.max_entries", float64(l.MaxEntries))
+	v.between("cache.llm.semantic_threshold", l.SemanticThreshold, 0, 1)
+	v.model("cache.llm.embedding_model", l.EmbeddingModel)
+	for _, route := range sortedKeys(l.Routes) {
+		mode := l.Routes[route]
+		v.oneOf("cache.llm.routes."+route, mode, "exact", "semantic")
+		if strings.EqualFold(mode, "semantic") && l.EmbeddingModel == "" {
+			v.add("cache.llm.embedding_model", "is required for semantic caching on route %s", route)
+		}
+	}
+}
+
+func (v *validator) rag() {
+	r := v.cfg.RAG
+	v.nonNegative("rag.top_k", float64(r.TopK))
+	v.between("rag.threshold", r.Threshold, 0, 1)
+	v.nonNegative("rag.chunk_size", float64(r.ChunkSize))
+	v.nonNegative("rag.chunk_overlap", float64(r.ChunkOverlap))
+	if r.ChunkSize > 0 && r.ChunkOverlap >= r.ChunkSize {
+		v.add("rag.chunk_overlap", "must be smaller than rag.chunk_size (%d), got %d", r.ChunkSize, r.ChunkOverlap)
+	}
+	v.model("rag.vision_model", r.VisionModel)
+	v.nonNegative("rag.upload.max_file_size_mb", float64(r.Upload.MaxFileSizeMB))
+	v.nonNegative("rag.upload.max_files", float64(r.Upload.MaxFiles))
+	for i, t := range r.Upload.AllowedTypes {
+		v.oneOf(fmt.Sprintf("rag.upload.allowed_types[%d]", i), t, "pdf", "docx", "txt", "md")
+	}
+	v.nonNegative("rag.search.max_results", float64(r.Search.MaxResults))
+	v.nonNegative("rag.search.max_content_length", float64(r.Search.MaxContentLength))
+	v.nonNegative("rag.search.max_response_bytes", float64(r.Search.MaxResponseBytes))
+
+	el := r.EmbeddingRateLimit
+	v.nonNegative("rag.embedding_rate_limit.requests_per_second", el.RequestsPerSecond)
+	v.nonNegative("rag.embedding_rate_limit.burst", float64(el.Burst))
+	v.nonNegative("rag.embedding_rate_limit.interactive_reserve", float64(el.InteractiveReserve))
+	if el.Burst > 0 && el.InteractiveReserve >= el.Burst {
+		v.add("rag.embedding_rate_limit.interactive_reserve", "must be smaller than rag.embedding_rate_limit.burst (%d), got %d", el.Burst, el.InteractiveReserve)
+	}
+
+	rf := r.Reflection
+	v.nonNegative("rag.reflection.max_iterations", float64(rf.MaxIterations))
+	v.nonNegative("rag.reflection.max_retries", float64(rf.MaxRetries))
+	v.between("rag.reflection.min_score", rf.MinScore, 0, 1)
+	v.nonNegative("rag.reflection.min_improvement", rf.MinImprovement)
+	v.duration("rag.reflection.max_duration", rf.MaxDuration)
+
+	if sh := r.Shadow; sh.Enabled {
+		v.between("rag.shadow.sample_rate", sh.SampleRate, 0, 1)
+		v.nonNegative("rag.shadow.chunk_size", float64(sh.ChunkSize))
+		v.nonNegative("rag.shadow.chunk_overlap", float64(sh.ChunkOverlap))
+		if sh.ChunkSize > 0 && sh.ChunkOverlap >= sh.ChunkSize {
+			v.add("rag.shadow.chunk_overlap", "must be smaller than rag.shadow.chunk_size (%d), got %d", sh.ChunkSize, sh.ChunkOverlap)
+		}
+		v.oneOf("rag.shadow.retriever", sh.Retriever, "", "vector", "bm25", "hybrid")
+		v.oneOf("rag.shadow.reranker", sh.Reranker, "", "none", "simple")
+		v.duration("rag.shadow.timeout", sh.Timeout)
+		v.nonNegative("rag.shadow.max_concurrent", float64(sh.MaxConcurrent))
+		v.nonNegative("rag.shadow.max_result_chars", float64(sh.MaxResultChars))
+	}
+}
+
+func (v *validator) usage() {
+	for _, model := range sortedKeys(v.cfg.Usage.Pricing) {
+		price := v.cfg.Usage.Pricing[model]
+		v.nonNegative("usage.pricing."+model+".prompt_per_1k", price.PromptPer1K)
+		v.nonNegative("usage.pricing."+model+".completion_per_1k", price.CompletionPer1K)
+	}
+}
+
+func (v *validator) modelRouting() {
+	mr := v.cfg.ModelRouting
+	if mr.Enabled && v.required("model_routing.default_model", mr.DefaultModel) {
+		v.model("model_routing.default_model", mr.DefaultModel)
+	}
+	for _, name := range sortedKeys(mr.Models) {
+		key := "model_routing.models." + name
+		if mr.Enabled {
+			v.model(key, name)
+		}
+		profile := mr.Models[name]
+		v.oneOf(key+".tier", profile.Tier, "", "cheap", "standard", "strong")
+		v.nonNegative(key+".avg_latency_ms", float64(profile.AvgLatencyMs))
+		v.nonNegative(key+".cost_per_1k", profile.CostPer1K)
+	}
+	for _, name := range sortedKeys(mr.Pipelines) {
+		pipeline := mr.Pipelines[name]
+		key := "model_routing.pipelines." + name
+		v.between(key+".complexity_threshold", pipeline.ComplexityThreshold, 0, 1)
+		v.nonNegative(key+".latency_budget_ms", float64(pipeline.LatencyBudgetMs))
+		v.nonNegative(key+".cost_ceiling", pipeline.CostCeiling)
+		for i, rule := range pipeline.Rules {
+			ruleKey := fmt.Sprintf("%s.rules[%d]", key, i)
+			if v.required(ruleKey+".model", rule.Model) && mr.Enabled {
+				v.model(ruleKey+".model", rule.Model)
+			}
+			v.between(ruleKey+".min_complexity", rule.MinComplexity, 0, 1)
+			v.between(ruleKey+".max_complexity", rule.MaxComplexity, 0, 1)
+			if rule.MaxComplexity > 0 && rule.MinComplexity > rule.MaxComplexity {
+				v.add(ruleKey+".min_complexity", "exceeds max_complexity (%v > %v)", rule.MinComplexity, rule.MaxComplexity)
+			}
+		}
+	}
+}
+
+func (v *validator) generation() {
+	g := v.cfg.Generation
+	v.between("generation.max_temperature", g.MaxTemperature, 0, 2)
+	v.between("generation.max_top_p", g.MaxTopP, 0, 1)
+	v.nonNegative("generation.max_tokens", float64(g.MaxTokens))
+	for i, model := range g.AllowedModels {
+		v.model(fmt.Sprintf("generation.allowed_models[%d]", i), model)
+	}
+}
+
+func (v *validator) llmLogging() {
+	l := v.cfg.LLMLogging
+	if !l.Enabled {
+		return
+	}
+	v.oneOf("llm_logging.store", l.Store, "", "memory", "file")
+	v.nonNegative("llm_logging.max_entries", float64(l.MaxEntries))
+	for i, rule := range l.Redaction.Rules {
+		v.oneOf(fmt.Sprintf("llm_logging.redaction.rules[%d]", i), rule, "email", "phone", "id_card")
+	}
+	for _, name := range sortedKeys(l.Redaction.CustomPatterns) {
+		v.pattern("llm_logging.redaction.custom_patterns."+name, l.Redaction.CustomPatterns[name])
+	}
+}
+
+func (v *validator) llmQueue() {
+	q := v.cfg.LLMQueue
+	if !q.Enabled {
+		return
+	}
+	v.nonNegative("llm_queue.max_concurrent", float64(q.MaxConcurrent))
+	v.nonNegative("llm_queue.max_queue_size", float64(q.MaxQueueSize))
+	v.nonNegative("llm_queue.max_retries", float64(q.MaxRetries))
+	v.duration("llm_queue.max_wait", q.MaxWait)
+	v.duration("llm_queue.backoff", q.Backoff)
+	v.duration("llm_queue.max_backoff", q.MaxBackoff)
+}
+
+func (v *validator) auth() {
+	a := v.cfg.Auth
+	if !a.Enabled {
+		return
+	}
+	v.oneOf("auth.api_keys.store", a.APIKeys.Store, "", "memory", "file")
+	for i, key := range a.APIKeys.Keys {
+		prefix := fmt.Sprintf("auth.api_keys.keys[%d]", i)
+		v.required(prefix+".name", key.Name)
+		if _, err := hex.DecodeString(key.Hash); err != nil || len(key.Hash) != 64 {
+			v.add(prefix+".hash", "must be a hex encoded sha256 of the key, not the key itself")
+		}
+	}
+
+	jwt := a.JWT
+	if !jwt.Enabled {
+		return
+	}
+	if jwt.Issuer == "" && jwt.JWKSURL == "" && jwt.HMACSecret == "" {
+		v.add("auth.jwt", "requires issuer, jwks_url or hmac_secret")
+	}
+	roles := []string{"viewer", "editor", "admin"}
+	v.oneOf("auth.jwt.default_role", jwt.DefaultRole, append([]string{""}, roles...)...)
+	for _, claim := range sortedKeys(jwt.RoleMapping) {
+		v.oneOf("auth.jwt.role_mapping."+claim, jwt.RoleMapping[claim], roles...)
+	}
+	v.duration("auth.jwt.clock_skew", jwt.ClockSkew)
+	v.duration("auth.jwt.jwks_cache_ttl", jwt.JWKSCacheTTL)
+	v.duration("auth.jwt.jwks_min_refresh", jwt.JWKSMinRefresh)
+	v.duration("auth.jwt.http_timeout", jwt.HTTPTimeout)
+}
+
+func (v *validator) rateLimit() {
+	rl := v.cfg.RateLimit
+	v.nonNegative("rate_limit.requests_per_minute", rl.RequestsPerMinute)
+	v.nonNegative("rate_limit.burst", float64(rl.Burst))
+	v.nonNegative("rate_limit.max_in_flight", float64(rl.MaxInFlight))
+	v.nonNegative("rate_limit.client_max_in_flight", float64(rl.ClientMaxInFlight))
+
+	names := make(map[string]bool, len(rl.Budgets))
+	for i, b := range rl.Budgets {
+		key := fmt.Sprintf("rate_limit.budgets[%d]", i)
+		if v.required(key+".name", b.Name) && names[b.Name] {
+			v.add(key+".name", "duplicate budget name %q", b.Name)
+		}
+		names[b.Name] = true
+		if len(b.Paths) == 0 {
+			v.add(key+".paths", "is required")
+		}
+		v.nonNegative(key+".requests_per_minute", b.RequestsPerMinute)
+		v.nonNegative(key+".burst", float64(b.Burst))
+		v.nonNegative(key+".max_in_flight", float64(b.MaxInFlight))
+	}
+}
+
+// stores 各类记录的存储后端
+func (v *validator) stores() {
+	v.oneOf("tasks.store", v.cfg.Tasks.Store, "", "memory", "file")
+	v.oneOf("reports.store", v.cfg.Reports.Store, "", "memory", "file")
+	v.oneOf("artifacts.store", v.cfg.Artifacts.Store, "", "memory", "file")
+	v.oneOf("workflows.store", v.cfg.Workflows.Store, "", "memory", "file")
+	v.oneOf("scheduler.registry.store", v.cfg.Scheduler.Registry.Store, "", "memory", "redis", "postgres", "postgresql")
+	switch r := v.cfg.Scheduler.Registry; strings.ToLower(r.Store) {
+	case "redis":
+		v.required("scheduler.registry.redis.addr", r.Redis.Addr)
+	case "postgres", "postgresql":
+		v.required("scheduler.registry.postgres.dsn", r.Postgres.DSN)
+	}
+	sc := v.cfg.Scheduler.Registry.Scoring
+	v.oneOf("scheduler.registry.scoring.strategy", sc.Strategy, "", "capability", "least_loaded", "best_performing", "weighted")
+	v.nonNegative("scheduler.registry.scoring.success_weight", sc.SuccessWeight)
+	v.nonNegative("scheduler.registry.scoring.load_weight", sc.LoadWeight)
+	v.nonNegative("scheduler.registry.scoring.latency_weight", sc.LatencyWeight)
+	if lease := v.cfg.Scheduler.Registry.Lease; lease.Enabled {
+		v.duration("scheduler.registry.lease.ttl", lease.TTL)
+		v.duration("scheduler.registry.lease.expire_after", lease.ExpireAfter)
+		v.duration("scheduler.registry.lease.evict_after", lease.EvictAfter)
+		v.duration("scheduler.registry.lease.check_interval", lease.CheckInterval)
+	}
+	if ra := v.cfg.Scheduler.RemoteAgents; ra.Enabled {
+		if !v.cfg.GRPC.Enabled {
+			v.add("scheduler.remote_agents.enabled", "requires grpc.enabled, remote agents connect over the gRPC port")
+		}
+		v.duration("scheduler.remote_agents.heartbeat_interval", ra.HeartbeatInterval)
+	}
+}
+
+func (v *validator) jobs() {
+	j := v.cfg.Jobs
+	v.duration("jobs.timeout", j.Timeout)
+	v.duration("jobs.retention", j.Retention)
+	v.duration("jobs.webhook.timeout", j.Webhook.Timeout)
+	v.nonNegative("jobs.webhook.max_attempts", float64(j.Webhook.MaxAttempts))
+}
+
+func (v *validator) workflows() {
+	e := v.cfg.Workflows.Executor
+	v.duration("workflows.executor.default_timeout", e.DefaultTimeout)
+	v.nonNegative("workflows.executor.max_parallel_steps", float64(e.MaxParallelSteps))
+	v.nonNegative("workflows.executor.max_concurrent_steps", float64(e.MaxConcurrentSteps))
+	v.nonNegative("workflows.executor.default_agent_limit", float64(e.DefaultAgentLimit))
+	for _, agent := range sortedKeys(e.AgentLimits) {
+		v.nonNegative("workflows.executor.agent_limits."+agent, float64(e.AgentLimits[agent]))
+	}
+	m := v.cfg.Workflows.Monitor
+	v.duration("workflows.monitor.retention", m.Retention)
+	v.nonNegative("workflows.monitor.event_buffer_size", float64(m.EventBufferSize))
+	v.duration("workflows.monitor.collect_interval", m.CollectInterval)
+	v.duration("workflows.monitor.cleanup_interval", m.CleanupInterval)
+	v.oneOf("workflows.monitor.overflow", m.Overflow, "", "drop", "block", "spill", "sample")
+	v.duration("workflows.monitor.block_timeout", m.BlockTimeout)
+	v.between("workflows.monitor.sample_rate", m.SampleRate, 0, 1)
+}
+
+func (v *validator) eval() {
+	e := v.cfg.Eval
+	names := make(map[string]bool, len(e.Suites))
+	for i, s := range e.Suites {
+		key := fmt.Sprintf("eval.suites[%d]", i)
+		if v.required(key+".name", s.Name) {
+			if names[s.Name] {
+				v.add(key+".name", "duplicate suite name %q", s.Name)
+			}
+			names[s.Name] = true
+		}
+		if v.required(key+".schedule", s.Schedule) {
+			if _, err := cron.Parse(s.Schedule); err != nil {
+				v.add(key+".schedule", "%v", err)
+			}
+		}
+		v.required(key+".dataset", s.Dataset)
+		v.oneOf(key+".pipeline", s.Pipeline, "", "chat", "rag")
+		v.model(key+".model", s.Model)
+		v.nonNegative(key+".top_k", float64(s.TopK))
+		v.oneOf(key+".scoring", s.Scoring, "", "exact_match", "similarity")
+		v.between(key+".threshold", s.Threshold, 0, 1)
+		v.duration(key+".timeout", s.Timeout)
+		v.between(key+".max_accuracy_drop", s.MaxAccuracyDrop, 0, 1)
+		v.between(key+".max_score_drop", s.MaxScoreDrop, 0, 1)
+		v.nonNegative(key+".max_latency_increase", s.MaxLatencyIncrease)
+	}
+	if e.Alert.WebhookURL != "" {
+		if u, err := url.Parse(e.Alert.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add("eval.alert.webhook_url", "must be an http(s) URL")
+		}
+		if e.Alert.Secret == "" && v.cfg.Jobs.Webhook.Secret == "" {
+			v.add("eval.alert.secret", "is required for webhook alerts (or set jobs.webhook.secret)")
+		}
+	}
+	v.nonNegative("eval.alert.max_attempts", float64(e.Alert.MaxAttempts))
+}
+
+func (v *validator) debug() {
+	d := v.cfg.Monitoring.Debug
+	v.nonNegative("monitoring.debug.max_profile_seconds", float64(d.MaxProfileSeconds))
+	v.nonNegative("monitoring.debug.block_profile_rate", float64(d.BlockProfileRate))
+	v.nonNegative("monitoring.debug.mutex_profile_fraction", float64(d.MutexProfileFraction))
+}
+
+func (v *validator) scheduling() {
+	s := v.cfg.Scheduler
+	if s.Preemption.Enabled {
+		v.oneOf("scheduler.preemption.min_priority", s.Preemption.MinPriority, "", "low", "normal", "high", "urgent")
+	}
+	v.oneOf("scheduler.fairness.mode", s.Fairness.Mode, "", "strict", "weighted")
+	for name, weight := range s.Fairness.Weights {
+		key := "scheduler.fairness.weights." + name
+		v.oneOf(key, name, "low", "normal", "high", "urgent")
+		if weight <= 0 {
+			v.add(key, "must be positive, got %d", weight)
+		}
+	}
+	b := s.Bus
+	v.oneOf("scheduler.bus.broker", b.Broker, "", "memory", "nats", "kafka")
+	v.nonNegative("scheduler.bus.max_deliver", float64(b.MaxDeliver))
+	v.duration("scheduler.bus.retry_delay", b.RetryDelay)
+	v.duration("scheduler.bus.ack_wait", b.AckWait)
+	if b.Prefix != "" && !busTopicPattern.MatchString(b.Prefix) {
+		v.add("scheduler.bus.prefix", "may only contain letters, digits, '_' and '-', got %q", b.Prefix)
+	}
+	if e := s.Election; e.Enabled {
+		v.oneOf("scheduler.election.backend", e.Backend, "", "redis", "memory")
+		v.duration("scheduler.election.ttl", e.TTL)
+		v.duration("scheduler.election.renew_interval", e.RenewInterval)
+		if !strings.EqualFold(e.Backend, "memory") {
+			v.required("scheduler.election.redis.addr", e.Redis.Addr)
+		}
+	}
+}
+
+func (v *validator) grpc() {
+	g := v.cfg.GRPC
+	if !g.Enabled {
+		return
+	}
+	v.between("grpc.port", float64(g.Port), 1, 65535)
+	if g.Port == v.cfg.Server.Port {
+		v.add("grpc.port", "must differ from server.port (%d)", v.cfg.Server.Port)
+	}
+}
+
+func (v *validator) moderation() {
+	m := v.cfg.Moderation
+	if !m.Enabled {
+		return
+	}
+	for i, rule := range m.Rules {
+		key := fmt.Sprintf("moderation.rules[%d]", i)
+		v.required(key+".name", rule.Name)
+		v.oneOf(key+".action", rule.Action, "block", "redact", "flag")
+		if len(rule.Keywords) == 0 && len(rule.Patterns) == 0 {
+			v.add(key, "requires keywords or patterns")
+		}
+		for j, p := range rule.Patterns {
+			v.pattern(fmt.Sprintf("%s.patterns[%d]", key, j), p)
+		}
+		for j, stage := range rule.Stages {
+			v.oneOf(fmt.Sprintf("%s.stages[%d]", key, j), stage, "input", "output")
+		}
+	}
+
+	c := m.Classifier
+	if c.Enabled {
+		if v.required("moderation.classifier.model", c.Model) {
+			v.model("moderation.classifier.model", c.Model)
+		}
+		v.oneOf("moderation.classifier.action", c.Action, "", "block", "flag")
+		for j, stage := range c.Stages {
+			v.oneOf(fmt.Sprintf("moderation.classifier.stages[%d]", j), stage, "input", "output")
+		}
+	}
+	v.oneOf("moderation.audit.store", m.Audit.Store, "", "memory", "file")
+	v.nonNegative("moderation.audit.max_entries", float64(m.Audit.MaxEntries))
+}
+
+// sortedKeys 按键排序，使报告的顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
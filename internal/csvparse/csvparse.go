@@ -0,0 +1,167 @@
+package csvparse
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options CSV解析选项，零值按RFC 4180解析（逗号分隔、双引号、自动识别编码）
+type Options struct {
+	Delimiter string // 字段分隔符，可以是多个字符（如 "||"、"\t"），默认 ","
+	Quote     string // 引号字符，默认 `"`；"none" 表示不识别引号
+	Escape    string // 引号内的转义字符（如 `\`），为空时按RFC 4180用两个引号表示一个引号
+	Encoding  string // 文件编码：auto（默认）、utf-8、gbk、gb18030、utf-16le、utf-16be，见Decode
+}
+
+// ParseError 解析错误，Line为出错的行号（从1开始）
+type ParseError struct {
+	Line int
+	Err  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("csv line %d: %s", e.Line, e.Err)
+}
+
+// Parse 解码并解析CSV，返回全部记录；空行跳过，各行字段数可以不同
+func Parse(data []byte, opts Options) ([][]string, error) {
+	text, _, err := Decode(data, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(text, opts)
+}
+
+// ParseString 解析已是UTF-8的CSV文本，开头的BOM会被去掉；Encoding选项被忽略
+func ParseString(text string, opts Options) ([][]string, error) {
+	delim, quote, escape, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimPrefix(text, bom)
+
+	var (
+		records    [][]string
+		record     []string
+		field      strings.Builder
+		inQuotes   bool
+		fieldStart = true // 当前字段还没有内容，此时遇到引号才开始引用
+		line       = 1
+		quoteLine  int
+	)
+	endField := func() {
+		record = append(record, field.String())
+		field.Reset()
+		fieldStart = true
+	}
+	endRecord := func() {
+		// 空行（没有任何字段内容）不产生记录
+		if len(record) > 0 || field.Len() > 0 || !fieldStart {
+			endField()
+			records = append(records, record)
+		}
+		record = nil
+		field.Reset()
+		fieldStart = true
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		if inQuotes {
+			switch {
+			case escape != 0 && r == escape && i+size < len(text):
+				next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+				field.WriteRune(next)
+				if next == '\n' {
+					line++
+				}
+				i += size + nextSize
+				continue
+			case r == quote:
+				if escape == 0 && strings.HasPrefix(text[i+size:], string(quote)) {
+					field.WriteRune(quote)
+					i += 2 * size
+					continue
+				}
+				inQuotes = false
+			default:
+				if r == '\n' {
+					line++
+				}
+				field.WriteRune(r)
+			}
+			i += size
+			continue
+		}
+
+		switch {
+		case quote != 0 && r == quote && fieldStart:
+			inQuotes, fieldStart, quoteLine = true, false, line
+		case strings.HasPrefix(text[i:], delim):
+			endField()
+			i += len(delim)
+			continue
+		case r == '\r' || r == '\n':
+			endRecord()
+			line++
+			if r == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+		default:
+			field.WriteRune(r)
+			fieldStart = false
+		}
+		i += size
+	}
+
+	if inQuotes {
+		return nil, &ParseError{Line: quoteLine, Err: "unterminated quoted field"}
+	}
+	endRecord()
+	return records, nil
+}
+
+// normalize 检查选项并返回分隔符、引号和转义字符（0表示不使用）
+func (o Options) normalize() (delim string, quote, escape rune, err error) {
+	delim = o.Delimiter
+	if delim == "" {
+		delim = ","
+	}
+	if strings.ContainsAny(delim, "\r\n") {
+		return "", 0, 0, fmt.Errorf("invalid delimiter %q", delim)
+	}
+
+	switch o.Quote {
+	case "":
+		quote = '"'
+	case "none":
+	default:
+		if utf8.RuneCountInString(o.Quote) != 1 {
+			return "", 0, 0, fmt.Errorf("quote must be a single character, got %q", o.Quote)
+		}
+		quote, _ = utf8.DecodeRuneInString(o.Quote)
+	}
+	if quote != 0 && strings.ContainsRune(delim, quote) {
+		return "", 0, 0, fmt.Errorf("delimiter %q must not contain the quote character", delim)
+	}
+
+	if o.Escape != "" {
+		if utf8.RuneCountInString(o.Escape) != 1 {
+			return "", 0, 0, fmt.Errorf("escape must be a single character, got %q", o.Escape)
+		}
+		escape, _ = utf8.DecodeRuneInString(o.Escape)
+	}
+	return delim, quote, escape, nil
+}
+
+// OptionsFromParams 从工具参数或任务要求中读取解析选项：delimiter、quote、escape、encoding
+func OptionsFromParams(params map[string]interface{}) Options {
+	var opts Options
+	opts.Delimiter, _ = params["delimiter"].(string)
+	opts.Quote, _ = params["quote"].(string)
+	opts.Escape, _ = params["escape"].(string)
+	opts.Encoding, _ = params["encoding"].(string)
+	return opts
+}
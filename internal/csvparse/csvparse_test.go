@@ -0,0 +1,107 @@
+package csvparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// TestParseString 测试多字符分隔符、引号和转义配置
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		opts Options
+		want [][]string
+	}{
+		{
+			name: "rfc4180",
+			text: "\ufeff姓名,备注\r\n张三,\"他说\"\"你好\"\"，\n换行\"\r\n\r\n李四,\n",
+			want: [][]string{{"姓名", "备注"}, {"张三", "他说\"你好\"，\n换行"}, {"李四", ""}},
+		},
+		{
+			name: "multi-char delimiter",
+			text: "a||b||c\n1||2|3||",
+			opts: Options{Delimiter: "||"},
+			want: [][]string{{"a", "b", "c"}, {"1", "2|3", ""}},
+		},
+		{
+			name: "backslash escape and single quote",
+			text: `'it\'s';'a\\b'` + "\n",
+			opts: Options{Delimiter: ";", Quote: "'", Escape: `\`},
+			want: [][]string{{"it's", `a\b`}},
+		},
+		{
+			name: "quotes disabled",
+			text: "\"a\t\"b\"",
+			opts: Options{Delimiter: "\t", Quote: "none"},
+			want: [][]string{{`"a`, `"b"`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseString(tt.text, tt.opts)
+			if err != nil {
+				t.Fatalf("ParseString failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var perr *ParseError
+	if _, err := ParseString("a,b\nc,\"unterminated\nd", Options{}); !errors.As(err, &perr) || perr.Line != 2 {
+		t.Errorf("expected unterminated quote error on line 2, got %v", err)
+	}
+	if _, err := ParseString("a", Options{Delimiter: `"`}); err == nil {
+		t.Error("delimiter containing the quote character should be rejected")
+	}
+}
+
+// TestDecode 测试BOM处理和GBK、UTF-16转码
+func TestDecode(t *testing.T) {
+	const text = "城市,人口\n北京,2189\n"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(text))
+	utf16le, _ := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(text))
+	utf16be, _ := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(text))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		detected string
+	}{
+		{"utf-8 bom", append([]byte("\ufeff"), text...), "", EncodingUTF8},
+		{"gbk detected", gbk, "auto", EncodingGB18030},
+		{"gbk explicit", gbk, "GB2312", EncodingGBK},
+		{"utf-16le without bom", utf16le, "", EncodingUTF16LE},
+		{"utf-16be with bom", utf16be, "", EncodingUTF16BE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detected, err := Decode(tt.data, tt.encoding)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if got != text || detected != tt.detected {
+				t.Errorf("got %q (%s), want %q (%s)", got, detected, text, tt.detected)
+			}
+		})
+	}
+
+	if _, _, err := Decode(gbk, "utf-8"); err == nil {
+		t.Error("invalid utf-8 should be rejected when encoding is utf-8")
+	}
+	if _, _, err := Decode([]byte(text), "latin-9"); err == nil {
+		t.Error("unsupported encoding should be rejected")
+	}
+
+	records, err := Parse(gbk, Options{})
+	if err != nil || len(records) != 2 || records[1][0] != "北京" {
+		t.Errorf("Parse gbk = %q, %v", records, err)
+	}
+}
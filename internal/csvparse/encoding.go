@@ -0,0 +1,116 @@
+package csvparse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 支持的编码名称
+const (
+	EncodingAuto    = "auto"
+	EncodingUTF8    = "utf-8"
+	EncodingGBK     = "gbk"
+	EncodingGB18030 = "gb18030"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+const bom = "\ufeff"
+
+// Decode 把文件内容转换为UTF-8文本并去掉BOM，返回实际使用的编码
+//
+// auto按以下顺序识别：BOM（UTF-8、UTF-16LE/BE）；合法的UTF-8；
+// 没有BOM但含大量0字节时按0字节的位置判断为UTF-16LE/BE；
+// 其余按GB18030解码（兼容GBK和GB2312，中文Windows下Excel导出的CSV默认编码）
+func Decode(data []byte, name string) (string, string, error) {
+	name = normalizeEncoding(name)
+	if name == EncodingAuto {
+		name = detect(data)
+	}
+
+	var enc encoding.Encoding
+	switch name {
+	case EncodingUTF8:
+		if !utf8.Valid(data) {
+			return "", name, fmt.Errorf("content is not valid utf-8")
+		}
+		return strings.TrimPrefix(string(data), bom), name, nil
+	case EncodingGBK:
+		enc = simplifiedchinese.GBK
+	case EncodingGB18030:
+		enc = simplifiedchinese.GB18030
+	case EncodingUTF16LE:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case EncodingUTF16BE:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	default:
+		return "", name, fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", name, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return strings.TrimPrefix(string(decoded), bom), name, nil
+}
+
+// normalizeEncoding 统一编码名称的写法，如 UTF8、utf_16le、GB2312
+func normalizeEncoding(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "_", "-")
+	switch name {
+	case "", EncodingAuto:
+		return EncodingAuto
+	case "utf8", EncodingUTF8:
+		return EncodingUTF8
+	case "gb2312", "cp936", EncodingGBK:
+		return EncodingGBK
+	case "utf-16", "utf16", "utf16le", EncodingUTF16LE:
+		return EncodingUTF16LE
+	case "utf16be", EncodingUTF16BE:
+		return EncodingUTF16BE
+	}
+	return name
+}
+
+// detect 自动识别编码
+func detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte(bom)):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return EncodingUTF16BE
+	case utf8.Valid(data):
+		return EncodingUTF8
+	}
+
+	// ASCII字符较多的UTF-16文本中，0字节集中在奇数位（LE）或偶数位（BE）
+	sample := data[:min(len(data), 4096)]
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case half > 0 && odd*4 >= half && odd > even*4:
+		return EncodingUTF16LE
+	case half > 0 && even*4 >= half && even > odd*4:
+		return EncodingUTF16BE
+	}
+	return EncodingGB18030
+}
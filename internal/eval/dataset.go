@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadDataset 读取测试用例文件
+// .jsonl 每行一个用例；.json 为用例数组或 {"test_cases": [...]}（与 /eval/run 的请求体一致）
+func LoadDataset(path string) ([]TestCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	var cases []TestCase
+	if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		cases, err = parseJSONLines(data)
+	} else {
+		cases, err = parseJSONDataset(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dataset %s: %w", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("dataset %s has no test cases", path)
+	}
+	return cases, nil
+}
+
+// parseJSONDataset 解析JSON数组或带test_cases字段的对象
+func parseJSONDataset(data []byte) ([]TestCase, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var cases []TestCase
+		err := json.Unmarshal(data, &cases)
+		return cases, err
+	}
+	var wrapped struct {
+		TestCases []TestCase `json:"test_cases"`
+	}
+	err := json.Unmarshal(data, &wrapped)
+	return wrapped.TestCases, err
+}
+
+// parseJSONLines 解析JSONL，跳过空行
+func parseJSONLines(data []byte) ([]TestCase, error) {
+	cases := make([]TestCase, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var tc TestCase
+		if err := json.Unmarshal(text, &tc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cases = append(cases, tc)
+	}
+	return cases, scanner.Err()
+}
@@ -1,11 +1,19 @@
 package eval
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
-	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/cron"
+	"ai-agent-assistant/internal/rag/adaptive"
+	"ai-agent-assistant/internal/rag/store"
 	"ai-agent-assistant/pkg/models"
 )
 
@@ -196,3 +204,401 @@ func TestPercentile(t *testing.T) {
 
 	t.Logf("P50: %v, P95: %v", p50, p95)
 }
+
+// stubRetriever 返回固定检索结果
+type stubRetriever struct{ results []string }
+
+func (r *stubRetriever) BuildContextWithResults(ctx context.Context, query string, topK int) (string, []string, error) {
+	return "参考信息：" + strings.Join(r.results, "\n"), r.results, nil
+}
+
+// TestHarnessRun 测试离线评估的评分、错误记录和报告输出
+func TestHarnessRun(t *testing.T) {
+	pipeline := NewPipelineFunc("stub", func(ctx context.Context, tc TestCase) (*PipelineOutput, error) {
+		switch tc.Input {
+		case "error":
+			return nil, errors.New("boom")
+		case "wrong":
+			return &PipelineOutput{Answer: "完全不同的回答内容"}, nil
+		}
+		return &PipelineOutput{Answer: tc.GetExpected()}, nil
+	})
+	dataset := []TestCase{
+		{Input: "1+1=?", Expected: "2"},
+		{Input: "wrong", Expected: "4"},
+		{Input: "error", Expected: "6"},
+		{Input: "capital", ExpectedText: "北京"},
+	}
+
+	report, err := NewHarness(pipeline, HarnessOptions{Dataset: "unit", Scoring: "exact_match"}).Run(context.Background(), dataset)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	s := report.Summary
+	if s.Total != 4 || s.Passed != 2 || s.Failed != 2 || s.Errors != 1 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if s.Accuracy != 0.5 {
+		t.Errorf("Expected accuracy 0.5, got %v", s.Accuracy)
+	}
+	if report.Cases[2].Error != "boom" {
+		t.Errorf("Expected pipeline error to be recorded, got %q", report.Cases[2].Error)
+	}
+
+	var jsonOut, htmlOut bytes.Buffer
+	if err := report.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded BenchmarkReport
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil || decoded.Summary.Passed != 2 {
+		t.Errorf("JSON report did not round-trip: %v %+v", err, decoded.Summary)
+	}
+	if err := report.WriteHTML(&htmlOut); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	if !strings.Contains(htmlOut.String(), "50.00%") {
+		t.Errorf("HTML report should contain the accuracy")
+	}
+}
+
+// TestRAGPipeline 测试RAG流水线把检索上下文作为系统消息并返回检索结果
+func TestRAGPipeline(t *testing.T) {
+	pipeline := NewRAGPipeline(&stubRetriever{results: []string{"Go由Google开发"}}, &MockEvalModel{}, 0)
+	output, err := pipeline.Run(context.Background(), TestCase{Input: "Go是谁开发的？"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if output.Answer != "测试响应" || len(output.Contexts) != 1 {
+		t.Errorf("Unexpected output: %+v", output)
+	}
+}
+
+// TestLoadDataset 测试JSON数组、test_cases对象和JSONL数据集
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"array.json":   `[{"input": "a", "expected_output": "1"}, {"input": "b", "expected": "2"}]`,
+		"wrapped.json": `{"test_cases": [{"input": "a", "expected_output": "1"}, {"input": "b", "expected_output": "2"}]}`,
+		"lines.jsonl":  "{\"input\": \"a\", \"expected_output\": \"1\"}\n\n{\"input\": \"b\", \"expected_output\": \"2\"}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cases, err := LoadDataset(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(cases) != 2 || cases[1].GetExpected() != "2" {
+			t.Errorf("%s: unexpected cases %+v", name, cases)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	os.WriteFile(empty, []byte(`[]`), 0o644)
+	if _, err := LoadDataset(empty); err == nil {
+		t.Error("Expected error for empty dataset")
+	}
+}
+
+// TestImportDataset 测试SQuAD、BEIR和CSV格式的导入与格式自动判断
+func TestImportDataset(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	squad := write("squad.json", `{"version": "v2.0", "data": [{"title": "Go", "paragraphs": [{
+		"context": "Go was designed at Google in 2007.",
+		"qas": [
+			{"id": "q1", "question": "Where was Go designed?", "answers": [{"text": "Google"}, {"text": "at Google"}]},
+			{"id": "q2", "question": "Who owns Rust?", "answers": [], "is_impossible": true}
+		]}]}]}`)
+	cases, err := ImportDataset(squad, ImportOptions{})
+	if err != nil {
+		t.Fatalf("squad: %v", err)
+	}
+	if len(cases) != 1 || cases[0].Expected != "Google" || cases[0].Metadata["id"] != "q1" {
+		t.Errorf("squad: unexpected cases %+v", cases)
+	}
+
+	beir := filepath.Join(dir, "beir")
+	write("beir/corpus.jsonl", `{"_id": "d1", "title": "Go", "text": "Go is compiled."}
+{"_id": "d2", "title": "", "text": "Go has goroutines."}
+{"_id": "d3", "title": "", "text": "Unrelated."}
+`)
+	write("beir/queries.jsonl", `{"_id": "q1", "text": "Is Go compiled?"}
+{"_id": "q2", "text": "Does Go have coroutines?"}
+{"_id": "q3", "text": "Not judged"}
+`)
+	write("beir/qrels/test.tsv", "query-id\tcorpus-id\tscore\nq2\td3\t0\nq2\td2\t1\nq1\td2\t1\nq1\td1\t2\n")
+	cases, err = ImportDataset(beir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("beir: %v", err)
+	}
+	if len(cases) != 2 || cases[0].Input != "Does Go have coroutines?" {
+		t.Fatalf("beir: unexpected cases %+v", cases)
+	}
+	if cases[1].Expected != "Go\nGo is compiled." {
+		t.Errorf("beir: expected highest scored doc, got %q", cases[1].Expected)
+	}
+	if docs := cases[1].Metadata["relevant_docs"].([]string); len(docs) != 2 || docs[0] != "d1" {
+		t.Errorf("beir: unexpected relevant docs %v", docs)
+	}
+	if _, err := ImportDataset(beir, ImportOptions{Split: "dev"}); err == nil {
+		t.Error("Expected error for missing qrels split")
+	}
+
+	csvPath := write("qa.csv", "\ufeffQuestion,Answer,Context,category\n\"What is 2+2?\",4,math||arithmetic,easy\n,skipped,,\nCapital of France?,Paris,,geo\n")
+	cases, err = ImportDataset(csvPath, ImportOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if len(cases) != 1 || cases[0].Expected != "4" || cases[0].Metadata["category"] != "easy" {
+		t.Errorf("csv: unexpected cases %+v", cases)
+	}
+	if contexts := cases[0].Metadata["contexts"].([]string); len(contexts) != 2 {
+		t.Errorf("csv: expected 2 contexts, got %v", contexts)
+	}
+	tsv := write("qa.tsv", "query\tground_truth\nWhat is Go?\tA language\n")
+	if cases, err = ImportDataset(tsv, ImportOptions{}); err != nil || len(cases) != 1 || cases[0].Expected != "A language" {
+		t.Errorf("tsv: unexpected result %+v, %v", cases, err)
+	}
+	if _, err := ImportDataset(write("bad.csv", "prompt,reply\na,b\n"), ImportOptions{}); err == nil {
+		t.Error("Expected error for csv without question and answer columns")
+	}
+
+	// 导出为JSONL后能用 LoadDataset 读回
+	var buf bytes.Buffer
+	if err := WriteDataset(&buf, cases); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDataset(write("converted.jsonl", buf.String()))
+	if err != nil || len(loaded) != 1 || loaded[0].Input != "What is Go?" {
+		t.Errorf("round trip: unexpected result %+v, %v", loaded, err)
+	}
+
+	if _, err := ImportDataset(squad, ImportOptions{Format: "parquet"}); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+// TestTrajectoryEvaluator 测试工具选择、冗余步骤、预算和逐步诊断
+func TestTrajectoryEvaluator(t *testing.T) {
+	run := &AgentRun{
+		ID:     "run-1",
+		Query:  "Go的GC是什么？",
+		Answer: "三色标记",
+		Steps: []RunStep{
+			{Tool: "search", Input: "Go GC"},
+			{Tool: "search", Input: "Go GC"},
+			{Tool: "calculator", Input: "1+1", Error: "failed"},
+			{Tool: "answer", Input: "三色标记"},
+		},
+		Duration: 2 * time.Second,
+	}
+
+	evaluator := NewTrajectoryEvaluator("exact_match", 0)
+	result := evaluator.Evaluate(run, "三色标记", TrajectoryExpectation{
+		Tools:        []string{"search", "answer"},
+		AllowedTools: []string{"calculator"},
+		MaxSteps:     4,
+	})
+	if !result.Passed {
+		t.Errorf("Expected trajectory to pass, issues: %v", result.Issues)
+	}
+	if result.RedundantSteps != 1 || result.FailedSteps != 1 || result.RedundancyScore != 0.75 {
+		t.Errorf("Unexpected redundancy: %+v", result)
+	}
+	if !result.Steps[0].Expected || result.Steps[1].Expected || !result.Steps[3].Expected {
+		t.Errorf("Unexpected expected-tool matches: %+v", result.Steps)
+	}
+	if len(result.Steps[1].Issues) != 1 || result.Steps[1].Issues[0] != IssueRedundant {
+		t.Errorf("Expected step 2 to be redundant, got %v", result.Steps[1].Issues)
+	}
+
+	result = evaluator.Evaluate(run, "", TrajectoryExpectation{
+		Tools:        []string{"search", "summarize"},
+		AllowedTools: []string{"search"},
+		MaxSteps:     2,
+	})
+	if result.Passed {
+		t.Error("Expected trajectory to fail")
+	}
+	if len(result.MissingTools) != 1 || result.MissingTools[0] != "summarize" {
+		t.Errorf("Expected summarize to be missing, got %v", result.MissingTools)
+	}
+	if result.BudgetScore != 0.5 {
+		t.Errorf("Expected budget score 0.5, got %v", result.BudgetScore)
+	}
+	if got := result.Steps[3].Issues; len(got) != 2 || got[0] != IssueUnexpectedTool || got[1] != IssueOverBudget {
+		t.Errorf("Unexpected issues for step 4: %v", got)
+	}
+}
+
+// TestEvaluateTrajectories 测试按查询匹配最近的轨迹并从metadata读取期望
+func TestEvaluateTrajectories(t *testing.T) {
+	trajectories := []*adaptive.Trajectory{
+		{
+			ID:           "new",
+			Query:        "q1",
+			Answer:       "a1",
+			Actions:      []adaptive.Action{{Tool: "search", Input: "q1"}},
+			Observations: []adaptive.Observation{{Content: "ok", Type: "result"}},
+			DurationMs:   100,
+		},
+		{
+			ID:           "old",
+			Query:        "q1",
+			Answer:       "a1",
+			Actions:      []adaptive.Action{{Tool: "search", Input: "q1"}, {Tool: "search", Input: "q1"}},
+			Observations: []adaptive.Observation{{Content: "boom", Type: "error"}, {Content: "ok", Type: "result"}},
+		},
+	}
+	dataset := []TestCase{
+		{Input: "q1", Expected: "a1", Metadata: map[string]interface{}{
+			"expected_tools": []interface{}{"search"},
+			"max_steps":      float64(1),
+			"max_duration":   "1s",
+		}},
+		{Input: "q2", Expected: "a2"},
+	}
+
+	report, err := NewTrajectoryEvaluator("exact_match", 0).EvaluateTrajectories(trajectories, dataset)
+	if err != nil {
+		t.Fatalf("EvaluateTrajectories failed: %v", err)
+	}
+	if report.Runs[0].RunID != "new" || !report.Runs[0].Passed {
+		t.Errorf("Expected the latest trajectory to pass, got %+v", report.Runs[0])
+	}
+	s := report.Summary
+	if s.Total != 2 || s.Passed != 1 || s.Missing != 1 || s.PassRate != 0.5 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+
+	dataset[0].Metadata["max_steps"] = "one"
+	if _, err := NewTrajectoryEvaluator("", 0).EvaluateTrajectories(trajectories, dataset); err == nil {
+		t.Error("Expected error for invalid max_steps")
+	}
+}
+
+// TestRunRetrievalBenchmark 测试内存后端的写入、查询指标和失败后端的记录
+func TestRunRetrievalBenchmark(t *testing.T) {
+	backends := []RetrievalBackend{
+		{Name: "memory", New: func(ctx context.Context, size, dimension int) (store.VectorStore, func(), error) {
+			return store.NewInMemoryVectorStore(nil), nil, nil
+		}},
+		{Name: "broken", New: func(ctx context.Context, size, dimension int) (store.VectorStore, func(), error) {
+			return nil, nil, errors.New("unreachable")
+		}},
+	}
+	report, err := RunRetrievalBenchmark(context.Background(), backends, RetrievalBenchOptions{
+		Sizes:     []int{50, 200},
+		Dimension: 16,
+		Queries:   20,
+		Seed:      1,
+	})
+	if err != nil {
+		t.Fatalf("RunRetrievalBenchmark failed: %v", err)
+	}
+	if len(report.Results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(report.Results))
+	}
+	for _, r := range report.Results {
+		switch r.Backend {
+		case "memory":
+			if r.Error != "" || r.Queries != 20 || r.QueryErrors != 0 || r.QPS <= 0 || r.IngestRate <= 0 {
+				t.Errorf("Unexpected memory result: %+v", r)
+			}
+		case "broken":
+			if !strings.Contains(r.Error, "unreachable") {
+				t.Errorf("Expected backend error to be recorded, got %+v", r)
+			}
+		}
+	}
+
+	var htmlOut bytes.Buffer
+	if err := report.WriteHTML(&htmlOut); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	if !strings.Contains(htmlOut.String(), "语料规模 200") {
+		t.Error("HTML report should group results by corpus size")
+	}
+}
+
+// recordingAlerter 记录收到的报警
+type recordingAlerter struct{ alerts []*RegressionAlert }
+
+func (a *recordingAlerter) Alert(ctx context.Context, alert *RegressionAlert) error {
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+// TestSchedulerRegression 测试首次运行成为基线、回归时报警以及提升基线
+func TestSchedulerRegression(t *testing.T) {
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "golden.jsonl")
+	os.WriteFile(dataset, []byte("{\"input\": \"a\", \"expected_output\": \"1\"}\n{\"input\": \"b\", \"expected_output\": \"2\"}\n"), 0o644)
+
+	correct := true
+	pipeline := NewPipelineFunc("stub", func(ctx context.Context, tc TestCase) (*PipelineOutput, error) {
+		if !correct && tc.Input == "b" {
+			return &PipelineOutput{Answer: "wrong"}, nil
+		}
+		return &PipelineOutput{Answer: tc.GetExpected()}, nil
+	})
+
+	store, err := NewRunStore(filepath.Join(dir, "results"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := &recordingAlerter{}
+	scheduler := NewScheduler(store, alerter)
+	schedule, _ := cron.Parse("@daily")
+	if err := scheduler.Add(Suite{Name: "golden", Schedule: schedule, Dataset: dataset, Pipeline: pipeline, Options: HarnessOptions{Scoring: "exact_match"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Add(Suite{Name: "bad/name", Schedule: schedule, Pipeline: pipeline}); err == nil {
+		t.Error("Expected invalid suite name to be rejected")
+	}
+
+	ctx := context.Background()
+	run, err := scheduler.Run(ctx, "golden")
+	if err != nil || run.Baseline != nil || run.Summary.Accuracy != 1 {
+		t.Fatalf("Expected first run to become the baseline: %+v %v", run, err)
+	}
+
+	correct = false
+	run, err = scheduler.Run(ctx, "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Regressions) != 2 || !run.Alerted || len(alerter.alerts) != 1 {
+		t.Fatalf("Expected accuracy and score regressions to be alerted: %+v", run)
+	}
+	if alerter.alerts[0].Baseline.Accuracy != 1 || alerter.alerts[0].Current.Accuracy != 0.5 {
+		t.Errorf("Unexpected alert: %+v", alerter.alerts[0])
+	}
+
+	if _, err := scheduler.PromoteBaseline("golden"); err != nil {
+		t.Fatal(err)
+	}
+	run, _ = scheduler.Run(ctx, "golden")
+	if len(run.Regressions) != 0 || len(alerter.alerts) != 1 {
+		t.Errorf("Expected no regression against the promoted baseline: %+v", run)
+	}
+	if status := scheduler.Status(); len(status) != 1 || status[0].LastRun == nil {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if _, err := scheduler.Run(ctx, "missing"); !errors.Is(err, ErrSuiteNotFound) {
+		t.Errorf("Expected ErrSuiteNotFound, got %v", err)
+	}
+}
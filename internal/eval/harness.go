@@ -0,0 +1,213 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/llm"
+	rageval "ai-agent-assistant/internal/rag/eval"
+	"ai-agent-assistant/pkg/models"
+)
+
+// PipelineOutput 流水线对一个测试用例的输出
+type PipelineOutput struct {
+	Answer   string    // 最终回答
+	Contexts []string  // 检索到的上下文，RAG流水线填写，用于计算RAGAS指标
+	Run      *AgentRun // 多步运行记录，工作流等流水线填写，用于轨迹评估
+}
+
+// Pipeline 离线评估的被测流水线（对话、RAG、工作流）
+type Pipeline interface {
+	Name() string
+	Run(ctx context.Context, tc TestCase) (*PipelineOutput, error)
+}
+
+// PipelineFunc 以函数实现的流水线
+type PipelineFunc struct {
+	name string
+	run  func(ctx context.Context, tc TestCase) (*PipelineOutput, error)
+}
+
+// NewPipelineFunc 用函数创建流水线
+func NewPipelineFunc(name string, run func(ctx context.Context, tc TestCase) (*PipelineOutput, error)) *PipelineFunc {
+	return &PipelineFunc{name: name, run: run}
+}
+
+// Name 流水线名称
+func (p *PipelineFunc) Name() string { return p.name }
+
+// Run 执行流水线
+func (p *PipelineFunc) Run(ctx context.Context, tc TestCase) (*PipelineOutput, error) {
+	return p.run(ctx, tc)
+}
+
+// ChatPipeline 直接用模型回答问题
+type ChatPipeline struct {
+	model llm.Model
+}
+
+// NewChatPipeline 创建对话流水线
+func NewChatPipeline(model llm.Model) *ChatPipeline {
+	return &ChatPipeline{model: model}
+}
+
+// Name 流水线名称
+func (p *ChatPipeline) Name() string { return "chat" }
+
+// Run 执行流水线
+func (p *ChatPipeline) Run(ctx context.Context, tc TestCase) (*PipelineOutput, error) {
+	answer, err := p.model.Chat(ctx, []models.Message{{Role: "user", Content: tc.Input}})
+	if err != nil {
+		return nil, err
+	}
+	return &PipelineOutput{Answer: answer}, nil
+}
+
+// Retriever 构建检索增强上下文，rag.RAG 实现了该接口
+type Retriever interface {
+	BuildContextWithResults(ctx context.Context, query string, topK int) (string, []string, error)
+}
+
+// RAGPipeline 先检索再生成，与 /chat/rag 的消息构造一致
+type RAGPipeline struct {
+	retriever Retriever
+	model     llm.Model
+	topK      int
+}
+
+// NewRAGPipeline 创建RAG流水线，topK不大于0时检索3条
+func NewRAGPipeline(retriever Retriever, model llm.Model, topK int) *RAGPipeline {
+	if topK <= 0 {
+		topK = 3
+	}
+	return &RAGPipeline{retriever: retriever, model: model, topK: topK}
+}
+
+// Name 流水线名称
+func (p *RAGPipeline) Name() string { return "rag" }
+
+// Run 执行流水线
+func (p *RAGPipeline) Run(ctx context.Context, tc TestCase) (*PipelineOutput, error) {
+	ragContext, results, err := p.retriever.BuildContextWithResults(ctx, tc.Input, p.topK)
+	if err != nil {
+		return nil, fmt.Errorf("retrieval failed: %w", err)
+	}
+	answer, err := p.model.Chat(ctx, []models.Message{
+		{Role: "system", Content: ragContext},
+		{Role: "user", Content: tc.Input},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PipelineOutput{Answer: answer, Contexts: results}, nil
+}
+
+// modelJudge 以对话模型作为RAGAS的评估模型
+type modelJudge struct {
+	model llm.Model
+}
+
+// NewModelJudge 把对话模型包装为RAGAS评估使用的LLMProvider
+func NewModelJudge(model llm.Model) rageval.LLMProvider {
+	return &modelJudge{model: model}
+}
+
+func (j *modelJudge) Generate(ctx context.Context, prompt string) (string, error) {
+	return j.model.Chat(ctx, []models.Message{{Role: "user", Content: prompt}})
+}
+
+// HarnessOptions 离线评估选项
+type HarnessOptions struct {
+	Dataset   string                  // 数据集名称，写入报告
+	Scoring   string                  // 答案评分方式：exact_match, similarity，默认similarity
+	Threshold float64                 // 相似度评分的通过阈值，默认0.8
+	RAGAS     *rageval.RAGASEvaluator // 为nil时不计算RAGAS指标；只对有检索上下文的用例计算
+}
+
+// Harness 离线评估：逐条执行数据集，计算准确率、RAGAS和延迟指标
+type Harness struct {
+	pipeline   Pipeline
+	accuracy   *AccuracyEval
+	trajectory *TrajectoryEvaluator
+	ragas      *rageval.RAGASEvaluator
+	dataset    string
+}
+
+// NewHarness 创建离线评估
+func NewHarness(pipeline Pipeline, opts HarnessOptions) *Harness {
+	return &Harness{
+		pipeline:   pipeline,
+		accuracy:   NewAccuracyEval(opts.Scoring, nil, opts.Threshold),
+		trajectory: NewTrajectoryEvaluator(opts.Scoring, opts.Threshold),
+		ragas:      opts.RAGAS,
+		dataset:    opts.Dataset,
+	}
+}
+
+// Run 执行评估，单个用例失败记录在报告中而不中断评估；ctx结束时返回已完成部分的报告和ctx的错误
+func (h *Harness) Run(ctx context.Context, dataset []TestCase) (*BenchmarkReport, error) {
+	report := &BenchmarkReport{
+		Pipeline:  h.pipeline.Name(),
+		Dataset:   h.dataset,
+		StartedAt: time.Now(),
+		Cases:     make([]BenchmarkCase, 0, len(dataset)),
+	}
+	// 服务商限流时评测的LLM调用排在对话和工作流之后
+	ctx = llm.WithPriority(ctx, llm.PriorityBatch)
+
+	for _, tc := range dataset {
+		if err := ctx.Err(); err != nil {
+			report.finish()
+			return report, err
+		}
+		report.Cases = append(report.Cases, h.runCase(ctx, tc))
+	}
+	report.finish()
+	return report, nil
+}
+
+// runCase 执行并评分一个用例
+func (h *Harness) runCase(ctx context.Context, tc TestCase) BenchmarkCase {
+	result := BenchmarkCase{
+		Input:    tc.Input,
+		Expected: tc.GetExpected(),
+		Metadata: tc.Metadata,
+	}
+
+	start := time.Now()
+	output, err := h.pipeline.Run(ctx, tc)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Actual = output.Answer
+	result.Contexts = output.Contexts
+	result.Score, result.Passed = h.accuracy.scoreResult(result.Expected, output.Answer)
+
+	// 有运行记录时评估轨迹，metadata中声明的轨迹期望不满足时用例不通过
+	if output.Run != nil {
+		expect, err := ExpectationFromMetadata(tc.Metadata)
+		if err != nil {
+			result.Error = err.Error()
+			result.Passed = false
+			return result
+		}
+		result.Trajectory = h.trajectory.Evaluate(output.Run, "", expect)
+		if !expect.isZero() && !result.Trajectory.Passed {
+			result.Passed = false
+		}
+	}
+
+	if h.ragas != nil && len(output.Contexts) > 0 {
+		ragas, err := h.ragas.Evaluate(ctx, tc.Input, output.Contexts, output.Answer, result.Expected)
+		if err != nil {
+			result.Error = fmt.Sprintf("ragas: %v", err)
+		} else {
+			result.RAGAS = ragas
+		}
+	}
+	return result
+}
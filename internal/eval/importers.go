@@ -0,0 +1,401 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ai-agent-assistant/internal/csvparse"
+)
+
+// 支持导入的数据集格式
+const (
+	FormatAuto  = "auto"  // 按扩展名和目录结构判断
+	FormatJSON  = "json"  // 本项目的JSON/JSONL用例格式
+	FormatSQuAD = "squad" // SQuAD v1.1/v2.0 JSON
+	FormatBEIR  = "beir"  // BEIR目录：corpus.jsonl、queries.jsonl、qrels/<split>.tsv
+	FormatCSV   = "csv"   // 带表头的CSV/TSV问答对
+)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	Format string // 为空或auto时自动判断
+	Split  string // BEIR的qrels划分，默认test
+	Limit  int    // 最多导入的用例数，0表示不限制
+}
+
+// ImportDataset 把公开基准或问答表格导入为测试用例，导入结果可用 WriteDataset 保存为JSONL数据集
+func ImportDataset(path string, opts ImportOptions) ([]TestCase, error) {
+	format := strings.ToLower(opts.Format)
+	if format == "" || format == FormatAuto {
+		var err error
+		if format, err = detectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var cases []TestCase
+	var err error
+	switch format {
+	case FormatJSON:
+		// LoadDataset 的错误已包含文件名
+		if cases, err = LoadDataset(path); err != nil {
+			return nil, err
+		}
+	case FormatSQuAD:
+		cases, err = importSQuAD(path)
+	case FormatBEIR:
+		cases, err = importBEIR(path, opts.Split)
+	case FormatCSV:
+		cases, err = importCSV(path)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %q, expected json, squad, beir or csv", opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s dataset %s: %w", format, path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s dataset %s has no test cases", format, path)
+	}
+	if opts.Limit > 0 && len(cases) > opts.Limit {
+		cases = cases[:opts.Limit]
+	}
+	return cases, nil
+}
+
+// detectFormat 目录按BEIR处理；.csv/.tsv按CSV处理；JSON文件顶层有data字段时按SQuAD处理，否则按本项目格式处理
+func detectFormat(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read dataset: %w", err)
+	}
+	if info.IsDir() {
+		return FormatBEIR, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		return FormatCSV, nil
+	case ".json":
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read dataset: %w", err)
+		}
+		defer file.Close()
+		var probe struct {
+			Data    json.RawMessage `json:"data"`
+			Version string          `json:"version"`
+		}
+		if json.NewDecoder(file).Decode(&probe) == nil && len(probe.Data) > 0 {
+			return FormatSQuAD, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// squadFile SQuAD数据集结构
+type squadFile struct {
+	Data []struct {
+		Title      string `json:"title"`
+		Paragraphs []struct {
+			Context string `json:"context"`
+			QAs     []struct {
+				ID           string `json:"id"`
+				Question     string `json:"question"`
+				IsImpossible bool   `json:"is_impossible"`
+				Answers      []struct {
+					Text string `json:"text"`
+				} `json:"answers"`
+			} `json:"qas"`
+		} `json:"paragraphs"`
+	} `json:"data"`
+}
+
+// importSQuAD 每个问题一个用例，第一个答案作为期望输出，其他答案记入metadata.answers；
+// 段落作为metadata.contexts，用于RAGAS计算；SQuAD 2.0中无答案的问题跳过
+func importSQuAD(path string) ([]TestCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var squad squadFile
+	if err := json.Unmarshal(data, &squad); err != nil {
+		return nil, err
+	}
+
+	cases := make([]TestCase, 0)
+	for _, article := range squad.Data {
+		for _, paragraph := range article.Paragraphs {
+			for _, qa := range paragraph.QAs {
+				if qa.IsImpossible || len(qa.Answers) == 0 {
+					continue
+				}
+				answers := make([]string, 0, len(qa.Answers))
+				for _, a := range qa.Answers {
+					answers = append(answers, a.Text)
+				}
+				cases = append(cases, TestCase{
+					Input:    qa.Question,
+					Expected: answers[0],
+					Metadata: map[string]interface{}{
+						"id":       qa.ID,
+						"title":    article.Title,
+						"answers":  answers,
+						"contexts": []string{paragraph.Context},
+						"source":   FormatSQuAD,
+					},
+				})
+			}
+		}
+	}
+	return cases, nil
+}
+
+// beirDoc BEIR语料中的文档
+type beirDoc struct {
+	ID    string `json:"_id"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// importBEIR 按qrels中的查询生成用例：相关度最高的文档作为期望输出，
+// 所有相关文档的ID和正文记入metadata.relevant_docs和metadata.contexts，用于检索质量评估
+func importBEIR(dir, split string) ([]TestCase, error) {
+	if split == "" {
+		split = "test"
+	}
+	qrels, order, err := readQrels(filepath.Join(dir, "qrels", split+".tsv"))
+	if err != nil {
+		return nil, err
+	}
+
+	queries := make(map[string]string, len(qrels))
+	err = readJSONLines(filepath.Join(dir, "queries.jsonl"), func(line []byte) error {
+		var q beirDoc
+		if err := json.Unmarshal(line, &q); err != nil {
+			return err
+		}
+		if _, ok := qrels[q.ID]; ok {
+			queries[q.ID] = q.Text
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 语料可能很大，只保留qrels中引用的文档
+	wanted := make(map[string]bool)
+	for _, docs := range qrels {
+		for _, d := range docs {
+			wanted[d.id] = true
+		}
+	}
+	corpus := make(map[string]string, len(wanted))
+	err = readJSONLines(filepath.Join(dir, "corpus.jsonl"), func(line []byte) error {
+		var doc beirDoc
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return err
+		}
+		if wanted[doc.ID] {
+			text := doc.Text
+			if doc.Title != "" {
+				text = doc.Title + "\n" + text
+			}
+			corpus[doc.ID] = text
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cases := make([]TestCase, 0, len(order))
+	for _, queryID := range order {
+		query, ok := queries[queryID]
+		if !ok {
+			continue
+		}
+		docs := qrels[queryID]
+		sort.SliceStable(docs, func(i, j int) bool { return docs[i].score > docs[j].score })
+		ids := make([]string, 0, len(docs))
+		contexts := make([]string, 0, len(docs))
+		for _, d := range docs {
+			if text, ok := corpus[d.id]; ok {
+				ids = append(ids, d.id)
+				contexts = append(contexts, text)
+			}
+		}
+		if len(contexts) == 0 {
+			continue
+		}
+		cases = append(cases, TestCase{
+			Input:    query,
+			Expected: contexts[0],
+			Metadata: map[string]interface{}{
+				"id":            queryID,
+				"relevant_docs": ids,
+				"contexts":      contexts,
+				"source":        FormatBEIR,
+			},
+		})
+	}
+	return cases, nil
+}
+
+// qrel 一条相关性标注
+type qrel struct {
+	id    string
+	score int
+}
+
+// readQrels 读取 query-id、corpus-id、score 三列的TSV（首行为表头），只保留score大于0的标注
+// 返回按首次出现顺序排列的查询ID
+func readQrels(path string) (map[string][]qrel, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	qrels := make(map[string][]qrel)
+	var order []string
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if line == 1 && len(fields) > 0 && fields[0] == "query-id" {
+			continue
+		}
+		if len(fields) < 3 {
+			if len(fields) == 1 && fields[0] == "" {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%s line %d: expected query-id, corpus-id and score", filepath.Base(path), line)
+		}
+		score, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s line %d: invalid score %q", filepath.Base(path), line, fields[2])
+		}
+		if score <= 0 {
+			continue
+		}
+		if _, ok := qrels[fields[0]]; !ok {
+			order = append(order, fields[0])
+		}
+		qrels[fields[0]] = append(qrels[fields[0]], qrel{id: fields[1], score: score})
+	}
+	return qrels, order, scanner.Err()
+}
+
+// readJSONLines 逐行读取JSONL文件，跳过空行
+func readJSONLines(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		if len(strings.TrimSpace(string(text))) == 0 {
+			continue
+		}
+		if err := fn(text); err != nil {
+			return fmt.Errorf("%s line %d: %w", filepath.Base(path), line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// CSV表头中可识别的列名（不区分大小写），其他列原样记入metadata
+var (
+	csvQuestionColumns = []string{"question", "query", "input"}
+	csvAnswerColumns   = []string{"answer", "expected", "expected_output", "ground_truth"}
+	csvContextColumns  = []string{"context", "contexts"}
+)
+
+// importCSV 读取带表头的问答表格，.tsv以制表符分隔；必须有问题列和答案列
+// 自动识别编码（BOM、UTF-8、GBK、UTF-16），context列记入metadata.contexts，多个上下文用 || 分隔
+func importCSV(path string) ([]TestCase, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	opts := csvparse.Options{}
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		opts.Delimiter = "\t"
+	}
+	records, err := csvparse.Parse(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	question, answer, context := columnIndex(header, csvQuestionColumns), columnIndex(header, csvAnswerColumns), columnIndex(header, csvContextColumns)
+	if question < 0 || answer < 0 {
+		return nil, fmt.Errorf("header must include a question column (%s) and an answer column (%s)",
+			strings.Join(csvQuestionColumns, "/"), strings.Join(csvAnswerColumns, "/"))
+	}
+
+	cases := make([]TestCase, 0)
+	for _, record := range records[1:] {
+		if question >= len(record) || strings.TrimSpace(record[question]) == "" {
+			continue
+		}
+		tc := TestCase{Input: record[question], Metadata: map[string]interface{}{"source": FormatCSV}}
+		if answer < len(record) {
+			tc.Expected = record[answer]
+		}
+		for i, value := range record {
+			switch {
+			case i == question || i == answer || i >= len(header) || value == "":
+			case i == context:
+				tc.Metadata["contexts"] = strings.Split(value, "||")
+			default:
+				tc.Metadata[header[i]] = value
+			}
+		}
+		cases = append(cases, tc)
+	}
+	return cases, nil
+}
+
+// columnIndex 第一个匹配的列
+func columnIndex(header, names []string) int {
+	for _, name := range names {
+		for i, h := range header {
+			if h == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// WriteDataset 以JSONL格式写出用例，可被 LoadDataset 读取
+func WriteDataset(w io.Writer, cases []TestCase) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, tc := range cases {
+		if err := enc.Encode(tc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -27,6 +27,7 @@ func (m *Manager) AddEvaluator(evaluator Evaluator) {
 // RunEvaluations 运行所有评估
 func (m *Manager) RunEvaluations(ctx context.Context, model llm.Model, dataset []TestCase) ([]*EvalResult, error) {
 	results := make([]*EvalResult, 0, len(m.evaluators))
+	ctx = llm.WithPriority(ctx, llm.PriorityBatch)
 
 	for _, evaluator := range m.evaluators {
 		result, err := evaluator.Evaluate(ctx, model, dataset)
@@ -2,6 +2,7 @@ package eval
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"ai-agent-assistant/internal/llm"
@@ -134,8 +135,11 @@ func percentile(latencies []time.Duration, p float64) time.Duration {
 		}
 	}
 
-	// 计算索引
-	index := int(float64(len(latencies)-1) * p)
+	// 计算索引（nearest-rank：不小于p比例样本的最小值）
+	index := int(math.Ceil(float64(len(latencies))*p)) - 1
+	if index < 0 {
+		index = 0
+	}
 
 	return latencies[index]
 }
@@ -0,0 +1,83 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+)
+
+// 回归检查的默认阈值
+const (
+	defaultMaxAccuracyDrop = 0.05
+	defaultMaxScoreDrop    = 0.05
+)
+
+// RegressionThresholds 与基线比较时允许的指标变化，为0时准确率和得分使用默认值0.05，延迟不检查
+type RegressionThresholds struct {
+	MaxAccuracyDrop    float64 `json:"max_accuracy_drop"`
+	MaxScoreDrop       float64 `json:"max_score_drop"`
+	MaxLatencyIncrease float64 `json:"max_latency_increase,omitempty"` // P95延迟增长比例
+}
+
+// Regression 一项超出阈值的指标变化
+type Regression struct {
+	Metric   string  `json:"metric"` // accuracy, avg_score, p95_latency_ms
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Change   float64 `json:"change"` // 准确率和得分为差值，延迟为增长比例
+	Limit    float64 `json:"limit"`
+}
+
+// String 可读的描述，用于日志
+func (r Regression) String() string {
+	if r.Metric == "p95_latency_ms" {
+		return fmt.Sprintf("%s %.0f -> %.0f (+%.1f%%, limit +%.1f%%)", r.Metric, r.Baseline, r.Current, r.Change*100, r.Limit*100)
+	}
+	return fmt.Sprintf("%s %.3f -> %.3f (%+.3f, limit -%.3f)", r.Metric, r.Baseline, r.Current, r.Change, r.Limit)
+}
+
+// CompareReports 比较本次评估与基线的汇总指标，返回超出阈值的回归项
+func CompareReports(baseline, current BenchmarkSummary, th RegressionThresholds) []Regression {
+	if th.MaxAccuracyDrop <= 0 {
+		th.MaxAccuracyDrop = defaultMaxAccuracyDrop
+	}
+	if th.MaxScoreDrop <= 0 {
+		th.MaxScoreDrop = defaultMaxScoreDrop
+	}
+
+	var regressions []Regression
+	if drop := baseline.Accuracy - current.Accuracy; drop > th.MaxAccuracyDrop {
+		regressions = append(regressions, Regression{
+			Metric: "accuracy", Baseline: baseline.Accuracy, Current: current.Accuracy, Change: -drop, Limit: th.MaxAccuracyDrop,
+		})
+	}
+	if drop := baseline.AvgScore - current.AvgScore; drop > th.MaxScoreDrop {
+		regressions = append(regressions, Regression{
+			Metric: "avg_score", Baseline: baseline.AvgScore, Current: current.AvgScore, Change: -drop, Limit: th.MaxScoreDrop,
+		})
+	}
+	if th.MaxLatencyIncrease > 0 && baseline.P95LatencyMs > 0 {
+		increase := float64(current.P95LatencyMs-baseline.P95LatencyMs) / float64(baseline.P95LatencyMs)
+		if increase > th.MaxLatencyIncrease {
+			regressions = append(regressions, Regression{
+				Metric:   "p95_latency_ms",
+				Baseline: float64(baseline.P95LatencyMs),
+				Current:  float64(current.P95LatencyMs),
+				Change:   increase,
+				Limit:    th.MaxLatencyIncrease,
+			})
+		}
+	}
+	return regressions
+}
+
+// RegressionAlert 定时评估发现回归时发出的报警
+type RegressionAlert struct {
+	Suite       string           `json:"suite"`
+	Dataset     string           `json:"dataset,omitempty"`
+	Pipeline    string           `json:"pipeline"`
+	RunAt       time.Time        `json:"run_at"`
+	BaselineAt  time.Time        `json:"baseline_at"`
+	Baseline    BenchmarkSummary `json:"baseline"`
+	Current     BenchmarkSummary `json:"current"`
+	Regressions []Regression     `json:"regressions"`
+}
@@ -0,0 +1,187 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	rageval "ai-agent-assistant/internal/rag/eval"
+)
+
+// BenchmarkReport 离线评估报告
+type BenchmarkReport struct {
+	Pipeline  string           `json:"pipeline"`
+	Dataset   string           `json:"dataset,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	Duration  time.Duration    `json:"duration"`
+	Summary   BenchmarkSummary `json:"summary"`
+	Cases     []BenchmarkCase  `json:"cases"`
+}
+
+// BenchmarkSummary 评估汇总指标
+type BenchmarkSummary struct {
+	Total        int                `json:"total"`
+	Passed       int                `json:"passed"`
+	Failed       int                `json:"failed"`
+	Errors       int                `json:"errors"`   // 流水线执行失败的用例数，计入Failed
+	Accuracy     float64            `json:"accuracy"` // Passed / Total
+	AvgScore     float64            `json:"avg_score"`
+	AvgLatencyMs int64              `json:"avg_latency_ms"`
+	P50LatencyMs int64              `json:"p50_latency_ms"`
+	P95LatencyMs int64              `json:"p95_latency_ms"`
+	P99LatencyMs int64              `json:"p99_latency_ms"`
+	RAGAS        *RAGASSummary      `json:"ragas,omitempty"`
+	Trajectory   *TrajectorySummary `json:"trajectory,omitempty"` // 只统计有运行记录的用例
+}
+
+// RAGASSummary RAGAS指标的平均值，只统计计算了RAGAS的用例
+type RAGASSummary struct {
+	Cases            int     `json:"cases"`
+	ContextPrecision float64 `json:"context_precision"`
+	ContextRecall    float64 `json:"context_recall"`
+	AnswerRelevancy  float64 `json:"answer_relevancy"`
+	Faithfulness     float64 `json:"faithfulness"`
+	OverallScore     float64 `json:"overall_score"`
+}
+
+// BenchmarkCase 单个用例的评估结果
+type BenchmarkCase struct {
+	Input      string                 `json:"input"`
+	Expected   string                 `json:"expected"`
+	Actual     string                 `json:"actual"`
+	Contexts   []string               `json:"contexts,omitempty"`
+	Passed     bool                   `json:"passed"`
+	Score      float64                `json:"score"`
+	LatencyMs  int64                  `json:"latency_ms"`
+	Error      string                 `json:"error,omitempty"`
+	RAGAS      *rageval.RAGASResult   `json:"ragas,omitempty"`
+	Trajectory *TrajectoryResult      `json:"trajectory,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// finish 计算汇总指标
+func (r *BenchmarkReport) finish() {
+	r.Duration = time.Since(r.StartedAt)
+	s := BenchmarkSummary{Total: len(r.Cases)}
+	if s.Total == 0 {
+		r.Summary = s
+		return
+	}
+
+	var totalScore float64
+	var totalLatency time.Duration
+	latencies := make([]time.Duration, 0, len(r.Cases))
+	ragas := &RAGASSummary{}
+	trajectories := &TrajectoryReport{}
+	for _, c := range r.Cases {
+		if c.Passed {
+			s.Passed++
+		} else {
+			s.Failed++
+		}
+		if c.Error != "" && c.Actual == "" {
+			s.Errors++
+		}
+		totalScore += c.Score
+		latency := time.Duration(c.LatencyMs) * time.Millisecond
+		totalLatency += latency
+		latencies = append(latencies, latency)
+
+		if c.RAGAS != nil {
+			ragas.Cases++
+			ragas.ContextPrecision += c.RAGAS.ContextPrecision
+			ragas.ContextRecall += c.RAGAS.ContextRecall
+			ragas.AnswerRelevancy += c.RAGAS.AnswerRelevancy
+			ragas.Faithfulness += c.RAGAS.Faithfulness
+			ragas.OverallScore += c.RAGAS.OverallScore
+		}
+		if c.Trajectory != nil {
+			trajectories.Runs = append(trajectories.Runs, *c.Trajectory)
+		}
+	}
+
+	n := float64(s.Total)
+	s.Accuracy = float64(s.Passed) / n
+	s.AvgScore = totalScore / n
+	s.AvgLatencyMs = (totalLatency / time.Duration(s.Total)).Milliseconds()
+	s.P50LatencyMs = percentile(latencies, 0.50).Milliseconds()
+	s.P95LatencyMs = percentile(latencies, 0.95).Milliseconds()
+	s.P99LatencyMs = percentile(latencies, 0.99).Milliseconds()
+	if ragas.Cases > 0 {
+		m := float64(ragas.Cases)
+		ragas.ContextPrecision /= m
+		ragas.ContextRecall /= m
+		ragas.AnswerRelevancy /= m
+		ragas.Faithfulness /= m
+		ragas.OverallScore /= m
+		s.RAGAS = ragas
+	}
+	if len(trajectories.Runs) > 0 {
+		trajectories.finish()
+		s.Trajectory = &trajectories.Summary
+	}
+	r.Summary = s
+}
+
+// WriteJSON 以JSON格式输出报告
+func (r *BenchmarkReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHTML 以HTML格式输出报告，便于在CI产物中直接查看
+func (r *BenchmarkReport) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"join":    strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>评估报告 - {{.Pipeline}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+tr.fail td { background: #fff4f4; }
+pre { white-space: pre-wrap; margin: 0; }
+</style>
+</head>
+<body>
+<h1>评估报告</h1>
+<p>流水线：{{.Pipeline}}{{if .Dataset}}，数据集：{{.Dataset}}{{end}}，开始时间：{{.StartedAt.Format "2006-01-02 15:04:05"}}，耗时：{{.Duration}}</p>
+<h2>汇总</h2>
+<table>
+<tr><th>用例数</th><th>通过</th><th>失败</th><th>执行错误</th><th>准确率</th><th>平均得分</th><th>平均延迟</th><th>P50</th><th>P95</th><th>P99</th></tr>
+<tr><td>{{.Summary.Total}}</td><td>{{.Summary.Passed}}</td><td>{{.Summary.Failed}}</td><td>{{.Summary.Errors}}</td><td>{{percent .Summary.Accuracy}}</td><td>{{printf "%.3f" .Summary.AvgScore}}</td><td>{{.Summary.AvgLatencyMs}}ms</td><td>{{.Summary.P50LatencyMs}}ms</td><td>{{.Summary.P95LatencyMs}}ms</td><td>{{.Summary.P99LatencyMs}}ms</td></tr>
+</table>
+{{with .Summary.RAGAS}}
+<h2>RAGAS（{{.Cases}}个用例）</h2>
+<table>
+<tr><th>Context Precision</th><th>Context Recall</th><th>Answer Relevancy</th><th>Faithfulness</th><th>Overall</th></tr>
+<tr><td>{{percent .ContextPrecision}}</td><td>{{percent .ContextRecall}}</td><td>{{percent .AnswerRelevancy}}</td><td>{{percent .Faithfulness}}</td><td>{{percent .OverallScore}}</td></tr>
+</table>
+{{end}}
+{{with .Summary.Trajectory}}
+<h2>轨迹（{{.Total}}个用例）</h2>
+<table>
+<tr><th>轨迹通过率</th><th>工具选择</th><th>非冗余步骤</th><th>预算</th><th>平均步数</th></tr>
+<tr><td>{{percent .PassRate}}</td><td>{{percent .AvgToolChoiceScore}}</td><td>{{percent .AvgRedundancyScore}}</td><td>{{percent .AvgBudgetScore}}</td><td>{{printf "%.1f" .AvgSteps}}</td></tr>
+</table>
+{{end}}
+<h2>用例</h2>
+<table>
+<tr><th>#</th><th>输入</th><th>期望</th><th>实际</th><th>得分</th><th>延迟</th><th>轨迹</th><th>错误</th></tr>
+{{range $i, $c := .Cases}}<tr{{if not $c.Passed}} class="fail"{{end}}><td>{{$i}}</td><td><pre>{{$c.Input}}</pre></td><td><pre>{{$c.Expected}}</pre></td><td><pre>{{$c.Actual}}</pre></td><td>{{printf "%.3f" $c.Score}}</td><td>{{$c.LatencyMs}}ms</td><td>{{with $c.Trajectory}}{{printf "%.3f" .Score}}{{range .Issues}}<br>{{.}}{{end}}{{range .Steps}}{{if .Issues}}<br>#{{.Index}} {{.Tool}}: {{join .Issues ", "}}{{end}}{{end}}{{end}}</td><td>{{$c.Error}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
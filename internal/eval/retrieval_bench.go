@@ -0,0 +1,330 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/rag/store"
+)
+
+// 检索基准测试的默认参数
+const (
+	defaultBenchDimension   = 128
+	defaultBenchQueries     = 200
+	defaultBenchConcurrency = 4
+	defaultBenchTopK        = 5
+	defaultBenchBatchSize   = 100
+)
+
+// DefaultBenchSizes 默认的语料规模
+var DefaultBenchSizes = []int{1000, 10000}
+
+// RetrievalBackend 参与基准测试的向量存储后端
+// New 为每个语料规模创建一个空存储，cleanup 在该规模测完后调用（如删除临时集合），可以为nil
+type RetrievalBackend struct {
+	Name string
+	New  func(ctx context.Context, size, dimension int) (vs store.VectorStore, cleanup func(), err error)
+}
+
+// RetrievalBenchOptions 检索基准测试选项，字段为零值时使用默认值
+type RetrievalBenchOptions struct {
+	Sizes       []int `json:"sizes"`       // 语料规模，默认 1000 和 10000
+	Dimension   int   `json:"dimension"`   // 向量维度，默认128
+	Queries     int   `json:"queries"`     // 每个规模的查询次数，默认200
+	Concurrency int   `json:"concurrency"` // 并发查询数，默认4
+	TopK        int   `json:"top_k"`       // 每次查询返回条数，默认5
+	BatchSize   int   `json:"batch_size"`  // 存储支持批量写入时每批条数，默认100
+	Seed        int64 `json:"seed"`        // 合成语料的随机种子，相同种子生成相同语料
+}
+
+// withDefaults 填充默认值
+func (o RetrievalBenchOptions) withDefaults() RetrievalBenchOptions {
+	if len(o.Sizes) == 0 {
+		o.Sizes = DefaultBenchSizes
+	}
+	if o.Dimension <= 0 {
+		o.Dimension = defaultBenchDimension
+	}
+	if o.Queries <= 0 {
+		o.Queries = defaultBenchQueries
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultBenchConcurrency
+	}
+	if o.TopK <= 0 {
+		o.TopK = defaultBenchTopK
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBenchBatchSize
+	}
+	return o
+}
+
+// RetrievalBenchResult 一个后端在一个语料规模下的测试结果
+type RetrievalBenchResult struct {
+	Backend      string  `json:"backend"`
+	CorpusSize   int     `json:"corpus_size"`
+	IngestMs     int64   `json:"ingest_ms"`
+	IngestRate   float64 `json:"ingest_rate"` // 每秒写入条数
+	Queries      int     `json:"queries"`
+	QueryErrors  int     `json:"query_errors"`
+	QPS          float64 `json:"qps"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P50LatencyMs float64 `json:"p50_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+	Error        string  `json:"error,omitempty"` // 创建存储或写入失败时该项没有查询指标
+}
+
+// RetrievalBenchReport 检索基准测试报告
+type RetrievalBenchReport struct {
+	StartedAt time.Time              `json:"started_at"`
+	Duration  time.Duration          `json:"duration"`
+	Options   RetrievalBenchOptions  `json:"options"`
+	Results   []RetrievalBenchResult `json:"results"`
+}
+
+// RunRetrievalBenchmark 对每个后端、每个语料规模写入合成语料并并发查询，测量写入速率、QPS和延迟分位数
+// 单个后端失败记录在结果中而不中断测试；ctx结束时返回已完成部分的报告和ctx的错误
+func RunRetrievalBenchmark(ctx context.Context, backends []RetrievalBackend, opts RetrievalBenchOptions) (*RetrievalBenchReport, error) {
+	opts = opts.withDefaults()
+	report := &RetrievalBenchReport{
+		StartedAt: time.Now(),
+		Options:   opts,
+		Results:   make([]RetrievalBenchResult, 0, len(backends)*len(opts.Sizes)),
+	}
+
+	for _, size := range opts.Sizes {
+		corpus := syntheticVectors(opts.Seed, size, opts.Dimension)
+		queries := syntheticQueries(opts.Seed, corpus, opts.Queries)
+		for _, backend := range backends {
+			if err := ctx.Err(); err != nil {
+				report.Duration = time.Since(report.StartedAt)
+				return report, err
+			}
+			report.Results = append(report.Results, benchBackend(ctx, backend, corpus, queries, opts))
+		}
+	}
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+// benchBackend 测试一个后端在一个语料规模下的表现
+func benchBackend(ctx context.Context, backend RetrievalBackend, corpus []store.Vector, queries [][]float64, opts RetrievalBenchOptions) RetrievalBenchResult {
+	result := RetrievalBenchResult{Backend: backend.Name, CorpusSize: len(corpus)}
+	vs, cleanup, err := backend.New(ctx, len(corpus), opts.Dimension)
+	if err != nil {
+		result.Error = fmt.Sprintf("create store: %v", err)
+		return result
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	start := time.Now()
+	if err := ingest(ctx, vs, corpus, opts.BatchSize); err != nil {
+		result.Error = fmt.Sprintf("ingest: %v", err)
+		return result
+	}
+	elapsed := time.Since(start)
+	result.IngestMs = elapsed.Milliseconds()
+	if elapsed > 0 {
+		result.IngestRate = float64(len(corpus)) / elapsed.Seconds()
+	}
+
+	latencies, errs, elapsed := runQueries(ctx, vs, queries, opts.Concurrency, opts.TopK)
+	result.Queries = len(queries)
+	result.QueryErrors = errs
+	if elapsed > 0 {
+		result.QPS = float64(len(latencies)) / elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		var total time.Duration
+		for _, l := range latencies {
+			total += l
+		}
+		result.AvgLatencyMs = durationMs(total / time.Duration(len(latencies)))
+		result.P50LatencyMs = durationMs(percentile(latencies, 0.50))
+		result.P95LatencyMs = durationMs(percentile(latencies, 0.95))
+		result.P99LatencyMs = durationMs(percentile(latencies, 0.99))
+	}
+	return result
+}
+
+// ingest 写入语料，存储支持批量写入时按批写入
+func ingest(ctx context.Context, vs store.VectorStore, corpus []store.Vector, batchSize int) error {
+	if batcher, ok := vs.(store.BatchAdder); ok {
+		for i := 0; i < len(corpus); i += batchSize {
+			end := i + batchSize
+			if end > len(corpus) {
+				end = len(corpus)
+			}
+			if err := batcher.AddBatch(ctx, corpus[i:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, v := range corpus {
+		if err := vs.Add(ctx, v.Data, v.Text, v.Metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runQueries 并发执行查询，返回成功查询的延迟、失败次数和总耗时
+func runQueries(ctx context.Context, vs store.VectorStore, queries [][]float64, concurrency, topK int) ([]time.Duration, int, time.Duration) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies = make([]time.Duration, 0, len(queries))
+		errs      int
+	)
+	next := make(chan []float64)
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range next {
+				begin := time.Now()
+				_, err := vs.Search(ctx, q, topK)
+				latency := time.Since(begin)
+				mu.Lock()
+				if err != nil {
+					errs++
+				} else {
+					latencies = append(latencies, latency)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, q := range queries {
+		next <- q
+	}
+	close(next)
+	wg.Wait()
+	return latencies, errs, time.Since(start)
+}
+
+// syntheticVectors 生成单位长度的随机向量作为合成语料
+func syntheticVectors(seed int64, size, dimension int) []store.Vector {
+	rng := rand.New(rand.NewSource(seed))
+	corpus := make([]store.Vector, size)
+	for i := range corpus {
+		corpus[i] = store.Vector{
+			Data: randomUnitVector(rng, dimension),
+			Text: fmt.Sprintf("synthetic document %d", i),
+			Metadata: map[string]interface{}{
+				"source": "benchmark",
+			},
+		}
+	}
+	return corpus
+}
+
+// syntheticQueries 在语料向量上加噪声生成查询，使每个查询都有明确的近邻
+func syntheticQueries(seed int64, corpus []store.Vector, n int) [][]float64 {
+	rng := rand.New(rand.NewSource(seed + 1))
+	queries := make([][]float64, n)
+	for i := range queries {
+		base := corpus[rng.Intn(len(corpus))].Data
+		noise := randomUnitVector(rng, len(base))
+		q := make([]float64, len(base))
+		for j := range q {
+			q[j] = base[j] + 0.1*noise[j]
+		}
+		queries[i] = q
+	}
+	return queries
+}
+
+// randomUnitVector 生成随机单位向量
+func randomUnitVector(rng *rand.Rand, dimension int) []float64 {
+	v := make([]float64, dimension)
+	var norm float64
+	for i := range v {
+		v[i] = rng.NormFloat64()
+		norm += v[i] * v[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// durationMs 以毫秒表示时长，保留小数以区分内存后端的微秒级延迟
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteJSON 以JSON格式输出报告
+func (r *RetrievalBenchReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHTML 以HTML格式输出报告，按语料规模分组对比各后端
+func (r *RetrievalBenchReport) WriteHTML(w io.Writer) error {
+	groups := make(map[int][]RetrievalBenchResult)
+	for _, result := range r.Results {
+		groups[result.CorpusSize] = append(groups[result.CorpusSize], result)
+	}
+	sizes := make([]int, 0, len(groups))
+	for size := range groups {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	type sizeGroup struct {
+		Size    int
+		Results []RetrievalBenchResult
+	}
+	data := struct {
+		*RetrievalBenchReport
+		Groups []sizeGroup
+	}{RetrievalBenchReport: r}
+	for _, size := range sizes {
+		data.Groups = append(data.Groups, sizeGroup{Size: size, Results: groups[size]})
+	}
+	return retrievalBenchTemplate.Execute(w, data)
+}
+
+var retrievalBenchTemplate = template.Must(template.New("retrieval").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>检索基准测试</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 6px 8px; text-align: left; }
+th { background: #f5f5f5; }
+tr.fail td { background: #fff4f4; }
+</style>
+</head>
+<body>
+<h1>检索基准测试</h1>
+<p>开始时间：{{.StartedAt.Format "2006-01-02 15:04:05"}}，耗时：{{.Duration}}，维度：{{.Options.Dimension}}，每个规模查询{{.Options.Queries}}次，并发{{.Options.Concurrency}}，TopK {{.Options.TopK}}</p>
+{{range .Groups}}
+<h2>语料规模 {{.Size}}</h2>
+<table>
+<tr><th>后端</th><th>写入耗时</th><th>写入速率（条/秒）</th><th>QPS</th><th>平均延迟</th><th>P50</th><th>P95</th><th>P99</th><th>查询失败</th><th>错误</th></tr>
+{{range .Results}}<tr{{if .Error}} class="fail"{{end}}><td>{{.Backend}}</td><td>{{.IngestMs}}ms</td><td>{{printf "%.0f" .IngestRate}}</td><td>{{printf "%.1f" .QPS}}</td><td>{{printf "%.3f" .AvgLatencyMs}}ms</td><td>{{printf "%.3f" .P50LatencyMs}}ms</td><td>{{printf "%.3f" .P95LatencyMs}}ms</td><td>{{printf "%.3f" .P99LatencyMs}}ms</td><td>{{.QueryErrors}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,486 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/cron"
+	"ai-agent-assistant/internal/jobs"
+)
+
+var (
+	// ErrSuiteNotFound 评估套件不存在
+	ErrSuiteNotFound = errors.New("eval suite not found")
+	// ErrSuiteRunning 评估套件正在运行
+	ErrSuiteRunning = errors.New("eval suite is already running")
+	// ErrNoRuns 评估套件还没有运行记录
+	ErrNoRuns = errors.New("eval suite has no runs")
+)
+
+// 定时评估的默认参数
+const (
+	defaultResultsDir   = "./data/eval"
+	defaultSuiteTimeout = 30 * time.Minute
+)
+
+// suiteName 套件名称用作目录名，只允许字母、数字、- 和 _
+var suiteName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Suite 按cron定时运行的评估套件
+type Suite struct {
+	Name       string
+	Schedule   *cron.Schedule
+	Dataset    string // 黄金数据集文件或BEIR目录，每次运行时重新读取，格式自动判断
+	Pipeline   Pipeline
+	Options    HarnessOptions
+	Timeout    time.Duration // 单次运行的最长时间，默认30分钟
+	Thresholds RegressionThresholds
+}
+
+// SuiteRun 一次定时评估的结果，完整报告保存在 RunStore 中
+type SuiteRun struct {
+	Suite       string            `json:"suite"`
+	RunAt       time.Time         `json:"run_at"`
+	Summary     BenchmarkSummary  `json:"summary"`
+	Baseline    *BenchmarkSummary `json:"baseline,omitempty"` // 为nil表示本次运行成为基线
+	BaselineAt  *time.Time        `json:"baseline_at,omitempty"`
+	Regressions []Regression      `json:"regressions,omitempty"`
+	Alerted     bool              `json:"alerted"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// RunStore 按套件保存每次运行的报告和基线
+// 目录结构：<dir>/<suite>/runs/<时间>.json 和 <dir>/<suite>/baseline.json
+type RunStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewRunStore 创建运行记录存储，dir为空时使用 ./data/eval
+func NewRunStore(dir string) (*RunStore, error) {
+	if dir == "" {
+		dir = defaultResultsDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create eval results directory: %w", err)
+	}
+	return &RunStore{dir: dir}, nil
+}
+
+// Save 保存一次运行的报告
+func (s *RunStore) Save(suite string, report *BenchmarkReport) error {
+	name := report.StartedAt.UTC().Format("20060102T150405.000Z") + ".json"
+	return s.write(filepath.Join(s.dir, suite, "runs", name), report)
+}
+
+// Latest 最近一次运行的报告，没有记录时返回 ErrNoRuns
+func (s *RunStore) Latest(suite string) (*BenchmarkReport, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, suite, "runs", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoRuns
+	}
+	sort.Strings(files)
+	return s.read(files[len(files)-1])
+}
+
+// Baseline 套件的基线报告，还没有基线时返回nil
+func (s *RunStore) Baseline(suite string) (*BenchmarkReport, error) {
+	report, err := s.read(filepath.Join(s.dir, suite, "baseline.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return report, err
+}
+
+// SetBaseline 把报告设为套件的基线
+func (s *RunStore) SetBaseline(suite string, report *BenchmarkReport) error {
+	return s.write(filepath.Join(s.dir, suite, "baseline.json"), report)
+}
+
+// write 先写临时文件再重命名，避免读到写了一半的文件
+func (s *RunStore) write(path string, report *BenchmarkReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode eval report: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write eval report: %w", err)
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write eval report: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write eval report: %w", err)
+	}
+	return nil
+}
+
+// read 读取报告
+func (s *RunStore) read(path string) (*BenchmarkReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode eval report %s: %w", filepath.Base(path), err)
+	}
+	return &report, nil
+}
+
+// Alerter 发送回归报警
+type Alerter interface {
+	Alert(ctx context.Context, alert *RegressionAlert) error
+}
+
+// WebhookAlerter 以签名webhook发送 eval.regression 事件，签名和重试与作业回调相同
+type WebhookAlerter struct {
+	sender *jobs.WebhookSender
+	url    string
+}
+
+// NewWebhookAlerter 创建webhook报警
+func NewWebhookAlerter(sender *jobs.WebhookSender, url string) *WebhookAlerter {
+	return &WebhookAlerter{sender: sender, url: url}
+}
+
+// Alert 投递报警
+func (a *WebhookAlerter) Alert(ctx context.Context, alert *RegressionAlert) error {
+	payload := struct {
+		Event string           `json:"event"`
+		Alert *RegressionAlert `json:"alert"`
+	}{Event: "eval.regression", Alert: alert}
+	delivery := a.sender.DeliverEvent(ctx, a.url, payload.Event, payload)
+	if delivery.DeliveredAt == nil {
+		return fmt.Errorf("alert webhook failed after %d attempts: %s", delivery.Attempts, delivery.LastError)
+	}
+	return nil
+}
+
+// logAlerter 未配置webhook时把报警写入日志
+type logAlerter struct{}
+
+func (logAlerter) Alert(ctx context.Context, alert *RegressionAlert) error {
+	parts := make([]string, len(alert.Regressions))
+	for i, r := range alert.Regressions {
+		parts[i] = r.String()
+	}
+	log.Printf("[eval] regression in suite %s: %s", alert.Suite, strings.Join(parts, "; "))
+	return nil
+}
+
+// scheduledSuite 调度中的套件及其运行状态
+type scheduledSuite struct {
+	Suite
+	running bool
+	next    time.Time
+	last    *SuiteRun
+}
+
+// SuiteStatus 套件的调度状态
+type SuiteStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Dataset  string    `json:"dataset"`
+	Pipeline string    `json:"pipeline"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run,omitempty"`
+	LastRun  *SuiteRun `json:"last_run,omitempty"`
+}
+
+// Scheduler 定时运行评估套件，与基线比较并在回归时报警
+type Scheduler struct {
+	mu      sync.Mutex
+	suites  []*scheduledSuite
+	store   *RunStore
+	alerter Alerter
+	now     func() time.Time
+}
+
+// NewScheduler 创建定时评估调度器，alerter为nil时回归只写入日志
+func NewScheduler(store *RunStore, alerter Alerter) *Scheduler {
+	if alerter == nil {
+		alerter = logAlerter{}
+	}
+	return &Scheduler{store: store, alerter: alerter, now: time.Now}
+}
+
+// NewSchedulerFromConfig 根据配置创建调度器；newPipeline 按套件配置创建被评估的流水线
+// 报警webhook的密钥未配置时使用jobs.webhook.secret
+func NewSchedulerFromConfig(cfg config.EvalConfig, jobsWebhook config.WebhookConfig, newPipeline func(config.EvalSuiteConfig) (Pipeline, error)) (*Scheduler, error) {
+	store, err := NewRunStore(cfg.ResultsDir)
+	if err != nil {
+		return nil, err
+	}
+	var alerter Alerter
+	if cfg.Alert.WebhookURL != "" {
+		secret := cfg.Alert.Secret
+		if secret == "" {
+			secret = jobsWebhook.Secret
+		}
+		alerter = NewWebhookAlerter(jobs.NewWebhookSender(secret, cfg.Alert.MaxAttempts), cfg.Alert.WebhookURL)
+	}
+	s := NewScheduler(store, alerter)
+
+	for _, sc := range cfg.Suites {
+		schedule, err := cron.Parse(sc.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("eval suite %s: %w", sc.Name, err)
+		}
+		pipeline, err := newPipeline(sc)
+		if err != nil {
+			return nil, fmt.Errorf("eval suite %s: %w", sc.Name, err)
+		}
+		timeout, err := time.ParseDuration(sc.Timeout)
+		if err != nil || timeout <= 0 {
+			timeout = defaultSuiteTimeout
+		}
+		err = s.Add(Suite{
+			Name:     sc.Name,
+			Schedule: schedule,
+			Dataset:  sc.Dataset,
+			Pipeline: pipeline,
+			Options: HarnessOptions{
+				Dataset:   filepath.Base(sc.Dataset),
+				Scoring:   sc.Scoring,
+				Threshold: sc.Threshold,
+			},
+			Timeout: timeout,
+			Thresholds: RegressionThresholds{
+				MaxAccuracyDrop:    sc.MaxAccuracyDrop,
+				MaxScoreDrop:       sc.MaxScoreDrop,
+				MaxLatencyIncrease: sc.MaxLatencyIncrease,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加套件
+func (s *Scheduler) Add(suite Suite) error {
+	if !suiteName.MatchString(suite.Name) {
+		return fmt.Errorf("invalid eval suite name %q: only letters, digits, '-' and '_' are allowed", suite.Name)
+	}
+	if suite.Schedule == nil || suite.Pipeline == nil {
+		return fmt.Errorf("eval suite %s requires a schedule and a pipeline", suite.Name)
+	}
+	if suite.Timeout <= 0 {
+		suite.Timeout = defaultSuiteTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.suites {
+		if existing.Name == suite.Name {
+			return fmt.Errorf("duplicate eval suite %s", suite.Name)
+		}
+	}
+	s.suites = append(s.suites, &scheduledSuite{Suite: suite})
+	return nil
+}
+
+// Has 套件是否存在
+func (s *Scheduler) Has(name string) bool {
+	_, err := s.find(name)
+	return err == nil
+}
+
+// Len 套件数量
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.suites)
+}
+
+// Start 为每个套件启动定时循环，ctx结束时停止；上一次运行未结束时跳过本次触发
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	suites := append([]*scheduledSuite(nil), s.suites...)
+	s.mu.Unlock()
+	for _, suite := range suites {
+		go s.loop(ctx, suite)
+	}
+}
+
+// loop 单个套件的定时循环
+func (s *Scheduler) loop(ctx context.Context, suite *scheduledSuite) {
+	for {
+		now := s.now()
+		next := suite.Schedule.Next(now)
+		if next.IsZero() {
+			log.Printf("[eval] suite %s: schedule %q never fires, stopping", suite.Name, suite.Schedule)
+			return
+		}
+		s.mu.Lock()
+		suite.next = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.Run(ctx, suite.Name); err != nil && !errors.Is(err, ErrSuiteRunning) {
+			log.Printf("[eval] suite %s failed: %v", suite.Name, err)
+		}
+	}
+}
+
+// Run 立即运行套件：读取数据集并评估，保存报告，与基线比较并在回归时报警
+// 套件还没有基线时本次运行成为基线；评估本身失败时返回错误，报警失败只记录在结果中
+func (s *Scheduler) Run(ctx context.Context, name string) (*SuiteRun, error) {
+	suite, err := s.acquire(name)
+	if err != nil {
+		return nil, err
+	}
+	run := &SuiteRun{Suite: name, RunAt: s.now()}
+	defer s.release(suite, run)
+
+	dataset, err := ImportDataset(suite.Dataset, ImportOptions{})
+	if err != nil {
+		run.Error = err.Error()
+		return run, err
+	}
+	runCtx, cancel := context.WithTimeout(ctx, suite.Timeout)
+	defer cancel()
+	report, err := NewHarness(suite.Pipeline, suite.Options).Run(runCtx, dataset)
+	if err != nil {
+		// 未完成的评估不保存，避免与基线比较出虚假的回归
+		run.Error = err.Error()
+		return run, err
+	}
+	run.Summary = report.Summary
+	if err := s.store.Save(name, report); err != nil {
+		run.Error = err.Error()
+		return run, err
+	}
+
+	baseline, err := s.store.Baseline(name)
+	if err != nil {
+		run.Error = err.Error()
+		return run, err
+	}
+	if baseline == nil {
+		if err := s.store.SetBaseline(name, report); err != nil {
+			run.Error = err.Error()
+			return run, err
+		}
+		log.Printf("[eval] suite %s: first run recorded as baseline (accuracy %.2f%%)", name, report.Summary.Accuracy*100)
+		return run, nil
+	}
+
+	run.Baseline = &baseline.Summary
+	run.BaselineAt = &baseline.StartedAt
+	run.Regressions = CompareReports(baseline.Summary, report.Summary, suite.Thresholds)
+	if len(run.Regressions) == 0 {
+		return run, nil
+	}
+	err = s.alerter.Alert(ctx, &RegressionAlert{
+		Suite:       name,
+		Dataset:     report.Dataset,
+		Pipeline:    report.Pipeline,
+		RunAt:       report.StartedAt,
+		BaselineAt:  baseline.StartedAt,
+		Baseline:    baseline.Summary,
+		Current:     report.Summary,
+		Regressions: run.Regressions,
+	})
+	if err != nil {
+		run.Error = fmt.Sprintf("alert: %v", err)
+		log.Printf("[eval] suite %s: failed to send regression alert: %v", name, err)
+	} else {
+		run.Alerted = true
+	}
+	return run, nil
+}
+
+// PromoteBaseline 把套件最近一次运行设为新的基线，如确认指标变化符合预期之后
+func (s *Scheduler) PromoteBaseline(name string) (*BenchmarkReport, error) {
+	if _, err := s.find(name); err != nil {
+		return nil, err
+	}
+	latest, err := s.store.Latest(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetBaseline(name, latest); err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// Status 各套件的调度状态
+func (s *Scheduler) Status() []SuiteStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]SuiteStatus, len(s.suites))
+	for i, suite := range s.suites {
+		statuses[i] = SuiteStatus{
+			Name:     suite.Name,
+			Schedule: suite.Schedule.String(),
+			Dataset:  suite.Dataset,
+			Pipeline: suite.Pipeline.Name(),
+			Running:  suite.running,
+			NextRun:  suite.next,
+			LastRun:  suite.last,
+		}
+	}
+	return statuses
+}
+
+// find 按名称查找套件
+func (s *Scheduler) find(name string) (*scheduledSuite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, suite := range s.suites {
+		if suite.Name == name {
+			return suite, nil
+		}
+	}
+	return nil, ErrSuiteNotFound
+}
+
+// acquire 标记套件开始运行，同一套件不并发运行
+func (s *Scheduler) acquire(name string) (*scheduledSuite, error) {
+	suite, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if suite.running {
+		return nil, ErrSuiteRunning
+	}
+	suite.running = true
+	return suite, nil
+}
+
+// release 记录运行结果并标记运行结束
+func (s *Scheduler) release(suite *scheduledSuite, run *SuiteRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	suite.running = false
+	suite.last = run
+}
@@ -0,0 +1,421 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/rag/adaptive"
+	"ai-agent-assistant/internal/workflow"
+)
+
+// 轨迹诊断中的问题类型
+const (
+	IssueUnexpectedTool = "unexpected_tool" // 使用了期望和允许列表之外的工具
+	IssueRedundant      = "redundant"       // 与之前某个成功步骤的工具和输入完全相同
+	IssueOverBudget     = "over_budget"     // 超出步数预算
+	IssueStepError      = "error"           // 步骤执行失败
+)
+
+// RunStep 多步运行中的一步
+type RunStep struct {
+	ID       string        `json:"id,omitempty"` // 工作流步骤ID，代理式RAG轨迹为空
+	Tool     string        `json:"tool"`         // 调用的工具（代理式RAG）或执行步骤的Agent（工作流）
+	Input    string        `json:"input,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// AgentRun 用于轨迹评估的多步运行记录，由代理式RAG轨迹或工作流执行转换而来
+type AgentRun struct {
+	ID       string        `json:"id"`
+	Query    string        `json:"query,omitempty"`
+	Answer   string        `json:"answer,omitempty"`
+	Steps    []RunStep     `json:"steps"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// RunFromTrajectory 把持久化的代理式RAG轨迹转换为运行记录，没有行动的迭代不计为步骤
+// 观察类型为error的行动记为失败
+func RunFromTrajectory(t *adaptive.Trajectory) *AgentRun {
+	run := &AgentRun{
+		ID:       t.ID,
+		Query:    t.Query,
+		Answer:   t.Answer,
+		Steps:    make([]RunStep, 0, len(t.Actions)),
+		Duration: time.Duration(t.DurationMs) * time.Millisecond,
+		Error:    t.Error,
+	}
+	for _, step := range t.Steps() {
+		if step.Action == nil {
+			continue
+		}
+		s := RunStep{Tool: step.Action.Tool, Input: step.Action.Input, Output: step.Action.Output}
+		if step.Observation != nil && step.Observation.Type == "error" {
+			s.Error = step.Observation.Content
+		}
+		run.Steps = append(run.Steps, s)
+	}
+	return run
+}
+
+// RunFromExecution 把工作流执行记录转换为运行记录，按开始时间排序，未执行和跳过的步骤不计入
+func RunFromExecution(execution *workflow.WorkflowExecution) *AgentRun {
+	run := &AgentRun{
+		ID:       execution.ID,
+		Duration: execution.Duration,
+		Error:    execution.Error,
+	}
+	if input, ok := execution.Inputs["input"].(string); ok {
+		run.Query = input
+	}
+
+	states := make([]*workflow.StepState, 0, len(execution.StepStates))
+	for _, state := range execution.StepStates {
+		if state.StartedAt == nil || state.Status == workflow.StepStatusSkipped {
+			continue
+		}
+		states = append(states, state)
+	}
+	sort.SliceStable(states, func(i, j int) bool {
+		if states[i].StartedAt.Equal(*states[j].StartedAt) {
+			return states[i].StepID < states[j].StepID
+		}
+		return states[i].StartedAt.Before(*states[j].StartedAt)
+	})
+
+	run.Steps = make([]RunStep, 0, len(states))
+	for _, state := range states {
+		tool := state.AgentUsed
+		if tool == "" {
+			tool = state.StepID
+		}
+		run.Steps = append(run.Steps, RunStep{
+			ID:       state.StepID,
+			Tool:     tool,
+			Input:    stepText(state.Input),
+			Output:   stepText(state.Output),
+			Error:    state.Error,
+			Duration: state.Duration,
+		})
+	}
+	return run
+}
+
+// stepText 步骤输入输出的文本表示，用于冗余判断和诊断展示
+func stepText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// TrajectoryExpectation 对一次运行轨迹的期望，字段为零值时不检查对应项
+type TrajectoryExpectation struct {
+	Tools        []string      `json:"expected_tools,omitempty"` // 期望按顺序调用的工具，中间可以插入其他允许的步骤
+	AllowedTools []string      `json:"allowed_tools,omitempty"`  // 允许额外调用的工具，为空时不限制
+	MaxSteps     int           `json:"max_steps,omitempty"`      // 步数预算
+	MaxDuration  time.Duration `json:"max_duration,omitempty"`   // 耗时预算
+}
+
+// ExpectationFromMetadata 从测试用例的metadata读取轨迹期望：
+// expected_tools、allowed_tools 为字符串数组，max_steps 为整数，max_duration 为时长字符串（如 "30s"）
+func ExpectationFromMetadata(metadata map[string]interface{}) (TrajectoryExpectation, error) {
+	var expect TrajectoryExpectation
+	var err error
+	if expect.Tools, err = metadataStrings(metadata, "expected_tools"); err != nil {
+		return expect, err
+	}
+	if expect.AllowedTools, err = metadataStrings(metadata, "allowed_tools"); err != nil {
+		return expect, err
+	}
+	switch v := metadata["max_steps"].(type) {
+	case nil:
+	case float64:
+		expect.MaxSteps = int(v)
+	case int:
+		expect.MaxSteps = v
+	default:
+		return expect, fmt.Errorf("metadata max_steps must be a number, got %T", v)
+	}
+	if v, ok := metadata["max_duration"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return expect, fmt.Errorf("metadata max_duration must be a duration string, got %T", v)
+		}
+		if expect.MaxDuration, err = time.ParseDuration(s); err != nil {
+			return expect, fmt.Errorf("metadata max_duration: %w", err)
+		}
+	}
+	return expect, nil
+}
+
+// metadataStrings 读取字符串数组字段
+func metadataStrings(metadata map[string]interface{}, key string) ([]string, error) {
+	switch v := metadata[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("metadata %s must be a list of strings", key)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("metadata %s must be a list of strings, got %T", key, v)
+	}
+}
+
+// isZero 是否没有任何轨迹期望
+func (e TrajectoryExpectation) isZero() bool {
+	return len(e.Tools) == 0 && len(e.AllowedTools) == 0 && e.MaxSteps <= 0 && e.MaxDuration <= 0
+}
+
+// StepDiagnostic 单个步骤的诊断
+type StepDiagnostic struct {
+	Index      int      `json:"index"`
+	ID         string   `json:"id,omitempty"`
+	Tool       string   `json:"tool"`
+	Input      string   `json:"input,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+	Expected   bool     `json:"expected"` // 是否命中了期望工具序列中的一项
+	Issues     []string `json:"issues,omitempty"`
+}
+
+// TrajectoryResult 一次运行的轨迹评估结果
+// ToolChoiceScore 为按顺序命中的期望工具比例乘以允许工具步骤的比例；RedundancyScore 为非冗余步骤的比例；
+// BudgetScore 在预算内为1，超出时按 预算/实际 折算；Score 为三者与答案得分的平均值
+type TrajectoryResult struct {
+	RunID           string           `json:"run_id,omitempty"`
+	Query           string           `json:"query"`
+	Expected        string           `json:"expected,omitempty"`
+	Answer          string           `json:"answer,omitempty"`
+	Passed          bool             `json:"passed"`
+	Score           float64          `json:"score"`
+	AnswerScore     float64          `json:"answer_score"`
+	ToolChoiceScore float64          `json:"tool_choice_score"`
+	RedundancyScore float64          `json:"redundancy_score"`
+	BudgetScore     float64          `json:"budget_score"`
+	MissingTools    []string         `json:"missing_tools,omitempty"`
+	RedundantSteps  int              `json:"redundant_steps"`
+	FailedSteps     int              `json:"failed_steps"`
+	Issues          []string         `json:"issues,omitempty"` // 运行级别的问题
+	Steps           []StepDiagnostic `json:"steps"`
+}
+
+// TrajectoryEvaluator 轨迹评估器：不只看最终答案，还检查工具选择、冗余步骤和预算
+type TrajectoryEvaluator struct {
+	accuracy *AccuracyEval
+}
+
+// NewTrajectoryEvaluator 创建轨迹评估器，scoring和threshold用于答案评分，含义与 NewAccuracyEval 相同
+func NewTrajectoryEvaluator(scoring string, threshold float64) *TrajectoryEvaluator {
+	return &TrajectoryEvaluator{accuracy: NewAccuracyEval(scoring, nil, threshold)}
+}
+
+// Evaluate 评估一次运行；expected为空时不评分答案
+// 通过条件：运行没有错误、答案通过、期望工具全部按序命中、没有不允许的工具且不超预算；冗余步骤只降低得分
+func (e *TrajectoryEvaluator) Evaluate(run *AgentRun, expected string, expect TrajectoryExpectation) *TrajectoryResult {
+	result := &TrajectoryResult{
+		RunID:           run.ID,
+		Query:           run.Query,
+		Expected:        expected,
+		Answer:          run.Answer,
+		AnswerScore:     1,
+		ToolChoiceScore: 1,
+		RedundancyScore: 1,
+		BudgetScore:     1,
+		Steps:           make([]StepDiagnostic, len(run.Steps)),
+	}
+	answerPassed := true
+	if expected != "" {
+		result.AnswerScore, answerPassed = e.accuracy.scoreResult(expected, run.Answer)
+	}
+
+	allowed := make(map[string]bool, len(expect.Tools)+len(expect.AllowedTools))
+	for _, tool := range expect.Tools {
+		allowed[tool] = true
+	}
+	for _, tool := range expect.AllowedTools {
+		allowed[tool] = true
+	}
+	restrictTools := len(expect.AllowedTools) > 0
+
+	seen := make(map[string]bool, len(run.Steps))
+	next, unexpected := 0, 0
+	for i, step := range run.Steps {
+		d := StepDiagnostic{
+			Index:      i + 1,
+			ID:         step.ID,
+			Tool:       step.Tool,
+			Input:      step.Input,
+			DurationMs: step.Duration.Milliseconds(),
+		}
+		if next < len(expect.Tools) && step.Tool == expect.Tools[next] && step.Error == "" {
+			d.Expected = true
+			next++
+		}
+		if restrictTools && !allowed[step.Tool] {
+			d.Issues = append(d.Issues, IssueUnexpectedTool)
+			unexpected++
+		}
+		key := step.Tool + "\x00" + strings.TrimSpace(step.Input)
+		if seen[key] {
+			d.Issues = append(d.Issues, IssueRedundant)
+			result.RedundantSteps++
+		}
+		if step.Error != "" {
+			d.Issues = append(d.Issues, IssueStepError)
+			result.FailedSteps++
+		} else {
+			seen[key] = true
+		}
+		if expect.MaxSteps > 0 && i >= expect.MaxSteps {
+			d.Issues = append(d.Issues, IssueOverBudget)
+		}
+		result.Steps[i] = d
+	}
+
+	steps := len(run.Steps)
+	if len(expect.Tools) > 0 {
+		result.MissingTools = append([]string(nil), expect.Tools[next:]...)
+		result.ToolChoiceScore = float64(next) / float64(len(expect.Tools))
+	}
+	if steps > 0 {
+		result.ToolChoiceScore *= 1 - float64(unexpected)/float64(steps)
+		result.RedundancyScore = 1 - float64(result.RedundantSteps)/float64(steps)
+	}
+	if expect.MaxSteps > 0 && steps > expect.MaxSteps {
+		result.BudgetScore *= float64(expect.MaxSteps) / float64(steps)
+		result.Issues = append(result.Issues, fmt.Sprintf("used %d steps, budget %d", steps, expect.MaxSteps))
+	}
+	if expect.MaxDuration > 0 && run.Duration > expect.MaxDuration {
+		result.BudgetScore *= float64(expect.MaxDuration) / float64(run.Duration)
+		result.Issues = append(result.Issues, fmt.Sprintf("took %s, budget %s", run.Duration, expect.MaxDuration))
+	}
+	if len(result.MissingTools) > 0 {
+		result.Issues = append(result.Issues, "missing expected tools: "+strings.Join(result.MissingTools, ", "))
+	}
+	if run.Error != "" {
+		result.Issues = append(result.Issues, "run failed: "+run.Error)
+	}
+
+	result.Score = (result.AnswerScore + result.ToolChoiceScore + result.RedundancyScore + result.BudgetScore) / 4
+	result.Passed = run.Error == "" && answerPassed && len(result.MissingTools) == 0 &&
+		unexpected == 0 && result.BudgetScore == 1
+	return result
+}
+
+// TrajectoryReport 轨迹评估报告
+type TrajectoryReport struct {
+	Dataset string             `json:"dataset,omitempty"`
+	Summary TrajectorySummary  `json:"summary"`
+	Runs    []TrajectoryResult `json:"runs"`
+}
+
+// TrajectorySummary 轨迹评估汇总
+type TrajectorySummary struct {
+	Total              int            `json:"total"`
+	Passed             int            `json:"passed"`
+	Missing            int            `json:"missing"` // 数据集中没有找到对应运行记录的用例数
+	PassRate           float64        `json:"pass_rate"`
+	AvgScore           float64        `json:"avg_score"`
+	AvgToolChoiceScore float64        `json:"avg_tool_choice_score"`
+	AvgRedundancyScore float64        `json:"avg_redundancy_score"`
+	AvgBudgetScore     float64        `json:"avg_budget_score"`
+	AvgSteps           float64        `json:"avg_steps"`
+	StepIssues         map[string]int `json:"step_issues,omitempty"` // 各类步骤问题的出现次数
+}
+
+// EvaluateTrajectories 用数据集评估持久化的代理式RAG轨迹
+// 每个用例按查询文本匹配最近的一条轨迹（trajectories 按时间倒序，与 TrajectoryStore.List 一致），
+// 期望从用例metadata读取；找不到轨迹的用例记为未通过
+func (e *TrajectoryEvaluator) EvaluateTrajectories(trajectories []*adaptive.Trajectory, dataset []TestCase) (*TrajectoryReport, error) {
+	latest := make(map[string]*adaptive.Trajectory, len(trajectories))
+	for _, t := range trajectories {
+		query := strings.TrimSpace(t.Query)
+		if _, ok := latest[query]; !ok {
+			latest[query] = t
+		}
+	}
+
+	report := &TrajectoryReport{Runs: make([]TrajectoryResult, 0, len(dataset))}
+	for i, tc := range dataset {
+		expect, err := ExpectationFromMetadata(tc.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("test case %d: %w", i+1, err)
+		}
+		t, ok := latest[strings.TrimSpace(tc.Input)]
+		if !ok {
+			report.Runs = append(report.Runs, TrajectoryResult{
+				Query:    tc.Input,
+				Expected: tc.GetExpected(),
+				Issues:   []string{"no trajectory recorded for this query"},
+				Steps:    []StepDiagnostic{},
+			})
+			report.Summary.Missing++
+			continue
+		}
+		report.Runs = append(report.Runs, *e.Evaluate(RunFromTrajectory(t), tc.GetExpected(), expect))
+	}
+	report.finish()
+	return report, nil
+}
+
+// finish 计算汇总指标
+func (r *TrajectoryReport) finish() {
+	s := TrajectorySummary{Total: len(r.Runs), Missing: r.Summary.Missing, StepIssues: make(map[string]int)}
+	if s.Total == 0 {
+		r.Summary = s
+		return
+	}
+	var steps int
+	for _, run := range r.Runs {
+		if run.Passed {
+			s.Passed++
+		}
+		s.AvgScore += run.Score
+		s.AvgToolChoiceScore += run.ToolChoiceScore
+		s.AvgRedundancyScore += run.RedundancyScore
+		s.AvgBudgetScore += run.BudgetScore
+		steps += len(run.Steps)
+		for _, step := range run.Steps {
+			for _, issue := range step.Issues {
+				s.StepIssues[issue]++
+			}
+		}
+	}
+	n := float64(s.Total)
+	s.PassRate = float64(s.Passed) / n
+	s.AvgScore /= n
+	s.AvgToolChoiceScore /= n
+	s.AvgRedundancyScore /= n
+	s.AvgBudgetScore /= n
+	s.AvgSteps = float64(steps) / n
+	r.Summary = s
+}
+
+// WriteJSON 以JSON格式输出报告
+func (r *TrajectoryReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,194 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	agentv1 "ai-agent-assistant/api/proto/agent/v1"
+	"ai-agent-assistant/internal/apierror"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/tenant"
+
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultHeartbeatInterval 建议远程Agent使用的心跳间隔，注册表在30秒未收到心跳时认为Agent不健康
+const defaultHeartbeatInterval = 10 * time.Second
+
+// AgentService 远程Agent协议：注册、心跳、接收TaskScheduler分配的任务和上报进度
+type AgentService struct {
+	agentv1.UnimplementedAgentServiceServer
+
+	registry          *aiagentorchestrator.AgentRegistry
+	scheduler         *aiagentorchestrator.TaskScheduler
+	remote            *aiagentorchestrator.RemoteAgents
+	heartbeatInterval time.Duration
+}
+
+// NewAgentService 创建远程Agent服务
+// scheduler的执行器需使用remote.Executor，任务才会下发给已连接的远程Agent；scheduler为nil时不记录进度
+func NewAgentService(registry *aiagentorchestrator.AgentRegistry, scheduler *aiagentorchestrator.TaskScheduler, remote *aiagentorchestrator.RemoteAgents) *AgentService {
+	return &AgentService{
+		registry:          registry,
+		scheduler:         scheduler,
+		remote:            remote,
+		heartbeatInterval: defaultHeartbeatInterval,
+	}
+}
+
+// SetHeartbeatInterval 设置注册时返回给Agent的心跳间隔
+func (s *AgentService) SetHeartbeatInterval(interval time.Duration) {
+	if interval > 0 {
+		s.heartbeatInterval = interval
+	}
+}
+
+// Register 注册远程Agent，建立ReceiveTasks连接前Agent为inactive，不会被调度
+// 启用多租户时Agent属于请求的租户，注册名限定在租户内（如 acme::worker），只处理该租户的任务和工作流步骤
+func (s *AgentService) Register(ctx context.Context, req *agentv1.RegisterRequest) (*agentv1.RegisterResponse, error) {
+	if req.GetName() == "" {
+		return nil, apierror.New(apierror.CodeValidation, "name is required")
+	}
+	name := tenant.Scope(ctx, req.GetName())
+
+	endpoint := req.GetEndpoint()
+	if endpoint == "" {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			endpoint = p.Addr.String()
+		}
+	}
+	agentType := req.GetType()
+	if agentType == "" {
+		agentType = "custom"
+	}
+	status := "inactive"
+	if s.remote.Connected(name) {
+		status = "active"
+	}
+
+	agent, err := s.registry.Upsert(&aiagentorchestrator.AgentInfo{
+		ID:           "remote-" + name,
+		Name:         name,
+		Type:         agentType,
+		Capabilities: req.GetCapabilities(),
+		Endpoint:     endpoint,
+		Status:       status,
+		Metadata:     req.GetMetadata(),
+		Tenant:       tenant.FromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &agentv1.RegisterResponse{
+		AgentId:                  agent.ID,
+		HeartbeatIntervalSeconds: int32(s.heartbeatInterval / time.Second),
+	}, nil
+}
+
+// Heartbeat 刷新心跳
+func (s *AgentService) Heartbeat(ctx context.Context, req *agentv1.HeartbeatRequest) (*agentv1.HeartbeatResponse, error) {
+	name := tenant.Scope(ctx, req.GetName())
+	if err := s.registry.UpdateHeartbeat(name); err != nil {
+		return nil, err
+	}
+	agent, err := s.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &agentv1.HeartbeatResponse{Status: agent.Status}, nil
+}
+
+// Unregister 注销Agent
+func (s *AgentService) Unregister(ctx context.Context, req *agentv1.UnregisterRequest) (*agentv1.UnregisterResponse, error) {
+	if err := s.registry.Unregister(tenant.Scope(ctx, req.GetName())); err != nil {
+		return nil, err
+	}
+	return &agentv1.UnregisterResponse{}, nil
+}
+
+// ReceiveTasks 下发分配给Agent的任务，流结束时Agent变为inactive
+func (s *AgentService) ReceiveTasks(req *agentv1.ReceiveTasksRequest, stream agentv1.AgentService_ReceiveTasksServer) error {
+	conn, err := s.remote.Connect(tenant.Scope(stream.Context(), req.GetName()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-conn.Done():
+			return apierror.New(apierror.CodeConflict, "replaced by a newer connection of the same agent")
+		case event := <-conn.Events():
+			if err := stream.Send(TaskEventToProto(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ReportProgress 记录任务进度，带结果的消息完成任务
+func (s *AgentService) ReportProgress(stream agentv1.AgentService_ReportProgressServer) error {
+	var received int32
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&agentv1.ReportProgressResponse{Received: received})
+		}
+		if err != nil {
+			return err
+		}
+		received++
+
+		name, taskID := tenant.Scope(stream.Context(), progress.GetName()), progress.GetTaskId()
+		if !s.remote.Running(name, taskID) {
+			return apierror.New(apierror.CodeNotFound, "task is not running on this agent").WithDetails(map[string]interface{}{
+				"agent":   progress.GetName(),
+				"task_id": taskID,
+			})
+		}
+
+		if result := progress.GetResult(); result != nil {
+			var taskErr error
+			if result.GetError() != "" {
+				taskErr = errors.New(result.GetError())
+			}
+			s.remote.Complete(name, taskID, result.GetOutput().AsInterface(), taskErr)
+			continue
+		}
+		if s.scheduler != nil {
+			// 任务在分配后、开始执行前上报的进度忽略
+			s.scheduler.UpdateProgress(taskID, progress.GetPercent(), progress.GetMessage())
+		}
+	}
+}
+
+// TaskEventToProto 下发给远程Agent的事件转换为gRPC消息
+func TaskEventToProto(event *aiagentorchestrator.RemoteTaskEvent) *agentv1.TaskEvent {
+	if event.Cancel {
+		return &agentv1.TaskEvent{Event: &agentv1.TaskEvent_Cancelled{Cancelled: &agentv1.TaskCancelled{
+			TaskId: event.Task.ID,
+			Reason: event.Reason,
+		}}}
+	}
+
+	task := event.Task
+	assigned := &agentv1.Task{
+		TaskId:       task.ID,
+		Type:         task.Type,
+		Goal:         task.Goal,
+		Requirements: toValue(task.Requirements).GetStructValue(),
+		Priority:     int32(task.Priority),
+		Metadata:     toValue(task.Metadata).GetStructValue(),
+		RetryCount:   int32(task.RetryCount),
+		CreatedAt:    toTimestamp(&task.CreatedAt),
+	}
+	if !event.Deadline.IsZero() {
+		assigned.Deadline = timestamppb.New(event.Deadline)
+	}
+	return &agentv1.TaskEvent{Event: &agentv1.TaskEvent_Assigned{Assigned: assigned}}
+}
@@ -0,0 +1,93 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"time"
+
+	assistantv1 "ai-agent-assistant/api/proto/assistant/v1"
+	"ai-agent-assistant/internal/llm"
+	aiagenttask "ai-agent-assistant/internal/task"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TaskToProto 任务记录转换为gRPC消息
+func TaskToProto(record *aiagenttask.TaskRecord) *assistantv1.Task {
+	task := &assistantv1.Task{
+		TaskId:      record.TaskID,
+		BatchId:     record.BatchID,
+		Type:        record.Type,
+		Goal:        record.Goal,
+		Agent:       record.Agent,
+		Priority:    int32(record.Priority),
+		Status:      string(record.Status),
+		Output:      toValue(record.Output),
+		Error:       record.Error,
+		Usage:       toValue(record.Metadata["usage"]),
+		CreatedAt:   toTimestamp(&record.CreatedAt),
+		StartedAt:   toTimestamp(record.StartedAt),
+		CompletedAt: toTimestamp(record.CompletedAt),
+	}
+	for _, transition := range record.Transitions {
+		task.Transitions = append(task.Transitions, &assistantv1.TaskTransition{
+			Status: string(transition.Status),
+			Reason: transition.Reason,
+			At:     timestamppb.New(transition.At),
+		})
+	}
+	return task
+}
+
+// UsageToProto 用量合计转换为gRPC消息
+func UsageToProto(usage llm.UsageSummary) *assistantv1.Usage {
+	return &assistantv1.Usage{
+		Calls:            usage.Calls,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             usage.Cost,
+	}
+}
+
+// RouteDecisionToProto 路由结果转换为gRPC消息，未路由时为nil
+func RouteDecisionToProto(decision *llm.RouteDecision) *assistantv1.RouteDecision {
+	if decision == nil {
+		return nil
+	}
+	return &assistantv1.RouteDecision{
+		Model:      decision.Model,
+		Tier:       decision.Tier,
+		Complexity: decision.Complexity,
+		Reason:     decision.Reason,
+	}
+}
+
+// toValue 任意JSON可序列化的值转换为google.protobuf.Value，nil或无法转换时为nil
+// 先按JSON编码，与REST响应中的结构保持一致
+func toValue(v interface{}) *structpb.Value {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	value, err := structpb.NewValue(generic)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// toTimestamp 时间转换为google.protobuf.Timestamp，nil时为nil
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
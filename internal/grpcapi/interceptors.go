@@ -0,0 +1,244 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+
+	agentv1 "ai-agent-assistant/api/proto/agent/v1"
+	assistantv1 "ai-agent-assistant/api/proto/assistant/v1"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/tenant"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// 元数据键，与REST的请求头对应（gRPC元数据键为小写）
+const (
+	requestIDMetadata  = "x-request-id"
+	apiKeyMetadata     = "x-api-key"
+	authMetadata       = "authorization"
+	tenantMetadata     = "x-tenant-id"
+	retryAfterMetadata = "retry-after"
+
+	// errorDomain 错误详情ErrorInfo的domain
+	errorDomain = "ai-agent-assistant"
+)
+
+// methodScopes 各方法需要的权限范围，与REST路由组一致，未列出的方法只要求认证
+var methodScopes = map[string][]string{
+	assistantv1.AssistantService_Chat_FullMethodName:            {auth.ScopeChat},
+	assistantv1.AssistantService_StreamChat_FullMethodName:      {auth.ScopeChat},
+	assistantv1.AssistantService_SubmitTask_FullMethodName:      {auth.ScopeChat},
+	assistantv1.AssistantService_ExecuteWorkflow_FullMethodName: {auth.ScopeWorkflowsAdmin},
+
+	agentv1.AgentService_Register_FullMethodName:       {auth.ScopeAgentsConnect},
+	agentv1.AgentService_Heartbeat_FullMethodName:      {auth.ScopeAgentsConnect},
+	agentv1.AgentService_Unregister_FullMethodName:     {auth.ScopeAgentsConnect},
+	agentv1.AgentService_ReceiveTasks_FullMethodName:   {auth.ScopeAgentsConnect},
+	agentv1.AgentService_ReportProgress_FullMethodName: {auth.ScopeAgentsConnect},
+}
+
+// statusCodes 统一错误码到gRPC状态码的映射
+var statusCodes = map[apierror.Code]codes.Code{
+	apierror.CodeValidation:           codes.InvalidArgument,
+	apierror.CodeUnauthenticated:      codes.Unauthenticated,
+	apierror.CodeForbidden:            codes.PermissionDenied,
+	apierror.CodeNotFound:             codes.NotFound,
+	apierror.CodeConflict:             codes.AlreadyExists,
+	apierror.CodePayloadTooLarge:      codes.ResourceExhausted,
+	apierror.CodeUnsupportedMediaType: codes.InvalidArgument,
+	apierror.CodeUnprocessable:        codes.FailedPrecondition,
+	apierror.CodeRateLimited:          codes.ResourceExhausted,
+	apierror.CodeQuotaExceeded:        codes.ResourceExhausted,
+	apierror.CodeProviderError:        codes.Unavailable,
+	apierror.CodeUnavailable:          codes.Unavailable,
+	apierror.CodeTimeout:              codes.DeadlineExceeded,
+	apierror.CodeInternal:             codes.Internal,
+}
+
+// unaryInterceptor 请求ID、认证、权限范围、限流和错误转换
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, release, err := s.admit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	defer release()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = toStatus(ctx, fmt.Errorf("panic in %s: %v", info.FullMethod, r))
+		}
+	}()
+
+	resp, err = next(ctx, req)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// streamInterceptor 流式方法的请求ID、认证、权限范围、限流和错误转换
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) (err error) {
+	ctx, release, err := s.admit(stream.Context(), info.FullMethod)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	defer release()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = toStatus(ctx, fmt.Errorf("panic in %s: %v", info.FullMethod, r))
+		}
+	}()
+
+	if err := next(srv, &serverStream{ServerStream: stream, ctx: ctx}); err != nil {
+		return toStatus(ctx, err)
+	}
+	return nil
+}
+
+// admit 分配请求ID、认证调用方、校验权限范围、解析租户并占用限流配额
+// 返回带请求ID、调用方和租户的context，以及请求结束时释放配额的函数
+func (s *Server) admit(ctx context.Context, method string) (context.Context, func(), error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, requestID := apierror.ContextWithRequestID(ctx, firstValue(md, requestIDMetadata))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+
+	var principal *auth.Principal
+	if s.authenticator.Enabled() {
+		var err error
+		principal, err = s.authenticator.Authenticate(ctx, firstValue(md, apiKeyMetadata), firstValue(md, authMetadata))
+		if err != nil {
+			return ctx, nil, auth.AuthError(err)
+		}
+		ctx = auth.ContextWithPrincipal(ctx, principal)
+	}
+	if err := s.authenticator.CheckScopes(principal, methodScopes[method]...); err != nil {
+		return ctx, nil, err
+	}
+	if s.tenants != nil {
+		id, err := s.tenants.Resolve(principal, firstValue(md, tenantMetadata))
+		if err != nil {
+			return ctx, nil, err
+		}
+		ctx = tenant.WithTenant(ctx, id)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(tenantMetadata, id))
+	}
+
+	if s.limiter == nil {
+		return ctx, func() {}, nil
+	}
+	decision, release := s.limiter.Acquire(clientKey(ctx, principal), method)
+	if !decision.Allowed {
+		retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(retryAfterMetadata, strconv.Itoa(retryAfter)))
+		return ctx, nil, apierror.New(apierror.CodeRateLimited, "too many requests").WithDetails(map[string]interface{}{
+			"reason":      decision.Reason,
+			"budget":      decision.Budget,
+			"retry_after": retryAfter,
+		})
+	}
+	return ctx, release, nil
+}
+
+// clientKey 限流的客户端标识，与REST一致：已认证时为调用方ID，否则为对端IP
+func clientKey(ctx context.Context, principal *auth.Principal) string {
+	if principal != nil && principal.ID != "" {
+		return principal.Method + ":" + principal.ID
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:unknown"
+}
+
+// toStatus 把错误转换为gRPC状态，错误码和请求ID放在ErrorInfo详情中
+// 已是gRPC状态的错误（如Unimplemented）原样返回
+func toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	apiErr := apierror.From(err)
+	code, ok := statusCodes[apiErr.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason:   string(apiErr.Code),
+		Domain:   errorDomain,
+		Metadata: detailsMetadata(apiErr.Details),
+	}
+	if requestID := apierror.RequestIDFromContext(ctx); requestID != "" {
+		info.Metadata["request_id"] = requestID
+	}
+
+	st, detailErr := status.New(code, apiErr.Message).WithDetails(info)
+	if detailErr != nil {
+		return status.Error(code, apiErr.Message)
+	}
+	return st.Err()
+}
+
+// detailsMetadata 把错误详情展开为ErrorInfo的字符串键值，非字符串值按JSON编码
+func detailsMetadata(details interface{}) map[string]string {
+	result := make(map[string]string)
+	if details == nil {
+		return result
+	}
+
+	var fields map[string]interface{}
+	data, err := json.Marshal(details)
+	if err != nil || json.Unmarshal(data, &fields) != nil {
+		result["details"] = string(data)
+		return result
+	}
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			result[key] = s
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		result[key] = string(encoded)
+	}
+	return result
+}
+
+// firstValue 元数据中键的第一个值
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream 替换流的context，使处理函数拿到请求ID和调用方
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
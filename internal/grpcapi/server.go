@@ -0,0 +1,235 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	agentv1 "ai-agent-assistant/api/proto/agent/v1"
+	assistantv1 "ai-agent-assistant/api/proto/assistant/v1"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/handler"
+	"ai-agent-assistant/internal/ratelimit"
+	aiagenttask "ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// ChatBackend 对话服务，由服务入口适配现有的会话处理，保证与REST写入会话的内容一致
+type ChatBackend interface {
+	Chat(ctx context.Context, req *assistantv1.ChatRequest) (*assistantv1.ChatResponse, error)
+	StreamChat(ctx context.Context, req *assistantv1.ChatRequest, send func(*assistantv1.ChatEvent) error) error
+}
+
+// TaskBackend 任务提交与查询（handler.AgentHandler）
+type TaskBackend interface {
+	SubmitTask(ctx context.Context, req handler.TaskSubmission) (*aiagenttask.TaskRecord, error)
+	GetTask(ctx context.Context, taskID string) (*aiagenttask.TaskRecord, error)
+}
+
+// WorkflowBackend 工作流执行（handler.AgentHandler）
+type WorkflowBackend interface {
+	StartWorkflow(ctx context.Context, workflowID string, inputs map[string]interface{}) (*handler.WorkflowRun, error)
+}
+
+// KnowledgeBackend 知识库检索（rag.RAG）
+type KnowledgeBackend interface {
+	Retrieve(ctx context.Context, query string, topK int) ([]string, error)
+}
+
+// Server 与REST API并行的gRPC服务
+// 各后端通过Set方法注入，未设置的接口返回Unimplemented；认证、限流与REST共用同一实例
+type Server struct {
+	assistantv1.UnimplementedAssistantServiceServer
+
+	grpcServer    *grpc.Server
+	authenticator *auth.Authenticator
+	limiter       *ratelimit.Limiter
+	tenants       *tenant.Resolver
+	chat          ChatBackend
+	tasks         TaskBackend
+	workflows     WorkflowBackend
+	knowledge     KnowledgeBackend
+}
+
+// NewServer 创建gRPC服务，authenticator和limiter可为nil（不认证、不限流）
+func NewServer(authenticator *auth.Authenticator, limiter *ratelimit.Limiter, reflect bool) *Server {
+	s := &Server{
+		authenticator: authenticator,
+		limiter:       limiter,
+	}
+	s.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	assistantv1.RegisterAssistantServiceServer(s.grpcServer, s)
+	if reflect {
+		// 支持grpcurl等工具列出服务和方法
+		reflection.Register(s.grpcServer)
+	}
+	return s
+}
+
+// NewServerFromConfig 根据配置创建gRPC服务，未启用时返回nil
+func NewServerFromConfig(cfg config.GRPCConfig, authenticator *auth.Authenticator, limiter *ratelimit.Limiter) *Server {
+	if !cfg.Enabled {
+		return nil
+	}
+	return NewServer(authenticator, limiter, cfg.Reflection)
+}
+
+// SetTenancy 设置租户解析，与REST共用同一实例；未设置时不区分租户
+func (s *Server) SetTenancy(tenants *tenant.Resolver) {
+	s.tenants = tenants
+}
+
+// SetChat 设置对话后端
+func (s *Server) SetChat(chat ChatBackend) {
+	s.chat = chat
+}
+
+// SetTasks 设置任务后端
+func (s *Server) SetTasks(tasks TaskBackend) {
+	s.tasks = tasks
+}
+
+// SetWorkflows 设置工作流后端
+func (s *Server) SetWorkflows(workflows WorkflowBackend) {
+	s.workflows = workflows
+}
+
+// SetKnowledge 设置知识库后端
+func (s *Server) SetKnowledge(knowledge KnowledgeBackend) {
+	s.knowledge = knowledge
+}
+
+// SetAgents 注册远程Agent协议服务（agent.v1.AgentService），需在Serve之前调用
+func (s *Server) SetAgents(agents *AgentService) {
+	agentv1.RegisterAgentServiceServer(s.grpcServer, agents)
+}
+
+// Serve 在监听器上提供服务，阻塞直到Stop
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcServer.Serve(lis)
+}
+
+// ListenAndServe 监听地址并提供服务
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(lis)
+}
+
+// Stop 停止接收新请求，等待进行中的请求结束
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
+
+// Chat 单轮对话
+func (s *Server) Chat(ctx context.Context, req *assistantv1.ChatRequest) (*assistantv1.ChatResponse, error) {
+	if s.chat == nil {
+		return s.UnimplementedAssistantServiceServer.Chat(ctx, req)
+	}
+	if req.GetSessionId() == "" || req.GetMessage() == "" {
+		return nil, apierror.New(apierror.CodeValidation, "session_id and message are required")
+	}
+	return s.chat.Chat(ctx, req)
+}
+
+// StreamChat 流式对话
+func (s *Server) StreamChat(req *assistantv1.ChatRequest, stream assistantv1.AssistantService_StreamChatServer) error {
+	if s.chat == nil {
+		return s.UnimplementedAssistantServiceServer.StreamChat(req, stream)
+	}
+	if req.GetSessionId() == "" || req.GetMessage() == "" {
+		return apierror.New(apierror.CodeValidation, "session_id and message are required")
+	}
+	return s.chat.StreamChat(stream.Context(), req, stream.Send)
+}
+
+// SubmitTask 提交任务
+func (s *Server) SubmitTask(ctx context.Context, req *assistantv1.SubmitTaskRequest) (*assistantv1.Task, error) {
+	if s.tasks == nil {
+		return s.UnimplementedAssistantServiceServer.SubmitTask(ctx, req)
+	}
+	if req.GetType() == "" || req.GetGoal() == "" {
+		return nil, apierror.New(apierror.CodeValidation, "type and goal are required")
+	}
+
+	record, err := s.tasks.SubmitTask(ctx, handler.TaskSubmission{
+		Type:         req.GetType(),
+		Goal:         req.GetGoal(),
+		Priority:     int(req.GetPriority()),
+		Requirements: req.GetRequirements().AsMap(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return TaskToProto(record), nil
+}
+
+// GetTask 查询任务
+func (s *Server) GetTask(ctx context.Context, req *assistantv1.GetTaskRequest) (*assistantv1.Task, error) {
+	if s.tasks == nil {
+		return s.UnimplementedAssistantServiceServer.GetTask(ctx, req)
+	}
+	if req.GetTaskId() == "" {
+		return nil, apierror.New(apierror.CodeValidation, "task_id is required")
+	}
+
+	record, err := s.tasks.GetTask(ctx, req.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+	return TaskToProto(record), nil
+}
+
+// ExecuteWorkflow 执行工作流
+func (s *Server) ExecuteWorkflow(ctx context.Context, req *assistantv1.ExecuteWorkflowRequest) (*assistantv1.WorkflowExecution, error) {
+	if s.workflows == nil {
+		return s.UnimplementedAssistantServiceServer.ExecuteWorkflow(ctx, req)
+	}
+
+	run, err := s.workflows.StartWorkflow(ctx, req.GetWorkflowId(), req.GetInputs().AsMap())
+	if err != nil {
+		return nil, err
+	}
+	return &assistantv1.WorkflowExecution{
+		ExecutionId: run.ExecutionID,
+		WorkflowId:  run.WorkflowID,
+		Status:      run.Status,
+	}, nil
+}
+
+// maxSearchTopK 检索结果数上限，与REST接口 /knowledge/search 的 top_k 上限一致
+const maxSearchTopK = 20
+
+// SearchKnowledge 知识库检索，top_k 默认3，超过 maxSearchTopK 时按上限返回
+func (s *Server) SearchKnowledge(ctx context.Context, req *assistantv1.SearchKnowledgeRequest) (*assistantv1.SearchKnowledgeResponse, error) {
+	if s.knowledge == nil {
+		return s.UnimplementedAssistantServiceServer.SearchKnowledge(ctx, req)
+	}
+	if req.GetQuery() == "" {
+		return nil, apierror.New(apierror.CodeValidation, "query is required")
+	}
+
+	topK := int(req.GetTopK())
+	if topK <= 0 {
+		topK = 3
+	}
+	topK = min(topK, maxSearchTopK)
+	results, err := s.knowledge.Retrieve(ctx, req.GetQuery(), topK)
+	if err != nil {
+		return nil, err
+	}
+	return &assistantv1.SearchKnowledgeResponse{
+		Query:   req.GetQuery(),
+		Results: results,
+	}, nil
+}
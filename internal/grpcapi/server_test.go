@@ -0,0 +1,302 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	agentv1 "ai-agent-assistant/api/proto/agent/v1"
+	assistantv1 "ai-agent-assistant/api/proto/assistant/v1"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/handler"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	aiagenttask "ai-agent-assistant/internal/task"
+	"ai-agent-assistant/pkg/agentclient"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeBackend 测试用的对话、任务和知识库后端
+type fakeBackend struct{}
+
+func (fakeBackend) Chat(ctx context.Context, req *assistantv1.ChatRequest) (*assistantv1.ChatResponse, error) {
+	return &assistantv1.ChatResponse{Response: "你好", SessionId: req.GetSessionId()}, nil
+}
+
+func (fakeBackend) StreamChat(ctx context.Context, req *assistantv1.ChatRequest, send func(*assistantv1.ChatEvent) error) error {
+	for _, token := range []string{"你", "好"} {
+		if err := send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Token{Token: token}}); err != nil {
+			return err
+		}
+	}
+	return send(&assistantv1.ChatEvent{Event: &assistantv1.ChatEvent_Done{Done: &assistantv1.ChatResponse{Response: "你好"}}})
+}
+
+func (fakeBackend) SubmitTask(ctx context.Context, req handler.TaskSubmission) (*aiagenttask.TaskRecord, error) {
+	task := &aiagenttask.Task{ID: "task-1", Type: req.Type, Goal: req.Goal, CreatedAt: time.Now()}
+	return aiagenttask.NewTaskRecord(task, "Researcher"), nil
+}
+
+func (fakeBackend) GetTask(ctx context.Context, taskID string) (*aiagenttask.TaskRecord, error) {
+	return nil, apierror.New(apierror.CodeNotFound, "Task not found")
+}
+
+func (fakeBackend) Retrieve(ctx context.Context, query string, topK int) ([]string, error) {
+	results := make([]string, topK)
+	for i := range results {
+		results[i] = query
+	}
+	return results, nil
+}
+
+// startServer 在内存连接上启动服务并返回客户端
+func startServer(t *testing.T, s *Server) assistantv1.AssistantServiceClient {
+	t.Helper()
+	return assistantv1.NewAssistantServiceClient(dialServer(t, s))
+}
+
+// dialServer 在内存连接上启动服务并返回连接
+func dialServer(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// errorInfo 取出状态中的ErrorInfo详情
+func errorInfo(err error) *errdetails.ErrorInfo {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// TestServer 测试认证、权限范围、错误码转换和各接口
+func TestServer(t *testing.T) {
+	keys := auth.NewMemoryKeyStore()
+	chatKey, chatRecord, _ := auth.GenerateAPIKey("chat-only", []string{auth.ScopeChat}, 0)
+	keys.Save(context.Background(), chatRecord)
+
+	s := NewServer(auth.NewAuthenticator(keys), nil, false)
+	s.SetChat(fakeBackend{})
+	s.SetTasks(fakeBackend{})
+	s.SetKnowledge(fakeBackend{})
+	client := startServer(t, s)
+
+	// 未携带凭证
+	_, err := client.SearchKnowledge(context.Background(), &assistantv1.SearchKnowledgeRequest{Query: "rag"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("missing credential should be unauthenticated, got %v", err)
+	}
+	if info := errorInfo(err); info == nil || info.Reason != string(apierror.CodeUnauthenticated) || info.Metadata["request_id"] == "" {
+		t.Errorf("error should carry code and request id: %+v", info)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Bearer "+chatKey, "x-request-id", "req-123")
+
+	var header metadata.MD
+	resp, err := client.SearchKnowledge(ctx, &assistantv1.SearchKnowledgeRequest{Query: "rag"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("SearchKnowledge failed: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Errorf("default top_k should be 3, got %d", len(resp.Results))
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-123" {
+		t.Errorf("request id should be echoed, got %v", got)
+	}
+	resp, err = client.SearchKnowledge(ctx, &assistantv1.SearchKnowledgeRequest{Query: "rag", TopK: 1000})
+	if err != nil {
+		t.Fatalf("SearchKnowledge failed: %v", err)
+	}
+	if len(resp.Results) != maxSearchTopK {
+		t.Errorf("top_k should be capped at %d, got %d", maxSearchTopK, len(resp.Results))
+	}
+
+	// 缺少workflows:admin权限
+	_, err = client.ExecuteWorkflow(ctx, &assistantv1.ExecuteWorkflowRequest{WorkflowId: "wf-1"})
+	if status.Code(err) != codes.PermissionDenied || errorInfo(err).Metadata["required_scope"] != auth.ScopeWorkflowsAdmin {
+		t.Errorf("missing scope should be permission denied, got %v", err)
+	}
+
+	task, err := client.SubmitTask(ctx, &assistantv1.SubmitTaskRequest{Type: "researcher", Goal: "搜索AI信息"})
+	if err != nil || task.TaskId != "task-1" || task.Status != "pending" || len(task.Transitions) != 1 {
+		t.Errorf("unexpected task: %+v, %v", task, err)
+	}
+	if _, err := client.SubmitTask(ctx, &assistantv1.SubmitTaskRequest{Type: "researcher"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing goal should be invalid argument, got %v", err)
+	}
+	if _, err := client.GetTask(ctx, &assistantv1.GetTaskRequest{TaskId: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("missing task should be not found, got %v", err)
+	}
+
+	stream, err := client.StreamChat(ctx, &assistantv1.ChatRequest{SessionId: "s1", Message: "你好"})
+	if err != nil {
+		t.Fatalf("StreamChat failed: %v", err)
+	}
+	var tokens string
+	var done *assistantv1.ChatResponse
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		tokens += event.GetToken()
+		if event.GetDone() != nil {
+			done = event.GetDone()
+		}
+	}
+	if tokens != "你好" || done == nil || done.Response != "你好" {
+		t.Errorf("unexpected stream: tokens=%q done=%+v", tokens, done)
+	}
+}
+
+// TestServerUnimplemented 未设置后端的接口返回Unimplemented
+func TestServerUnimplemented(t *testing.T) {
+	client := startServer(t, NewServer(nil, nil, false))
+
+	_, err := client.Chat(context.Background(), &assistantv1.ChatRequest{SessionId: "s1", Message: "hi"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("chat without backend should be unimplemented, got %v", err)
+	}
+}
+
+// waitFor 轮询直到条件满足或超时
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestAgentService 测试远程Agent注册、接收调度器分配的任务、上报进度和结果，以及断开后变为inactive
+func TestAgentService(t *testing.T) {
+	keys := auth.NewMemoryKeyStore()
+	agentKey, agentRecord, _ := auth.GenerateAPIKey("remote-agent", []string{auth.ScopeAgentsConnect}, 0)
+	keys.Save(context.Background(), agentRecord)
+	chatKey, chatRecord, _ := auth.GenerateAPIKey("chat-only", []string{auth.ScopeChat}, 0)
+	keys.Save(context.Background(), chatRecord)
+
+	registry := aiagentorchestrator.NewAgentRegistry()
+	remote := aiagentorchestrator.NewRemoteAgents(registry)
+	scheduler := aiagentorchestrator.NewTaskSchedulerFromConfig(registry, config.SchedulerConfig{PollInterval: "10ms", Workers: 1})
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	outcomes := make(chan outcome, 1)
+	scheduler.SetExecutor(func(ctx context.Context, task *aiagentorchestrator.Task, agent *aiagentorchestrator.AgentInfo) (interface{}, error) {
+		result, err := remote.Execute(ctx, task, agent)
+		outcomes <- outcome{result, err}
+		return result, err
+	})
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	s := NewServer(auth.NewAuthenticator(keys), nil, false)
+	s.SetAgents(NewAgentService(registry, scheduler, remote))
+	conn := dialServer(t, s)
+
+	// 缺少agents:connect权限
+	chatCtx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", chatKey)
+	_, err := agentv1.NewAgentServiceClient(conn).Register(chatCtx, &agentv1.RegisterRequest{Name: "remote-1"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("register without agents:connect should be denied, got %v", err)
+	}
+
+	client := agentclient.New(conn, agentclient.Options{
+		Name:         "remote-1",
+		Capabilities: []string{"search"},
+		APIKey:       agentKey,
+	})
+	handler := func(ctx context.Context, task *agentv1.Task, report agentclient.ReportFunc) (interface{}, error) {
+		report(50, "halfway")
+		// 进度记录到调度器后再返回结果（在客户端协程中执行，不能调用t.Fatal）
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if running, err := scheduler.GetTask(task.GetTaskId()); err == nil && running.Progress != nil {
+				if running.Progress.Percent != 50 || running.Progress.Message != "halfway" {
+					t.Errorf("unexpected progress: %+v", running.Progress)
+				}
+				return map[string]interface{}{"answer": task.GetGoal()}, nil
+			}
+		}
+		t.Error("progress was not recorded by the scheduler")
+		return map[string]interface{}{"answer": task.GetGoal()}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- client.Run(ctx, handler) }()
+
+	waitFor(t, "agent to connect", func() bool {
+		agent, err := registry.Get("remote-1")
+		return err == nil && agent.Status == "active"
+	})
+	agent, _ := registry.Get("remote-1")
+	if agent.Type != "custom" || len(agent.Capabilities) != 1 || agent.Endpoint == "" {
+		t.Errorf("unexpected registered agent: %+v", agent)
+	}
+
+	if err := scheduler.Submit(&aiagentorchestrator.Task{ID: "t1", Goal: "hello", AssignedTo: "remote-1"}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	select {
+	case got := <-outcomes:
+		if got.err != nil {
+			t.Fatalf("remote task failed: %v", got.err)
+		}
+		if result, ok := got.result.(map[string]interface{}); !ok || result["answer"] != "hello" {
+			t.Errorf("unexpected result: %#v", got.result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for remote task")
+	}
+
+	// 未分配给该Agent的任务不能上报
+	agentCtx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", agentKey)
+	stream, err := agentv1.NewAgentServiceClient(conn).ReportProgress(agentCtx)
+	if err != nil {
+		t.Fatalf("ReportProgress failed: %v", err)
+	}
+	stream.Send(&agentv1.TaskProgress{Name: "remote-1", TaskId: "t1", Percent: 10})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.NotFound {
+		t.Errorf("progress for a finished task should be not found, got %v", err)
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Run should stop cleanly, got %v", err)
+	}
+	waitFor(t, "agent to become inactive", func() bool {
+		agent, err := registry.Get("remote-1")
+		return err == nil && agent.Status == "inactive"
+	})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getExecution 获取工作流执行记录的快照，其他租户的执行按不存在处理
+func (h *AgentHandler) getExecution(c *gin.Context) (*workflow.WorkflowExecution, error) {
+	executionID := c.Param("id")
+	execution, err := h.stateManager.GetExecution(executionID)
+	if err == nil && execution.Tenant != tenant.FromContext(c.Request.Context()) {
+		execution = nil
+	}
+	if err != nil || execution == nil {
+		return nil, apierror.New(apierror.CodeNotFound, "Execution not found").WithDetails(gin.H{"execution_id": executionID})
+	}
+	return execution.Snapshot(), nil
+}
+
+// ListExecutionArtifacts 列出工作流执行中生成的产物（报告、图表、转换后的文件、压缩包等）
+// 执行仍在进行时只包含已完成步骤的产物
+//
+// 响应示例：
+//
+//	{
+//	  "execution_id": "exec-1",
+//	  "artifacts": [
+//	    {"name": "report.md", "artifact_id": "artifact-...", "step_id": "write", "content_type": "text/markdown", "size": 1024,
+//	     "created_at": "2024-01-01T00:00:00Z", "url": "/api/v1/workflows/executions/exec-1/artifacts/report.md"}
+//	  ],
+//	  "count": 1
+//	}
+func (h *AgentHandler) ListExecutionArtifacts(c *gin.Context) {
+	execution, err := h.getExecution(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	items := make([]gin.H, 0, len(execution.Artifacts))
+	for _, item := range execution.ListArtifacts() {
+		items = append(items, gin.H{
+			"name":         item.Name,
+			"artifact_id":  item.ArtifactID,
+			"step_id":      item.StepID,
+			"content_type": artifactContentType(item.Name, item.ContentType),
+			"size":         item.Size,
+			"created_at":   item.CreatedAt,
+			"url":          executionArtifactURL(execution.ID, item.Name),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"execution_id": execution.ID,
+		"artifacts":    items,
+		"count":        len(items),
+	})
+}
+
+// GetExecutionArtifact 按名称下载工作流执行中生成的产物，直接返回文件内容
+// 图片、文本、JSON和PDF在浏览器中直接显示，其他类型作为附件下载；查询参数 download=true 时总是作为附件
+func (h *AgentHandler) GetExecutionArtifact(c *gin.Context) {
+	execution, err := h.getExecution(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	name := c.Param("name")
+	ref, ok := execution.GetArtifact(name)
+	if !ok {
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Artifact not found").
+			WithDetails(gin.H{"execution_id": execution.ID, "name": name}))
+		return
+	}
+
+	item, data, err := h.artifactStore.Get(c.Request.Context(), ref.ArtifactID)
+	if err == nil && item.Tenant != tenant.FromContext(c.Request.Context()) {
+		err = artifact.ErrArtifactNotFound
+	}
+	if err != nil {
+		if errors.Is(err, artifact.ErrArtifactNotFound) {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Artifact not found").
+				WithDetails(gin.H{"execution_id": execution.ID, "name": name}))
+			return
+		}
+		apierror.Respond(c, apierror.Annotate(err, "Failed to get artifact"))
+		return
+	}
+
+	contentType := artifactContentType(name, item.ContentType)
+	disposition := "attachment"
+	if c.Query("download") != "true" && inlineContentType(contentType) {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q; filename*=UTF-8''%s", disposition, asciiFilename(name), url.PathEscape(name)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// executionArtifactURL 执行产物的下载地址
+func executionArtifactURL(executionID, name string) string {
+	return "/api/v1/workflows/executions/" + executionID + "/artifacts/" + url.PathEscape(name)
+}
+
+// artifactContentType 产物的内容类型，未记录具体类型时按文件扩展名推断
+func artifactContentType(name, contentType string) string {
+	if contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	if guessed := mime.TypeByExtension(filepath.Ext(name)); guessed != "" {
+		return guessed
+	}
+	return "application/octet-stream"
+}
+
+// inlineContentType 浏览器可以直接显示的内容类型
+func inlineContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/pdf":
+		return true
+	}
+	return false
+}
+
+// asciiFilename Content-Disposition的filename参数，非ASCII字符替换为下划线，完整名称见filename*
+func asciiFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+}
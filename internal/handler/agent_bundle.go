@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"ai-agent-assistant/internal/apierror"
+	aitools "ai-agent-assistant/internal/tools"
+	"ai-agent-assistant/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newWorkflowBundles 创建工作流包管理器，使用当前的工作流存储和执行器的提示词模板库、工具链库，按Agent工厂中的人设比对
+func (h *AgentHandler) newWorkflowBundles() *workflow.BundleManager {
+	bundles := workflow.NewBundleManager(h.workflowRepo, h.workflowExecutor.Prompts(), h.workflowExecutor.ToolChains())
+	bundles.SetPersonaResolver(func(agent string) (*workflow.PersonaRef, bool) {
+		p, ok := h.agentFactory.Persona(agent)
+		if !ok {
+			return nil, false
+		}
+		return &workflow.PersonaRef{Agent: p.Agent, Name: p.Name, Model: p.Model, Tools: p.Tools}, true
+	})
+	return bundles
+}
+
+// seedToolChains 把预定义的工具链（见 tools.CreateToolChains）加入工作流的工具链库，tool步骤可通过 config.chain 引用
+func seedToolChains(library *workflow.ToolChainLibrary, toolManager *aitools.ToolManager) {
+	for name, chain := range aitools.CreateToolChains(toolManager) {
+		definition := &workflow.ToolChainDefinition{Name: name}
+		for _, step := range chain.GetSteps() {
+			definition.Steps = append(definition.Steps, workflow.ToolChainStep{
+				Tool:      step.ToolName,
+				Operation: step.Operation,
+				Params:    step.Params,
+				InputFrom: step.InputFrom,
+			})
+		}
+		library.Put(name, definition)
+	}
+}
+
+// ExportWorkflow 导出工作流包：工作流定义及其引用的提示词模板、工具链定义和task步骤所用Agent的人设
+// 导出的内容可直接作为 POST /workflows/import 的请求体导入其他环境；查询参数 download=true 时作为附件下载
+//
+// 响应示例：
+//
+//	{
+//	  "version": 1,
+//	  "exported_at": "2024-01-01T00:00:00Z",
+//	  "workflow": {"id": "wf-...", "name": "daily-report", "steps": [...]},
+//	  "prompts": [{"name": "summary", "template": "总结：{{content}}"}],
+//	  "tool_chains": [{"name": "data_processing", "steps": [{"tool": "file", "operation": "read", "params": {...}}]}],
+//	  "personas": [{"agent": "writer", "name": "技术写作者", "model": "gpt-4o"}]
+//	}
+func (h *AgentHandler) ExportWorkflow(c *gin.Context) {
+	wf, err := h.getWorkflow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	bundle, err := h.workflowBundles.Export(c.Request.Context(), wf.ID)
+	if err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to export workflow"))
+		return
+	}
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", asciiFilename(wf.Name)+".workflow.json"))
+	}
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportWorkflow 导入工作流包，请求体为 GET /workflows/:id/export 的响应
+// 先校验包中的工作流、提示词模板和工具链，全部通过后再一起安装，工作流归属于当前租户；
+// 已有内容不同的同名定义时返回409，查询参数 overwrite=true 时覆盖。Agent不存在或人设与导出环境不一致时在warnings中提示
+//
+// 响应示例：
+//
+//	{"workflow_id": "wf-...", "replaced": false, "prompts": ["summary"], "tool_chains": [], "warnings": ["agent writer persona model differs: bundle \"gpt-4o\", local \"\""]}
+func (h *AgentHandler) ImportWorkflow(c *gin.Context) {
+	var bundle workflow.Bundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		apierror.Respond(c, apierror.New(apierror.CodeValidation, "Invalid workflow bundle").WithDetails(gin.H{"error": err.Error()}))
+		return
+	}
+
+	result, err := h.workflowBundles.Import(c.Request.Context(), &bundle, workflow.ImportOptions{
+		Overwrite: c.Query("overwrite") == "true",
+	})
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+
+	"ai-agent-assistant/internal/apierror"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deadLetterListSpec 死信队列列表支持的排序和过滤字段
+var deadLetterListSpec = pagination.Spec[*aiagentorchestrator.DeadLetter]{
+	Fields: map[string]pagination.Field[*aiagentorchestrator.DeadLetter]{
+		"reason":      func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.Reason },
+		"failed_at":   func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.FailedAt },
+		"type":        func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.Task.Type },
+		"assigned_to": func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.Task.AssignedTo },
+		"attempts":    func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.Task.Attempts },
+		"priority":    func(dl *aiagentorchestrator.DeadLetter) interface{} { return dl.Task.Priority },
+	},
+	DefaultSort: "-failed_at",
+}
+
+// scheduler 任务调度器，未启用时返回错误
+func (h *AgentHandler) scheduler() (*aiagentorchestrator.TaskScheduler, error) {
+	if h.taskScheduler == nil {
+		return nil, apierror.New(apierror.CodeUnavailable, "Task scheduler is not enabled")
+	}
+	return h.taskScheduler, nil
+}
+
+// ListDeadLetters 列出死信队列中的任务
+// 执行失败且用完重试次数（scheduler.retry或任务的retry_policy）、或多次分配不到Agent的任务进入死信队列
+// 查询参数：limit、offset、sort（默认-failed_at），按reason、type、assigned_to、attempts、priority过滤
+//
+// 响应示例：
+//
+//	{
+//	  "dead_letters": [
+//	    {"task": {"id": "task-1", "attempts": 3, "error": "...", ...}, "reason": "execution_failed", "failed_at": "2024-01-01T00:00:00Z"}
+//	  ],
+//	  "total": 1, "limit": 50, "offset": 0, "has_more": false
+//	}
+func (h *AgentHandler) ListDeadLetters(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	page, err := deadLetterListSpec.List(c, scheduler.DeadLetters())
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("dead_letters"))
+}
+
+// GetDeadLetter 查看死信队列中的任务
+func (h *AgentHandler) GetDeadLetter(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	dl, err := scheduler.GetDeadLetter(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dl)
+}
+
+// RequeueDeadLetter 把死信队列中的任务重新提交给调度器，执行次数和错误清零
+func (h *AgentHandler) RequeueDeadLetter(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	task, err := scheduler.Requeue(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"task": task})
+}
+
+// DiscardDeadLetter 丢弃死信队列中的任务
+func (h *AgentHandler) DiscardDeadLetter(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if err := scheduler.Discard(c.Param("id")); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -2,16 +2,31 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	aiagentconfig "ai-agent-assistant/internal/config"
 	aiagentexpert "ai-agent-assistant/internal/agent/expert"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/idempotency"
+	"ai-agent-assistant/internal/idgen"
+	"ai-agent-assistant/internal/jobs"
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/pagination"
+	"ai-agent-assistant/internal/report"
+	"ai-agent-assistant/internal/tenant"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	aiagenttask "ai-agent-assistant/internal/task"
 	aitools "ai-agent-assistant/internal/tools"
 	"ai-agent-assistant/internal/workflow"
+	"ai-agent-assistant/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +41,18 @@ type AgentHandler struct {
 	workflowExecutor *workflow.Executor              // 工作流执行器
 	stateManager     *workflow.StateManager          // 状态管理器
 	toolManager      *aitools.ToolManager            // 工具管理器
+	authenticator    *auth.Authenticator             // 认证器（nil表示不校验权限）
+	taskStore        aiagenttask.TaskStore           // 任务执行记录存储
+	taskCancels      *taskCancels                    // 后台执行中的任务的取消函数
+	jobManager       *jobs.Manager                   // 异步作业（报告生成、批量任务）
+	idempotency      *idempotency.Cache              // 幂等键缓存（nil表示不支持Idempotency-Key）
+	reportStore      report.Store                    // 报告存储
+	artifactStore    artifact.Store                  // 产物存储（分析Agent渲染的图表）
+	workflowRepo     workflow.Repository             // 工作流定义存储
+	workflowBundles  *workflow.BundleManager         // 工作流包的导出与导入
+	taskTimeout      time.Duration                   // 后台任务的最长执行时间（tasks.timeout），0表示不限制
+	snapshotDir      string                          // 运行时快照的保存目录（scheduler.snapshot_dir）
+	leaderElector    *aiagentorchestrator.LeaderElector // 编排器选主（nil表示未启用，本实例总是主实例）
 }
 
 // NewAgentHandler 创建Agent处理器
@@ -42,26 +69,111 @@ func NewAgentHandler(
 	registry *aiagentorchestrator.AgentRegistry,
 	scheduler *aiagentorchestrator.TaskScheduler,
 ) *AgentHandler {
-	// 创建工作流执行器
-	workflowExecutor := workflow.NewExecutor(registry, scheduler)
-
-	// 创建工具管理器
-	toolManager := aitools.NewToolManager(&aitools.ToolManagerConfig{
-		AutoRegister: true,
-	})
+	// 创建工作流执行器和工具管理器（workflows.executor、tools.manager 配置段）
+	var workflowCfg aiagentconfig.WorkflowStoreConfig
+	var toolsCfg aiagentconfig.ToolManagerConfig
+	if cfg != nil {
+		workflowCfg = cfg.Workflows
+		toolsCfg = cfg.Tools.Manager
+	}
+	workflowExecutor := workflow.NewExecutorFromConfig(registry, scheduler, workflowCfg.Executor)
+	toolManager := aitools.NewToolManagerFromConfig(toolsCfg)
 
 	// 将工具管理器设置到工厂
 	factory.SetToolManager(toolManager)
 
-	return &AgentHandler{
+	h := &AgentHandler{
 		config:           cfg,
 		agentFactory:     factory,
 		agentRegistry:    registry,
 		taskScheduler:    scheduler,
 		workflowExecutor: workflowExecutor,
-		stateManager:     workflow.NewStateManager(),
+		stateManager:     workflowExecutor.StateManager(),
 		toolManager:      toolManager,
+		taskStore:        aiagenttask.NewMemoryTaskStore(),
+		taskCancels:      newTaskCancels(),
+		jobManager:       jobs.NewManager(nil),
+		reportStore:      report.NewMemoryStore(),
+		artifactStore:    artifact.NewMemoryStore(),
+		workflowRepo:     workflow.NewMemoryRepository(),
+		snapshotDir:      defaultSnapshotDir,
 	}
+	factory.SetArtifactStore(h.artifactStore)
+	workflowExecutor.SetArtifactStore(h.artifactStore)
+	if cfg != nil {
+		if d, err := time.ParseDuration(cfg.Tasks.Timeout); err == nil && d > 0 {
+			h.taskTimeout = d
+		}
+		if cfg.Scheduler.SnapshotDir != "" {
+			h.snapshotDir = cfg.Scheduler.SnapshotDir
+		}
+		workflowExecutor.SetQuotas(tenant.NewQuotasFromConfig(cfg.Tenancy))
+	}
+
+	// 工作流的task步骤由Agent工厂创建的Agent执行，tool步骤直接调用工具管理器
+	workflowExecutor.SetStepRunner(h.runAgentStep)
+	workflowExecutor.SetToolManager(toolManager)
+	seedToolChains(workflowExecutor.ToolChains(), toolManager)
+	h.workflowBundles = h.newWorkflowBundles()
+
+	return h
+}
+
+// SetModelManager 设置模型管理器
+// 工作流中的consensus（多模型共识）和llm（模型调用）步骤依赖模型管理器
+func (h *AgentHandler) SetModelManager(modelManager *llm.ModelManager) {
+	h.workflowExecutor.SetModelManager(modelManager)
+}
+
+// SetAuthenticator 设置认证器
+// 设置后RegisterRoutes会按路由组校验API Key的权限范围，需在RegisterRoutes之前调用
+func (h *AgentHandler) SetAuthenticator(authenticator *auth.Authenticator) {
+	h.authenticator = authenticator
+}
+
+// SetTaskStore 设置任务执行记录存储（默认为内存存储）
+func (h *AgentHandler) SetTaskStore(store aiagenttask.TaskStore) {
+	h.taskStore = store
+}
+
+// SetJobManager 设置异步作业管理器，与JobHandler共用以便通过 /jobs/:id 查询
+func (h *AgentHandler) SetJobManager(manager *jobs.Manager) {
+	h.jobManager = manager
+}
+
+// SetReportStore 设置报告存储（默认为内存存储）
+func (h *AgentHandler) SetReportStore(store report.Store) {
+	h.reportStore = store
+}
+
+// RegisterTools 注册额外的工具（如git、沙箱执行工具），Agent和 /tools 接口均可使用
+func (h *AgentHandler) RegisterTools(tools ...aitools.ToolExecutor) error {
+	for _, tool := range tools {
+		if err := h.toolManager.GetRegistry().Register(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetArtifactStore 设置产物存储，Agent渲染的图表和工作流步骤声明的产物保存到其中，
+// 通过 GET /artifacts/:id 或 GET /workflows/executions/:id/artifacts/:name 获取
+func (h *AgentHandler) SetArtifactStore(store artifact.Store) {
+	h.artifactStore = store
+	h.agentFactory.SetArtifactStore(store)
+	h.workflowExecutor.SetArtifactStore(store)
+}
+
+// SetWorkflowRepository 设置工作流定义存储（默认为内存存储）
+func (h *AgentHandler) SetWorkflowRepository(repo workflow.Repository) {
+	h.workflowRepo = repo
+	h.workflowBundles = h.newWorkflowBundles()
+}
+
+// SetIdempotency 设置幂等键缓存
+// 设置后任务提交和工作流执行支持Idempotency-Key请求头，需在RegisterRoutes之前调用
+func (h *AgentHandler) SetIdempotency(cache *idempotency.Cache) {
+	h.idempotency = cache
 }
 
 // RegisterRoutes 注册Agent相关的路由
@@ -90,39 +202,60 @@ func (h *AgentHandler) RegisterRoutes(router *gin.RouterGroup) {
 	taskGroup := router.Group("/tasks")
 	{
 		// POST /tasks - 创建并执行新任务
-		taskGroup.POST("", h.ExecuteTask)
+		taskGroup.POST("", h.authenticator.RequireScope(auth.ScopeChat), h.idempotency.Middleware(), h.ExecuteTask)
 
 		// GET /tasks/:id - 获取任务执行状态
 		taskGroup.GET("/:id", h.GetTaskStatus)
 
+		// DELETE /tasks/:id - 取消任务
+		taskGroup.DELETE("/:id", h.authenticator.RequireScope(auth.ScopeChat), h.CancelTask)
+
 		// POST /tasks/batch - 批量执行任务
-		taskGroup.POST("/batch", h.ExecuteBatchTasks)
+		taskGroup.POST("/batch", h.authenticator.RequireScope(auth.ScopeChat), h.idempotency.Middleware(), h.ExecuteBatchTasks)
 	}
 
 	// 工作流相关路由
 	workflowGroup := router.Group("/workflows")
 	{
 		// POST /workflows - 创建新工作流
-		workflowGroup.POST("", h.CreateWorkflow)
+		workflowGroup.POST("", h.authenticator.RequireScope(auth.ScopeWorkflowsAdmin), h.CreateWorkflow)
+
+		// POST /workflows/plan - 由规划Agent按目标生成工作流
+		workflowGroup.POST("/plan", h.authenticator.RequireScope(auth.ScopeWorkflowsAdmin), h.PlanWorkflow)
+
+		// POST /workflows/import - 导入工作流包（工作流及其提示词模板、工具链）
+		workflowGroup.POST("/import", h.authenticator.RequireScope(auth.ScopeWorkflowsAdmin), h.ImportWorkflow)
 
 		// GET /workflows - 获取所有工作流列表
 		workflowGroup.GET("", h.ListWorkflows)
 
+		// GET /workflows/functions - 列出步骤配置和条件表达式中可用的内置函数
+		workflowGroup.GET("/functions", h.ListWorkflowFunctions)
+
 		// GET /workflows/:id - 获取工作流详情
 		workflowGroup.GET("/:id", h.GetWorkflow)
 
+		// GET /workflows/:id/export - 导出工作流包
+		workflowGroup.GET("/:id/export", h.ExportWorkflow)
+
 		// POST /workflows/:id/execute - 执行工作流
-		workflowGroup.POST("/:id/execute", h.ExecuteWorkflow)
+		workflowGroup.POST("/:id/execute", h.authenticator.RequireScope(auth.ScopeWorkflowsAdmin), h.idempotency.Middleware(), h.ExecuteWorkflow)
 
 		// GET /workflows/:id/executions - 获取工作流执行历史
 		workflowGroup.GET("/:id/executions", h.GetWorkflowExecutions)
 
+		// GET /workflows/executions/:id/artifacts - 列出执行中生成的产物
+		workflowGroup.GET("/executions/:id/artifacts", h.ListExecutionArtifacts)
+
+		// GET /workflows/executions/:id/artifacts/:name - 下载执行中生成的产物
+		workflowGroup.GET("/executions/:id/artifacts/:name", h.GetExecutionArtifact)
+
 		// DELETE /workflows/:id - 删除工作流
-		workflowGroup.DELETE("/:id", h.DeleteWorkflow)
+		workflowGroup.DELETE("/:id", h.authenticator.RequireScope(auth.ScopeWorkflowsAdmin), h.DeleteWorkflow)
 	}
 
 	// 分析和研究相关路由
-	analysisGroup := router.Group("/analysis")
+	analysisGroup := router.Group("/analysis", h.authenticator.RequireScope(auth.ScopeChat))
 	{
 		// POST /analysis/search - 执行网络搜索
 		analysisGroup.POST("/search", h.PerformSearch)
@@ -135,6 +268,59 @@ func (h *AgentHandler) RegisterRoutes(router *gin.RouterGroup) {
 
 		// POST /analysis/report - 生成分析报告
 		analysisGroup.POST("/report", h.GenerateReport)
+
+		// GET /analysis/report/:id - 获取报告状态和正文
+		analysisGroup.GET("/report/:id", h.GetReport)
+	}
+
+	// 运行时快照路由（蓝绿部署时在实例间迁移编排器状态）
+	snapshotGroup := router.Group("/admin/snapshots", h.authenticator.RequireScope(auth.ScopeOrchestratorAdmin))
+	{
+		// POST /admin/snapshots - 导出Agent、等待任务和运行中的执行到快照文件
+		snapshotGroup.POST("", h.CreateSnapshot)
+
+		// GET /admin/snapshots - 列出快照文件
+		snapshotGroup.GET("", h.ListSnapshots)
+
+		// POST /admin/snapshots/:name/restore - 从快照文件恢复
+		snapshotGroup.POST("/:name/restore", h.RestoreSnapshot)
+	}
+
+	// 选主状态路由（多实例部署时只有主实例调度任务和启动工作流执行）
+	// GET /admin/leader - 查看本实例是否为主实例以及当前主实例
+	router.GET("/admin/leader", h.authenticator.RequireScope(auth.ScopeOrchestratorAdmin), h.GetLeader)
+
+	// 容量模拟路由（回放任务到达轨迹，预估不同Agent数和并发上限下的排队时间与利用率）
+	simulationGroup := router.Group("/admin/scheduler", h.authenticator.RequireScope(auth.ScopeOrchestratorAdmin))
+	{
+		// GET /admin/scheduler/trace - 导出记录的任务到达轨迹
+		simulationGroup.GET("/trace", h.GetSchedulerTrace)
+
+		// POST /admin/scheduler/simulate - 在假设的容量下回放轨迹
+		simulationGroup.POST("/simulate", h.SimulateScheduler)
+	}
+
+	// 死信队列路由（用完重试次数仍失败的调度任务）
+	deadLetterGroup := router.Group("/admin/dead-letters", h.authenticator.RequireScope(auth.ScopeOrchestratorAdmin))
+	{
+		// GET /admin/dead-letters - 列出死信队列中的任务
+		deadLetterGroup.GET("", h.ListDeadLetters)
+
+		// GET /admin/dead-letters/:id - 查看任务、进入原因和最后一次的错误
+		deadLetterGroup.GET("/:id", h.GetDeadLetter)
+
+		// POST /admin/dead-letters/:id/requeue - 重新提交任务
+		deadLetterGroup.POST("/:id/requeue", h.RequeueDeadLetter)
+
+		// DELETE /admin/dead-letters/:id - 丢弃任务
+		deadLetterGroup.DELETE("/:id", h.DiscardDeadLetter)
+	}
+
+	// 产物相关路由
+	artifactGroup := router.Group("/artifacts")
+	{
+		// GET /artifacts/:id - 获取Agent生成的图表等产物
+		artifactGroup.GET("/:id", h.GetArtifact)
 	}
 
 	// 工具相关路由
@@ -150,21 +336,46 @@ func (h *AgentHandler) RegisterRoutes(router *gin.RouterGroup) {
 		toolsGroup.GET("/:name/capabilities", h.GetToolCapabilities)
 
 		// POST /tools/execute - 执行工具操作
-		toolsGroup.POST("/execute", h.ExecuteTool)
+		toolsGroup.POST("/execute", h.authenticator.RequireScope(auth.ScopeToolsExecute), h.ExecuteTool)
 
 		// POST /tools/batch - 批量执行工具
-		toolsGroup.POST("/batch", h.BatchExecuteTools)
+		toolsGroup.POST("/batch", h.authenticator.RequireScope(auth.ScopeToolsExecute), h.BatchExecuteTools)
 
 		// GET /tools/chains - 获取所有工具链
 		toolsGroup.GET("/chains", h.ListToolChains)
 
 		// POST /tools/chains/:name/execute - 执行工具链
-		toolsGroup.POST("/chains/:name/execute", h.ExecuteToolChain)
+		toolsGroup.POST("/chains/:name/execute", h.authenticator.RequireScope(auth.ScopeToolsExecute), h.ExecuteToolChain)
 	}
 }
 
+// 列表接口支持的排序和过滤字段，查询参数约定见pagination.Spec
+var (
+	agentListSpec = pagination.Spec[map[string]interface{}]{
+		Fields:      pagination.MapFields("name", "type", "status", "capabilities"),
+		DefaultSort: "name",
+	}
+	workflowListSpec = pagination.Spec[map[string]interface{}]{
+		Fields:      pagination.MapFields("name", "status", "created_at"),
+		DefaultSort: "-created_at",
+	}
+	executionListSpec = pagination.Spec[map[string]interface{}]{
+		Fields:      pagination.MapFields("status", "started_at"),
+		DefaultSort: "-started_at",
+	}
+	toolListSpec = pagination.Spec[map[string]interface{}]{
+		Fields:      pagination.MapFields("name", "version"),
+		DefaultSort: "name",
+	}
+	toolChainListSpec = pagination.Spec[map[string]interface{}]{
+		Fields:      pagination.MapFields("name", "steps"),
+		DefaultSort: "name",
+	}
+)
+
 // ListAgents 获取所有Agent列表
 // 返回系统中所有可用的Agent及其基本信息
+// 查询参数：limit、offset、sort（默认name），按type、status、capabilities过滤
 //
 // 响应示例：
 // {
@@ -177,17 +388,21 @@ func (h *AgentHandler) RegisterRoutes(router *gin.RouterGroup) {
 //       "status": "idle"
 //     }
 //   ],
-//   "total": 3
+//   "total": 3,
+//   "limit": 50,
+//   "offset": 0,
+//   "has_more": false
 // }
 func (h *AgentHandler) ListAgents(c *gin.Context) {
 	// 从工厂获取所有Agent的信息
-	agentsInfo := h.agentFactory.GetAgentInfo()
+	page, err := agentListSpec.List(c, h.agentFactory.GetAgentInfo())
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
 	// 返回Agent列表
-	c.JSON(http.StatusOK, gin.H{
-		"agents": agentsInfo,
-		"total":  len(agentsInfo),
-	})
+	c.JSON(http.StatusOK, page.Response("agents"))
 }
 
 // GetAgent 获取指定Agent的详细信息
@@ -209,14 +424,11 @@ func (h *AgentHandler) GetAgent(c *gin.Context) {
 	// 获取Agent ID
 	agentID := c.Param("id")
 
-	// 从注册表获取Agent信息
-	agent, err := h.agentRegistry.Get(agentID)
+	// 从注册表获取Agent信息，其他租户的Agent按不存在处理
+	agent, err := h.agentRegistry.GetForTenant(tenant.FromContext(c.Request.Context()), agentID)
 	if err != nil {
 		// Agent不存在
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Agent not found",
-			"id":    agentID,
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Agent not found").WithDetails(gin.H{"id": agentID}))
 		return
 	}
 
@@ -241,12 +453,9 @@ func (h *AgentHandler) GetAgentCapabilities(c *gin.Context) {
 	agentID := c.Param("id")
 
 	// 从注册表获取Agent信息
-	agent, err := h.agentRegistry.Get(agentID)
+	agent, err := h.agentRegistry.GetForTenant(tenant.FromContext(c.Request.Context()), agentID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Agent not found",
-			"id":    agentID,
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Agent not found").WithDetails(gin.H{"id": agentID}))
 		return
 	}
 
@@ -274,17 +483,14 @@ func (h *AgentHandler) GetAgentStatus(c *gin.Context) {
 	agentID := c.Param("id")
 
 	// 从注册表获取Agent信息
-	agent, err := h.agentRegistry.Get(agentID)
+	agent, err := h.agentRegistry.GetForTenant(tenant.FromContext(c.Request.Context()), agentID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Agent not found",
-			"id":    agentID,
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Agent not found").WithDetails(gin.H{"id": agentID}))
 		return
 	}
 
 	// 检查Agent健康状态
-	isHealthy := h.agentRegistry.CheckHealth(agentID)
+	isHealthy := h.agentRegistry.CheckHealth(agent.Name)
 
 	// 返回Agent状态
 	c.JSON(http.StatusOK, gin.H{
@@ -310,13 +516,13 @@ func (h *AgentHandler) UpdateAgentHeartbeat(c *gin.Context) {
 	// 获取Agent ID
 	agentID := c.Param("id")
 
-	// 更新心跳时间
-	err := h.agentRegistry.UpdateHeartbeat(agentID)
+	// 更新心跳时间，只能更新对当前租户可见的Agent
+	agent, err := h.agentRegistry.GetForTenant(tenant.FromContext(c.Request.Context()), agentID)
+	if err == nil {
+		err = h.agentRegistry.UpdateHeartbeat(agent.Name)
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Failed to update heartbeat",
-			"id":    agentID,
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Failed to update heartbeat").WithDetails(gin.H{"id": agentID}))
 		return
 	}
 
@@ -352,26 +558,49 @@ func (h *AgentHandler) ExecuteTask(c *gin.Context) {
 	var req struct {
 		Type         string                 `json:"type" binding:"required"`         // Agent类型
 		Goal         string                 `json:"goal" binding:"required"`         // 任务目标
-		Priority     int                    `json:"priority"`                        // 任务优先级（0-3）
+		Priority     int                    `json:"priority" binding:"gte=0,lte=3"`  // 任务优先级（0-3）
 		Requirements map[string]interface{} `json:"requirements"`                    // 任务要求
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
+	record, err := h.SubmitTask(c.Request.Context(), TaskSubmission{
+		Type:         req.Type,
+		Goal:         req.Goal,
+		Priority:     req.Priority,
+		Requirements: req.Requirements,
+	})
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	// 返回任务信息
+	c.JSON(http.StatusAccepted, gin.H{
+		"task_id":    record.TaskID,
+		"status":     record.Status,
+		"agent":      record.Agent,
+		"started_at": time.Now().Format(time.RFC3339),
+	})
+}
+
+// TaskSubmission 提交任务的参数，REST与gRPC接口共用
+type TaskSubmission struct {
+	Type         string                 // Agent类型
+	Goal         string                 // 任务目标
+	Priority     int                    // 任务优先级（0-3）
+	Requirements map[string]interface{} // 任务要求
+}
+
+// SubmitTask 创建任务记录并在后台执行，返回提交时的记录
+func (h *AgentHandler) SubmitTask(ctx context.Context, req TaskSubmission) (*aiagenttask.TaskRecord, error) {
 	// 根据类型创建Agent
 	agent, err := h.agentFactory.CreateAgent(req.Type)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid agent type",
-			"type":  req.Type,
-		})
-		return
+		return nil, apierror.New(apierror.CodeValidation, "Invalid agent type").WithDetails(gin.H{"type": req.Type})
 	}
 
 	// 创建任务对象
@@ -385,19 +614,88 @@ func (h *AgentHandler) ExecuteTask(c *gin.Context) {
 		CreatedAt:    time.Now(),
 	}
 
-	// 在后台执行任务
-	go func() {
-		ctx := context.Background()
-		_, _ = agent.Execute(ctx, task)
-	}()
+	// 记录任务并在后台执行
+	record := aiagenttask.NewTaskRecord(task, agent.GetInfo().Name)
+	record.Tenant = tenant.FromContext(ctx)
+	if err := h.taskStore.Save(ctx, record); err != nil {
+		return nil, apierror.Annotate(err, "Failed to record task")
+	}
+	submitted := *record
+	submitted.Transitions = append([]aiagenttask.TaskTransition(nil), record.Transitions...)
+	// 任务不随请求取消，但沿用请求context中的租户、请求ID和追踪信息
+	go h.runTask(context.WithoutCancel(ctx), agent, task, record)
 
-	// 返回任务信息
-	c.JSON(http.StatusAccepted, gin.H{
-		"task_id":    task.ID,
-		"status":     task.Status,
-		"agent":      agent.GetInfo().Name,
-		"started_at": time.Now().Format(time.RFC3339),
-	})
+	return &submitted, nil
+}
+
+// GetTask 获取任务执行记录
+func (h *AgentHandler) GetTask(ctx context.Context, taskID string) (*aiagenttask.TaskRecord, error) {
+	record, err := h.taskStore.Get(ctx, taskID)
+	// 其他租户的任务同样按不存在处理
+	if err == nil && record.Tenant != tenant.FromContext(ctx) {
+		err = aiagenttask.ErrTaskNotFound
+	}
+	if err != nil {
+		if errors.Is(err, aiagenttask.ErrTaskNotFound) {
+			return nil, apierror.New(apierror.CodeNotFound, "Task not found").WithDetails(gin.H{"task_id": taskID})
+		}
+		return nil, apierror.Annotate(err, "Failed to get task")
+	}
+	return record, nil
+}
+
+// runTask 执行任务并记录状态变更、结果和错误，超过tasks.timeout时取消执行，
+// 通过 DELETE /tasks/:id 或所在作业被取消时标记为cancelled
+func (h *AgentHandler) runTask(ctx context.Context, agent aiagentexpert.ExpertAgent, task *aiagenttask.Task, record *aiagenttask.TaskRecord) {
+	ctx = tenant.WithTenant(ctx, record.Tenant)
+	if h.taskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.taskTimeout)
+		defer cancel()
+	}
+	ctx, done := h.taskCancels.start(ctx, task.ID)
+	defer done()
+
+	// 开始执行前已通过 DELETE /tasks/:id 取消的任务不再执行
+	if stored, err := h.taskStore.Get(ctx, task.ID); err == nil && stored.Status == aiagenttask.TaskStatusCancelled {
+		return
+	}
+	// 所在作业已被取消的任务同样不再执行
+	if errors.Is(ctx.Err(), context.Canceled) {
+		record.Transition(aiagenttask.TaskStatusCancelled, "cancelled before start")
+		if err := h.taskStore.Save(context.WithoutCancel(ctx), record); err != nil {
+			fmt.Printf("Failed to save task %s: %v\n", record.TaskID, err)
+		}
+		return
+	}
+
+	record.Transition(aiagenttask.TaskStatusRunning, "started")
+	_ = h.taskStore.Save(ctx, record)
+
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, agent, task)
+	if result != nil {
+		record.Output = result.Output
+		record.Metadata = result.Metadata
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		record.Error = err.Error()
+		record.Transition(aiagenttask.TaskStatusCancelled, "cancelled")
+	case err != nil:
+		record.Error = err.Error()
+		record.Transition(aiagenttask.TaskStatusFailed, "agent returned error")
+	case result != nil && result.Status == aiagenttask.TaskStatusFailed:
+		record.Error = result.Error
+		record.Transition(aiagenttask.TaskStatusFailed, "agent reported failure")
+	default:
+		record.Transition(aiagenttask.TaskStatusCompleted, "finished")
+	}
+
+	// 超时或取消后仍需保存最终状态
+	if err := h.taskStore.Save(context.WithoutCancel(ctx), record); err != nil {
+		fmt.Printf("Failed to save task %s: %v\n", record.TaskID, err)
+	}
 }
 
 // GetTaskStatus 获取任务执行状态
@@ -415,13 +713,40 @@ func (h *AgentHandler) GetTaskStatus(c *gin.Context) {
 	// 获取任务ID
 	taskID := c.Param("id")
 
-	// TODO: 从状态管理器获取任务状态
-	// 当前版本简化实现，返回任务ID
-	c.JSON(http.StatusOK, gin.H{
-		"task_id": taskID,
-		"status":  "pending",
-		"message": "Task status tracking will be implemented in next version",
-	})
+	record, err := h.GetTask(c.Request.Context(), taskID)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	response := gin.H{
+		"task_id":     record.TaskID,
+		"type":        record.Type,
+		"goal":        record.Goal,
+		"agent":       record.Agent,
+		"status":      record.Status,
+		"result":      record.Output,
+		"duration":    record.Duration().String(),
+		"created_at":  record.CreatedAt,
+		"transitions": record.Transitions,
+	}
+	if record.BatchID != "" {
+		response["batch_id"] = record.BatchID
+	}
+	if record.StartedAt != nil {
+		response["started_at"] = record.StartedAt
+	}
+	if record.CompletedAt != nil {
+		response["completed_at"] = record.CompletedAt
+	}
+	if record.Error != "" {
+		response["error"] = record.Error
+	}
+	if usage, ok := record.Metadata["usage"]; ok {
+		response["usage"] = usage
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // ExecuteBatchTasks 批量执行任务
@@ -433,8 +758,11 @@ func (h *AgentHandler) GetTaskStatus(c *gin.Context) {
 //   ]
 // }
 //
+// 可选callback_url：全部任务结束后投递签名webhook，也可通过 GET /jobs/:job_id 轮询
+//
 // 响应示例：
 // {
+//   "job_id": "job-1700000000000000000-1",
 //   "batch_id": "batch-001",
 //   "tasks": [
 //     {"task_id": "task-001", "status": "running"},
@@ -448,16 +776,18 @@ func (h *AgentHandler) ExecuteBatchTasks(c *gin.Context) {
 		Tasks []struct {
 			Type         string                 `json:"type" binding:"required"`
 			Goal         string                 `json:"goal" binding:"required"`
-			Priority     int                    `json:"priority"`
+			Priority     int                    `json:"priority" binding:"gte=0,lte=3"`
 			Requirements map[string]interface{} `json:"requirements"`
-		} `json:"tasks" binding:"required"`
+		} `json:"tasks" binding:"required,min=1,dive"`
+		CallbackURL string `json:"callback_url"` // 全部任务结束后接收webhook
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if err := h.jobManager.ValidateCallbackURL(req.CallbackURL); err != nil {
+		apierror.Respond(c, apierror.Validation(err))
 		return
 	}
 
@@ -465,6 +795,12 @@ func (h *AgentHandler) ExecuteBatchTasks(c *gin.Context) {
 	batchID := generateBatchID()
 
 	// 处理每个任务
+	type batchItem struct {
+		agent  aiagentexpert.ExpertAgent
+		task   *aiagenttask.Task
+		record *aiagenttask.TaskRecord
+	}
+	items := make([]batchItem, 0, len(req.Tasks))
 	taskResponses := make([]gin.H, 0, len(req.Tasks))
 	for _, taskReq := range req.Tasks {
 		// 创建Agent
@@ -488,20 +824,69 @@ func (h *AgentHandler) ExecuteBatchTasks(c *gin.Context) {
 			CreatedAt:    time.Now(),
 		}
 
-		// 在后台执行任务
-		go func(t *aiagenttask.Task) {
-			ctx := context.Background()
-			_, _ = agent.Execute(ctx, t)
-		}(task)
+		// 记录任务
+		record := aiagenttask.NewTaskRecord(task, agent.GetInfo().Name)
+		record.BatchID = batchID
+		record.Tenant = tenant.FromContext(c.Request.Context())
+		if err := h.taskStore.Save(c.Request.Context(), record); err != nil {
+			taskResponses = append(taskResponses, gin.H{
+				"error":   "Failed to record task",
+				"details": err.Error(),
+			})
+			continue
+		}
+		items = append(items, batchItem{agent: agent, task: task, record: record})
 
 		taskResponses = append(taskResponses, gin.H{
 			"task_id": task.ID,
-			"status":  task.Status,
+			"status":  record.Status,
 		})
 	}
 
+	// 作为一个作业在后台并发执行，全部结束后作业完成
+	job, err := h.jobManager.SubmitContext(c.Request.Context(), jobs.Request{
+		Kind:        "batch_tasks",
+		CallbackURL: req.CallbackURL,
+		Owner:       JobOwner(c),
+		Metadata:    map[string]interface{}{"batch_id": batchID},
+	}, func(ctx context.Context) (interface{}, error) {
+		var wg sync.WaitGroup
+		for _, item := range items {
+			wg.Add(1)
+			go func(item batchItem) {
+				defer wg.Done()
+				h.runTask(ctx, item.agent, item.task, item.record)
+			}(item)
+		}
+		wg.Wait()
+
+		results := make([]gin.H, 0, len(items))
+		failed := 0
+		for _, item := range items {
+			record, err := h.taskStore.Get(ctx, item.task.ID)
+			if err != nil {
+				return nil, err
+			}
+			if record.Status == aiagenttask.TaskStatusFailed {
+				failed++
+			}
+			results = append(results, gin.H{
+				"task_id":  record.TaskID,
+				"status":   record.Status,
+				"result":   record.Output,
+				"error":    record.Error,
+				"duration": record.Duration().String(),
+			})
+		}
+		return gin.H{"batch_id": batchID, "tasks": results, "failed": failed}, nil
+	})
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
 	// 返回批次信息
-	c.JSON(http.StatusAccepted, gin.H{
+	JobAccepted(c, job, gin.H{
 		"batch_id": batchID,
 		"tasks":    taskResponses,
 		"total":    len(req.Tasks),
@@ -509,55 +894,217 @@ func (h *AgentHandler) ExecuteBatchTasks(c *gin.Context) {
 }
 
 // CreateWorkflow 创建新工作流
+// definition的字段与YAML工作流定义相同（steps、agents、variables、config），
+// 创建时解析并校验步骤类型、依赖关系和环
 // 请求体示例：
 // {
 //   "name": "研究工作流",
-//   "definition": {...}
+//   "definition": {
+//     "variables": [{"name": "topic", "type": "string", "required": true}],
+//     "steps": [
+//       {"id": "research", "agent": "researcher", "config": {"goal": "收集资料：{{topic}}"}},
+//       {"id": "write", "agent": "writer", "depends_on": ["research"], "inputs": {"data": "research"}}
+//     ]
+//   }
 // }
 func (h *AgentHandler) CreateWorkflow(c *gin.Context) {
 	// 解析请求体
 	var req struct {
-		Name       string                 `json:"name" binding:"required"`
-		Definition map[string]interface{} `json:"definition" binding:"required"`
+		Name        string                 `json:"name" binding:"required"`
+		Description string                 `json:"description"`
+		Definition  map[string]interface{} `json:"definition" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	// 请求中的名称和描述优先于定义中的
+	req.Definition["name"] = req.Name
+	if req.Description != "" {
+		req.Definition["description"] = req.Description
+	}
+	wf, err := workflow.NewParser("").ParseDefinition(req.Definition)
+	if err != nil {
+		details := gin.H{"error": err.Error()}
+		// 依赖问题附带环的路径和无法执行的步骤，便于定位
+		var dagErr *workflow.DAGError
+		if errors.As(err, &dagErr) {
+			details["undefined"] = dagErr.Undefined
+			details["cycle"] = dagErr.Cycle
+			details["unreachable"] = dagErr.Unreachable
+		}
+		apierror.Respond(c, apierror.New(apierror.CodeValidation, "Invalid workflow definition").WithDetails(details))
 		return
 	}
+	wf.Tenant = tenant.FromContext(c.Request.Context())
 
-	// TODO: 实现工作流创建逻辑
-	c.JSON(http.StatusCreated, gin.H{
-		"workflow_id": generateWorkflowID(),
-		"name":        req.Name,
-		"status":      "created",
+	if err := h.workflowRepo.Save(c.Request.Context(), wf); err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to create workflow"))
+		return
+	}
+
+	response := workflowSummary(wf)
+	response["status"] = "created"
+	c.JSON(http.StatusCreated, response)
+}
+
+// PlanWorkflow 由规划Agent把高层目标拆解为工作流定义
+// 规划Agent按Agent注册表中的可用Agent及其能力规划步骤；save为true时保存工作流，
+// 之后通过 POST /workflows/:id/execute 执行（输入goal默认为规划时的目标）
+// 请求体示例：
+// {
+//   "goal": "调研新能源汽车市场并撰写分析报告",
+//   "save": true
+// }
+func (h *AgentHandler) PlanWorkflow(c *gin.Context) {
+	var req struct {
+		Goal string `json:"goal" binding:"required"` // 高层目标
+		Save bool   `json:"save"`                    // 是否保存生成的工作流
+	}
+
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	planner, err := h.agentFactory.CreateAgent("planner")
+	if err != nil {
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "Failed to create planner agent"))
+		return
+	}
+
+	result, err := aiagentexpert.ExecuteWithUsage(c.Request.Context(), planner, &aiagenttask.Task{
+		ID:        generateTaskID(),
+		Type:      "planner",
+		Goal:      req.Goal,
+		Priority:  aiagenttask.PriorityNormal,
+		Status:    aiagenttask.TaskStatusPending,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.New(apierror.CodeUnprocessable, "Failed to plan workflow").
+			WithDetails(gin.H{"goal": req.Goal, "error": err.Error()}))
+		return
+	}
+
+	output, _ := result.Output.(map[string]interface{})
+	wf, _ := output["workflow"].(*workflow.Workflow)
+	if wf == nil {
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "Planner produced no workflow"))
+		return
+	}
+
+	status := http.StatusOK
+	if req.Save {
+		wf.Tenant = tenant.FromContext(c.Request.Context())
+		if err := h.workflowRepo.Save(c.Request.Context(), wf); err != nil {
+			apierror.Respond(c, apierror.Annotate(err, "Failed to save workflow"))
+			return
+		}
+		status = http.StatusCreated
+	}
+
+	c.JSON(status, gin.H{
+		"workflow_id":     wf.ID,
+		"saved":           req.Save,
+		"planned_by":      output["planned_by"],
+		"fallback_reason": output["fallback_reason"],
+		"agents":          output["agents"],
+		"workflow":        wf,
 	})
 }
 
 // ListWorkflows 获取所有工作流列表
+// 查询参数：limit、offset、sort（默认-created_at），按name、status过滤
 func (h *AgentHandler) ListWorkflows(c *gin.Context) {
-	// TODO: 从状态管理器获取工作流列表
+	workflows, err := h.workflowRepo.List(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to list workflows"))
+		return
+	}
+
+	current := tenant.FromContext(c.Request.Context())
+	items := make([]map[string]interface{}, 0, len(workflows))
+	for _, wf := range workflows {
+		if wf.Tenant == current {
+			items = append(items, workflowSummary(wf))
+		}
+	}
+
+	page, err := workflowListSpec.List(c, items)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("workflows"))
+}
+
+// ListWorkflowFunctions 列出工作流模板和表达式中可用的内置函数，包括签名、说明和示例
+// 查询参数：category（json、string、date、regex、encoding）按类别过滤
+func (h *AgentHandler) ListWorkflowFunctions(c *gin.Context) {
+	category := c.Query("category")
+	functions := make([]workflow.TemplateFunction, 0)
+	for _, fn := range workflow.TemplateFunctions() {
+		if category == "" || fn.Category == category {
+			functions = append(functions, fn)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"workflows": []gin.H{},
-		"total":     0,
+		"functions": functions,
+		"count":     len(functions),
 	})
 }
 
-// GetWorkflow 获取工作流详情
+// GetWorkflow 获取工作流详情，包括完整的步骤定义
 func (h *AgentHandler) GetWorkflow(c *gin.Context) {
-	workflowID := c.Param("id")
+	wf, err := h.getWorkflow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
-	// TODO: 从状态管理器获取工作流详情
-	c.JSON(http.StatusOK, gin.H{
-		"workflow_id": workflowID,
-		"message":     "Workflow details will be implemented",
-	})
+	response := workflowSummary(wf)
+	response["definition"] = wf
+	c.JSON(http.StatusOK, response)
+}
+
+// getWorkflow 获取当前租户的工作流定义，其他租户的工作流按不存在处理
+func (h *AgentHandler) getWorkflow(ctx context.Context, workflowID string) (*workflow.Workflow, error) {
+	if workflowID == "" {
+		return nil, apierror.New(apierror.CodeValidation, "workflow_id is required")
+	}
+
+	wf, err := h.workflowRepo.Get(ctx, workflowID)
+	if err == nil && wf.Tenant != tenant.FromContext(ctx) {
+		err = workflow.ErrWorkflowNotFound
+	}
+	if err != nil {
+		if errors.Is(err, workflow.ErrWorkflowNotFound) {
+			return nil, apierror.New(apierror.CodeNotFound, "Workflow not found").WithDetails(gin.H{"workflow_id": workflowID})
+		}
+		return nil, apierror.Annotate(err, "Failed to get workflow")
+	}
+	return wf, nil
+}
+
+// workflowSummary 工作流的列表项
+func workflowSummary(wf *workflow.Workflow) map[string]interface{} {
+	return map[string]interface{}{
+		"workflow_id": wf.ID,
+		"name":        wf.Name,
+		"description": wf.Description,
+		"version":     wf.Version,
+		"steps":       len(wf.Steps),
+		"status":      "active",
+		"created_at":  wf.CreatedAt,
+	}
 }
 
 // ExecuteWorkflow 执行工作流
+// 执行在后台进行，通过 GET /workflows/:id/executions 查看执行状态
 // 请求体示例：
 // {
 //   "inputs": {
@@ -573,41 +1120,109 @@ func (h *AgentHandler) ExecuteWorkflow(c *gin.Context) {
 		Inputs map[string]interface{} `json:"inputs"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	run, err := h.StartWorkflow(c.Request.Context(), workflowID, req.Inputs)
+	if err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	// TODO: 执行工作流逻辑
 	c.JSON(http.StatusAccepted, gin.H{
-		"execution_id": generateExecutionID(),
-		"workflow_id":  workflowID,
-		"status":       "running",
+		"execution_id": run.ExecutionID,
+		"workflow_id":  run.WorkflowID,
+		"status":       run.Status,
 	})
 }
 
+// WorkflowRun 工作流执行的提交结果
+type WorkflowRun struct {
+	ExecutionID string
+	WorkflowID  string
+	Status      string
+}
+
+// StartWorkflow 提交工作流执行，REST与gRPC接口共用
+// 按变量定义补全输入后由执行器在后台执行，执行不随请求取消
+func (h *AgentHandler) StartWorkflow(ctx context.Context, workflowID string, inputs map[string]interface{}) (*WorkflowRun, error) {
+	wf, err := h.getWorkflow(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err = wf.ResolveInputs(inputs)
+	if err != nil {
+		return nil, apierror.New(apierror.CodeValidation, "Invalid workflow inputs").
+			WithDetails(gin.H{"workflow_id": workflowID, "error": err.Error()})
+	}
+
+	// 执行不随请求取消，沿用请求context中的租户、请求ID和追踪信息
+	runCtx := context.WithoutCancel(ctx)
+	executionID, err := h.workflowExecutor.Start(runCtx, wf, inputs)
+	if err != nil {
+		return nil, apierror.Annotate(err, "Failed to start workflow")
+	}
+
+	return &WorkflowRun{
+		ExecutionID: executionID,
+		WorkflowID:  wf.ID,
+		Status:      string(workflow.WorkflowStatusRunning),
+	}, nil
+}
+
 // GetWorkflowExecutions 获取工作流执行历史
+// 查询参数：limit、offset、sort（默认-started_at），按status过滤
 func (h *AgentHandler) GetWorkflowExecutions(c *gin.Context) {
-	workflowID := c.Param("id")
+	wf, err := h.getWorkflow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
-	// TODO: 获取执行历史
-	c.JSON(http.StatusOK, gin.H{
-		"workflow_id":  workflowID,
-		"executions":   []gin.H{},
-		"total":        0,
-	})
+	items := make([]map[string]interface{}, 0)
+	for _, execution := range h.stateManager.GetAllExecutions() {
+		if execution.WorkflowID != wf.ID {
+			continue
+		}
+		items = append(items, map[string]interface{}{
+			"execution_id": execution.ID,
+			"status":       execution.Status,
+			"error":        execution.Error,
+			"started_at":   execution.StartedAt,
+			"completed_at": execution.CompletedAt,
+			"duration":     execution.Duration.String(),
+		})
+	}
+
+	page, err := executionListSpec.List(c, items)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	response := page.Response("executions")
+	response["workflow_id"] = wf.ID
+	c.JSON(http.StatusOK, response)
 }
 
-// DeleteWorkflow 删除工作流
+// DeleteWorkflow 删除工作流，已开始的执行不受影响
 func (h *AgentHandler) DeleteWorkflow(c *gin.Context) {
-	workflowID := c.Param("id")
+	wf, err := h.getWorkflow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	if err := h.workflowRepo.Delete(c.Request.Context(), wf.ID); err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to delete workflow"))
+		return
+	}
 
-	// TODO: 删除工作流逻辑
 	c.JSON(http.StatusOK, gin.H{
-		"workflow_id": workflowID,
+		"workflow_id": wf.ID,
 		"status":      "deleted",
 	})
 }
@@ -624,26 +1239,21 @@ func (h *AgentHandler) PerformSearch(c *gin.Context) {
 	// 解析请求体
 	var req struct {
 		Query      string                 `json:"query" binding:"required"`      // 搜索查询
-		MaxResults int                    `json:"max_results"`                  // 最大结果数
+		MaxResults int                    `json:"max_results" binding:"gte=0,lte=100"` // 最大结果数
 		TimeRange  string                 `json:"time_range"`                   // 时间范围
 		Keywords   []string               `json:"keywords"`                     // 关键词
 		Options    map[string]interface{} `json:"options"`                      // 额外选项
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	// 创建Researcher Agent
 	researcher, err := h.agentFactory.CreateAgent("researcher")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create researcher agent",
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "Failed to create researcher agent"))
 		return
 	}
 
@@ -667,13 +1277,10 @@ func (h *AgentHandler) PerformSearch(c *gin.Context) {
 	}
 
 	// 执行搜索
-	ctx := context.Background()
-	result, err := researcher.Execute(ctx, task)
+	ctx := c.Request.Context()
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, researcher, task)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Search failed",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "Search failed"))
 		return
 	}
 
@@ -705,20 +1312,15 @@ func (h *AgentHandler) PerformAnalysis(c *gin.Context) {
 		Options      map[string]interface{} `json:"options"`                          // 分析选项
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	// 创建Analyst Agent
 	analyst, err := h.agentFactory.CreateAgent("analyst")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create analyst agent",
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "Failed to create analyst agent"))
 		return
 	}
 
@@ -751,14 +1353,11 @@ func (h *AgentHandler) PerformAnalysis(c *gin.Context) {
 		CreatedAt:    time.Now(),
 	}
 
-	// 执行分析
-	ctx := context.Background()
-	result, err := analyst.Execute(ctx, task)
+	// 执行分析（渲染的图表按请求的租户保存）
+	ctx := c.Request.Context()
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, analyst, task)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Analysis failed",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "Analysis failed"))
 		return
 	}
 
@@ -769,6 +1368,7 @@ func (h *AgentHandler) PerformAnalysis(c *gin.Context) {
 		"result":        result.Output,
 		"status":        result.Status,
 		"agent":         result.AgentUsed,
+		"artifacts":     result.Artifacts,
 	})
 }
 
@@ -787,25 +1387,20 @@ func (h *AgentHandler) PerformWriting(c *gin.Context) {
 		ContentType  string                 `json:"content_type" binding:"required"` // 内容类型
 		Topic        string                 `json:"topic" binding:"required"`         // 主题
 		Style        string                 `json:"style"`                            // 写作风格
-		Length       int                    `json:"length"`                           // 内容长度
+		Length       int                    `json:"length" binding:"gte=0"`           // 内容长度
 		Keywords     []string               `json:"keywords"`                         // 关键词
 		Options      map[string]interface{} `json:"options"`                          // 额外选项
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	// 创建Writer Agent
 	writer, err := h.agentFactory.CreateAgent("writer")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create writer agent",
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "Failed to create writer agent"))
 		return
 	}
 
@@ -841,13 +1436,10 @@ func (h *AgentHandler) PerformWriting(c *gin.Context) {
 	}
 
 	// 执行写作
-	ctx := context.Background()
-	result, err := writer.Execute(ctx, task)
+	ctx := c.Request.Context()
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, writer, task)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Writing failed",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "Writing failed"))
 		return
 	}
 
@@ -868,74 +1460,314 @@ func (h *AgentHandler) PerformWriting(c *gin.Context) {
 // 请求体示例：
 // {
 //   "topic": "AI技术发展",
-//   "sections": ["研究", "分析", "总结"]
+//   "sections": ["研究", "分析", "总结"],
+//   "callback_url": "https://example.com/hooks/report"
 // }
+// 返回job_id和report_id，通过 GET /jobs/:job_id 轮询作业状态，
+// 或通过 GET /analysis/report/:report_id 查看各步骤进度和最终的报告正文
 func (h *AgentHandler) GenerateReport(c *gin.Context) {
 	// 解析请求体
 	var req struct {
-		Topic    string                 `json:"topic" binding:"required"`    // 报告主题
-		Sections []string               `json:"sections"`                    // 报告章节
-		Options  map[string]interface{} `json:"options"`                     // 报告选项
+		Topic       string                 `json:"topic" binding:"required"`    // 报告主题
+		Sections    []string               `json:"sections"`                    // 报告章节
+		Options     map[string]interface{} `json:"options"`                     // 报告选项
+		CallbackURL string                 `json:"callback_url"`                // 报告生成结束后接收webhook
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	// 生成报告ID
-	reportID := generateReportID()
+	// 创建报告记录
+	rpt := report.New(generateReportID(), req.Topic, req.Sections)
+	rpt.Owner = JobOwner(c)
+	rpt.Tenant = tenant.FromContext(c.Request.Context())
+	rpt.WorkflowID = workflow.ReportWorkflowID
+	if err := h.reportStore.Save(c.Request.Context(), rpt); err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to create report"))
+		return
+	}
 
 	// 在后台生成报告（耗时操作）
-	go func() {
-		// TODO: 实现多Agent协作生成报告
-		// 1. 使用Researcher收集信息
-		// 2. 使用Analyst分析数据
-		// 3. 使用Writer生成最终报告
-	}()
-
-	// 返回报告生成任务
-	c.JSON(http.StatusAccepted, gin.H{
-		"report_id": reportID,
-		"topic":     req.Topic,
-		"status":    "generating",
-		"message":   "Report is being generated in background",
+	job, err := h.jobManager.SubmitContext(c.Request.Context(), jobs.Request{
+		Kind:        "report",
+		CallbackURL: req.CallbackURL,
+		Owner:       rpt.Owner,
+		Tenant:      rpt.Tenant,
+		Metadata:    map[string]interface{}{"report_id": rpt.ID, "topic": req.Topic},
+	}, func(ctx context.Context) (interface{}, error) {
+		return h.generateReport(ctx, rpt, req.Options)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+	rpt.JobID = job.ID
+	_ = h.reportStore.Save(c.Request.Context(), rpt)
+
+	// 返回报告生成作业
+	JobAccepted(c, job, gin.H{
+		"report_id":  rpt.ID,
+		"topic":      req.Topic,
+		"report_url": reportURL(rpt.ID),
+		"message":    "Report is being generated in background",
 	})
 }
 
-// 辅助函数：生成唯一ID
+// generateReport 多Agent协作生成报告
+// 以内置报告工作流（researcher → analyst → writer）执行，每一步结束后更新报告记录，
+// 结束时报告正文（Markdown）随报告一起保存，可通过 GET /analysis/report/:id 获取
+func (h *AgentHandler) generateReport(ctx context.Context, rpt *report.Report, options map[string]interface{}) (interface{}, error) {
+	rpt.Start()
+	_ = h.reportStore.Save(ctx, rpt)
 
-// generateTaskID 生成唯一的任务ID
-// 格式：task-时间戳-随机数
-func generateTaskID() string {
-	return fmt.Sprintf("task-%d-%d", time.Now().Unix(), time.Now().Nanosecond()%1000)
+	inputs := map[string]interface{}{}
+	for k, v := range options {
+		inputs[k] = v
+	}
+	inputs["topic"] = rpt.Topic
+	inputs["sections"] = rpt.Sections
+
+	def := workflow.ReportWorkflow()
+	execution, err := h.workflowExecutor.Execute(ctx, def, inputs)
+	if execution != nil {
+		rpt.ExecutionID = execution.ID
+		rpt.Steps = reportSteps(def, execution)
+		if state := execution.GetStepState("research"); state != nil {
+			rpt.Research = state.Output
+		}
+		if state := execution.GetStepState("analyze"); state != nil {
+			rpt.Analysis = state.Output
+		}
+	}
+	if err == nil {
+		if state := execution.GetStepState("write"); state != nil {
+			rpt.Complete(embedFigures(reportDocument(state.Output), rpt.Analysis))
+		} else {
+			err = errors.New("writer step produced no output")
+		}
+	}
+	if err != nil {
+		// 失败原因取自失败的步骤
+		for _, step := range rpt.Steps {
+			if step.Error != "" {
+				err = fmt.Errorf("%s step failed: %s", step.Agent, step.Error)
+				break
+			}
+		}
+		rpt.Fail(err)
+	}
+	if saveErr := h.reportStore.Save(ctx, rpt); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save report: %w", saveErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"report_id":  rpt.ID,
+		"topic":      rpt.Topic,
+		"sections":   rpt.Sections,
+		"research":   rpt.Research,
+		"analysis":   rpt.Analysis,
+		"report":     rpt.Document,
+		"report_url": reportURL(rpt.ID),
+	}, nil
 }
 
-// generateBatchID 生成唯一的批次ID
-// 格式：batch-时间戳-随机数
-func generateBatchID() string {
-	return fmt.Sprintf("batch-%d-%d", time.Now().Unix(), time.Now().Nanosecond()%1000)
+// runAgentStep 工作流task步骤的执行函数：按步骤的Agent类型创建Agent并执行
+// 步骤Config中的goal作为任务目标，其中的 {{变量名}} 替换为同名的工作流输入；
+// 步骤输入（工作流输入与依赖步骤的输出）作为任务要求
+func (h *AgentHandler) runAgentStep(ctx context.Context, step *workflow.Step, inputs map[string]interface{}) (interface{}, error) {
+	agent, err := h.agentFactory.CreateAgent(step.Agent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s agent: %w", step.Agent, err)
+	}
+
+	goal := step.Name
+	if g, ok := step.Config["goal"].(string); ok && g != "" {
+		goal = g
+	}
+	for name, value := range inputs {
+		switch value.(type) {
+		case string, int, int64, float64, bool:
+			goal = strings.ReplaceAll(goal, "{{"+name+"}}", fmt.Sprint(value))
+		}
+	}
+
+	task := &aiagenttask.Task{
+		ID:           generateTaskID(),
+		Type:         step.Agent,
+		Goal:         goal,
+		Requirements: inputs,
+		Priority:     aiagenttask.PriorityNormal,
+		Status:       aiagenttask.TaskStatusPending,
+		CreatedAt:    time.Now(),
+	}
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, agent, task)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status == aiagenttask.TaskStatusFailed {
+		return nil, errors.New(result.Error)
+	}
+	return result.Output, nil
+}
+
+// reportSteps 按工作流定义的顺序汇总各步骤的执行结果，未执行的步骤为pending
+func reportSteps(def *workflow.Workflow, execution *workflow.WorkflowExecution) []report.Step {
+	steps := make([]report.Step, 0, len(def.Steps))
+	for _, step := range def.Steps {
+		rs := report.Step{ID: step.ID, Agent: step.Agent, Status: string(workflow.StepStatusPending)}
+		if state := execution.GetStepState(step.ID); state != nil {
+			rs.Status = string(state.Status)
+			rs.Output = state.Output
+			rs.Error = state.Error
+			rs.Duration = state.Duration.String()
+		}
+		steps = append(steps, rs)
+	}
+	return steps
+}
+
+// reportDocument writer步骤输出中的报告正文：优先取content字段，否则整体序列化
+func reportDocument(output interface{}) string {
+	switch v := output.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if content, ok := v["content"].(string); ok {
+			return content
+		}
+	}
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", output)
+	}
+	return string(data)
+}
+
+// embedFigures 把分析步骤渲染的图表以Markdown图片的形式附在报告正文末尾
+func embedFigures(document string, analysis interface{}) string {
+	output, ok := analysis.(map[string]interface{})
+	if !ok {
+		return document
+	}
+	figures, _ := output["figures"].([]aiagenttask.ArtifactRef)
+	if len(figures) == 0 {
+		return document
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(document, "\n"))
+	sb.WriteString("\n\n## 图表\n")
+	for _, figure := range figures {
+		title := figure.Title
+		if title == "" {
+			title = figure.Name
+		}
+		sb.WriteString(fmt.Sprintf("\n![%s](%s)\n", title, figure.URL))
+	}
+	return sb.String()
+}
+
+// reportURL 报告的查询地址
+func reportURL(reportID string) string {
+	return "/api/v1/analysis/report/" + reportID
+}
+
+// GetReport 获取报告的生成状态和正文
+// 查询参数 format=markdown 时直接返回Markdown正文（报告未完成返回409）
+//
+// 响应示例：
+// {
+//   "report_id": "report-01920b6e-3c4a-7d1e-9f20-5b8c2a1d4e6f",
+//   "topic": "AI技术发展",
+//   "status": "completed",
+//   "steps": [{"id": "research", "agent": "researcher", "status": "completed", "duration": "1.2s"}],
+//   "document": "# AI技术发展\n..."
+// }
+func (h *AgentHandler) GetReport(c *gin.Context) {
+	reportID := c.Param("id")
+
+	rpt, err := h.reportStore.Get(c.Request.Context(), reportID)
+	// 其他调用方（含其他租户）的报告同样按不存在处理
+	if err == nil && rpt.Owner != "" && rpt.Owner != JobOwner(c) {
+		err = report.ErrReportNotFound
+	}
+	if err != nil {
+		if errors.Is(err, report.ErrReportNotFound) {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Report not found").WithDetails(gin.H{"report_id": reportID}))
+			return
+		}
+		apierror.Respond(c, apierror.Annotate(err, "Failed to get report"))
+		return
+	}
+
+	if c.Query("format") == "markdown" {
+		if rpt.Status != report.StatusCompleted {
+			apierror.Respond(c, apierror.New(apierror.CodeConflict, "Report is not completed").
+				WithDetails(gin.H{"report_id": reportID, "status": rpt.Status}))
+			return
+		}
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(rpt.Document))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"report_id":    rpt.ID,
+		"topic":        rpt.Topic,
+		"sections":     rpt.Sections,
+		"status":       rpt.Status,
+		"job_id":       rpt.JobID,
+		"execution_id": rpt.ExecutionID,
+		"steps":        rpt.Steps,
+		"document":     rpt.Document,
+		"error":        rpt.Error,
+		"created_at":   rpt.CreatedAt,
+		"completed_at": rpt.CompletedAt,
+		"duration":     rpt.Duration().String(),
+	})
 }
 
-// generateWorkflowID 生成唯一的工作流ID
-// 格式：workflow-时间戳-随机数
-func generateWorkflowID() string {
-	return fmt.Sprintf("workflow-%d-%d", time.Now().Unix(), time.Now().Nanosecond()%1000)
+// GetArtifact 获取Agent生成的产物（如分析Agent渲染的SVG/PNG图表），直接返回文件内容
+// 产物只对生成时的租户可见，其他租户按不存在处理
+func (h *AgentHandler) GetArtifact(c *gin.Context) {
+	artifactID := c.Param("id")
+
+	item, data, err := h.artifactStore.Get(c.Request.Context(), artifactID)
+	if err == nil && item.Tenant != tenant.FromContext(c.Request.Context()) {
+		err = artifact.ErrArtifactNotFound
+	}
+	if err != nil {
+		if errors.Is(err, artifact.ErrArtifactNotFound) {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Artifact not found").WithDetails(gin.H{"artifact_id": artifactID}))
+			return
+		}
+		apierror.Respond(c, apierror.Annotate(err, "Failed to get artifact"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", item.Name))
+	c.Data(http.StatusOK, item.ContentType, data)
 }
 
-// generateExecutionID 生成唯一的执行ID
-// 格式：exec-时间戳-随机数
-func generateExecutionID() string {
-	return fmt.Sprintf("exec-%d-%d", time.Now().Unix(), time.Now().Nanosecond()%1000)
+// 辅助函数：生成唯一ID（UUIDv7，见idgen.New）
+
+// generateTaskID 生成唯一的任务ID
+func generateTaskID() string {
+	return idgen.New(idgen.PrefixTask)
+}
+
+// generateBatchID 生成唯一的批次ID
+func generateBatchID() string {
+	return idgen.New(idgen.PrefixBatch)
 }
 
 // generateReportID 生成唯一的报告ID
-// 格式：report-时间戳-随机数
 func generateReportID() string {
-	return fmt.Sprintf("report-%d-%d", time.Now().Unix(), time.Now().Nanosecond()%1000)
+	return idgen.New(idgen.PrefixReport)
 }
 
 // ============================================================
@@ -944,16 +1776,20 @@ func generateReportID() string {
 
 // ListTools 获取所有可用工具列表
 // GET /api/v1/tools
+// 查询参数：limit、offset、sort（默认name），按name、version过滤
 func (h *AgentHandler) ListTools(c *gin.Context) {
-	tools := h.toolManager.GetAvailableTools()
+	page, err := toolListSpec.List(c, h.toolManager.GetAvailableTools())
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
+	data := page.Response("tools")
+	data["count"] = len(page.Items)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "获取工具列表成功",
-		"data": gin.H{
-			"tools": tools,
-			"count": len(tools),
-		},
+		"data":    data,
 	})
 }
 
@@ -964,10 +1800,7 @@ func (h *AgentHandler) GetToolInfo(c *gin.Context) {
 
 	info, err := h.toolManager.GetToolCapabilities(toolName)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("工具不存在: %s", toolName),
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, fmt.Sprintf("工具不存在: %s", toolName)))
 		return
 	}
 
@@ -985,10 +1818,7 @@ func (h *AgentHandler) GetToolCapabilities(c *gin.Context) {
 
 	capabilities, err := h.toolManager.GetToolCapabilities(toolName)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("工具不存在: %s", toolName),
-		})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, fmt.Sprintf("工具不存在: %s", toolName)))
 		return
 	}
 
@@ -1017,25 +1847,17 @@ func (h *AgentHandler) ExecuteTool(c *gin.Context) {
 		Params     map[string]interface{} `json:"params"`                        // 操作参数
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	// 执行工具
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	result, err := h.toolManager.ExecuteTool(ctx, req.ToolName, req.Operation, req.Params)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "工具执行失败",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "工具执行失败"))
 		return
 	}
 
@@ -1059,16 +1881,12 @@ func (h *AgentHandler) ExecuteTool(c *gin.Context) {
 // }
 func (h *AgentHandler) BatchExecuteTools(c *gin.Context) {
 	var req struct {
-		Calls      []aitools.ToolCall `json:"calls" binding:"required"`     // 工具调用列表
-		Concurrency int               `json:"concurrency"`                  // 并发数（可选）
+		Calls      []aitools.ToolCall `json:"calls" binding:"required,min=1"` // 工具调用列表
+		Concurrency int               `json:"concurrency" binding:"gte=0"`    // 并发数（可选）
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -1076,15 +1894,11 @@ func (h *AgentHandler) BatchExecuteTools(c *gin.Context) {
 	toolIntegration := aitools.NewAgentToolIntegration("batch_handler", h.toolManager)
 
 	// 批量执行
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	results, err := toolIntegration.BatchCallTools(ctx, req.Calls)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "批量工具执行失败",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "批量工具执行失败"))
 		return
 	}
 
@@ -1113,25 +1927,31 @@ func (h *AgentHandler) BatchExecuteTools(c *gin.Context) {
 
 // ListToolChains 获取所有工具链
 // GET /api/v1/tools/chains
+// 查询参数：limit、offset、sort（默认name）
 func (h *AgentHandler) ListToolChains(c *gin.Context) {
 	// 创建预定义的工具链
 	chains := aitools.CreateToolChains(h.toolManager)
 
-	chainList := make([]gin.H, 0)
+	chainList := make([]map[string]interface{}, 0)
 	for name, chain := range chains {
-		chainList = append(chainList, gin.H{
+		chainList = append(chainList, map[string]interface{}{
 			"name":  name,
 			"steps": len(chain.GetSteps()),
 		})
 	}
 
+	page, err := toolChainListSpec.List(c, chainList)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	data := page.Response("chains")
+	data["count"] = len(page.Items)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "获取工具链列表成功",
-		"data": gin.H{
-			"chains": chainList,
-			"count":  len(chainList),
-		},
+		"data":    data,
 	})
 }
 
@@ -1164,15 +1984,11 @@ func (h *AgentHandler) ExecuteToolChain(c *gin.Context) {
 	}
 
 	// 执行工具链
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	result, err := executor.ExecuteChain(ctx, chainName, req.Input)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "工具链执行失败",
-			"details": err.Error(),
-		})
+		apierror.Respond(c, apierror.Annotate(err, "工具链执行失败"))
 		return
 	}
 
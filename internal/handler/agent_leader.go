@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetLeaderElector 设置编排器选举者（scheduler.election），工作流执行器只在主实例上启动执行，
+// GET /admin/leader 返回本实例的选主状态；任务调度器需单独调用 TaskScheduler.SetLeaderElector
+func (h *AgentHandler) SetLeaderElector(elector *aiagentorchestrator.LeaderElector) {
+	h.leaderElector = elector
+	h.workflowExecutor.SetLeaderElector(elector)
+}
+
+// GetLeader 查看本实例的选主状态，负载均衡器和运维脚本据此把任务和工作流请求发往主实例
+// 未启用选主时本实例总是主实例
+//
+// 响应示例：
+//
+//	{"enabled": true, "id": "orchestrator-1-4242", "leader": false, "leader_id": "orchestrator-2-4242", "ttl": "15s"}
+func (h *AgentHandler) GetLeader(c *gin.Context) {
+	if h.leaderElector == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "leader": true})
+		return
+	}
+	status := h.leaderElector.Status(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":      true,
+		"id":           status.ID,
+		"leader":       status.Leader,
+		"leader_id":    status.LeaderID,
+		"leader_since": status.LeaderSince,
+		"ttl":          status.TTL,
+	})
+}
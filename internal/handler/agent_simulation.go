@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+
+	"ai-agent-assistant/internal/apierror"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSchedulerTrace 导出调度器记录的任务到达轨迹（最近结束的 scheduler.trace_size 个任务），
+// 可保存下来在 POST /admin/scheduler/simulate 中回放，observed为轨迹中实际排队时间的统计
+//
+// 响应示例：
+//
+//	{
+//	  "trace": [{"arrived_at": "2024-01-01T00:00:00Z", "priority": 1, "tenant": "acme", "duration_ms": 5200, "wait_ms": 30}],
+//	  "count": 1,
+//	  "observed": {"tasks": 1, "avg_ms": 30, "p50_ms": 30, "p95_ms": 30, "p99_ms": 30, "max_ms": 30}
+//	}
+func (h *AgentHandler) GetSchedulerTrace(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	trace := scheduler.Trace()
+	c.JSON(http.StatusOK, gin.H{
+		"trace":    trace,
+		"count":    len(trace),
+		"observed": aiagentorchestrator.ObservedWait(trace),
+	})
+}
+
+// SimulateScheduler 容量规划：在假设的Agent数、工作协程数和队列上限下回放任务到达轨迹，
+// 预估排队时间、利用率和被拒绝的任务数；不影响实际的调度
+// 未提供trace时使用调度器记录的轨迹，出队规则与调度器当前的设置（严格优先级或 scheduler.fairness）一致
+//
+// 请求体示例：
+//
+//	{
+//	  "scenarios": [
+//	    {"name": "current", "agents": 4},
+//	    {"name": "peak", "agents": 4, "load_factor": 2},
+//	    {"name": "scaled", "agents": 8, "workers": 8, "load_factor": 2}
+//	  ]
+//	}
+//
+// 响应示例：
+//
+//	{
+//	  "trace_size": 1000,
+//	  "observed": {"tasks": 980, "avg_ms": 120, ...},
+//	  "results": [
+//	    {"scenario": {"name": "current", "agents": 4, ...}, "tasks": 1000, "completed": 1000, "rejected": 0,
+//	     "wait": {"tasks": 1000, "avg_ms": 115.2, "p50_ms": 0, "p95_ms": 800, "p99_ms": 2100, "max_ms": 4000},
+//	     "by_priority": {"normal": {...}}, "utilization": 0.62, "max_queue_length": 12, "makespan_ms": 3600000}
+//	  ]
+//	}
+func (h *AgentHandler) SimulateScheduler(c *gin.Context) {
+	scheduler, err := h.scheduler()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	var req struct {
+		Trace     []aiagentorchestrator.TraceEntry         `json:"trace"`                                     // 回放的到达轨迹，为空时使用记录的轨迹
+		Scenarios []aiagentorchestrator.SimulationScenario `json:"scenarios" binding:"required,min=1,max=20"` // 容量假设
+	}
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	trace := req.Trace
+	if len(trace) == 0 {
+		trace = scheduler.Trace()
+	}
+	results := make([]*aiagentorchestrator.SimulationResult, 0, len(req.Scenarios))
+	for i, scenario := range req.Scenarios {
+		result, err := scheduler.Simulate(trace, scenario)
+		if err != nil {
+			apierror.Respond(c, apierror.New(apierror.CodeValidation, "Invalid simulation scenario").
+				WithDetails(gin.H{"index": i, "name": scenario.Name, "error": err.Error()}))
+			return
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trace_size": len(trace),
+		"observed":   aiagentorchestrator.ObservedWait(trace),
+		"results":    results,
+	})
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"ai-agent-assistant/internal/apierror"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	aiagenttask "ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskCancels 本实例后台执行中的任务的取消函数，DELETE /tasks/:id 据此取消任务
+// 尚未开始执行的任务的取消记录在任务存储中（状态为cancelled），开始执行时检查，不在这里登记
+type taskCancels struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // task_id -> 取消函数
+}
+
+// newTaskCancels 创建任务取消登记表
+func newTaskCancels() *taskCancels {
+	return &taskCancels{running: make(map[string]context.CancelFunc)}
+}
+
+// start 登记开始执行的任务，返回可取消的ctx和执行结束时调用的done
+func (t *taskCancels) start(ctx context.Context, taskID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.mu.Lock()
+	t.running[taskID] = cancel
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.running, taskID)
+		t.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel 取消执行中的任务，返回任务是否在本实例执行中
+func (t *taskCancels) cancel(taskID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cancel, ok := t.running[taskID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// CancelTask 取消任务
+// POST /tasks 和 /tasks/batch 提交的任务：取消执行的context，Agent不再发起新的模型和工具调用，
+// 任务随后标记为cancelled（通过 GET /tasks/:id 查看）；尚未开始执行的任务不再执行
+// 不在任务记录中的ID按调度器中的任务（工作流步骤等）取消：等待中的任务移出队列，执行中的任务取消其ctx
+// 已结束的任务返回409
+//
+// 响应示例：
+//
+//	{"task_id": "task-001", "status": "running", "cancel_requested": true}
+func (h *AgentHandler) CancelTask(c *gin.Context) {
+	ctx := c.Request.Context()
+	taskID := c.Param("id")
+
+	record, err := h.GetTask(ctx, taskID)
+	if err != nil {
+		if apierror.From(err).Code == apierror.CodeNotFound && h.cancelScheduledTask(c, taskID) {
+			return
+		}
+		apierror.Respond(c, err)
+		return
+	}
+
+	if record.Finished() {
+		apierror.Respond(c, apierror.New(apierror.CodeConflict, "Task already finished").
+			WithDetails(gin.H{"task_id": taskID, "status": record.Status}))
+		return
+	}
+	if h.taskCancels.cancel(taskID) {
+		c.JSON(http.StatusAccepted, gin.H{
+			"task_id":          taskID,
+			"status":           record.Status,
+			"cancel_requested": true,
+		})
+		return
+	}
+	if record.Status != aiagenttask.TaskStatusPending {
+		apierror.Respond(c, apierror.New(apierror.CodeConflict, "Task is not running on this instance").
+			WithDetails(gin.H{"task_id": taskID, "status": record.Status}))
+		return
+	}
+
+	// 尚未开始执行的任务直接标记为cancelled，开始执行时检查到后不再执行；
+	// 保存期间恰好开始执行的任务再取消一次
+	record.Transition(aiagenttask.TaskStatusCancelled, "cancelled before start")
+	if err := h.taskStore.Save(ctx, record); err != nil {
+		apierror.Respond(c, apierror.Annotate(err, "Failed to cancel task"))
+		return
+	}
+	h.taskCancels.cancel(taskID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"task_id":          taskID,
+		"status":           record.Status,
+		"cancel_requested": true,
+	})
+}
+
+// cancelScheduledTask 取消调度器中当前租户的任务，任务不在调度器中时返回false由调用方按不存在处理
+func (h *AgentHandler) cancelScheduledTask(c *gin.Context, taskID string) bool {
+	if h.taskScheduler == nil {
+		return false
+	}
+	owner, ok := h.scheduledTaskTenant(taskID)
+	if !ok || owner != tenant.FromContext(c.Request.Context()) {
+		return false
+	}
+	if err := h.taskScheduler.Cancel(taskID); err != nil {
+		if errors.Is(err, aiagentorchestrator.ErrTaskNotFound) {
+			return false
+		}
+		apierror.Respond(c, apierror.Annotate(err, "Failed to cancel task"))
+		return true
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"task_id":          taskID,
+		"status":           aiagentorchestrator.TaskStatusCancelled,
+		"cancel_requested": true,
+	})
+	return true
+}
+
+// scheduledTaskTenant 调度器中执行中或等待中的任务所属的租户
+func (h *AgentHandler) scheduledTaskTenant(taskID string) (string, bool) {
+	if task, err := h.taskScheduler.GetTask(taskID); err == nil {
+		return task.Tenant, true
+	}
+	for _, task := range h.taskScheduler.PendingTasks() {
+		if task.ID == taskID {
+			return task.Tenant, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/agent/events"
+	aiagentexpert "ai-agent-assistant/internal/agent/expert"
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/idgen"
+	aiagenttask "ai-agent-assistant/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxAgentSessionRequests 单个连接上同时执行的请求数上限
+	maxAgentSessionRequests = 4
+
+	// agentSessionHistory message请求携带的最近对话轮数
+	agentSessionHistory = 10
+
+	// defaultSessionAgent 会话未指定Agent时使用的Agent类型
+	defaultSessionAgent = "researcher"
+)
+
+// agentWebSocketUpgrader WebSocket升级器，默认只接受同源请求
+var agentWebSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// agentSessionRequest 客户端发送的消息
+type agentSessionRequest struct {
+	Type         string                 `json:"type"`                   // task, message, workflow, cancel, ping
+	ID           string                 `json:"id"`                     // 客户端指定的请求ID，该请求的事件中原样返回；为空时由服务端生成
+	AgentType    string                 `json:"agent_type,omitempty"`   // task必填；message时切换会话的Agent
+	Goal         string                 `json:"goal,omitempty"`         // task的任务目标
+	Content      string                 `json:"content,omitempty"`      // message的消息内容
+	Requirements map[string]interface{} `json:"requirements,omitempty"` // task、message的任务要求
+	WorkflowID   string                 `json:"workflow_id,omitempty"`  // workflow要执行的工作流
+	Inputs       map[string]interface{} `json:"inputs,omitempty"`       // workflow的输入
+}
+
+// agentSession 一个WebSocket连接上的交互会话
+// 同一连接可以并发执行多个请求，各请求的事件以request_id区分
+type agentSession struct {
+	h       *AgentHandler
+	conn    *websocket.Conn
+	ctx     context.Context
+	writeMu sync.Mutex // 串行化帧写入
+
+	mu        sync.Mutex
+	running   map[string]context.CancelFunc // request_id -> 取消函数
+	agentType string                        // message使用的Agent
+	history   []gin.H                       // message的对话历史
+	wg        sync.WaitGroup
+}
+
+// RegisterWebSocketRoutes 注册交互式Agent会话的WebSocket路由（/ws/agent）
+// router需已挂载认证、多租户和限流中间件
+func (h *AgentHandler) RegisterWebSocketRoutes(router *gin.RouterGroup) {
+	router.GET("/agent", h.authenticator.RequireScope(auth.ScopeChat), h.AgentWebSocket)
+}
+
+// AgentWebSocket 交互式Agent会话
+// 连接建立后客户端发送JSON消息，服务端以 {"event": ..., "request_id": ..., "data": ...} 帧推送执行过程：
+//   - task：{"type": "task", "id": "1", "agent_type": "researcher", "goal": "搜索Go泛型"}
+//   - message：{"type": "message", "id": "2", "content": "再分析一下趋势"}，使用会话当前的Agent并携带最近的对话历史
+//   - workflow：{"type": "workflow", "id": "3", "workflow_id": "...", "inputs": {...}}
+//   - cancel：{"type": "cancel", "id": "1"}；ping：{"type": "ping"}
+//
+// 事件依次为accepted、thought、tool_call、tool_result、step_started、step_completed/step_failed，
+// 最后是answer、error或cancelled之一
+func (h *AgentHandler) AgentWebSocket(c *gin.Context) {
+	conn, err := agentWebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // Upgrade已写回错误响应
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	s := &agentSession{
+		h:         h,
+		conn:      conn,
+		ctx:       ctx,
+		running:   make(map[string]context.CancelFunc),
+		agentType: defaultSessionAgent,
+	}
+	// 连接断开时取消仍在执行的请求，并等待其结束
+	defer s.wg.Wait()
+	defer cancel()
+
+	for {
+		var req agentSessionRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+				_ = s.send("error", "", apierror.Body(ctx, apierror.Validation(err)))
+			}
+			return
+		}
+		s.dispatch(req)
+	}
+}
+
+// send 写入一帧，写入失败时结束会话
+func (s *agentSession) send(event, requestID string, data interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	frame := gin.H{"event": event, "data": data}
+	if requestID != "" {
+		frame["request_id"] = requestID
+	}
+	return s.conn.WriteJSON(frame)
+}
+
+// fail 返回请求的错误
+func (s *agentSession) fail(requestID string, err error) {
+	_ = s.send("error", requestID, apierror.Body(s.ctx, err))
+}
+
+// dispatch 处理一条客户端消息
+func (s *agentSession) dispatch(req agentSessionRequest) {
+	switch req.Type {
+	case "ping":
+		_ = s.send("pong", req.ID, gin.H{"time": time.Now()})
+		return
+	case "cancel":
+		s.mu.Lock()
+		cancel, ok := s.running[req.ID]
+		s.mu.Unlock()
+		if !ok {
+			s.fail(req.ID, apierror.New(apierror.CodeNotFound, "No running request with this id"))
+			return
+		}
+		cancel()
+		return
+	case "task":
+		if req.AgentType == "" || req.Goal == "" {
+			s.fail(req.ID, apierror.New(apierror.CodeValidation, "agent_type and goal are required"))
+			return
+		}
+	case "message":
+		if req.Content == "" {
+			s.fail(req.ID, apierror.New(apierror.CodeValidation, "content is required"))
+			return
+		}
+	case "workflow":
+		if req.WorkflowID == "" {
+			s.fail(req.ID, apierror.New(apierror.CodeValidation, "workflow_id is required"))
+			return
+		}
+	default:
+		s.fail(req.ID, apierror.New(apierror.CodeValidation, "unknown message type").WithDetails(gin.H{"type": req.Type}))
+		return
+	}
+
+	if req.ID == "" {
+		req.ID = idgen.New("req")
+	}
+
+	s.mu.Lock()
+	if _, exists := s.running[req.ID]; exists {
+		s.mu.Unlock()
+		s.fail(req.ID, apierror.New(apierror.CodeConflict, "A request with this id is already running"))
+		return
+	}
+	if len(s.running) >= maxAgentSessionRequests {
+		s.mu.Unlock()
+		s.fail(req.ID, apierror.New(apierror.CodeRateLimited, "Too many running requests on this connection").
+			WithDetails(gin.H{"limit": maxAgentSessionRequests}))
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.running[req.ID] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, req.ID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.run(ctx, req)
+	}()
+}
+
+// run 执行请求并推送事件
+func (s *agentSession) run(ctx context.Context, req agentSessionRequest) {
+	_ = s.send("accepted", req.ID, gin.H{"type": req.Type})
+
+	// Agent和工作流执行期间的事件直接转发给客户端
+	ctx = events.WithSink(ctx, func(e events.Event) {
+		_ = s.send(e.Type, req.ID, e)
+	})
+
+	start := time.Now()
+	var answer gin.H
+	var err error
+	switch req.Type {
+	case "workflow":
+		answer, err = s.runWorkflow(ctx, req)
+	default:
+		answer, err = s.runTask(ctx, req)
+	}
+
+	switch {
+	case ctx.Err() != nil && s.ctx.Err() == nil:
+		_ = s.send("cancelled", req.ID, gin.H{"duration": time.Since(start).String()})
+	case err != nil:
+		s.fail(req.ID, err)
+	default:
+		answer["duration"] = time.Since(start).String()
+		_ = s.send("answer", req.ID, answer)
+	}
+}
+
+// runTask 执行task或message请求
+func (s *agentSession) runTask(ctx context.Context, req agentSessionRequest) (gin.H, error) {
+	agentType, goal := req.AgentType, req.Goal
+	requirements := make(map[string]interface{}, len(req.Requirements)+1)
+	for k, v := range req.Requirements {
+		requirements[k] = v
+	}
+
+	if req.Type == "message" {
+		s.mu.Lock()
+		if agentType == "" {
+			agentType = s.agentType
+		}
+		requirements["history"] = append([]gin.H(nil), s.history...)
+		s.mu.Unlock()
+		goal = req.Content
+	}
+
+	agent, err := s.h.agentFactory.CreateAgent(agentType)
+	if err != nil {
+		return nil, apierror.New(apierror.CodeValidation, "Failed to create agent").
+			WithDetails(gin.H{"agent_type": agentType, "error": err.Error()})
+	}
+	if req.Type == "message" {
+		// message指定的Agent成为会话后续消息的默认Agent
+		s.mu.Lock()
+		s.agentType = agentType
+		s.mu.Unlock()
+	}
+
+	task := &aiagenttask.Task{
+		ID:           generateTaskID(),
+		Type:         agentType,
+		Goal:         goal,
+		Requirements: requirements,
+		Priority:     aiagenttask.PriorityNormal,
+		Status:       aiagenttask.TaskStatusPending,
+		CreatedAt:    time.Now(),
+	}
+	result, err := aiagentexpert.ExecuteWithUsage(ctx, agent, task)
+	if err == nil && result.Status == aiagenttask.TaskStatusFailed {
+		err = errors.New(result.Error)
+	}
+	if err != nil {
+		return nil, apierror.Annotate(err, "Task execution failed")
+	}
+
+	if req.Type == "message" {
+		s.mu.Lock()
+		s.history = append(s.history,
+			gin.H{"role": "user", "content": req.Content},
+			gin.H{"role": "agent", "agent": agentType, "content": result.Output})
+		if len(s.history) > agentSessionHistory*2 {
+			s.history = s.history[len(s.history)-agentSessionHistory*2:]
+		}
+		s.mu.Unlock()
+	}
+
+	return gin.H{
+		"task_id":    task.ID,
+		"agent_type": agentType,
+		"output":     result.Output,
+		"usage":      result.Metadata["usage"],
+	}, nil
+}
+
+// runWorkflow 执行workflow请求，各步骤的事件由执行器推送
+func (s *agentSession) runWorkflow(ctx context.Context, req agentSessionRequest) (gin.H, error) {
+	wf, err := s.h.getWorkflow(ctx, req.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := wf.ResolveInputs(req.Inputs)
+	if err != nil {
+		return nil, apierror.New(apierror.CodeValidation, "Invalid workflow inputs").
+			WithDetails(gin.H{"workflow_id": wf.ID, "error": err.Error()})
+	}
+
+	execution, err := s.h.workflowExecutor.Execute(ctx, wf, inputs)
+	if err != nil {
+		return nil, apierror.Annotate(err, "Workflow execution failed")
+	}
+
+	outputs := make(gin.H, len(wf.Steps))
+	for _, step := range wf.Steps {
+		if state := execution.GetStepState(step.ID); state != nil {
+			outputs[step.ID] = state.Output
+		}
+	}
+	return gin.H{
+		"workflow_id":  wf.ID,
+		"execution_id": execution.ID,
+		"status":       execution.Status,
+		"outputs":      outputs,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/auth"
+	aiagenteval "ai-agent-assistant/internal/eval"
+	"ai-agent-assistant/internal/jobs"
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/internal/memory"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/rag"
+	"ai-agent-assistant/internal/report"
+	aiagenttask "ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/internal/workflow"
+)
+
+// 各模块的内部错误到统一错误码的映射，apierror.Respond据此选择HTTP状态码
+func init() {
+	apierror.Register(auth.ErrUnauthenticated, apierror.CodeUnauthenticated)
+	apierror.Register(auth.ErrInvalidCredential, apierror.CodeUnauthenticated)
+	apierror.Register(auth.ErrNoRole, apierror.CodeForbidden)
+
+	apierror.Register(aiagenttask.ErrTaskNotFound, apierror.CodeNotFound)
+	apierror.Register(jobs.ErrJobNotFound, apierror.CodeNotFound)
+	apierror.Register(report.ErrReportNotFound, apierror.CodeNotFound)
+	apierror.Register(artifact.ErrArtifactNotFound, apierror.CodeNotFound)
+	apierror.Register(workflow.ErrWorkflowNotFound, apierror.CodeNotFound)
+	apierror.Register(workflow.ErrInvalidSnapshotName, apierror.CodeValidation)
+	apierror.Register(workflow.ErrInvalidBundle, apierror.CodeValidation)
+	apierror.Register(workflow.ErrBundleConflict, apierror.CodeConflict)
+	apierror.Register(aiagentorchestrator.ErrAgentNotFound, apierror.CodeNotFound)
+	apierror.Register(aiagentorchestrator.ErrAgentExists, apierror.CodeConflict)
+	apierror.Register(aiagentorchestrator.ErrDeadLetterNotFound, apierror.CodeNotFound)
+	apierror.Register(aiagentorchestrator.ErrQueueFull, apierror.CodeUnavailable)
+	apierror.Register(aiagentorchestrator.ErrNotLeader, apierror.CodeUnavailable)
+	apierror.Register(aiagentorchestrator.ErrInvalidScenario, apierror.CodeValidation)
+	apierror.Register(aiagentorchestrator.ErrTaskNotFound, apierror.CodeNotFound)
+	apierror.Register(aiagentorchestrator.ErrTaskNotCancellable, apierror.CodeConflict)
+	apierror.Register(memory.ErrSessionNotFound, apierror.CodeNotFound)
+	apierror.Register(memory.ErrMemoryNotFound, apierror.CodeNotFound)
+	apierror.Register(memory.ErrSessionExists, apierror.CodeConflict)
+	apierror.Register(memory.ErrMemoryAccessDenied, apierror.CodeForbidden)
+	apierror.Register(aiagenteval.ErrSuiteNotFound, apierror.CodeNotFound)
+	apierror.Register(aiagenteval.ErrSuiteRunning, apierror.CodeConflict)
+	apierror.Register(aiagenteval.ErrNoRuns, apierror.CodeConflict)
+
+	apierror.Register(tenant.ErrQuotaExceeded, apierror.CodeQuotaExceeded)
+
+	apierror.Register(rag.ErrFileTooLarge, apierror.CodePayloadTooLarge)
+	apierror.Register(rag.ErrUnsupportedFileType, apierror.CodeUnsupportedMediaType)
+
+	// 服务商限流时排队失败：队列已满或排队超时
+	apierror.Register(llm.ErrRequestQueueFull, apierror.CodeRateLimited)
+	apierror.Register(llm.ErrRequestQueueTimeout, apierror.CodeTimeout)
+
+	// 模型服务商错误：429为配额用尽，其余为服务商故障
+	apierror.RegisterClassifier(func(err error) (apierror.Code, bool) {
+		var apiErr *llm.APIError
+		if !errors.As(err, &apiErr) {
+			return "", false
+		}
+		if apiErr.QuotaExceeded() {
+			return apierror.CodeQuotaExceeded, true
+		}
+		return apierror.CodeProviderError, true
+	})
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"ai-agent-assistant/internal/apierror"
+	aiagenteval "ai-agent-assistant/internal/eval"
+	"ai-agent-assistant/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EvalScheduleHandler 定时评估套件的查询、手动触发和基线管理
+type EvalScheduleHandler struct {
+	scheduler  *aiagenteval.Scheduler
+	jobManager *jobs.Manager
+}
+
+// NewEvalScheduleHandler 创建定时评估处理器
+func NewEvalScheduleHandler(scheduler *aiagenteval.Scheduler, jobManager *jobs.Manager) *EvalScheduleHandler {
+	return &EvalScheduleHandler{scheduler: scheduler, jobManager: jobManager}
+}
+
+// RegisterRoutes 注册定时评估路由
+func (h *EvalScheduleHandler) RegisterRoutes(router gin.IRoutes) {
+	// GET /eval/suites - 各套件的调度状态和最近一次运行结果
+	router.GET("/eval/suites", h.ListSuites)
+
+	// POST /eval/suites/:name/run - 立即运行套件（异步作业）
+	router.POST("/eval/suites/:name/run", h.RunSuite)
+
+	// POST /eval/suites/:name/baseline - 把最近一次运行设为基线
+	router.POST("/eval/suites/:name/baseline", h.PromoteBaseline)
+}
+
+// ListSuites 列出评估套件
+func (h *EvalScheduleHandler) ListSuites(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"suites": h.scheduler.Status()})
+}
+
+// RunSuite 提交一次套件运行，返回202和job_id；结果包含与基线的比较和回归项
+// 请求体（可选）：{"callback_url": "..."}
+func (h *EvalScheduleHandler) RunSuite(c *gin.Context) {
+	var req struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.InvalidBody(err))
+			return
+		}
+	}
+
+	name := c.Param("name")
+	if !h.scheduler.Has(name) {
+		apierror.Respond(c, aiagenteval.ErrSuiteNotFound)
+		return
+	}
+	job, err := h.jobManager.SubmitContext(c.Request.Context(), jobs.Request{
+		Kind:        "eval_suite",
+		CallbackURL: req.CallbackURL,
+		Owner:       JobOwner(c),
+		Metadata:    map[string]interface{}{"suite": name},
+	}, func(ctx context.Context) (interface{}, error) {
+		return h.scheduler.Run(ctx, name)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+	JobAccepted(c, job, gin.H{"suite": name})
+}
+
+// PromoteBaseline 确认指标变化符合预期后，把最近一次运行设为新的基线
+func (h *EvalScheduleHandler) PromoteBaseline(c *gin.Context) {
+	report, err := h.scheduler.PromoteBaseline(c.Param("name"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"suite":       c.Param("name"),
+		"baseline_at": report.StartedAt,
+		"summary":     report.Summary,
+	})
+}
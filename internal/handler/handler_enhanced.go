@@ -1,15 +1,18 @@
 package handler
 
 import (
-	"context"
 	"strconv"
 
+	"ai-agent-assistant/internal/apierror"
 	aiagentconfig "ai-agent-assistant/internal/config"
 	aiagenteval "ai-agent-assistant/internal/eval"
 	aiagentllm "ai-agent-assistant/internal/llm"
 	aiagentmemory "ai-agent-assistant/internal/memory"
+	"ai-agent-assistant/internal/pagination"
 	aiagentrag "ai-agent-assistant/internal/rag"
+	"ai-agent-assistant/internal/rag/store"
 	aigentreasoning "ai-agent-assistant/internal/reasoning"
+	"ai-agent-assistant/internal/validation"
 	"ai-agent-assistant/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -44,14 +47,14 @@ func NewEnhancedHandler(
 // handleChat 处理聊天请求
 func HandleChat(c *gin.Context, cfg *aiagentconfig.Config, modelManager *aiagentllm.ModelManager, sessionManager *aiagentmemory.EnhancedSessionManager) {
 	var req struct {
-		SessionID string `json:"session_id"`
-		Message   string `json:"message"`
+		SessionID string `json:"session_id" binding:"required"`
+		Message   string `json:"message" binding:"required"`
 		Model     string `json:"model,omitempty"`
 		WithTools bool   `json:"with_tools,omitempty"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -63,7 +66,7 @@ func HandleChat(c *gin.Context, cfg *aiagentconfig.Config, modelManager *aiagent
 
 	model, err := modelManager.GetModel(modelName)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Model not available"})
+		apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "Model not available"))
 		return
 	}
 
@@ -80,11 +83,11 @@ func HandleChat(c *gin.Context, cfg *aiagentconfig.Config, modelManager *aiagent
 	history, _ := sessionManager.GetHistory(req.SessionID)
 
 	// 调用模型
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	response, err := model.Chat(ctx, history)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -104,13 +107,13 @@ func HandleChat(c *gin.Context, cfg *aiagentconfig.Config, modelManager *aiagent
 // handleChatWithRAG 处理RAG增强对话
 func HandleChatWithRAG(c *gin.Context, cfg *aiagentconfig.Config, modelManager *aiagentllm.ModelManager, ragSystem *aiagentrag.RAGEnhanced, sessionManager *aiagentmemory.EnhancedSessionManager) {
 	var req struct {
-		SessionID string `json:"session_id"`
-		Message   string `json:"message"`
-		TopK      int    `json:"top_k,omitempty"`
+		SessionID string `json:"session_id" binding:"required"`
+		Message   string `json:"message" binding:"required"`
+		TopK      int    `json:"top_k,omitempty" binding:"gte=0,lte=20"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -120,10 +123,10 @@ func HandleChatWithRAG(c *gin.Context, cfg *aiagentconfig.Config, modelManager *
 	}
 
 	// RAG检索
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	ragContext, err := ragSystem.BuildContext(ctx, req.Message, topK)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "RAG retrieval failed"})
+		apierror.Respond(c, apierror.New(apierror.CodeInternal, "RAG retrieval failed"))
 		return
 	}
 
@@ -137,7 +140,7 @@ func HandleChatWithRAG(c *gin.Context, cfg *aiagentconfig.Config, modelManager *
 	model, _ := modelManager.GetModel(cfg.Agent.DefaultModel)
 	response, err := model.Chat(ctx, messages)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -151,11 +154,11 @@ func HandleChatWithRAG(c *gin.Context, cfg *aiagentconfig.Config, modelManager *
 // handleChainOfThought 处理思维链推理
 func HandleChainOfThought(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 	var req struct {
-		Task string `json:"task"`
+		Task string `json:"task" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -167,7 +170,7 @@ func HandleChainOfThought(c *gin.Context, modelManager *aiagentllm.ModelManager)
 	}
 
 	if model == nil {
-		c.JSON(500, gin.H{"error": "No reasoning model available"})
+		apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "No reasoning model available"))
 		return
 	}
 
@@ -175,11 +178,11 @@ func HandleChainOfThought(c *gin.Context, modelManager *aiagentllm.ModelManager)
 	cot := aigentreasoning.NewChainOfThought(model, true)
 
 	// 执行推理
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	reasoning, answer, err := cot.Reason(ctx, req.Task)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -192,19 +195,19 @@ func HandleChainOfThought(c *gin.Context, modelManager *aiagentllm.ModelManager)
 // handleReflection 处理自我反思
 func HandleReflection(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 	var req struct {
-		Task              string   `json:"task"`
+		Task              string   `json:"task" binding:"required"`
 		PreviousAttempts []string `json:"previous_attempts"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	// 获取模型
 	model, _ := modelManager.GetModel("qwen")
 	if model == nil {
-		c.JSON(500, gin.H{"error": "No model available"})
+		apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "No model available"))
 		return
 	}
 
@@ -212,11 +215,11 @@ func HandleReflection(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 	reflection := aigentreasoning.NewReflection(model, 1)
 
 	// 执行反思
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	reflectionText, improvedAnswer, err := reflection.Reflect(ctx, req.Task, req.PreviousAttempts)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -230,13 +233,13 @@ func HandleReflection(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 func HandleGetSession(c *gin.Context, sessionManager *aiagentmemory.EnhancedSessionManager) {
 	sessionID := c.Query("session_id")
 	if sessionID == "" {
-		c.JSON(400, gin.H{"error": "session_id is required"})
+		apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
 		return
 	}
 
 	session, err := sessionManager.GetSession(sessionID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Session not found"})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Session not found"))
 		return
 	}
 
@@ -254,12 +257,12 @@ func HandleGetSession(c *gin.Context, sessionManager *aiagentmemory.EnhancedSess
 func HandleClearSession(c *gin.Context, sessionManager *aiagentmemory.EnhancedSessionManager) {
 	sessionID := c.Query("session_id")
 	if sessionID == "" {
-		c.JSON(400, gin.H{"error": "session_id is required"})
+		apierror.Respond(c, apierror.New(apierror.CodeValidation, "session_id is required"))
 		return
 	}
 
 	if err := sessionManager.Clear(sessionID); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -269,18 +272,18 @@ func HandleClearSession(c *gin.Context, sessionManager *aiagentmemory.EnhancedSe
 // handleUpdateState 更新会话状态
 func HandleUpdateState(c *gin.Context, sessionManager *aiagentmemory.EnhancedSessionManager) {
 	var req struct {
-		SessionID string                 `json:"session_id"`
-		Updates   map[string]interface{} `json:"updates"`
+		SessionID string                 `json:"session_id" binding:"required"`
+		Updates   map[string]interface{} `json:"updates" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	version, err := sessionManager.UpdateState(req.SessionID, req.Updates)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -293,20 +296,20 @@ func HandleUpdateState(c *gin.Context, sessionManager *aiagentmemory.EnhancedSes
 // handleExtractMemory 提取记忆
 func HandleExtractMemory(c *gin.Context, memoryManager *aiagentmemory.EnhancedMemoryManager) {
 	var req struct {
-		UserID      string `json:"user_id"`
-		Conversation string `json:"conversation"`
+		UserID      string `json:"user_id" binding:"required"`
+		Conversation string `json:"conversation" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	memories, err := memoryManager.ExtractMemories(ctx, req.UserID, req.Conversation)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -331,15 +334,15 @@ func HandleSearchMemory(c *gin.Context, memoryManager *aiagentmemory.EnhancedMem
 	limitInt, _ := strconv.Atoi(limit)
 
 	if userID == "" || query == "" {
-		c.JSON(400, gin.H{"error": "user_id and query are required"})
+		apierror.Respond(c, apierror.New(apierror.CodeValidation, "user_id and query are required"))
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	memories, err := memoryManager.SemanticSearch(ctx, userID, query, limitInt)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -353,18 +356,18 @@ func HandleSearchMemory(c *gin.Context, memoryManager *aiagentmemory.EnhancedMem
 // handleAddKnowledge 添加知识
 func HandleAddKnowledge(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 	var req struct {
-		Text   string `json:"text"`
+		Text   string `json:"text" binding:"required"`
 		Source string `json:"source"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	if err := ragSystem.AddText(ctx, req.Text, req.Source); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -374,17 +377,17 @@ func HandleAddKnowledge(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 // handleAddKnowledgeFromDoc 从文档添加知识
 func HandleAddKnowledgeFromDoc(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 	var req struct {
-		DocPath string `json:"doc_path"`
+		DocPath string `json:"doc_path" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	if err := ragSystem.AddDocument(ctx, req.DocPath); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -392,23 +395,61 @@ func HandleAddKnowledgeFromDoc(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced
 }
 
 // handleGetKnowledgeStats 获取知识库统计
+// stats为向量存储的原始统计，index为向量化模型、分块类型分布、来源数、最后更新时间和存储大小
 func HandleGetKnowledgeStats(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 	stats := ragSystem.GetStats()
+	index, err := ragSystem.IndexStats(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"stats": stats,
+		"index": index,
 	})
 }
 
+// KnowledgeSourceListSpec 知识库来源列表支持的排序和过滤字段，默认最近更新的来源在前
+var KnowledgeSourceListSpec = pagination.Spec[store.SourceStats]{
+	Fields: map[string]pagination.Field[store.SourceStats]{
+		"source":       func(s store.SourceStats) interface{} { return s.Source },
+		"chunks":       func(s store.SourceStats) interface{} { return s.Chunks },
+		"characters":   func(s store.SourceStats) interface{} { return s.Characters },
+		"bytes":        func(s store.SourceStats) interface{} { return s.Bytes },
+		"last_updated": func(s store.SourceStats) interface{} { return s.LastUpdated },
+	},
+	DefaultSort: "-last_updated",
+}
+
+// HandleListKnowledgeSources 按来源列出知识库中的分块数、字符数、分块类型和最后更新时间
+// 查询参数：limit、offset、sort（默认-last_updated），按source过滤
+func HandleListKnowledgeSources(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
+	index, err := ragSystem.IndexStats(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	page, err := KnowledgeSourceListSpec.List(c, index.Sources)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	resp := page.Response("sources")
+	resp["tracked_since"] = index.TrackedSince
+	c.JSON(200, resp)
+}
+
 // handleSearchKnowledge 搜索知识库
 func HandleSearchKnowledge(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 	var req struct {
-		Query string `json:"query"`
-		TopK  int    `json:"top_k,omitempty"`
+		Query string `json:"query" binding:"required"`
+		TopK  int    `json:"top_k,omitempty" binding:"gte=0,lte=20"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -417,11 +458,11 @@ func HandleSearchKnowledge(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 		topK = 3
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	results, err := ragSystem.RetrieveEnhanced(ctx, req.Query, topK)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -435,19 +476,19 @@ func HandleSearchKnowledge(c *gin.Context, ragSystem *aiagentrag.RAGEnhanced) {
 // handleEvaluation 执行评估
 func HandleEvaluation(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 	var req struct {
-		TestCases []aiagenteval.TestCase `json:"test_cases"`
+		TestCases []aiagenteval.TestCase `json:"test_cases" binding:"required,min=1"`
 		Accuracy bool                       `json:"accuracy,omitempty"`
 		Performance bool                   `json:"performance,omitempty"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if err := validation.Bind(c, &req); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
 	model, _ := modelManager.GetModel("qwen")
 	if model == nil {
-		c.JSON(500, gin.H{"error": "No model available"})
+		apierror.Respond(c, apierror.New(apierror.CodeUnavailable, "No model available"))
 		return
 	}
 
@@ -463,11 +504,11 @@ func HandleEvaluation(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 
 	manager := builder.Build()
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 	results, err := manager.RunEvaluations(ctx, model, req.TestCases)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -500,7 +541,7 @@ func HandleGetModelInfo(c *gin.Context, modelManager *aiagentllm.ModelManager) {
 	info := modelManager.GetModelInfo(modelName)
 
 	if info == nil {
-		c.JSON(404, gin.H{"error": "Model not found"})
+		apierror.Respond(c, apierror.New(apierror.CodeNotFound, "Model not found"))
 		return
 	}
 
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/jobs"
+	"ai-agent-assistant/internal/pagination"
+	"ai-agent-assistant/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler 异步作业查询
+// 报告生成、批量任务、知识库导入等长耗时操作提交后返回job_id，通过这里轮询状态
+type JobHandler struct {
+	manager *jobs.Manager
+}
+
+// NewJobHandler 创建作业处理器
+func NewJobHandler(manager *jobs.Manager) *JobHandler {
+	return &JobHandler{manager: manager}
+}
+
+// RegisterRoutes 注册作业路由
+func (h *JobHandler) RegisterRoutes(router gin.IRoutes) {
+	// GET /jobs - 列出当前调用方的作业
+	router.GET("/jobs", h.ListJobs)
+
+	// GET /jobs/:id - 获取作业状态和结果
+	router.GET("/jobs/:id", h.GetJob)
+}
+
+// GetJob 获取作业状态
+// 响应示例：
+//
+//	{
+//	  "job_id": "job-1700000000000000000-1",
+//	  "kind": "report",
+//	  "status": "completed",
+//	  "result": {...},
+//	  "duration": "12.3s"
+//	}
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, err := h.manager.Get(c.Param("id"))
+	// 其他调用方的作业同样按不存在处理
+	if err == nil && job.Owner != "" && job.Owner != JobOwner(c) {
+		err = jobs.ErrJobNotFound
+	}
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobResponse(job))
+}
+
+// jobListSpec 作业列表支持的排序和过滤字段
+var jobListSpec = pagination.Spec[*jobs.Job]{
+	Fields: map[string]pagination.Field[*jobs.Job]{
+		"kind":         func(j *jobs.Job) interface{} { return j.Kind },
+		"status":       func(j *jobs.Job) interface{} { return j.Status },
+		"created_at":   func(j *jobs.Job) interface{} { return j.CreatedAt },
+		"completed_at": func(j *jobs.Job) interface{} { return j.CompletedAt },
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListJobs 列出作业
+// 查询参数：limit（默认50）、offset、sort（默认-created_at），按kind、status过滤
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, err := jobListSpec.List(c, h.manager.List("", JobOwner(c), 0))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Map(page, jobResponse).Response("jobs"))
+}
+
+// JobOwner 作业的所属调用方，未启用认证时为空
+// 多租户时限定在租户内，未认证的调用方只能看到本租户的作业
+func JobOwner(c *gin.Context) string {
+	owner := ""
+	if principal := auth.PrincipalFromContext(c); principal != nil {
+		owner = principal.Method + ":" + principal.ID
+	}
+	return tenant.Scope(c.Request.Context(), owner)
+}
+
+// JobAccepted 返回202和作业信息
+func JobAccepted(c *gin.Context, job *jobs.Job, extra gin.H) {
+	response := gin.H{
+		"job_id":     job.ID,
+		"kind":       job.Kind,
+		"status":     job.Status,
+		"status_url": "/api/v1/jobs/" + job.ID,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// jobResponse 作业的响应体
+func jobResponse(job *jobs.Job) gin.H {
+	response := gin.H{
+		"job_id":     job.ID,
+		"kind":       job.Kind,
+		"status":     job.Status,
+		"created_at": job.CreatedAt,
+		"duration":   job.Duration().String(),
+	}
+	if job.Result != nil {
+		response["result"] = job.Result
+	}
+	if job.Error != "" {
+		response["error"] = job.Error
+	}
+	if job.Metadata != nil {
+		response["metadata"] = job.Metadata
+	}
+	if job.StartedAt != nil {
+		response["started_at"] = job.StartedAt
+	}
+	if job.CompletedAt != nil {
+		response["completed_at"] = job.CompletedAt
+	}
+	if job.CallbackURL != "" {
+		response["callback_url"] = job.CallbackURL
+		response["webhook"] = job.Webhook
+	}
+	return response
+}
@@ -0,0 +1,175 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status 依赖状态
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// 就绪检查的整体状态
+const (
+	ReportReady       = "ready"       // 所有依赖可用
+	ReportDegraded    = "degraded"    // 非关键依赖不可用，仍可接收流量
+	ReportUnavailable = "unavailable" // 关键依赖不可用，返回503
+)
+
+// Probe 依赖探测函数，返回的details原样出现在报告中
+type Probe func(ctx context.Context) (details interface{}, err error)
+
+// check 已注册的依赖检查
+type check struct {
+	name     string
+	critical bool
+	probe    Probe
+}
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Name      string      `json:"name"`
+	Status    Status      `json:"status"`
+	Critical  bool        `json:"critical"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// Report 就绪检查报告
+type Report struct {
+	Status    string    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker 依赖检查器
+// 关键依赖（向量库、会话存储）不可用时服务未就绪；非关键依赖（模型服务商、工具）不可用时只降级，
+// 避免外部服务商故障时所有实例同时被摘除流量
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration // 单个依赖的探测超时
+}
+
+// NewChecker 创建依赖检查器
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+// NewCheckerFromConfig 根据配置创建依赖检查器
+func NewCheckerFromConfig(cfg config.HealthConfig) *Checker {
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	return NewChecker(timeout)
+}
+
+// Register 注册依赖检查，critical表示该依赖不可用时服务未就绪
+func (c *Checker) Register(name string, critical bool, probe Probe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, critical: critical, probe: probe})
+}
+
+// Run 并发探测所有依赖
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := Report{Status: ReportReady, Checks: results, CheckedAt: time.Now()}
+	for _, result := range results {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = ReportUnavailable
+			break
+		}
+		report.Status = ReportDegraded
+	}
+	return report
+}
+
+// runCheck 执行单个依赖检查，超时视为不可用
+func (c *Checker) runCheck(ctx context.Context, chk check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("probe panicked: %v", r)}
+			}
+		}()
+		details, err := chk.probe(ctx)
+		done <- outcome{details: details, err: err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := Result{
+		Name:      chk.name,
+		Status:    StatusUp,
+		Critical:  chk.critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   out.details,
+	}
+	if out.err != nil {
+		result.Status = StatusDown
+		result.Error = out.err.Error()
+	}
+	return result
+}
+
+// ReadyHandler 就绪检查接口：关键依赖不可用时返回503
+func (c *Checker) ReadyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report := c.Run(ctx.Request.Context())
+		status := http.StatusOK
+		if report.Status == ReportUnavailable {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, report)
+	}
+}
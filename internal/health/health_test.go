@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestReadiness 测试关键/非关键依赖对就绪状态的影响
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized) // 未带API Key，但服务在线
+	}))
+	defer provider.Close()
+
+	sessionErr := errors.New("connection refused")
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Register("vectordb", true, func(ctx context.Context) (interface{}, error) { return nil, nil })
+	checker.Register("llm:glm", false, HTTPProbe(nil, provider.URL))
+
+	report := checker.Run(context.Background())
+	if report.Status != ReportReady || len(report.Checks) != 2 {
+		t.Fatalf("all dependencies up should be ready: %+v", report)
+	}
+	if report.Checks[0].Name != "llm:glm" || report.Checks[0].Details.(map[string]interface{})["status_code"] != http.StatusUnauthorized {
+		t.Errorf("provider check should report status code: %+v", report.Checks[0])
+	}
+
+	// 非关键依赖超时只降级
+	checker.Register("tools", false, func(ctx context.Context) (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	if report := checker.Run(context.Background()); report.Status != ReportDegraded {
+		t.Errorf("non-critical failure should degrade, got %s", report.Status)
+	}
+
+	// 关键依赖不可用时返回503
+	checker.Register("session_store", true, func(ctx context.Context) (interface{}, error) { return nil, sessionErr })
+	router := gin.New()
+	router.GET("/health/ready", checker.ReadyHandler())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	var body Report
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusServiceUnavailable || body.Status != ReportUnavailable {
+		t.Fatalf("critical failure should be unavailable: %d %s", w.Code, w.Body)
+	}
+	for _, result := range body.Checks {
+		switch result.Name {
+		case "session_store":
+			if result.Status != StatusDown || result.Error != sessionErr.Error() {
+				t.Errorf("unexpected session_store result: %+v", result)
+			}
+		case "tools":
+			if result.Status != StatusDown || result.LatencyMS < 50 {
+				t.Errorf("timed out check should be down with latency: %+v", result)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// HTTPProbe 探测HTTP服务可达：收到任意HTTP响应即视为可用（401/404说明服务在线），网络错误视为不可用
+func HTTPProbe(client *http.Client, url string) Probe {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) (interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		details := map[string]interface{}{"status_code": resp.StatusCode}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return details, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return details, nil
+	}
+}
+
+// RegisterModelProviders 为已配置API Key的模型服务商注册可达性检查（非关键依赖）
+func RegisterModelProviders(c *Checker, models config.ModelsConfig) {
+	providers := map[string]config.ModelConfig{
+		"glm":  models.GLM,
+		"qwen": models.Qwen,
+	}
+	for name, model := range providers {
+		if model.APIKey == "" || model.BaseURL == "" {
+			continue
+		}
+		c.Register("llm:"+name, false, HTTPProbe(nil, strings.TrimRight(model.BaseURL, "/")))
+	}
+}
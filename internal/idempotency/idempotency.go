@@ -0,0 +1,239 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderKey 客户端传入的幂等键请求头
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed 重放的响应带有该响应头
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// maxKeyLength 幂等键的最大长度
+	maxKeyLength = 255
+	// maxFingerprintBody 超过该大小的请求体不参与指纹计算（如文件上传），只比较长度和类型
+	maxFingerprintBody = 1 << 20
+)
+
+// Response 首次请求的响应，重试时原样返回
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// entry 一个幂等键的状态
+type entry struct {
+	fingerprint string
+	response    *Response // nil表示首次请求仍在处理中
+	expiresAt   time.Time
+}
+
+// Cache 幂等键缓存：key -> 首次请求的响应
+// 同一调用方在TTL内用相同的Idempotency-Key重试时不再执行处理函数，直接返回首次的响应
+type Cache struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+// NewCache 创建幂等键缓存
+func NewCache(ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Cache{
+		entries:   make(map[string]*entry),
+		ttl:       ttl,
+		lastSweep: time.Now(),
+	}
+}
+
+// NewCacheFromConfig 根据配置创建幂等键缓存，未启用时返回nil
+func NewCacheFromConfig(cfg config.IdempotencyConfig) *Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+	ttl, _ := time.ParseDuration(cfg.TTL)
+	return NewCache(ttl)
+}
+
+// begin 占用幂等键，已存在时返回已有记录
+func (c *Cache) begin(key, fingerprint string, now time.Time) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweepLocked(now)
+
+	if e, ok := c.entries[key]; ok && now.Before(e.expiresAt) {
+		existing := *e
+		return &existing, false
+	}
+	c.entries[key] = &entry{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(c.ttl),
+	}
+	return nil, true
+}
+
+// complete 记录首次请求的响应
+func (c *Cache) complete(key string, response *Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.response = response
+		e.expiresAt = time.Now().Add(c.ttl)
+	}
+}
+
+// release 释放幂等键，允许客户端重试（处理失败时）
+func (c *Cache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// sweepLocked 清理过期的幂等键，调用方需持有锁
+func (c *Cache) sweepLocked(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Middleware 幂等中间件，只对携带Idempotency-Key的请求生效
+// - 首次请求：正常处理并记录响应（5xx响应不记录，允许重试）
+// - 重试：返回首次的响应，并带 Idempotent-Replayed: true
+// - 首次请求仍在处理中：409
+// - 同一个键用于不同的请求：422
+// 需挂在认证中间件之后，幂等键按调用方隔离
+func (c *Cache) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		idempotencyKey := ctx.GetHeader(HeaderKey)
+		if c == nil || idempotencyKey == "" {
+			ctx.Next()
+			return
+		}
+		if len(idempotencyKey) > maxKeyLength {
+			apierror.Abort(ctx, apierror.New(apierror.CodeValidation,
+				"Idempotency-Key must be at most "+strconv.Itoa(maxKeyLength)+" characters"))
+			return
+		}
+
+		fingerprint, err := requestFingerprint(ctx.Request)
+		if err != nil {
+			apierror.Abort(ctx, apierror.New(apierror.CodeValidation, "failed to read request body: "+err.Error()))
+			return
+		}
+
+		key := scopeKey(ctx, idempotencyKey)
+		existing, started := c.begin(key, fingerprint, time.Now())
+		if !started {
+			switch {
+			case existing.fingerprint != fingerprint:
+				apierror.Abort(ctx, apierror.New(apierror.CodeUnprocessable,
+					"Idempotency-Key was already used with a different request"))
+			case existing.response == nil:
+				apierror.Abort(ctx, apierror.New(apierror.CodeConflict,
+					"a request with this Idempotency-Key is still being processed"))
+			default:
+				ctx.Header(HeaderReplayed, "true")
+				ctx.Data(existing.response.Status, existing.response.ContentType, existing.response.Body)
+				ctx.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		defer func() {
+			// 处理函数panic时释放幂等键
+			if r := recover(); r != nil {
+				c.release(key)
+				panic(r)
+			}
+		}()
+
+		ctx.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			c.release(key)
+			return
+		}
+		c.complete(key, &Response{
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+	}
+}
+
+// scopeKey 幂等键按调用方和接口隔离
+func scopeKey(c *gin.Context, idempotencyKey string) string {
+	owner := "ip:" + c.ClientIP()
+	if principal := auth.PrincipalFromContext(c); principal != nil && principal.ID != "" {
+		owner = principal.Method + ":" + principal.ID
+	}
+	return owner + " " + c.Request.Method + " " + c.Request.URL.Path + " " + idempotencyKey
+}
+
+// requestFingerprint 请求体指纹，用于识别同一个键被用于不同的请求
+func requestFingerprint(r *http.Request) (string, error) {
+	if r.Body == nil || r.ContentLength > maxFingerprintBody {
+		return r.Header.Get("Content-Type") + ":" + strconv.FormatInt(r.ContentLength, 10), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFingerprintBody+1))
+	if err != nil {
+		return "", err
+	}
+	// 读取的部分放回请求体，供处理函数使用
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if len(body) > maxFingerprintBody {
+		return r.Header.Get("Content-Type") + ":chunked", nil
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// responseRecorder 记录写出的响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestIdempotencyMiddleware 测试重试返回首次响应、键冲突与失败后重试
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls int32
+	release := make(chan struct{})
+	router := gin.New()
+	cache := NewCache(time.Hour)
+	router.POST("/tasks", cache.Middleware(), func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusAccepted, gin.H{"task_id": n})
+	})
+	router.POST("/slow", cache.Middleware(), func(c *gin.Context) {
+		<-release
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	router.POST("/fail", cache.Middleware(), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "provider unavailable"})
+	})
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderKey, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("/tasks", "key-1", `{"task":"a"}`)
+	retry := do("/tasks", "key-1", `{"task":"a"}`)
+	if first.Code != http.StatusAccepted || retry.Code != http.StatusAccepted || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry should replay first response: %d %s / %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get(HeaderReplayed) != "true" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("retry should not run handler again, calls = %d", calls)
+	}
+
+	if w := do("/tasks", "key-1", `{"task":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key with different body: code = %d, want 422", w.Code)
+	}
+	if w := do("/tasks", "", `{"task":"a"}`); w.Code != http.StatusAccepted || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("requests without key should always run, calls = %d", calls)
+	}
+
+	// 首次请求处理中时重试返回409
+	done := make(chan struct{})
+	go func() {
+		do("/slow", "key-2", "")
+		close(done)
+	}()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		cache.mu.Lock()
+		started := len(cache.entries) == 2
+		cache.mu.Unlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slow request did not start")
+		}
+	}
+	if w := do("/slow", "key-2", ""); w.Code != http.StatusConflict {
+		t.Errorf("in-flight retry: code = %d, want 409", w.Code)
+	}
+	close(release)
+	<-done
+
+	// 5xx响应不记录，允许重试
+	do("/fail", "key-3", "")
+	do("/fail", "key-3", "")
+	if atomic.LoadInt32(&calls) != 4 {
+		t.Errorf("5xx responses should not be cached, calls = %d", calls)
+	}
+
+	// 未启用时直接放行
+	var disabled *Cache
+	router.POST("/disabled", disabled.Middleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	if w := do("/disabled", "key-4", ""); w.Code != http.StatusNoContent {
+		t.Errorf("nil cache should pass through, code = %d", w.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package idgen
+
+import (
+	"github.com/google/uuid"
+)
+
+// 各类资源ID的前缀
+const (
+	PrefixTask       = "task"
+	PrefixBatch      = "batch"
+	PrefixWorkflow   = "workflow"
+	PrefixExecution  = "exec"
+	PrefixReport     = "report"
+	PrefixArtifact   = "artifact"
+	PrefixTrajectory = "traj"
+	PrefixMessage    = "msg"
+)
+
+// New 生成带前缀的唯一ID，如 task-01920b6e-3c4a-7d1e-9f20-5b8c2a1d4e6f
+// 使用UUIDv7：前48位为毫秒时间戳，其余为随机数，并发生成不会冲突，且同一前缀的ID按生成时间有序
+func New(prefix string) string {
+	id := uuid.Must(uuid.NewV7()).String()
+	if prefix == "" {
+		return id
+	}
+	return prefix + "-" + id
+}
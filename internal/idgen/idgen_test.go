@@ -0,0 +1,47 @@
+package idgen
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestNew 测试并发生成的ID不重复，且按生成顺序有序
+func TestNew(t *testing.T) {
+	const workers, perWorker = 8, 1000
+
+	var mu sync.Mutex
+	seen := make(map[string]bool, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				id := New(PrefixTask)
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = New(PrefixReport)
+		if !strings.HasPrefix(ids[i], "report-") || len(ids[i]) != len("report-")+36 {
+			t.Fatalf("unexpected id format %q", ids[i])
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids should be ordered by generation time")
+	}
+	if id := New(""); len(id) != 36 {
+		t.Errorf("id without prefix should be a bare UUID, got %q", id)
+	}
+}
@@ -0,0 +1,316 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// Status 作业状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// ErrJobNotFound 作业不存在
+var ErrJobNotFound = errors.New("job not found")
+
+// Func 作业的执行函数，返回值作为作业结果
+type Func func(ctx context.Context) (interface{}, error)
+
+// Request 提交作业的参数
+type Request struct {
+	Kind        string                 // report, batch_tasks, knowledge_ingest 等
+	CallbackURL string                 // 完成或失败时接收签名webhook的地址，可为空
+	Owner       string                 // 提交者（认证后的调用方ID），为空表示不限制查询
+	Tenant      string                 // 提交者所属租户，作业执行时放入context
+	Metadata    map[string]interface{} // 附加信息，原样返回
+}
+
+// WebhookDelivery webhook投递情况
+type WebhookDelivery struct {
+	Attempts    int        `json:"attempts"`
+	StatusCode  int        `json:"status_code,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Job 长耗时操作的统一作业资源：提交后返回ID，通过GET轮询状态
+type Job struct {
+	ID          string                 `json:"job_id"`
+	Kind        string                 `json:"kind"`
+	Status      Status                 `json:"status"`
+	Result      interface{}            `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Owner       string                 `json:"-"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	RequestID   string                 `json:"request_id,omitempty"` // 提交作业的请求ID，作业日志和webhook沿用该ID
+	CallbackURL string                 `json:"callback_url,omitempty"`
+	Webhook     *WebhookDelivery       `json:"webhook,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// Finished 是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// Duration 执行耗时
+func (j *Job) Duration() time.Duration {
+	if j.StartedAt == nil {
+		return 0
+	}
+	if j.CompletedAt != nil {
+		return j.CompletedAt.Sub(*j.StartedAt)
+	}
+	return time.Since(*j.StartedAt)
+}
+
+// Manager 作业管理器
+type Manager struct {
+	mu           sync.RWMutex
+	jobs         map[string]*Job
+	webhook      *WebhookSender
+	timeout      time.Duration // 单个作业的最长执行时间
+	retention    time.Duration // 已结束作业的保留时间
+	allowedHosts map[string]bool
+	seq          uint64
+}
+
+// NewManager 创建作业管理器，webhook为nil时不投递回调
+func NewManager(webhook *WebhookSender) *Manager {
+	return &Manager{
+		jobs:         make(map[string]*Job),
+		webhook:      webhook,
+		timeout:      30 * time.Minute,
+		retention:    24 * time.Hour,
+		allowedHosts: make(map[string]bool),
+	}
+}
+
+// NewManagerFromConfig 根据配置创建作业管理器
+func NewManagerFromConfig(cfg config.JobsConfig) *Manager {
+	var webhook *WebhookSender
+	if cfg.Webhook.Secret != "" {
+		webhook = NewWebhookSender(cfg.Webhook.Secret, cfg.Webhook.MaxAttempts)
+		if d, err := time.ParseDuration(cfg.Webhook.Timeout); err == nil && d > 0 {
+			webhook.client.Timeout = d
+		}
+	}
+
+	m := NewManager(webhook)
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		m.timeout = d
+	}
+	if d, err := time.ParseDuration(cfg.Retention); err == nil && d > 0 {
+		m.retention = d
+	}
+	for _, host := range cfg.Webhook.AllowedHosts {
+		m.allowedHosts[strings.ToLower(host)] = true
+	}
+	return m
+}
+
+// ValidateCallbackURL 校验回调地址：需配置webhook密钥，且为http(s)地址
+func (m *Manager) ValidateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if m.webhook == nil {
+		return fmt.Errorf("callback_url is not supported: webhook secret is not configured")
+	}
+
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback_url: %s", callbackURL)
+	}
+	if len(m.allowedHosts) > 0 && !m.allowedHosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("callback_url host %s is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// Detach 派生后台执行用的context：保留ctx中的租户、请求ID和追踪span，但不随请求结束或客户端断开而取消；
+// timeout大于0时设置执行时限
+func Detach(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if timeout > 0 {
+		return context.WithTimeout(detached, timeout)
+	}
+	return context.WithCancel(detached)
+}
+
+// Submit 提交作业，在后台执行；不关联请求，需要沿用请求的租户和追踪信息时使用 SubmitContext
+func (m *Manager) Submit(req Request, fn Func) (*Job, error) {
+	return m.SubmitContext(context.Background(), req, fn)
+}
+
+// SubmitContext 提交作业，在后台执行；作业的context由ctx派生（见 Detach），请求结束后作业继续执行直到完成或超时
+// req.Tenant为空时使用ctx中的租户
+func (m *Manager) SubmitContext(ctx context.Context, req Request, fn Func) (*Job, error) {
+	if err := m.ValidateCallbackURL(req.CallbackURL); err != nil {
+		return nil, err
+	}
+	if req.Tenant == "" {
+		req.Tenant = tenant.FromContext(ctx)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	job := &Job{
+		ID:          fmt.Sprintf("job-%d-%d", time.Now().UnixNano(), m.seq),
+		Kind:        req.Kind,
+		Status:      StatusPending,
+		Owner:       req.Owner,
+		Tenant:      req.Tenant,
+		RequestID:   apierror.RequestIDFromContext(ctx),
+		CallbackURL: req.CallbackURL,
+		Metadata:    req.Metadata,
+		CreatedAt:   time.Now(),
+	}
+	m.jobs[job.ID] = job
+	snapshot := job.clone()
+	m.mu.Unlock()
+
+	go m.run(tenant.WithTenant(ctx, job.Tenant), job, fn)
+	return snapshot, nil
+}
+
+// run 执行作业并投递webhook
+func (m *Manager) run(parent context.Context, job *Job, fn Func) {
+	ctx, cancel := Detach(parent, m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	now := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &now
+	m.mu.Unlock()
+
+	result, err := runSafely(ctx, fn)
+
+	m.mu.Lock()
+	now = time.Now()
+	job.CompletedAt = &now
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = StatusCompleted
+		job.Result = result
+	}
+	snapshot := job.clone()
+	m.mu.Unlock()
+
+	if job.CallbackURL == "" || m.webhook == nil {
+		return
+	}
+	// 作业超时后仍需投递失败通知，webhook使用不带时限的context
+	delivery := m.webhook.Deliver(context.WithoutCancel(parent), job.CallbackURL, snapshot)
+
+	m.mu.Lock()
+	job.Webhook = delivery
+	m.mu.Unlock()
+}
+
+// runSafely 执行作业函数，panic转换为错误
+func runSafely(ctx context.Context, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Get 获取作业
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return job.clone(), nil
+}
+
+// List 按创建时间倒序列出作业，kind和owner为空表示不过滤
+func (m *Manager) List(kind, owner string, limit int) []*Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if (kind == "" || job.Kind == kind) && (owner == "" || job.Owner == owner) {
+			jobs = append(jobs, job.clone())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// StartCleanup 定期清理超过保留时间的已结束作业
+func (m *Manager) StartCleanup(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.cleanup(time.Now())
+			}
+		}
+	}()
+}
+
+// cleanup 清理过期作业
+func (m *Manager) cleanup(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for id, job := range m.jobs {
+		if job.Finished() && job.CompletedAt != nil && now.Sub(*job.CompletedAt) > m.retention {
+			delete(m.jobs, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// clone 复制作业，调用方需持有锁
+func (j *Job) clone() *Job {
+	c := *j
+	if j.Webhook != nil {
+		webhook := *j.Webhook
+		c.Webhook = &webhook
+	}
+	return &c
+}
@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// waitFinished 等待作业结束
+func waitFinished(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if job.Finished() && (job.CallbackURL == "" || job.Webhook != nil) {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+// TestJobWebhook 测试作业执行与签名webhook
+func TestJobWebhook(t *testing.T) {
+	secret := "test-secret"
+	received := make(chan webhookPayload, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature([]byte(secret), r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload webhookPayload
+		json.Unmarshal(body, &payload)
+		if payload.Event != r.Header.Get(EventHeader) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	m := NewManagerFromConfig(config.JobsConfig{Webhook: config.WebhookConfig{Secret: secret}})
+
+	job, err := m.Submit(Request{Kind: "report", CallbackURL: receiver.URL}, func(ctx context.Context) (interface{}, error) {
+		return "报告内容", nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job.Status != StatusPending {
+		t.Errorf("submitted job status = %s, want pending", job.Status)
+	}
+
+	done := waitFinished(t, m, job.ID)
+	if done.Status != StatusCompleted || done.Result != "报告内容" {
+		t.Errorf("unexpected job: %+v", done)
+	}
+	if done.Webhook.DeliveredAt == nil || done.Webhook.Attempts != 1 {
+		t.Errorf("webhook should be delivered on first attempt, got %+v", done.Webhook)
+	}
+	if payload := <-received; payload.Event != "job.completed" || payload.Job.ID != job.ID {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	failed, _ := m.Submit(Request{Kind: "knowledge_ingest", CallbackURL: receiver.URL}, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("embedding failed")
+	})
+	if done := waitFinished(t, m, failed.ID); done.Status != StatusFailed || done.Error != "embedding failed" {
+		t.Errorf("unexpected failed job: %+v", done)
+	}
+	if payload := <-received; payload.Event != "job.failed" {
+		t.Errorf("event = %s, want job.failed", payload.Event)
+	}
+
+	// 回调地址校验
+	if _, err := m.Submit(Request{CallbackURL: "ftp://example.com"}, nil); err == nil {
+		t.Error("non-http callback_url should be rejected")
+	}
+	if _, err := NewManager(nil).Submit(Request{CallbackURL: receiver.URL}, nil); err == nil {
+		t.Error("callback_url should be rejected without a webhook secret")
+	}
+}
+
+// TestSubmitContext 测试作业沿用请求的租户和请求ID，且不随请求取消
+func TestSubmitContext(t *testing.T) {
+	requestIDs := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs <- r.Header.Get(apierror.RequestIDHeader)
+	}))
+	defer receiver.Close()
+
+	m := NewManagerFromConfig(config.JobsConfig{Timeout: "1s", Webhook: config.WebhookConfig{Secret: "s"}})
+	reqCtx, cancel := context.WithCancel(tenant.WithTenant(context.Background(), "acme"))
+	reqCtx, _ = apierror.ContextWithRequestID(reqCtx, "req-123")
+
+	started := make(chan struct{})
+	job, err := m.SubmitContext(reqCtx, Request{Kind: "report", CallbackURL: receiver.URL}, func(ctx context.Context) (interface{}, error) {
+		<-started
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("job context has no deadline")
+		}
+		return tenant.FromContext(ctx) + "/" + apierror.RequestIDFromContext(ctx), nil
+	})
+	if err != nil {
+		t.Fatalf("SubmitContext failed: %v", err)
+	}
+	// 请求结束后作业继续执行
+	cancel()
+	close(started)
+
+	done := waitFinished(t, m, job.ID)
+	if done.Status != StatusCompleted || done.Result != "acme/req-123" {
+		t.Errorf("unexpected job: %+v", done)
+	}
+	if done.Tenant != "acme" || done.RequestID != "req-123" {
+		t.Errorf("job tenant/request_id = %q/%q", done.Tenant, done.RequestID)
+	}
+	if id := <-requestIDs; id != "req-123" {
+		t.Errorf("webhook %s = %q, want req-123", apierror.RequestIDHeader, id)
+	}
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+)
+
+// webhook请求头
+const (
+	SignatureHeader = "X-Webhook-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+	TimestampHeader = "X-Webhook-Timestamp" // Unix秒，接收方可据此拒绝重放
+	EventHeader     = "X-Webhook-Event"     // job.completed, job.failed, eval.regression
+)
+
+// webhookPayload webhook请求体
+type webhookPayload struct {
+	Event string `json:"event"`
+	Job   *Job   `json:"job"`
+}
+
+// WebhookSender 签名webhook投递，失败时按指数退避重试
+type WebhookSender struct {
+	secret      []byte
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewWebhookSender 创建webhook投递器
+func NewWebhookSender(secret string, maxAttempts int) *WebhookSender {
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	return &WebhookSender{
+		secret:      []byte(secret),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: maxAttempts,
+		backoff:     time.Second,
+	}
+}
+
+// Sign 计算签名
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 校验签名，供接收方使用
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Deliver 投递作业结束通知，2xx视为成功
+func (s *WebhookSender) Deliver(ctx context.Context, callbackURL string, job *Job) *WebhookDelivery {
+	event := "job.completed"
+	if job.Status == StatusFailed {
+		event = "job.failed"
+	}
+	return s.DeliverEvent(ctx, callbackURL, event, webhookPayload{Event: event, Job: job})
+}
+
+// DeliverEvent 投递任意事件，payload序列化为JSON请求体，签名和重试与作业通知相同
+func (s *WebhookSender) DeliverEvent(ctx context.Context, callbackURL, event string, payload interface{}) *WebhookDelivery {
+	delivery := &WebhookDelivery{}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.LastError = err.Error()
+		return delivery
+	}
+
+	backoff := s.backoff
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		delivery.Attempts = attempt
+
+		statusCode, err := s.post(ctx, callbackURL, event, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			now := time.Now()
+			delivery.DeliveredAt = &now
+			delivery.LastError = ""
+			return delivery
+		}
+		delivery.LastError = err.Error()
+
+		if attempt < s.maxAttempts {
+			select {
+			case <-ctx.Done():
+				return delivery
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+	return delivery
+}
+
+// post 发送一次webhook请求
+func (s *WebhookSender) post(ctx context.Context, callbackURL, event string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	if requestID := apierror.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(apierror.RequestIDHeader, requestID)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,343 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/pkg/models"
+)
+
+// builtinRedactionRules 内置脱敏规则
+var builtinRedactionRules = map[string]*regexp.Regexp{
+	"email":   regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	"id_card": regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`),
+	"phone":   regexp.MustCompile(`(?:\+?86[\- ]?)?\b1[3-9]\d{9}\b`),
+}
+
+// DefaultRedactionRules 默认启用的脱敏规则（身份证号先于手机号匹配）
+var DefaultRedactionRules = []string{"email", "id_card", "phone"}
+
+// redactionRule 脱敏规则
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Redactor 敏感信息脱敏器
+// 在日志持久化之前把邮箱、手机号、身份证号等替换为占位符
+type Redactor struct {
+	rules []redactionRule
+}
+
+// NewRedactor 创建脱敏器
+// rules为内置规则名称，custom为自定义规则（名称 -> 正则表达式）
+func NewRedactor(rules []string, custom map[string]string) (*Redactor, error) {
+	r := &Redactor{rules: make([]redactionRule, 0, len(rules)+len(custom))}
+
+	for _, name := range rules {
+		pattern, ok := builtinRedactionRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction rule: %s", name)
+		}
+		r.rules = append(r.rules, redactionRule{name: name, pattern: pattern})
+	}
+
+	for name, expr := range custom {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", name, err)
+		}
+		r.rules = append(r.rules, redactionRule{name: name, pattern: pattern})
+	}
+
+	return r, nil
+}
+
+// Redact 脱敏文本
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.rules {
+		text = rule.pattern.ReplaceAllString(text, "[REDACTED_"+strings.ToUpper(rule.name)+"]")
+	}
+	return text
+}
+
+// CallLog 一次LLM调用的日志
+type CallLog struct {
+	ID               string           `json:"id"`
+	Model            string           `json:"model"`
+	Provider         string           `json:"provider"`
+	Route            string           `json:"route,omitempty"`
+	Messages         []models.Message `json:"messages"`
+	Response         string           `json:"response,omitempty"`
+	Error            string           `json:"error,omitempty"`
+	PromptTokens     int64            `json:"prompt_tokens"`
+	CompletionTokens int64            `json:"completion_tokens"`
+	LatencyMs        int64            `json:"latency_ms"`
+	Timestamp        time.Time        `json:"timestamp"`
+}
+
+// CallLogStore 调用日志存储
+type CallLogStore interface {
+	// Save 保存一条日志
+	Save(ctx context.Context, log *CallLog) error
+
+	// Recent 获取最近的日志
+	Recent(ctx context.Context, limit int) ([]*CallLog, error)
+}
+
+// MemoryCallLogStore 内存调用日志存储（保留最近N条）
+type MemoryCallLogStore struct {
+	mu         sync.RWMutex
+	logs       []*CallLog
+	maxEntries int
+}
+
+// NewMemoryCallLogStore 创建内存调用日志存储
+func NewMemoryCallLogStore(maxEntries int) *MemoryCallLogStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryCallLogStore{
+		logs:       make([]*CallLog, 0),
+		maxEntries: maxEntries,
+	}
+}
+
+// Save 保存一条日志
+func (s *MemoryCallLogStore) Save(ctx context.Context, log *CallLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs = append(s.logs, log)
+	if len(s.logs) > s.maxEntries {
+		s.logs = s.logs[len(s.logs)-s.maxEntries:]
+	}
+	return nil
+}
+
+// Recent 获取最近的日志
+func (s *MemoryCallLogStore) Recent(ctx context.Context, limit int) ([]*CallLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 || limit > len(s.logs) {
+		limit = len(s.logs)
+	}
+	result := make([]*CallLog, limit)
+	copy(result, s.logs[len(s.logs)-limit:])
+	return result, nil
+}
+
+// FileCallLogStore JSONL文件调用日志存储，每行一条，便于导出为微调数据
+type FileCallLogStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileCallLogStore 创建文件调用日志存储
+func NewFileCallLogStore(path string) (*FileCallLogStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("log file path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	return &FileCallLogStore{path: path}, nil
+}
+
+// Save 追加一条日志
+func (s *FileCallLogStore) Save(ctx context.Context, log *CallLog) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to marshal call log: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write call log: %w", err)
+	}
+	return nil
+}
+
+// Recent 读取最近的日志
+func (s *FileCallLogStore) Recent(ctx context.Context, limit int) ([]*CallLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*CallLog{}, nil
+		}
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	logs := make([]*CallLog, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var log CallLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			continue
+		}
+		logs = append(logs, &log)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	if limit > 0 && limit < len(logs) {
+		logs = logs[len(logs)-limit:]
+	}
+	return logs, nil
+}
+
+// CallLogger LLM调用日志记录器
+type CallLogger struct {
+	store    CallLogStore
+	redactor *Redactor
+	routes   map[string]bool // 为空表示记录所有路由
+}
+
+// NewCallLogger 创建调用日志记录器，redactor可以为nil（不脱敏）
+func NewCallLogger(store CallLogStore, redactor *Redactor, routes []string) *CallLogger {
+	routeSet := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		routeSet[route] = true
+	}
+	return &CallLogger{
+		store:    store,
+		redactor: redactor,
+		routes:   routeSet,
+	}
+}
+
+// NewCallLoggerFromConfig 根据应用配置创建调用日志记录器
+func NewCallLoggerFromConfig(cfg config.LLMLoggingConfig) (*CallLogger, error) {
+	var store CallLogStore
+	switch cfg.Store {
+	case "file":
+		fileStore, err := NewFileCallLogStore(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	case "", "memory":
+		store = NewMemoryCallLogStore(cfg.MaxEntries)
+	default:
+		return nil, fmt.Errorf("unsupported call log store: %s", cfg.Store)
+	}
+
+	var redactor *Redactor
+	if cfg.Redaction.Enabled {
+		rules := cfg.Redaction.Rules
+		if len(rules) == 0 {
+			rules = DefaultRedactionRules
+		}
+		var err error
+		redactor, err = NewRedactor(rules, cfg.Redaction.CustomPatterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewCallLogger(store, redactor, cfg.Routes), nil
+}
+
+// Enabled 路由是否需要记录
+func (l *CallLogger) Enabled(route string) bool {
+	return len(l.routes) == 0 || l.routes[route]
+}
+
+// Log 脱敏后保存日志
+func (l *CallLogger) Log(ctx context.Context, log *CallLog) error {
+	messages := make([]models.Message, len(log.Messages))
+	for i, msg := range log.Messages {
+		msg.Content = l.redactor.Redact(msg.Content)
+		messages[i] = msg
+	}
+	log.Messages = messages
+	log.Response = l.redactor.Redact(log.Response)
+	log.Error = l.redactor.Redact(log.Error)
+
+	return l.store.Save(ctx, log)
+}
+
+// Recent 获取最近的日志
+func (l *CallLogger) Recent(ctx context.Context, limit int) ([]*CallLog, error) {
+	return l.store.Recent(ctx, limit)
+}
+
+// LoggedModel 带调用日志的模型包装
+type LoggedModel struct {
+	Model
+	logger *CallLogger
+}
+
+// NewLoggedModel 创建带调用日志的模型
+func NewLoggedModel(model Model, logger *CallLogger) *LoggedModel {
+	return &LoggedModel{
+		Model:  model,
+		logger: logger,
+	}
+}
+
+// Chat 调用底层模型并记录请求/响应
+func (m *LoggedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	route := CacheRouteFromContext(ctx)
+	if !m.logger.Enabled(route) {
+		return m.Model.Chat(ctx, messages)
+	}
+
+	collector := NewUsageCollector(nil, route)
+	start := time.Now()
+	response, err := m.Model.Chat(WithUsageCollector(ctx, collector), messages)
+
+	totals := collector.Totals()
+	log := &CallLog{
+		ID:               fmt.Sprintf("llm_%d", start.UnixNano()),
+		Model:            m.Model.GetModelName(),
+		Provider:         m.Model.GetProviderName(),
+		Route:            route,
+		Messages:         messages,
+		Response:         response,
+		PromptTokens:     totals.PromptTokens,
+		CompletionTokens: totals.CompletionTokens,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		Timestamp:        start,
+	}
+	if err != nil {
+		log.Error = err.Error()
+	}
+
+	// 日志写入失败不影响调用结果
+	_ = m.logger.Log(ctx, log)
+
+	return response, err
+}
+
+// Unwrap 获取底层模型
+func (m *LoggedModel) Unwrap() Model {
+	return m.Model
+}
@@ -47,6 +47,7 @@ func (m *ClaudeModel) Chat(ctx context.Context, messages []models.Message) (stri
 // ChatStream 实现流式Chat接口
 func (m *ClaudeModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	reqBody := m.buildChatRequest(messages, true)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -69,7 +70,7 @@ func (m *ClaudeModel) ChatStream(ctx context.Context, messages []models.Message)
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	ch := make(chan string)
@@ -110,6 +111,7 @@ func (m *ClaudeModel) ChatStream(ctx context.Context, messages []models.Message)
 // ChatWithOptions 带选项的对话
 func (m *ClaudeModel) ChatWithOptions(ctx context.Context, messages []models.Message, options map[string]interface{}) (*ChatResponse, error) {
 	reqBody := m.buildChatRequest(messages, false)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	// 应用选项
 	if options != nil {
@@ -140,7 +142,7 @@ func (m *ClaudeModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -175,14 +177,19 @@ func (m *ClaudeModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 		}
 	}
 
+	usage := &Usage{
+		PromptTokens:     claudeResp.Usage.InputTokens,
+		CompletionTokens: claudeResp.Usage.OutputTokens,
+		TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
+	}
+
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), usage)
+
 	return &ChatResponse{
 		Content: content,
 		FinishReason: claudeResp.StopReason,
-		Usage: &Usage{
-			PromptTokens:     claudeResp.Usage.InputTokens,
-			CompletionTokens: claudeResp.Usage.OutputTokens,
-			TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
-		},
+		Usage: usage,
 	}, nil
 }
 
@@ -223,11 +230,13 @@ func (m *ClaudeModel) SetMaxTokens(tokens int) {
 
 // claudeChatRequest Claude聊天请求结构
 type claudeChatRequest struct {
-	Model     string                `json:"model"`
-	MaxTokens int                   `json:"max_tokens"`
-	Messages  []claudeChatMessage   `json:"messages"`
-	System    string                `json:"system,omitempty"`
-	Stream    bool                  `json:"stream,omitempty"`
+	Model       string              `json:"model"`
+	MaxTokens   int                 `json:"max_tokens"`
+	Temperature float64             `json:"temperature,omitempty"`
+	TopP        float64             `json:"top_p,omitempty"`
+	Messages    []claudeChatMessage `json:"messages"`
+	System      string              `json:"system,omitempty"`
+	Stream      bool                `json:"stream,omitempty"`
 }
 
 type claudeChatMessage struct {
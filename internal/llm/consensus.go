@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/pkg/models"
+)
+
+// ConsensusMode 共识合并方式
+type ConsensusMode string
+
+const (
+	ConsensusModeVote  ConsensusMode = "vote"  // 精确匹配投票，适用于分类等短答案
+	ConsensusModeJudge ConsensusMode = "judge" // 由裁判模型从候选答案中选出最佳，适用于自由文本
+)
+
+// ConsensusCandidate 单个模型的候选答案
+type ConsensusCandidate struct {
+	Model     string `json:"model"`
+	Answer    string `json:"answer,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// ConsensusResult 共识生成结果
+type ConsensusResult struct {
+	Answer      string               `json:"answer"`
+	Mode        ConsensusMode        `json:"mode"`
+	Selected    int                  `json:"selected"`        // 被选中的候选下标
+	Agreement   float64              `json:"agreement"`       // 与最终答案一致的候选占比
+	Votes       map[string]int       `json:"votes,omitempty"` // 归一化答案 -> 票数
+	JudgeReason string               `json:"judge_reason,omitempty"`
+	Candidates  []ConsensusCandidate `json:"candidates"`
+}
+
+// Consensus 多模型共识生成器
+// 并行调用N个模型，再按投票或裁判模型合并答案，用于高风险的工作流步骤
+type Consensus struct {
+	models  []Model
+	mode    ConsensusMode
+	judge   Model
+	timeout time.Duration
+}
+
+// NewConsensus 创建共识生成器，judge模式下judge为空时使用第一个模型做裁判
+func NewConsensus(models []Model, mode ConsensusMode, judge Model) (*Consensus, error) {
+	if len(models) < 2 {
+		return nil, fmt.Errorf("consensus requires at least 2 models, got %d", len(models))
+	}
+	switch mode {
+	case "":
+		mode = ConsensusModeVote
+	case ConsensusModeVote, ConsensusModeJudge:
+	default:
+		return nil, fmt.Errorf("unsupported consensus mode: %s", mode)
+	}
+	if mode == ConsensusModeJudge && judge == nil {
+		judge = models[0]
+	}
+
+	return &Consensus{
+		models:  models,
+		mode:    mode,
+		judge:   judge,
+		timeout: 60 * time.Second,
+	}, nil
+}
+
+// SetTimeout 设置单个模型的调用超时
+func (c *Consensus) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// Generate 并行调用所有模型并合并答案
+func (c *Consensus) Generate(ctx context.Context, messages []models.Message) (*ConsensusResult, error) {
+	candidates := make([]ConsensusCandidate, len(c.models))
+
+	var wg sync.WaitGroup
+	for i, model := range c.models {
+		wg.Add(1)
+		go func(i int, model Model) {
+			defer wg.Done()
+
+			callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			answer, err := model.Chat(callCtx, messages)
+			candidates[i] = ConsensusCandidate{
+				Model:     model.GetModelName(),
+				Answer:    strings.TrimSpace(answer),
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				candidates[i].Error = err.Error()
+			}
+		}(i, model)
+	}
+	wg.Wait()
+
+	valid := 0
+	for _, cand := range candidates {
+		if cand.Error == "" && cand.Answer != "" {
+			valid++
+		}
+	}
+	if valid == 0 {
+		return nil, fmt.Errorf("all %d models failed to answer", len(candidates))
+	}
+
+	result := &ConsensusResult{
+		Mode:       c.mode,
+		Candidates: candidates,
+	}
+
+	votes, best := voteCandidates(candidates)
+	result.Votes = votes
+	result.Selected = best
+
+	if c.mode == ConsensusModeJudge && valid > 1 {
+		selected, reason, err := c.judgeCandidates(ctx, messages, candidates)
+		if err == nil {
+			result.Selected = selected
+			result.JudgeReason = reason
+		} else {
+			// 裁判失败时退化为投票结果
+			result.JudgeReason = "judge failed, fell back to vote: " + err.Error()
+		}
+	}
+
+	result.Answer = candidates[result.Selected].Answer
+	selectedKey := normalizeAnswer(result.Answer)
+	result.Agreement = float64(votes[selectedKey]) / float64(len(candidates))
+
+	return result, nil
+}
+
+// voteCandidates 对归一化后的答案投票，返回票数和得票最多的候选下标（平票时取靠前的模型）
+func voteCandidates(candidates []ConsensusCandidate) (map[string]int, int) {
+	votes := make(map[string]int)
+	for _, cand := range candidates {
+		if cand.Error != "" || cand.Answer == "" {
+			continue
+		}
+		votes[normalizeAnswer(cand.Answer)]++
+	}
+
+	best := -1
+	bestVotes := 0
+	for i, cand := range candidates {
+		if cand.Error != "" || cand.Answer == "" {
+			continue
+		}
+		if n := votes[normalizeAnswer(cand.Answer)]; n > bestVotes {
+			best = i
+			bestVotes = n
+		}
+	}
+	return votes, best
+}
+
+// normalizeAnswer 归一化答案用于精确匹配投票
+func normalizeAnswer(answer string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	answer = strings.TrimRight(answer, "。.!！?？ \n\t")
+	return strings.Join(strings.Fields(answer), " ")
+}
+
+// judgeSelectionPattern 裁判输出中的候选编号
+var judgeSelectionPattern = regexp.MustCompile(`\d+`)
+
+// judgeCandidates 由裁判模型选择最佳答案
+func (c *Consensus) judgeCandidates(ctx context.Context, messages []models.Message, candidates []ConsensusCandidate) (int, string, error) {
+	question := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			question = messages[i].Content
+			break
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("你是一名严格的评审。下面是同一个问题的多个候选答案，请选出最准确、最完整的一个。\n\n")
+	sb.WriteString("问题：\n")
+	sb.WriteString(question)
+	sb.WriteString("\n\n候选答案：\n")
+	indexes := make([]int, 0, len(candidates))
+	for i, cand := range candidates {
+		if cand.Error != "" || cand.Answer == "" {
+			continue
+		}
+		indexes = append(indexes, i)
+		sb.WriteString(fmt.Sprintf("\n[%d]\n%s\n", len(indexes), cand.Answer))
+	}
+	sb.WriteString("\n请在第一行只输出最佳答案的编号，第二行简要说明理由。")
+
+	output, err := c.judge.Chat(ctx, []models.Message{
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	match := judgeSelectionPattern.FindString(output)
+	if match == "" {
+		return 0, "", fmt.Errorf("judge did not return a candidate number")
+	}
+	n, _ := strconv.Atoi(match)
+	if n < 1 || n > len(indexes) {
+		return 0, "", fmt.Errorf("judge selected invalid candidate %d", n)
+	}
+
+	reason := ""
+	if lines := strings.SplitN(strings.TrimSpace(output), "\n", 2); len(lines) == 2 {
+		reason = strings.TrimSpace(lines[1])
+	}
+	return indexes[n-1], reason, nil
+}
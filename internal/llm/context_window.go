@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"sync"
+
+	"ai-agent-assistant/pkg/models"
+)
+
+// messageOverheadTokens 每条消息的角色/分隔符开销
+const messageOverheadTokens = 4
+
+// contextWindows 已知模型的上下文窗口（token）
+var contextWindows = map[string]int{
+	"glm":               128000,
+	"glm-4-flash":       128000,
+	"glm-4-plus":        128000,
+	"glm-4-alltools":    128000,
+	"qwen":              131072,
+	"qwen-turbo":        131072,
+	"qwen-plus":         131072,
+	"qwen-max":          32768,
+	"qwen-long":         1000000,
+	"qwen-vl-plus":      32768,
+	"qwen-vl-max":       32768,
+	"gpt-3.5-turbo":     16385,
+	"gpt-4":             8192,
+	"gpt-4-turbo":       128000,
+	"gpt-4o":            128000,
+	"claude-3-5-sonnet": 200000,
+	"claude-3-opus":     200000,
+	"claude-3-haiku":    200000,
+	"deepseek-chat":     64000,
+	"deepseek-coder":    64000,
+	"deepseek-r1":       64000,
+}
+
+var contextWindowsMu sync.RWMutex
+
+// ContextWindow 获取模型的上下文窗口大小，未知模型返回0
+func ContextWindow(model string) int {
+	contextWindowsMu.RLock()
+	defer contextWindowsMu.RUnlock()
+	return contextWindows[model]
+}
+
+// RegisterContextWindow 注册或覆盖模型的上下文窗口大小
+func RegisterContextWindow(model string, tokens int) {
+	contextWindowsMu.Lock()
+	defer contextWindowsMu.Unlock()
+	contextWindows[model] = tokens
+}
+
+// EstimateMessageTokens 估算单条消息占用的token数
+func EstimateMessageTokens(message models.Message) int {
+	return EstimateTokens(message.Content) + messageOverheadTokens
+}
+
+// EstimateMessagesTokens 估算消息列表占用的token数
+func EstimateMessagesTokens(messages []models.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateMessageTokens(msg)
+	}
+	return total
+}
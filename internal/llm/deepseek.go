@@ -47,6 +47,7 @@ func (m *DeepSeekModel) Chat(ctx context.Context, messages []models.Message) (st
 // ChatStream 实现流式Chat接口
 func (m *DeepSeekModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	reqBody := m.buildChatRequest(messages, true)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -68,7 +69,7 @@ func (m *DeepSeekModel) ChatStream(ctx context.Context, messages []models.Messag
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	ch := make(chan string)
@@ -117,6 +118,20 @@ func (m *DeepSeekModel) ChatStream(ctx context.Context, messages []models.Messag
 // ChatWithOptions 带选项的对话
 func (m *DeepSeekModel) ChatWithOptions(ctx context.Context, messages []models.Message, options map[string]interface{}) (*ChatResponse, error) {
 	reqBody := m.buildChatRequest(messages, false)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
+
+	// 应用选项
+	if options != nil {
+		if temp, ok := options["temperature"].(float64); ok {
+			reqBody.Temperature = temp
+		}
+		if maxTokens, ok := options["max_tokens"].(int); ok {
+			reqBody.MaxTokens = maxTokens
+		}
+		if tools, ok := options["tools"].([]Tool); ok {
+			reqBody.Tools = tools
+		}
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -139,7 +154,7 @@ func (m *DeepSeekModel) ChatWithOptions(ctx context.Context, messages []models.M
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -150,8 +165,9 @@ func (m *DeepSeekModel) ChatWithOptions(ctx context.Context, messages []models.M
 	var deepseekResp struct {
 		Choices []struct {
 			Message struct {
-				Content   string `json:"content"`
-				Reasoning string `json:"reasoning_content"` // 推理内容（R1模型）
+				Content   string     `json:"content"`
+				Reasoning string     `json:"reasoning_content"` // 推理内容（R1模型）
+				ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
@@ -174,13 +190,22 @@ func (m *DeepSeekModel) ChatWithOptions(ctx context.Context, messages []models.M
 		content = "[思考过程]\n" + choice.Message.Reasoning + "\n\n[答案]\n" + content
 	}
 
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), &deepseekResp.Usage)
+
 	return &ChatResponse{
 		Content:      content,
+		ToolCalls:    choice.Message.ToolCalls,
 		FinishReason: choice.FinishReason,
 		Usage:        &deepseekResp.Usage,
 	}, nil
 }
 
+// ChatWithTools 携带工具定义的对话
+func (m *DeepSeekModel) ChatWithTools(ctx context.Context, messages []models.Message, tools []Tool) (*ChatResponse, error) {
+	return m.ChatWithOptions(ctx, messages, map[string]interface{}{"tools": tools})
+}
+
 // SupportsToolCalling DeepSeek支持工具调用
 func (m *DeepSeekModel) SupportsToolCalling() bool {
 	return true
@@ -272,8 +297,10 @@ type deepseekChatRequest struct {
 	Model       string                 `json:"model"`
 	Messages    []deepseekChatMessage  `json:"messages"`
 	Temperature float64                `json:"temperature,omitempty"`
+	TopP        float64                `json:"top_p,omitempty"`
 	MaxTokens   int                    `json:"max_tokens,omitempty"`
 	Stream      bool                   `json:"stream,omitempty"`
+	Tools       []Tool                 `json:"tools,omitempty"`
 }
 
 type deepseekChatMessage struct {
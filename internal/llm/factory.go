@@ -1,7 +1,9 @@
 package llm
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"ai-agent-assistant/internal/config"
 )
@@ -33,6 +35,15 @@ func (f *ModelFactory) CreateModel(modelName string, cfg *config.Config) (Model,
 		}
 		return NewQwenModel(modelCfg)
 
+	case "qwen-vl-plus", "qwen-vl-max":
+		// 千问视觉模型，复用千问的API Key
+		modelCfg := ModelConfig{
+			APIKey:  cfg.Models.Qwen.APIKey,
+			BaseURL: cfg.Models.Qwen.BaseURL,
+			Model:   modelName,
+		}
+		return NewQwenModel(modelCfg)
+
 	case "openai", "gpt-4", "gpt-4-turbo", "gpt-3.5-turbo", "gpt-4o":
 		// 从环境变量或配置中获取OpenAI API Key
 		return NewOpenAIModel(ModelConfig{
@@ -86,6 +97,8 @@ func (f *ModelFactory) GetSupportedModels() []string {
 		"qwen-plus",
 		"qwen-max",
 		"qwen-long",
+		"qwen-vl-plus",
+		"qwen-vl-max",
 		// OpenAI系列
 		"gpt-3.5-turbo",
 		"gpt-4",
@@ -102,6 +115,16 @@ func (f *ModelFactory) GetSupportedModels() []string {
 	}
 }
 
+// SupportsModel 模型名称是否受支持（具体型号或提供商名称）
+func (f *ModelFactory) SupportsModel(name string) bool {
+	for _, supported := range append(f.GetSupportedModels(), f.GetSupportedProviders()...) {
+		if name == supported {
+			return true
+		}
+	}
+	return false
+}
+
 // GetSupportedProviders 获取支持的提供商列表
 func (f *ModelFactory) GetSupportedProviders() []string {
 	return []string{
@@ -115,18 +138,27 @@ func (f *ModelFactory) GetSupportedProviders() []string {
 
 // ModelManager 模型管理器（新版，使用Model接口）
 type ModelManager struct {
-	factory *ModelFactory
-	models  map[string]Model
-	config  *config.Config
+	factory    *ModelFactory
+	models     map[string]Model
+	config     *config.Config
+	registered map[string]bool // 通过RegisterModel注册的自定义模型
+	mu         sync.RWMutex    // 保护models和config，密钥轮换时替换
+	cache      *ResponseCache  // LLM响应缓存（可选）
+	usage      *UsageTracker   // 用量统计（可选）
+	router     *ModelRouter    // 模型路由策略（可选）
+	routerMu   sync.RWMutex    // 配置热加载时替换路由策略
+	logger     *CallLogger     // 调用日志（可选）
+	queue      *RequestQueue   // 限流时排队的请求队列（可选）
 }
 
 // NewModelManager 创建模型管理器
 func NewModelManager(cfg *config.Config) (*ModelManager, error) {
 	factory := NewModelFactory()
 	manager := &ModelManager{
-		factory: factory,
-		models:  make(map[string]Model),
-		config:  cfg,
+		factory:    factory,
+		models:     make(map[string]Model),
+		config:     cfg,
+		registered: make(map[string]bool),
 	}
 
 	// 初始化默认模型
@@ -162,29 +194,175 @@ func (m *ModelManager) initDefaultModels() error {
 
 // GetModel 获取模型
 func (m *ModelManager) GetModel(modelName string) (Model, error) {
+	m.mu.RLock()
+	model, ok := m.models[modelName]
+	cfg := m.config
+	m.mu.RUnlock()
+
 	// 如果已经初始化，直接返回
-	if model, ok := m.models[modelName]; ok {
-		return model, nil
+	if ok {
+		return m.wrap(model), nil
 	}
 
 	// 尝试动态创建
-	model, err := m.factory.CreateModel(modelName, m.config)
+	model, err := m.factory.CreateModel(modelName, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	// 缓存模型
-	m.models[modelName] = model
-	return model, nil
+	m.mu.Lock()
+	if existing, ok := m.models[modelName]; ok {
+		model = existing
+	} else {
+		m.models[modelName] = model
+	}
+	m.mu.Unlock()
+	return m.wrap(model), nil
+}
+
+// UpdateModelsConfig 替换模型提供方配置（API Key、地址），用于密钥轮换
+// 已创建的提供方模型被丢弃，之后通过GetModel获取的模型使用新配置；
+// 通过RegisterModel注册的自定义模型保留。已持有模型实例的组件需重新获取才会使用新密钥
+func (m *ModelManager) UpdateModelsConfig(models config.ModelsConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := *m.config
+	next.Models = models
+	m.config = &next
+	for name := range m.models {
+		if !m.registered[name] {
+			delete(m.models, name)
+		}
+	}
+	return m.initDefaultModels()
+}
+
+// EnableResponseCache 启用LLM响应缓存
+// 启用后GetModel返回的模型会按上下文中的路由查询缓存
+func (m *ModelManager) EnableResponseCache(cache *ResponseCache) {
+	m.cache = cache
+}
+
+// GetResponseCache 获取LLM响应缓存
+func (m *ModelManager) GetResponseCache() *ResponseCache {
+	return m.cache
+}
+
+// EnableUsageTracking 启用token用量与费用统计
+func (m *ModelManager) EnableUsageTracking(tracker *UsageTracker) {
+	m.usage = tracker
+}
+
+// GetUsageTracker 获取用量统计器
+func (m *ModelManager) GetUsageTracker() *UsageTracker {
+	return m.usage
+}
+
+// RouteModel 按路由策略获取模型
+func (m *ModelManager) RouteModel(ctx context.Context, req RouteRequest) (Model, RouteDecision, error) {
+	router := m.GetModelRouter()
+	if router == nil {
+		return nil, RouteDecision{}, fmt.Errorf("model routing is not enabled")
+	}
+
+	decision := router.Route(req)
+	model, err := m.GetModel(decision.Model)
+	if err != nil {
+		return nil, decision, fmt.Errorf("routed model %s unavailable: %w", decision.Model, err)
+	}
+	return model, decision, nil
+}
+
+// SetModelRouter 设置模型路由策略
+// 设置后可通过RouteModel按任务类型和复杂度选择模型，设置为nil时关闭路由
+func (m *ModelManager) SetModelRouter(router *ModelRouter) {
+	m.routerMu.Lock()
+	defer m.routerMu.Unlock()
+	m.router = router
+}
+
+// GetModelRouter 获取模型路由器
+func (m *ModelManager) GetModelRouter() *ModelRouter {
+	m.routerMu.RLock()
+	defer m.routerMu.RUnlock()
+	return m.router
+}
+
+// EnableCallLogging 启用LLM请求/响应日志
+func (m *ModelManager) EnableCallLogging(logger *CallLogger) {
+	m.logger = logger
+}
+
+// GetCallLogger 获取调用日志记录器
+func (m *ModelManager) GetCallLogger() *CallLogger {
+	return m.logger
+}
+
+// EnableRequestQueue 启用LLM请求队列
+// 启用后GetModel返回的模型按上下文中的优先级和租户排队调用服务商
+func (m *ModelManager) EnableRequestQueue(queue *RequestQueue) {
+	m.queue = queue
+}
+
+// GetRequestQueue 获取LLM请求队列
+func (m *ModelManager) GetRequestQueue() *RequestQueue {
+	return m.queue
+}
+
+// wrap 按需为模型包装请求队列、用量统计、调用日志和响应缓存
+// 队列在最内层，被限流后的重试不重复记录日志；缓存在最外层，命中缓存的调用不排队、不产生用量也不记录日志
+func (m *ModelManager) wrap(model Model) Model {
+	if m.queue != nil {
+		model = NewQueuedModel(model, m.queue)
+	}
+	// 未启用全局统计时也包装，流式调用的估算用量仍会上报到请求的收集器
+	model = NewUsageTrackedModel(model, m.usage)
+	if m.logger != nil {
+		model = NewLoggedModel(model, m.logger)
+	}
+	if m.cache != nil {
+		model = NewCachedModel(model, m.cache)
+	}
+	return model
+}
+
+// NewConsensus 根据模型名称创建共识生成器
+func (m *ModelManager) NewConsensus(modelNames []string, mode ConsensusMode, judgeName string) (*Consensus, error) {
+	candidates := make([]Model, 0, len(modelNames))
+	for _, name := range modelNames {
+		model, err := m.GetModel(name)
+		if err != nil {
+			return nil, fmt.Errorf("consensus model %s unavailable: %w", name, err)
+		}
+		candidates = append(candidates, model)
+	}
+
+	var judge Model
+	if judgeName != "" {
+		model, err := m.GetModel(judgeName)
+		if err != nil {
+			return nil, fmt.Errorf("judge model %s unavailable: %w", judgeName, err)
+		}
+		judge = model
+	}
+
+	return NewConsensus(candidates, mode, judge)
 }
 
 // RegisterModel 注册自定义模型
 func (m *ModelManager) RegisterModel(name string, model Model) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.models[name] = model
+	m.registered[name] = true
 }
 
 // ListModels 列出所有已加载的模型
 func (m *ModelManager) ListModels() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	models := make([]string, 0, len(m.models))
 	for name := range m.models {
 		models = append(models, name)
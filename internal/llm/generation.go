@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// GenerationOptions 单次调用的生成参数
+// 未设置的字段使用模型配置中的默认值
+type GenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+}
+
+// IsZero 是否没有设置任何参数
+func (o *GenerationOptions) IsZero() bool {
+	return o == nil || (o.Temperature == nil && o.TopP == nil && o.MaxTokens == 0)
+}
+
+// applyTo 把生成参数写入请求体字段，传nil表示该提供商不支持该参数
+func (o *GenerationOptions) applyTo(temperature, topP *float64, maxTokens *int) {
+	if o == nil {
+		return
+	}
+	if o.Temperature != nil && temperature != nil {
+		*temperature = *o.Temperature
+	}
+	if o.TopP != nil && topP != nil {
+		*topP = *o.TopP
+	}
+	if o.MaxTokens > 0 && maxTokens != nil {
+		*maxTokens = o.MaxTokens
+	}
+}
+
+// generationOptionsKey 上下文中生成参数的键
+type generationOptionsKey struct{}
+
+// WithGenerationOptions 在上下文中设置本次调用的生成参数
+func WithGenerationOptions(ctx context.Context, opts GenerationOptions) context.Context {
+	if opts.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, generationOptionsKey{}, &opts)
+}
+
+// GenerationOptionsFromContext 从上下文中读取生成参数，未设置时返回nil
+func GenerationOptionsFromContext(ctx context.Context) *GenerationOptions {
+	if opts, ok := ctx.Value(generationOptionsKey{}).(*GenerationOptions); ok {
+		return opts
+	}
+	return nil
+}
+
+// GenerationLimits 服务端对客户端生成参数的限制
+type GenerationLimits struct {
+	MaxTemperature float64
+	MaxTopP        float64
+	MaxTokens      int
+	AllowedModels  map[string]bool // 为空表示不限制
+}
+
+// NewGenerationLimitsFromConfig 根据应用配置创建生成参数限制
+func NewGenerationLimitsFromConfig(cfg config.GenerationConfig) *GenerationLimits {
+	limits := &GenerationLimits{
+		MaxTemperature: cfg.MaxTemperature,
+		MaxTopP:        cfg.MaxTopP,
+		MaxTokens:      cfg.MaxTokens,
+		AllowedModels:  make(map[string]bool, len(cfg.AllowedModels)),
+	}
+	if limits.MaxTemperature <= 0 {
+		limits.MaxTemperature = 2
+	}
+	if limits.MaxTopP <= 0 || limits.MaxTopP > 1 {
+		limits.MaxTopP = 1
+	}
+	for _, model := range cfg.AllowedModels {
+		limits.AllowedModels[model] = true
+	}
+	return limits
+}
+
+// CheckModel 检查客户端是否允许使用该模型
+func (l *GenerationLimits) CheckModel(model string) error {
+	if model == "" || len(l.AllowedModels) == 0 || l.AllowedModels[model] {
+		return nil
+	}
+	return fmt.Errorf("model %s is not allowed", model)
+}
+
+// Clamp 校验并限制客户端传入的生成参数
+// 负数参数返回错误，超过上限的参数截断到上限
+func (l *GenerationLimits) Clamp(opts GenerationOptions) (GenerationOptions, error) {
+	if opts.Temperature != nil {
+		if *opts.Temperature < 0 {
+			return opts, fmt.Errorf("temperature must be >= 0")
+		}
+		temp := *opts.Temperature
+		if temp > l.MaxTemperature {
+			temp = l.MaxTemperature
+		}
+		opts.Temperature = &temp
+	}
+	if opts.TopP != nil {
+		if *opts.TopP <= 0 {
+			return opts, fmt.Errorf("top_p must be > 0")
+		}
+		topP := *opts.TopP
+		if topP > l.MaxTopP {
+			topP = l.MaxTopP
+		}
+		opts.TopP = &topP
+	}
+	if opts.MaxTokens < 0 {
+		return opts, fmt.Errorf("max_tokens must be >= 0")
+	}
+	if l.MaxTokens > 0 && opts.MaxTokens > l.MaxTokens {
+		opts.MaxTokens = l.MaxTokens
+	}
+	return opts, nil
+}
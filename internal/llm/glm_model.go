@@ -38,6 +38,7 @@ func NewGLMModel(config ModelConfig) (*GLMModel, error) {
 // Chat 实现Chat接口
 func (m *GLMModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
 	reqBody := m.buildAPIChatRequest(messages, false)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -60,7 +61,7 @@ func (m *GLMModel) Chat(ctx context.Context, messages []models.Message) (string,
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -77,12 +78,16 @@ func (m *GLMModel) Chat(ctx context.Context, messages []models.Message) (string,
 		return "", fmt.Errorf("no choices in response")
 	}
 
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), chatResp.Usage)
+
 	return chatResp.Choices[0].Message.Content, nil
 }
 
 // ChatStream 实现流式Chat接口
 func (m *GLMModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	reqBody := m.buildAPIChatRequest(messages, true)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -104,7 +109,7 @@ func (m *GLMModel) ChatStream(ctx context.Context, messages []models.Message) (<
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	ch := make(chan string)
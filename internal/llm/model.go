@@ -2,6 +2,8 @@ package llm
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 
 	"ai-agent-assistant/pkg/models"
 )
@@ -30,6 +32,25 @@ type Model interface {
 	GetProviderName() string
 }
 
+// APIError 模型服务商返回的非200响应
+// 调用方可据此区分配额用尽（429）与其他服务商故障
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("API error: status=%d", e.StatusCode)
+	}
+	return fmt.Sprintf("API error: status=%d, body=%s", e.StatusCode, e.Body)
+}
+
+// QuotaExceeded 是否为配额用尽或被服务商限流
+func (e *APIError) QuotaExceeded() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
 // ToolCall 工具调用
 type ToolCall struct {
 	ID       string                 `json:"id"`
@@ -103,6 +124,30 @@ type ModelWithOptions interface {
 	SetMaxTokens(tokens int)
 }
 
+// ToolCallingModel 支持函数调用的模型接口
+type ToolCallingModel interface {
+	Model
+
+	// ChatWithTools 携带工具定义的对话，模型选择的工具调用在ChatResponse.ToolCalls中返回
+	ChatWithTools(ctx context.Context, messages []models.Message, tools []Tool) (*ChatResponse, error)
+}
+
+// AsToolCallingModel 获取模型的函数调用能力
+// 与AsVisionModel一样逐层解开包装，找到已开启工具调用的底层实现
+func AsToolCallingModel(model Model) (ToolCallingModel, bool) {
+	for model != nil {
+		if tm, ok := model.(ToolCallingModel); ok && tm.SupportsToolCalling() {
+			return tm, true
+		}
+		wrapper, ok := model.(interface{ Unwrap() Model })
+		if !ok {
+			break
+		}
+		model = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
 // StreamingModel 流式模型接口
 type StreamingModel interface {
 	// ChatStreamWithCallback 带回调的流式对话
@@ -120,6 +165,41 @@ type ModelWithReasoning interface {
 	Reflect(ctx context.Context, previousRuns []string) (reflection string, err error)
 }
 
+// ImageInput 图片输入
+// URL与Data二选一，Data为原始字节，发送时编码为base64 data URL
+type ImageInput struct {
+	URL      string `json:"url,omitempty"`
+	Data     []byte `json:"-"`
+	MIMEType string `json:"mime_type,omitempty"`
+}
+
+// VisionModel 支持图片输入的模型接口
+type VisionModel interface {
+	Model
+
+	// SupportsVision 是否支持图片输入
+	SupportsVision() bool
+
+	// ChatWithImages 携带图片的对话，图片附加在最后一条用户消息上
+	ChatWithImages(ctx context.Context, messages []models.Message, images []ImageInput) (string, error)
+}
+
+// AsVisionModel 获取模型的图片输入能力
+// 会逐层解开缓存、用量统计等包装，找到底层的VisionModel实现
+func AsVisionModel(model Model) (VisionModel, bool) {
+	for model != nil {
+		if vm, ok := model.(VisionModel); ok && vm.SupportsVision() {
+			return vm, true
+		}
+		wrapper, ok := model.(interface{ Unwrap() Model })
+		if !ok {
+			break
+		}
+		model = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
 // ========== 通用API请求/响应类型 ==========
 
 // APIChatRequest 通用聊天API请求
@@ -140,6 +220,32 @@ type APIChatMessage struct {
 	Content string `json:"content"`
 }
 
+// APIContentPart 多模态消息内容片段（OpenAI兼容格式）
+type APIContentPart struct {
+	Type     string       `json:"type"` // text, image_url
+	Text     string       `json:"text,omitempty"`
+	ImageURL *APIImageURL `json:"image_url,omitempty"`
+}
+
+// APIImageURL 图片地址（支持 data:image/...;base64, 形式）
+type APIImageURL struct {
+	URL string `json:"url"`
+}
+
+// APIMultimodalMessage 多模态聊天消息
+type APIMultimodalMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"` // string 或 []APIContentPart
+}
+
+// APIMultimodalRequest 多模态聊天API请求
+type APIMultimodalRequest struct {
+	Model       string                 `json:"model"`
+	Messages    []APIMultimodalMessage `json:"messages"`
+	Temperature float64                `json:"temperature,omitempty"`
+	MaxTokens   int                    `json:"max_tokens,omitempty"`
+}
+
 // APIChatResponse 通用聊天API响应
 type APIChatResponse struct {
 	ID      string              `json:"id"`
@@ -1,7 +1,15 @@
 package llm
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
 )
 
 // TestModelFactory 测试模型工厂
@@ -197,3 +205,466 @@ func TestModelManager(t *testing.T) {
 	}
 	*/
 }
+
+// countingModel 记录调用次数的测试模型
+type countingModel struct {
+	GLMModel
+	calls  int
+	embeds int
+}
+
+func (m *countingModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	m.calls++
+	return "answer", nil
+}
+
+func (m *countingModel) Embed(ctx context.Context, text string) ([]float64, error) {
+	// 以文本长度作为向量，便于构造相似/不相似的输入
+	m.embeds++
+	return []float64{1, float64(len(text)) / 100}, nil
+}
+
+// TestResponseCache 测试LLM响应缓存
+func TestResponseCache(t *testing.T) {
+	inner := &countingModel{GLMModel: GLMModel{config: ModelConfig{Model: "glm-4-flash"}}}
+	cache := NewResponseCache(ResponseCacheConfig{
+		TTL: time.Minute,
+		Routes: map[string]CacheMode{
+			"eval":           CacheModeExact,
+			"classification": CacheModeSemantic,
+		},
+	})
+	model := NewCachedModel(inner, cache)
+
+	messages := []models.Message{{Role: "user", Content: "hello"}}
+
+	// 未开启缓存的路由不走缓存
+	model.Chat(context.Background(), messages)
+	model.Chat(context.Background(), messages)
+	if inner.calls != 2 {
+		t.Errorf("Expected 2 calls without cache route, got %d", inner.calls)
+	}
+
+	// 精确匹配
+	ctx := WithCacheRoute(context.Background(), "eval")
+	model.Chat(ctx, messages)
+	model.Chat(ctx, messages)
+	if inner.calls != 3 {
+		t.Errorf("Expected exact cache hit, got %d calls", inner.calls)
+	}
+
+	// 语义匹配：没有向量化模型时退化为精确匹配
+	if mode := cache.ModeFor("classification"); mode != CacheModeExact {
+		t.Errorf("Expected exact mode without embedder, got %s", mode)
+	}
+
+	cache.SetEmbedder(inner)
+	ctx = WithCacheRoute(context.Background(), "classification")
+	model.Chat(ctx, []models.Message{{Role: "user", Content: "classify: good"}})
+	model.Chat(ctx, []models.Message{{Role: "user", Content: "classify: fine"}})
+	if inner.calls != 4 {
+		t.Errorf("Expected semantic cache hit, got %d calls", inner.calls)
+	}
+	// 未命中时Lookup的向量复用于Store，每次请求只向量化一次
+	if inner.embeds != 2 {
+		t.Errorf("Expected 2 embeddings for 2 semantic requests, got %d", inner.embeds)
+	}
+
+	// 生成参数不同的请求不会语义命中
+	model.Chat(WithGenerationOptions(ctx, GenerationOptions{MaxTokens: 50}), []models.Message{{Role: "user", Content: "classify: okay"}})
+	if inner.calls != 5 {
+		t.Errorf("Expected a miss for different generation options, got %d calls", inner.calls)
+	}
+
+	stats := cache.Stats()
+	if stats["semantic_hits"].(int64) != 1 {
+		t.Errorf("Expected 1 semantic hit, got %v", stats["semantic_hits"])
+	}
+}
+
+// TestResponseCacheEviction 测试缓存容量淘汰
+func TestResponseCacheEviction(t *testing.T) {
+	cache := NewResponseCache(ResponseCacheConfig{
+		MaxEntries: 2,
+		Routes:     map[string]CacheMode{"eval": CacheModeExact},
+	})
+	ctx := WithCacheRoute(context.Background(), "eval")
+
+	for _, content := range []string{"a", "b", "c"} {
+		cache.Store(ctx, "m", []models.Message{{Role: "user", Content: content}}, nil, content)
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, _, ok := cache.Lookup(ctx, "m", []models.Message{{Role: "user", Content: "a"}}); ok {
+		t.Error("Oldest entry should have been evicted")
+	}
+	if _, _, ok := cache.Lookup(ctx, "m", []models.Message{{Role: "user", Content: "c"}}); !ok {
+		t.Error("Newest entry should be cached")
+	}
+}
+
+// TestUsageTracking 测试token用量与费用统计
+func TestUsageTracking(t *testing.T) {
+	tracker := NewUsageTracker(map[string]ModelPrice{
+		"glm-4-flash": {PromptPer1K: 1, CompletionPer1K: 2},
+	}, "CNY")
+
+	// 外层收集器（如一次RAG查询）不挂统计器，只收集
+	outer := NewUsageCollector(nil, "rag")
+	ctx := WithUsageCollector(context.Background(), outer)
+
+	// 模型包装层创建挂在统计器上的收集器
+	inner := NewUsageCollector(tracker, "rag")
+	ctx = WithUsageCollector(ctx, inner)
+
+	reportUsage(ctx, "glm-4-flash", "zhipu", &Usage{PromptTokens: 1000, CompletionTokens: 500})
+
+	totals := outer.Totals()
+	if totals.TotalTokens != 1500 {
+		t.Errorf("Expected 1500 tokens in outer collector, got %d", totals.TotalTokens)
+	}
+	if totals.Cost != 2 {
+		t.Errorf("Expected cost 2, got %f", totals.Cost)
+	}
+
+	summary := tracker.Summary()
+	if summary["total"].(UsageSummary).Calls != 1 {
+		t.Errorf("Expected 1 tracked call, got %v", summary["total"])
+	}
+}
+
+// streamingModel 分块返回固定答案的流式模型
+type streamingModel struct {
+	GLMModel
+	chunks []string
+}
+
+func (m *streamingModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, chunk := range m.chunks {
+			ch <- chunk
+		}
+	}()
+	return ch, nil
+}
+
+// TestStreamUsage 测试流式调用的估算用量
+func TestStreamUsage(t *testing.T) {
+	inner := &streamingModel{
+		GLMModel: GLMModel{config: ModelConfig{Model: "glm-4-flash"}},
+		chunks:   []string{"你好，", "我是", "助手"},
+	}
+	tracker := NewUsageTracker(nil, "CNY")
+	model := NewUsageTrackedModel(inner, tracker)
+
+	collector := NewUsageCollector(nil, "chat")
+	messages := []models.Message{{Role: "user", Content: "介绍一下你自己"}}
+	stream, err := model.ChatStream(WithUsageCollector(context.Background(), collector), messages)
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+
+	var answer string
+	for chunk := range stream {
+		answer += chunk
+	}
+	if answer != "你好，我是助手" {
+		t.Errorf("Unexpected stream content: %s", answer)
+	}
+
+	totals := collector.Totals()
+	if totals.Calls != 1 {
+		t.Fatalf("Expected 1 usage record, got %d", totals.Calls)
+	}
+	if want := EstimateMessagesTokens(messages) + EstimateTokens(answer); totals.TotalTokens != int64(want) {
+		t.Errorf("Expected %d estimated tokens, got %d", want, totals.TotalTokens)
+	}
+	if tracker.Summary()["total"].(UsageSummary).Calls != 1 {
+		t.Error("Expected stream usage recorded in tracker")
+	}
+}
+
+// TestModelRouter 测试便宜/昂贵模型路由
+func TestModelRouter(t *testing.T) {
+	router := NewModelRouter("glm", []ModelProfile{
+		{Name: "glm", Tier: TierCheap, AvgLatencyMs: 800, CostPer1K: 0.1},
+		{Name: "qwen", Tier: TierStrong, AvgLatencyMs: 3000, CostPer1K: 2},
+	})
+	router.SetPipelinePolicy("chat_rag", PipelinePolicy{
+		Rules: []RoutingRule{
+			{TaskType: "query_condense", Model: "glm"},
+			{TaskType: "final_answer", MinComplexity: 0.6, Model: "qwen"},
+		},
+	})
+
+	decision := router.Route(RouteRequest{Pipeline: "chat_rag", TaskType: "query_condense", Complexity: 0.9})
+	if decision.Model != "glm" {
+		t.Errorf("Expected glm for query_condense, got %s", decision.Model)
+	}
+
+	decision = router.Route(RouteRequest{Pipeline: "chat_rag", TaskType: "final_answer", Complexity: 0.8})
+	if decision.Model != "qwen" {
+		t.Errorf("Expected qwen for complex final_answer, got %s", decision.Model)
+	}
+
+	// 简单问题没有命中规则，按复杂度选择便宜模型
+	decision = router.Route(RouteRequest{Pipeline: "chat_rag", TaskType: "final_answer", Prompt: "你好"})
+	if decision.Model != "glm" {
+		t.Errorf("Expected glm for simple final_answer, got %s (%s)", decision.Model, decision.Reason)
+	}
+
+	// 超出延迟预算时降级
+	decision = router.Route(RouteRequest{Pipeline: "chat_rag", TaskType: "final_answer", Complexity: 0.8, LatencyBudgetMs: 1000})
+	if decision.Model != "glm" {
+		t.Errorf("Expected downgrade to glm within latency budget, got %s", decision.Model)
+	}
+}
+
+// TestVisionModel 测试视觉模型识别和多模态请求构建
+func TestVisionModel(t *testing.T) {
+	textModel, _ := NewQwenModel(ModelConfig{APIKey: "test", Model: "qwen-plus"})
+	if _, ok := AsVisionModel(textModel); ok {
+		t.Error("qwen-plus should not support vision")
+	}
+
+	visionModel, _ := NewQwenModel(ModelConfig{APIKey: "test", Model: "qwen-vl-plus"})
+	wrapped := NewCachedModel(NewUsageTrackedModel(visionModel, NewUsageTracker(nil, "")), NewResponseCache(ResponseCacheConfig{}))
+	if _, ok := AsVisionModel(wrapped); !ok {
+		t.Error("Expected wrapped qwen-vl-plus to support vision")
+	}
+
+	req, err := visionModel.buildMultimodalRequest([]models.Message{
+		{Role: "system", Content: "你是助手"},
+		{Role: "user", Content: "描述这张图"},
+	}, []ImageInput{{Data: []byte("fake"), MIMEType: "image/png"}})
+	if err != nil {
+		t.Fatalf("buildMultimodalRequest failed: %v", err)
+	}
+
+	parts, ok := req.Messages[1].Content.([]APIContentPart)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Expected image and text parts on user message, got %#v", req.Messages[1].Content)
+	}
+	if parts[0].ImageURL == nil || parts[0].ImageURL.URL != "data:image/png;base64,ZmFrZQ==" {
+		t.Errorf("Unexpected image part: %#v", parts[0])
+	}
+}
+
+// TestGenerationOptions 测试生成参数上限和请求体覆盖
+func TestGenerationOptions(t *testing.T) {
+	limits := &GenerationLimits{MaxTemperature: 1, MaxTopP: 0.9, MaxTokens: 1000}
+
+	temp, topP := 1.8, 0.95
+	opts, err := limits.Clamp(GenerationOptions{Temperature: &temp, TopP: &topP, MaxTokens: 5000})
+	if err != nil {
+		t.Fatalf("Clamp failed: %v", err)
+	}
+	if *opts.Temperature != 1 || *opts.TopP != 0.9 || opts.MaxTokens != 1000 {
+		t.Errorf("Expected clamped options, got temp=%v top_p=%v max_tokens=%d", *opts.Temperature, *opts.TopP, opts.MaxTokens)
+	}
+
+	negative := -0.1
+	if _, err := limits.Clamp(GenerationOptions{Temperature: &negative}); err == nil {
+		t.Error("Expected error for negative temperature")
+	}
+
+	model, _ := NewQwenModel(ModelConfig{APIKey: "test", Temperature: 0.7, MaxTokens: 2000})
+	ctx := WithGenerationOptions(context.Background(), opts)
+	req := model.buildAPIChatRequest(nil, false)
+	GenerationOptionsFromContext(ctx).applyTo(&req.Temperature, &req.TopP, &req.MaxTokens)
+	if req.Temperature != 1 || req.TopP != 0.9 || req.MaxTokens != 1000 {
+		t.Errorf("Expected request overridden by options, got %+v", req)
+	}
+}
+
+// TestCallLogRedaction 测试调用日志脱敏
+func TestCallLogRedaction(t *testing.T) {
+	redactor, err := NewRedactor(DefaultRedactionRules, map[string]string{"order": `ORD-\d+`})
+	if err != nil {
+		t.Fatalf("NewRedactor failed: %v", err)
+	}
+
+	store := NewMemoryCallLogStore(10)
+	logger := NewCallLogger(store, redactor, nil)
+
+	model := NewLoggedModel(&countingModel{}, logger)
+	_, err = model.Chat(context.Background(), []models.Message{
+		{Role: "user", Content: "我的邮箱是 zhang.san@example.com，手机13812345678，身份证110101199003071234，订单ORD-42"},
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	logs, _ := store.Recent(context.Background(), 1)
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, got %d", len(logs))
+	}
+	content := logs[0].Messages[0].Content
+	for _, secret := range []string{"zhang.san@example.com", "13812345678", "110101199003071234", "ORD-42"} {
+		if strings.Contains(content, secret) {
+			t.Errorf("Expected %s to be redacted, got %s", secret, content)
+		}
+	}
+	if !strings.Contains(content, "[REDACTED_ID_CARD]") {
+		t.Errorf("Expected id card placeholder, got %s", content)
+	}
+}
+
+// fixedModel 返回固定答案的模型
+type fixedModel struct {
+	GLMModel
+	name   string
+	answer string
+}
+
+func (m *fixedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	return m.answer, nil
+}
+
+func (m *fixedModel) GetModelName() string {
+	return m.name
+}
+
+// TestConsensus 测试多模型共识
+func TestConsensus(t *testing.T) {
+	candidates := []Model{
+		&fixedModel{name: "a", answer: "正面"},
+		&fixedModel{name: "b", answer: "负面"},
+		&fixedModel{name: "c", answer: "正面。"},
+	}
+
+	consensus, err := NewConsensus(candidates, ConsensusModeVote, nil)
+	if err != nil {
+		t.Fatalf("NewConsensus failed: %v", err)
+	}
+	result, err := consensus.Generate(context.Background(), []models.Message{{Role: "user", Content: "情感分类"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if result.Answer != "正面" || result.Votes["正面"] != 2 {
+		t.Errorf("Expected majority answer 正面 with 2 votes, got %q %v", result.Answer, result.Votes)
+	}
+
+	// 裁判模型选择第2个候选
+	judge := &fixedModel{name: "judge", answer: "2\n更准确"}
+	consensus, _ = NewConsensus(candidates, ConsensusModeJudge, judge)
+	result, err = consensus.Generate(context.Background(), []models.Message{{Role: "user", Content: "情感分类"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if result.Answer != "负面" || result.JudgeReason != "更准确" {
+		t.Errorf("Expected judge to select 负面, got %q (%s)", result.Answer, result.JudgeReason)
+	}
+}
+
+// throttledModel 前几次调用返回429的模型
+type throttledModel struct {
+	GLMModel
+	failures int
+	calls    int
+}
+
+func (m *throttledModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return "", &APIError{StatusCode: 429, Body: "rate limited"}
+	}
+	return "answer", nil
+}
+
+// TestRequestQueue 测试LLM请求队列的优先级、租户公平和限流重试
+func TestRequestQueue(t *testing.T) {
+	queue := NewRequestQueue(RequestQueueConfig{MaxConcurrent: 1, MaxWait: 5 * time.Second})
+
+	// 占住唯一的槽位，让后续请求排队
+	release, err := queue.acquire(context.Background(), "glm")
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	queued := 0
+	enqueue := func(label, tenantID string, priority Priority) {
+		ctx := WithPriority(tenant.WithTenant(context.Background(), tenantID), priority)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.Do(ctx, "glm", func(ctx context.Context) error {
+				mu.Lock()
+				order = append(order, label)
+				mu.Unlock()
+				return nil
+			})
+		}()
+		// 等待请求进入队列，保证入队顺序
+		queued++
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if stats := queue.Stats(); len(stats) == 1 && stats[0].Queued == queued {
+				break
+			}
+		}
+	}
+
+	enqueue("batch-a1", "a", PriorityBatch)
+	enqueue("batch-a2", "a", PriorityBatch)
+	enqueue("batch-b1", "b", PriorityBatch)
+	enqueue("workflow-a1", "a", PriorityWorkflow)
+	enqueue("chat-b1", "b", PriorityInteractive)
+
+	stats := queue.Stats()[0]
+	if stats.Queued != 5 || stats.QueuedByPriority["batch"] != 3 || stats.QueuedByTenant["a"] != 3 {
+		t.Errorf("Unexpected queue stats: %+v", stats)
+	}
+
+	release()
+	wg.Wait()
+
+	expected := []string{"chat-b1", "workflow-a1", "batch-a1", "batch-b1", "batch-a2"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+
+	// 被限流后暂停服务商并重新排队，最终成功
+	queue = NewRequestQueue(RequestQueueConfig{MaxRetries: 2, Backoff: 10 * time.Millisecond, MaxBackoff: time.Second})
+	inner := &throttledModel{failures: 2}
+	model := NewQueuedModel(inner, queue)
+	start := time.Now()
+	answer, err := model.Chat(context.Background(), []models.Message{{Role: "user", Content: "hello"}})
+	if err != nil || answer != "answer" {
+		t.Fatalf("Expected answer after retries, got %q, %v", answer, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected backoff of 10ms+20ms between retries, took %v", elapsed)
+	}
+	if stats := queue.Stats()[0]; stats.Throttled != 2 || stats.Active != 0 {
+		t.Errorf("Unexpected stats after retries: %+v", stats)
+	}
+
+	// 超过重试次数时返回服务商的429错误
+	inner = &throttledModel{failures: 5}
+	_, err = NewQueuedModel(inner, queue).Chat(context.Background(), []models.Message{{Role: "user", Content: "hello"}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.QuotaExceeded() || inner.calls != 3 {
+		t.Errorf("Expected 429 after 3 calls, got %v after %d calls", err, inner.calls)
+	}
+
+	// 队列已满
+	queue = NewRequestQueue(RequestQueueConfig{MaxConcurrent: 1, MaxQueueSize: 1, MaxWait: 20 * time.Millisecond})
+	release, _ = queue.acquire(context.Background(), "glm")
+	go queue.acquire(context.Background(), "glm")
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && queue.Stats()[0].Queued == 0; {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := queue.acquire(context.Background(), "glm"); !errors.Is(err, ErrRequestQueueFull) {
+		t.Errorf("Expected ErrRequestQueueFull, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if stats := queue.Stats()[0]; stats.TimedOut != 1 || stats.Rejected != 1 {
+		t.Errorf("Expected 1 timeout and 1 rejection, got %+v", stats)
+	}
+	release()
+}
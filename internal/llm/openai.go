@@ -47,6 +47,7 @@ func (m *OpenAIModel) Chat(ctx context.Context, messages []models.Message) (stri
 // ChatStream 实现流式Chat接口
 func (m *OpenAIModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	reqBody := m.buildChatRequest(messages, true)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -68,7 +69,7 @@ func (m *OpenAIModel) ChatStream(ctx context.Context, messages []models.Message)
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	ch := make(chan string)
@@ -112,6 +113,7 @@ func (m *OpenAIModel) ChatStream(ctx context.Context, messages []models.Message)
 // ChatWithOptions 带选项的对话
 func (m *OpenAIModel) ChatWithOptions(ctx context.Context, messages []models.Message, options map[string]interface{}) (*ChatResponse, error) {
 	reqBody := m.buildChatRequest(messages, false)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	// 应用选项
 	if options != nil {
@@ -121,6 +123,9 @@ func (m *OpenAIModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 		if maxTokens, ok := options["max_tokens"].(int); ok {
 			reqBody.MaxTokens = maxTokens
 		}
+		if tools, ok := options["tools"].([]Tool); ok {
+			reqBody.Tools = tools
+		}
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -144,7 +149,7 @@ func (m *OpenAIModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -172,6 +177,10 @@ func (m *OpenAIModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 	}
 
 	choice := openaiResp.Choices[0]
+
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), &openaiResp.Usage)
+
 	return &ChatResponse{
 		Content:      choice.Message.Content,
 		ToolCalls:    choice.Message.ToolCalls,
@@ -180,6 +189,11 @@ func (m *OpenAIModel) ChatWithOptions(ctx context.Context, messages []models.Mes
 	}, nil
 }
 
+// ChatWithTools 携带工具定义的对话
+func (m *OpenAIModel) ChatWithTools(ctx context.Context, messages []models.Message, tools []Tool) (*ChatResponse, error) {
+	return m.ChatWithOptions(ctx, messages, map[string]interface{}{"tools": tools})
+}
+
 // SupportsToolCalling OpenAI支持工具调用
 func (m *OpenAIModel) SupportsToolCalling() bool {
 	return m.config.EnableToolCalling
@@ -218,7 +232,7 @@ func (m *OpenAIModel) Embed(ctx context.Context, text string) ([]float64, error)
 
 	if resp.StatusCode != http.StatusOK {
 		errorBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(errorBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(errorBody)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -268,9 +282,10 @@ type openAIChatRequest struct {
 	Model       string                 `json:"model"`
 	Messages    []openAIChatMessage    `json:"messages"`
 	Temperature float64                `json:"temperature,omitempty"`
+	TopP        float64                `json:"top_p,omitempty"`
 	MaxTokens   int                    `json:"max_tokens,omitempty"`
 	Stream      bool                   `json:"stream,omitempty"`
-	Tools       []map[string]interface{} `json:"tools,omitempty"`
+	Tools       []Tool                 `json:"tools,omitempty"`
 }
 
 type openAIChatMessage struct {
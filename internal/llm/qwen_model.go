@@ -3,10 +3,12 @@ package llm
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"ai-agent-assistant/pkg/models"
 )
@@ -38,6 +40,7 @@ func NewQwenModel(config ModelConfig) (*QwenModel, error) {
 // Chat 实现Chat接口
 func (m *QwenModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
 	reqBody := m.buildAPIChatRequest(messages, false)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -60,7 +63,7 @@ func (m *QwenModel) Chat(ctx context.Context, messages []models.Message) (string
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -77,12 +80,16 @@ func (m *QwenModel) Chat(ctx context.Context, messages []models.Message) (string
 		return "", fmt.Errorf("no choices in response")
 	}
 
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), chatResp.Usage)
+
 	return chatResp.Choices[0].Message.Content, nil
 }
 
 // ChatStream 实现流式Chat接口
 func (m *QwenModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	reqBody := m.buildAPIChatRequest(messages, true)
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, &reqBody.TopP, &reqBody.MaxTokens)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -104,7 +111,7 @@ func (m *QwenModel) ChatStream(ctx context.Context, messages []models.Message) (
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	ch := make(chan string)
@@ -188,7 +195,7 @@ func (m *QwenModel) Embed(ctx context.Context, text string) ([]float64, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -215,6 +222,70 @@ func (m *QwenModel) Embed(ctx context.Context, text string) ([]float64, error) {
 	return embedResp.Output.Embeddings[0].Embedding, nil
 }
 
+// SupportsVision 千问VL系列（qwen-vl-plus/qwen-vl-max）支持图片输入
+func (m *QwenModel) SupportsVision() bool {
+	return strings.Contains(m.config.Model, "-vl")
+}
+
+// ChatWithImages 携带图片的对话（使用兼容模式的多模态消息格式）
+func (m *QwenModel) ChatWithImages(ctx context.Context, messages []models.Message, images []ImageInput) (string, error) {
+	if !m.SupportsVision() {
+		return "", fmt.Errorf("model %s does not support image input", m.config.Model)
+	}
+	if len(images) == 0 {
+		return m.Chat(ctx, messages)
+	}
+
+	reqBody, err := m.buildMultimodalRequest(messages, images)
+	if err != nil {
+		return "", err
+	}
+	GenerationOptionsFromContext(ctx).applyTo(&reqBody.Temperature, nil, &reqBody.MaxTokens)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.config.APIKey)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var chatResp APIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	// 上报token用量
+	reportUsage(ctx, m.config.Model, m.GetProviderName(), chatResp.Usage)
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
 // GetModelName 获取模型名称
 func (m *QwenModel) GetModelName() string {
 	return m.config.Model
@@ -253,3 +324,64 @@ func (m *QwenModel) buildAPIChatRequest(messages []models.Message, stream bool)
 		Stream:      stream,
 	}
 }
+
+// buildMultimodalRequest 构建多模态请求，图片附加在最后一条用户消息上
+func (m *QwenModel) buildMultimodalRequest(messages []models.Message, images []ImageInput) (APIMultimodalRequest, error) {
+	lastUser := -1
+	for i, msg := range messages {
+		if msg.Role == "user" {
+			lastUser = i
+		}
+	}
+
+	chatMessages := make([]APIMultimodalMessage, 0, len(messages)+1)
+	for i, msg := range messages {
+		if i != lastUser {
+			chatMessages = append(chatMessages, APIMultimodalMessage{Role: msg.Role, Content: msg.Content})
+			continue
+		}
+
+		parts, err := imageContentParts(images)
+		if err != nil {
+			return APIMultimodalRequest{}, err
+		}
+		parts = append(parts, APIContentPart{Type: "text", Text: msg.Content})
+		chatMessages = append(chatMessages, APIMultimodalMessage{Role: msg.Role, Content: parts})
+	}
+
+	// 没有用户消息时单独追加一条只含图片的消息
+	if lastUser < 0 {
+		parts, err := imageContentParts(images)
+		if err != nil {
+			return APIMultimodalRequest{}, err
+		}
+		chatMessages = append(chatMessages, APIMultimodalMessage{Role: "user", Content: parts})
+	}
+
+	return APIMultimodalRequest{
+		Model:       m.config.Model,
+		Messages:    chatMessages,
+		Temperature: m.config.Temperature,
+		MaxTokens:   m.config.MaxTokens,
+	}, nil
+}
+
+// imageContentParts 将图片转换为image_url内容片段
+func imageContentParts(images []ImageInput) ([]APIContentPart, error) {
+	parts := make([]APIContentPart, 0, len(images))
+	for i, img := range images {
+		url := img.URL
+		if url == "" {
+			if len(img.Data) == 0 {
+				return nil, fmt.Errorf("image %d has neither url nor data", i)
+			}
+			mimeType := img.MIMEType
+			if mimeType == "" {
+				mimeType = http.DetectContentType(img.Data)
+			}
+			url = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+		}
+		parts = append(parts, APIContentPart{Type: "image_url", ImageURL: &APIImageURL{URL: url}})
+	}
+	return parts, nil
+}
@@ -0,0 +1,518 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
+)
+
+// Priority LLM请求优先级，数值越大越先出队
+type Priority int
+
+const (
+	// PriorityBatch 批量评测等离线任务
+	PriorityBatch Priority = iota
+	// PriorityWorkflow 工作流步骤
+	PriorityWorkflow
+	// PriorityInteractive 交互式对话（默认）
+	PriorityInteractive
+)
+
+// priorityNames 优先级名称，下标为优先级
+var priorityNames = [...]string{"batch", "workflow", "interactive"}
+
+// String 优先级名称
+func (p Priority) String() string {
+	if p < PriorityBatch || p > PriorityInteractive {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+type priorityKey struct{}
+
+// WithPriority 设置请求在LLM请求队列中的优先级
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext 获取请求的优先级，未设置时为交互式
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= PriorityBatch && p <= PriorityInteractive {
+		return p
+	}
+	return PriorityInteractive
+}
+
+var (
+	// ErrRequestQueueFull 服务商的等待队列已满
+	ErrRequestQueueFull = errors.New("llm request queue is full")
+
+	// ErrRequestQueueTimeout 排队超过最长等待时间
+	ErrRequestQueueTimeout = errors.New("timed out waiting in llm request queue")
+)
+
+// RequestQueueConfig LLM请求队列配置
+type RequestQueueConfig struct {
+	MaxConcurrent int           // 每个服务商同时进行的请求数，0表示不限制
+	MaxQueueSize  int           // 每个服务商的等待请求数上限，0表示不限制
+	MaxWait       time.Duration // 排队最长等待时间，0表示只受请求上下文限制
+	MaxRetries    int           // 被限流（429）后重新排队的次数
+	Backoff       time.Duration // 被限流后暂停该服务商的初始时长，连续限流时翻倍
+	MaxBackoff    time.Duration // 暂停时长上限
+}
+
+// RequestQueue 按服务商排队的LLM请求队列
+// 服务商返回429时暂停该服务商并让请求重新排队，而不是直接失败；
+// 出队时高优先级先出，同一优先级内在租户之间轮转，避免单个租户的大量请求饿死其他租户
+type RequestQueue struct {
+	cfg       RequestQueueConfig
+	mu        sync.Mutex
+	providers map[string]*providerQueue
+}
+
+// providerQueue 单个服务商的并发槽位、限流状态和等待队列
+type providerQueue struct {
+	active        int
+	cooldownUntil time.Time
+	backoff       time.Duration // 当前暂停时长，请求成功后清零
+	timer         *time.Timer   // 暂停结束后唤醒等待者
+	levels        [len(priorityNames)]fairQueue
+	queued        int
+
+	served    int64
+	throttled int64
+	rejected  int64
+	timedOut  int64
+	waitTotal time.Duration
+}
+
+// fairQueue 同一优先级的等待者，每个租户一个FIFO，按租户轮转出队
+type fairQueue struct {
+	tenants []string
+	waiting map[string][]*queueWaiter
+	next    int
+}
+
+// queueWaiter 等待槽位的请求
+type queueWaiter struct {
+	tenant   string
+	enqueued time.Time
+	ready    chan struct{}
+	granted  bool
+}
+
+// NewRequestQueue 创建LLM请求队列
+func NewRequestQueue(cfg RequestQueueConfig) *RequestQueue {
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	return &RequestQueue{
+		cfg:       cfg,
+		providers: make(map[string]*providerQueue),
+	}
+}
+
+// NewRequestQueueFromConfig 根据应用配置创建LLM请求队列，未启用时返回nil
+func NewRequestQueueFromConfig(cfg config.LLMQueueConfig) *RequestQueue {
+	if !cfg.Enabled {
+		return nil
+	}
+	maxQueueSize := cfg.MaxQueueSize
+	if maxQueueSize == 0 {
+		maxQueueSize = 1000
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+	return NewRequestQueue(RequestQueueConfig{
+		MaxConcurrent: cfg.MaxConcurrent,
+		MaxQueueSize:  maxQueueSize,
+		MaxWait:       parseQueueDuration(cfg.MaxWait, 60*time.Second),
+		MaxRetries:    maxRetries,
+		Backoff:       parseQueueDuration(cfg.Backoff, time.Second),
+		MaxBackoff:    parseQueueDuration(cfg.MaxBackoff, 30*time.Second),
+	})
+}
+
+// parseQueueDuration 解析时长，为空或不合法时使用默认值
+func parseQueueDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
+
+// Do 在服务商的槽位内执行调用，被限流时暂停服务商并重新排队，最多重试MaxRetries次
+func (q *RequestQueue) Do(ctx context.Context, provider string, call func(ctx context.Context) error) error {
+	release, err := q.run(ctx, provider, call)
+	if err != nil {
+		return err
+	}
+	release()
+	return nil
+}
+
+// run 执行调用，成功时返回释放槽位的函数，由调用方在用完结果（如读完流）后调用
+func (q *RequestQueue) run(ctx context.Context, provider string, call func(ctx context.Context) error) (func(), error) {
+	for attempt := 0; ; attempt++ {
+		release, err := q.acquire(ctx, provider)
+		if err != nil {
+			return nil, err
+		}
+
+		err = call(ctx)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.QuotaExceeded() {
+			q.throttle(provider)
+			release()
+			if attempt < q.cfg.MaxRetries {
+				continue
+			}
+			return nil, err
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+		q.resetBackoff(provider)
+		return release, nil
+	}
+}
+
+// acquire 获取服务商的槽位，没有空闲槽位或服务商被暂停时排队等待
+func (q *RequestQueue) acquire(ctx context.Context, provider string) (func(), error) {
+	q.mu.Lock()
+	pq := q.provider(provider)
+	now := time.Now()
+	if pq.queued == 0 && pq.available(q.cfg.MaxConcurrent, now) {
+		pq.active++
+		pq.served++
+		q.mu.Unlock()
+		return q.releaser(provider), nil
+	}
+	if q.cfg.MaxQueueSize > 0 && pq.queued >= q.cfg.MaxQueueSize {
+		pq.rejected++
+		q.mu.Unlock()
+		return nil, ErrRequestQueueFull
+	}
+
+	w := &queueWaiter{
+		tenant:   tenant.FromContext(ctx),
+		enqueued: now,
+		ready:    make(chan struct{}),
+	}
+	pq.levels[PriorityFromContext(ctx)].push(w)
+	pq.queued++
+	q.dispatch(pq, now)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if q.cfg.MaxWait > 0 {
+		timer := time.NewTimer(q.cfg.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var waitErr error
+	select {
+	case <-w.ready:
+		return q.releaser(provider), nil
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	case <-timeout:
+		waitErr = ErrRequestQueueTimeout
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.granted {
+		// 超时与出队同时发生，槽位已分配，交还给下一个等待者
+		pq.active--
+		q.dispatch(pq, time.Now())
+	} else {
+		pq.levels[PriorityFromContext(ctx)].remove(w)
+		pq.queued--
+	}
+	if errors.Is(waitErr, ErrRequestQueueTimeout) {
+		pq.timedOut++
+	}
+	return nil, waitErr
+}
+
+// releaser 返回只生效一次的槽位释放函数
+func (q *RequestQueue) releaser(provider string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			pq := q.provider(provider)
+			pq.active--
+			q.dispatch(pq, time.Now())
+		})
+	}
+}
+
+// throttle 服务商返回429，暂停服务商，连续限流时暂停时长翻倍
+func (q *RequestQueue) throttle(provider string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pq := q.provider(provider)
+	if pq.backoff == 0 {
+		pq.backoff = q.cfg.Backoff
+	} else if pq.backoff = pq.backoff * 2; pq.backoff > q.cfg.MaxBackoff {
+		pq.backoff = q.cfg.MaxBackoff
+	}
+	pq.cooldownUntil = time.Now().Add(pq.backoff)
+	pq.throttled++
+}
+
+// resetBackoff 请求成功，清除服务商的连续限流状态
+func (q *RequestQueue) resetBackoff(provider string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.provider(provider).backoff = 0
+}
+
+// provider 获取服务商的队列，调用方需持有锁
+func (q *RequestQueue) provider(name string) *providerQueue {
+	pq, ok := q.providers[name]
+	if !ok {
+		pq = &providerQueue{}
+		q.providers[name] = pq
+	}
+	return pq
+}
+
+// dispatch 把空闲槽位分配给等待者；服务商被暂停时在暂停结束后再分配，调用方需持有锁
+func (q *RequestQueue) dispatch(pq *providerQueue, now time.Time) {
+	for pq.queued > 0 && pq.available(q.cfg.MaxConcurrent, now) {
+		w := pq.pop()
+		pq.queued--
+		pq.active++
+		pq.served++
+		pq.waitTotal += now.Sub(w.enqueued)
+		w.granted = true
+		close(w.ready)
+	}
+
+	if pq.queued > 0 && now.Before(pq.cooldownUntil) && pq.timer == nil {
+		pq.timer = time.AfterFunc(pq.cooldownUntil.Sub(now), func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			pq.timer = nil
+			q.dispatch(pq, time.Now())
+		})
+	}
+}
+
+// available 是否可以立即开始新请求
+func (pq *providerQueue) available(maxConcurrent int, now time.Time) bool {
+	return (maxConcurrent <= 0 || pq.active < maxConcurrent) && !now.Before(pq.cooldownUntil)
+}
+
+// pop 取出优先级最高的等待者
+func (pq *providerQueue) pop() *queueWaiter {
+	for p := len(pq.levels) - 1; p >= 0; p-- {
+		if w := pq.levels[p].pop(); w != nil {
+			return w
+		}
+	}
+	return nil
+}
+
+// push 加入租户的FIFO末尾，新租户排在轮转顺序末尾
+func (f *fairQueue) push(w *queueWaiter) {
+	if f.waiting == nil {
+		f.waiting = make(map[string][]*queueWaiter)
+	}
+	if len(f.waiting[w.tenant]) == 0 {
+		f.tenants = append(f.tenants, w.tenant)
+	}
+	f.waiting[w.tenant] = append(f.waiting[w.tenant], w)
+}
+
+// pop 取出轮转到的租户的第一个等待者
+func (f *fairQueue) pop() *queueWaiter {
+	if len(f.tenants) == 0 {
+		return nil
+	}
+	if f.next >= len(f.tenants) {
+		f.next = 0
+	}
+	name := f.tenants[f.next]
+	waiters := f.waiting[name]
+	w := waiters[0]
+	if len(waiters) == 1 {
+		delete(f.waiting, name)
+		f.tenants = append(f.tenants[:f.next], f.tenants[f.next+1:]...)
+	} else {
+		f.waiting[name] = waiters[1:]
+		f.next++
+	}
+	return w
+}
+
+// remove 移除放弃等待的请求
+func (f *fairQueue) remove(w *queueWaiter) {
+	waiters := f.waiting[w.tenant]
+	for i, candidate := range waiters {
+		if candidate != w {
+			continue
+		}
+		waiters = append(waiters[:i:i], waiters[i+1:]...)
+		if len(waiters) > 0 {
+			f.waiting[w.tenant] = waiters
+			return
+		}
+		delete(f.waiting, w.tenant)
+		for j, name := range f.tenants {
+			if name == w.tenant {
+				f.tenants = append(f.tenants[:j], f.tenants[j+1:]...)
+				if j < f.next {
+					f.next--
+				}
+				break
+			}
+		}
+		return
+	}
+}
+
+// RequestQueueStats 单个服务商的队列统计
+type RequestQueueStats struct {
+	Provider          string         `json:"provider"`
+	Active            int            `json:"active"`
+	Queued            int            `json:"queued"`
+	QueuedByPriority  map[string]int `json:"queued_by_priority"`
+	QueuedByTenant    map[string]int `json:"queued_by_tenant"`
+	Served            int64          `json:"served"`
+	Throttled         int64          `json:"throttled"`
+	Rejected          int64          `json:"rejected"`
+	TimedOut          int64          `json:"timed_out"`
+	AvgWaitMs         float64        `json:"avg_wait_ms"`
+	CooldownRemaining float64        `json:"cooldown_remaining_seconds"`
+}
+
+// Stats 各服务商的队列统计，按服务商名称排序
+func (q *RequestQueue) Stats() []RequestQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]RequestQueueStats, 0, len(q.providers))
+	for name, pq := range q.providers {
+		s := RequestQueueStats{
+			Provider:         name,
+			Active:           pq.active,
+			Queued:           pq.queued,
+			QueuedByPriority: make(map[string]int, len(priorityNames)),
+			QueuedByTenant:   make(map[string]int),
+			Served:           pq.served,
+			Throttled:        pq.throttled,
+			Rejected:         pq.rejected,
+			TimedOut:         pq.timedOut,
+		}
+		for p := range pq.levels {
+			s.QueuedByPriority[priorityNames[p]] = 0
+			for tenantID, waiters := range pq.levels[p].waiting {
+				s.QueuedByPriority[priorityNames[p]] += len(waiters)
+				s.QueuedByTenant[tenantID] += len(waiters)
+			}
+		}
+		if pq.served > 0 {
+			s.AvgWaitMs = float64(pq.waitTotal.Milliseconds()) / float64(pq.served)
+		}
+		if now.Before(pq.cooldownUntil) {
+			s.CooldownRemaining = pq.cooldownUntil.Sub(now).Seconds()
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// QueuedModel 经过请求队列调用的模型
+type QueuedModel struct {
+	Model
+	queue *RequestQueue
+}
+
+// NewQueuedModel 创建经过请求队列调用的模型
+func NewQueuedModel(model Model, queue *RequestQueue) *QueuedModel {
+	return &QueuedModel{
+		Model: model,
+		queue: queue,
+	}
+}
+
+// Chat 排队后调用底层模型
+func (m *QueuedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	var response string
+	err := m.queue.Do(ctx, m.GetProviderName(), func(ctx context.Context) error {
+		var err error
+		response, err = m.Model.Chat(ctx, messages)
+		return err
+	})
+	return response, err
+}
+
+// ChatStream 排队后调用底层模型，槽位在流结束后释放
+// 调用方提前停止读取时仍会读完底层流，避免模型实现的发送协程阻塞
+func (m *QueuedModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	var stream <-chan string
+	release, err := m.queue.run(ctx, m.GetProviderName(), func(ctx context.Context) error {
+		var err error
+		stream, err = m.Model.ChatStream(ctx, messages)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer release()
+
+		for chunk := range stream {
+			if ctx.Err() != nil {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Embed 排队后调用底层模型的向量化接口
+func (m *QueuedModel) Embed(ctx context.Context, text string) ([]float64, error) {
+	var embedding []float64
+	err := m.queue.Do(ctx, m.GetProviderName(), func(ctx context.Context) error {
+		var err error
+		embedding, err = m.Model.Embed(ctx, text)
+		return err
+	})
+	return embedding, err
+}
+
+// Unwrap 获取底层模型
+func (m *QueuedModel) Unwrap() Model {
+	return m.Model
+}
@@ -0,0 +1,425 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
+)
+
+// CacheMode 缓存匹配模式
+type CacheMode string
+
+const (
+	CacheModeOff      CacheMode = "off"      // 不使用缓存
+	CacheModeExact    CacheMode = "exact"    // 精确匹配（模型 + 消息哈希）
+	CacheModeSemantic CacheMode = "semantic" // 语义匹配（先精确，再按向量相似度）
+)
+
+// cacheRouteKey 上下文中缓存路由的键
+type cacheRouteKey struct{}
+
+// WithCacheRoute 在上下文中标记调用所属的路由
+// 只有在配置中为该路由开启了缓存，调用才会走缓存
+func WithCacheRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, cacheRouteKey{}, route)
+}
+
+// CacheRouteFromContext 从上下文中读取缓存路由
+func CacheRouteFromContext(ctx context.Context) string {
+	if route, ok := ctx.Value(cacheRouteKey{}).(string); ok {
+		return route
+	}
+	return ""
+}
+
+// ResponseCacheConfig 响应缓存配置
+type ResponseCacheConfig struct {
+	TTL               time.Duration        // 缓存有效期
+	MaxEntries        int                  // 最大条目数
+	SemanticThreshold float64              // 语义匹配阈值
+	Routes            map[string]CacheMode // 路由 -> 缓存模式
+	Embedder          Model                // 语义匹配使用的向量化模型（可选）
+}
+
+// cacheEntry 缓存条目
+type cacheEntry struct {
+	key       string
+	model     string
+	route     string
+	options   string // 生成参数，语义匹配只在生成参数相同的条目中查找
+	response  string
+	vector    []float64
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+// ResponseCache LLM响应缓存
+// 以 (模型, 消息哈希) 为键；semantic模式下额外比较提示词向量，
+// 适用于分类、抽取等确定性提示词
+type ResponseCache struct {
+	mu           sync.RWMutex
+	entries      map[string]*cacheEntry
+	ttl          time.Duration
+	maxEntries   int
+	threshold    float64
+	routes       map[string]CacheMode
+	embedder     Model
+	hits         int64
+	misses       int64
+	semanticHits int64
+}
+
+// NewResponseCache 创建响应缓存
+func NewResponseCache(cfg ResponseCacheConfig) *ResponseCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1000
+	}
+	if cfg.SemanticThreshold <= 0 {
+		cfg.SemanticThreshold = 0.95
+	}
+	routes := make(map[string]CacheMode, len(cfg.Routes))
+	for route, mode := range cfg.Routes {
+		routes[route] = mode
+	}
+
+	return &ResponseCache{
+		entries:    make(map[string]*cacheEntry),
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		threshold:  cfg.SemanticThreshold,
+		routes:     routes,
+		embedder:   cfg.Embedder,
+	}
+}
+
+// NewResponseCacheFromConfig 根据应用配置创建响应缓存
+func NewResponseCacheFromConfig(cfg config.LLMCacheConfig) *ResponseCache {
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil {
+		ttl = 0
+	}
+
+	routes := make(map[string]CacheMode, len(cfg.Routes))
+	for route, mode := range cfg.Routes {
+		routes[route] = CacheMode(strings.ToLower(mode))
+	}
+
+	return NewResponseCache(ResponseCacheConfig{
+		TTL:               ttl,
+		MaxEntries:        cfg.MaxEntries,
+		SemanticThreshold: cfg.SemanticThreshold,
+		Routes:            routes,
+	})
+}
+
+// SetEmbedder 设置语义匹配使用的向量化模型
+func (c *ResponseCache) SetEmbedder(embedder Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.embedder = embedder
+}
+
+// SetRouteMode 设置路由的缓存模式
+func (c *ResponseCache) SetRouteMode(route string, mode CacheMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes[route] = mode
+}
+
+// ModeFor 获取路由的缓存模式，未配置的路由不使用缓存
+func (c *ResponseCache) ModeFor(route string) CacheMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mode, ok := c.routes[route]
+	if !ok || route == "" {
+		return CacheModeOff
+	}
+	if mode == CacheModeSemantic && c.embedder == nil {
+		// 没有向量化模型时退化为精确匹配
+		return CacheModeExact
+	}
+	return mode
+}
+
+// Lookup 查找缓存响应
+// semantic模式下未命中时同时返回提示词向量，传给Store避免对同一提示词重复向量化
+func (c *ResponseCache) Lookup(ctx context.Context, model string, messages []models.Message) (string, []float64, bool) {
+	route := CacheRouteFromContext(ctx)
+	mode := c.ModeFor(route)
+	if mode == CacheModeOff {
+		return "", nil, false
+	}
+	// 多租户时缓存按租户隔离，不同租户不会命中彼此的响应
+	model = tenant.Scope(ctx, model)
+
+	key := hashMessages(model, messages, GenerationOptionsFromContext(ctx))
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		c.recordHit(false)
+		return entry.response, nil, true
+	}
+
+	var vector []float64
+	if mode == CacheModeSemantic {
+		if v, err := c.embed(ctx, messages); err == nil {
+			vector = v
+			if response, found := c.findSimilar(model, route, optionsKey(GenerationOptionsFromContext(ctx)), vector, now); found {
+				c.recordHit(true)
+				return response, nil, true
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+	return "", vector, false
+}
+
+// Store 写入缓存响应
+// vector为Lookup返回的提示词向量，semantic模式下为nil时重新向量化
+func (c *ResponseCache) Store(ctx context.Context, model string, messages []models.Message, vector []float64, response string) {
+	route := CacheRouteFromContext(ctx)
+	mode := c.ModeFor(route)
+	if mode == CacheModeOff || response == "" {
+		return
+	}
+	model = tenant.Scope(ctx, model)
+
+	opts := GenerationOptionsFromContext(ctx)
+	entry := &cacheEntry{
+		key:       hashMessages(model, messages, opts),
+		model:     model,
+		route:     route,
+		options:   optionsKey(opts),
+		response:  response,
+		createdAt: time.Now(),
+	}
+	entry.expiresAt = entry.createdAt.Add(c.ttl)
+
+	if mode == CacheModeSemantic {
+		if vector == nil {
+			vector, _ = c.embed(ctx, messages)
+		}
+		entry.vector = vector
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.key] = entry
+	c.evictLocked()
+}
+
+// Purge 清理过期条目
+func (c *ResponseCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clear 清空缓存
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cacheEntry)
+}
+
+// Stats 获取缓存统计
+func (c *ResponseCache) Stats() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	routes := make(map[string]string, len(c.routes))
+	for route, mode := range c.routes {
+		routes[route] = string(mode)
+	}
+
+	total := c.hits + c.misses
+	hitRate := 0.0
+	if total > 0 {
+		hitRate = float64(c.hits) / float64(total)
+	}
+
+	return map[string]interface{}{
+		"entries":       len(c.entries),
+		"hits":          c.hits,
+		"semantic_hits": c.semanticHits,
+		"misses":        c.misses,
+		"hit_rate":      hitRate,
+		"ttl_seconds":   c.ttl.Seconds(),
+		"routes":        routes,
+	}
+}
+
+// recordHit 记录命中
+func (c *ResponseCache) recordHit(semantic bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits++
+	if semantic {
+		c.semanticHits++
+	}
+}
+
+// findSimilar 在同一模型、同一路由、相同生成参数的条目中查找相似提示词
+func (c *ResponseCache) findSimilar(model, route, options string, vector []float64, now time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	bestScore := 0.0
+	bestResponse := ""
+	for _, entry := range c.entries {
+		if entry.model != model || entry.route != route || entry.options != options || entry.vector == nil {
+			continue
+		}
+		if !now.Before(entry.expiresAt) {
+			continue
+		}
+		score := cosineSimilarity(vector, entry.vector)
+		if score >= c.threshold && score > bestScore {
+			bestScore = score
+			bestResponse = entry.response
+		}
+	}
+
+	return bestResponse, bestResponse != ""
+}
+
+// evictLocked 超出容量时淘汰最早写入的条目（调用方需持有写锁）
+func (c *ResponseCache) evictLocked() {
+	for len(c.entries) > c.maxEntries {
+		var oldest *cacheEntry
+		for _, entry := range c.entries {
+			if oldest == nil || entry.createdAt.Before(oldest.createdAt) {
+				oldest = entry
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(c.entries, oldest.key)
+	}
+}
+
+// embed 对提示词做向量化
+func (c *ResponseCache) embed(ctx context.Context, messages []models.Message) ([]float64, error) {
+	c.mu.RLock()
+	embedder := c.embedder
+	c.mu.RUnlock()
+
+	return embedder.Embed(ctx, promptText(messages))
+}
+
+// hashMessages 计算 (模型, 消息, 生成参数) 的哈希
+func hashMessages(model string, messages []models.Message, opts *GenerationOptions) string {
+	data, _ := json.Marshal(struct {
+		Model    string             `json:"model"`
+		Messages []models.Message   `json:"messages"`
+		Options  *GenerationOptions `json:"options,omitempty"`
+	}{model, messages, opts})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// optionsKey 生成参数的规范化表示，未设置时为空字符串
+func optionsKey(opts *GenerationOptions) string {
+	if opts.IsZero() {
+		return ""
+	}
+	data, _ := json.Marshal(opts)
+	return string(data)
+}
+
+// promptText 将消息拼接为用于向量化的文本
+func promptText(messages []models.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		sb.WriteString(msg.Role)
+		sb.WriteString(": ")
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// cosineSimilarity 余弦相似度
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// CachedModel 带响应缓存的模型包装
+type CachedModel struct {
+	Model
+	cache *ResponseCache
+}
+
+// NewCachedModel 创建带缓存的模型
+func NewCachedModel(model Model, cache *ResponseCache) *CachedModel {
+	return &CachedModel{
+		Model: model,
+		cache: cache,
+	}
+}
+
+// Chat 先查缓存，未命中时调用底层模型并写入缓存
+func (m *CachedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	modelName := m.Model.GetModelName()
+	response, vector, ok := m.cache.Lookup(ctx, modelName, messages)
+	if ok {
+		return response, nil
+	}
+
+	response, err := m.Model.Chat(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	m.cache.Store(ctx, modelName, messages, vector, response)
+	return response, nil
+}
+
+// Unwrap 获取底层模型
+func (m *CachedModel) Unwrap() Model {
+	return m.Model
+}
@@ -0,0 +1,317 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 模型档位
+const (
+	TierCheap    = "cheap"
+	TierStandard = "standard"
+	TierStrong   = "strong"
+)
+
+// ModelProfile 候选模型档案
+type ModelProfile struct {
+	Name         string  `json:"name"`
+	Tier         string  `json:"tier"`
+	AvgLatencyMs int     `json:"avg_latency_ms"`
+	CostPer1K    float64 `json:"cost_per_1k"`
+}
+
+// RoutingRule 路由规则
+// TaskType为空表示匹配所有任务类型；MaxComplexity为0表示不限上限
+type RoutingRule struct {
+	TaskType      string  `json:"task_type"`
+	MinComplexity float64 `json:"min_complexity"`
+	MaxComplexity float64 `json:"max_complexity"`
+	Model         string  `json:"model"`
+}
+
+// matches 判断规则是否匹配
+func (r RoutingRule) matches(taskType string, complexity float64) bool {
+	if r.TaskType != "" && r.TaskType != taskType {
+		return false
+	}
+	if complexity < r.MinComplexity {
+		return false
+	}
+	if r.MaxComplexity > 0 && complexity > r.MaxComplexity {
+		return false
+	}
+	return true
+}
+
+// PipelinePolicy 流水线路由策略
+type PipelinePolicy struct {
+	Rules               []RoutingRule `json:"rules"`
+	ComplexityThreshold float64       `json:"complexity_threshold"`
+	LatencyBudgetMs     int           `json:"latency_budget_ms"`
+	CostCeiling         float64       `json:"cost_ceiling"`
+}
+
+// RouteRequest 路由请求
+type RouteRequest struct {
+	Pipeline        string  // 流水线名称，如 chat, chat_rag
+	TaskType        string  // 任务类型，如 query_condense, final_answer
+	Prompt          string  // 用于估算复杂度和token数的提示词
+	Complexity      float64 // 调用方给出的复杂度（0-1），为0时自动估算
+	LatencyBudgetMs int     // 延迟预算，覆盖流水线配置
+	CostCeiling     float64 // 费用上限，覆盖流水线配置
+}
+
+// RouteDecision 路由决策
+type RouteDecision struct {
+	Model      string  `json:"model"`
+	Tier       string  `json:"tier"`
+	Complexity float64 `json:"complexity"`
+	Reason     string  `json:"reason"`
+}
+
+// ModelRouter 模型路由器
+// 根据任务类型、复杂度、延迟预算和费用上限在便宜/昂贵模型之间选择
+type ModelRouter struct {
+	defaultModel string
+	profiles     map[string]ModelProfile
+	pipelines    map[string]PipelinePolicy
+}
+
+// NewModelRouter 创建模型路由器
+func NewModelRouter(defaultModel string, profiles []ModelProfile) *ModelRouter {
+	router := &ModelRouter{
+		defaultModel: defaultModel,
+		profiles:     make(map[string]ModelProfile),
+		pipelines:    make(map[string]PipelinePolicy),
+	}
+	for _, profile := range profiles {
+		router.profiles[profile.Name] = profile
+	}
+	return router
+}
+
+// NewModelRouterFromConfig 根据应用配置创建模型路由器
+func NewModelRouterFromConfig(cfg config.ModelRoutingConfig) *ModelRouter {
+	profiles := make([]ModelProfile, 0, len(cfg.Models))
+	for name, profile := range cfg.Models {
+		profiles = append(profiles, ModelProfile{
+			Name:         name,
+			Tier:         profile.Tier,
+			AvgLatencyMs: profile.AvgLatencyMs,
+			CostPer1K:    profile.CostPer1K,
+		})
+	}
+
+	router := NewModelRouter(cfg.DefaultModel, profiles)
+	for name, pipeline := range cfg.Pipelines {
+		rules := make([]RoutingRule, 0, len(pipeline.Rules))
+		for _, rule := range pipeline.Rules {
+			rules = append(rules, RoutingRule{
+				TaskType:      rule.TaskType,
+				MinComplexity: rule.MinComplexity,
+				MaxComplexity: rule.MaxComplexity,
+				Model:         rule.Model,
+			})
+		}
+		router.SetPipelinePolicy(name, PipelinePolicy{
+			Rules:               rules,
+			ComplexityThreshold: pipeline.ComplexityThreshold,
+			LatencyBudgetMs:     pipeline.LatencyBudgetMs,
+			CostCeiling:         pipeline.CostCeiling,
+		})
+	}
+
+	return router
+}
+
+// SetPipelinePolicy 设置流水线路由策略
+func (r *ModelRouter) SetPipelinePolicy(pipeline string, policy PipelinePolicy) {
+	r.pipelines[pipeline] = policy
+}
+
+// Route 为请求选择模型
+func (r *ModelRouter) Route(req RouteRequest) RouteDecision {
+	complexity := req.Complexity
+	if complexity <= 0 {
+		complexity = EstimateComplexity(req.Prompt)
+	}
+
+	policy := r.pipelines[req.Pipeline]
+	latencyBudget := policy.LatencyBudgetMs
+	if req.LatencyBudgetMs > 0 {
+		latencyBudget = req.LatencyBudgetMs
+	}
+	costCeiling := policy.CostCeiling
+	if req.CostCeiling > 0 {
+		costCeiling = req.CostCeiling
+	}
+	estimatedTokens := EstimateTokens(req.Prompt)
+
+	// 1. 按规则顺序匹配
+	candidate := ""
+	reason := ""
+	for _, rule := range policy.Rules {
+		if rule.matches(req.TaskType, complexity) {
+			candidate = rule.Model
+			reason = fmt.Sprintf("matched rule task_type=%q", rule.TaskType)
+			break
+		}
+	}
+
+	// 2. 没有规则命中时按复杂度选择档位
+	if candidate == "" {
+		threshold := policy.ComplexityThreshold
+		if threshold <= 0 {
+			threshold = 0.6
+		}
+		tier := TierCheap
+		if complexity >= threshold {
+			tier = TierStrong
+		}
+		candidate = r.pickByTier(tier)
+		reason = fmt.Sprintf("complexity %.2f -> %s tier", complexity, tier)
+	}
+
+	if candidate == "" {
+		candidate = r.defaultModel
+		reason = "no candidate, using default model"
+	}
+
+	// 3. 校验延迟预算和费用上限，不满足时降级到满足约束的最便宜模型
+	if !r.withinLimits(candidate, latencyBudget, costCeiling, estimatedTokens) {
+		if fallback := r.cheapestWithin(latencyBudget, costCeiling, estimatedTokens); fallback != "" {
+			reason = fmt.Sprintf("%s; %s exceeds budget, downgraded", reason, candidate)
+			candidate = fallback
+		} else if cheapest := r.cheapestWithin(0, 0, 0); cheapest != "" {
+			reason = fmt.Sprintf("%s; no model within budget, using cheapest", reason)
+			candidate = cheapest
+		}
+	}
+
+	return RouteDecision{
+		Model:      candidate,
+		Tier:       r.profiles[candidate].Tier,
+		Complexity: complexity,
+		Reason:     reason,
+	}
+}
+
+// pickByTier 选择指定档位的模型，找不到时取最接近的档位
+func (r *ModelRouter) pickByTier(tier string) string {
+	profiles := r.sortedProfiles()
+	if len(profiles) == 0 {
+		return ""
+	}
+
+	for _, profile := range profiles {
+		if profile.Tier == tier {
+			return profile.Name
+		}
+	}
+
+	// 强模型取最贵的，便宜模型取最便宜的
+	if tier == TierStrong {
+		return profiles[len(profiles)-1].Name
+	}
+	return profiles[0].Name
+}
+
+// withinLimits 判断模型是否满足延迟和费用约束（0表示不限）
+func (r *ModelRouter) withinLimits(model string, latencyBudget int, costCeiling float64, tokens int) bool {
+	profile, ok := r.profiles[model]
+	if !ok {
+		// 未登记档案的模型不做约束
+		return true
+	}
+	if latencyBudget > 0 && profile.AvgLatencyMs > latencyBudget {
+		return false
+	}
+	if costCeiling > 0 && float64(tokens)/1000*profile.CostPer1K > costCeiling {
+		return false
+	}
+	return true
+}
+
+// cheapestWithin 满足约束的最便宜模型
+func (r *ModelRouter) cheapestWithin(latencyBudget int, costCeiling float64, tokens int) string {
+	for _, profile := range r.sortedProfiles() {
+		if r.withinLimits(profile.Name, latencyBudget, costCeiling, tokens) {
+			return profile.Name
+		}
+	}
+	return ""
+}
+
+// sortedProfiles 按价格从低到高排序的档案
+func (r *ModelRouter) sortedProfiles() []ModelProfile {
+	profiles := make([]ModelProfile, 0, len(r.profiles))
+	for _, profile := range r.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].CostPer1K == profiles[j].CostPer1K {
+			return profiles[i].Name < profiles[j].Name
+		}
+		return profiles[i].CostPer1K < profiles[j].CostPer1K
+	})
+	return profiles
+}
+
+// EstimateTokens 粗略估算token数（中文按字、英文按4字符计）
+func EstimateTokens(text string) int {
+	runes := utf8.RuneCountInString(text)
+	ascii := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	return (runes - ascii) + ascii/4
+}
+
+// EstimateComplexity 启发式估算提示词复杂度（0-1）
+// 综合长度、多问题、推理/比较类关键词和代码片段
+func EstimateComplexity(prompt string) float64 {
+	if prompt == "" {
+		return 0
+	}
+
+	score := 0.0
+	tokens := EstimateTokens(prompt)
+	switch {
+	case tokens > 2000:
+		score += 0.4
+	case tokens > 500:
+		score += 0.25
+	case tokens > 100:
+		score += 0.1
+	}
+
+	questions := strings.Count(prompt, "?") + strings.Count(prompt, "？")
+	if questions > 1 {
+		score += 0.15
+	}
+
+	lower := strings.ToLower(prompt)
+	keywords := []string{"为什么", "分析", "比较", "推理", "证明", "设计", "方案", "步骤",
+		"why", "analyze", "compare", "explain", "prove", "design", "step by step"}
+	for _, kw := range keywords {
+		if strings.Contains(lower, kw) {
+			score += 0.2
+			break
+		}
+	}
+
+	if strings.Contains(prompt, "```") || strings.Contains(prompt, "func ") {
+		score += 0.2
+	}
+
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
@@ -0,0 +1,433 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
+)
+
+// ModelPrice 模型价格（每1000个token）
+type ModelPrice struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+// UsageRecord 单次调用的用量记录
+type UsageRecord struct {
+	Model            string    `json:"model"`
+	Provider         string    `json:"provider"`
+	Route            string    `json:"route,omitempty"`
+	Tenant           string    `json:"tenant,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// UsageSummary 用量汇总
+type UsageSummary struct {
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add 累加一条记录
+func (s *UsageSummary) add(rec UsageRecord) {
+	s.Calls++
+	s.PromptTokens += int64(rec.PromptTokens)
+	s.CompletionTokens += int64(rec.CompletionTokens)
+	s.TotalTokens += int64(rec.TotalTokens)
+	s.Cost += rec.Cost
+}
+
+// UsageTracker 全局用量统计
+// 按模型、路由和租户汇总token和费用，价格来自配置中的价格表
+type UsageTracker struct {
+	mu        sync.RWMutex
+	pricing   map[string]ModelPrice
+	currency  string
+	total     UsageSummary
+	byModel   map[string]*UsageSummary
+	byRoute   map[string]*UsageSummary
+	byTenant  map[string]*UsageSummary
+	recent    []UsageRecord
+	maxRecent int
+	startedAt time.Time
+}
+
+// NewUsageTracker 创建用量统计器
+func NewUsageTracker(pricing map[string]ModelPrice, currency string) *UsageTracker {
+	if pricing == nil {
+		pricing = make(map[string]ModelPrice)
+	}
+	if currency == "" {
+		currency = "CNY"
+	}
+
+	return &UsageTracker{
+		pricing:   pricing,
+		currency:  currency,
+		byModel:   make(map[string]*UsageSummary),
+		byRoute:   make(map[string]*UsageSummary),
+		byTenant:  make(map[string]*UsageSummary),
+		recent:    make([]UsageRecord, 0),
+		maxRecent: 1000,
+		startedAt: time.Now(),
+	}
+}
+
+// NewUsageTrackerFromConfig 根据应用配置创建用量统计器
+func NewUsageTrackerFromConfig(cfg config.UsageConfig) *UsageTracker {
+	pricing := make(map[string]ModelPrice, len(cfg.Pricing))
+	for model, price := range cfg.Pricing {
+		pricing[model] = ModelPrice{
+			PromptPer1K:     price.PromptPer1K,
+			CompletionPer1K: price.CompletionPer1K,
+		}
+	}
+	return NewUsageTracker(pricing, cfg.Currency)
+}
+
+// Cost 根据价格表计算费用，未配置价格的模型费用为0
+func (t *UsageTracker) Cost(model string, promptTokens, completionTokens int) float64 {
+	t.mu.RLock()
+	price, ok := t.pricing[model]
+	t.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	return float64(promptTokens)/1000*price.PromptPer1K +
+		float64(completionTokens)/1000*price.CompletionPer1K
+}
+
+// Record 记录一次调用
+func (t *UsageTracker) Record(rec UsageRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.add(rec)
+
+	if _, ok := t.byModel[rec.Model]; !ok {
+		t.byModel[rec.Model] = &UsageSummary{}
+	}
+	t.byModel[rec.Model].add(rec)
+
+	route := rec.Route
+	if route == "" {
+		route = "default"
+	}
+	if _, ok := t.byRoute[route]; !ok {
+		t.byRoute[route] = &UsageSummary{}
+	}
+	t.byRoute[route].add(rec)
+
+	if rec.Tenant != "" {
+		if _, ok := t.byTenant[rec.Tenant]; !ok {
+			t.byTenant[rec.Tenant] = &UsageSummary{}
+		}
+		t.byTenant[rec.Tenant].add(rec)
+	}
+
+	t.recent = append(t.recent, rec)
+	if len(t.recent) > t.maxRecent {
+		t.recent = t.recent[len(t.recent)-t.maxRecent:]
+	}
+}
+
+// Summary 获取汇总报告
+func (t *UsageTracker) Summary() map[string]interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	byModel := make(map[string]UsageSummary, len(t.byModel))
+	for model, summary := range t.byModel {
+		byModel[model] = *summary
+	}
+	byRoute := make(map[string]UsageSummary, len(t.byRoute))
+	for route, summary := range t.byRoute {
+		byRoute[route] = *summary
+	}
+	byTenant := make(map[string]UsageSummary, len(t.byTenant))
+	for tenant, summary := range t.byTenant {
+		byTenant[tenant] = *summary
+	}
+
+	return map[string]interface{}{
+		"currency":   t.currency,
+		"since":      t.startedAt,
+		"total":      t.total,
+		"by_model":   byModel,
+		"by_route":   byRoute,
+		"by_tenant":  byTenant,
+		"recent_len": len(t.recent),
+	}
+}
+
+// TenantSummary 单个租户的汇总报告，多租户部署中只向租户展示自己的用量
+func (t *UsageTracker) TenantSummary(tenant string) map[string]interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var total UsageSummary
+	if summary, ok := t.byTenant[tenant]; ok {
+		total = *summary
+	}
+	byModel := make(map[string]UsageSummary)
+	recentLen := 0
+	for _, rec := range t.recent {
+		if rec.Tenant != tenant {
+			continue
+		}
+		summary := byModel[rec.Model]
+		summary.add(rec)
+		byModel[rec.Model] = summary
+		recentLen++
+	}
+
+	return map[string]interface{}{
+		"currency":   t.currency,
+		"since":      t.startedAt,
+		"tenant":     tenant,
+		"total":      total,
+		"by_model":   byModel, // 按保留的最近记录统计
+		"recent_len": recentLen,
+	}
+}
+
+// Recent 获取最近的调用记录
+func (t *UsageTracker) Recent(limit int) []UsageRecord {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if limit <= 0 || limit > len(t.recent) {
+		limit = len(t.recent)
+	}
+	result := make([]UsageRecord, limit)
+	copy(result, t.recent[len(t.recent)-limit:])
+	return result
+}
+
+// RecentFor 获取租户最近的调用记录
+func (t *UsageTracker) RecentFor(tenant string, limit int) []UsageRecord {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]UsageRecord, 0)
+	for i := len(t.recent) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if t.recent[i].Tenant == tenant {
+			result = append(result, t.recent[i])
+		}
+	}
+	// 与Recent一致，按时间从早到晚
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Reset 重置统计
+func (t *UsageTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total = UsageSummary{}
+	t.byModel = make(map[string]*UsageSummary)
+	t.byRoute = make(map[string]*UsageSummary)
+	t.byTenant = make(map[string]*UsageSummary)
+	t.recent = make([]UsageRecord, 0)
+	t.startedAt = time.Now()
+}
+
+// UsageCollector 单次请求的用量收集器
+// 通过上下文传递，模型调用时把用量写入收集器；
+// 嵌套的收集器会把记录同时累加到外层，便于附加到RAGResult/TaskResult元数据
+type UsageCollector struct {
+	mu      sync.Mutex
+	tracker *UsageTracker
+	parent  *UsageCollector
+	route   string
+	records []UsageRecord
+}
+
+// NewUsageCollector 创建用量收集器，tracker可以为nil（只收集不汇总）
+func NewUsageCollector(tracker *UsageTracker, route string) *UsageCollector {
+	return &UsageCollector{
+		tracker: tracker,
+		route:   route,
+		records: make([]UsageRecord, 0),
+	}
+}
+
+// Add 添加一条用量记录
+func (c *UsageCollector) Add(rec UsageRecord) {
+	if rec.Route == "" {
+		rec.Route = c.route
+	}
+	if c.tracker != nil {
+		if rec.Cost == 0 {
+			rec.Cost = c.tracker.Cost(rec.Model, rec.PromptTokens, rec.CompletionTokens)
+		}
+		c.tracker.Record(rec)
+	}
+
+	for col := c; col != nil; col = col.parent {
+		col.mu.Lock()
+		col.records = append(col.records, rec)
+		col.mu.Unlock()
+	}
+}
+
+// Records 获取收集到的记录
+func (c *UsageCollector) Records() []UsageRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]UsageRecord, len(c.records))
+	copy(result, c.records)
+	return result
+}
+
+// Totals 获取收集到的用量合计
+func (c *UsageCollector) Totals() UsageSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var summary UsageSummary
+	for _, rec := range c.records {
+		summary.add(rec)
+	}
+	return summary
+}
+
+// Metadata 以元数据形式输出用量，供结果对象附加
+func (c *UsageCollector) Metadata() map[string]interface{} {
+	totals := c.Totals()
+	return map[string]interface{}{
+		"calls":             totals.Calls,
+		"prompt_tokens":     totals.PromptTokens,
+		"completion_tokens": totals.CompletionTokens,
+		"total_tokens":      totals.TotalTokens,
+		"cost":              totals.Cost,
+	}
+}
+
+// usageCollectorKey 上下文中用量收集器的键
+type usageCollectorKey struct{}
+
+// WithUsageCollector 把用量收集器放入上下文
+// 如果上下文中已有收集器，新收集器会把记录同时累加到外层
+func WithUsageCollector(ctx context.Context, collector *UsageCollector) context.Context {
+	if parent := UsageCollectorFromContext(ctx); parent != nil && parent != collector {
+		collector.parent = parent
+	}
+	return context.WithValue(ctx, usageCollectorKey{}, collector)
+}
+
+// UsageCollectorFromContext 从上下文获取用量收集器
+func UsageCollectorFromContext(ctx context.Context) *UsageCollector {
+	if collector, ok := ctx.Value(usageCollectorKey{}).(*UsageCollector); ok {
+		return collector
+	}
+	return nil
+}
+
+// reportUsage 供各模型实现在解析响应后上报用量
+func reportUsage(ctx context.Context, model, provider string, usage *Usage) {
+	if usage == nil {
+		return
+	}
+	collector := UsageCollectorFromContext(ctx)
+	if collector == nil {
+		return
+	}
+
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	collector.Add(UsageRecord{
+		Model:            model,
+		Provider:         provider,
+		Tenant:           tenant.FromContext(ctx),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      total,
+		Timestamp:        time.Now(),
+	})
+}
+
+// UsageTrackedModel 带用量统计的模型包装
+// 每次调用创建一个挂在全局统计器上的收集器，底层模型上报的用量会进入全局汇总；
+// tracker为nil时只上报到请求上下文中的收集器
+type UsageTrackedModel struct {
+	Model
+	tracker *UsageTracker
+}
+
+// NewUsageTrackedModel 创建带用量统计的模型
+func NewUsageTrackedModel(model Model, tracker *UsageTracker) *UsageTrackedModel {
+	return &UsageTrackedModel{
+		Model:   model,
+		tracker: tracker,
+	}
+}
+
+// Chat 调用底层模型并统计用量
+func (m *UsageTrackedModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	collector := NewUsageCollector(m.tracker, CacheRouteFromContext(ctx))
+	return m.Model.Chat(WithUsageCollector(ctx, collector), messages)
+}
+
+// ChatStream 流式调用底层模型，流结束后按估算的token数统计用量（流式接口不返回用量）
+// 调用方提前停止读取时仍会读完底层流，避免模型实现的发送协程阻塞
+func (m *UsageTrackedModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	collector := NewUsageCollector(m.tracker, CacheRouteFromContext(ctx))
+	ctx = WithUsageCollector(ctx, collector)
+
+	stream, err := m.Model.ChatStream(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+
+		var completion strings.Builder
+		for chunk := range stream {
+			completion.WriteString(chunk)
+			if ctx.Err() != nil {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		}
+
+		promptTokens := EstimateMessagesTokens(messages)
+		completionTokens := EstimateTokens(completion.String())
+		reportUsage(ctx, m.GetModelName(), m.GetProviderName(), &Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		})
+	}()
+
+	return out, nil
+}
+
+// Unwrap 获取底层模型
+func (m *UsageTrackedModel) Unwrap() Model {
+	return m.Model
+}
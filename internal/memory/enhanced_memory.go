@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/llm"
 	"ai-agent-assistant/pkg/models"
 )
@@ -24,6 +25,8 @@ type UserMemory struct {
 	UpdatedAt time.Time              `json:"updated_at"`
 	AccessedAt time.Time              `json:"accessed_at"` // 最后访问时间
 	AccessCount int                   `json:"access_count"` // 访问次数
+	Scope     MemoryScope            `json:"scope,omitempty"`   // 作用域，空表示用户作用域
+	TeamID    string                 `json:"team_id,omitempty"` // 团队作用域所属团队
 	Vector    []float64              `json:"vector"`       // 用于语义检索
 	mu        sync.RWMutex
 }
@@ -36,6 +39,12 @@ type EnhancedMemoryManager struct {
 	enableAutoExtract bool
 	enableSemanticSearch bool
 	optimizationStrategy string // "summarization", "time_decay", "importance"
+	store           UserMemoryStore  // 长期存储（可选）
+	loaded          map[string]bool  // 已从存储加载的用户
+	decayHalfLife   time.Duration    // 重要性衰减半衰期
+	minImportance   float64          // 衰减后低于此值的记忆被清理
+	consolidateThreshold float64     // 相似度不低于此值的记忆合并
+	acl             *MemoryACL       // 共享记忆访问控制
 }
 
 // NewEnhancedMemoryManager 创建增强版记忆管理器
@@ -46,6 +55,11 @@ func NewEnhancedMemoryManager(embeddingModel llm.Model) *EnhancedMemoryManager {
 		enableAutoExtract:   embeddingModel != nil,
 		enableSemanticSearch: embeddingModel != nil,
 		optimizationStrategy: "importance", // 默认重要性优化
+		loaded:              make(map[string]bool),
+		decayHalfLife:       720 * time.Hour, // 30天半衰期
+		minImportance:       0.05,
+		consolidateThreshold: 0.85,
+		acl:                 NewMemoryACL(config.MemoryScopeConfig{}),
 	}
 }
 
@@ -216,13 +230,18 @@ func (m *EnhancedMemoryManager) AddMemory(ctx context.Context, memory *UserMemor
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	owner := memoryOwnerKey(memory)
+	if err := m.loadLocked(ctx, owner); err != nil {
+		return err
+	}
+
 	// 检查是否需要去重
-	memories := m.memories[memory.UserID]
+	memories := m.memories[owner]
 	for _, existing := range memories {
 		if similarity := cosineSimilarity(memory.Vector, existing.Vector); similarity > 0.9 {
 			// 相似度过高，合并记忆
 			m.mergeMemories(existing, memory)
-			return nil
+			return m.persistLocked(ctx, owner)
 		}
 	}
 
@@ -231,9 +250,9 @@ func (m *EnhancedMemoryManager) AddMemory(ctx context.Context, memory *UserMemor
 	memory.UpdatedAt = time.Now()
 	memory.AccessedAt = time.Now()
 
-	m.memories[memory.UserID] = append(memories, memory)
+	m.memories[owner] = append(memories, memory)
 
-	return nil
+	return m.persistLocked(ctx, owner)
 }
 
 // mergeMemories 合并相似记忆
@@ -276,6 +295,10 @@ func (m *EnhancedMemoryManager) SemanticSearch(ctx context.Context, userID strin
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
+	if err := m.ensureLoaded(ctx, userID); err != nil {
+		return nil, err
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -323,6 +346,8 @@ func (m *EnhancedMemoryManager) SemanticSearch(ctx context.Context, userID strin
 
 // GetMemories 获取用户记忆（带优化）
 func (m *EnhancedMemoryManager) GetMemories(userID string, limit int) []*UserMemory {
+	_ = m.ensureLoaded(context.Background(), userID)
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -364,12 +389,9 @@ func (m *EnhancedMemoryManager) timeDecayOptimization(memories []*UserMemory) []
 	scores := make([]memoryScore, len(memories))
 	for i, memory := range memories {
 		// 计算时间衰减得分
-		hoursPassed := now.Sub(memory.AccessedAt).Hours()
-		decayScore := memory.Importance * math.Exp(-hoursPassed/720) // 30天半衰期
-
 		scores[i] = memoryScore{
 			memory: memory,
-			score:  decayScore,
+			score:  m.decayedImportance(memory, now),
 		}
 	}
 
@@ -432,16 +454,22 @@ func (m *EnhancedMemoryManager) summarizationOptimization(memories []*UserMemory
 
 // OptimizeMemories 手动触发优化
 func (m *EnhancedMemoryManager) OptimizeMemories(userID string) error {
+	ctx := context.Background()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return err
+	}
+
 	memories := m.memories[userID]
 	optimized := m.optimizeMemories(memories)
 
 	// 删除重复或低质量的记忆
 	m.memories[userID] = optimized
 
-	return nil
+	return m.persistLocked(ctx, userID)
 }
 
 // SetOptimizationStrategy 设置优化策略
@@ -2,11 +2,13 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/llm"
 	"ai-agent-assistant/pkg/models"
 )
@@ -19,7 +21,16 @@ type EnhancedSessionManager struct {
 	enableAutoSummary bool
 	summaryModel    llm.Model
 	summaryThreshold int // 超过此消息数时自动摘要
-	storeType       string // "memory", "mysql", "redis"
+	summaryTokenThreshold int // 超过此token数时自动摘要，0表示不按token触发
+	summaryPrompt   string // 摘要提示词模板，为空时使用内置提示词
+	promptMu        sync.RWMutex // 保护summaryPrompt，配置热加载时会替换模板
+	maxSummaryVersions int // 每个会话保留的摘要版本数
+	storeType       string // "memory", "redis", "postgres"
+	store           SessionStore  // 持久化存储（可选），sessions作为其热缓存
+	sessionTTL      time.Duration // 会话空闲过期时间，0表示不过期
+	eviction        EvictionStats // 空闲会话淘汰统计
+	tokenBudget     int           // 历史窗口的固定token预算，0表示不限
+	contextRatio    float64       // 历史窗口占模型上下文窗口的比例，0表示不按模型计算
 }
 
 // EnhancedSession 增强版会话
@@ -28,11 +39,14 @@ type EnhancedSession struct {
 	Model           string
 	Messages        []models.Message
 	Summary         string            // 会话摘要
+	SummaryVersions []SummaryVersion  // 摘要历史版本
 	State           SessionState      // 结构化状态
 	Metadata        map[string]interface{}
+	TTL             time.Duration     // 会话级空闲过期时间，0表示使用管理器默认值
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	mu              sync.RWMutex
+	foldMu          sync.Mutex // 串行化滚动摘要的合并
 }
 
 // SessionState 会话状态
@@ -54,23 +68,92 @@ func NewEnhancedSessionManager(maxHistory int, storeType string, summaryModel ll
 	}
 }
 
-// GetOrCreateSession 获取或创建会话（并发安全）
-func (m *EnhancedSessionManager) GetOrCreateSession(sessionID, modelName string) (*EnhancedSession, error) {
-	// 先尝试读锁获取
+// NewEnhancedSessionManagerFromConfig 根据记忆配置创建会话管理器（含持久化存储和TTL）
+func NewEnhancedSessionManagerFromConfig(cfg config.MemoryConfig, summaryModel llm.Model) (*EnhancedSessionManager, error) {
+	store, err := NewSessionStoreFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if cfg.SessionTTL != "" {
+		ttl, _ = time.ParseDuration(cfg.SessionTTL) // 已在NewSessionStoreFromConfig中校验
+	}
+
+	storeType := cfg.StoreType
+	if storeType == "" {
+		storeType = "memory"
+	}
+
+	m := NewEnhancedSessionManager(cfg.MaxHistory, storeType, summaryModel)
+	m.SetSessionStore(store, ttl)
+	m.SetTokenWindow(cfg.HistoryTokenBudget, cfg.HistoryContextRatio)
+
+	if err := m.SetSummaryPrompt(cfg.Summary.PromptTemplate); err != nil {
+		return nil, err
+	}
+	if cfg.Summary.Threshold > 0 {
+		m.SetSummaryThreshold(cfg.Summary.Threshold)
+	}
+	m.SetSummaryTokenThreshold(cfg.Summary.TokenThreshold)
+	m.SetMaxSummaryVersions(cfg.Summary.MaxVersions)
+	return m, nil
+}
+
+// SetSessionStore 设置会话持久化存储和空闲过期时间
+func (m *EnhancedSessionManager) SetSessionStore(store SessionStore, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.sessionTTL = ttl
+}
+
+// Close 关闭会话存储
+func (m *EnhancedSessionManager) Close() error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.Close()
+}
+
+// Ping 探测会话存储的连通性，未配置持久化存储时始终可用
+// 通过加载一个不存在的会话探测，ErrSessionNotFound视为正常
+func (m *EnhancedSessionManager) Ping(ctx context.Context) error {
 	m.mu.RLock()
-	session, exists := m.sessions[sessionID]
+	store := m.store
 	m.mu.RUnlock()
 
-	if exists {
+	if store == nil {
+		return nil
+	}
+	if _, err := store.Load(ctx, "__health_check__"); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return nil
+}
+
+// StoreType 会话存储类型
+func (m *EnhancedSessionManager) StoreType() string {
+	return m.storeType
+}
+
+// GetOrCreateSession 获取或创建会话（并发安全）
+func (m *EnhancedSessionManager) GetOrCreateSession(sessionID, modelName string) (*EnhancedSession, error) {
+	// 先从缓存或持久化存储获取
+	session, err := m.GetSession(sessionID)
+	if err == nil {
 		return session, nil
 	}
+	if !errors.Is(err, ErrSessionNotFound) {
+		return nil, err
+	}
 
 	// 不存在，用写锁创建
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// 双重检查
-	session, exists = m.sessions[sessionID]
+	session, exists := m.sessions[sessionID]
 	if exists {
 		return session, nil
 	}
@@ -90,6 +173,10 @@ func (m *EnhancedSessionManager) GetOrCreateSession(sessionID, modelName string)
 		UpdatedAt: time.Now(),
 	}
 
+	if err := m.persist(session); err != nil {
+		return nil, err
+	}
+
 	m.sessions[sessionID] = session
 	return session, nil
 }
@@ -108,8 +195,16 @@ func (m *EnhancedSessionManager) AddMessage(sessionID string, message models.Mes
 	session.Messages = append(session.Messages, message)
 	session.UpdatedAt = time.Now()
 
+	// 按token预算保留历史，移出窗口的消息合并进滚动摘要
+	if budget := m.historyBudget(session.Model); budget > 0 {
+		if folded := m.applyTokenWindow(session, budget); len(folded) > 0 && m.enableAutoSummary {
+			go m.foldIntoSummary(sessionID, folded)
+		}
+		return m.persist(session)
+	}
+
 	// 检查是否需要自动摘要
-	if m.enableAutoSummary && len(session.Messages) > m.summaryThreshold {
+	if m.enableAutoSummary && m.shouldAutoSummary(session.Messages) {
 		go m.autoSummary(sessionID) // 异步生成摘要
 	}
 
@@ -125,7 +220,7 @@ func (m *EnhancedSessionManager) AddMessage(sessionID string, message models.Mes
 		}
 	}
 
-	return nil
+	return m.persist(session)
 }
 
 // autoSummary 自动摘要
@@ -138,13 +233,19 @@ func (m *EnhancedSessionManager) autoSummary(sessionID string) {
 		return
 	}
 
-	summary, err := m.generateSummary(ctx, session.Messages)
+	session.mu.RLock()
+	messages := make([]models.Message, len(session.Messages))
+	copy(messages, session.Messages)
+	session.mu.RUnlock()
+
+	summary, err := m.generateSummary(ctx, messages)
 	if err != nil {
 		return // 摘要生成失败，不影响主流程
 	}
 
 	session.mu.Lock()
-	session.Summary = summary
+	m.setSummaryLocked(session, summary, "threshold")
+	_ = m.persist(session)
 	session.mu.Unlock()
 }
 
@@ -166,6 +267,10 @@ func (m *EnhancedSessionManager) generateSummary(ctx context.Context, messages [
 
 // buildSummaryPrompt 构建摘要提示
 func (m *EnhancedSessionManager) buildSummaryPrompt(messages []models.Message) string {
+	if m.summaryTemplate() != "" {
+		return m.renderSummaryPrompt("", messages)
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString("请将以下对话历史总结为简洁的摘要，保留关键信息：\n\n")
@@ -203,7 +308,8 @@ func (m *EnhancedSessionManager) generateSummaryFromMessages(sessionID string, m
 
 	if exists {
 		session.mu.Lock()
-		session.Summary = summary
+		m.setSummaryLocked(session, summary, "overflow")
+		_ = m.persist(session)
 		session.mu.Unlock()
 	}
 }
@@ -232,15 +338,48 @@ func (m *EnhancedSessionManager) GetHistory(sessionID string) ([]models.Message,
 }
 
 // GetSession 获取会话（并发安全）
+// 缓存未命中时从持久化存储加载，超过空闲过期时间的会话视为不存在
 func (m *EnhancedSessionManager) GetSession(sessionID string) (*EnhancedSession, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	session, exists := m.sessions[sessionID]
-	if !exists {
-		return nil, fmt.Errorf("session not found: %s", sessionID)
+	store := m.store
+	m.mu.RUnlock()
+
+	if exists {
+		if !m.expired(session) {
+			return session, nil
+		}
+		m.mu.Lock()
+		if m.sessions[sessionID] == session {
+			delete(m.sessions, sessionID)
+		}
+		m.mu.Unlock()
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	record, err := store.Load(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+		}
+		return nil, err
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 加载期间可能已被其他请求创建
+	if cached, exists := m.sessions[sessionID]; exists {
+		return cached, nil
+	}
+	session = sessionFromRecord(record)
+	m.sessions[sessionID] = session
 	return session, nil
 }
 
@@ -250,9 +389,95 @@ func (m *EnhancedSessionManager) Clear(sessionID string) error {
 	defer m.mu.Unlock()
 
 	delete(m.sessions, sessionID)
+
+	if m.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.store.Delete(ctx, sessionID)
+}
+
+// expired 缓存中的会话是否已超过空闲过期时间
+func (m *EnhancedSessionManager) expired(session *EnhancedSession) bool {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+
+	ttl := m.sessionTTL
+	if session.TTL > 0 {
+		ttl = session.TTL
+	}
+	return ttl > 0 && time.Since(session.UpdatedAt) > ttl
+}
+
+// persist 将会话写入持久化存储，调用方需持有session的锁
+func (m *EnhancedSessionManager) persist(session *EnhancedSession) error {
+	if m.store == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.store.Save(ctx, session.record()); err != nil {
+		return fmt.Errorf("failed to persist session %s: %w", session.ID, err)
+	}
 	return nil
 }
 
+// record 生成会话快照，调用方需持有session的锁
+func (s *EnhancedSession) record() *SessionRecord {
+	messages := make([]models.Message, len(s.Messages))
+	copy(messages, s.Messages)
+	versions := make([]SummaryVersion, len(s.SummaryVersions))
+	copy(versions, s.SummaryVersions)
+
+	return &SessionRecord{
+		ID:              s.ID,
+		Model:           s.Model,
+		Messages:        messages,
+		Summary:         s.Summary,
+		SummaryVersions: versions,
+		State: SessionState{
+			Data:      copyMap(s.State.Data),
+			Version:   s.State.Version,
+			UpdatedAt: s.State.UpdatedAt,
+		},
+		Metadata:  copyMap(s.Metadata),
+		TTL:       s.TTL,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
+
+
+// sessionFromRecord 从持久化快照恢复会话
+func sessionFromRecord(record *SessionRecord) *EnhancedSession {
+	session := &EnhancedSession{
+		ID:              record.ID,
+		Model:           record.Model,
+		Messages:        record.Messages,
+		Summary:         record.Summary,
+		SummaryVersions: record.SummaryVersions,
+		State:           record.State,
+		Metadata:        record.Metadata,
+		TTL:             record.TTL,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+	}
+	if session.Messages == nil {
+		session.Messages = make([]models.Message, 0)
+	}
+	if session.State.Data == nil {
+		session.State.Data = make(map[string]interface{})
+	}
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+	return session
+}
+
+
 // UpdateState 更新会话状态（并发安全，版本控制）
 func (m *EnhancedSessionManager) UpdateState(sessionID string, updates map[string]interface{}) (int, error) {
 	session, err := m.GetOrCreateSession(sessionID, "")
@@ -273,6 +498,10 @@ func (m *EnhancedSessionManager) UpdateState(sessionID string, updates map[strin
 	session.State.UpdatedAt = time.Now()
 	session.UpdatedAt = time.Now()
 
+	if err := m.persist(session); err != nil {
+		return 0, err
+	}
+
 	return session.State.Version, nil
 }
 
@@ -310,7 +539,7 @@ func (m *EnhancedSessionManager) SetMetadata(sessionID string, metadata map[stri
 
 	session.UpdatedAt = time.Now()
 
-	return nil
+	return m.persist(session)
 }
 
 // GetMetadata 获取元数据
@@ -328,12 +557,24 @@ func (m *EnhancedSessionManager) GetMetadata(sessionID string) (map[string]inter
 }
 
 // ListSessions 列出所有会话
+// 配置了持久化存储时以存储为准，包含其他实例创建的会话
 func (m *EnhancedSessionManager) ListSessions() []string {
+	if m.store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if ids, err := m.store.List(ctx); err == nil {
+			return ids
+		}
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	ids := make([]string, 0, len(m.sessions))
-	for id := range m.sessions {
+	for id, session := range m.sessions {
+		if m.expired(session) {
+			continue
+		}
 		ids = append(ids, id)
 	}
 	return ids
@@ -341,9 +582,7 @@ func (m *EnhancedSessionManager) ListSessions() []string {
 
 // GetSessionCount 获取会话数量
 func (m *EnhancedSessionManager) GetSessionCount() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.sessions)
+	return len(m.ListSessions())
 }
 
 // SetSummaryModel 设置摘要模型
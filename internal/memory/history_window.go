@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// SetTokenWindow 启用按token预算的历史窗口，替代按消息条数截断
+// budget为固定token预算，contextRatio为占模型上下文窗口的比例，两者同时设置时取较小值，均为0时关闭
+func (m *EnhancedSessionManager) SetTokenWindow(budget int, contextRatio float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenBudget = budget
+	m.contextRatio = contextRatio
+}
+
+// historyBudget 计算会话历史可用的token预算，0表示未启用
+func (m *EnhancedSessionManager) historyBudget(model string) int {
+	budget := m.tokenBudget
+	if m.contextRatio > 0 {
+		if window := llm.ContextWindow(model); window > 0 {
+			if b := int(float64(window) * m.contextRatio); budget == 0 || b < budget {
+				budget = b
+			}
+		}
+	}
+	return budget
+}
+
+// applyTokenWindow 保留预算内最近的消息，返回被移出窗口的旧消息，调用方需持有session的锁
+// 摘要同样占用预算；最新一条消息始终保留
+func (m *EnhancedSessionManager) applyTokenWindow(session *EnhancedSession, budget int) []models.Message {
+	used := 0
+	if session.Summary != "" {
+		used = llm.EstimateTokens(session.Summary)
+	}
+
+	cut := len(session.Messages)
+	for cut > 0 {
+		tokens := llm.EstimateMessageTokens(session.Messages[cut-1])
+		if used+tokens > budget && cut < len(session.Messages) {
+			break
+		}
+		used += tokens
+		cut--
+	}
+
+	// 窗口不以孤立的工具结果开头
+	for cut < len(session.Messages)-1 && session.Messages[cut].Role == "tool" {
+		cut++
+	}
+
+	if cut == 0 {
+		return nil
+	}
+
+	folded := make([]models.Message, cut)
+	copy(folded, session.Messages[:cut])
+	session.Messages = append(make([]models.Message, 0, len(session.Messages)-cut), session.Messages[cut:]...)
+	return folded
+}
+
+// foldIntoSummary 将移出窗口的消息合并进滚动摘要
+// 同一会话的合并串行执行，避免并发覆盖摘要
+func (m *EnhancedSessionManager) foldIntoSummary(sessionID string, folded []models.Message) {
+	session, err := m.GetSession(sessionID)
+	if err != nil {
+		return
+	}
+
+	session.foldMu.Lock()
+	defer session.foldMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session.mu.RLock()
+	previous := session.Summary
+	session.mu.RUnlock()
+
+	if m.summaryModel == nil {
+		return
+	}
+	prompt := buildFoldPrompt(previous, folded)
+	if m.summaryTemplate() != "" {
+		prompt = m.renderSummaryPrompt(previous, folded)
+	}
+	summary, err := m.summaryModel.Chat(ctx, []models.Message{
+		{Role: "user", Content: prompt},
+	})
+	if err != nil {
+		return // 摘要生成失败，不影响主流程
+	}
+
+	session.mu.Lock()
+	m.setSummaryLocked(session, strings.TrimSpace(summary), "fold")
+	_ = m.persist(session)
+	session.mu.Unlock()
+}
+
+// buildFoldPrompt 构建滚动摘要提示
+func buildFoldPrompt(previous string, messages []models.Message) string {
+	var sb strings.Builder
+
+	sb.WriteString("请将已有的会话摘要与新移出上下文的对话合并为一份新的摘要，保留关键信息：\n\n")
+	if previous != "" {
+		sb.WriteString("已有摘要：\n")
+		sb.WriteString(previous)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("新增对话：\n")
+	for _, msg := range messages {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	sb.WriteString("\n摘要要求：\n")
+	sb.WriteString("1. 简洁明了，按时间顺序组织\n")
+	sb.WriteString("2. 保留关键信息（用户需求、重要决定、已确认的事实等）\n")
+	sb.WriteString("3. 控制在300字以内")
+
+	return sb.String()
+}
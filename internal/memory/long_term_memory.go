@@ -0,0 +1,392 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
+)
+
+// MaintenanceReport 一次记忆维护（合并+清理）的结果
+type MaintenanceReport struct {
+	Users  int       `json:"users"`
+	Merged int       `json:"merged"`
+	Pruned int       `json:"pruned"`
+	RanAt  time.Time `json:"ran_at"`
+}
+
+// NewEnhancedMemoryManagerFromConfig 根据记忆配置创建记忆管理器（含长期存储和衰减参数）
+func NewEnhancedMemoryManagerFromConfig(cfg config.MemoryConfig, embeddingModel llm.Model) (*EnhancedMemoryManager, error) {
+	m := NewEnhancedMemoryManager(embeddingModel)
+
+	store, err := NewUserMemoryStoreFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m.SetUserMemoryStore(store)
+
+	userCfg := cfg.UserMemory
+	if userCfg.DecayHalfLife != "" {
+		halfLife, err := time.ParseDuration(userCfg.DecayHalfLife)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decay_half_life %q: %w", userCfg.DecayHalfLife, err)
+		}
+		m.decayHalfLife = halfLife
+	}
+	if userCfg.MinImportance > 0 {
+		m.minImportance = userCfg.MinImportance
+	}
+	if userCfg.ConsolidateThreshold > 0 {
+		m.consolidateThreshold = userCfg.ConsolidateThreshold
+	}
+	m.SetMemoryACL(NewMemoryACL(userCfg.Scopes))
+
+	return m, nil
+}
+
+// SetUserMemoryStore 设置长期存储，设置后用户记忆按需从存储加载并在变更后写回
+func (m *EnhancedMemoryManager) SetUserMemoryStore(store UserMemoryStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.loaded = make(map[string]bool)
+}
+
+// ensureLoaded 确保用户记忆已从存储加载
+func (m *EnhancedMemoryManager) ensureLoaded(ctx context.Context, userID string) error {
+	m.mu.RLock()
+	done := m.store == nil || m.loaded[userID]
+	m.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loadLocked(ctx, userID)
+}
+
+// loadLocked 从存储加载用户记忆，调用方需持有写锁
+func (m *EnhancedMemoryManager) loadLocked(ctx context.Context, userID string) error {
+	if m.store == nil || m.loaded[userID] {
+		return nil
+	}
+
+	memories, err := m.store.LoadUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load memories for %s: %w", userID, err)
+	}
+
+	// 合并加载前已在内存中新增的记忆
+	m.memories[userID] = append(memories, m.memories[userID]...)
+	m.loaded[userID] = true
+	return nil
+}
+
+// persistLocked 将用户记忆写回存储，调用方需持有写锁
+func (m *EnhancedMemoryManager) persistLocked(ctx context.Context, userID string) error {
+	if m.store == nil {
+		return nil
+	}
+	if len(m.memories[userID]) == 0 {
+		return m.store.DeleteUser(ctx, userID)
+	}
+	return m.store.SaveUser(ctx, userID, m.memories[userID])
+}
+
+// decayedImportance 计算记忆的衰减后重要性
+// 按最后访问时间指数衰减，访问越频繁衰减越慢
+func (m *EnhancedMemoryManager) decayedImportance(memory *UserMemory, now time.Time) float64 {
+	memory.mu.RLock()
+	defer memory.mu.RUnlock()
+
+	score := memory.Importance
+	if m.decayHalfLife > 0 {
+		age := now.Sub(memory.AccessedAt)
+		score *= math.Pow(0.5, float64(age)/float64(m.decayHalfLife))
+	}
+	score *= 1 + 0.1*math.Log1p(float64(memory.AccessCount))
+
+	return math.Min(score, 1)
+}
+
+// Consolidate 合并用户的相关记忆，返回被合并掉的记忆数
+// 有向量时按余弦相似度判断，否则按主题重合度判断
+func (m *EnhancedMemoryManager) Consolidate(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	merged := m.consolidateLocked(userID)
+	if merged == 0 {
+		return 0, nil
+	}
+	return merged, m.persistLocked(ctx, userID)
+}
+
+// consolidateLocked 合并相关记忆，重要性高的记忆吸收其余记忆
+func (m *EnhancedMemoryManager) consolidateLocked(userID string) int {
+	memories := make([]*UserMemory, len(m.memories[userID]))
+	copy(memories, m.memories[userID])
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].Importance > memories[j].Importance
+	})
+
+	kept := make([]*UserMemory, 0, len(memories))
+	merged := 0
+	for _, memory := range memories {
+		absorbed := false
+		for _, target := range kept {
+			if m.related(target, memory) {
+				m.mergeMemories(target, memory)
+				if memory.AccessCount > 0 {
+					target.AccessCount += memory.AccessCount
+				}
+				if memory.AccessedAt.After(target.AccessedAt) {
+					target.AccessedAt = memory.AccessedAt
+				}
+				absorbed = true
+				merged++
+				break
+			}
+		}
+		if !absorbed {
+			kept = append(kept, memory)
+		}
+	}
+
+	m.memories[userID] = kept
+	return merged
+}
+
+// related 判断两条记忆是否相关
+func (m *EnhancedMemoryManager) related(a, b *UserMemory) bool {
+	if len(a.Vector) > 0 && len(b.Vector) > 0 {
+		return cosineSimilarity(a.Vector, b.Vector) >= m.consolidateThreshold
+	}
+	return topicOverlap(a.Topics, b.Topics) >= m.consolidateThreshold
+}
+
+// topicOverlap 主题集合的Jaccard重合度
+func topicOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, topic := range a {
+		set[strings.ToLower(topic)] = true
+	}
+
+	intersection := 0
+	union := len(set)
+	seen := make(map[string]bool, len(b))
+	for _, topic := range b {
+		topic = strings.ToLower(topic)
+		if seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		if set[topic] {
+			intersection++
+		} else {
+			union++
+		}
+	}
+
+	return float64(intersection) / float64(union)
+}
+
+// Prune 清理衰减后重要性低于阈值的记忆，返回清理数量
+func (m *EnhancedMemoryManager) Prune(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	pruned := m.pruneLocked(userID, time.Now())
+	if pruned == 0 {
+		return 0, nil
+	}
+	return pruned, m.persistLocked(ctx, userID)
+}
+
+// pruneLocked 清理低于阈值的记忆
+func (m *EnhancedMemoryManager) pruneLocked(userID string, now time.Time) int {
+	memories := m.memories[userID]
+	kept := make([]*UserMemory, 0, len(memories))
+	for _, memory := range memories {
+		if m.decayedImportance(memory, now) >= m.minImportance {
+			kept = append(kept, memory)
+		}
+	}
+	m.memories[userID] = kept
+	return len(memories) - len(kept)
+}
+
+// Maintain 对所有用户执行记忆合并和清理
+func (m *EnhancedMemoryManager) Maintain(ctx context.Context) (MaintenanceReport, error) {
+	report := MaintenanceReport{RanAt: time.Now()}
+
+	users := make(map[string]bool)
+	m.mu.RLock()
+	for userID := range m.memories {
+		users[userID] = true
+	}
+	store := m.store
+	m.mu.RUnlock()
+
+	if store != nil {
+		stored, err := store.ListUsers(ctx)
+		if err != nil {
+			return report, err
+		}
+		for _, userID := range stored {
+			users[userID] = true
+		}
+	}
+
+	for userID := range users {
+		merged, err := m.Consolidate(ctx, userID)
+		if err != nil {
+			return report, err
+		}
+		pruned, err := m.Prune(ctx, userID)
+		if err != nil {
+			return report, err
+		}
+		report.Users++
+		report.Merged += merged
+		report.Pruned += pruned
+	}
+
+	return report, nil
+}
+
+// StartMaintenance 启动后台定期维护，ctx取消时停止
+func (m *EnhancedMemoryManager) StartMaintenance(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = m.Maintain(ctx)
+			}
+		}
+	}()
+}
+
+// RecallForContext 检索与当前问题相关的记忆，范围包括用户本人、所属团队和全局记忆
+// 综合语义相似度、衰减后重要性和作用域权重排序，被高优先级作用域覆盖的共享记忆不参与排序，并更新访问统计
+func (m *EnhancedMemoryManager) RecallForContext(ctx context.Context, userID, query string, limit int) ([]*UserMemory, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	owners := m.readableOwners(userID)
+	for _, owner := range owners {
+		if err := m.ensureLoaded(ctx, owner.key); err != nil {
+			return nil, err
+		}
+	}
+
+	var queryVector []float64
+	if m.enableSemanticSearch && m.embeddingModel != nil && query != "" {
+		vector, err := m.embeddingModel.Embed(ctx, query)
+		if err == nil {
+			queryVector = vector
+		}
+	}
+
+	m.mu.RLock()
+	weights := m.acl.weights
+	candidates := make([][]*UserMemory, len(owners))
+	for i, owner := range owners {
+		candidates[i] = make([]*UserMemory, len(m.memories[owner.key]))
+		copy(candidates[i], m.memories[owner.key])
+	}
+	m.mu.RUnlock()
+
+	now := time.Now()
+	type memoryScore struct {
+		memory *UserMemory
+		owner  string
+		score  float64
+	}
+	scores := make([]memoryScore, 0)
+	higher := make([]*UserMemory, 0)
+	for i, owner := range owners {
+		for _, memory := range candidates[i] {
+			if owner.scope != MemoryScopeUser && m.shadowedByHigherScope(memory, higher) {
+				continue
+			}
+			score := m.decayedImportance(memory, now)
+			if queryVector != nil {
+				score = 0.7*cosineSimilarity(queryVector, memory.Vector) + 0.3*score
+			}
+			scores = append(scores, memoryScore{memory: memory, owner: owner.key, score: score * weights[owner.scope]})
+		}
+		higher = append(higher, candidates[i]...)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+	if limit > len(scores) {
+		limit = len(scores)
+	}
+
+	result := make([]*UserMemory, limit)
+	touched := make(map[string]bool)
+	for i := 0; i < limit; i++ {
+		result[i] = scores[i].memory
+		touched[scores[i].owner] = true
+	}
+	touchMemories(result, now)
+
+	// 访问统计影响衰减速度，需要写回存储
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for owner := range touched {
+		if err := m.persistLocked(ctx, owner); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// FormatMemoryContext 将记忆格式化为可注入对话的系统提示
+func FormatMemoryContext(memories []*UserMemory) string {
+	if len(memories) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[用户长期记忆]\n以下是关于当前用户的已知信息，回答时可参考：\n")
+	for _, memory := range memories {
+		sb.WriteString("- ")
+		sb.WriteString(scopeLabel(memory.Scope))
+		sb.WriteString(memory.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
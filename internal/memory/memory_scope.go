@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// MemoryScope 记忆作用域
+type MemoryScope string
+
+const (
+	MemoryScopeUser   MemoryScope = "user"   // 仅用户本人可见
+	MemoryScopeTeam   MemoryScope = "team"   // 团队成员共享
+	MemoryScopeGlobal MemoryScope = "global" // 组织内所有用户共享
+)
+
+// ErrMemoryAccessDenied 无权访问该作用域的记忆
+var ErrMemoryAccessDenied = errors.New("memory access denied")
+
+// 作用域默认检索权重，越具体的作用域优先级越高
+var defaultScopeWeights = map[MemoryScope]float64{
+	MemoryScopeUser:   1.0,
+	MemoryScopeTeam:   0.9,
+	MemoryScopeGlobal: 0.8,
+}
+
+// ParseMemoryScope 解析作用域，空字符串视为用户作用域
+func ParseMemoryScope(s string) (MemoryScope, error) {
+	switch MemoryScope(strings.ToLower(s)) {
+	case "", MemoryScopeUser:
+		return MemoryScopeUser, nil
+	case MemoryScopeTeam:
+		return MemoryScopeTeam, nil
+	case MemoryScopeGlobal:
+		return MemoryScopeGlobal, nil
+	default:
+		return "", fmt.Errorf("unsupported memory scope: %s", s)
+	}
+}
+
+// scopeOwnerKey 作用域在记忆表和存储中的键
+// 用户作用域直接使用用户ID，与已有数据保持兼容
+// 多租户时用户ID和团队ID已限定在租户内，全局记忆按用户所属租户区分
+func scopeOwnerKey(scope MemoryScope, userID, teamID string) string {
+	switch scope {
+	case MemoryScopeTeam:
+		return "team/" + teamID
+	case MemoryScopeGlobal:
+		return tenant.ScopeTo(tenant.Of(userID), "global/")
+	default:
+		return userID
+	}
+}
+
+// memoryOwnerKey 记忆所属的键
+func memoryOwnerKey(memory *UserMemory) string {
+	return scopeOwnerKey(memory.Scope, memory.UserID, memory.TeamID)
+}
+
+// MemoryACL 共享记忆的访问控制
+// 团队成员可读写团队记忆，所有用户可读全局记忆，仅管理员可写全局记忆
+type MemoryACL struct {
+	teams   map[string]map[string]bool // teamID -> 成员
+	admins  map[string]bool
+	weights map[MemoryScope]float64
+}
+
+// NewMemoryACL 根据配置创建访问控制
+func NewMemoryACL(cfg config.MemoryScopeConfig) *MemoryACL {
+	acl := &MemoryACL{
+		teams:   make(map[string]map[string]bool),
+		admins:  make(map[string]bool),
+		weights: make(map[MemoryScope]float64),
+	}
+	for teamID, members := range cfg.Teams {
+		acl.teams[teamID] = make(map[string]bool, len(members))
+		for _, member := range members {
+			acl.teams[teamID][member] = true
+		}
+	}
+	for _, admin := range cfg.Admins {
+		acl.admins[admin] = true
+	}
+	for scope, weight := range defaultScopeWeights {
+		acl.weights[scope] = weight
+	}
+	for scope, weight := range cfg.Weights {
+		if parsed, err := ParseMemoryScope(scope); err == nil && weight > 0 {
+			acl.weights[parsed] = weight
+		}
+	}
+	return acl
+}
+
+// TeamsOf 用户所属的团队（已排序）
+func (a *MemoryACL) TeamsOf(userID string) []string {
+	teams := make([]string, 0)
+	for teamID, members := range a.teams {
+		if members[userID] {
+			teams = append(teams, teamID)
+		}
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// CanRead 用户是否可读该作用域的记忆
+func (a *MemoryACL) CanRead(userID string, scope MemoryScope, teamID string) bool {
+	switch scope {
+	case MemoryScopeUser:
+		return userID != ""
+	case MemoryScopeTeam:
+		return a.admins[userID] || a.teams[teamID][userID]
+	case MemoryScopeGlobal:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanWrite 用户是否可写该作用域的记忆
+func (a *MemoryACL) CanWrite(userID string, scope MemoryScope, teamID string) bool {
+	switch scope {
+	case MemoryScopeUser:
+		return userID != ""
+	case MemoryScopeTeam:
+		return a.admins[userID] || a.teams[teamID][userID]
+	case MemoryScopeGlobal:
+		return a.admins[userID]
+	default:
+		return false
+	}
+}
+
+// SetMemoryACL 设置共享记忆的访问控制
+func (m *EnhancedMemoryManager) SetMemoryACL(acl *MemoryACL) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acl = acl
+}
+
+// checkAccess 校验作用域访问权限
+func (m *EnhancedMemoryManager) checkAccess(userID string, scope MemoryScope, teamID string, write bool) error {
+	if scope == MemoryScopeTeam && teamID == "" {
+		return fmt.Errorf("team_id is required for team scope")
+	}
+
+	m.mu.RLock()
+	acl := m.acl
+	m.mu.RUnlock()
+
+	allowed := acl.CanRead(userID, scope, teamID)
+	if write {
+		allowed = acl.CanWrite(userID, scope, teamID)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s cannot access %s scope", ErrMemoryAccessDenied, userID, scope)
+	}
+	return nil
+}
+
+// scopedOwner 用户可读的一个作用域
+type scopedOwner struct {
+	key   string
+	scope MemoryScope
+}
+
+// readableOwners 用户可读的全部作用域，按优先级从高到低
+func (m *EnhancedMemoryManager) readableOwners(userID string) []scopedOwner {
+	m.mu.RLock()
+	acl := m.acl
+	m.mu.RUnlock()
+
+	owners := []scopedOwner{{key: userID, scope: MemoryScopeUser}}
+	for _, teamID := range acl.TeamsOf(userID) {
+		owners = append(owners, scopedOwner{key: scopeOwnerKey(MemoryScopeTeam, userID, teamID), scope: MemoryScopeTeam})
+	}
+	owners = append(owners, scopedOwner{key: scopeOwnerKey(MemoryScopeGlobal, userID, ""), scope: MemoryScopeGlobal})
+
+	sort.SliceStable(owners, func(i, j int) bool {
+		return acl.weights[owners[i].scope] > acl.weights[owners[j].scope]
+	})
+	return owners
+}
+
+// AddScopedMemory 以userID的身份在指定作用域下添加记忆
+func (m *EnhancedMemoryManager) AddScopedMemory(ctx context.Context, userID string, memory *UserMemory) error {
+	scope, err := ParseMemoryScope(string(memory.Scope))
+	if err != nil {
+		return err
+	}
+	if err := m.checkAccess(userID, scope, memory.TeamID, true); err != nil {
+		return err
+	}
+
+	memory.Scope = scope
+	memory.UserID = userID // 共享记忆中记录创建者
+	if scope != MemoryScopeTeam {
+		memory.TeamID = ""
+	}
+	if memory.ID == "" {
+		memory.ID = generateID()
+	}
+	if memory.Importance <= 0 {
+		memory.Importance = 0.5
+	}
+	if memory.Vector == nil && m.embeddingModel != nil && m.embeddingModel.SupportsEmbedding() {
+		if vector, err := m.embeddingModel.Embed(ctx, memory.Content); err == nil {
+			memory.Vector = vector
+		}
+	}
+
+	return m.AddMemory(ctx, memory)
+}
+
+// ListScopedMemories 列出作用域下的全部记忆
+func (m *EnhancedMemoryManager) ListScopedMemories(ctx context.Context, userID string, scope MemoryScope, teamID string) ([]*UserMemory, error) {
+	if err := m.checkAccess(userID, scope, teamID, false); err != nil {
+		return nil, err
+	}
+	if scope == MemoryScopeUser {
+		return m.ListMemories(userID), nil
+	}
+
+	owner := scopeOwnerKey(scope, userID, teamID)
+	if err := m.ensureLoaded(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	memories := make([]*UserMemory, len(m.memories[owner]))
+	copy(memories, m.memories[owner])
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].CreatedAt.Before(memories[j].CreatedAt)
+	})
+	return memories, nil
+}
+
+// DeleteScopedMemory 删除作用域下的单条记忆
+func (m *EnhancedMemoryManager) DeleteScopedMemory(ctx context.Context, userID string, scope MemoryScope, teamID, memoryID string) error {
+	if err := m.checkAccess(userID, scope, teamID, true); err != nil {
+		return err
+	}
+	return m.DeleteMemory(ctx, scopeOwnerKey(scope, userID, teamID), memoryID)
+}
+
+// shadowedByHigherScope 共享记忆是否被更高优先级作用域中的相关记忆覆盖
+// 例如用户本人的偏好与团队默认值冲突时，以用户记忆为准
+func (m *EnhancedMemoryManager) shadowedByHigherScope(memory *UserMemory, higher []*UserMemory) bool {
+	for _, h := range higher {
+		if m.related(h, memory) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeLabel 作用域在对话上下文中的标注
+func scopeLabel(scope MemoryScope) string {
+	switch scope {
+	case MemoryScopeTeam:
+		return "[团队] "
+	case MemoryScopeGlobal:
+		return "[组织] "
+	default:
+		return ""
+	}
+}
+
+// touchMemories 更新访问统计
+func touchMemories(memories []*UserMemory, now time.Time) {
+	for _, memory := range memories {
+		memory.mu.Lock()
+		memory.AccessedAt = now
+		memory.AccessCount++
+		memory.mu.Unlock()
+	}
+}
@@ -2,12 +2,19 @@ package memory
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/llm"
 	"ai-agent-assistant/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
 )
 
 // MockMemoryModel 模拟记忆模型
@@ -189,6 +196,439 @@ func TestAutoSummary(t *testing.T) {
 	}
 }
 
+// TestSessionStore 测试会话持久化存储与过期
+func TestSessionStore(t *testing.T) {
+	store := NewMemorySessionStore(0)
+
+	manager := NewEnhancedSessionManager(10, "memory", nil)
+	manager.SetSessionStore(store, 0)
+
+	if _, err := manager.GetOrCreateSession("persisted", "qwen"); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	manager.AddMessage("persisted", models.Message{Role: "user", Content: "你好"})
+	manager.UpdateState("persisted", map[string]interface{}{"step": "greeting"})
+
+	// 模拟进程重启：新的管理器从同一存储恢复会话
+	restarted := NewEnhancedSessionManager(10, "memory", nil)
+	restarted.SetSessionStore(store, 0)
+
+	history, err := restarted.GetHistory("persisted")
+	if err != nil {
+		t.Fatalf("Session should be restored from store: %v", err)
+	}
+	if len(history) != 1 || history[0].Content != "你好" {
+		t.Errorf("Expected restored history, got %v", history)
+	}
+
+	state, _ := restarted.GetState("persisted")
+	if state.Version != 1 || state.Data["step"] != "greeting" {
+		t.Errorf("Expected restored state, got %+v", state)
+	}
+
+	restarted.Clear("persisted")
+	if _, err := store.Load(context.Background(), "persisted"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected session deleted from store, got %v", err)
+	}
+
+	// TTL过期
+	expiring := NewEnhancedSessionManager(10, "memory", nil)
+	expiring.SetSessionStore(NewMemorySessionStore(20*time.Millisecond), 20*time.Millisecond)
+	expiring.GetOrCreateSession("short-lived", "qwen")
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, err := expiring.GetSession("short-lived"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected expired session, got %v", err)
+	}
+	if count := expiring.GetSessionCount(); count != 0 {
+		t.Errorf("Expected 0 sessions after expiry, got %d", count)
+	}
+}
+
+// TestRedisSessionStore 测试Redis会话存储：键过期实现TTL，会话级TTL优先
+func TestRedisSessionStore(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store, err := NewRedisSessionStore(config.RedisConfig{Addr: mr.Addr()}, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create redis session store: %v", err)
+	}
+	defer store.Close()
+
+	record := &SessionRecord{
+		ID:       "s1",
+		Model:    "qwen",
+		Messages: []models.Message{{Role: "user", Content: "你好"}, {Role: "assistant", Content: "你好！", ToolCalls: []models.ToolCall{{ID: "c1", Name: "search"}}}},
+		Summary:  "问候",
+		State:    SessionState{Data: map[string]interface{}{"step": "greeting"}, Version: 1},
+	}
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	if err := store.Save(ctx, &SessionRecord{ID: "s2", TTL: 5 * time.Minute}); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	if ttl := mr.TTL(redisSessionPrefix + "s1"); ttl != time.Minute {
+		t.Errorf("Expected store ttl 1m, got %v", ttl)
+	}
+	if ttl := mr.TTL(redisSessionPrefix + "s2"); ttl != 5*time.Minute {
+		t.Errorf("Expected session ttl 5m, got %v", ttl)
+	}
+
+	loaded, err := store.Load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if loaded.Summary != "问候" || len(loaded.Messages) != 2 || loaded.Messages[1].ToolCalls[0].Name != "search" || loaded.State.Data["step"] != "greeting" {
+		t.Errorf("Unexpected session: %+v", loaded)
+	}
+	if ids, err := store.List(ctx); err != nil || len(ids) != 2 {
+		t.Errorf("Expected 2 sessions without key prefix, got %v, %v", ids, err)
+	}
+
+	// 超过存储TTL后s1过期，s2仍在
+	mr.FastForward(2 * time.Minute)
+	if _, err := store.Load(ctx, "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected expired session, got %v", err)
+	}
+	if ids, _ := store.List(ctx); len(ids) != 1 || ids[0] != "s2" {
+		t.Errorf("Expected only s2 left, got %v", ids)
+	}
+
+	if err := store.Delete(ctx, "s2"); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	if _, err := store.Load(ctx, "s2"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected deleted session, got %v", err)
+	}
+}
+
+// TestPostgresSessionStore 测试Postgres会话存储的迁移和SQL语句
+func TestPostgresSessionStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// 首次启动按版本顺序执行全部迁移
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS session_schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM session_schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	for _, migration := range postgresSessionMigrations {
+		mock.ExpectBegin()
+		for range migration.statements {
+			mock.ExpectExec(`.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(`INSERT INTO session_schema_migrations`).WithArgs(migration.version, migration.description).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	store, err := NewPostgresSessionStore(ctx, db, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create postgres session store: %v", err)
+	}
+
+	// 保存时整体替换消息，过期时间按存储TTL计算，有工具调用的消息写入annotations列
+	now := time.Now()
+	record := &SessionRecord{
+		ID:        "s1",
+		Model:     "qwen",
+		Messages:  []models.Message{{Role: "user", Content: "你好"}, {Role: "assistant", Content: "查一下", ToolCalls: []models.ToolCall{{ID: "c1", Name: "search"}}}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO sessions .+ ON CONFLICT \(id\) DO UPDATE`).
+		WithArgs("s1", "qwen", "", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(0), now, now, expiresAround{now.Add(time.Hour)}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM messages WHERE session_id = \$1`).WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO messages`).WithArgs("s1", "user", "你好", "", []byte(nil)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO messages`).WithArgs("s1", "assistant", "查一下", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT model, summary, summary_versions, state, metadata, ttl_seconds, created_at, updated_at\s+FROM sessions\s+WHERE id = \$1 AND \(expires_at IS NULL OR expires_at > NOW\(\)\)`).
+		WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows([]string{"model", "summary", "summary_versions", "state", "metadata", "ttl_seconds", "created_at", "updated_at"}).
+			AddRow("qwen", "问候", nil, []byte(`{"data":{"step":"greeting"},"version":1}`), []byte(`{"user_id":"u1"}`), int64(300), now, now))
+	mock.ExpectQuery(`SELECT role, content, tool_id, annotations FROM messages WHERE session_id = \$1 ORDER BY id`).WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows([]string{"role", "content", "tool_id", "annotations"}).
+			AddRow("user", "你好", "", nil).
+			AddRow("assistant", "查一下", "", []byte(`{"tool_calls":[{"id":"c1","name":"search"}]}`)))
+	loaded, err := store.Load(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if loaded.Summary != "问候" || loaded.TTL != 5*time.Minute || loaded.State.Version != 1 || loaded.Metadata["user_id"] != "u1" {
+		t.Errorf("Unexpected session: %+v", loaded)
+	}
+	if len(loaded.Messages) != 2 || len(loaded.Messages[1].ToolCalls) != 1 || loaded.Messages[1].ToolCalls[0].Name != "search" {
+		t.Errorf("Unexpected messages: %+v", loaded.Messages)
+	}
+
+	mock.ExpectQuery(`SELECT model, summary`).WithArgs("missing").WillReturnRows(sqlmock.NewRows([]string{"model"}))
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT id FROM sessions\s+WHERE expires_at IS NULL OR expires_at > NOW\(\)\s+ORDER BY updated_at DESC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s2").AddRow("s1"))
+	if ids, err := store.List(ctx); err != nil || len(ids) != 2 || ids[0] != "s2" {
+		t.Errorf("Expected sessions by last update, got %v, %v", ids, err)
+	}
+
+	mock.ExpectExec(`DELETE FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= NOW\(\)`).WillReturnResult(sqlmock.NewResult(0, 3))
+	if n, err := store.PurgeExpired(ctx); err != nil || n != 3 {
+		t.Errorf("Expected 3 purged sessions, got %d, %v", n, err)
+	}
+
+	mock.ExpectExec(`DELETE FROM sessions WHERE id = \$1`).WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.Delete(ctx, "s1"); err != nil {
+		t.Errorf("Failed to delete session: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// expiresAround 匹配与预期相差不超过一分钟的过期时间
+type expiresAround struct {
+	at time.Time
+}
+
+func (m expiresAround) Match(v driver.Value) bool {
+	at, ok := v.(time.Time)
+	return ok && at.Sub(m.at).Abs() < time.Minute
+}
+
+// TestQuerySessions 测试会话查询、会话级TTL与空闲淘汰
+func TestQuerySessions(t *testing.T) {
+	manager := NewEnhancedSessionManager(10, "memory", nil)
+	manager.SetSessionStore(NewMemorySessionStore(time.Hour), time.Hour)
+
+	manager.GetOrCreateSession("s1", "qwen")
+	manager.SetMetadata("s1", map[string]interface{}{SessionUserIDKey: "alice"})
+	manager.GetOrCreateSession("s2", "glm")
+	manager.SetMetadata("s2", map[string]interface{}{SessionUserIDKey: "alice"})
+	manager.GetOrCreateSession("s3", "qwen")
+	manager.SetMetadata("s3", map[string]interface{}{SessionUserIDKey: "bob"})
+
+	sessions, total := manager.QuerySessions(SessionQuery{UserID: "alice"})
+	if total != 2 || len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions for alice, got %d", total)
+	}
+	if sessions[0].ID != "s2" {
+		t.Errorf("Expected most recently active session first, got %s", sessions[0].ID)
+	}
+
+	sessions, total = manager.QuerySessions(SessionQuery{Model: "qwen", Limit: 1})
+	if total != 2 || len(sessions) != 1 {
+		t.Errorf("Expected page of 1 out of 2 qwen sessions, got %d/%d", len(sessions), total)
+	}
+
+	sessions, _ = manager.QuerySessions(SessionQuery{ActiveSince: time.Now().Add(time.Minute)})
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions active in the future, got %d", len(sessions))
+	}
+
+	// 会话级TTL覆盖默认值，到期后被淘汰
+	if err := manager.SetSessionTTL("s3", 20*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set session TTL: %v", err)
+	}
+	if err := manager.SetSessionTTL("missing", time.Minute); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	evicted := manager.EvictIdleSessions()
+	if len(evicted) != 1 || evicted[0] != "s3" {
+		t.Errorf("Expected s3 to be evicted, got %v", evicted)
+	}
+	stats := manager.GetEvictionStats()
+	if stats.TotalEvicted != 1 || stats.StorePurged != 1 {
+		t.Errorf("Unexpected eviction stats: %+v", stats)
+	}
+	if _, total := manager.QuerySessions(SessionQuery{}); total != 2 {
+		t.Errorf("Expected 2 sessions after eviction, got %d", total)
+	}
+}
+
+// TestForkSession 测试会话分叉
+func TestForkSession(t *testing.T) {
+	manager := NewEnhancedSessionManager(100, "memory", nil)
+	manager.GetOrCreateSession("origin", "qwen")
+	for i := 0; i < 4; i++ {
+		manager.AddMessage("origin", models.Message{Role: "user", Content: fmt.Sprintf("消息%d", i)})
+	}
+	manager.UpdateState("origin", map[string]interface{}{"step": 2})
+
+	fork, err := manager.ForkSession("origin", "branch", 2)
+	if err != nil {
+		t.Fatalf("ForkSession failed: %v", err)
+	}
+	if len(fork.Messages) != 2 || fork.Messages[1].Content != "消息1" {
+		t.Errorf("Expected fork to keep messages before index 2, got %v", fork.Messages)
+	}
+	if fork.State.Data["step"] != 2 {
+		t.Errorf("Expected state copied to fork, got %v", fork.State.Data)
+	}
+
+	// 分支与原会话互不影响
+	manager.AddMessage("branch", models.Message{Role: "user", Content: "另一种问法"})
+	manager.UpdateState("branch", map[string]interface{}{"step": 3})
+	origin, _ := manager.GetSession("origin")
+	if len(origin.Messages) != 4 || origin.State.Data["step"] != 2 {
+		t.Errorf("Original session changed by fork: %d messages, state %v", len(origin.Messages), origin.State.Data)
+	}
+
+	if _, err := manager.ForkSession("origin", "branch", 1); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Expected ErrSessionExists, got %v", err)
+	}
+	if _, err := manager.ForkSession("origin", "", 10); err == nil {
+		t.Error("Expected error for out of range index")
+	}
+	if _, err := manager.ForkSession("missing", "", -1); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	forks := manager.ListForks("origin")
+	if len(forks) != 1 || forks[0].ID != "branch" || forks[0].ForkedFrom != "origin" {
+		t.Errorf("Unexpected forks: %+v", forks)
+	}
+}
+
+// TestExportTranscript 测试会话记录导出
+func TestExportTranscript(t *testing.T) {
+	manager := NewEnhancedSessionManager(100, "memory", nil)
+	manager.GetOrCreateSession("audit", "qwen")
+	manager.AddMessage("audit", models.Message{Role: "user", Content: "杭州天气如何？"})
+	manager.AddMessage("audit", models.Message{
+		Role:      "assistant",
+		Content:   "杭州今天晴。",
+		ToolCalls: []models.ToolCall{{ID: "call_1", Name: "weather", Arguments: map[string]interface{}{"city": "杭州"}, Result: "晴 25℃"}},
+		Citations: []models.Citation{{Index: 1, Content: "杭州气象台数据", Source: "weather.md"}},
+	})
+
+	transcript, err := manager.ExportTranscript("audit")
+	if err != nil {
+		t.Fatalf("ExportTranscript failed: %v", err)
+	}
+	if len(transcript.Messages) != 2 || len(transcript.Messages[1].Citations) != 1 {
+		t.Fatalf("Unexpected transcript messages: %+v", transcript.Messages)
+	}
+
+	markdown := transcript.Markdown()
+	for _, want := range []string{"# 会话记录 audit", "### 1. 用户", "### 2. 助手", "`weather`", "晴 25℃", "1. 杭州气象台数据（来源：weather.md）"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Markdown missing %q:\n%s", want, markdown)
+		}
+	}
+
+	if _, err := manager.ExportTranscript("missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// TestTokenWindow 测试按token预算的历史窗口与滚动摘要
+func TestTokenWindow(t *testing.T) {
+	model := &MockMemoryModel{summaryResponse: "滚动摘要"}
+	manager := NewEnhancedSessionManager(100, "memory", model)
+	manager.SetTokenWindow(60, 0)
+
+	manager.GetOrCreateSession("window", "qwen")
+	for i := 0; i < 6; i++ {
+		manager.AddMessage("window", models.Message{
+			Role:    "user",
+			Content: fmt.Sprintf("第%d条消息，包含一些用于占用预算的中文内容", i+1),
+		})
+	}
+
+	// 等待异步合并摘要
+	time.Sleep(100 * time.Millisecond)
+
+	history, _ := manager.GetHistory("window")
+	if len(history) == 0 || history[0].Role != "system" {
+		t.Fatalf("Expected running summary at the head of history, got %v", history)
+	}
+	if !strings.Contains(history[0].Content, "滚动摘要") {
+		t.Errorf("Expected folded summary, got %s", history[0].Content)
+	}
+
+	recent := history[1:]
+	if len(recent) >= 6 {
+		t.Errorf("Expected older messages to be folded, got %d messages", len(recent))
+	}
+	if recent[len(recent)-1].Content != "第6条消息，包含一些用于占用预算的中文内容" {
+		t.Errorf("Latest message must be kept, got %s", recent[len(recent)-1].Content)
+	}
+	if tokens := llm.EstimateMessagesTokens(recent); tokens > 60 {
+		t.Errorf("History exceeds token budget: %d", tokens)
+	}
+
+	// 按模型上下文窗口比例计算预算
+	manager.SetTokenWindow(0, 0.5)
+	if budget := manager.historyBudget("gpt-4"); budget != 4096 {
+		t.Errorf("Expected budget 4096 for gpt-4, got %d", budget)
+	}
+	if budget := manager.historyBudget("unknown-model"); budget != 0 {
+		t.Errorf("Expected no budget for unknown model, got %d", budget)
+	}
+}
+
+// TestSummaryVersions 测试摘要模板与版本记录
+func TestSummaryVersions(t *testing.T) {
+	model := &MockMemoryModel{summaryResponse: "新摘要"}
+	manager := NewEnhancedSessionManager(100, "memory", model)
+
+	if err := manager.SetSummaryPrompt("没有占位符"); err == nil {
+		t.Error("Expected error for template without {{conversation}}")
+	}
+	if err := manager.SetSummaryPrompt("旧：{{previous_summary}}\n对话：\n{{conversation}}"); err != nil {
+		t.Fatalf("SetSummaryPrompt failed: %v", err)
+	}
+	prompt := manager.renderSummaryPrompt("旧摘要", []models.Message{{Role: "user", Content: "你好"}})
+	if prompt != "旧：旧摘要\n对话：\nuser: 你好\n" {
+		t.Errorf("Unexpected rendered prompt: %q", prompt)
+	}
+
+	// 按token阈值触发
+	manager.SetSummaryThreshold(100)
+	manager.SetSummaryTokenThreshold(10)
+	if !manager.shouldAutoSummary([]models.Message{{Role: "user", Content: strings.Repeat("长消息", 20)}}) {
+		t.Error("Expected token threshold to trigger summary")
+	}
+
+	// 版本按上限保留最新的
+	manager.SetMaxSummaryVersions(2)
+	session, _ := manager.GetOrCreateSession("versions", "qwen")
+	session.mu.Lock()
+	for i := 1; i <= 3; i++ {
+		manager.setSummaryLocked(session, fmt.Sprintf("摘要%d", i), "threshold")
+	}
+	session.mu.Unlock()
+
+	versions, err := manager.GetSummaryVersions("versions")
+	if err != nil {
+		t.Fatalf("GetSummaryVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Summary != "摘要3" {
+		t.Errorf("Unexpected versions: %+v", versions)
+	}
+	if versions[1].Model != "mock-memory" {
+		t.Errorf("Expected summary model recorded, got %s", versions[1].Model)
+	}
+	if session.Summary != "摘要3" {
+		t.Errorf("Expected latest summary, got %s", session.Summary)
+	}
+}
+
 // TestEnhancedMemoryManager 测试增强版记忆管理
 func TestEnhancedMemoryManager(t *testing.T) {
 	model := &MockMemoryModel{}
@@ -229,6 +669,175 @@ func TestEnhancedMemoryManager(t *testing.T) {
 	t.Logf("Semantic search returned %d results", len(searchResults))
 }
 
+// TestLongTermMemory 测试用户长期记忆的持久化、衰减、合并与召回
+func TestLongTermMemory(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileUserMemoryStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	manager := NewEnhancedMemoryManager(nil)
+	manager.SetUserMemoryStore(store)
+
+	manager.AddMemory(ctx, &UserMemory{ID: "m1", UserID: "u1", Content: "用户喜欢Go语言", Topics: []string{"编程", "Go"}, Importance: 0.9})
+	manager.AddMemory(ctx, &UserMemory{ID: "m2", UserID: "u1", Content: "用户常用Go写后端", Topics: []string{"go", "编程"}, Importance: 0.6})
+	manager.AddMemory(ctx, &UserMemory{ID: "m3", UserID: "u1", Content: "用户下周去杭州出差", Topics: []string{"出行"}, Importance: 0.4})
+
+	// 模拟进程重启：新的管理器从同一存储加载
+	restarted := NewEnhancedMemoryManager(nil)
+	restarted.SetUserMemoryStore(store)
+	if got := len(restarted.GetMemories("u1", 0)); got != 3 {
+		t.Fatalf("Expected 3 persisted memories, got %d", got)
+	}
+
+	// 主题完全重合的记忆被合并
+	merged, err := restarted.Consolidate(ctx, "u1")
+	if err != nil || merged != 1 {
+		t.Fatalf("Expected 1 merged memory, got %d (%v)", merged, err)
+	}
+
+	// 长期未访问的低重要性记忆衰减后被清理
+	restarted.decayHalfLife = time.Hour
+	for _, memory := range restarted.GetMemories("u1", 0) {
+		if memory.ID == "m3" {
+			memory.AccessedAt = time.Now().Add(-10 * time.Hour)
+		}
+	}
+	pruned, err := restarted.Prune(ctx, "u1")
+	if err != nil || pruned != 1 {
+		t.Fatalf("Expected 1 pruned memory, got %d (%v)", pruned, err)
+	}
+
+	recalled, err := restarted.RecallForContext(ctx, "u1", "推荐一门语言", 5)
+	if err != nil || len(recalled) != 1 {
+		t.Fatalf("Expected 1 recalled memory, got %d (%v)", len(recalled), err)
+	}
+	if !strings.Contains(recalled[0].Content, "用户喜欢Go语言") || recalled[0].AccessCount != 1 {
+		t.Errorf("Unexpected recalled memory: %+v", recalled[0])
+	}
+	if text := FormatMemoryContext(recalled); !strings.Contains(text, "用户喜欢Go语言") {
+		t.Errorf("Expected memory in context, got %s", text)
+	}
+
+	// 最终状态已写回存储
+	stored, _ := store.LoadUser(ctx, "u1")
+	if len(stored) != 1 || stored[0].AccessCount != 1 {
+		t.Errorf("Expected consolidated memory persisted, got %d", len(stored))
+	}
+}
+
+// TestMemoryScopes 测试共享记忆作用域、访问控制与检索优先级
+func TestMemoryScopes(t *testing.T) {
+	ctx := context.Background()
+	manager := NewEnhancedMemoryManager(nil)
+	manager.SetMemoryACL(NewMemoryACL(config.MemoryScopeConfig{
+		Teams:  map[string][]string{"platform": {"alice", "bob"}},
+		Admins: []string{"alice"},
+	}))
+
+	// 访问控制
+	err := manager.AddScopedMemory(ctx, "carol", &UserMemory{Scope: MemoryScopeTeam, TeamID: "platform", Content: "团队规范"})
+	if !errors.Is(err, ErrMemoryAccessDenied) {
+		t.Errorf("Expected non-member to be denied, got %v", err)
+	}
+	err = manager.AddScopedMemory(ctx, "bob", &UserMemory{Scope: MemoryScopeGlobal, Content: "公司规定"})
+	if !errors.Is(err, ErrMemoryAccessDenied) {
+		t.Errorf("Expected non-admin global write to be denied, got %v", err)
+	}
+
+	manager.AddScopedMemory(ctx, "bob", &UserMemory{Scope: MemoryScopeTeam, TeamID: "platform", Content: "团队默认使用PostgreSQL", Topics: []string{"数据库"}, Importance: 0.9})
+	manager.AddScopedMemory(ctx, "alice", &UserMemory{Scope: MemoryScopeGlobal, Content: "公司位于杭州", Topics: []string{"公司"}, Importance: 0.9})
+	manager.AddScopedMemory(ctx, "bob", &UserMemory{Content: "bob偏好SQLite", Topics: []string{"数据库"}, Importance: 0.8})
+
+	if _, err := manager.ListScopedMemories(ctx, "carol", MemoryScopeTeam, "platform"); !errors.Is(err, ErrMemoryAccessDenied) {
+		t.Errorf("Expected non-member read to be denied, got %v", err)
+	}
+	if global, _ := manager.ListScopedMemories(ctx, "carol", MemoryScopeGlobal, ""); len(global) != 1 {
+		t.Errorf("Expected global memory visible to everyone, got %d", len(global))
+	}
+	if personal := manager.ListMemories("bob"); len(personal) != 1 {
+		t.Errorf("Shared memories must not appear in personal scope, got %d", len(personal))
+	}
+
+	// 用户记忆覆盖相关的团队记忆
+	recalled, _ := manager.RecallForContext(ctx, "bob", "数据库", 10)
+	contents := make([]string, 0, len(recalled))
+	for _, memory := range recalled {
+		contents = append(contents, memory.Content)
+	}
+	if len(recalled) != 2 || recalled[0].Content != "bob偏好SQLite" {
+		t.Errorf("Expected personal memory first and team memory shadowed, got %v", contents)
+	}
+
+	// 非团队成员只能检索到全局记忆
+	recalled, _ = manager.RecallForContext(ctx, "carol", "", 10)
+	if len(recalled) != 1 || recalled[0].Scope != MemoryScopeGlobal {
+		t.Errorf("Expected only global memory for carol, got %d", len(recalled))
+	}
+	if text := FormatMemoryContext(recalled); !strings.Contains(text, "[组织] 公司位于杭州") {
+		t.Errorf("Expected scope label in context, got %s", text)
+	}
+}
+
+// TestUserDataDeletion 测试用户记忆的增删改、导出与整体删除
+func TestUserDataDeletion(t *testing.T) {
+	ctx := context.Background()
+	memories := NewEnhancedMemoryManager(nil)
+	sessions := NewEnhancedSessionManager(10, "memory", nil)
+	sessions.SetSessionStore(NewMemorySessionStore(0), 0)
+
+	memories.AddMemory(ctx, &UserMemory{ID: "m1", UserID: "u1", Content: "用户叫小王", Importance: 0.8})
+	memories.AddMemory(ctx, &UserMemory{ID: "m2", UserID: "u1", Content: "用户住在上海", Importance: 0.6})
+	memories.AddMemory(ctx, &UserMemory{ID: "m3", UserID: "u2", Content: "其他用户", Importance: 0.6})
+
+	sessions.GetOrCreateSession("u1-s1", "qwen")
+	sessions.SetMetadata("u1-s1", map[string]interface{}{SessionUserIDKey: "u1"})
+	sessions.AddMessage("u1-s1", models.Message{Role: "user", Content: "你好"})
+	sessions.GetOrCreateSession("u2-s1", "qwen")
+	sessions.SetMetadata("u2-s1", map[string]interface{}{SessionUserIDKey: "u2"})
+
+	content := "用户叫王小明"
+	updated, err := memories.UpdateMemory(ctx, "u1", "m1", MemoryUpdate{Content: &content})
+	if err != nil || updated.Content != content {
+		t.Fatalf("Failed to update memory: %v", err)
+	}
+	invalid := 1.5
+	if _, err := memories.UpdateMemory(ctx, "u1", "m1", MemoryUpdate{Importance: &invalid}); err == nil {
+		t.Error("Expected error for out-of-range importance")
+	}
+	if err := memories.DeleteMemory(ctx, "u1", "m2"); err != nil {
+		t.Fatalf("Failed to delete memory: %v", err)
+	}
+	if err := memories.DeleteMemory(ctx, "u1", "m2"); !errors.Is(err, ErrMemoryNotFound) {
+		t.Errorf("Expected ErrMemoryNotFound, got %v", err)
+	}
+
+	export := ExportUserData("u1", memories, sessions)
+	if len(export.Memories) != 1 || len(export.Sessions) != 1 || len(export.Sessions[0].Messages) != 1 {
+		t.Fatalf("Unexpected export: %d memories, %d sessions", len(export.Memories), len(export.Sessions))
+	}
+
+	result, err := DeleteUserData(ctx, "u1", memories, sessions)
+	if err != nil {
+		t.Fatalf("Failed to delete user data: %v", err)
+	}
+	if result.MemoriesDeleted != 1 || len(result.SessionsDeleted) != 1 {
+		t.Errorf("Unexpected deletion result: %+v", result)
+	}
+	if len(memories.ListMemories("u1")) != 0 {
+		t.Error("Expected no memories left for u1")
+	}
+	if _, err := sessions.GetSession("u1-s1"); err == nil {
+		t.Error("Expected u1 session to be deleted")
+	}
+
+	// 其他用户的数据不受影响
+	if len(memories.ListMemories("u2")) != 1 || sessions.GetSessionCount() != 1 {
+		t.Error("Other users' data must be kept")
+	}
+}
+
 // TestMemoryOptimization 测试记忆优化
 func TestMemoryOptimization(t *testing.T) {
 	model := &MockMemoryModel{}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/pkg/models"
+)
+
+// 分支会话元数据中记录来源的键
+const (
+	SessionForkedFromKey = "forked_from"
+	SessionForkIndexKey  = "fork_index"
+)
+
+// ErrSessionExists 会话已存在
+var ErrSessionExists = errors.New("session already exists")
+
+// ForkSession 从已有会话的指定消息位置分叉出新会话，原会话不受影响
+// 新会话包含messages[:atIndex]，atIndex<0表示复制全部消息；摘要、状态和元数据一并复制
+// newID为空时自动生成
+func (m *EnhancedSessionManager) ForkSession(sourceID, newID string, atIndex int) (*EnhancedSession, error) {
+	source, err := m.GetSession(sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if newID == "" {
+		newID = fmt.Sprintf("%s-fork-%d", sourceID, time.Now().UnixNano())
+	}
+	if _, err := m.GetSession(newID); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, newID)
+	}
+
+	source.mu.RLock()
+	if atIndex < 0 {
+		atIndex = len(source.Messages)
+	}
+	if atIndex > len(source.Messages) {
+		source.mu.RUnlock()
+		return nil, fmt.Errorf("message index %d out of range (session has %d messages)", atIndex, len(source.Messages))
+	}
+
+	now := time.Now()
+	fork := &EnhancedSession{
+		ID:       newID,
+		Model:    source.Model,
+		Messages: append(make([]models.Message, 0, atIndex), source.Messages[:atIndex]...),
+		Summary:  source.Summary,
+		State: SessionState{
+			Data:      copyMap(source.State.Data),
+			Version:   source.State.Version,
+			UpdatedAt: now,
+		},
+		Metadata:  copyMap(source.Metadata),
+		TTL:       source.TTL,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	source.mu.RUnlock()
+
+	fork.Metadata[SessionForkedFromKey] = sourceID
+	fork.Metadata[SessionForkIndexKey] = atIndex
+
+	m.mu.Lock()
+	if _, exists := m.sessions[newID]; exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, newID)
+	}
+	m.sessions[newID] = fork
+	m.mu.Unlock()
+
+	fork.mu.Lock()
+	defer fork.mu.Unlock()
+	if err := m.persist(fork); err != nil {
+		m.mu.Lock()
+		delete(m.sessions, newID)
+		m.mu.Unlock()
+		return nil, err
+	}
+	return fork, nil
+}
+
+// ListForks 列出从指定会话直接分叉出的会话
+func (m *EnhancedSessionManager) ListForks(sessionID string) []SessionInfo {
+	forks := make([]SessionInfo, 0)
+	for _, id := range m.ListSessions() {
+		session, err := m.GetSession(id)
+		if err != nil {
+			continue
+		}
+		if info := m.sessionInfo(session); info.ForkedFrom == sessionID {
+			forks = append(forks, info)
+		}
+	}
+	return forks
+}
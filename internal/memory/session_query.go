@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionUserIDKey 会话元数据中记录所属用户的键
+const SessionUserIDKey = "user_id"
+
+// SessionQuery 会话查询条件，零值字段不参与过滤
+type SessionQuery struct {
+	IDPrefix     string // 会话ID前缀，多租户时用于只列出本租户的会话
+	UserID       string
+	Model        string
+	ActiveSince  time.Time // 最后活跃时间不早于
+	ActiveBefore time.Time // 最后活跃时间早于
+	Offset       int
+	Limit        int // 0表示不分页
+}
+
+// SessionInfo 会话概要信息
+type SessionInfo struct {
+	ID           string     `json:"session_id"`
+	Model        string     `json:"model"`
+	UserID       string     `json:"user_id,omitempty"`
+	ForkedFrom   string     `json:"forked_from,omitempty"`
+	MessageCount int        `json:"message_count"`
+	HasSummary   bool       `json:"has_summary"`
+	TTL          string     `json:"ttl,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// EvictionStats 空闲会话淘汰统计
+type EvictionStats struct {
+	TotalEvicted int64     `json:"total_evicted"`
+	StorePurged  int64     `json:"store_purged"`
+	LastRunAt    time.Time `json:"last_run_at"`
+	LastEvicted  []string  `json:"last_evicted"`
+}
+
+// QuerySessions 按条件查询会话，按最后活跃时间倒序分页，返回当前页和总数
+func (m *EnhancedSessionManager) QuerySessions(query SessionQuery) ([]SessionInfo, int) {
+	matched := make([]SessionInfo, 0)
+	for _, id := range m.ListSessions() {
+		if !strings.HasPrefix(id, query.IDPrefix) {
+			continue
+		}
+		session, err := m.GetSession(id)
+		if err != nil {
+			continue // 列出后已过期或被删除
+		}
+
+		info := m.sessionInfo(session)
+		if query.UserID != "" && info.UserID != query.UserID {
+			continue
+		}
+		if query.Model != "" && info.Model != query.Model {
+			continue
+		}
+		if !query.ActiveSince.IsZero() && info.UpdatedAt.Before(query.ActiveSince) {
+			continue
+		}
+		if !query.ActiveBefore.IsZero() && !info.UpdatedAt.Before(query.ActiveBefore) {
+			continue
+		}
+		matched = append(matched, info)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].UpdatedAt.After(matched[j].UpdatedAt)
+	})
+
+	total := len(matched)
+	if query.Offset >= total {
+		return []SessionInfo{}, total
+	}
+	matched = matched[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(matched) {
+		matched = matched[:query.Limit]
+	}
+	return matched, total
+}
+
+// sessionInfo 生成会话概要
+func (m *EnhancedSessionManager) sessionInfo(session *EnhancedSession) SessionInfo {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+
+	info := SessionInfo{
+		ID:           session.ID,
+		Model:        session.Model,
+		MessageCount: len(session.Messages),
+		HasSummary:   session.Summary != "",
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+	}
+	if userID, ok := session.Metadata[SessionUserIDKey].(string); ok {
+		info.UserID = userID
+	}
+	if source, ok := session.Metadata[SessionForkedFromKey].(string); ok {
+		info.ForkedFrom = source
+	}
+
+	ttl := m.sessionTTL
+	if session.TTL > 0 {
+		ttl = session.TTL
+	}
+	if ttl > 0 {
+		expiresAt := session.UpdatedAt.Add(ttl)
+		info.TTL = ttl.String()
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
+
+// SetSessionTTL 设置单个会话的空闲过期时间，ttl为0时恢复为管理器默认值
+func (m *EnhancedSessionManager) SetSessionTTL(sessionID string, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+
+	session, err := m.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.TTL = ttl
+	return m.persist(session)
+}
+
+// EvictIdleSessions 淘汰超过空闲过期时间的会话，返回被淘汰的会话ID
+// 同时清理持久化存储中已过期的记录（Redis由键过期自动清理）
+func (m *EnhancedSessionManager) EvictIdleSessions() []string {
+	m.mu.RLock()
+	candidates := make(map[string]*EnhancedSession)
+	for id, session := range m.sessions {
+		if m.expired(session) {
+			candidates[id] = session
+		}
+	}
+	store := m.store
+	m.mu.RUnlock()
+
+	evicted := make([]string, 0, len(candidates))
+	m.mu.Lock()
+	for id, session := range candidates {
+		if m.sessions[id] == session {
+			delete(m.sessions, id)
+			evicted = append(evicted, id)
+		}
+	}
+	m.mu.Unlock()
+	sort.Strings(evicted)
+
+	var purged int64
+	if expiring, ok := store.(ExpiringSessionStore); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		purged, _ = expiring.PurgeExpired(ctx)
+		cancel()
+	}
+
+	m.mu.Lock()
+	m.eviction.TotalEvicted += int64(len(evicted))
+	m.eviction.StorePurged += purged
+	m.eviction.LastRunAt = time.Now()
+	m.eviction.LastEvicted = evicted
+	m.mu.Unlock()
+
+	return evicted
+}
+
+// StartIdleEviction 启动后台空闲会话淘汰，ctx取消时停止
+func (m *EnhancedSessionManager) StartIdleEviction(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.EvictIdleSessions()
+			}
+		}
+	}()
+}
+
+// GetEvictionStats 获取空闲会话淘汰统计
+func (m *EnhancedSessionManager) GetEvictionStats() EvictionStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := m.eviction
+	stats.LastEvicted = append([]string(nil), m.eviction.LastEvicted...)
+	return stats
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/pkg/models"
+)
+
+// ErrSessionNotFound 会话不存在或已过期
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRecord 会话的持久化快照
+type SessionRecord struct {
+	ID              string                 `json:"id"`
+	Model           string                 `json:"model"`
+	Messages        []models.Message       `json:"messages"`
+	Summary         string                 `json:"summary"`
+	SummaryVersions []SummaryVersion       `json:"summary_versions,omitempty"`
+	State           SessionState           `json:"state"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	TTL             time.Duration          `json:"ttl,omitempty"` // 会话级过期时间，0表示使用存储默认值
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// effectiveTTL 计算会话的实际过期时间
+func (r *SessionRecord) effectiveTTL(defaultTTL time.Duration) time.Duration {
+	if r.TTL > 0 {
+		return r.TTL
+	}
+	return defaultTTL
+}
+
+// SessionStore 会话存储后端
+// 过期时间从最后一次Save开始计算，ttl<=0表示永不过期
+type SessionStore interface {
+	// Load 加载会话，不存在或已过期时返回ErrSessionNotFound
+	Load(ctx context.Context, sessionID string) (*SessionRecord, error)
+
+	// Save 保存会话并刷新过期时间
+	Save(ctx context.Context, record *SessionRecord) error
+
+	// Delete 删除会话
+	Delete(ctx context.Context, sessionID string) error
+
+	// List 列出未过期的会话ID
+	List(ctx context.Context) ([]string, error)
+
+	// Close 关闭存储
+	Close() error
+}
+
+// ExpiringSessionStore 需要主动清理过期会话的存储（Redis依赖键过期，无需实现）
+type ExpiringSessionStore interface {
+	// PurgeExpired 删除已过期的会话，返回删除数量
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// NewSessionStoreFromConfig 根据配置创建会话存储
+func NewSessionStoreFromConfig(cfg config.MemoryConfig) (SessionStore, error) {
+	ttl := time.Duration(0)
+	if cfg.SessionTTL != "" {
+		parsed, err := time.ParseDuration(cfg.SessionTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session_ttl %q: %w", cfg.SessionTTL, err)
+		}
+		ttl = parsed
+	}
+
+	switch strings.ToLower(cfg.StoreType) {
+	case "", "memory":
+		return NewMemorySessionStore(ttl), nil
+	case "redis":
+		return NewRedisSessionStore(cfg.Redis, ttl)
+	case "postgres", "postgresql":
+		return OpenPostgresSessionStore(cfg.Postgres, ttl)
+	default:
+		return nil, fmt.Errorf("unsupported session store: %s", cfg.StoreType)
+	}
+}
+
+// memorySessionEntry 内存存储条目
+type memorySessionEntry struct {
+	record    *SessionRecord
+	expiresAt time.Time
+}
+
+// MemorySessionStore 内存会话存储（进程重启后丢失）
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*memorySessionEntry
+	ttl      time.Duration
+}
+
+// NewMemorySessionStore 创建内存会话存储
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*memorySessionEntry),
+		ttl:      ttl,
+	}
+}
+
+// Load 加载会话
+func (s *MemorySessionStore) Load(ctx context.Context, sessionID string) (*SessionRecord, error) {
+	s.mu.RLock()
+	entry, exists := s.sessions[sessionID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	if s.expired(entry) {
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		return nil, ErrSessionNotFound
+	}
+
+	return entry.record, nil
+}
+
+// Save 保存会话
+func (s *MemorySessionStore) Save(ctx context.Context, record *SessionRecord) error {
+	entry := &memorySessionEntry{record: record}
+	if ttl := record.effectiveTTL(s.ttl); ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	s.mu.Lock()
+	s.sessions[record.ID] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete 删除会话
+func (s *MemorySessionStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	return nil
+}
+
+// List 列出未过期的会话ID
+func (s *MemorySessionStore) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.sessions))
+	for id, entry := range s.sessions {
+		if s.expired(entry) {
+			delete(s.sessions, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// PurgeExpired 删除已过期的会话
+func (s *MemorySessionStore) PurgeExpired(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, entry := range s.sessions {
+		if s.expired(entry) {
+			delete(s.sessions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Close 关闭存储
+func (s *MemorySessionStore) Close() error {
+	return nil
+}
+
+// expired 条目是否已过期
+func (s *MemorySessionStore) expired(entry *memorySessionEntry) bool {
+	return !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)
+}
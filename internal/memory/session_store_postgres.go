@@ -0,0 +1,369 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/pkg/models"
+)
+
+// sessionMigration 会话表结构迁移
+type sessionMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// postgresSessionMigrations 会话表迁移，按版本顺序执行
+// 版本1与database/schema.sql中的sessions/messages表结构一致，之后的版本增加元数据、过期时间、会话级TTL、摘要版本和消息附注
+var postgresSessionMigrations = []sessionMigration{
+	{
+		version:     1,
+		description: "create sessions and messages tables",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS sessions (
+				id VARCHAR(255) PRIMARY KEY,
+				model VARCHAR(50) NOT NULL DEFAULT '',
+				summary TEXT,
+				state JSONB,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_updated ON sessions(updated_at DESC)`,
+			`CREATE TABLE IF NOT EXISTS messages (
+				id BIGSERIAL PRIMARY KEY,
+				session_id VARCHAR(255) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+				role VARCHAR(20) NOT NULL,
+				content TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_messages_session_created ON messages(session_id, created_at)`,
+		},
+	},
+	{
+		version:     2,
+		description: "add session metadata, expiry and message tool id",
+		statements: []string{
+			`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS metadata JSONB`,
+			`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at)`,
+			`ALTER TABLE messages ADD COLUMN IF NOT EXISTS tool_id VARCHAR(255) NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     3,
+		description: "add per-session ttl",
+		statements: []string{
+			`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ttl_seconds BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version:     4,
+		description: "add summary versions",
+		statements: []string{
+			`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summary_versions JSONB`,
+		},
+	},
+	{
+		version:     5,
+		description: "add message annotations",
+		statements: []string{
+			`ALTER TABLE messages ADD COLUMN IF NOT EXISTS annotations JSONB`,
+		},
+	},
+}
+
+// messageAnnotations 消息附注（工具调用和引用），存储在messages.annotations列
+type messageAnnotations struct {
+	ToolCalls []models.ToolCall `json:"tool_calls,omitempty"`
+	Citations []models.Citation `json:"citations,omitempty"`
+}
+
+// PostgresSessionStore Postgres会话存储
+// 过期时间记录在expires_at列，读取时过滤，PurgeExpired定期清理
+type PostgresSessionStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// OpenPostgresSessionStore 根据配置连接Postgres并创建会话存储
+// 需要在main包中匿名导入对应的database/sql驱动（如 github.com/lib/pq）
+func OpenPostgresSessionStore(cfg config.PostgresSessionConfig, ttl time.Duration) (*PostgresSessionStore, error) {
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("postgres dsn is required")
+	}
+	driver := cfg.Driver
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres (is the %q driver imported?): %w", driver, err)
+	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	store, err := NewPostgresSessionStore(ctx, db, ttl)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewPostgresSessionStore 使用已有连接创建会话存储，并执行表结构迁移
+func NewPostgresSessionStore(ctx context.Context, db *sql.DB, ttl time.Duration) (*PostgresSessionStore, error) {
+	store := &PostgresSessionStore{
+		db:  db,
+		ttl: ttl,
+	}
+	if err := store.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate 执行尚未应用的表结构迁移
+func (s *PostgresSessionStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS session_schema_migrations (
+		version INT PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current := 0
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM session_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, migration := range postgresSessionMigrations {
+		if migration.version <= current {
+			continue
+		}
+		if err := s.applyMigration(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration 在事务中执行单个迁移
+func (s *PostgresSessionStore) applyMigration(ctx context.Context, migration sessionMigration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", migration.version, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range migration.statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", migration.version, migration.description, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO session_schema_migrations (version, description) VALUES ($1, $2)`,
+		migration.version, migration.description); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.version, err)
+	}
+
+	return tx.Commit()
+}
+
+// Load 加载会话
+func (s *PostgresSessionStore) Load(ctx context.Context, sessionID string) (*SessionRecord, error) {
+	record := &SessionRecord{ID: sessionID}
+	var summary sql.NullString
+	var state, metadata, versions []byte
+	var ttlSeconds int64
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT model, summary, summary_versions, state, metadata, ttl_seconds, created_at, updated_at
+		FROM sessions
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW())`,
+		sessionID).Scan(&record.Model, &summary, &versions, &state, &metadata, &ttlSeconds, &record.CreatedAt, &record.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	record.Summary = summary.String
+	record.TTL = time.Duration(ttlSeconds) * time.Second
+
+	if len(state) > 0 {
+		if err := json.Unmarshal(state, &record.State); err != nil {
+			return nil, fmt.Errorf("failed to decode session state: %w", err)
+		}
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &record.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode session metadata: %w", err)
+		}
+	}
+	if len(versions) > 0 {
+		if err := json.Unmarshal(versions, &record.SummaryVersions); err != nil {
+			return nil, fmt.Errorf("failed to decode summary versions: %w", err)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT role, content, tool_id, annotations FROM messages WHERE session_id = $1 ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	defer rows.Close()
+
+	record.Messages = make([]models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		var annotations []byte
+		if err := rows.Scan(&msg.Role, &msg.Content, &msg.ToolID, &annotations); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		if len(annotations) > 0 {
+			var a messageAnnotations
+			if err := json.Unmarshal(annotations, &a); err != nil {
+				return nil, fmt.Errorf("failed to decode message annotations: %w", err)
+			}
+			msg.ToolCalls, msg.Citations = a.ToolCalls, a.Citations
+		}
+		record.Messages = append(record.Messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+
+	return record, nil
+}
+
+// Save 保存会话并刷新过期时间
+// 消息列表整体替换，以与内存中裁剪后的历史保持一致
+func (s *PostgresSessionStore) Save(ctx context.Context, record *SessionRecord) error {
+	state, err := json.Marshal(record.State)
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+	metadata, err := json.Marshal(record.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode session metadata: %w", err)
+	}
+	versions, err := json.Marshal(record.SummaryVersions)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary versions: %w", err)
+	}
+
+	var expiresAt sql.NullTime
+	if ttl := record.effectiveTTL(s.ttl); ttl > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(ttl), Valid: true}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, model, summary, state, metadata, ttl_seconds, created_at, updated_at, expires_at, summary_versions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			model = EXCLUDED.model,
+			summary = EXCLUDED.summary,
+			summary_versions = EXCLUDED.summary_versions,
+			state = EXCLUDED.state,
+			metadata = EXCLUDED.metadata,
+			ttl_seconds = EXCLUDED.ttl_seconds,
+			updated_at = EXCLUDED.updated_at,
+			expires_at = EXCLUDED.expires_at`,
+		record.ID, record.Model, record.Summary, state, metadata, int64(record.TTL/time.Second),
+		record.CreatedAt, record.UpdatedAt, expiresAt, versions)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE session_id = $1`, record.ID); err != nil {
+		return fmt.Errorf("failed to replace messages: %w", err)
+	}
+	for _, msg := range record.Messages {
+		var annotations []byte
+		if len(msg.ToolCalls) > 0 || len(msg.Citations) > 0 {
+			annotations, err = json.Marshal(messageAnnotations{ToolCalls: msg.ToolCalls, Citations: msg.Citations})
+			if err != nil {
+				return fmt.Errorf("failed to encode message annotations: %w", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (session_id, role, content, tool_id, annotations) VALUES ($1, $2, $3, $4, $5)`,
+			record.ID, msg.Role, msg.Content, msg.ToolID, annotations); err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete 删除会话（消息级联删除）
+func (s *PostgresSessionStore) Delete(ctx context.Context, sessionID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// List 列出未过期的会话ID
+func (s *PostgresSessionStore) List(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM sessions
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeExpired 删除已过期的会话，返回删除数量
+func (s *PostgresSessionStore) PurgeExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Close 关闭数据库连接
+func (s *PostgresSessionStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSessionPrefix Redis会话键前缀
+const redisSessionPrefix = "ai-agent:session:"
+
+// RedisSessionStore Redis会话存储，使用键过期实现TTL
+type RedisSessionStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisSessionStore 创建Redis会话存储
+func NewRedisSessionStore(cfg config.RedisConfig, ttl time.Duration) (*RedisSessionStore, error) {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return NewRedisSessionStoreWithClient(client, ttl), nil
+}
+
+// NewRedisSessionStoreWithClient 使用已有的Redis客户端创建会话存储
+func NewRedisSessionStoreWithClient(client *redis.Client, ttl time.Duration) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Load 加载会话
+func (s *RedisSessionStore) Load(ctx context.Context, sessionID string) (*SessionRecord, error) {
+	data, err := s.client.Get(ctx, redisSessionPrefix+sessionID).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	var record SessionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &record, nil
+}
+
+// Save 保存会话并刷新过期时间
+func (s *RedisSessionStore) Save(ctx context.Context, record *SessionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// ttl为0时Redis不设置过期
+	if err := s.client.Set(ctx, redisSessionPrefix+record.ID, data, record.effectiveTTL(s.ttl)).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除会话
+func (s *RedisSessionStore) Delete(ctx context.Context, sessionID string) error {
+	if err := s.client.Del(ctx, redisSessionPrefix+sessionID).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// List 列出未过期的会话ID
+func (s *RedisSessionStore) List(ctx context.Context) ([]string, error) {
+	ids := make([]string, 0)
+	iter := s.client.Scan(ctx, 0, redisSessionPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), redisSessionPrefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return ids, nil
+}
+
+// Close 关闭Redis连接
+func (s *RedisSessionStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// 摘要提示词模板占位符
+const (
+	SummaryPlaceholderConversation    = "{{conversation}}"
+	SummaryPlaceholderPreviousSummary = "{{previous_summary}}"
+)
+
+// defaultMaxSummaryVersions 默认保留的摘要版本数
+const defaultMaxSummaryVersions = 10
+
+// SummaryVersion 会话摘要的一个历史版本
+type SummaryVersion struct {
+	Version      int       `json:"version"`
+	Summary      string    `json:"summary"`
+	Model        string    `json:"model,omitempty"`
+	Trigger      string    `json:"trigger"`       // threshold, overflow, fold
+	MessageCount int       `json:"message_count"` // 生成摘要时会话中的消息数
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SetSummaryPrompt 设置摘要提示词模板
+// 模板中的{{conversation}}替换为对话内容，{{previous_summary}}替换为已有摘要；为空时使用内置提示词
+func (m *EnhancedSessionManager) SetSummaryPrompt(template string) error {
+	if err := ValidateSummaryPrompt(template); err != nil {
+		return err
+	}
+	m.promptMu.Lock()
+	defer m.promptMu.Unlock()
+	m.summaryPrompt = template
+	return nil
+}
+
+// ValidateSummaryPrompt 校验摘要提示词模板，空模板表示使用内置提示词
+func ValidateSummaryPrompt(template string) error {
+	if template != "" && !strings.Contains(template, SummaryPlaceholderConversation) {
+		return fmt.Errorf("summary prompt template must contain %s", SummaryPlaceholderConversation)
+	}
+	return nil
+}
+
+// summaryTemplate 当前的摘要提示词模板
+func (m *EnhancedSessionManager) summaryTemplate() string {
+	m.promptMu.RLock()
+	defer m.promptMu.RUnlock()
+	return m.summaryPrompt
+}
+
+// SetSummaryTokenThreshold 设置按token数触发自动摘要的阈值，0表示只按消息数触发
+func (m *EnhancedSessionManager) SetSummaryTokenThreshold(threshold int) {
+	m.summaryTokenThreshold = threshold
+}
+
+// SetMaxSummaryVersions 设置每个会话保留的摘要版本数
+func (m *EnhancedSessionManager) SetMaxSummaryVersions(n int) {
+	m.maxSummaryVersions = n
+}
+
+// shouldAutoSummary 是否达到自动摘要的触发阈值
+func (m *EnhancedSessionManager) shouldAutoSummary(messages []models.Message) bool {
+	if len(messages) > m.summaryThreshold {
+		return true
+	}
+	return m.summaryTokenThreshold > 0 && llm.EstimateMessagesTokens(messages) > m.summaryTokenThreshold
+}
+
+// renderSummaryPrompt 使用模板渲染摘要提示词
+func (m *EnhancedSessionManager) renderSummaryPrompt(previous string, messages []models.Message) string {
+	var conversation strings.Builder
+	for _, msg := range messages {
+		conversation.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	prompt := strings.ReplaceAll(m.summaryTemplate(), SummaryPlaceholderConversation, conversation.String())
+	return strings.ReplaceAll(prompt, SummaryPlaceholderPreviousSummary, previous)
+}
+
+// setSummaryLocked 更新会话摘要并记录新版本，调用方需持有session的锁
+func (m *EnhancedSessionManager) setSummaryLocked(session *EnhancedSession, summary, trigger string) {
+	session.Summary = summary
+
+	version := 1
+	if n := len(session.SummaryVersions); n > 0 {
+		version = session.SummaryVersions[n-1].Version + 1
+	}
+
+	modelName := ""
+	if m.summaryModel != nil {
+		modelName = m.summaryModel.GetModelName()
+	}
+
+	session.SummaryVersions = append(session.SummaryVersions, SummaryVersion{
+		Version:      version,
+		Summary:      summary,
+		Model:        modelName,
+		Trigger:      trigger,
+		MessageCount: len(session.Messages),
+		CreatedAt:    time.Now(),
+	})
+
+	limit := m.maxSummaryVersions
+	if limit <= 0 {
+		limit = defaultMaxSummaryVersions
+	}
+	if len(session.SummaryVersions) > limit {
+		session.SummaryVersions = session.SummaryVersions[len(session.SummaryVersions)-limit:]
+	}
+}
+
+// GetSummaryVersions 获取会话的摘要历史版本（从旧到新）
+func (m *EnhancedSessionManager) GetSummaryVersions(sessionID string) ([]SummaryVersion, error) {
+	session, err := m.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+
+	versions := make([]SummaryVersion, len(session.SummaryVersions))
+	copy(versions, session.SummaryVersions)
+	return versions, nil
+}
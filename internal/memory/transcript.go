@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/pkg/models"
+)
+
+// Transcript 会话记录导出，用于审计和分享
+type Transcript struct {
+	SessionID  string                 `json:"session_id"`
+	Model      string                 `json:"model"`
+	Summary    string                 `json:"summary,omitempty"` // 已移出历史窗口的早期对话摘要
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Messages   []models.Message       `json:"messages"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+	ExportedAt time.Time              `json:"exported_at"`
+}
+
+// ExportTranscript 导出会话记录
+func (m *EnhancedSessionManager) ExportTranscript(sessionID string) (*Transcript, error) {
+	session, err := m.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+
+	messages := make([]models.Message, len(session.Messages))
+	copy(messages, session.Messages)
+
+	return &Transcript{
+		SessionID:  session.ID,
+		Model:      session.Model,
+		Summary:    session.Summary,
+		Metadata:   copyMap(session.Metadata),
+		Messages:   messages,
+		CreatedAt:  session.CreatedAt,
+		UpdatedAt:  session.UpdatedAt,
+		ExportedAt: time.Now(),
+	}, nil
+}
+
+// transcriptRoleTitles 各角色在Markdown中的标题
+var transcriptRoleTitles = map[string]string{
+	"system":    "系统",
+	"user":      "用户",
+	"assistant": "助手",
+	"tool":      "工具",
+}
+
+// Markdown 将会话记录渲染为Markdown
+func (t *Transcript) Markdown() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# 会话记录 %s\n\n", t.SessionID))
+	sb.WriteString(fmt.Sprintf("- 模型：%s\n", t.Model))
+	sb.WriteString(fmt.Sprintf("- 创建时间：%s\n", t.CreatedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- 最后活跃：%s\n", t.UpdatedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- 导出时间：%s\n", t.ExportedAt.Format(time.RFC3339)))
+	if len(t.Metadata) > 0 {
+		keys := make([]string, 0, len(t.Metadata))
+		for key := range t.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("- %s：%v\n", key, t.Metadata[key]))
+		}
+	}
+
+	if t.Summary != "" {
+		sb.WriteString("\n## 早期对话摘要\n\n")
+		sb.WriteString(quoteMarkdown(t.Summary))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n## 对话\n")
+	for i, msg := range t.Messages {
+		title, ok := transcriptRoleTitles[msg.Role]
+		if !ok {
+			title = msg.Role
+		}
+		sb.WriteString(fmt.Sprintf("\n### %d. %s", i+1, title))
+		if msg.ToolID != "" {
+			sb.WriteString(fmt.Sprintf("（%s）", msg.ToolID))
+		}
+		sb.WriteString("\n\n")
+
+		if msg.Role == "tool" {
+			sb.WriteString("```\n")
+			sb.WriteString(msg.Content)
+			sb.WriteString("\n```\n")
+		} else {
+			sb.WriteString(msg.Content)
+			sb.WriteString("\n")
+		}
+
+		if len(msg.ToolCalls) > 0 {
+			sb.WriteString("\n**工具调用：**\n\n")
+			for _, call := range msg.ToolCalls {
+				sb.WriteString(fmt.Sprintf("- `%s`", call.Name))
+				if len(call.Arguments) > 0 {
+					sb.WriteString(fmt.Sprintf(" 参数：`%v`", call.Arguments))
+				}
+				if call.Result != "" {
+					sb.WriteString(fmt.Sprintf(" → %s", call.Result))
+				}
+				sb.WriteString("\n")
+			}
+		}
+
+		if len(msg.Citations) > 0 {
+			sb.WriteString("\n**引用：**\n\n")
+			for _, citation := range msg.Citations {
+				sb.WriteString(fmt.Sprintf("%d. %s", citation.Index, strings.ReplaceAll(citation.Content, "\n", " ")))
+				if citation.Source != "" {
+					sb.WriteString(fmt.Sprintf("（来源：%s）", citation.Source))
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// quoteMarkdown 将文本渲染为Markdown引用块
+func quoteMarkdown(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrMemoryNotFound 记忆不存在
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// MemoryUpdate 记忆修改内容，nil字段保持不变
+type MemoryUpdate struct {
+	Content    *string  `json:"content,omitempty"`
+	Topics     []string `json:"topics,omitempty"`
+	Importance *float64 `json:"importance,omitempty"`
+}
+
+// UserDataExport 用户数据导出（记忆+会话）
+type UserDataExport struct {
+	UserID     string           `json:"user_id"`
+	Memories   []*UserMemory    `json:"memories"`
+	Sessions   []*SessionRecord `json:"sessions"`
+	ExportedAt time.Time        `json:"exported_at"`
+}
+
+// UserDataDeletion 用户数据删除结果
+type UserDataDeletion struct {
+	UserID          string   `json:"user_id"`
+	MemoriesDeleted int      `json:"memories_deleted"`
+	SessionsDeleted []string `json:"sessions_deleted"`
+}
+
+// ListMemories 获取用户的全部记忆（不经过优化策略过滤），按创建时间排序
+func (m *EnhancedMemoryManager) ListMemories(userID string) []*UserMemory {
+	_ = m.ensureLoaded(context.Background(), userID)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	memories := make([]*UserMemory, len(m.memories[userID]))
+	copy(memories, m.memories[userID])
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].CreatedAt.Before(memories[j].CreatedAt)
+	})
+	return memories
+}
+
+// GetMemory 获取用户的单条记忆
+func (m *EnhancedMemoryManager) GetMemory(userID, memoryID string) (*UserMemory, error) {
+	if err := m.ensureLoaded(context.Background(), userID); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, memory := range m.memories[userID] {
+		if memory.ID == memoryID {
+			return memory, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMemoryNotFound, memoryID)
+}
+
+// UpdateMemory 修改用户的单条记忆
+func (m *EnhancedMemoryManager) UpdateMemory(ctx context.Context, userID, memoryID string, update MemoryUpdate) (*UserMemory, error) {
+	if update.Importance != nil && (*update.Importance < 0 || *update.Importance > 1) {
+		return nil, fmt.Errorf("importance must be between 0 and 1")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	for _, memory := range m.memories[userID] {
+		if memory.ID != memoryID {
+			continue
+		}
+
+		memory.mu.Lock()
+		if update.Content != nil && *update.Content != memory.Content {
+			memory.Content = *update.Content
+			memory.Vector = nil // 内容变化后旧向量失效
+		}
+		if update.Topics != nil {
+			memory.Topics = update.Topics
+		}
+		if update.Importance != nil {
+			memory.Importance = *update.Importance
+		}
+		memory.UpdatedAt = time.Now()
+		memory.mu.Unlock()
+
+		// 重新计算向量，失败时仅影响语义检索
+		if update.Content != nil && m.embeddingModel != nil && m.embeddingModel.SupportsEmbedding() {
+			if vector, err := m.embeddingModel.Embed(ctx, memory.Content); err == nil {
+				memory.Vector = vector
+			}
+		}
+
+		return memory, m.persistLocked(ctx, userID)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrMemoryNotFound, memoryID)
+}
+
+// DeleteMemory 删除用户的单条记忆
+func (m *EnhancedMemoryManager) DeleteMemory(ctx context.Context, userID, memoryID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return err
+	}
+
+	memories := m.memories[userID]
+	for i, memory := range memories {
+		if memory.ID == memoryID {
+			m.memories[userID] = append(memories[:i:i], memories[i+1:]...)
+			return m.persistLocked(ctx, userID)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrMemoryNotFound, memoryID)
+}
+
+// DeleteUserMemories 删除用户的全部记忆（含存储），返回删除数量
+func (m *EnhancedMemoryManager) DeleteUserMemories(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.loadLocked(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	count := len(m.memories[userID])
+	delete(m.memories, userID)
+
+	if m.store != nil {
+		if err := m.store.DeleteUser(ctx, userID); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// GetUserSessions 获取属于用户的全部会话快照
+func (m *EnhancedSessionManager) GetUserSessions(userID string) []*SessionRecord {
+	infos, _ := m.QuerySessions(SessionQuery{UserID: userID})
+
+	records := make([]*SessionRecord, 0, len(infos))
+	for _, info := range infos {
+		session, err := m.GetSession(info.ID)
+		if err != nil {
+			continue
+		}
+		session.mu.RLock()
+		records = append(records, session.record())
+		session.mu.RUnlock()
+	}
+	return records
+}
+
+// DeleteUserSessions 删除属于用户的全部会话，返回被删除的会话ID
+func (m *EnhancedSessionManager) DeleteUserSessions(userID string) ([]string, error) {
+	infos, _ := m.QuerySessions(SessionQuery{UserID: userID})
+
+	deleted := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if err := m.Clear(info.ID); err != nil {
+			return deleted, fmt.Errorf("failed to delete session %s: %w", info.ID, err)
+		}
+		deleted = append(deleted, info.ID)
+	}
+	return deleted, nil
+}
+
+// ExportUserData 导出用户的全部记忆和会话
+func ExportUserData(userID string, memories *EnhancedMemoryManager, sessions *EnhancedSessionManager) *UserDataExport {
+	export := &UserDataExport{
+		UserID:     userID,
+		Memories:   []*UserMemory{},
+		Sessions:   []*SessionRecord{},
+		ExportedAt: time.Now(),
+	}
+	if memories != nil {
+		export.Memories = memories.ListMemories(userID)
+	}
+	if sessions != nil {
+		export.Sessions = sessions.GetUserSessions(userID)
+	}
+	return export
+}
+
+// DeleteUserData 删除用户的全部记忆和会话，用于响应数据删除请求
+func DeleteUserData(ctx context.Context, userID string, memories *EnhancedMemoryManager, sessions *EnhancedSessionManager) (*UserDataDeletion, error) {
+	result := &UserDataDeletion{
+		UserID:          userID,
+		SessionsDeleted: []string{},
+	}
+
+	if memories != nil {
+		count, err := memories.DeleteUserMemories(ctx, userID)
+		result.MemoriesDeleted = count
+		if err != nil {
+			return result, fmt.Errorf("failed to delete memories: %w", err)
+		}
+	}
+
+	if sessions != nil {
+		deleted, err := sessions.DeleteUserSessions(userID)
+		result.SessionsDeleted = deleted
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
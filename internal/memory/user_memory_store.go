@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UserMemoryStore 用户长期记忆存储
+// 以用户为单位整体读写，合并与淘汰后直接覆盖
+type UserMemoryStore interface {
+	// LoadUser 加载用户的全部记忆，用户不存在时返回空列表
+	LoadUser(ctx context.Context, userID string) ([]*UserMemory, error)
+
+	// SaveUser 覆盖保存用户的全部记忆
+	SaveUser(ctx context.Context, userID string, memories []*UserMemory) error
+
+	// DeleteUser 删除用户的全部记忆
+	DeleteUser(ctx context.Context, userID string) error
+
+	// ListUsers 列出有记忆的用户
+	ListUsers(ctx context.Context) ([]string, error)
+}
+
+// NewUserMemoryStoreFromConfig 根据配置创建用户记忆存储，store为空或memory时返回nil（仅进程内）
+func NewUserMemoryStoreFromConfig(cfg config.MemoryConfig) (UserMemoryStore, error) {
+	switch strings.ToLower(cfg.UserMemory.Store) {
+	case "", "memory":
+		return nil, nil
+	case "file":
+		return NewFileUserMemoryStore(cfg.UserMemory.Path)
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			PoolSize: cfg.Redis.PoolSize,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to ping redis: %w", err)
+		}
+		return NewRedisUserMemoryStore(client), nil
+	default:
+		return nil, fmt.Errorf("unsupported user memory store: %s", cfg.UserMemory.Store)
+	}
+}
+
+// FileUserMemoryStore 文件用户记忆存储，每个用户一个JSON文件
+type FileUserMemoryStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileUserMemoryStore 创建文件用户记忆存储
+func NewFileUserMemoryStore(dir string) (*FileUserMemoryStore, error) {
+	if dir == "" {
+		dir = "./data/user_memories"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create memory directory: %w", err)
+	}
+	return &FileUserMemoryStore{dir: dir}, nil
+}
+
+// path 用户记忆文件路径（用户ID转义后作为文件名）
+func (s *FileUserMemoryStore) path(userID string) string {
+	return filepath.Join(s.dir, url.PathEscape(userID)+".json")
+}
+
+// LoadUser 加载用户记忆
+func (s *FileUserMemoryStore) LoadUser(ctx context.Context, userID string) ([]*UserMemory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(userID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*UserMemory{}, nil
+		}
+		return nil, fmt.Errorf("failed to read memories: %w", err)
+	}
+
+	var memories []*UserMemory
+	if err := json.Unmarshal(data, &memories); err != nil {
+		return nil, fmt.Errorf("failed to decode memories: %w", err)
+	}
+	return memories, nil
+}
+
+// SaveUser 保存用户记忆（先写临时文件再重命名，避免写到一半的文件）
+func (s *FileUserMemoryStore) SaveUser(ctx context.Context, userID string, memories []*UserMemory) error {
+	data, err := json.Marshal(memories)
+	if err != nil {
+		return fmt.Errorf("failed to encode memories: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(userID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write memories: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write memories: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser 删除用户记忆
+func (s *FileUserMemoryStore) DeleteUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(userID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete memories: %w", err)
+	}
+	return nil
+}
+
+// ListUsers 列出有记忆的用户
+func (s *FileUserMemoryStore) ListUsers(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memory directory: %w", err)
+	}
+
+	users := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		userID, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		users = append(users, userID)
+	}
+	return users, nil
+}
+
+// redisUserMemoryPrefix Redis用户记忆键前缀
+const redisUserMemoryPrefix = "ai-agent:user_memory:"
+
+// RedisUserMemoryStore Redis用户记忆存储，每个用户一个键
+type RedisUserMemoryStore struct {
+	client *redis.Client
+}
+
+// NewRedisUserMemoryStore 创建Redis用户记忆存储
+func NewRedisUserMemoryStore(client *redis.Client) *RedisUserMemoryStore {
+	return &RedisUserMemoryStore{client: client}
+}
+
+// LoadUser 加载用户记忆
+func (s *RedisUserMemoryStore) LoadUser(ctx context.Context, userID string) ([]*UserMemory, error) {
+	data, err := s.client.Get(ctx, redisUserMemoryPrefix+userID).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return []*UserMemory{}, nil
+		}
+		return nil, fmt.Errorf("failed to load memories: %w", err)
+	}
+
+	var memories []*UserMemory
+	if err := json.Unmarshal(data, &memories); err != nil {
+		return nil, fmt.Errorf("failed to decode memories: %w", err)
+	}
+	return memories, nil
+}
+
+// SaveUser 保存用户记忆
+func (s *RedisUserMemoryStore) SaveUser(ctx context.Context, userID string, memories []*UserMemory) error {
+	data, err := json.Marshal(memories)
+	if err != nil {
+		return fmt.Errorf("failed to encode memories: %w", err)
+	}
+	if err := s.client.Set(ctx, redisUserMemoryPrefix+userID, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save memories: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser 删除用户记忆
+func (s *RedisUserMemoryStore) DeleteUser(ctx context.Context, userID string) error {
+	if err := s.client.Del(ctx, redisUserMemoryPrefix+userID).Err(); err != nil {
+		return fmt.Errorf("failed to delete memories: %w", err)
+	}
+	return nil
+}
+
+// ListUsers 列出有记忆的用户
+func (s *RedisUserMemoryStore) ListUsers(ctx context.Context) ([]string, error) {
+	users := make([]string, 0)
+	iter := s.client.Scan(ctx, 0, redisUserMemoryPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		users = append(users, strings.TrimPrefix(iter.Val(), redisUserMemoryPrefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyRoute 一组接口的请求体大小上限
+type BodyRoute struct {
+	Paths    []string // 路由模式，如 /api/v1/knowledge/add；以*结尾表示前缀匹配
+	MaxBytes int64    // 0表示不限制
+}
+
+// matches 路由是否属于该组
+func (r BodyRoute) matches(route string) bool {
+	for _, path := range r.Paths {
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if route == path {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyLimit 请求体大小限制
+// Content-Length超过上限时直接返回413；未声明长度的请求在读取超过上限时返回413
+type BodyLimit struct {
+	defaultMax int64
+	routes     []BodyRoute
+}
+
+// NewBodyLimit 创建请求体大小限制，routes按顺序匹配，都不匹配时使用defaultMax
+func NewBodyLimit(defaultMax int64, routes []BodyRoute) *BodyLimit {
+	return &BodyLimit{defaultMax: defaultMax, routes: routes}
+}
+
+// NewBodyLimitFromConfig 根据配置创建请求体大小限制，未配置任何上限时返回nil
+func NewBodyLimitFromConfig(cfg config.BodyLimitConfig) *BodyLimit {
+	if cfg.DefaultKB <= 0 && len(cfg.Routes) == 0 {
+		return nil
+	}
+	routes := make([]BodyRoute, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, BodyRoute{Paths: r.Paths, MaxBytes: int64(r.MaxKB) << 10})
+	}
+	return NewBodyLimit(int64(cfg.DefaultKB)<<10, routes)
+}
+
+// Limit 路由的请求体上限，0表示不限制
+func (l *BodyLimit) Limit(route string) int64 {
+	for _, r := range l.routes {
+		if r.matches(route) {
+			return r.MaxBytes
+		}
+	}
+	return l.defaultMax
+}
+
+// Middleware 请求体大小限制中间件
+func (l *BodyLimit) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		limit := l.Limit(route)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, apierror.New(apierror.CodePayloadTooLarge, "request body too large").WithDetails(gin.H{
+				"max_bytes":      limit,
+				"content_length": c.Request.ContentLength,
+			}))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	// defaultExposedHeaders 浏览器端需要读取的响应头：请求ID、限流和幂等重放
+	defaultExposedHeaders = []string{"X-Request-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "Idempotent-Replayed"}
+)
+
+// CORS 跨域资源共享策略
+type CORS struct {
+	origins          []string
+	anyOrigin        bool
+	methods          string
+	headers          string // 为空时回显预检请求声明的请求头
+	exposed          string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORS 创建跨域策略，methods和exposed为空时使用默认值
+func NewCORS(origins, methods, headers, exposed []string, allowCredentials bool, maxAge time.Duration) *CORS {
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	if len(exposed) == 0 {
+		exposed = defaultExposedHeaders
+	}
+	c := &CORS{
+		methods:          strings.Join(methods, ", "),
+		headers:          strings.Join(headers, ", "),
+		exposed:          strings.Join(exposed, ", "),
+		allowCredentials: allowCredentials,
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		c.origins = append(c.origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+	}
+	if maxAge > 0 {
+		c.maxAge = strconv.Itoa(int(maxAge.Seconds()))
+	}
+	return c
+}
+
+// NewCORSFromConfig 根据配置创建跨域策略，未启用时返回nil
+func NewCORSFromConfig(cfg config.CORSConfig) *CORS {
+	if !cfg.Enabled {
+		return nil
+	}
+	maxAge, _ := time.ParseDuration(cfg.MaxAge)
+	return NewCORS(cfg.AllowedOrigins, cfg.AllowedMethods, cfg.AllowedHeaders, cfg.ExposedHeaders, cfg.AllowCredentials, maxAge)
+}
+
+// Allowed 来源是否在允许列表中
+func (p *CORS) Allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if allowed == origin {
+			return true
+		}
+		// https://*.example.com 匹配任意子域名，不匹配example.com本身
+		if scheme, host, ok := strings.Cut(allowed, "://*."); ok {
+			if rest, found := strings.CutPrefix(origin, scheme+"://"); found && strings.HasSuffix(rest, "."+host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware 跨域中间件，需注册为全局中间件，预检请求在认证之前直接返回204
+// 不在允许列表中的来源：预检请求返回403，普通请求照常处理但不带CORS响应头
+func (p *CORS) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if p == nil || origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Writer.Header().Add("Vary", "Origin")
+		if !p.Allowed(origin) {
+			if preflight {
+				apierror.Abort(c, apierror.New(apierror.CodeForbidden, "origin not allowed").WithDetails(gin.H{"origin": origin}))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 允许携带凭证时不能使用通配符，回显请求来源
+		if p.anyOrigin && !p.allowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if p.allowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", p.exposed)
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		c.Header("Access-Control-Allow-Methods", p.methods)
+		if headers := p.headers; headers != "" {
+			c.Header("Access-Control-Allow-Headers", headers)
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			c.Header("Access-Control-Allow-Headers", requested)
+		}
+		if p.maxAge != "" {
+			c.Header("Access-Control-Max-Age", p.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
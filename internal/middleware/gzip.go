@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultGzipTypes 默认压缩的响应类型前缀
+var defaultGzipTypes = []string{"application/json", "text/plain", "text/html", "text/markdown", "text/csv"}
+
+// Gzip 响应压缩
+// 客户端声明Accept-Encoding: gzip且响应类型匹配时压缩；SSE、WebSocket和已编码的响应不压缩
+type Gzip struct {
+	types []string
+	pool  sync.Pool
+}
+
+// NewGzip 创建响应压缩，level为0时使用默认级别，types为空时使用默认类型
+func NewGzip(level int, types []string) *Gzip {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	if len(types) == 0 {
+		types = defaultGzipTypes
+	}
+	g := &Gzip{types: types}
+	g.pool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, level)
+		return w
+	}
+	return g
+}
+
+// NewGzipFromConfig 根据配置创建响应压缩，未启用时返回nil
+func NewGzipFromConfig(cfg config.GzipConfig) *Gzip {
+	if !cfg.Enabled {
+		return nil
+	}
+	return NewGzip(cfg.Level, cfg.ContentTypes)
+}
+
+// Middleware 响应压缩中间件
+func (g *Gzip) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g == nil || !acceptsGzip(c.Request) || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer, gzip: g}
+		c.Writer = w
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		defer w.close()
+		c.Next()
+	}
+}
+
+// compressible 响应类型是否需要压缩
+func (g *Gzip) compressible(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	for _, prefix := range g.types {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip 客户端是否接受gzip编码
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(encoding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipWriter 在第一次写入响应体时按响应类型决定是否压缩
+type gzipWriter struct {
+	gin.ResponseWriter
+	gzip    *Gzip
+	writer  *gzip.Writer // nil表示不压缩
+	decided bool
+}
+
+// decide 根据已设置的响应头决定是否压缩，响应头已发出时不再压缩
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	status := w.Status()
+	if w.Written() || header.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified ||
+		!w.gzip.compressible(header.Get("Content-Type")) {
+		return
+	}
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.writer = w.gzip.pool.Get().(*gzip.Writer)
+	w.writer.Reset(w.ResponseWriter)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.writer == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.writer.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 先刷新压缩缓冲区，保证已写入的数据发送给客户端
+func (w *gzipWriter) Flush() {
+	if w.writer != nil {
+		_ = w.writer.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 写出压缩尾部并归还gzip.Writer
+func (w *gzipWriter) close() {
+	if w.writer == nil {
+		return
+	}
+	_ = w.writer.Close()
+	w.writer.Reset(nil)
+	w.gzip.pool.Put(w.writer)
+	w.writer = nil
+}
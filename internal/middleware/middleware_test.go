@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRouter 挂载全部中间件的测试路由
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(
+		NewCORS([]string{"https://app.example.com", "https://*.example.org"}, nil, nil, nil, true, 10*time.Minute).Middleware(),
+		NewSecurityHeadersFromConfig(config.SecurityHeadersConfig{Enabled: true, HSTSMaxAge: "8760h"}).Middleware(),
+		NewGzip(0, nil).Middleware(),
+		NewBodyLimit(16, []BodyRoute{
+			{Paths: []string{"/knowledge/add"}, MaxBytes: 64},
+			{Paths: []string{"/knowledge/upload"}, MaxBytes: 0},
+		}).Middleware(),
+	)
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"size": len(body), "padding": strings.Repeat("x", 1024)})
+	}
+	router.POST("/tools/execute", echo)
+	router.POST("/knowledge/add", echo)
+	router.POST("/knowledge/upload", echo)
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: hello\n\n")
+	})
+	return router
+}
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestCORS 测试预检请求、允许和拒绝的来源
+func TestCORS(t *testing.T) {
+	router := newRouter()
+
+	req := httptest.NewRequest(http.MethodOptions, "/tools/execute", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+	w := serve(router, req)
+	if w.Code != http.StatusNoContent ||
+		w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/tools/execute", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	if w := serve(router, req); w.Code != http.StatusForbidden {
+		t.Errorf("preflight from disallowed origin should be 403, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tools/execute", strings.NewReader("{}"))
+	req.Header.Set("Origin", "https://api.example.org")
+	w = serve(router, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://api.example.org" ||
+		!strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Errorf("wildcard subdomain should be allowed: %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tools/execute", strings.NewReader("{}"))
+	req.Header.Set("Origin", "https://example.org")
+	if w := serve(router, req); w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin should not get CORS headers: %d %v", w.Code, w.Header())
+	}
+}
+
+// TestSecurityHeadersAndGzip 测试安全响应头和响应压缩
+func TestSecurityHeadersAndGzip(t *testing.T) {
+	router := newRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/tools/execute", strings.NewReader("{}"))
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := serve(router, req)
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("X-Frame-Options") != "DENY" ||
+		w.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("missing security headers: %v", w.Header())
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("JSON response should be gzipped: %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("invalid gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if !strings.Contains(string(body), `"size":2`) {
+		t.Errorf("unexpected decompressed body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if w := serve(router, req); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "data: hello\n\n" {
+		t.Errorf("event stream should not be gzipped: %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tools/execute", strings.NewReader("{}"))
+	if w := serve(router, req); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("response should not be gzipped without Accept-Encoding")
+	}
+}
+
+// TestBodyLimit 测试默认上限、按路由的上限和不限制的路由
+func TestBodyLimit(t *testing.T) {
+	router := newRouter()
+	body := strings.Repeat("a", 32)
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/tools/execute", http.StatusRequestEntityTooLarge}, // 默认16字节
+		{"/knowledge/add", http.StatusOK},                    // 64字节
+		{"/knowledge/upload", http.StatusOK},                 // 不限制
+	}
+	for _, tc := range cases {
+		if w := serve(router, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(body))); w.Code != tc.status {
+			t.Errorf("%s: got %d, want %d", tc.path, w.Code, tc.status)
+		}
+	}
+
+	// 未声明Content-Length时在读取超限时失败
+	req := httptest.NewRequest(http.MethodPost, "/tools/execute", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	if w := serve(router, req); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked body over limit should fail, got %d", w.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 安全响应头的默认值，适用于只返回JSON的API
+const (
+	defaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	defaultFrameOptions          = "DENY"
+	defaultReferrerPolicy        = "no-referrer"
+)
+
+// SecurityHeaders 为所有响应添加的安全头
+type SecurityHeaders struct {
+	headers map[string]string
+}
+
+// NewSecurityHeadersFromConfig 根据配置创建安全响应头，未启用时返回nil
+// 未配置的项使用默认值，hsts_max_age为空时不发送Strict-Transport-Security
+func NewSecurityHeadersFromConfig(cfg config.SecurityHeadersConfig) *SecurityHeaders {
+	if !cfg.Enabled {
+		return nil
+	}
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": valueOr(cfg.ContentSecurityPolicy, defaultContentSecurityPolicy),
+		"X-Frame-Options":         valueOr(cfg.FrameOptions, defaultFrameOptions),
+		"Referrer-Policy":         valueOr(cfg.ReferrerPolicy, defaultReferrerPolicy),
+	}
+	if maxAge, err := time.ParseDuration(cfg.HSTSMaxAge); err == nil && maxAge > 0 {
+		headers["Strict-Transport-Security"] = "max-age=" + strconv.Itoa(int(maxAge.Seconds())) + "; includeSubDomains"
+	}
+	return &SecurityHeaders{headers: headers}
+}
+
+// Middleware 安全响应头中间件，在处理请求前写入，处理函数可以覆盖
+func (s *SecurityHeaders) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s != nil {
+			for name, value := range s.headers {
+				c.Header(name, value)
+			}
+		}
+		c.Next()
+	}
+}
+
+// valueOr 值为空时使用默认值
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,167 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// Record 一条审核记录，只记录被标记、替换或拦截的内容
+type Record struct {
+	ID        string    `json:"id"`
+	Stage     Stage     `json:"stage"`
+	Action    Action    `json:"action"`
+	Tenant    string    `json:"tenant,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Findings  []Finding `json:"findings"`
+	Excerpt   string    `json:"excerpt"` // 审核前的原文（截断），用于人工复核
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AuditStore 审核记录存储
+type AuditStore interface {
+	// Save 保存一条记录
+	Save(ctx context.Context, record *Record) error
+
+	// Recent 获取最近的记录，tenantID为空时返回全部租户
+	Recent(ctx context.Context, tenantID string, limit int) ([]*Record, error)
+}
+
+// NewAuditStoreFromConfig 根据配置创建审核记录存储
+func NewAuditStoreFromConfig(cfg config.ModerationAuditConfig) (AuditStore, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return NewMemoryAuditStore(cfg.MaxEntries), nil
+	case "file":
+		return NewFileAuditStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported moderation audit store: %s", cfg.Store)
+	}
+}
+
+// filterRecent 按租户过滤并保留最近limit条，records按时间正序
+func filterRecent(records []*Record, tenantID string, limit int) []*Record {
+	result := make([]*Record, 0)
+	for _, record := range records {
+		if tenantID == "" || record.Tenant == tenantID {
+			result = append(result, record)
+		}
+	}
+	if limit > 0 && limit < len(result) {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// MemoryAuditStore 内存审核记录存储（保留最近N条）
+type MemoryAuditStore struct {
+	mu         sync.RWMutex
+	records    []*Record
+	maxEntries int
+}
+
+// NewMemoryAuditStore 创建内存审核记录存储
+func NewMemoryAuditStore(maxEntries int) *MemoryAuditStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryAuditStore{
+		records:    make([]*Record, 0),
+		maxEntries: maxEntries,
+	}
+}
+
+// Save 保存一条记录
+func (s *MemoryAuditStore) Save(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+	if len(s.records) > s.maxEntries {
+		s.records = s.records[len(s.records)-s.maxEntries:]
+	}
+	return nil
+}
+
+// Recent 获取最近的记录
+func (s *MemoryAuditStore) Recent(ctx context.Context, tenantID string, limit int) ([]*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return filterRecent(s.records, tenantID, limit), nil
+}
+
+// FileAuditStore JSONL文件审核记录存储，每行一条
+type FileAuditStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileAuditStore 创建文件审核记录存储
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("moderation audit file path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	return &FileAuditStore{path: path}, nil
+}
+
+// Save 追加一条记录
+func (s *FileAuditStore) Save(ctx context.Context, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal moderation record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write moderation record: %w", err)
+	}
+	return nil
+}
+
+// Recent 读取最近的记录
+func (s *FileAuditStore) Recent(ctx context.Context, tenantID string, limit int) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Record{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	records := make([]*Record, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return filterRecent(records, tenantID, limit), nil
+}
@@ -0,0 +1,73 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// Verdict 分类模型的判定结果
+type Verdict struct {
+	Flagged  bool    `json:"flagged"`
+	Category string  `json:"category"`
+	Score    float64 `json:"score"`
+	Reason   string  `json:"reason"`
+}
+
+// Classifier 内容分类器，可接入LLM或专用的审核服务
+type Classifier interface {
+	Classify(ctx context.Context, text string) (*Verdict, error)
+}
+
+// DefaultCategories 未配置类别时分类模型识别的违规类别
+var DefaultCategories = []string{"violence", "sexual", "hate", "self_harm", "illegal"}
+
+// LLMClassifier 使用大模型判断内容是否违规
+type LLMClassifier struct {
+	model      llm.Model
+	categories []string
+}
+
+// NewLLMClassifier 创建基于大模型的分类器，categories为空时使用DefaultCategories
+func NewLLMClassifier(model llm.Model, categories []string) *LLMClassifier {
+	if len(categories) == 0 {
+		categories = DefaultCategories
+	}
+	return &LLMClassifier{model: model, categories: categories}
+}
+
+// Classify 判断内容是否属于任一违规类别
+func (c *LLMClassifier) Classify(ctx context.Context, text string) (*Verdict, error) {
+	prompt := fmt.Sprintf(`你是内容安全审核员。判断下面的内容是否属于以下违规类别之一：%s。
+只输出一个JSON对象，不要输出其他内容：
+{"flagged": true或false, "category": "命中的类别，未命中为空", "score": 0到1之间的置信度, "reason": "简要理由"}
+
+待审核内容：
+%s`, strings.Join(c.categories, ", "), text)
+
+	output, err := c.model.Chat(llm.WithCacheRoute(ctx, "moderation"), []models.Message{
+		{Role: "user", Content: prompt},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("moderation classifier call failed: %w", err)
+	}
+	return parseVerdict(output)
+}
+
+// parseVerdict 从模型输出中解析判定结果，兼容代码块包裹的JSON
+func parseVerdict(output string) (*Verdict, error) {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("moderation classifier returned no verdict")
+	}
+	var verdict Verdict
+	if err := json.Unmarshal([]byte(output[start:end+1]), &verdict); err != nil {
+		return nil, fmt.Errorf("invalid moderation verdict: %w", err)
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,364 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/idgen"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// ErrContentBlocked 内容被审核策略拦截
+var ErrContentBlocked = errors.New("content blocked by moderation policy")
+
+// Stage 审核阶段
+type Stage string
+
+const (
+	StageInput  Stage = "input"  // 用户输入
+	StageOutput Stage = "output" // 模型输出
+)
+
+// Action 审核处理方式，按严重程度从低到高
+type Action string
+
+const (
+	ActionAllow  Action = "allow"  // 放行
+	ActionFlag   Action = "flag"   // 放行并记录
+	ActionRedact Action = "redact" // 替换命中的内容后放行
+	ActionBlock  Action = "block"  // 拦截
+)
+
+// severity 处理方式的严重程度
+func (a Action) severity() int {
+	switch a {
+	case ActionFlag:
+		return 1
+	case ActionRedact:
+		return 2
+	case ActionBlock:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// parseAction 解析配置中的处理方式
+func parseAction(action string, allowed ...Action) (Action, error) {
+	for _, a := range allowed {
+		if strings.EqualFold(action, string(a)) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported moderation action: %q", action)
+}
+
+// parseStages 解析配置中的审核阶段，为空表示输入和输出
+func parseStages(stages []string) (map[Stage]bool, error) {
+	if len(stages) == 0 {
+		return map[Stage]bool{StageInput: true, StageOutput: true}, nil
+	}
+	set := make(map[Stage]bool, len(stages))
+	for _, s := range stages {
+		stage := Stage(strings.ToLower(s))
+		if stage != StageInput && stage != StageOutput {
+			return nil, fmt.Errorf("unsupported moderation stage: %q", s)
+		}
+		set[stage] = true
+	}
+	return set, nil
+}
+
+// Finding 一条命中记录
+type Finding struct {
+	Source   string   `json:"source"` // rule, classifier
+	Rule     string   `json:"rule,omitempty"`
+	Category string   `json:"category"`
+	Action   Action   `json:"action"`
+	Matches  []string `json:"matches,omitempty"` // 命中的关键词或文本片段
+	Score    float64  `json:"score,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Result 一次审核的结果
+type Result struct {
+	Stage    Stage     `json:"stage"`
+	Action   Action    `json:"action"`
+	Findings []Finding `json:"findings,omitempty"`
+	Text     string    `json:"-"` // 处理后的文本，redact时为替换后的内容
+}
+
+// Blocked 内容是否被拦截
+func (r *Result) Blocked() bool {
+	return r.Action == ActionBlock
+}
+
+// Categories 命中的违规类别（去重）
+func (r *Result) Categories() []string {
+	seen := make(map[string]bool, len(r.Findings))
+	categories := make([]string, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		if !seen[f.Category] {
+			seen[f.Category] = true
+			categories = append(categories, f.Category)
+		}
+	}
+	return categories
+}
+
+// Err 内容被拦截时返回包装ErrContentBlocked的错误，否则返回nil
+func (r *Result) Err() error {
+	if !r.Blocked() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (%s)", ErrContentBlocked, r.Stage, strings.Join(r.Categories(), ", "))
+}
+
+// Rule 关键词/正则审核规则
+type Rule struct {
+	Name     string
+	Category string
+	Action   Action
+	stages   map[Stage]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRule 创建审核规则，关键词不区分大小写
+// stages为空表示同时审核输入和输出
+func NewRule(name, category string, action Action, keywords, patterns []string, stages []string) (*Rule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("moderation rule name is required")
+	}
+	if category == "" {
+		category = name
+	}
+	stageSet, err := parseStages(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{Name: name, Category: category, Action: action, stages: stageSet}
+	quoted := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			quoted = append(quoted, regexp.QuoteMeta(keyword))
+		}
+	}
+	if len(quoted) > 0 {
+		rule.patterns = append(rule.patterns, regexp.MustCompile(`(?i)(?:`+strings.Join(quoted, "|")+`)`))
+	}
+	for _, expr := range patterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern in rule %s: %w", name, err)
+		}
+		rule.patterns = append(rule.patterns, pattern)
+	}
+	if len(rule.patterns) == 0 {
+		return nil, fmt.Errorf("moderation rule %s has no keywords or patterns", name)
+	}
+	return rule, nil
+}
+
+// match 返回命中的文本片段（去重）
+func (r *Rule) match(text string) []string {
+	var matches []string
+	seen := make(map[string]bool)
+	for _, pattern := range r.patterns {
+		for _, m := range pattern.FindAllString(text, -1) {
+			if !seen[m] {
+				seen[m] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches
+}
+
+// redact 替换命中的内容
+func (r *Rule) redact(text string) string {
+	placeholder := "[MODERATED_" + strings.ToUpper(r.Category) + "]"
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllString(text, placeholder)
+	}
+	return text
+}
+
+// Moderator 内容审核器：先按规则匹配，未被拦截时交给分类模型，非放行的结果写入审核记录
+type Moderator struct {
+	rules []*Rule
+
+	classifier       Classifier
+	classifierAction Action
+	classifierStages map[Stage]bool
+	failClosed       bool
+
+	audit AuditStore
+}
+
+// NewModerator 创建内容审核器，audit为nil时使用内存存储
+func NewModerator(rules []*Rule, audit AuditStore) *Moderator {
+	if audit == nil {
+		audit = NewMemoryAuditStore(0)
+	}
+	return &Moderator{rules: rules, audit: audit}
+}
+
+// SetClassifier 设置分类模型，判定违规时按action处理（block或flag）
+// stages为空表示同时审核输入和输出；failClosed为true时分类模型调用失败即拦截
+func (m *Moderator) SetClassifier(classifier Classifier, action Action, stages []string, failClosed bool) error {
+	if action != ActionBlock && action != ActionFlag {
+		return fmt.Errorf("unsupported classifier action: %q", action)
+	}
+	stageSet, err := parseStages(stages)
+	if err != nil {
+		return err
+	}
+	m.classifier = classifier
+	m.classifierAction = action
+	m.classifierStages = stageSet
+	m.failClosed = failClosed
+	return nil
+}
+
+// NewModeratorFromConfig 根据配置创建内容审核器，未启用时返回nil
+// classifier为启用分类审核时使用的分类模型，可以为nil
+func NewModeratorFromConfig(cfg config.ModerationConfig, classifier Classifier) (*Moderator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	rules := make([]*Rule, 0, len(cfg.Rules))
+	for _, rc := range cfg.Rules {
+		action, err := parseAction(rc.Action, ActionBlock, ActionRedact, ActionFlag)
+		if err != nil {
+			return nil, fmt.Errorf("moderation rule %s: %w", rc.Name, err)
+		}
+		rule, err := NewRule(rc.Name, rc.Category, action, rc.Keywords, rc.Patterns, rc.Stages)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	audit, err := NewAuditStoreFromConfig(cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
+	m := NewModerator(rules, audit)
+
+	if cfg.Classifier.Enabled && classifier != nil {
+		action := ActionFlag
+		if cfg.Classifier.Action != "" {
+			if action, err = parseAction(cfg.Classifier.Action, ActionBlock, ActionFlag); err != nil {
+				return nil, fmt.Errorf("moderation classifier: %w", err)
+			}
+		}
+		if err := m.SetClassifier(classifier, action, cfg.Classifier.Stages, cfg.Classifier.FailClosed); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// CheckInput 审核用户输入，m为nil时直接放行
+func (m *Moderator) CheckInput(ctx context.Context, sessionID, text string) *Result {
+	return m.Check(ctx, StageInput, sessionID, text)
+}
+
+// CheckOutput 审核模型输出，m为nil时直接放行
+func (m *Moderator) CheckOutput(ctx context.Context, sessionID, text string) *Result {
+	return m.Check(ctx, StageOutput, sessionID, text)
+}
+
+// Check 依次执行规则和分类模型审核，非放行的结果写入审核记录，m为nil时直接放行
+func (m *Moderator) Check(ctx context.Context, stage Stage, sessionID, text string) *Result {
+	result := &Result{Stage: stage, Action: ActionAllow, Text: text}
+	if m == nil || text == "" {
+		return result
+	}
+
+	for _, rule := range m.rules {
+		if !rule.stages[stage] {
+			continue
+		}
+		matches := rule.match(result.Text)
+		if len(matches) == 0 {
+			continue
+		}
+		result.add(Finding{Source: "rule", Rule: rule.Name, Category: rule.Category, Action: rule.Action, Matches: matches})
+		if rule.Action == ActionRedact {
+			result.Text = rule.redact(result.Text)
+		}
+	}
+
+	// 已被规则拦截时不再调用分类模型
+	if m.classifier != nil && m.classifierStages[stage] && !result.Blocked() {
+		verdict, err := m.classifier.Classify(ctx, result.Text)
+		switch {
+		case err != nil && m.failClosed:
+			result.add(Finding{Source: "classifier", Category: "classifier_error", Action: ActionBlock, Reason: err.Error()})
+		case err != nil:
+			log.Printf("moderation classifier failed, allowing content: %v", err)
+		case verdict.Flagged:
+			result.add(Finding{
+				Source:   "classifier",
+				Category: verdict.Category,
+				Action:   m.classifierAction,
+				Score:    verdict.Score,
+				Reason:   verdict.Reason,
+			})
+		}
+	}
+
+	if result.Action != ActionAllow {
+		record := &Record{
+			ID:        idgen.New("mod"),
+			Stage:     stage,
+			Action:    result.Action,
+			Tenant:    tenant.FromContext(ctx),
+			SessionID: sessionID,
+			Findings:  result.Findings,
+			Excerpt:   excerpt(text),
+			Timestamp: time.Now(),
+		}
+		if err := m.audit.Save(ctx, record); err != nil {
+			log.Printf("failed to save moderation record: %v", err)
+		}
+	}
+	return result
+}
+
+// add 追加命中记录，结果的处理方式取最严重的一项
+func (r *Result) add(f Finding) {
+	if f.Category == "" {
+		f.Category = "unspecified"
+	}
+	r.Findings = append(r.Findings, f)
+	if f.Action.severity() > r.Action.severity() {
+		r.Action = f.Action
+	}
+}
+
+// Recent 获取最近的审核记录，tenantID为空时返回全部租户
+func (m *Moderator) Recent(ctx context.Context, tenantID string, limit int) ([]*Record, error) {
+	return m.audit.Recent(ctx, tenantID, limit)
+}
+
+// maxExcerptRunes 审核记录中保留的原文长度
+const maxExcerptRunes = 200
+
+// excerpt 截取原文用于人工复核
+func excerpt(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxExcerptRunes {
+		return text
+	}
+	return string(runes[:maxExcerptRunes]) + "…"
+}
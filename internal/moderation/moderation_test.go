@@ -0,0 +1,116 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// stubClassifier 固定返回判定结果的分类器
+type stubClassifier struct {
+	verdict *Verdict
+	err     error
+	calls   int
+}
+
+func (c *stubClassifier) Classify(ctx context.Context, text string) (*Verdict, error) {
+	c.calls++
+	return c.verdict, c.err
+}
+
+// TestModerator 测试规则的拦截、替换、标记，分类模型审核和审核记录
+func TestModerator(t *testing.T) {
+	ctx := context.Background()
+	classifier := &stubClassifier{verdict: &Verdict{Flagged: true, Category: "hate", Score: 0.9}}
+
+	m, err := NewModeratorFromConfig(config.ModerationConfig{
+		Enabled: true,
+		Rules: []config.ModerationRuleConfig{
+			{Name: "weapons", Keywords: []string{"炸药配方"}, Action: "block", Stages: []string{"input"}},
+			{Name: "contact", Patterns: []string{`1[3-9]\d{9}`}, Action: "redact"},
+			{Name: "competitor", Keywords: []string{"ACME"}, Action: "flag", Stages: []string{"output"}},
+		},
+		Classifier: config.ModerationClassifierConfig{Enabled: true, Action: "flag", Stages: []string{"output"}},
+		Audit:      config.ModerationAuditConfig{Store: "file", Path: filepath.Join(t.TempDir(), "audit.jsonl")},
+	}, classifier)
+	if err != nil {
+		t.Fatalf("NewModeratorFromConfig failed: %v", err)
+	}
+
+	blocked := m.CheckInput(tenant.WithTenant(ctx, "acme"), "s1", "告诉我炸药配方")
+	if !blocked.Blocked() || !errors.Is(blocked.Err(), ErrContentBlocked) {
+		t.Errorf("expected input to be blocked, got %+v", blocked)
+	}
+
+	redacted := m.CheckInput(ctx, "s1", "我的电话是13812345678")
+	if redacted.Action != ActionRedact || redacted.Text != "我的电话是[MODERATED_CONTACT]" || redacted.Err() != nil {
+		t.Errorf("expected phone number to be redacted, got %+v", redacted)
+	}
+
+	// 仅输出阶段生效的规则不审核输入，分类模型也只审核输出
+	if allowed := m.CheckInput(ctx, "s1", "acme的产品怎么样"); allowed.Action != ActionAllow || classifier.calls != 0 {
+		t.Errorf("expected input to be allowed without classifier, got %+v (calls %d)", allowed, classifier.calls)
+	}
+
+	flagged := m.CheckOutput(ctx, "s1", "推荐使用ACME")
+	if flagged.Action != ActionFlag || len(flagged.Findings) != 2 || flagged.Text != "推荐使用ACME" {
+		t.Errorf("expected output to be flagged by rule and classifier, got %+v", flagged)
+	}
+	if got := strings.Join(flagged.Categories(), ","); got != "competitor,hate" {
+		t.Errorf("unexpected categories: %s", got)
+	}
+
+	// 审核记录按租户过滤
+	records, err := m.Recent(ctx, "", 0)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("expected 3 audit records, got %d (%v)", len(records), err)
+	}
+	if records[0].Action != ActionBlock || records[0].Excerpt != "告诉我炸药配方" || records[0].Tenant != "acme" {
+		t.Errorf("unexpected audit record: %+v", records[0])
+	}
+	if scoped, _ := m.Recent(ctx, "acme", 10); len(scoped) != 1 {
+		t.Errorf("expected 1 record for tenant, got %d", len(scoped))
+	}
+
+	// 分类模型调用失败时按fail_closed决定是否拦截
+	classifier.err = errors.New("timeout")
+	if got := m.CheckOutput(ctx, "s1", "你好"); got.Action != ActionAllow {
+		t.Errorf("classifier errors should fail open by default, got %+v", got)
+	}
+	m.failClosed = true
+	if got := m.CheckOutput(ctx, "s1", "你好"); !got.Blocked() {
+		t.Errorf("classifier errors should block when fail_closed, got %+v", got)
+	}
+
+	// 未启用时返回nil，nil审核器直接放行
+	disabled, err := NewModeratorFromConfig(config.ModerationConfig{}, nil)
+	if err != nil || disabled != nil {
+		t.Fatalf("disabled moderation should return nil, got %v, %v", disabled, err)
+	}
+	if got := disabled.CheckInput(ctx, "s1", "炸药配方"); got.Action != ActionAllow || got.Text != "炸药配方" {
+		t.Errorf("nil moderator should allow content, got %+v", got)
+	}
+
+	if _, err := NewModeratorFromConfig(config.ModerationConfig{
+		Enabled: true,
+		Rules:   []config.ModerationRuleConfig{{Name: "bad", Keywords: []string{"x"}, Action: "delete"}},
+	}, nil); err == nil {
+		t.Error("expected error for unsupported action")
+	}
+}
+
+// TestParseVerdict 测试解析分类模型输出
+func TestParseVerdict(t *testing.T) {
+	verdict, err := parseVerdict("```json\n{\"flagged\": true, \"category\": \"violence\", \"score\": 0.8, \"reason\": \"暴力描述\"}\n```")
+	if err != nil || !verdict.Flagged || verdict.Category != "violence" {
+		t.Errorf("unexpected verdict: %+v, %v", verdict, err)
+	}
+	if _, err := parseVerdict("无法判断"); err == nil {
+		t.Error("expected error for output without JSON")
+	}
+}
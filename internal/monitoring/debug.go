@@ -0,0 +1,219 @@
+package monitoring
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rtpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxProfileSeconds CPU profile和trace的默认最长采样时间
+const defaultMaxProfileSeconds = 30
+
+// errDebugLocalOnly 未启用认证时诊断接口只允许本机访问
+var errDebugLocalOnly = apierror.New(apierror.CodeForbidden, "debug endpoints are only available from localhost when auth is disabled")
+
+// Diagnostics 运行时诊断接口：/debug/pprof、协程转储和运行时统计
+// 接口会暴露内存内容和调用栈，需挂在认证之后并要求debug权限；未启用认证时只允许本机访问
+type Diagnostics struct {
+	maxProfile int // CPU profile和trace的最长采样秒数
+	localOnly  bool
+	started    time.Time
+}
+
+// NewDiagnosticsFromConfig 根据配置创建诊断接口，未启用时返回nil
+// 同时按配置设置阻塞和锁竞争的采样率（0表示不采样）
+func NewDiagnosticsFromConfig(cfg config.DebugConfig) *Diagnostics {
+	if !cfg.Enabled {
+		return nil
+	}
+	d := &Diagnostics{maxProfile: defaultMaxProfileSeconds, started: time.Now()}
+	if cfg.MaxProfileSeconds > 0 {
+		d.maxProfile = cfg.MaxProfileSeconds
+	}
+	runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+	runtime.SetMutexProfileFraction(cfg.MutexProfileFraction)
+	return d
+}
+
+// SetLocalOnly 只允许本机访问，未启用认证时使用
+func (d *Diagnostics) SetLocalOnly(localOnly bool) {
+	if d != nil {
+		d.localOnly = localOnly
+	}
+}
+
+// Register 在 /debug 下注册诊断接口，handlers为认证和权限校验中间件；d为nil时不注册
+func (d *Diagnostics) Register(router gin.IRouter, handlers ...gin.HandlerFunc) {
+	if d == nil {
+		return
+	}
+	group := router.Group("/debug", append(handlers, d.guard)...)
+
+	// GET /debug/pprof/ - pprof索引；/debug/pprof/<profile> - heap、goroutine、allocs、block、mutex等
+	group.GET("/pprof/*profile", d.pprof)
+	group.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+
+	// GET /debug/goroutines - 全部协程的调用栈，?debug=1 时按调用栈合并计数
+	group.GET("/goroutines", d.goroutines)
+
+	// GET /debug/runtime - 堆、GC停顿和协程数
+	group.GET("/runtime", d.runtimeStats)
+}
+
+// guard 未启用认证时拒绝非本机的请求
+func (d *Diagnostics) guard(c *gin.Context) {
+	if d.localOnly {
+		if ip := net.ParseIP(c.ClientIP()); ip == nil || !ip.IsLoopback() {
+			apierror.Abort(c, errDebugLocalOnly)
+			return
+		}
+	}
+	c.Next()
+}
+
+// pprof 分发pprof接口，限制CPU profile和trace的采样时间
+func (d *Diagnostics) pprof(c *gin.Context) {
+	name := strings.Trim(c.Param("profile"), "/")
+	switch name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "profile":
+		d.capSeconds(c.Request, 30)
+		pprof.Profile(c.Writer, c.Request)
+	case "trace":
+		d.capSeconds(c.Request, 1)
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		if rtpprof.Lookup(name) == nil {
+			apierror.Respond(c, apierror.New(apierror.CodeNotFound, "unknown profile: "+name))
+			return
+		}
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// capSeconds 把seconds参数限制在最长采样时间内；未指定时沿用pprof的默认值（profile 30秒，trace 1秒）
+func (d *Diagnostics) capSeconds(r *http.Request, defaultSeconds float64) {
+	query := r.URL.Query()
+	seconds, err := strconv.ParseFloat(query.Get("seconds"), 64)
+	if err != nil || seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	if seconds <= float64(d.maxProfile) {
+		return
+	}
+	query.Set("seconds", strconv.Itoa(d.maxProfile))
+	r.URL.RawQuery = query.Encode()
+}
+
+// goroutines 输出全部协程的调用栈
+func (d *Diagnostics) goroutines(c *gin.Context) {
+	debug := 2
+	if c.Query("debug") == "1" {
+		debug = 1
+	}
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := rtpprof.Lookup("goroutine").WriteTo(c.Writer, debug); err != nil {
+		c.Error(err)
+	}
+}
+
+// runtimeStats 运行时统计：堆内存、GC停顿（最近至多256次）和协程数
+func (d *Diagnostics) runtimeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, RuntimeStats(d.started))
+}
+
+// RuntimeStats 采集运行时统计，started为进程（或服务）启动时间，用于计算运行时长
+func RuntimeStats(started time.Time) gin.H {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	// PauseNs是最近256次GC停顿的环形缓冲区
+	n := int(m.NumGC)
+	if n > len(m.PauseNs) {
+		n = len(m.PauseNs)
+	}
+	pauses := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		pauses = append(pauses, time.Duration(m.PauseNs[(int(m.NumGC)-1-i+len(m.PauseNs))%len(m.PauseNs)]))
+	}
+	var lastPause time.Duration
+	if n > 0 {
+		lastPause = pauses[0]
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i] < pauses[j] })
+
+	var lastGC interface{}
+	if m.LastGC > 0 {
+		lastGC = time.Unix(0, int64(m.LastGC))
+	}
+
+	return gin.H{
+		"go_version":     runtime.Version(),
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"uptime_seconds": int64(time.Since(started).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"heap": gin.H{
+			"alloc_bytes":       m.HeapAlloc,
+			"inuse_bytes":       m.HeapInuse,
+			"idle_bytes":        m.HeapIdle,
+			"released_bytes":    m.HeapReleased,
+			"sys_bytes":         m.HeapSys,
+			"objects":           m.HeapObjects,
+			"next_gc_bytes":     m.NextGC,
+			"total_alloc_bytes": m.TotalAlloc,
+			"mallocs":           m.Mallocs,
+			"frees":             m.Frees,
+		},
+		"gc": gin.H{
+			"num_gc":         m.NumGC,
+			"forced":         m.NumForcedGC,
+			"last_gc":        lastGC,
+			"cpu_fraction":   m.GCCPUFraction,
+			"pause_total_ms": durationMs(time.Duration(m.PauseTotalNs)),
+			"last_pause_ms":  durationMs(lastPause),
+			"recent_pauses":  len(pauses),
+			"pause_p50_ms":   durationMs(percentileDuration(pauses, 0.5)),
+			"pause_p99_ms":   durationMs(percentileDuration(pauses, 0.99)),
+			"pause_max_ms":   durationMs(percentileDuration(pauses, 1)),
+		},
+		"sys_bytes": m.Sys,
+	}
+}
+
+// percentileDuration 已排序数组的分位数（最近秩）
+func percentileDuration(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// durationMs 毫秒数，保留三位小数
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute 未匹配任何路由的请求（404）的route标签，避免按原始路径产生大量时间序列
+const unmatchedRoute = "unmatched"
+
+// HTTPMetrics HTTP接口指标：按路由统计请求数、状态码和耗时
+// route标签为路由模式（如 /api/v1/tasks/:id），而不是原始路径
+type HTTPMetrics struct {
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	inFlight   prometheus.Gauge
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+	path       string
+}
+
+// NewHTTPMetrics 创建HTTP接口指标
+// registry为nil时注册到Prometheus默认注册表（已包含进程和Go运行时指标），
+// 否则注册到指定的注册表并一并注册进程和Go运行时指标
+func NewHTTPMetrics(registry *prometheus.Registry) *HTTPMetrics {
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if registry != nil {
+		registry.MustRegister(
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			collectors.NewGoCollector(),
+		)
+		registerer, gatherer = registry, registry
+	}
+
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests",
+			},
+			[]string{"method", "route", "status"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "HTTP request latency in seconds",
+				Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0},
+			},
+			[]string{"method", "route"},
+		),
+		inFlight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "Number of HTTP requests being served",
+			},
+		),
+		registerer: registerer,
+		gatherer:   gatherer,
+		path:       "/metrics",
+	}
+	registerer.MustRegister(m.requests, m.duration, m.inFlight)
+	return m
+}
+
+// NewHTTPMetricsFromConfig 根据配置创建HTTP接口指标，未启用监控时返回nil
+// 指标注册到默认注册表，进程内只应创建一次
+func NewHTTPMetricsFromConfig(cfg config.MonitoringConfig) *HTTPMetrics {
+	if !cfg.Enabled {
+		return nil
+	}
+	m := NewHTTPMetrics(nil)
+	if cfg.Prometheus.Path != "" {
+		m.path = cfg.Prometheus.Path
+	}
+	return m
+}
+
+// Middleware 请求指标中间件，需挂在其他中间件之前，以便统计被中止的请求（401、413、429等）
+func (m *HTTPMetrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		m.requests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler Prometheus抓取接口
+func (m *HTTPMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// MustRegister 把其他指标收集器注册到同一个注册表，m为nil时不注册
+func (m *HTTPMetrics) MustRegister(cs ...prometheus.Collector) {
+	if m == nil {
+		return
+	}
+	m.registerer.MustRegister(cs...)
+}
+
+// Register 在路由上注册抓取接口（默认 /metrics），m为nil时不注册
+func (m *HTTPMetrics) Register(router gin.IRoutes) {
+	if m == nil {
+		return
+	}
+	router.GET(m.path, gin.WrapH(m.Handler()))
+}
@@ -0,0 +1,136 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestHTTPMetrics 测试按路由模式统计请求，并通过 /metrics 暴露
+func TestHTTPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics := NewHTTPMetrics(prometheus.NewRegistry())
+
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	metrics.Register(router)
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/tasks/1", "/tasks/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/tasks/:id",status="204"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/tasks/:id"} 2`,
+		"process_cpu_seconds_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+
+	// nil指标直接放行且不注册抓取接口
+	var disabled *HTTPMetrics
+	plain := gin.New()
+	plain.Use(disabled.Middleware())
+	disabled.Register(plain)
+	w = httptest.NewRecorder()
+	plain.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabled metrics should not expose /metrics, got %d", w.Code)
+	}
+}
+
+// TestDiagnostics 测试运行时统计、pprof索引、协程转储和本机访问限制
+func TestDiagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if NewDiagnosticsFromConfig(config.DebugConfig{}) != nil {
+		t.Fatal("disabled diagnostics should be nil")
+	}
+	diagnostics := NewDiagnosticsFromConfig(config.DebugConfig{Enabled: true, MaxProfileSeconds: 5})
+	diagnostics.SetLocalOnly(true)
+
+	router := gin.New()
+	diagnostics.Register(router)
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	runtime.GC()
+	w := get("/debug/runtime", "127.0.0.1:1234")
+	if w.Code != http.StatusOK {
+		t.Fatalf("runtime stats status = %d", w.Code)
+	}
+	var stats struct {
+		Goroutines int `json:"goroutines"`
+		Heap       struct {
+			AllocBytes uint64 `json:"alloc_bytes"`
+		} `json:"heap"`
+		GC struct {
+			NumGC        uint32  `json:"num_gc"`
+			RecentPauses int     `json:"recent_pauses"`
+			PauseMaxMs   float64 `json:"pause_max_ms"`
+			PauseP50Ms   float64 `json:"pause_p50_ms"`
+		} `json:"gc"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid runtime stats: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.Heap.AllocBytes == 0 || stats.GC.NumGC == 0 || stats.GC.RecentPauses == 0 {
+		t.Errorf("unexpected runtime stats: %s", w.Body.String())
+	}
+	if stats.GC.PauseP50Ms > stats.GC.PauseMaxMs {
+		t.Errorf("p50 pause %v > max pause %v", stats.GC.PauseP50Ms, stats.GC.PauseMaxMs)
+	}
+
+	if w := get("/debug/pprof/", "127.0.0.1:1234"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("pprof index status = %d", w.Code)
+	}
+	if w := get("/debug/pprof/heap?debug=1", "127.0.0.1:1234"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap profile") {
+		t.Errorf("heap profile status = %d", w.Code)
+	}
+	if w := get("/debug/pprof/unknown", "127.0.0.1:1234"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown profile status = %d, want 404", w.Code)
+	}
+	if w := get("/debug/goroutines", "[::1]:1234"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "TestDiagnostics") {
+		t.Errorf("goroutine dump status = %d", w.Code)
+	}
+
+	// 未启用认证时拒绝非本机请求
+	if w := get("/debug/runtime", "10.0.0.8:1234"); w.Code != http.StatusForbidden {
+		t.Errorf("remote request status = %d, want 403", w.Code)
+	}
+
+	// 采样时间不超过上限
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=60", nil)
+	diagnostics.capSeconds(req, 30)
+	if got := req.URL.Query().Get("seconds"); got != "5" {
+		t.Errorf("capped seconds = %q, want 5", got)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/trace", nil)
+	diagnostics.capSeconds(req, 1)
+	if got := req.URL.Query().Get("seconds"); got != "" {
+		t.Errorf("default trace seconds should be kept, got %q", got)
+	}
+}
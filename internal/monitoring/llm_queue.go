@@ -0,0 +1,67 @@
+package monitoring
+
+import (
+	"ai-agent-assistant/internal/llm"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LLMQueueCollector LLM请求队列指标，抓取时从队列读取各服务商的排队深度和限流计数
+type LLMQueueCollector struct {
+	queue     *llm.RequestQueue
+	depth     *prometheus.Desc
+	active    *prometheus.Desc
+	throttled *prometheus.Desc
+	rejected  *prometheus.Desc
+	timedOut  *prometheus.Desc
+	cooldown  *prometheus.Desc
+}
+
+// NewLLMQueueCollector 创建LLM请求队列指标收集器
+func NewLLMQueueCollector(queue *llm.RequestQueue) *LLMQueueCollector {
+	return &LLMQueueCollector{
+		queue: queue,
+		depth: prometheus.NewDesc("llm_queue_depth",
+			"Number of LLM requests waiting in the queue",
+			[]string{"provider", "priority"}, nil),
+		active: prometheus.NewDesc("llm_queue_active_requests",
+			"Number of LLM requests in progress",
+			[]string{"provider"}, nil),
+		throttled: prometheus.NewDesc("llm_queue_throttled_total",
+			"Total number of rate limited (429) responses from the provider",
+			[]string{"provider"}, nil),
+		rejected: prometheus.NewDesc("llm_queue_rejected_total",
+			"Total number of LLM requests rejected because the queue was full",
+			[]string{"provider"}, nil),
+		timedOut: prometheus.NewDesc("llm_queue_timeouts_total",
+			"Total number of LLM requests that timed out waiting in the queue",
+			[]string{"provider"}, nil),
+		cooldown: prometheus.NewDesc("llm_queue_cooldown_seconds",
+			"Remaining time the provider is paused after being rate limited",
+			[]string{"provider"}, nil),
+	}
+}
+
+// Describe 实现prometheus.Collector
+func (c *LLMQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.active
+	ch <- c.throttled
+	ch <- c.rejected
+	ch <- c.timedOut
+	ch <- c.cooldown
+}
+
+// Collect 实现prometheus.Collector
+func (c *LLMQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.queue.Stats() {
+		for priority, depth := range s.QueuedByPriority {
+			ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(depth), s.Provider, priority)
+		}
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(s.Active), s.Provider)
+		ch <- prometheus.MustNewConstMetric(c.throttled, prometheus.CounterValue, float64(s.Throttled), s.Provider)
+		ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(s.Rejected), s.Provider)
+		ch <- prometheus.MustNewConstMetric(c.timedOut, prometheus.CounterValue, float64(s.TimedOut), s.Provider)
+		ch <- prometheus.MustNewConstMetric(c.cooldown, prometheus.GaugeValue, s.CooldownRemaining, s.Provider)
+	}
+}
@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"ai-agent-assistant/internal/rag"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RAGShadowCollector 检索影子测试指标，抓取时读取影子管道的累计统计
+// 平均重合度为 rag_shadow_overlap_sum / rag_shadow_queries_total{result="compared"}
+type RAGShadowCollector struct {
+	shadow      *rag.Shadow
+	queries     *prometheus.Desc
+	overlap     *prometheus.Desc
+	recall      *prometheus.Desc
+	top1        *prometheus.Desc
+	latency     *prometheus.Desc
+	indexed     *prometheus.Desc
+	indexErrors *prometheus.Desc
+}
+
+// NewRAGShadowCollector 创建检索影子测试指标收集器
+func NewRAGShadowCollector(shadow *rag.Shadow) *RAGShadowCollector {
+	return &RAGShadowCollector{
+		shadow: shadow,
+		queries: prometheus.NewDesc("rag_shadow_queries_total",
+			"Total number of sampled queries by outcome (compared, error, skipped)",
+			[]string{"pipeline", "result"}, nil),
+		overlap: prometheus.NewDesc("rag_shadow_overlap_sum",
+			"Sum of result overlap (intersection over union) between the primary and shadow pipelines",
+			[]string{"pipeline"}, nil),
+		recall: prometheus.NewDesc("rag_shadow_recall_sum",
+			"Sum of the fraction of primary results also returned by the shadow pipeline",
+			[]string{"pipeline"}, nil),
+		top1: prometheus.NewDesc("rag_shadow_top1_matches_total",
+			"Total number of compared queries whose first result is the same in both pipelines",
+			[]string{"pipeline"}, nil),
+		latency: prometheus.NewDesc("rag_shadow_latency_seconds_sum",
+			"Sum of retrieval latency of compared queries",
+			[]string{"pipeline", "side"}, nil),
+		indexed: prometheus.NewDesc("rag_shadow_indexed_chunks",
+			"Number of chunks in the shadow pipeline's own index",
+			[]string{"pipeline"}, nil),
+		indexErrors: prometheus.NewDesc("rag_shadow_index_errors_total",
+			"Total number of documents that failed to be written to the shadow index",
+			[]string{"pipeline"}, nil),
+	}
+}
+
+// Describe 实现prometheus.Collector
+func (c *RAGShadowCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queries
+	ch <- c.overlap
+	ch <- c.recall
+	ch <- c.top1
+	ch <- c.latency
+	ch <- c.indexed
+	ch <- c.indexErrors
+}
+
+// Collect 实现prometheus.Collector
+func (c *RAGShadowCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.shadow.Stats()
+	ch <- prometheus.MustNewConstMetric(c.queries, prometheus.CounterValue, float64(s.Sampled), s.Pipeline, "compared")
+	ch <- prometheus.MustNewConstMetric(c.queries, prometheus.CounterValue, float64(s.Errors), s.Pipeline, "error")
+	ch <- prometheus.MustNewConstMetric(c.queries, prometheus.CounterValue, float64(s.Skipped), s.Pipeline, "skipped")
+	ch <- prometheus.MustNewConstMetric(c.overlap, prometheus.CounterValue, s.OverlapSum, s.Pipeline)
+	ch <- prometheus.MustNewConstMetric(c.recall, prometheus.CounterValue, s.RecallSum, s.Pipeline)
+	ch <- prometheus.MustNewConstMetric(c.top1, prometheus.CounterValue, float64(s.Top1Matches), s.Pipeline)
+	ch <- prometheus.MustNewConstMetric(c.latency, prometheus.CounterValue, s.PrimarySeconds, s.Pipeline, "primary")
+	ch <- prometheus.MustNewConstMetric(c.latency, prometheus.CounterValue, s.ShadowSeconds, s.Pipeline, "shadow")
+	ch <- prometheus.MustNewConstMetric(c.indexed, prometheus.GaugeValue, float64(s.IndexedChunks), s.Pipeline)
+	ch <- prometheus.MustNewConstMetric(c.indexErrors, prometheus.CounterValue, float64(s.IndexErrors), s.Pipeline)
+}
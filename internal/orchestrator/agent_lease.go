@@ -0,0 +1,221 @@
+package orchestrator
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 租约失效后的Agent状态，两者都不会被调度
+const (
+	AgentStatusUnhealthy = "unhealthy" // 超过ttl没有心跳
+	AgentStatusExpired   = "expired"   // 超过expire_after没有心跳，到evict_after时注销
+)
+
+// 注册表事件名称，以广播消息（类型event，内容为*Event）发布到通信总线，
+// Data中包含 agent、previous_status、status 和 reason
+const (
+	EventAgentRegistered    = "agent.registered"
+	EventAgentStatusChanged = "agent.status_changed"
+	EventAgentDeregistered  = "agent.deregistered"
+)
+
+// 注册表事件的reason
+const (
+	ReasonRegistered    = "registered"     // 注册
+	ReasonUnregistered  = "unregistered"   // 主动注销
+	ReasonStatusUpdated = "status_updated" // UpdateStatus（如远程Agent连接、断开）
+	ReasonLeaseLapsed   = "lease_lapsed"   // 超过ttl或expire_after没有心跳
+	ReasonLeaseEvicted  = "lease_evicted"  // 超过evict_after没有心跳，被注销
+	ReasonHeartbeat     = "heartbeat"      // 租约失效后恢复心跳
+)
+
+// registryEventSource 注册表事件的发送者
+const registryEventSource = "registry"
+
+// defaultLeaseTTL 未配置租约时健康检查的判定时间，也是租约ttl的默认值
+const defaultLeaseTTL = 30 * time.Second
+
+// AgentLease 基于心跳的Agent租约，各时长都从最后一次心跳算起
+type AgentLease struct {
+	TTL           time.Duration // 超过后变为unhealthy
+	ExpireAfter   time.Duration // 超过后变为expired
+	EvictAfter    time.Duration // 超过后从注册表注销
+	CheckInterval time.Duration // 后台检查的间隔
+}
+
+// NewAgentLeaseFromConfig 根据 scheduler.registry.lease 配置创建租约，未启用时返回nil；
+// 未设置或不合法的时长使用默认值，expire_after不小于ttl，evict_after不小于expire_after
+func NewAgentLeaseFromConfig(cfg config.AgentLeaseConfig) *AgentLease {
+	if !cfg.Enabled {
+		return nil
+	}
+	parse := func(value string, def time.Duration) time.Duration {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		return def
+	}
+	lease := &AgentLease{TTL: parse(cfg.TTL, defaultLeaseTTL)}
+	lease.ExpireAfter = max(parse(cfg.ExpireAfter, 3*lease.TTL), lease.TTL)
+	lease.EvictAfter = max(parse(cfg.EvictAfter, 10*lease.TTL), lease.ExpireAfter)
+	lease.CheckInterval = parse(cfg.CheckInterval, lease.TTL/2)
+	return lease
+}
+
+// status 最后一次心跳后经过idle时租约对应的状态，租约有效时返回空字符串
+func (l *AgentLease) status(idle time.Duration) string {
+	switch {
+	case idle >= l.ExpireAfter:
+		return AgentStatusExpired
+	case idle >= l.TTL:
+		return AgentStatusUnhealthy
+	}
+	return ""
+}
+
+// leased Agent是否受租约限制：只有远程Agent（有endpoint）发送心跳
+func (a *AgentInfo) leased() bool {
+	return a.Endpoint != ""
+}
+
+// lapsed Agent是否处于租约失效状态
+func (a *AgentInfo) lapsed() bool {
+	return a.Status == AgentStatusUnhealthy || a.Status == AgentStatusExpired
+}
+
+// SetLease 设置Agent租约，nil表示不检查租约；需要定期检查时调用StartLeaseMonitor
+func (r *AgentRegistry) SetLease(lease *AgentLease) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lease = lease
+}
+
+// SetEventBus 设置通信总线，Agent注册、注销和状态变化时在总线上广播注册表事件；
+// 任务占用和释放Agent（active与busy之间的切换）不发布事件
+func (r *AgentRegistry) SetEventBus(bus *CommunicationBus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bus = bus
+}
+
+// publishEvent 在通信总线上广播注册表事件，未设置总线时忽略；发布失败只记录日志
+func (r *AgentRegistry) publishEvent(name, agent, previous, status, reason string) {
+	r.mu.RLock()
+	bus := r.bus
+	r.mu.RUnlock()
+	if bus == nil {
+		return
+	}
+	msg := NewEventMessage(registryEventSource, &Event{
+		Name:      name,
+		Source:    registryEventSource,
+		Timestamp: r.now(),
+		Data: map[string]interface{}{
+			"agent":           agent,
+			"previous_status": previous,
+			"status":          status,
+			"reason":          reason,
+		},
+	})
+	if err := bus.Broadcast(msg); err != nil {
+		log.Printf("failed to publish registry event %s for agent %s: %v", name, agent, err)
+	}
+}
+
+// ExpireLeases 检查一次租约：超过ttl没有心跳的Agent变为unhealthy，超过expire_after变为expired，
+// 超过evict_after注销；返回状态发生变化（含注销）的Agent数。未设置租约时不做任何事
+func (r *AgentRegistry) ExpireLeases() int {
+	r.mu.RLock()
+	lease := r.lease
+	r.mu.RUnlock()
+	if lease == nil {
+		return 0
+	}
+
+	changed := 0
+	for _, agent := range r.List() {
+		if !agent.leased() {
+			continue
+		}
+		now := r.now()
+		if now.Sub(agent.LastHeartbeat) >= lease.EvictAfter {
+			if err := r.remove(agent.Name); err != nil {
+				if !errors.Is(err, ErrAgentNotFound) {
+					log.Printf("failed to evict agent %s: %v", agent.Name, err)
+				}
+				continue
+			}
+			r.publishEvent(EventAgentDeregistered, agent.Name, agent.Status, "", ReasonLeaseEvicted)
+			changed++
+			continue
+		}
+
+		var previous, status string
+		_, err := r.update(agent.Name, func(a *AgentInfo) error {
+			previous, status = a.Status, a.Status
+			target := lease.status(now.Sub(a.LastHeartbeat))
+			if target == "" || target == a.Status || a.Status == AgentStatusExpired {
+				return nil
+			}
+			if !a.lapsed() {
+				// busy的Agent恢复后为active：其任务在租约失效期间可能已经结束，不会再释放它
+				a.LapsedStatus = a.Status
+				if a.LapsedStatus == "busy" {
+					a.LapsedStatus = "active"
+				}
+			}
+			a.Status = target
+			status = target
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, ErrAgentNotFound) {
+				log.Printf("failed to expire lease of agent %s: %v", agent.Name, err)
+			}
+			continue
+		}
+		if status != previous {
+			r.publishEvent(EventAgentStatusChanged, agent.Name, previous, status, ReasonLeaseLapsed)
+			changed++
+		}
+	}
+	return changed
+}
+
+// StartLeaseMonitor 在后台按租约的检查间隔调用ExpireLeases，Close时停止；未设置租约或已启动时不做任何事
+func (r *AgentRegistry) StartLeaseMonitor() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lease == nil || r.stopLease != nil {
+		return
+	}
+	stop := make(chan struct{})
+	r.stopLease = stop
+	interval := r.lease.CheckInterval
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.ExpireLeases()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopLeaseMonitor 停止后台的租约检查
+func (r *AgentRegistry) stopLeaseMonitor() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopLease != nil {
+		close(r.stopLease)
+		r.stopLease = nil
+	}
+}
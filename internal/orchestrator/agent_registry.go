@@ -1,11 +1,29 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
+
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+const (
+	// agentStoreTimeout 单次访问Agent存储的超时时间
+	agentStoreTimeout = 5 * time.Second
+
+	// maxClaimAttempts 选中的空闲Agent被其他调度方（或其他编排器实例）抢先占用时，重新选择的次数上限
+	maxClaimAttempts = 10
 )
 
+// errAgentNotActive 要占用的Agent不是active状态
+var errAgentNotActive = errors.New("agent is not active")
+
 // AgentInfo Agent信息
 type AgentInfo struct {
 	ID           string            `json:"id"`
@@ -13,234 +31,392 @@ type AgentInfo struct {
 	Type         string            `json:"type"`         // expert, general, custom
 	Capabilities []string          `json:"capabilities"` // Agent能力列表
 	Endpoint     string            `json:"endpoint"`     // Agent访问端点
-	Status       string            `json:"status"`       // active, inactive, busy
+	Status       string            `json:"status"`       // active, inactive, busy, unhealthy, expired
 	Metadata     map[string]string `json:"metadata"`
 	LastHeartbeat time.Time        `json:"last_heartbeat"`
 	CreatedAt    time.Time         `json:"created_at"`
+	LapsedStatus string            `json:"lapsed_status,omitempty"` // 租约失效前的状态，恢复心跳后还原
+	Tenant       string            `json:"tenant,omitempty"`        // 注册Agent的租户，为空表示所有租户共享
+}
+
+// clone Agent信息的副本，注册表对外只返回副本，避免调用方读取时与状态更新竞争
+func (a *AgentInfo) clone() *AgentInfo {
+	c := *a
+	c.Capabilities = append([]string(nil), a.Capabilities...)
+	c.Metadata = make(map[string]string, len(a.Metadata))
+	for k, v := range a.Metadata {
+		c.Metadata[k] = v
+	}
+	return &c
 }
 
 // AgentRegistry Agent注册表
+// 注册信息保存在AgentStore中，默认为内存存储；使用Redis或Postgres存储时编排器重启后注册信息仍在，
+// 多个编排器实例也可以共享同一份注册表。查询方法返回Agent信息的副本，状态变更需通过 UpdateStatus、UpdateHeartbeat 等方法
 type AgentRegistry struct {
-	mu     sync.RWMutex
-	agents map[string]*AgentInfo // key: agent_name
+	mu      sync.RWMutex
+	store   AgentStore
+	clock   clock.Clock        // 注册时间和心跳时间
+	scoring ScoringStrategy    // FindBestAgent的评分策略，nil表示只看能力匹配
+	stats   AgentStatsProvider // 评分使用的运行统计（可选）
+	lease   *AgentLease        // 基于心跳的租约，nil表示不检查
+	bus     *CommunicationBus  // 发布注册表事件（可选）
+	quotas  *tenant.Quotas     // 每个租户注册的Agent数上限，nil表示不限制
+
+	stopLease chan struct{} // 停止后台租约检查，未启动时为nil
 }
 
-// NewAgentRegistry 创建Agent注册表
+// NewAgentRegistry 创建使用内存存储的Agent注册表
 func NewAgentRegistry() *AgentRegistry {
+	return NewAgentRegistryWithStore(NewMemoryAgentStore())
+}
+
+// NewAgentRegistryWithStore 使用指定存储创建Agent注册表
+func NewAgentRegistryWithStore(store AgentStore) *AgentRegistry {
 	return &AgentRegistry{
-		agents: make(map[string]*AgentInfo),
+		store: store,
+		clock: clock.System,
 	}
 }
 
-// Register 注册Agent
-func (r *AgentRegistry) Register(agent *AgentInfo) error {
+// NewAgentRegistryFromConfig 根据 scheduler.registry 配置创建Agent注册表，启用租约时在后台检查租约
+func NewAgentRegistryFromConfig(cfg config.AgentRegistryConfig) (*AgentRegistry, error) {
+	scoring, err := NewScoringStrategy(cfg.Scoring)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewAgentStoreFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	registry := NewAgentRegistryWithStore(store)
+	registry.scoring = scoring
+	registry.lease = NewAgentLeaseFromConfig(cfg.Lease)
+	registry.StartLeaseMonitor()
+	return registry, nil
+}
+
+// SetClock 设置时钟，测试和模拟中使用clock.Fake
+func (r *AgentRegistry) SetClock(c clock.Clock) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.clock = clock.OrSystem(c)
+}
 
-	if _, exists := r.agents[agent.Name]; exists {
-		return fmt.Errorf("agent %s already registered", agent.Name)
+// Close 停止租约检查并关闭底层存储
+func (r *AgentRegistry) Close() error {
+	r.stopLeaseMonitor()
+	return r.store.Close()
+}
+
+// now 当前时间
+func (r *AgentRegistry) now() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clock.Now()
+}
+
+// agentError 给存储返回的错误加上Agent名称
+func agentError(name string, err error) error {
+	if errors.Is(err, ErrAgentNotFound) || errors.Is(err, ErrAgentExists) || errors.Is(err, errAgentNotActive) {
+		return fmt.Errorf("%w: %s", err, name)
 	}
+	return fmt.Errorf("agent %s: %w", name, err)
+}
 
-	agent.CreatedAt = time.Now()
-	agent.LastHeartbeat = time.Now()
+// Register 注册Agent，租户注册的Agent数达到配额时返回 tenant.ErrQuotaExceeded
+func (r *AgentRegistry) Register(agent *AgentInfo) error {
+	if err := r.checkQuota(agent); err != nil {
+		return err
+	}
+	now := r.now()
+	agent.CreatedAt = now
+	agent.LastHeartbeat = now
 	agent.Status = "active"
 
-	r.agents[agent.Name] = agent
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	if err := r.store.Create(ctx, agent.clone()); err != nil {
+		return agentError(agent.Name, err)
+	}
+	r.publishEvent(EventAgentRegistered, agent.Name, "", agent.Status, ReasonRegistered)
 	return nil
 }
 
+// Upsert 注册Agent，同名Agent已注册时更新其类型、能力、端点和元数据并刷新心跳，保留状态和注册时间
+// 新注册的Agent使用agent.Status（为空时为active），远程Agent以inactive注册、建立接收任务的连接后才可调度；
+// 用于远程Agent重启后重新注册，返回注册表中的Agent副本；Agent的租户不随重新注册改变
+func (r *AgentRegistry) Upsert(agent *AgentInfo) (*AgentInfo, error) {
+	if err := r.checkQuota(agent); err != nil {
+		return nil, err
+	}
+	now := r.now()
+	created := agent.clone()
+	created.CreatedAt = now
+	created.LastHeartbeat = now
+	if created.Status == "" {
+		created.Status = "active"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	err := r.store.Create(ctx, created)
+	if err == nil {
+		r.publishEvent(EventAgentRegistered, created.Name, "", created.Status, ReasonRegistered)
+		return created.clone(), nil
+	}
+	if !errors.Is(err, ErrAgentExists) {
+		return nil, agentError(agent.Name, err)
+	}
+
+	return r.update(agent.Name, func(existing *AgentInfo) error {
+		existing.Type = agent.Type
+		existing.Capabilities = append([]string(nil), agent.Capabilities...)
+		existing.Endpoint = agent.Endpoint
+		existing.Metadata = agent.clone().Metadata
+		existing.LastHeartbeat = now
+		return nil
+	})
+}
+
 // Unregister 注销Agent
 func (r *AgentRegistry) Unregister(name string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, exists := r.agents[name]; !exists {
-		return fmt.Errorf("agent %s not found", name)
+	if err := r.remove(name); err != nil {
+		return err
 	}
+	r.publishEvent(EventAgentDeregistered, name, "", "", ReasonUnregistered)
+	return nil
+}
 
-	delete(r.agents, name)
+// remove 从存储中删除Agent
+func (r *AgentRegistry) remove(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	if err := r.store.Delete(ctx, name); err != nil {
+		return agentError(name, err)
+	}
 	return nil
 }
 
 // Get 获取Agent信息
 func (r *AgentRegistry) Get(name string) (*AgentInfo, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	agent, exists := r.agents[name]
-	if !exists {
-		return nil, fmt.Errorf("agent %s not found", name)
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	agent, err := r.store.Get(ctx, name)
+	if err != nil {
+		return nil, agentError(name, err)
 	}
-
 	return agent, nil
 }
 
-// List 列出所有Agent
+// List 列出所有Agent（按名称排序），读取存储失败时记录日志并返回空列表
 func (r *AgentRegistry) List() []*AgentInfo {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	agents := make([]*AgentInfo, 0, len(r.agents))
-	for _, agent := range r.agents {
-		agents = append(agents, agent)
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	agents, err := r.store.List(ctx)
+	if err != nil {
+		log.Printf("failed to list agents: %v", err)
+		return []*AgentInfo{}
 	}
 	return agents
 }
 
-// ListByType 按类型列出Agent
-func (r *AgentRegistry) ListByType(agentType string) []*AgentInfo {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
+// filter 列出满足条件的Agent
+func (r *AgentRegistry) filter(match func(agent *AgentInfo) bool) []*AgentInfo {
 	agents := make([]*AgentInfo, 0)
-	for _, agent := range r.agents {
-		if agent.Type == agentType {
+	for _, agent := range r.List() {
+		if match(agent) {
 			agents = append(agents, agent)
 		}
 	}
 	return agents
 }
 
+// ListByType 按类型列出Agent
+func (r *AgentRegistry) ListByType(agentType string) []*AgentInfo {
+	return r.filter(func(agent *AgentInfo) bool { return agent.Type == agentType })
+}
+
 // ListByCapability 按能力列出Agent
 func (r *AgentRegistry) ListByCapability(capability string) []*AgentInfo {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	return r.filter(func(agent *AgentInfo) bool { return agent.hasCapability(capability) })
+}
 
-	agents := make([]*AgentInfo, 0)
-	for _, agent := range r.agents {
-		for _, cap := range agent.Capabilities {
-			if cap == capability {
-				agents = append(agents, agent)
-				break
-			}
+// hasCapability 是否具备指定能力
+func (a *AgentInfo) hasCapability(capability string) bool {
+	for _, cap := range a.Capabilities {
+		if cap == capability {
+			return true
 		}
 	}
-	return agents
+	return false
 }
 
-// UpdateHeartbeat 更新心跳
-func (r *AgentRegistry) UpdateHeartbeat(name string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	agent, exists := r.agents[name]
-	if !exists {
-		return fmt.Errorf("agent %s not found", name)
+// update 原子地修改Agent
+func (r *AgentRegistry) update(name string, update func(agent *AgentInfo) error) (*AgentInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+	agent, err := r.store.Update(ctx, name, update)
+	if err != nil {
+		return nil, agentError(name, err)
 	}
+	return agent, nil
+}
 
-	agent.LastHeartbeat = time.Now()
-	return nil
+// UpdateHeartbeat 更新心跳，租约失效（unhealthy、expired）的Agent恢复为失效前的状态
+func (r *AgentRegistry) UpdateHeartbeat(name string) error {
+	now := r.now()
+	var previous, status string
+	_, err := r.update(name, func(agent *AgentInfo) error {
+		agent.LastHeartbeat = now
+		previous = agent.Status
+		if agent.lapsed() {
+			agent.Status = agent.LapsedStatus
+			if agent.Status == "" {
+				agent.Status = "active"
+			}
+			agent.LapsedStatus = ""
+		}
+		status = agent.Status
+		return nil
+	})
+	if err == nil && status != previous {
+		r.publishEvent(EventAgentStatusChanged, name, previous, status, ReasonHeartbeat)
+	}
+	return err
 }
 
 // UpdateStatus 更新Agent状态
 func (r *AgentRegistry) UpdateStatus(name, status string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	agent, exists := r.agents[name]
-	if !exists {
-		return fmt.Errorf("agent %s not found", name)
+	var previous string
+	_, err := r.update(name, func(agent *AgentInfo) error {
+		previous = agent.Status
+		agent.Status = status
+		agent.LapsedStatus = ""
+		return nil
+	})
+	if err == nil && status != previous {
+		r.publishEvent(EventAgentStatusChanged, name, previous, status, ReasonStatusUpdated)
 	}
+	return err
+}
 
-	agent.Status = status
-	return nil
+// release 任务结束后释放Agent：只把busy的Agent恢复为active，执行期间被标记为inactive（如远程Agent断开）的Agent保持不变
+func (r *AgentRegistry) release(name string) error {
+	_, err := r.update(name, func(agent *AgentInfo) error {
+		if agent.Status == "busy" {
+			agent.Status = "active"
+		}
+		return nil
+	})
+	return err
 }
 
 // GetActiveAgents 获取所有活跃的Agent
 func (r *AgentRegistry) GetActiveAgents() []*AgentInfo {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	agents := make([]*AgentInfo, 0)
-	for _, agent := range r.agents {
-		if agent.Status == "active" {
-			agents = append(agents, agent)
-		}
-	}
-	return agents
+	return r.filter(func(agent *AgentInfo) bool { return agent.Status == "active" })
 }
 
 // CheckHealth 检查Agent健康状态
 func (r *AgentRegistry) CheckHealth(name string) bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	agent, exists := r.agents[name]
-	if !exists {
+	agent, err := r.Get(name)
+	if err != nil || agent.lapsed() {
 		return false
 	}
 
-	// 如果超过30秒没有心跳，认为不健康
-	return time.Since(agent.LastHeartbeat) < 30*time.Second
+	// 超过租约的ttl（未设置租约时为30秒）没有心跳，认为不健康
+	ttl := defaultLeaseTTL
+	r.mu.RLock()
+	if r.lease != nil {
+		ttl = r.lease.TTL
+	}
+	r.mu.RUnlock()
+	return r.now().Sub(agent.LastHeartbeat) < ttl
 }
 
 // GetIdleAgent 获取一个空闲的Agent
 func (r *AgentRegistry) GetIdleAgent() (*AgentInfo, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	for _, agent := range r.agents {
-		if agent.Status == "active" {
-			return agent, nil
-		}
+	active := r.GetActiveAgents()
+	if len(active) == 0 {
+		return nil, fmt.Errorf("no idle agent available")
 	}
-
-	return nil, fmt.Errorf("no idle agent available")
+	return active[0], nil
 }
 
-// FindBestAgent 根据能力找到最匹配的Agent
-func (r *AgentRegistry) FindBestAgent(requiredCapabilities []string) (*AgentInfo, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	var bestAgent *AgentInfo
-	maxMatch := 0
-
-	for _, agent := range r.agents {
+// claim 为租户的任务把Agent标记为busy并返回其副本，name为空时由pick从按名称排序、对租户可见的空闲Agent中选择一个
+// （pick为nil时选第一个），使选择结果只取决于随机种子而不是map的遍历顺序；指定的Agent对租户不可见时按不存在处理
+// 标记通过存储的原子更新完成，选中的Agent已被其他调度方（包括共享存储的其他编排器实例）占用时重新选择，
+// 避免两个调度方同时取得同一个Agent
+func (r *AgentRegistry) claim(tenantID, name string, pick func(n int) int) (*AgentInfo, error) {
+	markBusy := func(agent *AgentInfo) error {
+		if !agent.visibleTo(tenantID) {
+			return ErrAgentNotFound
+		}
 		if agent.Status != "active" {
-			continue
+			return errAgentNotActive
 		}
+		agent.Status = "busy"
+		return nil
+	}
 
-		matchCount := 0
-		for _, reqCap := range requiredCapabilities {
-			for _, agentCap := range agent.Capabilities {
-				if agentCap == reqCap {
-					matchCount++
-					break
-				}
-			}
-		}
+	if name != "" {
+		return r.update(name, markBusy)
+	}
 
-		if matchCount > maxMatch {
-			maxMatch = matchCount
-			bestAgent = agent
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		idle := r.activeAgentsFor(tenantID)
+		if len(idle) == 0 {
+			break
+		}
+		i := 0
+		if pick != nil {
+			i = pick(len(idle))
+		}
+		agent, err := r.update(idle[i].Name, markBusy)
+		if errors.Is(err, errAgentNotActive) || errors.Is(err, ErrAgentNotFound) {
+			continue
 		}
+		return agent, err
 	}
+	return nil, fmt.Errorf("no idle agent available")
+}
 
-	if bestAgent == nil {
-		return nil, fmt.Errorf("no agent found with required capabilities")
+// Restore 把快照中的Agent写回注册表，已注册的Agent跳过，返回写回的数量
+// 快照时为busy的Agent恢复为active：占用它的任务属于旧实例，不会在新实例上完成
+func (r *AgentRegistry) Restore(agents []*AgentInfo) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), agentStoreTimeout)
+	defer cancel()
+
+	restored := 0
+	for _, agent := range agents {
+		agent = agent.clone()
+		if agent.Status == "busy" {
+			agent.Status = "active"
+		}
+		err := r.store.Create(ctx, agent)
+		if errors.Is(err, ErrAgentExists) {
+			continue
+		}
+		if err != nil {
+			return restored, agentError(agent.Name, err)
+		}
+		restored++
 	}
+	return restored, nil
+}
 
-	return bestAgent, nil
+// FindBestAgent 找到具备最多所需能力的活跃共享Agent，匹配数相同时按评分策略（见SetScoringStrategy）选择，
+// 未设置策略时选名称在前的；租户的Agent见FindBestAgentFor
+func (r *AgentRegistry) FindBestAgent(requiredCapabilities []string) (*AgentInfo, error) {
+	return r.FindBestAgentFor("", requiredCapabilities)
 }
 
 // Count 统计Agent数量
 func (r *AgentRegistry) Count() int {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return len(r.agents)
+	return len(r.List())
 }
 
 // CountByStatus 按状态统计Agent数量
 func (r *AgentRegistry) CountByStatus(status string) int {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	count := 0
-	for _, agent := range r.agents {
-		if agent.Status == status {
-			count++
-		}
-	}
-	return count
+	return len(r.filter(func(agent *AgentInfo) bool { return agent.Status == status }))
 }
@@ -0,0 +1,155 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// Agent评分策略名称
+const (
+	ScoringCapability     = "capability"      // 只看能力匹配数，匹配数相同时按名称
+	ScoringLeastLoaded    = "least_loaded"    // 优先执行中任务少的Agent
+	ScoringBestPerforming = "best_performing" // 优先历史成功率高、执行快的Agent
+	ScoringWeighted       = "weighted"        // 按权重综合成功率、负载和时延
+)
+
+// weighted策略的默认权重
+const (
+	defaultSuccessWeight = 1.0
+	defaultLoadWeight    = 0.2
+	defaultLatencyWeight = 0.01
+)
+
+// AgentStats 评分使用的Agent运行统计
+type AgentStats struct {
+	Running    int           // 正在执行的任务数
+	Executions int           // 历史执行次数
+	Failures   int           // 历史失败次数
+	AvgLatency time.Duration // 平均执行时长
+}
+
+// SuccessRate 平滑后的历史成功率：(成功数+1)/(执行数+2)，没有历史的Agent为0.5，避免一两次执行就决定排名
+func (s AgentStats) SuccessRate() float64 {
+	return float64(s.Executions-s.Failures+1) / float64(s.Executions+2)
+}
+
+// AgentStatsProvider 提供Agent的运行统计，由 workflow.Monitor 等实现；没有该Agent的统计时返回false
+type AgentStatsProvider interface {
+	AgentStats(agentName string) (AgentStats, bool)
+}
+
+// AgentCandidate FindBestAgent的候选Agent
+type AgentCandidate struct {
+	Agent   *AgentInfo `json:"agent"`
+	Matched int        `json:"matched"` // 具备的所需能力数
+	Stats   AgentStats `json:"stats"`
+	Score   float64    `json:"score"` // 评分策略给出的分数
+}
+
+// ScoringStrategy Agent评分策略，分数越高越优先
+// 能力匹配数始终优先，分数只用于在匹配数相同的Agent之间选择
+type ScoringStrategy interface {
+	Score(candidate *AgentCandidate) float64
+}
+
+// ScoringFunc 函数形式的评分策略
+type ScoringFunc func(candidate *AgentCandidate) float64
+
+// Score 计算分数
+func (f ScoringFunc) Score(candidate *AgentCandidate) float64 {
+	return f(candidate)
+}
+
+// WeightedScoring 分数 = 成功率×SuccessWeight − 执行中任务数×LoadWeight − 平均时延秒数×LatencyWeight
+type WeightedScoring struct {
+	SuccessWeight float64
+	LoadWeight    float64
+	LatencyWeight float64
+}
+
+// Score 计算分数
+func (w WeightedScoring) Score(c *AgentCandidate) float64 {
+	return w.SuccessWeight*c.Stats.SuccessRate() -
+		w.LoadWeight*float64(c.Stats.Running) -
+		w.LatencyWeight*c.Stats.AvgLatency.Seconds()
+}
+
+// NewScoringStrategy 按名称创建评分策略，capability（或空）返回nil，即只看能力匹配
+func NewScoringStrategy(cfg config.AgentScoringConfig) (ScoringStrategy, error) {
+	switch cfg.Strategy {
+	case "", ScoringCapability:
+		return nil, nil
+	case ScoringLeastLoaded:
+		// 负载相同时优先成功率高的
+		return WeightedScoring{LoadWeight: 1, SuccessWeight: 0.01}, nil
+	case ScoringBestPerforming:
+		return WeightedScoring{SuccessWeight: 1, LatencyWeight: defaultLatencyWeight}, nil
+	case ScoringWeighted:
+		w := WeightedScoring{SuccessWeight: cfg.SuccessWeight, LoadWeight: cfg.LoadWeight, LatencyWeight: cfg.LatencyWeight}
+		if w.SuccessWeight == 0 && w.LoadWeight == 0 && w.LatencyWeight == 0 {
+			w = WeightedScoring{SuccessWeight: defaultSuccessWeight, LoadWeight: defaultLoadWeight, LatencyWeight: defaultLatencyWeight}
+		}
+		return w, nil
+	default:
+		return nil, fmt.Errorf("unknown agent scoring strategy: %s", cfg.Strategy)
+	}
+}
+
+// SetScoringStrategy 设置FindBestAgent的评分策略，nil表示只看能力匹配
+func (r *AgentRegistry) SetScoringStrategy(strategy ScoringStrategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scoring = strategy
+}
+
+// SetStatsProvider 设置评分使用的Agent运行统计来源
+func (r *AgentRegistry) SetStatsProvider(provider AgentStatsProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = provider
+}
+
+// RankAgents 按FindBestAgent的顺序列出具备至少一项所需能力的活跃共享Agent（没有所需能力时为全部活跃共享Agent）：
+// 先按能力匹配数，再按评分策略的分数，最后按名称
+func (r *AgentRegistry) RankAgents(requiredCapabilities []string) []*AgentCandidate {
+	return r.RankAgentsFor("", requiredCapabilities)
+}
+
+// RankAgentsFor 按FindBestAgentFor的顺序列出对租户可见的候选Agent，排序规则同RankAgents
+func (r *AgentRegistry) RankAgentsFor(tenantID string, requiredCapabilities []string) []*AgentCandidate {
+	r.mu.RLock()
+	strategy, provider := r.scoring, r.stats
+	r.mu.RUnlock()
+
+	candidates := make([]*AgentCandidate, 0)
+	for _, agent := range r.activeAgentsFor(tenantID) {
+		c := &AgentCandidate{Agent: agent}
+		for _, reqCap := range requiredCapabilities {
+			if agent.hasCapability(reqCap) {
+				c.Matched++
+			}
+		}
+		if c.Matched == 0 && len(requiredCapabilities) > 0 {
+			continue
+		}
+		if provider != nil {
+			c.Stats, _ = provider.AgentStats(agent.Name)
+		}
+		if strategy != nil {
+			c.Score = strategy.Score(c)
+		}
+		candidates = append(candidates, c)
+	}
+
+	// activeAgentsFor已按名称排序，稳定排序保留名称顺序
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Matched != candidates[j].Matched {
+			return candidates[i].Matched > candidates[j].Matched
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
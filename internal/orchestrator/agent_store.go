@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-agent-assistant/internal/config"
+)
+
+var (
+	// ErrAgentNotFound Agent未注册
+	ErrAgentNotFound = errors.New("agent not found")
+
+	// ErrAgentExists 同名Agent已注册
+	ErrAgentExists = errors.New("agent already registered")
+)
+
+// AgentStore Agent注册信息存储
+// 多个编排器实例共享同一个Redis或Postgres存储时共享同一份注册表，Update需保证读改写的原子性
+type AgentStore interface {
+	// Create 保存新Agent，同名Agent已存在时返回ErrAgentExists
+	Create(ctx context.Context, agent *AgentInfo) error
+
+	// Get 读取Agent，不存在时返回ErrAgentNotFound
+	Get(ctx context.Context, name string) (*AgentInfo, error)
+
+	// List 列出全部Agent，按名称排序
+	List(ctx context.Context) ([]*AgentInfo, error)
+
+	// Update 原子地读取、修改并保存Agent，返回修改后的副本
+	// update返回错误时不保存并原样返回该错误；Agent不存在时返回ErrAgentNotFound
+	Update(ctx context.Context, name string, update func(agent *AgentInfo) error) (*AgentInfo, error)
+
+	// Delete 删除Agent，不存在时返回ErrAgentNotFound
+	Delete(ctx context.Context, name string) error
+
+	// Close 释放存储连接
+	Close() error
+}
+
+// NewAgentStoreFromConfig 根据配置创建Agent存储，未设置时使用内存存储
+func NewAgentStoreFromConfig(cfg config.AgentRegistryConfig) (AgentStore, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryAgentStore(), nil
+	case "redis":
+		return NewRedisAgentStore(cfg.Redis)
+	case "postgres", "postgresql":
+		return OpenPostgresAgentStore(cfg.Postgres)
+	default:
+		return nil, fmt.Errorf("unsupported agent registry store: %s", cfg.Store)
+	}
+}
+
+// MemoryAgentStore 内存Agent存储（进程重启后丢失，不能在实例间共享）
+type MemoryAgentStore struct {
+	mu     sync.Mutex
+	agents map[string]*AgentInfo
+}
+
+// NewMemoryAgentStore 创建内存Agent存储
+func NewMemoryAgentStore() *MemoryAgentStore {
+	return &MemoryAgentStore{agents: make(map[string]*AgentInfo)}
+}
+
+// Create 保存新Agent
+func (s *MemoryAgentStore) Create(ctx context.Context, agent *AgentInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.agents[agent.Name]; exists {
+		return ErrAgentExists
+	}
+	s.agents[agent.Name] = agent.clone()
+	return nil
+}
+
+// Get 读取Agent
+func (s *MemoryAgentStore) Get(ctx context.Context, name string) (*AgentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, exists := s.agents[name]
+	if !exists {
+		return nil, ErrAgentNotFound
+	}
+	return agent.clone(), nil
+}
+
+// List 列出全部Agent
+func (s *MemoryAgentStore) List(ctx context.Context) ([]*AgentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agents := make([]*AgentInfo, 0, len(s.agents))
+	for _, agent := range s.agents {
+		agents = append(agents, agent.clone())
+	}
+	sortAgents(agents)
+	return agents, nil
+}
+
+// Update 在锁内修改Agent
+func (s *MemoryAgentStore) Update(ctx context.Context, name string, update func(agent *AgentInfo) error) (*AgentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, exists := s.agents[name]
+	if !exists {
+		return nil, ErrAgentNotFound
+	}
+	updated := agent.clone()
+	if err := update(updated); err != nil {
+		return nil, err
+	}
+	s.agents[name] = updated
+	return updated.clone(), nil
+}
+
+// Delete 删除Agent
+func (s *MemoryAgentStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.agents[name]; !exists {
+		return ErrAgentNotFound
+	}
+	delete(s.agents, name)
+	return nil
+}
+
+// Close 内存存储无需释放资源
+func (s *MemoryAgentStore) Close() error {
+	return nil
+}
+
+// sortAgents 按名称排序
+func sortAgents(agents []*AgentInfo) {
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+}
@@ -0,0 +1,243 @@
+package orchestrator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// agentMigration Agent表结构迁移
+type agentMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// postgresAgentMigrations Agent表迁移，按版本顺序执行
+var postgresAgentMigrations = []agentMigration{
+	{
+		version:     1,
+		description: "create agents table",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS agents (
+				name VARCHAR(255) PRIMARY KEY,
+				info JSONB NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+		},
+	},
+}
+
+// PostgresAgentStore Postgres Agent存储，Agent信息以JSON保存在agents.info列，Update使用行锁
+type PostgresAgentStore struct {
+	db *sql.DB
+}
+
+// OpenPostgresAgentStore 根据配置连接Postgres并创建Agent存储
+// 需要在main包中匿名导入对应的database/sql驱动（如 github.com/lib/pq）
+func OpenPostgresAgentStore(cfg config.PostgresSessionConfig) (*PostgresAgentStore, error) {
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("postgres dsn is required")
+	}
+	driver := cfg.Driver
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres (is the %q driver imported?): %w", driver, err)
+	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	store, err := NewPostgresAgentStore(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewPostgresAgentStore 使用已有连接创建Agent存储，并执行表结构迁移
+func NewPostgresAgentStore(ctx context.Context, db *sql.DB) (*PostgresAgentStore, error) {
+	store := &PostgresAgentStore{db: db}
+	if err := store.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate 执行未应用的表结构迁移，已应用的版本记录在agent_schema_migrations表
+func (s *PostgresAgentStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS agent_schema_migrations (
+		version INT PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current := 0
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM agent_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, migration := range postgresAgentMigrations {
+		if migration.version <= current {
+			continue
+		}
+		if err := s.applyMigration(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration 在事务中执行单个迁移
+func (s *PostgresAgentStore) applyMigration(ctx context.Context, migration agentMigration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", migration.version, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range migration.statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", migration.version, migration.description, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO agent_schema_migrations (version, description) VALUES ($1, $2)`,
+		migration.version, migration.description); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.version, err)
+	}
+
+	return tx.Commit()
+}
+
+// Create 保存新Agent
+func (s *PostgresAgentStore) Create(ctx context.Context, agent *AgentInfo) error {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return fmt.Errorf("failed to encode agent: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO agents (name, info) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`,
+		agent.Name, data)
+	if err != nil {
+		return fmt.Errorf("failed to save agent: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAgentExists
+	}
+	return nil
+}
+
+// Get 读取Agent
+func (s *PostgresAgentStore) Get(ctx context.Context, name string) (*AgentInfo, error) {
+	return scanAgent(s.db.QueryRowContext(ctx, `SELECT info FROM agents WHERE name = $1`, name))
+}
+
+// List 列出全部Agent
+func (s *PostgresAgentStore) List(ctx context.Context) ([]*AgentInfo, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT info FROM agents ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := make([]*AgentInfo, 0)
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, agent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	return agents, nil
+}
+
+// Update 在事务中用SELECT ... FOR UPDATE锁住该行后修改Agent
+func (s *PostgresAgentStore) Update(ctx context.Context, name string, update func(agent *AgentInfo) error) (*AgentInfo, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	agent, err := scanAgent(tx.QueryRowContext(ctx, `SELECT info FROM agents WHERE name = $1 FOR UPDATE`, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := update(agent); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode agent: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE agents SET info = $2, updated_at = NOW() WHERE name = $1`, name, data); err != nil {
+		return nil, fmt.Errorf("failed to save agent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit agent update: %w", err)
+	}
+	return agent, nil
+}
+
+// Delete 删除Agent
+func (s *PostgresAgentStore) Delete(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM agents WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAgentNotFound
+	}
+	return nil
+}
+
+// Close 关闭数据库连接
+func (s *PostgresAgentStore) Close() error {
+	return s.db.Close()
+}
+
+// scanAgent 从查询结果中解码Agent
+func scanAgent(row interface{ Scan(dest ...any) error }) (*AgentInfo, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAgentNotFound
+		}
+		return nil, fmt.Errorf("failed to load agent: %w", err)
+	}
+
+	var agent AgentInfo
+	if err := json.Unmarshal(data, &agent); err != nil {
+		return nil, fmt.Errorf("failed to decode agent: %w", err)
+	}
+	return &agent, nil
+}
@@ -0,0 +1,181 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisAgentPrefix Redis中Agent键前缀
+const redisAgentPrefix = "ai-agent:agent:"
+
+// redisAgentUpdateRetries 乐观锁冲突（其他实例同时修改同一个Agent）时Update的重试次数
+const redisAgentUpdateRetries = 10
+
+// RedisAgentStore Redis Agent存储，每个Agent一个JSON值，Update使用WATCH乐观锁
+type RedisAgentStore struct {
+	client *redis.Client
+}
+
+// NewRedisAgentStore 创建Redis Agent存储
+func NewRedisAgentStore(cfg config.RedisConfig) (*RedisAgentStore, error) {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return NewRedisAgentStoreWithClient(client), nil
+}
+
+// NewRedisAgentStoreWithClient 使用已有的Redis客户端创建Agent存储
+func NewRedisAgentStoreWithClient(client *redis.Client) *RedisAgentStore {
+	return &RedisAgentStore{client: client}
+}
+
+// Create 保存新Agent，使用SETNX保证同名Agent只注册一次
+func (s *RedisAgentStore) Create(ctx context.Context, agent *AgentInfo) error {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return fmt.Errorf("failed to encode agent: %w", err)
+	}
+	created, err := s.client.SetNX(ctx, redisAgentPrefix+agent.Name, data, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to save agent: %w", err)
+	}
+	if !created {
+		return ErrAgentExists
+	}
+	return nil
+}
+
+// Get 读取Agent
+func (s *RedisAgentStore) Get(ctx context.Context, name string) (*AgentInfo, error) {
+	return s.get(ctx, s.client, name)
+}
+
+// get 通过客户端或WATCH事务读取Agent
+func (s *RedisAgentStore) get(ctx context.Context, cmd redis.Cmdable, name string) (*AgentInfo, error) {
+	data, err := cmd.Get(ctx, redisAgentPrefix+name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrAgentNotFound
+		}
+		return nil, fmt.Errorf("failed to load agent: %w", err)
+	}
+
+	var agent AgentInfo
+	if err := json.Unmarshal(data, &agent); err != nil {
+		return nil, fmt.Errorf("failed to decode agent: %w", err)
+	}
+	return &agent, nil
+}
+
+// List 列出全部Agent
+func (s *RedisAgentStore) List(ctx context.Context) ([]*AgentInfo, error) {
+	keys := make([]string, 0)
+	iter := s.client.Scan(ctx, 0, redisAgentPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	agents := make([]*AgentInfo, 0, len(keys))
+	if len(keys) == 0 {
+		return agents, nil
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agents: %w", err)
+	}
+	for i, value := range values {
+		// 扫描和读取之间被删除的Agent
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var agent AgentInfo
+		if err := json.Unmarshal([]byte(data), &agent); err != nil {
+			return nil, fmt.Errorf("failed to decode agent %s: %w", keys[i], err)
+		}
+		agents = append(agents, &agent)
+	}
+	sortAgents(agents)
+	return agents, nil
+}
+
+// Update 在WATCH事务中修改Agent，键在读取后被其他实例修改时重试
+func (s *RedisAgentStore) Update(ctx context.Context, name string, update func(agent *AgentInfo) error) (*AgentInfo, error) {
+	key := redisAgentPrefix + name
+	var updated *AgentInfo
+	txf := func(tx *redis.Tx) error {
+		agent, err := s.get(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if err := update(agent); err != nil {
+			return err
+		}
+		data, err := json.Marshal(agent)
+		if err != nil {
+			return fmt.Errorf("failed to encode agent: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			return nil
+		})
+		updated = agent
+		return err
+	}
+
+	for i := 0; i < redisAgentUpdateRetries; i++ {
+		err := s.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("failed to update agent %s: too many concurrent updates", name)
+}
+
+// Delete 删除Agent
+func (s *RedisAgentStore) Delete(ctx context.Context, name string) error {
+	deleted, err := s.client.Del(ctx, redisAgentPrefix+name).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete agent: %w", err)
+	}
+	if deleted == 0 {
+		return ErrAgentNotFound
+	}
+	return nil
+}
+
+// Close 关闭Redis连接
+func (s *RedisAgentStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,320 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 默认消息投递参数，可通过 scheduler.bus 覆盖
+const (
+	defaultBusPrefix     = "agents"
+	defaultBusMaxDeliver = 5
+	defaultBusRetryDelay = time.Second
+	defaultBusAckWait    = 30 * time.Second
+)
+
+// memoryBrokerBuffer 内存代理每个订阅者等待处理的消息数上限，超出时Publish阻塞
+const memoryBrokerBuffer = 1000
+
+// ErrBrokerClosed 消息代理已关闭
+var ErrBrokerClosed = errors.New("broker closed")
+
+// BrokerHandler 处理代理投递的一条消息，返回错误时消息稍后重新投递，直到达到最多投递次数
+type BrokerHandler func(ctx context.Context, data []byte) error
+
+// Broker 消息代理，CommunicationBus通过它在多个编排器和Agent进程之间传递消息
+// 消息至少投递一次：处理失败或处理前进程退出的消息会重新投递，处理函数需能容忍重复消息
+type Broker interface {
+	// Publish 发布消息到主题
+	Publish(ctx context.Context, topic string, data []byte) error
+
+	// Subscribe 订阅主题。group不为空时同组的订阅者（可以在不同进程中）组成消费组，每条消息只投递给组内一个订阅者；
+	// group为空时每个订阅者都收到全部消息。消费组第一次订阅之前发布的消息不会投递给该组
+	Subscribe(topic, group string, handler BrokerHandler) (BrokerSubscription, error)
+
+	// Close 取消全部订阅并释放连接
+	Close() error
+}
+
+// BrokerSubscription 代理上的一个订阅
+type BrokerSubscription interface {
+	// Unsubscribe 停止接收消息，消费组的进度保留在代理上
+	Unsubscribe() error
+}
+
+// DeliveryPolicy 处理失败时的重新投递策略
+type DeliveryPolicy struct {
+	MaxDeliver int           // 最多投递次数（含第一次），之后丢弃该消息
+	RetryDelay time.Duration // 处理失败后重新投递前的等待时间
+	AckWait    time.Duration // 等待处理确认的时间，超时未确认时重新投递（nats）
+}
+
+// deliveryPolicyFromConfig 根据配置创建投递策略，未设置或无效的配置项使用默认值
+func deliveryPolicyFromConfig(cfg config.BusConfig) DeliveryPolicy {
+	policy := DeliveryPolicy{
+		MaxDeliver: defaultBusMaxDeliver,
+		RetryDelay: defaultBusRetryDelay,
+		AckWait:    defaultBusAckWait,
+	}
+	if cfg.MaxDeliver > 0 {
+		policy.MaxDeliver = cfg.MaxDeliver
+	}
+	if d, err := time.ParseDuration(cfg.RetryDelay); err == nil && d >= 0 {
+		policy.RetryDelay = d
+	}
+	if d, err := time.ParseDuration(cfg.AckWait); err == nil && d > 0 {
+		policy.AckWait = d
+	}
+	return policy
+}
+
+// NewBrokerFromConfig 根据 scheduler.bus 配置创建消息代理，broker为空或memory时返回nil（只在进程内通信）
+func NewBrokerFromConfig(cfg config.BusConfig) (Broker, error) {
+	policy := deliveryPolicyFromConfig(cfg)
+	switch strings.ToLower(cfg.Broker) {
+	case "", "memory":
+		return nil, nil
+	case "nats":
+		return NewNATSBroker(cfg.NATS, busPrefix(cfg), policy)
+	case "kafka":
+		return NewKafkaBroker(cfg.Kafka, policy)
+	default:
+		return nil, fmt.Errorf("unsupported bus broker: %s", cfg.Broker)
+	}
+}
+
+// busPrefix 主题前缀
+func busPrefix(cfg config.BusConfig) string {
+	if cfg.Prefix == "" {
+		return defaultBusPrefix
+	}
+	return cfg.Prefix
+}
+
+// sanitizeTopicToken 名称转换为主题和消费组名称中可用的形式：字母、数字、'_'、'-'之外的字符替换为'_'
+// NATS主题以'.'分隔，消费者名称不能包含'.'、'*'、'>'，Kafka主题只允许字母、数字和'.'、'_'、'-'
+func sanitizeTopicToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// brokerClientID 代理客户端名称，用于区分进程，默认主机名
+func brokerClientID(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "ai-agent-assistant"
+}
+
+// deliverWithRetry 在进程内按投递策略重试处理消息，直到成功、达到最多投递次数或stop关闭
+// 返回消息是否已经处理完毕（成功或放弃），stop关闭时返回false，消息留给代理重新投递
+func deliverWithRetry(policy DeliveryPolicy, stop <-chan struct{}, topic string, data []byte, handler BrokerHandler) bool {
+	maxDeliver := policy.MaxDeliver
+	if maxDeliver <= 0 {
+		maxDeliver = 1
+	}
+	for attempt := 1; ; attempt++ {
+		err := handler(context.Background(), data)
+		if err == nil {
+			return true
+		}
+		if attempt >= maxDeliver {
+			log.Printf("bus: dropping message on %s after %d deliveries: %v", topic, attempt, err)
+			return true
+		}
+
+		timer := time.NewTimer(policy.RetryDelay)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// MemoryBroker 内存消息代理，只能在一个进程内使用，用于测试和单进程部署
+// 语义与nats、kafka代理一致：同组订阅者轮流接收消息，处理失败的消息按投递策略重试
+type MemoryBroker struct {
+	policy DeliveryPolicy
+
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	closed bool
+}
+
+// memoryTopic 内存代理的一个主题
+type memoryTopic struct {
+	groups map[string][]*memorySubscription // 消费组 -> 组内订阅者
+	next   map[string]int                   // 消费组 -> 下一个接收消息的订阅者
+	fanout []*memorySubscription            // 不属于消费组的订阅者
+}
+
+// memorySubscription 内存代理上的订阅，消息按发布顺序逐条处理
+type memorySubscription struct {
+	broker  *MemoryBroker
+	topic   string
+	group   string
+	handler BrokerHandler
+	queue   chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryBroker 创建内存消息代理
+func NewMemoryBroker(policy DeliveryPolicy) *MemoryBroker {
+	return &MemoryBroker{policy: policy, topics: make(map[string]*memoryTopic)}
+}
+
+// Publish 发布消息到主题，没有订阅者时丢弃
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	var targets []*memorySubscription
+	if t, ok := b.topics[topic]; ok {
+		targets = append(targets, t.fanout...)
+		for group, members := range t.groups {
+			i := t.next[group] % len(members)
+			t.next[group] = i + 1
+			targets = append(targets, members[i])
+		}
+	}
+	b.mu.Unlock()
+
+	for _, sub := range targets {
+		select {
+		case sub.queue <- data:
+		case <-sub.stop:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅主题
+func (b *MemoryBroker) Subscribe(topic, group string, handler BrokerHandler) (BrokerSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	sub := &memorySubscription{
+		broker:  b,
+		topic:   topic,
+		group:   group,
+		handler: handler,
+		queue:   make(chan []byte, memoryBrokerBuffer),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	t, ok := b.topics[topic]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string][]*memorySubscription), next: make(map[string]int)}
+		b.topics[topic] = t
+	}
+	if group == "" {
+		t.fanout = append(t.fanout, sub)
+	} else {
+		t.groups[group] = append(t.groups[group], sub)
+	}
+	go sub.run()
+	return sub, nil
+}
+
+// Close 取消全部订阅
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	var subs []*memorySubscription
+	for _, t := range b.topics {
+		subs = append(subs, t.fanout...)
+		for _, members := range t.groups {
+			subs = append(subs, members...)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return nil
+}
+
+// remove 从主题中删除订阅
+func (b *MemoryBroker) remove(sub *memorySubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[sub.topic]
+	if !ok {
+		return
+	}
+	if sub.group == "" {
+		t.fanout = removeSubscription(t.fanout, sub)
+	} else if members := removeSubscription(t.groups[sub.group], sub); len(members) > 0 {
+		t.groups[sub.group] = members
+	} else {
+		delete(t.groups, sub.group)
+		delete(t.next, sub.group)
+	}
+	if len(t.fanout) == 0 && len(t.groups) == 0 {
+		delete(b.topics, sub.topic)
+	}
+}
+
+// removeSubscription 从列表中删除订阅
+func removeSubscription(subs []*memorySubscription, sub *memorySubscription) []*memorySubscription {
+	kept := subs[:0]
+	for _, s := range subs {
+		if s != sub {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// run 逐条处理消息
+func (s *memorySubscription) run() {
+	defer close(s.done)
+	for {
+		select {
+		case data := <-s.queue:
+			if !deliverWithRetry(s.broker.policy, s.stop, s.topic, data, s.handler) {
+				return
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Unsubscribe 停止接收消息，等待正在处理的消息结束，尚未处理的消息丢弃
+func (s *memorySubscription) Unsubscribe() error {
+	s.once.Do(func() {
+		s.broker.remove(s)
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
@@ -0,0 +1,180 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// defaultKafkaBroker 未配置Kafka地址时使用
+const defaultKafkaBroker = "127.0.0.1:9092"
+
+// KafkaBroker Kafka消息代理
+// 消费组对应Kafka消费组，消息处理成功（或达到最多投递次数）后才提交位移，进程在处理前退出时由组内其他订阅者重新消费；
+// 不属于消费组的订阅者使用各自唯一的临时消费组，从订阅时的最新位置开始接收
+type KafkaBroker struct {
+	brokers  []string
+	clientID string
+	dialer   *kafka.Dialer
+	writer   *kafka.Writer
+	policy   DeliveryPolicy
+
+	mu     sync.Mutex
+	subs   map[*kafkaSubscription]struct{}
+	closed bool
+}
+
+// kafkaSubscription Kafka上的一个订阅，每个订阅一个Reader
+type kafkaSubscription struct {
+	broker *KafkaBroker
+	reader *kafka.Reader
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewKafkaBroker 创建Kafka消息代理，主题不存在时发布消息会自动创建（需broker允许自动创建主题）
+func NewKafkaBroker(cfg config.KafkaBusConfig, policy DeliveryPolicy) (*KafkaBroker, error) {
+	brokers := cfg.Brokers
+	if len(brokers) == 0 {
+		brokers = []string{defaultKafkaBroker}
+	}
+	clientID := brokerClientID(cfg.ClientID)
+	dialer := &kafka.Dialer{ClientID: clientID, Timeout: 10 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kafka: %w", err)
+	}
+	conn.Close()
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		Transport:              &kafka.Transport{ClientID: clientID},
+	}
+	return &KafkaBroker{
+		brokers:  brokers,
+		clientID: clientID,
+		dialer:   dialer,
+		writer:   writer,
+		policy:   policy,
+		subs:     make(map[*kafkaSubscription]struct{}),
+	}, nil
+}
+
+// Publish 发布消息，所有同步副本写入后返回
+func (b *KafkaBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	if err := b.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Value: data}); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe 订阅主题，group为空时使用唯一的临时消费组
+func (b *KafkaBroker) Subscribe(topic, group string, handler BrokerHandler) (BrokerSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	groupID := sanitizeTopicToken(group)
+	if groupID == "" {
+		suffix := make([]byte, 6)
+		rand.Read(suffix)
+		groupID = sanitizeTopicToken(b.clientID) + "-" + hex.EncodeToString(suffix)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &kafkaSubscription{
+		broker: b,
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     b.brokers,
+			GroupID:     groupID,
+			Topic:       topic,
+			Dialer:      b.dialer,
+			StartOffset: kafka.LastOffset,
+		}),
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	b.subs[sub] = struct{}{}
+	go sub.run(ctx, topic, handler)
+	return sub, nil
+}
+
+// Close 取消全部订阅并关闭连接
+func (b *KafkaBroker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*kafkaSubscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return b.writer.Close()
+}
+
+// run 逐条读取并处理消息，处理完毕后提交位移
+func (s *kafkaSubscription) run(ctx context.Context, topic string, handler BrokerHandler) {
+	defer close(s.done)
+	for {
+		msg, err := s.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("bus: failed to fetch from %s: %v", topic, err)
+			select {
+			case <-time.After(s.broker.policy.RetryDelay):
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+
+		if !deliverWithRetry(s.broker.policy, s.stop, topic, msg.Value, handler) {
+			// 停止订阅时未处理完的消息不提交，由组内其他订阅者或下次订阅重新消费
+			return
+		}
+		if err := s.reader.CommitMessages(ctx, msg); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("bus: failed to commit offset on %s: %v", topic, err)
+		}
+	}
+}
+
+// Unsubscribe 停止接收消息，等待正在处理的消息结束
+func (s *kafkaSubscription) Unsubscribe() error {
+	var err error
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		delete(s.broker.subs, s)
+		s.broker.mu.Unlock()
+
+		close(s.stop)
+		s.cancel()
+		<-s.done
+		err = s.reader.Close()
+	})
+	return err
+}
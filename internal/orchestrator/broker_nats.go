@@ -0,0 +1,146 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/nats-io/nats.go"
+)
+
+// 默认NATS参数
+const (
+	defaultNATSURL    = nats.DefaultURL
+	defaultNATSStream = "AGENT_BUS"
+	natsStreamMaxAge  = 24 * time.Hour // 流中消息的保留时间
+)
+
+// NATSBroker NATS JetStream消息代理
+// 前缀下的全部主题保存在一个流中；消费组对应一个持久推送消费者，同组订阅者以队列组方式分担消息，
+// 处理成功后确认，失败时延迟重新投递，超过最多投递次数后不再投递
+type NATSBroker struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	stream string
+	policy DeliveryPolicy
+}
+
+// NewNATSBroker 连接NATS并确保流存在，流覆盖 <prefix>.> 下的全部主题
+func NewNATSBroker(cfg config.NATSBusConfig, prefix string, policy DeliveryPolicy) (*NATSBroker, error) {
+	url := cfg.URL
+	if url == "" {
+		url = defaultNATSURL
+	}
+	stream := cfg.Stream
+	if stream == "" {
+		stream = defaultNATSStream
+	}
+
+	conn, err := nats.Connect(url, nats.Name(brokerClientID("")), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	if _, err := js.StreamInfo(stream); errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{prefix + ".>"},
+			Storage:  nats.FileStorage,
+			MaxAge:   natsStreamMaxAge,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create nats stream %s: %w", stream, err)
+		}
+	} else if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get nats stream %s: %w", stream, err)
+	}
+
+	return &NATSBroker{conn: conn, js: js, stream: stream, policy: policy}, nil
+}
+
+// Publish 发布消息，JetStream确认保存后返回
+func (b *NATSBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	if _, err := b.js.Publish(topic, data, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe 订阅主题：group不为空时使用以group命名的持久消费者（不存在时创建），为空时使用临时消费者
+func (b *NATSBroker) Subscribe(topic, group string, handler BrokerHandler) (BrokerSubscription, error) {
+	cb := func(msg *nats.Msg) {
+		if err := handler(context.Background(), msg.Data); err != nil {
+			if meta, metaErr := msg.Metadata(); metaErr == nil && int(meta.NumDelivered) >= b.policy.MaxDeliver {
+				// 最后一次投递也失败，终止该消息的投递
+				msg.Term()
+				return
+			}
+			msg.NakWithDelay(b.policy.RetryDelay)
+			return
+		}
+		msg.Ack()
+	}
+
+	if group == "" {
+		sub, err := b.js.Subscribe(topic, cb,
+			nats.ManualAck(),
+			nats.DeliverNew(),
+			nats.AckWait(b.policy.AckWait),
+			nats.MaxDeliver(b.policy.MaxDeliver),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+		}
+		return sub, nil
+	}
+
+	durable := sanitizeTopicToken(group)
+	if err := b.ensureConsumer(topic, durable); err != nil {
+		return nil, err
+	}
+	// 绑定到已有的消费者，取消订阅时不会删除消费者，消费组的进度保留在服务端
+	sub, err := b.js.QueueSubscribe(topic, durable, cb, nats.Bind(b.stream, durable), nats.ManualAck())
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	return sub, nil
+}
+
+// ensureConsumer 创建消费组对应的持久推送消费者，其他进程已创建时直接使用
+func (b *NATSBroker) ensureConsumer(topic, durable string) error {
+	if _, err := b.js.ConsumerInfo(b.stream, durable); err == nil {
+		return nil
+	} else if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("failed to get nats consumer %s: %w", durable, err)
+	}
+
+	_, err := b.js.AddConsumer(b.stream, &nats.ConsumerConfig{
+		Durable:        durable,
+		DeliverSubject: "_bus.deliver." + durable,
+		DeliverGroup:   durable,
+		DeliverPolicy:  nats.DeliverNewPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        b.policy.AckWait,
+		MaxDeliver:     b.policy.MaxDeliver,
+		FilterSubject:  topic,
+	})
+	if err != nil && !errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
+		return fmt.Errorf("failed to create nats consumer %s: %w", durable, err)
+	}
+	return nil
+}
+
+// Close 取消全部订阅，正在处理的消息处理完后在后台关闭连接
+func (b *NATSBroker) Close() error {
+	return b.conn.Drain()
+}
@@ -1,10 +1,16 @@
 package orchestrator
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/idgen"
 )
 
 // MessageType 消息类型
@@ -21,27 +27,43 @@ const (
 
 // Message 消息定义
 type Message struct {
-	ID        string                 `json:"id"`
-	Type      MessageType           `json:"type"`
-	From      string                 `json:"from"`      // 发送者Agent名称
-	To        string                 `json:"to"`        // 接收者Agent名称（空表示广播）
-	Content   interface{}            `json:"content"`
-	Timestamp time.Time              `json:"timestamp"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ID            string                 `json:"id"`
+	Type          MessageType            `json:"type"`
+	From          string                 `json:"from"` // 发送者Agent名称
+	To            string                 `json:"to"`   // 接收者Agent名称（空表示广播）
+	Content       interface{}            `json:"content"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"` // 请求与回复的关联ID，回复沿用请求的值
+	ReplyTo       string                 `json:"reply_to,omitempty"`       // 回复的接收地址，由Request设置
 }
 
 // MessageHandler 消息处理函数
 type MessageHandler func(msg *Message) error
 
 // CommunicationBus 通信总线
+// 设置消息代理后Send/Broadcast/Subscribe经代理跨进程工作：发给Agent的消息由订阅该Agent的所有进程组成的消费组
+// 中的一个接收，广播消息每个进程都接收；处理函数返回错误时消息重新投递，处理函数需能容忍重复消息
 type CommunicationBus struct {
-	mu               sync.RWMutex
-	subscribers      map[string][]MessageHandler // agent_name -> handlers
-	broadcastSubs    []MessageHandler            // 广播订阅者
-	messageHistory   []*Message                  // 消息历史（用于调试）
-	maxHistory       int
-	eventChan         chan *Message
-	stopped          chan struct{}
+	mu             sync.RWMutex
+	subscribers    map[string][]MessageHandler // agent_name -> handlers
+	broadcastSubs  []MessageHandler            // 广播订阅者
+	messageHistory []*Message                  // 消息历史（用于调试）
+	maxHistory     int
+	eventChan      chan *Message
+	stopped        chan struct{}
+
+	broker       Broker                        // 为nil时只在进程内通信
+	prefix       string                        // 代理主题前缀
+	agentSubs    map[string]BrokerSubscription // agent_name -> 代理上的订阅
+	broadcastSub BrokerSubscription
+
+	pendingMu sync.Mutex
+	pending   map[string]chan *Message // correlation_id -> 等待回复的Request
+	inbox     string                   // 本总线接收回复的地址
+	inboxSub  BrokerSubscription       // 代理上回复地址的订阅
+
+	msgLog MessageLog // 本进程发送的消息的持久化日志（可选）
 }
 
 // NewCommunicationBus 创建通信总线
@@ -53,6 +75,8 @@ func NewCommunicationBus() *CommunicationBus {
 		maxHistory:     1000,
 		eventChan:      make(chan *Message, 1000),
 		stopped:        make(chan struct{}),
+		pending:        make(map[string]chan *Message),
+		inbox:          idgen.New(inboxPrefix),
 	}
 
 	// 启动事件处理协程
@@ -61,28 +85,82 @@ func NewCommunicationBus() *CommunicationBus {
 	return bus
 }
 
-// Subscribe 订阅消息
-func (b *CommunicationBus) Subscribe(agentName string, handler MessageHandler) {
+// NewCommunicationBusWithBroker 创建经消息代理跨进程通信的总线，prefix为代理主题前缀，Stop时关闭代理
+func NewCommunicationBusWithBroker(broker Broker, prefix string) *CommunicationBus {
+	bus := NewCommunicationBus()
+	bus.broker = broker
+	bus.prefix = prefix
+	bus.agentSubs = make(map[string]BrokerSubscription)
+	return bus
+}
+
+// NewCommunicationBusFromConfig 根据 scheduler.bus 配置创建通信总线，broker为空或memory时只在进程内通信
+func NewCommunicationBusFromConfig(cfg config.BusConfig) (*CommunicationBus, error) {
+	broker, err := NewBrokerFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	msgLog, err := NewMessageLogFromConfig(cfg.Log)
+	if err != nil {
+		if broker != nil {
+			broker.Close()
+		}
+		return nil, err
+	}
+
+	bus := NewCommunicationBus()
+	if broker != nil {
+		bus = NewCommunicationBusWithBroker(broker, busPrefix(cfg))
+	}
+	if msgLog != nil {
+		bus.SetMessageLog(msgLog)
+	}
+	return bus, nil
+}
+
+// Subscribe 订阅消息，设置了消息代理时该Agent的第一个订阅同时加入代理上该Agent的消费组
+func (b *CommunicationBus) Subscribe(agentName string, handler MessageHandler) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.broker != nil && b.agentSubs[agentName] == nil {
+		sub, err := b.broker.Subscribe(b.agentTopic(agentName), b.agentGroup(agentName), b.deliver)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe agent %s: %w", agentName, err)
+		}
+		b.agentSubs[agentName] = sub
+	}
 	b.subscribers[agentName] = append(b.subscribers[agentName], handler)
+	return nil
 }
 
-// SubscribeBroadcast 订阅广播消息
-func (b *CommunicationBus) SubscribeBroadcast(handler MessageHandler) {
+// SubscribeBroadcast 订阅广播消息，设置了消息代理时第一个订阅同时订阅代理上的广播主题
+func (b *CommunicationBus) SubscribeBroadcast(handler MessageHandler) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.broker != nil && b.broadcastSub == nil {
+		sub, err := b.broker.Subscribe(b.broadcastTopic(), "", b.deliver)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe broadcast: %w", err)
+		}
+		b.broadcastSub = sub
+	}
 	b.broadcastSubs = append(b.broadcastSubs, handler)
+	return nil
 }
 
-// Unsubscribe 取消订阅
+// Unsubscribe 取消订阅，设置了消息代理时本进程退出该Agent的消费组
 func (b *CommunicationBus) Unsubscribe(agentName string) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-
+	sub := b.agentSubs[agentName]
+	delete(b.agentSubs, agentName)
 	delete(b.subscribers, agentName)
+	b.mu.Unlock()
+
+	if sub != nil {
+		sub.Unsubscribe()
+	}
 }
 
 // Send 发送消息给指定Agent
@@ -96,6 +174,13 @@ func (b *CommunicationBus) Send(msg *Message) error {
 
 	// 添加到历史记录
 	b.addToHistory(msg)
+	if err := b.appendLog(msg); err != nil {
+		return err
+	}
+
+	if b.broker != nil {
+		return b.publish(b.agentTopic(msg.To), msg)
+	}
 
 	// 发送到事件通道
 	select {
@@ -114,6 +199,13 @@ func (b *CommunicationBus) Broadcast(msg *Message) error {
 
 	// 添加到历史记录
 	b.addToHistory(msg)
+	if err := b.appendLog(msg); err != nil {
+		return err
+	}
+
+	if b.broker != nil {
+		return b.publish(b.broadcastTopic(), msg)
+	}
 
 	// 发送到事件通道
 	select {
@@ -138,6 +230,10 @@ func (b *CommunicationBus) processEvents() {
 
 // handleMessage 处理消息
 func (b *CommunicationBus) handleMessage(msg *Message) {
+	if b.resolveReply(msg) {
+		return
+	}
+
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -161,19 +257,80 @@ func (b *CommunicationBus) handleMessage(msg *Message) {
 	}
 }
 
-// addToHistory 添加到消息历史
+// publish 经消息代理发布消息
+func (b *CommunicationBus) publish(topic string, msg *Message) error {
+	data, err := msg.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return b.broker.Publish(ctx, topic, data)
+}
+
+// deliver 处理消息代理投递的消息：依次调用本进程的处理函数，任一处理函数返回错误时消息重新投递
+func (b *CommunicationBus) deliver(ctx context.Context, data []byte) error {
+	msg, err := UnmarshalMessage(data)
+	if err != nil {
+		// 无法解析的消息重新投递也无法处理
+		log.Printf("bus: dropping malformed message: %v", err)
+		return nil
+	}
+	b.addToHistory(msg)
+	if b.resolveReply(msg) {
+		return nil
+	}
+
+	b.mu.RLock()
+	handlers := b.broadcastSubs
+	if msg.To != "" {
+		handlers = b.subscribers[msg.To]
+	}
+	handlers = append([]MessageHandler(nil), handlers...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// agentTopic 发给Agent的消息在代理上的主题
+func (b *CommunicationBus) agentTopic(agentName string) string {
+	return b.prefix + ".agent." + sanitizeTopicToken(agentName)
+}
+
+// agentGroup 订阅同一Agent的进程组成的消费组
+func (b *CommunicationBus) agentGroup(agentName string) string {
+	return b.prefix + "-agent-" + sanitizeTopicToken(agentName)
+}
+
+// broadcastTopic 广播消息在代理上的主题
+func (b *CommunicationBus) broadcastTopic() string {
+	return b.prefix + ".broadcast"
+}
+
+// addToHistory 添加到消息历史，同一消息（本进程发送后又经代理收到，或重新投递）只记录一次
 func (b *CommunicationBus) addToHistory(msg *Message) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	for i := len(b.messageHistory) - 1; i >= 0; i-- {
+		if b.messageHistory[i].ID == msg.ID {
+			return
+		}
+	}
 	b.messageHistory = append(b.messageHistory, msg)
 	if len(b.messageHistory) > b.maxHistory {
 		b.messageHistory = b.messageHistory[1:]
 	}
 }
 
-// GetHistory 获取消息历史
-func (b *CommunicationBus) GetHistory(limit int) []*Message {
+// GetRecentHistory 获取最近limit条消息历史（limit<=0时全部），只包含内存中保留的消息
+func (b *CommunicationBus) GetRecentHistory(limit int) []*Message {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -210,14 +367,47 @@ func (b *CommunicationBus) GetMessagesForAgent(agentName string, limit int) []*M
 	return messages
 }
 
-// Stop 停止通信总线
+// Stop 停止通信总线并关闭消息日志，设置了消息代理时取消代理上的订阅并关闭代理
 func (b *CommunicationBus) Stop() {
 	close(b.stopped)
+	if b.msgLog != nil {
+		if err := b.msgLog.Close(); err != nil {
+			log.Printf("bus: failed to close message log: %v", err)
+		}
+	}
+	if b.broker == nil {
+		return
+	}
+
+	b.mu.Lock()
+	subs := make([]BrokerSubscription, 0, len(b.agentSubs)+1)
+	for name, sub := range b.agentSubs {
+		subs = append(subs, sub)
+		delete(b.agentSubs, name)
+	}
+	if b.broadcastSub != nil {
+		subs = append(subs, b.broadcastSub)
+		b.broadcastSub = nil
+	}
+	b.mu.Unlock()
+	b.pendingMu.Lock()
+	if b.inboxSub != nil {
+		subs = append(subs, b.inboxSub)
+		b.inboxSub = nil
+	}
+	b.pendingMu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	if err := b.broker.Close(); err != nil {
+		log.Printf("bus: failed to close broker: %v", err)
+	}
 }
 
-// generateMessageID 生成消息ID
+// generateMessageID 生成消息ID，多个进程经消息代理通信时也不会冲突
 func generateMessageID() string {
-	return fmt.Sprintf("msg-%d", time.Now().UnixNano())
+	return idgen.New(idgen.PrefixMessage)
 }
 
 // Event 事件定义（用于事件驱动）
@@ -0,0 +1,207 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// 默认重试策略和死信队列参数，可通过 scheduler.retry 和 scheduler.dead_letter_size 覆盖
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = time.Minute
+	defaultRetryMultiplier = 2
+	defaultRetryJitter     = 0.2
+	defaultDeadLetterSize  = 1000
+)
+
+// ErrDeadLetterNotFound 死信队列中没有该任务
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// RetryPolicy 任务执行失败（执行器返回错误或超时）后的重试策略，取消的任务不重试
+type RetryPolicy struct {
+	MaxAttempts int           `json:"max_attempts"`          // 最多执行次数（含第一次），1表示不重试
+	Backoff     time.Duration `json:"backoff"`               // 第一次重试前的等待时间，之后每次乘以Multiplier
+	MaxBackoff  time.Duration `json:"max_backoff,omitempty"` // 等待时间上限，0表示不限制
+	Multiplier  float64       `json:"multiplier,omitempty"`  // 退避倍数，小于1时按1
+	Jitter      float64       `json:"jitter,omitempty"`      // 0-1，等待时间在±Jitter比例内随机，避免失败的任务同时重试
+}
+
+// delay 第attempt次执行失败后到下一次执行的等待时间，random返回[0,1)内的随机数
+func (p RetryPolicy) delay(attempt int, random func() float64) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+	d := float64(p.Backoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*random()-1)
+	}
+	return time.Duration(d)
+}
+
+// DeadLetter 死信队列中的任务：用完重试次数仍失败，或多次分配不到Agent
+type DeadLetter struct {
+	Task     *Task     `json:"task"`
+	Reason   string    `json:"reason"` // execution_failed, assignment_failed
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// 进入死信队列的原因
+const (
+	DeadLetterExecutionFailed  = "execution_failed"
+	DeadLetterAssignmentFailed = "assignment_failed"
+)
+
+// SetRetryPolicy 设置任务未指定RetryPolicy时使用的默认重试策略，需在Start之前调用
+func (s *TaskScheduler) SetRetryPolicy(policy RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// retryPolicyFor 任务的重试策略
+func (s *TaskScheduler) retryPolicyFor(task *Task) RetryPolicy {
+	if task.RetryPolicy != nil {
+		return *task.RetryPolicy
+	}
+	return s.retryPolicy
+}
+
+// shouldRetry 执行失败的任务是否还能重试：取消（包括调度器停止）的任务不重试
+func (s *TaskScheduler) shouldRetry(task *Task, err error) bool {
+	if task.Status == TaskStatusCancelled || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return task.Attempts < s.retryPolicyFor(task).MaxAttempts
+}
+
+// scheduleRetry 任务按退避时间延迟后重新排队，调用方需持有s.mu
+func (s *TaskScheduler) scheduleRetry(task *Task) {
+	next := s.clock.Now().Add(s.retryPolicyFor(task).delay(task.Attempts, s.rand.Float64))
+	task.Status = TaskStatusPending
+	task.AssignedTo = task.pinnedAgent
+	task.StartedAt = nil
+	task.Progress = nil
+	task.NextAttemptAt = &next
+	task.agent = nil
+	task.cancel = nil
+	s.delayed = append(s.delayed, task)
+}
+
+// promoteDueRetries 等待时间已到的重试任务放回优先队列
+func (s *TaskScheduler) promoteDueRetries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	waiting := s.delayed[:0]
+	for _, task := range s.delayed {
+		if task.NextAttemptAt != nil && task.NextAttemptAt.After(now) {
+			waiting = append(waiting, task)
+			continue
+		}
+		task.NextAttemptAt = nil
+		s.taskQueue.Enqueue(task)
+	}
+	for i := len(waiting); i < len(s.delayed); i++ {
+		s.delayed[i] = nil
+	}
+	s.delayed = waiting
+}
+
+// deadLetter 任务进入死信队列，超出上限时丢弃最早的，调用方需持有s.mu
+func (s *TaskScheduler) deadLetter(task *Task, reason string) {
+	task.Status = TaskStatusFailed
+	task.agent = nil
+	task.cancel = nil
+	s.deadLetters = append(s.deadLetters, &DeadLetter{
+		Task:     task,
+		Reason:   reason,
+		FailedAt: s.clock.Now(),
+	})
+	if over := len(s.deadLetters) - s.deadLetterSize; over > 0 {
+		s.deadLetters = append(s.deadLetters[:0:0], s.deadLetters[over:]...)
+	}
+}
+
+// DeadLetters 死信队列中的任务（副本），按进入时间从早到晚排序
+func (s *TaskScheduler) DeadLetters() []*DeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	letters := make([]*DeadLetter, 0, len(s.deadLetters))
+	for _, dl := range s.deadLetters {
+		letters = append(letters, &DeadLetter{Task: dl.Task.snapshot(), Reason: dl.Reason, FailedAt: dl.FailedAt})
+	}
+	return letters
+}
+
+// GetDeadLetter 获取死信队列中的任务（副本）
+func (s *TaskScheduler) GetDeadLetter(taskID string) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.deadLetterIndex(taskID); i >= 0 {
+		dl := s.deadLetters[i]
+		return &DeadLetter{Task: dl.Task.snapshot(), Reason: dl.Reason, FailedAt: dl.FailedAt}, nil
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+// Requeue 把死信队列中的任务重新提交，执行次数和错误清零；队列已满时任务留在死信队列中并返回 ErrQueueFull
+func (s *TaskScheduler) Requeue(taskID string) (*Task, error) {
+	s.mu.Lock()
+	i := s.deadLetterIndex(taskID)
+	if i < 0 {
+		s.mu.Unlock()
+		return nil, ErrDeadLetterNotFound
+	}
+	if s.taskQueue.Size() >= s.queueSize {
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	task := s.deadLetters[i].Task
+	s.deadLetters = append(s.deadLetters[:i], s.deadLetters[i+1:]...)
+
+	task.Status = TaskStatusPending
+	task.AssignedTo = task.pinnedAgent
+	task.Attempts = 0
+	task.RetryCount = 0
+	task.Error = ""
+	task.Result = nil
+	task.StartedAt = nil
+	task.CompletedAt = nil
+	task.Progress = nil
+	s.taskQueue.Enqueue(task)
+	requeued := task.snapshot()
+	s.mu.Unlock()
+
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+	return requeued, nil
+}
+
+// Discard 从死信队列中删除任务
+func (s *TaskScheduler) Discard(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.deadLetterIndex(taskID)
+	if i < 0 {
+		return ErrDeadLetterNotFound
+	}
+	s.deadLetters = append(s.deadLetters[:i], s.deadLetters[i+1:]...)
+	return nil
+}
+
+// deadLetterIndex 任务在死信队列中的位置，不存在时返回-1，调用方需持有s.mu
+func (s *TaskScheduler) deadLetterIndex(taskID string) int {
+	for i, dl := range s.deadLetters {
+		if dl.Task.ID == taskID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,330 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+)
+
+// ErrNotLeader 启用选主时本实例不是主实例，任务和工作流执行需要提交到主实例
+var ErrNotLeader = errors.New("not the leader instance")
+
+// 选主的默认参数
+const (
+	defaultElectionKey = "ai-agent:leader"
+	defaultElectionTTL = 15 * time.Second
+)
+
+// LeaderLock 选主使用的带租约的锁，多个实例竞争同一个锁，持有者即为主实例
+type LeaderLock interface {
+	// TryAcquire 锁空闲或租约已过期时由id获得锁，id已持有锁时续约；返回id是否持有锁
+	TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// Release id持有锁时释放，其他实例可以立即接替
+	Release(ctx context.Context, id string) error
+	// Holder 当前持有锁的实例，没有时返回空字符串
+	Holder(ctx context.Context) (string, error)
+}
+
+// MemoryLeaderLock 进程内的选主锁，同一进程中的多个选举者共享，用于单实例部署和测试
+type MemoryLeaderLock struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	clock   clock.Clock
+}
+
+// NewMemoryLeaderLock 创建进程内的选主锁，c为nil时使用系统时钟
+func NewMemoryLeaderLock(c clock.Clock) *MemoryLeaderLock {
+	return &MemoryLeaderLock{clock: clock.OrSystem(c)}
+}
+
+// TryAcquire 锁空闲、已过期或已由id持有时获得（续约）锁
+func (l *MemoryLeaderLock) TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if l.holder != "" && l.holder != id && now.Before(l.expires) {
+		return false, nil
+	}
+	l.holder = id
+	l.expires = now.Add(ttl)
+	return true, nil
+}
+
+// Release id持有锁时释放
+func (l *MemoryLeaderLock) Release(ctx context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == id {
+		l.holder = ""
+	}
+	return nil
+}
+
+// Holder 租约有效的持有者
+func (l *MemoryLeaderLock) Holder(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == "" || !l.clock.Now().Before(l.expires) {
+		return "", nil
+	}
+	return l.holder, nil
+}
+
+// LeaderStatus 本实例的选主状态
+type LeaderStatus struct {
+	ID          string     `json:"id"`                     // 本实例的标识
+	Leader      bool       `json:"leader"`                 // 本实例是否为主实例
+	LeaderID    string     `json:"leader_id"`              // 当前主实例的标识，查询锁失败或没有主实例时为空
+	LeaderSince *time.Time `json:"leader_since,omitempty"` // 本实例成为主实例的时间
+	TTL         string     `json:"ttl"`
+}
+
+// LeaderElector 编排器选主：定期竞选或续约选主锁，持有锁的实例为主实例
+// 主实例距最后一次成功续约超过 ttl-续约间隔 时即放弃主实例身份，早于锁的租约过期，
+// 避免在租约过期、其他实例接替后仍以为自己是主实例而同时调度
+type LeaderElector struct {
+	lock          LeaderLock
+	id            string
+	ttl           time.Duration
+	renewInterval time.Duration
+	clock         clock.Clock
+
+	mu        sync.RWMutex
+	leader    bool
+	since     time.Time // 成为主实例的时间
+	renewed   time.Time // 最后一次成功续约的请求发出的时间，租约不早于此时开始
+	listeners []func(leader bool)
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewLeaderElector 创建选举者，id为本实例的标识，ttl<=0时使用默认的15s；续约间隔为ttl的三分之一
+func NewLeaderElector(lock LeaderLock, id string, ttl time.Duration) *LeaderElector {
+	if ttl <= 0 {
+		ttl = defaultElectionTTL
+	}
+	return &LeaderElector{
+		lock:          lock,
+		id:            id,
+		ttl:           ttl,
+		renewInterval: ttl / 3,
+		clock:         clock.System,
+	}
+}
+
+// NewLeaderElectorFromConfig 根据 scheduler.election 配置创建选举者，未启用时返回nil（本实例总是主实例）；
+// 未设置或不合法的参数使用默认值，续约间隔不超过ttl的一半
+func NewLeaderElectorFromConfig(cfg config.LeaderElectionConfig) (*LeaderElector, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	key := cfg.Key
+	if key == "" {
+		key = defaultElectionKey
+	}
+	id := cfg.ID
+	if id == "" {
+		host, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	var lock LeaderLock
+	switch strings.ToLower(cfg.Backend) {
+	case "memory":
+		lock = NewMemoryLeaderLock(nil)
+	case "", "redis":
+		redisLock, err := NewRedisLeaderLock(cfg.Redis, key)
+		if err != nil {
+			return nil, err
+		}
+		lock = redisLock
+	default:
+		return nil, fmt.Errorf("unsupported leader election backend: %s", cfg.Backend)
+	}
+
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil || ttl <= 0 {
+		ttl = defaultElectionTTL
+	}
+	e := NewLeaderElector(lock, id, ttl)
+	if d, err := time.ParseDuration(cfg.RenewInterval); err == nil && d > 0 {
+		e.renewInterval = min(d, ttl/2)
+	}
+	return e, nil
+}
+
+// SetClock 设置时钟，需在Start之前调用；测试中使用clock.Fake判断续约是否超时
+func (e *LeaderElector) SetClock(c clock.Clock) {
+	e.clock = clock.OrSystem(c)
+}
+
+// ID 本实例的标识
+func (e *LeaderElector) ID() string {
+	return e.id
+}
+
+// IsLeader 本实例是否为主实例，e为nil（未启用选主）时总是true
+// 按最后一次成功续约的时间判断，续约失败时不等下一次竞选，在租约过期前就不再是主实例
+func (e *LeaderElector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leadingLocked(e.clock.Now())
+}
+
+// leadingLocked 是否为主实例且租约仍在安全期内（距最后一次成功续约不超过 ttl-续约间隔），调用方需持有e.mu
+func (e *LeaderElector) leadingLocked(now time.Time) bool {
+	return e.leader && now.Sub(e.renewed) < e.ttl-e.renewInterval
+}
+
+// OnChange 注册主实例身份变化的回调，在竞选的协程中调用，不能阻塞
+func (e *LeaderElector) OnChange(fn func(leader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// Campaign 竞选一次：不是主实例时尝试获得锁，是主实例时续约；返回本实例是否为主实例
+// 访问锁失败时在安全期内保持原来的身份，超过 ttl-续约间隔 没有成功续约时放弃主实例身份
+func (e *LeaderElector) Campaign(ctx context.Context) bool {
+	// 租约从锁服务处理请求时开始，按请求发出的时间计算更保守
+	start := e.clock.Now()
+	acquired, err := e.lock.TryAcquire(ctx, e.id, e.ttl)
+	now := e.clock.Now()
+
+	e.mu.Lock()
+	previous := e.leader
+	switch {
+	case err != nil:
+		log.Printf("leader election: %s failed to renew lock: %v", e.id, err)
+		e.leader = e.leadingLocked(now)
+	case acquired:
+		if !e.leader {
+			e.since = start
+		}
+		e.leader = true
+		e.renewed = start
+	default:
+		e.leader = false
+	}
+	leader := e.leader
+	listeners := append([]func(bool){}, e.listeners...)
+	e.mu.Unlock()
+
+	if leader != previous {
+		if leader {
+			log.Printf("leader election: %s became leader", e.id)
+		} else {
+			log.Printf("leader election: %s lost leadership", e.id)
+		}
+		for _, fn := range listeners {
+			fn(leader)
+		}
+	}
+	return leader
+}
+
+// Start 立即竞选一次，之后在后台按续约间隔竞选或续约，Close时停止；已启动时不做任何事
+func (e *LeaderElector) Start() {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.mu.Unlock()
+		return
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	stop, done := e.stop, e.done
+	e.mu.Unlock()
+
+	e.Campaign(context.Background())
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(e.renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+				e.Campaign(ctx)
+				cancel()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止竞选，本实例是主实例时释放锁，使热备实例无需等待租约过期即可接替
+func (e *LeaderElector) Close() error {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop = nil
+	e.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	listeners := append([]func(bool){}, e.listeners...)
+	e.mu.Unlock()
+	if !wasLeader {
+		return nil
+	}
+	for _, fn := range listeners {
+		fn(false)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return e.lock.Release(ctx, e.id)
+}
+
+// Status 本实例的选主状态，当前主实例从锁中查询
+func (e *LeaderElector) Status(ctx context.Context) LeaderStatus {
+	e.mu.RLock()
+	leader := e.leadingLocked(e.clock.Now())
+	status := LeaderStatus{ID: e.id, Leader: leader, TTL: e.ttl.String()}
+	if leader {
+		since := e.since
+		status.LeaderSince = &since
+	}
+	e.mu.RUnlock()
+
+	holder, err := e.lock.Holder(ctx)
+	if err != nil {
+		log.Printf("leader election: failed to query lock holder: %v", err)
+	}
+	status.LeaderID = holder
+	return status
+}
+
+// SetLeaderElector 设置选举者，只有主实例接受提交和分配任务，成为主实例时立即调度一次队列中的任务；
+// 失去主实例身份时不再分配，执行中的任务继续执行
+func (s *TaskScheduler) SetLeaderElector(elector *LeaderElector) {
+	s.elector = elector
+	if elector == nil {
+		return
+	}
+	elector.OnChange(func(leader bool) {
+		if !leader {
+			return
+		}
+		select {
+		case s.wakeup <- struct{}{}:
+		default:
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisAcquireScript 键不存在时设置为id，已由id持有时续约；返回1表示持有锁
+var redisAcquireScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// redisReleaseScript 键由id持有时删除
+var redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLeaderLock Redis选主锁，持有者的id保存在带过期时间的键中，获取和续约在Lua脚本中原子完成
+type RedisLeaderLock struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisLeaderLock 创建Redis选主锁
+func NewRedisLeaderLock(cfg config.RedisConfig, key string) (*RedisLeaderLock, error) {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return NewRedisLeaderLockWithClient(client, key), nil
+}
+
+// NewRedisLeaderLockWithClient 使用已有的Redis客户端创建选主锁
+func NewRedisLeaderLockWithClient(client *redis.Client, key string) *RedisLeaderLock {
+	return &RedisLeaderLock{client: client, key: key}
+}
+
+// TryAcquire 锁空闲（键已过期）时由id获得锁，id已持有锁时续约
+func (l *RedisLeaderLock) TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	held, err := redisAcquireScript.Run(ctx, l.client, []string{l.key}, id, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire leader lock: %w", err)
+	}
+	return held == 1, nil
+}
+
+// Release id持有锁时释放
+func (l *RedisLeaderLock) Release(ctx context.Context, id string) error {
+	if err := redisReleaseScript.Run(ctx, l.client, []string{l.key}, id).Err(); err != nil {
+		return fmt.Errorf("failed to release leader lock: %w", err)
+	}
+	return nil
+}
+
+// Holder 当前持有锁的实例
+func (l *RedisLeaderLock) Holder(ctx context.Context) (string, error) {
+	holder, err := l.client.Get(ctx, l.key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get leader lock holder: %w", err)
+	}
+	return holder, nil
+}
+
+// Close 关闭Redis连接
+func (l *RedisLeaderLock) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,286 @@
+package orchestrator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// defaultMessageLogPath 未配置路径时消息日志的文件
+const defaultMessageLogPath = "./data/bus-messages.jsonl"
+
+// BroadcastTopic 消息日志中广播消息的主题，发给Agent的消息以接收者名称为主题
+const BroadcastTopic = "broadcast"
+
+// MessageLogEntry 消息日志中的一条记录
+type MessageLogEntry struct {
+	Offset  int64    `json:"offset"`
+	Topic   string   `json:"topic"`
+	Message *Message `json:"message"`
+}
+
+// MessageLog 只追加的消息日志，按写入顺序分配从0开始递增的偏移量
+type MessageLog interface {
+	// Append 追加一条消息，返回其偏移量
+	Append(topic string, msg *Message) (int64, error)
+
+	// Read 按偏移量顺序读取topic上偏移量不小于from的记录，topic为空时读取全部主题，limit<=0时不限制条数
+	Read(topic string, from int64, limit int) ([]*MessageLogEntry, error)
+
+	// Close 关闭日志
+	Close() error
+}
+
+// NewMessageLogFromConfig 根据 scheduler.bus.log 配置创建消息日志，未启用时返回nil
+func NewMessageLogFromConfig(cfg config.BusLogConfig) (MessageLog, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	path := cfg.Path
+	if path == "" {
+		path = defaultMessageLogPath
+	}
+	return NewFileMessageLog(path)
+}
+
+// MessageTopic 消息在消息日志中的主题
+func MessageTopic(msg *Message) string {
+	if msg.To == "" {
+		return BroadcastTopic
+	}
+	return msg.To
+}
+
+// SetMessageLog 设置消息日志，之后本总线发送的消息在投递前先追加到日志；Stop时关闭日志
+func (b *CommunicationBus) SetMessageLog(msgLog MessageLog) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgLog = msgLog
+}
+
+// appendLog 设置了消息日志时追加消息，写入失败时不再发送
+func (b *CommunicationBus) appendLog(msg *Message) error {
+	b.mu.RLock()
+	msgLog := b.msgLog
+	b.mu.RUnlock()
+	if msgLog == nil {
+		return nil
+	}
+	if _, err := msgLog.Append(MessageTopic(msg), msg); err != nil {
+		return fmt.Errorf("failed to persist message: %w", err)
+	}
+	return nil
+}
+
+// Replay 按顺序把消息日志中topic上偏移量不小于from的消息交给handler（topic为空时重放全部主题），
+// 返回下次重放的起始偏移量；handler返回错误时停止，返回值为出错消息的偏移量，修复后可从该处继续
+func (b *CommunicationBus) Replay(topic string, from int64, handler MessageHandler) (int64, error) {
+	b.mu.RLock()
+	msgLog := b.msgLog
+	b.mu.RUnlock()
+	if msgLog == nil {
+		return from, fmt.Errorf("message log is not enabled")
+	}
+
+	entries, err := msgLog.Read(topic, from, 0)
+	if err != nil {
+		return from, err
+	}
+	next := from
+	for _, entry := range entries {
+		if err := handler(entry.Message); err != nil {
+			return entry.Offset, fmt.Errorf("replay stopped at offset %d: %w", entry.Offset, err)
+		}
+		next = entry.Offset + 1
+	}
+	return next, nil
+}
+
+// GetHistory 获取topic上时间不早于since的消息（topic为空时全部主题，since为零值时不限时间），用于调试多Agent交互；
+// 设置了消息日志时从日志读取，否则只包含内存中保留的消息
+func (b *CommunicationBus) GetHistory(topic string, since time.Time) ([]*Message, error) {
+	b.mu.RLock()
+	msgLog := b.msgLog
+	var history []*Message
+	if msgLog == nil {
+		history = append(history, b.messageHistory...)
+	}
+	b.mu.RUnlock()
+
+	if msgLog != nil {
+		entries, err := msgLog.Read(topic, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			history = append(history, entry.Message)
+		}
+	}
+
+	messages := make([]*Message, 0, len(history))
+	for _, msg := range history {
+		if topic != "" && MessageTopic(msg) != topic || msg.Timestamp.Before(since) {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// MemoryMessageLog 内存消息日志，进程重启后丢失，用于测试
+type MemoryMessageLog struct {
+	mu      sync.RWMutex
+	entries []*MessageLogEntry
+}
+
+// NewMemoryMessageLog 创建内存消息日志
+func NewMemoryMessageLog() *MemoryMessageLog {
+	return &MemoryMessageLog{}
+}
+
+// Append 追加一条消息
+func (l *MemoryMessageLog) Append(topic string, msg *Message) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &MessageLogEntry{Offset: int64(len(l.entries)), Topic: topic, Message: msg}
+	l.entries = append(l.entries, entry)
+	return entry.Offset, nil
+}
+
+// Read 读取记录
+func (l *MemoryMessageLog) Read(topic string, from int64, limit int) ([]*MessageLogEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if from < 0 {
+		from = 0
+	}
+	entries := make([]*MessageLogEntry, 0)
+	for _, entry := range l.entries[min(from, int64(len(l.entries))):] {
+		if topic != "" && entry.Topic != topic {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Close 内存日志无需关闭
+func (l *MemoryMessageLog) Close() error {
+	return nil
+}
+
+// FileMessageLog JSONL文件消息日志，每行一条记录，偏移量即行号（从0开始）
+type FileMessageLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	next int64 // 下一条记录的偏移量
+}
+
+// NewFileMessageLog 打开（不存在时创建）消息日志文件，已有的记录保留，新记录接着追加
+func NewFileMessageLog(path string) (*FileMessageLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create message log directory: %w", err)
+	}
+	l := &FileMessageLog{path: path}
+	err := l.scan(func(*MessageLogEntry) bool {
+		l.next++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message log: %w", err)
+	}
+	return l, nil
+}
+
+// Append 追加一条记录
+func (l *FileMessageLog) Append(topic string, msg *Message) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, fmt.Errorf("message log is closed")
+	}
+	entry := &MessageLogEntry{Offset: l.next, Topic: topic, Message: msg}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return 0, fmt.Errorf("failed to write message log: %w", err)
+	}
+	l.next++
+	return entry.Offset, nil
+}
+
+// Read 从头扫描文件读取记录
+func (l *FileMessageLog) Read(topic string, from int64, limit int) ([]*MessageLogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]*MessageLogEntry, 0)
+	err := l.scan(func(entry *MessageLogEntry) bool {
+		if entry.Offset < from || topic != "" && entry.Topic != topic {
+			return true
+		}
+		entries = append(entries, entry)
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries, err
+}
+
+// Close 关闭文件
+func (l *FileMessageLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// scan 按顺序解析每条记录，fn返回false时停止；文件不存在时没有记录
+func (l *FileMessageLog) scan(fn func(entry *MessageLogEntry) bool) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open message log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry MessageLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("corrupted message log entry: %w", err)
+		}
+		if !fn(&entry) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read message log: %w", err)
+	}
+	return nil
+}
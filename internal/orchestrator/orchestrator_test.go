@@ -1,8 +1,25 @@
 package orchestrator
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 // TestAgentRegistry 测试Agent注册表
@@ -126,6 +143,1220 @@ func TestTaskScheduler(t *testing.T) {
 	}
 }
 
+// TestTaskSchedulerWorkerPool 测试工作协程数限制、任务超时和队列上限
+func TestTaskSchedulerWorkerPool(t *testing.T) {
+	registry := NewAgentRegistry()
+	for i := 0; i < 6; i++ {
+		registry.Register(&AgentInfo{Name: fmt.Sprintf("worker-%d", i), Metadata: make(map[string]string)})
+	}
+	scheduler := NewTaskSchedulerFromConfig(registry, config.SchedulerConfig{
+		PollInterval: "10ms",
+		Workers:      2,
+		TaskTimeout:  "200ms",
+	})
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	timedOut := make(chan error, 1)
+	scheduler.SetExecutor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		defer wg.Done()
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		if task.ID == "slow" {
+			<-ctx.Done()
+			timedOut <- ctx.Err()
+			return nil, ctx.Err()
+		}
+		time.Sleep(20 * time.Millisecond)
+		return agent.Name, nil
+	})
+	scheduler.Start()
+
+	wg.Add(6)
+	for i := 0; i < 5; i++ {
+		if err := scheduler.Submit(&Task{ID: fmt.Sprintf("task-%d", i)}); err != nil {
+			t.Fatalf("Failed to submit task: %v", err)
+		}
+	}
+	scheduler.Submit(&Task{ID: "slow"})
+
+	select {
+	case err := <-timedOut:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for task timeout")
+	}
+	wg.Wait()
+	scheduler.Stop()
+
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 concurrent tasks, got %d", maxRunning)
+	}
+	if n := len(scheduler.GetRunningTasks()); n != 0 || scheduler.GetQueueSize() != 0 {
+		t.Errorf("Expected all tasks finished, running=%d queued=%d", n, scheduler.GetQueueSize())
+	}
+	if busy := registry.CountByStatus("busy"); busy != 0 {
+		t.Errorf("Expected all agents released, %d still busy", busy)
+	}
+
+	full := NewTaskSchedulerFromConfig(registry, config.SchedulerConfig{QueueSize: 1})
+	if err := full.Submit(&Task{ID: "a"}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	if err := full.Submit(&Task{ID: "b"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+}
+
+// fakeAgentStats 固定的Agent运行统计
+type fakeAgentStats map[string]AgentStats
+
+func (f fakeAgentStats) AgentStats(agentName string) (AgentStats, bool) {
+	stats, ok := f[agentName]
+	return stats, ok
+}
+
+// TestFindBestAgentScoring 测试能力匹配数优先，匹配数相同时按评分策略选择
+func TestFindBestAgentScoring(t *testing.T) {
+	registry := NewAgentRegistry()
+	for _, agent := range []*AgentInfo{
+		{Name: "a-busy", Capabilities: []string{"search", "analyze"}},
+		{Name: "b-flaky", Capabilities: []string{"search", "analyze"}},
+		{Name: "c-slow", Capabilities: []string{"search", "analyze"}},
+		{Name: "d-partial", Capabilities: []string{"search"}},
+	} {
+		if err := registry.Register(agent); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	registry.SetStatsProvider(fakeAgentStats{
+		"a-busy":    {Running: 3, Executions: 20, Failures: 0, AvgLatency: time.Second},
+		"b-flaky":   {Running: 0, Executions: 20, Failures: 10, AvgLatency: time.Second},
+		"c-slow":    {Running: 1, Executions: 20, Failures: 0, AvgLatency: 10 * time.Second},
+		"d-partial": {Running: 0, Executions: 100},
+	})
+
+	best := func() string {
+		agent, err := registry.FindBestAgent([]string{"search", "analyze"})
+		if err != nil {
+			t.Fatalf("FindBestAgent failed: %v", err)
+		}
+		return agent.Name
+	}
+
+	// 默认只看能力匹配，匹配数相同时选名称在前的
+	if name := best(); name != "a-busy" {
+		t.Errorf("capability: expected a-busy, got %s", name)
+	}
+	for strategy, want := range map[string]string{
+		ScoringLeastLoaded:    "b-flaky",
+		ScoringBestPerforming: "a-busy",
+		ScoringWeighted:       "c-slow",
+	} {
+		scoring, err := NewScoringStrategy(config.AgentScoringConfig{Strategy: strategy})
+		if err != nil {
+			t.Fatalf("NewScoringStrategy(%s) failed: %v", strategy, err)
+		}
+		registry.SetScoringStrategy(scoring)
+		if name := best(); name != want {
+			t.Errorf("%s: expected %s, got %s (ranking %v)", strategy, want, name, registry.RankAgents([]string{"search", "analyze"}))
+		}
+	}
+
+	// 自定义策略
+	registry.SetScoringStrategy(ScoringFunc(func(c *AgentCandidate) float64 {
+		return -c.Stats.AvgLatency.Seconds() - float64(c.Stats.Failures)
+	}))
+	if name := best(); name != "a-busy" {
+		t.Errorf("custom: expected a-busy, got %s", name)
+	}
+	if ranked := registry.RankAgents([]string{"search", "analyze"}); len(ranked) != 4 || ranked[3].Agent.Name != "d-partial" {
+		t.Errorf("Agents matching fewer capabilities should rank last: %v", ranked)
+	}
+
+	if _, err := registry.FindBestAgent([]string{"translate"}); err == nil {
+		t.Error("Expected error when no agent has the capability")
+	}
+	if _, err := NewScoringStrategy(config.AgentScoringConfig{Strategy: "random"}); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+// TestAgentLease 测试基于心跳的租约：超时后依次变为unhealthy、expired并被注销，恢复心跳后还原状态，状态变化发布到总线
+func TestAgentLease(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	registry := NewAgentRegistry()
+	registry.SetClock(fake)
+	registry.SetLease(NewAgentLeaseFromConfig(config.AgentLeaseConfig{Enabled: true, TTL: "10s"}))
+
+	bus := NewCommunicationBus()
+	defer bus.Stop()
+	events := make(chan *Event, 32)
+	bus.SubscribeBroadcast(func(msg *Message) error {
+		events <- msg.Content.(*Event)
+		return nil
+	})
+	registry.SetEventBus(bus)
+	nextEvent := func() (string, string, string) {
+		select {
+		case event := <-events:
+			return event.Name, event.Data["agent"].(string), event.Data["status"].(string) + "/" + event.Data["reason"].(string)
+		case <-time.After(time.Second):
+			t.Fatal("Expected a registry event")
+			return "", "", ""
+		}
+	}
+
+	registry.Register(&AgentInfo{Name: "local"})
+	registry.Register(&AgentInfo{Name: "remote", Endpoint: "10.0.0.2:9000"})
+	registry.Register(&AgentInfo{Name: "worker", Endpoint: "10.0.0.3:9000"})
+	for i := 0; i < 3; i++ {
+		if name, _, _ := nextEvent(); name != EventAgentRegistered {
+			t.Errorf("Expected registered event, got %s", name)
+		}
+	}
+
+	// 超过ttl：远程Agent变为unhealthy，不再被调度；进程内Agent不受租约限制
+	fake.Advance(15 * time.Second)
+	registry.UpdateHeartbeat("worker")
+	if n := registry.ExpireLeases(); n != 1 {
+		t.Errorf("Expected 1 lapsed lease, got %d", n)
+	}
+	if name, agent, change := nextEvent(); name != EventAgentStatusChanged || agent != "remote" || change != "unhealthy/lease_lapsed" {
+		t.Errorf("Unexpected event: %s %s %s", name, agent, change)
+	}
+	if registry.CheckHealth("remote") || !registry.CheckHealth("worker") {
+		t.Error("Expected remote unhealthy and worker healthy")
+	}
+	if agent, _ := registry.Get("local"); agent.Status != "active" {
+		t.Errorf("Local agent should stay active, got %s", agent.Status)
+	}
+	if _, err := registry.claim("", "remote", nil); err == nil {
+		t.Error("Unhealthy agent should not be claimed")
+	}
+
+	// 恢复心跳后回到原来的状态
+	registry.UpdateHeartbeat("remote")
+	if agent, _ := registry.Get("remote"); agent.Status != "active" || agent.LapsedStatus != "" {
+		t.Errorf("Expected remote active after heartbeat, got %s (%s)", agent.Status, agent.LapsedStatus)
+	}
+	if _, _, change := nextEvent(); change != "active/heartbeat" {
+		t.Errorf("Unexpected event: %s", change)
+	}
+
+	// 超过expire_after（默认3倍ttl）变为expired，inactive的Agent恢复后仍为inactive
+	registry.UpdateStatus("remote", "inactive")
+	nextEvent()
+	fake.Advance(35 * time.Second)
+	registry.UpdateHeartbeat("worker")
+	registry.ExpireLeases()
+	if agent, _ := registry.Get("remote"); agent.Status != AgentStatusExpired || agent.LapsedStatus != "inactive" {
+		t.Errorf("Expected remote expired, got %s (%s)", agent.Status, agent.LapsedStatus)
+	}
+	if _, _, change := nextEvent(); change != "expired/lease_lapsed" {
+		t.Errorf("Unexpected event: %s", change)
+	}
+	registry.UpdateHeartbeat("remote")
+	if agent, _ := registry.Get("remote"); agent.Status != "inactive" {
+		t.Errorf("Expected remote inactive after heartbeat, got %s", agent.Status)
+	}
+	nextEvent()
+
+	// 超过evict_after（默认10倍ttl）从注册表注销
+	fake.Advance(101 * time.Second)
+	registry.UpdateHeartbeat("worker")
+	if n := registry.ExpireLeases(); n != 1 {
+		t.Errorf("Expected 1 evicted agent, got %d", n)
+	}
+	if _, err := registry.Get("remote"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected remote evicted, got %v", err)
+	}
+	if name, agent, change := nextEvent(); name != EventAgentDeregistered || agent != "remote" || change != "/lease_evicted" {
+		t.Errorf("Unexpected event: %s %s %s", name, agent, change)
+	}
+	if registry.Count() != 2 {
+		t.Errorf("Expected local and worker to remain, got %d agents", registry.Count())
+	}
+
+	if NewAgentLeaseFromConfig(config.AgentLeaseConfig{}) != nil {
+		t.Error("Disabled lease should be nil")
+	}
+	lease := NewAgentLeaseFromConfig(config.AgentLeaseConfig{Enabled: true, TTL: "1m", ExpireAfter: "30s", EvictAfter: "bad"})
+	if lease.ExpireAfter != time.Minute || lease.EvictAfter != 10*time.Minute || lease.CheckInterval != 30*time.Second {
+		t.Errorf("Unexpected lease defaults: %+v", lease)
+	}
+}
+
+// TestTenantIsolation 测试多租户：租户的Agent只对本租户可见，任务只分配给可见的Agent，Agent数和任务数受配额限制
+func TestTenantIsolation(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.SetQuotas(tenant.NewQuotasFromConfig(config.TenancyConfig{
+		Enabled: true,
+		Quotas: config.TenantQuotasConfig{
+			Default: config.TenantQuotaConfig{MaxAgents: 1, MaxTasks: 2},
+			Tenants: map[string]config.TenantQuotaConfig{"beta": {MaxAgents: -1}},
+		},
+	}))
+
+	registry.Register(&AgentInfo{Name: "shared", Capabilities: []string{"search"}})
+	if err := registry.Register(&AgentInfo{Name: "acme::worker", Capabilities: []string{"search", "report"}, Tenant: "acme"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	err := registry.Register(&AgentInfo{Name: "acme::second", Tenant: "acme"})
+	var quotaErr *tenant.QuotaError
+	if !errors.Is(err, tenant.ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.Resource != tenant.ResourceAgents {
+		t.Errorf("Expected agents quota error, got %v", err)
+	}
+	// 重新注册同名Agent不占用新的配额
+	if _, err := registry.Upsert(&AgentInfo{Name: "acme::worker", Capabilities: []string{"search", "report"}, Tenant: "acme"}); err != nil {
+		t.Errorf("Re-registering an agent should not exceed the quota: %v", err)
+	}
+	// beta的Agent数不限制
+	for i := 0; i < 3; i++ {
+		if err := registry.Register(&AgentInfo{Name: fmt.Sprintf("beta::worker-%d", i), Capabilities: []string{"report"}, Tenant: "beta"}); err != nil {
+			t.Errorf("Register for unlimited tenant failed: %v", err)
+		}
+	}
+
+	names := func(agents []*AgentInfo) []string {
+		var result []string
+		for _, agent := range agents {
+			result = append(result, agent.Name)
+		}
+		return result
+	}
+	if got := names(registry.ListForTenant("acme")); !reflect.DeepEqual(got, []string{"acme::worker", "shared"}) {
+		t.Errorf("Unexpected agents visible to acme: %v", got)
+	}
+	if got := registry.CountForTenant("beta"); got != 3 {
+		t.Errorf("Expected 3 agents for beta, got %d", got)
+	}
+
+	// 按客户端使用的名称查找：先查本租户，再查共享Agent，其他租户的Agent不可见
+	if agent, err := registry.GetForTenant("acme", "worker"); err != nil || agent.Name != "acme::worker" {
+		t.Errorf("Expected acme::worker, got %v, %v", agent, err)
+	}
+	if agent, err := registry.GetForTenant("acme", "shared"); err != nil || agent.Name != "shared" {
+		t.Errorf("Expected shared agent, got %v, %v", agent, err)
+	}
+	if _, err := registry.GetForTenant("acme", "beta::worker-0"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected other tenant's agent to be hidden, got %v", err)
+	}
+
+	if agent, err := registry.FindBestAgentFor("acme", []string{"search", "report"}); err != nil || agent.Name != "acme::worker" {
+		t.Errorf("Expected acme::worker, got %v, %v", agent, err)
+	}
+	if agent, err := registry.FindBestAgent([]string{"search", "report"}); err != nil || agent.Name != "shared" {
+		t.Errorf("Expected only shared agents without a tenant, got %v, %v", agent, err)
+	}
+
+	// 指定其他租户的Agent时按不存在处理，自动选择时只选可见的Agent
+	if _, err := registry.claim("acme", "beta::worker-0", nil); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected claim of other tenant's agent to fail, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		agent, err := registry.claim("acme", "", nil)
+		if err != nil {
+			t.Fatalf("claim failed: %v", err)
+		}
+		if agent.Tenant != "" && agent.Tenant != "acme" {
+			t.Errorf("Claimed agent of another tenant: %s", agent.Name)
+		}
+	}
+	if _, err := registry.claim("acme", "", nil); err == nil {
+		t.Error("Expected no idle agent for acme")
+	}
+
+	scheduler := NewTaskScheduler(NewAgentRegistry())
+	scheduler.SetQuotas(tenant.NewQuotasFromConfig(config.TenancyConfig{
+		Enabled: true,
+		Quotas:  config.TenantQuotasConfig{Default: config.TenantQuotaConfig{MaxTasks: 2}},
+	}))
+	for i := 0; i < 2; i++ {
+		if err := scheduler.Submit(&Task{ID: fmt.Sprintf("acme-%d", i), Tenant: "acme"}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	if err := scheduler.Submit(&Task{ID: "acme-2", Tenant: "acme"}); !errors.Is(err, tenant.ErrQuotaExceeded) {
+		t.Errorf("Expected tasks quota error, got %v", err)
+	}
+	if err := scheduler.Submit(&Task{ID: "beta-0", Tenant: "beta"}); err != nil {
+		t.Errorf("Other tenants should not be affected: %v", err)
+	}
+	if err := scheduler.Submit(&Task{ID: "shared-0"}); err != nil {
+		t.Errorf("Tasks without a tenant should not be limited: %v", err)
+	}
+}
+
+// flakyLeaderLock 可以模拟访问失败的选主锁
+type flakyLeaderLock struct {
+	*MemoryLeaderLock
+	fail bool
+}
+
+func (l *flakyLeaderLock) TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	if l.fail {
+		return false, errors.New("connection refused")
+	}
+	return l.MemoryLeaderLock.TryAcquire(ctx, id, ttl)
+}
+
+// TestLeaderElection 测试编排器选主：只有主实例接受和分配任务，主实例租约过期或主动退出后由热备实例接替
+func TestLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lock := &flakyLeaderLock{MemoryLeaderLock: NewMemoryLeaderLock(fake)}
+	var changes []string
+	newElector := func(id string) *LeaderElector {
+		e := NewLeaderElector(lock, id, 10*time.Second)
+		e.SetClock(fake)
+		e.OnChange(func(leader bool) { changes = append(changes, fmt.Sprintf("%s:%v", id, leader)) })
+		return e
+	}
+	a, b := newElector("a"), newElector("b")
+	if !a.Campaign(ctx) || b.Campaign(ctx) {
+		t.Fatalf("Expected a to lead, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	if status := b.Status(ctx); status.Leader || status.LeaderID != "a" || status.LeaderSince != nil {
+		t.Errorf("Unexpected follower status: %+v", status)
+	}
+	var disabled *LeaderElector
+	if !disabled.IsLeader() {
+		t.Error("Instances without leader election should always lead")
+	}
+
+	// 热备实例不接受任务，主实例的队列在失去主实例身份期间不分配
+	registry := NewAgentRegistry()
+	registry.Register(&AgentInfo{Name: "worker", Capabilities: []string{"search"}})
+	follower := NewTaskScheduler(registry)
+	follower.SetLeaderElector(b)
+	if err := follower.Submit(&Task{ID: "task-0"}); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader from follower, got %v", err)
+	}
+	leader := NewTaskScheduler(registry)
+	leader.SetLeaderElector(a)
+	if err := leader.Submit(&Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	// 主实例按时续约时保持身份；停止续约超过ttl后由热备实例接替
+	fake.Advance(6 * time.Second)
+	a.Campaign(ctx)
+	fake.Advance(6 * time.Second)
+	if b.Campaign(ctx) {
+		t.Error("Follower should not take over while the lease is renewed")
+	}
+	fake.Advance(5 * time.Second)
+	if !b.Campaign(ctx) || a.Campaign(ctx) {
+		t.Fatalf("Expected b to take over after the lease lapsed, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	leader.scheduleTasks()
+	if size := leader.GetQueueSize(); size != 1 {
+		t.Errorf("Task should stay queued on a former leader, queue size %d", size)
+	}
+
+	// 主实例访问锁失败时在安全期（ttl-续约间隔）内保持身份，之后在租约过期前放弃，
+	// IsLeader不等下一次竞选即返回false，不会与接替的实例同时调度
+	lock.fail = true
+	fake.Advance(5 * time.Second)
+	if !b.Campaign(ctx) {
+		t.Error("Leader should survive lock errors within the safety margin")
+	}
+	fake.Advance(2 * time.Second)
+	if holder, _ := lock.Holder(ctx); holder != "b" {
+		t.Fatalf("Lease should still be held by b before the ttl, got %q", holder)
+	}
+	if b.IsLeader() || b.Status(ctx).Leader {
+		t.Error("Leader should stop reporting leadership before its lease expires")
+	}
+	if b.Campaign(ctx) {
+		t.Error("Leader should step down before its lease expires")
+	}
+	lock.fail = false
+
+	// 主实例退出时释放锁，热备实例立即接替，恢复身份后分配积压的任务
+	if !b.Campaign(ctx) {
+		t.Fatal("Expected b to reacquire the lock")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !a.Campaign(ctx) {
+		t.Fatal("Expected a to take over after b released the lock")
+	}
+	leader.scheduleTasks()
+	if task, err := leader.GetTask("task-1"); err != nil || task.Status != TaskStatusAssigned {
+		t.Errorf("Expected queued task to be assigned after regaining leadership, got %+v, %v", task, err)
+	}
+
+	want := []string{"a:true", "b:true", "a:false", "b:false", "b:true", "b:false", "a:true"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Unexpected leadership changes: %v, want %v", changes, want)
+	}
+}
+
+// TestSchedulerSimulation 测试调度器记录任务到达轨迹，以及在假设的Agent数、工作协程数和队列上限下回放轨迹
+func TestSchedulerSimulation(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&AgentInfo{Name: "agent-1", Metadata: make(map[string]string)})
+	scheduler := NewTaskSchedulerFromConfig(registry, config.SchedulerConfig{PollInterval: "10ms", TraceSize: 2})
+	scheduler.SetExecutor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		return "done", nil
+	})
+	scheduler.Start()
+	for i := 0; i < 3; i++ {
+		if err := scheduler.Submit(&Task{ID: fmt.Sprintf("task-%d", i), Priority: TaskPriorityHigh}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for scheduler.GetQueueSize() > 0 || len(scheduler.GetRunningTasks()) > 0 || len(scheduler.Trace()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Tasks did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	scheduler.Stop()
+	trace := scheduler.Trace()
+	if len(trace) != 2 {
+		t.Fatalf("Expected trace to keep the last 2 tasks, got %d", len(trace))
+	}
+	if trace[0].Priority != TaskPriorityHigh || trace[0].WaitMs == nil || trace[0].ArrivedAt.After(trace[1].ArrivedAt) {
+		t.Errorf("Unexpected trace: %+v", trace)
+	}
+	if observed := ObservedWait(trace); observed.Tasks != 2 {
+		t.Errorf("Expected observed wait over 2 tasks, got %+v", observed)
+	}
+
+	// 四个1秒的任务同时到达：1个Agent时按优先级依次执行，2个Agent时两两执行
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	burst := []TraceEntry{
+		{ArrivedAt: start, Priority: TaskPriorityLow, DurationMs: 1000},
+		{ArrivedAt: start, Priority: TaskPriorityNormal, DurationMs: 1000},
+		{ArrivedAt: start, Priority: TaskPriorityHigh, DurationMs: 1000},
+		{ArrivedAt: start, Priority: TaskPriorityUrgent, DurationMs: 1000},
+	}
+	result, err := Simulate(burst, SimulationScenario{Agents: 1}, nil)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if result.Completed != 4 || result.MakespanMs != 4000 || result.Utilization != 1 || result.Wait.MaxMs != 3000 || result.Wait.AvgMs != 1500 {
+		t.Errorf("Unexpected single-agent result: %+v", result)
+	}
+	if result.ByPriority["urgent"].MaxMs != 0 || result.ByPriority["normal"].MaxMs != 2000 || result.ByPriority["low"].MaxMs != 3000 {
+		t.Errorf("Expected tasks to run in priority order, got %+v", result.ByPriority)
+	}
+	if result.MaxQueueLength != 3 {
+		t.Errorf("Expected queue to peak at 3, got %d", result.MaxQueueLength)
+	}
+	result, _ = Simulate(burst, SimulationScenario{Agents: 2}, nil)
+	if result.MakespanMs != 2000 || result.Wait.MaxMs != 1000 || result.Wait.P50Ms != 0 {
+		t.Errorf("Unexpected two-agent result: %+v", result)
+	}
+	// 工作协程数限制并发，队列上限拒绝多出的任务
+	result, _ = Simulate(burst, SimulationScenario{Agents: 4, Workers: 1}, nil)
+	if result.MakespanMs != 4000 {
+		t.Errorf("Expected workers to limit concurrency, got makespan %d", result.MakespanMs)
+	}
+	result, _ = Simulate(burst, SimulationScenario{Agents: 1, QueueSize: 2}, nil)
+	if result.Completed != 2 || result.Rejected != 2 {
+		t.Errorf("Expected 2 rejected tasks, got %+v", result)
+	}
+
+	// 到达速率翻倍时每秒到达一个的1秒任务开始排队
+	spaced := make([]TraceEntry, 4)
+	for i := range spaced {
+		spaced[i] = TraceEntry{ArrivedAt: start.Add(time.Duration(i) * time.Second), Priority: TaskPriorityNormal, DurationMs: 1000}
+	}
+	result, _ = Simulate(spaced, SimulationScenario{Agents: 1}, nil)
+	if result.Wait.MaxMs != 0 || result.Utilization != 1 {
+		t.Errorf("Expected no queueing at the recorded rate, got %+v", result)
+	}
+	result, _ = Simulate(spaced, SimulationScenario{Agents: 1, LoadFactor: 2}, nil)
+	if result.Wait.MaxMs != 1500 || result.MakespanMs != 4000 {
+		t.Errorf("Expected queueing at double load, got %+v", result)
+	}
+	result, _ = Simulate(spaced, SimulationScenario{Agents: 1, LoadFactor: 2, DurationScale: 0.5}, nil)
+	if result.Wait.MaxMs != 0 {
+		t.Errorf("Expected faster tasks to absorb double load, got %+v", result)
+	}
+
+	if _, err := Simulate(burst, SimulationScenario{Agents: 0}, nil); !errors.Is(err, ErrInvalidScenario) {
+		t.Errorf("Expected ErrInvalidScenario, got %v", err)
+	}
+}
+
+// TestAgentRegistryConcurrentClaim 测试并发调度时同一个Agent不会被分配两次
+func TestAgentRegistryConcurrentClaim(t *testing.T) {
+	registry := NewAgentRegistry()
+	for i := 0; i < 4; i++ {
+		registry.Register(&AgentInfo{Name: fmt.Sprintf("agent-%d", i), Metadata: make(map[string]string)})
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed = make(map[string]int)
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent, err := registry.claim("", "", nil)
+			_ = registry.List()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			claimed[agent.Name]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != 4 {
+		t.Fatalf("Expected 4 agents claimed, got %v", claimed)
+	}
+	for name, n := range claimed {
+		if n != 1 {
+			t.Errorf("Agent %s claimed %d times", name, n)
+		}
+	}
+	if busy := registry.CountByStatus("busy"); busy != 4 {
+		t.Errorf("Expected 4 busy agents, got %d", busy)
+	}
+}
+
+// TestAgentRegistrySharedStore 测试共享存储的注册表：重启后注册信息仍在，多个实例不会占用同一个Agent
+func TestAgentRegistrySharedStore(t *testing.T) {
+	store := NewMemoryAgentStore()
+	first := NewAgentRegistryWithStore(store)
+	for i := 0; i < 3; i++ {
+		if err := first.Register(&AgentInfo{Name: fmt.Sprintf("agent-%d", i), Capabilities: []string{"search"}}); err != nil {
+			t.Fatalf("Failed to register agent: %v", err)
+		}
+	}
+	if err := first.Register(&AgentInfo{Name: "agent-0"}); !errors.Is(err, ErrAgentExists) {
+		t.Errorf("Expected ErrAgentExists, got %v", err)
+	}
+
+	// 模拟编排器重启：新的注册表实例读取同一个存储
+	second := NewAgentRegistryWithStore(store)
+	if n := second.Count(); n != 3 {
+		t.Fatalf("Expected 3 agents after restart, got %d", n)
+	}
+	if agents := second.ListByCapability("search"); len(agents) != 3 || agents[0].Name != "agent-0" {
+		t.Errorf("Expected agents sorted by name, got %v", agents)
+	}
+
+	claimed := make(map[string]bool)
+	for _, registry := range []*AgentRegistry{first, second, first} {
+		agent, err := registry.claim("", "", nil)
+		if err != nil {
+			t.Fatalf("Failed to claim agent: %v", err)
+		}
+		if claimed[agent.Name] {
+			t.Errorf("Agent %s claimed twice", agent.Name)
+		}
+		claimed[agent.Name] = true
+	}
+	if _, err := second.claim("", "", nil); err == nil {
+		t.Error("Expected no idle agent after all agents were claimed")
+	}
+	if _, err := first.claim("", "agent-1", nil); !errors.Is(err, errAgentNotActive) {
+		t.Errorf("Expected errAgentNotActive, got %v", err)
+	}
+
+	if err := second.UpdateStatus("agent-1", "active"); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if agent, err := first.Get("agent-1"); err != nil || agent.Status != "active" {
+		t.Errorf("Status update should be visible to other instances, got %+v, %v", agent, err)
+	}
+	if err := first.Unregister("agent-2"); err != nil {
+		t.Fatalf("Failed to unregister agent: %v", err)
+	}
+	if _, err := second.Get("agent-2"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+}
+
+// TestMemoryAgentStore 测试内存Agent存储
+func TestMemoryAgentStore(t *testing.T) {
+	testAgentStore(t, NewMemoryAgentStore())
+}
+
+// TestRedisAgentStore 测试Redis Agent存储：SETNX去重、SCAN列出、WATCH事务更新
+func TestRedisAgentStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := NewRedisAgentStore(config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("Failed to create redis agent store: %v", err)
+	}
+	defer store.Close()
+	testAgentStore(t, store)
+
+	// 读取后其他实例修改了同一个Agent时，WATCH事务失败并基于最新值重试
+	if err := store.Create(context.Background(), &AgentInfo{Name: "agent-c", Status: "active"}); err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	other := NewRedisAgentStoreWithClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer other.Close()
+	attempts := 0
+	updated, err := store.Update(context.Background(), "agent-c", func(agent *AgentInfo) error {
+		attempts++
+		if attempts == 1 {
+			if _, err := other.Update(context.Background(), "agent-c", func(agent *AgentInfo) error {
+				agent.Endpoint = "grpc://agent-c"
+				return nil
+			}); err != nil {
+				t.Fatalf("Failed to update agent from other instance: %v", err)
+			}
+		}
+		agent.Status = "busy"
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("Expected update to retry once after conflict, got %d attempts, %v", attempts, err)
+	}
+	if updated.Status != "busy" || updated.Endpoint != "grpc://agent-c" {
+		t.Errorf("Expected both updates to be applied, got %+v", updated)
+	}
+}
+
+// TestPostgresAgentStore 测试Postgres Agent存储的迁移和SQL语句
+func TestPostgresAgentStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// 首次启动执行全部迁移
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS agent_schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM agent_schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS agents`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO agent_schema_migrations`).WithArgs(1, "create agents table").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	store, err := NewPostgresAgentStore(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to create postgres agent store: %v", err)
+	}
+
+	// 已应用的迁移不再执行
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS agent_schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM agent_schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	agent := &AgentInfo{Name: "agent-a", Status: "active", Capabilities: []string{"search"}}
+	mock.ExpectExec(`INSERT INTO agents \(name, info\) VALUES \(\$1, \$2\) ON CONFLICT \(name\) DO NOTHING`).
+		WithArgs("agent-a", agentJSON{status: "active"}).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO agents`).WithArgs("agent-a", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := store.Create(ctx, agent); err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	if err := store.Create(ctx, agent); !errors.Is(err, ErrAgentExists) {
+		t.Errorf("Expected ErrAgentExists, got %v", err)
+	}
+
+	data, _ := json.Marshal(agent)
+	mock.ExpectQuery(`SELECT info FROM agents WHERE name = \$1`).WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"info"}))
+	mock.ExpectQuery(`SELECT info FROM agents ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows([]string{"info"}).AddRow(data).AddRow([]byte(`{"name":"agent-b"}`)))
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+	if agents, err := store.List(ctx); err != nil || len(agents) != 2 || agents[0].Name != "agent-a" || agents[1].Name != "agent-b" {
+		t.Errorf("Expected agent-a and agent-b, got %v, %v", agents, err)
+	}
+
+	// Update锁住该行后写回修改，update返回错误时回滚
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT info FROM agents WHERE name = \$1 FOR UPDATE`).WithArgs("agent-a").
+		WillReturnRows(sqlmock.NewRows([]string{"info"}).AddRow(data))
+	mock.ExpectExec(`UPDATE agents SET info = \$2, updated_at = NOW\(\) WHERE name = \$1`).
+		WithArgs("agent-a", agentJSON{status: "busy"}).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	updated, err := store.Update(ctx, "agent-a", func(agent *AgentInfo) error {
+		agent.Status = "busy"
+		return nil
+	})
+	if err != nil || updated.Status != "busy" {
+		t.Errorf("Expected busy agent, got %+v, %v", updated, err)
+	}
+
+	errRejected := errors.New("rejected")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT info FROM agents WHERE name = \$1 FOR UPDATE`).WithArgs("agent-a").
+		WillReturnRows(sqlmock.NewRows([]string{"info"}).AddRow(data))
+	mock.ExpectRollback()
+	if _, err := store.Update(ctx, "agent-a", func(*AgentInfo) error { return errRejected }); !errors.Is(err, errRejected) {
+		t.Errorf("Expected update error, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT info FROM agents WHERE name = \$1 FOR UPDATE`).WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"info"}))
+	mock.ExpectRollback()
+	if _, err := store.Update(ctx, "missing", func(*AgentInfo) error { return nil }); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+
+	mock.ExpectExec(`DELETE FROM agents WHERE name = \$1`).WithArgs("agent-a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM agents WHERE name = \$1`).WithArgs("agent-a").WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := store.Delete(ctx, "agent-a"); err != nil {
+		t.Errorf("Failed to delete agent: %v", err)
+	}
+	if err := store.Delete(ctx, "agent-a"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// agentJSON 匹配以JSON保存的Agent信息中的状态
+type agentJSON struct {
+	status string
+}
+
+func (m agentJSON) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	var agent AgentInfo
+	return json.Unmarshal(data, &agent) == nil && agent.Status == m.status
+}
+
+// testAgentStore 各Agent存储共用的行为测试
+func testAgentStore(t *testing.T, store AgentStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, name := range []string{"agent-b", "agent-a"} {
+		agent := &AgentInfo{Name: name, Status: "active", Capabilities: []string{"search"}, Metadata: map[string]string{"zone": "a"}}
+		if err := store.Create(ctx, agent); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	if err := store.Create(ctx, &AgentInfo{Name: "agent-a"}); !errors.Is(err, ErrAgentExists) {
+		t.Errorf("Expected ErrAgentExists, got %v", err)
+	}
+
+	agent, err := store.Get(ctx, "agent-a")
+	if err != nil || agent.Status != "active" || !reflect.DeepEqual(agent.Capabilities, []string{"search"}) || agent.Metadata["zone"] != "a" {
+		t.Errorf("Unexpected agent: %+v, %v", agent, err)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+	if agents, err := store.List(ctx); err != nil || len(agents) != 2 || agents[0].Name != "agent-a" || agents[1].Name != "agent-b" {
+		t.Errorf("Expected agents sorted by name, got %v, %v", agents, err)
+	}
+
+	updated, err := store.Update(ctx, "agent-a", func(agent *AgentInfo) error {
+		agent.Status = "busy"
+		return nil
+	})
+	if err != nil || updated.Status != "busy" {
+		t.Errorf("Expected busy agent, got %+v, %v", updated, err)
+	}
+	errRejected := errors.New("rejected")
+	if _, err := store.Update(ctx, "agent-a", func(agent *AgentInfo) error {
+		agent.Status = "inactive"
+		return errRejected
+	}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected update error, got %v", err)
+	}
+	if agent, _ := store.Get(ctx, "agent-a"); agent == nil || agent.Status != "busy" {
+		t.Errorf("Rejected update should not be saved, got %+v", agent)
+	}
+	if _, err := store.Update(ctx, "missing", func(*AgentInfo) error { return nil }); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "agent-a"); err != nil {
+		t.Errorf("Failed to delete agent: %v", err)
+	}
+	if err := store.Delete(ctx, "agent-a"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+	if agents, _ := store.List(ctx); len(agents) != 1 {
+		t.Errorf("Expected 1 agent after delete, got %d", len(agents))
+	}
+	store.Delete(ctx, "agent-b")
+}
+
+// TestTaskSchedulerDeterministic 测试相同的随机种子和时钟下分配结果与任务时间可复现
+func TestTaskSchedulerDeterministic(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(seed int64) map[string]string {
+		fake := clock.NewFake(start)
+		registry := NewAgentRegistry()
+		registry.SetClock(fake)
+		for i := 0; i < 6; i++ {
+			registry.Register(&AgentInfo{Name: fmt.Sprintf("agent-%d", i), Metadata: make(map[string]string)})
+		}
+		scheduler := NewTaskScheduler(registry)
+		scheduler.SetClock(fake)
+		scheduler.SetSeed(seed)
+		tasks := make([]*Task, 4)
+		for i := range tasks {
+			tasks[i] = &Task{ID: fmt.Sprintf("task-%d", i), Priority: TaskPriority(i % 2)}
+			if err := scheduler.Submit(tasks[i]); err != nil {
+				t.Fatalf("Failed to submit task: %v", err)
+			}
+		}
+		scheduler.scheduleTasks()
+
+		assigned := make(map[string]string)
+		for _, task := range scheduler.GetRunningTasks() {
+			assigned[task.ID] = task.AssignedTo
+			if !task.CreatedAt.Equal(start) {
+				t.Errorf("task %s CreatedAt = %v, want %v", task.ID, task.CreatedAt, start)
+			}
+		}
+		fake.Advance(time.Minute)
+		scheduler.CompleteTask("task-0", "done", nil)
+		if done := tasks[0].CompletedAt; done == nil || done.Sub(start) != time.Minute {
+			t.Errorf("task-0 CompletedAt = %v, want %v", done, start.Add(time.Minute))
+		}
+		if agent, _ := registry.Get(assigned["task-0"]); agent == nil || !agent.CreatedAt.Equal(start) {
+			t.Errorf("agent CreatedAt should come from the fake clock, got %+v", agent)
+		}
+		return assigned
+	}
+
+	first, second := run(42), run(42)
+	if len(first) != 4 {
+		t.Fatalf("Expected 4 tasks assigned, got %v", first)
+	}
+	for id, agent := range first {
+		if second[id] != agent {
+			t.Errorf("task %s assigned to %s and %s with the same seed", id, agent, second[id])
+		}
+	}
+}
+
+// TestTaskSchedulerRetryAndDeadLetter 测试执行失败按退避时间重试，用完次数后进入死信队列并可重新提交或删除
+func TestTaskSchedulerRetryAndDeadLetter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	registry := NewAgentRegistry()
+	registry.Register(&AgentInfo{Name: "agent-1", Metadata: make(map[string]string)})
+	scheduler := NewTaskScheduler(registry)
+	scheduler.SetClock(fake)
+	scheduler.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Multiplier: 2})
+	fail := true
+	scheduler.SetExecutor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		if fail {
+			return nil, errors.New("boom")
+		}
+		return "ok", nil
+	})
+
+	// run 分配并执行一次，返回是否执行
+	run := func() bool {
+		scheduler.scheduleTasks()
+		task, err := scheduler.GetTask("task-1")
+		if err != nil {
+			return false
+		}
+		scheduler.execute(scheduler.runningTasks[task.ID])
+		return true
+	}
+
+	if err := scheduler.Submit(&Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	for attempt, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		if !run() {
+			t.Fatalf("attempt %d was not executed", attempt+1)
+		}
+		pending := scheduler.PendingTasks()
+		if len(pending) != 1 || pending[0].Attempts != attempt+1 || pending[0].NextAttemptAt == nil || !pending[0].NextAttemptAt.Equal(fake.Now().Add(backoff)) {
+			t.Fatalf("task should wait %v before retrying, got %+v", backoff, pending)
+		}
+		if run() {
+			t.Fatalf("task retried before its backoff elapsed")
+		}
+		fake.Advance(backoff)
+	}
+	if !run() {
+		t.Fatal("last attempt was not executed")
+	}
+
+	letters := scheduler.DeadLetters()
+	if len(letters) != 1 || letters[0].Reason != DeadLetterExecutionFailed || letters[0].Task.Attempts != 3 || letters[0].Task.Error != "boom" {
+		t.Fatalf("task should be dead-lettered after 3 attempts, got %+v", letters)
+	}
+	if len(scheduler.PendingTasks()) != 0 {
+		t.Errorf("dead-lettered task should not be pending")
+	}
+
+	// 重新提交后执行成功
+	fail = false
+	if task, err := scheduler.Requeue("task-1"); err != nil || task.Attempts != 0 || task.Error != "" {
+		t.Fatalf("Requeue failed: %+v, %v", task, err)
+	}
+	if !run() {
+		t.Fatal("requeued task was not executed")
+	}
+	if _, err := scheduler.GetDeadLetter("task-1"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("requeued task should leave the dead letter queue, got %v", err)
+	}
+
+	// 多次分配不到Agent的任务也进入死信队列
+	if err := scheduler.Submit(&Task{ID: "task-2", AssignedTo: "missing", MaxRetries: 1}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	if dl, err := scheduler.GetDeadLetter("task-2"); err != nil || dl.Reason != DeadLetterAssignmentFailed {
+		t.Errorf("unassignable task should be dead-lettered, got %+v, %v", dl, err)
+	}
+	if err := scheduler.Discard("task-2"); err != nil {
+		t.Errorf("Discard failed: %v", err)
+	}
+	if err := scheduler.Discard("task-2"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected ErrDeadLetterNotFound, got %v", err)
+	}
+
+	// 退避时间有上限，抖动在±Jitter比例内
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second, Multiplier: 2, Jitter: 0.5}
+	if d := policy.delay(3, func() float64 { return 0 }); d != 1500*time.Millisecond {
+		t.Errorf("delay = %v, want 1.5s", d)
+	}
+}
+
+// TestRemoteAgents 测试任务下发给远程Agent、上报结果、取消和断开连接
+func TestTaskSchedulerPreemption(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&AgentInfo{Name: "agent-1", Metadata: make(map[string]string)})
+	scheduler := NewTaskScheduler(registry)
+	scheduler.SetPreemption(TaskPriorityHigh)
+	started := make(chan string, 4)
+	scheduler.SetExecutor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		started <- task.ID
+		if task.ID == "low" && task.Preemptions == 0 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "ok", nil
+	})
+
+	if err := scheduler.Submit(&Task{ID: "low", Priority: TaskPriorityLow}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.execute(scheduler.runningTasks["low"])
+	}()
+	if id := <-started; id != "low" {
+		t.Fatalf("expected low to start, got %s", id)
+	}
+
+	// 高优先级任务没有空闲Agent，抢占低优先级任务，等待期间不计入分配失败
+	if err := scheduler.Submit(&Task{ID: "high", Priority: TaskPriorityHigh}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	<-done
+	pending := scheduler.PendingTasks()
+	if len(pending) != 2 || pending[0].ID != "high" || pending[0].RetryCount != 0 {
+		t.Fatalf("high should wait at the head of the queue, got %+v", pending)
+	}
+	if low := pending[1]; low.Preemptions != 1 || low.Attempts != 0 || low.Status != TaskStatusPending {
+		t.Fatalf("preempted task should be requeued without using an attempt, got %+v", low)
+	}
+
+	for _, want := range []string{"high", "low"} {
+		scheduler.scheduleTasks()
+		task, err := scheduler.GetTask(want)
+		if err != nil {
+			t.Fatalf("expected %s to be assigned: %v", want, err)
+		}
+		scheduler.execute(scheduler.runningTasks[task.ID])
+		if id := <-started; id != want {
+			t.Fatalf("expected %s to run, got %s", want, id)
+		}
+	}
+
+	// 同优先级不抢占
+	if err := scheduler.Submit(&Task{ID: "low-2", Priority: TaskPriorityLow}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	if scheduler.preempt(&Task{ID: "low-3", Priority: TaskPriorityLow}) {
+		t.Error("tasks of the same priority should not preempt each other")
+	}
+}
+
+// TestTaskSchedulerCancel 测试取消等待调度、等待重试和执行中的任务
+func TestTaskSchedulerCancel(t *testing.T) {
+	registry := NewAgentRegistry()
+	scheduler := NewTaskScheduler(registry)
+	scheduler.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, Backoff: time.Hour})
+	started := make(chan string, 1)
+	scheduler.SetExecutor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		if task.ID == "retry" {
+			return nil, errors.New("boom")
+		}
+		started <- task.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	// 没有Agent时任务留在队列中
+	if err := scheduler.Submit(&Task{ID: "queued"}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	if err := scheduler.Cancel("queued"); err != nil {
+		t.Fatalf("Failed to cancel queued task: %v", err)
+	}
+	if size := scheduler.GetQueueSize(); size != 0 {
+		t.Errorf("cancelled task should leave the queue, got %d queued", size)
+	}
+	if err := scheduler.Cancel("queued"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound for a cancelled task, got %v", err)
+	}
+
+	// 执行失败后等待重试的任务
+	registry.Register(&AgentInfo{Name: "agent-1", Metadata: make(map[string]string)})
+	if err := scheduler.Submit(&Task{ID: "retry"}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	scheduler.execute(scheduler.runningTasks["retry"])
+	if pending := scheduler.PendingTasks(); len(pending) != 1 || pending[0].NextAttemptAt == nil {
+		t.Fatalf("task should wait before retrying, got %+v", pending)
+	}
+	if err := scheduler.Cancel("retry"); err != nil {
+		t.Fatalf("Failed to cancel task waiting for retry: %v", err)
+	}
+	if pending := scheduler.PendingTasks(); len(pending) != 0 {
+		t.Errorf("cancelled task should not be retried, got %+v", pending)
+	}
+
+	// 执行中的任务取消其ctx，不进入死信队列
+	if err := scheduler.Submit(&Task{ID: "running"}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	scheduler.scheduleTasks()
+	done := make(chan struct{})
+	go func() {
+		scheduler.execute(scheduler.runningTasks["running"])
+		close(done)
+	}()
+	<-started
+	if err := scheduler.Cancel("running"); err != nil {
+		t.Fatalf("Failed to cancel running task: %v", err)
+	}
+	<-done
+	if _, err := scheduler.GetTask("running"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("cancelled task should no longer be running, got %v", err)
+	}
+	if letters := scheduler.DeadLetters(); len(letters) != 0 {
+		t.Errorf("cancelled tasks should not be dead-lettered, got %+v", letters)
+	}
+}
+
+func TestRemoteAgents(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Upsert(&AgentInfo{Name: "remote", Status: "inactive"})
+	registry.Register(&AgentInfo{Name: "local", Metadata: make(map[string]string)})
+	remote := NewRemoteAgents(registry)
+	executor := remote.Executor(func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		return "local:" + task.ID, nil
+	})
+
+	// 未连接的Agent交给本地执行器
+	if result, err := executor(context.Background(), &Task{ID: "t0"}, &AgentInfo{Name: "local"}); err != nil || result != "local:t0" {
+		t.Errorf("unconnected agent should run locally, got %v, %v", result, err)
+	}
+	if _, err := remote.Execute(context.Background(), &Task{ID: "t0"}, &AgentInfo{Name: "remote"}); !errors.Is(err, ErrAgentNotConnected) {
+		t.Errorf("Expected ErrAgentNotConnected, got %v", err)
+	}
+
+	conn, err := remote.Connect("remote")
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if agent, _ := registry.Get("remote"); agent.Status != "active" {
+		t.Errorf("connected agent should be active, got %s", agent.Status)
+	}
+
+	// 下发任务并上报结果
+	done := make(chan error, 1)
+	go func() {
+		result, err := executor(context.Background(), &Task{ID: "t1"}, &AgentInfo{Name: "remote"})
+		if err == nil && result != "remote:t1" {
+			err = fmt.Errorf("unexpected result %v", result)
+		}
+		done <- err
+	}()
+	event := <-conn.Events()
+	if event.Cancel || event.Task.ID != "t1" || !remote.Running("remote", "t1") {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if err := remote.Complete("other", "t1", nil, nil); err == nil {
+		t.Error("other agents should not complete the task")
+	}
+	remote.Complete("remote", "t1", "remote:t1", nil)
+	if err := <-done; err != nil {
+		t.Errorf("remote task failed: %v", err)
+	}
+
+	// 取消时通知Agent
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := remote.Execute(ctx, &Task{ID: "t2"}, &AgentInfo{Name: "remote"})
+		done <- err
+	}()
+	<-conn.Events()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if event := <-conn.Events(); !event.Cancel || event.Task.ID != "t2" || event.Reason != "cancelled" {
+		t.Errorf("Expected cancel event, got %+v", event)
+	}
+
+	// 断开连接时执行中的任务失败，Agent变为inactive
+	go func() {
+		_, err := remote.Execute(context.Background(), &Task{ID: "t3"}, &AgentInfo{Name: "remote"})
+		done <- err
+	}()
+	<-conn.Events()
+	conn.Close()
+	if err := <-done; !errors.Is(err, ErrAgentDisconnected) {
+		t.Errorf("Expected ErrAgentDisconnected, got %v", err)
+	}
+	if agent, _ := registry.Get("remote"); agent.Status != "inactive" || remote.Connected("remote") {
+		t.Errorf("disconnected agent should be inactive, got %s", agent.Status)
+	}
+}
+
 // TestTaskQueue 测试任务队列
 func TestTaskQueue(t *testing.T) {
 	queue := NewTaskQueue()
@@ -170,6 +1401,33 @@ func TestTaskQueue(t *testing.T) {
 	}
 }
 
+func TestTaskQueueWeighted(t *testing.T) {
+	queue := NewTaskQueue()
+	queue.SetWeights(map[TaskPriority]int{TaskPriorityLow: 1, TaskPriorityHigh: 3})
+	for i := 0; i < 4; i++ {
+		queue.Enqueue(&Task{ID: fmt.Sprintf("high-%d", i), Priority: TaskPriorityHigh})
+		queue.Enqueue(&Task{ID: fmt.Sprintf("low-%d", i), Priority: TaskPriorityLow})
+	}
+
+	// 高优先级按3:1获得份额，低优先级不会等到高优先级任务全部出队；同优先级先入先出
+	var order []string
+	for task := queue.Dequeue(); task != nil; task = queue.Dequeue() {
+		order = append(order, task.ID)
+	}
+	want := []string{"high-0", "low-0", "high-1", "high-2", "high-3", "low-1", "low-2", "low-3"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("unexpected weighted order: %v", order)
+	}
+
+	// 去掉权重后恢复严格按优先级
+	queue.SetWeights(nil)
+	queue.Enqueue(&Task{ID: "low", Priority: TaskPriorityLow})
+	queue.Enqueue(&Task{ID: "high", Priority: TaskPriorityHigh})
+	if task := queue.Peek(); task.ID != "high" {
+		t.Errorf("strict mode should dequeue high first, got %s", task.ID)
+	}
+}
+
 // TestCommunicationBus 测试通信总线
 func TestCommunicationBus(t *testing.T) {
 	bus := NewCommunicationBus()
@@ -233,6 +1491,246 @@ func TestCommunicationBus(t *testing.T) {
 	}
 }
 
+// TestCommunicationBusBroker 测试经消息代理跨进程通信：消费组、重新投递和广播
+func TestCommunicationBusBroker(t *testing.T) {
+	// 两个总线共享一个代理，模拟两个进程
+	broker := NewMemoryBroker(DeliveryPolicy{MaxDeliver: 3, RetryDelay: 10 * time.Millisecond})
+	bus1 := NewCommunicationBusWithBroker(broker, "test")
+	bus2 := NewCommunicationBusWithBroker(broker, "test")
+	defer bus1.Stop()
+	defer bus2.Stop()
+
+	// 两个进程订阅同一Agent时组成消费组，每条消息只由其中一个处理
+	var mu sync.Mutex
+	received := map[string]int{}
+	var count1, count2 int32
+	var failed int32
+	handler := func(counter *int32) MessageHandler {
+		return func(msg *Message) error {
+			// 第一条消息第一次处理失败，应重新投递
+			if msg.Content == "msg-0" && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+				return errors.New("temporary failure")
+			}
+			atomic.AddInt32(counter, 1)
+			mu.Lock()
+			received[msg.ID]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	if err := bus1.Subscribe("worker", handler(&count1)); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := bus2.Subscribe("worker", handler(&count2)); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		if err := bus1.Send(&Message{Type: MessageTypeTask, From: "orchestrator", To: "worker", Content: fmt.Sprintf("msg-%d", i)}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&count1)+atomic.LoadInt32(&count2) < total && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	if len(received) != total {
+		t.Errorf("expected %d distinct messages, got %d", total, len(received))
+	}
+	for id, n := range received {
+		if n != 1 {
+			t.Errorf("message %s handled %d times, expected once", id, n)
+		}
+	}
+	mu.Unlock()
+	if atomic.LoadInt32(&count1) == 0 || atomic.LoadInt32(&count2) == 0 {
+		t.Errorf("expected both group members to receive messages, got %d and %d", count1, count2)
+	}
+	if atomic.LoadInt32(&failed) != 1 {
+		t.Error("expected the failed message to be redelivered")
+	}
+	if history := bus1.GetRecentHistory(0); len(history) != total {
+		t.Errorf("expected %d messages in sender history, got %d", total, len(history))
+	}
+
+	// 广播消息每个进程都收到
+	broadcast1 := make(chan *Message, 2)
+	broadcast2 := make(chan *Message, 2)
+	bus1.SubscribeBroadcast(func(msg *Message) error { broadcast1 <- msg; return nil })
+	bus2.SubscribeBroadcast(func(msg *Message) error { broadcast2 <- msg; return nil })
+	if err := bus1.Broadcast(&Message{Type: MessageTypeEvent, From: "system", Content: "hello"}); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	for i, ch := range []chan *Message{broadcast1, broadcast2} {
+		select {
+		case msg := <-ch:
+			if msg.Content != "hello" {
+				t.Errorf("bus%d received unexpected broadcast content %v", i+1, msg.Content)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("bus%d timeout waiting for broadcast", i+1)
+		}
+	}
+
+	// 处理一直失败的消息达到最多投递次数后丢弃
+	var attempts int32
+	bus2.Subscribe("flaky", func(msg *Message) error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("permanent failure")
+	})
+	bus1.Send(&Message{Type: MessageTypeTask, To: "flaky"})
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("expected 3 deliveries, got %d", n)
+	}
+}
+
+// TestCommunicationBusRequest 测试请求/回复：进程内和经消息代理跨进程
+func TestCommunicationBusRequest(t *testing.T) {
+	local := NewCommunicationBus()
+	defer local.Stop()
+
+	broker := NewMemoryBroker(DeliveryPolicy{MaxDeliver: 1})
+	requester := NewCommunicationBusWithBroker(broker, "test")
+	responder := NewCommunicationBusWithBroker(broker, "test")
+	defer requester.Stop()
+	defer responder.Stop()
+
+	for name, buses := range map[string][2]*CommunicationBus{
+		"local":  {local, local},
+		"broker": {requester, responder},
+	} {
+		client, server := buses[0], buses[1]
+		err := server.SubscribeRequests("calculator", func(msg *Message) (interface{}, error) {
+			if msg.Content == "fail" {
+				return nil, errors.New("cannot compute")
+			}
+			if msg.Content == "slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			return fmt.Sprintf("answer to %v", msg.Content), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: SubscribeRequests failed: %v", name, err)
+		}
+
+		reply, err := client.Request(context.Background(), &Message{From: "planner", To: "calculator", Content: "2+2"}, 5*time.Second)
+		if err != nil {
+			t.Fatalf("%s: Request failed: %v", name, err)
+		}
+		if reply.Type != MessageTypeResponse || reply.From != "calculator" || reply.Content != "answer to 2+2" {
+			t.Errorf("%s: unexpected reply %+v", name, reply)
+		}
+
+		// 并发请求按CorrelationID匹配各自的回复
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				reply, err := client.Request(context.Background(), &Message{To: "calculator", Content: i}, 5*time.Second)
+				if err != nil {
+					t.Errorf("%s: concurrent Request failed: %v", name, err)
+					return
+				}
+				if want := fmt.Sprintf("answer to %d", i); reply.Content != want {
+					t.Errorf("%s: expected %q, got %v", name, want, reply.Content)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if _, err := client.Request(context.Background(), &Message{To: "calculator", Content: "fail"}, 5*time.Second); !errors.Is(err, ErrRequestFailed) {
+			t.Errorf("%s: expected ErrRequestFailed, got %v", name, err)
+		}
+		if _, err := client.Request(context.Background(), &Message{To: "calculator", Content: "slow"}, 20*time.Millisecond); !errors.Is(err, ErrRequestTimeout) {
+			t.Errorf("%s: expected ErrRequestTimeout, got %v", name, err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := client.Request(ctx, &Message{To: "calculator", Content: "slow"}, 0); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+}
+
+// TestCommunicationBusMessageLog 测试消息日志：重启后从偏移量重放错过的消息，按主题和时间查询历史
+func TestCommunicationBusMessageLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.jsonl")
+	bus, err := NewCommunicationBusFromConfig(config.BusConfig{Log: config.BusLogConfig{Enabled: true, Path: path}})
+	if err != nil {
+		t.Fatalf("NewCommunicationBusFromConfig failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := bus.Send(&Message{Type: MessageTypeTask, From: "orchestrator", To: "worker", Content: fmt.Sprintf("task-%d", i)}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := bus.Broadcast(&Message{Type: MessageTypeEvent, From: "orchestrator", Content: "shutdown"}); err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	bus.Stop()
+
+	// 重新打开日志后偏移量接着增长，离线的worker从偏移量1开始重放
+	bus, err = NewCommunicationBusFromConfig(config.BusConfig{Log: config.BusLogConfig{Enabled: true, Path: path}})
+	if err != nil {
+		t.Fatalf("NewCommunicationBusFromConfig failed: %v", err)
+	}
+	defer bus.Stop()
+	cutoff := time.Now()
+	if err := bus.Send(&Message{Type: MessageTypeTask, From: "orchestrator", To: "worker", Content: "task-3"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var replayed []interface{}
+	next, err := bus.Replay("worker", 1, func(msg *Message) error {
+		replayed = append(replayed, msg.Content)
+		return nil
+	})
+	if err != nil || next != 5 {
+		t.Fatalf("Replay returned %d, %v", next, err)
+	}
+	if !reflect.DeepEqual(replayed, []interface{}{"task-1", "task-2", "task-3"}) {
+		t.Errorf("Unexpected replayed messages: %v", replayed)
+	}
+
+	// 处理失败时停在出错的偏移量
+	next, err = bus.Replay("", 0, func(msg *Message) error {
+		if msg.Content == "shutdown" {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+	if err == nil || next != 3 {
+		t.Errorf("Expected replay to stop at offset 3, got %d (%v)", next, err)
+	}
+
+	history, err := bus.GetHistory(BroadcastTopic, time.Time{})
+	if err != nil || len(history) != 1 || history[0].Content != "shutdown" {
+		t.Errorf("Unexpected broadcast history: %v (%v)", history, err)
+	}
+	history, err = bus.GetHistory("worker", cutoff)
+	if err != nil || len(history) != 1 || history[0].Content != "task-3" {
+		t.Errorf("Unexpected history since cutoff: %v (%v)", history, err)
+	}
+
+	// 未启用消息日志时不能重放，历史来自内存
+	local := NewCommunicationBus()
+	defer local.Stop()
+	if _, err := local.Replay("worker", 0, func(*Message) error { return nil }); err == nil {
+		t.Error("Expected replay error without a message log")
+	}
+	local.Send(&Message{Type: MessageTypeTask, From: "a", To: "b", Content: "x"})
+	if history, _ := local.GetHistory("b", time.Time{}); len(history) != 1 {
+		t.Errorf("Expected in-memory history, got %v", history)
+	}
+}
+
 // TestEventBus 测试事件总线
 func TestEventBus(t *testing.T) {
 	bus := NewEventBus()
@@ -265,3 +1763,74 @@ func TestEventBus(t *testing.T) {
 		t.Error("Timeout waiting for event")
 	}
 }
+
+// TestSnapshotRestore 测试运行时快照中的Agent和等待任务恢复到新实例
+func TestSnapshotRestore(t *testing.T) {
+	registry := NewAgentRegistry()
+	if err := registry.Register(&AgentInfo{ID: "agent-1", Name: "existing", Type: "expert", Status: "active"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	busy := &AgentInfo{ID: "agent-2", Name: "worker", Type: "expert", Capabilities: []string{"search"}, Status: "busy"}
+	restored, err := registry.Restore([]*AgentInfo{
+		{ID: "agent-x", Name: "existing", Type: "expert", Status: "inactive"},
+		busy,
+	})
+	if err != nil || restored != 1 {
+		t.Fatalf("Restore = %d, %v; want 1 agent", restored, err)
+	}
+	if agent, _ := registry.Get("existing"); agent.ID != "agent-1" || agent.Status != "active" {
+		t.Errorf("registered agents should be kept, got %+v", agent)
+	}
+	if agent, _ := registry.Get("worker"); agent == nil || agent.Status != "active" || len(agent.Capabilities) != 1 {
+		t.Errorf("busy agents should be restored as active, got %+v", agent)
+	}
+	if busy.Status != "busy" {
+		t.Error("Restore should not modify the snapshot")
+	}
+
+	// 旧实例的等待任务按优先级和创建时间导出
+	old := NewTaskScheduler(registry)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, task := range []*Task{
+		{ID: "task-low", Priority: TaskPriorityLow},
+		{ID: "task-normal-1", Priority: TaskPriorityNormal},
+		{ID: "task-high", Priority: TaskPriorityHigh},
+		{ID: "task-normal-2", Priority: TaskPriorityNormal},
+	} {
+		task.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		old.taskQueue.Enqueue(task)
+	}
+	pending := old.PendingTasks()
+	ids := make([]string, 0, len(pending))
+	for _, task := range pending {
+		ids = append(ids, task.ID)
+	}
+	if want := []string{"task-high", "task-normal-1", "task-normal-2", "task-low"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("PendingTasks = %v, want %v", ids, want)
+	}
+
+	// 新实例恢复时重置分配状态，保留ID、创建时间和重试次数，已在队列中的任务跳过
+	started := base
+	pending[0].Status = TaskStatusRunning
+	pending[0].AssignedTo = "worker"
+	pending[0].StartedAt = &started
+	pending[0].RetryCount = 2
+
+	scheduler := NewTaskScheduler(registry)
+	scheduler.queueSize = 3
+	scheduler.taskQueue.Enqueue(&Task{ID: "task-low", Priority: TaskPriorityLow, CreatedAt: base})
+	restored, err = scheduler.Restore(pending)
+	if !errors.Is(err, ErrQueueFull) || restored != 2 {
+		t.Fatalf("Restore = %d, %v; want 2 tasks and ErrQueueFull", restored, err)
+	}
+	queued := scheduler.PendingTasks()
+	if len(queued) != 3 || queued[0].ID != "task-high" || queued[1].ID != "task-normal-1" {
+		t.Fatalf("unexpected queue after restore: %+v", queued)
+	}
+	high := queued[0]
+	if high.Status != TaskStatusPending || high.AssignedTo != "" || high.StartedAt != nil || high.RetryCount != 2 ||
+		!high.CreatedAt.Equal(base.Add(2*time.Minute)) || high.MaxRetries != defaultMaxRetries {
+		t.Errorf("unexpected restored task: %+v", high)
+	}
+}
@@ -0,0 +1,184 @@
+package orchestrator
+
+import "strings"
+
+// DefaultFairnessWeights 加权公平模式下各优先级的默认权重
+var DefaultFairnessWeights = map[TaskPriority]int{
+	TaskPriorityLow:    1,
+	TaskPriorityNormal: 2,
+	TaskPriorityHigh:   4,
+	TaskPriorityUrgent: 8,
+}
+
+// ParseTaskPriority 解析优先级名称：low, normal, high, urgent，不区分大小写
+func ParseTaskPriority(name string) (TaskPriority, bool) {
+	switch strings.ToLower(name) {
+	case "low":
+		return TaskPriorityLow, true
+	case "normal":
+		return TaskPriorityNormal, true
+	case "high":
+		return TaskPriorityHigh, true
+	case "urgent":
+		return TaskPriorityUrgent, true
+	}
+	return 0, false
+}
+
+// SetWeights 切换为加权公平出队：各优先级按权重比例轮流出队，低优先级不会被持续到达的高优先级任务饿死，
+// 同时高优先级始终获得与权重相称的份额；weights为空时恢复严格按优先级出队，未设置权重的优先级按1
+func (q *TaskQueue) SetWeights(weights map[TaskPriority]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(weights) == 0 {
+		q.weights = nil
+		q.pass = nil
+		return
+	}
+	q.weights = make(map[TaskPriority]int, len(weights))
+	for priority, weight := range weights {
+		if weight > 0 {
+			q.weights[priority] = weight
+		}
+	}
+	q.pass = make(map[TaskPriority]float64)
+	q.vtime = 0
+}
+
+// fairIndex 加权公平模式下下一个出队的任务位置：在有任务的优先级中选累计虚拟时间最小的（相同时选优先级高的），
+// 取该优先级中最早入队的任务，调用方需持有q.mu且队列非空
+func (q *TaskQueue) fairIndex() (int, TaskPriority, float64) {
+	oldest := make(map[TaskPriority]int)
+	for i, task := range q.items {
+		if j, ok := oldest[task.Priority]; !ok || task.seq < q.items[j].seq {
+			oldest[task.Priority] = i
+		}
+	}
+
+	best, bestPriority, bestPass := -1, TaskPriority(0), 0.0
+	for priority, i := range oldest {
+		// 空闲过的优先级从当前虚拟时间开始，不累积空闲期间的份额
+		pass := q.pass[priority]
+		if pass < q.vtime {
+			pass = q.vtime
+		}
+		if best < 0 || pass < bestPass || (pass == bestPass && priority > bestPriority) {
+			best, bestPriority, bestPass = i, priority, pass
+		}
+	}
+	return best, bestPriority, bestPass
+}
+
+// advance 记录加权公平模式下priority出队一个任务，调用方需持有q.mu
+func (q *TaskQueue) advance(priority TaskPriority, pass float64) {
+	weight := q.weights[priority]
+	if weight <= 0 {
+		weight = 1
+	}
+	q.vtime = pass
+	q.pass[priority] = pass + 1/float64(weight)
+}
+
+// SetPreemption 启用抢占：优先级不低于minPriority的任务因没有空闲Agent或工作协程而等待时，
+// 暂停一个优先级更低的执行中任务（取消其ctx）并放回队列，需在Start之前调用且需设置执行器
+func (s *TaskScheduler) SetPreemption(minPriority TaskPriority) {
+	s.preemption = true
+	s.preemptPriority = minPriority
+}
+
+// SetFairness 设置加权公平出队的权重，weights为空时严格按优先级出队，需在Start之前调用
+func (s *TaskScheduler) SetFairness(weights map[TaskPriority]int) {
+	s.taskQueue.SetWeights(weights)
+}
+
+// canPreempt 等待中的任务是否可以抢占优先级更低的任务
+func (s *TaskScheduler) canPreempt(task *Task) bool {
+	return s.preemption && s.executor != nil && task.Priority >= s.preemptPriority
+}
+
+// preemptionsInFlight 已选中、尚未让出Agent的被抢占任务数
+func (s *TaskScheduler) preemptionsInFlight() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, task := range s.runningTasks {
+		if task.preempted {
+			n++
+		}
+	}
+	return n
+}
+
+// preempt 为等待中的任务选一个优先级更低的任务暂停：优先选优先级最低的、正在执行的（能让出工作协程）、
+// 最晚开始的（损失的工作最少）；只选同一租户的任务，任务指定了Agent时只选该Agent上的任务。没有可抢占的任务时返回false
+func (s *TaskScheduler) preempt(task *Task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var victim *Task
+	for _, running := range s.runningTasks {
+		if running.preempted || running.Priority >= task.Priority {
+			continue
+		}
+		if running.Status != TaskStatusRunning && running.Status != TaskStatusAssigned {
+			continue
+		}
+		if task.pinnedAgent != "" && running.AssignedTo != task.pinnedAgent {
+			continue
+		}
+		if running.Tenant != task.Tenant {
+			// 只抢占同一租户的任务
+			continue
+		}
+		if victim == nil || preferVictim(running, victim) {
+			victim = running
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	victim.preempted = true
+	if victim.Status == TaskStatusRunning && victim.cancel != nil {
+		victim.cancel()
+	}
+	return true
+}
+
+// preferVictim a是否比b更适合被抢占
+func preferVictim(a, b *Task) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	if (a.Status == TaskStatusRunning) != (b.Status == TaskStatusRunning) {
+		return a.Status == TaskStatusRunning
+	}
+	if a.StartedAt != nil && b.StartedAt != nil && !a.StartedAt.Equal(*b.StartedAt) {
+		return a.StartedAt.After(*b.StartedAt)
+	}
+	return a.seq > b.seq
+}
+
+// requeuePreempted 被抢占的任务放回队列，这次执行不计入执行次数，调用方需持有s.mu
+func (s *TaskScheduler) requeuePreempted(task *Task) {
+	if task.StartedAt != nil && task.Attempts > 0 {
+		task.Attempts--
+	}
+	task.Preemptions++
+	task.preempted = false
+	task.Status = TaskStatusPending
+	task.AssignedTo = task.pinnedAgent
+	task.Error = ""
+	task.StartedAt = nil
+	task.Progress = nil
+	task.agent = nil
+	task.cancel = nil
+	s.taskQueue.Enqueue(task)
+
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
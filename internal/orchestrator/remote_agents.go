@@ -0,0 +1,230 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAgentNotConnected 远程Agent当前没有接收任务的连接
+	ErrAgentNotConnected = errors.New("remote agent is not connected")
+
+	// ErrAgentDisconnected 远程Agent在执行任务期间断开连接
+	ErrAgentDisconnected = errors.New("remote agent disconnected")
+
+	// errUnknownRemoteTask 上报结果的任务不是分配给该Agent的执行中任务
+	errUnknownRemoteTask = errors.New("task is not running on this agent")
+)
+
+// RemoteTaskEvent 下发给远程Agent的事件：分配任务或取消任务
+type RemoteTaskEvent struct {
+	Task     *Task     // 分配的任务（副本），取消事件中只有ID
+	Deadline time.Time // 任务截止时间，零值表示不限制
+	Cancel   bool      // 任务被取消或超时
+	Reason   string    // 取消原因
+}
+
+// RemoteAgents 远程Agent的任务分发
+// 远程Agent建立接收任务的连接（如gRPC流）后，Executor把分配给它的任务通过连接下发，
+// 并等待Agent通过Complete上报结果；连接断开时执行中的任务以ErrAgentDisconnected失败
+type RemoteAgents struct {
+	mu       sync.Mutex
+	registry *AgentRegistry
+	conns    map[string]*RemoteConnection // Agent名称 -> 接收任务的连接
+	calls    map[string]*remoteCall       // 任务ID -> 等待结果的执行
+}
+
+// RemoteConnection 远程Agent接收任务的连接
+type RemoteConnection struct {
+	agent  string
+	remote *RemoteAgents
+	events chan *RemoteTaskEvent
+	closed chan struct{}
+}
+
+// Events 下发给Agent的事件
+func (c *RemoteConnection) Events() <-chan *RemoteTaskEvent {
+	return c.events
+}
+
+// Done 连接被关闭或被同一Agent的新连接替换时关闭
+func (c *RemoteConnection) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Close 断开连接，Agent变为inactive，通过该连接下发的执行中任务以ErrAgentDisconnected失败
+func (c *RemoteConnection) Close() {
+	c.remote.disconnect(c, true)
+}
+
+// remoteCall 等待远程Agent结果的任务
+type remoteCall struct {
+	agent  string
+	conn   *RemoteConnection
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+// NewRemoteAgents 创建远程Agent的任务分发，连接建立和断开时更新registry中的Agent状态
+func NewRemoteAgents(registry *AgentRegistry) *RemoteAgents {
+	return &RemoteAgents{
+		registry: registry,
+		conns:    make(map[string]*RemoteConnection),
+		calls:    make(map[string]*remoteCall),
+	}
+}
+
+// Connect 远程Agent开始接收任务，连接后Agent变为active（执行中的Agent保持busy）
+// 同一Agent只保留最新的连接，旧连接被关闭，通过旧连接下发的任务失败
+func (r *RemoteAgents) Connect(name string) (*RemoteConnection, error) {
+	if _, err := r.registry.update(name, func(agent *AgentInfo) error {
+		if agent.Status != "busy" {
+			agent.Status = "active"
+		}
+		agent.LastHeartbeat = r.registry.now()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	conn := &RemoteConnection{
+		agent:  name,
+		remote: r,
+		events: make(chan *RemoteTaskEvent, 16),
+		closed: make(chan struct{}),
+	}
+	r.mu.Lock()
+	previous := r.conns[name]
+	r.conns[name] = conn
+	r.mu.Unlock()
+	if previous != nil {
+		r.disconnect(previous, false)
+	}
+
+	return conn, nil
+}
+
+// disconnect 关闭连接并让通过该连接下发的任务失败；markInactive为true时Agent变为inactive
+func (r *RemoteAgents) disconnect(conn *RemoteConnection, markInactive bool) {
+	r.mu.Lock()
+	select {
+	case <-conn.closed:
+		r.mu.Unlock()
+		return
+	default:
+	}
+	close(conn.closed)
+	current := r.conns[conn.agent] == conn
+	if current {
+		delete(r.conns, conn.agent)
+	}
+	failed := make([]*remoteCall, 0)
+	for id, call := range r.calls {
+		if call.conn == conn {
+			call.err = ErrAgentDisconnected
+			failed = append(failed, call)
+			delete(r.calls, id)
+		}
+	}
+	r.mu.Unlock()
+
+	// 先标记inactive再让任务失败，任务完成时只把busy的Agent释放为active，不会重新启用已断开的Agent
+	if markInactive && current {
+		r.registry.UpdateStatus(conn.agent, "inactive")
+	}
+	for _, call := range failed {
+		close(call.done)
+	}
+}
+
+// Connected 远程Agent是否有接收任务的连接
+func (r *RemoteAgents) Connected(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.conns[name]
+	return ok
+}
+
+// Execute 把任务下发给远程Agent并等待结果，可直接作为TaskExecutor使用
+// ctx结束（任务被取消或超时）时通知Agent取消并返回ctx的错误
+func (r *RemoteAgents) Execute(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+	r.mu.Lock()
+	conn, ok := r.conns[agent.Name]
+	if !ok {
+		r.mu.Unlock()
+		return nil, ErrAgentNotConnected
+	}
+	call := &remoteCall{agent: agent.Name, conn: conn, done: make(chan struct{})}
+	r.calls[task.ID] = call
+	r.mu.Unlock()
+
+	event := &RemoteTaskEvent{Task: task.snapshot()}
+	if deadline, ok := ctx.Deadline(); ok {
+		event.Deadline = deadline
+	}
+	select {
+	case conn.events <- event:
+	case <-conn.closed:
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		r.mu.Lock()
+		if r.calls[task.ID] == call {
+			delete(r.calls, task.ID)
+		}
+		r.mu.Unlock()
+
+		reason := "cancelled"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "timeout"
+		}
+		select {
+		case conn.events <- &RemoteTaskEvent{Task: &Task{ID: task.ID}, Cancel: true, Reason: reason}:
+		case <-conn.closed:
+		default:
+			// Agent没有及时读取事件，任务结果到达时会被忽略
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Executor 返回按Agent分发的执行器：有连接的远程Agent通过连接执行，其他Agent交给local；local为nil时返回ErrAgentNotConnected
+func (r *RemoteAgents) Executor(local TaskExecutor) TaskExecutor {
+	return func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error) {
+		if local == nil || r.Connected(agent.Name) {
+			return r.Execute(ctx, task, agent)
+		}
+		return local(ctx, task, agent)
+	}
+}
+
+// Complete 远程Agent上报任务结果，任务不是分配给该Agent的执行中任务时返回错误
+func (r *RemoteAgents) Complete(agent, taskID string, result interface{}, err error) error {
+	r.mu.Lock()
+	call, ok := r.calls[taskID]
+	if !ok || call.agent != agent {
+		r.mu.Unlock()
+		return errUnknownRemoteTask
+	}
+	delete(r.calls, taskID)
+	r.mu.Unlock()
+
+	call.result, call.err = result, err
+	close(call.done)
+	return nil
+}
+
+// Running 任务是否正在该远程Agent上执行
+func (r *RemoteAgents) Running(agent, taskID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	call, ok := r.calls[taskID]
+	return ok && call.agent == agent
+}
@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// inboxPrefix 总线接收回复的地址前缀
+const inboxPrefix = "inbox"
+
+// ReplyErrorKey 回复的Metadata中保存处理错误的键
+const ReplyErrorKey = "error"
+
+var (
+	// ErrRequestTimeout 超时未收到回复
+	ErrRequestTimeout = errors.New("request timed out waiting for reply")
+
+	// ErrRequestFailed 接收方处理请求失败，错误信息在回复的Metadata中
+	ErrRequestFailed = errors.New("request failed")
+
+	// ErrBusStopped 通信总线已停止
+	ErrBusStopped = errors.New("communication bus stopped")
+)
+
+// RequestHandler 处理请求并返回回复内容，返回错误时回复中带上错误信息
+type RequestHandler func(msg *Message) (interface{}, error)
+
+// Request 发送请求并等待匹配的回复：设置CorrelationID和ReplyTo后发送给msg.To，
+// 收到CorrelationID相同的回复、ctx结束或超过timeout（<=0时只受ctx限制）时返回。
+// 接收方处理失败时返回回复和 ErrRequestFailed；超时后才到达的回复丢弃
+func (b *CommunicationBus) Request(ctx context.Context, msg *Message, timeout time.Duration) (*Message, error) {
+	if msg.To == "" {
+		return nil, fmt.Errorf("message 'to' field is required for requests")
+	}
+	if err := b.subscribeInbox(); err != nil {
+		return nil, err
+	}
+	if msg.Type == "" {
+		msg.Type = MessageTypeRequest
+	}
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = generateMessageID()
+	}
+	msg.ReplyTo = b.inbox
+
+	reply := make(chan *Message, 1)
+	b.pendingMu.Lock()
+	b.pending[msg.CorrelationID] = reply
+	b.pendingMu.Unlock()
+	defer func() {
+		b.pendingMu.Lock()
+		delete(b.pending, msg.CorrelationID)
+		b.pendingMu.Unlock()
+	}()
+
+	if err := b.Send(msg); err != nil {
+		return nil, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case resp := <-reply:
+		if errMsg, ok := resp.Metadata[ReplyErrorKey].(string); ok && errMsg != "" {
+			return resp, fmt.Errorf("%w: %s", ErrRequestFailed, errMsg)
+		}
+		return resp, nil
+	case <-expired:
+		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.stopped:
+		return nil, ErrBusStopped
+	}
+}
+
+// Reply 回复请求：发送给请求的ReplyTo，沿用CorrelationID；handlerErr不为nil时把错误信息放入Metadata
+// 请求没有ReplyTo（不是经Request发送的）时不回复
+func (b *CommunicationBus) Reply(request *Message, from string, content interface{}, handlerErr error) error {
+	if request.ReplyTo == "" {
+		return nil
+	}
+	reply := &Message{
+		Type:          MessageTypeResponse,
+		From:          from,
+		To:            request.ReplyTo,
+		Content:       content,
+		CorrelationID: request.CorrelationID,
+	}
+	if handlerErr != nil {
+		reply.Metadata = map[string]interface{}{ReplyErrorKey: handlerErr.Error()}
+	}
+	return b.Send(reply)
+}
+
+// SubscribeRequests 订阅发给agentName的消息，handler的返回值自动作为回复发送给请求方
+// 回复发送失败时返回错误，设置了消息代理时请求会重新投递
+func (b *CommunicationBus) SubscribeRequests(agentName string, handler RequestHandler) error {
+	return b.Subscribe(agentName, func(msg *Message) error {
+		content, err := handler(msg)
+		if msg.ReplyTo == "" {
+			return err
+		}
+		return b.Reply(msg, agentName, content, err)
+	})
+}
+
+// subscribeInbox 设置了消息代理时在第一次Request前订阅本总线的回复地址
+// 回复地址只属于本进程，使用不属于消费组的订阅
+func (b *CommunicationBus) subscribeInbox() error {
+	if b.broker == nil {
+		return nil
+	}
+
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	if b.inboxSub != nil {
+		return nil
+	}
+	sub, err := b.broker.Subscribe(b.agentTopic(b.inbox), "", b.deliver)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe reply inbox: %w", err)
+	}
+	b.inboxSub = sub
+	return nil
+}
+
+// resolveReply 发给本总线回复地址的消息交给等待的Request，返回消息是否为回复
+func (b *CommunicationBus) resolveReply(msg *Message) bool {
+	if msg.To != b.inbox {
+		return false
+	}
+
+	b.pendingMu.Lock()
+	waiter, ok := b.pending[msg.CorrelationID]
+	delete(b.pending, msg.CorrelationID)
+	b.pendingMu.Unlock()
+	if ok {
+		waiter <- msg
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package orchestrator
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultTraceSize 默认记录的任务到达轨迹条数
+const defaultTraceSize = 1000
+
+// ErrInvalidScenario 模拟的容量假设不合法
+var ErrInvalidScenario = errors.New("invalid simulation scenario")
+
+// TraceEntry 任务到达轨迹中的一条，调度器在任务执行结束时记录，用于在假设的容量下回放（见Simulate）
+type TraceEntry struct {
+	ArrivedAt  time.Time    `json:"arrived_at"`
+	Priority   TaskPriority `json:"priority"`
+	Tenant     string       `json:"tenant,omitempty"`
+	DurationMs int64        `json:"duration_ms"`       // 最后一次执行的时长
+	WaitMs     *int64       `json:"wait_ms,omitempty"` // 实际的排队时间，任务重试或被抢占过时为空
+}
+
+// SimulationScenario 模拟假设的容量
+// 模拟的Agent都是共享的、能执行任意任务，每个Agent同时执行一个任务；不模拟重试、抢占和租户配额
+type SimulationScenario struct {
+	Name          string  `json:"name,omitempty"`
+	Agents        int     `json:"agents"`                   // Agent数量
+	Workers       int     `json:"workers,omitempty"`        // 工作协程数，同时执行的任务数不超过它和Agent数，0表示只受Agent数限制
+	QueueSize     int     `json:"queue_size,omitempty"`     // 等待调度的任务数上限，超出时任务被拒绝，0表示不限制
+	LoadFactor    float64 `json:"load_factor,omitempty"`    // 到达速率倍数，2表示同样的任务在一半的时间内到达，默认1
+	DurationScale float64 `json:"duration_scale,omitempty"` // 执行时长倍数，0.5表示任务执行时间减半，默认1
+}
+
+// WaitStats 排队时间（从到达到开始执行）的统计，单位毫秒
+type WaitStats struct {
+	Tasks int     `json:"tasks"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms int64   `json:"p50_ms"`
+	P95Ms int64   `json:"p95_ms"`
+	P99Ms int64   `json:"p99_ms"`
+	MaxMs int64   `json:"max_ms"`
+}
+
+// SimulationResult 一个容量假设下的模拟结果
+type SimulationResult struct {
+	Scenario       SimulationScenario   `json:"scenario"`
+	Tasks          int                  `json:"tasks"`
+	Completed      int                  `json:"completed"`
+	Rejected       int                  `json:"rejected"`         // 队列已满被拒绝的任务
+	Wait           WaitStats            `json:"wait"`             // 执行的任务的排队时间
+	ByPriority     map[string]WaitStats `json:"by_priority"`      // 按优先级（low、normal、high、urgent）的排队时间
+	Utilization    float64              `json:"utilization"`      // 执行时间占 并发上限×模拟时长 的比例
+	MaxQueueLength int                  `json:"max_queue_length"` // 等待调度的任务数峰值
+	MakespanMs     int64                `json:"makespan_ms"`      // 从第一个任务到达到最后一个任务完成的时长
+}
+
+// recordTrace 记录执行结束的任务的到达时间和执行时长，调用方需持有s.mu；未开始执行的任务不记录
+func (s *TaskScheduler) recordTrace(task *Task, completedAt time.Time) {
+	if task.StartedAt == nil || s.traceSize <= 0 {
+		return
+	}
+	entry := TraceEntry{
+		ArrivedAt:  task.CreatedAt,
+		Priority:   task.Priority,
+		Tenant:     task.Tenant,
+		DurationMs: completedAt.Sub(*task.StartedAt).Milliseconds(),
+	}
+	if task.Attempts <= 1 && task.Preemptions == 0 {
+		wait := task.StartedAt.Sub(task.CreatedAt).Milliseconds()
+		entry.WaitMs = &wait
+	}
+	s.trace = append(s.trace, entry)
+	if over := len(s.trace) - s.traceSize; over > 0 {
+		s.trace = append(s.trace[:0:0], s.trace[over:]...)
+	}
+}
+
+// Trace 记录的任务到达轨迹（副本），按到达时间排序
+func (s *TaskScheduler) Trace() []TraceEntry {
+	s.mu.RLock()
+	trace := append([]TraceEntry(nil), s.trace...)
+	s.mu.RUnlock()
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].ArrivedAt.Before(trace[j].ArrivedAt) })
+	return trace
+}
+
+// Simulate 按调度器当前的出队规则（严格优先级或加权公平）在假设的容量下回放到达轨迹
+func (s *TaskScheduler) Simulate(trace []TraceEntry, scenario SimulationScenario) (*SimulationResult, error) {
+	s.taskQueue.mu.Lock()
+	weights := make(map[TaskPriority]int, len(s.taskQueue.weights))
+	for priority, weight := range s.taskQueue.weights {
+		weights[priority] = weight
+	}
+	s.taskQueue.mu.Unlock()
+	return Simulate(trace, scenario, weights)
+}
+
+// Simulate 离散事件模拟：按到达时间把轨迹中的任务放入优先队列，有空闲的并发名额时按出队规则开始执行，
+// 执行时长为记录的时长乘以duration_scale；weights为空时严格按优先级出队，否则按权重加权公平出队
+// 结果可重复：相同的轨迹和假设总是得到相同的结果
+func Simulate(trace []TraceEntry, scenario SimulationScenario, weights map[TaskPriority]int) (*SimulationResult, error) {
+	if scenario.Agents <= 0 {
+		return nil, fmt.Errorf("%w: agents must be positive", ErrInvalidScenario)
+	}
+	if scenario.Workers < 0 || scenario.QueueSize < 0 || scenario.LoadFactor < 0 || scenario.DurationScale < 0 {
+		return nil, fmt.Errorf("%w: workers, queue_size, load_factor and duration_scale must not be negative", ErrInvalidScenario)
+	}
+	if scenario.LoadFactor == 0 {
+		scenario.LoadFactor = 1
+	}
+	if scenario.DurationScale == 0 {
+		scenario.DurationScale = 1
+	}
+	capacity := scenario.Agents
+	if scenario.Workers > 0 {
+		capacity = min(capacity, scenario.Workers)
+	}
+
+	entries := append([]TraceEntry(nil), trace...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ArrivedAt.Before(entries[j].ArrivedAt) })
+	result := &SimulationResult{Scenario: scenario, Tasks: len(entries), ByPriority: make(map[string]WaitStats)}
+	if len(entries) == 0 {
+		return result, nil
+	}
+
+	// 到达时间为相对第一个任务的偏移
+	start := entries[0].ArrivedAt
+	arrivals := make([]time.Duration, len(entries))
+	durations := make([]time.Duration, len(entries))
+	for i, entry := range entries {
+		arrivals[i] = time.Duration(float64(entry.ArrivedAt.Sub(start)) / scenario.LoadFactor)
+		durations[i] = time.Duration(float64(max(entry.DurationMs, 0)) * float64(time.Millisecond) * scenario.DurationScale)
+	}
+
+	queue := NewTaskQueue()
+	queue.SetWeights(weights)
+	running := &completionHeap{}
+	var (
+		now      time.Duration
+		busy     time.Duration
+		lastDone time.Duration
+		waits    []time.Duration
+	)
+	byPriority := make(map[TaskPriority][]time.Duration)
+	dispatch := func() {
+		for running.Len() < capacity {
+			task := queue.Dequeue()
+			if task == nil {
+				return
+			}
+			i, _ := strconv.Atoi(task.ID)
+			wait := now - arrivals[i]
+			waits = append(waits, wait)
+			byPriority[task.Priority] = append(byPriority[task.Priority], wait)
+			done := now + durations[i]
+			heap.Push(running, done)
+			busy += durations[i]
+			lastDone = max(lastDone, done)
+			result.Completed++
+		}
+	}
+
+	next := 0
+	for next < len(entries) || running.Len() > 0 {
+		// 推进到下一个事件：任务完成或任务到达，同一时刻先完成再到达
+		now = time.Duration(math.MaxInt64)
+		if next < len(entries) {
+			now = arrivals[next]
+		}
+		if running.Len() > 0 && (*running)[0] <= now {
+			now = (*running)[0]
+		}
+		for running.Len() > 0 && (*running)[0] <= now {
+			heap.Pop(running)
+		}
+		dispatch()
+
+		// 同时到达的任务全部入队后再按出队规则分配
+		for next < len(entries) && arrivals[next] <= now {
+			if scenario.QueueSize > 0 && queue.Size() >= scenario.QueueSize {
+				result.Rejected++
+			} else {
+				queue.Enqueue(&Task{ID: strconv.Itoa(next), Priority: entries[next].Priority})
+			}
+			next++
+		}
+		dispatch()
+		result.MaxQueueLength = max(result.MaxQueueLength, queue.Size())
+	}
+
+	result.Wait = newWaitStats(waits)
+	for priority, values := range byPriority {
+		result.ByPriority[priorityName(priority)] = newWaitStats(values)
+	}
+	result.MakespanMs = lastDone.Milliseconds()
+	if lastDone > 0 {
+		result.Utilization = float64(busy) / (float64(capacity) * float64(lastDone))
+	}
+	return result, nil
+}
+
+// ObservedWait 轨迹中实际排队时间的统计，用于与模拟结果对照
+func ObservedWait(trace []TraceEntry) WaitStats {
+	waits := make([]time.Duration, 0, len(trace))
+	for _, entry := range trace {
+		if entry.WaitMs != nil {
+			waits = append(waits, time.Duration(*entry.WaitMs)*time.Millisecond)
+		}
+	}
+	return newWaitStats(waits)
+}
+
+// newWaitStats 计算排队时间的平均值、分位数和最大值
+func newWaitStats(waits []time.Duration) WaitStats {
+	stats := WaitStats{Tasks: len(waits)}
+	if len(waits) == 0 {
+		return stats
+	}
+	sorted := append([]time.Duration(nil), waits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, wait := range sorted {
+		total += wait
+	}
+	percentile := func(p float64) int64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)].Milliseconds()
+	}
+	stats.AvgMs = float64(total) / float64(len(sorted)) / float64(time.Millisecond)
+	stats.P50Ms = percentile(0.50)
+	stats.P95Ms = percentile(0.95)
+	stats.P99Ms = percentile(0.99)
+	stats.MaxMs = sorted[len(sorted)-1].Milliseconds()
+	return stats
+}
+
+// priorityName 优先级的名称，与ParseTaskPriority对应
+func priorityName(priority TaskPriority) string {
+	switch priority {
+	case TaskPriorityLow:
+		return "low"
+	case TaskPriorityNormal:
+		return "normal"
+	case TaskPriorityHigh:
+		return "high"
+	case TaskPriorityUrgent:
+		return "urgent"
+	}
+	return strconv.Itoa(int(priority))
+}
+
+// completionHeap 模拟中执行中任务的完成时间，最早完成的在堆顶
+type completionHeap []time.Duration
+
+func (h completionHeap) Len() int            { return len(h) }
+func (h completionHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h completionHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *completionHeap) Push(x interface{}) { *h = append(*h, x.(time.Duration)) }
+func (h *completionHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -2,11 +2,41 @@ package orchestrator
 
 import (
 	"container/heap"
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// 调度器默认参数，可通过 scheduler 配置段覆盖
+const (
+	defaultPollInterval = time.Second
+	defaultMaxRetries   = 3
+	defaultWorkers      = 4
+	defaultQueueSize    = 1000
+)
+
+// ErrQueueFull 等待调度的任务数已达上限
+var ErrQueueFull = errors.New("task queue is full")
+
+var (
+	// ErrTaskNotFound 任务不在调度器中：未提交或已结束
+	ErrTaskNotFound = errors.New("task not found in scheduler")
+
+	// ErrTaskNotCancellable 任务当前的状态不能取消
+	ErrTaskNotCancellable = errors.New("task cannot be cancelled in current state")
 )
 
+// TaskExecutor 在工作协程中执行已分配给Agent的任务，ctx在任务取消、超时或调度器停止时结束
+type TaskExecutor func(ctx context.Context, task *Task, agent *AgentInfo) (interface{}, error)
+
 // TaskStatus 任务状态
 type TaskStatus string
 
@@ -31,27 +61,59 @@ const (
 
 // Task 任务定义
 type Task struct {
-	ID          string                 `json:"id"`
-	Type        string                 `json:"type"`        // single, composite, workflow
-	Goal        string                 `json:"goal"`        // 任务目标
-	Requirements map[string]interface{} `json:"requirements"` // 任务要求
-	Priority    TaskPriority           `json:"priority"`    // 优先级
-	Status      TaskStatus             `json:"status"`      // 状态
-	AssignedTo  string                 `json:"assigned_to"` // 分配给的Agent
-	CreatedAt   time.Time              `json:"created_at"`
-	StartedAt   *time.Time             `json:"started_at,omitempty"`
-	CompletedAt *time.Time             `json:"completed_at,omitempty"`
-	Result      interface{}            `json:"result,omitempty"`
-	Error       string                 `json:"error,omitempty"`
-	RetryCount  int                    `json:"retry_count"`
-	MaxRetries  int                    `json:"max_retries"`
-	Metadata    map[string]interface{} `json:"metadata"`
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`         // single, composite, workflow
+	Goal          string                 `json:"goal"`         // 任务目标
+	Requirements  map[string]interface{} `json:"requirements"` // 任务要求
+	Priority      TaskPriority           `json:"priority"`     // 优先级
+	Status        TaskStatus             `json:"status"`       // 状态
+	AssignedTo    string                 `json:"assigned_to"`  // 分配给的Agent
+	CreatedAt     time.Time              `json:"created_at"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"`
+	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
+	Result        interface{}            `json:"result,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	RetryCount    int                    `json:"retry_count"`
+	MaxRetries    int                    `json:"max_retries"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	Progress      *TaskProgress          `json:"progress,omitempty"`        // Agent上报的最新进度
+	Attempts      int                    `json:"attempts"`                  // 已执行次数
+	RetryPolicy   *RetryPolicy           `json:"retry_policy,omitempty"`    // 执行失败后的重试策略，为空时使用调度器的默认策略
+	NextAttemptAt *time.Time             `json:"next_attempt_at,omitempty"` // 等待重试的任务下一次执行的时间
+	Preemptions   int                    `json:"preemptions,omitempty"`     // 被更高优先级的任务抢占、放回队列的次数
+	Tenant        string                 `json:"tenant,omitempty"`          // 提交任务的租户，只分配给对该租户可见的Agent
+
+	agent       *AgentInfo
+	cancel      context.CancelFunc // 执行中的任务的取消函数
+	pinnedAgent string             // 提交时指定的Agent，重试时仍分配给它
+	preempted   bool               // 已被选中抢占，让出Agent后放回队列
+	seq         uint64             // 入队顺序，同优先级先入先出
+}
+
+// TaskProgress 任务执行进度，每次上报替换为新的值
+type TaskProgress struct {
+	Percent   float64   `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// snapshot 任务的副本，工作协程会并发更新运行中的任务，查询时返回副本
+func (t *Task) snapshot() *Task {
+	c := *t
+	c.agent = nil
+	c.cancel = nil
+	return &c
 }
 
 // TaskQueue 任务队列（优先队列）
+// 默认严格按优先级出队，同优先级先入先出；设置权重后按优先级加权公平出队
 type TaskQueue struct {
-	items []*Task
-	mu    sync.Mutex
+	items   []*Task
+	mu      sync.Mutex
+	nextSeq uint64
+	weights map[TaskPriority]int     // 加权公平模式下各优先级的权重，nil表示严格按优先级
+	pass    map[TaskPriority]float64 // 各优先级累计的虚拟时间，每出队一个任务增加1/权重
+	vtime   float64                  // 最近出队的任务的虚拟时间
 }
 
 // NewTaskQueue 创建任务队列
@@ -70,8 +132,11 @@ func (q *TaskQueue) Len() int {
 
 // Less 实现heap.Interface
 func (q *TaskQueue) Less(i, j int) bool {
-	// 优先级高的排在前面
-	return q.items[i].Priority > q.items[j].Priority
+	// 优先级高的排在前面，同优先级先入队的排在前面
+	if q.items[i].Priority != q.items[j].Priority {
+		return q.items[i].Priority > q.items[j].Priority
+	}
+	return q.items[i].seq < q.items[j].seq
 }
 
 // Swap 实现heap.Interface
@@ -95,6 +160,15 @@ func (q *TaskQueue) Pop() interface{} {
 
 // Enqueue 入队
 func (q *TaskQueue) Enqueue(task *Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextSeq++
+	task.seq = q.nextSeq
+	heap.Push(q, task)
+}
+
+// requeue 放回暂时无法分配的任务，保留原来的入队顺序
+func (q *TaskQueue) requeue(task *Task) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	heap.Push(q, task)
@@ -107,6 +181,11 @@ func (q *TaskQueue) Dequeue() *Task {
 	if q.Len() == 0 {
 		return nil
 	}
+	if q.weights != nil {
+		i, priority, pass := q.fairIndex()
+		q.advance(priority, pass)
+		return heap.Remove(q, i).(*Task)
+	}
 	return heap.Pop(q).(*Task)
 }
 
@@ -117,9 +196,25 @@ func (q *TaskQueue) Peek() *Task {
 	if q.Len() == 0 {
 		return nil
 	}
+	if q.weights != nil {
+		i, _, _ := q.fairIndex()
+		return q.items[i]
+	}
 	return q.items[0]
 }
 
+// remove 移除指定ID的任务，不在队列中时返回nil
+func (q *TaskQueue) remove(taskID string) *Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, task := range q.items {
+		if task.ID == taskID {
+			return heap.Remove(q, i).(*Task)
+		}
+	}
+	return nil
+}
+
 // Size 队列大小
 func (q *TaskQueue) Size() int {
 	q.mu.Lock()
@@ -127,50 +222,267 @@ func (q *TaskQueue) Size() int {
 	return q.Len()
 }
 
+// List 队列中全部任务的副本，按优先级从高到低、同优先级按入队顺序排序
+func (q *TaskQueue) List() []*Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := make([]*Task, 0, len(q.items))
+	for _, task := range q.items {
+		tasks = append(tasks, task.snapshot())
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Priority != tasks[j].Priority {
+			return tasks[i].Priority > tasks[j].Priority
+		}
+		return tasks[i].seq < tasks[j].seq
+	})
+	return tasks
+}
+
 // TaskScheduler 任务调度器
+// 调度协程按优先级把任务分配给Agent，设置了执行器时交给固定数量的工作协程执行，
+// 分配后的任务经有界通道传给工作协程，工作协程都忙时停止分配，任务留在优先队列中
 type TaskScheduler struct {
-	registry      *AgentRegistry
-	taskQueue     *TaskQueue
-	runningTasks  map[string]*Task // task_id -> task
-	mu            sync.RWMutex
-	stopCh        chan struct{}
-	workerStopped chan struct{}
+	registry        *AgentRegistry
+	taskQueue       *TaskQueue
+	runningTasks    map[string]*Task // task_id -> task
+	mu              sync.RWMutex
+	stopCh          chan struct{}
+	workerStopped   chan struct{}
+	pollInterval    time.Duration  // 从队列取任务的间隔
+	maxRetries      int            // 任务未设置MaxRetries时的重试次数
+	workers         int            // 执行任务的工作协程数
+	queueSize       int            // 等待调度的任务数上限
+	taskTimeout     time.Duration  // 单个任务的最长执行时间，0表示不限制
+	retryPolicy     RetryPolicy    // 任务未设置RetryPolicy时的重试策略
+	delayed         []*Task        // 等待退避时间后重试的任务
+	deadLetters     []*DeadLetter  // 用完重试次数仍失败的任务，按进入时间排序
+	deadLetterSize  int            // 死信队列保留的任务数上限
+	trace           []TraceEntry   // 执行结束的任务的到达轨迹，按结束顺序，用于容量模拟
+	traceSize       int            // 保留的轨迹条数上限，0表示不记录
+	preemption      bool           // 是否允许高优先级任务抢占执行中的低优先级任务
+	preemptPriority TaskPriority   // 可以抢占其他任务的最低优先级
+	quotas          *tenant.Quotas // 每个租户等待和执行中的任务数上限，nil表示不限制
+	elector         *LeaderElector // 启用选主时只有主实例分配任务，nil表示总是分配
+	executor        TaskExecutor
+	dispatch        chan *Task    // 已分配待执行的任务
+	wakeup          chan struct{} // 提交任务后立即触发一次调度
+	clock           clock.Clock   // 任务的创建、开始和完成时间
+	rand            *clock.Rand   // 未指定Agent时从空闲Agent中随机选择
+	ctx             context.Context
+	cancel          context.CancelFunc
+	workerWG        sync.WaitGroup
 }
 
 // NewTaskScheduler 创建任务调度器
 func NewTaskScheduler(registry *AgentRegistry) *TaskScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &TaskScheduler{
 		registry:      registry,
 		taskQueue:     NewTaskQueue(),
 		runningTasks:  make(map[string]*Task),
 		stopCh:        make(chan struct{}),
 		workerStopped: make(chan struct{}),
+		pollInterval:  defaultPollInterval,
+		maxRetries:    defaultMaxRetries,
+		workers:       defaultWorkers,
+		queueSize:     defaultQueueSize,
+		retryPolicy: RetryPolicy{
+			MaxAttempts: 1,
+			Backoff:     defaultRetryBackoff,
+			MaxBackoff:  defaultRetryMaxBackoff,
+			Multiplier:  defaultRetryMultiplier,
+			Jitter:      defaultRetryJitter,
+		},
+		deadLetterSize: defaultDeadLetterSize,
+		traceSize:      defaultTraceSize,
+		wakeup:         make(chan struct{}, 1),
+		clock:          clock.System,
+		rand:           clock.NewRand(0),
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 }
 
+// NewTaskSchedulerFromConfig 根据配置创建任务调度器，未设置或无效的配置项使用默认值
+func NewTaskSchedulerFromConfig(registry *AgentRegistry, cfg config.SchedulerConfig) *TaskScheduler {
+	s := NewTaskScheduler(registry)
+	if d, err := time.ParseDuration(cfg.PollInterval); err == nil && d > 0 {
+		s.pollInterval = d
+	}
+	if cfg.MaxRetries > 0 {
+		s.maxRetries = cfg.MaxRetries
+	}
+	if cfg.Workers > 0 {
+		s.workers = cfg.Workers
+	}
+	if cfg.QueueSize > 0 {
+		s.queueSize = cfg.QueueSize
+	}
+	if d, err := time.ParseDuration(cfg.TaskTimeout); err == nil && d > 0 {
+		s.taskTimeout = d
+	}
+	if cfg.Retry.MaxAttempts > 0 {
+		s.retryPolicy.MaxAttempts = cfg.Retry.MaxAttempts
+	}
+	if d, err := time.ParseDuration(cfg.Retry.Backoff); err == nil && d > 0 {
+		s.retryPolicy.Backoff = d
+	}
+	if d, err := time.ParseDuration(cfg.Retry.MaxBackoff); err == nil && d > 0 {
+		s.retryPolicy.MaxBackoff = d
+	}
+	if cfg.Retry.Multiplier >= 1 {
+		s.retryPolicy.Multiplier = cfg.Retry.Multiplier
+	}
+	if cfg.Retry.Jitter > 0 && cfg.Retry.Jitter <= 1 {
+		s.retryPolicy.Jitter = cfg.Retry.Jitter
+	}
+	if cfg.DeadLetterSize > 0 {
+		s.deadLetterSize = cfg.DeadLetterSize
+	}
+	if cfg.TraceSize > 0 {
+		s.traceSize = cfg.TraceSize
+	}
+	if cfg.Preemption.Enabled {
+		minPriority, ok := ParseTaskPriority(cfg.Preemption.MinPriority)
+		if !ok {
+			minPriority = TaskPriorityHigh
+		}
+		s.SetPreemption(minPriority)
+	}
+	if strings.EqualFold(cfg.Fairness.Mode, "weighted") {
+		weights := make(map[TaskPriority]int, len(DefaultFairnessWeights))
+		for priority, weight := range DefaultFairnessWeights {
+			weights[priority] = weight
+		}
+		for name, weight := range cfg.Fairness.Weights {
+			if priority, ok := ParseTaskPriority(name); ok && weight > 0 {
+				weights[priority] = weight
+			}
+		}
+		s.SetFairness(weights)
+	}
+	return s
+}
+
+// SetExecutor 设置任务执行器，需在Start之前调用；未设置时任务只分配给Agent，由调用方通过CompleteTask报告结果
+func (s *TaskScheduler) SetExecutor(executor TaskExecutor) {
+	s.executor = executor
+}
+
+// SetClock 设置时钟，需在Start之前调用；测试和模拟中使用clock.Fake使任务时间可复现
+func (s *TaskScheduler) SetClock(c clock.Clock) {
+	s.clock = clock.OrSystem(c)
+}
+
+// SetSeed 设置选择Agent的随机种子，需在Start之前调用；种子相同且提交顺序相同时分配结果相同，0表示使用当前时间
+func (s *TaskScheduler) SetSeed(seed int64) {
+	s.rand = clock.NewRand(seed)
+}
+
 // Start 启动调度器
 func (s *TaskScheduler) Start() {
+	if s.executor != nil {
+		s.dispatch = make(chan *Task, s.workers)
+		s.workerWG.Add(s.workers)
+		for i := 0; i < s.workers; i++ {
+			go s.runWorker()
+		}
+	}
 	go s.worker()
 }
 
-// Stop 停止调度器
+// Stop 停止调度器，取消执行中的任务并等待工作协程退出
 func (s *TaskScheduler) Stop() {
 	close(s.stopCh)
 	<-s.workerStopped
+	s.cancel()
+	if s.dispatch != nil {
+		close(s.dispatch)
+		s.workerWG.Wait()
+	}
 }
 
-// Submit 提交任务
+// Submit 提交任务，等待调度的任务数达到上限时返回 ErrQueueFull，
+// 任务所属租户等待和执行中的任务数达到配额时返回 tenant.ErrQuotaExceeded，启用选主且本实例不是主实例时返回 ErrNotLeader
 func (s *TaskScheduler) Submit(task *Task) error {
-	task.CreatedAt = time.Now()
+	if !s.elector.IsLeader() {
+		return ErrNotLeader
+	}
+	if s.taskQueue.Size() >= s.queueSize {
+		return ErrQueueFull
+	}
+	if err := s.quotas.Check(task.Tenant, tenant.ResourceTasks, s.tenantTasks(task.Tenant)); err != nil {
+		return err
+	}
+	task.CreatedAt = s.clock.Now()
 	task.Status = TaskStatusPending
+	task.pinnedAgent = task.AssignedTo
 	if task.MaxRetries == 0 {
-		task.MaxRetries = 3
+		task.MaxRetries = s.maxRetries
 	}
 
 	s.taskQueue.Enqueue(task)
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
+// PendingTasks 等待调度的任务（包括等待重试的任务）的副本，用于运行时快照
+func (s *TaskScheduler) PendingTasks() []*Task {
+	tasks := s.taskQueue.List()
+	s.mu.RLock()
+	for _, task := range s.delayed {
+		tasks = append(tasks, task.snapshot())
+	}
+	s.mu.RUnlock()
+	return tasks
+}
+
+// Restore 把快照中的等待任务放回队列，保留任务ID、创建时间和重试次数；已在队列中的任务跳过
+// 返回放回的任务数，队列已满时返回 ErrQueueFull
+func (s *TaskScheduler) Restore(tasks []*Task) (int, error) {
+	queued := make(map[string]bool)
+	for _, task := range s.taskQueue.List() {
+		queued[task.ID] = true
+	}
+
+	restored := 0
+	for _, task := range tasks {
+		if queued[task.ID] {
+			continue
+		}
+		if s.taskQueue.Size() >= s.queueSize {
+			return restored, ErrQueueFull
+		}
+		task = task.snapshot()
+		if task.CreatedAt.IsZero() {
+			task.CreatedAt = s.clock.Now()
+		}
+		task.Status = TaskStatusPending
+		task.AssignedTo = ""
+		task.StartedAt = nil
+		task.NextAttemptAt = nil
+		if task.MaxRetries == 0 {
+			task.MaxRetries = s.maxRetries
+		}
+		s.taskQueue.Enqueue(task)
+		queued[task.ID] = true
+		restored++
+	}
+
+	if restored > 0 {
+		select {
+		case s.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	return restored, nil
+}
+
 // GetTask 获取任务信息
 func (s *TaskScheduler) GetTask(taskID string) (*Task, error) {
 	s.mu.RLock()
@@ -178,36 +490,67 @@ func (s *TaskScheduler) GetTask(taskID string) (*Task, error) {
 
 	// 先在运行任务中查找
 	if task, exists := s.runningTasks[taskID]; exists {
-		return task, nil
+		return task.snapshot(), nil
 	}
 
 	// 在队列中查找（需要遍历队列）
 	// 注意：这里简化处理，实际应用中可能需要维护一个所有任务的映射
 
-	return nil, fmt.Errorf("task %s not found in running tasks", taskID)
+	return nil, fmt.Errorf("%w: %s is not running", ErrTaskNotFound, taskID)
 }
 
-// Cancel 取消任务
+// Cancel 取消任务：执行中的任务取消其ctx，已分配未执行的任务不再执行，
+// 等待调度和等待重试的任务直接移出队列；已结束或不在本调度器中的任务返回 ErrTaskNotFound
 func (s *TaskScheduler) Cancel(taskID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if task, exists := s.runningTasks[taskID]; exists {
-		if task.Status == TaskStatusRunning {
+		switch task.Status {
+		case TaskStatusRunning:
 			task.Status = TaskStatusCancelled
+			if task.cancel != nil {
+				task.cancel()
+			}
+			return nil
+		case TaskStatusAssigned:
+			if s.executor == nil {
+				break
+			}
+			task.Status = TaskStatusCancelled
+			return nil
+		}
+		return fmt.Errorf("%w: task %s is %s", ErrTaskNotCancellable, taskID, task.Status)
+	}
+
+	for i, task := range s.delayed {
+		if task.ID == taskID {
+			s.delayed = append(s.delayed[:i], s.delayed[i+1:]...)
+			s.cancelWaiting(task)
 			return nil
 		}
-		return fmt.Errorf("task %s cannot be cancelled in current state", taskID)
+	}
+	if task := s.taskQueue.remove(taskID); task != nil {
+		s.cancelWaiting(task)
+		return nil
 	}
 
-	return fmt.Errorf("task %s not found in running tasks", taskID)
+	return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+}
+
+// cancelWaiting 结束移出队列的等待任务，调用方需持有s.mu
+func (s *TaskScheduler) cancelWaiting(task *Task) {
+	now := s.clock.Now()
+	task.Status = TaskStatusCancelled
+	task.NextAttemptAt = nil
+	task.CompletedAt = &now
 }
 
 // worker 调度工作协程
 func (s *TaskScheduler) worker() {
 	defer close(s.workerStopped)
 
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(s.pollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -216,14 +559,76 @@ func (s *TaskScheduler) worker() {
 			return
 		case <-ticker.C:
 			s.scheduleTasks()
+		case <-s.wakeup:
+			s.scheduleTasks()
 		}
 	}
 }
 
-// scheduleTasks 调度任务
+// runWorker 执行任务的工作协程，dispatch关闭后退出
+func (s *TaskScheduler) runWorker() {
+	defer s.workerWG.Done()
+	for task := range s.dispatch {
+		s.execute(task)
+	}
+}
+
+// execute 在任务自己的ctx中执行，完成后释放Agent
+func (s *TaskScheduler) execute(task *Task) {
+	s.mu.Lock()
+	if task.Status != TaskStatusAssigned || task.preempted || s.ctx.Err() != nil {
+		// 等待执行期间任务被取消、被抢占或调度器已停止
+		s.mu.Unlock()
+		s.CompleteTask(task.ID, nil, context.Canceled)
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	if s.taskTimeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, s.taskTimeout)
+	}
+	now := s.clock.Now()
+	task.Status = TaskStatusRunning
+	task.StartedAt = &now
+	task.Attempts++
+	task.cancel = cancel
+	agent := task.agent
+	s.mu.Unlock()
+	defer cancel()
+
+	result, err := s.executor(ctx, task, agent)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	s.CompleteTask(task.ID, result, err)
+}
+
+// scheduleTasks 调度任务，不是主实例时不分配，任务留在队列中
 func (s *TaskScheduler) scheduleTasks() {
+	if !s.elector.IsLeader() {
+		return
+	}
+	s.promoteDueRetries()
+
+	// 本轮分配不到Agent的任务（包括等待被抢占的任务让出Agent的任务）在本轮结束后放回队列，下一轮再分配
+	var deferred []*Task
+	inFlight := s.preemptionsInFlight()
+	preempting := 0
+	defer func() {
+		for _, task := range deferred {
+			s.taskQueue.requeue(task)
+		}
+	}()
+
 	// 从队列中取出任务
 	for {
+		// 工作协程都忙时停止分配，避免Agent被还在等待执行的任务占用；
+		// 队首任务可以抢占时暂停一个低优先级任务让出工作协程
+		if s.dispatch != nil && len(s.dispatch) == cap(s.dispatch) {
+			if head := s.taskQueue.Peek(); head != nil && s.canPreempt(head) && inFlight == 0 {
+				s.preempt(head)
+			}
+			return
+		}
 		task := s.taskQueue.Dequeue()
 		if task == nil {
 			break
@@ -231,13 +636,22 @@ func (s *TaskScheduler) scheduleTasks() {
 
 		// 分配任务给Agent
 		if err := s.assignTask(task); err != nil {
-			// 分配失败，重新入队
+			// 没有空闲Agent时可抢占的任务等待低优先级任务让出Agent，不计入分配失败次数
+			if s.canPreempt(task) && (preempting < inFlight || s.preempt(task)) {
+				preempting++
+				deferred = append(deferred, task)
+				continue
+			}
+
+			// 分配失败，下一轮重试
 			task.RetryCount++
 			if task.RetryCount < task.MaxRetries {
-				s.taskQueue.Enqueue(task)
+				deferred = append(deferred, task)
 			} else {
-				task.Status = TaskStatusFailed
+				s.mu.Lock()
 				task.Error = fmt.Sprintf("Failed to assign after %d retries: %v", task.RetryCount, err)
+				s.deadLetter(task, DeadLetterAssignmentFailed)
+				s.mu.Unlock()
 			}
 		}
 	}
@@ -245,40 +659,47 @@ func (s *TaskScheduler) scheduleTasks() {
 
 // assignTask 分配任务给Agent
 func (s *TaskScheduler) assignTask(task *Task) error {
-	// 查找合适的Agent
-	var agent *AgentInfo
-	var err error
-
-	if task.AssignedTo != "" {
-		// 指定了Agent
-		agent, err = s.registry.Get(task.AssignedTo)
-		if err != nil {
-			return err
-		}
-		if agent.Status != "active" {
-			return fmt.Errorf("agent %s is not active", agent.Name)
-		}
-	} else {
-		// 自动选择Agent
-		agent, err = s.registry.GetIdleAgent()
-		if err != nil {
-			return err
-		}
+	// 查找合适的Agent并标记为busy；未指定Agent时自动选择
+	agent, err := s.registry.claim(task.Tenant, task.AssignedTo, s.rand.Intn)
+	if err != nil {
+		return err
 	}
 
 	// 分配任务
 	s.mu.Lock()
 	task.Status = TaskStatusAssigned
 	task.AssignedTo = agent.Name
+	task.agent = agent
 	s.runningTasks[task.ID] = task
 	s.mu.Unlock()
 
-	// 更新Agent状态
-	s.registry.UpdateStatus(agent.Name, "busy")
+	// 交给工作协程执行；未设置执行器时由调用方执行并调用CompleteTask
+	if s.dispatch != nil {
+		s.dispatch <- task
+	}
 
-	// 执行任务（这里只是标记，实际执行在其他地方）
-	// TODO: 触发Agent执行任务
+	return nil
+}
 
+// UpdateProgress 记录执行中任务的进度，percent限制在0-100之间
+func (s *TaskScheduler) UpdateProgress(taskID string, percent float64, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, exists := s.runningTasks[taskID]
+	if !exists || task.Status != TaskStatusRunning {
+		return fmt.Errorf("task %s is not running", taskID)
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	task.Progress = &TaskProgress{
+		Percent:   percent,
+		Message:   message,
+		UpdatedAt: s.clock.Now(),
+	}
 	return nil
 }
 
@@ -292,24 +713,41 @@ func (s *TaskScheduler) CompleteTask(taskID string, result interface{}, err erro
 		return
 	}
 
-	now := time.Now()
-	task.CompletedAt = &now
+	// 释放Agent并从运行任务中移除
+	if task.AssignedTo != "" {
+		s.registry.release(task.AssignedTo)
+	}
+	delete(s.runningTasks, taskID)
+
+	// 被抢占的任务放回队列；抢占生效前已执行完或被取消的任务照常结束
+	if task.preempted && err != nil && task.Status != TaskStatusCancelled {
+		s.requeuePreempted(task)
+		return
+	}
 
 	if err != nil {
-		task.Status = TaskStatusFailed
 		task.Error = err.Error()
-	} else {
+		if s.shouldRetry(task, err) {
+			s.scheduleRetry(task)
+			return
+		}
+	}
+
+	now := s.clock.Now()
+	task.CompletedAt = &now
+	switch {
+	case err == nil:
 		task.Status = TaskStatusCompleted
 		task.Result = result
+	case task.Status == TaskStatusCancelled || errors.Is(err, context.Canceled):
+		task.Status = TaskStatusCancelled
+	default:
+		// 执行失败且不再重试的任务进入死信队列，可检查、重新提交或删除
+		s.deadLetter(task, DeadLetterExecutionFailed)
 	}
-
-	// 释放Agent
-	if task.AssignedTo != "" {
-		s.registry.UpdateStatus(task.AssignedTo, "active")
+	if task.Status != TaskStatusCancelled {
+		s.recordTrace(task, now)
 	}
-
-	// 从运行任务中移除
-	delete(s.runningTasks, taskID)
 }
 
 // GetQueueSize 获取队列大小
@@ -317,14 +755,14 @@ func (s *TaskScheduler) GetQueueSize() int {
 	return s.taskQueue.Size()
 }
 
-// GetRunningTasks 获取运行中的任务
+// GetRunningTasks 获取运行中的任务（副本）
 func (s *TaskScheduler) GetRunningTasks() []*Task {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	tasks := make([]*Task, 0, len(s.runningTasks))
 	for _, task := range s.runningTasks {
-		tasks = append(tasks, task)
+		tasks = append(tasks, task.snapshot())
 	}
 	return tasks
 }
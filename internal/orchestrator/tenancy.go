@@ -0,0 +1,119 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+
+	"ai-agent-assistant/internal/tenant"
+)
+
+// visibleTo Agent对租户是否可见：未绑定租户的Agent（如进程内的专家Agent）由所有租户共享，
+// 绑定了租户的Agent只对该租户可见；tenantID为空（未启用多租户）时只能看到共享的Agent
+func (a *AgentInfo) visibleTo(tenantID string) bool {
+	return a.Tenant == "" || a.Tenant == tenantID
+}
+
+// SetQuotas 设置租户配额，限制每个租户注册的Agent数；nil表示不限制
+func (r *AgentRegistry) SetQuotas(quotas *tenant.Quotas) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quotas = quotas
+}
+
+// checkQuota 为租户注册名为name的Agent前检查配额，同名Agent重新注册不占用新的配额
+func (r *AgentRegistry) checkQuota(agent *AgentInfo) error {
+	r.mu.RLock()
+	quotas := r.quotas
+	r.mu.RUnlock()
+	if quotas == nil || agent.Tenant == "" {
+		return nil
+	}
+
+	used := 0
+	for _, existing := range r.List() {
+		if existing.Tenant == agent.Tenant && existing.Name != agent.Name {
+			used++
+		}
+	}
+	return quotas.Check(agent.Tenant, tenant.ResourceAgents, used)
+}
+
+// ListForTenant 列出对租户可见的Agent：共享的Agent和该租户注册的Agent
+func (r *AgentRegistry) ListForTenant(tenantID string) []*AgentInfo {
+	return r.filter(func(agent *AgentInfo) bool { return agent.visibleTo(tenantID) })
+}
+
+// CountForTenant 统计租户注册的Agent数，不含共享的Agent
+func (r *AgentRegistry) CountForTenant(tenantID string) int {
+	return len(r.filter(func(agent *AgentInfo) bool { return agent.Tenant == tenantID }))
+}
+
+// GetForTenant 按客户端使用的名称获取对租户可见的Agent：先查找租户内的同名Agent（注册名为 "租户::名称"），
+// 再查找共享的Agent；其他租户的Agent按不存在处理
+func (r *AgentRegistry) GetForTenant(tenantID, name string) (*AgentInfo, error) {
+	if tenantID != "" {
+		agent, err := r.Get(tenant.ScopeTo(tenantID, name))
+		if err == nil && agent.visibleTo(tenantID) {
+			return agent, nil
+		}
+		if err != nil && !errors.Is(err, ErrAgentNotFound) {
+			return nil, err
+		}
+	}
+
+	agent, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if !agent.visibleTo(tenantID) {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	return agent, nil
+}
+
+// activeAgentsFor 对租户可见的活跃Agent（按名称排序）
+func (r *AgentRegistry) activeAgentsFor(tenantID string) []*AgentInfo {
+	return r.filter(func(agent *AgentInfo) bool {
+		return agent.Status == "active" && agent.visibleTo(tenantID)
+	})
+}
+
+// FindBestAgentFor 在对租户可见的活跃Agent中查找最合适的Agent，选择规则同FindBestAgent
+func (r *AgentRegistry) FindBestAgentFor(tenantID string, requiredCapabilities []string) (*AgentInfo, error) {
+	candidates := r.RankAgentsFor(tenantID, requiredCapabilities)
+	if len(candidates) == 0 || candidates[0].Matched == 0 {
+		return nil, fmt.Errorf("no agent found with required capabilities")
+	}
+	return candidates[0].Agent, nil
+}
+
+// SetQuotas 设置租户配额，限制每个租户等待和执行中的任务数（包括等待重试的任务）；nil表示不限制
+func (s *TaskScheduler) SetQuotas(quotas *tenant.Quotas) {
+	s.quotas = quotas
+}
+
+// tenantTasks 租户等待、等待重试和执行中的任务数
+func (s *TaskScheduler) tenantTasks(tenantID string) int {
+	if tenantID == "" || s.quotas == nil {
+		return 0
+	}
+	n := 0
+	for _, task := range s.taskQueue.List() {
+		if task.Tenant == tenantID {
+			n++
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, task := range s.delayed {
+		if task.Tenant == tenantID {
+			n++
+		}
+	}
+	for _, task := range s.runningTasks {
+		if task.Tenant == tenantID {
+			n++
+		}
+	}
+	return n
+}
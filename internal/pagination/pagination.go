@@ -0,0 +1,263 @@
+package pagination
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultLimit 未指定limit时每页的条数
+	DefaultLimit = 50
+	// MaxLimit 每页条数上限
+	MaxLimit = 200
+)
+
+// Field 可排序和过滤的字段，返回条目中该字段的值
+type Field[T any] func(item T) interface{}
+
+// Spec 列表接口支持的字段和默认排序
+//
+// 查询参数约定：
+//   - limit/offset：分页，limit默认50，最大200
+//   - sort：排序字段，前缀"-"表示倒序，如 sort=-created_at
+//   - <字段名>=<值>：按字段过滤，逗号分隔多个值表示任一匹配；切片字段包含任一值即匹配
+type Spec[T any] struct {
+	Fields      map[string]Field[T]
+	DefaultSort string // 为空时保持原顺序
+}
+
+// Query 解析后的列表参数
+type Query struct {
+	Limit   int
+	Offset  int
+	Sort    string
+	Desc    bool
+	Filters map[string][]string
+}
+
+// Page 一页结果
+type Page[T any] struct {
+	Items  []T
+	Total  int // 过滤后、分页前的条数
+	Limit  int
+	Offset int
+}
+
+// MapFields 条目为map时按键取值的字段
+func MapFields(keys ...string) map[string]Field[map[string]interface{}] {
+	fields := make(map[string]Field[map[string]interface{}], len(keys))
+	for _, key := range keys {
+		key := key
+		fields[key] = func(item map[string]interface{}) interface{} { return item[key] }
+	}
+	return fields
+}
+
+// Parse 解析分页、排序和过滤参数，参数不合法时返回validation_error
+func (s Spec[T]) Parse(c *gin.Context) (Query, error) {
+	q := Query{Limit: DefaultLimit, Filters: make(map[string][]string)}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return q, apierror.New(apierror.CodeValidation, "limit must be a positive integer").
+				WithDetails(gin.H{"limit": value})
+		}
+		q.Limit = min(limit, MaxLimit)
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return q, apierror.New(apierror.CodeValidation, "offset must be a non-negative integer").
+				WithDetails(gin.H{"offset": value})
+		}
+		q.Offset = offset
+	}
+
+	sortBy := c.DefaultQuery("sort", s.DefaultSort)
+	if sortBy != "" {
+		q.Sort, q.Desc = strings.TrimPrefix(sortBy, "-"), strings.HasPrefix(sortBy, "-")
+		if _, ok := s.Fields[q.Sort]; !ok {
+			return q, apierror.New(apierror.CodeValidation, "unsupported sort field: "+q.Sort).
+				WithDetails(gin.H{"sortable": s.fieldNames()})
+		}
+	}
+
+	for name := range s.Fields {
+		if value := c.Query(name); value != "" {
+			q.Filters[name] = strings.Split(value, ",")
+		}
+	}
+	return q, nil
+}
+
+// Apply 对条目过滤、排序并截取一页，不修改传入的切片
+func (s Spec[T]) Apply(items []T, q Query) Page[T] {
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if s.matches(item, q.Filters) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	if field, ok := s.Fields[q.Sort]; ok {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			cmp := compare(field(filtered[i]), field(filtered[j]))
+			if q.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+
+	page := Page[T]{Total: len(filtered), Limit: q.Limit, Offset: q.Offset}
+	if q.Offset < len(filtered) {
+		end := len(filtered)
+		if q.Limit > 0 && q.Offset+q.Limit < end {
+			end = q.Offset + q.Limit
+		}
+		page.Items = filtered[q.Offset:end]
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
+
+// List 解析查询参数并返回一页结果
+func (s Spec[T]) List(c *gin.Context, items []T) (Page[T], error) {
+	q, err := s.Parse(c)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return s.Apply(items, q), nil
+}
+
+// HasMore 是否还有下一页
+func (p Page[T]) HasMore() bool {
+	return p.Offset+len(p.Items) < p.Total
+}
+
+// Response 列表响应：{key: 条目, "total", "limit", "offset", "has_more"}
+func (p Page[T]) Response(key string) gin.H {
+	return gin.H{
+		key:        p.Items,
+		"total":    p.Total,
+		"limit":    p.Limit,
+		"offset":   p.Offset,
+		"has_more": p.HasMore(),
+	}
+}
+
+// Map 转换一页中的条目，分页信息不变
+func Map[T, R any](p Page[T], fn func(T) R) Page[R] {
+	items := make([]R, 0, len(p.Items))
+	for _, item := range p.Items {
+		items = append(items, fn(item))
+	}
+	return Page[R]{Items: items, Total: p.Total, Limit: p.Limit, Offset: p.Offset}
+}
+
+// matches 条目是否满足全部过滤条件
+func (s Spec[T]) matches(item T, filters map[string][]string) bool {
+	for name, values := range filters {
+		if !matchValue(s.Fields[name](item), values) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldNames 支持的字段名，按字母排序
+func (s Spec[T]) fieldNames() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchValue 字段值是否等于任一过滤值（不区分大小写），切片字段包含任一值即匹配
+func matchValue(value interface{}, wanted []string) bool {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			if matchValue(rv.Index(i).Interface(), wanted) {
+				return true
+			}
+		}
+		return false
+	}
+
+	actual := fmt.Sprint(value)
+	for _, w := range wanted {
+		if strings.EqualFold(actual, strings.TrimSpace(w)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare 比较两个字段值：数字按大小、时间按先后、其余按字符串
+func compare(a, b interface{}) int {
+	if ta, ok := asTime(a); ok {
+		if tb, ok := asTime(b); ok {
+			return ta.Compare(tb)
+		}
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if fa, ok := asFloat(va); ok {
+		if fb, ok := asFloat(vb); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// asTime 时间或时间指针（nil视为零值）
+func asTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, true
+		}
+		return *t, true
+	}
+	return time.Time{}, false
+}
+
+// asFloat 数值类型转换为float64，bool按0/1
+func asFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
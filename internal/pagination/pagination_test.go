@@ -0,0 +1,104 @@
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type item struct {
+	Name      string
+	Status    string
+	Tags      []string
+	Score     int
+	CreatedAt time.Time
+}
+
+var itemSpec = Spec[item]{
+	Fields: map[string]Field[item]{
+		"name":       func(i item) interface{} { return i.Name },
+		"status":     func(i item) interface{} { return i.Status },
+		"tags":       func(i item) interface{} { return i.Tags },
+		"score":      func(i item) interface{} { return i.Score },
+		"created_at": func(i item) interface{} { return i.CreatedAt },
+	},
+	DefaultSort: "-created_at",
+}
+
+// list 以查询字符串调用Spec.List
+func list(t *testing.T, query string, items []item) (Page[item], error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/items?"+query, nil)
+	return itemSpec.List(c, items)
+}
+
+// names 条目名称，便于比较顺序
+func names(items []item) string {
+	var s string
+	for _, i := range items {
+		s += i.Name
+	}
+	return s
+}
+
+// TestSpecList 测试分页、排序、过滤和参数校验
+func TestSpecList(t *testing.T) {
+	now := time.Now()
+	items := []item{
+		{Name: "a", Status: "running", Tags: []string{"go"}, Score: 10, CreatedAt: now.Add(-3 * time.Hour)},
+		{Name: "b", Status: "completed", Tags: []string{"rag", "go"}, Score: 2, CreatedAt: now.Add(-1 * time.Hour)},
+		{Name: "c", Status: "Running", Score: 7, CreatedAt: now.Add(-2 * time.Hour)},
+		{Name: "d", Status: "failed", Tags: []string{"rag"}, Score: 10, CreatedAt: now},
+	}
+
+	tests := []struct {
+		query   string
+		want    string
+		total   int
+		hasMore bool
+	}{
+		{"", "dbca", 4, false},                      // 默认按created_at倒序
+		{"limit=2", "db", 4, true},                  // 第一页
+		{"limit=2&offset=2", "ca", 4, false},        // 第二页
+		{"offset=10", "", 4, false},                 // 超出范围
+		{"sort=score", "bcad", 4, false},            // 数值升序，相同值保持原顺序
+		{"sort=-name", "dcba", 4, false},            // 字符串倒序
+		{"status=running", "ca", 2, false},          // 不区分大小写
+		{"status=failed,completed", "db", 2, false}, // 多值任一匹配
+		{"tags=go&sort=name", "ab", 2, false},       // 切片包含
+		{"tags=rag&status=failed", "d", 1, false},   // 多个条件同时满足
+		{"unknown=x&limit=1", "d", 4, true},         // 未声明的参数忽略
+		{"limit=1000", "dbca", 4, false},            // 超过上限按MaxLimit
+	}
+	for _, tt := range tests {
+		page, err := list(t, tt.query, items)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.query, err)
+			continue
+		}
+		if got := names(page.Items); got != tt.want || page.Total != tt.total || page.HasMore() != tt.hasMore {
+			t.Errorf("%q: got %q total=%d has_more=%v, want %q total=%d has_more=%v",
+				tt.query, got, page.Total, page.HasMore(), tt.want, tt.total, tt.hasMore)
+		}
+	}
+	if page, _ := list(t, "limit=1000", items); page.Limit != MaxLimit {
+		t.Errorf("limit should be capped at %d, got %d", MaxLimit, page.Limit)
+	}
+	if names(items) != "abcd" {
+		t.Error("List should not reorder the input slice")
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "offset=-1", "sort=secret"} {
+		_, err := list(t, query, items)
+		if apierror.From(err).Code != apierror.CodeValidation {
+			t.Errorf("%q should be a validation error, got %v", query, err)
+		}
+	}
+}
@@ -108,6 +108,21 @@ type Variant struct {
 	Stats *VariantStats
 }
 
+// clone 变体的副本，结果记录共享（只追加不修改），统计数据复制
+func (v *Variant) clone() *Variant {
+	c := *v
+	c.Results = v.Results[:len(v.Results):len(v.Results)]
+	if v.Stats != nil {
+		stats := *v.Stats
+		if v.Stats.ConfidenceInterval != nil {
+			ci := *v.Stats.ConfidenceInterval
+			stats.ConfidenceInterval = &ci
+		}
+		c.Stats = &stats
+	}
+	return &c
+}
+
 // VariantResult 变体结果
 type VariantResult struct {
 	Query         string
@@ -156,6 +171,23 @@ type ExperimentMetrics struct {
 	Winner string
 }
 
+// snapshot 实验的副本，RecordResult会并发更新统计，查询时返回副本
+func (e *Experiment) snapshot() *Experiment {
+	c := *e
+	c.Variants = make([]*Variant, len(e.Variants))
+	for i, v := range e.Variants {
+		c.Variants[i] = v.clone()
+		if e.Winner == v {
+			c.Winner = c.Variants[i]
+		}
+	}
+	if e.Metrics != nil {
+		metrics := *e.Metrics
+		c.Metrics = &metrics
+	}
+	return &c
+}
+
 // CreateExperiment 创建实验
 func (ab *ABTestingFramework) CreateExperiment(ctx context.Context, name, description string, variants []*Variant) error {
 	ab.mu.Lock()
@@ -404,7 +436,7 @@ func (ab *ABTestingFramework) findVariant(experiment *Experiment, name string) *
 	return nil
 }
 
-// SelectVariant 选择变体 (用于流量分配)
+// SelectVariant 选择变体 (用于流量分配)，返回变体的副本
 func (ab *ABTestingFramework) SelectVariant(ctx context.Context, experimentName string) (*Variant, error) {
 	ab.mu.RLock()
 	defer ab.mu.RUnlock()
@@ -427,21 +459,24 @@ func (ab *ABTestingFramework) SelectVariant(ctx context.Context, experimentName
 	for _, variant := range experiment.Variants {
 		cumulative += variant.Traffic
 		if rand < cumulative {
-			return variant, nil
+			return variant.clone(), nil
 		}
 	}
 
 	// 默认返回第一个
-	return experiment.Variants[0], nil
+	return experiment.Variants[0].clone(), nil
 }
 
-// GetExperiment 获取实验
+// GetExperiment 获取实验的快照
 func (ab *ABTestingFramework) GetExperiment(name string) (*Experiment, bool) {
 	ab.mu.RLock()
 	defer ab.mu.RUnlock()
 
 	experiment, exists := ab.experiments[name]
-	return experiment, exists
+	if !exists {
+		return nil, false
+	}
+	return experiment.snapshot(), true
 }
 
 // ListExperiments 列出所有实验
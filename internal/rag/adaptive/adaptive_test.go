@@ -0,0 +1,355 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+type stubLLM struct{}
+
+func (stubLLM) Generate(ctx context.Context, prompt string) (string, error) {
+	return "0.8", nil
+}
+
+// 以下测试主要在 go test -race 下发现共享状态的并发读写
+
+func TestABTestingConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	ab := NewABTestingFramework(DefaultABTestConfig())
+	if err := ab.CreateExperiment(ctx, "exp", "", []*Variant{
+		{Name: "a", Strategy: "vector", Traffic: 0.5},
+		{Name: "b", Strategy: "hybrid", Traffic: 0.5},
+	}); err != nil {
+		t.Fatalf("CreateExperiment failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				variant := "a"
+				if (i+j)%2 == 1 {
+					variant = "b"
+				}
+				_ = ab.RecordResult(ctx, "exp", variant, &VariantResult{Score: float64(j%10) / 10})
+				if exp, ok := ab.GetExperiment("exp"); ok {
+					for _, v := range exp.Variants {
+						_ = v.Stats
+					}
+				}
+				_, _ = ab.SelectVariant(ctx, "exp")
+				_ = ab.GenerateReport("exp")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestQueryRouterConcurrentFeedback(t *testing.T) {
+	ctx := context.Background()
+	router, err := NewQueryRouter(stubLLM{}, DefaultRouterConfig())
+	if err != nil {
+		t.Fatalf("NewQueryRouter failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			strategy := []string{"vector", "hybrid"}[i%2]
+			for j := 0; j < 50; j++ {
+				_ = router.RecordFeedback(ctx, fmt.Sprintf("q%d", j), strategy, &RAGExecutionResult{
+					Strategy: strategy, Score: 0.7, Latency: 100, UserFeedback: 0.9, Success: true,
+				})
+				_ = router.GetAllPerformance()
+				_, _ = router.OptimizeParameters(ctx, strategy)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	perf, ok := router.GetStrategyPerformance("vector")
+	if !ok || perf.TotalQueries != 200 {
+		t.Fatalf("vector performance = %+v, want 200 queries", perf)
+	}
+}
+
+func TestParameterOptimizerConcurrentRecord(t *testing.T) {
+	ctx := context.Background()
+	optimizer, err := NewParameterOptimizer(stubLLM{}, DefaultOptimizerConfig())
+	if err != nil {
+		t.Fatalf("NewParameterOptimizer failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = optimizer.RecordPerformance(ctx, "vector", &RAGExecutionResult{
+					Strategy: "vector", Score: 0.6, Latency: 80, Success: true,
+				})
+				for _, perf := range optimizer.GetAllPerformance() {
+					_ = perf.AverageScore
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if perf := optimizer.GetAllPerformance()["vector"]; perf == nil || perf.TotalQueries != 400 {
+		t.Fatalf("vector performance = %+v, want 400 queries", perf)
+	}
+}
+
+// toolCallingLLM 返回预设函数调用的LLM，记录收到的工具定义
+type toolCallingLLM struct {
+	stubLLM
+	supported bool
+	calls     []ToolCall
+	err       error
+	tools     []ToolSchema
+}
+
+func (l *toolCallingLLM) SupportsToolCalling() bool { return l.supported }
+
+func (l *toolCallingLLM) CallTools(ctx context.Context, prompt string, tools []ToolSchema) ([]ToolCall, error) {
+	l.tools = tools
+	return l.calls, l.err
+}
+
+// filterTool 声明输入Schema的工具
+type filterTool struct{ *KnowledgeQueryTool }
+
+func (filterTool) Name() string { return "filter_search" }
+
+func (filterTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query":  map[string]interface{}{"type": "string"},
+			"source": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// TestDecideActionFunctionCalling 测试通过函数调用选择工具，不支持或失败时回退到关键词匹配
+func TestDecideActionFunctionCalling(t *testing.T) {
+	ctx := context.Background()
+	thought := &Thought{Content: "需要用 graph_search 查找实体关系"}
+
+	cases := []struct {
+		name string
+		llm  *toolCallingLLM
+		want Action
+	}{
+		{"function call", &toolCallingLLM{supported: true, calls: []ToolCall{{Name: "hybrid_search", Arguments: `{"input":"Go 泛型"}`}}},
+			Action{Tool: "hybrid_search", Input: "Go 泛型"}},
+		{"missing input uses query", &toolCallingLLM{supported: true, calls: []ToolCall{{Name: "vector_search", Arguments: `{}`}}},
+			Action{Tool: "vector_search", Input: "Go 泛型怎么用"}},
+		{"schema tool gets raw arguments", &toolCallingLLM{supported: true, calls: []ToolCall{{Name: "filter_search", Arguments: `{"query":"泛型","source":"docs"}`}}},
+			Action{Tool: "filter_search", Input: `{"query":"泛型","source":"docs"}`}},
+		{"unknown tool", &toolCallingLLM{supported: true, calls: []ToolCall{{Name: "web_search"}}},
+			Action{Tool: "graph_search", Input: "Go 泛型怎么用"}},
+		{"call failed", &toolCallingLLM{supported: true, err: fmt.Errorf("rate limited")},
+			Action{Tool: "graph_search", Input: "Go 泛型怎么用"}},
+		{"not supported", &toolCallingLLM{calls: []ToolCall{{Name: "hybrid_search"}}},
+			Action{Tool: "graph_search", Input: "Go 泛型怎么用"}},
+	}
+	for _, tc := range cases {
+		ar, err := NewAgenticRAG(tc.llm, DefaultAgenticRAGConfig())
+		if err != nil {
+			t.Fatalf("NewAgenticRAG failed: %v", err)
+		}
+		ar.AddTool(filterTool{&KnowledgeQueryTool{}})
+		ar.state = &AgentState{Query: "Go 泛型怎么用"}
+
+		action := ar.decideAction(ctx, thought)
+		if action.Tool != tc.want.Tool || action.Input != tc.want.Input {
+			t.Errorf("%s: got %+v, want %+v", tc.name, *action, tc.want)
+		}
+		if !tc.llm.supported {
+			if tc.llm.tools != nil {
+				t.Errorf("%s: CallTools should not be used", tc.name)
+			}
+			continue
+		}
+		if len(tc.llm.tools) != 5 {
+			t.Fatalf("%s: expected 5 tool definitions, got %d", tc.name, len(tc.llm.tools))
+		}
+		if required, _ := tc.llm.tools[0].Parameters["required"].([]string); len(required) != 1 || required[0] != "input" {
+			t.Errorf("%s: default schema should require input, got %v", tc.name, tc.llm.tools[0].Parameters)
+		}
+		if props, _ := tc.llm.tools[4].Parameters["properties"].(map[string]interface{}); props["source"] == nil {
+			t.Errorf("%s: schema tool should declare its own parameters, got %v", tc.name, tc.llm.tools[4].Parameters)
+		}
+	}
+}
+
+// TestTrajectoryStore 测试查询轨迹的保存、重放、文件持久化和微调数据导出
+func TestTrajectoryStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultAgenticRAGConfig()
+	cfg.MaxIterations = 2
+	ar, err := NewAgenticRAG(stubLLM{}, cfg)
+	if err != nil {
+		t.Fatalf("NewAgenticRAG failed: %v", err)
+	}
+	if _, err := ar.Replay(ctx, "traj-x"); err == nil {
+		t.Error("expected error without a trajectory store")
+	}
+
+	dir := t.TempDir()
+	store, err := NewFileTrajectoryStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileTrajectoryStore failed: %v", err)
+	}
+	ar.SetTrajectoryStore(store)
+
+	result, err := ar.Query(ctx, "Go 泛型怎么用")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.TrajectoryID == "" {
+		t.Fatal("expected a trajectory id")
+	}
+	steps, err := ar.Replay(ctx, result.TrajectoryID)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(steps) == 0 || len(steps) != len(result.Actions) || steps[0].Action.Tool != result.Actions[0].Tool || steps[0].Iteration != 1 {
+		t.Errorf("replay should follow the recorded actions, got %d steps for %d actions", len(steps), len(result.Actions))
+	}
+	if _, err := ar.Trajectory(ctx, "traj-missing"); !errors.Is(err, ErrTrajectoryNotFound) {
+		t.Errorf("expected ErrTrajectoryNotFound, got %v", err)
+	}
+
+	failed := &Trajectory{ID: "traj-failed", Query: "失败的查询", StartedAt: time.Now().Add(time.Minute),
+		Observations: []Observation{{Content: "timeout", Type: "error"}}}
+	if err := store.Save(ctx, failed); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// 重新打开后按开始时间恢复顺序
+	reopened, err := NewFileTrajectoryStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	all, _ := reopened.List(ctx, TrajectoryFilter{})
+	if len(all) != 2 || all[0].ID != "traj-failed" || all[1].ID != result.TrajectoryID {
+		t.Fatalf("unexpected trajectories after reopen: %d", len(all))
+	}
+	saved := all[1]
+	if saved.Query != "Go 泛型怎么用" || saved.Mode != ModeReAct || saved.Iterations != result.Iterations || saved.Answer != result.Answer {
+		t.Errorf("unexpected saved trajectory: %+v", saved)
+	}
+
+	// 只导出成功的轨迹
+	var sb strings.Builder
+	successful := &Trajectory{Query: "q", Success: true, Answer: "a",
+		Thoughts: []Thought{{Content: "先检索"}}, Actions: []Action{{Tool: "vector_search", Input: "q"}},
+		Observations: []Observation{{Content: "找到1篇文档", Type: "success"}}}
+	n, err := ExportFineTuning(&sb, []*Trajectory{successful, failed})
+	if err != nil || n != 1 {
+		t.Fatalf("ExportFineTuning = %d, %v", n, err)
+	}
+	want := `{"messages":[{"role":"user","content":"q"},{"role":"assistant","content":"Thought: 先检索\nAction: vector_search[q]"},` +
+		`{"role":"user","content":"Observation: 找到1篇文档"},{"role":"assistant","content":"a"}]}` + "\n"
+	if sb.String() != want {
+		t.Errorf("unexpected training example:\n%s", sb.String())
+	}
+
+	// 内存存储只保留最近N条
+	memory := NewMemoryTrajectoryStore(2)
+	for _, id := range []string{"a", "b", "c"} {
+		_ = memory.Save(ctx, &Trajectory{ID: id, Success: id != "b"})
+	}
+	if _, err := memory.Get(ctx, "a"); !errors.Is(err, ErrTrajectoryNotFound) {
+		t.Errorf("oldest trajectory should be evicted, got %v", err)
+	}
+	if list, _ := memory.List(ctx, TrajectoryFilter{SuccessOnly: true}); len(list) != 1 || list[0].ID != "c" {
+		t.Errorf("unexpected successful trajectories: %v", list)
+	}
+}
+
+// TestReflectionBudget 测试反思预算的配置、请求级收紧和提前结束
+func TestReflectionBudget(t *testing.T) {
+	budget, err := NewReflectionBudgetFromConfig(config.RAGReflectionConfig{MaxIterations: 5, MaxRetries: 3, MinScore: 0.7, MaxDuration: "10s"})
+	if err != nil {
+		t.Fatalf("NewReflectionBudgetFromConfig failed: %v", err)
+	}
+	if budget.MaxDuration != 10*time.Second || budget.MaxIterations != 5 {
+		t.Errorf("unexpected budget: %+v", budget)
+	}
+	for _, cfg := range []config.RAGReflectionConfig{{MinScore: 1.5}, {MaxDuration: "-1s"}, {MaxDuration: "soon"}} {
+		if _, err := NewReflectionBudgetFromConfig(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	// 请求级预算只能收紧次数和耗时
+	capped := budget.Cap(ReflectionBudget{MaxIterations: 10, MaxRetries: 1, MaxDuration: time.Second, MinScore: 0.9, StopOnRepeat: true})
+	want := ReflectionBudget{MaxIterations: 5, MaxRetries: 1, MinScore: 0.9, MaxDuration: time.Second, StopOnRepeat: true}
+	if capped != want {
+		t.Errorf("Cap = %+v, want %+v", capped, want)
+	}
+	if got := (ReflectionBudget{}).Cap(ReflectionBudget{MaxIterations: 2}); got.MaxIterations != 2 {
+		t.Errorf("override should apply to unlimited budgets, got %+v", got)
+	}
+
+	now := time.Now()
+	retry := ReflectionBudget{MaxRetries: 3, MinScore: 0.8, MinImprovement: 0.05, MaxDuration: time.Minute}
+	cases := []struct {
+		retries          int
+		prev, score      float64
+		startedAt        time.Time
+		continueRetrying bool
+		reason           string
+	}{
+		{0, 0, 0.5, now, true, ""},
+		{0, 0, 0.85, now, false, StopScoreReached},
+		{3, 0.5, 0.7, now, false, StopMaxRetries},
+		{1, 0.5, 0.7, now.Add(-2 * time.Minute), false, StopMaxDuration},
+		{1, 0.5, 0.52, now, false, StopNoImprovement},
+		{1, 0.5, 0.6, now, true, ""},
+	}
+	for i, tc := range cases {
+		ok, reason := retry.NextRetry(tc.retries, tc.prev, tc.score, tc.startedAt)
+		if ok != tc.continueRetrying || reason != tc.reason {
+			t.Errorf("case %d: NextRetry = %v, %q; want %v, %q", i, ok, reason, tc.continueRetrying, tc.reason)
+		}
+	}
+
+	ctx := context.Background()
+	ar, err := NewAgenticRAG(stubLLM{}, DefaultAgenticRAGConfig())
+	if err != nil {
+		t.Fatalf("NewAgenticRAG failed: %v", err)
+	}
+	result, err := ar.Query(WithReflectionBudget(ctx, ReflectionBudget{MaxIterations: 1}), "Go 泛型怎么用")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Iterations != 1 || result.StopReason != StopMaxIterations {
+		t.Errorf("request budget should cap iterations, got %d (%s)", result.Iterations, result.StopReason)
+	}
+
+	// 没有思考出新行动时每轮都检索原始查询，开启重复检查后第二轮提前结束
+	result, err = ar.Query(WithReflectionBudget(ctx, ReflectionBudget{StopOnRepeat: true}), "Go 泛型怎么用")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.StopReason != StopRepeatedAction || len(result.Actions) != 1 {
+		t.Errorf("expected early exit on repeated action, got %s after %d actions", result.StopReason, len(result.Actions))
+	}
+}
@@ -2,10 +2,12 @@ package adaptive
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 // AgenticRAG 代理式 RAG
@@ -37,6 +39,7 @@ type AgenticRAG struct {
 	reflector    Reflector
 	config       AgenticRAGConfig
 	state        *AgentState
+	trajectories TrajectoryStore
 	mu           sync.RWMutex
 }
 
@@ -56,6 +59,25 @@ type AgenticRAGConfig struct {
 
 	// ToolTimeout 工具执行超时（毫秒）
 	ToolTimeout int64
+
+	// MaxDuration ReAct 循环总耗时上限，0 表示不限制
+	MaxDuration time.Duration
+
+	// StopOnRepeatedAction 重复执行相同的工具和输入时提前结束
+	StopOnRepeatedAction bool
+}
+
+// ApplyBudget 用配置的反思预算覆盖默认值，预算中未设置的项保持不变
+func (c *AgenticRAGConfig) ApplyBudget(budget ReflectionBudget) {
+	if budget.MaxIterations > 0 {
+		c.MaxIterations = budget.MaxIterations
+	}
+	if budget.MaxDuration > 0 {
+		c.MaxDuration = budget.MaxDuration
+	}
+	if budget.StopOnRepeat {
+		c.StopOnRepeatedAction = true
+	}
 }
 
 // AgentTool 代理工具接口
@@ -73,6 +95,41 @@ type AgentTool interface {
 	ValidateInput(input string) bool
 }
 
+// SchemaTool 声明输入参数 JSON Schema 的工具
+// 通过函数调用选择工具时使用该 Schema，模型生成的参数（JSON）原样作为工具输入；
+// 未实现时使用只有 input 字符串参数的默认 Schema
+type SchemaTool interface {
+	AgentTool
+
+	// InputSchema 输入参数的 JSON Schema
+	InputSchema() map[string]interface{}
+}
+
+// ToolSchema 提供给模型的工具定义
+type ToolSchema struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema
+}
+
+// ToolCall 模型选择的工具调用
+type ToolCall struct {
+	Name      string
+	Arguments string // JSON 参数
+}
+
+// ToolCallingProvider 支持函数调用的 LLM 提供者
+// decideAction 优先让模型通过函数调用选择工具，不支持时回退到关键词匹配
+type ToolCallingProvider interface {
+	LLMProvider
+
+	// SupportsToolCalling 当前模型是否支持函数调用
+	SupportsToolCalling() bool
+
+	// CallTools 携带工具定义调用模型，返回模型选择的工具调用
+	CallTools(ctx context.Context, prompt string, tools []ToolSchema) ([]ToolCall, error)
+}
+
 // AgentMemory 代理记忆
 type AgentMemory struct {
 	observations  []Observation
@@ -84,21 +141,21 @@ type AgentMemory struct {
 
 // Observation 观察
 type Observation struct {
-	Content string
-	Type    string
+	Content string `json:"content"`
+	Type    string `json:"type"`
 }
 
 // Thought 思考
 type Thought struct {
-	Content string
-	Reasoning string
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 // Action 行动
 type Action struct {
-	Tool   string
-	Input  string
-	Output string
+	Tool   string `json:"tool"`
+	Input  string `json:"input"`
+	Output string `json:"output,omitempty"`
 }
 
 // Planner 规划器接口
@@ -151,6 +208,7 @@ type AgentState struct {
 	Actions        []Action
 	Answer         string
 	Confidence     float64
+	StopReason     string
 }
 
 // DefaultAgenticRAGConfig 返回默认配置
@@ -197,11 +255,24 @@ func (ar *AgenticRAG) registerDefaultTools() {
 	)
 }
 
+// SetTrajectoryStore 设置轨迹存储，设置后每次查询的思考、行动和观察都会被保存
+func (ar *AgenticRAG) SetTrajectoryStore(store TrajectoryStore) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.trajectories = store
+}
+
 // Query 执行代理式查询
 func (ar *AgenticRAG) Query(ctx context.Context, query string) (*AgentResult, error) {
 	ar.mu.Lock()
 	defer ar.mu.Unlock()
 
+	startedAt := time.Now()
+	mode := ModeReAct
+	if !ar.config.EnableReAct && ar.config.EnablePlanning {
+		mode = ModePlanAndExecute
+	}
+
 	// 初始化状态
 	ar.state = &AgentState{
 		Query:        query,
@@ -214,18 +285,52 @@ func (ar *AgenticRAG) Query(ctx context.Context, query string) (*AgentResult, er
 		Confidence:   0.0,
 	}
 
-	// ReAct 模式
-	if ar.config.EnableReAct {
-		return ar.reactMode(ctx, query)
+	var result *AgentResult
+	var err error
+	if mode == ModePlanAndExecute {
+		result, err = ar.planAndExecuteMode(ctx, query)
+	} else {
+		// 默认使用 ReAct
+		result, err = ar.reactMode(ctx, query)
+	}
+
+	ar.recordTrajectory(ctx, mode, startedAt, result, err)
+	return result, err
+}
+
+// recordTrajectory 保存本次查询的轨迹，保存失败不影响查询结果
+func (ar *AgenticRAG) recordTrajectory(ctx context.Context, mode string, startedAt time.Time, result *AgentResult, runErr error) {
+	if ar.trajectories == nil {
+		return
 	}
+	trajectory := newTrajectory(ar.state, mode, startedAt, runErr)
+	if err := ar.trajectories.Save(ctx, trajectory); err != nil {
+		fmt.Printf("[Trajectory] 保存轨迹失败: %v\n", err)
+		return
+	}
+	if result != nil {
+		result.TrajectoryID = trajectory.ID
+	}
+}
 
-	// Plan-and-Execute 模式
-	if ar.config.EnablePlanning {
-		return ar.planAndExecuteMode(ctx, query)
+// Trajectory 获取已保存的轨迹
+func (ar *AgenticRAG) Trajectory(ctx context.Context, id string) (*Trajectory, error) {
+	ar.mu.RLock()
+	store := ar.trajectories
+	ar.mu.RUnlock()
+	if store == nil {
+		return nil, fmt.Errorf("trajectory store not configured")
 	}
+	return store.Get(ctx, id)
+}
 
-	// 默认使用 ReAct
-	return ar.reactMode(ctx, query)
+// Replay 按执行顺序重放已保存轨迹的每一步，用于排查推理失败
+func (ar *AgenticRAG) Replay(ctx context.Context, id string) ([]TrajectoryStep, error) {
+	trajectory, err := ar.Trajectory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return trajectory.Steps(), nil
 }
 
 // reactMode ReAct 模式（推理 + 行动）
@@ -233,7 +338,19 @@ func (ar *AgenticRAG) Query(ctx context.Context, query string) (*AgentResult, er
 func (ar *AgenticRAG) reactMode(ctx context.Context, query string) (*AgentResult, error) {
 	fmt.Printf("[ReAct] 开始处理查询: %s\n", query)
 
-	for ar.state.Iterations < ar.config.MaxIterations && !ar.state.Completed {
+	budget := budgetFromContext(ctx, ReflectionBudget{
+		MaxIterations: ar.config.MaxIterations,
+		MaxDuration:   ar.config.MaxDuration,
+		StopOnRepeat:  ar.config.StopOnRepeatedAction,
+	})
+	startedAt := time.Now()
+	ar.state.StopReason = StopMaxIterations
+
+	for ar.state.Iterations < budget.MaxIterations && !ar.state.Completed {
+		if budget.MaxDuration > 0 && time.Since(startedAt) >= budget.MaxDuration {
+			ar.state.StopReason = StopMaxDuration
+			break
+		}
 		ar.state.Iterations++
 
 		// Step 1: Thought (思考)
@@ -243,17 +360,26 @@ func (ar *AgenticRAG) reactMode(ctx context.Context, query string) (*AgentResult
 
 		// Step 2: Action (行动)
 		action := ar.decideAction(ctx, thought)
-		ar.state.Actions = append(ar.state.Actions, *action)
+		if budget.StopOnRepeat && ar.repeatsAction(action) {
+			// 重复的行动不会带来新信息，直接基于已有观察作答
+			ar.state.StopReason = StopRepeatedAction
+			break
+		}
 		fmt.Printf("[Action %d] 使用工具: %s, 输入: %s\n", ar.state.Iterations, action.Tool, action.Input)
 
 		// Step 3: Observation (观察)
 		observation := ar.executeAction(ctx, action)
+		ar.state.Actions = append(ar.state.Actions, *action)
 		ar.state.Observations = append(ar.state.Observations, *observation)
 		fmt.Printf("[Observation %d] %s\n", ar.state.Iterations, observation.Content)
 
 		// Step 4: Check if complete (检查是否完成)
-		if ar.checkCompletion(ctx) {
+		if ar.checkCompletion(ctx, budget.MaxIterations) {
 			ar.state.Completed = true
+			ar.state.StopReason = StopCompleted
+			if ar.state.Iterations >= budget.MaxIterations {
+				ar.state.StopReason = StopMaxIterations
+			}
 			break
 		}
 	}
@@ -281,6 +407,7 @@ func (ar *AgenticRAG) reactMode(ctx context.Context, query string) (*AgentResult
 		Observations:  ar.state.Observations,
 		Iterations:    ar.state.Iterations,
 		Confidence:    ar.state.Confidence,
+		StopReason:    ar.state.StopReason,
 	}, nil
 }
 
@@ -323,6 +450,8 @@ func (ar *AgenticRAG) planAndExecuteMode(ctx context.Context, query string) (*Ag
 	// Step 3: Generate Answer (生成答案)
 	answer := ar.generateAnswer(ctx)
 	ar.state.Answer = answer
+	ar.state.Completed = true
+	ar.state.Confidence = 0.8
 
 	return &AgentResult{
 		Query:         query,
@@ -386,11 +515,90 @@ func (ar *AgenticRAG) buildThoughtPrompt(query string, state *AgentState) string
 }
 
 // decideAction 决定行动
+// 模型支持函数调用时由模型按工具 Schema 选择工具和参数，否则基于关键词匹配
 func (ar *AgenticRAG) decideAction(ctx context.Context, thought *Thought) *Action {
+	if action := ar.selectToolByFunctionCall(ctx, thought); action != nil {
+		return action
+	}
+	return ar.matchToolByKeyword(thought)
+}
+
+// selectToolByFunctionCall 通过函数调用选择工具
+// 模型不支持函数调用、调用失败或选择了未注册的工具时返回 nil
+func (ar *AgenticRAG) selectToolByFunctionCall(ctx context.Context, thought *Thought) *Action {
+	provider, ok := ar.llm.(ToolCallingProvider)
+	if !ok || !provider.SupportsToolCalling() || len(ar.tools) == 0 {
+		return nil
+	}
+
+	schemas := make([]ToolSchema, 0, len(ar.tools))
+	for _, tool := range ar.tools {
+		schemas = append(schemas, toolSchema(tool))
+	}
+
+	prompt := fmt.Sprintf("查询: %s\n\n思考: %s\n\n请调用最合适的一个工具执行下一步检索。", ar.state.Query, thought.Content)
+	calls, err := provider.CallTools(ctx, prompt, schemas)
+	if err != nil {
+		fmt.Printf("[Action] 函数调用失败，回退到关键词匹配: %v\n", err)
+		return nil
+	}
+
+	for _, call := range calls {
+		for _, tool := range ar.tools {
+			if tool.Name() == call.Name {
+				return &Action{
+					Tool:  tool.Name(),
+					Input: ar.toolCallInput(tool, call.Arguments),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// toolSchema 工具的函数调用定义
+func toolSchema(tool AgentTool) ToolSchema {
+	schema := ToolSchema{
+		Name:        tool.Name(),
+		Description: tool.Description(),
+	}
+	if st, ok := tool.(SchemaTool); ok {
+		schema.Parameters = st.InputSchema()
+		return schema
+	}
+	schema.Parameters = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"input": map[string]interface{}{
+				"type":        "string",
+				"description": "工具输入，如检索语句",
+			},
+		},
+		"required": []string{"input"},
+	}
+	return schema
+}
+
+// toolCallInput 从函数调用参数中取得工具输入
+// 声明了 Schema 的工具直接使用参数 JSON，其余工具取 input 参数，缺失时使用原始查询
+func (ar *AgenticRAG) toolCallInput(tool AgentTool, arguments string) string {
+	if _, ok := tool.(SchemaTool); ok && strings.TrimSpace(arguments) != "" {
+		return arguments
+	}
+	var args struct {
+		Input string `json:"input"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err == nil && strings.TrimSpace(args.Input) != "" {
+		return args.Input
+	}
+	return ar.state.Query
+}
+
+// matchToolByKeyword 基于关键词匹配从思考中选择工具
+func (ar *AgenticRAG) matchToolByKeyword(thought *Thought) *Action {
 	// 从思考中提取行动
 	content := strings.ToLower(thought.Content)
 
-	// 简化实现：基于关键词匹配
 	for _, tool := range ar.tools {
 		toolName := strings.ToLower(tool.Name())
 		if strings.Contains(content, toolName) || strings.Contains(content, toolName+"_search") {
@@ -467,8 +675,18 @@ func (ar *AgenticRAG) executeAction(ctx context.Context, action *Action) *Observ
 	}
 }
 
+// repeatsAction 行动是否与之前某次行动的工具和输入完全相同
+func (ar *AgenticRAG) repeatsAction(action *Action) bool {
+	for _, prev := range ar.state.Actions {
+		if prev.Tool == action.Tool && prev.Input == action.Input {
+			return true
+		}
+	}
+	return false
+}
+
 // checkCompletion 检查是否完成
-func (ar *AgenticRAG) checkCompletion(ctx context.Context) bool {
+func (ar *AgenticRAG) checkCompletion(ctx context.Context, maxIterations int) bool {
 	// 检查条件:
 	// 1. 已有足够的观察
 	if len(ar.state.Observations) < 2 {
@@ -482,7 +700,7 @@ func (ar *AgenticRAG) checkCompletion(ctx context.Context) bool {
 	}
 
 	// 3. 达到最大迭代次数
-	if ar.state.Iterations >= ar.config.MaxIterations {
+	if ar.state.Iterations >= maxIterations {
 		return true
 	}
 
@@ -536,7 +754,7 @@ func (ar *AgenticRAG) generateAnswer(ctx context.Context) string {
 
 	response, err := ar.llm.Generate(ctx, prompt)
 	if err != nil {
-		return "答案生成失败"
+		return answerFailedText
 	}
 
 	return strings.TrimSpace(response)
@@ -558,6 +776,9 @@ func (ar *AgenticRAG) checkDependencies(step PlanStep, state *AgentState) bool {
 	return true
 }
 
+// answerFailedText 答案生成失败时返回的内容
+const answerFailedText = "答案生成失败"
+
 // AgentResult 代理结果
 type AgentResult struct {
 	Query         string
@@ -567,6 +788,8 @@ type AgentResult struct {
 	Observations  []Observation
 	Iterations    int
 	Confidence    float64
+	StopReason    string // ReAct 循环结束的原因，如 completed、max_iterations、repeated_action
+	TrajectoryID  string // 设置了轨迹存储时为本次查询的轨迹ID
 }
 
 // ===== 默认工具实现 =====
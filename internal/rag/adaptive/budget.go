@@ -0,0 +1,112 @@
+package adaptive
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 反思循环结束的原因
+const (
+	StopCompleted      = "completed"       // 代理判断信息已足够
+	StopScoreReached   = "score_reached"   // 检索质量达到阈值
+	StopMaxIterations  = "max_iterations"  // 迭代次数用尽
+	StopMaxRetries     = "max_retries"     // 重新检索次数用尽
+	StopMaxDuration    = "max_duration"    // 耗时上限用尽
+	StopNoImprovement  = "no_improvement"  // 重新检索后得分提升不足
+	StopRepeatedAction = "repeated_action" // 代理重复执行相同行动
+	StopError          = "error"           // 检索或评估失败
+)
+
+// ReflectionBudget 反思循环预算，0表示不设置该项
+// Self-RAG 使用重试次数、分数阈值和耗时上限；Agentic RAG 使用迭代次数、耗时上限和重复行动检查
+type ReflectionBudget struct {
+	MaxIterations  int           // Agentic RAG 最大迭代次数
+	MaxRetries     int           // Self-RAG 最多重新检索次数
+	MinScore       float64       // 检索质量达到该分数后不再重新检索
+	MinImprovement float64       // 一轮重新检索的得分提升低于该值时提前结束
+	MaxDuration    time.Duration // 反思循环总耗时上限
+	StopOnRepeat   bool          // 重复执行相同的工具和输入时提前结束
+}
+
+// NewReflectionBudgetFromConfig 根据配置创建反思循环预算
+func NewReflectionBudgetFromConfig(cfg config.RAGReflectionConfig) (ReflectionBudget, error) {
+	budget := ReflectionBudget{
+		MaxIterations:  cfg.MaxIterations,
+		MaxRetries:     cfg.MaxRetries,
+		MinScore:       cfg.MinScore,
+		MinImprovement: cfg.MinImprovement,
+		StopOnRepeat:   cfg.StopOnRepeat,
+	}
+	if cfg.MinScore < 0 || cfg.MinScore > 1 {
+		return budget, fmt.Errorf("invalid reflection min_score %v: must be within [0, 1]", cfg.MinScore)
+	}
+	if cfg.MaxDuration != "" {
+		d, err := time.ParseDuration(cfg.MaxDuration)
+		if err != nil || d <= 0 {
+			return budget, fmt.Errorf("invalid reflection max_duration %q", cfg.MaxDuration)
+		}
+		budget.MaxDuration = d
+	}
+	return budget, nil
+}
+
+// Cap 合并请求级预算
+// 迭代次数、重试次数和耗时上限只能收紧，分数阈值以请求为准，重复行动检查只能开启
+func (b ReflectionBudget) Cap(override ReflectionBudget) ReflectionBudget {
+	b.MaxIterations = lowerLimit(b.MaxIterations, override.MaxIterations)
+	b.MaxRetries = lowerLimit(b.MaxRetries, override.MaxRetries)
+	if override.MaxDuration > 0 && (b.MaxDuration <= 0 || override.MaxDuration < b.MaxDuration) {
+		b.MaxDuration = override.MaxDuration
+	}
+	if override.MinScore > 0 {
+		b.MinScore = override.MinScore
+	}
+	if override.MinImprovement > 0 {
+		b.MinImprovement = override.MinImprovement
+	}
+	b.StopOnRepeat = b.StopOnRepeat || override.StopOnRepeat
+	return b
+}
+
+// lowerLimit 取两个次数限制中较严格的一个，0表示不限制
+func lowerLimit(limit, override int) int {
+	if override > 0 && (limit <= 0 || override < limit) {
+		return override
+	}
+	return limit
+}
+
+// reflectionBudgetKey 上下文中请求级反思预算的键
+type reflectionBudgetKey struct{}
+
+// WithReflectionBudget 在上下文中设置本次请求的反思预算，用于延迟敏感的接口限制推理深度
+func WithReflectionBudget(ctx context.Context, budget ReflectionBudget) context.Context {
+	return context.WithValue(ctx, reflectionBudgetKey{}, budget)
+}
+
+// budgetFromContext 以上下文中的请求级预算收紧默认预算
+func budgetFromContext(ctx context.Context, budget ReflectionBudget) ReflectionBudget {
+	if override, ok := ctx.Value(reflectionBudgetKey{}).(ReflectionBudget); ok {
+		return budget.Cap(override)
+	}
+	return budget
+}
+
+// NextRetry 判断 Self-RAG 是否继续重新检索，不继续时返回结束原因
+// retries 为已重新检索的次数，prevScore 为上一轮得分，startedAt 为反思循环开始时间
+func (b ReflectionBudget) NextRetry(retries int, prevScore, score float64, startedAt time.Time) (bool, string) {
+	switch {
+	case score >= b.MinScore:
+		return false, StopScoreReached
+	case retries >= b.MaxRetries:
+		return false, StopMaxRetries
+	case b.MaxDuration > 0 && time.Since(startedAt) >= b.MaxDuration:
+		return false, StopMaxDuration
+	case retries > 0 && b.MinImprovement > 0 && score-prevScore < b.MinImprovement:
+		return false, StopNoImprovement
+	}
+	return true, ""
+}
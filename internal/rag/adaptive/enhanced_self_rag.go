@@ -430,13 +430,13 @@ func (esr *EnhancedSelfRAG) adjustTopK(originalTopK int, score float64) int {
 	return originalTopK
 }
 
-// GetPerformanceHistory 获取性能历史
+// GetPerformanceHistory 获取性能历史的副本
 func (esr *EnhancedSelfRAG) GetPerformanceHistory(query string) []QueryPerformance {
 	esr.perfTracker.mu.RLock()
 	defer esr.perfTracker.mu.RUnlock()
 
 	if history, exists := esr.perfTracker.queryHistory[query]; exists {
-		return history
+		return append([]QueryPerformance(nil), history...)
 	}
 
 	return nil
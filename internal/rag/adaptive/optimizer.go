@@ -337,10 +337,11 @@ func (po *ParameterOptimizer) GetAllPerformance() map[string]*StrategyPerformanc
 	po.mu.RLock()
 	defer po.mu.RUnlock()
 
-	// 返回副本
+	// 返回副本，RecordPerformance会在锁内继续更新原记录
 	result := make(map[string]*StrategyPerformance)
 	for k, v := range po.performanceData {
-		result[k] = v
+		c := *v
+		result[k] = &c
 	}
 
 	return result
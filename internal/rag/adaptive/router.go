@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 // QueryRouter 查询路由器
@@ -23,6 +24,7 @@ type QueryRouter struct {
 	performanceData map[string]*StrategyPerformance
 	llm             LLMProvider
 	config          RouterConfig
+	mu              sync.RWMutex // 保护performanceData，并发请求会同时路由和记录反馈
 }
 
 // RouterConfig 路由器配置
@@ -254,6 +256,9 @@ func (qr *QueryRouter) routeByML(ctx context.Context, query string) (string, err
 
 // routeByPerformance 基于历史性能路由
 func (qr *QueryRouter) routeByPerformance() string {
+	qr.mu.RLock()
+	defer qr.mu.RUnlock()
+
 	bestStrategy := ""
 	bestScore := 0.0
 
@@ -280,7 +285,7 @@ func (qr *QueryRouter) routeByPerformance() string {
 // OptimizeParameters 优化检索参数
 func (qr *QueryRouter) OptimizeParameters(ctx context.Context, queryType string) (map[string]interface{}, error) {
 	// 获取策略的性能数据
-	perf, exists := qr.performanceData[queryType]
+	perf, exists := qr.GetStrategyPerformance(queryType)
 	if !exists || perf.TotalQueries < 10 {
 		// 数据不足，返回默认参数
 		return qr.getDefaultParameters(queryType), nil
@@ -343,6 +348,9 @@ func (qr *QueryRouter) getDefaultParameters(strategy string) map[string]interfac
 
 // RecordFeedback 记录反馈
 func (qr *QueryRouter) RecordFeedback(ctx context.Context, query string, strategy string, result *RAGExecutionResult) error {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+
 	perf, exists := qr.performanceData[strategy]
 	if !exists {
 		perf = &StrategyPerformance{
@@ -364,15 +372,30 @@ func (qr *QueryRouter) RecordFeedback(ctx context.Context, query string, strateg
 	return nil
 }
 
-// GetStrategyPerformance 获取策略性能
+// GetStrategyPerformance 获取策略性能（副本）
 func (qr *QueryRouter) GetStrategyPerformance(strategy string) (*StrategyPerformance, bool) {
+	qr.mu.RLock()
+	defer qr.mu.RUnlock()
+
 	perf, exists := qr.performanceData[strategy]
-	return perf, exists
+	if !exists {
+		return nil, false
+	}
+	c := *perf
+	return &c, true
 }
 
-// GetAllPerformance 获取所有策略性能
+// GetAllPerformance 获取所有策略性能（副本）
 func (qr *QueryRouter) GetAllPerformance() map[string]*StrategyPerformance {
-	return qr.performanceData
+	qr.mu.RLock()
+	defer qr.mu.RUnlock()
+
+	result := make(map[string]*StrategyPerformance, len(qr.performanceData))
+	for k, v := range qr.performanceData {
+		c := *v
+		result[k] = &c
+	}
+	return result
 }
 
 // GetStrategy 获取策略信息
@@ -3,6 +3,7 @@ package adaptive
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // SelfRAGStrategy Self-RAG 策略接口
@@ -81,6 +82,28 @@ type SelfRAGConfig struct {
 
 	// EnableReflection 是否启用反思
 	EnableReflection bool
+
+	// MinImprovement 一轮重新检索的得分提升低于该值时提前结束，0 表示不检查
+	MinImprovement float64
+
+	// MaxDuration 反思循环总耗时上限，0 表示不限制
+	MaxDuration time.Duration
+}
+
+// ApplyBudget 用配置的反思预算覆盖默认值，预算中未设置的项保持不变
+func (c *SelfRAGConfig) ApplyBudget(budget ReflectionBudget) {
+	if budget.MaxRetries > 0 {
+		c.MaxRetries = budget.MaxRetries
+	}
+	if budget.MinScore > 0 {
+		c.MinScore = budget.MinScore
+	}
+	if budget.MinImprovement > 0 {
+		c.MinImprovement = budget.MinImprovement
+	}
+	if budget.MaxDuration > 0 {
+		c.MaxDuration = budget.MaxDuration
+	}
 }
 
 // DefaultSelfRAGConfig 返回默认配置
@@ -119,6 +142,16 @@ func NewSelfReflectiveRAG(llm LLMProvider, config SelfRAGConfig) (*SelfReflectiv
 	}, nil
 }
 
+// Budget 本次请求的反思预算：配置值经上下文中的请求级预算收紧
+func (sr *SelfReflectiveRAG) Budget(ctx context.Context) ReflectionBudget {
+	return budgetFromContext(ctx, ReflectionBudget{
+		MaxRetries:     sr.config.MaxRetries,
+		MinScore:       sr.config.MinScore,
+		MinImprovement: sr.config.MinImprovement,
+		MaxDuration:    sr.config.MaxDuration,
+	})
+}
+
 // EvaluateRetrieval 评估检索质量
 func (sr *SelfReflectiveRAG) EvaluateRetrieval(ctx context.Context, query string, retrievedDocs []string) (float64, error) {
 	if len(retrievedDocs) == 0 {
@@ -0,0 +1,311 @@
+package adaptive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/idgen"
+)
+
+// ErrTrajectoryNotFound 轨迹不存在
+var ErrTrajectoryNotFound = errors.New("trajectory not found")
+
+// 代理执行模式，记录在轨迹中
+const (
+	ModeReAct          = "react"
+	ModePlanAndExecute = "plan_and_execute"
+)
+
+// Trajectory 一次代理式查询的完整轨迹（思考、行动、观察和最终答案）
+// 用于复盘推理失败的原因，以及从成功的运行中构建微调数据集
+type Trajectory struct {
+	ID           string        `json:"id"`
+	Query        string        `json:"query"`
+	Mode         string        `json:"mode"`
+	Thoughts     []Thought     `json:"thoughts,omitempty"`
+	Actions      []Action      `json:"actions,omitempty"`
+	Observations []Observation `json:"observations,omitempty"`
+	Answer       string        `json:"answer,omitempty"`
+	Iterations   int           `json:"iterations"`
+	Confidence   float64       `json:"confidence"`
+	Completed    bool          `json:"completed"`
+	Success      bool          `json:"success"`
+	StopReason   string        `json:"stop_reason,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	DurationMs   int64         `json:"duration_ms"`
+}
+
+// TrajectoryStep 轨迹中的一步，按迭代对齐思考、行动和观察
+// Plan-and-Execute 模式没有思考，Thought 为 nil
+type TrajectoryStep struct {
+	Iteration   int          `json:"iteration"`
+	Thought     *Thought     `json:"thought,omitempty"`
+	Action      *Action      `json:"action,omitempty"`
+	Observation *Observation `json:"observation,omitempty"`
+}
+
+// Steps 按执行顺序重放轨迹
+func (t *Trajectory) Steps() []TrajectoryStep {
+	n := len(t.Actions)
+	if len(t.Thoughts) > n {
+		n = len(t.Thoughts)
+	}
+	if len(t.Observations) > n {
+		n = len(t.Observations)
+	}
+
+	steps := make([]TrajectoryStep, n)
+	for i := range steps {
+		steps[i].Iteration = i + 1
+		if i < len(t.Thoughts) {
+			steps[i].Thought = &t.Thoughts[i]
+		}
+		if i < len(t.Actions) {
+			steps[i].Action = &t.Actions[i]
+		}
+		if i < len(t.Observations) {
+			steps[i].Observation = &t.Observations[i]
+		}
+	}
+	return steps
+}
+
+// newTrajectory 根据代理状态创建轨迹
+func newTrajectory(state *AgentState, mode string, startedAt time.Time, runErr error) *Trajectory {
+	t := &Trajectory{
+		ID:           idgen.New(idgen.PrefixTrajectory),
+		Query:        state.Query,
+		Mode:         mode,
+		Thoughts:     append([]Thought(nil), state.Thoughts...),
+		Actions:      append([]Action(nil), state.Actions...),
+		Observations: append([]Observation(nil), state.Observations...),
+		Answer:       state.Answer,
+		Iterations:   state.Iterations,
+		Confidence:   state.Confidence,
+		Completed:    state.Completed,
+		StopReason:   state.StopReason,
+		StartedAt:    startedAt,
+		DurationMs:   time.Since(startedAt).Milliseconds(),
+	}
+	if runErr != nil {
+		t.Error = runErr.Error()
+	}
+	t.Success = runErr == nil && t.Answer != "" && t.Answer != answerFailedText && lastObservationOK(t.Observations)
+	return t
+}
+
+// lastObservationOK 最后一次观察是否成功
+func lastObservationOK(observations []Observation) bool {
+	return len(observations) > 0 && observations[len(observations)-1].Type != "error"
+}
+
+// TrajectoryFilter 轨迹查询条件
+type TrajectoryFilter struct {
+	SuccessOnly bool // 只返回成功的轨迹
+	Limit       int  // 最多返回条数，0表示不限制
+}
+
+// TrajectoryStore 轨迹存储
+type TrajectoryStore interface {
+	// Save 保存轨迹
+	Save(ctx context.Context, trajectory *Trajectory) error
+
+	// Get 获取轨迹
+	Get(ctx context.Context, id string) (*Trajectory, error)
+
+	// List 按时间倒序列出轨迹
+	List(ctx context.Context, filter TrajectoryFilter) ([]*Trajectory, error)
+}
+
+// MemoryTrajectoryStore 内存轨迹存储（保留最近N条）
+type MemoryTrajectoryStore struct {
+	mu           sync.RWMutex
+	trajectories map[string]*Trajectory
+	order        []string
+	maxEntries   int
+}
+
+// NewMemoryTrajectoryStore 创建内存轨迹存储，maxEntries<=0时不限制条数
+func NewMemoryTrajectoryStore(maxEntries int) *MemoryTrajectoryStore {
+	return &MemoryTrajectoryStore{
+		trajectories: make(map[string]*Trajectory),
+		order:        make([]string, 0),
+		maxEntries:   maxEntries,
+	}
+}
+
+// Save 保存轨迹
+func (s *MemoryTrajectoryStore) Save(ctx context.Context, trajectory *Trajectory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.trajectories[trajectory.ID]; !ok {
+		s.order = append(s.order, trajectory.ID)
+	}
+	s.trajectories[trajectory.ID] = trajectory
+	if s.maxEntries > 0 && len(s.order) > s.maxEntries {
+		for _, id := range s.order[:len(s.order)-s.maxEntries] {
+			delete(s.trajectories, id)
+		}
+		s.order = append([]string(nil), s.order[len(s.order)-s.maxEntries:]...)
+	}
+	return nil
+}
+
+// Get 获取轨迹
+func (s *MemoryTrajectoryStore) Get(ctx context.Context, id string) (*Trajectory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trajectory, ok := s.trajectories[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTrajectoryNotFound, id)
+	}
+	return trajectory, nil
+}
+
+// List 按时间倒序列出轨迹
+func (s *MemoryTrajectoryStore) List(ctx context.Context, filter TrajectoryFilter) ([]*Trajectory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Trajectory, 0)
+	for i := len(s.order) - 1; i >= 0; i-- {
+		trajectory := s.trajectories[s.order[i]]
+		if filter.SuccessOnly && !trajectory.Success {
+			continue
+		}
+		result = append(result, trajectory)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// FileTrajectoryStore 文件轨迹存储
+// 每条轨迹一个JSON文件，启动时按开始时间加载已有轨迹
+type FileTrajectoryStore struct {
+	*MemoryTrajectoryStore
+	dir string
+	mu  sync.Mutex // 串行化文件写入
+}
+
+// NewFileTrajectoryStore 创建文件轨迹存储
+func NewFileTrajectoryStore(dir string) (*FileTrajectoryStore, error) {
+	if dir == "" {
+		dir = "./data/trajectories"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trajectory directory: %w", err)
+	}
+
+	s := &FileTrajectoryStore{
+		MemoryTrajectoryStore: NewMemoryTrajectoryStore(0),
+		dir:                   dir,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 加载已有轨迹
+func (s *FileTrajectoryStore) load() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	trajectories := make([]*Trajectory, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read trajectory: %w", err)
+		}
+		var trajectory Trajectory
+		if err := json.Unmarshal(data, &trajectory); err != nil {
+			return fmt.Errorf("failed to decode trajectory %s: %w", filepath.Base(file), err)
+		}
+		trajectories = append(trajectories, &trajectory)
+	}
+	sort.SliceStable(trajectories, func(i, j int) bool {
+		return trajectories[i].StartedAt.Before(trajectories[j].StartedAt)
+	})
+
+	ctx := context.Background()
+	for _, trajectory := range trajectories {
+		_ = s.MemoryTrajectoryStore.Save(ctx, trajectory)
+	}
+	return nil
+}
+
+// Save 保存轨迹并写入文件
+func (s *FileTrajectoryStore) Save(ctx context.Context, trajectory *Trajectory) error {
+	data, err := json.MarshalIndent(trajectory, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trajectory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	path := filepath.Join(s.dir, filepath.Base(trajectory.ID)+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write trajectory: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write trajectory: %w", err)
+	}
+
+	return s.MemoryTrajectoryStore.Save(ctx, trajectory)
+}
+
+// fineTuningMessage 微调样本中的一条消息
+type fineTuningMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ExportFineTuning 将成功的轨迹导出为JSONL微调数据集，每行一条 {"messages": [...]} 样本
+// 思考与行动作为assistant消息，观察作为user消息，最后一条assistant消息为答案；返回导出的条数
+func ExportFineTuning(w io.Writer, trajectories []*Trajectory) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	for _, t := range trajectories {
+		if !t.Success {
+			continue
+		}
+
+		messages := []fineTuningMessage{{Role: "user", Content: t.Query}}
+		for _, step := range t.Steps() {
+			if step.Action != nil {
+				content := fmt.Sprintf("Action: %s[%s]", step.Action.Tool, step.Action.Input)
+				if step.Thought != nil {
+					content = "Thought: " + step.Thought.Content + "\n" + content
+				}
+				messages = append(messages, fineTuningMessage{Role: "assistant", Content: content})
+			}
+			if step.Observation != nil {
+				messages = append(messages, fineTuningMessage{Role: "user", Content: "Observation: " + step.Observation.Content})
+			}
+		}
+		messages = append(messages, fineTuningMessage{Role: "assistant", Content: t.Answer})
+
+		if err := encoder.Encode(map[string]interface{}{"messages": messages}); err != nil {
+			return count, fmt.Errorf("failed to write training example: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
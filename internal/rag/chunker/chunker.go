@@ -3,6 +3,7 @@ package chunker
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -13,6 +14,7 @@ const (
 )
 
 // Chunker 文本分块器
+// 分块基于原文的字节偏移计算，返回的分块是原文的子串，不会复制文本
 type Chunker struct {
 	chunkSize int
 	overlap   int
@@ -28,90 +30,132 @@ func NewChunker(chunkSize, overlap int) *Chunker {
 
 // Split 将文本分成多个块
 func (c *Chunker) Split(text string) []string {
-	if len(text) <= c.chunkSize {
-		return []string{text}
-	}
+	return Texts(text, c.Spans(text))
+}
 
-	var chunks []string
-	runes := []rune(text) // 支持中文
-	start := 0
+// Spans 计算分块区间：每块不超过chunkSize个字符，
+// 优先在块后半部分的句子结束符之后断开，其次在空白处断开，相邻块重叠overlap个字符
+func (c *Chunker) Spans(text string) []Span {
+	var spans []Span
+	c.walk(text, func(s Span) bool {
+		spans = append(spans, s)
+		return true
+	})
+	return spans
+}
 
-	for start < len(runes) {
-		end := start + c.chunkSize
-		if end > len(runes) {
-			end = len(runes)
+// Each 依次处理每个分块，不保存全部分块，适合很大的文档；fn返回错误时停止并返回该错误
+func (c *Chunker) Each(text string, fn func(i int, chunk string) error) error {
+	var err error
+	i := 0
+	c.walk(text, func(s Span) bool {
+		if err = fn(i, s.Text(text)); err != nil {
+			return false
 		}
+		i++
+		return true
+	})
+	return err
+}
 
-		// 尝试在句子边界分割
-		chunk := c.findSentenceBoundary(runes[start:end])
-
-		chunks = append(chunks, strings.TrimSpace(chunk))
+// walk 按顺序产生分块区间，yield返回false时停止
+func (c *Chunker) walk(text string, yield func(Span) bool) {
+	size := c.chunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	overlap := c.overlap
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
 
-		// 移动到下一个块，考虑重叠
-		start += len([]rune(chunk)) - c.overlap
-		if start < 0 {
-			start = 0
+	start := 0
+	for start < len(text) {
+		end := forward(text, start, size)
+		if end < len(text) {
+			end = findBoundary(text, forward(text, start, size/2), end)
 		}
-	}
 
-	return chunks
-}
+		if span := trimSpan(text, start, end); span.Len() > 0 {
+			if !yield(span) {
+				return
+			}
+		}
+		if end >= len(text) {
+			return
+		}
 
-// findSentenceBoundary 在句子边界分割
-func (c *Chunker) findSentenceBoundary(runes []rune) string {
-	if len(runes) <= c.chunkSize {
-		return string(runes)
+		// 移动到下一个块，考虑重叠；重叠后没有前进时不重叠
+		next := backward(text, end, overlap, start)
+		if next <= start {
+			next = end
+		}
+		start = next
 	}
+}
 
-	// 从后往前找句子结束符
-	sentenceEnders := []rune{'。', '！', '？', '.', '!', '?', '\n', '\r'}
-
-	for i := len(runes) - 1; i >= 0; i-- {
-		r := runes[i]
-		for _, ender := range sentenceEnders {
-			if r == ender {
-				return string(runes[:i+1])
-			}
+// findBoundary 在(lo, end]中从后往前找断点：句子结束符或换行之后，其次是空白之前，都没有时在end处截断
+func findBoundary(text string, lo, end int) int {
+	for i := end; i > lo; {
+		r, size := utf8.DecodeLastRuneInString(text[lo:i])
+		if isSentenceEnd(r) || r == '\n' || r == '\r' {
+			return i
 		}
+		i -= size
 	}
 
-	// 如果找不到句子边界，尝试在空格分割
-	for i := len(runes) - 1; i >= 0; i-- {
-		if unicode.IsSpace(runes[i]) {
-			return string(runes[:i])
+	for i := end; i > lo; {
+		r, size := utf8.DecodeLastRuneInString(text[lo:i])
+		i -= size
+		if unicode.IsSpace(r) && i > lo {
+			return i
 		}
 	}
 
-	// 实在找不到，强制截断
-	return string(runes[:c.chunkSize])
+	return end
 }
 
 // SplitByParagraph 按段落分割
 func (c *Chunker) SplitByParagraph(text string) []string {
-	paragraphs := strings.Split(text, "\n\n")
-	var chunks []string
+	return Texts(text, c.ParagraphSpans(text))
+}
 
-	for _, para := range paragraphs {
-		para = strings.TrimSpace(para)
-		if para == "" {
-			continue
+// ParagraphSpans 按空行分段，超过chunkSize个字符的段落再按Spans分块
+func (c *Chunker) ParagraphSpans(text string) []Span {
+	var spans []Span
+	for start := 0; start < len(text); {
+		end := strings.Index(text[start:], "\n\n")
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
 		}
 
-		// 如果段落过长，继续分割
-		if len(para) > c.chunkSize {
-			subChunks := c.Split(para)
-			chunks = append(chunks, subChunks...)
-		} else {
-			chunks = append(chunks, para)
+		para := trimSpan(text, start, end)
+		if para.Len() > 0 {
+			// 如果段落过长，继续分割
+			if utf8.RuneCountInString(para.Text(text)) > c.chunkSize {
+				for _, s := range c.Spans(para.Text(text)) {
+					spans = append(spans, s.shift(para.Start))
+				}
+			} else {
+				spans = append(spans, para)
+			}
 		}
+		start = end + 2
 	}
 
-	return chunks
+	return spans
 }
 
 // GetChunkCount 获取分块数量
 func (c *Chunker) GetChunkCount(text string) int {
-	return len(c.Split(text))
+	count := 0
+	c.walk(text, func(Span) bool {
+		count++
+		return true
+	})
+	return count
 }
 
 // MergeChunks 合并分块
@@ -2,7 +2,10 @@ package chunker
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"ai-agent-assistant/pkg/models"
 )
@@ -154,3 +157,92 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// TestChunkerSpans 测试分块区间：大小、重叠、句子边界和覆盖原文
+func TestChunkerSpans(t *testing.T) {
+	text := strings.Repeat("这是一个用于分块的句子。Another sentence here! ", 40)
+	c := NewChunker(50, 10)
+
+	spans := c.Spans(text)
+	if len(spans) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(spans))
+	}
+	chunks := c.Split(text)
+	if len(chunks) != len(spans) || c.GetChunkCount(text) != len(spans) {
+		t.Fatalf("Split/GetChunkCount disagree with Spans: %d %d %d", len(chunks), c.GetChunkCount(text), len(spans))
+	}
+
+	covered := 0
+	for i, s := range spans {
+		chunk := s.Text(text)
+		if chunks[i] != chunk {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], chunk)
+		}
+		if n := utf8.RuneCountInString(chunk); n == 0 || n > 50 {
+			t.Errorf("chunk %d has %d runes", i, n)
+		}
+		if !utf8.ValidString(chunk) || strings.TrimSpace(chunk) != chunk {
+			t.Errorf("chunk %d is not a trimmed valid string: %q", i, chunk)
+		}
+		if i < len(spans)-1 {
+			if r, _ := utf8.DecodeLastRuneInString(chunk); !isSentenceEnd(r) {
+				t.Errorf("chunk %d should end at a sentence boundary: %q", i, chunk)
+			}
+			if spans[i+1].Start >= s.End || spans[i+1].Start <= s.Start {
+				t.Errorf("chunk %d and %d should overlap: %v %v", i, i+1, s, spans[i+1])
+			}
+		}
+		if s.Start > covered && strings.TrimSpace(text[covered:s.Start]) != "" {
+			t.Errorf("text between %d and %d not covered", covered, s.Start)
+		}
+		covered = s.End
+	}
+	if strings.TrimSpace(text[covered:]) != "" {
+		t.Errorf("tail not covered: %q", text[covered:])
+	}
+
+	// 短文本、空白文本和没有断点的长文本
+	if got := c.Split("  短文本  "); len(got) != 1 || got[0] != "短文本" {
+		t.Errorf("short text = %q", got)
+	}
+	if got := c.Split(" \n "); len(got) != 0 {
+		t.Errorf("blank text = %q", got)
+	}
+	if got := NewChunker(10, 9).Split(strings.Repeat("字", 95)); len(got) != 86 {
+		t.Errorf("unbroken text split into %d chunks", len(got))
+	}
+
+	// Each在出错时停止
+	stop := errors.New("stop")
+	n := 0
+	err := c.Each(text, func(i int, chunk string) error {
+		if i != n || chunk != chunks[i] {
+			t.Errorf("Each chunk %d = %q", i, chunk)
+		}
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || n != 3 {
+		t.Errorf("Each = %v after %d chunks", err, n)
+	}
+
+	paras := NewChunker(20, 0).SplitByParagraph("第一段。\n\n\n\n" + strings.Repeat("第二段很长。", 8) + "\n\n  ")
+	if len(paras) < 3 || paras[0] != "第一段。" {
+		t.Errorf("paragraphs = %q", paras)
+	}
+}
+
+// BenchmarkChunkerSplit 测试大文档分块的内存分配
+func BenchmarkChunkerSplit(b *testing.B) {
+	text := strings.Repeat("检索增强生成把文档切成小块后向量化。Chunks are embedded and stored. ", 100000)
+	c := NewChunker(DefaultChunkSize, DefaultOverlap)
+	b.SetBytes(int64(len(text)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Split(text)
+	}
+}
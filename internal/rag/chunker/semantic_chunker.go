@@ -4,9 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"regexp"
-	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"ai-agent-assistant/internal/llm"
 )
@@ -43,10 +42,15 @@ func NewSemanticChunker(embeddingModel llm.Model, threshold float64, maxChunkSiz
 
 // Split 将文本按语义分块
 func (sc *SemanticChunker) Split(text string) []string {
+	return Texts(text, sc.Spans(text))
+}
+
+// Spans 计算语义分块区间，分块是原文中连续的句子
+func (sc *SemanticChunker) Spans(text string) []Span {
 	// 1. 分句
-	sentences := sc.splitSentences(text)
+	sentences := sentenceSpans(text)
 	if len(sentences) == 0 {
-		return []string{text}
+		return []Span{{Start: 0, End: len(text)}}
 	}
 
 	// 2. 如果只有一个句子，直接返回
@@ -56,34 +60,50 @@ func (sc *SemanticChunker) Split(text string) []string {
 
 	// 3. 计算所有句子的embedding
 	ctx := context.Background()
-	embeddings, err := sc.computeEmbeddings(ctx, sentences)
+	embeddings, err := sc.computeEmbeddings(ctx, Texts(text, sentences))
 	if err != nil {
 		// 如果embedding失败，回退到固定大小分块
 		fallback := &FixedChunker{maxSize: sc.maxChunkSize, overlap: 50}
-		return fallback.Split(text)
+		return fallback.Spans(text)
 	}
 
 	// 4. 基于相似度分块
 	chunks := sc.groupBySimilarity(sentences, embeddings)
 
 	// 5. 合并过小的chunk并拆分过大的chunk
-	chunks = sc.optimizeChunks(chunks)
-
-	return chunks
+	return sc.optimizeChunks(text, chunks)
 }
 
 // splitSentences 将文本分割成句子
 func (sc *SemanticChunker) splitSentences(text string) []string {
-	// 使用正则表达式分句（支持中英文）
-	re := regexp.MustCompile(`([。！？.!?]+)\s*`)
-	matches := re.Split(text, -1)
-
-	sentences := make([]string, 0, len(matches))
-	for _, sentence := range matches {
-		sentence = strings.TrimSpace(sentence)
-		if sentence != "" {
+	return Texts(text, sentenceSpans(text))
+}
+
+// sentenceSpans 按中英文句末标点分句，标点保留在句子中
+func sentenceSpans(text string) []Span {
+	var sentences []Span
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if !isSentenceEnd(r) {
+			continue
+		}
+		// 连续的标点属于同一句
+		for i < len(text) {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			if !isSentenceEnd(r) {
+				break
+			}
+			i += size
+		}
+		if sentence := trimSpan(text, start, i); sentence.Len() > 0 {
 			sentences = append(sentences, sentence)
 		}
+		start = i
+	}
+	if sentence := trimSpan(text, start, len(text)); sentence.Len() > 0 {
+		sentences = append(sentences, sentence)
 	}
 
 	return sentences
@@ -125,17 +145,10 @@ func (sc *SemanticChunker) computeEmbeddings(ctx context.Context, sentences []st
 	return embeddings, nil
 }
 
-// groupBySimilarity 基于相似度将句子分组
-func (sc *SemanticChunker) groupBySimilarity(sentences []string, embeddings [][]float64) []string {
-	chunks := make([]string, 0)
-	currentChunk := make([]string, 0)
-	currentChunkSize := 0
-
-	// 添加第一个句子
-	if len(sentences) > 0 {
-		currentChunk = append(currentChunk, sentences[0])
-		currentChunkSize = len(sentences[0])
-	}
+// groupBySimilarity 基于相似度将相邻句子合并为一个区间
+func (sc *SemanticChunker) groupBySimilarity(sentences []Span, embeddings [][]float64) []Span {
+	chunks := make([]Span, 0)
+	current := sentences[0]
 
 	// 遍历后续句子
 	for i := 1; i < len(sentences); i++ {
@@ -144,52 +157,37 @@ func (sc *SemanticChunker) groupBySimilarity(sentences []string, embeddings [][]
 
 		// 判断是否应该开始新的chunk
 		shouldStartNewChunk := similarity < sc.threshold ||
-			currentChunkSize+len(sentences[i]) > sc.maxChunkSize
+			sentences[i].End-current.Start > sc.maxChunkSize
 
 		if shouldStartNewChunk {
-			// 保存当前chunk
-			if len(currentChunk) > 0 {
-				chunks = append(chunks, strings.Join(currentChunk, ""))
-			}
-
-			// 开始新的chunk
-			currentChunk = []string{sentences[i]}
-			currentChunkSize = len(sentences[i])
+			// 保存当前chunk，开始新的chunk
+			chunks = append(chunks, current)
+			current = sentences[i]
 		} else {
 			// 添加到当前chunk
-			currentChunk = append(currentChunk, sentences[i])
-			currentChunkSize += len(sentences[i])
+			current.End = sentences[i].End
 		}
 	}
 
 	// 添加最后一个chunk
-	if len(currentChunk) > 0 {
-		chunks = append(chunks, strings.Join(currentChunk, ""))
-	}
-
-	return chunks
+	return append(chunks, current)
 }
 
 // optimizeChunks 优化chunks：合并过小的，拆分过大的
-func (sc *SemanticChunker) optimizeChunks(chunks []string) []string {
-	optimized := make([]string, 0, len(chunks))
+func (sc *SemanticChunker) optimizeChunks(text string, chunks []Span) []Span {
+	optimized := make([]Span, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		if len(chunk) > sc.maxChunkSize {
-			// 拆分过大的chunk
-			subChunks := sc.splitLargeChunk(chunk)
-			optimized = append(optimized, subChunks...)
-		} else if len(chunk) < 100 {
-			// 合并过小的chunk到前一个或后一个
-			if len(optimized) > 0 && len(optimized[len(optimized)-1])+len(chunk) < sc.maxChunkSize {
-				// 合并到前一个chunk
-				optimized[len(optimized)-1] += chunk
-			} else {
-				// 不能合并，直接添加
-				optimized = append(optimized, chunk)
+		if chunk.Len() > sc.maxChunkSize {
+			// 超长的单个句子按固定大小拆分
+			fixed := &FixedChunker{maxSize: sc.maxChunkSize}
+			for _, sub := range fixed.Spans(chunk.Text(text)) {
+				optimized = append(optimized, sub.shift(chunk.Start))
 			}
+		} else if last := len(optimized) - 1; chunk.Len() < 100 && last >= 0 && chunk.End-optimized[last].Start < sc.maxChunkSize {
+			// 过小的chunk合并到前一个
+			optimized[last].End = chunk.End
 		} else {
-			// 大小合适的chunk
 			optimized = append(optimized, chunk)
 		}
 	}
@@ -197,39 +195,15 @@ func (sc *SemanticChunker) optimizeChunks(chunks []string) []string {
 	return optimized
 }
 
-// splitLargeChunk 拆分过大的chunk
-func (sc *SemanticChunker) splitLargeChunk(chunk string) []string {
-	sentences := sc.splitSentences(chunk)
-	result := make([]string, 0)
-	currentChunk := ""
-	currentSize := 0
-
-	for _, sentence := range sentences {
-		if currentSize+len(sentence) > sc.maxChunkSize && currentChunk != "" {
-			result = append(result, currentChunk)
-			currentChunk = sentence
-			currentSize = len(sentence)
-		} else {
-			currentChunk += sentence
-			currentSize += len(sentence)
-		}
-	}
-
-	if currentChunk != "" {
-		result = append(result, currentChunk)
-	}
-
-	return result
-}
-
-// cosineSimilarity 计算余弦相似度
+// cosineSimilarity 计算余弦相似度，维度不同时按共同的维度计算
 func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		return 0
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
 	}
 
 	var dotProduct, normA, normB float64
-	for i := 0; i < len(a); i++ {
+	for i := 0; i < n; i++ {
 		dotProduct += a[i] * b[i]
 		normA += a[i] * a[i]
 		normB += b[i] * b[i]
@@ -250,27 +224,40 @@ type FixedChunker struct {
 
 // Split 固定大小分块
 func (fc *FixedChunker) Split(text string) []string {
-	if len(text) <= fc.maxSize {
-		return []string{text}
-	}
+	return Texts(text, fc.Spans(text))
+}
 
-	chunks := make([]string, 0)
-	start := 0
+// Spans 按maxSize字节切分，切分点对齐到字符边界，相邻块重叠overlap字节
+func (fc *FixedChunker) Spans(text string) []Span {
+	if fc.maxSize <= 0 || len(text) <= fc.maxSize {
+		return []Span{{Start: 0, End: len(text)}}
+	}
+	overlap := fc.overlap
+	if overlap < 0 || overlap >= fc.maxSize {
+		overlap = 0
+	}
 
-	for start < len(text) {
+	spans := make([]Span, 0, len(text)/(fc.maxSize-overlap)+1)
+	for start := 0; start < len(text); {
 		end := start + fc.maxSize
-		if end > len(text) {
-			end = len(text)
+		if end >= len(text) {
+			spans = append(spans, Span{Start: start, End: len(text)})
+			break
 		}
+		end = runeStart(text, end)
+		if end <= start {
+			// maxSize小于一个字符
+			end = forward(text, start, 1)
+		}
+		spans = append(spans, Span{Start: start, End: end})
 
-		chunks = append(chunks, text[start:end])
-
-		// 移动start位置，保留overlap
-		start = end - fc.overlap
-		if start < 0 {
-			start = 0
+		// 移动start位置，保留overlap；重叠后没有前进时不重叠
+		next := runeStart(text, end-overlap)
+		if next <= start {
+			next = end
 		}
+		start = next
 	}
 
-	return chunks
+	return spans
 }
@@ -0,0 +1,91 @@
+package chunker
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span 分块在原文中的字节区间[Start, End)
+// 分块过程只计算区间，需要内容时再通过Text取原文的子串，不复制文本
+type Span struct {
+	Start int
+	End   int
+}
+
+// Len 区间的字节数
+func (s Span) Len() int {
+	return s.End - s.Start
+}
+
+// Text 返回区间对应的原文子串（与原文共享内存）
+func (s Span) Text(text string) string {
+	return text[s.Start:s.End]
+}
+
+// shift 把相对于子串的区间换算为相对于原文的区间
+func (s Span) shift(offset int) Span {
+	return Span{Start: s.Start + offset, End: s.End + offset}
+}
+
+// Texts 把一组区间转换为原文子串
+func Texts(text string, spans []Span) []string {
+	texts := make([]string, len(spans))
+	for i, s := range spans {
+		texts[i] = s.Text(text)
+	}
+	return texts
+}
+
+// trimSpan 去掉区间首尾的空白字符
+func trimSpan(text string, start, end int) Span {
+	for start < end {
+		r, size := utf8.DecodeRuneInString(text[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		start += size
+	}
+	for end > start {
+		r, size := utf8.DecodeLastRuneInString(text[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		end -= size
+	}
+	return Span{Start: start, End: end}
+}
+
+// forward 从offset向后移动n个字符，返回新的字节偏移
+func forward(text string, offset, n int) int {
+	for ; n > 0 && offset < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// backward 从offset向前移动n个字符，不早于min
+func backward(text string, offset, n, min int) int {
+	for ; n > 0 && offset > min; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[min:offset])
+		offset -= size
+	}
+	return offset
+}
+
+// runeStart 把字节偏移调整到不晚于offset的字符起点，避免从多字节字符中间截断
+func runeStart(text string, offset int) int {
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
+
+// isSentenceEnd 句子结束符
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '.', '!', '?':
+		return true
+	}
+	return false
+}
@@ -3,6 +3,8 @@ package chunking
 import (
 	"context"
 	"fmt"
+
+	"ai-agent-assistant/internal/rag/chunker"
 )
 
 // LegacyChunkerAdapter 旧版分块器适配器
@@ -28,6 +30,14 @@ func NewLegacyChunkerAdapter(chunkerType string, legacyChunker interface{}) (*Le
 // Split 实现 ChunkerStrategy 接口
 // 将旧版分块器的结果转换为新格式
 func (la *LegacyChunkerAdapter) Split(ctx context.Context, text string) ([]Chunk, error) {
+	// 能返回分块区间的旧版分块器直接取原文子串，位置准确且不复制文本
+	if spanner, ok := la.legacyChunker.(interface{ Spans(string) []chunker.Span }); ok {
+		switch la.chunkerType {
+		case "fixed", "semantic":
+			return convertSpansToChunks(text, spanner.Spans(text), la.chunkerType), nil
+		}
+	}
+
 	switch la.chunkerType {
 	case "fixed":
 		// 旧版 FixedChunker 返回 []string
@@ -88,6 +98,26 @@ func convertStringsToChunks(texts []string, chunkType string) []Chunk {
 	return chunks
 }
 
+// convertSpansToChunks 将旧版分块器的区间转换为新版 []Chunk
+func convertSpansToChunks(text string, spans []chunker.Span, chunkType string) []Chunk {
+	chunks := make([]Chunk, len(spans))
+	for i, span := range spans {
+		content := span.Text(text)
+		chunks[i] = Chunk{
+			Content: content,
+			Metadata: ChunkMetadata{
+				Index:      i,
+				StartPos:   span.Start,
+				EndPos:     span.End,
+				ChunkType:  chunkType,
+				TokenCount: estimateTokens(content),
+			},
+		}
+	}
+
+	return chunks
+}
+
 // ChunkerManager 分块器管理器
 // 提供统一的分块器创建和管理接口
 type ChunkerManager struct {
@@ -2,7 +2,9 @@ package chunking
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // TestRecursiveCharacterChunker 测试递归字符分块器
@@ -474,3 +476,31 @@ func TestChunkerConfig(t *testing.T) {
 		}
 	})
 }
+
+// TestRecursiveChunkerPositions 测试分块内容与原文位置一致且不截断多字节字符
+func TestRecursiveChunkerPositions(t *testing.T) {
+	text := strings.Repeat("第一段内容，没有句号的超长句子", 6) + "\n\n" + strings.Repeat("word ", 30) + "。" + strings.Repeat("连续汉字", 20)
+	chunker, err := NewRecursiveCharacterChunker(ChunkerConfig{ChunkSize: 40, ChunkOverlap: 6})
+	if err != nil {
+		t.Fatalf("创建分块器失败: %v", err)
+	}
+
+	chunks, err := chunker.Split(context.Background(), text)
+	if err != nil {
+		t.Fatalf("分块失败: %v", err)
+	}
+	for i, chunk := range chunks {
+		if text[chunk.Metadata.StartPos:chunk.Metadata.EndPos] != chunk.Content {
+			t.Errorf("分块 %d 的位置 [%d, %d) 与内容不一致", i, chunk.Metadata.StartPos, chunk.Metadata.EndPos)
+		}
+		if len(chunk.Content) > 40 || !utf8.ValidString(chunk.Content) {
+			t.Errorf("分块 %d 长度 %d 或编码不正确: %q", i, len(chunk.Content), chunk.Content)
+		}
+		if i > 0 && chunk.Metadata.StartPos < chunks[i-1].Metadata.StartPos {
+			t.Errorf("分块 %d 的位置早于前一块", i)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.Metadata.EndPos != len(text) {
+		t.Errorf("最后一块应到原文末尾，实际结束于 %d/%d", last.Metadata.EndPos, len(text))
+	}
+}
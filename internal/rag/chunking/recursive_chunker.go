@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"ai-agent-assistant/internal/rag/chunker"
 )
 
 // RecursiveCharacterChunker 递归字符分块器
@@ -87,26 +90,19 @@ func (rc *RecursiveCharacterChunker) Split(ctx context.Context, text string) ([]
 		}}, nil
 	}
 
-	// 递归分块
-	splits := rc.recursiveSplit(text, rc.config.Separators)
-
-	// 合并分块 (考虑 overlap)
-	chunks := rc.mergeSplits(splits)
+	// 递归分块，只计算区间，最后再取原文子串
+	spans := rc.mergeSplits(text, rc.recursiveSplit(text, 0, len(text), rc.config.Separators))
 
 	// 构建带元数据的 Chunk 对象
-	result := make([]Chunk, len(chunks))
-	position := 0
-	for i, chunkText := range chunks {
-		startPos := position
-		endPos := position + len(chunkText)
-		position = endPos - rc.config.ChunkOverlap // 考虑重叠
-
+	result := make([]Chunk, len(spans))
+	for i, span := range spans {
+		chunkText := span.Text(text)
 		result[i] = Chunk{
 			Content: chunkText,
 			Metadata: ChunkMetadata{
 				Index:      i,
-				StartPos:   startPos,
-				EndPos:     endPos,
+				StartPos:   span.Start,
+				EndPos:     span.End,
 				ChunkType:  rc.name,
 				TokenCount: estimateTokens(chunkText),
 			},
@@ -116,173 +112,184 @@ func (rc *RecursiveCharacterChunker) Split(ctx context.Context, text string) ([]
 	return result, nil
 }
 
-// recursiveSplit 递归分割文本
-func (rc *RecursiveCharacterChunker) recursiveSplit(text string, separators []string) []string {
+// recursiveSplit 递归分割text[start:end]，返回原文中的区间
+// 相邻的片段合并时区间直接延伸，片段之间的分隔符随之保留在块中
+func (rc *RecursiveCharacterChunker) recursiveSplit(text string, start, end int, separators []string) []chunker.Span {
 	// 基础情况: 如果文本足够小，直接返回
-	if len(text) <= rc.config.ChunkSize {
-		return []string{text}
+	if end-start <= rc.config.ChunkSize {
+		return []chunker.Span{{Start: start, End: end}}
 	}
 
 	// 如果没有分隔符了，强制分割
 	if len(separators) == 0 {
-		return rc.forceSplit(text)
+		return rc.forceSplit(text, start, end)
 	}
 
 	// 获取当前分隔符
 	separator := separators[0]
-
-	// 尝试用当前分隔符分割
-	var splits []string
 	if separator == "" {
 		// 字符级分割
-		splits = rc.splitByCharacter(text)
-	} else {
-		// 按分隔符分割
-		splits = strings.Split(text, separator)
+		return rc.splitByCharacter(text, start, end)
 	}
 
-	// 处理分割结果
-	var result []string
-	currentChunk := ""
-	currentSeparator := ""
+	// 按分隔符依次取出片段并合并
+	var result []chunker.Span
+	var current chunker.Span
+	open := false
+	for pos := start; pos <= end; {
+		pieceEnd, next := end, end+1
+		if idx := strings.Index(text[pos:end], separator); idx >= 0 {
+			pieceEnd = pos + idx
+			next = pieceEnd + len(separator)
+			// 如果需要保留分隔符
+			if rc.config.KeepSeparator {
+				pieceEnd = next
+			}
+		}
+		piece := chunker.Span{Start: pos, End: pieceEnd}
+		pos = next
+		if piece.Len() == 0 {
+			continue
+		}
+
+		// 如果添加这个片段后不超过大小限制
+		if open && piece.End-current.Start <= rc.config.ChunkSize {
+			current.End = piece.End
+			continue
+		}
 
-	for i, split := range splits {
-		// 如果需要保留分隔符
-		if rc.config.KeepSeparator && i < len(splits)-1 {
-			split += separator
+		// 当前 chunk 已满，保存它
+		if open {
+			result = append(result, current)
+			open = false
 		}
 
-		// 如果添加这个 split 后不超过大小限制
-		if len(currentChunk)+len(split)+len(currentSeparator) <= rc.config.ChunkSize {
-			currentChunk += currentSeparator + split
-			currentSeparator = separator
+		if piece.Len() > rc.config.ChunkSize {
+			// 单个片段就超过大小限制，使用剩余的分隔符递归分割
+			result = append(result, rc.recursiveSplit(text, piece.Start, piece.End, separators[1:])...)
 		} else {
-			// 当前 chunk 已满，保存它
-			if currentChunk != "" {
-				result = append(result, currentChunk)
-			}
-
-			// 如果单个 split 就超过大小限制，递归处理
-			if len(split) > rc.config.ChunkSize {
-				// 使用剩余的分隔符递归分割
-				recursiveSplits := rc.recursiveSplit(split, separators[1:])
-				result = append(result, recursiveSplits...)
-				currentChunk = ""
-				currentSeparator = ""
-			} else {
-				// 开始新的 chunk
-				currentChunk = split
-				currentSeparator = separator
-			}
+			// 开始新的 chunk
+			current, open = piece, true
 		}
 	}
 
 	// 添加最后一个 chunk
-	if currentChunk != "" {
-		result = append(result, currentChunk)
+	if open {
+		result = append(result, current)
 	}
 
 	return result
 }
 
-// splitByCharacter 按字符分割
-func (rc *RecursiveCharacterChunker) splitByCharacter(text string) []string {
-	runes := []rune(text)
-	var result []string
-
-	// 保持单词完整性
-	currentWord := ""
-	currentChunk := ""
-
-	for _, r := range runes {
-		if unicode.IsSpace(r) {
-			// 遇到空格，保存当前单词
-			if currentWord != "" {
-				if len(currentChunk)+len(currentWord) <= rc.config.ChunkSize {
-					currentChunk += currentWord
-				} else {
-					if currentChunk != "" {
-						result = append(result, currentChunk)
-					}
-					// 如果单个单词就超过大小，强制分割
-					if len(currentWord) > rc.config.ChunkSize {
-						for i := 0; i < len(currentWord); i += rc.config.ChunkSize {
-							end := i + rc.config.ChunkSize
-							if end > len(currentWord) {
-								end = len(currentWord)
-							}
-							result = append(result, currentWord[i:end])
-						}
-					} else {
-						currentChunk = currentWord
-					}
-				}
-				currentWord = ""
+// splitByCharacter 按字符分割，保持单词完整性，超长的单词按字符边界强制截断
+func (rc *RecursiveCharacterChunker) splitByCharacter(text string, start, end int) []chunker.Span {
+	var result []chunker.Span
+	chunkStart := start
+
+	for pos := start; pos < end; {
+		// 找到下一个单词 [wordStart, wordEnd)
+		wordStart := pos
+		for wordStart < end {
+			r, size := utf8.DecodeRuneInString(text[wordStart:end])
+			if !unicode.IsSpace(r) {
+				break
 			}
-			currentChunk += string(r)
-		} else {
-			currentWord += string(r)
+			wordStart += size
 		}
-	}
+		wordEnd := wordStart
+		for wordEnd < end {
+			r, size := utf8.DecodeRuneInString(text[wordEnd:end])
+			if unicode.IsSpace(r) {
+				break
+			}
+			wordEnd += size
+		}
+		pos = wordEnd
 
-	// 添加最后一个单词
-	if currentWord != "" {
-		currentChunk += currentWord
+		// 放不下这个单词时在单词前断开
+		if wordEnd-chunkStart > rc.config.ChunkSize && wordStart > chunkStart {
+			result = append(result, chunker.Span{Start: chunkStart, End: wordStart})
+			chunkStart = wordStart
+		}
+		// 单个单词就超过大小，强制分割
+		for wordEnd-chunkStart > rc.config.ChunkSize {
+			cut := alignRune(text, chunkStart, chunkStart+rc.config.ChunkSize)
+			result = append(result, chunker.Span{Start: chunkStart, End: cut})
+			chunkStart = cut
+		}
 	}
 
-	if currentChunk != "" {
-		result = append(result, currentChunk)
+	if chunkStart < end {
+		result = append(result, chunker.Span{Start: chunkStart, End: end})
 	}
 
 	return result
 }
 
 // forceSplit 强制分割 (当所有分隔符都无效时)
-func (rc *RecursiveCharacterChunker) forceSplit(text string) []string {
-	var result []string
+func (rc *RecursiveCharacterChunker) forceSplit(text string, start, end int) []chunker.Span {
+	var result []chunker.Span
 
-	for i := 0; i < len(text); i += rc.config.ChunkSize {
-		end := i + rc.config.ChunkSize
-		if end > len(text) {
-			end = len(text)
+	for start < end {
+		cut := end
+		if end-start > rc.config.ChunkSize {
+			cut = alignRune(text, start, start+rc.config.ChunkSize)
 		}
-		result = append(result, text[i:end])
+		result = append(result, chunker.Span{Start: start, End: cut})
+		start = cut
 	}
 
 	return result
 }
 
-// mergeSplits 合并分割结果，考虑 overlap
-func (rc *RecursiveCharacterChunker) mergeSplits(splits []string) []string {
-	if len(splits) == 0 {
-		return []string{}
+// alignRune 把切分点调整到字符起点，避免截断多字节字符；切分点不晚于offset且大于start
+func alignRune(text string, start, offset int) int {
+	for offset > start && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
 	}
+	if offset <= start {
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return offset
+}
 
+// mergeSplits 合并相邻的区间，并让每块向前延伸 overlap 字节与上一块重叠
+func (rc *RecursiveCharacterChunker) mergeSplits(text string, splits []chunker.Span) []chunker.Span {
 	// 如果没有 overlap，直接返回
-	if rc.config.ChunkOverlap == 0 {
+	if len(splits) == 0 || rc.config.ChunkOverlap == 0 {
 		return splits
 	}
 
-	var result []string
-	currentChunk := splits[0]
+	result := make([]chunker.Span, 0, len(splits))
+	current := splits[0]
 
-	for i := 1; i < len(splits); i++ {
-		// 如果当前 chunk + 下一个 split - overlap 不超过限制
-		if len(currentChunk)+len(splits[i])-rc.config.ChunkOverlap <= rc.config.ChunkSize {
-			// 合并 (保留 overlap 部分)
-			currentChunk += splits[i][rc.config.ChunkOverlap:]
-		} else {
-			// 保存当前 chunk
-			result = append(result, currentChunk)
-			// 开始新的 chunk
-			currentChunk = splits[i]
+	for _, split := range splits[1:] {
+		// 合并后不超过限制时直接延伸
+		if split.End-current.Start <= rc.config.ChunkSize {
+			current.End = split.End
+			continue
 		}
+
+		// 保存当前 chunk
+		result = append(result, current)
+
+		// 开始新的 chunk，重叠部分取上一块的末尾，延伸后超过限制时不重叠
+		start := split.Start - rc.config.ChunkOverlap
+		if start < current.Start {
+			start = current.Start
+		}
+		for start < split.Start && !utf8.RuneStart(text[start]) {
+			start++
+		}
+		if split.End-start > rc.config.ChunkSize {
+			start = split.Start
+		}
+		current = chunker.Span{Start: start, End: split.End}
 	}
 
 	// 添加最后一个 chunk
-	result = append(result, currentChunk)
-
-	return result
+	return append(result, current)
 }
 
 // Name 返回分块器名称
@@ -307,11 +314,10 @@ func (rc *RecursiveCharacterChunker) Validate() error {
 // estimateTokens 估算 Token 数量
 // 粗略估计: 中文约 1.5 字符 = 1 token, 英文约 4 字符 = 1 token
 func estimateTokens(text string) int {
-	runes := []rune(text)
 	chineseChars := 0
 	otherChars := 0
 
-	for _, r := range runes {
+	for _, r := range text {
 		if unicode.Is(unicode.Han, r) {
 			chineseChars++
 		} else {
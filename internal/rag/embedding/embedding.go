@@ -9,6 +9,7 @@ import (
 	"net/http"
 
 	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
 )
 
 // EmbeddingProvider 向量化提供者接口
@@ -114,7 +115,7 @@ func (e *GLMEmbedding) Embed(ctx context.Context, text string) ([]float64, error
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &llm.APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -171,7 +172,7 @@ func (e *GLMEmbedding) BatchEmbed(ctx context.Context, texts []string) ([][]floa
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &llm.APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -258,7 +259,7 @@ func (e *QwenEmbedding) Embed(ctx context.Context, text string) ([]float64, erro
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &llm.APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -315,7 +316,7 @@ func (e *QwenEmbedding) BatchEmbed(ctx context.Context, texts []string) ([][]flo
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &llm.APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
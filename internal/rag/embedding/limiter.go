@@ -0,0 +1,254 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// Priority 向量化调用的优先级
+type Priority int
+
+const (
+	// PriorityInteractive 对话检索等在线请求，默认优先级
+	PriorityInteractive Priority = iota
+	// PriorityBatch 文档导入等批量任务，交互请求等待时让出令牌
+	PriorityBatch
+)
+
+// String 优先级名称
+func (p Priority) String() string {
+	if p == PriorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// 限流的默认参数
+const (
+	defaultEmbeddingRate = 10.0
+	minRetryWait         = time.Millisecond
+)
+
+type priorityKey struct{}
+
+// WithPriority 设置上下文中向量化调用的优先级
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext 读取上下文中的优先级，未设置时为交互优先级
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// Limiter 向量化调用的令牌桶，带优先级
+// 有交互请求在等待时批量请求不取令牌；批量请求不能用掉最后reserve个令牌，留给突发的交互请求
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // 每秒补充的令牌数
+	burst   float64 // 令牌桶容量
+	reserve float64 // 只有交互请求能使用的令牌数
+	tokens  float64
+	last    time.Time
+
+	waiting [2]int           // 各优先级正在等待的调用数
+	granted [2]int64         // 各优先级已放行的调用数
+	waited  [2]time.Duration // 各优先级累计等待时间
+}
+
+// NewLimiter 创建限流器，rate<=0时使用默认值，burst<=0时取rate向上取整
+func NewLimiter(rate float64, burst, reserve int) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.configure(rate, burst, reserve)
+	l.tokens = l.burst
+	return l
+}
+
+// NewLimiterFromConfig 根据配置创建限流器，未启用时返回nil（不限流）
+func NewLimiterFromConfig(cfg config.EmbeddingRateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return NewLimiter(cfg.RequestsPerSecond, cfg.Burst, cfg.InteractiveReserve)
+}
+
+// configure 设置参数，reserve未设置时取容量的1/5，且至少留1个令牌给批量请求
+func (l *Limiter) configure(rate float64, burst, reserve int) {
+	if rate <= 0 {
+		rate = defaultEmbeddingRate
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	if reserve <= 0 {
+		reserve = burst / 5
+	}
+	if reserve >= burst {
+		reserve = burst - 1
+	}
+	l.rate = rate
+	l.burst = float64(burst)
+	l.reserve = float64(reserve)
+	l.tokens = math.Min(l.tokens, l.burst)
+}
+
+// Reconfigure 更新限流参数，已有令牌按新容量截断
+func (l *Limiter) Reconfigure(cfg config.EmbeddingRateLimitConfig) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.configure(cfg.RequestsPerSecond, cfg.Burst, cfg.InteractiveReserve)
+}
+
+// Wait 等待一个令牌，ctx取消时返回ctx的错误
+func (l *Limiter) Wait(ctx context.Context, p Priority) error {
+	if l == nil {
+		return nil
+	}
+	if p != PriorityBatch {
+		p = PriorityInteractive
+	}
+
+	start := time.Now()
+	queued := false
+	defer func() {
+		if queued {
+			l.mu.Lock()
+			l.waiting[p]--
+			l.mu.Unlock()
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		wait, ok := l.take(time.Now(), p)
+		if ok {
+			l.granted[p]++
+			l.waited[p] += time.Since(start)
+			l.mu.Unlock()
+			return nil
+		}
+		if !queued {
+			l.waiting[p]++
+			queued = true
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// take 补充令牌后尝试取一个，不足时返回建议的等待时间
+func (l *Limiter) take(now time.Time, p Priority) (time.Duration, bool) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	floor := 0.0
+	if p == PriorityBatch {
+		floor = l.reserve
+		if l.waiting[PriorityInteractive] > 0 {
+			// 交互请求优先，等它们取完令牌
+			return l.retryAfter(floor + 1), false
+		}
+	}
+	if l.tokens >= floor+1 {
+		l.tokens--
+		return 0, true
+	}
+	return l.retryAfter(floor + 1), false
+}
+
+// retryAfter 令牌数补充到target所需的时间
+func (l *Limiter) retryAfter(target float64) time.Duration {
+	wait := time.Duration((target - l.tokens) / l.rate * float64(time.Second))
+	if wait < minRetryWait {
+		wait = minRetryWait
+	}
+	return wait
+}
+
+// Stats 限流器状态：参数、当前令牌数和各优先级的等待情况
+func (l *Limiter) Stats() map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"requests_per_second": l.rate,
+		"burst":               int(l.burst),
+		"interactive_reserve": int(l.reserve),
+		"tokens":              math.Floor(l.tokens),
+	}
+	for _, p := range []Priority{PriorityInteractive, PriorityBatch} {
+		avgWait := time.Duration(0)
+		if l.granted[p] > 0 {
+			avgWait = l.waited[p] / time.Duration(l.granted[p])
+		}
+		stats[p.String()] = map[string]interface{}{
+			"waiting":     l.waiting[p],
+			"granted":     l.granted[p],
+			"avg_wait_ms": avgWait.Milliseconds(),
+		}
+	}
+	return stats
+}
+
+var (
+	sharedMu      sync.Mutex
+	sharedLimiter *Limiter
+)
+
+// SharedLimiter 返回进程内共享的限流器，所有RAG实例的向量化调用共用一个令牌桶
+// 再次调用时按新配置原地更新；未启用时返回nil
+func SharedLimiter(cfg config.EmbeddingRateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedLimiter == nil {
+		sharedLimiter = NewLimiterFromConfig(cfg)
+	} else {
+		sharedLimiter.Reconfigure(cfg)
+	}
+	return sharedLimiter
+}
+
+// rateLimitedProvider 调用前按上下文中的优先级等待令牌
+type rateLimitedProvider struct {
+	EmbeddingProvider
+	limiter *Limiter
+}
+
+// WithRateLimit 为向量化提供者加上限流，limiter为nil时原样返回
+func WithRateLimit(provider EmbeddingProvider, limiter *Limiter) EmbeddingProvider {
+	if limiter == nil {
+		return provider
+	}
+	return &rateLimitedProvider{EmbeddingProvider: provider, limiter: limiter}
+}
+
+// Embed 等待令牌后向量化
+func (p *rateLimitedProvider) Embed(ctx context.Context, text string) ([]float64, error) {
+	if err := p.limiter.Wait(ctx, PriorityFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	return p.EmbeddingProvider.Embed(ctx, text)
+}
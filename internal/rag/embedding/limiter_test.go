@@ -0,0 +1,103 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+type countingProvider struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *countingProvider) Embed(ctx context.Context, text string) ([]float64, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, text)
+	p.mu.Unlock()
+	return []float64{1}, nil
+}
+
+func (p *countingProvider) GetDimension() int { return 1 }
+
+// TestLimiterPriority 测试交互请求优先于批量请求，批量请求不使用保留的令牌
+func TestLimiterPriority(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(50, 5, 2)
+	inner := &countingProvider{}
+	provider := WithRateLimit(inner, limiter)
+	batchCtx := WithPriority(ctx, PriorityBatch)
+
+	// 批量请求只能用掉 burst-reserve 个令牌
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(batchCtx, PriorityBatch); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	short, cancel := context.WithTimeout(batchCtx, 5*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Embed(short, "batch"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("batch call should wait for reserved tokens, got %v", err)
+	}
+	// 保留的令牌留给交互请求
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Embed(ctx, "interactive"); err != nil {
+			t.Fatalf("interactive call failed: %v", err)
+		}
+	}
+
+	// 令牌耗尽后，后到的交互请求先于等待中的批量请求
+	inner.calls = nil
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		provider.Embed(batchCtx, "batch")
+	}()
+	for limiter.Stats()["batch"].(map[string]interface{})["waiting"] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider.Embed(ctx, "interactive")
+		}()
+	}
+	wg.Wait()
+
+	if len(inner.calls) != 4 || inner.calls[3] != "batch" {
+		t.Errorf("interactive calls should be served first, got %v", inner.calls)
+	}
+	stats := limiter.Stats()
+	if got := stats["interactive"].(map[string]interface{})["granted"]; got != int64(5) {
+		t.Errorf("interactive granted = %v, want 5", got)
+	}
+	if got := stats["batch"].(map[string]interface{})["granted"]; got != int64(4) {
+		t.Errorf("batch granted = %v, want 4", got)
+	}
+}
+
+// TestSharedLimiter 测试共享限流器按配置创建和更新
+func TestSharedLimiter(t *testing.T) {
+	if SharedLimiter(config.EmbeddingRateLimitConfig{}) != nil {
+		t.Fatal("disabled limiter should be nil")
+	}
+	if p := WithRateLimit(&countingProvider{}, nil); p == nil {
+		t.Fatal("provider without limiter should be returned as is")
+	}
+
+	first := SharedLimiter(config.EmbeddingRateLimitConfig{Enabled: true, RequestsPerSecond: 5})
+	second := SharedLimiter(config.EmbeddingRateLimitConfig{Enabled: true, RequestsPerSecond: 20, Burst: 40, InteractiveReserve: 10})
+	if first != second {
+		t.Fatal("SharedLimiter should return the same limiter")
+	}
+	stats := second.Stats()
+	if stats["requests_per_second"] != 20.0 || stats["burst"] != 40 || stats["interactive_reserve"] != 10 {
+		t.Errorf("unexpected stats after reconfigure: %v", stats)
+	}
+}
@@ -0,0 +1,41 @@
+package embedding
+
+import "strings"
+
+// knownDimensions 常用向量化模型的输出维度
+var knownDimensions = map[string]int{
+	"embedding-2":            1024, // 智谱 embedding-2
+	"embedding-3":            2048, // 智谱 embedding-3（默认维度）
+	"text-embedding-v1":      1536, // 千问
+	"text-embedding-v2":      1536, // 千问
+	"text-embedding-v3":      1024, // 千问（默认维度）
+	"text-embedding-3-small": 1536, // OpenAI
+	"text-embedding-3-large": 3072, // OpenAI
+	"text-embedding-ada-002": 1536, // OpenAI
+}
+
+// providerDefaultModels 提供商默认使用的向量化模型
+var providerDefaultModels = map[string]string{
+	"glm":  "embedding-2",
+	"qwen": "text-embedding-v3",
+}
+
+// ResolveModelName 将提供商名称（glm/qwen）解析为实际的向量化模型名称
+func ResolveModelName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if model, ok := providerDefaultModels[name]; ok {
+		return model
+	}
+	return name
+}
+
+// LookupDimension 查询向量化模型的维度
+func LookupDimension(model string) (int, bool) {
+	dim, ok := knownDimensions[ResolveModelName(model)]
+	return dim, ok
+}
+
+// RegisterDimension 注册自定义向量化模型的维度
+func RegisterDimension(model string, dimension int) {
+	knownDimensions[strings.ToLower(model)] = dimension
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/rag/embedding"
+	"ai-agent-assistant/internal/rag/store"
+)
+
+// resolveEmbeddingModel 实际写入向量库的向量化模型
+func resolveEmbeddingModel(cfg *config.Config, fallback string) string {
+	name := cfg.Agent.EmbeddingModel
+	if name == "" {
+		name = fallback
+	}
+	return embedding.ResolveModelName(name)
+}
+
+// verifyMilvusEmbedding 启动时校验向量化模型与Milvus集合的维度，不一致时直接失败
+// 1. 配置的维度与已知模型维度一致
+// 2. 配置中声明的集合模型与实际使用的模型一致
+// 3. 已存在集合记录的模型和维度与当前配置一致
+func verifyMilvusEmbedding(cfg *config.Config, vs *store.MilvusVectorStore, model string) error {
+	milvusCfg := cfg.VectorDB.Milvus
+
+	if milvusCfg.EmbeddingModel != "" && embedding.ResolveModelName(milvusCfg.EmbeddingModel) != model {
+		return fmt.Errorf("vectordb.milvus.embedding_model is %s but agent.embedding_model uses %s; "+
+			"they must match", milvusCfg.EmbeddingModel, model)
+	}
+
+	if dim, ok := embedding.LookupDimension(model); ok && milvusCfg.Dimension > 0 && dim != milvusCfg.Dimension {
+		return fmt.Errorf("vectordb.milvus.dimension is %d but embedding model %s produces %d-dim vectors",
+			milvusCfg.Dimension, model, dim)
+	}
+
+	vs.SetEmbeddingModel(model)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return vs.VerifyEmbedding(ctx)
+}
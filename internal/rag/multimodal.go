@@ -0,0 +1,177 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// imageMIMETypes 支持的图片格式
+var imageMIMETypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+}
+
+// markdownImagePattern Markdown图片引用 ![alt](path)
+var markdownImagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+
+// captionPrompt 图片描述提示词
+const captionPrompt = `请详细描述这张图片的内容，用于知识库检索。
+如果是图表、流程图或架构图，请说明其类型、包含的要素、数据趋势和各部分之间的关系；
+如果包含文字，请完整转写关键文字。只输出描述本身。`
+
+// IsImageFile 判断文件是否为支持的图片格式
+func IsImageFile(path string) bool {
+	_, ok := imageMIMETypes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// SetVisionModel 设置用于图片描述的视觉模型
+func (r *RAGEnhanced) SetVisionModel(model llm.Model) error {
+	if _, ok := llm.AsVisionModel(model); !ok {
+		return fmt.Errorf("model %s does not support image input", model.GetModelName())
+	}
+	r.vision = model
+	return nil
+}
+
+// CaptionImage 使用视觉模型为图片生成描述
+func (r *RAGEnhanced) CaptionImage(ctx context.Context, image llm.ImageInput, hint string) (string, error) {
+	if r.vision == nil {
+		return "", fmt.Errorf("vision model not configured")
+	}
+	vision, ok := llm.AsVisionModel(r.vision)
+	if !ok {
+		return "", fmt.Errorf("model %s does not support image input", r.vision.GetModelName())
+	}
+
+	prompt := captionPrompt
+	if hint != "" {
+		prompt += "\n图片标题：" + hint
+	}
+
+	caption, err := vision.ChatWithImages(ctx, []models.Message{
+		{Role: "user", Content: prompt},
+	}, []llm.ImageInput{image})
+	if err != nil {
+		return "", fmt.Errorf("failed to caption image: %w", err)
+	}
+
+	return strings.TrimSpace(caption), nil
+}
+
+// AddImage 为图片生成描述并将描述索引到知识库
+func (r *RAGEnhanced) AddImage(ctx context.Context, imagePath string, source string) error {
+	image, err := loadImage(imagePath)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		source = imagePath
+	}
+
+	return r.addImageCaption(ctx, image, imagePath, source, "", 0)
+}
+
+// AddDocumentWithImages 添加文档，并为文档中引用的图片/图表生成描述一起索引
+// 目前识别Markdown格式的图片引用，本地路径相对于文档所在目录解析
+func (r *RAGEnhanced) AddDocumentWithImages(ctx context.Context, docPath string) error {
+	if IsImageFile(docPath) {
+		return r.AddImage(ctx, docPath, docPath)
+	}
+
+	if err := r.AddDocument(ctx, docPath); err != nil {
+		return err
+	}
+
+	if r.vision == nil {
+		return nil
+	}
+
+	text, err := r.parser.Parse(docPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse document: %w", err)
+	}
+
+	for i, match := range markdownImagePattern.FindAllStringSubmatch(text, -1) {
+		alt, ref := match[1], match[2]
+
+		var image llm.ImageInput
+		if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+			image = llm.ImageInput{URL: ref}
+		} else {
+			imagePath := ref
+			if !filepath.IsAbs(imagePath) {
+				imagePath = filepath.Join(filepath.Dir(docPath), imagePath)
+			}
+			image, err = loadImage(imagePath)
+			if err != nil {
+				return fmt.Errorf("failed to load figure %d: %w", i, err)
+			}
+		}
+
+		if err := r.addImageCaption(ctx, image, ref, docPath, alt, i); err != nil {
+			return fmt.Errorf("failed to index figure %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// addImageCaption 生成图片描述并存储
+func (r *RAGEnhanced) addImageCaption(ctx context.Context, image llm.ImageInput, imageRef, source, alt string, index int) error {
+	caption, err := r.CaptionImage(ctx, image, alt)
+	if err != nil {
+		return err
+	}
+
+	title := alt
+	if title == "" {
+		title = filepath.Base(imageRef)
+	}
+	content := fmt.Sprintf("[图片: %s] %s", title, caption)
+
+	vector, err := r.embedding.Embed(ctx, content)
+	if err != nil {
+		return fmt.Errorf("failed to embed caption: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"source":  source,
+		"chunk":   index,
+		"type":    "image",
+		"image":   imageRef,
+		"caption": caption,
+	}
+
+	if err := r.store.Add(ctx, vector, content, metadata); err != nil {
+		return fmt.Errorf("failed to store caption: %w", err)
+	}
+
+	return nil
+}
+
+// loadImage 读取本地图片
+func loadImage(imagePath string) (llm.ImageInput, error) {
+	mimeType, ok := imageMIMETypes[strings.ToLower(filepath.Ext(imagePath))]
+	if !ok {
+		return llm.ImageInput{}, fmt.Errorf("unsupported image format: %s", imagePath)
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return llm.ImageInput{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return llm.ImageInput{Data: data, MIMEType: mimeType}, nil
+}
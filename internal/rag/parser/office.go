@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// parseDOCX 解析DOCX文件：读取word/document.xml中的文本，按段落换行
+func (p *DocumentParser) parseDOCX(filePath string) (string, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open docx: %w", err)
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("failed to read docx: %w", err)
+		}
+		defer rc.Close()
+		return extractDOCXText(rc)
+	}
+	return "", fmt.Errorf("invalid docx: word/document.xml not found")
+}
+
+// extractDOCXText 从document.xml中提取文本
+func extractDOCXText(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	var sb strings.Builder
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse docx xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextPattern   = regexp.MustCompile(`(?s)BT(.*?)ET`)
+)
+
+// extractPDFText 提取PDF中的文本（简化实现）
+// 只处理未压缩或FlateDecode压缩的内容流中以字面量字符串写出的文字（Tj/TJ/'/"），
+// 适用于大多数由文字处理软件导出的PDF；扫描件和使用CID字体的PDF无法提取
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("invalid pdf: missing header")
+	}
+
+	var sb strings.Builder
+	for _, match := range pdfStreamPattern.FindAllSubmatch(data, -1) {
+		dict, content := match[1], match[2]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, err := inflate(content)
+			if err != nil {
+				continue
+			}
+			content = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue // 其他压缩方式（图片等）跳过
+		}
+
+		for _, block := range pdfTextPattern.FindAllSubmatch(content, -1) {
+			writePDFTextBlock(&sb, block[1])
+			sb.WriteString("\n")
+		}
+	}
+
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", fmt.Errorf("no extractable text found in pdf (scanned or CID-font pdfs are not supported)")
+	}
+	return text, nil
+}
+
+// inflate 解压FlateDecode流
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// writePDFTextBlock 解析BT/ET之间的文本操作符
+func writePDFTextBlock(sb *strings.Builder, block []byte) {
+	var pending []string // 当前操作数中的字符串
+	for i := 0; i < len(block); i++ {
+		switch c := block[i]; {
+		case c == '(':
+			s, next := readPDFString(block, i)
+			pending = append(pending, s)
+			i = next
+		case c == '%':
+			for i < len(block) && block[i] != '\n' && block[i] != '\r' {
+				i++
+			}
+		case isPDFAlpha(c) || c == '\'' || c == '"' || c == '*':
+			start := i
+			for i+1 < len(block) && (isPDFAlpha(block[i+1]) || block[i+1] == '*') {
+				i++
+			}
+			op := string(block[start : i+1])
+			switch op {
+			case "Tj", "TJ":
+				sb.WriteString(strings.Join(pending, ""))
+			case "'", "\"":
+				sb.WriteString("\n")
+				sb.WriteString(strings.Join(pending, ""))
+			case "T*", "Td", "TD":
+				sb.WriteString("\n")
+			}
+			pending = pending[:0]
+		}
+	}
+}
+
+// isPDFAlpha 是否为操作符字符
+func isPDFAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// readPDFString 读取以(开头的字面量字符串，返回内容和结束位置
+func readPDFString(data []byte, start int) (string, int) {
+	var sb strings.Builder
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// 续行
+			default:
+				if e >= '0' && e <= '7' {
+					// 八进制转义，最多3位
+					v := int(e - '0')
+					for j := 0; j < 2 && i+1 < len(data) && data[i+1] >= '0' && data[i+1] <= '7'; j++ {
+						i++
+						v = v*8 + int(data[i]-'0')
+					}
+					sb.WriteByte(byte(v))
+				} else {
+					sb.WriteByte(e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				sb.WriteByte(c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return sb.String(), i
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), len(data)
+}
@@ -36,6 +36,8 @@ func (p *DocumentParser) Parse(filePath string) (string, error) {
 		return p.parseTextFile(filePath)
 	case ".pdf":
 		return p.parsePDF(filePath)
+	case ".docx":
+		return p.parseDOCX(filePath)
 	case ".json", ".yaml", ".yml", ".xml", ".html", ".htm":
 		return p.parseTextFile(filePath)
 	default:
@@ -61,11 +63,14 @@ func (p *DocumentParser) parseTextFile(filePath string) (string, error) {
 	return string(content), nil
 }
 
-// parsePDF 解析PDF文件（简化实现）
+// parsePDF 解析PDF文件（简化实现，只提取文本型PDF中的文字）
+// 生产环境应使用专门的PDF解析库如: github.com/pdfcpu/pdfcpu
 func (p *DocumentParser) parsePDF(filePath string) (string, error) {
-	// 简化实现：返回提示信息
-	// 生产环境应使用专门的PDF解析库如: github.com/pdfcpu/pdfcpu
-	return "", fmt.Errorf("PDF parsing not implemented yet. Please convert to text format or use txt/md files")
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return extractPDFText(data)
 }
 
 // ParseFromBytes 从字节数组解析文档
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseDOCX 测试DOCX文本提取
+func TestParseDOCX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.docx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>第一段</w:t></w:r><w:r><w:tab/><w:t>续写</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>第二段</w:t></w:r></w:p></w:body></w:document>`))
+	zw.Close()
+	f.Close()
+
+	text, err := NewParser().Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if text != "第一段\t续写\n第二段" {
+		t.Errorf("unexpected text: %q", text)
+	}
+}
+
+// TestExtractPDFText 测试PDF文本提取（未压缩与FlateDecode流）
+func TestExtractPDFText(t *testing.T) {
+	plain := `BT /F1 12 Tf 72 720 Td (Hello \(PDF\)) Tj 0 -14 Td [(Wor) -20 (ld)] TJ ET`
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(`BT (Deflated text) Tj ET`))
+	zw.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(plain), plain)
+	fmt.Fprintf(&pdf, "5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	text, err := extractPDFText(pdf.Bytes())
+	if err != nil {
+		t.Fatalf("extractPDFText failed: %v", err)
+	}
+	for _, want := range []string{"Hello (PDF)", "World", "Deflated text"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q should contain %q", text, want)
+		}
+	}
+
+	if _, err := extractPDFText([]byte("not a pdf")); err == nil {
+		t.Error("non-pdf data should be rejected")
+	}
+}
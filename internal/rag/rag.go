@@ -3,12 +3,16 @@ package rag
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/rag/chunker"
 	"ai-agent-assistant/internal/rag/embedding"
 	"ai-agent-assistant/internal/rag/parser"
 	"ai-agent-assistant/internal/rag/store"
+	"ai-agent-assistant/internal/tenant"
 	"ai-agent-assistant/internal/vectordb"
 )
 
@@ -17,8 +21,16 @@ type RAG struct {
 	parser    parser.Parser
 	chunker   chunker.Chunker
 	embedding embedding.EmbeddingProvider
+	limiter   *embedding.Limiter // 向量化调用的共享限流器，未启用时为nil
 	store     store.VectorStore
 	config    *config.Config
+	shadow    *Shadow        // 检索影子测试，未启用时为nil
+	quotas    *tenant.Quotas // 每个租户知识库中的文档数上限，nil表示不限制
+
+	// 多租户时每个租户使用独立的向量存储，首次访问时创建
+	newStore func(tenant string) (store.VectorStore, error)
+	mu       sync.Mutex
+	stores   map[string]store.VectorStore
 }
 
 // NewRAG 创建RAG系统
@@ -47,102 +59,189 @@ func NewRAG(cfg *config.Config) (*RAG, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding provider: %w", err)
 	}
+	limiter := embedding.SharedLimiter(cfg.RAG.EmbeddingRateLimit)
+	ep = embedding.WithRateLimit(ep, limiter)
 
 	// 初始化向量存储
 	var vs store.VectorStore
+	var newStore func(tenant string) (store.VectorStore, error)
 
 	// 根据配置选择向量存储后端
 	if cfg.VectorDB.Provider == "milvus" {
 		// 使用Milvus向量存储
-		milvusConfig := &vectordb.MilvusConfig{
-			Address:  cfg.VectorDB.Milvus.Address,
-			Username: "",
-			Password: "",
-			Database: "default",
-		}
-
-		milvusClient, err := vectordb.NewMilvusClient(milvusConfig)
+		milvusClient, err := vectordb.NewMilvusClient(vectordb.MilvusConfigFromConfig(cfg.VectorDB.Milvus))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create milvus client: %w", err)
 		}
 
-		vs = store.NewMilvusVectorStore(
+		milvusStore := store.NewMilvusVectorStore(
 			milvusClient,
 			cfg.VectorDB.Milvus.CollectionName,
 			cfg.VectorDB.Milvus.Dimension,
 		)
+		milvusStore.SetWriteOptions(store.MilvusWriteOptionsFromConfig(cfg.VectorDB.Milvus))
+		if err := verifyMilvusEmbedding(cfg, milvusStore, resolveEmbeddingModel(cfg, embeddingModel)); err != nil {
+			return nil, fmt.Errorf("embedding check failed: %w", err)
+		}
+		vs = milvusStore
+
+		// 租户的集合名为 <collection>_<tenant>
+		newStore = func(t string) (store.VectorStore, error) {
+			tenantStore := store.NewMilvusVectorStore(
+				milvusClient,
+				tenantCollection(cfg.VectorDB.Milvus.CollectionName, t),
+				cfg.VectorDB.Milvus.Dimension,
+			)
+			tenantStore.SetWriteOptions(store.MilvusWriteOptionsFromConfig(cfg.VectorDB.Milvus))
+			if err := verifyMilvusEmbedding(cfg, tenantStore, resolveEmbeddingModel(cfg, embeddingModel)); err != nil {
+				return nil, fmt.Errorf("embedding check failed: %w", err)
+			}
+			return tenantStore, nil
+		}
 	} else {
 		// 使用内存向量存储（默认）
 		vs = store.NewInMemoryVectorStore(ep)
+		newStore = func(string) (store.VectorStore, error) {
+			return store.NewInMemoryVectorStore(ep), nil
+		}
 	}
 
 	return &RAG{
 		parser:    p,
 		chunker:   *c,
 		embedding: ep,
+		limiter:   limiter,
 		store:     vs,
 		config:    cfg,
+		newStore:  newStore,
+		stores:    make(map[string]store.VectorStore),
+		shadow:    NewShadowFromConfig(cfg.RAG.Shadow, ep, chunker.DefaultChunkSize, chunker.DefaultOverlap),
+		quotas:    tenant.NewQuotasFromConfig(cfg.Tenancy),
 	}, nil
 }
 
+// tenantCollection 租户的Milvus集合名，集合名只允许字母、数字和下划线
+func tenantCollection(collection, t string) string {
+	return collection + "_" + strings.ReplaceAll(t, "-", "_")
+}
+
+// storeFor 请求租户的向量存储，未启用多租户时使用默认存储
+func (r *RAG) storeFor(ctx context.Context) (store.VectorStore, error) {
+	t := tenant.FromContext(ctx)
+	if t == "" || r.newStore == nil {
+		return r.store, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if vs, ok := r.stores[t]; ok {
+		return vs, nil
+	}
+	vs, err := r.newStore(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vector store for tenant %s: %w", t, err)
+	}
+	r.stores[t] = vs
+	return vs, nil
+}
+
 // AddDocument 添加文档到知识库
 func (r *RAG) AddDocument(ctx context.Context, docPath string) error {
+	return r.AddDocumentAs(ctx, docPath, docPath)
+}
+
+// AddDocumentAs 添加文档到知识库，检索结果中的来源记为source
+// 用于上传文件：服务端保存路径与用户可见的文件名不同
+func (r *RAG) AddDocumentAs(ctx context.Context, docPath string, source string) error {
 	// 1. 解析文档
 	text, err := r.parser.Parse(docPath)
 	if err != nil {
 		return fmt.Errorf("failed to parse document: %w", err)
 	}
 
-	// 2. 分块
-	chunks := r.chunker.Split(text)
+	// 2. 分块、向量化并存储
+	return r.AddText(ctx, text, source)
+}
 
-	// 3. 向量化并存储
-	for i, chunk := range chunks {
+// AddText 直接添加文本到知识库，租户知识库中的文档数达到配额时返回 tenant.ErrQuotaExceeded
+func (r *RAG) AddText(ctx context.Context, text string, source string) error {
+	vs, err := r.storeFor(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.checkQuota(ctx, vs, source); err != nil {
+		return err
+	}
+
+	// 1. 分块并向量化，分块是原文的子串，不复制文本
+	// 导入按批量优先级限流，对话检索的向量化优先
+	ctx = embedding.WithPriority(ctx, embedding.PriorityBatch)
+	var vectors []store.Vector
+	err = r.chunker.Each(text, func(i int, chunk string) error {
 		vector, err := r.embedding.Embed(ctx, chunk)
 		if err != nil {
 			return fmt.Errorf("failed to embed chunk %d: %w", i, err)
 		}
 
-		metadata := map[string]interface{}{
-			"source": docPath,
-			"chunk":  i,
-		}
+		vectors = append(vectors, store.Vector{
+			Data: vector,
+			Text: chunk,
+			Metadata: map[string]interface{}{
+				"source": source,
+				"chunk":  i,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		if err := r.store.Add(ctx, vector, chunk, metadata); err != nil {
-			return fmt.Errorf("failed to store chunk %d: %w", i, err)
-		}
+	// 2. 批量写入，Milvus按批插入而不是每个分块一次
+	if err := store.AddAll(ctx, vs, vectors); err != nil {
+		return fmt.Errorf("failed to store chunks: %w", err)
+	}
+
+	// 3. 启用影子测试且影子管道使用独立索引时，按影子管道的分块同时写入
+	if r.shadow != nil {
+		r.shadow.Index(ctx, text, source)
 	}
 
 	return nil
 }
 
-// AddText 直接添加文本到知识库
-func (r *RAG) AddText(ctx context.Context, text string, source string) error {
-	// 1. 分块
-	chunks := r.chunker.Split(text)
-
-	// 2. 向量化并存储
-	for i, chunk := range chunks {
-		vector, err := r.embedding.Embed(ctx, chunk)
-		if err != nil {
-			return fmt.Errorf("failed to embed chunk %d: %w", i, err)
-		}
-
-		metadata := map[string]interface{}{
-			"source": source,
-			"chunk":  i,
-		}
+// SetQuotas 设置租户配额，限制每个租户知识库中的文档数；nil表示不限制
+func (r *RAG) SetQuotas(quotas *tenant.Quotas) {
+	r.quotas = quotas
+}
 
-		if err := r.store.Add(ctx, vector, chunk, metadata); err != nil {
-			return fmt.Errorf("failed to store chunk %d: %w", i, err)
+// checkQuota 向租户知识库添加来源为source的文档前检查配额，文档按来源计数，重新导入已有的来源不占用新的配额
+func (r *RAG) checkQuota(ctx context.Context, vs store.VectorStore, source string) error {
+	t := tenant.FromContext(ctx)
+	if r.quotas == nil || r.quotas.For(t).MaxDocuments == 0 {
+		return nil
+	}
+	stats, err := store.Detailed(ctx, vs)
+	if err != nil {
+		return fmt.Errorf("failed to count documents: %w", err)
+	}
+	for _, existing := range stats.Sources {
+		if existing.Source == source {
+			return nil
 		}
 	}
-
-	return nil
+	return r.quotas.Check(t, tenant.ResourceDocuments, len(stats.Sources))
 }
 
 // Retrieve 检索相关内容
 func (r *RAG) Retrieve(ctx context.Context, query string, topK int) ([]string, error) {
+	vs, err := r.storeFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+
 	// 1. 将查询向量化
 	queryVector, err := r.embedding.Embed(ctx, query)
 	if err != nil {
@@ -150,7 +249,11 @@ func (r *RAG) Retrieve(ctx context.Context, query string, topK int) ([]string, e
 	}
 
 	// 2. 检索最相似的内容
-	results, err := r.store.Search(ctx, queryVector, topK)
+	results, err := vs.Search(ctx, queryVector, topK)
+	if r.shadow != nil {
+		// 抽样的查询在后台用影子管道再检索一次，不影响返回结果
+		r.shadow.Observe(ctx, vs, query, topK, queryVector, results, time.Since(start), err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -158,15 +261,26 @@ func (r *RAG) Retrieve(ctx context.Context, query string, topK int) ([]string, e
 	return results, nil
 }
 
+// Shadow 检索影子测试，未启用时为nil
+func (r *RAG) Shadow() *Shadow {
+	return r.shadow
+}
+
 // BuildContext 构建增强上下文
 func (r *RAG) BuildContext(ctx context.Context, query string, topK int) (string, error) {
+	context, _, err := r.BuildContextWithResults(ctx, query, topK)
+	return context, err
+}
+
+// BuildContextWithResults 构建增强上下文，同时返回检索结果（顺序与上下文中的编号一致），用于记录引用
+func (r *RAG) BuildContextWithResults(ctx context.Context, query string, topK int) (string, []string, error) {
 	results, err := r.Retrieve(ctx, query, topK)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if len(results) == 0 {
-		return "", nil
+		return "", results, nil
 	}
 
 	context := "参考信息：\n"
@@ -174,10 +288,50 @@ func (r *RAG) BuildContext(ctx context.Context, query string, topK int) (string,
 		context += fmt.Sprintf("\n[%d] %s", i+1, result)
 	}
 
-	return context, nil
+	return context, results, nil
+}
+
+// Ping 探测向量存储的连通性，内存存储始终可用
+func (r *RAG) Ping(ctx context.Context) error {
+	if pinger, ok := r.store.(store.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
 }
 
 // GetStats 获取知识库统计信息
 func (r *RAG) GetStats() map[string]interface{} {
 	return r.store.Stats()
 }
+
+// IndexStatsFor 获取请求租户知识库的索引统计：向量总数、向量化模型、分块类型分布、各来源明细、最后更新时间和存储大小
+func (r *RAG) IndexStatsFor(ctx context.Context) (*store.IndexStats, error) {
+	vs, err := r.storeFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := store.Detailed(ctx, vs)
+	if err != nil {
+		return nil, err
+	}
+	if stats.EmbeddingModel == "" {
+		stats.EmbeddingModel = resolveEmbeddingModel(r.config, "glm")
+	}
+	return stats, nil
+}
+
+// StatsFor 获取请求租户的知识库统计信息
+func (r *RAG) StatsFor(ctx context.Context) map[string]interface{} {
+	vs, err := r.storeFor(ctx)
+	if err != nil {
+		return map[string]interface{}{"status": "error", "error": err.Error()}
+	}
+	stats := vs.Stats()
+	if t := tenant.FromContext(ctx); t != "" {
+		stats["tenant"] = t
+	}
+	if r.limiter != nil {
+		stats["embedding_rate_limit"] = r.limiter.Stats()
+	}
+	return stats
+}
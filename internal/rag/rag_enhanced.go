@@ -25,9 +25,10 @@ import (
 
 // RAGResult RAG 查询结果
 type RAGResult struct {
-	Answer  string   // 生成的答案
-	Context []string // 检索到的上下文
-	Query   string   // 原始查询
+	Answer   string                 // 生成的答案
+	Context  []string               // 检索到的上下文
+	Query    string                 // 原始查询
+	Metadata map[string]interface{} // 附加信息（如token用量）
 }
 
 // RAGEnhanced 增强版RAG系统（支持语义分块、混合检索、重排序）
@@ -45,6 +46,7 @@ type RAGEnhanced struct {
 	parameterOptimizer *adaptive.ParameterOptimizer // 参数优化器
 	abTesting      *adaptive.ABTestingFramework   // A/B 测试框架
 	embedding      llm.Model                 // 使用统一的Model接口
+	vision         llm.Model                 // 图片描述使用的视觉模型（可选）
 	store          store.VectorStore
 	hybridRetriever *retriever.HybridRetriever // 混合检索器
 	reranker       reranker.Reranker            // 重排序器
@@ -57,6 +59,7 @@ type RAGEnhanced struct {
 	enableSelfRAG   bool                       // 是否启用 Self-RAG
 	enableAdaptive  bool                       // 是否启用自适应路由
 	currentChunker chunking.ChunkerStrategy    // 当前使用的分块器 (新版)
+	shadow         *Shadow                     // 检索影子测试，未启用时为nil
 }
 
 // NewRAGEnhanced 创建增强版RAG系统
@@ -106,6 +109,7 @@ func NewRAGEnhanced(cfg *config.Config, modelManager *llm.ModelManager) (*RAGEnh
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding provider: %w", err)
 	}
+	ep = embedding.WithRateLimit(ep, embedding.SharedLimiter(cfg.RAG.EmbeddingRateLimit))
 	c := chunker.NewChunker(cfg.RAG.ChunkSize, cfg.RAG.ChunkOverlap)
 
 	// 2.5 初始化新版分块器管理器
@@ -122,26 +126,34 @@ func NewRAGEnhanced(cfg *config.Config, modelManager *llm.ModelManager) (*RAGEnh
 		ragasEvaluator, _ = eval.NewRAGASEvaluator(llmProvider)
 	}
 
+	// 2.8 初始化视觉模型（多模态RAG，可选）
+	var visionModel llm.Model
+	if cfg.RAG.VisionModel != "" {
+		if vm, err := modelManager.GetModel(cfg.RAG.VisionModel); err == nil {
+			if _, ok := llm.AsVisionModel(vm); ok {
+				visionModel = vm
+			}
+		}
+	}
+
 	// 3. 初始化向量存储
 	var vs store.VectorStore
 	if cfg.VectorDB.Provider == "milvus" {
-		milvusConfig := &vectordb.MilvusConfig{
-			Address:  cfg.VectorDB.Milvus.Address,
-			Username: "",
-			Password: "",
-			Database: "default",
-		}
-
-		milvusClient, err := vectordb.NewMilvusClient(milvusConfig)
+		milvusClient, err := vectordb.NewMilvusClient(vectordb.MilvusConfigFromConfig(cfg.VectorDB.Milvus))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create milvus client: %w", err)
 		}
 
-		vs = store.NewMilvusVectorStore(
+		milvusStore := store.NewMilvusVectorStore(
 			milvusClient,
 			cfg.VectorDB.Milvus.CollectionName,
 			cfg.VectorDB.Milvus.Dimension,
 		)
+		milvusStore.SetWriteOptions(store.MilvusWriteOptionsFromConfig(cfg.VectorDB.Milvus))
+		if err := verifyMilvusEmbedding(cfg, milvusStore, resolveEmbeddingModel(cfg, embeddingModelName)); err != nil {
+			return nil, fmt.Errorf("embedding check failed: %w", err)
+		}
+		vs = milvusStore
 	} else {
 		vs = store.NewInMemoryVectorStore(ep)
 	}
@@ -174,6 +186,7 @@ func NewRAGEnhanced(cfg *config.Config, modelManager *llm.ModelManager) (*RAGEnh
 		parameterOptimizer: nil, // 可选，需要单独初始化
 		abTesting:          nil, // 可选，需要单独初始化
 		embedding:          embeddingModel,
+		vision:             visionModel,
 		store:              vs,
 		hybridRetriever:    hybridRetriever,
 		reranker:           r,
@@ -186,6 +199,7 @@ func NewRAGEnhanced(cfg *config.Config, modelManager *llm.ModelManager) (*RAGEnh
 		enableSelfRAG:      false, // 默认关闭 Self-RAG
 		enableAdaptive:     false, // 默认关闭自适应路由
 		currentChunker:     nil,  // 默认使用旧版分块器
+		shadow:             NewShadowFromConfig(cfg.RAG.Shadow, ep, cfg.RAG.ChunkSize, cfg.RAG.ChunkOverlap),
 	}, nil
 }
 
@@ -216,6 +230,8 @@ func (a *VectorStoreAdapter) Search(ctx context.Context, queryVector []float64,
 
 // AddDocumentWithSemanticChunking 使用语义分块添加文档
 func (r *RAGEnhanced) AddDocumentWithSemanticChunking(ctx context.Context, docPath string) error {
+	ctx = embedding.WithPriority(ctx, embedding.PriorityBatch)
+
 	// 1. 解析文档
 	text, err := r.parser.Parse(docPath)
 	if err != nil {
@@ -242,6 +258,9 @@ func (r *RAGEnhanced) AddDocumentWithSemanticChunking(ctx context.Context, docPa
 			return fmt.Errorf("failed to store chunk %d: %w", i, err)
 		}
 	}
+	if err := store.FlushPending(ctx, r.store); err != nil {
+		return fmt.Errorf("failed to flush chunks: %w", err)
+	}
 
 	// 4. 同时索引到BM25（用于混合检索）
 	if r.enableHybrid {
@@ -347,6 +366,8 @@ func (r *RAGEnhanced) RetrieveEnhanced(ctx context.Context, query string, topK i
 
 // AddDocument 添加文档（使用普通分块）
 func (r *RAGEnhanced) AddDocument(ctx context.Context, docPath string) error {
+	ctx = embedding.WithPriority(ctx, embedding.PriorityBatch)
+
 	text, err := r.parser.Parse(docPath)
 	if err != nil {
 		return fmt.Errorf("failed to parse document: %w", err)
@@ -369,6 +390,12 @@ func (r *RAGEnhanced) AddDocument(ctx context.Context, docPath string) error {
 			return fmt.Errorf("failed to store chunk %d: %w", i, err)
 		}
 	}
+	if err := store.FlushPending(ctx, r.store); err != nil {
+		return fmt.Errorf("failed to flush chunks: %w", err)
+	}
+	if r.shadow != nil {
+		r.shadow.Index(ctx, text, docPath)
+	}
 
 	return nil
 }
@@ -390,7 +417,12 @@ func (r *RAGEnhanced) Retrieve(ctx context.Context, query string, topK int) ([]s
 
 // BuildContext 构建上下文
 func (r *RAGEnhanced) BuildContext(ctx context.Context, query string, topK int) (string, error) {
+	start := time.Now()
 	results, err := r.RetrieveEnhanced(ctx, query, topK)
+	if r.shadow != nil {
+		// 抽样的查询在后台用影子管道再检索一次，不影响返回结果
+		r.shadow.Observe(ctx, r.store, query, topK, nil, results, time.Since(start), err)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -412,8 +444,22 @@ func (r *RAGEnhanced) GetStats() map[string]interface{} {
 	return r.store.Stats()
 }
 
+// IndexStats 获取知识库的索引统计：向量总数、向量化模型、分块类型分布、各来源明细、最后更新时间和存储大小
+func (r *RAGEnhanced) IndexStats(ctx context.Context) (*store.IndexStats, error) {
+	stats, err := store.Detailed(ctx, r.store)
+	if err != nil {
+		return nil, err
+	}
+	if stats.EmbeddingModel == "" {
+		stats.EmbeddingModel = resolveEmbeddingModel(r.config, "qwen")
+	}
+	return stats, nil
+}
+
 // AddText 添加文本知识
 func (r *RAGEnhanced) AddText(ctx context.Context, text string, source string) error {
+	ctx = embedding.WithPriority(ctx, embedding.PriorityBatch)
+
 	// 使用语义分块
 	chunks := r.semanticChunker.Split(text)
 
@@ -433,10 +479,21 @@ func (r *RAGEnhanced) AddText(ctx context.Context, text string, source string) e
 			return fmt.Errorf("failed to add chunk to store: %w", err)
 		}
 	}
+	if err := store.FlushPending(ctx, r.store); err != nil {
+		return fmt.Errorf("failed to flush chunks: %w", err)
+	}
+	if r.shadow != nil {
+		r.shadow.Index(ctx, text, source)
+	}
 
 	return nil
 }
 
+// Shadow 检索影子测试，未启用时为nil
+func (r *RAGEnhanced) Shadow() *Shadow {
+	return r.shadow
+}
+
 // SetReranker 设置重排序器
 func (r *RAGEnhanced) SetReranker(reranker reranker.Reranker) {
 	r.reranker = reranker
@@ -473,6 +530,8 @@ func (r *RAGEnhanced) GetChunkerManager() *chunking.ChunkerManager {
 
 // AddDocumentWithChunker 使用指定分块器添加文档 (新版)
 func (r *RAGEnhanced) AddDocumentWithChunker(ctx context.Context, docPath string) error {
+	ctx = embedding.WithPriority(ctx, embedding.PriorityBatch)
+
 	if r.currentChunker == nil {
 		return fmt.Errorf("no chunker set, please call SetChunker first")
 	}
@@ -516,6 +575,9 @@ func (r *RAGEnhanced) AddDocumentWithChunker(ctx context.Context, docPath string
 			return fmt.Errorf("failed to store chunk %d: %w", i, err)
 		}
 	}
+	if err := store.FlushPending(ctx, r.store); err != nil {
+		return fmt.Errorf("failed to flush chunks: %w", err)
+	}
 
 	// 4. 同时索引到BM25（用于混合检索）
 	if r.enableHybrid {
@@ -637,6 +699,42 @@ func (adapter *ModelLLMAdapter) Generate(ctx context.Context, prompt string) (st
 	return response, nil
 }
 
+// SupportsToolCalling 底层模型是否支持函数调用，实现 adaptive.ToolCallingProvider 接口
+func (adapter *ModelLLMAdapter) SupportsToolCalling() bool {
+	_, ok := llm.AsToolCallingModel(adapter.model)
+	return ok
+}
+
+// CallTools 通过模型的函数调用 API 选择工具，实现 adaptive.ToolCallingProvider 接口
+func (adapter *ModelLLMAdapter) CallTools(ctx context.Context, prompt string, tools []adaptive.ToolSchema) ([]adaptive.ToolCall, error) {
+	model, ok := llm.AsToolCallingModel(adapter.model)
+	if !ok {
+		return nil, fmt.Errorf("model %s does not support tool calling", adapter.model.GetModelName())
+	}
+
+	defs := make([]llm.Tool, 0, len(tools))
+	for _, tool := range tools {
+		defs = append(defs, llm.Tool{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	response, err := model.ChatWithTools(ctx, []models.Message{{Role: "user", Content: prompt}}, defs)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]adaptive.ToolCall, 0, len(response.ToolCalls))
+	for _, call := range response.ToolCalls {
+		calls = append(calls, adaptive.ToolCall{Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return calls, nil
+}
+
 // SetQueryOptimizer 设置查询优化器
 func (r *RAGEnhanced) SetQueryOptimizer(optimizerName string, optimizerType string) error {
 	if r.queryOptimizer == nil {
@@ -652,6 +750,8 @@ func (r *RAGEnhanced) SetQueryOptimizer(optimizerName string, optimizerType stri
 
 // QueryWithOptimization 使用查询优化进行检索
 func (r *RAGEnhanced) QueryWithOptimization(ctx context.Context, query string, optimizerName string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if !r.enableQueryOpt {
 		return r.QueryWithContext(ctx, query, topK)
 	}
@@ -691,6 +791,7 @@ func (r *RAGEnhanced) QueryWithOptimization(ctx context.Context, query string, o
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: uniqueContexts,
 		Query:   query,
@@ -733,6 +834,8 @@ func (r *RAGEnhanced) SetCrossEncoder(apiKey, baseURL, model string) error {
 
 // QueryWithCrossEncoder 使用 CrossEncoder 重排序的查询
 func (r *RAGEnhanced) QueryWithCrossEncoder(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if r.crossEncoder == nil {
 		return r.QueryWithContext(ctx, query, topK)
 	}
@@ -779,6 +882,7 @@ func (r *RAGEnhanced) QueryWithCrossEncoder(ctx context.Context, query string, t
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: finalContexts,
 		Query:   query,
@@ -869,6 +973,8 @@ func (r *RAGEnhanced) GetRAGASEvaluator() *eval.RAGASEvaluator {
 
 // QueryWithContext 使用上下文查询（新增方法）
 func (r *RAGEnhanced) QueryWithContext(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	// 1. 检索上下文
 	contexts, err := r.RetrieveEnhanced(ctx, query, topK)
 	if err != nil {
@@ -889,6 +995,7 @@ func (r *RAGEnhanced) QueryWithContext(ctx context.Context, query string, topK i
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -935,6 +1042,8 @@ func (r *RAGEnhanced) BuildKnowledgeGraph(ctx context.Context, documents []strin
 
 // QueryWithGraphRAG 使用 Graph RAG 检索
 func (r *RAGEnhanced) QueryWithGraphRAG(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if !r.enableGraphRAG || r.graphRAG == nil || r.knowledgeGraph == nil {
 		// 回退到普通检索
 		return r.QueryWithContext(ctx, query, topK)
@@ -964,6 +1073,7 @@ func (r *RAGEnhanced) QueryWithGraphRAG(ctx context.Context, query string, topK
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -972,6 +1082,8 @@ func (r *RAGEnhanced) QueryWithGraphRAG(ctx context.Context, query string, topK
 
 // QueryGlobalGraph 使用全局图检索
 func (r *RAGEnhanced) QueryGlobalGraph(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if r.graphRAG == nil || r.knowledgeGraph == nil {
 		return nil, fmt.Errorf("knowledge graph not built")
 	}
@@ -998,6 +1110,7 @@ func (r *RAGEnhanced) QueryGlobalGraph(ctx context.Context, query string, topK i
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -1006,6 +1119,8 @@ func (r *RAGEnhanced) QueryGlobalGraph(ctx context.Context, query string, topK i
 
 // QueryLocalGraph 使用局部图检索
 func (r *RAGEnhanced) QueryLocalGraph(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if r.graphRAG == nil || r.knowledgeGraph == nil {
 		return nil, fmt.Errorf("knowledge graph not built")
 	}
@@ -1032,6 +1147,7 @@ func (r *RAGEnhanced) QueryLocalGraph(ctx context.Context, query string, topK in
 	}
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -1074,6 +1190,11 @@ func (r *RAGEnhanced) InitSelfRAG() error {
 	llmProvider := &ModelLLMAdapter{model: r.embedding}
 
 	config := adaptive.DefaultSelfRAGConfig()
+	budget, err := adaptive.NewReflectionBudgetFromConfig(r.config.RAG.Reflection)
+	if err != nil {
+		return err
+	}
+	config.ApplyBudget(budget)
 	selfRAG, err := adaptive.NewSelfReflectiveRAG(llmProvider, config)
 	if err != nil {
 		return fmt.Errorf("failed to create Self-RAG: %w", err)
@@ -1086,7 +1207,10 @@ func (r *RAGEnhanced) InitSelfRAG() error {
 }
 
 // QueryWithSelfRAG 使用 Self-RAG 进行自我反思检索
+// 重新检索的次数、分数阈值和耗时受 rag.reflection 配置限制，可通过 adaptive.WithReflectionBudget 按请求收紧
 func (r *RAGEnhanced) QueryWithSelfRAG(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if !r.enableSelfRAG || r.selfRAG == nil {
 		// 回退到普通检索
 		return r.QueryWithContext(ctx, query, topK)
@@ -1106,16 +1230,26 @@ func (r *RAGEnhanced) QueryWithSelfRAG(ctx context.Context, query string, topK i
 	}
 
 	// 3. 判断是否需要重新检索
-	maxRetries := 2
+	budget := r.selfRAG.Budget(ctx)
+	startedAt := time.Now()
 	retryCount := 0
+	prevScore := score
+	stopReason := ""
+
+	for {
+		more, reason := budget.NextRetry(retryCount, prevScore, score, startedAt)
+		if !more {
+			stopReason = reason
+			break
+		}
 
-	for r.selfRAG.ShouldRetrieveMore(score) && retryCount < maxRetries {
 		// 调整 Top-K
 		adjustedTopK := r.selfRAG.AdjustTopK(topK, score)
 
 		// 重新检索
 		newContexts, err := r.RetrieveEnhanced(ctx, query, adjustedTopK)
 		if err != nil {
+			stopReason = adaptive.StopError
 			break
 		}
 
@@ -1123,12 +1257,14 @@ func (r *RAGEnhanced) QueryWithSelfRAG(ctx context.Context, query string, topK i
 		contexts = append(contexts, newContexts...)
 
 		// 重新评估
+		prevScore = score
 		score, err = r.selfRAG.EvaluateRetrieval(ctx, query, contexts)
+		retryCount++
 		if err != nil {
+			score = prevScore
+			stopReason = adaptive.StopError
 			break
 		}
-
-		retryCount++
 	}
 
 	// 4. 去重并限制数量
@@ -1139,7 +1275,7 @@ func (r *RAGEnhanced) QueryWithSelfRAG(ctx context.Context, query string, topK i
 
 	// 5. 生成答案（可选：包含反思）
 	reflection := ""
-	if score < 0.7 {
+	if score < budget.MinScore {
 		reflection, _ = r.selfRAG.GenerateReflection(ctx, query, score, uniqueContexts)
 	}
 
@@ -1159,7 +1295,15 @@ func (r *RAGEnhanced) QueryWithSelfRAG(ctx context.Context, query string, topK i
 		return nil, fmt.Errorf("LLM generation failed: %w", err)
 	}
 
+	metadata := usageMetadata(usage)
+	metadata["reflection"] = map[string]interface{}{
+		"retries":     retryCount,
+		"score":       score,
+		"stop_reason": stopReason,
+	}
+
 	return &RAGResult{
+		Metadata: metadata,
 		Answer:  answer,
 		Context: uniqueContexts,
 		Query:   query,
@@ -1200,6 +1344,8 @@ func (r *RAGEnhanced) InitQueryRouter(config adaptive.RouterConfig) error {
 
 // QueryWithRouting 使用自适应路由检索
 func (r *RAGEnhanced) QueryWithRouting(ctx context.Context, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if !r.enableAdaptive || r.queryRouter == nil {
 		// 回退到普通检索
 		return r.QueryWithContext(ctx, query, topK)
@@ -1263,6 +1409,7 @@ func (r *RAGEnhanced) QueryWithRouting(ctx context.Context, query string, topK i
 	r.queryRouter.RecordFeedback(ctx, query, strategy, result)
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -1302,6 +1449,8 @@ func (r *RAGEnhanced) InitParameterOptimizer(config adaptive.OptimizerConfig) er
 
 // QueryWithOptimizedParams 使用优化参数检索
 func (r *RAGEnhanced) QueryWithOptimizedParams(ctx context.Context, query, strategy string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if r.parameterOptimizer == nil {
 		return r.QueryWithContext(ctx, query, topK)
 	}
@@ -1350,6 +1499,7 @@ func (r *RAGEnhanced) QueryWithOptimizedParams(ctx context.Context, query, strat
 	r.parameterOptimizer.RecordPerformance(ctx, strategy, result)
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
@@ -1380,6 +1530,8 @@ func (r *RAGEnhanced) CreateABTest(ctx context.Context, name, description string
 
 // QueryWithABTest 使用 A/B 测试检索
 func (r *RAGEnhanced) QueryWithABTest(ctx context.Context, experimentName, query string, topK int) (*RAGResult, error) {
+	ctx, usage := startUsage(ctx)
+
 	if r.abTesting == nil {
 		return r.QueryWithContext(ctx, query, topK)
 	}
@@ -1435,6 +1587,7 @@ func (r *RAGEnhanced) QueryWithABTest(ctx context.Context, experimentName, query
 	r.abTesting.RecordResult(ctx, experimentName, variant.Name, result)
 
 	return &RAGResult{
+		Metadata: usageMetadata(usage),
 		Answer:  answer,
 		Context: contexts,
 		Query:   query,
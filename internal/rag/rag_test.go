@@ -0,0 +1,257 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/rag/chunker"
+	"ai-agent-assistant/internal/rag/embedding"
+	"ai-agent-assistant/internal/rag/store"
+	"ai-agent-assistant/internal/tenant"
+)
+
+type fakeEmbedding struct{}
+
+func (fakeEmbedding) Embed(ctx context.Context, text string) ([]float64, error) {
+	return []float64{float64(len(text)), 1}, nil
+}
+
+func (fakeEmbedding) GetDimension() int { return 2 }
+
+// batchStore 记录写入方式的向量存储
+type batchStore struct {
+	adds    int
+	batches [][]store.Vector
+	err     error
+}
+
+func (s *batchStore) Add(ctx context.Context, vector []float64, text string, metadata map[string]interface{}) error {
+	s.adds++
+	return nil
+}
+
+func (s *batchStore) AddBatch(ctx context.Context, vectors []store.Vector) error {
+	s.batches = append(s.batches, vectors)
+	return s.err
+}
+
+func (s *batchStore) Search(ctx context.Context, queryVector []float64, topK int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *batchStore) Stats() map[string]interface{} { return nil }
+
+// TestAddTextBatchesChunks 测试AddText把所有分块一次批量写入
+func TestAddTextBatchesChunks(t *testing.T) {
+	vs := &batchStore{}
+	r := &RAG{chunker: *chunker.NewChunker(10, 0), embedding: fakeEmbedding{}, store: vs}
+
+	if err := r.AddText(context.Background(), "第一段内容。第二段内容。第三段内容。第四段内容。", "doc.txt"); err != nil {
+		t.Fatalf("AddText failed: %v", err)
+	}
+	if vs.adds != 0 || len(vs.batches) != 1 {
+		t.Fatalf("expected one batch and no single adds, got adds=%d batches=%d", vs.adds, len(vs.batches))
+	}
+	batch := vs.batches[0]
+	if len(batch) < 2 {
+		t.Fatalf("expected several chunks in batch, got %d", len(batch))
+	}
+	for i, v := range batch {
+		if v.Metadata["source"] != "doc.txt" || v.Metadata["chunk"] != i || len(v.Data) != 2 {
+			t.Errorf("unexpected vector %d: %+v", i, v)
+		}
+	}
+
+	vs.err = errors.New("unavailable")
+	if err := r.AddText(context.Background(), "第五段内容。", "doc.txt"); !errors.Is(err, vs.err) {
+		t.Errorf("expected store error, got %v", err)
+	}
+}
+
+// TestDocumentQuota 测试租户知识库的文档数配额：按来源计数，重新导入已有来源不受限制，各租户的知识库分别计数
+func TestDocumentQuota(t *testing.T) {
+	r := &RAG{
+		chunker:   *chunker.NewChunker(10, 0),
+		embedding: fakeEmbedding{},
+		store:     store.NewInMemoryVectorStore(fakeEmbedding{}),
+		newStore: func(string) (store.VectorStore, error) {
+			return store.NewInMemoryVectorStore(fakeEmbedding{}), nil
+		},
+		stores: make(map[string]store.VectorStore),
+	}
+	r.SetQuotas(tenant.NewQuotasFromConfig(config.TenancyConfig{
+		Enabled: true,
+		Quotas:  config.TenantQuotasConfig{Default: config.TenantQuotaConfig{MaxDocuments: 2}},
+	}))
+	acme := tenant.WithTenant(context.Background(), "acme")
+
+	for _, source := range []string{"a.md", "b.md", "a.md"} {
+		if err := r.AddText(acme, "第一段内容。", source); err != nil {
+			t.Fatalf("AddText %s failed: %v", source, err)
+		}
+	}
+	if err := r.AddText(acme, "第一段内容。", "c.md"); !errors.Is(err, tenant.ErrQuotaExceeded) {
+		t.Errorf("expected documents quota error, got %v", err)
+	}
+	if err := r.AddText(tenant.WithTenant(context.Background(), "beta"), "第一段内容。", "c.md"); err != nil {
+		t.Errorf("other tenants should not be affected: %v", err)
+	}
+	if err := r.AddText(context.Background(), "第一段内容。", "c.md"); err != nil {
+		t.Errorf("knowledge base without a tenant should not be limited: %v", err)
+	}
+}
+
+// TestIndexStatsFor 测试按来源和分块类型统计知识库内容
+func TestIndexStatsFor(t *testing.T) {
+	vs := store.NewInMemoryVectorStore(fakeEmbedding{})
+	cfg := &config.Config{}
+	cfg.Agent.EmbeddingModel = "glm"
+	r := &RAG{chunker: *chunker.NewChunker(10, 0), embedding: fakeEmbedding{}, store: vs, config: cfg}
+
+	ctx := context.Background()
+	if err := r.AddText(ctx, "第一段内容。第二段内容。第三段内容。", "a.txt"); err != nil {
+		t.Fatalf("AddText failed: %v", err)
+	}
+	if err := vs.Add(ctx, []float64{1, 2}, "一张图片", map[string]interface{}{"source": "b.png", "type": "image"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	stats, err := r.IndexStatsFor(ctx)
+	if err != nil {
+		t.Fatalf("IndexStatsFor failed: %v", err)
+	}
+	chunks := int(stats.VectorCount) - 1
+	if stats.Type != "memory" || chunks < 2 || stats.Dimension != 2 || stats.EmbeddingModel == "" {
+		t.Errorf("unexpected totals: %+v", stats)
+	}
+	if stats.SourceCount != 2 || stats.ChunkTypes["text"] != chunks || stats.ChunkTypes["image"] != 1 {
+		t.Errorf("unexpected breakdown: sources=%d chunk_types=%v", stats.SourceCount, stats.ChunkTypes)
+	}
+	if stats.LastUpdated == nil || stats.StorageBytes <= 0 {
+		t.Errorf("expected last updated time and storage size, got %+v", stats)
+	}
+
+	// 最近更新的来源在前
+	if stats.Sources[0].LastUpdated.Before(stats.Sources[1].LastUpdated) {
+		t.Errorf("sources should be ordered by last update, got %+v", stats.Sources)
+	}
+	for _, source := range stats.Sources {
+		if source.Source == "a.txt" && (source.Chunks != chunks || source.Characters != len([]rune("第一段内容。第二段内容。第三段内容。"))) {
+			t.Errorf("unexpected source stats: %+v", source)
+		}
+	}
+
+	vs.DeleteAll()
+	if stats, _ := r.IndexStatsFor(ctx); stats.SourceCount != 0 || stats.VectorCount != 0 {
+		t.Errorf("stats should be cleared with the store, got %+v", stats)
+	}
+}
+
+// TestEmbeddingDimensionMismatch 测试维度与集合不一致的向量被拒绝，以及启动时的模型/维度校验
+func TestEmbeddingDimensionMismatch(t *testing.T) {
+	ctx := context.Background()
+	vs := store.NewInMemoryVectorStore(fakeEmbedding{})
+	if err := vs.Add(ctx, []float64{1, 2}, "a", nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	var mismatch *store.DimensionMismatchError
+	if err := vs.Add(ctx, []float64{1, 2, 3}, "b", nil); !errors.As(err, &mismatch) || mismatch.Expected != 2 || mismatch.Actual != 3 {
+		t.Errorf("expected dimension mismatch on add, got %v", err)
+	}
+	if err := vs.AddBatch(ctx, []store.Vector{{Data: []float64{1, 2}}, {Data: []float64{1}}}); !errors.As(err, &mismatch) {
+		t.Errorf("expected dimension mismatch on batch add, got %v", err)
+	}
+	if vs.GetTotalCount() != 1 {
+		t.Errorf("rejected vectors should not be stored, got %d", vs.GetTotalCount())
+	}
+	if _, err := vs.Search(ctx, []float64{1, 2, 3}, 1); !errors.As(err, &mismatch) {
+		t.Errorf("expected dimension mismatch on search, got %v", err)
+	}
+	vs.DeleteAll()
+	if err := vs.Add(ctx, []float64{1, 2, 3}, "c", nil); err != nil {
+		t.Errorf("dimension should reset with the store: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Agent.EmbeddingModel = "glm"
+	model := resolveEmbeddingModel(cfg, "qwen")
+	if model != "embedding-2" {
+		t.Fatalf("expected glm to resolve to embedding-2, got %s", model)
+	}
+	if dim, ok := embedding.LookupDimension("glm"); !ok || dim != 1024 {
+		t.Errorf("unexpected glm dimension: %d, %v", dim, ok)
+	}
+
+	// 配置的检查在访问Milvus之前失败
+	cfg.VectorDB.Milvus.Dimension = 1536
+	if err := verifyMilvusEmbedding(cfg, nil, model); err == nil || !strings.Contains(err.Error(), "produces 1024-dim vectors") {
+		t.Errorf("expected dimension error, got %v", err)
+	}
+	cfg.VectorDB.Milvus.EmbeddingModel = "qwen"
+	if err := verifyMilvusEmbedding(cfg, nil, model); err == nil || !strings.Contains(err.Error(), "they must match") {
+		t.Errorf("expected model error, got %v", err)
+	}
+}
+
+// TestShadow 测试影子管道在后台检索并记录与主管道的对比
+func TestShadow(t *testing.T) {
+	if NewShadowFromConfig(config.RAGShadowConfig{}, fakeEmbedding{}, 10, 0) != nil {
+		t.Fatal("shadow should be nil when disabled")
+	}
+
+	logPath := filepath.Join(t.TempDir(), "shadow.jsonl")
+	shadow := NewShadowFromConfig(config.RAGShadowConfig{
+		Enabled:    true,
+		Name:       "bm25-rerank",
+		SampleRate: 1,
+		ChunkSize:  30,
+		Retriever:  "bm25",
+		Reranker:   "simple",
+		LogPath:    logPath,
+	}, fakeEmbedding{}, 10, 0)
+	r := &RAG{chunker: *chunker.NewChunker(10, 0), embedding: fakeEmbedding{}, store: store.NewInMemoryVectorStore(fakeEmbedding{}), shadow: shadow}
+
+	ctx := context.Background()
+	if err := r.AddText(ctx, "apple banana. cherry grape. melon peach.", "fruit.txt"); err != nil {
+		t.Fatalf("AddText failed: %v", err)
+	}
+	if stats := shadow.Stats(); !stats.OwnIndex || stats.IndexedChunks == 0 {
+		t.Fatalf("shadow index should be written on import, got %+v", stats)
+	}
+
+	primary, err := r.Retrieve(ctx, "cherry", 2)
+	if err != nil || len(primary) != 2 {
+		t.Fatalf("primary results should not be affected: %v, %v", primary, err)
+	}
+	shadow.Wait()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read shadow log: %v", err)
+	}
+	var entry ShadowComparison
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("invalid shadow log %q: %v", data, err)
+	}
+	if entry.Pipeline != "bm25-rerank" || entry.Query != "cherry" || len(entry.Primary.Results) != 2 || entry.Shadow.Error != "" {
+		t.Fatalf("unexpected comparison: %+v", entry)
+	}
+	if len(entry.Shadow.Results) == 0 || !strings.Contains(entry.Shadow.Results[0], "cherry") {
+		t.Errorf("shadow pipeline should find the keyword match, got %v", entry.Shadow.Results)
+	}
+	if stats := shadow.Stats(); stats.Sampled != 1 || stats.Errors != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	overlap, recall, top1 := compareResults([]string{"a b", "c d"}, []string{"a b c", "e"})
+	if overlap != 1.0/3 || recall != 0.5 || !top1 {
+		t.Errorf("unexpected comparison metrics: overlap=%v recall=%v top1=%v", overlap, recall, top1)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"ai-agent-assistant/internal/llm"
 )
 
 // Reranker 重排序器接口
@@ -90,7 +92,7 @@ func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, documen
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, &llm.APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
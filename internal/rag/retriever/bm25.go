@@ -32,8 +32,13 @@ func NewBM25(k1, b float64) *BM25 {
 	}
 }
 
-// Index 索引文档
+// Index 索引文档，未分词的文档按内容分词
 func (bm *BM25) Index(docs []Document) {
+	for i := range docs {
+		if docs[i].Tokens == nil {
+			docs[i].Tokens = bm.tokenize(docs[i].Content)
+		}
+	}
 	bm.documents = docs
 	bm.calculateIDF()
 	bm.calculateAvgDocLen()
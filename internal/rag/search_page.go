@@ -0,0 +1,151 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 知识库检索响应的默认限制
+const (
+	defaultSearchMaxResults       = 100
+	defaultSearchMaxContentLength = 2000
+	defaultSearchMaxResponseBytes = 256 << 10
+)
+
+// ErrInvalidPageToken 分页令牌无法解析或不属于当前查询
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// SearchPager 知识库检索结果的分页与大小限制
+// 单条结果超过maxContentLength个字符时截断，一页结果的内容总字节数不超过maxResponseBytes，
+// 放不下的结果留到下一页；分页最多能取到前maxResults条结果
+type SearchPager struct {
+	maxResults       int
+	maxContentLength int
+	maxResponseBytes int
+}
+
+// SearchPage 一页检索结果
+type SearchPage struct {
+	Results       []string `json:"results"`
+	Offset        int      `json:"offset"`                    // 本页第一条结果的序号
+	Truncated     int      `json:"truncated"`                 // 本页被截断的结果数
+	NextPageToken string   `json:"next_page_token,omitempty"` // 为空表示没有下一页
+}
+
+// NewSearchPager 根据配置创建分页器，未设置的限制使用默认值
+func NewSearchPager(cfg config.KnowledgeSearchConfig) *SearchPager {
+	p := &SearchPager{
+		maxResults:       cfg.MaxResults,
+		maxContentLength: cfg.MaxContentLength,
+		maxResponseBytes: cfg.MaxResponseBytes,
+	}
+	if p.maxResults <= 0 {
+		p.maxResults = defaultSearchMaxResults
+	}
+	if p.maxContentLength <= 0 {
+		p.maxContentLength = defaultSearchMaxContentLength
+	}
+	if p.maxResponseBytes <= 0 {
+		p.maxResponseBytes = defaultSearchMaxResponseBytes
+	}
+	return p
+}
+
+// Offset 解析分页令牌，返回本页第一条结果的序号；令牌为空时从0开始
+func (p *SearchPager) Offset(query, token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+	offsetText, hash, ok := strings.Cut(string(raw), ":")
+	if !ok || hash != queryHash(query) {
+		return 0, ErrInvalidPageToken
+	}
+	offset, err := strconv.Atoi(offsetText)
+	if err != nil || offset <= 0 || offset >= p.maxResults {
+		return 0, ErrInvalidPageToken
+	}
+	return offset, nil
+}
+
+// FetchSize 取一页需要检索的结果数：多取一条用于判断是否还有下一页，不超过maxResults
+func (p *SearchPager) FetchSize(offset, pageSize int) int {
+	return min(offset+pageSize+1, p.maxResults)
+}
+
+// Page 从检索结果（按相关度排序，从第0条开始）中取出offset开始的一页
+// maxContentLength>0时可以进一步收紧单条结果的字符数，但不能超过配置的上限
+func (p *SearchPager) Page(query string, results []string, offset, pageSize, maxContentLength int) SearchPage {
+	limit := p.maxContentLength
+	if maxContentLength > 0 && maxContentLength < limit {
+		limit = maxContentLength
+	}
+
+	page := SearchPage{Results: []string{}, Offset: offset}
+	end := min(offset+pageSize, len(results), p.maxResults)
+	used := 0
+	next := offset
+	for ; next < end; next++ {
+		content, truncated := truncateRunes(results[next], limit)
+		if used+len(content) > p.maxResponseBytes {
+			if len(page.Results) > 0 {
+				break
+			}
+			// 单条结果就超过总大小限制时截断到限制内，保证每页至少有一条结果
+			content = truncateBytes(content, p.maxResponseBytes)
+			truncated = true
+		}
+		if truncated {
+			page.Truncated++
+		}
+		used += len(content)
+		page.Results = append(page.Results, content)
+	}
+
+	if next < len(results) && next < p.maxResults {
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(next) + ":" + queryHash(query)))
+	}
+	return page
+}
+
+// queryHash 查询的短哈希，分页令牌只能用于生成它的查询
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:6])
+}
+
+// truncateRunes 截断到至多n个字符
+func truncateRunes(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos], true
+		}
+		i++
+	}
+	return s, false
+}
+
+// truncateBytes 截断到至多n个字节，不截断多字节字符
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,59 @@
+package rag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// TestSearchPager 测试检索结果的截断、总大小限制和分页令牌
+func TestSearchPager(t *testing.T) {
+	pager := NewSearchPager(config.KnowledgeSearchConfig{MaxResults: 5, MaxContentLength: 4, MaxResponseBytes: 16})
+	results := []string{"知识库内容", "ab", "cdef", "ghij", "k", "never"}
+
+	// 第一页：单条结果按字符截断，第三条放不下总大小限制，留到下一页
+	if got := pager.FetchSize(0, 3); got != 4 {
+		t.Errorf("FetchSize(0, 3) = %d, want 4", got)
+	}
+	page := pager.Page("q", results[:4], 0, 3, 0)
+	if len(page.Results) != 2 || page.Results[0] != "知识库内" || page.Truncated != 1 {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	offset, err := pager.Offset("q", page.NextPageToken)
+	if err != nil || offset != 2 {
+		t.Fatalf("Offset = %d, %v; want 2", offset, err)
+	}
+
+	// 令牌只能用于同一个查询
+	if _, err := pager.Offset("other", page.NextPageToken); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("token for another query should be rejected, got %v", err)
+	}
+	if _, err := pager.Offset("q", "not-a-token!"); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("malformed token should be rejected, got %v", err)
+	}
+
+	// 第二页：请求可以进一步收紧单条结果长度；分页不超过max_results
+	if got := pager.FetchSize(offset, 3); got != 5 {
+		t.Errorf("FetchSize(2, 3) = %d, want 5", got)
+	}
+	page = pager.Page("q", results[:5], offset, 3, 2)
+	if strings.Join(page.Results, ",") != "cd,gh,k" || page.Truncated != 2 || page.Offset != 2 {
+		t.Fatalf("unexpected second page %+v", page)
+	}
+	if page.NextPageToken != "" {
+		t.Errorf("no more pages within max_results, got token %q", page.NextPageToken)
+	}
+
+	// 单条结果超过总大小限制时截断到限制内
+	small := NewSearchPager(config.KnowledgeSearchConfig{MaxResponseBytes: 7})
+	page = small.Page("q", []string{"知识库内容"}, 0, 3, 0)
+	if len(page.Results) != 1 || page.Results[0] != "知识" || !utf8.ValidString(page.Results[0]) {
+		t.Errorf("oversized result should be cut at a rune boundary, got %q", page.Results)
+	}
+	if page.Truncated != 1 || page.NextPageToken != "" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
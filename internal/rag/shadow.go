@@ -0,0 +1,515 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/rag/chunker"
+	"ai-agent-assistant/internal/rag/embedding"
+	"ai-agent-assistant/internal/rag/reranker"
+	"ai-agent-assistant/internal/rag/retriever"
+	"ai-agent-assistant/internal/rag/store"
+	"ai-agent-assistant/internal/tenant"
+)
+
+// 影子检索的默认参数，可通过 rag.shadow 配置段覆盖
+const (
+	defaultShadowName           = "shadow"
+	defaultShadowSampleRate     = 0.1
+	defaultShadowTimeout        = 10 * time.Second
+	defaultShadowMaxConcurrent  = 4
+	defaultShadowLogPath        = "./data/rag_shadow.jsonl"
+	defaultShadowMaxResultChars = 200
+
+	shadowRRFK         = 60 // 混合检索RRF融合的平滑常数
+	shadowRerankFactor = 3  // 重排序时先取topK的倍数作为候选
+)
+
+// 影子管道的检索方式
+const (
+	ShadowRetrieverVector = "vector"
+	ShadowRetrieverBM25   = "bm25"
+	ShadowRetrieverHybrid = "hybrid"
+)
+
+// ShadowRun 一个管道的检索结果
+type ShadowRun struct {
+	Results   []string `json:"results"`
+	LatencyMs float64  `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// ShadowComparison 一次影子检索与主管道的对比，写入JSONL日志的一行
+// 两边分块不同时结果文本不会完全相同，一方包含另一方即视为同一结果，对比指标只是近似值，可用日志中的结果离线重新评估
+type ShadowComparison struct {
+	Time     time.Time `json:"time"`
+	Pipeline string    `json:"pipeline"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Query    string    `json:"query"`
+	TopK     int       `json:"top_k"`
+	Primary  ShadowRun `json:"primary"`
+	Shadow   ShadowRun `json:"shadow"`
+	Overlap  float64   `json:"overlap"`    // 两边结果的交集占并集的比例
+	Recall   float64   `json:"recall"`     // 主管道的结果中影子管道也检索到的比例
+	Top1     bool      `json:"top1_match"` // 排在第一的结果是否相同
+}
+
+// ShadowStats 影子检索的累计统计，用于Prometheus指标
+type ShadowStats struct {
+	Pipeline         string  `json:"pipeline"`
+	Sampled          int64   `json:"sampled"`            // 命中抽样并完成对比的查询数
+	Skipped          int64   `json:"skipped"`            // 命中抽样但并发已满而跳过的查询数
+	Errors           int64   `json:"errors"`             // 影子检索失败的次数
+	IndexErrors      int64   `json:"index_errors"`       // 写入影子索引失败的次数
+	Top1Matches      int64   `json:"top1_matches"`       // 第一条结果相同的次数
+	OverlapSum       float64 `json:"overlap_sum"`        // 各次对比overlap之和，除以sampled为平均值
+	RecallSum        float64 `json:"recall_sum"`         // 各次对比recall之和
+	PrimarySeconds   float64 `json:"primary_seconds"`    // 主管道检索耗时之和
+	ShadowSeconds    float64 `json:"shadow_seconds"`     // 影子管道检索耗时之和
+	IndexedChunks    int64   `json:"indexed_chunks"`     // 影子索引中的分块数（复用主管道索引时为0）
+	OwnIndex         bool    `json:"own_index"`          // 是否使用独立的影子索引
+	LogWriteFailures int64   `json:"log_write_failures"` // 写入对比日志失败的次数
+}
+
+// Shadow 检索影子测试：抽样线上查询，在后台用另一套检索管道再检索一次并记录对比结果，不影响响应
+// 分块大小不同或检索方式为bm25/hybrid时，影子管道使用独立的内存索引，导入文档时同时写入，
+// 只包含启用后导入的文档；否则复用主管道的向量存储，只替换检索后的重排序
+type Shadow struct {
+	name           string
+	sampleRate     float64
+	retriever      string
+	rerank         reranker.Reranker
+	timeout        time.Duration
+	maxResultChars int
+	logPath        string
+	embedding      embedding.EmbeddingProvider
+	chunker        *chunker.Chunker // 独立索引的分块器，nil表示复用主管道的索引
+	rand           *clock.Rand
+	clock          clock.Clock
+	sem            chan struct{}
+	wg             sync.WaitGroup
+
+	mu      sync.Mutex
+	indexes map[string]*shadowIndex // 租户 -> 影子索引
+	stats   ShadowStats
+	logMu   sync.Mutex
+}
+
+// NewShadowFromConfig 根据配置创建影子检索，未启用时返回nil；未设置或无效的配置项使用默认值
+// primaryChunkSize、primaryOverlap为主管道的分块参数，ep为主管道的向量化提供者
+func NewShadowFromConfig(cfg config.RAGShadowConfig, ep embedding.EmbeddingProvider, primaryChunkSize, primaryOverlap int) *Shadow {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	s := &Shadow{
+		name:           defaultShadowName,
+		sampleRate:     defaultShadowSampleRate,
+		retriever:      ShadowRetrieverVector,
+		timeout:        defaultShadowTimeout,
+		maxResultChars: defaultShadowMaxResultChars,
+		logPath:        defaultShadowLogPath,
+		embedding:      ep,
+		rand:           clock.NewRand(0),
+		clock:          clock.System,
+		indexes:        make(map[string]*shadowIndex),
+	}
+	if cfg.Name != "" {
+		s.name = cfg.Name
+	}
+	if cfg.SampleRate > 0 && cfg.SampleRate <= 1 {
+		s.sampleRate = cfg.SampleRate
+	}
+	switch r := strings.ToLower(cfg.Retriever); r {
+	case ShadowRetrieverBM25, ShadowRetrieverHybrid:
+		s.retriever = r
+	}
+	if strings.EqualFold(cfg.Reranker, "simple") {
+		s.rerank = reranker.NewSimpleReranker(0.3, 0.7)
+	}
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		s.timeout = d
+	}
+	maxConcurrent := defaultShadowMaxConcurrent
+	if cfg.MaxConcurrent > 0 {
+		maxConcurrent = cfg.MaxConcurrent
+	}
+	s.sem = make(chan struct{}, maxConcurrent)
+	if cfg.MaxResultChars > 0 {
+		s.maxResultChars = cfg.MaxResultChars
+	}
+	if cfg.LogPath != "" {
+		s.logPath = cfg.LogPath
+	}
+
+	chunkSize, overlap := primaryChunkSize, primaryOverlap
+	if cfg.ChunkSize > 0 {
+		chunkSize, overlap = cfg.ChunkSize, cfg.ChunkOverlap
+	}
+	if chunkSize != primaryChunkSize || overlap != primaryOverlap || s.retriever != ShadowRetrieverVector {
+		s.chunker = chunker.NewChunker(chunkSize, overlap)
+	}
+	s.stats.Pipeline = s.name
+	s.stats.OwnIndex = s.chunker != nil
+	return s
+}
+
+// SetClock 设置时钟，测试中使用clock.Fake使日志时间可复现
+func (s *Shadow) SetClock(c clock.Clock) {
+	s.clock = clock.OrSystem(c)
+}
+
+// SetSeed 设置抽样的随机种子，0表示使用当前时间
+func (s *Shadow) SetSeed(seed int64) {
+	s.rand = clock.NewRand(seed)
+}
+
+// Name 影子管道名称
+func (s *Shadow) Name() string {
+	return s.name
+}
+
+// Index 把导入的文本按影子管道的分块写入独立索引，复用主管道索引时不做任何事
+// 失败只计入统计，不影响导入
+func (s *Shadow) Index(ctx context.Context, text, source string) {
+	if s.chunker == nil {
+		return
+	}
+	idx := s.indexFor(tenant.FromContext(ctx))
+
+	err := s.chunker.Each(text, func(i int, chunk string) error {
+		var vector []float64
+		if s.retriever != ShadowRetrieverBM25 {
+			var err error
+			if vector, err = s.embedding.Embed(ctx, chunk); err != nil {
+				return fmt.Errorf("failed to embed chunk %d: %w", i, err)
+			}
+		}
+		return idx.add(ctx, vector, chunk, source, i)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.stats.IndexErrors++
+	}
+	s.stats.IndexedChunks = 0
+	for _, idx := range s.indexes {
+		s.stats.IndexedChunks += int64(idx.size())
+	}
+}
+
+// Observe 记录一次线上检索，命中抽样时在后台用影子管道检索并与主管道的结果对比，立即返回
+// primary为主管道使用的向量存储，queryVector为主管道已计算的查询向量，为nil时影子管道自己向量化查询
+func (s *Shadow) Observe(ctx context.Context, primary store.VectorStore, query string, topK int, queryVector []float64, results []string, latency time.Duration, err error) {
+	if s.rand.Float64() >= s.sampleRate {
+		return
+	}
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		s.mu.Lock()
+		s.stats.Skipped++
+		s.mu.Unlock()
+		return
+	}
+
+	comparison := &ShadowComparison{
+		Time:     s.clock.Now(),
+		Pipeline: s.name,
+		Tenant:   tenant.FromContext(ctx),
+		Query:    query,
+		TopK:     topK,
+		Primary:  ShadowRun{Results: results, LatencyMs: milliseconds(latency)},
+	}
+	if err != nil {
+		comparison.Primary.Error = err.Error()
+	}
+
+	// 影子检索不随请求结束而取消，但保留租户等请求信息
+	ctx = context.WithoutCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.sem }()
+		s.compare(ctx, primary, queryVector, comparison)
+	}()
+}
+
+// Wait 等待进行中的影子检索结束，用于测试和退出前写完日志
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// Stats 累计统计
+func (s *Shadow) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// compare 执行影子检索，计算对比指标并写入日志
+func (s *Shadow) compare(ctx context.Context, primary store.VectorStore, queryVector []float64, c *ShadowComparison) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var results []string
+	var err error
+	if queryVector == nil && s.retriever != ShadowRetrieverBM25 {
+		if queryVector, err = s.embedding.Embed(ctx, c.Query); err != nil {
+			err = fmt.Errorf("failed to embed query: %w", err)
+		}
+	}
+	if err == nil {
+		results, err = s.retrieve(ctx, primary, c.Query, c.TopK, queryVector)
+	}
+	shadowLatency := time.Since(start)
+	c.Shadow = ShadowRun{Results: results, LatencyMs: milliseconds(shadowLatency)}
+	if err != nil {
+		c.Shadow.Error = err.Error()
+	}
+	c.Overlap, c.Recall, c.Top1 = compareResults(c.Primary.Results, results)
+
+	s.mu.Lock()
+	if err != nil {
+		s.stats.Errors++
+	} else {
+		s.stats.Sampled++
+		s.stats.OverlapSum += c.Overlap
+		s.stats.RecallSum += c.Recall
+		if c.Top1 {
+			s.stats.Top1Matches++
+		}
+		s.stats.PrimarySeconds += c.Primary.LatencyMs / 1000
+		s.stats.ShadowSeconds += shadowLatency.Seconds()
+	}
+	s.mu.Unlock()
+
+	if err := s.writeLog(c); err != nil {
+		s.mu.Lock()
+		s.stats.LogWriteFailures++
+		s.mu.Unlock()
+	}
+}
+
+// retrieve 按影子管道的检索方式和重排序检索
+func (s *Shadow) retrieve(ctx context.Context, primary store.VectorStore, query string, topK int, queryVector []float64) ([]string, error) {
+	k := topK
+	if s.rerank != nil {
+		k = topK * shadowRerankFactor
+	}
+
+	var results []string
+	var err error
+	if s.chunker == nil {
+		results, err = primary.Search(ctx, queryVector, k)
+	} else {
+		idx := s.indexFor(tenant.FromContext(ctx))
+		switch s.retriever {
+		case ShadowRetrieverBM25:
+			results = idx.keyword(query, k)
+		case ShadowRetrieverHybrid:
+			var vector []string
+			if vector, err = idx.store.Search(ctx, queryVector, k); err == nil {
+				results = fuseRRF(k, vector, idx.keyword(query, k))
+			}
+		default:
+			results, err = idx.store.Search(ctx, queryVector, k)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("shadow search failed: %w", err)
+	}
+
+	if s.rerank != nil && len(results) > 0 {
+		// 向量存储不返回相似度，按名次给出递减的初始分
+		docs := make([]reranker.Document, len(results))
+		for i, content := range results {
+			docs[i] = reranker.Document{ID: fmt.Sprintf("doc_%d", i), Content: content, Score: 1 - float64(i)/float64(len(results))}
+		}
+		reranked, err := s.rerank.Rerank(ctx, query, docs)
+		if err != nil {
+			return nil, fmt.Errorf("shadow rerank failed: %w", err)
+		}
+		results = results[:0]
+		for _, doc := range reranked {
+			results = append(results, doc.Content)
+		}
+	}
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// writeLog 截断结果文本后追加一行对比日志
+func (s *Shadow) writeLog(c *ShadowComparison) error {
+	entry := *c
+	entry.Primary.Results = s.truncate(c.Primary.Results)
+	entry.Shadow.Results = s.truncate(c.Shadow.Results)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.logPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// truncate 每条结果只保留前maxResultChars个字符
+func (s *Shadow) truncate(results []string) []string {
+	truncated := make([]string, len(results))
+	for i, result := range results {
+		if r := []rune(result); len(r) > s.maxResultChars {
+			result = string(r[:s.maxResultChars]) + "…"
+		}
+		truncated[i] = result
+	}
+	return truncated
+}
+
+// indexFor 租户的影子索引，首次访问时创建
+func (s *Shadow) indexFor(t string) *shadowIndex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[t]
+	if !ok {
+		idx = &shadowIndex{store: store.NewInMemoryVectorStore(s.embedding), bm25: retriever.NewBM25(1.5, 0.75)}
+		s.indexes[t] = idx
+	}
+	return idx
+}
+
+// shadowIndex 影子管道的独立索引：向量存储和BM25关键词索引
+type shadowIndex struct {
+	store store.VectorStore
+
+	mu    sync.Mutex
+	docs  []retriever.Document
+	bm25  *retriever.BM25
+	dirty bool // 有新文档，下次关键词检索前重建BM25索引
+}
+
+// add 写入一个分块，vector为nil时只写入关键词索引
+func (i *shadowIndex) add(ctx context.Context, vector []float64, chunk, source string, n int) error {
+	if vector != nil {
+		if err := i.store.Add(ctx, vector, chunk, map[string]interface{}{"source": source, "chunk": n}); err != nil {
+			return err
+		}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.docs = append(i.docs, retriever.Document{ID: fmt.Sprintf("%s#%d", source, n), Content: chunk})
+	i.dirty = true
+	return nil
+}
+
+// size 分块数
+func (i *shadowIndex) size() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.docs)
+}
+
+// keyword BM25检索，只返回至少命中一个词的分块
+func (i *shadowIndex) keyword(query string, k int) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.docs) == 0 {
+		return nil
+	}
+	if i.dirty {
+		i.bm25.Index(append([]retriever.Document(nil), i.docs...))
+		i.dirty = false
+	}
+
+	var results []string
+	for _, r := range i.bm25.Search(query, k) {
+		if r.Score > 0 {
+			results = append(results, r.Content)
+		}
+	}
+	return results
+}
+
+// fuseRRF 按名次倒数融合多路检索结果，相同文本视为同一结果
+func fuseRRF(k int, lists ...[]string) []string {
+	scores := make(map[string]float64)
+	for _, list := range lists {
+		for rank, content := range list {
+			scores[content] += 1 / float64(shadowRRFK+rank+1)
+		}
+	}
+	fused := make([]string, 0, len(scores))
+	for content := range scores {
+		fused = append(fused, content)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if scores[fused[i]] != scores[fused[j]] {
+			return scores[fused[i]] > scores[fused[j]]
+		}
+		return fused[i] < fused[j]
+	})
+	if len(fused) > k {
+		fused = fused[:k]
+	}
+	return fused
+}
+
+// compareResults 计算两边结果的交并比、主管道结果的召回比例和第一条是否相同
+// 一方的文本包含另一方即视为同一结果（两边分块不同时）
+func compareResults(primary, shadow []string) (overlap, recall float64, top1 bool) {
+	if len(primary) == 0 && len(shadow) == 0 {
+		return 1, 1, true
+	}
+	same := func(a, b string) bool {
+		return a == b || strings.Contains(a, b) || strings.Contains(b, a)
+	}
+
+	matched := 0
+	for _, p := range primary {
+		for _, sh := range shadow {
+			if same(p, sh) {
+				matched++
+				break
+			}
+		}
+	}
+	shared := matched
+	if shared > len(shadow) {
+		shared = len(shadow)
+	}
+	if union := len(primary) + len(shadow) - shared; union > 0 {
+		overlap = float64(shared) / float64(union)
+	}
+	if len(primary) > 0 {
+		recall = float64(matched) / float64(len(primary))
+	}
+	top1 = len(primary) > 0 && len(shadow) > 0 && same(primary[0], shadow[0])
+	return overlap, recall, top1
+}
+
+// milliseconds 耗时的毫秒数
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
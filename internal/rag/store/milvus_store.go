@@ -2,22 +2,91 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"ai-agent-assistant/internal/config"
 	"ai-agent-assistant/internal/vectordb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Milvus写入的默认参数
+const (
+	defaultInsertBatchSize = 256
+	defaultFlushInterval   = time.Second
+	defaultInsertRetries   = 3
+	defaultRetryBackoff    = 200 * time.Millisecond
+	flushTimeout           = 30 * time.Second // 写入缓冲区的超时，缓冲区由多个调用方共享，不随某个调用方的ctx取消
 )
 
+// MilvusWriteOptions Milvus写入选项
+type MilvusWriteOptions struct {
+	BatchSize     int           // Add缓冲的向量达到该数量时写入一批，1表示逐条写入
+	FlushInterval time.Duration // 缓冲的向量最长等待时间，到期后在后台写入
+	MaxRetries    int           // 连接不可用、限流等瞬时错误的重试次数
+	RetryBackoff  time.Duration // 第一次重试前的等待时间，之后每次翻倍
+}
+
+// DefaultMilvusWriteOptions 默认写入选项
+func DefaultMilvusWriteOptions() MilvusWriteOptions {
+	return MilvusWriteOptions{
+		BatchSize:     defaultInsertBatchSize,
+		FlushInterval: defaultFlushInterval,
+		MaxRetries:    defaultInsertRetries,
+		RetryBackoff:  defaultRetryBackoff,
+	}
+}
+
+// MilvusWriteOptionsFromConfig 根据配置生成写入选项，未设置或无效的项使用默认值
+func MilvusWriteOptionsFromConfig(cfg config.MilvusConfig) MilvusWriteOptions {
+	opts := DefaultMilvusWriteOptions()
+	if cfg.InsertBatchSize > 0 {
+		opts.BatchSize = cfg.InsertBatchSize
+	}
+	if d, err := time.ParseDuration(cfg.FlushInterval); err == nil && d > 0 {
+		opts.FlushInterval = d
+	}
+	if cfg.InsertRetries > 0 {
+		opts.MaxRetries = cfg.InsertRetries
+	}
+	return opts
+}
+
+// milvusWriter 写入缓冲区使用的Milvus操作
+type milvusWriter interface {
+	InsertRows(ctx context.Context, vectors []*vectordb.VectorData) (int64, error)
+	Flush(ctx context.Context) error
+}
+
 // MilvusVectorStore Milvus向量存储
+// Add写入的向量先进入缓冲区，凑满一批或等待超过FlushInterval后一次插入；检索前会先写入缓冲区中的向量
+// 写入失败的向量留在缓冲区等待下次写入，失败次数和最近一次错误见Stats中的write_errors和last_write_error
 type MilvusVectorStore struct {
 	client       *vectordb.MilvusClient
 	collection   string
 	ops          *vectordb.VectorOperations
+	writer       milvusWriter // 初始化后为ops
 	initialized  bool
 	initOnce     sync.Once
 	dimension    int
+	embeddingModel string // 集合绑定的向量化模型
 	nextID       int64
 	idMutex      sync.Mutex
+
+	writeOpts   MilvusWriteOptions
+	bufMu       sync.Mutex
+	pending     []*vectordb.VectorData
+	flushTimer  *time.Timer
+	writeErrors atomic.Int64 // 重试后仍失败的批次
+	lastWriteError string    // 最近一次写入失败的原因，受bufMu保护
+	inserted    atomic.Int64
+	ledger      *sourceLedger // 本进程写入的分块的来源统计，集合中不保存来源等元数据
 }
 
 // NewMilvusVectorStore 创建Milvus向量存储
@@ -27,7 +96,17 @@ func NewMilvusVectorStore(client *vectordb.MilvusClient, collectionName string,
 		collection: collectionName,
 		dimension:  dimension,
 		nextID:     1,
+		writeOpts:  DefaultMilvusWriteOptions(),
+		ledger:     newSourceLedger(),
+	}
+}
+
+// SetWriteOptions 设置批量写入和重试参数（需在首次写入前调用）
+func (s *MilvusVectorStore) SetWriteOptions(opts MilvusWriteOptions) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
 	}
+	s.writeOpts = opts
 }
 
 // initialize 初始化集合
@@ -37,25 +116,84 @@ func (s *MilvusVectorStore) initialize(ctx context.Context) error {
 		// 使用CollectionManager创建集合
 		manager := vectordb.NewCollectionManager(s.client)
 
-		// 创建简单集合（如果不存在）
-		if err := manager.CreateSimpleCollection(ctx, s.collection, s.dimension); err != nil {
+		// 已存在的集合先校验向量化模型和维度，避免混入不同维度的向量
+		if err := s.verifyCollection(ctx, manager); err != nil {
+			initErr = err
+			return
+		}
+
+		// 创建集合（如果不存在），并记录向量化模型和维度
+		spec := vectordb.EmbeddingSpec{Model: s.embeddingModel, Dimension: s.dimension}
+		if err := manager.CreateCollectionWithEmbedding(ctx, s.collection, spec); err != nil {
 			initErr = fmt.Errorf("failed to create collection: %w", err)
 			return
 		}
 
 		// 创建向量操作实例
 		s.ops = vectordb.NewVectorOperations(s.client, s.collection, s.dimension)
+		s.writer = s.ops
 		s.initialized = true
 	})
 
 	return initErr
 }
 
+// Ping 探测Milvus连通性
+func (s *MilvusVectorStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+// SetEmbeddingModel 设置集合绑定的向量化模型（需在首次读写前调用）
+func (s *MilvusVectorStore) SetEmbeddingModel(model string) {
+	s.embeddingModel = model
+}
+
+// VerifyEmbedding 校验配置的向量化模型与维度是否与集合一致
+// 集合不存在时会按当前配置创建
+func (s *MilvusVectorStore) VerifyEmbedding(ctx context.Context) error {
+	return s.initialize(ctx)
+}
+
+// verifyCollection 校验已存在集合的向量化模型与维度
+func (s *MilvusVectorStore) verifyCollection(ctx context.Context, manager *vectordb.CollectionManager) error {
+	spec, err := manager.GetEmbeddingSpec(ctx, s.collection)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		return nil
+	}
+
+	if spec.Dimension > 0 && s.dimension > 0 && spec.Dimension != s.dimension {
+		return &DimensionMismatchError{
+			Collection: s.collection,
+			Model:      spec.Model,
+			Expected:   spec.Dimension,
+			Actual:     s.dimension,
+		}
+	}
+	if spec.Model != "" && s.embeddingModel != "" && spec.Model != s.embeddingModel {
+		return &ModelMismatchError{
+			Collection: s.collection,
+			Expected:   spec.Model,
+			Actual:     s.embeddingModel,
+		}
+	}
+
+	if s.dimension == 0 {
+		s.dimension = spec.Dimension
+	}
+	return nil
+}
+
 // Add 添加向量
 func (s *MilvusVectorStore) Add(ctx context.Context, vector []float64, text string, metadata map[string]interface{}) error {
 	if err := s.initialize(ctx); err != nil {
 		return err
 	}
+	if err := checkDimension(s.collection, s.embeddingModel, s.dimension, vector); err != nil {
+		return err
+	}
 
 	// 将float64转换为float32
 	vector32 := make([]float32, len(vector))
@@ -85,20 +223,169 @@ func (s *MilvusVectorStore) Add(ctx context.Context, vector []float64, text stri
 		}
 	}
 
-	// 插入向量
-	_, err := s.ops.InsertWithMetadata(ctx, []*vectordb.VectorData{vectorData})
-	if err != nil {
-		return fmt.Errorf("failed to insert vector: %w", err)
+	// 放入缓冲区，凑满一批时立即写入，否则等待定时写入
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+	s.pending = append(s.pending, vectorData)
+	if len(s.pending) >= s.writeOpts.BatchSize {
+		if err := s.flushSharedLocked(ctx); err != nil && s.dropPendingLocked(map[int64]bool{id: true}) > 0 {
+			// 本次的向量没有写入时从缓冲区移除并返回错误，由调用方重试；
+			// 之前的Add已经返回成功的向量留在缓冲区等待下次写入
+			return err
+		}
+		return nil
+	}
+	s.scheduleFlushLocked()
+
+	return nil
+}
+
+// Flush 写入缓冲区中的向量并刷新集合，批量导入结束时调用以确认写入结果
+// 写入失败的向量留在缓冲区，之后的Flush或定时写入会再次尝试
+func (s *MilvusVectorStore) Flush(ctx context.Context) error {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+
+	return s.flushLocked(ctx)
+}
+
+// flushInBackground 缓冲区等待超时后在后台写入，失败时记录日志，向量留在缓冲区等待下次定时写入
+func (s *MilvusVectorStore) flushInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := s.Flush(ctx); err != nil {
+		log.Printf("Warning: background insert into milvus collection %s failed, will retry: %v", s.collection, err)
 	}
+}
+
+// flushBeforeRead 检索和删除前写入缓冲区中的向量
+// 缓冲区中是其他调用方的向量，写入失败只记录，不影响本次操作，向量留在缓冲区等待下次写入
+func (s *MilvusVectorStore) flushBeforeRead(ctx context.Context) {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+
+	if err := s.flushSharedLocked(ctx); err != nil {
+		log.Printf("Warning: insert into milvus collection %s failed, %d buffered vectors are not searchable yet: %v",
+			s.collection, len(s.pending), err)
+	}
+}
+
+// flushSharedLocked 在不随调用方取消的ctx上写入缓冲区，调用方需持有bufMu
+func (s *MilvusVectorStore) flushSharedLocked(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+
+	return s.flushLocked(ctx)
+}
 
+// flushLocked 分批插入缓冲区中的向量，写入成功的批次移出缓冲区，调用方需持有bufMu
+// 写入失败时计入写入错误，并安排定时写入再次尝试
+func (s *MilvusVectorStore) flushLocked(ctx context.Context) error {
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	written, err := s.insertBatches(ctx, s.pending)
+	s.pending = append([]*vectordb.VectorData(nil), s.pending[written:]...)
+	if err != nil {
+		s.writeErrors.Add(1)
+		s.lastWriteError = err.Error()
+		s.scheduleFlushLocked()
+		return err
+	}
 	return nil
 }
 
+// scheduleFlushLocked 缓冲区中有向量且尚未安排时，FlushInterval后在后台写入，调用方需持有bufMu
+func (s *MilvusVectorStore) scheduleFlushLocked() {
+	if s.flushTimer == nil && len(s.pending) > 0 && s.writeOpts.FlushInterval > 0 {
+		s.flushTimer = time.AfterFunc(s.writeOpts.FlushInterval, s.flushInBackground)
+	}
+}
+
+// dropPendingLocked 从缓冲区移除指定ID的向量，返回移除的数量，调用方需持有bufMu
+func (s *MilvusVectorStore) dropPendingLocked(ids map[int64]bool) int {
+	kept := s.pending[:0]
+	for _, row := range s.pending {
+		if !ids[row.ID] {
+			kept = append(kept, row)
+		}
+	}
+	dropped := len(s.pending) - len(kept)
+	for i := len(kept); i < len(s.pending); i++ {
+		s.pending[i] = nil
+	}
+	s.pending = kept
+	return dropped
+}
+
+// insertBatches 按BatchSize分批插入，全部插入后刷新一次集合
+// 返回插入成功的行数，某一批失败时之后的批次不再插入
+func (s *MilvusVectorStore) insertBatches(ctx context.Context, rows []*vectordb.VectorData) (int, error) {
+	for start := 0; start < len(rows); start += s.writeOpts.BatchSize {
+		end := start + s.writeOpts.BatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := s.insertWithRetry(ctx, rows[start:end]); err != nil {
+			return start, fmt.Errorf("failed to insert vectors %d-%d of %d: %w", start, end, len(rows), err)
+		}
+		s.inserted.Add(int64(end - start))
+		for _, row := range rows[start:end] {
+			text, _ := row.Metadata["content"].(string)
+			s.ledger.record(text, row.Metadata, vectorBytes(text, len(row.Vector), 4))
+		}
+	}
+
+	if err := s.writer.Flush(ctx); err != nil {
+		log.Printf("Warning: failed to flush collection: %v", err)
+	}
+	return len(rows), nil
+}
+
+// insertWithRetry 插入一批向量，瞬时错误按指数退避重试
+func (s *MilvusVectorStore) insertWithRetry(ctx context.Context, rows []*vectordb.VectorData) error {
+	backoff := s.writeOpts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		_, err := s.writer.InsertRows(ctx, rows)
+		if err == nil || attempt >= s.writeOpts.MaxRetries || !isTransient(ctx, err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient 判断错误是否值得重试：连接不可用、限流、服务端超时等
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
 // Search 搜索最相似的向量
 func (s *MilvusVectorStore) Search(ctx context.Context, queryVector []float64, topK int) ([]string, error) {
 	if err := s.initialize(ctx); err != nil {
 		return nil, err
 	}
+	s.flushBeforeRead(ctx)
+	if err := checkDimension(s.collection, s.embeddingModel, s.dimension, queryVector); err != nil {
+		return nil, err
+	}
 
 	// 将float64转换为float32
 	vector32 := make([]float32, len(queryVector))
@@ -148,12 +435,49 @@ func (s *MilvusVectorStore) Stats() map[string]interface{} {
 		}
 	}
 
-	return map[string]interface{}{
+	s.bufMu.Lock()
+	pending := len(s.pending)
+	lastWriteError := s.lastWriteError
+	s.bufMu.Unlock()
+
+	stats := map[string]interface{}{
 		"type":         "milvus",
 		"collection":   s.collection,
 		"vector_count": count,
 		"dimension":    s.dimension,
+		"embedding_model": s.embeddingModel,
+		"pending_writes":  pending,
+		"inserted":        s.inserted.Load(),
+		"write_errors":    s.writeErrors.Load(),
 	}
+	if lastWriteError != "" {
+		stats["last_write_error"] = lastWriteError
+	}
+	for k, v := range s.client.PoolStats() {
+		stats[k] = v
+	}
+	return stats
+}
+
+// IndexStats 获取集合的向量总数和本进程写入的分块按来源、分块类型的统计
+func (s *MilvusVectorStore) IndexStats(ctx context.Context) (*IndexStats, error) {
+	stats := &IndexStats{
+		Type:           "milvus",
+		Collection:     s.collection,
+		Dimension:      s.dimension,
+		EmbeddingModel: s.embeddingModel,
+	}
+	if s.initialized {
+		count, err := s.ops.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count vectors in collection %s: %w", s.collection, err)
+		}
+		stats.VectorCount = count
+	}
+	s.ledger.fill(stats)
+	since := s.ledger.trackedSince()
+	stats.TrackedSince = &since
+	return stats, nil
 }
 
 // AddBatch 批量添加向量
@@ -169,6 +493,10 @@ func (s *MilvusVectorStore) AddBatch(ctx context.Context, vectors []Vector) erro
 	// 准备向量数据
 	vectorDataList := make([]*vectordb.VectorData, 0, len(vectors))
 	for _, v := range vectors {
+		if err := checkDimension(s.collection, s.embeddingModel, s.dimension, v.Data); err != nil {
+			return err
+		}
+
 		// 将float64转换为float32
 		vector32 := make([]float32, len(v.Data))
 		for i, val := range v.Data {
@@ -198,9 +526,9 @@ func (s *MilvusVectorStore) AddBatch(ctx context.Context, vectors []Vector) erro
 		vectorDataList = append(vectorDataList, vectorData)
 	}
 
-	// 批量插入
-	_, err := s.ops.InsertWithMetadata(ctx, vectorDataList)
-	if err != nil {
+	// 先写入Add缓冲的向量保持顺序（失败时不影响本批写入），之后不持有缓冲区锁，多个AddBatch可以通过不同连接并发写入
+	s.flushBeforeRead(ctx)
+	if _, err := s.insertBatches(ctx, vectorDataList); err != nil {
 		return fmt.Errorf("failed to insert vectors batch: %w", err)
 	}
 
@@ -212,6 +540,10 @@ func (s *MilvusVectorStore) SearchWithMetadata(ctx context.Context, queryVector
 	if err := s.initialize(ctx); err != nil {
 		return nil, err
 	}
+	s.flushBeforeRead(ctx)
+	if err := checkDimension(s.collection, s.embeddingModel, s.dimension, queryVector); err != nil {
+		return nil, err
+	}
 
 	// 将float64转换为float32
 	vector32 := make([]float32, len(queryVector))
@@ -261,6 +593,16 @@ func (s *MilvusVectorStore) Delete(ctx context.Context, ids []int64) error {
 		return err
 	}
 
+	// 还在缓冲区中的向量直接移除，避免删除后才写入集合
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	s.bufMu.Lock()
+	s.dropPendingLocked(remove)
+	s.bufMu.Unlock()
+	s.flushBeforeRead(ctx)
+
 	return s.ops.DeleteByID(ctx, ids)
 }
 
@@ -0,0 +1,196 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/vectordb"
+)
+
+// fakeMilvusWriter 记录写入的向量，fail为true时插入失败
+type fakeMilvusWriter struct {
+	mu    sync.Mutex
+	fail  bool
+	calls int
+	rows  []string
+}
+
+func (w *fakeMilvusWriter) InsertRows(ctx context.Context, vectors []*vectordb.VectorData) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls++
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.fail {
+		return 0, errors.New("insert rejected")
+	}
+	for _, v := range vectors {
+		w.rows = append(w.rows, v.Metadata["content"].(string))
+	}
+	return int64(len(vectors)), nil
+}
+
+func (w *fakeMilvusWriter) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (w *fakeMilvusWriter) setFail(fail bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail = fail
+}
+
+func (w *fakeMilvusWriter) snapshot() (int, []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.calls, append([]string(nil), w.rows...)
+}
+
+// newTestMilvusStore 创建跳过集合初始化、写入到writer的存储
+func newTestMilvusStore(writer milvusWriter, opts MilvusWriteOptions) *MilvusVectorStore {
+	s := NewMilvusVectorStore(nil, "test", 2)
+	s.SetWriteOptions(opts)
+	s.initOnce.Do(func() {})
+	s.writer = writer
+	s.initialized = true
+	return s
+}
+
+func (s *MilvusVectorStore) pendingTexts() []string {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+	texts := make([]string, 0, len(s.pending))
+	for _, row := range s.pending {
+		texts = append(texts, row.Metadata["content"].(string))
+	}
+	return texts
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestMilvusFlushKeepsRowsOnFailure 测试插入失败时缓冲区中的向量保留，下次写入时插入
+func TestMilvusFlushKeepsRowsOnFailure(t *testing.T) {
+	writer := &fakeMilvusWriter{fail: true}
+	s := newTestMilvusStore(writer, MilvusWriteOptions{BatchSize: 3})
+	ctx := context.Background()
+
+	for _, text := range []string{"a", "b"} {
+		if err := s.Add(ctx, []float64{1, 0}, text, nil); err != nil {
+			t.Fatalf("Add(%s) error = %v", text, err)
+		}
+	}
+	if err := s.Flush(ctx); err == nil {
+		t.Fatal("Flush() error = nil, want insert failure")
+	}
+	if got := s.pendingTexts(); !equalStrings(got, []string{"a", "b"}) {
+		t.Errorf("pending after failed flush = %v, want [a b]", got)
+	}
+
+	// 凑满一批时写入失败，只有本次Add的向量被拒绝，之前接受的向量仍在缓冲区
+	if err := s.Add(ctx, []float64{1, 0}, "c", nil); err == nil {
+		t.Error("Add(c) error = nil, want insert failure")
+	}
+	if got := s.pendingTexts(); !equalStrings(got, []string{"a", "b"}) {
+		t.Errorf("pending after failed Add = %v, want [a b]", got)
+	}
+	if got := s.writeErrors.Load(); got != 2 {
+		t.Errorf("writeErrors = %d, want 2", got)
+	}
+	if s.lastWriteError == "" {
+		t.Error("lastWriteError is empty after failed flush")
+	}
+
+	writer.setFail(false)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if _, rows := writer.snapshot(); !equalStrings(rows, []string{"a", "b"}) {
+		t.Errorf("inserted rows = %v, want [a b]", rows)
+	}
+	if got := s.pendingTexts(); len(got) != 0 {
+		t.Errorf("pending after flush = %v, want empty", got)
+	}
+	if got := s.inserted.Load(); got != 2 {
+		t.Errorf("inserted = %d, want 2", got)
+	}
+}
+
+// TestMilvusBackgroundFlushRetries 测试后台写入失败后向量保留，并在下次定时写入时插入
+func TestMilvusBackgroundFlushRetries(t *testing.T) {
+	writer := &fakeMilvusWriter{fail: true}
+	s := newTestMilvusStore(writer, MilvusWriteOptions{BatchSize: 10, FlushInterval: 10 * time.Millisecond})
+
+	if err := s.Add(context.Background(), []float64{1, 0}, "a", nil); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	waitFor(t, func() bool { calls, _ := writer.snapshot(); return calls >= 1 })
+	if got := s.pendingTexts(); !equalStrings(got, []string{"a"}) {
+		t.Errorf("pending after failed background flush = %v, want [a]", got)
+	}
+
+	writer.setFail(false)
+	waitFor(t, func() bool { _, rows := writer.snapshot(); return len(rows) == 1 })
+	if got := s.pendingTexts(); len(got) != 0 {
+		t.Errorf("pending after background retry = %v, want empty", got)
+	}
+	if got := s.writeErrors.Load(); got < 1 {
+		t.Errorf("writeErrors = %d, want at least 1", got)
+	}
+}
+
+// TestMilvusSharedFlushIgnoresCallerCancel 测试缓冲区写入不随触发写入的调用方ctx取消
+func TestMilvusSharedFlushIgnoresCallerCancel(t *testing.T) {
+	writer := &fakeMilvusWriter{}
+	s := newTestMilvusStore(writer, MilvusWriteOptions{BatchSize: 2})
+
+	if err := s.Add(context.Background(), []float64{1, 0}, "a", nil); err != nil {
+		t.Fatalf("Add(a) error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Add(ctx, []float64{1, 0}, "b", nil); err != nil {
+		t.Fatalf("Add(b) with cancelled ctx error = %v", err)
+	}
+	if _, rows := writer.snapshot(); !equalStrings(rows, []string{"a", "b"}) {
+		t.Errorf("inserted rows = %v, want [a b]", rows)
+	}
+}
+
+// TestMilvusFlushBeforeReadKeepsRows 测试检索前的写入失败不返回错误，向量留在缓冲区
+func TestMilvusFlushBeforeReadKeepsRows(t *testing.T) {
+	writer := &fakeMilvusWriter{fail: true}
+	s := newTestMilvusStore(writer, MilvusWriteOptions{BatchSize: 10})
+
+	if err := s.Add(context.Background(), []float64{1, 0}, "a", nil); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	s.flushBeforeRead(context.Background())
+	if got := s.pendingTexts(); !equalStrings(got, []string{"a"}) {
+		t.Errorf("pending after failed read flush = %v, want [a]", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// DimensionMismatchError 向量维度与集合不一致
+// 不同维度的向量写入同一集合会导致检索结果错乱，因此直接拒绝
+type DimensionMismatchError struct {
+	Collection string
+	Model      string // 集合绑定的向量化模型
+	Expected   int    // 集合的维度
+	Actual     int    // 当前向量/模型的维度
+}
+
+func (e *DimensionMismatchError) Error() string {
+	model := e.Model
+	if model == "" {
+		model = "unknown"
+	}
+	return fmt.Sprintf("embedding dimension mismatch for collection %q: collection expects %d (model %s), got %d; "+
+		"use the same embedding model or re-index into a new collection",
+		e.Collection, e.Expected, model, e.Actual)
+}
+
+// ModelMismatchError 向量化模型与集合绑定的模型不一致
+type ModelMismatchError struct {
+	Collection string
+	Expected   string
+	Actual     string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("embedding model mismatch for collection %q: collection was built with %s, configured %s; "+
+		"use the same embedding model or re-index into a new collection",
+		e.Collection, e.Expected, e.Actual)
+}
+
+// EmbeddingVerifier 可校验向量化模型/维度的存储
+type EmbeddingVerifier interface {
+	// VerifyEmbedding 校验配置的向量化模型与维度是否与集合一致
+	VerifyEmbedding(ctx context.Context) error
+}
+
+// checkDimension 校验向量维度（expected为0表示尚未确定）
+func checkDimension(collection, model string, expected int, vector []float64) error {
+	if expected > 0 && len(vector) != expected {
+		return &DimensionMismatchError{
+			Collection: collection,
+			Model:      model,
+			Expected:   expected,
+			Actual:     len(vector),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultChunkType 元数据中没有chunk_type/type时的分块类型
+const defaultChunkType = "text"
+
+// IndexStats 知识库的索引统计：总量、分块类型分布和各来源的明细
+type IndexStats struct {
+	Type           string         `json:"type"`
+	Collection     string         `json:"collection,omitempty"`
+	VectorCount    int64          `json:"vector_count"`
+	Dimension      int            `json:"dimension"`
+	EmbeddingModel string         `json:"embedding_model,omitempty"`
+	StorageBytes   int64          `json:"storage_bytes"` // 分块文本和向量占用的估算字节数
+	ChunkTypes     map[string]int `json:"chunk_types"`
+	SourceCount    int            `json:"source_count"`
+	LastUpdated    *time.Time     `json:"last_updated,omitempty"`
+	// TrackedSince 来源明细的统计起点；Milvus集合只保存文本和向量，来源明细只包含本进程启动后写入的分块
+	TrackedSince *time.Time    `json:"tracked_since,omitempty"`
+	Sources      []SourceStats `json:"-"`
+}
+
+// SourceStats 单个来源（文档、上传文件或文本的source）的索引统计
+type SourceStats struct {
+	Source       string         `json:"source"`
+	Chunks       int            `json:"chunks"`
+	Characters   int            `json:"characters"`
+	Bytes        int64          `json:"bytes"` // 分块文本和向量占用的估算字节数
+	ChunkTypes   map[string]int `json:"chunk_types"`
+	FirstIndexed time.Time      `json:"first_indexed"`
+	LastUpdated  time.Time      `json:"last_updated"`
+}
+
+// DetailedStatter 可按来源统计索引内容的向量存储
+type DetailedStatter interface {
+	IndexStats(ctx context.Context) (*IndexStats, error)
+}
+
+// sourceLedger 按来源累计写入的分块数、字符数、字节数、分块类型和写入时间
+type sourceLedger struct {
+	mu      sync.Mutex
+	since   time.Time
+	sources map[string]*SourceStats
+	now     func() time.Time
+}
+
+// newSourceLedger 创建来源统计
+func newSourceLedger() *sourceLedger {
+	return &sourceLedger{
+		since:   time.Now(),
+		sources: make(map[string]*SourceStats),
+		now:     time.Now,
+	}
+}
+
+// record 记录写入的一个分块，bytes为文本和向量的估算字节数
+func (l *sourceLedger) record(text string, metadata map[string]interface{}, bytes int64) {
+	source := "unknown"
+	if v, ok := metadata["source"]; ok && v != nil && fmt.Sprint(v) != "" {
+		source = fmt.Sprint(v)
+	}
+	chunkType := defaultChunkType
+	for _, key := range []string{"chunk_type", "type"} {
+		if v, ok := metadata[key].(string); ok && v != "" {
+			chunkType = v
+			break
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	s, ok := l.sources[source]
+	if !ok {
+		s = &SourceStats{Source: source, ChunkTypes: make(map[string]int), FirstIndexed: now}
+		l.sources[source] = s
+	}
+	s.Chunks++
+	s.Characters += len([]rune(text))
+	s.Bytes += bytes
+	s.ChunkTypes[chunkType]++
+	s.LastUpdated = now
+}
+
+// reset 清空统计，统计起点改为当前时间
+func (l *sourceLedger) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.since = l.now()
+	l.sources = make(map[string]*SourceStats)
+}
+
+// trackedSince 统计起点
+func (l *sourceLedger) trackedSince() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.since
+}
+
+// fill 把来源明细、分块类型分布、存储大小和最后更新时间写入stats
+// 来源按最后更新时间倒序排列
+func (l *sourceLedger) fill(stats *IndexStats) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats.ChunkTypes = make(map[string]int)
+	stats.Sources = make([]SourceStats, 0, len(l.sources))
+	for _, s := range l.sources {
+		copied := *s
+		copied.ChunkTypes = make(map[string]int, len(s.ChunkTypes))
+		for chunkType, n := range s.ChunkTypes {
+			copied.ChunkTypes[chunkType] = n
+			stats.ChunkTypes[chunkType] += n
+		}
+		stats.Sources = append(stats.Sources, copied)
+		stats.StorageBytes += s.Bytes
+		if stats.LastUpdated == nil || s.LastUpdated.After(*stats.LastUpdated) {
+			last := s.LastUpdated
+			stats.LastUpdated = &last
+		}
+	}
+	sort.Slice(stats.Sources, func(i, j int) bool {
+		a, b := stats.Sources[i], stats.Sources[j]
+		if !a.LastUpdated.Equal(b.LastUpdated) {
+			return a.LastUpdated.After(b.LastUpdated)
+		}
+		return a.Source < b.Source
+	})
+	stats.SourceCount = len(stats.Sources)
+}
+
+// Detailed 获取存储的索引统计，不支持来源明细的存储只返回Stats中的总量
+func Detailed(ctx context.Context, vs VectorStore) (*IndexStats, error) {
+	if ds, ok := vs.(DetailedStatter); ok {
+		return ds.IndexStats(ctx)
+	}
+
+	raw := vs.Stats()
+	stats := &IndexStats{ChunkTypes: map[string]int{}, Sources: []SourceStats{}}
+	stats.Type, _ = raw["type"].(string)
+	stats.Collection, _ = raw["collection"].(string)
+	stats.EmbeddingModel, _ = raw["embedding_model"].(string)
+	stats.Dimension, _ = raw["dimension"].(int)
+	switch count := raw["vector_count"].(type) {
+	case int:
+		stats.VectorCount = int64(count)
+	case int64:
+		stats.VectorCount = count
+	}
+	return stats, nil
+}
@@ -22,10 +22,48 @@ type VectorStore interface {
 	Stats() map[string]interface{}
 }
 
+// BatchAdder 支持批量写入的向量存储
+type BatchAdder interface {
+	AddBatch(ctx context.Context, vectors []Vector) error
+}
+
+// Flusher 缓冲写入的向量存储，Flush把缓冲区中的向量写入后端
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// AddAll 写入一组向量：支持批量写入的存储一次写入，否则逐条写入，最后写入缓冲区中的向量
+func AddAll(ctx context.Context, vs VectorStore, vectors []Vector) error {
+	if batcher, ok := vs.(BatchAdder); ok {
+		return batcher.AddBatch(ctx, vectors)
+	}
+	for i, v := range vectors {
+		if err := vs.Add(ctx, v.Data, v.Text, v.Metadata); err != nil {
+			return fmt.Errorf("failed to store vector %d: %w", i, err)
+		}
+	}
+	return FlushPending(ctx, vs)
+}
+
+// FlushPending 写入缓冲区中的向量，不缓冲写入的存储直接返回
+func FlushPending(ctx context.Context, vs VectorStore) error {
+	if flusher, ok := vs.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Pinger 可探测连通性的向量存储（外部向量数据库），用于就绪检查
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // InMemoryVectorStore 内存向量存储
 type InMemoryVectorStore struct {
 	vectors   []Vector
 	embedding embedding.EmbeddingProvider
+	dimension int // 第一次写入时确定，之后写入和检索的向量必须一致
+	ledger    *sourceLedger
 }
 
 // NewInMemoryVectorStore 创建内存向量存储
@@ -33,16 +71,21 @@ func NewInMemoryVectorStore(ep embedding.EmbeddingProvider) *InMemoryVectorStore
 	return &InMemoryVectorStore{
 		vectors:   make([]Vector, 0),
 		embedding: ep,
+		ledger:    newSourceLedger(),
 	}
 }
 
 // Add 添加向量
 func (s *InMemoryVectorStore) Add(ctx context.Context, vector []float64, text string, metadata map[string]interface{}) error {
+	if err := s.checkDimension(vector); err != nil {
+		return err
+	}
 	s.vectors = append(s.vectors, Vector{
 		Data:     vector,
 		Text:     text,
 		Metadata: metadata,
 	})
+	s.ledger.record(text, metadata, vectorBytes(text, len(vector), 8))
 	return nil
 }
 
@@ -51,6 +94,9 @@ func (s *InMemoryVectorStore) Search(ctx context.Context, queryVector []float64,
 	if len(s.vectors) == 0 {
 		return []string{}, nil
 	}
+	if err := checkDimension("memory", "", s.dimension, queryVector); err != nil {
+		return nil, err
+	}
 
 	// 计算所有向量的相似度
 	type Result struct {
@@ -90,16 +136,50 @@ func (s *InMemoryVectorStore) Search(ctx context.Context, queryVector []float64,
 
 // Stats 获取统计信息
 func (s *InMemoryVectorStore) Stats() map[string]interface{} {
+	dimension := s.dimension
+	if dimension == 0 {
+		dimension = s.embedding.GetDimension()
+	}
 	return map[string]interface{}{
 		"type":        "memory",
 		"vector_count": len(s.vectors),
-		"dimension":   s.embedding.GetDimension(),
+		"dimension":   dimension,
+	}
+}
+
+// IndexStats 获取按来源、分块类型统计的索引信息
+func (s *InMemoryVectorStore) IndexStats(ctx context.Context) (*IndexStats, error) {
+	stats := &IndexStats{
+		Type:        "memory",
+		VectorCount: int64(len(s.vectors)),
+		Dimension:   s.dimension,
+	}
+	if stats.Dimension == 0 {
+		stats.Dimension = s.embedding.GetDimension()
 	}
+	s.ledger.fill(stats)
+	return stats, nil
+}
+
+// vectorBytes 分块占用的估算字节数：文本字节数加上向量元素数乘以元素大小
+func vectorBytes(text string, dimension, elemSize int) int64 {
+	return int64(len(text) + dimension*elemSize)
+}
+
+// checkDimension 校验向量维度，第一次写入时确定维度
+func (s *InMemoryVectorStore) checkDimension(vector []float64) error {
+	if s.dimension == 0 {
+		s.dimension = len(vector)
+		return nil
+	}
+	return checkDimension("memory", "", s.dimension, vector)
 }
 
 // DeleteAll 清空所有向量
 func (s *InMemoryVectorStore) DeleteAll() {
 	s.vectors = make([]Vector, 0)
+	s.dimension = 0
+	s.ledger.reset()
 }
 
 // GetVectors 获取所有向量（用于调试）
@@ -109,7 +189,15 @@ func (s *InMemoryVectorStore) GetVectors() []Vector {
 
 // AddBatch 批量添加向量
 func (s *InMemoryVectorStore) AddBatch(ctx context.Context, vectors []Vector) error {
+	for _, v := range vectors {
+		if err := s.checkDimension(v.Data); err != nil {
+			return err
+		}
+	}
 	s.vectors = append(s.vectors, vectors...)
+	for _, v := range vectors {
+		s.ledger.record(v.Text, v.Metadata, vectorBytes(v.Text, len(v.Data), 8))
+	}
 	return nil
 }
 
@@ -118,6 +206,9 @@ func (s *InMemoryVectorStore) SearchWithMetadata(ctx context.Context, queryVecto
 	if len(s.vectors) == 0 {
 		return []Vector{}, nil
 	}
+	if err := checkDimension("memory", "", s.dimension, queryVector); err != nil {
+		return nil, err
+	}
 
 	type Result struct {
 		Vector     Vector
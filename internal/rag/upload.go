@@ -0,0 +1,171 @@
+package rag
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+var (
+	// ErrFileTooLarge 上传文件超过大小限制
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrUnsupportedFileType 不支持的文件类型
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+)
+
+// uploadContentTypes 各扩展名允许的内容类型（http.DetectContentType嗅探结果）
+var uploadContentTypes = map[string][]string{
+	".pdf":  {"application/pdf"},
+	".docx": {"application/zip"},
+	".txt":  {"text/plain"},
+	".md":   {"text/plain"},
+}
+
+// unsafeFileChars 文件名中需要替换的字符
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+// UploadedFile 已保存的上传文件
+type UploadedFile struct {
+	Filename string `json:"filename"` // 原始文件名
+	Path     string `json:"path"`     // 服务端保存路径
+	Size     int64  `json:"size"`
+	Type     string `json:"type"` // 扩展名，如 pdf
+}
+
+// Uploader 知识库上传文件的校验与保存
+type Uploader struct {
+	dir         string
+	maxFileSize int64
+	maxFiles    int
+	allowed     map[string]bool
+}
+
+// NewUploader 根据配置创建上传器
+func NewUploader(cfg config.KnowledgeUploadConfig) *Uploader {
+	u := &Uploader{
+		dir:         cfg.Dir,
+		maxFileSize: int64(cfg.MaxFileSizeMB) << 20,
+		maxFiles:    cfg.MaxFiles,
+		allowed:     make(map[string]bool),
+	}
+	if u.dir == "" {
+		u.dir = "./data/uploads"
+	}
+	if u.maxFileSize <= 0 {
+		u.maxFileSize = 20 << 20
+	}
+	if u.maxFiles <= 0 {
+		u.maxFiles = 10
+	}
+
+	types := cfg.AllowedTypes
+	if len(types) == 0 {
+		types = []string{"pdf", "docx", "txt", "md"}
+	}
+	for _, t := range types {
+		ext := "." + strings.TrimPrefix(strings.ToLower(t), ".")
+		if _, ok := uploadContentTypes[ext]; ok {
+			u.allowed[ext] = true
+		}
+	}
+	return u
+}
+
+// MaxFiles 单次请求最多上传的文件数
+func (u *Uploader) MaxFiles() int {
+	return u.maxFiles
+}
+
+// MaxRequestSize 单次请求体的大小上限（含multipart开销）
+func (u *Uploader) MaxRequestSize() int64 {
+	return u.maxFileSize*int64(u.maxFiles) + 1<<20
+}
+
+// Save 校验类型和大小后保存文件
+func (u *Uploader) Save(header *multipart.FileHeader) (*UploadedFile, error) {
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if !u.allowed[ext] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, header.Filename)
+	}
+	if header.Size > u.maxFileSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d MB", ErrFileTooLarge, header.Filename, u.maxFileSize>>20)
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer src.Close()
+
+	// 按内容嗅探类型，防止改扩展名绕过
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(src, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	if !matchesContentType(ext, contentType) {
+		return nil, fmt.Errorf("%w: %s content is %s", ErrUnsupportedFileType, header.Filename, contentType)
+	}
+
+	if err := os.MkdirAll(u.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	path := filepath.Join(u.dir, storedFileName(header.Filename))
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save upload: %w", err)
+	}
+	defer dst.Close()
+
+	// 大小以实际写入为准，multipart头中的Size可能不可信
+	written, err := io.Copy(dst, io.LimitReader(io.MultiReader(strings.NewReader(string(sniff[:n])), src), u.maxFileSize+1))
+	if err == nil && written > u.maxFileSize {
+		err = fmt.Errorf("%w: %s exceeds %d MB", ErrFileTooLarge, header.Filename, u.maxFileSize>>20)
+	}
+	if err != nil {
+		dst.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &UploadedFile{
+		Filename: header.Filename,
+		Path:     path,
+		Size:     written,
+		Type:     strings.TrimPrefix(ext, "."),
+	}, nil
+}
+
+// matchesContentType 嗅探到的类型是否与扩展名一致
+func matchesContentType(ext, contentType string) bool {
+	for _, allowed := range uploadContentTypes[ext] {
+		if strings.HasPrefix(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// storedFileName 保存时的文件名：时间戳-随机串-清理后的原文件名
+func storedFileName(filename string) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	base := unsafeFileChars.ReplaceAllString(filepath.Base(filename), "_")
+	if len(base) > 100 {
+		base = base[len(base)-100:]
+	}
+	return fmt.Sprintf("%d-%s-%s", time.Now().Unix(), hex.EncodeToString(suffix), base)
+}
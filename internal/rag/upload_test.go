@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"os"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// fileHeader 构造multipart文件头
+func fileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write(content)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm failed: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+// TestUploaderSave 测试上传文件的类型和大小校验
+func TestUploaderSave(t *testing.T) {
+	dir := t.TempDir()
+	uploader := NewUploader(config.KnowledgeUploadConfig{Dir: dir, MaxFileSizeMB: 1, AllowedTypes: []string{"pdf", "txt"}})
+
+	file, err := uploader.Save(fileHeader(t, "../笔记 1.txt", []byte("知识库内容")))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if file.Filename != "笔记 1.txt" || file.Type != "txt" || file.Size != int64(len("知识库内容")) {
+		t.Errorf("unexpected file: %+v", file)
+	}
+	if !strings.HasPrefix(file.Path, dir) || strings.Contains(file.Path, "..") {
+		t.Errorf("file should be stored inside upload dir, got %s", file.Path)
+	}
+	if data, _ := os.ReadFile(file.Path); string(data) != "知识库内容" {
+		t.Errorf("stored content = %q", data)
+	}
+
+	cases := []struct {
+		name     string
+		filename string
+		content  []byte
+		want     error
+	}{
+		{"extension not allowed", "a.docx", []byte("PK\x03\x04"), ErrUnsupportedFileType},
+		{"content mismatch", "a.pdf", []byte("plain text"), ErrUnsupportedFileType},
+		{"too large", "a.txt", bytes.Repeat([]byte("a"), 1<<20+1), ErrFileTooLarge},
+	}
+	for _, tc := range cases {
+		if _, err := uploader.Save(fileHeader(t, tc.filename, tc.content)); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if _, err := uploader.Save(fileHeader(t, "a.pdf", []byte("%PDF-1.4\n"))); err != nil {
+		t.Errorf("valid pdf rejected: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package rag
+
+import (
+	"context"
+
+	"ai-agent-assistant/internal/llm"
+)
+
+// startUsage 为一次RAG查询创建用量收集器
+// 查询过程中所有模型调用的token用量都会累加到该收集器
+func startUsage(ctx context.Context) (context.Context, *llm.UsageCollector) {
+	collector := llm.NewUsageCollector(nil, "rag")
+	return llm.WithUsageCollector(ctx, collector), collector
+}
+
+// usageMetadata 将用量转换为RAGResult元数据
+func usageMetadata(collector *llm.UsageCollector) map[string]interface{} {
+	return map[string]interface{}{
+		"usage": collector.Metadata(),
+	}
+}
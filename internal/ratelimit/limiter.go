@@ -0,0 +1,294 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBudgetName 未匹配任何预算的接口使用的预算名
+const defaultBudgetName = "default"
+
+// idleBucketTTL 空闲超过该时间的客户端令牌桶会被清理
+const idleBucketTTL = 10 * time.Minute
+
+// Budget 一组接口的限流预算
+type Budget struct {
+	Name        string
+	Paths       []string // 路由模式，如 /api/v1/chat/rag；以*结尾表示前缀匹配
+	Rate        float64  // 每秒补充的令牌数
+	Burst       int      // 令牌桶容量
+	MaxInFlight int      // 该预算的全局最大并发，0表示不限制
+
+	inFlight int
+}
+
+// matches 路由是否属于该预算
+func (b *Budget) matches(route string) bool {
+	for _, path := range b.Paths {
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if route == path {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket 令牌桶
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	inFlight int
+}
+
+// take 取一个令牌，不足时返回需等待的时间
+func (b *bucket) take(now time.Time, rate float64, burst int) (bool, time.Duration) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// Decision 限流判定结果
+type Decision struct {
+	Allowed    bool
+	Budget     string
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reason     string // rate_limited, too_many_in_flight
+}
+
+// Limiter 按客户端（API Key/JWT主体，未认证时为IP）的令牌桶限流和并发上限
+// 昂贵接口（如RAG对话、工具执行）可配置独立预算
+type Limiter struct {
+	mu                sync.Mutex
+	budgets           []*Budget
+	defaultBudget     *Budget
+	buckets           map[string]*bucket // budget/client -> 令牌桶
+	clientInFlight    map[string]int
+	inFlight          int
+	maxInFlight       int // 全局最大并发
+	clientMaxInFlight int // 每个客户端最大并发
+	lastSweep         time.Time
+	now               func() time.Time
+}
+
+// NewLimiter 创建限流器
+func NewLimiter(defaultBudget *Budget, budgets []*Budget, maxInFlight, clientMaxInFlight int) *Limiter {
+	if defaultBudget.Name == "" {
+		defaultBudget.Name = defaultBudgetName
+	}
+	return &Limiter{
+		budgets:           budgets,
+		defaultBudget:     defaultBudget,
+		buckets:           make(map[string]*bucket),
+		clientInFlight:    make(map[string]int),
+		maxInFlight:       maxInFlight,
+		clientMaxInFlight: clientMaxInFlight,
+		now:               time.Now,
+	}
+}
+
+// NewLimiterFromConfig 根据配置创建限流器，未启用时返回nil（中间件直接放行）
+func NewLimiterFromConfig(cfg config.RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	budgets := make([]*Budget, 0, len(cfg.Budgets))
+	for _, b := range cfg.Budgets {
+		budgets = append(budgets, newBudget(b.Name, b.Paths, b.RequestsPerMinute, b.Burst, b.MaxInFlight))
+	}
+	defaultBudget := newBudget(defaultBudgetName, nil, cfg.RequestsPerMinute, cfg.Burst, 0)
+
+	return NewLimiter(defaultBudget, budgets, cfg.MaxInFlight, cfg.ClientMaxInFlight)
+}
+
+// Reconfigure 按新配置更新预算和并发上限，用于配置热加载
+// 同名预算原地更新，保留正在处理的请求计数；客户端令牌桶保留，按新容量截断
+func (l *Limiter) Reconfigure(cfg config.RateLimitConfig) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing := make(map[string]*Budget, len(l.budgets))
+	for _, b := range l.budgets {
+		existing[b.Name] = b
+	}
+	budgets := make([]*Budget, 0, len(cfg.Budgets))
+	for _, b := range cfg.Budgets {
+		updated := newBudget(b.Name, b.Paths, b.RequestsPerMinute, b.Burst, b.MaxInFlight)
+		if current, ok := existing[b.Name]; ok {
+			updated.inFlight = current.inFlight
+			*current = *updated
+			updated = current
+		}
+		budgets = append(budgets, updated)
+	}
+	defaults := newBudget(defaultBudgetName, nil, cfg.RequestsPerMinute, cfg.Burst, 0)
+	defaults.inFlight = l.defaultBudget.inFlight
+	*l.defaultBudget = *defaults
+
+	l.budgets = budgets
+	l.maxInFlight = cfg.MaxInFlight
+	l.clientMaxInFlight = cfg.ClientMaxInFlight
+}
+
+// newBudget 按每分钟请求数创建预算
+func newBudget(name string, paths []string, perMinute float64, burst, maxInFlight int) *Budget {
+	if perMinute <= 0 {
+		perMinute = 60
+	}
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(perMinute/6))) // 默认允许10秒的突发量
+	}
+	return &Budget{
+		Name:        name,
+		Paths:       paths,
+		Rate:        perMinute / 60,
+		Burst:       burst,
+		MaxInFlight: maxInFlight,
+	}
+}
+
+// budgetFor 路由对应的预算
+func (l *Limiter) budgetFor(route string) *Budget {
+	for _, b := range l.budgets {
+		if b.matches(route) {
+			return b
+		}
+	}
+	return l.defaultBudget
+}
+
+// Acquire 判定请求是否放行；放行时须在请求结束后调用返回的release
+func (l *Limiter) Acquire(client, route string) (Decision, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweepLocked(now)
+
+	budget := l.budgetFor(route)
+	decision := Decision{Budget: budget.Name, Limit: budget.Burst}
+
+	// 并发上限先于令牌检查，避免被拒绝的请求消耗令牌
+	if (l.maxInFlight > 0 && l.inFlight >= l.maxInFlight) ||
+		(budget.MaxInFlight > 0 && budget.inFlight >= budget.MaxInFlight) ||
+		(l.clientMaxInFlight > 0 && l.clientInFlight[client] >= l.clientMaxInFlight) {
+		decision.Reason = "too_many_in_flight"
+		decision.RetryAfter = time.Second
+		return decision, nil
+	}
+
+	key := budget.Name + "/" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(budget.Burst), last: now}
+		l.buckets[key] = b
+	}
+	allowed, wait := b.take(now, budget.Rate, budget.Burst)
+	decision.Remaining = int(b.tokens)
+	if !allowed {
+		decision.Reason = "rate_limited"
+		decision.RetryAfter = wait
+		return decision, nil
+	}
+
+	decision.Allowed = true
+	l.inFlight++
+	budget.inFlight++
+	b.inFlight++
+	l.clientInFlight[client]++
+
+	var once sync.Once
+	return decision, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.inFlight--
+			budget.inFlight--
+			b.inFlight--
+			if l.clientInFlight[client]--; l.clientInFlight[client] <= 0 {
+				delete(l.clientInFlight, client)
+			}
+		})
+	}
+}
+
+// sweepLocked 清理长时间空闲且已回满的令牌桶，调用方需持有锁
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.inFlight == 0 && now.Sub(b.last) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientKey 限流的客户端标识：已认证时为调用方ID，否则为IP
+func clientKey(c *gin.Context) string {
+	if principal := auth.PrincipalFromContext(c); principal != nil && principal.ID != "" {
+		return principal.Method + ":" + principal.ID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Middleware 限流中间件，超出预算或并发上限时返回429和Retry-After
+// 需挂在认证中间件之后，以便按API Key区分客户端
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		decision, release := l.Acquire(clientKey(c), route)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			apierror.Abort(c, apierror.New(apierror.CodeRateLimited, "too many requests").WithDetails(gin.H{
+				"reason":      decision.Reason,
+				"budget":      decision.Budget,
+				"retry_after": retryAfter,
+			}))
+			return
+		}
+
+		defer release()
+		c.Next()
+	}
+}
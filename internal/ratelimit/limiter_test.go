@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestLimiterBudgets 测试令牌桶、独立预算与并发上限
+func TestLimiterBudgets(t *testing.T) {
+	limiter := NewLimiterFromConfig(config.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 60,
+		Burst:             2,
+		ClientMaxInFlight: 2,
+		Budgets: []config.RateLimitBudgetConfig{
+			{Name: "expensive", Paths: []string{"/api/v1/chat/rag", "/api/v1/tools/*"}, RequestsPerMinute: 6, Burst: 1, MaxInFlight: 1},
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	// 默认预算：突发2个后限流，1秒后补充1个令牌
+	for i := 0; i < 2; i++ {
+		decision, release := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat")
+		if !decision.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+		release()
+	}
+	decision, _ := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat")
+	if decision.Allowed || decision.Reason != "rate_limited" || decision.RetryAfter != time.Second {
+		t.Fatalf("third request should be rate limited with 1s retry, got %+v", decision)
+	}
+	if decision, _ := limiter.Acquire("ip:2.2.2.2", "/api/v1/chat"); !decision.Allowed {
+		t.Error("other clients should have their own bucket")
+	}
+	now = now.Add(time.Second)
+	if decision, release := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat"); !decision.Allowed {
+		t.Error("bucket should refill after 1s")
+	} else {
+		release()
+	}
+
+	// 昂贵接口使用独立预算，并受预算级并发上限约束
+	decision, release := limiter.Acquire("ip:1.1.1.1", "/api/v1/tools/execute")
+	if !decision.Allowed || decision.Budget != "expensive" {
+		t.Fatalf("expensive request should be allowed from its own budget, got %+v", decision)
+	}
+	if decision, _ := limiter.Acquire("ip:2.2.2.2", "/api/v1/chat/rag"); decision.Allowed || decision.Reason != "too_many_in_flight" {
+		t.Errorf("expensive budget in-flight cap should apply across clients, got %+v", decision)
+	}
+	release()
+	if decision, _ := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat/rag"); decision.Allowed || decision.RetryAfter != 10*time.Second {
+		t.Errorf("expensive budget should refill at 6/min, got %+v", decision)
+	}
+}
+
+// TestMiddlewareRetryAfter 测试429响应
+func TestMiddlewareRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewLimiterFromConfig(config.RateLimitConfig{Enabled: true, RequestsPerMinute: 30, Burst: 1})
+
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/api/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/models", nil))
+		return w
+	}
+
+	if w := serve(); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", w.Code)
+	}
+	w := serve()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	// 未启用时直接放行
+	var disabled *Limiter
+	router = gin.New()
+	router.Use(disabled.Middleware())
+	router.GET("/api/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for i := 0; i < 3; i++ {
+		if w := serve(); w.Code != http.StatusOK {
+			t.Fatalf("disabled limiter: status = %d, want 200", w.Code)
+		}
+	}
+}
+
+// TestLimiterReconfigure 测试热加载后预算生效且保留正在处理的请求
+func TestLimiterReconfigure(t *testing.T) {
+	limiter := NewLimiterFromConfig(config.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: 60,
+		Burst:             1,
+		Budgets: []config.RateLimitBudgetConfig{
+			{Name: "expensive", Paths: []string{"/api/v1/chat/rag"}, RequestsPerMinute: 6, Burst: 1, MaxInFlight: 1},
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	_, release := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat/rag")
+	limiter.Reconfigure(config.RateLimitConfig{
+		RequestsPerMinute: 120,
+		Burst:             5,
+		Budgets: []config.RateLimitBudgetConfig{
+			{Name: "expensive", Paths: []string{"/api/v1/chat/rag"}, RequestsPerMinute: 60, Burst: 3, MaxInFlight: 2},
+			{Name: "tools", Paths: []string{"/api/v1/tools/*"}, RequestsPerMinute: 6, Burst: 1},
+		},
+	})
+
+	// 重新加载前的请求仍计入并发
+	decision, release2 := limiter.Acquire("ip:2.2.2.2", "/api/v1/chat/rag")
+	if !decision.Allowed || decision.Limit != 3 {
+		t.Fatalf("reconfigured budget should allow a second in-flight request, got %+v", decision)
+	}
+	if decision, _ := limiter.Acquire("ip:3.3.3.3", "/api/v1/chat/rag"); decision.Allowed || decision.Reason != "too_many_in_flight" {
+		t.Errorf("in-flight requests from before the reload should count, got %+v", decision)
+	}
+	release()
+	release2()
+
+	if decision, _ := limiter.Acquire("ip:1.1.1.1", "/api/v1/tools/execute"); !decision.Allowed || decision.Budget != "tools" {
+		t.Errorf("new budget should apply, got %+v", decision)
+	}
+	if decision, _ := limiter.Acquire("ip:1.1.1.1", "/api/v1/chat"); !decision.Allowed || decision.Limit != 5 {
+		t.Errorf("default budget should be updated, got %+v", decision)
+	}
+}
@@ -26,8 +26,78 @@ func NewChainOfThought(model llm.Model, showReasoning bool) *ChainOfThought {
 
 // Reason 执行推理
 func (cot *ChainOfThought) Reason(ctx context.Context, task string) (reasoning string, answer string, err error) {
-	// 构造思维链提示
-	prompt := fmt.Sprintf(`请逐步思考以下问题，展示你的推理过程。
+	messages := []models.Message{
+		{Role: "user", Content: cot.buildPrompt(task)},
+	}
+
+	// 调用模型
+	response, err := cot.reasoningModel.Chat(ctx, messages)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to reason: %w", err)
+	}
+
+	// 解析响应
+	return cot.parseResponse(response)
+}
+
+// ReasonStream 流式执行推理
+// 思考过程每完成一行即作为一步交给onStep，进入【答案】部分后不再推送；onStep返回错误时停止推理并返回该错误
+func (cot *ChainOfThought) ReasonStream(ctx context.Context, task string, onStep StepHandler) (reasoning string, answer string, err error) {
+	messages := []models.Message{
+		{Role: "user", Content: cot.buildPrompt(task)},
+	}
+
+	stream, err := cot.reasoningModel.ChatStream(ctx, messages)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to reason: %w", err)
+	}
+
+	var response strings.Builder
+	var line strings.Builder
+	index := 0
+	inAnswer := false
+	emit := func() error {
+		content := strings.TrimSpace(line.String())
+		line.Reset()
+		if strings.Contains(content, "【答案】") {
+			inAnswer = true
+		}
+		content = strings.TrimSpace(strings.TrimPrefix(content, "【思考过程】"))
+		if inAnswer || content == "" {
+			return nil
+		}
+		index++
+		return onStep(ReasoningStep{Index: index, Phase: PhaseChainOfThought, Content: content})
+	}
+
+	for chunk := range stream {
+		response.WriteString(chunk)
+		for {
+			i := strings.IndexByte(chunk, '\n')
+			if i < 0 {
+				line.WriteString(chunk)
+				break
+			}
+			line.WriteString(chunk[:i])
+			chunk = chunk[i+1:]
+			if err := emit(); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if err := emit(); err != nil {
+		return "", "", err
+	}
+
+	return cot.parseResponse(response.String())
+}
+
+// buildPrompt 构造思维链提示
+func (cot *ChainOfThought) buildPrompt(task string) string {
+	return fmt.Sprintf(`请逐步思考以下问题，展示你的推理过程。
 
 要求：
 1. 先分析问题的关键点
@@ -43,19 +113,6 @@ func (cot *ChainOfThought) Reason(ctx context.Context, task string) (reasoning s
 
 【答案】
 （你的结论）`, task)
-
-	messages := []models.Message{
-		{Role: "user", Content: prompt},
-	}
-
-	// 调用模型
-	response, err := cot.reasoningModel.Chat(ctx, messages)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to reason: %w", err)
-	}
-
-	// 解析响应
-	return cot.parseResponse(response)
 }
 
 // parseResponse 解析模型响应，分离思考过程和答案
@@ -10,18 +10,36 @@ import (
 )
 
 // ReasoningManager 推理管理器
-// 整合思维链和自我反思，提供完整的推理能力
+// 整合思维链、自我反思和思维树，提供完整的推理能力
 type ReasoningManager struct {
 	cot        *ChainOfThought
 	reflection *Reflection
+	totConfig  ToTConfig
 	model      llm.Model
 }
 
+// 推理步骤所属阶段
+const (
+	PhaseChainOfThought = "cot"        // 思维链思考过程
+	PhaseReflection     = "reflection" // 对思维链答案的反思
+)
+
+// ReasoningStep 推理过程中的一步，用于流式展示推理进度
+type ReasoningStep struct {
+	Index   int    `json:"index"`
+	Phase   string `json:"phase"`
+	Content string `json:"content"`
+}
+
+// StepHandler 接收推理步骤，返回错误时停止推理（如客户端已断开）
+type StepHandler func(step ReasoningStep) error
+
 // NewReasoningManager 创建推理管理器
 func NewReasoningManager(model llm.Model, showReasoning bool, numReflections int) *ReasoningManager {
 	return &ReasoningManager{
 		cot:        NewChainOfThought(model, showReasoning),
 		reflection: NewReflection(model, numReflections),
+		totConfig:  DefaultToTConfig(),
 		model:      model,
 	}
 }
@@ -31,6 +49,24 @@ func (rm *ReasoningManager) ReasonWithCoT(ctx context.Context, task string) (rea
 	return rm.cot.Reason(ctx, task)
 }
 
+// ReasonWithToT 使用思维树推理
+// config中未设置的字段使用管理器的思维树配置
+func (rm *ReasoningManager) ReasonWithToT(ctx context.Context, task string, config ToTConfig) (*ToTResult, error) {
+	if config.Breadth <= 0 {
+		config.Breadth = rm.totConfig.Breadth
+	}
+	if config.Depth <= 0 {
+		config.Depth = rm.totConfig.Depth
+	}
+	if config.BeamWidth <= 0 {
+		config.BeamWidth = rm.totConfig.BeamWidth
+	}
+	if config.PruneThreshold <= 0 {
+		config.PruneThreshold = rm.totConfig.PruneThreshold
+	}
+	return NewTreeOfThoughts(rm.model, config).Solve(ctx, task)
+}
+
 // ReasonWithReflection 使用反思机制推理
 func (rm *ReasoningManager) ReasonWithReflection(ctx context.Context, task string) (finalAnswer string, iterations []Iteration, err error) {
 	// 1. 先获取初始答案
@@ -59,9 +95,41 @@ func (rm *ReasoningManager) ReasonWithCoTAndReflection(ctx context.Context, task
 	}
 
 	// 3. 组合完整的推理过程
-	fullReasoning = fmt.Sprintf("【初步思考】\n%s\n\n【反思改进】\n经过反思和改进，得出更准确的答案。", reasoning)
+	return combineReasoning(reasoning), improvedAnswer, nil
+}
+
+// ReasonWithCoTAndReflectionStream 结合思维链和反思，并流式推送中间推理步骤
+// 思维链的思考过程逐行推送，反思完成后推送反思内容；返回值与ReasonWithCoTAndReflection相同
+func (rm *ReasoningManager) ReasonWithCoTAndReflectionStream(ctx context.Context, task string, onStep StepHandler) (fullReasoning string, finalAnswer string, err error) {
+	steps := 0
+	reasoning, answer, err := rm.cot.ReasonStream(ctx, task, func(step ReasoningStep) error {
+		steps = step.Index
+		return onStep(step)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", "", err
+		}
+		return "", "", fmt.Errorf("chain of thought failed: %w", err)
+	}
+
+	reflection, improvedAnswer, err := rm.reflection.Reflect(ctx, task, []string{answer})
+	if err != nil {
+		// 反思失败，返回思维链的结果
+		return reasoning, answer, nil
+	}
+	if reflection != "" {
+		if err := onStep(ReasoningStep{Index: steps + 1, Phase: PhaseReflection, Content: reflection}); err != nil {
+			return "", "", err
+		}
+	}
 
-	return fullReasoning, improvedAnswer, nil
+	return combineReasoning(reasoning), improvedAnswer, nil
+}
+
+// combineReasoning 组合思维链和反思后的完整推理过程
+func combineReasoning(reasoning string) string {
+	return fmt.Sprintf("【初步思考】\n%s\n\n【反思改进】\n经过反思和改进，得出更准确的答案。", reasoning)
 }
 
 // MultiStepReasoning 多步推理
@@ -173,6 +241,11 @@ func (rm *ReasoningManager) SetNumReflections(num int) {
 	rm.reflection.SetNumReflections(num)
 }
 
+// SetToTConfig 设置思维树推理的默认配置
+func (rm *ReasoningManager) SetToTConfig(config ToTConfig) {
+	rm.totConfig = config.withDefaults()
+}
+
 // GetCoT 获取思维链推理器
 func (rm *ReasoningManager) GetCoT() *ChainOfThought {
 	return rm.cot
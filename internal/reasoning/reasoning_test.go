@@ -2,19 +2,25 @@ package reasoning
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
-	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
 )
 
 // MockReasoningModel 模拟推理模型
 type MockReasoningModel struct {
 	// 用于模拟不同类型的响应
-	cotResponse    string
+	cotResponse        string
 	reflectionResponse string
 }
 
-func (m *MockReasoningModel) Chat(ctx context.Context, messages []llm.Message) (string, error) {
+func (m *MockReasoningModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
 	if len(messages) == 0 {
 		return "", nil
 	}
@@ -49,7 +55,7 @@ func (m *MockReasoningModel) Chat(ctx context.Context, messages []llm.Message) (
 	return "默认响应", nil
 }
 
-func (m *MockReasoningModel) ChatStream(ctx context.Context, messages []llm.Message) (<-chan string, error) {
+func (m *MockReasoningModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
 	ch := make(chan string, 1)
 	resp, _ := m.Chat(ctx, messages)
 	ch <- resp
@@ -78,7 +84,7 @@ func (m *MockReasoningModel) GetProviderName() string {
 }
 
 func contains(s, substr string) bool {
-	return len(s) > 0 && len(substr) > 0 && s[:len(substr)] == substr
+	return strings.Contains(s, substr)
 }
 
 // TestChainOfThought 测试思维链推理
@@ -209,3 +215,251 @@ func TestReasoningManager(t *testing.T) {
 	t.Logf("Final Answer: %s", finalAnswer)
 	t.Logf("Iterations: %d", len(iterations))
 }
+
+// totModel 按思路打分的模拟模型：思路X的候选为X.a、X.b、X.c，得分取自scores（缺省5分）
+type totModel struct {
+	MockReasoningModel
+	scores map[string]string
+}
+
+// totStepPattern 提示词中已有的推理步骤
+var totStepPattern = regexp.MustCompile(`步骤\d+：(.+)`)
+
+func (m *totModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	prompt := messages[0].Content
+	last := ""
+	if steps := totStepPattern.FindAllStringSubmatch(prompt, -1); len(steps) > 0 {
+		last = steps[len(steps)-1][1]
+	}
+	switch {
+	case strings.Contains(prompt, "请评估"):
+		score, ok := m.scores[last]
+		if !ok {
+			score = "5"
+		}
+		return fmt.Sprintf("【分数】\n%s\n\n【理由】\n评估%s", score, last), nil
+	case strings.Contains(prompt, "下一步推理思路"):
+		prefix := ""
+		if last != "" {
+			prefix = last + "."
+		}
+		return fmt.Sprintf("1. %sa\n2. %sb\n3. %sc\n4. %sd", prefix, prefix, prefix, prefix), nil
+	default:
+		return "【答案】\n" + last, nil
+	}
+}
+
+// TestTreeOfThoughts 测试思维树按阈值和束宽剪枝，沿得分最高的路径作答
+func TestTreeOfThoughts(t *testing.T) {
+	model := &totModel{scores: map[string]string{
+		"a": "9", "b": "6", "c": "2",
+		"a.a": "4", "a.b": "8", "a.c": "1",
+		"b.a": "10", "b.b": "3", "b.c": "5",
+	}}
+	result, err := NewTreeOfThoughts(model, ToTConfig{Breadth: 3, Depth: 2, BeamWidth: 2, PruneThreshold: 0.3}).
+		Solve(context.Background(), "问题")
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+
+	// 每条思路只取前Breadth个候选：第一步3个，保留的2条思路各扩展3个
+	if len(result.Nodes) != 9 || result.Depth != 2 {
+		t.Fatalf("Expected 9 nodes over 2 steps, got %d nodes, depth %d", len(result.Nodes), result.Depth)
+	}
+	if result.Answer != "b.a" {
+		t.Errorf("Expected answer from best path b -> b.a, got %q", result.Answer)
+	}
+	if len(result.BestPath) != 2 || result.BestPath[0].Thought != "b" || result.BestPath[1].ParentID != result.BestPath[0].ID {
+		t.Errorf("Unexpected best path: %+v", result.BestPath)
+	}
+
+	// c、a.c低于阈值，a.a、b.b、b.c超出束宽
+	pruned := make(map[string]bool)
+	for _, node := range result.Nodes {
+		if node.Pruned {
+			pruned[node.Thought] = true
+		}
+	}
+	want := map[string]bool{"c": true, "a.c": true, "a.a": true, "b.b": true, "b.c": true}
+	if !reflect.DeepEqual(pruned, want) {
+		t.Errorf("Expected pruned %v, got %v", want, pruned)
+	}
+	if node := result.Nodes[0]; node.Thought != "a" || node.Score != 0.9 || node.Evaluation == "" {
+		t.Errorf("Unexpected first node: %+v", node)
+	}
+}
+
+// TestTreeOfThoughtsAllPruned 测试某一步的候选全部被剪枝时停止展开，沿用上一步的思路
+func TestTreeOfThoughtsAllPruned(t *testing.T) {
+	model := &totModel{scores: map[string]string{
+		"a": "7", "b": "8",
+		"a.a": "1", "a.b": "2", "b.a": "0", "b.b": "1",
+	}}
+	result, err := NewTreeOfThoughts(model, ToTConfig{Breadth: 2, Depth: 3, BeamWidth: 2, PruneThreshold: 0.3}).
+		Solve(context.Background(), "问题")
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if result.Depth != 1 || result.Answer != "b" || len(result.BestPath) != 1 {
+		t.Errorf("Expected to stop after step 1 with answer b, got depth %d, answer %q, path %+v", result.Depth, result.Answer, result.BestPath)
+	}
+	if len(result.Nodes) != 6 {
+		t.Errorf("Expected 6 explored nodes, got %d", len(result.Nodes))
+	}
+}
+
+// TestReasonWithToT 测试请求中未设置的思维树参数使用管理器的配置
+func TestReasonWithToT(t *testing.T) {
+	manager := NewReasoningManager(&totModel{}, true, 1)
+	manager.SetToTConfig(ToTConfig{Breadth: 2, Depth: 3})
+
+	result, err := manager.ReasonWithToT(context.Background(), "问题", ToTConfig{Depth: 1})
+	if err != nil {
+		t.Fatalf("ReasonWithToT failed: %v", err)
+	}
+	if len(result.Nodes) != 2 || result.Depth != 1 {
+		t.Errorf("Expected breadth 2 from manager and depth 1 from request, got %d nodes, depth %d", len(result.Nodes), result.Depth)
+	}
+
+	failing := NewReasoningManager(&failingModel{}, true, 1)
+	if _, err := failing.ReasonWithToT(context.Background(), "问题", ToTConfig{}); err == nil {
+		t.Error("Expected model error to be returned")
+	}
+}
+
+// failingModel 调用总是失败的模型
+type failingModel struct {
+	MockReasoningModel
+}
+
+func (m *failingModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+// TestParseToTResponses 测试候选思路和评分的解析
+func TestParseToTResponses(t *testing.T) {
+	thoughts := parseThoughts("候选如下：\n1. 先分解问题\n2、列出已知条件\n3) 估算范围\n- 反向验证\n")
+	if want := []string{"先分解问题", "列出已知条件", "估算范围", "反向验证"}; !reflect.DeepEqual(thoughts, want) {
+		t.Errorf("parseThoughts = %v, want %v", thoughts, want)
+	}
+	if thoughts := parseThoughts("  只有一个思路  "); !reflect.DeepEqual(thoughts, []string{"只有一个思路"}) {
+		t.Errorf("Expected unnumbered response as a single thought, got %v", thoughts)
+	}
+
+	scores := []struct {
+		response string
+		want     float64
+	}{
+		{"【分数】\n7\n\n【理由】\n思路正确", 0.7},
+		{"理由中提到3个问题\n【分数】\n6", 0.6},
+		{"8.5分", 0.85},
+		{"【分数】15", 1},
+		{"无法评分", 0},
+	}
+	for _, tt := range scores {
+		if got := parseScore(tt.response); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseScore(%q) = %v, want %v", tt.response, got, tt.want)
+		}
+	}
+}
+
+// chunkedModel 流式输出时把响应切成固定字数的片段，片段边界不与行边界对齐
+type chunkedModel struct {
+	MockReasoningModel
+	chunkSize int
+}
+
+func (m *chunkedModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	resp, _ := m.Chat(ctx, messages)
+	runes := []rune(resp)
+	ch := make(chan string, len(runes)/m.chunkSize+1)
+	for i := 0; i < len(runes); i += m.chunkSize {
+		ch <- string(runes[i:min(i+m.chunkSize, len(runes))])
+	}
+	close(ch)
+	return ch, nil
+}
+
+const streamedCoT = `【思考过程】
+第一步：理解题目
+
+第二步：计算25*4
+第三步：得到100
+
+【答案】
+25乘以4等于100`
+
+// TestChainOfThoughtStream 测试思维链的思考过程逐行推送，答案部分不推送
+func TestChainOfThoughtStream(t *testing.T) {
+	model := &chunkedModel{MockReasoningModel: MockReasoningModel{cotResponse: streamedCoT}, chunkSize: 4}
+	cot := NewChainOfThought(model, true)
+
+	steps := make([]ReasoningStep, 0)
+	reasoning, answer, err := cot.ReasonStream(context.Background(), "25 * 4 = ?", func(step ReasoningStep) error {
+		steps = append(steps, step)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReasonStream failed: %v", err)
+	}
+
+	want := []ReasoningStep{
+		{Index: 1, Phase: PhaseChainOfThought, Content: "第一步：理解题目"},
+		{Index: 2, Phase: PhaseChainOfThought, Content: "第二步：计算25*4"},
+		{Index: 3, Phase: PhaseChainOfThought, Content: "第三步：得到100"},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %+v, want %+v", steps, want)
+	}
+	// 流式与非流式的解析结果一致
+	wantReasoning, wantAnswer, _ := cot.Reason(context.Background(), "25 * 4 = ?")
+	if reasoning != wantReasoning || answer != wantAnswer || answer != "25乘以4等于100" {
+		t.Errorf("Expected %q / %q, got %q / %q", wantReasoning, wantAnswer, reasoning, answer)
+	}
+
+	// onStep返回错误（客户端断开）时停止推理
+	errDisconnected := errors.New("client disconnected")
+	calls := 0
+	_, _, err = cot.ReasonStream(context.Background(), "25 * 4 = ?", func(step ReasoningStep) error {
+		calls++
+		return errDisconnected
+	})
+	if !errors.Is(err, errDisconnected) || calls != 1 {
+		t.Errorf("Expected to stop after the first step, got %d calls, %v", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := cot.ReasonStream(ctx, "25 * 4 = ?", func(ReasoningStep) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestReasonWithCoTAndReflectionStream 测试思维链步骤之后推送反思步骤，结果与非流式一致
+func TestReasonWithCoTAndReflectionStream(t *testing.T) {
+	model := &chunkedModel{MockReasoningModel: MockReasoningModel{
+		cotResponse:        streamedCoT,
+		reflectionResponse: "【反思】\n计算无误。\n\n【改进后的答案】\n100",
+	}, chunkSize: 5}
+	manager := NewReasoningManager(model, true, 1)
+
+	steps := make([]ReasoningStep, 0)
+	reasoning, answer, err := manager.ReasonWithCoTAndReflectionStream(context.Background(), "25 * 4 = ?", func(step ReasoningStep) error {
+		steps = append(steps, step)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReasonWithCoTAndReflectionStream failed: %v", err)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("Expected 3 chain-of-thought steps and 1 reflection step, got %+v", steps)
+	}
+	if last := steps[3]; last.Index != 4 || last.Phase != PhaseReflection || last.Content != "计算无误。" {
+		t.Errorf("Unexpected reflection step: %+v", last)
+	}
+
+	wantReasoning, wantAnswer, _ := manager.ReasonWithCoTAndReflection(context.Background(), "25 * 4 = ?")
+	if reasoning != wantReasoning || answer != wantAnswer || answer != "100" {
+		t.Errorf("Expected %q / %q, got %q / %q", wantReasoning, wantAnswer, reasoning, answer)
+	}
+}
@@ -0,0 +1,287 @@
+package reasoning
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// ToTConfig 思维树推理配置，0表示使用默认值
+type ToTConfig struct {
+	Breadth        int     // 每条思路每步扩展的候选数
+	Depth          int     // 最大推理步数
+	BeamWidth      int     // 每步保留的思路数
+	PruneThreshold float64 // 得分低于该值（0-1）的候选直接剪枝
+}
+
+// DefaultToTConfig 返回默认的思维树推理配置
+func DefaultToTConfig() ToTConfig {
+	return ToTConfig{
+		Breadth:        3,
+		Depth:          3,
+		BeamWidth:      2,
+		PruneThreshold: 0.3,
+	}
+}
+
+// withDefaults 未设置的字段使用默认值
+func (c ToTConfig) withDefaults() ToTConfig {
+	defaults := DefaultToTConfig()
+	if c.Breadth <= 0 {
+		c.Breadth = defaults.Breadth
+	}
+	if c.Depth <= 0 {
+		c.Depth = defaults.Depth
+	}
+	if c.BeamWidth <= 0 {
+		c.BeamWidth = defaults.BeamWidth
+	}
+	if c.PruneThreshold <= 0 {
+		c.PruneThreshold = defaults.PruneThreshold
+	}
+	return c
+}
+
+// ThoughtNode 思维树中的一个推理步骤
+type ThoughtNode struct {
+	ID         int     `json:"id"`
+	ParentID   int     `json:"parent_id"` // 第一步的父节点为0
+	Depth      int     `json:"depth"`
+	Thought    string  `json:"thought"`
+	Score      float64 `json:"score"`
+	Evaluation string  `json:"evaluation,omitempty"`
+	Pruned     bool    `json:"pruned"`
+}
+
+// ToTResult 思维树推理结果
+type ToTResult struct {
+	Answer   string         `json:"answer"`
+	BestPath []*ThoughtNode `json:"best_path"` // 最终采用的推理路径
+	Nodes    []*ThoughtNode `json:"nodes"`     // 探索过的全部节点，包括被剪枝的
+	Depth    int            `json:"depth"`     // 实际展开的步数
+}
+
+// TreeOfThoughts 思维树推理
+// 每一步从保留的思路各扩展出多个候选推理步骤，用价值提示词为候选打分，
+// 剪掉低分候选后只保留得分最高的若干条思路继续展开，最后基于最佳路径给出答案
+type TreeOfThoughts struct {
+	model  llm.Model
+	config ToTConfig
+}
+
+// NewTreeOfThoughts 创建思维树推理器
+func NewTreeOfThoughts(model llm.Model, config ToTConfig) *TreeOfThoughts {
+	return &TreeOfThoughts{
+		model:  model,
+		config: config.withDefaults(),
+	}
+}
+
+// totPath 一条思路：从第一步到当前步骤的节点
+type totPath []*ThoughtNode
+
+func (p totPath) last() *ThoughtNode {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[len(p)-1]
+}
+
+// Solve 执行思维树推理
+func (t *TreeOfThoughts) Solve(ctx context.Context, task string) (*ToTResult, error) {
+	result := &ToTResult{Nodes: make([]*ThoughtNode, 0)}
+	frontier := []totPath{{}}
+
+	for depth := 1; depth <= t.config.Depth; depth++ {
+		candidates := make([]totPath, 0)
+		for _, path := range frontier {
+			thoughts, err := t.propose(ctx, task, path)
+			if err != nil {
+				return nil, err
+			}
+			parentID := 0
+			if parent := path.last(); parent != nil {
+				parentID = parent.ID
+			}
+
+			for _, thought := range thoughts {
+				node := &ThoughtNode{
+					ID:       len(result.Nodes) + 1,
+					ParentID: parentID,
+					Depth:    depth,
+					Thought:  thought,
+				}
+				result.Nodes = append(result.Nodes, node)
+
+				candidate := append(append(totPath{}, path...), node)
+				node.Score, node.Evaluation, err = t.evaluate(ctx, task, candidate)
+				if err != nil {
+					return nil, err
+				}
+				if node.Score < t.config.PruneThreshold {
+					node.Pruned = true
+					continue
+				}
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		// 全部候选都被剪枝时停止展开，沿用上一步保留的思路
+		if len(candidates) == 0 {
+			break
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].last().Score > candidates[j].last().Score
+		})
+		if len(candidates) > t.config.BeamWidth {
+			for _, dropped := range candidates[t.config.BeamWidth:] {
+				dropped.last().Pruned = true
+			}
+			candidates = candidates[:t.config.BeamWidth]
+		}
+		frontier = candidates
+		result.Depth = depth
+	}
+
+	result.BestPath = frontier[0]
+	answer, err := t.answer(ctx, task, frontier[0])
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = answer
+	return result, nil
+}
+
+// propose 为思路生成下一步的候选推理步骤
+func (t *TreeOfThoughts) propose(ctx context.Context, task string, path totPath) ([]string, error) {
+	prompt := fmt.Sprintf(`请为以下问题提出%d个不同的下一步推理思路，每个思路只推进一步，彼此之间要有明显差异。
+
+问题：%s
+
+%s请按编号逐行输出，每行一个思路：
+1. （思路一）
+2. （思路二）`, t.config.Breadth, task, formatPath(path))
+
+	response, err := t.chat(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose thoughts: %w", err)
+	}
+
+	thoughts := parseThoughts(response)
+	if len(thoughts) > t.config.Breadth {
+		thoughts = thoughts[:t.config.Breadth]
+	}
+	return thoughts, nil
+}
+
+// evaluate 用价值提示词为思路打分，返回0-1的分数
+func (t *TreeOfThoughts) evaluate(ctx context.Context, task string, path totPath) (float64, string, error) {
+	prompt := fmt.Sprintf(`请评估以下推理思路对解决问题的价值。
+
+问题：%s
+
+%s请判断这条思路是否正确、是否有助于得出答案，给出0到10的分数。
+
+请按以下格式回答：
+【分数】
+（0-10的数字）
+
+【理由】
+（简要说明）`, task, formatPath(path))
+
+	response, err := t.chat(ctx, prompt)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to evaluate thought: %w", err)
+	}
+	return parseScore(response), strings.TrimSpace(response), nil
+}
+
+// answer 基于最佳思路给出最终答案
+func (t *TreeOfThoughts) answer(ctx context.Context, task string, path totPath) (string, error) {
+	prompt := fmt.Sprintf(`请基于以下推理思路回答问题。
+
+问题：%s
+
+%s请按以下格式回答：
+【答案】
+（你的结论）`, task, formatPath(path))
+
+	response, err := t.chat(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to answer: %w", err)
+	}
+	if parts := strings.SplitN(response, "【答案】", 2); len(parts) == 2 {
+		return strings.TrimSpace(parts[1]), nil
+	}
+	return strings.TrimSpace(response), nil
+}
+
+func (t *TreeOfThoughts) chat(ctx context.Context, prompt string) (string, error) {
+	return t.model.Chat(ctx, []models.Message{{Role: "user", Content: prompt}})
+}
+
+// formatPath 格式化已有的推理步骤
+func formatPath(path totPath) string {
+	if len(path) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("已有的推理步骤：\n")
+	for i, node := range path {
+		sb.WriteString(fmt.Sprintf("步骤%d：%s\n", i+1, node.Thought))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// thoughtLinePattern 编号或列表符号开头的思路行
+var thoughtLinePattern = regexp.MustCompile(`^\s*(?:\d+\s*[.、):）]|[-*•])\s*(.+)$`)
+
+// parseThoughts 解析候选思路，没有编号时整段作为一个思路
+func parseThoughts(response string) []string {
+	thoughts := make([]string, 0)
+	for _, line := range strings.Split(response, "\n") {
+		if m := thoughtLinePattern.FindStringSubmatch(line); m != nil {
+			if thought := strings.TrimSpace(m[1]); thought != "" {
+				thoughts = append(thoughts, thought)
+			}
+		}
+	}
+	if len(thoughts) == 0 {
+		if thought := strings.TrimSpace(response); thought != "" {
+			thoughts = append(thoughts, thought)
+		}
+	}
+	return thoughts
+}
+
+// scorePattern 评分中的第一个数字
+var scorePattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// parseScore 解析评分，0-10的分数换算为0-1，无法解析时返回0
+func parseScore(response string) float64 {
+	text := response
+	if parts := strings.SplitN(response, "【分数】", 2); len(parts) == 2 {
+		text = parts[1]
+	}
+	match := scorePattern.FindString(text)
+	if match == "" {
+		return 0
+	}
+	score, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0
+	}
+	score /= 10
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
@@ -0,0 +1,265 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// ErrReportNotFound 报告不存在
+var ErrReportNotFound = errors.New("report not found")
+
+// Status 报告状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Step 报告生成流水线中一个步骤的结果
+type Step struct {
+	ID       string      `json:"id"`
+	Agent    string      `json:"agent"`
+	Status   string      `json:"status"`
+	Output   interface{} `json:"output,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Duration string      `json:"duration,omitempty"`
+}
+
+// Report 报告及其生成过程
+// Document为最终的报告正文（Markdown），Research和Analysis为中间结果
+type Report struct {
+	ID          string      `json:"report_id"`
+	Topic       string      `json:"topic"`
+	Sections    []string    `json:"sections,omitempty"`
+	Status      Status      `json:"status"`
+	Owner       string      `json:"owner,omitempty"`  // 提交者，与作业的所属调用方一致
+	Tenant      string      `json:"tenant,omitempty"` // 提交者所属租户
+	JobID       string      `json:"job_id,omitempty"`
+	WorkflowID  string      `json:"workflow_id,omitempty"`
+	ExecutionID string      `json:"execution_id,omitempty"`
+	Steps       []Step      `json:"steps,omitempty"`
+	Research    interface{} `json:"research,omitempty"`
+	Analysis    interface{} `json:"analysis,omitempty"`
+	Document    string      `json:"document,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	StartedAt   *time.Time  `json:"started_at,omitempty"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
+
+// New 创建待生成的报告
+func New(id, topic string, sections []string) *Report {
+	return &Report{
+		ID:        id,
+		Topic:     topic,
+		Sections:  sections,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Start 标记为生成中
+func (r *Report) Start() {
+	now := time.Now()
+	r.Status = StatusRunning
+	r.StartedAt = &now
+}
+
+// Complete 标记为完成并记录报告正文
+func (r *Report) Complete(document string) {
+	now := time.Now()
+	r.Status = StatusCompleted
+	r.Document = document
+	r.CompletedAt = &now
+}
+
+// Fail 标记为失败
+func (r *Report) Fail(err error) {
+	now := time.Now()
+	r.Status = StatusFailed
+	r.Error = err.Error()
+	r.CompletedAt = &now
+}
+
+// Finished 是否已结束
+func (r *Report) Finished() bool {
+	return r.Status == StatusCompleted || r.Status == StatusFailed
+}
+
+// Duration 生成耗时，未结束时为已运行时间
+func (r *Report) Duration() time.Duration {
+	if r.StartedAt == nil {
+		return 0
+	}
+	if r.CompletedAt != nil {
+		return r.CompletedAt.Sub(*r.StartedAt)
+	}
+	return time.Since(*r.StartedAt)
+}
+
+// clone 复制报告，避免生成中的报告与读取方共享切片
+func (r *Report) clone() *Report {
+	c := *r
+	c.Sections = append([]string(nil), r.Sections...)
+	c.Steps = append([]Step(nil), r.Steps...)
+	return &c
+}
+
+// Store 报告存储
+type Store interface {
+	// Save 保存或更新报告
+	Save(ctx context.Context, report *Report) error
+
+	// Get 获取报告
+	Get(ctx context.Context, id string) (*Report, error)
+}
+
+// NewStoreFromConfig 根据配置创建报告存储
+func NewStoreFromConfig(cfg config.ReportStoreConfig) (Store, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported report store: %s", cfg.Store)
+	}
+}
+
+// MemoryStore 内存报告存储
+type MemoryStore struct {
+	mu      sync.RWMutex
+	reports map[string]*Report
+}
+
+// NewMemoryStore 创建内存报告存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		reports: make(map[string]*Report),
+	}
+}
+
+// Save 保存报告
+func (s *MemoryStore) Save(ctx context.Context, report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[report.ID] = report.clone()
+	return nil
+}
+
+// Get 获取报告
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report, ok := s.reports[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReportNotFound, id)
+	}
+	return report.clone(), nil
+}
+
+// FileStore 文件报告存储
+// 每份报告一个JSON文件，完成后另存一份Markdown正文（<id>.md）便于直接查看
+// 启动时加载全部报告；上次运行中未完成的报告标记为失败
+type FileStore struct {
+	*MemoryStore
+	dir string
+	mu  sync.Mutex // 串行化文件写入
+}
+
+// NewFileStore 创建文件报告存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		dir = "./data/reports"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		dir:         dir,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 加载已有报告
+func (s *FileStore) load() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read report: %w", err)
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return fmt.Errorf("failed to decode report %s: %w", filepath.Base(file), err)
+		}
+
+		if !report.Finished() {
+			report.Fail(errors.New("report generation interrupted by server restart"))
+			if err := s.Save(ctx, &report); err != nil {
+				return err
+			}
+			continue
+		}
+		_ = s.MemoryStore.Save(ctx, &report)
+	}
+	return nil
+}
+
+// Save 保存报告并写入文件
+func (s *FileStore) Save(ctx context.Context, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base := filepath.Join(s.dir, filepath.Base(report.ID))
+	if err := writeFile(base+".json", data); err != nil {
+		return err
+	}
+	if report.Status == StatusCompleted && report.Document != "" {
+		if err := writeFile(base+".md", []byte(report.Document)); err != nil {
+			return err
+		}
+	}
+
+	return s.MemoryStore.Save(ctx, report)
+}
+
+// writeFile 先写临时文件再重命名，避免读到写了一半的文件
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileStore 测试报告的状态变更、Markdown正文落盘与重启恢复
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	done := New("report-1", "AI技术发展", []string{"研究", "总结"})
+	done.Start()
+	done.Steps = append(done.Steps, Step{ID: "write", Agent: "writer", Status: "completed"})
+	done.Complete("# AI技术发展\n\n正文")
+	if err := store.Save(ctx, done); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	running := New("report-2", "云计算", nil)
+	running.Start()
+	store.Save(ctx, running)
+
+	got, err := store.Get(ctx, "report-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != StatusCompleted || got.Document == "" || len(got.Steps) != 1 || got.Duration() < 0 {
+		t.Errorf("unexpected report: %+v", got)
+	}
+	if markdown, err := os.ReadFile(filepath.Join(dir, "report-1.md")); err != nil || string(markdown) != done.Document {
+		t.Errorf("completed report should be written as markdown: %q, %v", markdown, err)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrReportNotFound) {
+		t.Errorf("expected ErrReportNotFound, got %v", err)
+	}
+
+	// 重新打开后，未完成的报告标记为失败
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	interrupted, err := reopened.Get(ctx, "report-2")
+	if err != nil {
+		t.Fatalf("Get after reopen failed: %v", err)
+	}
+	if interrupted.Status != StatusFailed || interrupted.Error == "" || interrupted.CompletedAt == nil {
+		t.Errorf("running report should be marked failed after restart, got %+v", interrupted)
+	}
+	if got, _ := reopened.Get(ctx, "report-1"); got == nil || got.Document != done.Document {
+		t.Errorf("completed report should survive restart, got %+v", got)
+	}
+}
@@ -0,0 +1,428 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 支持的密钥引用前缀
+const (
+	SchemeVault = "vault" // vault://<路径>#<字段>，路径为完整的API路径，如 secret/data/ai-agent
+	SchemeAWS   = "awssm" // awssm://<SecretId>#<JSON字段>，SecretId可以是名称或ARN
+)
+
+// EventSecretsRotated 密钥轮换事件名
+const EventSecretsRotated = "secrets.rotated"
+
+// defaultTimeout 单次读取密钥的默认超时时间
+const defaultTimeout = 5 * time.Second
+
+// Provider 密钥后端
+type Provider interface {
+	// Read 读取路径下的全部字段，只有一个值的密钥以空字符串为字段名
+	Read(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Reference 密钥引用
+type Reference struct {
+	Scheme string
+	Path   string
+	Key    string // 为空时密钥只能有一个字段
+}
+
+func (r Reference) String() string {
+	s := r.Scheme + "://" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// ParseReference 解析密钥引用，不是 vault:// 或 awssm:// 开头的值返回false
+func ParseReference(value string) (Reference, bool, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(value), "://")
+	if !ok || (scheme != SchemeVault && scheme != SchemeAWS) {
+		return Reference{}, false, nil
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	ref := Reference{Scheme: scheme, Path: strings.Trim(path, "/"), Key: key}
+	if ref.Path == "" {
+		return ref, true, fmt.Errorf("invalid secret reference %q: missing path", value)
+	}
+	return ref, true, nil
+}
+
+// field 取出引用的字段
+func (r Reference) field(fields map[string]string) (string, error) {
+	if r.Key != "" {
+		value, ok := fields[r.Key]
+		if !ok {
+			return "", fmt.Errorf("key %q not found", r.Key)
+		}
+		return value, nil
+	}
+	if len(fields) != 1 {
+		return "", fmt.Errorf("secret has %d fields, specify one with #key", len(fields))
+	}
+	for _, value := range fields {
+		return value, nil
+	}
+	return "", nil
+}
+
+// Rotation 密钥轮换事件
+type Rotation struct {
+	Keys []string  // 值发生变化的配置项
+	At   time.Time // 检测到变化的时间
+}
+
+// binding 配置项与密钥引用的绑定
+type binding struct {
+	ref   Reference
+	value string
+}
+
+// Resolver 解析配置中的密钥引用
+// 启动时把引用替换为密钥的值，之后按间隔重新读取，值变化时通知订阅者，
+// 配置文件中只保存引用，不保存密钥本身
+type Resolver struct {
+	providers map[string]Provider
+	timeout   time.Duration
+	interval  time.Duration
+
+	mu       sync.RWMutex
+	bindings map[string]*binding // 配置项 -> 引用
+	handlers []func(Rotation)
+}
+
+// NewResolver 创建密钥解析器，需通过SetProvider注册后端
+func NewResolver() *Resolver {
+	return &Resolver{
+		providers: make(map[string]Provider),
+		timeout:   defaultTimeout,
+		bindings:  make(map[string]*binding),
+	}
+}
+
+// NewResolverFromConfig 根据配置创建密钥解析器，未启用时返回nil
+// 配置了地址（或 VAULT_ADDR）时注册Vault后端，配置了区域（或 AWS_REGION）时注册AWS Secrets Manager后端
+func NewResolverFromConfig(cfg config.SecretsConfig) (*Resolver, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	r := NewResolver()
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid secrets timeout %q", cfg.Timeout)
+		}
+		r.timeout = d
+	}
+	if cfg.RotationInterval != "" {
+		d, err := time.ParseDuration(cfg.RotationInterval)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid secrets rotation interval %q", cfg.RotationInterval)
+		}
+		r.interval = d
+	}
+
+	vault := cfg.Vault
+	if vault.Address == "" {
+		vault.Address = os.Getenv("VAULT_ADDR")
+	}
+	if vault.Token == "" {
+		vault.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if vault.Namespace == "" {
+		vault.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if vault.Address != "" {
+		r.SetProvider(SchemeVault, NewVaultProvider(vault.Address, vault.Token, vault.Namespace))
+	}
+
+	if aws := NewAWSProvider(cfg.AWS); aws != nil {
+		r.SetProvider(SchemeAWS, aws)
+	}
+	return r, nil
+}
+
+// SetProvider 注册密钥后端
+func (r *Resolver) SetProvider(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+// OnRotate 订阅密钥轮换，处理函数按订阅顺序同步调用
+func (r *Resolver) OnRotate(handler func(Rotation)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+// ResolveConfig 把配置中的密钥引用替换为密钥的值，并记录引用以便检查轮换
+// secrets 配置段本身不解析；解析器为nil时配置中出现引用视为错误，避免把引用当作密钥使用
+func (r *Resolver) ResolveConfig(ctx context.Context, cfg *config.Config) error {
+	refs := make(map[string]Reference)
+	var errs []error
+	walkStrings(reflect.ValueOf(cfg), "", func(key, value string) (string, bool) {
+		if strings.HasPrefix(key, "secrets.") {
+			return "", false
+		}
+		ref, ok, err := ParseReference(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		} else if ok {
+			refs[key] = ref
+		}
+		return "", false
+	})
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	if r == nil {
+		return fmt.Errorf("config contains secret references but secrets are not enabled: %s", strings.Join(sortedKeys(refs), ", "))
+	}
+
+	bindings, err := r.fetch(ctx, refs)
+	if err != nil {
+		return err
+	}
+	walkStrings(reflect.ValueOf(cfg), "", func(key, value string) (string, bool) {
+		if b, ok := bindings[key]; ok {
+			return b.value, true
+		}
+		return "", false
+	})
+
+	r.mu.Lock()
+	r.bindings = bindings
+	r.mu.Unlock()
+	return nil
+}
+
+// fetch 读取引用的密钥，同一路径只读取一次
+func (r *Resolver) fetch(ctx context.Context, refs map[string]Reference) (map[string]*binding, error) {
+	cache := make(map[string]map[string]string)
+	bindings := make(map[string]*binding, len(refs))
+	var errs []error
+	for _, key := range sortedKeys(refs) {
+		ref := refs[key]
+		source := ref.Scheme + "://" + ref.Path
+		fields, ok := cache[source]
+		if !ok {
+			provider, found := r.providers[ref.Scheme]
+			if !found {
+				errs = append(errs, fmt.Errorf("%s: no provider configured for %s:// references", key, ref.Scheme))
+				continue
+			}
+			readCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			var err error
+			fields, err = provider.Read(readCtx, ref.Path)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: failed to read %s: %w", key, source, err))
+				continue
+			}
+			cache[source] = fields
+		}
+		value, err := ref.field(fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", key, ref, err))
+			continue
+		}
+		bindings[key] = &binding{ref: ref, value: value}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return bindings, nil
+}
+
+// Keys 通过引用解析的配置项
+func (r *Resolver) Keys() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedKeys(r.bindings)
+}
+
+// Value 配置项当前的密钥值
+func (r *Resolver) Value(key string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.bindings[key]
+	if !ok {
+		return "", false
+	}
+	return b.value, true
+}
+
+// Apply 把当前的密钥值写入target，target为配置项prefix对应的结构体指针，如 (&cfg.Models, "models")
+// 返回写入的配置项数量
+func (r *Resolver) Apply(target interface{}, prefix string) int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	applied := 0
+	walkStrings(reflect.ValueOf(target), prefix, func(key, value string) (string, bool) {
+		b, ok := r.bindings[key]
+		if !ok || b.value == value {
+			return "", false
+		}
+		applied++
+		return b.value, true
+	})
+	return applied
+}
+
+// Refresh 重新读取所有引用的密钥，值有变化时更新并通知订阅者
+// 没有变化时返回nil；读取失败时保留当前的值
+func (r *Resolver) Refresh(ctx context.Context) (*Rotation, error) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.RLock()
+	refs := make(map[string]Reference, len(r.bindings))
+	for key, b := range r.bindings {
+		refs[key] = b.ref
+	}
+	r.mu.RUnlock()
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	fetched, err := r.fetch(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	changed := make([]string, 0)
+	for key, b := range fetched {
+		if current, ok := r.bindings[key]; ok && current.ref == b.ref && current.value != b.value {
+			current.value = b.value
+			changed = append(changed, key)
+		}
+	}
+	handlers := make([]func(Rotation), len(r.handlers))
+	copy(handlers, r.handlers)
+	r.mu.Unlock()
+
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	sort.Strings(changed)
+	rotation := Rotation{Keys: changed, At: time.Now()}
+	for _, handler := range handlers {
+		handler(rotation)
+	}
+	return &rotation, nil
+}
+
+// Start 按配置的间隔检查密钥轮换，ctx结束后停止；未配置间隔时不检查
+func (r *Resolver) Start(ctx context.Context) {
+	if r == nil || r.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rotation, err := r.Refresh(ctx)
+				switch {
+				case err != nil:
+					log.Printf("Secret refresh failed, keeping current values: %v", err)
+				case rotation != nil:
+					log.Printf("Secrets rotated: %v", rotation.Keys)
+				}
+			}
+		}
+	}()
+}
+
+// walkStrings 遍历结构体中的字符串（含列表元素和键值对的值），配置项名称与mapstructure标签一致
+// fn返回true时把值替换为返回的新值；返回是否替换过任何值
+func walkStrings(v reflect.Value, key string, fn func(key, value string) (string, bool)) bool {
+	changed := false
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			changed = walkStrings(v.Elem(), key, fn)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("mapstructure")
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			changed = walkStrings(v.Field(i), joinKey(key, tag), fn) || changed
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			changed = walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", key, i), fn) || changed
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		for _, mapKey := range v.MapKeys() {
+			// 键值对的值不可寻址，复制后处理再写回
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(mapKey))
+			if walkStrings(elem, joinKey(key, mapKey.String()), fn) {
+				v.SetMapIndex(mapKey, elem)
+				changed = true
+			}
+		}
+	case reflect.String:
+		if replaced, ok := fn(key, v.String()); ok && v.CanSet() {
+			v.SetString(replaced)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
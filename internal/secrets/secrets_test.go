@@ -0,0 +1,223 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// TestParseReference 测试引用解析
+func TestParseReference(t *testing.T) {
+	ref, ok, err := ParseReference("vault://secret/data/ai-agent#glm_api_key")
+	if err != nil || !ok || ref.Path != "secret/data/ai-agent" || ref.Key != "glm_api_key" {
+		t.Fatalf("vault reference = %+v, %v, %v", ref, ok, err)
+	}
+	ref, ok, err = ParseReference("awssm://arn:aws:secretsmanager:us-east-1:123:secret:db")
+	if err != nil || !ok || ref.Scheme != SchemeAWS || ref.Path != "arn:aws:secretsmanager:us-east-1:123:secret:db" || ref.Key != "" {
+		t.Fatalf("aws reference = %+v, %v, %v", ref, ok, err)
+	}
+	for _, value := range []string{"sk-plain", "https://example.com", ""} {
+		if _, ok, _ := ParseReference(value); ok {
+			t.Errorf("%q should not be a reference", value)
+		}
+	}
+	if _, _, err := ParseReference("vault://#key"); err == nil {
+		t.Error("reference without path should be rejected")
+	}
+}
+
+// fakeProvider 可修改内容的密钥后端
+type fakeProvider struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]string
+	reads   int
+}
+
+func (p *fakeProvider) Read(ctx context.Context, path string) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reads++
+	fields := make(map[string]string)
+	for key, value := range p.secrets[path] {
+		fields[key] = value
+	}
+	return fields, nil
+}
+
+func (p *fakeProvider) set(path, key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secrets[path][key] = value
+}
+
+// TestResolveConfigAndRotation 测试解析配置中的引用、同一路径只读取一次以及轮换检测
+func TestResolveConfigAndRotation(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]map[string]string{
+		"secret/data/ai-agent": {"glm": "glm-key-1", "db": "db-pass"},
+	}}
+	r := NewResolver()
+	r.SetProvider(SchemeVault, provider)
+
+	cfg := &config.Config{}
+	cfg.Models.GLM.APIKey = "vault://secret/data/ai-agent#glm"
+	cfg.Models.Qwen.APIKey = "plain-qwen-key"
+	cfg.Database.MySQL.Password = "vault://secret/data/ai-agent#db"
+	cfg.Secrets.Vault.Token = "vault://ignored#token"
+
+	if err := r.ResolveConfig(context.Background(), cfg); err != nil {
+		t.Fatalf("ResolveConfig: %v", err)
+	}
+	if cfg.Models.GLM.APIKey != "glm-key-1" || cfg.Database.MySQL.Password != "db-pass" {
+		t.Fatalf("unresolved config: glm=%q db=%q", cfg.Models.GLM.APIKey, cfg.Database.MySQL.Password)
+	}
+	if cfg.Models.Qwen.APIKey != "plain-qwen-key" || cfg.Secrets.Vault.Token != "vault://ignored#token" {
+		t.Fatal("plain values and the secrets section must be left untouched")
+	}
+	if provider.reads != 1 {
+		t.Errorf("expected one read per path, got %d", provider.reads)
+	}
+	if keys := r.Keys(); len(keys) != 2 || keys[0] != "database.mysql.password" || keys[1] != "models.glm.api_key" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	var rotated []Rotation
+	r.OnRotate(func(rotation Rotation) { rotated = append(rotated, rotation) })
+	if rotation, err := r.Refresh(context.Background()); err != nil || rotation != nil {
+		t.Fatalf("refresh without change = %v, %v", rotation, err)
+	}
+
+	provider.set("secret/data/ai-agent", "glm", "glm-key-2")
+	rotation, err := r.Refresh(context.Background())
+	if err != nil || rotation == nil || len(rotation.Keys) != 1 || rotation.Keys[0] != "models.glm.api_key" {
+		t.Fatalf("rotation = %+v, %v", rotation, err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("expected one rotation event, got %d", len(rotated))
+	}
+
+	models := cfg.Models
+	if n := r.Apply(&models, "models"); n != 1 || models.GLM.APIKey != "glm-key-2" {
+		t.Fatalf("Apply = %d, glm=%q", n, models.GLM.APIKey)
+	}
+	if cfg.Models.GLM.APIKey != "glm-key-1" {
+		t.Error("Apply must only modify the target")
+	}
+}
+
+// TestResolveConfigErrors 测试未启用或缺少字段时的错误
+func TestResolveConfigErrors(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.JWT.HMACSecret = "vault://secret/data/ai-agent#jwt"
+
+	var disabled *Resolver
+	if err := disabled.ResolveConfig(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "auth.jwt.hmac_secret") {
+		t.Fatalf("expected disabled error naming the key, got %v", err)
+	}
+
+	r := NewResolver()
+	if err := r.ResolveConfig(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "no provider") {
+		t.Fatalf("expected missing provider error, got %v", err)
+	}
+
+	r.SetProvider(SchemeVault, &fakeProvider{secrets: map[string]map[string]string{
+		"secret/data/ai-agent": {"other": "x"},
+	}})
+	if err := r.ResolveConfig(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), `key "jwt" not found`) {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+	if cfg.Auth.JWT.HMACSecret != "vault://secret/data/ai-agent#jwt" {
+		t.Error("config must be unchanged when resolution fails")
+	}
+}
+
+// TestVaultProvider 测试KV v2响应解析和请求头
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/ai-agent" || r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"api_key": "sk-123", "port": 5432},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	}))
+	defer server.Close()
+
+	fields, err := NewVaultProvider(server.URL, "root", "team").Read(context.Background(), "secret/data/ai-agent")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if fields["api_key"] != "sk-123" || fields["port"] != "5432" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	_, err = NewVaultProvider(server.URL, "wrong", "").Read(context.Background(), "secret/data/ai-agent")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected vault error, got %v", err)
+	}
+}
+
+// TestAWSProvider 测试GetSecretValue请求和JSON密钥解析
+func TestAWSProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/secretsmanager/aws4_request") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch body["SecretId"] {
+		case "prod/ai-agent":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"db_password":"p@ss"}`})
+		case "plain":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "just-a-token"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "ResourceNotFoundException", "message": "not found"})
+		}
+	}))
+	defer server.Close()
+
+	p := NewAWSProvider(config.AWSSecretsConfig{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: server.URL})
+	p.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	fields, err := p.Read(context.Background(), "prod/ai-agent")
+	if err != nil || fields["db_password"] != "p@ss" {
+		t.Fatalf("json secret = %v, %v", fields, err)
+	}
+	fields, err = p.Read(context.Background(), "plain")
+	if err != nil || fields[""] != "just-a-token" {
+		t.Fatalf("plain secret = %v, %v", fields, err)
+	}
+	if _, err := p.Read(context.Background(), "missing"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+// TestSignV4 使用AWS文档中的签名示例校验签名算法
+func TestSignV4(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header = http.Header{}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultProvider 通过HTTP API读取Vault密钥，兼容KV v1和KV v2引擎
+// KV v2 的路径需包含 data 段，如 secret/data/ai-agent
+type VaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider 创建Vault后端
+func NewVaultProvider(address, token, namespace string) *VaultProvider {
+	return &VaultProvider{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Read 读取路径下的全部字段
+func (p *VaultProvider) Read(ctx context.Context, path string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %w", err)
+	}
+
+	var result struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(result.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	data := result.Data
+	// KV v2 把字段放在 data.data 中，同时返回 data.metadata
+	if inner, ok := data["data"]; ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nil
+			if err := json.Unmarshal(inner, &data); err != nil {
+				return nil, fmt.Errorf("failed to parse vault kv v2 data: %w", err)
+			}
+		}
+	}
+	return stringFields(data), nil
+}
+
+// stringFields 把JSON字段转为字符串，非字符串的值保留JSON表示
+func stringFields(data map[string]json.RawMessage) map[string]string {
+	fields := make(map[string]string, len(data))
+	for key, raw := range data {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			fields[key] = s
+		} else {
+			fields[key] = string(raw)
+		}
+	}
+	return fields
+}
@@ -23,6 +23,16 @@ type TaskResult struct {
 	Metadata   map[string]interface{} `json:"metadata"`
 	Timestamp  time.Time              `json:"timestamp"`
 	AgentUsed  string                 `json:"agent_used,omitempty"`
+	Artifacts  []ArtifactRef          `json:"artifacts,omitempty"` // 任务生成的图表等产物
+}
+
+// ArtifactRef 任务生成的产物引用，内容通过URL获取
+type ArtifactRef struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
 }
 
 // AggregateResult 聚合结果
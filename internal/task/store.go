@@ -0,0 +1,271 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// ErrTaskNotFound 任务不存在
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskTransition 任务状态变更记录
+type TaskTransition struct {
+	Status TaskStatus `json:"status"`
+	Reason string     `json:"reason,omitempty"`
+	At     time.Time  `json:"at"`
+}
+
+// TaskRecord 已提交任务的执行记录，包括状态变更、结果和错误
+type TaskRecord struct {
+	TaskID      string                 `json:"task_id"`
+	BatchID     string                 `json:"batch_id,omitempty"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	Type        string                 `json:"type"`
+	Goal        string                 `json:"goal"`
+	Agent       string                 `json:"agent"`
+	Priority    TaskPriority           `json:"priority"`
+	Status      TaskStatus             `json:"status"`
+	Output      interface{}            `json:"output,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Transitions []TaskTransition       `json:"transitions"`
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// NewTaskRecord 为刚提交的任务创建记录
+func NewTaskRecord(t *Task, agent string) *TaskRecord {
+	record := &TaskRecord{
+		TaskID:    t.ID,
+		Type:      t.Type,
+		Goal:      t.Goal,
+		Agent:     agent,
+		Priority:  t.Priority,
+		CreatedAt: t.CreatedAt,
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	record.Transition(TaskStatusPending, "submitted")
+	return record
+}
+
+// Transition 变更状态并记录时间
+func (r *TaskRecord) Transition(status TaskStatus, reason string) {
+	now := time.Now()
+	r.Status = status
+	r.Transitions = append(r.Transitions, TaskTransition{Status: status, Reason: reason, At: now})
+
+	switch status {
+	case TaskStatusRunning:
+		if r.StartedAt == nil {
+			r.StartedAt = &now
+		}
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled:
+		if r.CompletedAt == nil {
+			r.CompletedAt = &now
+		}
+	}
+}
+
+// Finished 是否已结束
+func (r *TaskRecord) Finished() bool {
+	switch r.Status {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Duration 执行耗时，未结束时为已运行时间
+func (r *TaskRecord) Duration() time.Duration {
+	if r.StartedAt == nil {
+		return 0
+	}
+	if r.CompletedAt != nil {
+		return r.CompletedAt.Sub(*r.StartedAt)
+	}
+	return time.Since(*r.StartedAt)
+}
+
+// clone 复制记录，避免执行中的任务与读取方共享切片
+func (r *TaskRecord) clone() *TaskRecord {
+	c := *r
+	c.Transitions = append([]TaskTransition(nil), r.Transitions...)
+	if r.Metadata != nil {
+		c.Metadata = make(map[string]interface{}, len(r.Metadata))
+		for k, v := range r.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return &c
+}
+
+// TaskStore 任务记录存储
+type TaskStore interface {
+	// Save 保存或更新任务记录
+	Save(ctx context.Context, record *TaskRecord) error
+
+	// Get 获取任务记录
+	Get(ctx context.Context, taskID string) (*TaskRecord, error)
+
+	// List 按创建时间倒序列出任务，status为空表示全部，limit<=0表示不限制
+	List(ctx context.Context, status TaskStatus, limit int) ([]*TaskRecord, error)
+}
+
+// NewTaskStoreFromConfig 根据配置创建任务存储
+func NewTaskStoreFromConfig(cfg config.TaskStoreConfig) (TaskStore, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryTaskStore(), nil
+	case "file":
+		return NewFileTaskStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported task store: %s", cfg.Store)
+	}
+}
+
+// MemoryTaskStore 内存任务存储
+type MemoryTaskStore struct {
+	mu      sync.RWMutex
+	records map[string]*TaskRecord
+}
+
+// NewMemoryTaskStore 创建内存任务存储
+func NewMemoryTaskStore() *MemoryTaskStore {
+	return &MemoryTaskStore{
+		records: make(map[string]*TaskRecord),
+	}
+}
+
+// Save 保存任务记录
+func (s *MemoryTaskStore) Save(ctx context.Context, record *TaskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.TaskID] = record.clone()
+	return nil
+}
+
+// Get 获取任务记录
+func (s *MemoryTaskStore) Get(ctx context.Context, taskID string) (*TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[taskID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	return record.clone(), nil
+}
+
+// List 列出任务记录
+func (s *MemoryTaskStore) List(ctx context.Context, status TaskStatus, limit int) ([]*TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]*TaskRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if status == "" || record.Status == status {
+			records = append(records, record.clone())
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// FileTaskStore 文件任务存储，每个任务一个JSON文件
+// 启动时加载全部记录；上次运行中未结束的任务标记为失败
+type FileTaskStore struct {
+	*MemoryTaskStore
+	dir string
+	mu  sync.Mutex // 串行化文件写入
+}
+
+// NewFileTaskStore 创建文件任务存储
+func NewFileTaskStore(dir string) (*FileTaskStore, error) {
+	if dir == "" {
+		dir = "./data/tasks"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task directory: %w", err)
+	}
+
+	s := &FileTaskStore{
+		MemoryTaskStore: NewMemoryTaskStore(),
+		dir:             dir,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 加载已有任务记录
+func (s *FileTaskStore) load() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read task record: %w", err)
+		}
+		var record TaskRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("failed to decode task record %s: %w", filepath.Base(file), err)
+		}
+
+		if !record.Finished() {
+			record.Transition(TaskStatusFailed, "interrupted by server restart")
+			record.Error = "task interrupted by server restart"
+			if err := s.Save(ctx, &record); err != nil {
+				return err
+			}
+			continue
+		}
+		_ = s.MemoryTaskStore.Save(ctx, &record)
+	}
+	return nil
+}
+
+// Save 保存任务记录并写入文件
+func (s *FileTaskStore) Save(ctx context.Context, record *TaskRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode task record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, filepath.Base(record.TaskID)+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write task record: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write task record: %w", err)
+	}
+
+	return s.MemoryTaskStore.Save(ctx, record)
+}
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestFileTaskStore 测试任务记录的状态变更与持久化
+func TestFileTaskStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileTaskStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileTaskStore failed: %v", err)
+	}
+
+	done := NewTaskRecord(&Task{ID: "task-1", Type: "researcher", Goal: "搜索AI信息"}, "Researcher")
+	done.Transition(TaskStatusRunning, "started")
+	done.Output = "结果"
+	done.Transition(TaskStatusCompleted, "finished")
+	if err := store.Save(ctx, done); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	running := NewTaskRecord(&Task{ID: "task-2", Type: "analyst", CreatedAt: time.Now().Add(time.Second)}, "Analyst")
+	running.Transition(TaskStatusRunning, "started")
+	store.Save(ctx, running)
+
+	got, err := store.Get(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != TaskStatusCompleted || got.Output != "结果" || len(got.Transitions) != 3 {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if got.StartedAt == nil || got.CompletedAt == nil || got.Duration() < 0 {
+		t.Error("completed task should have start and completion times")
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
+
+	// 重新打开后，未结束的任务标记为失败
+	reopened, err := NewFileTaskStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	interrupted, err := reopened.Get(ctx, "task-2")
+	if err != nil {
+		t.Fatalf("Get after reopen failed: %v", err)
+	}
+	if interrupted.Status != TaskStatusFailed || interrupted.Error == "" || interrupted.CompletedAt == nil {
+		t.Errorf("running task should be marked failed after restart, got %+v", interrupted)
+	}
+
+	records, _ := reopened.List(ctx, "", 0)
+	if len(records) != 2 || records[0].TaskID != "task-2" {
+		t.Errorf("List should return newest first, got %d records", len(records))
+	}
+	if completed, _ := reopened.List(ctx, TaskStatusCompleted, 0); len(completed) != 1 {
+		t.Errorf("List by status = %d, want 1", len(completed))
+	}
+}
@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 配额限制的资源
+const (
+	ResourceAgents     = "agents"     // 注册的Agent
+	ResourceTasks      = "tasks"      // 调度器中等待和执行中的任务
+	ResourceExecutions = "executions" // 运行中的工作流执行
+	ResourceDocuments  = "documents"  // 知识库中的文档
+)
+
+// ErrQuotaExceeded 租户配额已用尽
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// QuotaError 租户的某项资源达到配额上限，errors.Is(err, ErrQuotaExceeded) 为true
+type QuotaError struct {
+	Tenant   string
+	Resource string
+	Limit    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: tenant %s is limited to %d %s", ErrQuotaExceeded, e.Tenant, e.Limit, e.Resource)
+}
+
+// Unwrap 返回ErrQuotaExceeded
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Quota 单个租户的配额，0表示不限制
+type Quota struct {
+	MaxAgents     int
+	MaxTasks      int
+	MaxExecutions int
+	MaxDocuments  int
+}
+
+// Limit 资源的配额上限，0表示不限制
+func (q Quota) Limit(resource string) int {
+	switch resource {
+	case ResourceAgents:
+		return q.MaxAgents
+	case ResourceTasks:
+		return q.MaxTasks
+	case ResourceExecutions:
+		return q.MaxExecutions
+	case ResourceDocuments:
+		return q.MaxDocuments
+	}
+	return 0
+}
+
+// Quotas 各租户的配额
+type Quotas struct {
+	def     Quota
+	tenants map[string]Quota
+}
+
+// NewQuotasFromConfig 根据 tenancy.quotas 配置创建租户配额，未启用多租户时返回nil（不限制）
+// 租户未设置的项沿用default，负数表示不限制
+func NewQuotasFromConfig(cfg config.TenancyConfig) *Quotas {
+	if !cfg.Enabled {
+		return nil
+	}
+	q := &Quotas{
+		def:     resolveQuota(cfg.Quotas.Default, Quota{}),
+		tenants: make(map[string]Quota, len(cfg.Quotas.Tenants)),
+	}
+	for id, quota := range cfg.Quotas.Tenants {
+		q.tenants[id] = resolveQuota(quota, q.def)
+	}
+	return q
+}
+
+// resolveQuota 配置的配额：0沿用def中的值，负数为不限制
+func resolveQuota(cfg config.TenantQuotaConfig, def Quota) Quota {
+	pick := func(value, def int) int {
+		switch {
+		case value < 0:
+			return 0
+		case value == 0:
+			return def
+		}
+		return value
+	}
+	return Quota{
+		MaxAgents:     pick(cfg.MaxAgents, def.MaxAgents),
+		MaxTasks:      pick(cfg.MaxTasks, def.MaxTasks),
+		MaxExecutions: pick(cfg.MaxExecutions, def.MaxExecutions),
+		MaxDocuments:  pick(cfg.MaxDocuments, def.MaxDocuments),
+	}
+}
+
+// For 租户的配额，q为nil时不限制
+func (q *Quotas) For(tenant string) Quota {
+	if q == nil {
+		return Quota{}
+	}
+	if quota, ok := q.tenants[tenant]; ok {
+		return quota
+	}
+	return q.def
+}
+
+// Check 租户已使用used个资源时能否再使用一个，超出配额时返回*QuotaError
+// q为nil或tenant为空（未启用多租户、共享资源）时不限制
+func (q *Quotas) Check(tenant, resource string, used int) error {
+	if tenant == "" {
+		return nil
+	}
+	limit := q.For(tenant).Limit(resource)
+	if limit > 0 && used >= limit {
+		return &QuotaError{Tenant: tenant, Resource: resource, Limit: limit}
+	}
+	return nil
+}
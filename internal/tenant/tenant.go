@@ -0,0 +1,156 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header 客户端指定租户的请求头，响应中回显解析后的租户
+	Header = "X-Tenant-ID"
+
+	// separator 租户与标识之间的分隔符，如 "acme::session-1"
+	separator = "::"
+)
+
+// idPattern 合法的租户ID：字母、数字、下划线和连字符，最长64个字符
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Valid 租户ID是否合法
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// tenantKey 请求context中租户的键
+type tenantKey struct{}
+
+// WithTenant 把租户放入context，id为空时原样返回
+func WithTenant(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext 获取请求的租户，未启用多租户时为空
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// Scope 把会话ID、用户ID等标识限定在请求的租户内，未启用多租户时原样返回
+// 各子系统以限定后的标识存取数据，不同租户的同名标识互不可见
+func Scope(ctx context.Context, id string) string {
+	return ScopeTo(FromContext(ctx), id)
+}
+
+// ScopeTo 把标识限定在指定租户内，tenant为空时原样返回
+func ScopeTo(tenant, id string) string {
+	if tenant == "" {
+		return id
+	}
+	return tenant + separator + id
+}
+
+// Unscope 去掉标识的租户前缀，返回客户端可见的标识
+// 标识不属于请求的租户时返回false
+func Unscope(ctx context.Context, scoped string) (string, bool) {
+	tenant := FromContext(ctx)
+	if tenant == "" {
+		return scoped, true
+	}
+	return strings.CutPrefix(scoped, tenant+separator)
+}
+
+// Prefix 租户内标识的公共前缀，用于按前缀筛选，未启用多租户时为空
+func Prefix(ctx context.Context) string {
+	if tenant := FromContext(ctx); tenant != "" {
+		return tenant + separator
+	}
+	return ""
+}
+
+// Of 限定标识所属的租户，未限定时为空
+func Of(scoped string) string {
+	if tenant, _, ok := strings.Cut(scoped, separator); ok {
+		return tenant
+	}
+	return ""
+}
+
+// Resolver 从凭证或请求头解析租户
+type Resolver struct {
+	allowHeader   bool
+	defaultTenant string
+}
+
+// NewResolver 创建租户解析器
+func NewResolver(allowHeader bool, defaultTenant string) *Resolver {
+	return &Resolver{allowHeader: allowHeader, defaultTenant: defaultTenant}
+}
+
+// NewResolverFromConfig 根据配置创建租户解析器，未启用时返回nil
+func NewResolverFromConfig(cfg config.TenancyConfig) *Resolver {
+	if !cfg.Enabled {
+		return nil
+	}
+	return NewResolver(cfg.AllowHeader, cfg.DefaultTenant)
+}
+
+// Resolve 解析请求的租户，header为X-Tenant-ID的值
+//   - 凭证绑定了租户时使用该租户，请求头指定了其他租户返回403
+//   - 凭证未绑定租户（或未启用认证）时，allow_header开启则使用请求头
+//   - 都没有时使用default_tenant，未配置则返回400
+func (r *Resolver) Resolve(principal *auth.Principal, header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if principal != nil && principal.Tenant != "" {
+		if header != "" && header != principal.Tenant {
+			return "", apierror.New(apierror.CodeForbidden, "credential is not allowed to access this tenant").
+				WithDetails(gin.H{"tenant": header})
+		}
+		return principal.Tenant, nil
+	}
+
+	if header != "" {
+		if !r.allowHeader {
+			return "", apierror.New(apierror.CodeForbidden, Header+" is not allowed, the tenant is bound to the credential")
+		}
+		if !Valid(header) {
+			return "", apierror.New(apierror.CodeValidation, "invalid tenant id").WithDetails(gin.H{"tenant": header})
+		}
+		return header, nil
+	}
+
+	if r.defaultTenant == "" {
+		return "", apierror.New(apierror.CodeValidation, "tenant is required").WithDetails(gin.H{"header": Header})
+	}
+	return r.defaultTenant, nil
+}
+
+// Middleware 多租户中间件，需挂在认证中间件之后；解析失败时中止请求
+// 解析出的租户放入请求context，后续处理函数通过FromContext/Scope使用
+func (r *Resolver) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		id, err := r.Resolve(auth.PrincipalFromContext(c), c.GetHeader(Header))
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), id))
+		c.Next()
+	}
+}
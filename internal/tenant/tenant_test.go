@@ -0,0 +1,152 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-agent-assistant/internal/apierror"
+	"ai-agent-assistant/internal/auth"
+	"ai-agent-assistant/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestResolve 测试凭证、请求头和默认租户的优先级
+func TestResolve(t *testing.T) {
+	bound := &auth.Principal{ID: "ci-bot", Method: "api_key", Tenant: "acme"}
+	unbound := &auth.Principal{ID: "alice", Method: "jwt"}
+
+	cases := []struct {
+		name      string
+		resolver  *Resolver
+		principal *auth.Principal
+		header    string
+		want      string
+		code      apierror.Code
+	}{
+		{"credential tenant", NewResolver(false, ""), bound, "", "acme", ""},
+		{"matching header", NewResolver(false, ""), bound, "acme", "acme", ""},
+		{"mismatched header", NewResolver(true, ""), bound, "globex", "", apierror.CodeForbidden},
+		{"header allowed", NewResolver(true, ""), unbound, "globex", "globex", ""},
+		{"header not allowed", NewResolver(false, "acme"), unbound, "globex", "", apierror.CodeForbidden},
+		{"invalid header", NewResolver(true, ""), nil, "acme::x", "", apierror.CodeValidation},
+		{"default tenant", NewResolver(true, "acme"), nil, "", "acme", ""},
+		{"tenant required", NewResolver(true, ""), nil, "", "", apierror.CodeValidation},
+	}
+	for _, tc := range cases {
+		got, err := tc.resolver.Resolve(tc.principal, tc.header)
+		if tc.code != "" {
+			if err == nil || apierror.From(err).Code != tc.code {
+				t.Errorf("%s: expected %s error, got %v", tc.name, tc.code, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+// TestScope 测试标识的限定与还原
+func TestScope(t *testing.T) {
+	ctx := context.Background()
+	if Scope(ctx, "s1") != "s1" || Prefix(ctx) != "" {
+		t.Error("identifiers should be unchanged without a tenant")
+	}
+
+	ctx = WithTenant(ctx, "acme")
+	scoped := Scope(ctx, "s1")
+	if scoped != "acme::s1" || Of(scoped) != "acme" || Prefix(ctx) != "acme::" {
+		t.Errorf("unexpected scoped id %q", scoped)
+	}
+	if id, ok := Unscope(ctx, scoped); !ok || id != "s1" {
+		t.Errorf("unscope: got %q, %v", id, ok)
+	}
+	if _, ok := Unscope(WithTenant(context.Background(), "globex"), scoped); ok {
+		t.Error("identifier of another tenant should not be unscoped")
+	}
+}
+
+// TestMiddleware 测试中间件写入context和响应头，nil解析器直接放行
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(r *Resolver) *gin.Engine {
+		router := gin.New()
+		router.Use(r.Middleware())
+		router.GET("/whoami", func(c *gin.Context) {
+			c.String(http.StatusOK, FromContext(c.Request.Context()))
+		})
+		return router
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set(Header, "acme")
+	w := httptest.NewRecorder()
+	newRouter(NewResolver(true, "")).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "acme" || w.Header().Get(Header) != "acme" {
+		t.Errorf("unexpected response: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	newRouter(NewResolver(false, "")).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("missing tenant should be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	newRouter(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("nil resolver should pass through, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// TestQuotas 测试默认配额、按租户覆盖和不限制的配额
+func TestQuotas(t *testing.T) {
+	quotas := NewQuotasFromConfig(config.TenancyConfig{
+		Enabled: true,
+		Quotas: config.TenantQuotasConfig{
+			Default: config.TenantQuotaConfig{MaxAgents: 2, MaxTasks: 10},
+			Tenants: map[string]config.TenantQuotaConfig{
+				"acme": {MaxTasks: 100, MaxDocuments: 5},
+				"beta": {MaxAgents: -1},
+			},
+		},
+	})
+
+	cases := []struct {
+		tenant string
+		want   Quota
+	}{
+		{"acme", Quota{MaxAgents: 2, MaxTasks: 100, MaxDocuments: 5}},
+		{"beta", Quota{MaxTasks: 10}},
+		{"other", Quota{MaxAgents: 2, MaxTasks: 10}},
+	}
+	for _, tc := range cases {
+		if got := quotas.For(tc.tenant); got != tc.want {
+			t.Errorf("For(%q) = %+v, want %+v", tc.tenant, got, tc.want)
+		}
+	}
+
+	if err := quotas.Check("acme", ResourceDocuments, 4); err != nil {
+		t.Errorf("Expected quota to allow the 5th document, got %v", err)
+	}
+	err := quotas.Check("acme", ResourceDocuments, 5)
+	var quotaErr *QuotaError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.Limit != 5 || quotaErr.Resource != ResourceDocuments {
+		t.Errorf("Expected documents quota error, got %v", err)
+	}
+	if err := quotas.Check("", ResourceTasks, 1000); err != nil {
+		t.Errorf("Expected no limit without a tenant, got %v", err)
+	}
+
+	var disabled *Quotas
+	if err := disabled.Check("acme", ResourceTasks, 1000); err != nil {
+		t.Errorf("Expected nil quotas to allow everything, got %v", err)
+	}
+	if NewQuotasFromConfig(config.TenancyConfig{}) != nil {
+		t.Error("Expected nil quotas when tenancy is disabled")
+	}
+}
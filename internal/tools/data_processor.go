@@ -2,7 +2,6 @@ package tools
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -10,6 +9,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"ai-agent-assistant/internal/csvparse"
 )
 
 // DataProcessingResult 数据处理结果
@@ -87,9 +88,12 @@ func (t *DataProcessorTool) Execute(ctx context.Context, operation string, param
 
 // parseCSV 解析CSV数据
 // 参数：
-//   - content: CSV内容字符串（必填）
+//   - content: CSV内容字符串（必填），可以是GBK、UTF-16等编码的原始字节
 //   - has_header: 是否有表头（可选，默认true）
-//   - delimiter: 分隔符（可选，默认","）
+//   - delimiter: 分隔符（可选，默认","），可以是多个字符，如"||"
+//   - quote: 引号字符（可选，默认"\""），"none"表示不识别引号
+//   - escape: 引号内的转义字符（可选，如"\\"），默认用两个引号表示一个引号
+//   - encoding: 编码（可选，默认auto）：auto、utf-8、gbk、gb18030、utf-16le、utf-16be
 func (t *DataProcessorTool) parseCSV(params map[string]interface{}) (*DataProcessingResult, error) {
 	content, ok := params["content"].(string)
 	if !ok {
@@ -104,18 +108,16 @@ func (t *DataProcessorTool) parseCSV(params map[string]interface{}) (*DataProces
 		hasHeader = hh
 	}
 
-	delimiter := ","
-	if d, ok := params["delimiter"].(string); ok {
-		delimiter = d
-	}
-
-	// 解析CSV
-	reader := csv.NewReader(strings.NewReader(content))
-	if delimiter != "," {
-		reader.Comma = rune(delimiter[0])
+	// 解码并解析CSV
+	opts := csvparse.OptionsFromParams(params)
+	text, encoding, err := csvparse.Decode([]byte(content), opts.Encoding)
+	if err != nil {
+		return &DataProcessingResult{
+			Success: false,
+			Error:   fmt.Sprintf("CSV解码失败: %v", err),
+		}, nil
 	}
-
-	records, err := reader.ReadAll()
+	records, err := csvparse.ParseString(text, opts)
 	if err != nil {
 		return &DataProcessingResult{
 			Success: false,
@@ -168,6 +170,7 @@ func (t *DataProcessorTool) parseCSV(params map[string]interface{}) (*DataProces
 			"row_count":    len(data),
 			"column_count": len(headers),
 			"has_header":   hasHeader,
+			"encoding":     encoding,
 		},
 	}, nil
 }
@@ -11,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"ai-agent-assistant/internal/csvparse"
 )
 
 // FileOperationResult 文件操作结果
@@ -54,6 +56,16 @@ func (t *FileOpsTool) Version() string {
 	return t.version
 }
 
+// HealthCheck 检查临时目录可写（压缩、格式转换依赖临时文件）
+func (t *FileOpsTool) HealthCheck(ctx context.Context) error {
+	f, err := os.CreateTemp("", "file_ops_health_*")
+	if err != nil {
+		return fmt.Errorf("临时目录不可写: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
 // Execute 执行文件操作
 // 支持的操作类型：read, write, batch_read, convert, compress, decompress
 func (t *FileOpsTool) Execute(ctx context.Context, operation string, params map[string]interface{}) (interface{}, error) {
@@ -261,6 +273,7 @@ func (t *FileOpsTool) batchReadFiles(params map[string]interface{}) (*FileOperat
 //   - path: 源文件路径（必填）
 //   - target_format: 目标格式（必填）
 //   - output_path: 输出路径（可选）
+//   - delimiter、quote、escape、encoding: 源CSV的解析选项（可选），见DataProcessorTool.parseCSV
 // 支持的转换：json <-> csv, json <-> yaml
 func (t *FileOpsTool) convertFile(params map[string]interface{}) (*FileOperationResult, error) {
 	path, ok := params["path"].(string)
@@ -300,7 +313,7 @@ func (t *FileOpsTool) convertFile(params map[string]interface{}) (*FileOperation
 	case sourceFormat == "json" && targetFormat == "csv":
 		convertedContent, err = t.jsonToCSV(readResult.Data.(map[string]interface{})["content"].(string))
 	case sourceFormat == "csv" && targetFormat == "json":
-		convertedContent, err = t.csvToJSON(readResult.Data.(map[string]interface{})["content"].(string))
+		convertedContent, err = t.csvToJSON(readResult.Data.(map[string]interface{})["content"].(string), csvparse.OptionsFromParams(params))
 	default:
 		return &FileOperationResult{
 			Success: false,
@@ -379,10 +392,9 @@ func (t *FileOpsTool) jsonToCSV(jsonContent string) (string, error) {
 	return buf.String(), nil
 }
 
-// csvToJSON CSV转JSON
-func (t *FileOpsTool) csvToJSON(csvContent string) (string, error) {
-	reader := csv.NewReader(strings.NewReader(csvContent))
-	records, err := reader.ReadAll()
+// csvToJSON CSV转JSON，按opts解码和解析（默认自动识别编码）
+func (t *FileOpsTool) csvToJSON(csvContent string, opts csvparse.Options) (string, error) {
+	records, err := csvparse.Parse([]byte(csvContent), opts)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maxGitFiles ls_files最多返回的文件数
+const maxGitFiles = 2000
+
+// GitTool git只读工具
+// 读取配置目录内仓库的文件列表、文件内容、提交历史和差异，并校验补丁能否应用；不修改仓库
+type GitTool struct {
+	name        string
+	description string
+	version     string
+	roots       repoRoots
+}
+
+// NewGitTool 创建git工具，只能访问roots目录内的仓库
+func NewGitTool(roots repoRoots) *GitTool {
+	return &GitTool{
+		name:        "git",
+		description: "Git仓库工具 - 读取文件、提交历史、差异，校验补丁",
+		version:     "1.0.0",
+		roots:       roots,
+	}
+}
+
+// Name 返回工具名称
+func (t *GitTool) Name() string {
+	return t.name
+}
+
+// Description 返回工具描述
+func (t *GitTool) Description() string {
+	return t.description
+}
+
+// Version 返回工具版本
+func (t *GitTool) Version() string {
+	return t.version
+}
+
+// HealthCheck 检查git命令可用
+func (t *GitTool) HealthCheck(ctx context.Context) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git命令不可用: %w", err)
+	}
+	return nil
+}
+
+// Execute 执行git操作
+// 支持的操作类型：status, log, ls_files, show, diff, grep, apply_check
+// 所有操作都需要参数repo（仓库路径）
+func (t *GitTool) Execute(ctx context.Context, operation string, params map[string]interface{}) (interface{}, error) {
+	repo, err := t.roots.resolve(stringParam(params, "repo"))
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	switch operation {
+	case "status":
+		return t.gitResult(runGit(ctx, repo, nil, "status", "--short", "--branch"))
+	case "log":
+		return t.log(ctx, repo, params)
+	case "ls_files":
+		return t.lsFiles(ctx, repo, params)
+	case "show":
+		return t.show(ctx, repo, params)
+	case "diff":
+		return t.diff(ctx, repo, params)
+	case "grep":
+		return t.grep(ctx, repo, params)
+	case "apply_check":
+		return t.applyCheck(ctx, repo, params)
+	default:
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("不支持的操作类型: %s", operation),
+		}, nil
+	}
+}
+
+// gitResult 把git命令的输出包装为工具结果
+func (t *GitTool) gitResult(output string, err error) (*ToolResult, error) {
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	return &ToolResult{Success: true, Data: output}, nil
+}
+
+// log 最近的提交
+// 参数：
+//   - limit: 提交数（可选，默认10）
+//   - path: 只看该路径的提交（可选）
+func (t *GitTool) log(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	args := []string{"log", fmt.Sprintf("-n%d", intParam(params, "limit", 10)), "--date=short", "--pretty=format:%h %ad %an %s"}
+	if path := stringParam(params, "path"); path != "" {
+		args = append(args, "--", path)
+	}
+	return t.gitResult(runGit(ctx, repo, nil, args...))
+}
+
+// lsFiles 仓库中已跟踪的文件
+// 参数：
+//   - path: 只列出该路径下的文件（可选）
+func (t *GitTool) lsFiles(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	args := []string{"ls-files"}
+	if path := stringParam(params, "path"); path != "" {
+		args = append(args, "--", path)
+	}
+	output, err := runGit(ctx, repo, nil, args...)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	files := strings.Fields(output)
+	truncated := len(files) > maxGitFiles
+	if truncated {
+		files = files[:maxGitFiles]
+	}
+	return &ToolResult{
+		Success:  true,
+		Data:     files,
+		Metadata: map[string]interface{}{"count": len(files), "truncated": truncated},
+	}, nil
+}
+
+// show 读取某个版本的文件内容
+// 参数：
+//   - path: 文件路径（必填，相对仓库根目录）
+//   - rev: 版本（可选，默认HEAD）
+func (t *GitTool) show(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	path := stringParam(params, "path")
+	if path == "" {
+		return &ToolResult{Success: false, Error: "缺少必填参数: path"}, nil
+	}
+	rev := stringParam(params, "rev")
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if strings.HasPrefix(rev, "-") {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("无效的版本: %s", rev)}, nil
+	}
+	return t.gitResult(runGit(ctx, repo, nil, "show", rev+":"+strings.TrimPrefix(path, "/")))
+}
+
+// diff 工作区或两个版本之间的差异
+// 参数：
+//   - rev: 与该版本比较（可选，默认比较工作区与暂存区）
+//   - path: 只看该路径的差异（可选）
+func (t *GitTool) diff(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	args := []string{"diff"}
+	if rev := stringParam(params, "rev"); rev != "" {
+		if strings.HasPrefix(rev, "-") {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("无效的版本: %s", rev)}, nil
+		}
+		args = append(args, rev)
+	}
+	if path := stringParam(params, "path"); path != "" {
+		args = append(args, "--", path)
+	}
+	return t.gitResult(runGit(ctx, repo, nil, args...))
+}
+
+// grep 在已跟踪的文件中搜索
+// 参数：
+//   - pattern: 搜索的正则表达式（必填）
+//   - path: 只搜索该路径（可选）
+func (t *GitTool) grep(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	pattern := stringParam(params, "pattern")
+	if pattern == "" {
+		return &ToolResult{Success: false, Error: "缺少必填参数: pattern"}, nil
+	}
+	args := []string{"grep", "-n", "-I", "-E", "-e", pattern}
+	if path := stringParam(params, "path"); path != "" {
+		args = append(args, "--", path)
+	}
+	output, err := runGit(ctx, repo, nil, args...)
+	// 没有匹配时git grep的退出码为1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return &ToolResult{Success: true, Data: ""}, nil
+	}
+	output, _ = truncateOutput(output, defaultMaxOutputBytes)
+	return t.gitResult(output, err)
+}
+
+// applyCheck 校验补丁能否应用到工作区，不修改仓库
+// 参数：
+//   - patch: unified diff格式的补丁（必填）
+func (t *GitTool) applyCheck(ctx context.Context, repo string, params map[string]interface{}) (*ToolResult, error) {
+	patch := stringParam(params, "patch")
+	if strings.TrimSpace(patch) == "" {
+		return &ToolResult{Success: false, Error: "缺少必填参数: patch"}, nil
+	}
+	output, err := runGit(ctx, repo, []byte(patch), "apply", "--check", "--stat", "-")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	return &ToolResult{Success: true, Message: "补丁可以应用", Data: output}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sync"
+
+	"ai-agent-assistant/internal/config"
 )
 
 // Tool 工具接口
@@ -44,6 +46,11 @@ type ToolExecutor interface {
 	Execute(ctx context.Context, operation string, params map[string]interface{}) (interface{}, error)
 }
 
+// HealthChecker 可自检的工具（依赖外部资源的工具实现），用于就绪检查
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
 // Registry 工具注册表
 // 管理所有可用的工具
 type Registry struct {
@@ -232,6 +239,14 @@ func NewToolManager(config *ToolManagerConfig) *ToolManager {
 	return manager
 }
 
+// NewToolManagerFromConfig 根据配置创建工具管理器，默认注册内置工具且不限制可执行的工具
+func NewToolManagerFromConfig(cfg config.ToolManagerConfig) *ToolManager {
+	return NewToolManager(&ToolManagerConfig{
+		AutoRegister: !cfg.DisableBuiltin,
+		EnabledTools: cfg.Enabled,
+	})
+}
+
 // registerBuiltinTools 注册内置工具
 func (m *ToolManager) registerBuiltinTools() {
 	// 注册文件操作工具
@@ -294,6 +309,28 @@ func (m *ToolManager) GetAvailableTools() []map[string]interface{} {
 	return allTools
 }
 
+// CheckHealth 检查已启用工具的可用性，返回每个不可用工具的错误
+// 启用列表中未注册的工具、自检失败的工具均视为不可用
+func (m *ToolManager) CheckHealth(ctx context.Context) map[string]error {
+	failures := make(map[string]error)
+	for _, name := range m.config.EnabledTools {
+		if !m.registry.HasTool(name) {
+			failures[name] = fmt.Errorf("工具未注册: %s", name)
+		}
+	}
+
+	for _, tool := range m.registry.List() {
+		checker, ok := tool.(HealthChecker)
+		if !ok || !m.isToolEnabled(tool.Name()) {
+			continue
+		}
+		if err := checker.HealthCheck(ctx); err != nil {
+			failures[tool.Name()] = err
+		}
+	}
+	return failures
+}
+
 // EnableTool 启用工具
 func (m *ToolManager) EnableTool(toolName string) {
 	m.config.EnabledTools = append(m.config.EnabledTools, toolName)
@@ -340,6 +377,12 @@ func (m *ToolManager) GetToolCapabilities(toolName string) (map[string]interface
 			"batch_http", "batch_process", "parallel_execute",
 			"concurrent_limit",
 		}
+	case "git":
+		capabilities["operations"] = []string{
+			"status", "log", "ls_files", "show", "diff", "grep", "apply_check",
+		}
+	case "sandbox_exec":
+		capabilities["operations"] = []string{"run"}
 	}
 
 	return capabilities, nil
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// 仓库工具的默认限制
+const (
+	defaultSandboxTimeout = 2 * time.Minute
+	defaultMaxOutputBytes = 64 * 1024
+)
+
+// defaultAllowedCommands 沙箱默认允许执行的命令
+var defaultAllowedCommands = []string{"go", "make", "npm", "pytest", "cargo"}
+
+// NewRepoToolsFromConfig 根据配置创建git工具和沙箱执行工具，未配置仓库目录时返回nil
+func NewRepoToolsFromConfig(cfg config.RepoToolsConfig) (*GitTool, *SandboxTool, error) {
+	if len(cfg.Roots) == 0 {
+		return nil, nil, nil
+	}
+	roots, err := newRepoRoots(cfg.Roots)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timeout := defaultSandboxTimeout
+	if cfg.Timeout != "" {
+		if timeout, err = time.ParseDuration(cfg.Timeout); err != nil || timeout <= 0 {
+			return nil, nil, fmt.Errorf("invalid sandbox timeout: %q", cfg.Timeout)
+		}
+	}
+	commands := cfg.AllowedCommands
+	if len(commands) == 0 {
+		commands = defaultAllowedCommands
+	}
+	return NewGitTool(roots), NewSandboxTool(roots, commands, timeout, cfg.MaxOutputBytes), nil
+}
+
+// repoRoots 允许访问的仓库所在目录
+type repoRoots []string
+
+// newRepoRoots 把配置的目录转为绝对路径
+func newRepoRoots(dirs []string) (repoRoots, error) {
+	roots := make(repoRoots, 0, len(dirs))
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo root %q: %w", dir, err)
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		roots = append(roots, abs)
+	}
+	return roots, nil
+}
+
+// resolve 解析仓库路径，只允许访问配置目录内的仓库（解析符号链接后判断）
+func (r repoRoots) resolve(repo string) (string, error) {
+	if repo == "" {
+		return "", fmt.Errorf("缺少必填参数: repo")
+	}
+	abs, err := filepath.Abs(repo)
+	if err != nil {
+		return "", fmt.Errorf("无效的仓库路径: %s", repo)
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("仓库不存在: %s", repo)
+	}
+	for _, root := range r {
+		if resolved == root || strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("仓库不在允许访问的目录内: %s", repo)
+}
+
+// runGit 在仓库中执行git命令，返回标准输出
+func runGit(ctx context.Context, dir string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// truncateOutput 截断过长的输出，保留开头和结尾
+func truncateOutput(output string, max int) (string, bool) {
+	if max <= 0 || len(output) <= max {
+		return output, false
+	}
+	half := max / 2
+	return output[:half] + "\n...（输出过长，已截断）...\n" + output[len(output)-half:], true
+}
+
+// stringParam 读取字符串参数
+func stringParam(params map[string]interface{}, key string) string {
+	s, _ := params[key].(string)
+	return s
+}
+
+// intParam 读取整数参数，JSON数字为float64
+func intParam(params map[string]interface{}, key string, def int) int {
+	switch v := params[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
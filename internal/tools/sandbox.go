@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// passthroughEnv 沙箱命令继承的环境变量，其余环境变量（含密钥）不传入
+var passthroughEnv = []string{"PATH", "GOPATH", "GOMODCACHE", "GOCACHE", "GOPROXY", "GOFLAGS", "LANG"}
+
+// SandboxTool 沙箱执行工具
+// 把仓库的HEAD克隆到临时目录，应用补丁后执行白名单内的命令（如运行测试），结束后删除临时目录；
+// 命令不经过shell，超时后终止，不影响原仓库
+type SandboxTool struct {
+	name        string
+	description string
+	version     string
+	roots       repoRoots
+	commands    map[string]bool
+	timeout     time.Duration
+	maxOutput   int
+}
+
+// NewSandboxTool 创建沙箱执行工具
+// commands为允许执行的命令，timeout为单条命令的最长执行时间，maxOutput为保留的输出长度
+func NewSandboxTool(roots repoRoots, commands []string, timeout time.Duration, maxOutput int) *SandboxTool {
+	allowed := make(map[string]bool, len(commands))
+	for _, c := range commands {
+		allowed[c] = true
+	}
+	if timeout <= 0 {
+		timeout = defaultSandboxTimeout
+	}
+	if maxOutput <= 0 {
+		maxOutput = defaultMaxOutputBytes
+	}
+	return &SandboxTool{
+		name:        "sandbox_exec",
+		description: "沙箱执行工具 - 在仓库副本中应用补丁并运行测试",
+		version:     "1.0.0",
+		roots:       roots,
+		commands:    allowed,
+		timeout:     timeout,
+		maxOutput:   maxOutput,
+	}
+}
+
+// Name 返回工具名称
+func (t *SandboxTool) Name() string {
+	return t.name
+}
+
+// Description 返回工具描述
+func (t *SandboxTool) Description() string {
+	return t.description
+}
+
+// Version 返回工具版本
+func (t *SandboxTool) Version() string {
+	return t.version
+}
+
+// HealthCheck 检查git命令可用且临时目录可写
+func (t *SandboxTool) HealthCheck(ctx context.Context) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git命令不可用: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "sandbox_health_*")
+	if err != nil {
+		return fmt.Errorf("临时目录不可写: %w", err)
+	}
+	return os.RemoveAll(dir)
+}
+
+// Execute 执行沙箱操作
+// 支持的操作类型：run
+func (t *SandboxTool) Execute(ctx context.Context, operation string, params map[string]interface{}) (interface{}, error) {
+	switch operation {
+	case "run":
+		return t.run(ctx, params)
+	default:
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("不支持的操作类型: %s", operation),
+		}, nil
+	}
+}
+
+// run 在仓库副本中执行命令
+// 参数：
+//   - repo: 仓库路径（必填）
+//   - command: 命令及参数，如 ["go", "test", "./..."] 或 "go test ./..."（必填，不经过shell）
+//   - patch: 执行前应用的unified diff补丁（可选）
+//
+// 命令以非0退出码结束时Success为false，exit_code和output记录在Data中
+func (t *SandboxTool) run(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	repo, err := t.roots.resolve(stringParam(params, "repo"))
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	argv := commandParam(params["command"])
+	if len(argv) == 0 {
+		return &ToolResult{Success: false, Error: "缺少必填参数: command"}, nil
+	}
+	if !t.commands[argv[0]] {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("命令不在允许范围内: %s", argv[0])}, nil
+	}
+
+	workdir, err := os.MkdirTemp("", "sandbox_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer os.RemoveAll(workdir)
+
+	checkout := filepath.Join(workdir, "repo")
+	if _, err := runGit(ctx, workdir, nil, "clone", "--quiet", "--local", "--no-hardlinks", repo, checkout); err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	patch := stringParam(params, "patch")
+	if strings.TrimSpace(patch) != "" {
+		if _, err := runGit(ctx, checkout, []byte(patch), "apply", "-"); err != nil {
+			return &ToolResult{
+				Success: false,
+				Error:   "补丁无法应用: " + err.Error(),
+				Data:    map[string]interface{}{"patch_applied": false},
+			}, nil
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, argv[0], argv[1:]...)
+	cmd.Dir = checkout
+	cmd.Env = sandboxEnv(workdir)
+	cmd.WaitDelay = 5 * time.Second
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	runErr := cmd.Run()
+	duration := time.Since(start)
+
+	text, truncated := truncateOutput(output.String(), t.maxOutput)
+	data := map[string]interface{}{
+		"command":       argv,
+		"exit_code":     0,
+		"output":        text,
+		"truncated":     truncated,
+		"timed_out":     errors.Is(runCtx.Err(), context.DeadlineExceeded),
+		"duration_ms":   duration.Milliseconds(),
+		"patch_applied": strings.TrimSpace(patch) != "",
+	}
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			data["exit_code"] = exitErr.ExitCode()
+		} else {
+			data["exit_code"] = -1
+		}
+		return &ToolResult{Success: false, Error: runErr.Error(), Data: data}, nil
+	}
+	return &ToolResult{Success: true, Message: "命令执行成功", Data: data}, nil
+}
+
+// commandParam 读取命令参数，字符串按空白切分
+func commandParam(v interface{}) []string {
+	switch c := v.(type) {
+	case string:
+		return strings.Fields(c)
+	case []string:
+		return c
+	case []interface{}:
+		argv := make([]string, 0, len(c))
+		for _, arg := range c {
+			if s, ok := arg.(string); ok {
+				argv = append(argv, s)
+			}
+		}
+		return argv
+	}
+	return nil
+}
+
+// sandboxEnv 沙箱命令的环境变量：只继承构建相关的变量，HOME和临时目录指向沙箱
+func sandboxEnv(workdir string) []string {
+	env := []string{"HOME=" + workdir, "TMPDIR=" + workdir}
+	for _, key := range passthroughEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
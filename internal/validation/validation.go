@@ -0,0 +1,230 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"ai-agent-assistant/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Problem 字段问题类型
+type Problem string
+
+const (
+	ProblemMissing    Problem = "missing"      // 必填字段缺失或为空
+	ProblemWrongType  Problem = "wrong_type"   // JSON类型与字段类型不符
+	ProblemOutOfRange Problem = "out_of_range" // 数值、长度或元素个数超出范围
+	ProblemInvalid    Problem = "invalid"      // 取值不在允许范围或格式不合法
+)
+
+// FieldError 单个字段的校验问题
+type FieldError struct {
+	Field    string  `json:"field"` // JSON字段路径，如 tasks[0].goal
+	Problem  Problem `json:"problem"`
+	Message  string  `json:"message"`
+	Expected string  `json:"expected,omitempty"` // 期望的类型、范围或取值
+}
+
+// Errors 校验失败的字段列表
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func init() {
+	useJSONNames()
+}
+
+// Bind 解析JSON请求体并按binding标签校验，失败时返回带逐字段详情的validation_error
+//
+// 字段规则写在结构体的binding标签中（go-playground/validator语法），如：
+//
+//	Goal     string `json:"goal" binding:"required"`
+//	Priority int    `json:"priority" binding:"gte=0,lte=3"`
+func Bind(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		return Translate(err)
+	}
+	return nil
+}
+
+// Translate 把绑定或校验错误转换为统一错误，details为 {"fields": [...]}
+// 请求体不是合法JSON时details中给出出错位置
+func Translate(err error) *apierror.Error {
+	if errors.Is(err, io.EOF) {
+		return apierror.New(apierror.CodeValidation, "Request body is empty")
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apierror.New(apierror.CodePayloadTooLarge, "request body too large").
+			WithDetails(gin.H{"max_bytes": maxBytesErr.Limit})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return apierror.New(apierror.CodeValidation, "Request body is not valid JSON").
+			WithDetails(gin.H{"offset": syntaxErr.Offset, "error": syntaxErr.Error()})
+	}
+
+	var fields Errors
+	var typeErr *json.UnmarshalTypeError
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &typeErr):
+		field := jsonPath(typeErr.Field)
+		if field == "" {
+			field = "(body)"
+		}
+		fields = Errors{{
+			Field:    field,
+			Problem:  ProblemWrongType,
+			Message:  fmt.Sprintf("%s must be %s, got %s", field, typeName(typeErr.Type), typeErr.Value),
+			Expected: typeName(typeErr.Type),
+		}}
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			fields = append(fields, fieldError(fe))
+		}
+	default:
+		return apierror.InvalidBody(err)
+	}
+
+	return &apierror.Error{
+		Code:    apierror.CodeValidation,
+		Message: "Request validation failed: " + fields.Error(),
+		Details: gin.H{"fields": fields},
+		Err:     fields,
+	}
+}
+
+// fieldError 校验标签失败转换为字段问题
+func fieldError(fe validator.FieldError) FieldError {
+	field := fieldPath(fe.Namespace())
+	param := fe.Param()
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return FieldError{Field: field, Problem: ProblemMissing, Message: field + " is required"}
+	case "min", "gte":
+		return rangeError(field, fe.Kind(), "at least", param)
+	case "max", "lte":
+		return rangeError(field, fe.Kind(), "at most", param)
+	case "gt":
+		return rangeError(field, fe.Kind(), "greater than", param)
+	case "lt":
+		return rangeError(field, fe.Kind(), "less than", param)
+	case "len":
+		return rangeError(field, fe.Kind(), "exactly", param)
+	case "oneof":
+		expected := strings.Join(strings.Fields(param), ", ")
+		return FieldError{Field: field, Problem: ProblemInvalid, Expected: expected,
+			Message: fmt.Sprintf("%s must be one of: %s", field, expected)}
+	default:
+		expected := fe.Tag()
+		if param != "" {
+			expected += "=" + param
+		}
+		return FieldError{Field: field, Problem: ProblemInvalid, Expected: expected,
+			Message: fmt.Sprintf("%s is invalid (%s)", field, expected)}
+	}
+}
+
+// rangeError 范围问题，字符串和集合类型按长度描述
+func rangeError(field string, kind reflect.Kind, bound, param string) FieldError {
+	expected := bound + " " + param
+	switch kind {
+	case reflect.String:
+		expected += " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		expected += " items"
+	}
+	return FieldError{Field: field, Problem: ProblemOutOfRange, Expected: expected,
+		Message: fmt.Sprintf("%s must be %s", field, expected)}
+}
+
+// fieldPath 去掉校验器命名空间中的结构体名，如 "req.tasks[0].goal" -> "tasks[0].goal"
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// jsonPath 把encoding/json的字段路径改为与校验器一致的写法，如 "tasks.0.goal" -> "tasks[0].goal"
+func jsonPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// typeName Go类型对应的JSON类型名称
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "unknown"
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
+
+// useJSONNames 让校验错误使用json标签中的字段名
+func useJSONNames() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+}
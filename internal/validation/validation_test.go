@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-agent-assistant/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type taskRequest struct {
+	Tasks []struct {
+		Goal     string `json:"goal" binding:"required"`
+		Priority int    `json:"priority" binding:"gte=0,lte=3"`
+	} `json:"tasks" binding:"required,min=1,dive"`
+	Mode string `json:"mode" binding:"omitempty,oneof=fast thorough"`
+}
+
+// bind 以给定请求体调用Bind
+func bind(t *testing.T, body string) *apierror.Error {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	var req taskRequest
+	if err := Bind(c, &req); err != nil {
+		return apierror.From(err)
+	}
+	return nil
+}
+
+// TestBind 测试逐字段的缺失、类型和范围问题
+func TestBind(t *testing.T) {
+	if err := bind(t, `{"tasks": [{"goal": "搜索", "priority": 2}]}`); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+
+	cases := []struct {
+		body    string
+		field   string
+		problem Problem
+	}{
+		{`{}`, "tasks", ProblemMissing},
+		{`{"tasks": []}`, "tasks", ProblemOutOfRange},
+		{`{"tasks": [{"goal": "a"}, {"priority": 1}]}`, "tasks[1].goal", ProblemMissing},
+		{`{"tasks": [{"goal": "a", "priority": 9}]}`, "tasks[0].priority", ProblemOutOfRange},
+		{`{"tasks": [{"goal": "a", "priority": "high"}]}`, "tasks[0].priority", ProblemWrongType},
+		{`{"tasks": [{"goal": "a"}], "mode": "slow"}`, "mode", ProblemInvalid},
+	}
+	for _, tc := range cases {
+		err := bind(t, tc.body)
+		if err == nil || err.Code != apierror.CodeValidation {
+			t.Errorf("%s: expected validation error, got %v", tc.body, err)
+			continue
+		}
+		fields, _ := err.Details.(gin.H)["fields"].(Errors)
+		if len(fields) != 1 || fields[0].Field != tc.field || fields[0].Problem != tc.problem {
+			t.Errorf("%s: got fields %+v, want %s/%s", tc.body, fields, tc.field, tc.problem)
+		}
+	}
+
+	for _, body := range []string{``, `{"tasks": [`} {
+		if err := bind(t, body); err == nil || err.Code != apierror.CodeValidation {
+			t.Errorf("%q: expected validation error, got %v", body, err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package vectordb
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
@@ -19,8 +21,43 @@ func NewCollectionManager(client *MilvusClient) *CollectionManager {
 	}
 }
 
+// EmbeddingSpec 集合绑定的向量化模型与维度
+type EmbeddingSpec struct {
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+}
+
+// String 编码为集合描述
+func (s EmbeddingSpec) String() string {
+	return fmt.Sprintf("embedding_model=%s;dimension=%d", s.Model, s.Dimension)
+}
+
+// parseEmbeddingSpec 从集合描述中解析向量化模型与维度
+func parseEmbeddingSpec(desc string) EmbeddingSpec {
+	var spec EmbeddingSpec
+	for _, part := range strings.Split(desc, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "embedding_model":
+			spec.Model = kv[1]
+		case "dimension":
+			spec.Dimension, _ = strconv.Atoi(kv[1])
+		}
+	}
+	return spec
+}
+
 // CreateSimpleCollection 创建简单的向量集合
 func (cm *CollectionManager) CreateSimpleCollection(ctx context.Context, collectionName string, dimension int) error {
+	return cm.CreateCollectionWithEmbedding(ctx, collectionName, EmbeddingSpec{Dimension: dimension})
+}
+
+// CreateCollectionWithEmbedding 创建向量集合，并把向量化模型和维度记录在集合描述中
+func (cm *CollectionManager) CreateCollectionWithEmbedding(ctx context.Context, collectionName string, spec EmbeddingSpec) error {
+	dimension := spec.Dimension
 	// 检查集合是否已存在
 	has, err := cm.client.HasCollection(ctx, collectionName)
 	if err != nil {
@@ -56,6 +93,7 @@ func (cm *CollectionManager) CreateSimpleCollection(ctx context.Context, collect
 
 	schema := &entity.Schema{
 		CollectionName: collectionName,
+		Description:    spec.String(),
 		Fields:         fields,
 	}
 
@@ -81,6 +119,37 @@ func (cm *CollectionManager) CreateSimpleCollection(ctx context.Context, collect
 	return nil
 }
 
+// GetEmbeddingSpec 获取集合绑定的向量化模型与维度
+// 集合不存在时返回nil；维度以向量字段的dim为准，模型来自集合描述（旧集合可能为空）
+func (cm *CollectionManager) GetEmbeddingSpec(ctx context.Context, collectionName string) (*EmbeddingSpec, error) {
+	has, err := cm.client.HasCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check collection: %w", err)
+	}
+	if !has {
+		return nil, nil
+	}
+
+	coll, err := cm.GetCollectionInfo(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe collection: %w", err)
+	}
+
+	spec := EmbeddingSpec{}
+	if coll.Schema != nil {
+		spec = parseEmbeddingSpec(coll.Schema.Description)
+		for _, field := range coll.Schema.Fields {
+			if field.DataType == entity.FieldTypeFloatVector {
+				if dim, err := strconv.Atoi(field.TypeParams["dim"]); err == nil {
+					spec.Dimension = dim
+				}
+			}
+		}
+	}
+
+	return &spec, nil
+}
+
 // DropCollection 删除集合
 func (cm *CollectionManager) DropCollection(ctx context.Context, collectionName string) error {
 	return cm.client.DropCollection(ctx, collectionName)
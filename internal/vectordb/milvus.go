@@ -3,8 +3,13 @@ package vectordb
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"ai-agent-assistant/internal/config"
+
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
@@ -16,6 +21,8 @@ type MilvusConfig struct {
 	Password       string        // 密码
 	Database       string        // 数据库名称
 	ConnectTimeout time.Duration // 连接超时时间
+	PoolSize       int           // 连接数，请求轮流使用各连接，默认1
+	HealthInterval time.Duration // 健康检查间隔，检查失败的连接会重建，0表示不检查
 }
 
 // DefaultMilvusConfig 返回默认配置
@@ -26,13 +33,37 @@ func DefaultMilvusConfig() *MilvusConfig {
 		Password:       "",
 		Database:       "default",
 		ConnectTimeout: 10 * time.Second,
+		PoolSize:       1,
 	}
 }
 
-// MilvusClient Milvus客户端
+// defaultHealthInterval 配置未指定时的连接健康检查间隔
+const defaultHealthInterval = 30 * time.Second
+
+// MilvusConfigFromConfig 根据应用配置生成客户端配置，未设置或无效的项使用默认值
+func MilvusConfigFromConfig(cfg config.MilvusConfig) *MilvusConfig {
+	mc := DefaultMilvusConfig()
+	mc.Address = cfg.Address
+	if cfg.PoolSize > 0 {
+		mc.PoolSize = cfg.PoolSize
+	}
+	mc.HealthInterval = defaultHealthInterval
+	if d, err := time.ParseDuration(cfg.HealthCheckInterval); err == nil && d > 0 {
+		mc.HealthInterval = d
+	}
+	return mc
+}
+
+// MilvusClient Milvus客户端，持有一组连接，并发写入时分摊到不同连接上
 type MilvusClient struct {
-	client client.Client
+	mu     sync.RWMutex
+	conns  []client.Client
+	next   atomic.Uint64
 	config *MilvusConfig
+
+	reconnects atomic.Int64 // 健康检查重建连接的次数
+	stop       chan struct{}
+	stopOnce   sync.Once
 }
 
 // NewMilvusClient 创建Milvus客户端
@@ -41,64 +72,169 @@ func NewMilvusClient(config *MilvusConfig) (*MilvusClient, error) {
 		config = DefaultMilvusConfig()
 	}
 
-	// 构建Milvus客户端配置
-	clientCfg := client.Config{
-		Address:  config.Address,
-		Username: config.Username,
-		Password: config.Password,
+	poolSize := config.PoolSize
+	if poolSize <= 0 {
+		poolSize = 1
 	}
 
-	// 连接到Milvus
-	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	mc := &MilvusClient{
+		conns:  make([]client.Client, 0, poolSize),
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	for i := 0; i < poolSize; i++ {
+		conn, err := mc.connect()
+		if err != nil {
+			mc.closeConns()
+			return nil, err
+		}
+		mc.conns = append(mc.conns, conn)
+	}
+
+	if config.HealthInterval > 0 {
+		go mc.healthLoop(config.HealthInterval)
+	}
+
+	return mc, nil
+}
+
+// connect 建立一个连接并确认服务健康
+func (mc *MilvusClient) connect() (client.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mc.connectTimeout())
 	defer cancel()
 
-	milvusClient, err := client.NewClient(ctx, clientCfg)
+	conn, err := client.NewClient(ctx, client.Config{
+		Address:  mc.config.Address,
+		Username: mc.config.Username,
+		Password: mc.config.Password,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to milvus: %w", err)
 	}
-
-	mc := &MilvusClient{
-		client: milvusClient,
-		config: config,
-	}
-
-	// 测试连接
-	if err := mc.Ping(ctx); err != nil {
-		milvusClient.Close()
+	if err := checkHealth(ctx, conn); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping milvus: %w", err)
 	}
+	return conn, nil
+}
 
-	return mc, nil
+func (mc *MilvusClient) connectTimeout() time.Duration {
+	if mc.config.ConnectTimeout > 0 {
+		return mc.config.ConnectTimeout
+	}
+	return DefaultMilvusConfig().ConnectTimeout
 }
 
-// Ping 测试Milvus连接
-func (mc *MilvusClient) Ping(ctx context.Context) error {
-	// 使用CheckHealth来测试连接
-	health, err := mc.client.CheckHealth(ctx)
+// checkHealth 检查单个连接
+func checkHealth(ctx context.Context, conn client.Client) error {
+	health, err := conn.CheckHealth(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to check health: %w", err)
 	}
-
 	if !health.IsHealthy {
 		return fmt.Errorf("milvus is not healthy")
 	}
+	return nil
+}
+
+// healthLoop 定期检查各连接，失败的连接重建后替换，重建失败时保留原连接等待下一轮
+func (mc *MilvusClient) healthLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-mc.stop:
+			return
+		case <-ticker.C:
+			mc.checkConns()
+		}
+	}
+}
+
+func (mc *MilvusClient) checkConns() {
+	mc.mu.RLock()
+	conns := append([]client.Client(nil), mc.conns...)
+	mc.mu.RUnlock()
+
+	for i, conn := range conns {
+		ctx, cancel := context.WithTimeout(context.Background(), mc.connectTimeout())
+		err := checkHealth(ctx, conn)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		fresh, connErr := mc.connect()
+		if connErr != nil {
+			log.Printf("Warning: milvus connection %d unhealthy (%v), reconnect failed: %v", i, err, connErr)
+			continue
+		}
+		mc.mu.Lock()
+		mc.conns[i] = fresh
+		mc.mu.Unlock()
+		conn.Close()
+		mc.reconnects.Add(1)
+		log.Printf("Milvus connection %d reconnected after health check failure: %v", i, err)
+	}
+}
 
+// Ping 测试Milvus连接，任一连接不健康时返回错误
+func (mc *MilvusClient) Ping(ctx context.Context) error {
+	mc.mu.RLock()
+	conns := append([]client.Client(nil), mc.conns...)
+	mc.mu.RUnlock()
+
+	for _, conn := range conns {
+		if err := checkHealth(ctx, conn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// PoolStats 连接池状态
+func (mc *MilvusClient) PoolStats() map[string]interface{} {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	return map[string]interface{}{
+		"pool_size":  len(mc.conns),
+		"reconnects": mc.reconnects.Load(),
+	}
+}
+
 // Close 关闭Milvus客户端
 func (mc *MilvusClient) Close() error {
-	return mc.client.Close()
+	mc.stopOnce.Do(func() { close(mc.stop) })
+	return mc.closeConns()
+}
+
+func (mc *MilvusClient) closeConns() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var firstErr error
+	for _, conn := range mc.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	mc.conns = nil
+	return firstErr
 }
 
-// GetClient 获取原始Milvus客户端
+// GetClient 获取原始Milvus客户端，多个连接时轮流返回
 func (mc *MilvusClient) GetClient() client.Client {
-	return mc.client
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	return mc.conns[mc.next.Add(1)%uint64(len(mc.conns))]
 }
 
 // ListCollections 列出所有集合
 func (mc *MilvusClient) ListCollections(ctx context.Context) ([]string, error) {
-	collections, err := mc.client.ListCollections(ctx)
+	collections, err := mc.GetClient().ListCollections(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list collections: %w", err)
 	}
@@ -113,7 +249,7 @@ func (mc *MilvusClient) ListCollections(ctx context.Context) ([]string, error) {
 
 // HasCollection 检查集合是否存在
 func (mc *MilvusClient) HasCollection(ctx context.Context, collectionName string) (bool, error) {
-	has, err := mc.client.HasCollection(ctx, collectionName)
+	has, err := mc.GetClient().HasCollection(ctx, collectionName)
 	if err != nil {
 		return false, fmt.Errorf("failed to check collection: %w", err)
 	}
@@ -122,17 +258,17 @@ func (mc *MilvusClient) HasCollection(ctx context.Context, collectionName string
 
 // DropCollection 删除集合
 func (mc *MilvusClient) DropCollection(ctx context.Context, collectionName string) error {
-	return mc.client.DropCollection(ctx, collectionName)
+	return mc.GetClient().DropCollection(ctx, collectionName)
 }
 
 // CreateIndex 为集合创建索引
 func (mc *MilvusClient) CreateIndex(ctx context.Context, collectionName string, fieldName string, index entity.Index) error {
-	return mc.client.CreateIndex(ctx, collectionName, fieldName, index, false)
+	return mc.GetClient().CreateIndex(ctx, collectionName, fieldName, index, false)
 }
 
 // GetIndex 获取集合的索引信息
 func (mc *MilvusClient) GetIndex(ctx context.Context, collectionName string, fieldName string) ([]entity.Index, error) {
-	indexes, err := mc.client.DescribeIndex(ctx, collectionName, fieldName)
+	indexes, err := mc.GetClient().DescribeIndex(ctx, collectionName, fieldName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to describe index: %w", err)
 	}
@@ -141,10 +277,10 @@ func (mc *MilvusClient) GetIndex(ctx context.Context, collectionName string, fie
 
 // LoadCollection 加载集合到内存
 func (mc *MilvusClient) LoadCollection(ctx context.Context, collectionName string) error {
-	return mc.client.LoadCollection(ctx, collectionName, false)
+	return mc.GetClient().LoadCollection(ctx, collectionName, false)
 }
 
 // ReleaseCollection 释放集合内存
 func (mc *MilvusClient) ReleaseCollection(ctx context.Context, collectionName string) error {
-	return mc.client.ReleaseCollection(ctx, collectionName)
+	return mc.GetClient().ReleaseCollection(ctx, collectionName)
 }
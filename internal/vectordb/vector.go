@@ -39,8 +39,23 @@ func NewVectorOperations(client *MilvusClient, collectionName string, dimension
 	}
 }
 
-// Insert 插入向量数据
+// Insert 插入向量数据并刷新集合
 func (vo *VectorOperations) Insert(ctx context.Context, vectors []*VectorData) (int64, error) {
+	n, err := vo.InsertRows(ctx, vectors)
+	if err != nil {
+		return 0, err
+	}
+
+	// 刷新以确保数据持久化
+	if err := vo.Flush(ctx); err != nil {
+		log.Printf("Warning: failed to flush collection: %v", err)
+	}
+
+	return n, nil
+}
+
+// InsertRows 插入向量数据但不刷新集合，批量写入时由调用方在最后统一调用Flush
+func (vo *VectorOperations) InsertRows(ctx context.Context, vectors []*VectorData) (int64, error) {
 	if len(vectors) == 0 {
 		return 0, fmt.Errorf("no vectors to insert")
 	}
@@ -75,14 +90,14 @@ func (vo *VectorOperations) Insert(ctx context.Context, vectors []*VectorData) (
 		return 0, fmt.Errorf("failed to insert vectors: %w", err)
 	}
 
-	// 刷新以确保数据持久化
-	if err := vo.client.GetClient().Flush(ctx, vo.collection, false); err != nil {
-		log.Printf("Warning: failed to flush collection: %v", err)
-	}
-
 	return int64(len(ids)), nil
 }
 
+// Flush 刷新集合，把已插入的数据持久化
+func (vo *VectorOperations) Flush(ctx context.Context) error {
+	return vo.client.GetClient().Flush(ctx, vo.collection, false)
+}
+
 // InsertWithMetadata 插入带元数据的向量
 func (vo *VectorOperations) InsertWithMetadata(ctx context.Context, vectors []*VectorData) (int64, error) {
 	return vo.Insert(ctx, vectors)
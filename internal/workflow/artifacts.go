@@ -0,0 +1,208 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/task"
+)
+
+// ExecutionArtifact 执行过程中生成的产物（报告、图表、转换后的文件、压缩包等），
+// 内容保存在产物存储中，按名称通过 GET /workflows/executions/:id/artifacts/:name 获取
+type ExecutionArtifact struct {
+	Name        string    `json:"name"`
+	ArtifactID  string    `json:"artifact_id"`
+	StepID      string    `json:"step_id"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddArtifact 登记产物，同名产物（如gate重试后重新生成）以最新的为准
+func (e *WorkflowExecution) AddArtifact(item *ExecutionArtifact) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Artifacts == nil {
+		e.Artifacts = make(map[string]*ExecutionArtifact)
+	}
+	e.Artifacts[item.Name] = item
+}
+
+// GetArtifact 按名称获取登记的产物
+func (e *WorkflowExecution) GetArtifact(name string) (*ExecutionArtifact, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	item, ok := e.Artifacts[name]
+	return item, ok
+}
+
+// ListArtifacts 按名称排序的全部产物
+func (e *WorkflowExecution) ListArtifacts() []*ExecutionArtifact {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	items := make([]*ExecutionArtifact, 0, len(e.Artifacts))
+	for _, item := range e.Artifacts {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+// SetArtifactStore 设置产物存储，步骤通过 config.artifact 声明的产物保存到其中；未设置时声明产物的步骤失败
+func (e *Executor) SetArtifactStore(store artifact.Store) {
+	e.artifacts = store
+}
+
+// artifactSpec 步骤 config.artifact 声明的一个产物
+type artifactSpec struct {
+	name        string // 产物名称，可使用 {{变量}} 模板
+	contentType string // 未设置时字符串内容为text/plain，其他内容编码为JSON
+	field       string // 取输出中的字段（点号分隔的路径），未设置时取整个输出
+}
+
+// parseArtifactSpecs 解析 config.artifact：单个对象或对象列表，每项有 name、可选的 content_type 和 field
+func parseArtifactSpecs(step *Step) ([]artifactSpec, error) {
+	raw, ok := step.Config["artifact"]
+	if !ok {
+		return nil, nil
+	}
+	var items []interface{}
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	default:
+		return nil, fmt.Errorf("step %s: config.artifact must be an object or a list", step.ID)
+	}
+
+	specs := make([]artifactSpec, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("step %s: artifact %d must be an object", step.ID, i)
+		}
+		var spec artifactSpec
+		spec.name, _ = m["name"].(string)
+		if spec.name == "" {
+			return nil, fmt.Errorf("step %s: artifact %d requires name", step.ID, i)
+		}
+		spec.contentType, _ = m["content_type"].(string)
+		spec.field, _ = m["field"].(string)
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// collectArtifacts 步骤成功后登记其产物：保存 config.artifact 声明的输出内容，
+// 并登记输出中已由Agent保存的产物引用（如分析Agent的figures）
+func (e *Executor) collectArtifacts(ctx context.Context, execution *WorkflowExecution, step *Step, output interface{}) error {
+	specs, err := parseArtifactSpecs(step)
+	if err != nil {
+		return err
+	}
+	if len(specs) > 0 && e.artifacts == nil {
+		return fmt.Errorf("step %s declares artifacts but no artifact store is configured", step.ID)
+	}
+
+	inputs := stepInputs(execution, step)
+	for _, spec := range specs {
+		value := output
+		if spec.field != "" {
+			var found bool
+			value, found = lookupInput(map[string]interface{}{"output": output}, "output."+spec.field)
+			if !found {
+				return fmt.Errorf("step %s: artifact field %s not found in output", step.ID, spec.field)
+			}
+		}
+		data, contentType, err := artifactContent(value, spec.contentType)
+		if err != nil {
+			return fmt.Errorf("step %s: failed to encode artifact: %w", step.ID, err)
+		}
+
+		source := step.Agent
+		if source == "" {
+			source = step.Type
+		}
+		item := &artifact.Artifact{Name: renderString(spec.name, inputs), ContentType: contentType, Source: source}
+		if err := e.artifacts.Save(ctx, item, data); err != nil {
+			return fmt.Errorf("step %s: failed to save artifact %s: %w", step.ID, item.Name, err)
+		}
+		execution.AddArtifact(&ExecutionArtifact{
+			Name:        item.Name,
+			ArtifactID:  item.ID,
+			StepID:      step.ID,
+			ContentType: item.ContentType,
+			Size:        item.Size,
+			CreatedAt:   item.CreatedAt,
+		})
+	}
+
+	for _, ref := range artifactRefs(output) {
+		execution.AddArtifact(&ExecutionArtifact{
+			Name:        ref.Name,
+			ArtifactID:  ref.ID,
+			StepID:      step.ID,
+			ContentType: ref.ContentType,
+			CreatedAt:   time.Now(),
+		})
+	}
+	return nil
+}
+
+// artifactContent 产物的内容和类型：字符串和字节按原样保存，其他值编码为JSON
+func artifactContent(value interface{}, contentType string) ([]byte, string, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, contentType, nil
+	case string:
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		return []byte(v), contentType, nil
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return data, contentType, nil
+}
+
+// artifactRefs 步骤输出中的产物引用：输出本身或输出对象的顶层字段为 task.ArtifactRef 或其列表
+func artifactRefs(output interface{}) []task.ArtifactRef {
+	var refs []task.ArtifactRef
+	add := func(v interface{}) {
+		switch val := v.(type) {
+		case task.ArtifactRef:
+			refs = append(refs, val)
+		case []task.ArtifactRef:
+			refs = append(refs, val...)
+		}
+	}
+	add(output)
+	if m, ok := output.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			add(m[k])
+		}
+	}
+
+	valid := refs[:0]
+	for _, ref := range refs {
+		if ref.ID != "" && ref.Name != "" {
+			valid = append(valid, ref)
+		}
+	}
+	return valid
+}
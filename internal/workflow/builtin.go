@@ -0,0 +1,57 @@
+package workflow
+
+import "time"
+
+// ReportWorkflowID 内置报告生成工作流的ID
+const ReportWorkflowID = "builtin-report"
+
+// ReportWorkflow 内置的报告生成工作流：researcher → analyst → writer
+// 步骤的Agent为Agent类型，goal中的 {{topic}} 由工作流输入替换；
+// analyst以调研结果为input，writer以分析结果为data、调研结果为research
+func ReportWorkflow() *Workflow {
+	now := time.Now()
+	return &Workflow{
+		ID:          ReportWorkflowID,
+		Name:        "report",
+		Description: "多Agent协作生成综合报告：调研、分析、撰写",
+		Version:     "1.0",
+		Steps: []*Step{
+			{
+				ID:     "research",
+				Name:   "收集资料",
+				Type:   "task",
+				Agent:  "researcher",
+				Config: map[string]interface{}{"goal": "收集资料：{{topic}}"},
+			},
+			{
+				ID:        "analyze",
+				Name:      "分析资料",
+				Type:      "task",
+				Agent:     "analyst",
+				DependsOn: []string{"research"},
+				Config:    map[string]interface{}{"goal": "分析资料：{{topic}}"},
+				Inputs:    map[string]string{"input": "research"},
+			},
+			{
+				ID:        "write",
+				Name:      "撰写报告",
+				Type:      "task",
+				Agent:     "writer",
+				DependsOn: []string{"analyze"},
+				Config:    map[string]interface{}{"goal": "生成报告：{{topic}}"},
+				Inputs:    map[string]string{"data": "analyze", "research": "research"},
+			},
+		},
+		Agents: []*AgentRef{
+			{Name: "researcher", Type: "researcher", Role: "信息收集"},
+			{Name: "analyst", Type: "analyst", Role: "数据分析"},
+			{Name: "writer", Type: "writer", Role: "报告撰写"},
+		},
+		Variables: []*Variable{
+			{Name: "topic", Type: "string", Required: true, Description: "报告主题"},
+			{Name: "sections", Type: "array", Description: "报告章节"},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
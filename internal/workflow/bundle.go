@@ -0,0 +1,356 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-agent-assistant/internal/tenant"
+)
+
+// BundleVersion 当前的工作流包格式版本
+const BundleVersion = 1
+
+var (
+	// ErrInvalidBundle 工作流包格式错误或包中的定义未通过校验
+	ErrInvalidBundle = errors.New("invalid workflow bundle")
+	// ErrBundleConflict 导入的工作流、提示词模板或工具链与已有的同名定义不同，且未指定覆盖
+	ErrBundleConflict = errors.New("workflow bundle conflicts with existing definitions")
+)
+
+// Bundle 工作流包：工作流定义及其引用的提示词模板、工具链定义和Agent人设，用于在环境之间共享工作流
+type Bundle struct {
+	Version    int                    `json:"version"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Workflow   *Workflow              `json:"workflow"`
+	Prompts    []*PromptTemplate      `json:"prompts,omitempty"`
+	ToolChains []*ToolChainDefinition `json:"tool_chains,omitempty"`
+	Personas   []*PersonaRef          `json:"personas,omitempty"` // task步骤使用的Agent在导出环境中的人设
+}
+
+// PersonaRef Agent人设的引用，只记录用于比对的字段；人设本身随部署配置（agent.personas_dir）分发，不随工作流导入
+type PersonaRef struct {
+	Agent string   `json:"agent"`
+	Name  string   `json:"name,omitempty"`
+	Model string   `json:"model,omitempty"`
+	Tools []string `json:"tools,omitempty"`
+}
+
+// PersonaResolver 查询本环境中Agent的人设，Agent不存在时返回false
+type PersonaResolver func(agent string) (*PersonaRef, bool)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	Overwrite bool // 覆盖内容不同的同名工作流、提示词模板和工具链
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	WorkflowID string   `json:"workflow_id"`
+	Replaced   bool     `json:"replaced"`           // 是否覆盖了已有的工作流
+	Prompts    []string `json:"prompts"`            // 新增或覆盖的提示词模板，内容相同的不计入
+	ToolChains []string `json:"tool_chains"`        // 新增或覆盖的工具链，内容相同的不计入
+	Warnings   []string `json:"warnings,omitempty"` // Agent不存在或人设与导出环境不一致
+}
+
+// BundleManager 导出和导入工作流包
+// 导入时先校验包中的全部内容，再安装提示词模板、工具链和工作流；保存工作流失败时撤销已安装的模板和工具链
+type BundleManager struct {
+	repo     Repository
+	prompts  *PromptLibrary
+	chains   *ToolChainLibrary
+	personas PersonaResolver
+
+	mu sync.Mutex // 导入之间互斥，避免交错安装
+}
+
+// NewBundleManager 创建工作流包管理器，prompts和chains通常为执行器使用的库（见 Executor.Prompts、Executor.ToolChains）
+func NewBundleManager(repo Repository, prompts *PromptLibrary, chains *ToolChainLibrary) *BundleManager {
+	return &BundleManager{repo: repo, prompts: prompts, chains: chains}
+}
+
+// SetPersonaResolver 设置人设查询，未设置时导出不包含人设，导入不比对人设
+func (m *BundleManager) SetPersonaResolver(resolver PersonaResolver) {
+	m.personas = resolver
+}
+
+// Export 导出当前租户的工作流及其引用的提示词模板、工具链和Agent人设；导出的工作流不包含租户
+func (m *BundleManager) Export(ctx context.Context, workflowID string) (*Bundle, error) {
+	wf, err := m.repo.Get(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if wf.Tenant != tenant.FromContext(ctx) {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+
+	exported := *wf
+	exported.Tenant = ""
+	bundle := &Bundle{Version: BundleVersion, ExportedAt: time.Now(), Workflow: &exported}
+
+	refs := collectReferences(wf)
+	for _, name := range refs.prompts {
+		tmpl, ok := m.prompts.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("workflow %s references unknown prompt template %s", wf.ID, name)
+		}
+		bundle.Prompts = append(bundle.Prompts, tmpl)
+	}
+	for _, name := range refs.chains {
+		chain, ok := m.chains.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("workflow %s references unknown tool chain %s", wf.ID, name)
+		}
+		bundle.ToolChains = append(bundle.ToolChains, chain)
+	}
+	if m.personas != nil {
+		for _, agent := range refs.agents {
+			if ref, ok := m.personas(agent); ok {
+				bundle.Personas = append(bundle.Personas, ref)
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// Import 校验并安装工作流包，工作流归属于当前租户
+// 包的版本不支持、定义未通过校验或引用了包和本环境中都不存在的模板、工具链时返回ErrInvalidBundle；
+// 已有内容不同的同名定义且未指定覆盖，或工作流ID已被其他租户使用时返回ErrBundleConflict，此时不安装任何内容
+func (m *BundleManager) Import(ctx context.Context, bundle *Bundle, opts ImportOptions) (*ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateBundle(bundle); err != nil {
+		return nil, err
+	}
+	wf := *bundle.Workflow
+	wf.Tenant = tenant.FromContext(ctx)
+	wf.UpdatedAt = time.Now()
+	result := &ImportResult{WorkflowID: wf.ID, Prompts: []string{}, ToolChains: []string{}}
+
+	// 找出需要安装的模板和工具链，引用的定义必须在包中或本环境中存在
+	prompts, err := pendingItems(m.prompts, bundle.Prompts, func(p *PromptTemplate) string { return p.Name }, "prompt template", opts.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+	chains, err := pendingItems(m.chains, bundle.ToolChains, func(c *ToolChainDefinition) string { return c.Name }, "tool chain", opts.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+	refs := collectReferences(&wf)
+	for _, name := range refs.prompts {
+		if _, ok := m.prompts.Get(name); !ok && !slices.ContainsFunc(bundle.Prompts, func(p *PromptTemplate) bool { return p.Name == name }) {
+			return nil, fmt.Errorf("%w: prompt template %s is neither in the bundle nor installed", ErrInvalidBundle, name)
+		}
+	}
+	for _, name := range refs.chains {
+		if _, ok := m.chains.Get(name); !ok && !slices.ContainsFunc(bundle.ToolChains, func(c *ToolChainDefinition) bool { return c.Name == name }) {
+			return nil, fmt.Errorf("%w: tool chain %s is neither in the bundle nor installed", ErrInvalidBundle, name)
+		}
+	}
+
+	existing, err := m.repo.Get(ctx, wf.ID)
+	switch {
+	case err == nil:
+		if existing.Tenant != wf.Tenant {
+			return nil, fmt.Errorf("%w: workflow id %s is already in use", ErrBundleConflict, wf.ID)
+		}
+		if !opts.Overwrite {
+			return nil, fmt.Errorf("%w: workflow %s already exists", ErrBundleConflict, wf.ID)
+		}
+		result.Replaced = true
+	case !errors.Is(err, ErrWorkflowNotFound):
+		return nil, err
+	}
+	result.Warnings = m.checkPersonas(refs.agents, bundle.Personas)
+
+	// 安装，保存工作流失败时恢复原来的模板和工具链
+	restorePrompts := installItems(m.prompts, prompts, func(p *PromptTemplate) string { return p.Name })
+	restoreChains := installItems(m.chains, chains, func(c *ToolChainDefinition) string { return c.Name })
+	if err := m.repo.Save(ctx, &wf); err != nil {
+		restoreChains()
+		restorePrompts()
+		return nil, fmt.Errorf("failed to save workflow %s: %w", wf.ID, err)
+	}
+	for _, p := range prompts {
+		result.Prompts = append(result.Prompts, p.Name)
+	}
+	for _, c := range chains {
+		result.ToolChains = append(result.ToolChains, c.Name)
+	}
+	return result, nil
+}
+
+// validateBundle 校验包的版本、工作流定义、提示词模板和工具链，名称不能重复
+func validateBundle(bundle *Bundle) error {
+	if bundle == nil || bundle.Workflow == nil {
+		return fmt.Errorf("%w: workflow is required", ErrInvalidBundle)
+	}
+	if bundle.Version != BundleVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, bundle.Version)
+	}
+	if bundle.Workflow.ID == "" {
+		return fmt.Errorf("%w: workflow id is required", ErrInvalidBundle)
+	}
+	if err := bundle.Workflow.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range bundle.Prompts {
+		if p == nil {
+			return fmt.Errorf("%w: empty prompt template", ErrInvalidBundle)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("%w: duplicate prompt template %s", ErrInvalidBundle, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	seen = make(map[string]bool)
+	for _, c := range bundle.ToolChains {
+		if c == nil {
+			return fmt.Errorf("%w: empty tool chain", ErrInvalidBundle)
+		}
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: duplicate tool chain %s", ErrInvalidBundle, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+// pendingItems 包中需要安装的定义：与库中内容相同（按JSON比较，忽略数字类型的差异）的跳过，内容不同且不覆盖时返回ErrBundleConflict
+func pendingItems[T any](lib *library[T], items []T, name func(T) string, kind string, overwrite bool) ([]T, error) {
+	pending := make([]T, 0, len(items))
+	for _, item := range items {
+		current, ok := lib.Get(name(item))
+		if ok && sameJSON(current, item) {
+			continue
+		}
+		if ok && !overwrite {
+			return nil, fmt.Errorf("%w: %s %s already exists with different content", ErrBundleConflict, kind, name(item))
+		}
+		pending = append(pending, item)
+	}
+	return pending, nil
+}
+
+// sameJSON a和b编码为JSON后是否相同
+func sameJSON(a, b interface{}) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+// installItems 安装定义，返回恢复安装前内容的函数
+func installItems[T any](lib *library[T], items []T, name func(T) string) func() {
+	type previous struct {
+		item   T
+		exists bool
+	}
+	saved := make(map[string]previous, len(items))
+	for _, item := range items {
+		current, ok := lib.Get(name(item))
+		saved[name(item)] = previous{item: current, exists: ok}
+		lib.Put(name(item), item)
+	}
+	return func() {
+		for key, prev := range saved {
+			if prev.exists {
+				lib.Put(key, prev.item)
+			} else {
+				lib.Delete(key)
+			}
+		}
+	}
+}
+
+// checkPersonas 比对task步骤使用的Agent在本环境中的人设与导出环境中的人设，不一致时返回警告
+func (m *BundleManager) checkPersonas(agents []string, exported []*PersonaRef) []string {
+	if m.personas == nil {
+		return nil
+	}
+	refs := make(map[string]*PersonaRef, len(exported))
+	for _, ref := range exported {
+		if ref != nil {
+			refs[ref.Agent] = ref
+		}
+	}
+
+	var warnings []string
+	for _, agent := range agents {
+		local, ok := m.personas(agent)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("agent %s is not available in this environment", agent))
+			continue
+		}
+		ref := refs[agent]
+		if ref == nil {
+			continue
+		}
+		if ref.Name != local.Name {
+			warnings = append(warnings, fmt.Sprintf("agent %s persona name differs: bundle %q, local %q", agent, ref.Name, local.Name))
+		}
+		if ref.Model != local.Model {
+			warnings = append(warnings, fmt.Sprintf("agent %s persona model differs: bundle %q, local %q", agent, ref.Model, local.Model))
+		}
+		if !slices.Equal(ref.Tools, local.Tools) {
+			warnings = append(warnings, fmt.Sprintf("agent %s persona tools differ: bundle %v, local %v", agent, ref.Tools, local.Tools))
+		}
+	}
+	return warnings
+}
+
+// bundleReferences 工作流引用的提示词模板、工具链和Agent，均已去重排序
+type bundleReferences struct {
+	prompts []string
+	chains  []string
+	agents  []string
+}
+
+// collectReferences 收集llm、consensus步骤引用的提示词模板、tool步骤引用的工具链和task步骤使用的Agent
+func collectReferences(wf *Workflow) bundleReferences {
+	prompts := make(map[string]bool)
+	chains := make(map[string]bool)
+	agents := make(map[string]bool)
+	for _, step := range wf.Steps {
+		switch step.Type {
+		case "llm", "consensus":
+			if name, _ := step.Config["prompt_template"].(string); name != "" {
+				prompts[name] = true
+			}
+			if name, _ := step.Config["system_template"].(string); name != "" && step.Type == "llm" {
+				prompts[name] = true
+			}
+		case "tool":
+			if name, _ := step.Config["chain"].(string); name != "" {
+				chains[name] = true
+			}
+		case "task":
+			agents[step.Agent] = true
+		}
+	}
+	return bundleReferences{prompts: sortedKeys(prompts), chains: sortedKeys(chains), agents: sortedKeys(agents)}
+}
+
+// sortedKeys 排序后的键
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
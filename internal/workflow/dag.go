@@ -3,6 +3,7 @@ package workflow
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // DAG 有向无环图
@@ -54,41 +55,160 @@ func (d *DAG) AddEdge(from, to string) error {
 		return fmt.Errorf("node %s does not exist", to)
 	}
 
-	// 检查是否会造成循环
-	if d.wouldCreateCycle(from, to) {
-		return fmt.Errorf("adding edge %s -> %s would create a cycle", to, from)
+	// 检查是否会造成循环：to已经（间接）依赖from
+	if path := d.dependencyPath(to, from); path != nil {
+		return fmt.Errorf("adding edge %s -> %s would create a cycle: %s", to, from, strings.Join(append([]string{from}, path...), " -> "))
 	}
 
+	d.addEdge(from, to)
+	return nil
+}
+
+// addEdge 记录from依赖to，不检查环；重复的依赖只记录一次
+func (d *DAG) addEdge(from, to string) {
 	// edges[from] 表示from依赖的所有节点
+	if contains(d.edges[from], to) {
+		return
+	}
 	d.edges[from] = append(d.edges[from], to)
 	d.nodes[from].InDegree++
+}
+
+// dependencyPath start经依赖关系到达target的路径（含首尾），不可达时返回nil
+func (d *DAG) dependencyPath(start, target string) []string {
+	visited := make(map[string]bool)
+	var visit func(current string) []string
+	visit = func(current string) []string {
+		if current == target {
+			return []string{current}
+		}
+		visited[current] = true
+		for _, neighbor := range d.edges[current] {
+			if visited[neighbor] {
+				continue
+			}
+			if path := visit(neighbor); path != nil {
+				return append([]string{current}, path...)
+			}
+		}
+		return nil
+	}
+	return visit(start)
+}
+
+// findCycle 找出一个依赖环，返回环上的节点，每个节点依赖下一个，首尾相同（如 [a b a]）；没有环时返回nil
+// 按order的顺序（为空时按节点ID排序）开始深度优先搜索，使结果确定
+func (d *DAG) findCycle(order []string) []string {
+	if len(order) == 0 {
+		order = make([]string, 0, len(d.nodes))
+		for id := range d.nodes {
+			order = append(order, id)
+		}
+		sort.Strings(order)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(d.nodes))
+	var stack []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range d.edges[id] {
+			switch state[dep] {
+			case visiting:
+				for i, onStack := range stack {
+					if onStack == dep {
+						return append(append([]string(nil), stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
 
+	for _, id := range order {
+		if state[id] == unvisited {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
 	return nil
 }
 
-// wouldCreateCycle 检查是否会创建循环
-func (d *DAG) wouldCreateCycle(from, to string) bool {
-	visited := make(map[string]bool)
-	return d.hasCycleDFS(to, from, visited)
+// DAGError 工作流依赖关系的诊断：依赖了不存在的步骤、依赖成环，以及因此永远无法执行的步骤
+type DAGError struct {
+	Undefined   map[string][]string `json:"undefined,omitempty"`   // 步骤ID -> 依赖的不存在的步骤
+	Cycle       []string            `json:"cycle,omitempty"`       // 依赖环上的步骤，每一步依赖下一步，首尾相同，如 [a b c a]
+	Unreachable []string            `json:"unreachable,omitempty"` // 因依赖环或不存在的步骤而无法执行的其他步骤，按ID排序
 }
 
-// hasCycleDFS DFS检测循环
-func (d *DAG) hasCycleDFS(current, target string, visited map[string]bool) bool {
-	if current == target {
-		return true
+// Error 错误信息，如 "dependency cycle: a -> b -> a; unreachable steps: c"
+func (e *DAGError) Error() string {
+	parts := make([]string, 0, len(e.Undefined)+2)
+	ids := make([]string, 0, len(e.Undefined))
+	for id := range e.Undefined {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("step %s depends on undefined step %s", id, strings.Join(e.Undefined[id], ", ")))
 	}
+	if len(e.Cycle) > 0 {
+		parts = append(parts, "dependency cycle: "+strings.Join(e.Cycle, " -> "))
+	}
+	if len(e.Unreachable) > 0 {
+		parts = append(parts, "unreachable steps: "+strings.Join(e.Unreachable, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
 
-	visited[current] = true
+// unreachable 依赖（直接或间接）环上的步骤或不存在的步骤而无法执行的节点，不含环上的节点和直接依赖不存在步骤的节点
+func (d *DAG) unreachable(diag *DAGError) []string {
+	blocked := make(map[string]bool)
+	for id := range diag.Undefined {
+		blocked[id] = true
+	}
+	for _, id := range diag.Cycle {
+		blocked[id] = true
+	}
 
-	for _, neighbor := range d.edges[current] {
-		if !visited[neighbor] {
-			if d.hasCycleDFS(neighbor, target, visited) {
-				return true
+	// 反复标记依赖了被阻塞节点的节点，直到不再变化
+	for changed := true; changed; {
+		changed = false
+		for id, deps := range d.edges {
+			if blocked[id] {
+				continue
+			}
+			for _, dep := range deps {
+				if blocked[dep] {
+					blocked[id] = true
+					changed = true
+					break
+				}
 			}
 		}
 	}
 
-	return false
+	var result []string
+	for id := range blocked {
+		if diag.Undefined[id] == nil && !contains(diag.Cycle, id) {
+			result = append(result, id)
+		}
+	}
+	sort.Strings(result)
+	return result
 }
 
 // TopologicalSort 拓扑排序
@@ -129,7 +249,9 @@ func (d *DAG) TopologicalSort() ([]string, error) {
 
 	// 检查是否有环
 	if len(result) != len(d.nodes) {
-		return nil, fmt.Errorf("cycle detected in workflow")
+		diag := &DAGError{Cycle: d.findCycle(nil)}
+		diag.Unreachable = d.unreachable(diag)
+		return nil, diag
 	}
 
 	return result, nil
@@ -235,24 +357,38 @@ func (d *DAG) Validate() error {
 }
 
 // BuildDAGFromWorkflow 从工作流构建DAG
+// 依赖了不存在的步骤或依赖成环时返回*DAGError，其中列出不存在的依赖、环的完整路径和因此无法执行的步骤
 func BuildDAGFromWorkflow(workflow *Workflow) (*DAG, error) {
 	dag := NewDAG()
 
 	// 添加所有节点
+	order := make([]string, 0, len(workflow.Steps))
 	for _, step := range workflow.Steps {
 		if err := dag.AddNode(step); err != nil {
 			return nil, fmt.Errorf("failed to add node %s: %w", step.ID, err)
 		}
+		order = append(order, step.ID)
 	}
 
-	// 添加所有边（依赖关系）
+	// 添加所有边（依赖关系），先收集全部问题再一起报告
+	diag := &DAGError{}
 	for _, step := range workflow.Steps {
 		for _, depID := range step.DependsOn {
-			if err := dag.AddEdge(step.ID, depID); err != nil {
-				return nil, fmt.Errorf("failed to add edge %s -> %s: %w", depID, step.ID, err)
+			if _, exists := dag.nodes[depID]; !exists {
+				if diag.Undefined == nil {
+					diag.Undefined = make(map[string][]string)
+				}
+				diag.Undefined[step.ID] = append(diag.Undefined[step.ID], depID)
+				continue
 			}
+			dag.addEdge(step.ID, depID)
 		}
 	}
+	diag.Cycle = dag.findCycle(order)
+	if len(diag.Undefined) > 0 || len(diag.Cycle) > 0 {
+		diag.Unreachable = dag.unreachable(diag)
+		return nil, diag
+	}
 
 	// 验证DAG
 	if err := dag.Validate(); err != nil {
@@ -1,8 +1,10 @@
 package workflow
 
 import (
-	"fmt"
+	"sync"
 	"time"
+
+	"ai-agent-assistant/internal/idgen"
 )
 
 // WorkflowStatus 工作流状态
@@ -39,6 +41,7 @@ type Workflow struct {
 	Variables   []*Variable  `json:"variables,omitempty"`
 	Config      *WorkflowConfig `json:"config,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tenant      string       `json:"tenant,omitempty"` // 创建者所属租户
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
@@ -86,13 +89,14 @@ type Step struct {
 	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
-// Condition 条件判断
+// Condition 条件判断，设置Expression时按表达式求值（可调用内置函数），忽略Variable、Operator和Value
 type Condition struct {
-	Variable string      `json:"variable"`      // 变量名
-	Operator string      `json:"operator"`      // eq, ne, gt, lt, gte, lte, in, not_in, contains
-	Value    interface{} `json:"value"`         // 比较值
-	Then     string      `json:"then"`          // 满足条件时执行的步骤ID
-	Else     string      `json:"else,omitempty"` // 不满足条件时执行的步骤ID
+	Expression string      `json:"expression,omitempty"` // 布尔表达式，如 contains(lower(title), "urgent")
+	Variable   string      `json:"variable"`             // 变量名
+	Operator   string      `json:"operator"`             // eq, ne, gt, lt, gte, lte, in, not_in, contains
+	Value      interface{} `json:"value"`                // 比较值
+	Then       string      `json:"then"`                 // 满足条件时执行的步骤ID
+	Else       string      `json:"else,omitempty"`       // 不满足条件时执行的步骤ID
 }
 
 // RetryConfig 重试配置
@@ -103,6 +107,7 @@ type RetryConfig struct {
 }
 
 // WorkflowExecution 工作流执行记录
+// 并行步骤和查询接口会同时访问执行记录，步骤状态和完成状态通过方法读写，查询时使用 Snapshot
 type WorkflowExecution struct {
 	ID            string                   `json:"id"`
 	WorkflowID    string                   `json:"workflow_id"`
@@ -117,6 +122,10 @@ type WorkflowExecution struct {
 	CompletedAt   *time.Time               `json:"completed_at,omitempty"`
 	Duration      time.Duration            `json:"duration"`
 	Metadata      map[string]interface{}   `json:"metadata,omitempty"`
+	Artifacts     map[string]*ExecutionArtifact `json:"artifacts,omitempty"` // 名称 -> 执行中生成的产物
+	Tenant        string                   `json:"tenant,omitempty"`    // 执行所属的租户，只使用对该租户可见的Agent
+
+	mu sync.RWMutex
 }
 
 // StepState 步骤执行状态
@@ -183,11 +192,12 @@ type YAMLStep struct {
 
 // YAMLCondition YAML格式的条件
 type YAMLCondition struct {
-	Variable string      `yaml:"variable"`
-	Operator string      `yaml:"operator"`
-	Value    interface{} `yaml:"value"`
-	Then     string      `yaml:"then"`
-	Else     string      `yaml:"else,omitempty"`
+	Expression string      `yaml:"expression,omitempty"`
+	Variable   string      `yaml:"variable"`
+	Operator   string      `yaml:"operator"`
+	Value      interface{} `yaml:"value"`
+	Then       string      `yaml:"then"`
+	Else       string      `yaml:"else,omitempty"`
 }
 
 // Helper functions
@@ -195,7 +205,7 @@ type YAMLCondition struct {
 // NewWorkflow 创建新工作流
 func NewWorkflow(name, description string) *Workflow {
 	return &Workflow{
-		ID:          generateID("workflow"),
+		ID:          idgen.New(idgen.PrefixWorkflow),
 		Name:        name,
 		Description: description,
 		Version:     "1.0",
@@ -227,10 +237,11 @@ func (w *Workflow) AddVariable(variable *Variable) {
 // NewWorkflowExecution 创建工作流执行实例
 func NewWorkflowExecution(workflow *Workflow, inputs map[string]interface{}) *WorkflowExecution {
 	return &WorkflowExecution{
-		ID:           generateID("exec"),
+		ID:           idgen.New(idgen.PrefixExecution),
 		WorkflowID:   workflow.ID,
 		WorkflowName: workflow.Name,
 		Workflow:     workflow, // 保存工作流定义引用
+		Tenant:       workflow.Tenant,
 		Status:       WorkflowStatusPending,
 		Inputs:       inputs,
 		Outputs:      make(map[string]interface{}),
@@ -252,21 +263,68 @@ func (w *Workflow) GetStep(stepID string) *Step {
 
 // GetStepState 获取步骤状态
 func (e *WorkflowExecution) GetStepState(stepID string) *StepState {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	return e.StepStates[stepID]
 }
 
 // SetStepState 设置步骤状态
 func (e *WorkflowExecution) SetStepState(stepID string, state *StepState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.StepStates[stepID] = state
 }
 
 // IsCompleted 是否完成
 func (e *WorkflowExecution) IsCompleted() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	return e.Status == WorkflowStatusCompleted || e.Status == WorkflowStatusFailed || e.Status == WorkflowStatusCancelled
 }
 
+// Snapshot 执行记录的一致副本，执行仍在进行时供查询接口读取
+func (e *WorkflowExecution) Snapshot() *WorkflowExecution {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	snapshot := &WorkflowExecution{
+		ID:           e.ID,
+		WorkflowID:   e.WorkflowID,
+		WorkflowName: e.WorkflowName,
+		Workflow:     e.Workflow,
+		Status:       e.Status,
+		Inputs:       e.Inputs,
+		Outputs:      make(map[string]interface{}, len(e.Outputs)),
+		StepStates:   make(map[string]*StepState, len(e.StepStates)),
+		Error:        e.Error,
+		StartedAt:    e.StartedAt,
+		CompletedAt:  e.CompletedAt,
+		Duration:     e.Duration,
+		Metadata:     make(map[string]interface{}, len(e.Metadata)),
+		Tenant:       e.Tenant,
+	}
+	for k, v := range e.Outputs {
+		snapshot.Outputs[k] = v
+	}
+	for k, v := range e.StepStates {
+		snapshot.StepStates[k] = v
+	}
+	for k, v := range e.Metadata {
+		snapshot.Metadata[k] = v
+	}
+	if len(e.Artifacts) > 0 {
+		snapshot.Artifacts = make(map[string]*ExecutionArtifact, len(e.Artifacts))
+		for k, v := range e.Artifacts {
+			snapshot.Artifacts[k] = v
+		}
+	}
+	return snapshot
+}
+
 // MarkCompleted 标记为完成
 func (e *WorkflowExecution) MarkCompleted() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	now := time.Now()
 	e.CompletedAt = &now
 	e.Duration = now.Sub(e.StartedAt)
@@ -275,6 +333,8 @@ func (e *WorkflowExecution) MarkCompleted() {
 
 // MarkFailed 标记为失败
 func (e *WorkflowExecution) MarkFailed(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	now := time.Now()
 	e.CompletedAt = &now
 	e.Duration = now.Sub(e.StartedAt)
@@ -283,8 +343,3 @@ func (e *WorkflowExecution) MarkFailed(err error) {
 		e.Error = err.Error()
 	}
 }
-
-// generateID 生成ID
-func generateID(prefix string) string {
-	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
-}
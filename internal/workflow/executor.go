@@ -2,15 +2,25 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"ai-agent-assistant/internal/agent/events"
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
 	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
 )
 
+// StepRunner 执行task步骤的函数，inputs为工作流输入与依赖步骤输出的合并结果（见stepInputs）
+type StepRunner func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error)
+
 // Executor 工作流执行器
 type Executor struct {
 	registry       *aiagentorchestrator.AgentRegistry
@@ -19,6 +29,56 @@ type Executor struct {
 	decomposer     task.Decomposer
 	aggregator     task.Aggregator
 	stateMgr       *StateManager
+	modelManager   *llm.ModelManager                  // consensus、llm步骤使用（可选）
+	stepRunner     StepRunner                         // task步骤的实际执行者（可选）
+	tools          ToolExecutor                       // tool步骤使用（可选）
+	monitor        *Monitor                           // 记录执行指标，gate步骤读取（可选）
+	artifacts      artifact.Store                     // 步骤声明的产物保存到其中（可选）
+	prompts        *PromptLibrary                     // llm、consensus步骤引用的提示词模板
+	chains         *ToolChainLibrary                  // tool步骤引用的工具链
+	quotas         *tenant.Quotas                     // 每个租户同时运行的执行数上限，nil表示不限制
+	quotaMu        sync.Mutex                         // 检查配额与登记执行实例之间不能插入其他执行
+	elector        *aiagentorchestrator.LeaderElector // 启用选主时只有主实例启动执行，nil表示总是启动
+	defaultTimeout time.Duration                      // 工作流未设置timeout时的执行时长上限，0表示不限制
+	maxParallel    int                                // 并行执行时同一层同时运行的步骤数，0表示不限制
+
+	// 步骤并发上限，所有执行共享：同类Agent的步骤共用一个信号量，全局信号量限制总数
+	globalSlots       semaphore
+	agentLimits       map[string]int
+	defaultAgentLimit int
+	agentMu           sync.Mutex
+	agentSlots        map[string]semaphore
+}
+
+// semaphore 计数信号量，nil表示不限制
+type semaphore chan struct{}
+
+// newSemaphore 创建容量为n的信号量，n<=0时返回nil
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// acquire 获取一个名额，ctx取消时放弃等待
+func (s semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 归还一个名额
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
 }
 
 // NewExecutor 创建执行器
@@ -33,25 +93,234 @@ func NewExecutor(
 		decomposer:   task.NewTemplateDecomposer(),
 		aggregator:   task.NewSimpleAggregator(),
 		stateMgr:     NewStateManager(),
+		prompts:      &PromptLibrary{},
+		chains:       &ToolChainLibrary{},
+	}
+}
+
+// NewExecutorFromConfig 根据配置创建执行器，无效的配置项视为未设置
+func NewExecutorFromConfig(
+	registry *aiagentorchestrator.AgentRegistry,
+	scheduler *aiagentorchestrator.TaskScheduler,
+	cfg config.WorkflowExecutorConfig,
+) *Executor {
+	e := NewExecutor(registry, scheduler)
+	e.defaultTimeout = positiveDuration(cfg.DefaultTimeout, 0)
+	if cfg.MaxParallelSteps > 0 {
+		e.maxParallel = cfg.MaxParallelSteps
+	}
+	e.globalSlots = newSemaphore(cfg.MaxConcurrentSteps)
+	e.defaultAgentLimit = cfg.DefaultAgentLimit
+	e.agentLimits = make(map[string]int, len(cfg.AgentLimits))
+	for agent, limit := range cfg.AgentLimits {
+		e.agentLimits[agent] = limit
+	}
+	return e
+}
+
+// agentSemaphore 返回Agent类型共用的信号量，首次使用时按agent_limits或default_agent_limit创建
+// 未指定Agent的步骤只受全局上限限制
+func (e *Executor) agentSemaphore(agent string) semaphore {
+	if agent == "" {
+		return nil
+	}
+
+	e.agentMu.Lock()
+	defer e.agentMu.Unlock()
+	if slots, ok := e.agentSlots[agent]; ok {
+		return slots
+	}
+	limit, ok := e.agentLimits[agent]
+	if !ok {
+		limit = e.defaultAgentLimit
+	}
+	if e.agentSlots == nil {
+		e.agentSlots = make(map[string]semaphore)
+	}
+	e.agentSlots[agent] = newSemaphore(limit)
+	return e.agentSlots[agent]
+}
+
+// acquireSlots 等待步骤的Agent类型名额和全局名额，返回归还名额的函数
+// 先取Agent名额，避免占着全局名额等待某一类Agent
+func (e *Executor) acquireSlots(ctx context.Context, step *Step) (func(), error) {
+	// gate步骤只做判断，重试分支时还要执行其他步骤，不占用名额
+	if step.Type == "gate" {
+		return func() {}, nil
+	}
+	agentSlots := e.agentSemaphore(step.Agent)
+	if err := agentSlots.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("canceled while waiting for agent %s: %w", step.Agent, err)
+	}
+	if err := e.globalSlots.acquire(ctx); err != nil {
+		agentSlots.release()
+		return nil, fmt.Errorf("canceled while waiting for a step slot: %w", err)
+	}
+	return func() {
+		e.globalSlots.release()
+		agentSlots.release()
+	}, nil
+}
+
+// ConcurrencyStats 各Agent类型和全局正在运行的步骤数及上限（0表示不限制）
+func (e *Executor) ConcurrencyStats() map[string]interface{} {
+	e.agentMu.Lock()
+	agents := make(map[string]interface{}, len(e.agentSlots))
+	for agent, slots := range e.agentSlots {
+		agents[agent] = map[string]int{"running": len(slots), "limit": cap(slots)}
+	}
+	e.agentMu.Unlock()
+
+	return map[string]interface{}{
+		"running": len(e.globalSlots),
+		"limit":   cap(e.globalSlots),
+		"agents":  agents,
+	}
+}
+
+// SetModelManager 设置模型管理器，启用consensus（多模型共识）和llm（模型调用）步骤
+func (e *Executor) SetModelManager(modelManager *llm.ModelManager) {
+	e.modelManager = modelManager
+}
+
+// SetStepRunner 设置task步骤的执行函数，未设置时task步骤只做Agent匹配
+func (e *Executor) SetStepRunner(runner StepRunner) {
+	e.stepRunner = runner
+}
+
+// SetMonitor 设置监控器，记录工作流和步骤的执行指标，gate步骤可引用其中的错误数和自定义指标；
+// 监控器同时作为Agent注册表的运行统计来源，供FindBestAgent按负载和历史表现选择Agent
+func (e *Executor) SetMonitor(monitor *Monitor) {
+	e.monitor = monitor
+	if e.registry != nil && monitor != nil {
+		e.registry.SetStatsProvider(monitor)
 	}
 }
 
-// Execute 执行工作流
+// StateManager 执行器登记执行实例的状态管理器，用于查询执行历史
+func (e *Executor) StateManager() *StateManager {
+	return e.stateMgr
+}
+
+// Execute 执行工作流，租户运行中的执行数达到配额时返回 tenant.ErrQuotaExceeded
 func (e *Executor) Execute(ctx context.Context, workflow *Workflow, inputs map[string]interface{}) (*WorkflowExecution, error) {
+	execution, err := e.newExecution(ctx, workflow, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return execution, e.run(ctx, execution)
+}
+
+// Start 在后台执行工作流，返回执行ID；执行实例已登记到状态管理器，可随时查询进度
+// 租户运行中的执行数达到配额时返回 tenant.ErrQuotaExceeded
+func (e *Executor) Start(ctx context.Context, workflow *Workflow, inputs map[string]interface{}) (string, error) {
+	execution, err := e.newExecution(ctx, workflow, inputs)
+	if err != nil {
+		return "", err
+	}
+	go e.run(ctx, execution)
+	return execution.ID, nil
+}
+
+// SetQuotas 设置租户配额，限制每个租户同时运行的执行数；nil表示不限制
+func (e *Executor) SetQuotas(quotas *tenant.Quotas) {
+	e.quotas = quotas
+}
+
+// SetLeaderElector 设置选举者，本实例不是主实例时Execute、Start、Resume和Restore返回 orchestrator.ErrNotLeader；
+// 失去主实例身份时运行中的执行继续完成
+func (e *Executor) SetLeaderElector(elector *aiagentorchestrator.LeaderElector) {
+	e.elector = elector
+}
+
+// Resume 在后台继续执行从快照恢复的执行实例，已完成或已跳过的步骤不再执行，其输出仍供后续步骤使用
+// 执行实例需带有工作流定义；同ID的执行已存在时返回错误
+func (e *Executor) Resume(ctx context.Context, execution *WorkflowExecution) error {
+	if !e.elector.IsLeader() {
+		return aiagentorchestrator.ErrNotLeader
+	}
+	if execution.Workflow == nil {
+		return fmt.Errorf("execution %s has no workflow definition", execution.ID)
+	}
+	if execution.StepStates == nil {
+		execution.StepStates = make(map[string]*StepState)
+	}
+	if execution.Outputs == nil {
+		execution.Outputs = make(map[string]interface{})
+	}
+	execution.Status = WorkflowStatusRunning
+	execution.CompletedAt = nil
+	if err := e.stateMgr.SetExecution(execution.ID, execution); err != nil {
+		return err
+	}
+	go e.run(ctx, execution)
+	return nil
+}
+
+// newExecution 创建执行实例并登记，执行属于工作流的租户，内置工作流（未绑定租户）的执行属于请求的租户
+func (e *Executor) newExecution(ctx context.Context, workflow *Workflow, inputs map[string]interface{}) (*WorkflowExecution, error) {
+	if !e.elector.IsLeader() {
+		return nil, aiagentorchestrator.ErrNotLeader
+	}
+
 	// 创建执行实例
 	execution := NewWorkflowExecution(workflow, inputs)
+	if execution.Tenant == "" {
+		execution.Tenant = tenant.FromContext(ctx)
+	}
+
+	// 更新执行状态
+	execution.Status = WorkflowStatusRunning
+
+	e.quotaMu.Lock()
+	defer e.quotaMu.Unlock()
+	if e.quotas != nil && execution.Tenant != "" {
+		running := 0
+		for _, other := range e.stateMgr.GetExecutionsByStatus(WorkflowStatusRunning) {
+			if other.Tenant == execution.Tenant {
+				running++
+			}
+		}
+		if err := e.quotas.Check(execution.Tenant, tenant.ResourceExecutions, running); err != nil {
+			return nil, err
+		}
+	}
 
 	// 初始化状态
 	e.stateMgr.SetExecution(execution.ID, execution)
 
-	// 更新执行状态
-	execution.Status = WorkflowStatusRunning
+	return execution, nil
+}
+
+// run 逐层执行工作流的步骤
+func (e *Executor) run(ctx context.Context, execution *WorkflowExecution) error {
+	workflow := execution.Workflow
+
+	timeout := e.defaultTimeout
+	if workflow.Config != nil && workflow.Config.Timeout > 0 {
+		timeout = workflow.Config.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// 服务商限流时工作流步骤的LLM调用排在交互式对话之后
+	ctx = llm.WithPriority(ctx, llm.PriorityWorkflow)
+
+	// 恢复的执行沿用已有的指标
+	if e.monitor != nil {
+		if _, err := e.monitor.GetExecutionMetrics(execution.ID); err != nil {
+			e.monitor.RecordWorkflowStart(execution.ID, workflow.ID)
+		}
+	}
 
 	// 构建DAG
 	dag, err := BuildDAGFromWorkflow(workflow)
 	if err != nil {
 		execution.MarkFailed(fmt.Errorf("failed to build DAG: %w", err))
-		return execution, err
+		e.recordWorkflowEnd(execution, err)
+		return err
 	}
 
 	// 获取执行层级
@@ -61,18 +330,26 @@ func (e *Executor) Execute(ctx context.Context, workflow *Workflow, inputs map[s
 	for levelIndex, levelSteps := range levels {
 		fmt.Printf("  📍 执行第%d层，共%d个步骤\n", levelIndex+1, len(levelSteps))
 
+		// 恢复的执行跳过已完成的步骤
+		levelSteps = remainingSteps(execution, levelSteps)
+		if len(levelSteps) == 0 {
+			continue
+		}
+
 		// 执行这一层的所有步骤
 		results := e.executeLevel(ctx, execution, dag, levelSteps)
 
 		// 检查是否有步骤失败
 		for _, result := range results {
 			if !result.Success {
-				// 如果配置了continue_on_error，继续执行
-				if execution.Workflow.Config != nil && execution.Workflow.Config.ContinueOnError {
+				// 如果配置了continue_on_error，继续执行；gate步骤中止时仍然停止
+				if !result.aborted && execution.Workflow.Config != nil && execution.Workflow.Config.ContinueOnError {
 					fmt.Printf("  ⚠️  步骤 %s 失败，但继续执行\n", result.StepID)
 				} else {
 					execution.MarkFailed(fmt.Errorf("step %s failed", result.StepID))
-					return execution, fmt.Errorf("workflow execution failed at step %s", result.StepID)
+					err := fmt.Errorf("workflow execution failed at step %s", result.StepID)
+					e.recordWorkflowEnd(execution, err)
+					return err
 				}
 			}
 		}
@@ -81,8 +358,16 @@ func (e *Executor) Execute(ctx context.Context, workflow *Workflow, inputs map[s
 	// 标记完成
 	execution.MarkCompleted()
 	e.stateMgr.UpdateExecution(execution.ID, execution)
+	e.recordWorkflowEnd(execution, nil)
 
-	return execution, nil
+	return nil
+}
+
+// recordWorkflowEnd 设置了监控器时记录工作流结束
+func (e *Executor) recordWorkflowEnd(execution *WorkflowExecution, err error) {
+	if e.monitor != nil {
+		e.monitor.RecordWorkflowEnd(execution.ID, string(execution.Status), err)
+	}
 }
 
 // executeLevel 执行某一层的步骤
@@ -126,11 +411,19 @@ func (e *Executor) executeParallel(ctx context.Context, execution *WorkflowExecu
 	var wg sync.WaitGroup
 	results := make([]*StepResult, len(stepIDs))
 	resultChan := make(chan *StepResult, len(stepIDs))
+	var slots chan struct{}
+	if e.maxParallel > 0 {
+		slots = make(chan struct{}, e.maxParallel)
+	}
 
 	for i, stepID := range stepIDs {
 		wg.Add(1)
 		go func(index int, stepID string) {
 			defer wg.Done()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
 
 			step := execution.Workflow.GetStep(stepID)
 			if step == nil {
@@ -170,6 +463,12 @@ func (e *Executor) executeStep(ctx context.Context, execution *WorkflowExecution
 		Success: true,
 	}
 
+	// 再次执行（gate重试分支、恢复执行）时累计重试次数
+	retryCount := 0
+	if prev := execution.GetStepState(step.ID); prev != nil && (prev.Status == StepStatusCompleted || prev.Status == StepStatusFailed) {
+		retryCount = prev.RetryCount + 1
+	}
+
 	// 创建步骤状态
 	now := time.Now()
 	stepState := &task.TaskState{
@@ -193,32 +492,31 @@ func (e *Executor) executeStep(ctx context.Context, execution *WorkflowExecution
 	}
 	e.lifecycleMgr.Create(tempTask)
 
-	// 更新为运行中
-	e.lifecycleMgr.UpdateStatus(step.ID, task.TaskStatusRunning, "step execution started")
-	stepState.Status = task.TaskStatusRunning
-	stepState.Stage = "executing"
-
-	// 根据步骤类型执行
+	// 等待并发名额，同类Agent的步骤和全局的同时运行数有上限
 	var output interface{}
-	var err error
+	release, err := e.acquireSlots(ctx, step)
+	if err == nil {
+		// 更新为运行中
+		e.lifecycleMgr.UpdateStatus(step.ID, task.TaskStatusRunning, "step execution started")
+		events.Emit(ctx, events.Event{Type: events.TypeStepStarted, Agent: step.Agent, Step: step.ID, Content: step.Name})
+		stepState.Status = task.TaskStatusRunning
+		stepState.Stage = "executing"
+		if e.monitor != nil {
+			e.monitor.RecordStepStart(execution.ID, step.ID, step.Agent)
+		}
 
-	switch step.Type {
-	case "task":
-		output, err = e.executeTaskStep(ctx, execution, step)
-	case "condition":
-		output, err = e.executeConditionStep(ctx, execution, step)
-	case "parallel":
-		output, err = e.executeParallelStep(ctx, execution, step)
-	case "sequential":
-		output, err = e.executeSequentialStep(ctx, execution, step)
-	default:
-		output, err = e.executeTaskStep(ctx, execution, step)
+		output, err = e.runStep(ctx, execution, step)
+		release()
+		if err == nil {
+			err = e.collectArtifacts(ctx, execution, step, output)
+		}
 	}
 
 	// 更新结果
 	if err != nil {
 		result.Success = false
 		result.Error = err.Error()
+		result.aborted = errors.Is(err, ErrGateAborted)
 		e.lifecycleMgr.SetError(step.ID, err)
 		e.lifecycleMgr.UpdateStatus(step.ID, task.TaskStatusFailed, "execution failed")
 	} else {
@@ -238,6 +536,17 @@ func (e *Executor) executeStep(ctx context.Context, execution *WorkflowExecution
 		duration = time.Since(*stepState.StartedAt)
 	}
 
+	if e.monitor != nil {
+		e.monitor.RecordStepEnd(execution.ID, step.ID, string(status), &task.TaskResult{TaskID: step.ID, Error: result.Error}, 0, 0, retryCount)
+	}
+
+	if result.Success {
+		events.Emit(ctx, events.Event{Type: events.TypeStepCompleted, Agent: step.Agent, Step: step.ID, Content: step.Name,
+			Data: map[string]interface{}{"duration_ms": duration.Milliseconds()}})
+	} else {
+		events.Emit(ctx, events.Event{Type: events.TypeStepFailed, Agent: step.Agent, Step: step.ID, Content: step.Name, Error: result.Error})
+	}
+
 	execution.SetStepState(step.ID, &StepState{
 		StepID:      step.ID,
 		Status:      status,
@@ -246,21 +555,49 @@ func (e *Executor) executeStep(ctx context.Context, execution *WorkflowExecution
 		Error:       result.Error,
 		Duration:    duration,
 		AgentUsed:   step.Agent,
-		RetryCount:  0,
+		RetryCount:  retryCount,
 	})
 
 	return result
 }
 
+// runStep 根据步骤类型执行
+func (e *Executor) runStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	switch step.Type {
+	case "task":
+		return e.executeTaskStep(ctx, execution, step)
+	case "condition":
+		return e.executeConditionStep(ctx, execution, step)
+	case "parallel":
+		return e.executeParallelStep(ctx, execution, step)
+	case "sequential":
+		return e.executeSequentialStep(ctx, execution, step)
+	case "consensus":
+		return e.executeConsensusStep(ctx, execution, step)
+	case "tool":
+		return e.executeToolStep(ctx, execution, step)
+	case "llm":
+		return e.executeLLMStep(ctx, execution, step)
+	case "gate":
+		return e.executeGateStep(ctx, execution, step)
+	default:
+		return e.executeTaskStep(ctx, execution, step)
+	}
+}
+
 // executeTaskStep 执行任务步骤
 func (e *Executor) executeTaskStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	if e.stepRunner != nil {
+		return e.stepRunner(ctx, step, stepInputs(execution, step))
+	}
+
 	// 查找合适的Agent
 	var agent *aiagentorchestrator.AgentInfo
 	var err error
 
 	if step.Agent != "" {
 		// 指定了Agent
-		agent, err = e.registry.Get(step.Agent)
+		agent, err = e.registry.GetForTenant(execution.Tenant, step.Agent)
 		if err != nil {
 			return nil, fmt.Errorf("agent %s not found: %w", step.Agent, err)
 		}
@@ -271,7 +608,7 @@ func (e *Executor) executeTaskStep(ctx context.Context, execution *WorkflowExecu
 		if step.Tool != "" {
 			capabilities = append(capabilities, step.Tool)
 		}
-		agent, err = e.registry.FindBestAgent(capabilities)
+		agent, err = e.registry.FindBestAgentFor(execution.Tenant, capabilities)
 		if err != nil {
 			return nil, fmt.Errorf("no suitable agent found: %w", err)
 		}
@@ -308,7 +645,7 @@ func (e *Executor) executeConditionStep(ctx context.Context, execution *Workflow
 
 	// 评估条件
 	for _, condition := range step.Conditions {
-		matched, err := e.evaluateCondition(ctx, execution, condition)
+		matched, err := e.evaluateCondition(ctx, execution, step, condition)
 		if err != nil {
 			return nil, err
 		}
@@ -341,8 +678,66 @@ func (e *Executor) executeSequentialStep(ctx context.Context, execution *Workflo
 	return fmt.Sprintf("Sequential step executed with %d sub-steps", len(step.DependsOn)), nil
 }
 
-// evaluateCondition 评估条件
-func (e *Executor) evaluateCondition(ctx context.Context, execution *WorkflowExecution, condition *Condition) (bool, error) {
+// executeConsensusStep 执行多模型共识步骤
+// 配置项：models（模型列表，至少2个）、mode（vote/judge）、judge（裁判模型）、prompt（支持{{输入名}}占位符），
+// 未设置prompt时使用prompt_template引用的提示词模板
+func (e *Executor) executeConsensusStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	if e.modelManager == nil {
+		return nil, fmt.Errorf("consensus step %s requires a model manager", step.ID)
+	}
+
+	modelNames := make([]string, 0)
+	switch v := step.Config["models"].(type) {
+	case []string:
+		modelNames = v
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				modelNames = append(modelNames, name)
+			}
+		}
+	}
+
+	mode, _ := step.Config["mode"].(string)
+	judge, _ := step.Config["judge"].(string)
+	prompt, err := e.stepPrompt(step, "prompt", "prompt_template")
+	if err != nil {
+		return nil, err
+	}
+	if prompt == "" {
+		return nil, fmt.Errorf("consensus step %s requires a prompt", step.ID)
+	}
+
+	// 用输入映射替换提示词中的占位符
+	for key, inputExpr := range step.Inputs {
+		if value, exists := execution.Inputs[inputExpr]; exists {
+			prompt = strings.ReplaceAll(prompt, "{{"+key+"}}", fmt.Sprintf("%v", value))
+		}
+	}
+
+	consensus, err := e.modelManager.NewConsensus(modelNames, llm.ConsensusMode(mode), judge)
+	if err != nil {
+		return nil, err
+	}
+	if step.Timeout > 0 {
+		consensus.SetTimeout(step.Timeout)
+	}
+
+	return consensus.Generate(ctx, []models.Message{
+		{Role: "user", Content: prompt},
+	})
+}
+
+// evaluateCondition 评估条件，表达式条件可以引用工作流输入、依赖步骤的输出和步骤的输入映射
+func (e *Executor) evaluateCondition(ctx context.Context, execution *WorkflowExecution, step *Step, condition *Condition) (bool, error) {
+	if condition.Expression != "" {
+		expr, err := parseExpression(condition.Expression)
+		if err != nil {
+			return false, fmt.Errorf("step %s: invalid condition expression: %w", step.ID, err)
+		}
+		return evalBool(expr, stepInputs(execution, step))
+	}
+
 	// 获取变量值
 	var varValue interface{}
 	if value, exists := execution.Inputs[condition.Variable]; exists {
@@ -476,4 +871,41 @@ type StepResult struct {
 	Success bool        `json:"success"`
 	Output  interface{} `json:"output"`
 	Error   string      `json:"error,omitempty"`
+
+	aborted bool // gate步骤中止了工作流
+}
+
+// remainingSteps 去掉已完成或已跳过的步骤
+func remainingSteps(execution *WorkflowExecution, stepIDs []string) []string {
+	remaining := make([]string, 0, len(stepIDs))
+	for _, stepID := range stepIDs {
+		if state := execution.GetStepState(stepID); state != nil &&
+			(state.Status == StepStatusCompleted || state.Status == StepStatusSkipped) {
+			continue
+		}
+		remaining = append(remaining, stepID)
+	}
+	return remaining
+}
+
+// stepInputs 步骤的输入：工作流输入，加上依赖步骤的输出（以步骤ID为键）
+// Inputs映射中的表达式可以是工作流输入名或步骤ID，映射后以映射的键名提供
+func stepInputs(execution *WorkflowExecution, step *Step) map[string]interface{} {
+	inputs := make(map[string]interface{}, len(execution.Inputs)+len(step.DependsOn)+len(step.Inputs))
+	for k, v := range execution.Inputs {
+		inputs[k] = v
+	}
+	for _, dep := range step.DependsOn {
+		if state := execution.GetStepState(dep); state != nil {
+			inputs[dep] = state.Output
+		}
+	}
+	for key, expr := range step.Inputs {
+		if value, ok := execution.Inputs[expr]; ok {
+			inputs[key] = value
+		} else if state := execution.GetStepState(expr); state != nil {
+			inputs[key] = state.Output
+		}
+	}
+	return inputs
 }
@@ -0,0 +1,561 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression 解析后的表达式，按变量求值
+type expression interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+// parseExpression 解析gate步骤、条件步骤和 {{ }} 模板中的表达式，支持：
+// 数字、'字符串' 或 "字符串"、true/false/null、变量路径（如 metrics.error_rate、steps.fetch.status），
+// 算术 + - * / %、比较 == != < <= > >=、逻辑 && || ! 、括号和内置函数调用（如 lower(status)，见TemplateFunctions）
+func parseExpression(src string) (expression, error) {
+	tokens, err := tokenizeExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return expr, nil
+}
+
+// evalBool 求值并按真值规则转换为布尔值
+func evalBool(expr expression, vars map[string]interface{}) (bool, error) {
+	value, err := expr.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprOperators 按长度从长到短匹配
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", ","}
+
+// tokenizeExpression 把表达式切分为记号
+func tokenizeExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: src[start:i], pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(src) && rune(src[i]) != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: tokenString, text: sb.String(), pos: start})
+		case isIdentByte(src[i]):
+			start := i
+			for i < len(src) && (isIdentByte(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: src[start:i], pos: start})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, exprToken{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+		}
+	}
+	return append(tokens, exprToken{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// isIdentByte 变量名中的字母、数字和下划线
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// exprParser 递归下降解析器，优先级从低到高：|| && 比较 加减 乘除 一元
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept 下一个记号是ops中的运算符时消费并返回
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "||", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (expression, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseComparison() (expression, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &compareExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseAdditive() (expression, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (expression, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expression, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return &literalExpr{value: value}, nil
+	case tokenString:
+		return &literalExpr{value: tok.text}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "null", "nil":
+			return &literalExpr{value: nil}, nil
+		}
+		if next := p.peek(); next.kind == tokenOperator && next.text == "(" {
+			return p.parseCall(tok)
+		}
+		return &variableExpr{path: tok.text}, nil
+	case tokenOperator:
+		if tok.text == "(" {
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ')' at offset %d", p.peek().pos)
+			}
+			return expr, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+// parseCall 解析函数调用的参数列表，函数名和参数个数在解析时检查
+func (p *exprParser) parseCall(name exprToken) (expression, error) {
+	fn, ok := lookupFunction(name.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	p.next() // (
+
+	var args []expression
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ')' at offset %d", p.peek().pos)
+			}
+			break
+		}
+	}
+	if err := fn.checkArity(len(args)); err != nil {
+		return nil, err
+	}
+	return &callExpr{fn: fn, args: args}, nil
+}
+
+// literalExpr 常量
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+// variableExpr 变量路径，不存在时为null
+type variableExpr struct {
+	path string
+}
+
+func (e *variableExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	value, _ := lookupInput(vars, e.path)
+	return value, nil
+}
+
+// callExpr 内置函数调用
+type callExpr struct {
+	fn   *TemplateFunction
+	args []expression
+}
+
+func (e *callExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	value, err := e.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.fn.Name, err)
+	}
+	return value, nil
+}
+
+// unaryExpr 逻辑非和取负
+type unaryExpr struct {
+	op      string
+	operand expression
+}
+
+func (e *unaryExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	value, err := e.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "!" {
+		return !truthy(value), nil
+	}
+	n, ok := numericValue(value)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %v", value)
+	}
+	return -n, nil
+}
+
+// logicalExpr && 和 ||，短路求值
+type logicalExpr struct {
+	op          string
+	left, right expression
+}
+
+func (e *logicalExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(e.left, vars)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "&&" && !left || e.op == "||" && left {
+		return left, nil
+	}
+	return evalBool(e.right, vars)
+}
+
+// compareExpr 比较：两边都是数字时按数值，都是字符串时按字典序；
+// 有一边为null（如指标不存在）时只有 == null 和 != 成立，大小比较一律为false
+type compareExpr struct {
+	op          string
+	left, right expression
+}
+
+func (e *compareExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := e.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op == "==" || e.op == "!=" {
+		return valuesEqual(left, right) == (e.op == "=="), nil
+	}
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	var cmp int
+	ln, lok := numericValue(left)
+	rn, rok := numericValue(right)
+	ls, lstr := left.(string)
+	rs, rstr := right.(string)
+	switch {
+	case lok && rok:
+		cmp = compareOrdered(ln, rn)
+	case lstr && rstr:
+		cmp = strings.Compare(ls, rs)
+	default:
+		return nil, fmt.Errorf("cannot compare %v %s %v", left, e.op, right)
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// arithmeticExpr 算术运算，+ 也可以连接两个字符串；除以0为错误
+type arithmeticExpr struct {
+	op          string
+	left, right expression
+}
+
+func (e *arithmeticExpr) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := e.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if ls, ok := left.(string); ok && e.op == "+" {
+		if rs, ok := right.(string); ok {
+			return ls + rs, nil
+		}
+	}
+	ln, lok := numericValue(left)
+	rn, rok := numericValue(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot compute %v %s %v", left, e.op, right)
+	}
+	switch e.op {
+	case "+":
+		return ln + rn, nil
+	case "-":
+		return ln - rn, nil
+	case "*":
+		return ln * rn, nil
+	}
+	if rn == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if e.op == "/" {
+		return ln / rn, nil
+	}
+	return float64(int64(ln) % int64(rn)), nil
+}
+
+// numericValue 数值类型转换为float64，字符串不做转换
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// valuesEqual 数值按大小比较，其他类型要求类型和值都相同
+func valuesEqual(a, b interface{}) bool {
+	if an, ok := numericValue(a); ok {
+		bn, ok := numericValue(b)
+		return ok && an == bn
+	}
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	}
+	return false
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// truthy 真值规则：null、false、0、空字符串、空列表和空对象为假
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	case map[string]interface{}:
+		return len(val) > 0
+	}
+	if n, ok := numericValue(v); ok {
+		return n != 0
+	}
+	return true
+}
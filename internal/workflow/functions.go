@@ -0,0 +1,510 @@
+package workflow
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模板函数的分类
+const (
+	FunctionCategoryJSON     = "json"
+	FunctionCategoryString   = "string"
+	FunctionCategoryDate     = "date"
+	FunctionCategoryRegex    = "regex"
+	FunctionCategoryEncoding = "encoding"
+)
+
+// TemplateFunction 工作流表达式中可调用的内置函数，用于步骤配置的 {{ }} 模板、条件步骤的expression和gate步骤的when
+// 参数按表达式求值：字符串参数接受任意值（null为空字符串，其他值按模板规则格式化），数字参数要求数值
+type TemplateFunction struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+	Example     string `json:"example"`
+
+	minArgs int // 最少参数个数
+	maxArgs int // 最多参数个数
+	call    func(args []interface{}) (interface{}, error)
+}
+
+// templateFunctions 内置函数，按名称索引
+var templateFunctions = map[string]*TemplateFunction{}
+
+func init() {
+	for _, fn := range builtinFunctions() {
+		templateFunctions[fn.Name] = fn
+	}
+}
+
+// TemplateFunctions 全部内置函数，按分类和名称排序，供 GET /workflows/functions 展示
+func TemplateFunctions() []TemplateFunction {
+	fns := make([]TemplateFunction, 0, len(templateFunctions))
+	for _, fn := range templateFunctions {
+		fns = append(fns, *fn)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].Category != fns[j].Category {
+			return fns[i].Category < fns[j].Category
+		}
+		return fns[i].Name < fns[j].Name
+	})
+	return fns
+}
+
+// lookupFunction 按名称查找内置函数
+func lookupFunction(name string) (*TemplateFunction, bool) {
+	fn, ok := templateFunctions[name]
+	return fn, ok
+}
+
+// checkArity 检查参数个数
+func (f *TemplateFunction) checkArity(n int) error {
+	switch {
+	case n >= f.minArgs && n <= f.maxArgs:
+		return nil
+	case f.minArgs == f.maxArgs:
+		return fmt.Errorf("%s expects %d arguments, got %d", f.Signature, f.minArgs, n)
+	}
+	return fmt.Errorf("%s expects %d to %d arguments, got %d", f.Signature, f.minArgs, f.maxArgs, n)
+}
+
+// builtinFunctions 内置函数列表
+func builtinFunctions() []*TemplateFunction {
+	return []*TemplateFunction{
+		// JSON
+		{
+			Name: "json_path", Category: FunctionCategoryJSON, Signature: "json_path(value, path)",
+			Description: "按路径提取字段，路径由点号分隔的字段名和 [下标] 组成，可以 $. 开头；路径不存在时为null",
+			Example:     "json_path(fetch, 'data.items[0].url')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				return jsonPath(args[0], stringArg(args[1]))
+			},
+		},
+		{
+			Name: "to_json", Category: FunctionCategoryJSON, Signature: "to_json(value)",
+			Description: "编码为JSON字符串",
+			Example:     "to_json(search.results)",
+			minArgs:     1, maxArgs: 1,
+			call: func(args []interface{}) (interface{}, error) {
+				data, err := json.Marshal(args[0])
+				if err != nil {
+					return nil, err
+				}
+				return string(data), nil
+			},
+		},
+		{
+			Name: "from_json", Category: FunctionCategoryJSON, Signature: "from_json(text)",
+			Description: "解析JSON字符串",
+			Example:     "json_path(from_json(judge), 'score') >= 8",
+			minArgs:     1, maxArgs: 1,
+			call: func(args []interface{}) (interface{}, error) {
+				var value interface{}
+				if err := json.Unmarshal([]byte(stringArg(args[0])), &value); err != nil {
+					return nil, err
+				}
+				return value, nil
+			},
+		},
+		{
+			Name: "len", Category: FunctionCategoryJSON, Signature: "len(value)",
+			Description: "字符串的字符数、列表的元素数或对象的字段数，null为0",
+			Example:     "len(search.results) > 0",
+			minArgs:     1, maxArgs: 1,
+			call: func(args []interface{}) (interface{}, error) {
+				value, err := jsonValue(args[0])
+				if err != nil {
+					return nil, err
+				}
+				switch v := value.(type) {
+				case nil:
+					return 0.0, nil
+				case string:
+					return float64(len([]rune(v))), nil
+				case []interface{}:
+					return float64(len(v)), nil
+				case map[string]interface{}:
+					return float64(len(v)), nil
+				}
+				return nil, fmt.Errorf("unsupported value %v", value)
+			},
+		},
+		{
+			Name: "default", Category: FunctionCategoryJSON, Signature: "default(value, fallback)",
+			Description: "value为null或空字符串时返回fallback",
+			Example:     "default(region, 'cn')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				if args[0] == nil || args[0] == "" {
+					return args[1], nil
+				}
+				return args[0], nil
+			},
+		},
+
+		// 字符串
+		stringFunction(FunctionCategoryString, "upper", "upper(s)", "转换为大写", "upper(region)", strings.ToUpper),
+		stringFunction(FunctionCategoryString, "lower", "lower(s)", "转换为小写", "lower(status) == 'ok'", strings.ToLower),
+		stringFunction(FunctionCategoryString, "trim", "trim(s)", "去掉首尾空白", "trim(answer)", strings.TrimSpace),
+		{
+			Name: "replace", Category: FunctionCategoryString, Signature: "replace(s, old, new)",
+			Description: "替换全部子串",
+			Example:     "replace(title, ' ', '-')",
+			minArgs:     3, maxArgs: 3,
+			call: func(args []interface{}) (interface{}, error) {
+				return strings.ReplaceAll(stringArg(args[0]), stringArg(args[1]), stringArg(args[2])), nil
+			},
+		},
+		{
+			Name: "contains", Category: FunctionCategoryString, Signature: "contains(s, substr)",
+			Description: "是否包含子串；s为列表时判断是否包含该元素",
+			Example:     "contains(tags, 'urgent')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				if list, err := jsonValue(args[0]); err == nil {
+					if items, ok := list.([]interface{}); ok {
+						for _, item := range items {
+							if valuesEqual(item, args[1]) {
+								return true, nil
+							}
+						}
+						return false, nil
+					}
+				}
+				return strings.Contains(stringArg(args[0]), stringArg(args[1])), nil
+			},
+		},
+		{
+			Name: "starts_with", Category: FunctionCategoryString, Signature: "starts_with(s, prefix)",
+			Description: "是否以prefix开头",
+			Example:     "starts_with(url, 'https://')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				return strings.HasPrefix(stringArg(args[0]), stringArg(args[1])), nil
+			},
+		},
+		{
+			Name: "ends_with", Category: FunctionCategoryString, Signature: "ends_with(s, suffix)",
+			Description: "是否以suffix结尾",
+			Example:     "ends_with(file, '.pdf')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				return strings.HasSuffix(stringArg(args[0]), stringArg(args[1])), nil
+			},
+		},
+		{
+			Name: "split", Category: FunctionCategoryString, Signature: "split(s, sep)",
+			Description: "按分隔符拆分为列表",
+			Example:     "split(keywords, ',')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				parts := strings.Split(stringArg(args[0]), stringArg(args[1]))
+				items := make([]interface{}, len(parts))
+				for i, part := range parts {
+					items[i] = part
+				}
+				return items, nil
+			},
+		},
+		{
+			Name: "join", Category: FunctionCategoryString, Signature: "join(list, sep)",
+			Description: "用分隔符连接列表的元素",
+			Example:     "join(sections, '、')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				value, err := jsonValue(args[0])
+				if err != nil {
+					return nil, err
+				}
+				items, ok := value.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("expects a list, got %v", args[0])
+				}
+				parts := make([]string, len(items))
+				for i, item := range items {
+					parts[i] = stringArg(item)
+				}
+				return strings.Join(parts, stringArg(args[1])), nil
+			},
+		},
+		{
+			Name: "substr", Category: FunctionCategoryString, Signature: "substr(s, start[, length])",
+			Description: "按字符截取子串，超出范围的部分忽略",
+			Example:     "substr(summary, 0, 200)",
+			minArgs:     2, maxArgs: 3,
+			call: func(args []interface{}) (interface{}, error) {
+				runes := []rune(stringArg(args[0]))
+				start, err := intArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				start = min(max(start, 0), len(runes))
+				end := len(runes)
+				if len(args) == 3 {
+					length, err := intArg(args[2])
+					if err != nil {
+						return nil, err
+					}
+					end = min(start+max(length, 0), len(runes))
+				}
+				return string(runes[start:end]), nil
+			},
+		},
+
+		// 日期
+		{
+			Name: "now", Category: FunctionCategoryDate, Signature: "now()",
+			Description: "当前UTC时间（RFC3339）",
+			Example:     "now()",
+			minArgs:     0, maxArgs: 0,
+			call: func(args []interface{}) (interface{}, error) {
+				return time.Now().UTC().Format(time.RFC3339), nil
+			},
+		},
+		{
+			Name: "date_add", Category: FunctionCategoryDate, Signature: "date_add(date, duration)",
+			Description: "时间加上时长，时长如 '2h30m'、'-7d'（支持d表示天），结果为RFC3339",
+			Example:     "date_add(now(), '-7d')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				t, err := parseDate(args[0])
+				if err != nil {
+					return nil, err
+				}
+				d, err := parseDays(stringArg(args[1]))
+				if err != nil {
+					return nil, err
+				}
+				return t.Add(d).Format(time.RFC3339), nil
+			},
+		},
+		{
+			Name: "date_diff", Category: FunctionCategoryDate, Signature: "date_diff(a, b)",
+			Description: "两个时间相差的秒数（a - b）",
+			Example:     "date_diff(now(), fetch.updated_at) > 86400",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				a, err := parseDate(args[0])
+				if err != nil {
+					return nil, err
+				}
+				b, err := parseDate(args[1])
+				if err != nil {
+					return nil, err
+				}
+				return a.Sub(b).Seconds(), nil
+			},
+		},
+		{
+			Name: "date_format", Category: FunctionCategoryDate, Signature: "date_format(date, layout)",
+			Description: "按Go的时间格式（如 '2006-01-02'）格式化时间",
+			Example:     "date_format(now(), '2006-01-02')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				t, err := parseDate(args[0])
+				if err != nil {
+					return nil, err
+				}
+				return t.Format(stringArg(args[1])), nil
+			},
+		},
+
+		// 正则
+		{
+			Name: "regex_match", Category: FunctionCategoryRegex, Signature: "regex_match(s, pattern)",
+			Description: "是否匹配正则表达式（RE2语法）",
+			Example:     "regex_match(email, '@example[.]com$')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				re, err := compileRegex(stringArg(args[1]))
+				if err != nil {
+					return nil, err
+				}
+				return re.MatchString(stringArg(args[0])), nil
+			},
+		},
+		{
+			Name: "regex_find", Category: FunctionCategoryRegex, Signature: "regex_find(s, pattern)",
+			Description: "第一个匹配，正则有分组时返回第一个分组；没有匹配时为null",
+			Example:     "regex_find(answer, 'score: ([0-9]+)')",
+			minArgs:     2, maxArgs: 2,
+			call: func(args []interface{}) (interface{}, error) {
+				re, err := compileRegex(stringArg(args[1]))
+				if err != nil {
+					return nil, err
+				}
+				m := re.FindStringSubmatch(stringArg(args[0]))
+				switch {
+				case m == nil:
+					return nil, nil
+				case len(m) > 1:
+					return m[1], nil
+				}
+				return m[0], nil
+			},
+		},
+		{
+			Name: "regex_replace", Category: FunctionCategoryRegex, Signature: "regex_replace(s, pattern, replacement)",
+			Description: "替换全部匹配，replacement中可用 $1 引用分组",
+			Example:     "regex_replace(phone, '[0-9]{4}$', '****')",
+			minArgs:     3, maxArgs: 3,
+			call: func(args []interface{}) (interface{}, error) {
+				re, err := compileRegex(stringArg(args[1]))
+				if err != nil {
+					return nil, err
+				}
+				return re.ReplaceAllString(stringArg(args[0]), stringArg(args[2])), nil
+			},
+		},
+
+		// 编码
+		stringFunction(FunctionCategoryEncoding, "base64_encode", "base64_encode(s)", "Base64编码（标准字母表，带填充）", "base64_encode(user + ':' + token)", func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		}),
+		{
+			Name: "base64_decode", Category: FunctionCategoryEncoding, Signature: "base64_decode(s)",
+			Description: "Base64解码，同时接受标准和URL字母表、带或不带填充",
+			Example:     "from_json(base64_decode(payload))",
+			minArgs:     1, maxArgs: 1,
+			call: func(args []interface{}) (interface{}, error) {
+				s := strings.TrimRight(stringArg(args[0]), "=")
+				for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+					if data, err := enc.DecodeString(s); err == nil {
+						return string(data), nil
+					}
+				}
+				return nil, fmt.Errorf("invalid base64 input")
+			},
+		},
+	}
+}
+
+// stringFunction 单个字符串参数、返回字符串的函数
+func stringFunction(category, name, signature, description, example string, fn func(string) string) *TemplateFunction {
+	return &TemplateFunction{
+		Name: name, Category: category, Signature: signature, Description: description, Example: example,
+		minArgs: 1, maxArgs: 1,
+		call: func(args []interface{}) (interface{}, error) {
+			return fn(stringArg(args[0])), nil
+		},
+	}
+}
+
+// stringArg 字符串参数：null为空字符串，其他值按模板规则格式化
+func stringArg(v interface{}) string {
+	return templateString(v)
+}
+
+// intArg 整数参数
+func intArg(v interface{}) (int, error) {
+	n, ok := numericValue(v)
+	if !ok {
+		return 0, fmt.Errorf("expects a number, got %v", v)
+	}
+	return int(n), nil
+}
+
+// jsonPathPattern json_path路径中的一段：字段名或 [下标]
+var jsonPathPattern = regexp.MustCompile(`^(?:\.?([^.\[\]]+)|\[(\d+)\])`)
+
+// jsonPath 按路径提取字段，路径不存在时返回nil
+func jsonPath(value interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for path != "" {
+		m := jsonPathPattern.FindStringSubmatch(path)
+		if m == nil {
+			return nil, fmt.Errorf("invalid path near %q", path)
+		}
+		path = path[len(m[0]):]
+
+		converted, err := jsonValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if m[2] != "" {
+			items, ok := converted.([]interface{})
+			i, _ := strconv.Atoi(m[2])
+			if !ok || i >= len(items) {
+				return nil, nil
+			}
+			value = items[i]
+			continue
+		}
+		fields, ok := converted.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		if value, ok = fields[m[1]]; !ok {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// dateLayouts date函数接受的时间格式
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseDate 解析时间参数：RFC3339、"2006-01-02 15:04:05"、"2006-01-02" 或Unix秒数
+func parseDate(v interface{}) (time.Time, error) {
+	if n, ok := numericValue(v); ok {
+		return time.Unix(int64(n), 0).UTC(), nil
+	}
+	s := stringArg(v)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseDays 解析时长，在time.ParseDuration的基础上支持以d结尾的天数，如 "7d"、"-1.5d"
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// maxCachedRegexes 缓存的正则表达式数上限，正则来自输入变量时不会无限增长
+const maxCachedRegexes = 256
+
+// regexCache 已编译的正则表达式，模板和条件在每次执行时求值，避免重复编译
+var regexCache = struct {
+	sync.Mutex
+	items map[string]*regexp.Regexp
+}{items: make(map[string]*regexp.Regexp)}
+
+// compileRegex 编译正则表达式，结果缓存
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if re, ok := regexCache.items[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	if len(regexCache.items) < maxCachedRegexes {
+		regexCache.items[pattern] = re
+	}
+	return re, nil
+}
@@ -0,0 +1,301 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// gate步骤的动作
+const (
+	GateActionContinue = "continue" // 继续执行后续步骤
+	GateActionRetry    = "retry"    // 重新执行规则列出的上游分支后再次判断
+	GateActionAbort    = "abort"    // 中止工作流
+)
+
+// defaultGateMaxRetries gate步骤未设置max_retries时最多重试分支的次数
+const defaultGateMaxRetries = 3
+
+// ErrGateAborted gate步骤中止了工作流，配置了continue_on_error时同样停止执行
+var ErrGateAborted = errors.New("workflow aborted by gate")
+
+// gateRule gate步骤的一条规则：when成立时执行action
+type gateRule struct {
+	when    string
+	expr    expression
+	action  string
+	retry   []string // action为retry时重新执行的步骤
+	message string
+}
+
+// gateConfig gate步骤的配置
+type gateConfig struct {
+	rules         []gateRule
+	defaultAction string // 没有规则成立时的动作，continue或abort
+	maxRetries    int
+}
+
+// parseGateConfig 解析gate步骤的配置：
+// rules 为规则列表，每条规则有 when（表达式）、action（continue/retry/abort）、retry（重试的步骤ID列表）和可选的 message；
+// default 为没有规则成立时的动作（默认continue），max_retries 为最多重试次数（默认3）
+func parseGateConfig(step *Step) (*gateConfig, error) {
+	cfg := &gateConfig{defaultAction: GateActionContinue, maxRetries: defaultGateMaxRetries}
+	if action, _ := step.Config["default"].(string); action != "" {
+		if action != GateActionContinue && action != GateActionAbort {
+			return nil, fmt.Errorf("gate step %s: default must be continue or abort, got %q", step.ID, action)
+		}
+		cfg.defaultAction = action
+	}
+	if v, ok := toFloat64(step.Config["max_retries"]); ok && v >= 0 {
+		cfg.maxRetries = int(v)
+	}
+
+	rawRules, _ := step.Config["rules"].([]interface{})
+	if len(rawRules) == 0 {
+		return nil, fmt.Errorf("gate step %s requires config.rules", step.ID)
+	}
+	for i, raw := range rawRules {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("gate step %s: rule %d must be an object", step.ID, i)
+		}
+		rule := gateRule{action: GateActionContinue}
+		rule.when, _ = m["when"].(string)
+		if rule.when == "" {
+			return nil, fmt.Errorf("gate step %s: rule %d requires when", step.ID, i)
+		}
+		expr, err := parseExpression(rule.when)
+		if err != nil {
+			return nil, fmt.Errorf("gate step %s: rule %d: invalid expression %q: %w", step.ID, i, rule.when, err)
+		}
+		rule.expr = expr
+		if action, _ := m["action"].(string); action != "" {
+			rule.action = action
+		}
+		rule.message, _ = m["message"].(string)
+
+		switch rule.action {
+		case GateActionContinue, GateActionAbort:
+		case GateActionRetry:
+			rule.retry = stringList(m["retry"])
+			if len(rule.retry) == 0 {
+				return nil, fmt.Errorf("gate step %s: rule %d: retry action requires retry steps", step.ID, i)
+			}
+		default:
+			return nil, fmt.Errorf("gate step %s: rule %d: unsupported action %q", step.ID, i, rule.action)
+		}
+		cfg.rules = append(cfg.rules, rule)
+	}
+	return cfg, nil
+}
+
+// stringList 字符串或字符串列表
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []string:
+		return val
+	case []interface{}:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// validateGateStep 校验gate步骤的规则，重试的步骤必须是gate的上游步骤
+func validateGateStep(w *Workflow, step *Step) error {
+	cfg, err := parseGateConfig(step)
+	if err != nil {
+		return err
+	}
+	upstream := upstreamSteps(w, step.ID)
+	for _, rule := range cfg.rules {
+		for _, id := range rule.retry {
+			if !upstream[id] {
+				return fmt.Errorf("gate step %s: retry step %s is not upstream of the gate", step.ID, id)
+			}
+		}
+	}
+	return nil
+}
+
+// upstreamSteps 直接或间接被stepID依赖的步骤
+func upstreamSteps(w *Workflow, stepID string) map[string]bool {
+	deps := make(map[string][]string, len(w.Steps))
+	for _, s := range w.Steps {
+		deps[s.ID] = s.DependsOn
+	}
+	upstream := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		for _, dep := range deps[id] {
+			if !upstream[dep] {
+				upstream[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	visit(stepID)
+	return upstream
+}
+
+// executeGateStep 执行gate步骤：按顺序计算规则的表达式，第一条成立的规则决定继续、重试分支或中止；
+// 重试时重新执行规则列出的步骤及其与gate之间的步骤，然后重新判断，最多重试max_retries次，用完后中止工作流。
+// 表达式可使用的变量见gateVariables
+func (e *Executor) executeGateStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	cfg, err := parseGateConfig(step)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := 0
+	for {
+		vars := e.gateVariables(ctx, execution, step)
+		action, matched := cfg.defaultAction, (*gateRule)(nil)
+		for i := range cfg.rules {
+			ok, err := evalBool(cfg.rules[i].expr, vars)
+			if err != nil {
+				return nil, fmt.Errorf("gate step %s: rule %q: %w", step.ID, cfg.rules[i].when, err)
+			}
+			if ok {
+				matched = &cfg.rules[i]
+				action = matched.action
+				break
+			}
+		}
+
+		switch action {
+		case GateActionContinue:
+			output := map[string]interface{}{"action": action, "retries": retries}
+			if matched != nil {
+				output["rule"] = matched.when
+			}
+			return output, nil
+		case GateActionAbort:
+			reason := "no rule matched"
+			if matched != nil {
+				reason = matched.message
+				if reason == "" {
+					reason = matched.when
+				}
+			}
+			return nil, fmt.Errorf("%w %s: %s", ErrGateAborted, step.ID, reason)
+		}
+
+		if retries >= cfg.maxRetries {
+			return nil, fmt.Errorf("%w %s: %q still holds after %d retries", ErrGateAborted, step.ID, matched.when, retries)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		retries++
+		if err := e.rerunBranch(ctx, execution, step, matched.retry); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rerunBranch 按DAG层级重新执行重试的步骤，以及依赖它们的gate上游步骤
+func (e *Executor) rerunBranch(ctx context.Context, execution *WorkflowExecution, gate *Step, targets []string) error {
+	dag, err := BuildDAGFromWorkflow(execution.Workflow)
+	if err != nil {
+		return err
+	}
+	upstream := upstreamSteps(execution.Workflow, gate.ID)
+	branch := make(map[string]bool, len(targets))
+	for _, id := range targets {
+		branch[id] = true
+	}
+	for _, level := range dag.GetLevels() {
+		var rerun []string
+		for _, id := range level {
+			if !upstream[id] {
+				continue
+			}
+			if !branch[id] {
+				for _, dep := range execution.Workflow.GetStep(id).DependsOn {
+					if branch[dep] {
+						branch[id] = true
+						break
+					}
+				}
+			}
+			if branch[id] {
+				rerun = append(rerun, id)
+			}
+		}
+		if len(rerun) > 0 {
+			e.executeLevel(ctx, execution, dag, rerun)
+		}
+	}
+	return nil
+}
+
+// gateVariables gate表达式的变量：步骤输入（见stepInputs），以及
+//   - steps.<ID>.status / error / duration_ms / retry_count / output：各步骤的当前状态
+//   - metrics.steps_total / steps_completed / steps_failed / steps_finished / error_rate（失败数/已结束数）/ retries
+//   - metrics.elapsed_ms，有超时限制时 metrics.remaining_ms
+//   - 设置了监控器时 metrics.error_count、metrics.warning_count 和 metrics.custom.<名称>（自定义指标）
+func (e *Executor) gateVariables(ctx context.Context, execution *WorkflowExecution, gate *Step) map[string]interface{} {
+	steps := make(map[string]interface{}, len(execution.Workflow.Steps))
+	var completed, failed, retries int
+	for _, s := range execution.Workflow.Steps {
+		if s.ID == gate.ID {
+			continue
+		}
+		entry := map[string]interface{}{"status": string(StepStatusPending)}
+		if state := execution.GetStepState(s.ID); state != nil {
+			entry = map[string]interface{}{
+				"status":      string(state.Status),
+				"error":       state.Error,
+				"duration_ms": float64(state.Duration.Milliseconds()),
+				"retry_count": float64(state.RetryCount),
+				"output":      state.Output,
+			}
+			switch state.Status {
+			case StepStatusCompleted:
+				completed++
+			case StepStatusFailed:
+				failed++
+			}
+			retries += state.RetryCount
+		}
+		steps[s.ID] = entry
+	}
+
+	metrics := map[string]interface{}{
+		"steps_total":     float64(len(steps)),
+		"steps_completed": float64(completed),
+		"steps_failed":    float64(failed),
+		"steps_finished":  float64(completed + failed),
+		"error_rate":      0.0,
+		"retries":         float64(retries),
+		"elapsed_ms":      float64(time.Since(execution.StartedAt).Milliseconds()),
+	}
+	if completed+failed > 0 {
+		metrics["error_rate"] = float64(failed) / float64(completed+failed)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		metrics["remaining_ms"] = float64(time.Until(deadline).Milliseconds())
+	}
+	if e.monitor != nil {
+		for k, v := range e.monitor.gateMetrics(execution.ID) {
+			metrics[k] = v
+		}
+	}
+
+	vars := stepInputs(execution, gate)
+	vars["steps"] = steps
+	vars["metrics"] = metrics
+	return vars
+}
@@ -0,0 +1,166 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PromptTemplate 命名的提示词模板
+// llm步骤通过 config.prompt_template、config.system_template 引用，consensus步骤通过 config.prompt_template 引用；
+// 模板中的 {{...}} 与直接写在步骤配置中的提示词一样由步骤输入渲染
+type PromptTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Template    string `json:"template"`
+}
+
+// ToolChainDefinition 命名的工具链定义，tool步骤通过 config.chain 引用
+type ToolChainDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Steps       []ToolChainStep `json:"steps"`
+}
+
+// ToolChainStep 工具链中的一步
+type ToolChainStep struct {
+	Tool      string                 `json:"tool"`
+	Operation string                 `json:"operation"`
+	Params    map[string]interface{} `json:"params,omitempty"`     // 支持 {{名称}} 占位符，由步骤输入渲染
+	InputFrom string                 `json:"input_from,omitempty"` // 非空时把上一步的输出作为参数input
+}
+
+// validate 校验提示词模板的名称和内容
+func (p *PromptTemplate) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("prompt template name is required")
+	}
+	if p.Template == "" {
+		return fmt.Errorf("prompt template %s: template is required", p.Name)
+	}
+	return nil
+}
+
+// validate 校验工具链的名称和每一步的工具、操作
+func (c *ToolChainDefinition) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("tool chain name is required")
+	}
+	if len(c.Steps) == 0 {
+		return fmt.Errorf("tool chain %s: at least one step is required", c.Name)
+	}
+	for i, step := range c.Steps {
+		if step.Tool == "" || step.Operation == "" {
+			return fmt.Errorf("tool chain %s: step %d requires tool and operation", c.Name, i)
+		}
+	}
+	return nil
+}
+
+// library 按名称索引的并发安全的定义库
+type library[T any] struct {
+	mu    sync.RWMutex
+	items map[string]T
+}
+
+// Get 按名称获取
+func (l *library[T]) Get(name string) (T, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	item, ok := l.items[name]
+	return item, ok
+}
+
+// Put 添加或替换
+func (l *library[T]) Put(name string, item T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.items == nil {
+		l.items = make(map[string]T)
+	}
+	l.items[name] = item
+}
+
+// Delete 按名称删除
+func (l *library[T]) Delete(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.items, name)
+}
+
+// List 按名称排序列出
+func (l *library[T]) List() []T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.items))
+	for name := range l.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]T, 0, len(names))
+	for _, name := range names {
+		items = append(items, l.items[name])
+	}
+	return items
+}
+
+// PromptLibrary 提示词模板库
+type PromptLibrary = library[*PromptTemplate]
+
+// ToolChainLibrary 工具链定义库
+type ToolChainLibrary = library[*ToolChainDefinition]
+
+// Prompts 执行器使用的提示词模板库
+func (e *Executor) Prompts() *PromptLibrary {
+	return e.prompts
+}
+
+// ToolChains 执行器使用的工具链定义库
+func (e *Executor) ToolChains() *ToolChainLibrary {
+	return e.chains
+}
+
+// stepPrompt 步骤的提示词：优先使用config中key的内容，为空时使用templateKey引用的提示词模板；都未设置时返回空字符串
+func (e *Executor) stepPrompt(step *Step, key, templateKey string) (string, error) {
+	if prompt, _ := step.Config[key].(string); prompt != "" {
+		return prompt, nil
+	}
+	name, _ := step.Config[templateKey].(string)
+	if name == "" {
+		return "", nil
+	}
+	tmpl, ok := e.prompts.Get(name)
+	if !ok {
+		return "", fmt.Errorf("step %s: prompt template %s not found", step.ID, name)
+	}
+	return tmpl.Template, nil
+}
+
+// executeToolChain 按顺序执行config.chain引用的工具链：每一步的参数由步骤输入渲染，
+// 声明了input_from的步骤以上一步的输出（第一步为渲染后的config.input）作为参数input；返回最后一步的输出
+func (e *Executor) executeToolChain(ctx context.Context, step *Step, name string, inputs map[string]interface{}) (interface{}, error) {
+	chain, ok := e.chains.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("tool step %s: tool chain %s not found", step.ID, name)
+	}
+
+	current := renderTemplate(step.Config["input"], inputs)
+	for i, chainStep := range chain.Steps {
+		params := make(map[string]interface{}, len(chainStep.Params)+1)
+		if len(chainStep.Params) > 0 {
+			params = renderTemplate(chainStep.Params, inputs).(map[string]interface{})
+		}
+		if chainStep.InputFrom != "" && current != nil {
+			params["input"] = current
+		}
+		result, err := e.tools.ExecuteTool(ctx, chainStep.Tool, chainStep.Operation, params)
+		if err != nil {
+			return nil, fmt.Errorf("tool chain %s step %d (%s %s) failed: %w", name, i, chainStep.Tool, chainStep.Operation, err)
+		}
+		if current, err = toolOutput(chainStep.Tool, chainStep.Operation, result); err != nil {
+			return nil, fmt.Errorf("tool chain %s step %d: %w", name, i, err)
+		}
+	}
+	return current, nil
+}
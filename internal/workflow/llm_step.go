@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ai-agent-assistant/internal/llm"
+	"ai-agent-assistant/pkg/models"
+)
+
+// executeLLMStep 执行模型调用步骤：用步骤输入替换config.prompt（和可选的config.system）中的 {{名称}} 后调用config.model，
+// 补全文本作为步骤输出；config.format 为 json 时把补全解析为JSON（可去掉```json代码块），解析失败时步骤失败。
+// 可选的 temperature、top_p、max_tokens 覆盖模型的默认生成参数；未设置prompt、system时分别使用
+// config.prompt_template、config.system_template 引用的提示词模板
+func (e *Executor) executeLLMStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	if e.modelManager == nil {
+		return nil, fmt.Errorf("llm step %s requires a model manager", step.ID)
+	}
+	modelName, _ := step.Config["model"].(string)
+	prompt, err := e.stepPrompt(step, "prompt", "prompt_template")
+	if err != nil {
+		return nil, err
+	}
+	system, err := e.stepPrompt(step, "system", "system_template")
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" || prompt == "" {
+		return nil, fmt.Errorf("llm step %s requires config.model and config.prompt", step.ID)
+	}
+	model, err := e.modelManager.GetModel(modelName)
+	if err != nil {
+		return nil, fmt.Errorf("llm step %s: %w", step.ID, err)
+	}
+
+	inputs := stepInputs(execution, step)
+	messages := make([]models.Message, 0, 2)
+	if system != "" {
+		messages = append(messages, models.Message{Role: "system", Content: renderString(system, inputs)})
+	}
+	messages = append(messages, models.Message{Role: "user", Content: renderString(prompt, inputs)})
+
+	var opts llm.GenerationOptions
+	if v, ok := toFloat64(step.Config["temperature"]); ok {
+		opts.Temperature = &v
+	}
+	if v, ok := toFloat64(step.Config["top_p"]); ok {
+		opts.TopP = &v
+	}
+	if v, ok := toFloat64(step.Config["max_tokens"]); ok {
+		opts.MaxTokens = int(v)
+	}
+	ctx = llm.WithGenerationOptions(ctx, opts)
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	completion, err := model.Chat(ctx, messages)
+	if err != nil {
+		return nil, fmt.Errorf("llm step %s: %w", step.ID, err)
+	}
+	if format, _ := step.Config["format"].(string); format == "json" {
+		value, err := parseJSONCompletion(completion)
+		if err != nil {
+			return nil, fmt.Errorf("llm step %s: completion is not valid JSON: %w", step.ID, err)
+		}
+		return value, nil
+	}
+	return completion, nil
+}
+
+// parseJSONCompletion 解析模型返回的JSON，容忍```json代码块和前后的说明文字
+func parseJSONCompletion(completion string) (interface{}, error) {
+	text := strings.TrimSpace(completion)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	var value interface{}
+	err := json.Unmarshal([]byte(text), &value)
+	if err == nil {
+		return value, nil
+	}
+	// 前后有说明文字时取第一个 { 或 [ 到最后一个 } 或 ] 之间的部分
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start < 0 || end <= start {
+		return nil, err
+	}
+	if jsonErr := json.Unmarshal([]byte(text[start:end+1]), &value); jsonErr != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -1,14 +1,42 @@
 package workflow
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
 	aiagenttask "ai-agent-assistant/internal/task"
 )
 
+// 监控器默认参数，可通过 workflows.monitor 配置段覆盖
+const (
+	defaultMetricsRetention = 24 * time.Hour
+	defaultEventBufferSize  = 1000
+	defaultCollectInterval  = time.Minute
+	defaultCleanupInterval  = time.Hour
+	defaultBlockTimeout     = 100 * time.Millisecond
+	defaultSpillPath        = "./data/monitor-spill.jsonl"
+	defaultSampleRate       = 0.1
+)
+
+// 事件缓冲区满时的处理方式
+const (
+	OverflowDrop   = "drop"   // 丢弃新事件
+	OverflowBlock  = "block"  // 等待缓冲区空位，超时后丢弃
+	OverflowSpill  = "spill"  // 写入磁盘文件，缓冲区清空后按顺序补发
+	OverflowSample = "sample" // 缓冲区过半后按比例采样，仍然放不下时丢弃
+)
+
 // Monitor 工作流监控器
 // 负责收集工作流执行指标、跟踪状态、记录性能数据
 type Monitor struct {
@@ -20,8 +48,25 @@ type Monitor struct {
 	eventChannel      chan *MonitorEvent                   // 事件通道
 	eventBufferSize   int                                  // 事件缓冲区大小
 	collectInterval   time.Duration                        // 指标收集间隔
+	cleanupInterval   time.Duration                        // 过期指标清理间隔
 	stopChan          chan struct{}                        // 停止信号
 	listeners         []MonitorListener                    // 监听器列表
+	clock             clock.Clock                          // 事件时间、执行时长和过期判断
+	runningSteps      map[string]int                       // agent -> 正在执行的步骤数
+
+	overflow     string        // 缓冲区满时的处理方式
+	blockTimeout time.Duration // block策略的最长等待时间
+	spillPath    string        // spill策略的溢出文件
+	sampleEvery  int64         // sample策略下每N个事件保留1个
+	spillMu      sync.Mutex    // 保护溢出文件的写入和回放
+	spillFile    *os.File
+
+	sampleSeq  atomic.Int64
+	published  atomic.Int64 // 进入缓冲区的事件数
+	dropped    atomic.Int64 // 丢弃的事件数
+	spilled    atomic.Int64 // 写入溢出文件的事件数
+	replayed   atomic.Int64 // 从溢出文件补发的事件数
+	sampledOut atomic.Int64 // 采样时舍弃的事件数
 }
 
 // WorkflowExecutionMetrics 工作流执行指标
@@ -100,17 +145,65 @@ type MonitorListener interface {
 
 // NewMonitor 创建新的监控器
 func NewMonitor() *Monitor {
+	return newMonitor(defaultMetricsRetention, defaultEventBufferSize, defaultCollectInterval, defaultCleanupInterval)
+}
+
+// NewMonitorFromConfig 根据配置创建监控器，未设置或无效的配置项使用默认值
+func NewMonitorFromConfig(cfg config.WorkflowMonitorConfig) *Monitor {
+	retention := positiveDuration(cfg.Retention, defaultMetricsRetention)
+	bufferSize := defaultEventBufferSize
+	if cfg.EventBufferSize > 0 {
+		bufferSize = cfg.EventBufferSize
+	}
+	collect := positiveDuration(cfg.CollectInterval, defaultCollectInterval)
+	cleanup := positiveDuration(cfg.CleanupInterval, defaultCleanupInterval)
+	m := newMonitor(retention, bufferSize, collect, cleanup)
+
+	if cfg.Overflow != "" {
+		m.overflow = cfg.Overflow
+	}
+	m.blockTimeout = positiveDuration(cfg.BlockTimeout, defaultBlockTimeout)
+	if cfg.SpillPath != "" {
+		m.spillPath = cfg.SpillPath
+	}
+	if cfg.SampleRate > 0 && cfg.SampleRate <= 1 {
+		m.sampleEvery = int64(math.Round(1 / cfg.SampleRate))
+	}
+	return m
+}
+
+func newMonitor(retention time.Duration, bufferSize int, collect, cleanup time.Duration) *Monitor {
 	return &Monitor{
 		executions:       make(map[string]*WorkflowExecutionMetrics),
 		agentMetrics:     make(map[string]*AgentMetrics),
+		runningSteps:     make(map[string]int),
 		enabled:          true,
-		metricsRetention: 24 * time.Hour, // 默认保留24小时
-		eventChannel:     make(chan *MonitorEvent, 1000),
-		eventBufferSize:  1000,
-		collectInterval:  1 * time.Minute,
+		metricsRetention: retention,
+		eventChannel:     make(chan *MonitorEvent, bufferSize),
+		eventBufferSize:  bufferSize,
+		collectInterval:  collect,
+		cleanupInterval:  cleanup,
 		stopChan:         make(chan struct{}),
 		listeners:        make([]MonitorListener, 0),
+		overflow:         OverflowDrop,
+		blockTimeout:     defaultBlockTimeout,
+		spillPath:        defaultSpillPath,
+		sampleEvery:      int64(math.Round(1 / defaultSampleRate)),
+		clock:            clock.System,
+	}
+}
+
+// SetClock 设置时钟，需在Start和记录事件之前调用；测试和模拟中使用clock.Fake使时长和过期清理可复现
+func (m *Monitor) SetClock(c clock.Clock) {
+	m.clock = clock.OrSystem(c)
+}
+
+// positiveDuration 解析配置中的时长，为空或无效时返回默认值
+func positiveDuration(value string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
 	}
+	return fallback
 }
 
 // Start 启动监控器
@@ -139,6 +232,13 @@ func (m *Monitor) Stop() {
 	m.enabled = false
 	close(m.stopChan)
 	close(m.eventChannel)
+
+	m.spillMu.Lock()
+	if m.spillFile != nil {
+		m.spillFile.Close()
+		m.spillFile = nil
+	}
+	m.spillMu.Unlock()
 }
 
 // RecordWorkflowStart 记录工作流开始
@@ -154,7 +254,7 @@ func (m *Monitor) RecordWorkflowStart(executionID, workflowID string) *WorkflowE
 		ExecutionID:    executionID,
 		WorkflowID:     workflowID,
 		Status:         "running",
-		StartTime:      time.Now(),
+		StartTime:      m.clock.Now(),
 		StepMetrics:    make(map[string]*StepMetrics),
 		AgentUsage:     make(map[string]int),
 		CustomMetrics:  make(map[string]interface{}),
@@ -166,7 +266,7 @@ func (m *Monitor) RecordWorkflowStart(executionID, workflowID string) *WorkflowE
 	// 发送事件
 	m.publishEvent(&MonitorEvent{
 		Type:        "workflow_started",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  workflowID,
 		Data: map[string]interface{}{
@@ -192,7 +292,7 @@ func (m *Monitor) RecordWorkflowEnd(executionID string, status string, err error
 	}
 
 	metrics.Status = status
-	metrics.EndTime = time.Now()
+	metrics.EndTime = m.clock.Now()
 	metrics.Duration = metrics.EndTime.Sub(metrics.StartTime)
 
 	if err != nil {
@@ -212,7 +312,7 @@ func (m *Monitor) RecordWorkflowEnd(executionID string, status string, err error
 
 	m.publishEvent(&MonitorEvent{
 		Type:        "workflow_completed",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  metrics.WorkflowID,
 		Data:        eventData,
@@ -239,11 +339,15 @@ func (m *Monitor) RecordStepStart(executionID, stepID, agent string) {
 	stepMetrics := &StepMetrics{
 		StepID:    stepID,
 		Agent:     agent,
-		StartTime: time.Now(),
+		StartTime: m.clock.Now(),
 		Status:    "running",
 	}
 
+	if prev, ok := metrics.StepMetrics[stepID]; ok && prev.Status == "running" {
+		m.runningSteps[prev.Agent]--
+	}
 	metrics.StepMetrics[stepID] = stepMetrics
+	m.runningSteps[agent]++
 
 	// 更新Agent使用统计
 	metrics.AgentUsage[agent]++
@@ -251,7 +355,7 @@ func (m *Monitor) RecordStepStart(executionID, stepID, agent string) {
 	// 发送事件
 	m.publishEvent(&MonitorEvent{
 		Type:        "step_started",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  metrics.WorkflowID,
 		StepID:      stepID,
@@ -281,7 +385,10 @@ func (m *Monitor) RecordStepEnd(executionID, stepID string, status string, resul
 		return
 	}
 
-	stepMetrics.EndTime = time.Now()
+	if stepMetrics.Status == "running" {
+		m.runningSteps[stepMetrics.Agent]--
+	}
+	stepMetrics.EndTime = m.clock.Now()
 	stepMetrics.Duration = stepMetrics.EndTime.Sub(stepMetrics.StartTime)
 	stepMetrics.Status = status
 	stepMetrics.InputSize = inputSize
@@ -290,7 +397,7 @@ func (m *Monitor) RecordStepEnd(executionID, stepID string, status string, resul
 
 	if result != nil && result.Error != "" {
 		// 将 string 类型的 error 转换为 error 类型
-		stepMetrics.Error = fmt.Errorf(result.Error)
+		stepMetrics.Error = fmt.Errorf("%s", result.Error)
 	}
 
 	// 计算性能评分（基于执行时间和成功率）
@@ -316,7 +423,7 @@ func (m *Monitor) RecordStepEnd(executionID, stepID string, status string, resul
 
 	m.publishEvent(&MonitorEvent{
 		Type:        "step_completed",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  metrics.WorkflowID,
 		StepID:      stepID,
@@ -344,7 +451,7 @@ func (m *Monitor) RecordError(executionID, stepID string, err error) {
 	// 发送事件
 	m.publishEvent(&MonitorEvent{
 		Type:        "error",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  metrics.WorkflowID,
 		StepID:      stepID,
@@ -373,7 +480,7 @@ func (m *Monitor) RecordWarning(executionID, stepID string, warning string) {
 	// 发送事件
 	m.publishEvent(&MonitorEvent{
 		Type:        "warning",
-		Timestamp:   time.Now(),
+		Timestamp:   m.clock.Now(),
 		ExecutionID: executionID,
 		WorkflowID:  metrics.WorkflowID,
 		StepID:      stepID,
@@ -430,6 +537,26 @@ func (m *Monitor) GetExecutionMetrics(executionID string) (*WorkflowExecutionMet
 	return metrics, nil
 }
 
+// gateMetrics 在锁内复制执行的错误数、警告数和自定义指标，供gate步骤的表达式使用；没有该执行的指标时返回nil
+func (m *Monitor) gateMetrics(executionID string) map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	metrics, exists := m.executions[executionID]
+	if !exists {
+		return nil
+	}
+	custom := make(map[string]interface{}, len(metrics.CustomMetrics))
+	for k, v := range metrics.CustomMetrics {
+		custom[k] = v
+	}
+	return map[string]interface{}{
+		"error_count":   float64(metrics.ErrorCount),
+		"warning_count": float64(metrics.WarningCount),
+		"custom":        custom,
+	}
+}
+
 // GetAllExecutions 获取所有执行记录
 func (m *Monitor) GetAllExecutions() []*WorkflowExecutionMetrics {
 	m.mu.RLock()
@@ -477,7 +604,7 @@ func (m *Monitor) GetPerformanceReport(workflowID string) *PerformanceReport {
 
 	report := &PerformanceReport{
 		WorkflowID:    workflowID,
-		GeneratedAt:   time.Now(),
+		GeneratedAt:   m.clock.Now(),
 		Executions:    make([]*WorkflowExecutionMetrics, 0),
 		AgentSummary:  make(map[string]*AgentMetrics),
 	}
@@ -570,7 +697,7 @@ func (m *Monitor) updateAgentMetrics(agentID string, step *StepMetrics) {
 	}
 
 	agentMetrics.TotalExecutions++
-	agentMetrics.LastUsed = time.Now()
+	agentMetrics.LastUsed = m.clock.Now()
 
 	if step.Status == "completed" {
 		agentMetrics.SuccessExecutions++
@@ -596,6 +723,22 @@ func (m *Monitor) updateAgentMetrics(agentID string, step *StepMetrics) {
 	agentMetrics.PerformanceScore = m.calculateAgentPerformanceScore(agentMetrics)
 }
 
+// AgentStats 实现 orchestrator.AgentStatsProvider：Agent正在执行的步骤数、历史执行次数、失败次数和平均时长，
+// 设置为注册表的统计来源后FindBestAgent可按负载和历史表现选择Agent
+func (m *Monitor) AgentStats(agentName string) (aiagentorchestrator.AgentStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := aiagentorchestrator.AgentStats{Running: m.runningSteps[agentName]}
+	metrics, exists := m.agentMetrics[agentName]
+	if exists {
+		stats.Executions = metrics.TotalExecutions
+		stats.Failures = metrics.FailedExecutions
+		stats.AvgLatency = metrics.AverageDuration
+	}
+	return stats, exists || stats.Running > 0
+}
+
 // calculateAgentPerformanceScore 计算Agent性能评分
 func (m *Monitor) calculateAgentPerformanceScore(metrics *AgentMetrics) float64 {
 	if metrics.TotalExecutions == 0 {
@@ -645,13 +788,106 @@ func (m *Monitor) RemoveListener(listener MonitorListener) {
 	}
 }
 
-// publishEvent 发布事件
+// publishEvent 发布事件，缓冲区满时按overflow策略处理，结果计入GetStats中的计数
 func (m *Monitor) publishEvent(event *MonitorEvent) {
+	if m.overflow == OverflowSample && len(m.eventChannel)*2 >= cap(m.eventChannel) {
+		if m.sampleSeq.Add(1)%m.sampleEvery != 0 {
+			m.sampledOut.Add(1)
+			return
+		}
+	}
+
 	select {
 	case m.eventChannel <- event:
+		m.published.Add(1)
+		return
 	default:
-		// 事件通道已满，丢弃事件
-		fmt.Printf("警告: 监控事件通道已满，丢弃事件: %s\n", event.Type)
+	}
+
+	switch m.overflow {
+	case OverflowBlock:
+		timer := time.NewTimer(m.blockTimeout)
+		defer timer.Stop()
+		select {
+		case m.eventChannel <- event:
+			m.published.Add(1)
+			return
+		case <-timer.C:
+		}
+	case OverflowSpill:
+		err := m.spillEvent(event)
+		if err == nil {
+			m.spilled.Add(1)
+			return
+		}
+		fmt.Printf("警告: 监控事件写入溢出文件失败: %v\n", err)
+	}
+
+	// 只在第一次和每1000次丢弃时打印，避免积压时刷屏
+	if n := m.dropped.Add(1); n == 1 || n%1000 == 0 {
+		fmt.Printf("警告: 监控事件通道已满，累计丢弃%d个事件（最近: %s）\n", n, event.Type)
+	}
+}
+
+// spillEvent 把事件追加到溢出文件
+func (m *Monitor) spillEvent(event *MonitorEvent) error {
+	m.spillMu.Lock()
+	defer m.spillMu.Unlock()
+
+	if m.spillFile == nil {
+		if err := os.MkdirAll(filepath.Dir(m.spillPath), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(m.spillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		m.spillFile = f
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = m.spillFile.Write(append(data, '\n'))
+	return err
+}
+
+// replaySpilled 缓冲区清空后补发溢出文件中的事件
+// 先把文件改名再读取，补发期间新的溢出事件写入新文件
+func (m *Monitor) replaySpilled() {
+	m.spillMu.Lock()
+	if m.spillFile == nil {
+		m.spillMu.Unlock()
+		return
+	}
+	m.spillFile.Close()
+	m.spillFile = nil
+	replayPath := m.spillPath + ".replay"
+	err := os.Rename(m.spillPath, replayPath)
+	m.spillMu.Unlock()
+	if err != nil {
+		fmt.Printf("警告: 读取监控事件溢出文件失败: %v\n", err)
+		return
+	}
+	defer os.Remove(replayPath)
+
+	f, err := os.Open(replayPath)
+	if err != nil {
+		fmt.Printf("警告: 读取监控事件溢出文件失败: %v\n", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event MonitorEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			m.dropped.Add(1)
+			continue
+		}
+		m.handleEvent(&event)
+		m.replayed.Add(1)
 	}
 }
 
@@ -663,8 +899,14 @@ func (m *Monitor) processEvents(ctx context.Context) {
 			return
 		case <-m.stopChan:
 			return
-		case event := <-m.eventChannel:
+		case event, ok := <-m.eventChannel:
+			if !ok {
+				return
+			}
 			m.handleEvent(event)
+			if m.overflow == OverflowSpill && len(m.eventChannel) == 0 {
+				m.replaySpilled()
+			}
 		}
 	}
 }
@@ -717,7 +959,7 @@ func (m *Monitor) collectSystemMetrics() {
 
 // cleanupOldMetrics 清理旧指标
 func (m *Monitor) cleanupOldMetrics(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Hour)
+	ticker := time.NewTicker(m.cleanupInterval)
 	defer ticker.Stop()
 
 	for {
@@ -737,7 +979,7 @@ func (m *Monitor) removeExpiredMetrics() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	now := time.Now()
+	now := m.clock.Now()
 	for id, metrics := range m.executions {
 		if now.Sub(metrics.StartTime) > m.metricsRetention {
 			delete(m.executions, id)
@@ -767,10 +1009,17 @@ func (m *Monitor) GetStats() map[string]interface{} {
 	defer m.mu.RUnlock()
 
 	return map[string]interface{}{
-		"enabled":           m.enabled,
-		"executions_count":  len(m.executions),
-		"agents_count":      len(m.agentMetrics),
-		"event_buffer_size": len(m.eventChannel),
-		"listeners_count":   len(m.listeners),
+		"enabled":            m.enabled,
+		"executions_count":   len(m.executions),
+		"agents_count":       len(m.agentMetrics),
+		"event_buffer_size":  len(m.eventChannel),
+		"event_buffer_cap":   cap(m.eventChannel),
+		"listeners_count":    len(m.listeners),
+		"overflow":           m.overflow,
+		"events_published":   m.published.Load(),
+		"events_dropped":     m.dropped.Load(),
+		"events_spilled":     m.spilled.Load(),
+		"events_replayed":    m.replayed.Load(),
+		"events_sampled_out": m.sampledOut.Load(),
 	}
 }
@@ -39,6 +39,23 @@ func (p *Parser) ParseFromString(data string, format string) (*Workflow, error)
 	return p.convertFromYAML(&yamlDef)
 }
 
+// ParseDefinition 解析API提交的工作流定义（JSON对象，字段同YAML格式）并校验
+func (p *Parser) ParseDefinition(definition map[string]interface{}) (*Workflow, error) {
+	data, err := yaml.Marshal(definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+
+	workflow, err := p.ParseFromString(string(data), "yaml")
+	if err != nil {
+		return nil, err
+	}
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
 // ParseFromYAML 从YAML结构解析
 func (p *Parser) ParseFromYAML(yamlDef *WorkflowDefinitionYAML) (*Workflow, error) {
 	return p.convertFromYAML(yamlDef)
@@ -127,11 +144,12 @@ func (p *Parser) convertStep(yamlStep *YAMLStep) (*Step, error) {
 		step.Conditions = make([]*Condition, len(yamlStep.Conditions))
 		for i, cond := range yamlStep.Conditions {
 			step.Conditions[i] = &Condition{
-				Variable: cond.Variable,
-				Operator: cond.Operator,
-				Value:    cond.Value,
-				Then:     cond.Then,
-				Else:     cond.Else,
+				Expression: cond.Expression,
+				Variable:   cond.Variable,
+				Operator:   cond.Operator,
+				Value:      cond.Value,
+				Then:       cond.Then,
+				Else:       cond.Else,
 			}
 		}
 	}
@@ -238,11 +256,12 @@ func (p *Parser) convertStepToYAML(step *Step) YAMLStep {
 		yamlStep.Conditions = make([]YAMLCondition, len(step.Conditions))
 		for i, cond := range step.Conditions {
 			yamlStep.Conditions[i] = YAMLCondition{
-				Variable: cond.Variable,
-				Operator: cond.Operator,
-				Value:    cond.Value,
-				Then:     cond.Then,
-				Else:     cond.Else,
+				Expression: cond.Expression,
+				Variable:   cond.Variable,
+				Operator:   cond.Operator,
+				Value:      cond.Value,
+				Then:       cond.Then,
+				Else:       cond.Else,
 			}
 		}
 	}
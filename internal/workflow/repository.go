@@ -0,0 +1,194 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-agent-assistant/internal/config"
+)
+
+// ErrWorkflowNotFound 工作流不存在
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// Repository 工作流定义存储
+type Repository interface {
+	// Save 保存或更新工作流定义
+	Save(ctx context.Context, workflow *Workflow) error
+
+	// Get 获取工作流定义
+	Get(ctx context.Context, workflowID string) (*Workflow, error)
+
+	// List 按创建时间倒序列出工作流定义
+	List(ctx context.Context) ([]*Workflow, error)
+
+	// Delete 删除工作流定义
+	Delete(ctx context.Context, workflowID string) error
+}
+
+// NewRepositoryFromConfig 根据配置创建工作流存储
+func NewRepositoryFromConfig(cfg config.WorkflowStoreConfig) (Repository, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryRepository(), nil
+	case "file":
+		return NewFileRepository(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported workflow store: %s", cfg.Store)
+	}
+}
+
+// MemoryRepository 内存工作流存储
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	workflows map[string]*Workflow
+}
+
+// NewMemoryRepository 创建内存工作流存储
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		workflows: make(map[string]*Workflow),
+	}
+}
+
+// Save 保存工作流定义
+func (r *MemoryRepository) Save(ctx context.Context, workflow *Workflow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workflows[workflow.ID] = workflow
+	return nil
+}
+
+// Get 获取工作流定义
+func (r *MemoryRepository) Get(ctx context.Context, workflowID string) (*Workflow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workflow, ok := r.workflows[workflowID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+	return workflow, nil
+}
+
+// List 列出工作流定义
+func (r *MemoryRepository) List(ctx context.Context) ([]*Workflow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workflows := make([]*Workflow, 0, len(r.workflows))
+	for _, workflow := range r.workflows {
+		workflows = append(workflows, workflow)
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].CreatedAt.After(workflows[j].CreatedAt)
+	})
+	return workflows, nil
+}
+
+// Delete 删除工作流定义
+func (r *MemoryRepository) Delete(ctx context.Context, workflowID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workflows[workflowID]; !ok {
+		return fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+	delete(r.workflows, workflowID)
+	return nil
+}
+
+// FileRepository 文件工作流存储，每个工作流一个JSON文件，启动时加载全部定义
+type FileRepository struct {
+	*MemoryRepository
+	dir string
+	mu  sync.Mutex // 串行化文件读写
+}
+
+// NewFileRepository 创建文件工作流存储
+func NewFileRepository(dir string) (*FileRepository, error) {
+	if dir == "" {
+		dir = "./data/workflows"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workflow directory: %w", err)
+	}
+
+	r := &FileRepository{
+		MemoryRepository: NewMemoryRepository(),
+		dir:              dir,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load 加载已有工作流定义
+func (r *FileRepository) load() error {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read workflow: %w", err)
+		}
+		var workflow Workflow
+		if err := json.Unmarshal(data, &workflow); err != nil {
+			return fmt.Errorf("failed to decode workflow %s: %w", filepath.Base(file), err)
+		}
+		_ = r.MemoryRepository.Save(ctx, &workflow)
+	}
+	return nil
+}
+
+// path 工作流定义文件路径
+func (r *FileRepository) path(workflowID string) string {
+	return filepath.Join(r.dir, filepath.Base(workflowID)+".json")
+}
+
+// Save 保存工作流定义并写入文件
+func (r *FileRepository) Save(ctx context.Context, workflow *Workflow) error {
+	data, err := json.MarshalIndent(workflow, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path(workflow.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write workflow: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write workflow: %w", err)
+	}
+
+	return r.MemoryRepository.Save(ctx, workflow)
+}
+
+// Delete 删除工作流定义及其文件
+func (r *FileRepository) Delete(ctx context.Context, workflowID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.MemoryRepository.Delete(ctx, workflowID); err != nil {
+		return err
+	}
+	if err := os.Remove(r.path(workflowID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete workflow: %w", err)
+	}
+	return nil
+}
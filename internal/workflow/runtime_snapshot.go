@@ -0,0 +1,179 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+)
+
+// RuntimeSnapshotVersion 运行时快照格式版本
+const RuntimeSnapshotVersion = 1
+
+// ErrInvalidSnapshotName 快照文件名不合法（含路径或不是.json文件）
+var ErrInvalidSnapshotName = errors.New("invalid snapshot name")
+
+// RuntimeSnapshot 编排器运行时状态快照：注册的Agent、等待调度的任务和运行中的工作流执行
+// 用于蓝绿部署时在旧实例上导出，在新实例上恢复
+type RuntimeSnapshot struct {
+	Version    int                              `json:"version"`
+	CreatedAt  time.Time                        `json:"created_at"`
+	Agents     []*aiagentorchestrator.AgentInfo `json:"agents"`
+	Tasks      []*aiagentorchestrator.Task      `json:"tasks"`
+	Executions []*ExecutionSnapshot             `json:"executions"`
+}
+
+// ExecutionSnapshot 运行中的执行及其工作流定义（WorkflowExecution序列化时不含定义）
+type ExecutionSnapshot struct {
+	Execution *WorkflowExecution `json:"execution"`
+	Workflow  *Workflow          `json:"workflow"`
+}
+
+// RestoreResult 恢复结果，各项为实际恢复的数量，已存在的Agent、任务和执行跳过
+type RestoreResult struct {
+	Agents     int `json:"agents"`
+	Tasks      int `json:"tasks"`
+	Executions int `json:"executions"`
+}
+
+// Snapshot 采集运行时状态快照，执行器未设置调度器时不包含任务
+func (e *Executor) Snapshot() *RuntimeSnapshot {
+	snapshot := &RuntimeSnapshot{
+		Version:    RuntimeSnapshotVersion,
+		CreatedAt:  time.Now(),
+		Agents:     []*aiagentorchestrator.AgentInfo{},
+		Tasks:      []*aiagentorchestrator.Task{},
+		Executions: []*ExecutionSnapshot{},
+	}
+	if e.registry != nil {
+		snapshot.Agents = e.registry.List()
+	}
+	if e.scheduler != nil {
+		snapshot.Tasks = e.scheduler.PendingTasks()
+	}
+
+	executions := e.stateMgr.GetExecutionsByStatus(WorkflowStatusRunning)
+	sort.Slice(executions, func(i, j int) bool { return executions[i].StartedAt.Before(executions[j].StartedAt) })
+	for _, execution := range executions {
+		snapshot.Executions = append(snapshot.Executions, &ExecutionSnapshot{
+			Execution: execution,
+			Workflow:  execution.Workflow,
+		})
+	}
+	return snapshot
+}
+
+// Restore 恢复快照：写回Agent、把任务放回队列，并在后台从中断处继续执行工作流
+// ctx为恢复的执行使用的上下文；出错时返回已恢复的部分
+func (e *Executor) Restore(ctx context.Context, snapshot *RuntimeSnapshot) (*RestoreResult, error) {
+	if snapshot.Version != RuntimeSnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	if !e.elector.IsLeader() {
+		return nil, aiagentorchestrator.ErrNotLeader
+	}
+
+	result := &RestoreResult{}
+	var err error
+	if e.registry != nil && len(snapshot.Agents) > 0 {
+		if result.Agents, err = e.registry.Restore(snapshot.Agents); err != nil {
+			return result, fmt.Errorf("failed to restore agents: %w", err)
+		}
+	}
+	if len(snapshot.Tasks) > 0 {
+		if e.scheduler == nil {
+			return result, fmt.Errorf("failed to restore tasks: no task scheduler")
+		}
+		if result.Tasks, err = e.scheduler.Restore(snapshot.Tasks); err != nil {
+			return result, fmt.Errorf("failed to restore tasks: %w", err)
+		}
+	}
+	for _, item := range snapshot.Executions {
+		if item.Execution == nil {
+			continue
+		}
+		if _, err := e.stateMgr.GetExecution(item.Execution.ID); err == nil {
+			continue
+		}
+		item.Execution.Workflow = item.Workflow
+		if err := e.Resume(ctx, item.Execution); err != nil {
+			return result, fmt.Errorf("failed to resume execution %s: %w", item.Execution.ID, err)
+		}
+		result.Executions++
+	}
+	return result, nil
+}
+
+// SaveRuntimeSnapshot 把快照写入dir下以创建时间命名的JSON文件，返回文件名
+// 先写临时文件再重命名，不会留下不完整的快照
+func SaveRuntimeSnapshot(dir string, snapshot *RuntimeSnapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	name := "orchestrator-" + snapshot.CreatedAt.UTC().Format("20060102T150405.000000000Z") + ".json"
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return name, nil
+}
+
+// LoadRuntimeSnapshot 读取dir下的快照文件，name只能是文件名
+func LoadRuntimeSnapshot(dir, name string) (*RuntimeSnapshot, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+		return nil, ErrInvalidSnapshotName
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var snapshot RuntimeSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// ListRuntimeSnapshots 列出dir下的快照文件名，按时间从新到旧排序；目录不存在时返回空列表
+func ListRuntimeSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, "orchestrator-") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
@@ -49,41 +49,41 @@ func (m *StateManager) UpdateExecution(executionID string, execution *WorkflowEx
 	return nil
 }
 
-// GetExecution 获取工作流执行
+// GetExecution 获取工作流执行的快照
 func (m *StateManager) GetExecution(executionID string) (*WorkflowExecution, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
 	execution, exists := m.executions[executionID]
 	if !exists {
 		return nil, fmt.Errorf("execution %s not found", executionID)
 	}
 
-	return execution, nil
+	return execution.Snapshot(), nil
 }
 
-// GetAllExecutions 获取所有执行
+// GetAllExecutions 获取所有执行的快照
 func (m *StateManager) GetAllExecutions() []*WorkflowExecution {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
 	executions := make([]*WorkflowExecution, 0, len(m.executions))
 	for _, exec := range m.executions {
-		executions = append(executions, exec)
+		executions = append(executions, exec.Snapshot())
 	}
 
 	return executions
 }
 
-// GetExecutionsByStatus 按状态获取执行
+// GetExecutionsByStatus 按状态获取执行的快照
 func (m *StateManager) GetExecutionsByStatus(status WorkflowStatus) []*WorkflowExecution {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
 	executions := make([]*WorkflowExecution, 0)
 	for _, exec := range m.executions {
-		if exec.Status == status {
-			executions = append(executions, exec)
+		if snapshot := exec.Snapshot(); snapshot.Status == status {
+			executions = append(executions, snapshot)
 		}
 	}
 
@@ -140,7 +140,7 @@ func (m *StateManager) CreateCheckpoint(executionID string) error {
 	}
 
 	// 序列化执行状态
-	data, err := SerializeExecution(execution)
+	data, err := SerializeExecution(execution.Snapshot())
 	if err != nil {
 		return fmt.Errorf("failed to serialize execution: %w", err)
 	}
@@ -0,0 +1,128 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templatePattern 参数模板中的占位符，如 {{path}}、{{fetch.data.url}}、{{ upper(topic) }}
+var templatePattern = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+
+// pathPattern 变量路径，步骤ID可以包含连字符，按路径直接查找而不是作为表达式解析
+var pathPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+(?:\.[A-Za-z0-9_\-]+)*$`)
+
+// jsonValue 经JSON编码再解码，结构体等类型转换为map、切片和基本类型
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, string, bool, float64, map[string]interface{}, []interface{}:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// renderTemplate 递归替换参数中字符串的占位符，找不到或无法求值的占位符保持原样
+// 整个字符串只有一个占位符时保留值的类型（如列表、数字），否则格式化为字符串
+func renderTemplate(value interface{}, inputs map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if m := templatePattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			if resolved, ok := evalPlaceholder(m[1], inputs); ok {
+				return resolved
+			}
+			return v
+		}
+		return renderString(v, inputs)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered[key] = renderTemplate(item, inputs)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered[i] = renderTemplate(item, inputs)
+		}
+		return rendered
+	default:
+		return value
+	}
+}
+
+// evalPlaceholder 占位符的值：变量路径按路径查找，其他内容作为表达式（可调用内置函数）求值
+// 变量不存在、表达式无法解析或求值失败时返回false
+func evalPlaceholder(content string, inputs map[string]interface{}) (interface{}, bool) {
+	if pathPattern.MatchString(content) {
+		return lookupInput(inputs, content)
+	}
+	expr, err := parseExpression(content)
+	if err != nil {
+		return nil, false
+	}
+	value, err := expr.eval(inputs)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// lookupInput 按 名称.字段.字段 查找输入值
+func lookupInput(inputs map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	value, ok := inputs[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	for _, field := range parts[1:] {
+		converted, err := jsonValue(value)
+		if err != nil {
+			return nil, false
+		}
+		m, isMap := converted.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		if value, ok = m[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// renderString 替换字符串中的占位符，值一律格式化为字符串，找不到或无法求值的占位符保持原样
+func renderString(tmpl string, inputs map[string]interface{}) string {
+	return templatePattern.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		resolved, ok := evalPlaceholder(templatePattern.FindStringSubmatch(placeholder)[1], inputs)
+		if !ok {
+			return placeholder
+		}
+		return templateString(resolved)
+	})
+}
+
+// templateString 嵌入字符串中的值：基本类型直接格式化，其他类型使用JSON
+func templateString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case bool, int, int64, float64:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
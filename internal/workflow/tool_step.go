@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+)
+
+// ToolExecutor tool步骤调用工具的接口，由 tools.ToolManager 实现
+type ToolExecutor interface {
+	ExecuteTool(ctx context.Context, toolName, operation string, params map[string]interface{}) (interface{}, error)
+}
+
+// SetToolManager 设置工具管理器，启用tool（直接调用工具）步骤
+func (e *Executor) SetToolManager(tools ToolExecutor) {
+	e.tools = tools
+}
+
+// executeToolStep 执行工具步骤：不经过Agent，直接以config.params调用step.Tool的config.operation操作
+// params中的 {{名称}} 由步骤输入替换（见stepInputs），名称可用'.'访问依赖步骤输出中的字段；
+// 整个字符串就是一个占位符时保留原值的类型。工具返回 success=false 的结果时步骤失败。
+// 设置 config.chain 时改为执行引用的工具链（见executeToolChain），不需要step.Tool和config.operation
+func (e *Executor) executeToolStep(ctx context.Context, execution *WorkflowExecution, step *Step) (interface{}, error) {
+	if e.tools == nil {
+		return nil, fmt.Errorf("tool step %s requires a tool manager", step.ID)
+	}
+	inputs := stepInputs(execution, step)
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+	if chain, _ := step.Config["chain"].(string); chain != "" {
+		return e.executeToolChain(ctx, step, chain, inputs)
+	}
+
+	operation, _ := step.Config["operation"].(string)
+	if operation == "" {
+		return nil, fmt.Errorf("tool step %s requires config.operation", step.ID)
+	}
+	params := make(map[string]interface{})
+	if raw, ok := step.Config["params"].(map[string]interface{}); ok {
+		params = renderTemplate(raw, inputs).(map[string]interface{})
+	}
+	result, err := e.tools.ExecuteTool(ctx, step.Tool, operation, params)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s %s failed: %w", step.Tool, operation, err)
+	}
+	return toolOutput(step.Tool, operation, result)
+}
+
+// toolOutput 工具结果转换为JSON形式（便于后续步骤按字段引用），结果中 success 为false时返回其中的错误
+func toolOutput(tool, operation string, result interface{}) (interface{}, error) {
+	output, err := jsonValue(result)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s %s returned an unencodable result: %w", tool, operation, err)
+	}
+	if m, ok := output.(map[string]interface{}); ok {
+		if success, ok := m["success"].(bool); ok && !success {
+			msg, _ := m["error"].(string)
+			if msg == "" {
+				msg, _ = m["message"].(string)
+			}
+			if msg == "" {
+				msg = "unsuccessful result"
+			}
+			return nil, fmt.Errorf("tool %s %s failed: %s", tool, operation, msg)
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"fmt"
+)
+
+// stepTypes 执行器支持的步骤类型
+var stepTypes = map[string]bool{
+	"task":       true,
+	"condition":  true,
+	"parallel":   true,
+	"sequential": true,
+	"consensus":  true,
+	"tool":       true,
+	"llm":        true,
+	"gate":       true,
+}
+
+// Validate 校验工作流定义：名称、步骤ID唯一、步骤类型、task步骤的Agent、tool步骤的工具和操作（或工具链）、llm步骤的模型和提示词（或提示词模板）、gate步骤的规则、条件表达式、产物声明、依赖和环
+func (w *Workflow) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("workflow name is required")
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow must have at least one step")
+	}
+
+	seen := make(map[string]bool, len(w.Steps))
+	for i, step := range w.Steps {
+		if step.ID == "" {
+			return fmt.Errorf("step %d: id is required", i)
+		}
+		if seen[step.ID] {
+			return fmt.Errorf("step %s: duplicate step id", step.ID)
+		}
+		seen[step.ID] = true
+
+		if !stepTypes[step.Type] {
+			return fmt.Errorf("step %s: unsupported type %q", step.ID, step.Type)
+		}
+		if step.Type == "task" && step.Agent == "" {
+			return fmt.Errorf("step %s: task step requires an agent", step.ID)
+		}
+		if chain, _ := step.Config["chain"].(string); step.Type == "tool" && chain == "" {
+			if step.Tool == "" {
+				return fmt.Errorf("step %s: tool step requires a tool", step.ID)
+			}
+			if operation, _ := step.Config["operation"].(string); operation == "" {
+				return fmt.Errorf("step %s: tool step requires config.operation", step.ID)
+			}
+		}
+		if step.Type == "llm" {
+			if model, _ := step.Config["model"].(string); model == "" {
+				return fmt.Errorf("step %s: llm step requires config.model", step.ID)
+			}
+			prompt, _ := step.Config["prompt"].(string)
+			template, _ := step.Config["prompt_template"].(string)
+			if prompt == "" && template == "" {
+				return fmt.Errorf("step %s: llm step requires config.prompt or config.prompt_template", step.ID)
+			}
+		}
+		if step.Type == "gate" {
+			if err := validateGateStep(w, step); err != nil {
+				return err
+			}
+		}
+		for _, condition := range step.Conditions {
+			if condition.Expression == "" {
+				continue
+			}
+			if _, err := parseExpression(condition.Expression); err != nil {
+				return fmt.Errorf("step %s: invalid condition expression: %w", step.ID, err)
+			}
+		}
+		if _, err := parseArtifactSpecs(step); err != nil {
+			return err
+		}
+	}
+
+	// 未定义的依赖和环由DAG校验
+	if _, err := BuildDAGFromWorkflow(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResolveInputs 按变量定义补全默认值，缺少必填变量时返回错误
+func (w *Workflow) ResolveInputs(inputs map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(inputs)+len(w.Variables))
+	for k, v := range inputs {
+		resolved[k] = v
+	}
+
+	for _, variable := range w.Variables {
+		if _, ok := resolved[variable.Name]; ok {
+			continue
+		}
+		if variable.DefaultValue != nil {
+			resolved[variable.Name] = variable.DefaultValue
+			continue
+		}
+		if variable.Required {
+			return nil, fmt.Errorf("missing required input: %s", variable.Name)
+		}
+	}
+	return resolved, nil
+}
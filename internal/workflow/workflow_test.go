@@ -1,7 +1,26 @@
 package workflow
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"ai-agent-assistant/internal/artifact"
+	"ai-agent-assistant/internal/clock"
+	"ai-agent-assistant/internal/config"
+	"ai-agent-assistant/internal/llm"
+	aiagentorchestrator "ai-agent-assistant/internal/orchestrator"
+	"ai-agent-assistant/internal/task"
+	"ai-agent-assistant/internal/tenant"
+	"ai-agent-assistant/pkg/models"
 )
 
 // TestWorkflowDefinition 测试工作流定义
@@ -226,9 +245,64 @@ func TestDAGCycleDetection(t *testing.T) {
 		t.Error("Expected error when adding cycle, got nil")
 	}
 
-	// 验证错误消息
-	if err != nil && err.Error() == "" {
-		t.Error("Expected non-empty error message")
+	// 错误信息包含环的路径
+	if err != nil && !strings.Contains(err.Error(), "A -> C -> B -> A") {
+		t.Errorf("Expected cycle path in error, got %v", err)
+	}
+}
+
+// TestDAGDiagnostics 测试构建DAG时报告依赖环的路径、不存在的依赖和无法执行的步骤
+func TestDAGDiagnostics(t *testing.T) {
+	wf := NewWorkflow("diagnostics", "")
+	wf.AddStep(&Step{ID: "fetch", Type: "task"})
+	wf.AddStep(&Step{ID: "parse", Type: "task", DependsOn: []string{"fetch", "check"}})
+	wf.AddStep(&Step{ID: "enrich", Type: "task", DependsOn: []string{"parse"}})
+	wf.AddStep(&Step{ID: "check", Type: "task", DependsOn: []string{"enrich"}})
+	wf.AddStep(&Step{ID: "report", Type: "task", DependsOn: []string{"check"}})
+	wf.AddStep(&Step{ID: "publish", Type: "task", DependsOn: []string{"report", "fetch"}})
+	wf.AddStep(&Step{ID: "notify", Type: "task", DependsOn: []string{"fetch", "email"}})
+	wf.AddStep(&Step{ID: "archive", Type: "task", DependsOn: []string{"notify"}})
+
+	_, err := BuildDAGFromWorkflow(wf)
+	var diag *DAGError
+	if !errors.As(err, &diag) {
+		t.Fatalf("Expected DAGError, got %v", err)
+	}
+	if !reflect.DeepEqual(diag.Cycle, []string{"parse", "check", "enrich", "parse"}) {
+		t.Errorf("Unexpected cycle: %v", diag.Cycle)
+	}
+	if !reflect.DeepEqual(diag.Undefined, map[string][]string{"notify": {"email"}}) {
+		t.Errorf("Unexpected undefined dependencies: %v", diag.Undefined)
+	}
+	if !reflect.DeepEqual(diag.Unreachable, []string{"archive", "publish", "report"}) {
+		t.Errorf("Unexpected unreachable steps: %v", diag.Unreachable)
+	}
+	want := "step notify depends on undefined step email; dependency cycle: parse -> check -> enrich -> parse; unreachable steps: archive, publish, report"
+	if err.Error() != want {
+		t.Errorf("Unexpected error message:\n got %s\nwant %s", err, want)
+	}
+	for _, step := range wf.Steps {
+		step.Agent = "researcher"
+	}
+	if err := wf.Validate(); !errors.As(err, &diag) {
+		t.Errorf("Validate should return the DAG diagnostics, got %v", err)
+	}
+
+	// 依赖自身也是环；重复的依赖只算一次
+	wf = NewWorkflow("self", "")
+	wf.AddStep(&Step{ID: "a", Type: "task", DependsOn: []string{"a"}})
+	if _, err := BuildDAGFromWorkflow(wf); err == nil || !strings.Contains(err.Error(), "dependency cycle: a -> a") {
+		t.Errorf("Expected self cycle, got %v", err)
+	}
+	wf = NewWorkflow("dup", "")
+	wf.AddStep(&Step{ID: "a", Type: "task"})
+	wf.AddStep(&Step{ID: "b", Type: "task", DependsOn: []string{"a", "a"}})
+	dag, err := BuildDAGFromWorkflow(wf)
+	if err != nil {
+		t.Fatalf("Duplicate dependency should be accepted: %v", err)
+	}
+	if order, err := dag.TopologicalSort(); err != nil || !reflect.DeepEqual(order, []string{"a", "b"}) {
+		t.Errorf("Unexpected order %v (%v)", order, err)
 	}
 }
 
@@ -304,6 +378,1260 @@ func TestDAGParallelExecution(t *testing.T) {
 	}
 }
 
+// TestReportWorkflowStepRunner 测试内置报告工作流按依赖顺序执行，并把上一步的输出传给下一步
+func TestReportWorkflowStepRunner(t *testing.T) {
+	executor := NewExecutor(nil, nil)
+	var order []string
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		order = append(order, step.Agent)
+		switch step.ID {
+		case "analyze":
+			if inputs["input"] != "research output" {
+				t.Errorf("analyze step should receive research output, got %v", inputs["input"])
+			}
+		case "write":
+			if inputs["data"] != "analyze output" || inputs["research"] != "research output" || inputs["topic"] != "Golang" {
+				t.Errorf("write step got unexpected inputs: %v", inputs)
+			}
+		}
+		return step.ID + " output", nil
+	})
+
+	execution, err := executor.Execute(context.Background(), ReportWorkflow(), map[string]interface{}{"topic": "Golang"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if execution.Status != WorkflowStatusCompleted {
+		t.Errorf("Expected status completed, got %s", execution.Status)
+	}
+	if len(order) != 3 || order[0] != "researcher" || order[1] != "analyst" || order[2] != "writer" {
+		t.Errorf("Unexpected step order: %v", order)
+	}
+	if execution.GetStepState("write").Output != "write output" {
+		t.Errorf("Unexpected write output: %v", execution.GetStepState("write").Output)
+	}
+}
+
+// TestRuntimeSnapshotRestore 测试快照写入文件后在新实例上恢复Agent、等待任务和运行中的执行
+func TestRuntimeSnapshotRestore(t *testing.T) {
+	registry := aiagentorchestrator.NewAgentRegistry()
+	registry.Register(&aiagentorchestrator.AgentInfo{Name: "researcher"})
+	registry.Register(&aiagentorchestrator.AgentInfo{Name: "writer"})
+	registry.UpdateStatus("writer", "busy")
+	scheduler := aiagentorchestrator.NewTaskScheduler(registry)
+	for i := 0; i < 2; i++ {
+		scheduler.Submit(&aiagentorchestrator.Task{ID: fmt.Sprintf("task-%d", i), Priority: aiagentorchestrator.TaskPriority(i)})
+	}
+	old := NewExecutor(registry, scheduler)
+
+	// 模拟执行到一半：research已完成，analyze和write未执行
+	execution := NewWorkflowExecution(ReportWorkflow(), map[string]interface{}{"topic": "Golang"})
+	execution.Status = WorkflowStatusRunning
+	execution.SetStepState("research", &StepState{StepID: "research", Status: StepStatusCompleted, Output: "research output"})
+	old.StateManager().SetExecution(execution.ID, execution)
+
+	dir := t.TempDir()
+	name, err := SaveRuntimeSnapshot(dir, old.Snapshot())
+	if err != nil {
+		t.Fatalf("SaveRuntimeSnapshot failed: %v", err)
+	}
+	if names, _ := ListRuntimeSnapshots(dir); len(names) != 1 || names[0] != name {
+		t.Errorf("Expected snapshot %s to be listed, got %v", name, names)
+	}
+	if _, err := LoadRuntimeSnapshot(dir, "../"+name); !errors.Is(err, ErrInvalidSnapshotName) {
+		t.Errorf("Expected ErrInvalidSnapshotName, got %v", err)
+	}
+	snapshot, err := LoadRuntimeSnapshot(dir, name)
+	if err != nil {
+		t.Fatalf("LoadRuntimeSnapshot failed: %v", err)
+	}
+
+	freshRegistry := aiagentorchestrator.NewAgentRegistry()
+	freshScheduler := aiagentorchestrator.NewTaskScheduler(freshRegistry)
+	fresh := NewExecutor(freshRegistry, freshScheduler)
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	fresh.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		ran = append(ran, step.ID)
+		mu.Unlock()
+		if step.ID == "analyze" && inputs["input"] != "research output" {
+			t.Errorf("analyze step should receive the restored research output, got %v", inputs["input"])
+		}
+		return step.ID + " output", nil
+	})
+
+	result, err := fresh.Restore(context.Background(), snapshot)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if result.Agents != 2 || result.Tasks != 2 || result.Executions != 1 {
+		t.Errorf("Unexpected restore result: %+v", result)
+	}
+	if writer, _ := freshRegistry.Get("writer"); writer == nil || writer.Status != "active" {
+		t.Errorf("Busy agent should be restored as active, got %+v", writer)
+	}
+	if tasks := freshScheduler.PendingTasks(); len(tasks) != 2 || tasks[0].ID != "task-1" {
+		t.Errorf("Expected queued tasks in priority order, got %v", tasks)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		restored, err := fresh.StateManager().GetExecution(execution.ID)
+		if err == nil && restored.Status == WorkflowStatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Restored execution did not complete: %+v, %v", restored, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 2 || ran[0] != "analyze" || ran[1] != "write" {
+		t.Errorf("Only the unfinished steps should run, got %v", ran)
+	}
+
+	// 重复恢复同一快照时已存在的内容跳过
+	again, err := fresh.Restore(context.Background(), snapshot)
+	if err != nil || again.Agents != 0 || again.Tasks != 0 || again.Executions != 0 {
+		t.Errorf("Expected nothing restored the second time, got %+v, %v", again, err)
+	}
+}
+
+// TestExecutorMaxParallelSteps 测试并行执行时同一层同时运行的步骤数不超过配置的上限
+func TestExecutorMaxParallelSteps(t *testing.T) {
+	executor := NewExecutorFromConfig(nil, nil, config.WorkflowExecutorConfig{MaxParallelSteps: 2})
+	var mu sync.Mutex
+	running, peak := 0, 0
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	})
+
+	workflow := NewWorkflow("parallel-limit", "并行上限")
+	workflow.Config = &WorkflowConfig{ParallelExecution: true}
+	for _, id := range []string{"A", "B", "C", "D", "E"} {
+		workflow.AddStep(&Step{ID: id, Name: id, Type: "task"})
+	}
+
+	execution, err := executor.Execute(context.Background(), workflow, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if execution.Status != WorkflowStatusCompleted {
+		t.Errorf("Expected status completed, got %s", execution.Status)
+	}
+	if peak != 2 {
+		t.Errorf("Expected at most 2 concurrent steps, got %d", peak)
+	}
+}
+
+// TestExecutorAgentLimits 测试同类Agent的步骤共用并发上限，全局上限跨执行生效，等待名额时可以取消
+func TestExecutorAgentLimits(t *testing.T) {
+	executor := NewExecutorFromConfig(nil, nil, config.WorkflowExecutorConfig{
+		MaxConcurrentSteps: 3,
+		AgentLimits:        map[string]int{"researcher": 2},
+		DefaultAgentLimit:  1,
+	})
+	var mu sync.Mutex
+	running := make(map[string]int)
+	peak := make(map[string]int)
+	block := make(chan struct{})
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		for _, key := range []string{step.Agent, "*"} {
+			running[key]++
+			if running[key] > peak[key] {
+				peak[key] = running[key]
+			}
+		}
+		mu.Unlock()
+		if step.ID == "hold" {
+			<-block
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mu.Lock()
+		running[step.Agent]--
+		running["*"]--
+		mu.Unlock()
+		return nil, nil
+	})
+
+	newWorkflow := func(id string) *Workflow {
+		workflow := NewWorkflow(id, id)
+		workflow.Config = &WorkflowConfig{ParallelExecution: true}
+		for i := 0; i < 4; i++ {
+			workflow.AddStep(&Step{ID: fmt.Sprintf("r%d", i), Name: "research", Type: "task", Agent: "researcher"})
+		}
+		for i := 0; i < 3; i++ {
+			workflow.AddStep(&Step{ID: fmt.Sprintf("c%d", i), Name: "code", Type: "task", Agent: "coder"})
+		}
+		workflow.AddStep(&Step{ID: "any", Name: "any", Type: "task"})
+		return workflow
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			execution, err := executor.Execute(context.Background(), newWorkflow(fmt.Sprintf("agent-limit-%d", i)), nil)
+			if err != nil || execution.Status != WorkflowStatusCompleted {
+				t.Errorf("Execute failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak["researcher"] != 2 || peak["coder"] != 1 || peak["*"] > 3 {
+		t.Errorf("Unexpected concurrency peaks: %v", peak)
+	}
+	stats := executor.ConcurrencyStats()
+	if stats["running"] != 0 || stats["limit"] != 3 {
+		t.Errorf("Unexpected stats after completion: %v", stats)
+	}
+
+	// 名额被占满时等待的步骤随上下文取消而失败
+	holder := NewWorkflow("holder", "holder")
+	holder.AddStep(&Step{ID: "hold", Name: "hold", Type: "task", Agent: "coder"})
+	go executor.Execute(context.Background(), holder, nil)
+	for executor.ConcurrencyStats()["agents"].(map[string]interface{})["coder"].(map[string]int)["running"] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	waiting := NewWorkflow("waiting", "waiting")
+	waiting.AddStep(&Step{ID: "c", Name: "code", Type: "task", Agent: "coder"})
+	execution, err := executor.Execute(ctx, waiting, nil)
+	close(block)
+	if err == nil || execution.Status != WorkflowStatusFailed {
+		t.Fatalf("Expected waiting step to fail, got %v", err)
+	}
+	if state := execution.GetStepState("c"); state == nil || !strings.Contains(state.Error, "waiting for agent coder") {
+		t.Errorf("Unexpected step state: %+v", state)
+	}
+}
+
+// TestExecutionSnapshotConcurrentRead 测试并行步骤写入状态时查询执行快照（配合 -race 运行）
+func TestExecutionSnapshotConcurrentRead(t *testing.T) {
+	executor := NewExecutorFromConfig(nil, nil, config.WorkflowExecutorConfig{MaxParallelSteps: 4})
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return step.ID + " output", nil
+	})
+
+	workflow := NewWorkflow("snapshot-read", "并发读取快照")
+	workflow.Config = &WorkflowConfig{ParallelExecution: true}
+	for _, id := range []string{"A", "B", "C", "D", "E", "F"} {
+		workflow.AddStep(&Step{ID: id, Name: id, Type: "task"})
+	}
+
+	id, err := executor.Start(context.Background(), workflow, nil)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, execution := range executor.StateManager().GetAllExecutions() {
+			for stepID := range execution.StepStates {
+				_ = execution.StepStates[stepID].Status
+			}
+		}
+		execution, err := executor.StateManager().GetExecution(id)
+		if err != nil {
+			t.Fatalf("GetExecution failed: %v", err)
+		}
+		if execution.Status == WorkflowStatusCompleted {
+			if len(execution.StepStates) != 6 {
+				t.Errorf("Expected 6 step states, got %d", len(execution.StepStates))
+			}
+			return
+		}
+		if execution.Status == WorkflowStatusFailed || time.Now().After(deadline) {
+			t.Fatalf("Unexpected execution status %s", execution.Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// countingListener 统计收到的监控事件
+type countingListener struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *countingListener) OnEvent(event *MonitorEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event.ExecutionID)
+	return nil
+}
+
+func (l *countingListener) OnMetricsUpdate(metrics *WorkflowExecutionMetrics) error { return nil }
+
+func (l *countingListener) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
+
+// TestMonitorOverflow 测试监控事件缓冲区满时的各种处理方式和计数
+func TestMonitorOverflow(t *testing.T) {
+	publish := func(m *Monitor, n int) {
+		for i := 0; i < n; i++ {
+			m.publishEvent(&MonitorEvent{Type: "test", ExecutionID: fmt.Sprintf("exec-%d", i)})
+		}
+	}
+	stat := func(m *Monitor, key string) int64 {
+		return m.GetStats()[key].(int64)
+	}
+
+	drop := NewMonitorFromConfig(config.WorkflowMonitorConfig{EventBufferSize: 2})
+	publish(drop, 5)
+	if stat(drop, "events_published") != 2 || stat(drop, "events_dropped") != 3 {
+		t.Errorf("drop: unexpected stats %v", drop.GetStats())
+	}
+
+	block := NewMonitorFromConfig(config.WorkflowMonitorConfig{EventBufferSize: 1, Overflow: OverflowBlock, BlockTimeout: "10ms"})
+	start := time.Now()
+	publish(block, 2)
+	if time.Since(start) < 10*time.Millisecond || stat(block, "events_dropped") != 1 {
+		t.Errorf("block: expected one event dropped after timeout, stats %v", block.GetStats())
+	}
+
+	sample := NewMonitorFromConfig(config.WorkflowMonitorConfig{EventBufferSize: 10, Overflow: OverflowSample, SampleRate: 0.5})
+	publish(sample, 15)
+	// 前5个直接进入缓冲区，之后每2个保留1个
+	if stat(sample, "events_published") != 10 || stat(sample, "events_sampled_out") != 5 {
+		t.Errorf("sample: unexpected stats %v", sample.GetStats())
+	}
+
+	spillPath := filepath.Join(t.TempDir(), "spill.jsonl")
+	spill := NewMonitorFromConfig(config.WorkflowMonitorConfig{EventBufferSize: 1, Overflow: OverflowSpill, SpillPath: spillPath})
+	listener := &countingListener{}
+	spill.AddListener(listener)
+	publish(spill, 4)
+	if stat(spill, "events_spilled") != 3 || stat(spill, "events_dropped") != 0 {
+		t.Errorf("spill: unexpected stats %v", spill.GetStats())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spill.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for listener.count() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if listener.count() != 4 || stat(spill, "events_replayed") != 3 {
+		t.Fatalf("spill: expected all events delivered, got %v, stats %v", listener.events, spill.GetStats())
+	}
+	if _, err := os.Stat(spillPath + ".replay"); !os.IsNotExist(err) {
+		t.Errorf("spill: replay file should be removed, stat err %v", err)
+	}
+}
+
+// TestMonitorClock 测试监控器的执行时长和过期清理使用注入的时钟
+func TestMonitorClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	m := NewMonitorFromConfig(config.WorkflowMonitorConfig{Retention: "1h"})
+	m.SetClock(fake)
+
+	m.RecordWorkflowStart("exec-1", "wf-1")
+	m.RecordStepStart("exec-1", "step-1", "researcher")
+	fake.Advance(2 * time.Second)
+	m.RecordStepEnd("exec-1", "step-1", "completed", nil, 0, 0, 0)
+	fake.Advance(time.Second)
+	m.RecordWorkflowEnd("exec-1", "completed", nil)
+
+	metrics, err := m.GetExecutionMetrics("exec-1")
+	if err != nil {
+		t.Fatalf("GetExecutionMetrics failed: %v", err)
+	}
+	if !metrics.StartTime.Equal(start) || metrics.Duration != 3*time.Second {
+		t.Errorf("unexpected start %v / duration %v", metrics.StartTime, metrics.Duration)
+	}
+	if step := metrics.StepMetrics["step-1"]; step == nil || step.Duration != 2*time.Second {
+		t.Errorf("unexpected step metrics %+v", step)
+	}
+
+	fake.Advance(30 * time.Minute)
+	m.removeExpiredMetrics()
+	if _, err := m.GetExecutionMetrics("exec-1"); err != nil {
+		t.Errorf("execution should be kept within retention: %v", err)
+	}
+	fake.Advance(time.Hour)
+	m.removeExpiredMetrics()
+	if _, err := m.GetExecutionMetrics("exec-1"); err == nil {
+		t.Error("execution should be removed after retention")
+	}
+}
+
+// TestParseDefinition 测试API提交的定义的解析与校验
+func TestParseDefinition(t *testing.T) {
+	parser := NewParser("")
+
+	workflow, err := parser.ParseDefinition(map[string]interface{}{
+		"name":      "research",
+		"variables": []interface{}{map[string]interface{}{"name": "topic", "type": "string", "required": true}},
+		"steps": []interface{}{
+			map[string]interface{}{"id": "search", "agent": "researcher"},
+			map[string]interface{}{"id": "write", "agent": "writer", "depends_on": []interface{}{"search"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	if len(workflow.Steps) != 2 || workflow.Steps[0].Type != "task" {
+		t.Errorf("Unexpected steps: %+v", workflow.Steps)
+	}
+	if _, err := workflow.ResolveInputs(nil); err == nil {
+		t.Error("Expected error for missing required input")
+	}
+
+	invalid := map[string][]interface{}{
+		"duplicate id":           {map[string]interface{}{"id": "a", "agent": "x"}, map[string]interface{}{"id": "a", "agent": "x"}},
+		"missing agent":          {map[string]interface{}{"id": "a"}},
+		"unknown type":           {map[string]interface{}{"id": "a", "type": "loop"}},
+		"undefined dependency":   {map[string]interface{}{"id": "a", "agent": "x", "depends_on": []interface{}{"b"}}},
+		"tool without operation": {map[string]interface{}{"id": "a", "type": "tool", "tool": "file_ops"}},
+		"llm without prompt":     {map[string]interface{}{"id": "a", "type": "llm", "config": map[string]interface{}{"model": "glm"}}},
+	}
+	for name, steps := range invalid {
+		if _, err := parser.ParseDefinition(map[string]interface{}{"name": "bad", "steps": steps}); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+// fakeTools 记录调用的工具管理器
+type fakeTools struct {
+	mu    sync.Mutex
+	calls []map[string]interface{}
+}
+
+func (f *fakeTools) ExecuteTool(ctx context.Context, toolName, operation string, params map[string]interface{}) (interface{}, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, params)
+	f.mu.Unlock()
+	switch operation {
+	case "read":
+		return struct {
+			Success bool                   `json:"success"`
+			Data    map[string]interface{} `json:"data"`
+		}{true, map[string]interface{}{"content": "hello", "lines": 3}}, nil
+	case "write":
+		return map[string]interface{}{"success": true, "written": params["content"]}, nil
+	default:
+		return map[string]interface{}{"success": false, "error": "unsupported operation: " + operation}, nil
+	}
+}
+
+// TestToolStep 测试tool步骤直接调用工具，参数模板引用工作流输入和依赖步骤的输出
+func TestToolStep(t *testing.T) {
+	tools := &fakeTools{}
+	executor := NewExecutor(nil, nil)
+	executor.SetToolManager(tools)
+
+	wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+		"name": "copy",
+		"steps": []interface{}{
+			map[string]interface{}{"id": "read", "type": "tool", "tool": "file_ops",
+				"config": map[string]interface{}{"operation": "read", "params": map[string]interface{}{"path": "{{dir}}/in.txt"}}},
+			map[string]interface{}{"id": "write", "type": "tool", "tool": "file_ops", "depends_on": []interface{}{"read"},
+				"config": map[string]interface{}{"operation": "write", "params": map[string]interface{}{
+					"path":    "{{dir}}/out.txt",
+					"content": "{{read.data.content}} ({{read.data.lines}} lines)",
+					"meta":    "{{read.data}}",
+					"tags":    []interface{}{"{{ dir }}", "{{missing}}"},
+				}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+
+	execution, err := executor.Execute(context.Background(), wf, map[string]interface{}{"dir": "/tmp"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(tools.calls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %d", len(tools.calls))
+	}
+	if tools.calls[0]["path"] != "/tmp/in.txt" {
+		t.Errorf("Unexpected read params: %v", tools.calls[0])
+	}
+	write := tools.calls[1]
+	if write["path"] != "/tmp/out.txt" || write["content"] != "hello (3 lines)" {
+		t.Errorf("Unexpected write params: %v", write)
+	}
+	if meta, ok := write["meta"].(map[string]interface{}); !ok || meta["content"] != "hello" {
+		t.Errorf("Whole-placeholder param should keep the value type, got %#v", write["meta"])
+	}
+	if tags := write["tags"].([]interface{}); tags[0] != "/tmp" || tags[1] != "{{missing}}" {
+		t.Errorf("Unexpected tags: %v", tags)
+	}
+	if output := execution.GetStepState("write").Output.(map[string]interface{}); output["written"] != "hello (3 lines)" {
+		t.Errorf("Unexpected write output: %v", output)
+	}
+
+	// 工具返回success=false时步骤失败
+	wf.Steps = []*Step{{ID: "bad", Type: "tool", Tool: "file_ops", Config: map[string]interface{}{"operation": "move"}}}
+	execution, err = executor.Execute(context.Background(), wf, nil)
+	if err == nil || execution.Status != WorkflowStatusFailed {
+		t.Fatalf("Expected failed execution, got %s (%v)", execution.Status, err)
+	}
+	if msg := execution.GetStepState("bad").Error; !strings.Contains(msg, "unsupported operation: move") {
+		t.Errorf("Unexpected step error: %s", msg)
+	}
+}
+
+// promptModel 记录提示词并返回固定补全的模型
+type promptModel struct {
+	mu         sync.Mutex
+	prompts    [][]models.Message
+	maxTokens  []int
+	completion string
+}
+
+func (m *promptModel) Chat(ctx context.Context, messages []models.Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = append(m.prompts, messages)
+	maxTokens := 0
+	if opts := llm.GenerationOptionsFromContext(ctx); opts != nil {
+		maxTokens = opts.MaxTokens
+	}
+	m.maxTokens = append(m.maxTokens, maxTokens)
+	return m.completion, nil
+}
+
+func (m *promptModel) ChatStream(ctx context.Context, messages []models.Message) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+func (m *promptModel) SupportsToolCalling() bool { return false }
+func (m *promptModel) SupportsEmbedding() bool   { return false }
+func (m *promptModel) Embed(ctx context.Context, text string) ([]float64, error) {
+	return nil, errors.New("not supported")
+}
+func (m *promptModel) GetModelName() string    { return "fake" }
+func (m *promptModel) GetProviderName() string { return "test" }
+
+// TestLLMStep 测试llm步骤：提示词模板引用输入和依赖步骤输出，补全作为步骤输出，json格式时解析补全
+func TestLLMStep(t *testing.T) {
+	manager, err := llm.NewModelManager(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewModelManager failed: %v", err)
+	}
+	model := &promptModel{completion: "```json\n{\"sentiment\": \"positive\", \"score\": 0.9}\n```"}
+	manager.RegisterModel("fake", model)
+
+	executor := NewExecutor(nil, nil)
+	executor.SetModelManager(manager)
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"text": "great product"}, nil
+	})
+
+	wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+		"name": "classify",
+		"steps": []interface{}{
+			map[string]interface{}{"id": "fetch", "agent": "researcher"},
+			map[string]interface{}{"id": "classify", "type": "llm", "depends_on": []interface{}{"fetch"},
+				"config": map[string]interface{}{
+					"model":      "fake",
+					"system":     "You classify {{lang}} reviews.",
+					"prompt":     "Review: {{fetch.text}}",
+					"format":     "json",
+					"max_tokens": 64,
+				}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+
+	execution, err := executor.Execute(context.Background(), wf, map[string]interface{}{"lang": "English"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(model.prompts) != 1 {
+		t.Fatalf("Expected 1 model call, got %d", len(model.prompts))
+	}
+	messages := model.prompts[0]
+	if len(messages) != 2 || messages[0].Content != "You classify English reviews." || messages[1].Content != "Review: great product" {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+	if model.maxTokens[0] != 64 {
+		t.Errorf("Expected max_tokens 64, got %d", model.maxTokens[0])
+	}
+	output, ok := execution.GetStepState("classify").Output.(map[string]interface{})
+	if !ok || output["sentiment"] != "positive" {
+		t.Errorf("Unexpected llm output: %#v", execution.GetStepState("classify").Output)
+	}
+
+	// 不要求json格式时补全原样作为输出；json格式解析失败时步骤失败
+	model.completion = "plain answer"
+	wf.Steps[1].Config["format"] = "text"
+	execution, _ = executor.Execute(context.Background(), wf, map[string]interface{}{"lang": "English"})
+	if got := execution.GetStepState("classify").Output; got != "plain answer" {
+		t.Errorf("Expected plain completion, got %v", got)
+	}
+	wf.Steps[1].Config["format"] = "json"
+	if _, err := executor.Execute(context.Background(), wf, map[string]interface{}{"lang": "English"}); err == nil {
+		t.Error("Expected failure for non-JSON completion")
+	}
+}
+
+// TestGateStep 测试gate步骤按指标继续、重试上游分支和中止
+func TestGateStep(t *testing.T) {
+	executor := NewExecutor(nil, nil)
+	monitor := NewMonitor()
+	executor.SetMonitor(monitor)
+	attempts := make(map[string]int)
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		attempts[step.ID]++
+		if step.ID == "fetch" && attempts["fetch"] < 3 {
+			return nil, fmt.Errorf("fetch failed")
+		}
+		return step.ID + " output", nil
+	})
+
+	gate := func(rules []interface{}, extra map[string]interface{}) *Workflow {
+		config := map[string]interface{}{"rules": rules}
+		for k, v := range extra {
+			config[k] = v
+		}
+		wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+			"name":   "adaptive",
+			"config": map[string]interface{}{"continue_on_error": true},
+			"steps": []interface{}{
+				map[string]interface{}{"id": "fetch", "type": "task", "agent": "researcher"},
+				map[string]interface{}{"id": "parse", "type": "task", "agent": "analyst", "depends_on": []interface{}{"fetch"}},
+				map[string]interface{}{"id": "other", "type": "task", "agent": "analyst"},
+				map[string]interface{}{"id": "check", "type": "gate", "depends_on": []interface{}{"parse", "other"}, "config": config},
+				map[string]interface{}{"id": "report", "type": "task", "agent": "writer", "depends_on": []interface{}{"check"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("ParseDefinition failed: %v", err)
+		}
+		if err := wf.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		return wf
+	}
+
+	// fetch前两次失败，gate重试fetch分支（parse随之重跑，other不重跑）直到成功
+	wf := gate([]interface{}{
+		map[string]interface{}{"when": "metrics.error_rate > 0.5", "action": "abort", "message": "too many failures"},
+		map[string]interface{}{"when": "steps.fetch.status == 'failed' && steps.fetch.retry_count < 5", "action": "retry", "retry": []interface{}{"fetch"}},
+	}, nil)
+	execution, err := executor.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if attempts["fetch"] != 3 || attempts["parse"] != 3 || attempts["other"] != 1 || attempts["report"] != 1 {
+		t.Errorf("Unexpected attempts: %v", attempts)
+	}
+	output := execution.GetStepState("check").Output.(map[string]interface{})
+	if output["action"] != GateActionContinue || output["retries"] != 2 {
+		t.Errorf("Unexpected gate output: %v", output)
+	}
+	if state := execution.GetStepState("fetch"); state.Status != StepStatusCompleted || state.RetryCount != 2 {
+		t.Errorf("Unexpected fetch state: %+v", state)
+	}
+	if metrics, _ := monitor.GetExecutionMetrics(execution.ID); metrics == nil || metrics.StepMetrics["fetch"].RetryCount != 2 {
+		t.Errorf("Monitor should record fetch retries, got %+v", metrics)
+	}
+	if stats, ok := monitor.AgentStats("researcher"); !ok || stats.Executions != 3 || stats.Failures != 2 || stats.Running != 0 {
+		t.Errorf("Unexpected researcher stats: %+v", stats)
+	}
+
+	// 重试次数用完后中止，即使配置了continue_on_error
+	attempts = make(map[string]int)
+	wf = gate([]interface{}{
+		map[string]interface{}{"when": "steps.fetch.status == 'failed'", "action": "retry", "retry": "fetch"},
+	}, map[string]interface{}{"max_retries": 1})
+	execution, err = executor.Execute(context.Background(), wf, nil)
+	if err == nil || execution.Status != WorkflowStatusFailed {
+		t.Fatalf("Expected aborted execution, got %s (%v)", execution.Status, err)
+	}
+	if attempts["fetch"] != 2 || attempts["report"] != 0 {
+		t.Errorf("Unexpected attempts after exhausted retries: %v", attempts)
+	}
+	if msg := execution.GetStepState("check").Error; !strings.Contains(msg, "still holds after 1 retries") {
+		t.Errorf("Unexpected gate error: %s", msg)
+	}
+
+	// 规则可以引用监控器的自定义指标，没有规则成立时按default处理
+	attempts = map[string]int{"fetch": 2}
+	wf = gate([]interface{}{
+		map[string]interface{}{"when": "metrics.custom.budget < 10", "action": "abort"},
+	}, map[string]interface{}{"default": "abort"})
+	execution, err = executor.Execute(context.Background(), wf, nil)
+	if err == nil || !strings.Contains(execution.GetStepState("check").Error, "no rule matched") {
+		t.Errorf("Expected default abort, got %v", err)
+	}
+
+	// 校验规则
+	for _, rules := range [][]interface{}{
+		{map[string]interface{}{"when": "metrics.error_rate >", "action": "abort"}},
+		{map[string]interface{}{"when": "true", "action": "skip"}},
+		{map[string]interface{}{"when": "true", "action": "retry", "retry": "report"}},
+	} {
+		wf.Steps[3].Config = map[string]interface{}{"rules": rules}
+		if err := wf.Validate(); err == nil {
+			t.Errorf("Expected validation error for rules %v", rules)
+		}
+	}
+}
+
+// TestExpression 测试gate表达式的运算和优先级
+func TestExpression(t *testing.T) {
+	vars := map[string]interface{}{
+		"metrics": map[string]interface{}{"error_rate": 0.25, "failed": 1},
+		"name":    "fetch",
+	}
+	cases := map[string]interface{}{
+		"1 + 2 * 3":                              7.0,
+		"(1 + 2) * 3":                            9.0,
+		"-metrics.failed + 3":                    2.0,
+		"metrics.error_rate >= 0.25 && !false":   true,
+		"metrics.missing > 1 || name == 'fetch'": true,
+		"metrics.missing == null":                true,
+		"name + \"-1\"":                          "fetch-1",
+		"metrics.failed == 1.0":                  true,
+		"7 % 4":                                  3.0,
+	}
+	for src, want := range cases {
+		expr, err := parseExpression(src)
+		if err != nil {
+			t.Errorf("parseExpression(%q) failed: %v", src, err)
+			continue
+		}
+		got, err := expr.eval(vars)
+		if err != nil || got != want {
+			t.Errorf("%q = %v (%v), want %v", src, got, err, want)
+		}
+	}
+
+	for _, src := range []string{"1 +", "(1", "'open", "a # b", "1 2"} {
+		if _, err := parseExpression(src); err == nil {
+			t.Errorf("Expected parse error for %q", src)
+		}
+	}
+	if expr, _ := parseExpression("1 / (metrics.failed - 1)"); expr != nil {
+		if _, err := expr.eval(vars); err == nil {
+			t.Error("Expected division by zero error")
+		}
+	}
+}
+
+// TestTemplateFunctions 测试内置函数在表达式、步骤配置模板和条件步骤中的使用
+func TestTemplateFunctions(t *testing.T) {
+	vars := map[string]interface{}{
+		"fetch":   map[string]interface{}{"data": map[string]interface{}{"items": []interface{}{map[string]interface{}{"url": "https://a.example"}}}},
+		"title":   "  Urgent: Disk Full  ",
+		"tags":    []interface{}{"ops", "urgent"},
+		"answer":  "score: 42/100",
+		"judge":   `{"score": 9}`,
+		"created": "2024-01-01T00:00:00Z",
+	}
+	cases := map[string]interface{}{
+		"json_path(fetch, 'data.items[0].url')":              "https://a.example",
+		"json_path(fetch, '$.data.items[1].url') == null":    true,
+		"json_path(from_json(judge), 'score') >= 8":          true,
+		"upper(trim(title))":                                 "URGENT: DISK FULL",
+		"contains(lower(title), 'urgent')":                   true,
+		"contains(tags, 'ops') && !contains(tags, 'dev')":    true,
+		"join(split('a,b,c', ','), '-')":                     "a-b-c",
+		"substr('abcdef', 2, 3)":                             "cde",
+		"len(tags) + len('中文')":                              4.0,
+		"default(missing, 'cn')":                             "cn",
+		"date_format(date_add(created, '1d'), '2006-01-02')": "2024-01-02",
+		"date_diff(date_add(created, '2h'), created)":        7200.0,
+		"regex_find(answer, 'score: ([0-9]+)')":              "42",
+		"regex_match(answer, '^score')":                      true,
+		"regex_replace(answer, '[0-9]+', 'N')":               "score: N/N",
+		"base64_decode(base64_encode('user:pass'))":          "user:pass",
+	}
+	for src, want := range cases {
+		expr, err := parseExpression(src)
+		if err != nil {
+			t.Errorf("parseExpression(%q) failed: %v", src, err)
+			continue
+		}
+		got, err := expr.eval(vars)
+		if err != nil || got != want {
+			t.Errorf("%q = %v (%v), want %v", src, got, err, want)
+		}
+	}
+
+	// 未知函数和参数个数在解析时报错，参数不合法在求值时报错
+	for _, src := range []string{"unknown(1)", "upper()", "replace('a', 'b')", "upper(1, 2)", "len(tags"} {
+		if _, err := parseExpression(src); err == nil {
+			t.Errorf("Expected parse error for %q", src)
+		}
+	}
+	for _, src := range []string{"regex_match(answer, '(')", "date_add('yesterday', '1h')", "base64_decode('%%%')"} {
+		expr, err := parseExpression(src)
+		if err != nil {
+			t.Fatalf("parseExpression(%q) failed: %v", src, err)
+		}
+		if _, err := expr.eval(vars); err == nil {
+			t.Errorf("Expected eval error for %q", src)
+		}
+	}
+
+	// 模板中的表达式：整个字符串为一个占位符时保留类型，无法求值的占位符保持原样
+	rendered := renderTemplate(map[string]interface{}{
+		"url":   "{{ json_path(fetch, 'data.items[0].url') }}",
+		"count": "{{ len(tags) }}",
+		"query": "tags={{ join(tags, ',') }}&q={{ fetch.missing }}",
+		"bad":   "{{ unknown(title) }}",
+	}, vars).(map[string]interface{})
+	want := map[string]interface{}{
+		"url":   "https://a.example",
+		"count": 2.0,
+		"query": "tags=ops,urgent&q={{ fetch.missing }}",
+		"bad":   "{{ unknown(title) }}",
+	}
+	if !reflect.DeepEqual(rendered, want) {
+		t.Errorf("renderTemplate = %v, want %v", rendered, want)
+	}
+
+	// 条件步骤的表达式条件
+	executor := NewExecutor(nil, nil)
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"status": "FAILED", "retries": 3}, nil
+	})
+	wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+		"name": "conditions",
+		"steps": []interface{}{
+			map[string]interface{}{"id": "fetch", "type": "task", "agent": "researcher"},
+			map[string]interface{}{"id": "route", "type": "condition", "depends_on": []interface{}{"fetch"}, "conditions": []interface{}{
+				map[string]interface{}{"expression": "lower(fetch.status) == 'ok'", "then": "report"},
+				map[string]interface{}{"expression": "starts_with(lower(fetch.status), 'fail') && fetch.retries >= 3", "then": "alert", "else": "report"},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	if err := wf.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	execution, err := executor.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if output := execution.GetStepState("route").Output; output != "Condition matched, executing: alert" {
+		t.Errorf("Unexpected condition output: %v", output)
+	}
+	wf.Steps[1].Conditions[0].Expression = "lower(fetch.status) =="
+	if err := wf.Validate(); err == nil {
+		t.Error("Expected validation error for invalid condition expression")
+	}
+
+	// 函数列表按类别和名称排序，示例都能解析
+	functions := TemplateFunctions()
+	if len(functions) != len(templateFunctions) {
+		t.Fatalf("Expected %d functions, got %d", len(templateFunctions), len(functions))
+	}
+	for i, fn := range functions {
+		if i > 0 && (functions[i-1].Category > fn.Category || functions[i-1].Category == fn.Category && functions[i-1].Name > fn.Name) {
+			t.Errorf("Functions not sorted at %s", fn.Name)
+		}
+		if _, err := parseExpression(fn.Example); err != nil {
+			t.Errorf("Example of %s does not parse: %v", fn.Name, err)
+		}
+	}
+}
+
+// TestExecutionArtifacts 测试步骤产物：config.artifact声明的输出保存到产物存储，输出中的产物引用自动登记
+func TestExecutionArtifacts(t *testing.T) {
+	store := artifact.NewMemoryStore()
+	executor := NewExecutor(nil, nil)
+	executor.SetArtifactStore(store)
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"report":  "# " + fmt.Sprint(inputs["topic"]),
+			"summary": map[string]interface{}{"words": 2},
+			"figures": []task.ArtifactRef{{ID: "artifact-chart", Name: "chart.svg", ContentType: "image/svg+xml"}},
+		}, nil
+	})
+
+	wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+		"name": "report",
+		"steps": []interface{}{
+			map[string]interface{}{"id": "write", "type": "task", "agent": "writer",
+				"config": map[string]interface{}{"artifact": []interface{}{
+					map[string]interface{}{"name": "{{topic}}.md", "field": "report", "content_type": "text/markdown"},
+					map[string]interface{}{"name": "summary.json", "field": "summary"},
+				}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+
+	execution, err := executor.Execute(context.Background(), wf, map[string]interface{}{"topic": "AI"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	items := execution.ListArtifacts()
+	if len(items) != 3 || items[0].Name != "AI.md" || items[1].Name != "chart.svg" || items[2].Name != "summary.json" {
+		t.Fatalf("Unexpected artifacts: %+v", items)
+	}
+
+	md, _ := execution.GetArtifact("AI.md")
+	saved, data, err := store.Get(context.Background(), md.ArtifactID)
+	if err != nil {
+		t.Fatalf("Get artifact failed: %v", err)
+	}
+	if string(data) != "# AI" || saved.ContentType != "text/markdown" || md.Size != 4 || md.StepID != "write" {
+		t.Errorf("Unexpected markdown artifact: %+v %q", md, data)
+	}
+	summary, _ := execution.GetArtifact("summary.json")
+	if _, data, _ := store.Get(context.Background(), summary.ArtifactID); summary.ContentType != "application/json" || !strings.Contains(string(data), `"words": 2`) {
+		t.Errorf("Unexpected summary artifact: %+v %q", summary, data)
+	}
+	if chart, _ := execution.GetArtifact("chart.svg"); chart.ArtifactID != "artifact-chart" || chart.ContentType != "image/svg+xml" {
+		t.Errorf("Unexpected chart artifact: %+v", chart)
+	}
+	if len(execution.Snapshot().Artifacts) != 3 {
+		t.Errorf("Snapshot should include artifacts")
+	}
+
+	// 输出中没有声明的字段时步骤失败
+	wf.Steps[0].Config["artifact"] = map[string]interface{}{"name": "missing.txt", "field": "missing"}
+	execution, err = executor.Execute(context.Background(), wf, nil)
+	if err == nil || !strings.Contains(execution.GetStepState("write").Error, "artifact field missing not found") {
+		t.Errorf("Expected missing field error, got %v", err)
+	}
+
+	// 未设置产物存储时声明产物的步骤失败
+	executor.SetArtifactStore(nil)
+	wf.Steps[0].Config["artifact"] = map[string]interface{}{"name": "report.md"}
+	execution, err = executor.Execute(context.Background(), wf, nil)
+	if err == nil || !strings.Contains(execution.GetStepState("write").Error, "no artifact store") {
+		t.Errorf("Expected missing store error, got %v", err)
+	}
+
+	// 声明缺少名称时定义校验失败
+	wf.Steps[0].Config["artifact"] = map[string]interface{}{"field": "report"}
+	if err := wf.Validate(); err == nil || !strings.Contains(err.Error(), "requires name") {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+}
+
+// TestExecutionTenancy 测试执行属于工作流的租户：task步骤只使用对租户可见的Agent，同时运行的执行数受配额限制
+func TestExecutionTenancy(t *testing.T) {
+	registry := aiagentorchestrator.NewAgentRegistry()
+	registry.Register(&aiagentorchestrator.AgentInfo{Name: "shared", Capabilities: []string{"search"}})
+	registry.Register(&aiagentorchestrator.AgentInfo{Name: "acme::bot", Capabilities: []string{"search"}, Tenant: "acme"})
+	executor := NewExecutor(registry, nil)
+
+	newWorkflow := func(tenantID, agent string) *Workflow {
+		wf := NewWorkflow("tenant-"+tenantID, "租户工作流")
+		wf.Tenant = tenantID
+		step := &Step{ID: "run", Name: "run", Type: "task", Agent: agent}
+		if agent == "" {
+			step.Tool = "search"
+		}
+		wf.AddStep(step)
+		return wf
+	}
+
+	execution, err := executor.Execute(context.Background(), newWorkflow("acme", "bot"), nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if execution.Tenant != "acme" || !strings.Contains(fmt.Sprint(execution.GetStepState("run").Output), "acme::bot") {
+		t.Errorf("Expected step to run on acme::bot for tenant acme, got %s %v", execution.Tenant, execution.GetStepState("run").Output)
+	}
+	if _, err := executor.Execute(context.Background(), newWorkflow("beta", "acme::bot"), nil); err == nil {
+		t.Error("Expected other tenant's agent to be unavailable")
+	}
+
+	// 内置工作流未绑定租户时，执行属于请求的租户
+	ctx := tenant.WithTenant(context.Background(), "beta")
+	execution, err = executor.Execute(ctx, newWorkflow("", ""), nil)
+	if err != nil || execution.Tenant != "beta" || !strings.Contains(fmt.Sprint(execution.GetStepState("run").Output), "shared") {
+		t.Errorf("Expected execution on the shared agent for request tenant beta, got %v, %v", execution, err)
+	}
+
+	release := make(chan struct{})
+	limited := NewExecutor(nil, nil)
+	limited.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		<-release
+		return "done", nil
+	})
+	limited.SetQuotas(tenant.NewQuotasFromConfig(config.TenancyConfig{
+		Enabled: true,
+		Quotas:  config.TenantQuotasConfig{Default: config.TenantQuotaConfig{MaxExecutions: 1}},
+	}))
+	id, err := limited.Start(context.Background(), newWorkflow("acme", ""), nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := limited.Start(context.Background(), newWorkflow("acme", ""), nil); !errors.Is(err, tenant.ErrQuotaExceeded) {
+		t.Errorf("Expected executions quota error, got %v", err)
+	}
+	if _, err := limited.Start(context.Background(), newWorkflow("beta", ""), nil); err != nil {
+		t.Errorf("Other tenants should not be affected: %v", err)
+	}
+	close(release)
+
+	// 执行结束后配额释放
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		execution, err := limited.StateManager().GetExecution(id)
+		if err == nil && execution.Status != WorkflowStatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Execution did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := limited.Execute(context.Background(), newWorkflow("acme", ""), nil); err != nil {
+		t.Errorf("Expected quota to be released after the execution finished, got %v", err)
+	}
+}
+
+// TestExecutorLeaderElection 测试启用选主时只有主实例启动工作流执行
+func TestExecutorLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	lock := aiagentorchestrator.NewMemoryLeaderLock(nil)
+	leader := aiagentorchestrator.NewLeaderElector(lock, "leader", time.Minute)
+	follower := aiagentorchestrator.NewLeaderElector(lock, "follower", time.Minute)
+	leader.Campaign(ctx)
+	follower.Campaign(ctx)
+
+	executor := NewExecutor(nil, nil)
+	executor.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		return "done", nil
+	})
+	wf := NewWorkflow("ha", "选主")
+	wf.AddStep(&Step{ID: "run", Name: "run", Type: "task", Agent: "researcher"})
+
+	executor.SetLeaderElector(follower)
+	if _, err := executor.Execute(ctx, wf, nil); !errors.Is(err, aiagentorchestrator.ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader from Execute, got %v", err)
+	}
+	if _, err := executor.Start(ctx, wf, nil); !errors.Is(err, aiagentorchestrator.ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader from Start, got %v", err)
+	}
+	if _, err := executor.Restore(ctx, &RuntimeSnapshot{Version: RuntimeSnapshotVersion}); !errors.Is(err, aiagentorchestrator.ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader from Restore, got %v", err)
+	}
+	if executions := executor.StateManager().GetAllExecutions(); len(executions) != 0 {
+		t.Errorf("Follower should not register executions, got %d", len(executions))
+	}
+
+	executor.SetLeaderElector(leader)
+	if execution, err := executor.Execute(ctx, wf, nil); err != nil || execution.Status != WorkflowStatusCompleted {
+		t.Errorf("Expected leader to run the workflow, got %v", err)
+	}
+}
+
+// failingRepository 保存总是失败的工作流存储
+type failingRepository struct {
+	*MemoryRepository
+}
+
+func (r failingRepository) Save(ctx context.Context, workflow *Workflow) error {
+	return errors.New("disk full")
+}
+
+// TestWorkflowBundle 测试工作流包的导出、导入、冲突检测和安装失败时的回滚
+func TestWorkflowBundle(t *testing.T) {
+	source := NewExecutor(nil, nil)
+	source.Prompts().Put("summary", &PromptTemplate{Name: "summary", Template: "Summarize: {{topic}}"})
+	source.ToolChains().Put("copy", &ToolChainDefinition{Name: "copy", Steps: []ToolChainStep{
+		{Tool: "file_ops", Operation: "read", Params: map[string]interface{}{"path": "{{dir}}/in.txt"}},
+		{Tool: "file_ops", Operation: "write", Params: map[string]interface{}{"path": "{{dir}}/out.txt"}, InputFrom: "previous"},
+	}})
+	sourceRepo := NewMemoryRepository()
+	exporter := NewBundleManager(sourceRepo, source.Prompts(), source.ToolChains())
+	exporter.SetPersonaResolver(func(agent string) (*PersonaRef, bool) {
+		return &PersonaRef{Agent: agent, Name: "Writer", Model: "gpt-4o"}, agent == "writer"
+	})
+
+	acme := tenant.WithTenant(context.Background(), "acme")
+	wf, err := NewParser("").ParseDefinition(map[string]interface{}{
+		"name": "shared",
+		"steps": []interface{}{
+			map[string]interface{}{"id": "draft", "agent": "writer"},
+			map[string]interface{}{"id": "summarize", "type": "llm", "depends_on": []interface{}{"draft"},
+				"config": map[string]interface{}{"model": "fake", "prompt_template": "summary"}},
+			map[string]interface{}{"id": "copy", "type": "tool", "depends_on": []interface{}{"summarize"},
+				"config": map[string]interface{}{"chain": "copy"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	wf.Tenant = "acme"
+	sourceRepo.Save(acme, wf)
+
+	if _, err := exporter.Export(context.Background(), wf.ID); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("Expected other tenants to get ErrWorkflowNotFound, got %v", err)
+	}
+	exported, err := exporter.Export(acme, wf.ID)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if exported.Workflow.Tenant != "" || len(exported.Prompts) != 1 || len(exported.ToolChains) != 1 || len(exported.Personas) != 1 {
+		t.Fatalf("Unexpected bundle: %+v", exported)
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	// 导入另一个环境：模板和工具链一起安装，工作流属于导入的租户，人设不一致时给出警告
+	target := NewExecutor(nil, nil)
+	targetRepo := NewMemoryRepository()
+	importer := NewBundleManager(targetRepo, target.Prompts(), target.ToolChains())
+	importer.SetPersonaResolver(func(agent string) (*PersonaRef, bool) {
+		return &PersonaRef{Agent: agent, Name: "Writer"}, agent == "writer"
+	})
+	beta := tenant.WithTenant(context.Background(), "beta")
+	result, err := importer.Import(beta, &bundle, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Replaced || len(result.Prompts) != 1 || len(result.ToolChains) != 1 || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "model") {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+	imported, err := targetRepo.Get(beta, wf.ID)
+	if err != nil || imported.Tenant != "beta" {
+		t.Fatalf("Expected imported workflow owned by beta, got %v, %v", imported, err)
+	}
+
+	// 导入的工作流引用的模板和工具链在目标环境中可以执行
+	manager, err := llm.NewModelManager(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewModelManager failed: %v", err)
+	}
+	model := &promptModel{completion: "short summary"}
+	manager.RegisterModel("fake", model)
+	tools := &fakeTools{}
+	target.SetModelManager(manager)
+	target.SetToolManager(tools)
+	target.SetStepRunner(func(ctx context.Context, step *Step, inputs map[string]interface{}) (interface{}, error) {
+		return "draft", nil
+	})
+	if _, err := target.Execute(beta, imported, map[string]interface{}{"topic": "Go", "dir": "/tmp"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := model.prompts[0][0].Content; got != "Summarize: Go" {
+		t.Errorf("Expected prompt template to be rendered, got %q", got)
+	}
+	if len(tools.calls) != 2 || tools.calls[0]["path"] != "/tmp/in.txt" || tools.calls[1]["input"] == nil {
+		t.Errorf("Unexpected tool chain calls: %v", tools.calls)
+	}
+
+	// 已存在的工作流需要覆盖，内容相同的模板和工具链不重复安装
+	if _, err := importer.Import(beta, &bundle, ImportOptions{}); !errors.Is(err, ErrBundleConflict) {
+		t.Errorf("Expected ErrBundleConflict for existing workflow, got %v", err)
+	}
+	if _, err := importer.Import(acme, &bundle, ImportOptions{Overwrite: true}); !errors.Is(err, ErrBundleConflict) {
+		t.Errorf("Expected ErrBundleConflict for another tenant's workflow id, got %v", err)
+	}
+	result, err = importer.Import(beta, &bundle, ImportOptions{Overwrite: true})
+	if err != nil || !result.Replaced || len(result.Prompts) != 0 || len(result.ToolChains) != 0 {
+		t.Errorf("Unexpected overwrite result: %+v, %v", result, err)
+	}
+
+	// 内容不同的同名模板未指定覆盖时整个包不安装
+	changed := bundle
+	changed.Workflow = &Workflow{}
+	*changed.Workflow = *bundle.Workflow
+	changed.Workflow.ID = "wf-changed"
+	changed.Prompts = []*PromptTemplate{{Name: "summary", Template: "TL;DR {{topic}}"}}
+	if _, err := importer.Import(beta, &changed, ImportOptions{}); !errors.Is(err, ErrBundleConflict) {
+		t.Errorf("Expected ErrBundleConflict for changed prompt template, got %v", err)
+	}
+	if _, err := targetRepo.Get(beta, "wf-changed"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("Conflicting bundle should not install the workflow, got %v", err)
+	}
+
+	// 引用缺失的工具链或版本不支持时包无效
+	missing := changed
+	missing.Prompts = nil
+	missing.ToolChains = nil
+	fresh := NewExecutor(nil, nil)
+	if _, err := NewBundleManager(NewMemoryRepository(), fresh.Prompts(), fresh.ToolChains()).Import(beta, &missing, ImportOptions{}); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for missing references, got %v", err)
+	}
+	unsupported := bundle
+	unsupported.Version = BundleVersion + 1
+	if _, err := importer.Import(beta, &unsupported, ImportOptions{Overwrite: true}); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for unsupported version, got %v", err)
+	}
+
+	// 保存工作流失败时撤销已安装的模板和工具链
+	rollback := NewExecutor(nil, nil)
+	failing := NewBundleManager(failingRepository{NewMemoryRepository()}, rollback.Prompts(), rollback.ToolChains())
+	if _, err := failing.Import(beta, &bundle, ImportOptions{}); err == nil {
+		t.Fatal("Expected import to fail when the workflow cannot be saved")
+	}
+	if len(rollback.Prompts().List()) != 0 || len(rollback.ToolChains().List()) != 0 {
+		t.Errorf("Expected prompts and tool chains to be rolled back, got %d and %d", len(rollback.Prompts().List()), len(rollback.ToolChains().List()))
+	}
+}
+
+// TestFileRepository 测试工作流定义的保存、删除与重启加载
+func TestFileRepository(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatalf("NewFileRepository failed: %v", err)
+	}
+	kept, removed := ReportWorkflow(), NewWorkflow("temp", "")
+	repo.Save(ctx, kept)
+	repo.Save(ctx, removed)
+	if err := repo.Delete(ctx, removed.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ctx, removed.ID); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("Expected ErrWorkflowNotFound, got %v", err)
+	}
+
+	reopened, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	workflows, _ := reopened.List(ctx)
+	if len(workflows) != 1 || workflows[0].ID != kept.ID || len(workflows[0].Steps) != 3 {
+		t.Errorf("Unexpected workflows after reopen: %+v", workflows)
+	}
+	if _, err := reopened.Get(ctx, removed.ID); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("Deleted workflow should not be loaded, got %v", err)
+	}
+}
+
 // Helper function
 func indexOf(slice []string, item string) int {
 	for i, s := range slice {
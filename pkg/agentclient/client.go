@@ -0,0 +1,232 @@
+// Package agentclient 远程Agent的gRPC客户端
+// 远程Agent通过它向编排器注册、定期发送心跳、接收TaskScheduler分配的任务并上报进度和结果
+package agentclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	agentv1 "ai-agent-assistant/api/proto/agent/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultHeartbeatInterval 编排器未返回心跳间隔时使用
+const defaultHeartbeatInterval = 10 * time.Second
+
+// ReportFunc 上报任务进度，percent为0-100
+type ReportFunc func(percent float64, message string)
+
+// Handler 执行分配的任务，返回值作为任务结果上报
+// 任务被取消、超时或客户端停止时ctx结束
+type Handler func(ctx context.Context, task *agentv1.Task, report ReportFunc) (interface{}, error)
+
+// Options 远程Agent的注册信息
+type Options struct {
+	Name         string
+	Type         string // expert, general, custom，为空时编排器使用custom
+	Capabilities []string
+	Endpoint     string // 为空时编排器使用连接的对端地址
+	Metadata     map[string]string
+	APIKey       string // 需要agents:connect权限，编排器未启用认证时可为空
+}
+
+// Client 远程Agent客户端
+type Client struct {
+	client agentv1.AgentServiceClient
+	opts   Options
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // 任务ID -> 取消执行
+	wg      sync.WaitGroup
+}
+
+// New 在已建立的gRPC连接上创建客户端
+func New(conn grpc.ClientConnInterface, opts Options) *Client {
+	return &Client{
+		client:  agentv1.NewAgentServiceClient(conn),
+		opts:    opts,
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// Run 注册Agent并接收任务，直到ctx结束或任务流断开
+// ctx结束时取消执行中的任务并返回nil；任务流断开时返回错误，调用方可重新调用Run重连
+func (c *Client) Run(ctx context.Context, handler Handler) error {
+	if c.opts.Name == "" {
+		return errors.New("agent name is required")
+	}
+	ctx = c.outgoing(ctx)
+
+	resp, err := c.client.Register(ctx, &agentv1.RegisterRequest{
+		Name:         c.opts.Name,
+		Type:         c.opts.Type,
+		Capabilities: c.opts.Capabilities,
+		Endpoint:     c.opts.Endpoint,
+		Metadata:     c.opts.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		c.wg.Wait()
+	}()
+
+	interval := time.Duration(resp.GetHeartbeatIntervalSeconds()) * time.Second
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	go c.heartbeat(ctx, interval)
+
+	stream, err := c.client.ReceiveTasks(ctx, &agentv1.ReceiveTasksRequest{Name: c.opts.Name})
+	if err != nil {
+		return fmt.Errorf("failed to receive tasks: %w", err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("task stream closed: %w", err)
+		}
+
+		switch e := event.GetEvent().(type) {
+		case *agentv1.TaskEvent_Assigned:
+			c.start(ctx, e.Assigned, handler)
+		case *agentv1.TaskEvent_Cancelled:
+			c.cancel(e.Cancelled.GetTaskId())
+		}
+	}
+}
+
+// Unregister 从编排器注销Agent
+func (c *Client) Unregister(ctx context.Context) error {
+	_, err := c.client.Unregister(c.outgoing(ctx), &agentv1.UnregisterRequest{Name: c.opts.Name})
+	return err
+}
+
+// outgoing 附加API Key元数据
+func (c *Client) outgoing(ctx context.Context) context.Context {
+	if c.opts.APIKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", c.opts.APIKey)
+}
+
+// heartbeat 定期发送心跳，单次失败不中断，任务流断开时Run会返回
+func (c *Client) heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.client.Heartbeat(ctx, &agentv1.HeartbeatRequest{Name: c.opts.Name})
+		}
+	}
+}
+
+// start 在后台执行任务，任务有截止时间时按截止时间取消
+func (c *Client) start(ctx context.Context, task *agentv1.Task, handler Handler) {
+	var taskCtx context.Context
+	var cancel context.CancelFunc
+	if deadline := task.GetDeadline(); deadline != nil {
+		taskCtx, cancel = context.WithDeadline(ctx, deadline.AsTime())
+	} else {
+		taskCtx, cancel = context.WithCancel(ctx)
+	}
+
+	c.mu.Lock()
+	c.running[task.GetTaskId()] = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.cancel(task.GetTaskId())
+		c.execute(ctx, taskCtx, task, handler)
+	}()
+}
+
+// cancel 取消执行中的任务
+func (c *Client) cancel(taskID string) {
+	c.mu.Lock()
+	cancel, ok := c.running[taskID]
+	delete(c.running, taskID)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// execute 执行任务并通过一个ReportProgress流上报进度和结果
+// 任务被取消或超时后编排器已不再等待结果，不再上报
+func (c *Client) execute(ctx, taskCtx context.Context, task *agentv1.Task, handler Handler) {
+	stream, err := c.client.ReportProgress(ctx)
+	if err != nil {
+		return
+	}
+	defer stream.CloseAndRecv()
+
+	var mu sync.Mutex
+	report := func(percent float64, message string) {
+		if taskCtx.Err() != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		stream.Send(&agentv1.TaskProgress{
+			Name:    c.opts.Name,
+			TaskId:  task.GetTaskId(),
+			Percent: percent,
+			Message: message,
+		})
+	}
+
+	output, err := handler(taskCtx, task, report)
+	if taskCtx.Err() != nil {
+		return
+	}
+
+	result := &agentv1.TaskResult{}
+	if err != nil {
+		result.Error = err.Error()
+	} else if result.Output, err = toValue(output); err != nil {
+		result.Error = fmt.Sprintf("failed to encode task output: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stream.Send(&agentv1.TaskProgress{
+		Name:    c.opts.Name,
+		TaskId:  task.GetTaskId(),
+		Percent: 100,
+		Result:  result,
+	})
+}
+
+// toValue 任务输出转换为protobuf Value，结构体等类型先经过JSON编码
+func toValue(v interface{}) (*structpb.Value, error) {
+	if value, err := structpb.NewValue(v); err == nil {
+		return value, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
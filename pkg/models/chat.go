@@ -33,6 +33,15 @@ type Message struct {
 	Role    string `json:"role"`    // user, assistant, system, tool
 	Content string `json:"content"`
 	ToolID  string `json:"tool_id,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // 助手消息触发的工具调用（仅用于记录和导出）
+	Citations []Citation `json:"citations,omitempty"`  // 回答引用的RAG检索结果（仅用于记录和导出）
+}
+
+// Citation RAG引用
+type Citation struct {
+	Index   int    `json:"index"` // 上下文中的编号，从1开始
+	Content string `json:"content"`
+	Source  string `json:"source,omitempty"`
 }
 
 // Session 会话